@@ -0,0 +1,175 @@
+// journalarchive 资金流水归档运维工具 (fund.JournalArchiver)：手动归档一轮、查清单、恢复
+//
+//	go run ./cmd/journalarchive -dsn "$MYSQL_DSN" -s3-endpoint http://minio:9000 -s3-bucket cex-archive run
+//	go run ./cmd/journalarchive ... -table journal_005 -from 2026-01-01 -to 2026-02-01 list
+//	go run ./cmd/journalarchive ... -id 42 -into journal_restore restore   # 恢复到单独的表排查
+//	go run ./cmd/journalarchive ... -id 42 -yes restore                    # 写回原表
+//	go run ./cmd/journalarchive ... -user 10086 checkpoints
+//
+// S3 密钥读环境变量 S3_ACCESS_KEY / S3_SECRET_KEY。
+// 常驻归档由资金服务里的 JournalArchiver.Start 负责，这里的 run 用于首次上线清存量和补跑
+//
+// 退出码：0 成功，1 执行失败，2 参数错误
+//
+// 【注意】restore 不带 -into 会写回原分片表，下一轮归档会再次搬走，必须显式加 -yes
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/walstore"
+)
+
+func main() {
+	var (
+		dsn         = flag.String("dsn", os.Getenv("MYSQL_DSN"), "资产库 MySQL DSN (默认读环境变量 MYSQL_DSN)")
+		singleTable = flag.Bool("single-table", false, "流水单表 journals (开发环境)")
+		endpoint    = flag.String("s3-endpoint", "", "S3 兼容对象存储地址")
+		region      = flag.String("s3-region", "", "S3 区域 (默认 us-east-1)")
+		bucket      = flag.String("s3-bucket", "", "S3 存储桶")
+		prefix      = flag.String("prefix", "fund/journal/", "归档对象 key 前缀")
+		retention   = flag.Duration("retention", 90*24*time.Hour, "run: 库里保留最近多久的流水")
+		batch       = flag.Int("batch", 5000, "run: 每个对象的行数")
+		table       = flag.String("table", "", "list: 只看某张流水表")
+		from        = flag.String("from", "", "list: 起始日期 YYYY-MM-DD (UTC，含)")
+		to          = flag.String("to", "", "list: 结束日期 YYYY-MM-DD (UTC，不含)")
+		id          = flag.Int64("id", 0, "restore: 清单 ID")
+		into        = flag.String("into", "", "restore: 写入的表，默认写回原表")
+		yes         = flag.Bool("yes", false, "restore: 确认写回原表")
+		user        = flag.Int64("user", 0, "checkpoints: 用户 ID")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: journalarchive [flags] run|list|restore|checkpoints")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *dsn == "" {
+		fail(2, "-dsn or MYSQL_DSN is required")
+	}
+
+	db, err := gorm.Open(mysql.Open(*dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		fail(1, "connect:", err)
+	}
+	repo := fund.NewBalanceRepo(db)
+	if *singleTable {
+		repo = fund.NewSingleTableBalanceRepo(db)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cmd := flag.Arg(0)
+	if cmd == "checkpoints" {
+		if *user <= 0 {
+			fail(2, "-user is required")
+		}
+		list, err := repo.JournalCheckpoints(ctx, *user)
+		if err != nil {
+			fail(1, err)
+		}
+		for _, cp := range list {
+			fmt.Printf("%-8s  available=%d locked=%d  last_journal=%d  as_of=%s\n",
+				cp.Symbol, cp.Available, cp.Locked, cp.LastJournalID, formatMillis(cp.AsOf))
+		}
+		return
+	}
+
+	if *endpoint == "" || *bucket == "" {
+		fail(2, "-s3-endpoint and -s3-bucket are required")
+	}
+	objects, err := walstore.NewS3(walstore.S3Config{
+		Endpoint:  *endpoint,
+		Region:    *region,
+		Bucket:    *bucket,
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	})
+	if err != nil {
+		fail(2, err)
+	}
+	archiver := fund.NewJournalArchiver(repo, fund.JournalArchiveConfig{
+		Remote:    walstore.Remote{Objects: objects, Prefix: *prefix},
+		Retention: *retention,
+		BatchSize: *batch,
+	})
+
+	switch cmd {
+	case "run":
+		start := time.Now()
+		n, err := archiver.Run(ctx)
+		st := archiver.Stats()
+		fmt.Printf("journalarchive: archived %d journal(s) into %d object(s) in %s\n",
+			n, st.Objects, time.Since(start).Round(time.Millisecond))
+		if err != nil {
+			fail(1, err)
+		}
+	case "list":
+		start, err := parseDay(*from)
+		if err != nil {
+			fail(2, "-from:", err)
+		}
+		end, err := parseDay(*to)
+		if err != nil {
+			fail(2, "-to:", err)
+		}
+		entries, err := archiver.ListArchives(ctx, *table, start, end)
+		if err != nil {
+			fail(1, err)
+		}
+		for _, e := range entries {
+			restored := ""
+			if e.RestoredAt > 0 {
+				restored = "  restored " + formatMillis(e.RestoredAt)
+			}
+			fmt.Printf("%6d  %-12s  id %d-%d  %6d rows  %s ~ %s  %s%s\n",
+				e.ID, e.SourceTable, e.FirstID, e.LastID, e.Records,
+				formatMillis(e.FromTime), formatMillis(e.ToTime), e.ObjectKey, restored)
+		}
+		fmt.Printf("journalarchive: %d archive(s)\n", len(entries))
+	case "restore":
+		if *id <= 0 {
+			fail(2, "-id is required")
+		}
+		if *into == "" && !*yes {
+			fail(2, "restoring into the source table is re-archived on the next run; pass -into or -yes")
+		}
+		n, err := archiver.Restore(ctx, *id, *into)
+		if err != nil {
+			fail(1, err)
+		}
+		fmt.Printf("journalarchive: restored %d journal(s) from archive %d\n", n, *id)
+	default:
+		fail(2, "unknown command", cmd)
+	}
+}
+
+// parseDay 空串表示不限
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.UTC)
+}
+
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+func fail(code int, args ...any) {
+	fmt.Fprintln(os.Stderr, append([]any{"journalarchive:"}, args...)...)
+	os.Exit(code)
+}
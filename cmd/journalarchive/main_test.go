@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDay(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false}, // 不限
+		{"2026-01-01", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2026-1-1", time.Time{}, true},
+		{"2026-01-01T00:00:00Z", time.Time{}, true},
+	} {
+		got, err := parseDay(tc.in)
+		if (err != nil) != tc.wantErr || !got.Equal(tc.want) {
+			t.Errorf("parseDay(%q) = %v, %v; want %v, err=%v", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+	if got := formatMillis(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC).UnixMilli()); got != "2026-01-01T08:00:00Z" {
+		t.Errorf("formatMillis = %s", got)
+	}
+}
@@ -0,0 +1,407 @@
+// loadgen 全链路压测：按配置生成合成订单流 (下单速率、撤单比例、交易对权重、用户分布)，
+// 打到进程内的撮合 + 资产 + 现货结算引擎 (或将来的 gRPC 接口)，
+// 报告吞吐、确认延迟分位和结算滞后，用来验证基准测试推出的 10 万 TPS 目标。
+//
+//	go run ./cmd/loadgen -rate 100000 -duration 30s -symbols BTC_USDT:7,ETH_USDT:3 \
+//	    -users 100000 -user-dist zipf -cancel-ratio 0.3
+//
+// 【注意】基准测试只测撮合线程，这里测的是整条链路：资产冻结 → 撮合 → 事件分发 → 结算，
+// 瓶颈通常在冻结 (每单一次分片命令往返) 和结算 handler
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+type config struct {
+	target      string
+	rate        int
+	duration    time.Duration
+	workers     int
+	cancelRatio float64
+	takerRatio  float64
+	symbols     []weightedSymbol
+	users       int
+	userDist    string
+	zipfS       float64
+	report      time.Duration
+	drain       time.Duration
+	seed        int64
+}
+
+type weightedSymbol struct {
+	symbol string
+	weight int
+}
+
+// parseSymbolMix 解析 "BTC_USDT:7,ETH_USDT:3"，省略权重视为 1
+func parseSymbolMix(s string) ([]weightedSymbol, error) {
+	var out []weightedSymbol
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, w, hasWeight := strings.Cut(part, ":")
+		ws := weightedSymbol{symbol: name, weight: 1}
+		if hasWeight {
+			n, err := strconv.Atoi(w)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", part)
+			}
+			ws.weight = n
+		}
+		if _, _, ok := cutSymbol(name); !ok {
+			return nil, fmt.Errorf("invalid symbol %q, want BASE_QUOTE", name)
+		}
+		out = append(out, ws)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no symbols")
+	}
+	return out, nil
+}
+
+func parseFlags() (config, error) {
+	var cfg config
+	var symbols string
+	flag.StringVar(&cfg.target, "target", "inprocess", "压测目标: inprocess | grpc")
+	flag.IntVar(&cfg.rate, "rate", 50000, "目标速率 (下单+撤单 / 秒)")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "压测时长")
+	flag.IntVar(&cfg.workers, "workers", 8, "发单 goroutine 数")
+	flag.Float64Var(&cfg.cancelRatio, "cancel-ratio", 0.2, "撤单占全部请求的比例")
+	flag.Float64Var(&cfg.takerRatio, "taker-ratio", 0.3, "下单中穿价吃单的比例，其余挂在盘口附近")
+	flag.StringVar(&symbols, "symbols", "BTC_USDT:7,ETH_USDT:3", "交易对及权重")
+	flag.IntVar(&cfg.users, "users", 10000, "用户数 (全部预先充值)")
+	flag.StringVar(&cfg.userDist, "user-dist", "uniform", "用户分布: uniform | zipf (少数用户贡献大部分订单)")
+	flag.Float64Var(&cfg.zipfS, "zipf-s", 1.1, "zipf 分布参数 s (>1，越大越集中)")
+	flag.DurationVar(&cfg.report, "report", time.Second, "进度输出间隔")
+	flag.DurationVar(&cfg.drain, "drain", 5*time.Second, "停止发单后等待结算追平的最长时间")
+	flag.Int64Var(&cfg.seed, "seed", 1, "随机种子")
+	flag.Parse()
+
+	var err error
+	if cfg.symbols, err = parseSymbolMix(symbols); err != nil {
+		return cfg, err
+	}
+	switch {
+	case cfg.rate <= 0:
+		return cfg, fmt.Errorf("-rate must be positive")
+	case cfg.workers <= 0:
+		return cfg, fmt.Errorf("-workers must be positive")
+	case cfg.users <= 1:
+		return cfg, fmt.Errorf("-users must be at least 2")
+	case cfg.cancelRatio < 0 || cfg.cancelRatio >= 1:
+		return cfg, fmt.Errorf("-cancel-ratio must be in [0, 1)")
+	case cfg.takerRatio < 0 || cfg.takerRatio > 1:
+		return cfg, fmt.Errorf("-taker-ratio must be in [0, 1]")
+	case cfg.userDist != "uniform" && cfg.userDist != "zipf":
+		return cfg, fmt.Errorf("-user-dist must be uniform or zipf")
+	case cfg.userDist == "zipf" && cfg.zipfS <= 1:
+		return cfg, fmt.Errorf("-zipf-s must be > 1")
+	}
+	return cfg, nil
+}
+
+// =============================================================================
+// 订单生成
+// =============================================================================
+
+// 价格以 midPrice 为中心：挂单离中间价 1~priceLevels 档，吃单穿过 priceLevels 档
+// 整数价格，避免现货结算 quoteAmount = Price/Precision*Qty 截断带来的冻结误差
+const (
+	midPrice    = 1000 * asset.Precision
+	tickSize    = asset.Precision
+	priceLevels = 20
+	minQty      = asset.Precision / 100 // 0.01
+	maxQtySteps = 10
+)
+
+// 每个 worker 记住最近下的订单，撤单从中随机挑选
+const recentOrders = 1024
+
+type placedOrder struct {
+	symbol string
+	id     int64
+}
+
+// generator 单个 worker 的订单生成器 (非并发安全)
+type generator struct {
+	cfg         *config
+	rng         *rand.Rand
+	zipf        *rand.Zipf
+	totalWeight int
+	recent      [recentOrders]placedOrder
+	recentN     int
+}
+
+func newGenerator(cfg *config, seed int64) *generator {
+	g := &generator{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+	for _, s := range cfg.symbols {
+		g.totalWeight += s.weight
+	}
+	if cfg.userDist == "zipf" {
+		g.zipf = rand.NewZipf(g.rng, cfg.zipfS, 1, uint64(cfg.users-1))
+	}
+	return g
+}
+
+func (g *generator) symbol() string {
+	n := g.rng.Intn(g.totalWeight)
+	for _, s := range g.cfg.symbols {
+		if n < s.weight {
+			return s.symbol
+		}
+		n -= s.weight
+	}
+	return g.cfg.symbols[len(g.cfg.symbols)-1].symbol
+}
+
+func (g *generator) user() int64 {
+	if g.zipf != nil {
+		return int64(g.zipf.Uint64()) + 1
+	}
+	return g.rng.Int63n(int64(g.cfg.users)) + 1
+}
+
+func (g *generator) order(id int64) *mtrade.Order {
+	side := mtrade.SideBuy
+	if g.rng.Intn(2) == 0 {
+		side = mtrade.SideSell
+	}
+	var offset int64
+	if g.rng.Float64() < g.cfg.takerRatio {
+		offset = -priceLevels // 穿价
+	} else {
+		offset = 1 + g.rng.Int63n(priceLevels)
+	}
+	price := midPrice - int64(side)*offset*tickSize
+
+	return &mtrade.Order{
+		ID:     id,
+		UserID: g.user(),
+		Symbol: g.symbol(),
+		Side:   side,
+		Type:   mtrade.OrderTypeLimit,
+		Price:  price,
+		Qty:    minQty * (1 + g.rng.Int63n(maxQtySteps)),
+	}
+}
+
+func (g *generator) remember(o placedOrder) {
+	g.recent[g.recentN%recentOrders] = o
+	g.recentN++
+}
+
+// pickCancel 随机挑一个最近下的订单 (可能已经成交，撤单失败也计入统计)
+func (g *generator) pickCancel() (placedOrder, bool) {
+	n := min(g.recentN, recentOrders)
+	if n == 0 {
+		return placedOrder{}, false
+	}
+	return g.recent[g.rng.Intn(n)], true
+}
+
+// =============================================================================
+// 发单
+// =============================================================================
+
+// clientStats 发单侧计数
+type clientStats struct {
+	placed       atomic.Int64 // PlaceOrder 成功
+	placeErrors  atomic.Int64 // PlaceOrder 同步失败 (冻结失败、队列满等)
+	cancels      atomic.Int64 // CancelOrder 提交成功
+	cancelMisses atomic.Int64 // CancelOrder 提交失败
+}
+
+func (s *clientStats) requests() int64 {
+	return s.placed.Load() + s.placeErrors.Load() + s.cancels.Load() + s.cancelMisses.Load()
+}
+
+// runWorker 按 rate 匀速发单直到 ctx 结束
+//
+// 【设计】按"开始以来应发数量"补齐而不是固定 ticker 间隔：
+// 单次调用变慢时后面会追赶，实际速率低于目标说明链路已经饱和
+func runWorker(ctx context.Context, target Target, g *generator, rate float64, nextID *atomic.Int64, stats *clientStats) {
+	start := time.Now()
+	var sent int64
+	for ctx.Err() == nil {
+		due := int64(time.Since(start).Seconds() * rate)
+		if sent >= due {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		for ; sent < due && ctx.Err() == nil; sent++ {
+			if g.rng.Float64() < g.cfg.cancelRatio {
+				if o, ok := g.pickCancel(); ok {
+					if target.CancelOrder(o.symbol, o.id) {
+						stats.cancels.Add(1)
+					} else {
+						stats.cancelMisses.Add(1)
+					}
+					continue
+				}
+			}
+			order := g.order(nextID.Add(1))
+			order.CreatedAt = time.Now().UnixNano()
+			if err := target.PlaceOrder(order); err != nil {
+				stats.placeErrors.Add(1)
+				continue
+			}
+			stats.placed.Add(1)
+			g.remember(placedOrder{symbol: order.Symbol, id: order.ID})
+		}
+	}
+}
+
+// =============================================================================
+// main
+// =============================================================================
+
+func main() {
+	cfg, err := parseFlags()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(2)
+	}
+	target, err := newTarget(cfg.target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	symbols := make([]string, len(cfg.symbols))
+	for i, s := range cfg.symbols {
+		symbols[i] = s.symbol
+	}
+	users := make([]int64, cfg.users)
+	for i := range users {
+		users[i] = int64(i + 1)
+	}
+
+	fmt.Printf("loadgen: target=%s rate=%d/s duration=%s workers=%d symbols=%v users=%d (%s) cancel=%.2f taker=%.2f\n",
+		cfg.target, cfg.rate, cfg.duration, cfg.workers, symbols, cfg.users, cfg.userDist, cfg.cancelRatio, cfg.takerRatio)
+	setupStart := time.Now()
+	if err := target.Setup(ctx, symbols, users); err != nil {
+		target.Close()
+		fmt.Fprintln(os.Stderr, "loadgen: setup:", err)
+		os.Exit(1)
+	}
+	defer target.Close()
+	fmt.Printf("loadgen: setup done in %s\n", time.Since(setupStart).Round(time.Millisecond))
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	var (
+		stats  clientStats
+		nextID atomic.Int64
+		wg     sync.WaitGroup
+	)
+	perWorker := float64(cfg.rate) / float64(cfg.workers)
+	start := time.Now()
+	for i := 0; i < cfg.workers; i++ {
+		g := newGenerator(&cfg, cfg.seed+int64(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(runCtx, target, g, perWorker, &nextID, &stats)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	progress(runCtx, done, cfg.report, start, target, &stats)
+	elapsed := time.Since(start)
+
+	// 停止发单后等结算 handler 追平，再出最终报告
+	drained := waitDrain(ctx, target, cfg.drain)
+	report(elapsed, drained, target.Snapshot(), &stats)
+}
+
+// progress 周期输出区间速率，直到所有 worker 退出
+func progress(ctx context.Context, done <-chan struct{}, interval time.Duration, start time.Time, target Target, stats *clientStats) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastReq, lastTrades int64
+	last := start
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			snap := target.Snapshot()
+			req := stats.requests()
+			secs := now.Sub(last).Seconds()
+			fmt.Printf("[%6.1fs] req/s=%-8.0f trades/s=%-8.0f accept p99=%-10s settle backlog=%-6d lag=%s\n",
+				now.Sub(start).Seconds(),
+				float64(req-lastReq)/secs,
+				float64(snap.Trades-lastTrades)/secs,
+				snap.AcceptLatency.P99,
+				snap.SettleBacklog,
+				snap.SettleLag.P99)
+			lastReq, lastTrades, last = req, snap.Trades, now
+		}
+	}
+}
+
+// waitDrain 等待结算队列清空，超时返回 false
+func waitDrain(ctx context.Context, target Target, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		if target.Snapshot().SettleBacklog == 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return target.Snapshot().SettleBacklog == 0
+}
+
+func report(elapsed time.Duration, drained bool, snap TargetStats, stats *clientStats) {
+	secs := elapsed.Seconds()
+	req := stats.requests()
+	fmt.Println()
+	fmt.Println("==================== loadgen report ====================")
+	fmt.Printf("elapsed            %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("requests           %d (%.0f/s)\n", req, float64(req)/secs)
+	fmt.Printf("  orders placed    %d (%.0f/s)\n", stats.placed.Load(), float64(stats.placed.Load())/secs)
+	fmt.Printf("  place errors     %d\n", stats.placeErrors.Load())
+	fmt.Printf("  cancels          %d (missed %d)\n", stats.cancels.Load(), stats.cancelMisses.Load())
+	fmt.Printf("engine             accepted=%d rejected=%d canceled=%d\n", snap.Accepted, snap.Rejected, snap.Canceled)
+	fmt.Printf("trades             %d (%.0f/s)\n", snap.Trades, float64(snap.Trades)/secs)
+	printPercentiles("accept latency", snap.AcceptLatency)
+	printPercentiles("trade event lag", snap.TradeLag)
+	printPercentiles("settle lag", snap.SettleLag)
+	fmt.Printf("settle max lag     %s\n", snap.SettleMaxLag)
+	if !drained {
+		fmt.Printf("settle backlog     %d events NOT drained\n", snap.SettleBacklog)
+	}
+}
+
+func printPercentiles(name string, p Percentiles) {
+	fmt.Printf("%-18s n=%d p50=%s p99=%s p999=%s max=%s\n", name, p.Samples, p.P50, p.P99, p.P999, p.Max)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/spot"
+)
+
+// =============================================================================
+// 压测目标
+// =============================================================================
+
+// Target 压测目标：进程内引擎，或将来的 gRPC 网关
+type Target interface {
+	// Setup 创建市场、给用户充值
+	Setup(ctx context.Context, symbols []string, users []int64) error
+	PlaceOrder(order *mtrade.Order) error
+	CancelOrder(symbol string, orderID int64) bool
+	// Snapshot 当前累计指标 (可在任意 goroutine 调用)
+	Snapshot() TargetStats
+	Close()
+}
+
+// TargetStats 目标侧观测到的指标
+type TargetStats struct {
+	Accepted int64
+	Rejected int64
+	Canceled int64
+	Trades   int64
+
+	AcceptLatency Percentiles // 下单 → 撮合确认 (EventOrderAccepted)
+	TradeLag      Percentiles // 成交 → 事件送达观察者
+	SettleLag     Percentiles // 结算处理器事件入队 → 处理完 (采样)
+	SettleMaxLag  time.Duration
+	SettleBacklog int // 结算处理器当前积压 (所有市场之和)
+}
+
+// Percentiles 延迟分位
+type Percentiles struct {
+	Samples             uint64
+	P50, P99, P999, Max time.Duration
+}
+
+func percentiles(h *mtrade.LatencyHistogram) Percentiles {
+	return Percentiles{
+		Samples: h.Count(),
+		P50:     h.Percentile(0.50),
+		P99:     h.Percentile(0.99),
+		P999:    h.Percentile(0.999),
+		Max:     h.Max(),
+	}
+}
+
+// newTarget 按名称创建目标
+func newTarget(name string) (Target, error) {
+	switch name {
+	case "inprocess":
+		return &inProcessTarget{}, nil
+	case "grpc":
+		return nil, errors.New("gRPC API is not available yet, use -target inprocess")
+	default:
+		return nil, fmt.Errorf("unknown target %q", name)
+	}
+}
+
+// =============================================================================
+// 进程内目标：资产引擎 + 每个市场一个撮合引擎和现货处理器
+// =============================================================================
+
+type inProcessMarket struct {
+	engine    *mtrade.Engine
+	processor *spot.SpotProcessor
+}
+
+type inProcessTarget struct {
+	assets  *asset.AccountEngine
+	markets map[string]*inProcessMarket
+
+	accepted atomic.Int64
+	rejected atomic.Int64
+	canceled atomic.Int64
+	trades   atomic.Int64
+
+	// 每个直方图只有一个写者：观察者 handler 各自一个 goroutine，
+	// 多市场共用时用 mu 串行化写入
+	mu            sync.Mutex
+	acceptLatency *mtrade.LatencyHistogram
+	tradeLag      *mtrade.LatencyHistogram
+	settleLag     *mtrade.LatencyHistogram
+
+	stopSampler chan struct{}
+	samplerDone chan struct{}
+}
+
+// 初始资金：足够压测期间不因余额不足被拒
+const (
+	quoteFunding = 1_000_000_000 * asset.Precision
+	baseFunding  = 10_000_000 * asset.Precision
+)
+
+func (t *inProcessTarget) Setup(ctx context.Context, symbols []string, users []int64) error {
+	t.markets = make(map[string]*inProcessMarket, len(symbols))
+	t.acceptLatency = mtrade.NewLatencyHistogram()
+	t.tradeLag = mtrade.NewLatencyHistogram()
+	t.settleLag = mtrade.NewLatencyHistogram()
+
+	cfg := asset.DefaultEngineConfig()
+	cfg.CommandQueueLen = 100000
+	t.assets = asset.NewEngine(cfg)
+	if err := t.assets.Start(); err != nil {
+		return err
+	}
+
+	funded := make(map[string]int64)
+	for _, symbol := range symbols {
+		base, quote, ok := cutSymbol(symbol)
+		if !ok {
+			return fmt.Errorf("invalid symbol %q", symbol)
+		}
+		funded[base] = baseFunding
+		funded[quote] = quoteFunding
+
+		engCfg := mtrade.DefaultEngineConfig(symbol)
+		engCfg.OrderQueueSize = 100000
+		engine, err := mtrade.NewEngine(engCfg)
+		if err != nil {
+			return err
+		}
+		processor := spot.NewSpotProcessor(spot.ProcessorConfig{
+			AssetEngine:  t.assets,
+			MatchEngine:  engine,
+			MakerFeeRate: 10,
+			TakerFeeRate: 20,
+		})
+		engine.OnEvent(t.observe)
+		engine.Start(ctx)
+		t.markets[symbol] = &inProcessMarket{engine: engine, processor: processor}
+	}
+
+	for _, uid := range users {
+		for sym, amount := range funded {
+			err := t.assets.ApplyBalanceChange(&asset.BalanceChangeEvent{
+				EventType: "DEPOSIT",
+				EventID:   fmt.Sprintf("loadgen_%d_%s", uid, sym),
+				UserID:    uid,
+				Symbol:    sym,
+				Amount:    amount,
+			})
+			if err != nil {
+				return fmt.Errorf("fund user %d %s: %w", uid, sym, err)
+			}
+		}
+	}
+
+	t.stopSampler = make(chan struct{})
+	t.samplerDone = make(chan struct{})
+	go t.sampleSettleLag()
+	return nil
+}
+
+// observe 观察者 handler，注册在现货处理器之后
+func (t *inProcessTarget) observe(e mtrade.Event) {
+	now := time.Now().UnixNano()
+	switch e.Type {
+	case mtrade.EventOrderAccepted:
+		t.accepted.Add(1)
+		if e.Order != nil && e.Order.CreatedAt > 0 {
+			t.mu.Lock()
+			t.acceptLatency.Record(time.Duration(now - e.Order.CreatedAt))
+			t.mu.Unlock()
+		}
+	case mtrade.EventOrderRejected:
+		t.rejected.Add(1)
+	case mtrade.EventOrderCanceled:
+		t.canceled.Add(1)
+	case mtrade.EventTrade:
+		t.trades.Add(1)
+		if e.Trade != nil && e.Trade.Timestamp > 0 {
+			t.mu.Lock()
+			t.tradeLag.Record(time.Duration(now - e.Trade.Timestamp))
+			t.mu.Unlock()
+		}
+	}
+}
+
+// sampleSettleLag 每 10ms 采样一次结算处理器 (第一个 handler) 的最近处理耗时
+//
+// 结算处理器是引擎内部的 handler，拿不到逐事件耗时，只能采样；
+// 最大值用 handler 自己统计的 MaxLag，不会漏掉毛刺
+func (t *inProcessTarget) sampleSettleLag() {
+	defer close(t.samplerDone)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopSampler:
+			return
+		case <-ticker.C:
+			for _, m := range t.markets {
+				if stats := m.engine.HandlerStats(); len(stats) > 0 && stats[0].Processed > 0 {
+					t.mu.Lock()
+					t.settleLag.Record(stats[0].LastLag)
+					t.mu.Unlock()
+				}
+			}
+		}
+	}
+}
+
+func (t *inProcessTarget) PlaceOrder(order *mtrade.Order) error {
+	m, ok := t.markets[order.Symbol]
+	if !ok {
+		return fmt.Errorf("unknown symbol %q", order.Symbol)
+	}
+	return m.processor.PlaceOrder(order)
+}
+
+func (t *inProcessTarget) CancelOrder(symbol string, orderID int64) bool {
+	m, ok := t.markets[symbol]
+	return ok && m.processor.CancelOrder(orderID)
+}
+
+func (t *inProcessTarget) Snapshot() TargetStats {
+	s := TargetStats{
+		Accepted: t.accepted.Load(),
+		Rejected: t.rejected.Load(),
+		Canceled: t.canceled.Load(),
+		Trades:   t.trades.Load(),
+	}
+	t.mu.Lock()
+	s.AcceptLatency = percentiles(t.acceptLatency)
+	s.TradeLag = percentiles(t.tradeLag)
+	s.SettleLag = percentiles(t.settleLag)
+	t.mu.Unlock()
+	for _, m := range t.markets {
+		if stats := m.engine.HandlerStats(); len(stats) > 0 {
+			s.SettleBacklog += stats[0].QueueLen
+			s.SettleMaxLag = max(s.SettleMaxLag, stats[0].MaxLag)
+		}
+	}
+	return s
+}
+
+func (t *inProcessTarget) Close() {
+	if t.stopSampler != nil {
+		close(t.stopSampler)
+		<-t.samplerDone
+	}
+	for _, m := range t.markets {
+		m.engine.Stop()
+	}
+	if t.assets != nil {
+		t.assets.Stop()
+	}
+}
+
+func cutSymbol(symbol string) (base, quote string, ok bool) {
+	for i := 0; i < len(symbol); i++ {
+		if symbol[i] == '_' {
+			return symbol[:i], symbol[i+1:], i > 0 && i < len(symbol)-1
+		}
+	}
+	return "", "", false
+}
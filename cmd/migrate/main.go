@@ -0,0 +1,119 @@
+// migrate 数据库表结构迁移 (pkg/migrate)：冷启动建全部表，升级时补新版本
+//
+//	go run ./cmd/migrate -dsn "$MYSQL_DSN" up              # 执行全部未执行的迁移
+//	go run ./cmd/migrate -dsn "$MYSQL_DSN" -to 5 up        # 只执行到版本 5
+//	go run ./cmd/migrate -dsn "$MYSQL_DSN" status          # 列出各版本执行状态
+//	go run ./cmd/migrate -dsn "$MYSQL_DSN" -steps 1 -yes down
+//
+// 启动编排在拉起任何服务 (撮合、合约处理器、资金服务) 之前执行 `migrate up`，退出码非 0 时中止启动；
+// 多个实例同时执行是安全的 (命名锁串行化，后到的发现已是最新直接退出)。
+//
+// 退出码：0 成功，1 迁移失败，2 参数错误，3 等锁超时 (另一个迁移在跑)
+//
+// 【注意】down 会删表和表里的数据，必须显式加 -yes；生产环境回滚优先考虑加新版本修正
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"max.com/pkg/migrate"
+)
+
+func main() {
+	var (
+		dsn         = flag.String("dsn", os.Getenv("MYSQL_DSN"), "MySQL DSN (默认读环境变量 MYSQL_DSN)")
+		to          = flag.Int64("to", 0, "up: 执行到的版本，0 表示最新")
+		steps       = flag.Int("steps", 1, "down: 回滚的版本数")
+		yes         = flag.Bool("yes", false, "down: 确认删表")
+		table       = flag.String("table", "", "版本表名 (默认 schema_migrations)")
+		lockTimeout = flag.Duration("lock-timeout", time.Minute, "等待其他迁移释放锁的时间")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate [flags] up|down|status")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *dsn == "" {
+		fail(2, "-dsn or MYSQL_DSN is required")
+	}
+
+	db, err := gorm.Open(mysql.Open(*dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		fail(1, "connect:", err)
+	}
+	m, err := migrate.New(db, migrate.Config{Table: *table, LockTimeout: *lockTimeout})
+	if err != nil {
+		fail(1, err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		start := time.Now()
+		applied, err := m.Up(ctx, *to)
+		report(applied, err)
+		fmt.Printf("migrate: up applied %d migration(s) in %s\n", len(applied), time.Since(start).Round(time.Millisecond))
+	case "down":
+		if !*yes {
+			fail(2, "down drops tables and their data; pass -yes to confirm")
+		}
+		reverted, err := m.Down(ctx, *steps)
+		report(reverted, err)
+		fmt.Printf("migrate: down reverted %d migration(s)\n", len(reverted))
+	case "status":
+		st, err := m.Status(ctx)
+		if err != nil {
+			fail(1, err)
+		}
+		pending := 0
+		for _, s := range st {
+			state := "pending"
+			switch {
+			case s.Unknown:
+				state = "UNKNOWN (applied by a newer build)"
+			case s.Applied:
+				state = "applied " + time.UnixMilli(s.AppliedAt).UTC().Format(time.RFC3339)
+			default:
+				pending++
+			}
+			fmt.Printf("%04d  %-28s  %s\n", s.Version, s.Name, state)
+		}
+		fmt.Printf("migrate: %d pending\n", pending)
+	default:
+		fail(2, "unknown command", cmd)
+	}
+}
+
+// report 打印已完成的版本，出错时按错误类型退出
+func report(done []migrate.Migration, err error) {
+	for _, mg := range done {
+		fmt.Printf("  %04d_%s\n", mg.Version, mg.Name)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, migrate.ErrLocked):
+		fail(3, err)
+	default:
+		fail(1, err)
+	}
+}
+
+func fail(code int, args ...any) {
+	fmt.Fprintln(os.Stderr, append([]any{"migrate:"}, args...)...)
+	os.Exit(code)
+}
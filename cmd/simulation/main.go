@@ -2,18 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
+	"max.com/pkg/futures"
 	"max.com/pkg/liquidation"
+	"max.com/pkg/marketsim"
 	"max.com/pkg/mtrade"
 	"max.com/pkg/risk"
+	"max.com/pkg/scenario"
 )
 
 // =============================================================================
@@ -144,7 +149,13 @@ func (e *MockLiquidationExecutor) Execute(ctx context.Context, task liquidation.
 // =============================================================================
 
 func main() {
+	scenarioPath := flag.String("scenario", "", "场景脚本 (YAML 文件或目录)，不填则运行内置的暴跌演示")
+	flag.Parse()
+
 	log.SetFlags(log.Ltime | log.Lmicroseconds)
+	if *scenarioPath != "" {
+		os.Exit(runScenarios(*scenarioPath))
+	}
 	log.Println("🚀 Starting Full System Simulation...")
 
 	// 1. 初始化 撮合引擎 (Matching Engine)
@@ -209,81 +220,44 @@ func main() {
 	userDataProvider.SetCurrentPrice(50000)
 
 	// 3.2 启动行情模拟器 (Market Simulator)
-	go func() {
-		price := float64(50000)
-		ticker := time.NewTicker(100 * time.Millisecond)
-		startTime := time.Now()
-		crashed := false
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if !crashed {
-					// 1. 随机波动价格
-					change := (rand.Float64() - 0.5) * 100 // -50 ~ +50
-					price += change
-
-					// 强制暴跌 (2秒后)
-					if time.Since(startTime) > 2*time.Second {
-						price = 40000 // 暴跌到 40000
-						crashed = true
-						log.Printf("[Market] 📉 FORCED CRASH! Price dropped to %.2f (Sustained)", price)
-					}
-				} else {
-					// 保持低价，轻微波动
-					change := (rand.Float64() - 0.5) * 10
-					price = 40000 + change
-				}
-
-				userDataProvider.SetCurrentPrice(price)
-
-				// 2. 推送价格给强平引擎 (模拟 Scanner 行为或直接推送)
-				// 注意：Engine 没有直接 OnPriceChange 公共方法，通常是通过 Scanner 或 PriceProvider
-				// 但 Engine 有 HandleLevelChange，或者 Scanner 会定期扫。
-				// 这里我们依赖 Scanner 的定期扫描 (默认 5s)
-				// 为了演示效果，我们可能需要缩短扫描间隔，或者手动触发。
-				// 由于 Scanner 是私有的，我们无法直接触发。
-				// 但我们可以通过更新 UserDataProvider 的价格，等待 Scanner 扫到。
-
-				// 3. 随机下单到撮合引擎 (制造流动性)
-				// Maker
-				intPrice := int64(price)
-				tradeEngine.SubmitOrder(&mtrade.Order{
-					UserID: rand.Int63n(1000),
-					Symbol: "BTC_USDT",
-					Side:   mtrade.SideBuy,
-					Type:   mtrade.OrderTypeLimit,
-					Price:  intPrice - rand.Int63n(50),
-					Qty:    rand.Int63n(10) + 1,
-				})
-				tradeEngine.SubmitOrder(&mtrade.Order{
-					UserID: rand.Int63n(1000),
-					Symbol: "BTC_USDT",
-					Side:   mtrade.SideSell,
-					Type:   mtrade.OrderTypeLimit,
-					Price:  intPrice + rand.Int63n(50),
-					Qty:    rand.Int63n(10) + 1,
-				})
-
-				// Taker (偶尔吃单)
-				if rand.Float32() < 0.3 {
-					side := mtrade.SideBuy
-					if rand.Float32() < 0.5 {
-						side = mtrade.SideSell
-					}
-					tradeEngine.SubmitOrder(&mtrade.Order{
-						UserID: rand.Int63n(1000),
-						Symbol: "BTC_USDT",
-						Side:   side,
-						Type:   mtrade.OrderTypeMarket,
-						Qty:    rand.Int63n(5) + 1,
-					})
-				}
+	// 多交易对相关 GBM + 跳跃；第 20 步 (约 2 秒后) BTC/ETH 分 3 步暴跌 20%
+	gen, err := marketsim.NewGenerator(marketsim.Config{
+		Symbols: []marketsim.SymbolConfig{
+			{Symbol: "BTC_USDT", Start: 50000, Vol: 0.8, JumpIntensity: 500, JumpStd: 0.002},
+			{Symbol: "ETH_USDT", Start: 3000, Vol: 1.0, JumpIntensity: 500, JumpStd: 0.003},
+		},
+		Correlation: [][]float64{{1, 0.85}, {0.85, 1}},
+		Regimes: []marketsim.Regime{
+			{Name: "calm", Steps: 50, VolX: 1},
+			{Name: "stress", Steps: 20, VolX: 4},
+		},
+		Crashes: []marketsim.Crash{{Step: 20, Drop: 0.2, Steps: 3}},
+		Seed:    time.Now().UnixNano(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Market Simulator: %v", err)
+	}
+	markPrices := futures.NewMarkPriceService()
+	flow := &marketsim.OrderFlow{
+		Engines:   map[string]*mtrade.Engine{"BTC_USDT": tradeEngine},
+		Spread:    50,
+		MaxQty:    10,
+		TakerProb: 0.3,
+		Users:     1000,
+		Rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	// 强平引擎依赖 Scanner 定期扫描 (默认 5s)，这里只需更新 UserDataProvider 的价格等它扫到
+	feed := marketsim.NewFeed(gen, 100*time.Millisecond,
+		marketsim.MarkPriceSink(markPrices),
+		func(t marketsim.Tick) {
+			userDataProvider.SetCurrentPrice(t.Prices["BTC_USDT"])
+			if t.Step%20 == 0 {
+				log.Printf("[Market] 📈 step %d (%s) BTC %.2f ETH %.2f", t.Step, t.Regime, t.Prices["BTC_USDT"], t.Prices["ETH_USDT"])
 			}
-		}
-	}()
+		},
+		flow.Sink(),
+	)
+	go feed.Run(ctx, 0)
 
 	// 等待信号
 	sigCh := make(chan os.Signal, 1)
@@ -292,3 +266,57 @@ func main() {
 
 	log.Println("🛑 Shutting down...")
 }
+
+// =============================================================================
+// 场景脚本模式
+// =============================================================================
+
+// runScenarios 运行一个脚本或目录下全部 *.yaml，返回进程退出码
+func runScenarios(path string) int {
+	files := []string{path}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(path, "*.yaml"))
+	}
+	if len(files) == 0 {
+		log.Printf("no scenarios found in %s", path)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	failed := 0
+	for _, f := range files {
+		sc, err := scenario.Load(f)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			failed++
+			continue
+		}
+		log.Printf("▶️  %s: %s", sc.Name, sc.Description)
+		report, err := scenario.Run(ctx, sc, scenario.Options{Logf: log.Printf})
+		if err != nil {
+			log.Printf("❌ %s: %v", sc.Name, err)
+			failed++
+			continue
+		}
+		for _, l := range report.Liquidations {
+			log.Printf("   ⚡️ user %d liquidated at %s %.2f (step %d)", l.UserID, l.Symbol, l.Price, l.Step)
+		}
+		if !report.Passed() {
+			for _, f := range report.Failures {
+				log.Printf("   ✗ %s", f)
+			}
+			log.Printf("❌ %s FAILED (%d steps, %d trades, %v)", sc.Name, report.Steps, report.Trades, report.Duration)
+			failed++
+			continue
+		}
+		log.Printf("✅ %s passed (%d steps, %d trades, %v)", sc.Name, report.Steps, report.Trades, report.Duration)
+	}
+
+	log.Printf("%d/%d scenarios passed", len(files)-failed, len(files))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
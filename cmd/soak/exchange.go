@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/diag"
+	"max.com/pkg/futures"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+	"max.com/pkg/spot"
+)
+
+// =============================================================================
+// 进程内交易所：资产引擎 (热) + 现货处理器，内存冷账本 + 合约处理器，每个市场一个撮合引擎
+// =============================================================================
+
+// feeAccountID 现货手续费账户 (系统用户，不在 1..users 范围内)
+const feeAccountID = 1_000_000_000
+
+// settleCurrency 合约全部按 USDT 结算 (正向合约)
+const settleCurrency = "USDT"
+
+// 初始资金：几小时的随机流量不至于因余额不足大面积拒单
+const (
+	spotQuoteFunding    = 100_000_000 * asset.Precision
+	spotBaseFunding     = 100_000 * asset.Precision
+	futuresFunding      = 10_000_000 * futures.Precision
+	feeAccountFunding   = 1_000_000 * asset.Precision // maker 返佣从这里支出
+	futuresContractSize = futures.Precision           // 1 张 = 1 个基础币
+)
+
+type spotMarket struct {
+	symbol    string
+	base      string
+	quote     string
+	engine    *mtrade.Engine
+	processor *spot.SpotProcessor
+}
+
+type futuresMarket struct {
+	spec      *futures.ContractSpec
+	engine    *mtrade.Engine
+	processor *futures.FuturesProcessor
+}
+
+type exchange struct {
+	assets  *asset.AccountEngine
+	spot    []*spotMarket
+	futures []*futuresMarket
+
+	ledger    *memLedger
+	orders    *orderSink
+	positions *memPositionRepo
+	diag      *diag.Registry
+
+	// 充值总额 (冷端记录)，对账基准；只在 setup 时写，之后只读
+	spotDeposits    map[string]int64     // 资产 → 充值总额 (含手续费账户)
+	futuresDeposits map[balanceKey]int64 // (用户, 结算币) → 充值总额
+}
+
+// newExchange 创建并启动所有引擎，给用户充值
+func newExchange(ctx context.Context, cfg *config) (*exchange, error) {
+	ex := &exchange{
+		ledger:          newMemLedger(),
+		orders:          &orderSink{},
+		positions:       newMemPositionRepo(),
+		diag:            diag.New(diag.Config{}),
+		spotDeposits:    make(map[string]int64),
+		futuresDeposits: make(map[balanceKey]int64),
+	}
+
+	assetCfg := asset.DefaultEngineConfig()
+	assetCfg.CommandQueueLen = 100000
+	assetCfg.FeeAccountID = feeAccountID
+	ex.assets = asset.NewEngine(assetCfg)
+	if err := ex.assets.Start(); err != nil {
+		return nil, err
+	}
+	ex.diag.Register("asset", diag.AssetEngine(ex.assets))
+
+	for _, symbol := range cfg.spot {
+		base, quote, _ := strings.Cut(symbol, "_")
+		engine, err := newMatchEngine(symbol)
+		if err != nil {
+			ex.Close()
+			return nil, err
+		}
+		processor := spot.NewSpotProcessor(spot.ProcessorConfig{
+			AssetEngine:  ex.assets,
+			MatchEngine:  engine,
+			MakerFeeRate: cfg.makerFee,
+			TakerFeeRate: cfg.takerFee,
+		})
+		engine.Start(ctx)
+		ex.spot = append(ex.spot, &spotMarket{symbol: symbol, base: base, quote: quote, engine: engine, processor: processor})
+		ex.diag.Register("mtrade."+symbol, diag.MatchingEngine(engine))
+	}
+
+	specs := make([]*futures.ContractSpec, len(cfg.futures))
+	for i, symbol := range cfg.futures {
+		specs[i] = &futures.ContractSpec{
+			Symbol:         symbol,
+			BaseCurrency:   strings.TrimSuffix(symbol, settleCurrency),
+			QuoteCurrency:  settleCurrency,
+			SettleCurrency: settleCurrency,
+			ContractType:   futures.TypePerpetual,
+			ContractSize:   futuresContractSize,
+			TickSize:       tickSize,
+			MaxLeverage:    100,
+			Status:         futures.StatusTrading,
+		}
+	}
+	contracts := futures.NewContractManager(newMemContractRepo(specs...))
+	orderService := order.NewOrderService(ex.orders)
+	markPrices := futures.NewMarkPriceService() // 可开仓按用户全部合约的持仓算浮亏，各处理器共用
+	for _, spec := range specs {
+		// 合约订单簿用跳价数组，价格带取中间价 ±50%
+		engine, err := newFuturesMatchEngine(spec)
+		if err != nil {
+			ex.Close()
+			return nil, err
+		}
+		processor := futures.NewFuturesProcessor(contracts, engine, ex.positions, orderService, ex.ledger)
+		processor.SetMarkPriceService(markPrices)
+		processor.UpdateMarkPrice(spec.Symbol, midPrice) // 市价平仓按标记价格算保护价
+		engine.Start(ctx)
+		ex.futures = append(ex.futures, &futuresMarket{spec: spec, engine: engine, processor: processor})
+		ex.diag.Register("mtrade."+spec.Symbol, diag.MatchingEngine(engine))
+	}
+
+	if err := ex.fund(cfg.users); err != nil {
+		ex.Close()
+		return nil, err
+	}
+	return ex, nil
+}
+
+func newMatchEngine(symbol string) (*mtrade.Engine, error) {
+	engCfg := mtrade.DefaultEngineConfig(symbol)
+	engCfg.OrderQueueSize = 100000
+	return mtrade.NewEngine(engCfg)
+}
+
+func newFuturesMatchEngine(spec *futures.ContractSpec) (*mtrade.Engine, error) {
+	engCfg := spec.MatchEngineConfig(midPrice/2, midPrice+midPrice/2)
+	engCfg.OrderQueueSize = 100000
+	return mtrade.NewEngine(engCfg)
+}
+
+// fund 现货充值走资产引擎 DEPOSIT 事件，合约充值直接入冷账本，两边都记充值总额
+func (ex *exchange) fund(users int) error {
+	spotFunding := make(map[string]int64)
+	for _, m := range ex.spot {
+		spotFunding[m.base] = spotBaseFunding
+		spotFunding[m.quote] = spotQuoteFunding
+	}
+	deposit := func(userID int64, sym string, amount int64) error {
+		err := ex.assets.ApplyBalanceChange(&asset.BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   fmt.Sprintf("soak_%d_%s", userID, sym),
+			UserID:    userID,
+			Symbol:    sym,
+			Amount:    amount,
+		})
+		if err != nil {
+			return fmt.Errorf("fund user %d %s: %w", userID, sym, err)
+		}
+		ex.spotDeposits[sym] += amount
+		return nil
+	}
+
+	for sym := range spotFunding {
+		if err := deposit(feeAccountID, sym, feeAccountFunding); err != nil {
+			return err
+		}
+	}
+	for uid := int64(1); uid <= int64(users); uid++ {
+		for sym, amount := range spotFunding {
+			if err := deposit(uid, sym, amount); err != nil {
+				return err
+			}
+		}
+		if len(ex.futures) > 0 {
+			ex.ledger.deposit(uid, settleCurrency, futuresFunding)
+			ex.futuresDeposits[balanceKey{uid, settleCurrency}] += futuresFunding
+		}
+	}
+	return nil
+}
+
+// engines 全部撮合引擎 (现货在前)
+func (ex *exchange) engines() []*mtrade.Engine {
+	out := make([]*mtrade.Engine, 0, len(ex.spot)+len(ex.futures))
+	for _, m := range ex.spot {
+		out = append(out, m.engine)
+	}
+	for _, m := range ex.futures {
+		out = append(out, m.engine)
+	}
+	return out
+}
+
+// trades 全部引擎累计成交笔数
+func (ex *exchange) trades() int64 {
+	var n int64
+	for _, e := range ex.engines() {
+		n += e.GetStats().TradesExecuted
+	}
+	return n
+}
+
+func (ex *exchange) Close() {
+	for _, e := range ex.engines() {
+		e.Stop()
+	}
+	if ex.assets != nil {
+		ex.assets.Stop()
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/futures"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+// =============================================================================
+// 随机订单流
+// =============================================================================
+//
+// 价格以 midPrice 为中心：挂单离中间价 1~priceLevels 档，吃单穿过 priceLevels 档。
+// 价格带很窄 (±1%)，合约杠杆低 (默认 5 倍)，任何持仓的亏损都远小于保证金：
+// 不会穿仓，冷账本守恒不需要保险基金兜底
+//
+// 合约用户方向固定 (奇数做多、偶数做空)：开仓永远同向加仓，避免处理器"反向开仓简化处理"
+// 这条路径 (见 FuturesProcessor.updatePosition) 把保证金留在冻结里、持仓却减少。
+// 平仓一律市价全平，每个用户每个采样周期最多一笔平仓在途：
+// 平仓数量按下单时的持仓算，两笔同时在途会把仓位平穿
+
+const (
+	midPrice    = 1000 * asset.Precision
+	tickSize    = asset.Precision
+	priceLevels = 10
+	minQty      = asset.Precision / 100 // 0.01
+	maxQtySteps = 10
+)
+
+// recentOrders 每个 worker 记住最近下的订单，撤单从中随机挑选
+const recentOrders = 512
+
+// batchInterval worker 按批发单：每批补上这段时间应发的量。
+// 暂停期间 ticker 丢掉的 tick 不补，恢复后不会瞬间灌入积压的流量
+const batchInterval = 10 * time.Millisecond
+
+// flowStats 发单侧计数
+type flowStats struct {
+	spotPlaced    atomic.Int64
+	spotRejected  atomic.Int64
+	spotCancels   atomic.Int64
+	futuresOpened atomic.Int64
+	futuresReject atomic.Int64
+	futuresClosed atomic.Int64
+	futuresCancel atomic.Int64
+}
+
+func (s *flowStats) requests() int64 {
+	return s.spotPlaced.Load() + s.spotRejected.Load() + s.spotCancels.Load() +
+		s.futuresOpened.Load() + s.futuresReject.Load() + s.futuresClosed.Load() + s.futuresCancel.Load()
+}
+
+// closeGuard 每个采样周期每个用户最多一笔平仓在途，采样 (全部处理完) 后清空
+type closeGuard struct {
+	mu      sync.Mutex
+	closing map[int64]bool
+}
+
+func (g *closeGuard) tryClose(userID int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing[userID] {
+		return false
+	}
+	g.closing[userID] = true
+	return true
+}
+
+func (g *closeGuard) reset() {
+	g.mu.Lock()
+	g.closing = make(map[int64]bool)
+	g.mu.Unlock()
+}
+
+type placedOrder struct {
+	market int // 现货 / 合约市场下标
+	id     int64
+}
+
+// generator 单个 worker 的订单流 (非并发安全)
+type generator struct {
+	cfg    *config
+	ex     *exchange
+	rng    *rand.Rand
+	nextID *atomic.Int64
+	guard  *closeGuard
+	stats  *flowStats
+
+	recentSpot    [recentOrders]placedOrder
+	recentSpotN   int
+	recentFutures [recentOrders]placedOrder
+	recentFutN    int
+}
+
+func (g *generator) user() int64 {
+	return g.rng.Int63n(int64(g.cfg.users)) + 1
+}
+
+// price 挂单价或穿价吃单价
+func (g *generator) price(side mtrade.Side) int64 {
+	var offset int64
+	if g.rng.Float64() < g.cfg.takerRatio {
+		offset = -priceLevels // 穿价
+	} else {
+		offset = 1 + g.rng.Int63n(priceLevels)
+	}
+	return midPrice - int64(side)*offset*tickSize
+}
+
+func (g *generator) qty() int64 {
+	return minQty * (1 + g.rng.Int63n(maxQtySteps))
+}
+
+// step 随机执行一个动作
+func (g *generator) step(ctx context.Context) {
+	futuresTurn := len(g.ex.futures) > 0 && (len(g.ex.spot) == 0 || g.rng.Float64() < g.cfg.futuresShare)
+	if futuresTurn {
+		g.futuresStep(ctx)
+	} else {
+		g.spotStep()
+	}
+}
+
+func (g *generator) spotStep() {
+	if g.rng.Float64() < g.cfg.cancelRatio {
+		if o, ok := pick(g.rng, g.recentSpot[:], g.recentSpotN); ok {
+			if g.ex.spot[o.market].processor.CancelOrder(o.id) {
+				g.stats.spotCancels.Add(1)
+			}
+			return
+		}
+	}
+	idx := g.rng.Intn(len(g.ex.spot))
+	m := g.ex.spot[idx]
+	side := mtrade.SideBuy
+	if g.rng.Intn(2) == 0 {
+		side = mtrade.SideSell
+	}
+	o := &mtrade.Order{
+		ID:     g.nextID.Add(1),
+		UserID: g.user(),
+		Symbol: m.symbol,
+		Side:   side,
+		Type:   mtrade.OrderTypeLimit,
+		Price:  g.price(side),
+		Qty:    g.qty(),
+	}
+	if err := m.processor.PlaceOrder(o); err != nil {
+		g.stats.spotRejected.Add(1)
+		return
+	}
+	g.stats.spotPlaced.Add(1)
+	g.recentSpot[g.recentSpotN%recentOrders] = placedOrder{market: idx, id: o.ID}
+	g.recentSpotN++
+}
+
+func (g *generator) futuresStep(ctx context.Context) {
+	r := g.rng.Float64()
+	if r < g.cfg.cancelRatio {
+		if o, ok := pick(g.rng, g.recentFutures[:], g.recentFutN); ok {
+			if g.ex.futures[o.market].processor.CancelOrder(o.id) {
+				g.stats.futuresCancel.Add(1)
+			}
+			return
+		}
+	}
+	idx := g.rng.Intn(len(g.ex.futures))
+	m := g.ex.futures[idx]
+	userID := g.user()
+
+	if r < g.cfg.cancelRatio+g.cfg.closeRatio {
+		if !g.guard.tryClose(userID) {
+			return
+		}
+		err := m.processor.ClosePosition(ctx, &futures.ClosePositionRequest{UserID: userID, Symbol: m.spec.Symbol})
+		if err == nil {
+			g.stats.futuresClosed.Add(1)
+		}
+		return
+	}
+
+	side := userSide(userID)
+	req := &futures.OpenPositionRequest{
+		OrderID:  order.GenerateOrderID(),
+		UserID:   userID,
+		Symbol:   m.spec.Symbol,
+		Side:     side,
+		Qty:      g.qty(),
+		Price:    g.price(toMatchSide(side)),
+		Leverage: g.cfg.leverage,
+	}
+	if err := m.processor.OpenPosition(ctx, req); err != nil {
+		g.stats.futuresReject.Add(1)
+		return
+	}
+	g.stats.futuresOpened.Add(1)
+	g.recentFutures[g.recentFutN%recentOrders] = placedOrder{market: idx, id: req.OrderID}
+	g.recentFutN++
+}
+
+// userSide 合约用户的固定方向：奇数做多、偶数做空
+func userSide(userID int64) futures.Side {
+	if userID%2 == 1 {
+		return futures.SideLong
+	}
+	return futures.SideShort
+}
+
+func toMatchSide(side futures.Side) mtrade.Side {
+	if side == futures.SideLong {
+		return mtrade.SideBuy
+	}
+	return mtrade.SideSell
+}
+
+// pick 随机挑一个最近下的订单 (可能已经成交，撤单失败不计)
+func pick(rng *rand.Rand, recent []placedOrder, n int) (placedOrder, bool) {
+	n = min(n, len(recent))
+	if n == 0 {
+		return placedOrder{}, false
+	}
+	return recent[rng.Intn(n)], true
+}
+
+// runWorker 按 rate 发单直到 ctx 结束；每个动作持有 gate 读锁，采样时拿写锁暂停入口
+func runWorker(ctx context.Context, g *generator, rate float64, gate *sync.RWMutex) {
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	perBatch := rate * batchInterval.Seconds()
+	var budget float64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		budget = min(budget+perBatch, max(perBatch*2, 1))
+		for ; budget >= 1 && ctx.Err() == nil; budget-- {
+			gate.RLock()
+			g.step(ctx)
+			gate.RUnlock()
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/fixed"
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 静止点采样
+// =============================================================================
+//
+// 【静止点】入口暂停 (gate 写锁) 后：
+//  1. 每个撮合引擎 Freeze，等已入队的下单 / 撤单撮合完 (之后订单簿不再变化)
+//  2. 轮询到所有引擎的事件队列、handler 队列都为空，且处理计数、资产引擎命令数
+//     连续 quietPolls 次不变 —— 结算 handler 已经把最后一个事件处理完
+//
+// 只有在静止点上，热钱包、冷账本、持仓、订单簿才是同一时刻的一致视图；
+// 流量中途采样，在途的成交会让任何守恒关系看起来"差一点"
+//
+// 【注意】handler 取走事件到处理完之间计数不变，静止判定靠"连续几次不变"；
+// 单个事件的处理时间超过 quietPolls × quietPollInterval 时可能误判，内存账本下远不到这个量级
+
+const (
+	quietPolls        = 3
+	quietPollInterval = 10 * time.Millisecond
+)
+
+var errNotQuiet = errors.New("pipeline did not drain")
+
+// progressMark 处理进度的指纹，连续不变视为静止
+type progressMark struct {
+	handled  int64
+	matched  int64
+	commands uint64
+}
+
+func (ex *exchange) progress() (progressMark, bool) {
+	var m progressMark
+	idle := true
+	for _, e := range ex.engines() {
+		q := e.QueueStats()
+		if q.OrderQueueLen+q.CancelQueueLen+q.EventQueueLen > 0 {
+			idle = false
+		}
+		for _, h := range e.HandlerStats() {
+			m.handled += h.Processed
+			if h.QueueLen > 0 {
+				idle = false
+			}
+		}
+		st := e.GetStats()
+		m.matched += st.OrdersReceived + st.TradesExecuted + st.OrdersCanceled
+	}
+	as := ex.assets.GetStats()
+	m.commands = as.TotalCommands
+	for _, depth := range as.QueueDepth {
+		if depth > 0 {
+			idle = false
+		}
+	}
+	return m, idle
+}
+
+// quiesce 冻结撮合入口并等待整条链路处理完；失败时已冻结的引擎由 thaw 恢复
+func (ex *exchange) quiesce(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, e := range ex.engines() {
+		if err := e.Freeze(ctx); err != nil {
+			return fmt.Errorf("freeze %w", err)
+		}
+	}
+
+	var last progressMark
+	stable := 0
+	for stable < quietPolls {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w within %s", errNotQuiet, timeout)
+		case <-time.After(quietPollInterval):
+		}
+		mark, idle := ex.progress()
+		if idle && mark == last {
+			stable++
+		} else {
+			stable = 0
+		}
+		last = mark
+	}
+	return nil
+}
+
+func (ex *exchange) thaw() {
+	for _, e := range ex.engines() {
+		e.Unfreeze()
+	}
+}
+
+// =============================================================================
+// 一致视图
+// =============================================================================
+
+// restingOrder 订单簿上的挂单 (拷贝)
+type restingOrder struct {
+	Symbol    string      `json:"symbol"`
+	ID        int64       `json:"id"`
+	UserID    int64       `json:"user_id"`
+	Side      mtrade.Side `json:"side"`
+	Price     int64       `json:"price"`
+	Qty       int64       `json:"qty"`
+	FilledQty int64       `json:"filled_qty"`
+}
+
+func (o restingOrder) remaining() int64 { return o.Qty - o.FilledQty }
+
+// marginView 合约保证金视图 (FuturesProcessor.AccountMargin 的相关字段)
+type marginView struct {
+	Available      int64            `json:"available"`
+	PositionMargin int64            `json:"position_margin"`
+	OrderMargin    map[string]int64 `json:"order_margin"` // 合约 → 该合约挂单冻结的保证金
+}
+
+// state 静止点上采集的全部状态
+type state struct {
+	At time.Time `json:"at"`
+
+	SpotBalances   map[int64]*asset.Snapshot `json:"spot_balances"`
+	SpotUsers      int                       `json:"spot_users"` // 资产引擎统计的用户数 (快照可能缺分片)
+	SpotOrders     []restingOrder            `json:"spot_orders"`
+	FuturesOrders  []restingOrder            `json:"futures_orders"`
+	Ledger         []fund.BalanceRecord      `json:"ledger"`
+	Positions      []*futures.Position       `json:"positions"`
+	Margins        map[int64]*marginView     `json:"margins"` // 用户 → 结算币 USDT 的保证金
+	EventsDropped  map[string]int64          `json:"events_dropped,omitempty"`
+	HandlerDropped map[string]int64          `json:"handler_dropped,omitempty"`
+}
+
+// collect 在静止点上采集状态 (引擎已冻结，处理器不会再改元数据)
+func (ex *exchange) collect(ctx context.Context) (*state, error) {
+	st := &state{
+		At:             time.Now(),
+		SpotBalances:   ex.assets.GetAllSnapshots(),
+		SpotUsers:      ex.assets.GetStats().TotalUsers,
+		Ledger:         ex.ledger.balances(),
+		Positions:      ex.positions.all(""),
+		Margins:        make(map[int64]*marginView),
+		EventsDropped:  make(map[string]int64),
+		HandlerDropped: make(map[string]int64),
+	}
+	bookOrders := func(e *mtrade.Engine, symbol string) []restingOrder {
+		var out []restingOrder
+		for _, o := range e.GetOrderBook().GetAllOrders() { // Freeze 之后 matchLoop 空闲，可以直接读
+			out = append(out, restingOrder{Symbol: symbol, ID: o.ID, UserID: o.UserID, Side: o.Side, Price: o.Price, Qty: o.Qty, FilledQty: o.FilledQty})
+		}
+		return out
+	}
+	for _, m := range ex.spot {
+		st.SpotOrders = append(st.SpotOrders, bookOrders(m.engine, m.symbol)...)
+	}
+	for _, m := range ex.futures {
+		st.FuturesOrders = append(st.FuturesOrders, bookOrders(m.engine, m.spec.Symbol)...)
+	}
+	for _, e := range ex.engines() {
+		symbol := e.GetOrderBook().Symbol
+		if n := e.GetStats().EventsDropped; n > 0 {
+			st.EventsDropped[symbol] = n
+		}
+		for _, h := range e.HandlerStats() {
+			if h.Dropped > 0 && h.Policy == mtrade.DeliveryBlocking {
+				st.HandlerDropped[symbol+"/"+h.Name] = h.Dropped
+			}
+		}
+	}
+
+	// 保证金：可用和持仓保证金对所有合约相同 (按结算币汇总)，挂单保证金只算各处理器自己的订单
+	for _, b := range st.Ledger {
+		if b.Symbol != settleCurrency {
+			continue
+		}
+		view := &marginView{OrderMargin: make(map[string]int64)}
+		for i, m := range ex.futures {
+			am, err := m.processor.AccountMargin(ctx, b.UserID, settleCurrency)
+			if err != nil {
+				return nil, fmt.Errorf("account margin user %d: %w", b.UserID, err)
+			}
+			if i == 0 {
+				view.Available, view.PositionMargin = am.Available, am.PositionMargin
+			}
+			if am.OrderMargin != 0 {
+				view.OrderMargin[m.spec.Symbol] = am.OrderMargin
+			}
+		}
+		st.Margins[b.UserID] = view
+	}
+	return st, nil
+}
+
+// =============================================================================
+// 不变量
+// =============================================================================
+
+// violation 一条不变量违反
+type violation struct {
+	Invariant string `json:"invariant"`
+	Subject   string `json:"subject"`
+	Detail    string `json:"detail"`
+}
+
+func (v violation) String() string {
+	return fmt.Sprintf("%-22s %-24s %s", v.Invariant, v.Subject, v.Detail)
+}
+
+// checker 逐项检查静止点状态
+type checker struct {
+	ex         *exchange
+	leverage   int64
+	st         *state
+	violations []violation
+}
+
+func (c *checker) fail(invariant, subject, format string, args ...any) {
+	c.violations = append(c.violations, violation{Invariant: invariant, Subject: subject, Detail: fmt.Sprintf(format, args...)})
+}
+
+// check 全部不变量，返回违反列表 (按不变量、对象排序)
+//
+// spotComplete 为 false 时 (资产快照缺分片) 跳过现货余额类检查
+func (ex *exchange) check(st *state, leverage int) (violations []violation, spotComplete bool) {
+	c := &checker{ex: ex, leverage: int64(leverage), st: st}
+	c.checkDrops()
+	spotComplete = len(st.SpotBalances) == st.SpotUsers
+	if spotComplete {
+		c.checkSpotHotCold()
+		c.checkSpotLocked()
+	}
+	c.checkLedger()
+	c.checkOpenInterest()
+	c.checkOrderMargin()
+	slices.SortStableFunc(c.violations, func(a, b violation) int {
+		return cmp.Or(cmp.Compare(a.Invariant, b.Invariant), cmp.Compare(a.Subject, b.Subject))
+	})
+	return c.violations, spotComplete
+}
+
+// checkDrops 结算 handler 丢事件：成交 / 撤单没结算，之后所有守恒都会慢慢偏
+func (c *checker) checkDrops() {
+	for symbol, n := range c.st.EventsDropped {
+		c.fail("events_dropped", symbol, "engine dropped %d events", n)
+	}
+	for name, n := range c.st.HandlerDropped {
+		c.fail("events_dropped", name, "blocking handler dropped %d events", n)
+	}
+}
+
+// checkSpotHotCold 热钱包 (资产引擎) 每种资产的总额 (含手续费账户) 等于冷端记录的充值总额
+//
+// 成交只在用户之间、用户与手续费账户之间转移，撤单只在可用与冻结之间转移，总额不变
+func (c *checker) checkSpotHotCold() {
+	hot := make(map[string]int64)
+	for uid, snap := range c.st.SpotBalances {
+		for sym, a := range snap.Assets {
+			hot[sym] += a.Total()
+			if a.Available < 0 || a.Locked < 0 {
+				c.fail("spot_negative", userSubject(uid, sym), "available=%s locked=%s", amount(a.Available), amount(a.Locked))
+			}
+		}
+	}
+	for sym, cold := range c.ex.spotDeposits {
+		if hot[sym] != cold {
+			c.fail("spot_hot_cold", sym, "hot total %s != deposits %s (diff %s)", amount(hot[sym]), amount(cold), amount(hot[sym]-cold))
+		}
+	}
+}
+
+// checkSpotLocked 每个用户每种资产的冻结 >= 订单簿上挂单剩余部分需要的冻结
+//
+// 只查下界：完全成交的买单不退手续费预留、吃单价格改善的差额也留在冻结里，
+// 冻结比挂单需要的多是已知行为；少了说明有挂单没有资金背书
+func (c *checker) checkSpotLocked() {
+	markets := make(map[string]*spotMarket, len(c.ex.spot))
+	for _, m := range c.ex.spot {
+		markets[m.symbol] = m
+	}
+	need := make(map[balanceKey]int64)
+	for _, o := range c.st.SpotOrders {
+		m := markets[o.Symbol]
+		if o.Side == mtrade.SideBuy {
+			need[balanceKey{o.UserID, m.quote}] += fixed.Mul(o.Price, o.remaining())
+		} else {
+			need[balanceKey{o.UserID, m.base}] += o.remaining()
+		}
+	}
+	for k, n := range need {
+		var locked int64
+		if snap := c.st.SpotBalances[k.userID]; snap != nil {
+			locked = snap.Assets[k.symbol].Locked
+		}
+		if locked < n {
+			c.fail("spot_locked_cover", userSubject(k.userID, k.symbol), "locked %s < resting orders need %s", amount(locked), amount(n))
+		}
+	}
+}
+
+// checkLedger 冷账本 (合约保证金)
+//
+//   - 余额守恒：可用 + 持仓保证金 + 挂单保证金 = 充值 + 累计已实现盈亏。
+//     与冷账本里持仓保证金是否仍算冻结无关 (进程内没有 NATS 写入器扣冻结，平仓结算直接加可用)
+//   - 冻结 >= 挂单保证金：挂单冻结的保证金必须真的在冷账本里
+func (c *checker) checkLedger() {
+	pnl := make(map[int64]int64)
+	for _, p := range c.st.Positions {
+		pnl[p.UserID] += p.RealizedPnL
+	}
+	for _, b := range c.st.Ledger {
+		subject := userSubject(b.UserID, b.Symbol)
+		if b.Available < 0 || b.Locked < 0 {
+			c.fail("ledger_negative", subject, "available=%s locked=%s", amount(b.Available), amount(b.Locked))
+		}
+		view := c.st.Margins[b.UserID]
+		if b.Symbol != settleCurrency || view == nil {
+			continue
+		}
+		var orderMargin int64
+		for _, m := range view.OrderMargin {
+			orderMargin += m
+		}
+		balance := view.Available + view.PositionMargin + orderMargin
+		want := c.ex.futuresDeposits[balanceKey{b.UserID, b.Symbol}] + pnl[b.UserID]
+		if balance != want {
+			c.fail("futures_balance", subject, "available %s + position margin %s + order margin %s = %s, want deposits + realized pnl = %s (diff %s)",
+				amount(view.Available), amount(view.PositionMargin), amount(orderMargin), amount(balance), amount(want), amount(balance-want))
+		}
+		if b.Locked < orderMargin {
+			c.fail("futures_locked_cover", subject, "ledger locked %s < order margin %s", amount(b.Locked), amount(orderMargin))
+		}
+	}
+}
+
+// checkOpenInterest 每个合约多头总持仓 = 空头总持仓；持仓与保证金、方向一致
+func (c *checker) checkOpenInterest() {
+	long, short := make(map[string]int64), make(map[string]int64)
+	for _, p := range c.st.Positions {
+		subject := userSubject(p.UserID, p.Symbol)
+		switch {
+		case p.Size > 0:
+			long[p.Symbol] += p.Size
+		case p.Size < 0:
+			short[p.Symbol] -= p.Size
+		}
+		if (p.Size != 0) != (p.Margin > 0) || p.Margin < 0 {
+			c.fail("position_margin", subject, "size %s with margin %s", amount(p.Size), amount(p.Margin))
+		}
+		// 用户方向固定，持仓反向说明平仓把仓位平穿了
+		if p.Size != 0 && (p.Size > 0) != (userSide(p.UserID) == futures.SideLong) {
+			c.fail("position_side", subject, "size %s against user side %s", amount(p.Size), userSide(p.UserID))
+		}
+	}
+	for _, m := range c.ex.futures {
+		symbol := m.spec.Symbol
+		if long[symbol] != short[symbol] {
+			c.fail("open_interest", symbol, "long %s != short %s", amount(long[symbol]), amount(short[symbol]))
+		}
+	}
+}
+
+// checkOrderMargin 每个用户每个合约的挂单保证金与订单簿上剩余挂单一致
+//
+// 单笔订单冻结 = 名义价值 / 杠杆，部分成交按比例转入持仓 (向零截断)，
+// 剩余部分比按剩余比例直接算的值多出每笔成交至多 1 个最小单位
+func (c *checker) checkOrderMargin() {
+	specs := make(map[string]*futures.ContractSpec, len(c.ex.futures))
+	for _, m := range c.ex.futures {
+		specs[m.spec.Symbol] = m.spec
+	}
+	type bound struct{ lo, hi int64 }
+	want := make(map[balanceKey]bound)
+	for _, o := range c.st.FuturesOrders {
+		margin := specs[o.Symbol].PositionValue(o.Qty, o.Price) / c.leverage
+		lo := fixed.MulDiv(margin, o.remaining(), o.Qty)
+		b := want[balanceKey{o.UserID, o.Symbol}]
+		b.lo += lo
+		b.hi += lo
+		if o.FilledQty > 0 {
+			b.hi += o.FilledQty/minQty + 1
+		}
+		want[balanceKey{o.UserID, o.Symbol}] = b
+	}
+	got := make(map[balanceKey]int64)
+	for uid, view := range c.st.Margins {
+		for symbol, m := range view.OrderMargin {
+			got[balanceKey{uid, symbol}] = m
+		}
+	}
+	for k := range want {
+		if _, ok := got[k]; !ok {
+			got[k] = 0
+		}
+	}
+	for k, m := range got {
+		b := want[k]
+		if m < b.lo || m > b.hi {
+			c.fail("order_margin", userSubject(k.userID, k.symbol), "order margin %s, resting orders need [%s, %s]", amount(m), amount(b.lo), amount(b.hi))
+		}
+	}
+}
+
+func userSubject(userID int64, symbol string) string {
+	return fmt.Sprintf("user %d %s", userID, symbol)
+}
+
+// amount 定点数转十进制字符串 (报告用)
+func amount(v int64) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign, u = "-", -uint64(v)
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, u/fixed.Scale, u%fixed.Scale)
+}
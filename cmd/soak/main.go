@@ -0,0 +1,391 @@
+// soak 长时间浸泡测试：进程内跑完整交易所 (资产引擎 + 现货、内存冷账本 + 合约，每个市场一个撮合引擎)，
+// 持续灌入随机订单流几个小时；每隔一段时间暂停入口、等链路静止，采样检查不变量
+// (热钱包与充值守恒、挂单与冻结、持仓多空相等、冷账本守恒)，
+// 第一次违反时把违反明细、诊断快照和采样状态写到文件后退出。
+// 抓的是慢漂移：单次截断差 1、极少数路径少退一笔冻结，单元测试和短压测都看不出来
+//
+//	go run ./cmd/soak -duration 6h -rate 2000 -users 500 -sample 30s -dump-dir /tmp
+//
+// 【注意】静止判定是启发式的 (队列为空且处理计数连续几次不变，见 quiesce)；
+// 静止超时本身也按违反处理：链路卡住同样是要抓的问题
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"max.com/pkg/diag"
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+type config struct {
+	duration     time.Duration
+	rate         int
+	workers      int
+	users        int
+	sample       time.Duration
+	quiesce      time.Duration
+	report       time.Duration
+	seed         int64
+	spot         []string
+	futures      []string
+	makerFee     int64
+	takerFee     int64
+	leverage     int
+	cancelRatio  float64
+	closeRatio   float64
+	takerRatio   float64
+	futuresShare float64
+	dumpDir      string
+	verbose      bool
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func parseFlags() (config, error) {
+	var cfg config
+	var spotSymbols, futuresSymbols string
+	flag.DurationVar(&cfg.duration, "duration", time.Hour, "运行时长")
+	flag.IntVar(&cfg.rate, "rate", 2000, "目标速率 (下单+撤单+平仓 / 秒)")
+	flag.IntVar(&cfg.workers, "workers", 4, "发单 goroutine 数")
+	flag.IntVar(&cfg.users, "users", 200, "用户数 (全部预先充值)")
+	flag.DurationVar(&cfg.sample, "sample", 30*time.Second, "不变量采样间隔")
+	flag.DurationVar(&cfg.quiesce, "quiesce-timeout", 30*time.Second, "采样时等待链路静止的最长时间")
+	flag.DurationVar(&cfg.report, "report", 10*time.Second, "进度输出间隔")
+	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "随机种子 (违反时写入 dump，便于复现)")
+	flag.StringVar(&spotSymbols, "spot", "BTC_USDT,ETH_USDT", "现货交易对 (BASE_QUOTE)，空表示不跑现货")
+	flag.StringVar(&futuresSymbols, "futures", "BTCUSDT,ETHUSDT", "USDT 永续合约，空表示不跑合约")
+	flag.Int64Var(&cfg.makerFee, "maker-fee", -2, "现货 maker 费率 (万分比，负数为返佣)")
+	flag.Int64Var(&cfg.takerFee, "taker-fee", 20, "现货 taker 费率 (万分比)")
+	flag.IntVar(&cfg.leverage, "leverage", 5, "合约开仓杠杆")
+	flag.Float64Var(&cfg.cancelRatio, "cancel-ratio", 0.2, "撤单占请求的比例")
+	flag.Float64Var(&cfg.closeRatio, "close-ratio", 0.05, "合约请求中市价全平的比例")
+	flag.Float64Var(&cfg.takerRatio, "taker-ratio", 0.3, "下单中穿价吃单的比例")
+	flag.Float64Var(&cfg.futuresShare, "futures-share", 0.5, "合约请求占全部请求的比例")
+	flag.StringVar(&cfg.dumpDir, "dump-dir", ".", "违反时 dump 文件的目录")
+	flag.BoolVar(&cfg.verbose, "v", false, "保留引擎和处理器的日志 (默认丢弃，几小时的平仓日志会淹没进度输出)")
+	flag.Parse()
+
+	cfg.spot, cfg.futures = splitList(spotSymbols), splitList(futuresSymbols)
+	for _, s := range cfg.spot {
+		if base, quote, ok := strings.Cut(s, "_"); !ok || base == "" || quote == "" {
+			return cfg, fmt.Errorf("invalid spot symbol %q, want BASE_QUOTE", s)
+		}
+	}
+	for _, s := range cfg.futures {
+		if base, ok := strings.CutSuffix(s, settleCurrency); !ok || base == "" {
+			return cfg, fmt.Errorf("invalid futures symbol %q, want <BASE>%s", s, settleCurrency)
+		}
+	}
+	switch {
+	case len(cfg.spot)+len(cfg.futures) == 0:
+		return cfg, fmt.Errorf("no markets")
+	case cfg.rate <= 0:
+		return cfg, fmt.Errorf("-rate must be positive")
+	case cfg.workers <= 0:
+		return cfg, fmt.Errorf("-workers must be positive")
+	case cfg.users <= 1:
+		return cfg, fmt.Errorf("-users must be at least 2")
+	case cfg.sample <= 0 || cfg.quiesce <= 0 || cfg.report <= 0:
+		return cfg, fmt.Errorf("-sample, -quiesce-timeout and -report must be positive")
+	case cfg.leverage < 1 || cfg.leverage > 20:
+		return cfg, fmt.Errorf("-leverage must be in [1, 20]") // 价格带 ±1%，更高杠杆可能强平，守恒需要保险基金
+	case cfg.cancelRatio < 0 || cfg.closeRatio < 0 || cfg.cancelRatio+cfg.closeRatio >= 1:
+		return cfg, fmt.Errorf("-cancel-ratio and -close-ratio must be non-negative with sum < 1")
+	case cfg.takerRatio < 0 || cfg.takerRatio > 1:
+		return cfg, fmt.Errorf("-taker-ratio must be in [0, 1]")
+	case cfg.futuresShare < 0 || cfg.futuresShare > 1:
+		return cfg, fmt.Errorf("-futures-share must be in [0, 1]")
+	}
+	return cfg, nil
+}
+
+// =============================================================================
+// 采样
+// =============================================================================
+
+// maxDumpViolations dump 和输出里最多保留的违反条数 (漂移一旦开始往往成片出现)
+const maxDumpViolations = 200
+
+// sampler 周期暂停入口、采样检查不变量
+type sampler struct {
+	cfg   *config
+	ex    *exchange
+	gate  *sync.RWMutex
+	guard *closeGuard
+	stats *flowStats
+	start time.Time
+
+	samples    int
+	skipped    int // 资产快照不完整、跳过现货检查的次数
+	paused     time.Duration
+	maxPaused  time.Duration
+	lastSample atomic.Pointer[sampleSummary]
+}
+
+type sampleSummary struct {
+	N      int           `json:"n"`
+	At     time.Time     `json:"at"`
+	Paused time.Duration `json:"paused"`
+}
+
+// dump 第一次违反时写出的全部现场
+type dump struct {
+	Sample     int            `json:"sample"`
+	Elapsed    string         `json:"elapsed"`
+	Seed       int64          `json:"seed"`
+	Violations []violation    `json:"violations"`
+	Total      int            `json:"total_violations"`
+	Flow       map[string]any `json:"flow"`
+	Diag       diag.Snapshot  `json:"diagnostics"`
+	State      *state         `json:"state,omitempty"`
+}
+
+// finding 一次采样发现的违反，以及暂停期间拍下的现场
+type finding struct {
+	violations []violation
+	state      *state
+	diag       diag.Snapshot
+}
+
+// run 暂停入口、等静止、采样检查；有违反 (含静止超时) 时在恢复流量前拍诊断快照
+func (s *sampler) run(ctx context.Context) *finding {
+	s.gate.Lock()
+	defer s.gate.Unlock()
+	pausedAt := time.Now()
+	defer func() {
+		s.ex.thaw()
+		s.guard.reset() // 在途平仓都已处理完
+		d := time.Since(pausedAt)
+		s.paused += d
+		s.maxPaused = max(s.maxPaused, d)
+		s.lastSample.Store(&sampleSummary{N: s.samples, At: pausedAt, Paused: d})
+	}()
+	s.samples++
+
+	found := func(violations []violation, st *state) *finding {
+		return &finding{violations: violations, state: st, diag: s.ex.diag.Snapshot()}
+	}
+	if err := s.ex.quiesce(ctx, s.cfg.quiesce); err != nil {
+		if ctx.Err() != nil {
+			return nil // 正常退出
+		}
+		return found([]violation{{Invariant: "quiesce", Subject: "pipeline", Detail: err.Error()}}, nil)
+	}
+	st, err := s.ex.collect(ctx)
+	if err != nil {
+		return found([]violation{{Invariant: "collect", Subject: "state", Detail: err.Error()}}, nil)
+	}
+	violations, spotComplete := s.ex.check(st, s.cfg.leverage)
+	if !spotComplete {
+		s.skipped++
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return found(violations, st)
+}
+
+func (s *sampler) flow() map[string]any {
+	return map[string]any{
+		"spot_placed":         s.stats.spotPlaced.Load(),
+		"spot_rejected":       s.stats.spotRejected.Load(),
+		"spot_cancels":        s.stats.spotCancels.Load(),
+		"futures_opened":      s.stats.futuresOpened.Load(),
+		"futures_rejected":    s.stats.futuresReject.Load(),
+		"futures_closed":      s.stats.futuresClosed.Load(),
+		"futures_cancels":     s.stats.futuresCancel.Load(),
+		"trades":              s.ex.trades(),
+		"ledger_journals":     s.ex.ledger.journalCount(),
+		"futures_orders":      s.ex.orders.created.Load(),
+		"samples":             s.samples,
+		"last_sample":         s.lastSample.Load(),
+		"spot_checks_skipped": s.skipped,
+	}
+}
+
+// writeDump 写出现场，返回文件路径
+func (s *sampler) writeDump(f *finding) (string, error) {
+	d := dump{
+		Sample:     s.samples,
+		Elapsed:    time.Since(s.start).Round(time.Second).String(),
+		Seed:       s.cfg.seed,
+		Violations: f.violations[:min(len(f.violations), maxDumpViolations)],
+		Total:      len(f.violations),
+		Flow:       s.flow(),
+		Diag:       f.diag,
+		State:      f.state,
+	}
+	path := filepath.Join(s.cfg.dumpDir, fmt.Sprintf("soak-%s.json", time.Now().Format("20060102-150405")))
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// =============================================================================
+// main
+// =============================================================================
+
+func main() {
+	cfg, err := parseFlags()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "soak:", err)
+		os.Exit(2)
+	}
+	if !cfg.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("soak: duration=%s rate=%d/s workers=%d users=%d spot=%v futures=%v sample=%s seed=%d\n",
+		cfg.duration, cfg.rate, cfg.workers, cfg.users, cfg.spot, cfg.futures, cfg.sample, cfg.seed)
+	ex, err := newExchange(ctx, &cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "soak: setup:", err)
+		os.Exit(1)
+	}
+	defer ex.Close()
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	var (
+		gate   sync.RWMutex
+		nextID atomic.Int64
+		stats  flowStats
+		guard  closeGuard
+		wg     sync.WaitGroup
+	)
+	guard.reset()
+	s := &sampler{cfg: &cfg, ex: ex, gate: &gate, guard: &guard, stats: &stats, start: time.Now()}
+	ex.diag.Register("soak", func() any { return s.flow() })
+
+	perWorker := float64(cfg.rate) / float64(cfg.workers)
+	for i := 0; i < cfg.workers; i++ {
+		g := &generator{
+			cfg:    &cfg,
+			ex:     ex,
+			rng:    rand.New(rand.NewSource(cfg.seed + int64(i))),
+			nextID: &nextID,
+			guard:  &guard,
+			stats:  &stats,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(runCtx, g, perWorker, &gate)
+		}()
+	}
+
+	code := loop(runCtx, s, cfg.report)
+	cancel()
+	wg.Wait()
+	if code != 0 {
+		os.Exit(code)
+	}
+	if ctx.Err() == nil {
+		// 跑满时长：停止发单后最后再采样一次
+		if f := s.run(ctx); f != nil {
+			os.Exit(fail(s, f))
+		}
+	}
+	report(s)
+}
+
+// loop 周期输出进度、采样，直到 ctx 结束；返回退出码 (0 表示未发现违反)
+func loop(ctx context.Context, s *sampler, interval time.Duration) int {
+	progressTicker := time.NewTicker(interval)
+	defer progressTicker.Stop()
+	sampleTicker := time.NewTicker(s.cfg.sample)
+	defer sampleTicker.Stop()
+
+	var lastReq, lastTrades int64
+	last := s.start
+	for {
+		select {
+		case <-ctx.Done():
+			return 0
+		case now := <-progressTicker.C:
+			req, trades := s.stats.requests(), s.ex.trades()
+			secs := now.Sub(last).Seconds()
+			fmt.Printf("[%8s] req/s=%-7.0f trades/s=%-7.0f samples=%-4d paused max=%s\n",
+				now.Sub(s.start).Round(time.Second), float64(req-lastReq)/secs, float64(trades-lastTrades)/secs,
+				s.samples, s.maxPaused.Round(time.Millisecond))
+			lastReq, lastTrades, last = req, trades, now
+		case <-sampleTicker.C:
+			if f := s.run(ctx); f != nil {
+				return fail(s, f)
+			}
+		}
+	}
+}
+
+// fail 输出违反并写 dump，返回退出码
+func fail(s *sampler, f *finding) int {
+	violations := f.violations
+	fmt.Printf("\nsoak: %d invariant violation(s) at sample %d after %s (seed %d)\n",
+		len(violations), s.samples, time.Since(s.start).Round(time.Second), s.cfg.seed)
+	for i, v := range violations {
+		if i == 10 {
+			fmt.Printf("  ... %d more\n", len(violations)-i)
+			break
+		}
+		fmt.Println(" ", v)
+	}
+	path, err := s.writeDump(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "soak: write dump:", err)
+	} else {
+		fmt.Println("soak: dump written to", path)
+	}
+	return 1
+}
+
+func report(s *sampler) {
+	elapsed := time.Since(s.start)
+	secs := elapsed.Seconds()
+	req := s.stats.requests()
+	fmt.Println()
+	fmt.Println("===================== soak report =====================")
+	fmt.Printf("elapsed            %s\n", elapsed.Round(time.Second))
+	fmt.Printf("requests           %d (%.0f/s)\n", req, float64(req)/secs)
+	fmt.Printf("  spot             placed=%d rejected=%d cancels=%d\n",
+		s.stats.spotPlaced.Load(), s.stats.spotRejected.Load(), s.stats.spotCancels.Load())
+	fmt.Printf("  futures          opened=%d rejected=%d closed=%d cancels=%d\n",
+		s.stats.futuresOpened.Load(), s.stats.futuresReject.Load(), s.stats.futuresClosed.Load(), s.stats.futuresCancel.Load())
+	fmt.Printf("trades             %d (%.0f/s)\n", s.ex.trades(), float64(s.ex.trades())/secs)
+	fmt.Printf("samples            %d (spot checks skipped %d)\n", s.samples, s.skipped)
+	if s.samples > 0 {
+		fmt.Printf("paused             total=%s max=%s avg=%s\n", s.paused.Round(time.Millisecond),
+			s.maxPaused.Round(time.Millisecond), (s.paused / time.Duration(s.samples)).Round(time.Millisecond))
+	}
+	fmt.Println("result             no invariant violations")
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+	"max.com/pkg/order"
+)
+
+// =============================================================================
+// 内存冷账本 (实现 futures.MarginLedger)
+// =============================================================================
+//
+// 与 pkg/futures 测试夹具的 memLedger 同语义：按 EventID 幂等，余额不足不记流水。
+// 区别是只记幂等键、不留流水明细，且幂等键分两代轮换：soak 一跑几小时，
+// 全量保留会占满内存；处理器只在同一笔订单结束时重复提交同一个键 (撤单 / 剩余撤销)，
+// 两代窗口足够覆盖
+
+type balanceKey struct {
+	userID int64
+	symbol string
+}
+
+// journalGeneration 每代最多保留的幂等键数
+const journalGeneration = 1 << 20
+
+type memLedger struct {
+	mu       sync.Mutex
+	bal      map[balanceKey]*fund.BalanceRecord
+	seen     map[string]struct{} // 当前代
+	prevSeen map[string]struct{} // 上一代
+	journals int64               // 累计流水数 (报告用)
+}
+
+func newMemLedger() *memLedger {
+	return &memLedger{
+		bal:  make(map[balanceKey]*fund.BalanceRecord),
+		seen: make(map[string]struct{}),
+	}
+}
+
+func (l *memLedger) record(userID int64, symbol string) *fund.BalanceRecord {
+	k := balanceKey{userID, symbol}
+	if l.bal[k] == nil {
+		l.bal[k] = &fund.BalanceRecord{UserID: userID, Symbol: symbol}
+	}
+	return l.bal[k]
+}
+
+func (l *memLedger) GetBalance(ctx context.Context, userID int64, symbol string) (*fund.BalanceRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.bal[balanceKey{userID, symbol}]
+	if !ok {
+		return nil, nil
+	}
+	cp := *b
+	return &cp, nil
+}
+
+func (l *memLedger) FreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	return l.apply(userID, symbol, -amount, amount, ref)
+}
+
+func (l *memLedger) UnfreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	return l.apply(userID, symbol, amount, -amount, ref)
+}
+
+func (l *memLedger) AddAvailableWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	return l.apply(userID, symbol, amount, 0, ref)
+}
+
+func (l *memLedger) apply(userID int64, symbol string, deltaAvailable, deltaLocked int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, dup := l.seen[ref.EventID]
+	if _, old := l.prevSeen[ref.EventID]; dup || old {
+		return &fund.JournalRecord{EventID: ref.EventID, UserID: userID, Symbol: symbol}, true, nil
+	}
+	b := l.record(userID, symbol)
+	if b.Available+deltaAvailable < 0 {
+		return nil, false, fund.ErrInsufficientAvailable
+	}
+	if b.Locked+deltaLocked < 0 {
+		return nil, false, fund.ErrInsufficientLocked
+	}
+	j := &fund.JournalRecord{
+		EventID:         ref.EventID,
+		UserID:          userID,
+		Symbol:          symbol,
+		ChangeType:      ref.ChangeType,
+		AvailableBefore: b.Available,
+		AvailableAfter:  b.Available + deltaAvailable,
+		LockedBefore:    b.Locked,
+		LockedAfter:     b.Locked + deltaLocked,
+		BizType:         ref.BizType,
+		BizID:           ref.BizID,
+	}
+	b.Available, b.Locked = j.AvailableAfter, j.LockedAfter
+
+	if len(l.seen) >= journalGeneration {
+		l.prevSeen, l.seen = l.seen, make(map[string]struct{})
+	}
+	l.seen[ref.EventID] = struct{}{}
+	l.journals++
+	return j, false, nil
+}
+
+// deposit 充值入账 (不走流水，充值总额由 exchange 单独记账)
+func (l *memLedger) deposit(userID int64, symbol string, amount int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record(userID, symbol).Available += amount
+}
+
+// balances 全部余额的拷贝
+func (l *memLedger) balances() []fund.BalanceRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]fund.BalanceRecord, 0, len(l.bal))
+	for _, b := range l.bal {
+		out = append(out, *b)
+	}
+	return out
+}
+
+func (l *memLedger) journalCount() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.journals
+}
+
+// =============================================================================
+// 订单记录 (实现 order.OrderRepository)
+// =============================================================================
+
+// orderSink 合约处理器只写不读订单记录 (成交和状态走撮合事件)，soak 只计数不保留
+type orderSink struct {
+	created atomic.Int64
+}
+
+func (s *orderSink) Create(ctx context.Context, o *order.Order) error {
+	s.created.Add(1)
+	return nil
+}
+
+func (s *orderSink) GetByOrderID(ctx context.Context, orderID int64) (*order.Order, error) {
+	return nil, nil
+}
+
+func (s *orderSink) GetActiveByUser(ctx context.Context, userID int64) ([]*order.Order, error) {
+	return nil, nil
+}
+
+func (s *orderSink) GetByUserAndSymbol(ctx context.Context, userID int64, symbol string, limit int) ([]*order.Order, error) {
+	return nil, nil
+}
+
+func (s *orderSink) UpdateFill(ctx context.Context, orderID int64, prevFilledQty, filledQty, avgPrice int64, status order.OrderStatus) (bool, error) {
+	return false, nil
+}
+
+func (s *orderSink) UpdateStatus(ctx context.Context, orderID int64, status order.OrderStatus) error {
+	return nil
+}
+
+// =============================================================================
+// 内存合约仓储 (实现 futures.ContractRepository)
+// =============================================================================
+
+type memContractRepo struct {
+	mu    sync.Mutex
+	specs map[string]*futures.ContractSpec
+}
+
+func newMemContractRepo(specs ...*futures.ContractSpec) *memContractRepo {
+	r := &memContractRepo{specs: make(map[string]*futures.ContractSpec)}
+	for _, s := range specs {
+		r.specs[s.Symbol] = s
+	}
+	return r
+}
+
+func (r *memContractRepo) Create(ctx context.Context, spec *futures.ContractSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.specs[spec.Symbol]; ok {
+		return futures.ErrSymbolExists
+	}
+	r.specs[spec.Symbol] = spec
+	return nil
+}
+
+func (r *memContractRepo) GetBySymbol(ctx context.Context, symbol string) (*futures.ContractSpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[symbol]
+	if !ok {
+		return nil, futures.ErrSymbolNotFound
+	}
+	cp := *spec
+	return &cp, nil
+}
+
+func (r *memContractRepo) Update(ctx context.Context, spec *futures.ContractSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs[spec.Symbol] = spec
+	return nil
+}
+
+func (r *memContractRepo) UpdateStatus(ctx context.Context, symbol string, from, to futures.ContractStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[symbol]
+	if !ok {
+		return futures.ErrSymbolNotFound
+	}
+	spec.Status = to
+	return nil
+}
+
+func (r *memContractRepo) List(ctx context.Context) ([]*futures.ContractSpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*futures.ContractSpec, 0, len(r.specs))
+	for _, s := range r.specs {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (r *memContractRepo) ListByStatus(ctx context.Context, status futures.ContractStatus) ([]*futures.ContractSpec, error) {
+	all, _ := r.List(ctx)
+	var out []*futures.ContractSpec
+	for _, s := range all {
+		if s.Status == status {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (r *memContractRepo) Delete(ctx context.Context, symbol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.specs, symbol)
+	return nil
+}
+
+// =============================================================================
+// 内存持仓仓储 (实现 futures.PositionRepository)
+// =============================================================================
+
+type memPositionRepo struct {
+	mu     sync.Mutex
+	nextID uint
+	pos    map[balanceKey]*futures.Position // (userID, symbol)
+}
+
+func newMemPositionRepo() *memPositionRepo {
+	return &memPositionRepo{pos: make(map[balanceKey]*futures.Position)}
+}
+
+func (r *memPositionRepo) GetByUserAndSymbol(ctx context.Context, userID int64, symbol string) (*futures.Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pos[balanceKey{userID, symbol}]
+	if !ok {
+		return nil, nil
+	}
+	cp := *p
+	return &cp, nil
+}
+
+func (r *memPositionRepo) GetByUser(ctx context.Context, userID int64) ([]*futures.Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*futures.Position
+	for k, p := range r.pos {
+		if k.userID == userID {
+			cp := *p
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out, nil
+}
+
+func (r *memPositionRepo) Save(ctx context.Context, pos *futures.Position) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pos.ID == 0 {
+		r.nextID++
+		pos.ID = r.nextID
+	}
+	cp := *pos
+	r.pos[balanceKey{pos.UserID, pos.Symbol}] = &cp
+	return nil
+}
+
+func (r *memPositionRepo) Delete(ctx context.Context, userID int64, symbol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pos, balanceKey{userID, symbol})
+	return nil
+}
+
+func (r *memPositionRepo) AddFunding(ctx context.Context, userID int64, symbol string, amount int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pos[balanceKey{userID, symbol}]; ok {
+		p.CycleFunding += amount
+	}
+	return nil
+}
+
+func (r *memPositionRepo) AddMargin(ctx context.Context, userID int64, symbol string, amount int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pos[balanceKey{userID, symbol}]
+	if !ok || p.Size == 0 {
+		return futures.ErrNoPosition
+	}
+	p.Margin += amount
+	return nil
+}
+
+func (r *memPositionRepo) ListBySymbol(ctx context.Context, symbol string, limit, offset int) ([]*futures.Position, error) {
+	all := r.all(symbol)
+	if offset >= len(all) {
+		return nil, nil
+	}
+	return all[offset:min(offset+limit, len(all))], nil
+}
+
+func (r *memPositionRepo) ListBySymbolAfter(ctx context.Context, symbol string, afterID uint, limit int) ([]*futures.Position, error) {
+	all := r.all(symbol)
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	var out []*futures.Position
+	for _, p := range all {
+		if p.ID > afterID && p.Size != 0 && len(out) < limit {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// all 某合约的全部持仓 (含已清零的)，symbol 为空时返回所有合约
+func (r *memPositionRepo) all(symbol string) []*futures.Position {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*futures.Position
+	for _, p := range r.pos {
+		if symbol == "" || p.Symbol == symbol {
+			cp := *p
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	return out
+}
@@ -0,0 +1,183 @@
+// Package account 账户状态 (KYC / 合规) 与交易权限
+//
+// 现货下单、合约开平仓、提现在动资金之前都会问 Provider 这个用户现在是什么状态：
+//
+//	状态          下单开仓   平仓/卖出   撤单   充值   提现
+//	ACTIVE          ✓          ✓        ✓      ✓      ✓
+//	UNVERIFIED      ✗          ✗        ✓      ✓      ✗    (未完成 KYC，只能充值)
+//	RESTRICTED      ✗          ✓        ✓      ✓      ✓    (只减仓：可以卖出/平仓后提走)
+//	BANNED          ✗          ✗        ✓      ✗      ✗
+//
+// 撤单永远允许：撤单只会降低风险敞口。
+// 现货没有"仓位"，RESTRICTED 用户只能挂卖单 (把持有的币卖成计价币)。
+//
+// 【fail-closed】查询状态失败时拒绝，和 risk/limits 一致：
+// 合规数据不可用时宁可误拒，也不能放行被封禁的账户
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrTradingNotAllowed  = errors.New("account: trading not allowed")
+	ErrCloseOnly          = errors.New("account: close-only, opening orders not allowed")
+	ErrWithdrawNotAllowed = errors.New("account: withdrawal not allowed")
+	ErrDepositNotAllowed  = errors.New("account: deposit not allowed")
+	ErrStatusLookup       = errors.New("account: status lookup failed")
+	ErrInvalidStatus      = errors.New("account: invalid status")
+	ErrApprovalRequired   = errors.New("account: unfreeze requires dual approval")
+)
+
+// =============================================================================
+// 状态
+// =============================================================================
+
+// Status 账户状态
+type Status int8
+
+const (
+	StatusActive     Status = iota + 1 // 正常
+	StatusUnverified                   // 未认证：只能充值
+	StatusRestricted                   // 受限：只能减仓
+	StatusBanned                       // 封禁：只能撤单
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusActive:
+		return "ACTIVE"
+	case StatusUnverified:
+		return "UNVERIFIED"
+	case StatusRestricted:
+		return "RESTRICTED"
+	case StatusBanned:
+		return "BANNED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseStatus 解析状态字符串
+func ParseStatus(s string) (Status, error) {
+	for st := StatusActive; st <= StatusBanned; st++ {
+		if st.String() == s {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidStatus, s)
+}
+
+// Valid 是否合法状态
+func (s Status) Valid() bool {
+	return s >= StatusActive && s <= StatusBanned
+}
+
+// CanOpen 能否开新仓 / 现货买入
+func (s Status) CanOpen() bool { return s == StatusActive }
+
+// CanClose 能否平仓 / 现货卖出
+func (s Status) CanClose() bool { return s == StatusActive || s == StatusRestricted }
+
+// CanDeposit 能否充值
+func (s Status) CanDeposit() bool { return s != StatusBanned && s.Valid() }
+
+// CanWithdraw 能否提现
+func (s Status) CanWithdraw() bool { return s == StatusActive || s == StatusRestricted }
+
+// IsUnfreeze 从 from 改为 to 是否属于解冻 (放宽限制)
+//
+// BANNED → RESTRICTED / ACTIVE、RESTRICTED → ACTIVE 都算解冻；
+// 冻结 (收紧) 要能一人立即执行，解冻必须走双人复核 (见 pkg/approval)
+func IsUnfreeze(from, to Status) bool {
+	return freezeLevel(to) < freezeLevel(from)
+}
+
+func freezeLevel(s Status) int {
+	switch s {
+	case StatusBanned:
+		return 2
+	case StatusRestricted:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// =============================================================================
+// Provider
+// =============================================================================
+
+// Provider 查询账户状态 (KYC 服务、用户中心等实现)
+type Provider interface {
+	Status(ctx context.Context, userID int64) (Status, error)
+}
+
+// lookup 查询状态，失败统一包装为 ErrStatusLookup
+func lookup(ctx context.Context, p Provider, userID int64) (Status, error) {
+	st, err := p.Status(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: user %d: %v", ErrStatusLookup, userID, err)
+	}
+	if !st.Valid() {
+		return 0, fmt.Errorf("%w: user %d status %d", ErrStatusLookup, userID, st)
+	}
+	return st, nil
+}
+
+// CheckOpen 开仓 / 现货买入前检查
+func CheckOpen(ctx context.Context, p Provider, userID int64) error {
+	st, err := lookup(ctx, p, userID)
+	if err != nil {
+		return err
+	}
+	if st == StatusRestricted {
+		return ErrCloseOnly
+	}
+	if !st.CanOpen() {
+		return fmt.Errorf("%w: status %s", ErrTradingNotAllowed, st)
+	}
+	return nil
+}
+
+// CheckClose 平仓 / 现货卖出前检查
+func CheckClose(ctx context.Context, p Provider, userID int64) error {
+	st, err := lookup(ctx, p, userID)
+	if err != nil {
+		return err
+	}
+	if !st.CanClose() {
+		return fmt.Errorf("%w: status %s", ErrTradingNotAllowed, st)
+	}
+	return nil
+}
+
+// CheckDeposit 充值入账前检查
+func CheckDeposit(ctx context.Context, p Provider, userID int64) error {
+	st, err := lookup(ctx, p, userID)
+	if err != nil {
+		return err
+	}
+	if !st.CanDeposit() {
+		return fmt.Errorf("%w: status %s", ErrDepositNotAllowed, st)
+	}
+	return nil
+}
+
+// CheckWithdraw 提现前检查
+func CheckWithdraw(ctx context.Context, p Provider, userID int64) error {
+	st, err := lookup(ctx, p, userID)
+	if err != nil {
+		return err
+	}
+	if !st.CanWithdraw() {
+		return fmt.Errorf("%w: status %s", ErrWithdrawNotAllowed, st)
+	}
+	return nil
+}
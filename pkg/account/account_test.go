@@ -0,0 +1,134 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"max.com/pkg/audit"
+)
+
+type failingProvider struct{}
+
+func (failingProvider) Status(context.Context, int64) (Status, error) {
+	return 0, errors.New("kyc service down")
+}
+
+type countingProvider struct {
+	status Status
+	calls  int
+}
+
+func (c *countingProvider) Status(context.Context, int64) (Status, error) {
+	c.calls++
+	return c.status, nil
+}
+
+type recorder struct{ events []audit.Event }
+
+func (r *recorder) Record(e audit.Event) { r.events = append(r.events, e) }
+
+func TestPermissionMatrix(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		status                         Status
+		open, close, deposit, withdraw error
+	}{
+		{StatusActive, nil, nil, nil, nil},
+		{StatusUnverified, ErrTradingNotAllowed, ErrTradingNotAllowed, nil, ErrWithdrawNotAllowed},
+		{StatusRestricted, ErrCloseOnly, nil, nil, nil},
+		{StatusBanned, ErrTradingNotAllowed, ErrTradingNotAllowed, ErrDepositNotAllowed, ErrWithdrawNotAllowed},
+	}
+	for _, c := range cases {
+		p := NewMemoryProvider(c.status)
+		check := func(name string, err, want error) {
+			if want == nil && err != nil {
+				t.Errorf("%s %s: unexpected error %v", c.status, name, err)
+			}
+			if want != nil && !errors.Is(err, want) {
+				t.Errorf("%s %s: got %v, want %v", c.status, name, err, want)
+			}
+		}
+		check("open", CheckOpen(ctx, p, 1), c.open)
+		check("close", CheckClose(ctx, p, 1), c.close)
+		check("deposit", CheckDeposit(ctx, p, 1), c.deposit)
+		check("withdraw", CheckWithdraw(ctx, p, 1), c.withdraw)
+	}
+
+	// fail-closed
+	if err := CheckClose(ctx, failingProvider{}, 1); !errors.Is(err, ErrStatusLookup) {
+		t.Fatalf("lookup failure should be rejected, got %v", err)
+	}
+}
+
+func TestCachedProvider(t *testing.T) {
+	next := &countingProvider{status: StatusActive}
+	c := NewCachedProvider(next, time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	c.Status(ctx, 1)
+	c.Status(ctx, 1)
+	if next.calls != 1 {
+		t.Fatalf("expected 1 lookup within TTL, got %d", next.calls)
+	}
+
+	// 封禁后失效缓存立即生效
+	next.status = StatusBanned
+	c.Invalidate(1)
+	if st, _ := c.Status(ctx, 1); st != StatusBanned {
+		t.Fatalf("expected BANNED after invalidate, got %s", st)
+	}
+
+	// TTL 过期重新查询
+	next.status = StatusActive
+	now = now.Add(2 * time.Minute)
+	if st, _ := c.Status(ctx, 1); st != StatusActive || next.calls != 3 {
+		t.Fatalf("expected refresh after TTL, got %s calls=%d", st, next.calls)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	p := NewMemoryProvider(StatusUnverified)
+	rec := &recorder{}
+	h := NewAdminHandler(p, rec)
+
+	body := `{"user_id":1001,"status":"RESTRICTED","operator_id":7,"reason":"aml review"}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/account/status", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set status: %d %s", w.Code, w.Body.String())
+	}
+	if st, _ := p.Status(context.Background(), 1001); st != StatusRestricted {
+		t.Fatalf("expected RESTRICTED, got %s", st)
+	}
+	if len(rec.events) != 1 || rec.events[0].Before != "UNVERIFIED" || rec.events[0].After != "RESTRICTED" {
+		t.Fatalf("unexpected audit events: %+v", rec.events)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/account/status", strings.NewReader(`{"user_id":1,"status":"FROZEN","operator_id":7}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid status should be 400, got %d", w.Code)
+	}
+
+	// 解冻必须走双人复核
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/account/status", strings.NewReader(`{"user_id":1001,"status":"ACTIVE","operator_id":7}`)))
+	if w.Code != http.StatusForbidden || len(rec.events) != 1 {
+		t.Fatalf("unfreeze should be 403 without audit, got %d events=%d", w.Code, len(rec.events))
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/account/status?user_id=1001", nil))
+	var resp StatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Status != "RESTRICTED" {
+		t.Fatalf("get status: %+v %v", resp, err)
+	}
+}
@@ -0,0 +1,125 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"max.com/pkg/audit"
+)
+
+// =============================================================================
+// 管理后台接口 (仅内网)
+// =============================================================================
+
+// StatusRequest 修改状态请求
+type StatusRequest struct {
+	UserID     int64  `json:"user_id"`
+	Status     string `json:"status"` // ACTIVE / UNVERIFIED / RESTRICTED / BANNED
+	OperatorID int64  `json:"operator_id"`
+	Reason     string `json:"reason"`
+}
+
+// StatusResponse 状态查询响应
+type StatusResponse struct {
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// NewAdminHandler 创建账户状态管理接口
+//
+//	GET  /account/status?user_id=1001   查询
+//	POST /account/status                修改 (StatusRequest)，解冻返回 403，须走 /admin/approvals
+//	GET  /account/status/history?user_id=1001
+//
+// auditor 不为 nil 时每次修改记一条 ADMIN 审计
+func NewAdminHandler(p *MemoryProvider, auditor audit.Recorder) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/account/status", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+			if err != nil || userID <= 0 {
+				http.Error(w, "invalid user_id", http.StatusBadRequest)
+				return
+			}
+			st, _ := p.Status(r.Context(), userID)
+			writeJSON(w, StatusResponse{UserID: userID, Status: st.String()})
+
+		case http.MethodPost:
+			var req StatusRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 || req.OperatorID <= 0 {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			st, err := ParseStatus(req.Status)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			change, err := p.setStatus(req.UserID, st, req.OperatorID, req.Reason, rejectUnfreeze)
+			if err != nil {
+				status := http.StatusInternalServerError
+				switch {
+				case errors.Is(err, ErrInvalidStatus):
+					status = http.StatusBadRequest
+				case errors.Is(err, ErrApprovalRequired):
+					status = http.StatusForbidden
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			if auditor != nil {
+				auditor.Record(audit.Event{
+					ActorType: audit.ActorAdmin,
+					ActorID:   req.OperatorID,
+					Action:    audit.ActionAdmin,
+					Resource:  "account_status:" + strconv.FormatInt(req.UserID, 10),
+					Before:    change.From.String(),
+					After:     change.To.String(),
+					Meta:      map[string]string{"reason": req.Reason},
+				})
+			}
+			writeJSON(w, StatusResponse{UserID: req.UserID, Status: st.String()})
+
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/account/status/history", func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+		if err != nil || userID <= 0 {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		type item struct {
+			From       string `json:"from"`
+			To         string `json:"to"`
+			OperatorID int64  `json:"operator_id"`
+			Reason     string `json:"reason"`
+			ChangedAt  int64  `json:"changed_at"`
+		}
+		out := []item{}
+		for _, c := range p.History(userID) {
+			out = append(out, item{c.From.String(), c.To.String(), c.OperatorID, c.Reason, c.ChangedAt})
+		}
+		writeJSON(w, out)
+	})
+	return mux
+}
+
+// rejectUnfreeze 单人接口只允许收紧，解冻由 approval.AccountUnfreezeOperation 执行
+func rejectUnfreeze(from, to Status) error {
+	if IsUnfreeze(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrApprovalRequired, from, to)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
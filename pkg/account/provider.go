@@ -0,0 +1,185 @@
+package account
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// MemoryProvider - 默认内存实现
+// =============================================================================
+
+// StatusChange 一次状态变更记录
+type StatusChange struct {
+	UserID     int64
+	From       Status
+	To         Status
+	OperatorID int64
+	Reason     string
+	ChangedAt  int64 // 毫秒
+}
+
+// MemoryProvider 内存账户状态
+//
+// 没有设置过的用户返回 defaultStatus：
+// 新用户默认 UNVERIFIED，KYC 通过后由管理后台 / KYC 回调改为 ACTIVE
+type MemoryProvider struct {
+	mu            sync.RWMutex
+	defaultStatus Status
+	statuses      map[int64]Status
+	history       []StatusChange
+	onChange      []func(StatusChange)
+}
+
+// NewMemoryProvider 创建内存实现
+func NewMemoryProvider(defaultStatus Status) *MemoryProvider {
+	if !defaultStatus.Valid() {
+		defaultStatus = StatusUnverified
+	}
+	return &MemoryProvider{
+		defaultStatus: defaultStatus,
+		statuses:      make(map[int64]Status),
+	}
+}
+
+// Status 实现 Provider
+func (p *MemoryProvider) Status(ctx context.Context, userID int64) (Status, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if st, ok := p.statuses[userID]; ok {
+		return st, nil
+	}
+	return p.defaultStatus, nil
+}
+
+// SetStatus 修改用户状态，返回变更记录
+func (p *MemoryProvider) SetStatus(userID int64, status Status, operatorID int64, reason string) (StatusChange, error) {
+	return p.setStatus(userID, status, operatorID, reason, nil)
+}
+
+// setStatus check 在锁内用当前状态校验本次变更，返回错误则不修改
+func (p *MemoryProvider) setStatus(userID int64, status Status, operatorID int64, reason string, check func(from, to Status) error) (StatusChange, error) {
+	if !status.Valid() {
+		return StatusChange{}, ErrInvalidStatus
+	}
+
+	p.mu.Lock()
+	from, ok := p.statuses[userID]
+	if !ok {
+		from = p.defaultStatus
+	}
+	if check != nil {
+		if err := check(from, status); err != nil {
+			p.mu.Unlock()
+			return StatusChange{}, err
+		}
+	}
+	p.statuses[userID] = status
+	change := StatusChange{
+		UserID:     userID,
+		From:       from,
+		To:         status,
+		OperatorID: operatorID,
+		Reason:     reason,
+		ChangedAt:  time.Now().UnixMilli(),
+	}
+	p.history = append(p.history, change)
+	callbacks := p.onChange
+	p.mu.Unlock()
+
+	// 回调在锁外执行 (如让缓存失效、记审计)
+	for _, fn := range callbacks {
+		fn(change)
+	}
+	return change, nil
+}
+
+// History 用户状态变更历史 (userID 为 0 返回全部)
+func (p *MemoryProvider) History(userID int64) []StatusChange {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out []StatusChange
+	for _, c := range p.history {
+		if userID == 0 || c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// OnChange 注册状态变更回调
+func (p *MemoryProvider) OnChange(fn func(StatusChange)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = append(p.onChange, fn)
+}
+
+// =============================================================================
+// CachedProvider - 带 TTL 的本地缓存
+// =============================================================================
+
+// CachedProvider 给远程 Provider (KYC 服务) 加本地缓存
+//
+// 下单是热路径，不能每单都 RPC；状态变化很少，TTL 内用缓存值。
+// 【注意】封禁要立即生效：变更方 (管理后台 / KYC 回调) 必须调用 Invalidate，
+// TTL 只是兜底，防止漏发失效通知时永远用旧值。
+// 查询失败不缓存，下一次请求重新查询 (配合 fail-closed)
+type CachedProvider struct {
+	next Provider
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu    sync.RWMutex
+	cache map[int64]cachedStatus
+}
+
+type cachedStatus struct {
+	status    Status
+	expiresAt time.Time
+}
+
+// NewCachedProvider 创建缓存 Provider，ttl <= 0 时默认 30s
+func NewCachedProvider(next Provider, ttl time.Duration) *CachedProvider {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &CachedProvider{
+		next:  next,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: make(map[int64]cachedStatus),
+	}
+}
+
+// Status 实现 Provider
+func (c *CachedProvider) Status(ctx context.Context, userID int64) (Status, error) {
+	now := c.now()
+	c.mu.RLock()
+	entry, ok := c.cache[userID]
+	c.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.status, nil
+	}
+
+	st, err := c.next.Status(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.cache[userID] = cachedStatus{status: st, expiresAt: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return st, nil
+}
+
+// Invalidate 让某个用户的缓存失效
+func (c *CachedProvider) Invalidate(userID int64) {
+	c.mu.Lock()
+	delete(c.cache, userID)
+	c.mu.Unlock()
+}
+
+var (
+	_ Provider = (*MemoryProvider)(nil)
+	_ Provider = (*CachedProvider)(nil)
+)
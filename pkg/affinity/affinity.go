@@ -0,0 +1,101 @@
+// Package affinity 线程绑核与 NUMA 拓扑
+//
+// 撮合 matchLoop、资产分片 processLoop 都是 "一个 goroutine 独占一份状态" 的单线程模型，
+// 在多核、多 NUMA 节点的机器上，goroutine 被调度器在核之间迁移会导致：
+//   - L1/L2 缓存失效，热数据要重新加载
+//   - 跨 NUMA 节点访问内存，延迟翻倍
+//
+// 做法：goroutine 先 runtime.LockOSThread 独占一个线程，再把线程绑到指定 CPU。
+//
+// 【注意】
+//   - 绑核只是提示：非 Linux 平台返回 ErrUnsupported，调用方应当记录后继续运行
+//   - 绑过核的线程不要 UnlockOSThread，让它随 goroutine 退出销毁，
+//     避免调度器把一个被限制了 CPU 的线程交给别的 goroutine
+package affinity
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported 当前平台不支持绑核
+var ErrUnsupported = errors.New("cpu affinity not supported on this platform")
+
+// Pin 锁定当前 goroutine 所在线程并绑定到 cpus（cpus 为空则只锁线程）
+// 返回绑核失败的原因；无论成功与否线程都已锁定
+func Pin(cpus []int) error {
+	runtime.LockOSThread()
+	if len(cpus) == 0 {
+		return nil
+	}
+	return setThreadAffinity(cpus)
+}
+
+// NUMANodes 返回每个 NUMA 节点上的 CPU 列表
+// 无法探测时（非 Linux、容器内无 sysfs）视为单节点，包含 0..NumCPU-1
+func NUMANodes() [][]int {
+	if nodes := detectNUMANodes(); len(nodes) > 0 {
+		return nodes
+	}
+	all := make([]int, runtime.NumCPU())
+	for i := range all {
+		all[i] = i
+	}
+	return [][]int{all}
+}
+
+// Spread 把 n 个工作线程分散到各 NUMA 节点的 CPU 上
+//
+// 【分配规则】
+//   - 按节点轮询：worker0 → node0, worker1 → node1, ...，让各节点负载均衡
+//   - 节点内按 CPU 顺序依次分配，每个 worker 独占一个 CPU
+//   - CPU 不够时从头复用（多个 worker 共享 CPU，仍然不会跨节点）
+func Spread(n int, nodes [][]int) [][]int {
+	var usable [][]int
+	for _, node := range nodes {
+		if len(node) > 0 {
+			usable = append(usable, node)
+		}
+	}
+	if n <= 0 || len(usable) == 0 {
+		return nil
+	}
+
+	out := make([][]int, n)
+	next := make([]int, len(usable)) // 每个节点下一个要分配的 CPU 下标
+	for i := 0; i < n; i++ {
+		node := i % len(usable)
+		cpus := usable[node]
+		out[i] = []int{cpus[next[node]%len(cpus)]}
+		next[node]++
+	}
+	return out
+}
+
+// parseCPUList 解析 sysfs 的 cpulist 格式，如 "0-3,8-11,16"
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil {
+				return nil, err
+			}
+		}
+		for c := start; c <= end; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
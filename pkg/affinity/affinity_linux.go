@@ -0,0 +1,55 @@
+//go:build linux
+
+package affinity
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// setThreadAffinity 把当前线程绑定到指定 CPU（调用方需已 LockOSThread）
+func setThreadAffinity(cpus []int) error {
+	var set unix.CPUSet
+	set.Zero()
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	return unix.SchedSetaffinity(0, &set) // 0 = 当前线程
+}
+
+// detectNUMANodes 读取 /sys/devices/system/node/node*/cpulist
+func detectNUMANodes() [][]int {
+	paths, _ := filepath.Glob("/sys/devices/system/node/node[0-9]*/cpulist")
+	type node struct {
+		id   int
+		cpus []int
+	}
+	var nodes []node
+	for _, p := range paths {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(p)), "node"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		cpus, err := parseCPUList(string(data))
+		if err != nil || len(cpus) == 0 {
+			continue
+		}
+		nodes = append(nodes, node{id, cpus})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].id < nodes[j].id })
+
+	out := make([][]int, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.cpus
+	}
+	return out
+}
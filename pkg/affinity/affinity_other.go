@@ -0,0 +1,13 @@
+//go:build !linux
+
+package affinity
+
+// setThreadAffinity 非 Linux 平台不支持绑核，只保留 LockOSThread
+func setThreadAffinity(cpus []int) error {
+	return ErrUnsupported
+}
+
+// detectNUMANodes 非 Linux 平台不探测 NUMA 拓扑
+func detectNUMANodes() [][]int {
+	return nil
+}
@@ -0,0 +1,48 @@
+package affinity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cases := map[string][]int{
+		"":             nil,
+		"0":            {0},
+		"0-3":          {0, 1, 2, 3},
+		"0-1,8-9,16\n": {0, 1, 8, 9, 16},
+	}
+	for in, want := range cases {
+		got, err := parseCPUList(in)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("parseCPUList(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseCPUList("a-b"); err == nil {
+		t.Error("expected error for malformed list")
+	}
+}
+
+func TestSpread(t *testing.T) {
+	nodes := [][]int{{0, 1, 2}, {8, 9}}
+
+	got := Spread(6, nodes)
+	want := [][]int{{0}, {8}, {1}, {9}, {2}, {8}} // 节点 1 的 CPU 不够，从头复用
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Spread = %v, want %v", got, want)
+	}
+
+	if Spread(4, nil) != nil || Spread(0, nodes) != nil {
+		t.Error("expected nil for empty topology or zero workers")
+	}
+	if got := Spread(2, [][]int{{}, {5}}); !reflect.DeepEqual(got, [][]int{{5}, {5}}) {
+		t.Errorf("empty nodes should be skipped, got %v", got)
+	}
+}
+
+func TestNUMANodesCoversSomeCPU(t *testing.T) {
+	nodes := NUMANodes()
+	if len(nodes) == 0 || len(nodes[0]) == 0 {
+		t.Fatalf("expected at least one node with cpus, got %v", nodes)
+	}
+}
@@ -0,0 +1,193 @@
+// Package algo 算法执行 (TWAP / 参与率 POV / 冰山)
+//
+// 大单直接砸进订单簿会吃穿多档，冲击成本高，也暴露交易意图。
+// 算法单把一个母单 (parent) 拆成多笔子单 (child)，按策略分批提交：
+//
+//   - TWAP:    在 Duration 内等分 Slices 片，按固定时间间隔下单
+//   - POV:     按市场成交量的固定比例下单 (跟量)，市场越活跃下得越快
+//   - Iceberg: 盘口只露 DisplayQty，上一笔完全成交后再露下一笔
+//
+// 子单通过 Router 提交给 SpotProcessor / FuturesProcessor，
+// 成交、撤单、拒单从撮合引擎事件 (Service.HandleEvent) 回流，按子单 ID 归集到母单。
+//
+// 【面试】为什么下单只在调度协程里做，而不是在成交回调里立刻补单？
+// 回调运行在撮合引擎的 handler 协程上，在里面再提交订单会让 handler 和撮合互相等待；
+// 成交回调只更新状态，补单统一交给下一次 tick，单写者也更容易推理。
+//
+// 【注意】母单状态只在内存中，进程重启后算法单不会恢复；已提交的子单仍在订单簿上，
+// 由常规的撤单 / 对账流程处理
+package algo
+
+import (
+	"errors"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrInvalidParams = errors.New("algo: invalid params")
+	ErrAlgoNotFound  = errors.New("algo: order not found")
+	ErrAlgoFinished  = errors.New("algo: order already finished")
+)
+
+// RatePrecision 参与率精度 (万分比)
+const RatePrecision = 10000
+
+// =============================================================================
+// 策略 / 状态
+// =============================================================================
+
+// Strategy 执行策略
+type Strategy int8
+
+const (
+	StrategyTWAP    Strategy = iota + 1 // 时间加权
+	StrategyPOV                         // 按成交量参与率
+	StrategyIceberg                     // 冰山
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case StrategyTWAP:
+		return "TWAP"
+	case StrategyPOV:
+		return "POV"
+	case StrategyIceberg:
+		return "ICEBERG"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Status 母单状态
+//
+//	Running ──→ Completed (全部成交)
+//	   ├──────→ Canceled  (用户撤销)
+//	   ├──────→ Expired   (到期仍有未成交，剩余子单已撤)
+//	   └──────→ Failed    (子单提交失败 / 被拒)
+type Status int8
+
+const (
+	StatusRunning Status = iota + 1
+	StatusCompleted
+	StatusCanceled
+	StatusExpired
+	StatusFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusRunning:
+		return "RUNNING"
+	case StatusCompleted:
+		return "COMPLETED"
+	case StatusCanceled:
+		return "CANCELED"
+	case StatusExpired:
+		return "EXPIRED"
+	case StatusFailed:
+		return "FAILED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// IsTerminal 是否终态 (终态后不再提交子单，已提交子单的成交仍会计入)
+func (s Status) IsTerminal() bool {
+	return s != StatusRunning
+}
+
+// =============================================================================
+// 母单参数 / 子单
+// =============================================================================
+
+// Params 母单参数
+type Params struct {
+	UserID     int64
+	Symbol     string
+	Side       mtrade.Side
+	TotalQty   int64 // 母单总量
+	LimitPrice int64 // 子单限价 (所有子单都挂这个价，防止追价)
+	Strategy   Strategy
+
+	// 执行时长：TWAP 必填；POV / Iceberg 可选，0 表示不限时
+	// 到期后撤掉所有未成交子单
+	Duration time.Duration
+
+	Slices            int   // TWAP: 切片数
+	ParticipationRate int64 // POV: 参与率 (万分比)，如 1000 = 市场成交量的 10%
+	MinSliceQty       int64 // POV: 子单最小数量，避免下出大量碎单
+	DisplayQty        int64 // Iceberg: 每次露出的数量
+}
+
+// validate 参数检查
+func (p *Params) validate() error {
+	if p.UserID <= 0 || p.Symbol == "" || p.TotalQty <= 0 || p.LimitPrice <= 0 || p.Duration < 0 {
+		return ErrInvalidParams
+	}
+	if p.Side != mtrade.SideBuy && p.Side != mtrade.SideSell {
+		return ErrInvalidParams
+	}
+
+	switch p.Strategy {
+	case StrategyTWAP:
+		if p.Duration <= 0 || p.Slices <= 0 || int64(p.Slices) > p.TotalQty {
+			return ErrInvalidParams
+		}
+	case StrategyPOV:
+		if p.ParticipationRate <= 0 || p.ParticipationRate >= RatePrecision || p.MinSliceQty < 0 {
+			return ErrInvalidParams
+		}
+	case StrategyIceberg:
+		if p.DisplayQty <= 0 || p.DisplayQty > p.TotalQty {
+			return ErrInvalidParams
+		}
+	default:
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+// ChildOrder 子单
+type ChildOrder struct {
+	ID       int64 // 子单 ID (由 Service 预先生成，用于归集成交)
+	ParentID int64 // 母单 ID
+	UserID   int64
+	Symbol   string
+	Side     mtrade.Side
+	Price    int64
+	Qty      int64
+}
+
+// =============================================================================
+// 执行统计
+// =============================================================================
+
+// Stats 母单执行统计
+type Stats struct {
+	ID       int64
+	UserID   int64
+	Symbol   string
+	Side     mtrade.Side
+	Strategy Strategy
+	Status   Status
+	Error    string // Failed 时的原因
+
+	TotalQty  int64
+	FilledQty int64
+	OpenQty   int64 // 已提交未成交 (在订单簿上)
+	AvgPrice  int64 // 成交均价 (VWAP)
+	FillRate  int64 // 成交比例 (万分比)
+
+	ChildOrders      int   // 已提交子单数
+	CanceledChildren int   // 撤单 / 拒单的子单数
+	MarketVolume     int64 // POV: 运行期间市场成交量 (不含自己)
+
+	StartedAt  int64 // Unix 毫秒
+	FinishedAt int64 // 进入终态的时间，0 表示仍在运行
+}
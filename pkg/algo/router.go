@@ -0,0 +1,92 @@
+package algo
+
+import (
+	"context"
+	"errors"
+
+	"max.com/pkg/futures"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/spot"
+)
+
+// errCancelQueueFull 撮合撤单队列满
+var errCancelQueueFull = errors.New("algo: cancel queue full")
+
+// Router 子单的下单通道
+type Router interface {
+	// Submit 提交子单，child.ID 必须作为订单 ID 使用
+	Submit(ctx context.Context, child ChildOrder) error
+	// Cancel 撤销子单 (异步，结果以撮合的撤单事件为准)
+	Cancel(ctx context.Context, child ChildOrder) error
+}
+
+var (
+	_ Router = (*SpotRouter)(nil)
+	_ Router = (*FuturesRouter)(nil)
+)
+
+// =============================================================================
+// 现货
+// =============================================================================
+
+// SpotRouter 通过 SpotProcessor 下现货子单 (冻结资金 → 撮合)
+type SpotRouter struct {
+	Processor *spot.SpotProcessor
+}
+
+// Submit 提交现货限价子单
+func (r *SpotRouter) Submit(ctx context.Context, child ChildOrder) error {
+	return r.Processor.PlaceOrder(&mtrade.Order{
+		ID:     child.ID,
+		UserID: child.UserID,
+		Symbol: child.Symbol,
+		Side:   child.Side,
+		Type:   mtrade.OrderTypeLimit,
+		Price:  child.Price,
+		Qty:    child.Qty,
+	})
+}
+
+// Cancel 撤销现货子单
+func (r *SpotRouter) Cancel(ctx context.Context, child ChildOrder) error {
+	if !r.Processor.CancelOrder(child.ID) {
+		return errCancelQueueFull
+	}
+	return nil
+}
+
+// =============================================================================
+// 合约
+// =============================================================================
+
+// FuturesRouter 通过 FuturesProcessor 下合约开仓子单
+// 买 = 开多，卖 = 开空，所有子单使用同一杠杆
+type FuturesRouter struct {
+	Processor *futures.FuturesProcessor
+	Leverage  int
+}
+
+// Submit 提交合约开仓子单
+func (r *FuturesRouter) Submit(ctx context.Context, child ChildOrder) error {
+	side := futures.SideLong
+	if child.Side == mtrade.SideSell {
+		side = futures.SideShort
+	}
+	return r.Processor.OpenPosition(ctx, &futures.OpenPositionRequest{
+		OrderID:  child.ID,
+		UserID:   child.UserID,
+		Symbol:   child.Symbol,
+		Side:     side,
+		Qty:      child.Qty,
+		Price:    child.Price,
+		Leverage: r.Leverage,
+	})
+}
+
+// Cancel 撤销合约子单
+func (r *FuturesRouter) Cancel(ctx context.Context, child ChildOrder) error {
+	if !r.Processor.CancelOrder(child.ID) {
+		return errCancelQueueFull
+	}
+	return nil
+}
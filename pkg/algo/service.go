@@ -0,0 +1,504 @@
+package algo
+
+import (
+	"context"
+	"log"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 算法执行服务配置
+type Config struct {
+	TickInterval time.Duration // 调度间隔：检查切片时间、补单、到期
+	NewOrderID   func() int64  // 子单 ID 生成器，默认雪花 ID
+	// Retention 母单结束后保留多久供 Get / List 查询，过后由 tick 清理，默认 10 分钟
+	// 保留期内撤单回报前的成交仍计入统计；过了保留期还没回报的子单一并丢弃
+	Retention time.Duration
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		TickInterval: time.Second,
+		NewOrderID:   order.GenerateOrderID,
+		Retention:    10 * time.Minute,
+	}
+}
+
+// =============================================================================
+// 内部状态
+// =============================================================================
+
+// child 子单执行状态
+type child struct {
+	order      ChildOrder
+	filled     int64
+	cancelSent bool
+}
+
+func (c *child) open() int64 {
+	return c.order.Qty - c.filled
+}
+
+// parent 母单执行状态 (受 Service.mu 保护)
+type parent struct {
+	id     int64
+	params Params
+	status Status
+	err    string
+
+	startedAt   time.Time
+	deadline    time.Time // 零值表示不限时
+	finishedAt  time.Time
+	nextSliceAt time.Time // TWAP
+	slicesSent  int       // TWAP
+
+	filled    int64
+	openQty   int64
+	notionalH uint64 // Σ price × qty 的 128 位累加，用于算 VWAP
+	notionalL uint64
+
+	children         map[int64]*child // 未结束的子单
+	childOrders      int
+	canceledChildren int
+	marketVolume     int64 // POV
+}
+
+func (a *parent) remaining() int64 {
+	return a.params.TotalQty - a.filled - a.openQty
+}
+
+func (a *parent) finish(status Status, now time.Time) {
+	a.status = status
+	a.finishedAt = now
+}
+
+func (a *parent) stats() Stats {
+	s := Stats{
+		ID:               a.id,
+		UserID:           a.params.UserID,
+		Symbol:           a.params.Symbol,
+		Side:             a.params.Side,
+		Strategy:         a.params.Strategy,
+		Status:           a.status,
+		Error:            a.err,
+		TotalQty:         a.params.TotalQty,
+		FilledQty:        a.filled,
+		OpenQty:          a.openQty,
+		FillRate:         a.filled * RatePrecision / a.params.TotalQty,
+		ChildOrders:      a.childOrders,
+		CanceledChildren: a.canceledChildren,
+		MarketVolume:     a.marketVolume,
+		StartedAt:        a.startedAt.UnixMilli(),
+	}
+	if a.filled > 0 {
+		// 均价 ≤ 最高成交价，商不会溢出
+		q, _ := bits.Div64(a.notionalH, a.notionalL, uint64(a.filled))
+		s.AvgPrice = int64(q)
+	}
+	if !a.finishedAt.IsZero() {
+		s.FinishedAt = a.finishedAt.UnixMilli()
+	}
+	return s
+}
+
+// =============================================================================
+// Service
+// =============================================================================
+
+// Service 算法执行服务
+//
+// 使用方式:
+//
+//	svc := algo.NewService(&algo.SpotRouter{Processor: sp}, algo.DefaultConfig())
+//	svc.Subscribe(matchEngine)
+//	svc.Start(ctx)
+//	id, err := svc.Submit(ctx, algo.Params{...})
+type Service struct {
+	router Router
+	cfg    Config
+
+	mu      sync.Mutex
+	nextID  int64
+	parents map[int64]*parent
+	byChild map[int64]*parent // 子单 ID -> 母单，成交归集用
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewService 创建算法执行服务
+func NewService(router Router, cfg Config) *Service {
+	def := DefaultConfig()
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = def.TickInterval
+	}
+	if cfg.NewOrderID == nil {
+		cfg.NewOrderID = def.NewOrderID
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	return &Service{
+		router:  router,
+		cfg:     cfg,
+		parents: make(map[int64]*parent),
+		byChild: make(map[int64]*parent),
+	}
+}
+
+// Subscribe 订阅撮合引擎事件 (成交 / 撤单 / 拒单)
+func (s *Service) Subscribe(engine *mtrade.Engine) {
+	engine.OnEventWithOptions(s.HandleEvent, mtrade.HandlerOptions{Name: "algo"})
+}
+
+// Start 启动调度协程
+func (s *Service) Start(ctx context.Context) {
+	s.stopCh = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.TickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.stopCh:
+				return
+			case now := <-ticker.C:
+				s.tick(ctx, now)
+			}
+		}
+	}()
+}
+
+// Stop 停止调度 (不撤已提交的子单)
+func (s *Service) Stop() {
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.wg.Wait()
+		s.stopCh = nil
+	}
+}
+
+// =============================================================================
+// 用户接口
+// =============================================================================
+
+// Submit 创建母单，第一笔子单在下一次 tick 提交；返回母单 ID
+func (s *Service) Submit(ctx context.Context, p Params) (int64, error) {
+	if err := p.validate(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	a := &parent{
+		id:          s.nextID,
+		params:      p,
+		status:      StatusRunning,
+		startedAt:   now,
+		nextSliceAt: now,
+		children:    make(map[int64]*child),
+	}
+	if p.Duration > 0 {
+		a.deadline = now.Add(p.Duration)
+	}
+	s.parents[a.id] = a
+	return a.id, nil
+}
+
+// Cancel 撤销母单：不再下新子单，并撤掉所有未成交子单
+// 撤单期间到达的成交仍会计入统计
+func (s *Service) Cancel(ctx context.Context, userID, id int64) error {
+	s.mu.Lock()
+	a, ok := s.parents[id]
+	if !ok || a.params.UserID != userID {
+		s.mu.Unlock()
+		return ErrAlgoNotFound
+	}
+	if a.status.IsTerminal() {
+		s.mu.Unlock()
+		return ErrAlgoFinished
+	}
+	a.finish(StatusCanceled, time.Now())
+	cancels := s.collectCancelsLocked(a)
+	s.mu.Unlock()
+
+	s.sendCancels(ctx, cancels)
+	return nil
+}
+
+// Get 查询母单执行统计 (结束超过 Retention 的母单已清理，返回 ErrAlgoNotFound)
+func (s *Service) Get(userID, id int64) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.parents[id]
+	if !ok || a.params.UserID != userID {
+		return Stats{}, ErrAlgoNotFound
+	}
+	return a.stats(), nil
+}
+
+// List 用户全部母单，按 ID 倒序
+func (s *Service) List(userID int64) []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Stats
+	for _, a := range s.parents {
+		if a.params.UserID == userID {
+			out = append(out, a.stats())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out
+}
+
+// =============================================================================
+// 调度
+// =============================================================================
+
+// tick 一次调度：对每个运行中的母单计算要下的子单和要撤的子单，清理过了保留期的已结束母单
+// 锁内只改内存状态，Router 调用放到锁外
+func (s *Service) tick(ctx context.Context, now time.Time) {
+	var submits, cancels []ChildOrder
+
+	s.mu.Lock()
+	for _, a := range s.parents {
+		if a.status.IsTerminal() {
+			if !now.Before(a.finishedAt.Add(s.cfg.Retention)) {
+				s.removeParentLocked(a)
+				continue
+			}
+			// 成交回调里失败的母单，其余子单在这里撤
+			cancels = append(cancels, s.collectCancelsLocked(a)...)
+			continue
+		}
+		// 到期：撤掉剩余子单
+		if !a.deadline.IsZero() && !now.Before(a.deadline) {
+			a.finish(StatusExpired, now)
+			cancels = append(cancels, s.collectCancelsLocked(a)...)
+			continue
+		}
+		if qty := a.nextSliceQty(now); qty > 0 {
+			submits = append(submits, s.newChildLocked(a, qty))
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range submits {
+		if err := s.router.Submit(ctx, c); err != nil {
+			log.Printf("[Algo] Parent %d submit child %d failed: %v", c.ParentID, c.ID, err)
+			cancels = append(cancels, s.failChild(c, err, now)...)
+		}
+	}
+	s.sendCancels(ctx, cancels)
+}
+
+// nextSliceQty 本次应下的子单数量，0 表示本次不下单
+func (a *parent) nextSliceQty(now time.Time) int64 {
+	remaining := a.remaining()
+	if remaining <= 0 {
+		return 0
+	}
+
+	switch a.params.Strategy {
+	case StrategyTWAP:
+		// 【设计】未成交的切片继续挂在限价上，不撤单重下：
+		// 剩余量按剩余片数均分，最后一片补齐，总提交量恰好等于母单
+		if a.slicesSent >= a.params.Slices || now.Before(a.nextSliceAt) {
+			return 0
+		}
+		slicesLeft := int64(a.params.Slices - a.slicesSent)
+		a.slicesSent++
+		a.nextSliceAt = a.nextSliceAt.Add(a.params.Duration / time.Duration(a.params.Slices))
+		return remaining / slicesLeft
+
+	case StrategyPOV:
+		// 目标累计量 = 市场成交量 × 参与率，差额不足最小子单时等量攒够
+		target := a.marketVolume * a.params.ParticipationRate / RatePrecision
+		qty := min(target-a.filled-a.openQty, remaining)
+		if qty <= 0 || (qty < a.params.MinSliceQty && qty < remaining) {
+			return 0
+		}
+		return qty
+
+	case StrategyIceberg:
+		// 上一笔完全成交 (或撤单) 后才露出下一笔
+		if a.openQty > 0 {
+			return 0
+		}
+		return min(a.params.DisplayQty, remaining)
+	}
+	return 0
+}
+
+// newChildLocked 生成子单并先登记再提交：成交事件可能比 Submit 返回还早
+func (s *Service) newChildLocked(a *parent, qty int64) ChildOrder {
+	c := ChildOrder{
+		ID:       s.cfg.NewOrderID(),
+		ParentID: a.id,
+		UserID:   a.params.UserID,
+		Symbol:   a.params.Symbol,
+		Side:     a.params.Side,
+		Price:    a.params.LimitPrice,
+		Qty:      qty,
+	}
+	a.children[c.ID] = &child{order: c}
+	a.openQty += qty
+	a.childOrders++
+	s.byChild[c.ID] = a
+	return c
+}
+
+// failChild 子单提交失败：回滚登记，母单失败并撤掉其余子单
+func (s *Service) failChild(c ChildOrder, err error, now time.Time) []ChildOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.byChild[c.ID]
+	if a == nil {
+		return nil
+	}
+	s.removeChildLocked(a, c.ID)
+	a.childOrders--
+	if a.status.IsTerminal() {
+		return nil
+	}
+	a.err = err.Error()
+	a.finish(StatusFailed, now)
+	return s.collectCancelsLocked(a)
+}
+
+// collectCancelsLocked 收集母单下所有未成交且还没发过撤单的子单
+func (s *Service) collectCancelsLocked(a *parent) []ChildOrder {
+	var out []ChildOrder
+	for _, c := range a.children {
+		if !c.cancelSent {
+			c.cancelSent = true
+			out = append(out, c.order)
+		}
+	}
+	return out
+}
+
+func (s *Service) sendCancels(ctx context.Context, cancels []ChildOrder) {
+	for _, c := range cancels {
+		if err := s.router.Cancel(ctx, c); err != nil {
+			log.Printf("[Algo] Parent %d cancel child %d failed: %v", c.ParentID, c.ID, err)
+		}
+	}
+}
+
+// removeChildLocked 子单结束：未成交部分不再占用 openQty
+func (s *Service) removeChildLocked(a *parent, childID int64) {
+	c, ok := a.children[childID]
+	if !ok {
+		return
+	}
+	a.openQty -= c.open()
+	delete(a.children, childID)
+	delete(s.byChild, childID)
+}
+
+// removeParentLocked 清理已结束的母单，连同还没等到回报的子单索引
+// (遍历 s.parents 时删除当前元素是安全的)
+func (s *Service) removeParentLocked(a *parent) {
+	for id := range a.children {
+		delete(s.byChild, id)
+	}
+	delete(s.parents, a.id)
+}
+
+// =============================================================================
+// 撮合事件
+// =============================================================================
+
+// HandleEvent 处理撮合事件，只更新内存状态，不在这里下单
+// 【注意】事件里的 Order/Trade 指针只在回调期间有效，这里只读取值
+func (s *Service) HandleEvent(event mtrade.Event) {
+	switch event.Type {
+	case mtrade.EventTrade:
+		if event.Trade != nil {
+			s.onTrade(event.Trade)
+		}
+	case mtrade.EventOrderCanceled, mtrade.EventOrderRejected:
+		if event.Order != nil {
+			s.onChildDone(event.Order.ID, event.Type == mtrade.EventOrderRejected)
+		}
+	}
+}
+
+func (s *Service) onTrade(t *mtrade.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taker := s.byChild[t.TakerID]
+	maker := s.byChild[t.MakerID]
+
+	// POV：统计同交易对上别人的成交量
+	for _, a := range s.parents {
+		if a.params.Strategy == StrategyPOV && !a.status.IsTerminal() &&
+			a.params.Symbol == t.Symbol && a != taker && a != maker {
+			a.marketVolume += t.Qty
+		}
+	}
+
+	if taker != nil {
+		s.applyFillLocked(taker, t.TakerID, t.Price, t.Qty)
+	}
+	if maker != nil {
+		s.applyFillLocked(maker, t.MakerID, t.Price, t.Qty)
+	}
+}
+
+func (s *Service) applyFillLocked(a *parent, childID, price, qty int64) {
+	c := a.children[childID]
+	c.filled += qty
+	a.filled += qty
+	a.openQty -= qty
+
+	hi, lo := bits.Mul64(uint64(price), uint64(qty))
+	var carry uint64
+	a.notionalL, carry = bits.Add64(a.notionalL, lo, 0)
+	a.notionalH += hi + carry
+
+	if c.open() <= 0 {
+		delete(a.children, childID)
+		delete(s.byChild, childID)
+	}
+	if a.filled >= a.params.TotalQty && a.status == StatusRunning {
+		a.finish(StatusCompleted, time.Now())
+	}
+}
+
+// onChildDone 子单撤销 / 被拒：剩余量回到母单，后续 tick 重新分配
+// 被拒说明参数或资金有问题，重下也会被拒，母单直接失败 (其余子单由下一次 tick 撤)
+func (s *Service) onChildDone(childID int64, rejected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.byChild[childID]
+	if a == nil {
+		return
+	}
+	s.removeChildLocked(a, childID)
+	a.canceledChildren++
+	if rejected && a.status == StatusRunning {
+		a.err = "child order rejected"
+		a.finish(StatusFailed, time.Now())
+	}
+}
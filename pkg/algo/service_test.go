@@ -0,0 +1,284 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+// fakeRouter 记录提交 / 撤销的子单
+type fakeRouter struct {
+	mu        sync.Mutex
+	submitted []ChildOrder
+	canceled  []int64
+	err       error
+}
+
+func (r *fakeRouter) Submit(ctx context.Context, c ChildOrder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.submitted = append(r.submitted, c)
+	return nil
+}
+
+func (r *fakeRouter) Cancel(ctx context.Context, c ChildOrder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.canceled = append(r.canceled, c.ID)
+	return nil
+}
+
+func (r *fakeRouter) last() ChildOrder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.submitted[len(r.submitted)-1]
+}
+
+func newTestService(router Router) *Service {
+	var seq int64
+	return NewService(router, Config{NewOrderID: func() int64 { seq++; return seq }})
+}
+
+// fill 模拟子单作为 Taker 成交
+func fill(s *Service, c ChildOrder, price, qty int64) {
+	s.HandleEvent(mtrade.Event{Type: mtrade.EventTrade, Trade: &mtrade.Trade{
+		Symbol: c.Symbol, Price: price, Qty: qty, TakerID: c.ID, MakerID: -c.ID,
+	}})
+}
+
+func marketTrade(s *Service, symbol string, qty int64) {
+	s.HandleEvent(mtrade.Event{Type: mtrade.EventTrade, Trade: &mtrade.Trade{
+		Symbol: symbol, Price: 100, Qty: qty, TakerID: -1, MakerID: -2,
+	}})
+}
+
+func TestTWAP_SlicesAndExpiry(t *testing.T) {
+	router := &fakeRouter{}
+	s := newTestService(router)
+	ctx := context.Background()
+
+	id, err := s.Submit(ctx, Params{
+		UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideBuy, TotalQty: 100, LimitPrice: 500,
+		Strategy: StrategyTWAP, Duration: 4 * time.Minute, Slices: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+
+	s.tick(ctx, start)
+	s.tick(ctx, start.Add(30*time.Second)) // 还没到第二片
+	if len(router.submitted) != 1 || router.submitted[0].Qty != 33 {
+		t.Fatalf("expected first slice of 33, got %+v", router.submitted)
+	}
+	fill(s, router.last(), 500, 33)
+
+	s.tick(ctx, start.Add(80*time.Second))
+	fill(s, router.last(), 600, 10) // 第二片部分成交
+	s.tick(ctx, start.Add(160*time.Second))
+	s.tick(ctx, start.Add(200*time.Second)) // 片数已用完
+
+	var total int64
+	for _, c := range router.submitted {
+		total += c.Qty
+		if c.Price != 500 || c.ParentID != id {
+			t.Errorf("unexpected child %+v", c)
+		}
+	}
+	if len(router.submitted) != 3 || total != 100 {
+		t.Fatalf("expected 3 slices summing to 100, got %+v", router.submitted)
+	}
+
+	// 到期：撤掉两笔未成交子单
+	s.tick(ctx, start.Add(4*time.Minute))
+	if len(router.canceled) != 2 {
+		t.Fatalf("expected 2 cancels, got %v", router.canceled)
+	}
+	for _, cid := range router.canceled {
+		s.HandleEvent(mtrade.Event{Type: mtrade.EventOrderCanceled, Order: &mtrade.Order{ID: cid}})
+	}
+
+	st, err := s.Get(1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// VWAP = (33×500 + 10×600) / 43 = 523
+	if st.Status != StatusExpired || st.FilledQty != 43 || st.OpenQty != 0 || st.AvgPrice != 523 ||
+		st.CanceledChildren != 2 || st.FillRate != 4300 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestPOV_FollowsMarketVolume(t *testing.T) {
+	router := &fakeRouter{}
+	s := newTestService(router)
+	ctx := context.Background()
+
+	id, _ := s.Submit(ctx, Params{
+		UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideSell, TotalQty: 50, LimitPrice: 100,
+		Strategy: StrategyPOV, ParticipationRate: 2000, MinSliceQty: 5,
+	})
+	now := time.Now()
+
+	s.tick(ctx, now) // 市场没成交，不下单
+	marketTrade(s, "BTC_USDT", 20)
+	marketTrade(s, "ETH_USDT", 1000) // 其他交易对不算
+	s.tick(ctx, now)                 // 目标 4 < 最小子单 5
+	if len(router.submitted) != 0 {
+		t.Fatalf("expected no child yet, got %+v", router.submitted)
+	}
+
+	marketTrade(s, "BTC_USDT", 30)
+	s.tick(ctx, now)
+	if len(router.submitted) != 1 || router.last().Qty != 10 {
+		t.Fatalf("expected child of 10, got %+v", router.submitted)
+	}
+	fill(s, router.last(), 100, 10) // 自己的成交不计入市场量
+
+	marketTrade(s, "BTC_USDT", 500)
+	s.tick(ctx, now)
+	if got := router.last().Qty; got != 40 {
+		t.Fatalf("expected child capped at remaining 40, got %d", got)
+	}
+	fill(s, router.last(), 100, 40)
+
+	st, _ := s.Get(1, id)
+	if st.Status != StatusCompleted || st.FilledQty != 50 || st.MarketVolume != 550 || st.FinishedAt == 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if _, err := s.Get(2, id); !errors.Is(err, ErrAlgoNotFound) {
+		t.Errorf("other user should not see algo, got %v", err)
+	}
+}
+
+func TestIceberg_CancelParent(t *testing.T) {
+	router := &fakeRouter{}
+	s := newTestService(router)
+	ctx := context.Background()
+
+	id, _ := s.Submit(ctx, Params{
+		UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideBuy, TotalQty: 25, LimitPrice: 100,
+		Strategy: StrategyIceberg, DisplayQty: 10,
+	})
+	now := time.Now()
+
+	s.tick(ctx, now)
+	s.tick(ctx, now) // 上一笔未成交，不露下一笔
+	if len(router.submitted) != 1 {
+		t.Fatalf("expected one visible child, got %+v", router.submitted)
+	}
+	fill(s, router.last(), 100, 10)
+	s.tick(ctx, now)
+	fill(s, router.last(), 100, 4)
+
+	if err := s.Cancel(ctx, 1, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Cancel(ctx, 1, id); !errors.Is(err, ErrAlgoFinished) {
+		t.Errorf("expected ErrAlgoFinished, got %v", err)
+	}
+	if len(router.canceled) != 1 || router.canceled[0] != router.last().ID {
+		t.Fatalf("expected open child canceled, got %v", router.canceled)
+	}
+
+	// 撤单回报前的成交仍然计入
+	fill(s, router.last(), 100, 2)
+	s.HandleEvent(mtrade.Event{Type: mtrade.EventOrderCanceled, Order: &mtrade.Order{ID: router.last().ID}})
+	s.tick(ctx, now)
+
+	st, _ := s.Get(1, id)
+	if st.Status != StatusCanceled || st.FilledQty != 16 || st.OpenQty != 0 || st.ChildOrders != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestService_ChildFailure(t *testing.T) {
+	router := &fakeRouter{err: errors.New("insufficient balance")}
+	s := newTestService(router)
+	ctx := context.Background()
+
+	if _, err := s.Submit(ctx, Params{UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideBuy,
+		TotalQty: 10, LimitPrice: 100, Strategy: StrategyTWAP, Slices: 2}); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("TWAP without duration should be rejected, got %v", err)
+	}
+
+	id, _ := s.Submit(ctx, Params{UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideBuy,
+		TotalQty: 10, LimitPrice: 100, Strategy: StrategyIceberg, DisplayQty: 5})
+	s.tick(ctx, time.Now())
+
+	st, _ := s.Get(1, id)
+	if st.Status != StatusFailed || st.Error != "insufficient balance" || st.ChildOrders != 0 || st.OpenQty != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	// 撮合拒单同样让母单失败，其余子单在下一次 tick 撤掉
+	router.err = nil
+	id, _ = s.Submit(ctx, Params{UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideBuy,
+		TotalQty: 10, LimitPrice: 100, Strategy: StrategyTWAP, Duration: time.Minute, Slices: 2})
+	now := time.Now()
+	s.tick(ctx, now)
+	first := router.last()
+	s.tick(ctx, now.Add(30*time.Second))
+	s.HandleEvent(mtrade.Event{Type: mtrade.EventOrderRejected, Order: &mtrade.Order{ID: router.last().ID}})
+	s.tick(ctx, now.Add(31*time.Second))
+
+	if len(router.canceled) != 1 || router.canceled[0] != first.ID {
+		t.Errorf("expected remaining child canceled, got %v", router.canceled)
+	}
+	if st, _ := s.Get(1, id); st.Status != StatusFailed {
+		t.Errorf("expected failed, got %+v", st)
+	}
+	if n := len(s.List(1)); n != 2 {
+		t.Errorf("expected 2 algos listed, got %d", n)
+	}
+}
+
+func TestService_RetentionPrunesFinished(t *testing.T) {
+	router := &fakeRouter{}
+	var seq int64
+	s := NewService(router, Config{NewOrderID: func() int64 { seq++; return seq }, Retention: time.Minute})
+	ctx := context.Background()
+
+	iceberg := Params{UserID: 1, Symbol: "BTC_USDT", Side: mtrade.SideBuy, TotalQty: 10, LimitPrice: 100,
+		Strategy: StrategyIceberg, DisplayQty: 10}
+	done, _ := s.Submit(ctx, iceberg)
+	s.tick(ctx, time.Now())
+	fill(s, router.last(), 100, 10)
+
+	// 撤单回报一直没来的母单：保留期过后连同子单索引一起清理
+	lost, _ := s.Submit(ctx, iceberg)
+	s.tick(ctx, time.Now())
+	if err := s.Cancel(ctx, 1, lost); err != nil {
+		t.Fatal(err)
+	}
+	running, _ := s.Submit(ctx, iceberg)
+
+	now := time.Now()
+	s.tick(ctx, now)
+	if st, err := s.Get(1, done); err != nil || st.Status != StatusCompleted {
+		t.Fatalf("finished algo must stay queryable within retention: %+v %v", st, err)
+	}
+	if len(s.parents) != 3 {
+		t.Fatalf("parents = %d before retention", len(s.parents))
+	}
+
+	s.tick(ctx, now.Add(time.Minute))
+	if len(s.parents) != 1 || len(s.byChild) != 1 {
+		t.Fatalf("parents = %d, children = %d after retention", len(s.parents), len(s.byChild))
+	}
+	for _, id := range []int64{done, lost} {
+		if _, err := s.Get(1, id); !errors.Is(err, ErrAlgoNotFound) {
+			t.Errorf("algo %d: %v", id, err)
+		}
+	}
+	if st, err := s.Get(1, running); err != nil || st.Status != StatusRunning {
+		t.Errorf("running algo: %+v %v", st, err)
+	}
+}
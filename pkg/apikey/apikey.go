@@ -0,0 +1,161 @@
+// Package apikey API Key 管理与 HMAC 请求签名 (无会话鉴权)
+//
+// 给 REST / WS 网关用：程序化交易客户端不走登录会话，每个请求自带签名。
+//
+//	客户端                                         网关
+//	  │ X-API-KEY / X-API-TIMESTAMP / X-API-NONCE     │
+//	  │ X-API-SIGNATURE = HMAC(signingKey, payload)   │
+//	  ├──────────────────────────────────────────────→│ 1. 查 Key (未吊销、未过期)
+//	  │                                               │ 2. 时间戳在窗口内
+//	  │                                               │ 3. nonce 窗口内没用过 (防重放)
+//	  │                                               │ 4. 重算签名，常量时间比较
+//	  │                                               │ 5. 权限包含接口要求的权限
+//
+// payload = timestamp \n nonce \n METHOD \n path?query \n body
+//
+// 【面试】数据库里只存 secret 的哈希，怎么验签？
+// 签名密钥本身就是哈希：signingKey = SHA256(secret)，客户端用 Sign 计算，服务端存 signingKey。
+// secret 只在创建时返回一次，服务端不落盘、不进日志；
+// 库被拖走时攻击者仍可用 signingKey 伪造请求，所以 secret_hash 列还要配合库级加密 (KMS)，
+// 但至少拿不到用户原始 secret (用户可能在别处复用)。
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrKeyNotFound       = errors.New("apikey: key not found")
+	ErrKeyRevoked        = errors.New("apikey: key revoked")
+	ErrKeyExpired        = errors.New("apikey: key expired")
+	ErrMissingHeaders    = errors.New("apikey: missing auth headers")
+	ErrTimestampWindow   = errors.New("apikey: timestamp outside window")
+	ErrNonceReused       = errors.New("apikey: nonce already used")
+	ErrBadSignature      = errors.New("apikey: signature mismatch")
+	ErrPermissionDenied  = errors.New("apikey: permission denied")
+	ErrInvalidPermission = errors.New("apikey: invalid permission")
+	ErrTooManyKeys       = errors.New("apikey: too many keys for user")
+)
+
+// =============================================================================
+// 权限
+// =============================================================================
+
+// Permission 权限位
+type Permission uint8
+
+const (
+	PermRead     Permission = 1 << iota // 查询余额、订单
+	PermTrade                           // 下单、撤单
+	PermWithdraw                        // 提现 (默认不开，需单独申请)
+)
+
+var permNames = []struct {
+	perm Permission
+	name string
+}{
+	{PermRead, "read"},
+	{PermTrade, "trade"},
+	{PermWithdraw, "withdraw"},
+}
+
+// Has 是否包含全部 required 权限
+func (p Permission) Has(required Permission) bool {
+	return p&required == required
+}
+
+func (p Permission) String() string {
+	var names []string
+	for _, pn := range permNames {
+		if p.Has(pn.perm) {
+			names = append(names, pn.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParsePermissions 解析 "read,trade" 形式的权限
+func ParsePermissions(s string) (Permission, error) {
+	var p Permission
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		found := false
+		for _, pn := range permNames {
+			if pn.name == part {
+				p |= pn.perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidPermission, part)
+		}
+	}
+	if p == 0 {
+		return 0, fmt.Errorf("%w: empty", ErrInvalidPermission)
+	}
+	return p, nil
+}
+
+// =============================================================================
+// Key
+// =============================================================================
+
+// Key 一个 API Key (表结构见 apikey.sql)
+type Key struct {
+	ID          string     `gorm:"column:id;primaryKey"` // 公开的 key (请求头 X-API-KEY)
+	UserID      int64      `gorm:"column:user_id;index"` // 所属用户
+	Label       string     `gorm:"column:label"`         // 用户备注
+	Permissions Permission `gorm:"column:permissions"`   // 权限位
+	SecretHash  []byte     `gorm:"column:secret_hash"`   // signingKey = SHA256(secret)
+	CreatedAt   int64      `gorm:"column:created_at"`    // 毫秒
+	ExpiresAt   int64      `gorm:"column:expires_at"`    // 毫秒，0 = 不过期
+	RevokedAt   int64      `gorm:"column:revoked_at"`    // 毫秒，0 = 有效
+}
+
+// TableName GORM 表名
+func (Key) TableName() string { return "api_key" }
+
+// Active 在 now 时刻是否可用
+func (k *Key) Active(now time.Time) error {
+	if k.RevokedAt != 0 {
+		return ErrKeyRevoked
+	}
+	if k.ExpiresAt != 0 && now.UnixMilli() >= k.ExpiresAt {
+		return ErrKeyExpired
+	}
+	return nil
+}
+
+// SigningKey 由 secret 派生签名密钥 (客户端和服务端一致)
+func SigningKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// generate 生成 key ID 和 secret
+func generate() (id, secret string, err error) {
+	idBytes := make([]byte, 16)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(idBytes), base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
@@ -0,0 +1,14 @@
+-- API Key 表
+-- secret 不落盘，secret_hash 为签名密钥 SHA256(secret)，生产环境该列需库级加密
+
+CREATE TABLE IF NOT EXISTS `api_key` (
+    `id` CHAR(32) NOT NULL PRIMARY KEY COMMENT '公开 key (X-API-KEY)',
+    `user_id` BIGINT NOT NULL,
+    `label` VARCHAR(64) NOT NULL DEFAULT '',
+    `permissions` TINYINT UNSIGNED NOT NULL COMMENT '位掩码: 1=read,2=trade,4=withdraw',
+    `secret_hash` BINARY(32) NOT NULL,
+    `created_at` BIGINT NOT NULL COMMENT '毫秒',
+    `expires_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒，0=不过期',
+    `revoked_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒，0=有效',
+    KEY `idx_user` (`user_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = 'API Key';
@@ -0,0 +1,106 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(keyID, secret string, ts int64, nonce, method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set(HeaderKey, keyID)
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, Sign(secret, ts, nonce, method, r.URL.RequestURI(), []byte(body)))
+	return r
+}
+
+func TestManager_CreateRevoke(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryStore(), ManagerConfig{MaxKeysPerUser: 1})
+
+	key, secret, err := m.Create(ctx, 1001, PermRead|PermTrade, "bot", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == "" || string(key.SecretHash) == secret {
+		t.Fatal("secret must be returned once and stored hashed")
+	}
+	if _, _, err := m.Create(ctx, 1001, PermRead, "second", 0); !errors.Is(err, ErrTooManyKeys) {
+		t.Fatalf("expected ErrTooManyKeys, got %v", err)
+	}
+	if err := m.Revoke(ctx, 2002, key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("other user must not revoke, got %v", err)
+	}
+	if err := m.Revoke(ctx, 1001, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	// 吊销后名额释放
+	if _, _, err := m.Create(ctx, 1001, PermRead, "second", 0); err != nil {
+		t.Fatalf("create after revoke: %v", err)
+	}
+
+	p, err := ParsePermissions("read, withdraw")
+	if err != nil || p != PermRead|PermWithdraw || p.String() != "read,withdraw" {
+		t.Fatalf("parse permissions: %v %v", p, err)
+	}
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.UnixMilli(1_700_000_000_000)
+	m := NewManager(store, ManagerConfig{Now: func() time.Time { return now }})
+	v := NewVerifier(store, VerifierConfig{Window: 5 * time.Second, Now: func() time.Time { return now }})
+
+	key, secret, _ := m.Create(ctx, 1001, PermRead|PermTrade, "bot", 0)
+	readOnly, roSecret, _ := m.Create(ctx, 1001, PermRead, "ro", 0)
+
+	var gotBody string
+	h := v.Middleware(PermTrade)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, _ := KeyFromContext(r.Context())
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Write([]byte(strconv.FormatInt(k.UserID, 10)))
+	}))
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	body := `{"symbol":"BTC_USDT","qty":1}`
+	ts := now.UnixMilli()
+	if code := serve(signedRequest(key.ID, secret, ts, "n1", "POST", "/order?x=1", body)); code != http.StatusOK || gotBody != body {
+		t.Fatalf("valid request: %d body=%q", code, gotBody)
+	}
+	// 重放
+	if code := serve(signedRequest(key.ID, secret, ts, "n1", "POST", "/order?x=1", body)); code != http.StatusUnauthorized {
+		t.Fatalf("replay should be rejected, got %d", code)
+	}
+	// 篡改请求体
+	r := signedRequest(key.ID, secret, ts, "n2", "POST", "/order?x=1", body)
+	r.Body = io.NopCloser(strings.NewReader(`{"symbol":"BTC_USDT","qty":100}`))
+	if code := serve(r); code != http.StatusUnauthorized {
+		t.Fatalf("tampered body should be rejected, got %d", code)
+	}
+	// 时间戳超出窗口
+	if code := serve(signedRequest(key.ID, secret, ts-6000, "n3", "POST", "/order", body)); code != http.StatusUnauthorized {
+		t.Fatalf("stale timestamp should be rejected, got %d", code)
+	}
+	// 权限不足
+	if code := serve(signedRequest(readOnly.ID, roSecret, ts, "n4", "POST", "/order", body)); code != http.StatusForbidden {
+		t.Fatalf("read-only key should get 403, got %d", code)
+	}
+	// 吊销
+	m.Revoke(ctx, 1001, key.ID)
+	if _, err := v.Verify(signedRequest(key.ID, secret, ts, "n5", "POST", "/order", body), PermTrade); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// =============================================================================
+// Manager - Key 生命周期
+// =============================================================================
+
+// ManagerConfig 配置
+type ManagerConfig struct {
+	MaxKeysPerUser int              // 每个用户最多有效 Key 数，默认 20
+	Now            func() time.Time // 时钟 (测试注入)，默认 time.Now
+}
+
+// Manager 创建、列出、吊销 Key
+type Manager struct {
+	store  Store
+	config ManagerConfig
+}
+
+// NewManager 创建 Manager
+func NewManager(store Store, cfg ManagerConfig) *Manager {
+	if cfg.MaxKeysPerUser <= 0 {
+		cfg.MaxKeysPerUser = 20
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Manager{store: store, config: cfg}
+}
+
+// Create 创建 Key，返回的 secret 只有这一次，之后无法再查到
+//
+// ttl <= 0 表示不过期
+func (m *Manager) Create(ctx context.Context, userID int64, perms Permission, label string, ttl time.Duration) (*Key, string, error) {
+	if perms == 0 || perms&^(PermRead|PermTrade|PermWithdraw) != 0 {
+		return nil, "", fmt.Errorf("%w: %d", ErrInvalidPermission, perms)
+	}
+
+	existing, err := m.store.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	now := m.config.Now()
+	active := 0
+	for i := range existing {
+		if existing[i].Active(now) == nil {
+			active++
+		}
+	}
+	if active >= m.config.MaxKeysPerUser {
+		return nil, "", ErrTooManyKeys
+	}
+
+	id, secret, err := generate()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &Key{
+		ID:          id,
+		UserID:      userID,
+		Label:       label,
+		Permissions: perms,
+		SecretHash:  SigningKey(secret),
+		CreatedAt:   now.UnixMilli(),
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl).UnixMilli()
+	}
+	if err := m.store.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// List 用户全部 Key
+func (m *Manager) List(ctx context.Context, userID int64) ([]Key, error) {
+	return m.store.ListByUser(ctx, userID)
+}
+
+// Revoke 吊销用户自己的 Key (不能吊销别人的)
+func (m *Manager) Revoke(ctx context.Context, userID int64, id string) error {
+	key, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.UserID != userID {
+		// 不暴露 key 是否存在
+		return ErrKeyNotFound
+	}
+	return m.store.Revoke(ctx, id, m.config.Now().UnixMilli())
+}
@@ -0,0 +1,238 @@
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 请求头
+const (
+	HeaderKey       = "X-API-KEY"
+	HeaderTimestamp = "X-API-TIMESTAMP" // 毫秒
+	HeaderNonce     = "X-API-NONCE"
+	HeaderSignature = "X-API-SIGNATURE" // hex
+)
+
+// maxNonceLen nonce 最大长度，防止用超长 nonce 撑爆去重表
+const maxNonceLen = 64
+
+// =============================================================================
+// 签名
+// =============================================================================
+
+// Sign 计算签名 (客户端 SDK 用同一个函数)
+//
+// requestURI 是 path + "?" + query (与 http.Request.URL.RequestURI 一致)
+func Sign(secret string, timestamp int64, nonce, method, requestURI string, body []byte) string {
+	return hex.EncodeToString(sign(SigningKey(secret), timestamp, nonce, method, requestURI, body))
+}
+
+func sign(signingKey []byte, timestamp int64, nonce, method, requestURI string, body []byte) []byte {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(nonce))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(method))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(requestURI))
+	mac.Write([]byte{'\n'})
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// =============================================================================
+// nonce 去重
+// =============================================================================
+
+const nonceShards = 16
+
+// NonceCache 时间窗口内的 nonce 去重表
+//
+// 时间戳超出窗口的请求已经被拒绝，所以 nonce 只需保留 2 倍窗口 (时钟两侧)，
+// 内存上限 ≈ 窗口内请求数
+type NonceCache struct {
+	ttl    time.Duration
+	shards [nonceShards]nonceShard
+}
+
+type nonceShard struct {
+	mu        sync.Mutex
+	seen      map[string]int64 // key -> 过期时间 (纳秒)
+	lastSweep int64
+}
+
+// NewNonceCache 创建去重表
+func NewNonceCache(window time.Duration) *NonceCache {
+	c := &NonceCache{ttl: 2 * window}
+	for i := range c.shards {
+		c.shards[i].seen = make(map[string]int64)
+	}
+	return c
+}
+
+// Use 记录 nonce，窗口内已用过返回 false
+func (c *NonceCache) Use(keyID, nonce string, now time.Time) bool {
+	k := keyID + "\x00" + nonce
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	s := &c.shards[h.Sum32()%nonceShards]
+
+	ts := now.UnixNano()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts-s.lastSweep > int64(c.ttl) {
+		for key, exp := range s.seen {
+			if exp <= ts {
+				delete(s.seen, key)
+			}
+		}
+		s.lastSweep = ts
+	}
+	if exp, ok := s.seen[k]; ok && exp > ts {
+		return false
+	}
+	s.seen[k] = ts + int64(c.ttl)
+	return true
+}
+
+// =============================================================================
+// Verifier
+// =============================================================================
+
+// VerifierConfig 验签配置
+type VerifierConfig struct {
+	Window       time.Duration    // 时间戳允许偏差，默认 10s
+	MaxBodyBytes int64            // 请求体上限，默认 1MB
+	Now          func() time.Time // 时钟 (测试注入)，默认 time.Now
+}
+
+// Verifier 请求验签
+type Verifier struct {
+	store  Store
+	nonces *NonceCache
+	config VerifierConfig
+}
+
+// NewVerifier 创建验签器
+func NewVerifier(store Store, cfg VerifierConfig) *Verifier {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Verifier{store: store, nonces: NewNonceCache(cfg.Window), config: cfg}
+}
+
+// Verify 校验请求，成功返回 Key；会读取并还原 r.Body
+func (v *Verifier) Verify(r *http.Request, required Permission) (*Key, error) {
+	keyID := r.Header.Get(HeaderKey)
+	tsStr := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	sigHex := r.Header.Get(HeaderSignature)
+	if keyID == "" || tsStr == "" || nonce == "" || sigHex == "" || len(nonce) > maxNonceLen {
+		return nil, ErrMissingHeaders
+	}
+
+	now := v.config.Now()
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return nil, ErrTimestampWindow
+	}
+	if d := now.Sub(time.UnixMilli(ts)); d > v.config.Window || d < -v.config.Window {
+		return nil, ErrTimestampWindow
+	}
+
+	key, err := v.store.Get(r.Context(), keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, v.config.MaxBodyBytes+1))
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(body)) > v.config.MaxBodyBytes {
+			return nil, ErrBadSignature
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+	expected := sign(key.SecretHash, ts, nonce, r.Method, r.URL.RequestURI(), body)
+	if !hmac.Equal(sig, expected) {
+		return nil, ErrBadSignature
+	}
+	// 吊销/过期在验签之后判断，不让未持有 secret 的人探测 key 状态
+	if err := key.Active(now); err != nil {
+		return nil, err
+	}
+
+	// 签名通过后再登记 nonce：否则任何人都能用伪造请求提前占用别人的 nonce
+	if !v.nonces.Use(keyID, nonce, now) {
+		return nil, ErrNonceReused
+	}
+
+	if !key.Permissions.Has(required) {
+		return nil, ErrPermissionDenied
+	}
+	return key, nil
+}
+
+// =============================================================================
+// HTTP 中间件
+// =============================================================================
+
+type ctxKey struct{}
+
+// KeyFromContext 取出中间件注入的 Key
+func KeyFromContext(ctx context.Context) (*Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(*Key)
+	return k, ok
+}
+
+// Middleware 要求请求带有效签名且 Key 有 required 权限
+//
+//	mux.Handle("/order", verifier.Middleware(apikey.PermTrade)(orderHandler))
+//
+// 鉴权失败返回 401，权限不足返回 403；错误信息不区分"key 不存在"和"签名错"
+func (v *Verifier) Middleware(required Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := v.Verify(r, required)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, key)))
+			case errors.Is(err, ErrPermissionDenied):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, ErrKeyNotFound), errors.Is(err, ErrBadSignature):
+				http.Error(w, "apikey: invalid key or signature", http.StatusUnauthorized)
+			case errors.Is(err, ErrKeyRevoked), errors.Is(err, ErrKeyExpired),
+				errors.Is(err, ErrMissingHeaders), errors.Is(err, ErrTimestampWindow), errors.Is(err, ErrNonceReused):
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			default:
+				http.Error(w, "apikey: verification unavailable", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package apikey
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 存储接口
+// =============================================================================
+
+// Store API Key 存储
+type Store interface {
+	Create(ctx context.Context, key *Key) error
+	// Get 按 ID 查询，不存在返回 ErrKeyNotFound
+	Get(ctx context.Context, id string) (*Key, error)
+	// ListByUser 用户全部 Key (含已吊销)，按创建时间升序
+	ListByUser(ctx context.Context, userID int64) ([]Key, error)
+	// Revoke 吊销 (幂等)
+	Revoke(ctx context.Context, id string, at int64) error
+}
+
+// =============================================================================
+// MemoryStore
+// =============================================================================
+
+// MemoryStore 内存存储 (测试、单机部署)
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]*Key)}
+}
+
+// Create 实现 Store
+func (s *MemoryStore) Create(ctx context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := *key
+	s.keys[key.ID] = &k
+	return nil
+}
+
+// Get 实现 Store
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	out := *k
+	return &out, nil
+}
+
+// ListByUser 实现 Store
+func (s *MemoryStore) ListByUser(ctx context.Context, userID int64) ([]Key, error) {
+	s.mu.RLock()
+	var out []Key
+	for _, k := range s.keys {
+		if k.UserID == userID {
+			out = append(out, *k)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	return out, nil
+}
+
+// Revoke 实现 Store
+func (s *MemoryStore) Revoke(ctx context.Context, id string, at int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if k.RevokedAt == 0 {
+		k.RevokedAt = at
+	}
+	return nil
+}
+
+// =============================================================================
+// GormStore - MySQL 实现
+// =============================================================================
+
+// GormStore MySQL 存储 (表结构见 apikey.sql)
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建 MySQL 存储
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Create 实现 Store
+func (s *GormStore) Create(ctx context.Context, key *Key) error {
+	return s.db.WithContext(ctx).Create(key).Error
+}
+
+// Get 实现 Store
+func (s *GormStore) Get(ctx context.Context, id string) (*Key, error) {
+	var k Key
+	err := s.db.WithContext(ctx).Where("id = ?", id).Take(&k).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ListByUser 实现 Store
+func (s *GormStore) ListByUser(ctx context.Context, userID int64) ([]Key, error) {
+	var keys []Key
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke 实现 Store
+func (s *GormStore) Revoke(ctx context.Context, id string, at int64) error {
+	res := s.db.WithContext(ctx).Model(&Key{}).
+		Where("id = ? AND revoked_at = 0", id).
+		Update("revoked_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 已吊销 (幂等) 或不存在
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// CachedStore - 验签热路径缓存
+// =============================================================================
+
+// CachedStore 给 Get 加本地缓存，每个请求都要查 Key，不能每次打库
+//
+// 【注意】吊销走本实例时立即失效；多实例部署时其他实例最多延迟 ttl 才看到吊销，
+// ttl 要按"被盗 key 还能用多久"来定，默认 10s
+type CachedStore struct {
+	Store
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.RWMutex
+	cache map[string]cachedKey
+}
+
+type cachedKey struct {
+	key       Key
+	expiresAt time.Time
+}
+
+// NewCachedStore 创建缓存存储
+func NewCachedStore(next Store, ttl time.Duration) *CachedStore {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return &CachedStore{Store: next, ttl: ttl, now: time.Now, cache: make(map[string]cachedKey)}
+}
+
+// Get 先查缓存 (不存在的 key 不缓存)
+func (s *CachedStore) Get(ctx context.Context, id string) (*Key, error) {
+	now := s.now()
+	s.mu.RLock()
+	c, ok := s.cache[id]
+	s.mu.RUnlock()
+	if ok && now.Before(c.expiresAt) {
+		k := c.key
+		return &k, nil
+	}
+
+	k, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cache[id] = cachedKey{key: *k, expiresAt: now.Add(s.ttl)}
+	s.mu.Unlock()
+	return k, nil
+}
+
+// Revoke 吊销并清缓存
+func (s *CachedStore) Revoke(ctx context.Context, id string, at int64) error {
+	err := s.Store.Revoke(ctx, id, at)
+	s.mu.Lock()
+	delete(s.cache, id)
+	s.mu.Unlock()
+	return err
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*GormStore)(nil)
+	_ Store = (*CachedStore)(nil)
+)
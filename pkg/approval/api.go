@@ -0,0 +1,127 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// =============================================================================
+// 管理后台接口 (仅内网)
+// =============================================================================
+
+// ProposeRequest 提议请求
+type ProposeRequest struct {
+	Kind       string          `json:"kind"`
+	Payload    json.RawMessage `json:"payload"`
+	OperatorID int64           `json:"operator_id"`
+	Reason     string          `json:"reason"`
+}
+
+// DecideRequest 批准 / 驳回请求
+type DecideRequest struct {
+	ID         string `json:"id"`
+	OperatorID int64  `json:"operator_id"`
+	Note       string `json:"note"`
+}
+
+// NewHandler 创建双人复核接口
+//
+//	POST /admin/approvals           提议 (ProposeRequest)
+//	GET  /admin/approvals?status=PENDING
+//	GET  /admin/approvals?id=...
+//	POST /admin/approvals/approve   批准并执行 (DecideRequest)，operator_id 不能是提议人
+//	POST /admin/approvals/reject    驳回 (DecideRequest)
+//
+// 执行失败返回 502，请求体仍是更新后的 Request (status=FAILED, error=...)
+func NewHandler(wf *Workflow) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/approvals", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			if id := q.Get("id"); id != "" {
+				req, err := wf.Get(id)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				writeJSON(w, req)
+				return
+			}
+			writeJSON(w, wf.List(Status(q.Get("status"))))
+
+		case http.MethodPost:
+			var req ProposeRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Kind == "" {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			created, err := wf.Propose(r.Context(), req.Kind, req.Payload, req.OperatorID, req.Reason)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/admin/approvals/approve", decide(wf.Approve))
+	mux.HandleFunc("/admin/approvals/reject", decide(wf.Reject))
+	return mux
+}
+
+func decide(fn func(ctx context.Context, id string, operatorID int64, note string) (*Request, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req DecideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		result, err := fn(r.Context(), req.ID, req.OperatorID, req.Note)
+		if err != nil && result == nil {
+			writeError(w, err)
+			return
+		}
+		if err != nil {
+			// 已批准但执行失败：返回单据，便于前端展示失败原因
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
+
+// writeError 工作流错误映射到 HTTP 状态码
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrRequestNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrUnknownOperation), errors.Is(err, ErrInvalidPayload), errors.Is(err, ErrInvalidOperator):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrSelfApproval):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrNotPending), errors.Is(err, ErrExpired):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,379 @@
+// Package approval 敏感管理操作的双人复核 (maker-checker)
+//
+// 高影响操作 (合约交割、保险基金提取、成交撤销、账户解冻) 不允许单个管理员直接执行：
+//
+//	管理员 A Propose ──→ PENDING ──管理员 B Approve (TTL 内)──→ 执行 ──→ EXECUTED / FAILED
+//	                        ├──任意管理员 Reject──→ REJECTED
+//	                        └──超过 TTL 无人处理──→ EXPIRED
+//
+// 每一步都记一条 APPROVAL 审计 (Resource = approval:{id})，执行结果也写进审计，
+// 事后能还原"谁提的、谁批的、批的是什么参数、执行成没成功"。
+//
+// 【面试】为什么参数在提议时就定死？
+// 复核人批准的是一份具体的请求 (币种、金额、symbol)，执行时用的必须是同一份字节，
+// 否则提议人可以先提一个小额提取骗过复核，再改成大额。所以 Payload 在 Propose 时
+// 校验并冻结，Approve 只接受 ID，不接受参数。
+//
+// 【注意】待审批请求只存在内存中，进程重启后需要重新提议 (宁可丢，不可误执行)
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"max.com/pkg/audit"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrUnknownOperation = errors.New("approval: unknown operation")
+	ErrInvalidPayload   = errors.New("approval: invalid payload")
+	ErrRequestNotFound  = errors.New("approval: request not found")
+	ErrNotPending       = errors.New("approval: request is not pending")
+	ErrExpired          = errors.New("approval: request expired")
+	ErrSelfApproval     = errors.New("approval: proposer cannot approve their own request")
+	ErrInvalidOperator  = errors.New("approval: invalid operator")
+)
+
+// =============================================================================
+// 请求
+// =============================================================================
+
+// Status 请求状态
+type Status string
+
+const (
+	StatusPending  Status = "PENDING"
+	StatusRejected Status = "REJECTED"
+	StatusExpired  Status = "EXPIRED"
+	StatusExecuted Status = "EXECUTED" // 已批准并执行成功
+	StatusFailed   Status = "FAILED"   // 已批准但执行失败 (需要重新提议)
+	statusRunning  Status = "EXECUTING"
+)
+
+// Request 一次待复核的操作
+type Request struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Payload    json.RawMessage `json:"payload"`
+	Resource   string          `json:"resource"` // 被操作的对象，如 "insurance_fund:USDT"
+	Reason     string          `json:"reason"`
+	ProposerID int64           `json:"proposer_id"`
+	CreatedAt  int64           `json:"created_at"` // 毫秒
+	ExpiresAt  int64           `json:"expires_at"`
+
+	Status     Status          `json:"status"`
+	DeciderID  int64           `json:"decider_id,omitempty"` // 批准或驳回的管理员
+	DecidedAt  int64           `json:"decided_at,omitempty"`
+	DecideNote string          `json:"decide_note,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// =============================================================================
+// 操作定义
+// =============================================================================
+
+// Operation 一种需要复核的操作
+type Operation struct {
+	Kind string
+
+	// Validate 提议时校验参数，返回被操作对象 (写入审计 Resource)
+	Validate func(payload json.RawMessage) (resource string, err error)
+
+	// Execute 批准后执行；req 为快照 (DeciderID 即批准人)，返回值序列化后写入 Request.Result
+	Execute func(ctx context.Context, req *Request) (any, error)
+}
+
+// =============================================================================
+// Workflow
+// =============================================================================
+
+// Config 工作流配置
+type Config struct {
+	TTL     time.Duration    // 待审批有效期，默认 1 小时
+	Auditor audit.Recorder   // 可选，不为 nil 则每一步记审计
+	Now     func() time.Time // 时钟 (测试注入)，默认 time.Now
+}
+
+// Workflow 双人复核工作流
+type Workflow struct {
+	config Config
+
+	mu         sync.Mutex
+	operations map[string]Operation
+	requests   map[string]*Request
+}
+
+// NewWorkflow 创建工作流
+func NewWorkflow(cfg Config) *Workflow {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Workflow{
+		config:     cfg,
+		operations: make(map[string]Operation),
+		requests:   make(map[string]*Request),
+	}
+}
+
+// Register 注册一种操作 (启动时调用)
+func (w *Workflow) Register(op Operation) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.operations[op.Kind] = op
+}
+
+// Kinds 已注册的操作类型
+func (w *Workflow) Kinds() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	kinds := make([]string, 0, len(w.operations))
+	for k := range w.operations {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Propose 提议一次操作，参数在此时校验并冻结
+func (w *Workflow) Propose(ctx context.Context, kind string, payload json.RawMessage, proposerID int64, reason string) (*Request, error) {
+	if proposerID <= 0 {
+		return nil, ErrInvalidOperator
+	}
+	w.mu.Lock()
+	op, ok := w.operations[kind]
+	w.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownOperation, kind)
+	}
+	resource, err := op.Validate(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	now := w.config.Now()
+	req := &Request{
+		ID:         newID(),
+		Kind:       kind,
+		Payload:    append(json.RawMessage(nil), payload...),
+		Resource:   resource,
+		Reason:     reason,
+		ProposerID: proposerID,
+		CreatedAt:  now.UnixMilli(),
+		ExpiresAt:  now.Add(w.config.TTL).UnixMilli(),
+		Status:     StatusPending,
+	}
+	w.mu.Lock()
+	w.requests[req.ID] = req
+	snapshot := *req
+	w.mu.Unlock()
+
+	w.audit(proposerID, &snapshot, "", "PROPOSE", nil)
+	return &snapshot, nil
+}
+
+// Approve 批准并执行
+//
+// 批准人不能是提议人；执行失败时请求进入 FAILED，不会自动重试
+func (w *Workflow) Approve(ctx context.Context, id string, approverID int64, note string) (*Request, error) {
+	if approverID <= 0 {
+		return nil, ErrInvalidOperator
+	}
+	w.mu.Lock()
+	req, err := w.pendingLocked(id)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	if req.ProposerID == approverID {
+		w.mu.Unlock()
+		return nil, ErrSelfApproval
+	}
+	op, ok := w.operations[req.Kind]
+	if !ok {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("%w: %q", ErrUnknownOperation, req.Kind)
+	}
+	// 先占住状态再在锁外执行，并发的第二个 Approve 会拿到 ErrNotPending
+	req.Status = statusRunning
+	req.DeciderID = approverID
+	req.DecidedAt = w.config.Now().UnixMilli()
+	req.DecideNote = note
+	running := *req
+	w.mu.Unlock()
+
+	result, execErr := op.Execute(ctx, &running)
+
+	w.mu.Lock()
+	if execErr != nil {
+		req.Status = StatusFailed
+		req.Error = execErr.Error()
+	} else {
+		req.Status = StatusExecuted
+		if result != nil {
+			req.Result, _ = json.Marshal(result)
+		}
+	}
+	snapshot := *req
+	w.mu.Unlock()
+
+	w.audit(approverID, &snapshot, StatusPending, "APPROVE", execErr)
+	if execErr != nil {
+		return &snapshot, fmt.Errorf("approval: execute %s: %w", req.Kind, execErr)
+	}
+	return &snapshot, nil
+}
+
+// Reject 驳回 (提议人也可以撤回自己的提议)
+func (w *Workflow) Reject(ctx context.Context, id string, operatorID int64, note string) (*Request, error) {
+	if operatorID <= 0 {
+		return nil, ErrInvalidOperator
+	}
+	w.mu.Lock()
+	req, err := w.pendingLocked(id)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	req.Status = StatusRejected
+	req.DeciderID = operatorID
+	req.DecidedAt = w.config.Now().UnixMilli()
+	req.DecideNote = note
+	snapshot := *req
+	w.mu.Unlock()
+
+	w.audit(operatorID, &snapshot, StatusPending, "REJECT", nil)
+	return &snapshot, nil
+}
+
+// Get 查询请求
+func (w *Workflow) Get(id string) (*Request, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	req, ok := w.requests[id]
+	if !ok {
+		return nil, ErrRequestNotFound
+	}
+	w.expireLocked(req)
+	snapshot := *req
+	return &snapshot, nil
+}
+
+// List 按状态列出请求 (status 为空返回全部)，按创建时间倒序
+func (w *Workflow) List(status Status) []Request {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]Request, 0, len(w.requests))
+	for _, req := range w.requests {
+		w.expireLocked(req)
+		if status == "" || req.Status == status {
+			out = append(out, *req)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Prune 删除 before 之前创建且已结束的请求 (审计日志里有完整记录)
+func (w *Workflow) Prune(before time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for id, req := range w.requests {
+		w.expireLocked(req)
+		if req.Status != StatusPending && req.Status != statusRunning && req.CreatedAt < before.UnixMilli() {
+			delete(w.requests, id)
+			n++
+		}
+	}
+	return n
+}
+
+// pendingLocked 取出待审批请求，过期的顺便标记 (调用方持锁)
+func (w *Workflow) pendingLocked(id string) (*Request, error) {
+	req, ok := w.requests[id]
+	if !ok {
+		return nil, ErrRequestNotFound
+	}
+	if w.expireLocked(req) {
+		return nil, ErrExpired
+	}
+	if req.Status != StatusPending {
+		return nil, fmt.Errorf("%w: %s", ErrNotPending, req.Status)
+	}
+	return req, nil
+}
+
+// expireLocked 过期检查 (惰性，访问时才标记)，本次标记为过期时返回 true
+func (w *Workflow) expireLocked(req *Request) bool {
+	if req.Status != StatusPending || w.config.Now().UnixMilli() < req.ExpiresAt {
+		return false
+	}
+	req.Status = StatusExpired
+	snapshot := *req
+	// 审计在锁内直接记录：Recorder 只是入队，不会回调本包
+	w.audit(0, &snapshot, StatusPending, "EXPIRE", nil)
+	return true
+}
+
+// audit 记一条 APPROVAL 审计
+func (w *Workflow) audit(operatorID int64, req *Request, before Status, step string, execErr error) {
+	if w.config.Auditor == nil {
+		return
+	}
+	actorType := audit.ActorAdmin
+	if operatorID == 0 {
+		actorType = audit.ActorSystem
+	}
+	meta := map[string]string{
+		"step":        step,
+		"kind":        req.Kind,
+		"target":      req.Resource,
+		"proposer_id": strconv.FormatInt(req.ProposerID, 10),
+		"reason":      req.Reason,
+	}
+	if req.DecideNote != "" {
+		meta["note"] = req.DecideNote
+	}
+	if execErr != nil {
+		meta["error"] = execErr.Error()
+	}
+	ev := audit.Event{
+		ActorType: actorType,
+		ActorID:   operatorID,
+		Action:    audit.ActionApproval,
+		Resource:  "approval:" + req.ID,
+		After:     req,
+		Meta:      meta,
+	}
+	if before != "" {
+		ev.Before = before
+	}
+	w.config.Auditor.Record(ev)
+}
+
+func newID() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
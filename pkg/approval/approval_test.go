@@ -0,0 +1,143 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"max.com/pkg/account"
+	"max.com/pkg/audit"
+)
+
+type recorder struct{ events []audit.Event }
+
+func (r *recorder) Record(e audit.Event) { r.events = append(r.events, e) }
+
+type fakeBuster struct {
+	busted   []int64
+	operator int64
+	err      error
+}
+
+func (b *fakeBuster) BustTrade(_ context.Context, _ string, tradeID int64, operatorID int64, _ string) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.busted = append(b.busted, tradeID)
+	b.operator = operatorID
+	return nil
+}
+
+func TestWorkflow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rec := &recorder{}
+	buster := &fakeBuster{}
+	wf := NewWorkflow(Config{TTL: time.Minute, Auditor: rec, Now: func() time.Time { return now }})
+	wf.Register(TradeBustOperation(buster))
+
+	if _, err := wf.Propose(ctx, KindTradeBust, json.RawMessage(`{"symbol":"BTC_USDT","trade_id":0}`), 1, ""); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected ErrInvalidPayload, got %v", err)
+	}
+	if _, err := wf.Propose(ctx, "DROP_TABLES", nil, 1, ""); !errors.Is(err, ErrUnknownOperation) {
+		t.Fatalf("expected ErrUnknownOperation, got %v", err)
+	}
+
+	req, err := wf.Propose(ctx, KindTradeBust, json.RawMessage(`{"symbol":"BTC_USDT","trade_id":42}`), 1, "fat finger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wf.Approve(ctx, req.ID, 1, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected ErrSelfApproval, got %v", err)
+	}
+	done, err := wf.Approve(ctx, req.ID, 2, "checked")
+	if err != nil || done.Status != StatusExecuted || done.DeciderID != 2 {
+		t.Fatalf("approve: %+v %v", done, err)
+	}
+	if len(buster.busted) != 1 || buster.busted[0] != 42 || buster.operator != 2 {
+		t.Fatalf("bust not executed by approver: %+v", buster)
+	}
+	if _, err := wf.Approve(ctx, req.ID, 3, ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("second approve should fail, got %v", err)
+	}
+
+	// 过期后不能再批准
+	stale, _ := wf.Propose(ctx, KindTradeBust, json.RawMessage(`{"symbol":"BTC_USDT","trade_id":43}`), 1, "")
+	now = now.Add(2 * time.Minute)
+	if _, err := wf.Approve(ctx, stale.ID, 2, ""); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if got, _ := wf.Get(stale.ID); got.Status != StatusExpired {
+		t.Fatalf("expected EXPIRED, got %s", got.Status)
+	}
+
+	// 执行失败进入 FAILED
+	buster.err = errors.New("trade already settled")
+	failed, _ := wf.Propose(ctx, KindTradeBust, json.RawMessage(`{"symbol":"BTC_USDT","trade_id":44}`), 1, "")
+	if got, err := wf.Approve(ctx, failed.ID, 2, ""); err == nil || got.Status != StatusFailed || got.Error == "" {
+		t.Fatalf("expected FAILED, got %+v %v", got, err)
+	}
+
+	// 审计：3 次提议 + 1 次批准 + 1 次过期 + 1 次失败
+	steps := map[string]int{}
+	for _, e := range rec.events {
+		if e.Action != audit.ActionApproval {
+			t.Fatalf("unexpected action %s", e.Action)
+		}
+		steps[e.Meta["step"]]++
+	}
+	if steps["PROPOSE"] != 3 || steps["APPROVE"] != 2 || steps["EXPIRE"] != 1 {
+		t.Fatalf("unexpected audit steps: %v", steps)
+	}
+}
+
+func TestHandler_AccountUnfreeze(t *testing.T) {
+	p := account.NewMemoryProvider(account.StatusActive)
+	p.SetStatus(1001, account.StatusBanned, 7, "fraud")
+	wf := NewWorkflow(Config{})
+	wf.Register(AccountUnfreezeOperation(p))
+	h := NewHandler(wf)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	// 收紧不是解冻，不接受提议
+	if w := post("/admin/approvals", `{"kind":"ACCOUNT_UNFREEZE","payload":{"user_id":1002,"status":"BANNED"},"operator_id":7}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("freeze proposal should be 400, got %d", w.Code)
+	}
+
+	w := post("/admin/approvals", `{"kind":"ACCOUNT_UNFREEZE","payload":{"user_id":1001},"operator_id":7,"reason":"appeal accepted"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("propose: %d %s", w.Code, w.Body.String())
+	}
+	var req Request
+	json.NewDecoder(w.Body).Decode(&req)
+
+	if w := post("/admin/approvals/approve", `{"id":"`+req.ID+`","operator_id":7}`); w.Code != http.StatusForbidden {
+		t.Fatalf("self approval should be 403, got %d", w.Code)
+	}
+	if st, _ := p.Status(context.Background(), 1001); st != account.StatusBanned {
+		t.Fatalf("status changed before approval: %s", st)
+	}
+	if w := post("/admin/approvals/approve", `{"id":"`+req.ID+`","operator_id":8}`); w.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", w.Code, w.Body.String())
+	}
+	if st, _ := p.Status(context.Background(), 1001); st != account.StatusActive {
+		t.Fatalf("expected ACTIVE after approval, got %s", st)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/approvals?status=EXECUTED", nil))
+	var list []Request
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || len(list) != 1 || list[0].DeciderID != 8 {
+		t.Fatalf("list executed: %+v %v", list, err)
+	}
+}
@@ -0,0 +1,369 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"max.com/pkg/account"
+	"max.com/pkg/emergency"
+	"max.com/pkg/futures"
+	"max.com/pkg/withdrawrisk"
+)
+
+// =============================================================================
+// 内置操作
+// =============================================================================
+
+// 操作类型
+const (
+	KindSettleContract    = "SETTLE_CONTRACT"
+	KindInsuranceWithdraw = "INSURANCE_WITHDRAW"
+	KindTradeBust         = "TRADE_BUST"
+	KindAccountUnfreeze   = "ACCOUNT_UNFREEZE"
+	KindWithdrawQuota     = "WITHDRAW_QUOTA_OVERRIDE"
+	KindKillSwitchRelease = "KILL_SWITCH_RELEASE"
+	KindTradingResume     = "TRADING_RESUME"
+)
+
+// decode 严格解析 payload：未知字段直接拒绝，防止拼错字段名被静默忽略
+func decode(payload json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// remark 执行时写入业务流水的备注，能从流水反查到审批单
+func remark(req *Request) string {
+	s := "approval:" + req.ID
+	if req.Reason != "" {
+		s += " " + req.Reason
+	}
+	return s
+}
+
+// -----------------------------------------------------------------------------
+// 合约交割
+// -----------------------------------------------------------------------------
+
+// SettlePayload 交割参数
+type SettlePayload struct {
+	Symbol string `json:"symbol"`
+}
+
+// SettleResult 交割结果摘要 (明细查 settlement_details)
+type SettleResult struct {
+	Symbol          string `json:"symbol"`
+	SettlementPrice int64  `json:"settlement_price"`
+	Positions       int    `json:"positions"`
+	Skipped         int    `json:"skipped"` // 之前批次已返还过的持仓 (重跑)
+	TotalPnL        int64  `json:"total_pnl"`
+	TotalReturned   int64  `json:"total_returned"`
+	TotalShortfall  int64  `json:"total_shortfall"`
+}
+
+// SettlementOperation 手动触发合约交割
+//
+// 【面试】为什么交割要复核？
+// 交割不可逆：合约进入 SETTLED 后所有持仓按结算价平掉，触发错合约或时间不对
+// 就是全体持仓用户的损失。复核人批准前应先用 dry-run 核对结算价和穿仓金额
+func SettlementOperation(engine *futures.SettlementEngine) Operation {
+	return Operation{
+		Kind: KindSettleContract,
+		Validate: func(payload json.RawMessage) (string, error) {
+			var p SettlePayload
+			if err := decode(payload, &p); err != nil {
+				return "", err
+			}
+			if p.Symbol == "" {
+				return "", errors.New("symbol is required")
+			}
+			return "contract:" + p.Symbol, nil
+		},
+		Execute: func(ctx context.Context, req *Request) (any, error) {
+			var p SettlePayload
+			if err := decode(req.Payload, &p); err != nil {
+				return nil, err
+			}
+			report, err := engine.SettleContract(ctx, p.Symbol, false)
+			if err != nil {
+				return nil, err
+			}
+			return SettleResult{
+				Symbol:          report.Symbol,
+				SettlementPrice: report.SettlementPrice,
+				Positions:       len(report.Entries),
+				Skipped:         report.SkippedCount,
+				TotalPnL:        report.TotalPnL,
+				TotalReturned:   report.TotalReturned,
+				TotalShortfall:  report.TotalShortfall,
+			}, nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// 保险基金提取
+// -----------------------------------------------------------------------------
+
+// InsuranceWithdrawPayload 提取参数
+type InsuranceWithdrawPayload struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+}
+
+// InsuranceWithdrawOperation 从保险基金提取
+//
+// 流水的 RelatedUserID 记批准人，Remark 带审批单号
+func InsuranceWithdrawOperation(fund *futures.InsuranceFund) Operation {
+	return Operation{
+		Kind: KindInsuranceWithdraw,
+		Validate: func(payload json.RawMessage) (string, error) {
+			var p InsuranceWithdrawPayload
+			if err := decode(payload, &p); err != nil {
+				return "", err
+			}
+			if p.Currency == "" || p.Amount <= 0 {
+				return "", errors.New("currency and positive amount are required")
+			}
+			return "insurance_fund:" + p.Currency, nil
+		},
+		Execute: func(ctx context.Context, req *Request) (any, error) {
+			var p InsuranceWithdrawPayload
+			if err := decode(req.Payload, &p); err != nil {
+				return nil, err
+			}
+			if err := fund.Withdraw(ctx, p.Currency, p.Amount, req.DeciderID, remark(req)); err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// 成交撤销
+// -----------------------------------------------------------------------------
+
+// TradeBuster 撤销一笔成交 (冲正双方资金和持仓)
+//
+// 【注意】撮合和结算目前都没有撤销成交的实现，这里只定义复核入口，
+// 由实现了冲正逻辑的模块注入
+type TradeBuster interface {
+	BustTrade(ctx context.Context, symbol string, tradeID int64, operatorID int64, reason string) error
+}
+
+// TradeBustPayload 成交撤销参数
+type TradeBustPayload struct {
+	Symbol  string `json:"symbol"`
+	TradeID int64  `json:"trade_id"`
+}
+
+// TradeBustOperation 撤销成交
+func TradeBustOperation(buster TradeBuster) Operation {
+	return Operation{
+		Kind: KindTradeBust,
+		Validate: func(payload json.RawMessage) (string, error) {
+			var p TradeBustPayload
+			if err := decode(payload, &p); err != nil {
+				return "", err
+			}
+			if p.Symbol == "" || p.TradeID <= 0 {
+				return "", errors.New("symbol and trade_id are required")
+			}
+			return fmt.Sprintf("trade:%s:%d", p.Symbol, p.TradeID), nil
+		},
+		Execute: func(ctx context.Context, req *Request) (any, error) {
+			var p TradeBustPayload
+			if err := decode(req.Payload, &p); err != nil {
+				return nil, err
+			}
+			if err := buster.BustTrade(ctx, p.Symbol, p.TradeID, req.DeciderID, remark(req)); err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// 账户解冻
+// -----------------------------------------------------------------------------
+
+// AccountUnfreezePayload 解冻参数，Status 为空表示恢复为 ACTIVE
+type AccountUnfreezePayload struct {
+	UserID int64  `json:"user_id"`
+	Status string `json:"status,omitempty"`
+}
+
+// AccountUnfreezeOperation 解冻账户 (BANNED / RESTRICTED 放宽)
+//
+// 冻结走 /account/status 一人即可立即生效；解冻在那里会被 403，只能从这里执行
+func AccountUnfreezeOperation(p *account.MemoryProvider) Operation {
+	parse := func(payload json.RawMessage) (AccountUnfreezePayload, account.Status, error) {
+		var req AccountUnfreezePayload
+		if err := decode(payload, &req); err != nil {
+			return req, 0, err
+		}
+		if req.UserID <= 0 {
+			return req, 0, errors.New("user_id is required")
+		}
+		if req.Status == "" {
+			req.Status = account.StatusActive.String()
+		}
+		st, err := account.ParseStatus(req.Status)
+		return req, st, err
+	}
+	return Operation{
+		Kind: KindAccountUnfreeze,
+		Validate: func(payload json.RawMessage) (string, error) {
+			req, to, err := parse(payload)
+			if err != nil {
+				return "", err
+			}
+			from, _ := p.Status(context.Background(), req.UserID)
+			if !account.IsUnfreeze(from, to) {
+				return "", fmt.Errorf("%s -> %s is not an unfreeze", from, to)
+			}
+			return "account_status:" + strconv.FormatInt(req.UserID, 10), nil
+		},
+		Execute: func(ctx context.Context, r *Request) (any, error) {
+			req, to, err := parse(r.Payload)
+			if err != nil {
+				return nil, err
+			}
+			change, err := p.SetStatus(req.UserID, to, r.DeciderID, remark(r))
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"from": change.From.String(), "to": change.To.String()}, nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// 提现限额临时放宽
+// -----------------------------------------------------------------------------
+
+// WithdrawQuotaPayload 临时限额参数，到期时间在提议时定死，复核人批准的就是这个期限
+type WithdrawQuotaPayload struct {
+	UserID    int64                    `json:"user_id"`
+	Limits    withdrawrisk.QuotaLimits `json:"limits"`
+	ExpiresAt time.Time                `json:"expires_at"`
+}
+
+// WithdrawQuotaOverrideOperation 临时放宽单个用户的提现日限额 (大客户出金、机构调拨)
+//
+// 撤销放宽是收紧，直接调用 Quota.ClearOverride 即可
+func WithdrawQuotaOverrideOperation(q *withdrawrisk.Quota) Operation {
+	parse := func(payload json.RawMessage) (withdrawrisk.Override, error) {
+		var p WithdrawQuotaPayload
+		if err := decode(payload, &p); err != nil {
+			return withdrawrisk.Override{}, err
+		}
+		return withdrawrisk.Override{UserID: p.UserID, Limits: p.Limits, ExpiresAt: p.ExpiresAt}, nil
+	}
+	return Operation{
+		Kind: KindWithdrawQuota,
+		Validate: func(payload json.RawMessage) (string, error) {
+			o, err := parse(payload)
+			if err != nil {
+				return "", err
+			}
+			if err := q.ValidateOverride(o); err != nil {
+				return "", err
+			}
+			return "withdraw_quota:" + strconv.FormatInt(o.UserID, 10), nil
+		},
+		Execute: func(ctx context.Context, r *Request) (any, error) {
+			o, err := parse(r.Payload)
+			if err != nil {
+				return nil, err
+			}
+			o.OperatorID = r.DeciderID
+			o.Reason = remark(r)
+			if err := q.SetOverride(o); err != nil {
+				return nil, err
+			}
+			return o, nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// 解除全站停机 / 恢复用户交易
+// -----------------------------------------------------------------------------
+
+// KillSwitchReleasePayload 解除停机参数 (无字段，原因写在审批单上)
+type KillSwitchReleasePayload struct{}
+
+// KillSwitchReleaseOperation 解除全站停机
+//
+// 停机走 /emergency/kill 一人即可立即生效；解除在那里会被 403，只能从这里执行
+func KillSwitchReleaseOperation(c *emergency.Control) Operation {
+	return Operation{
+		Kind: KindKillSwitchRelease,
+		Validate: func(payload json.RawMessage) (string, error) {
+			var p KillSwitchReleasePayload
+			if err := decode(payload, &p); err != nil {
+				return "", err
+			}
+			if !c.Engaged() {
+				return "", emergency.ErrNotEngaged
+			}
+			return "kill_switch", nil
+		},
+		Execute: func(ctx context.Context, r *Request) (any, error) {
+			if err := c.Release(r.DeciderID, remark(r)); err != nil {
+				return nil, err
+			}
+			return c.State().KillSwitch, nil
+		},
+	}
+}
+
+// TradingResumePayload 恢复交易参数
+type TradingResumePayload struct {
+	UserID int64 `json:"user_id"`
+}
+
+// TradingResumeOperation 恢复被暂停用户的交易
+func TradingResumeOperation(c *emergency.Control) Operation {
+	parse := func(payload json.RawMessage) (int64, error) {
+		var p TradingResumePayload
+		if err := decode(payload, &p); err != nil {
+			return 0, err
+		}
+		if p.UserID <= 0 {
+			return 0, errors.New("user_id is required")
+		}
+		return p.UserID, nil
+	}
+	return Operation{
+		Kind: KindTradingResume,
+		Validate: func(payload json.RawMessage) (string, error) {
+			userID, err := parse(payload)
+			if err != nil {
+				return "", err
+			}
+			if !c.Suspended(userID) {
+				return "", emergency.ErrNotSuspended.Wrapf("user %d", userID)
+			}
+			return "trading_suspension:" + strconv.FormatInt(userID, 10), nil
+		},
+		Execute: func(ctx context.Context, r *Request) (any, error) {
+			userID, err := parse(r.Payload)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.Resume(userID, r.DeciderID, remark(r)); err != nil {
+				return nil, err
+			}
+			return map[string]int64{"user_id": userID}, nil
+		},
+	}
+}
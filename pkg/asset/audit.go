@@ -0,0 +1,89 @@
+// 文件: pkg/asset/audit.go
+// 余额变动审计
+//
+// 每条成功执行的资金命令，为它改动的每个 (用户, 资产) 记一条 BALANCE_CHANGE，
+// Before/After 为该余额的 {available, locked}。
+// WAL 重放不再记录：重放的是已经审计过的命令。
+
+package asset
+
+import (
+	"fmt"
+
+	"max.com/pkg/audit"
+)
+
+// touchedBalances 命令会改动的余额 (去重)
+func touchedBalances(cmd Command) []balanceKey {
+	keys := []balanceKey{{cmd.UserID, cmd.Symbol}}
+	add := func(k balanceKey) {
+		for _, existing := range keys {
+			if existing == k {
+				return
+			}
+		}
+		keys = append(keys, k)
+	}
+	if cmd.Type == CmdTransfer || cmd.Type == CmdTransferRevert {
+		add(balanceKey{cmd.ToUserID, cmd.ToSymbol})
+		if cmd.Fee > 0 && cmd.FeeAsset != "" {
+			add(balanceKey{cmd.UserID, cmd.FeeAsset})
+		}
+	}
+	return keys
+}
+
+// balanceOf 读取余额 (不存在返回零值，不创建用户)
+func (s *Shard) balanceOf(key balanceKey) Asset {
+	if user, ok := s.users[key.UserID]; ok {
+		if a, ok := user.Assets[key.Symbol]; ok {
+			return *a
+		}
+	}
+	return Asset{}
+}
+
+// auditBefore 执行命令前记下改动余额的原值，未启用审计返回 nil
+func (s *Shard) auditBefore(cmd Command) []Asset {
+	if s.auditor == nil {
+		return nil
+	}
+	keys := touchedBalances(cmd)
+	before := make([]Asset, len(keys))
+	for i, key := range keys {
+		before[i] = s.balanceOf(key)
+	}
+	return before
+}
+
+// recordAudit 命令成功后按余额逐条记录
+func (s *Shard) recordAudit(cmd Command, before []Asset) {
+	if s.auditor == nil {
+		return
+	}
+	meta := map[string]string{"cmd": cmd.Type.String(), "cmd_id": cmd.Key.String()}
+	for i, key := range touchedBalances(cmd) {
+		after := s.balanceOf(key)
+		if after == before[i] {
+			continue // 实际没变 (如手续费不足未扣)
+		}
+		s.auditor.Record(audit.Event{
+			ActorType: audit.ActorSystem,
+			Action:    audit.ActionBalanceChange,
+			Resource:  fmt.Sprintf("balance:%d:%s", key.UserID, key.Symbol),
+			Before:    auditBalance(before[i]),
+			After:     auditBalance(after),
+			Meta:      meta,
+		})
+	}
+}
+
+// auditBalanceState 审计记录里的余额格式
+type auditBalanceState struct {
+	Available int64 `json:"available"`
+	Locked    int64 `json:"locked"`
+}
+
+func auditBalance(a Asset) auditBalanceState {
+	return auditBalanceState{Available: a.Available, Locked: a.Locked}
+}
@@ -0,0 +1,140 @@
+// 文件: pkg/asset/cmd_key.go
+// 命令幂等键
+//
+// 【问题】每笔成交要生成 2~4 个幂等键 (fill_seller_1 / fill_buyer_1 / fill_fee_1_USDT ...)，
+// 原来用 fmt.Sprintf 拼字符串，成交量大时每秒几十万个短命字符串，全是 GC 压力
+//
+// 【做法】幂等键改成可比较的结构体 (种类 + 数值 ID + 资产)，直接作为 map 键，不拼字符串：
+//   - 资产字符串引用成交事件里的原值，构造幂等键零分配
+//   - 写 WAL 时按原来的文本格式追加到复用缓冲区 (AppendTo)，WAL 格式不变
+//   - 重放 WAL / 外部传入的字符串键用 ParseCmdKey 还原，与运行时构造的键相等
+//
+// 【注意】外部字符串 (充值/提现事件 ID、付款 ID) 也要经过 ParseCmdKey：
+// "reserve_1" 这类看起来像内部格式的外部键会被解析成内部种类，重放时解析结果一致
+
+package asset
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CmdKind 幂等键种类
+type CmdKind uint8
+
+const (
+	CmdKindExternal   CmdKind = iota // 外部字符串键 (Name 为原始字符串)
+	CmdKindReserve                   // reserve_{orderID}
+	CmdKindRelease                   // release_{orderID}
+	CmdKindFillSeller                // fill_seller_{tradeID}
+	CmdKindFillBuyer                 // fill_buyer_{tradeID}
+	CmdKindFee                       // fill_fee_{tradeID}_{asset}
+	CmdKindRebateOut                 // fill_rebate_out_{tradeID}_{userID}_{asset}
+	CmdKindRebate                    // fill_rebate_{tradeID}_{userID}_{asset}
+)
+
+// cmdKindPrefix 各种类的文本前缀 (与原 fmt.Sprintf 格式一致)
+// 解析时按顺序匹配，fill_rebate_out_ 必须排在 fill_rebate_ 之前
+var cmdKindPrefix = [...]struct {
+	kind   CmdKind
+	prefix string
+}{
+	{CmdKindRebateOut, "fill_rebate_out_"},
+	{CmdKindRebate, "fill_rebate_"},
+	{CmdKindFee, "fill_fee_"},
+	{CmdKindFillSeller, "fill_seller_"},
+	{CmdKindFillBuyer, "fill_buyer_"},
+	{CmdKindReserve, "reserve_"},
+	{CmdKindRelease, "release_"},
+}
+
+// CmdKey 命令幂等键，零值表示不做幂等检查
+type CmdKey struct {
+	Kind   CmdKind
+	ID     int64  // 订单 ID / 成交 ID
+	UserID int64  // 返佣用户 (CmdKindRebate / CmdKindRebateOut)
+	Name   string // 资产 (手续费/返佣)；CmdKindExternal 为原始字符串
+}
+
+// IsZero 是否为空键
+func (k CmdKey) IsZero() bool {
+	return k == CmdKey{}
+}
+
+// AppendTo 按文本格式追加到 buf (写 WAL 用，不分配)
+func (k CmdKey) AppendTo(buf []byte) []byte {
+	if k.Kind == CmdKindExternal {
+		return append(buf, k.Name...)
+	}
+	for _, p := range cmdKindPrefix {
+		if p.kind == k.Kind {
+			buf = append(buf, p.prefix...)
+			break
+		}
+	}
+	buf = strconv.AppendInt(buf, k.ID, 10)
+	switch k.Kind {
+	case CmdKindRebate, CmdKindRebateOut:
+		buf = append(buf, '_')
+		buf = strconv.AppendInt(buf, k.UserID, 10)
+		fallthrough
+	case CmdKindFee:
+		buf = append(buf, '_')
+		buf = append(buf, k.Name...)
+	}
+	return buf
+}
+
+// String 文本格式 (日志、审计)
+func (k CmdKey) String() string {
+	return string(k.AppendTo(nil))
+}
+
+// ParseCmdKey 从文本还原幂等键，不符合内部格式的按外部键处理
+func ParseCmdKey(s string) CmdKey {
+	if s == "" {
+		return CmdKey{}
+	}
+	for _, p := range cmdKindPrefix {
+		rest, ok := strings.CutPrefix(s, p.prefix)
+		if !ok {
+			continue
+		}
+		if k, ok := parseCmdKeyBody(p.kind, rest); ok && k.String() == s {
+			return k // 回写一致才算内部格式 (排除前导零、"+1" 之类)
+		}
+		break
+	}
+	return CmdKey{Kind: CmdKindExternal, Name: s}
+}
+
+func parseCmdKeyBody(kind CmdKind, rest string) (CmdKey, bool) {
+	k := CmdKey{Kind: kind}
+	fields := 1
+	switch kind {
+	case CmdKindFee:
+		fields = 2
+	case CmdKindRebate, CmdKindRebateOut:
+		fields = 3
+	}
+	parts := strings.SplitN(rest, "_", fields)
+	if len(parts) != fields {
+		return k, false
+	}
+	var err error
+	if k.ID, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return k, false
+	}
+	if fields == 3 {
+		if k.UserID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return k, false
+		}
+	}
+	if fields > 1 {
+		k.Name = parts[fields-1]
+		if k.Name == "" {
+			return k, false
+		}
+	}
+	return k, true
+}
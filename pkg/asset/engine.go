@@ -32,11 +32,22 @@
 package asset
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"max.com/pkg/account"
+	"max.com/pkg/affinity"
+	"max.com/pkg/audit"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/epoch"
+	"max.com/pkg/fixed"
+	"max.com/pkg/fund"
+	"max.com/pkg/walstore"
+	"max.com/pkg/withdrawrisk"
 )
 
 // =============================================================================
@@ -49,14 +60,66 @@ type EngineConfig struct {
 	// 建议设置为 CPU 核数或其倍数
 	NumShards int
 
-	// CommandQueueLen 每个分片的命令队列长度
+	// CommandQueueLen 每个分片每个优先级的命令队列长度
 	// 队列满时会阻塞，设置过大会占用内存
 	CommandQueueLen int
 
+	// AdminRateLimit 每个分片管理/查询类命令 (充值提现同步、快照查询) 每秒最多提交数
+	// 超出时 Submit 返回 ErrRateLimited，0 表示不限
+	AdminRateLimit int
+
 	// DefaultTimeout 默认操作超时时间
 	DefaultTimeout time.Duration
 	WALDir         string // WAL 目录，为空则不启用
 
+	// WALArchiveDir 检查点时封存旧日志和快照的目录 (按分片分子目录)，为空则直接清空
+	WALArchiveDir string
+
+	// WALRemote 归档上传到对象存储 (需配置 WALArchiveDir，key 前缀按分片追加 shard_N/)：
+	// RecoverAll 前拉回本地缺失的归档，Start 后由 walstore.Archiver 每 WALArchiveInterval (<=0 为 30s) 上传
+	WALRemote          *walstore.Remote
+	WALArchiveInterval time.Duration
+
+	// LockOSThread 每个分片 goroutine 独占一个 OS 线程
+	// 8 个以上分片、高负载时避免分片在核之间迁移，稳定尾延迟
+	LockOSThread bool
+
+	// WriteBehind 分片记录变动余额，供 WriteBehind 异步回写冷库
+	WriteBehind bool
+
+	// FeeAccountID 手续费收入账户 (系统用户)，成交手续费归集到这里，maker 返佣从这里支出
+	// 0 表示不归集 (手续费只从用户侧扣除)，此时不支持返佣 (见 fee.go)
+	FeeAccountID int64
+
+	// Auditor 审计记录器，不为 nil 时每次余额变动记一条 BALANCE_CHANGE (见 audit.go)
+	Auditor audit.Recorder
+
+	// AccountStatus 账户状态，不为 nil 时提现扣款前检查 (未认证/封禁不能提现)
+	AccountStatus account.Provider
+
+	// WithdrawRisk 提现风控，不为 nil 时提现扣款前评分 (频率、新地址、大额)，
+	// DENY 返回 withdrawrisk.ErrDenied，HOLD 返回 withdrawrisk.ErrHeld，人工复核通过后用同一 EventID 重试
+	WithdrawRisk withdrawrisk.Checker
+
+	// WithdrawQuota 提现日限额 (*withdrawrisk.Quota)，不为 nil 时在风控评分之前检查，
+	// 超限返回 withdrawrisk.ErrQuotaAmountExceeded / ErrQuotaCountExceeded
+	WithdrawQuota withdrawrisk.Checker
+
+	// FundFlow 充值 / 提现流量计数，不为 nil 时入账 / 扣款成功后记录 (提现日限额的用量来源)
+	FundFlow fund.FlowCounter
+
+	// WithdrawDisabled 禁止提现 (纸面交易/测试网，见 pkg/paper)，WITHDRAW 返回 ErrWithdrawDisabled
+	WithdrawDisabled bool
+
+	// ShardCPUs 分片 → CPU 映射，下标为分片编号，为空则不绑核
+	// 配置后隐含 LockOSThread；可用 SpreadShardsAcrossNUMA 生成
+	ShardCPUs [][]int
+}
+
+// SpreadShardsAcrossNUMA 按 NUMA 拓扑生成分片 → CPU 映射
+// 分片轮流分到各节点，每个分片独占一个 CPU（见 affinity.Spread）
+func SpreadShardsAcrossNUMA(numShards int) [][]int {
+	return affinity.Spread(numShards, affinity.NUMANodes())
 }
 
 // DefaultEngineConfig 返回默认配置
@@ -102,10 +165,22 @@ type AccountEngine struct {
 	// ===== 快照存储 =====
 	snapshotStore *SnapshotStore
 
+	// ===== 纪元栅栏 =====
+	// 所有分片共享，拒绝旧撮合实例的成交 (见 pkg/epoch)
+	fence *epoch.Fence
+
 	// ===== 序列号生成 =====
 	// 全局递增，用于 WAL 和幂等键生成
 	sequence atomic.Uint64
 
+	// ===== 资金流量 =====
+	flowRecordErrors atomic.Int64
+
+	// ===== WAL 归档上传 (配置 WALRemote 时) =====
+	archivers     []*walstore.Archiver
+	stopArchivers context.CancelFunc
+	archiverWG    sync.WaitGroup
+
 	// ===== 生命周期 =====
 	running atomic.Bool
 	stopCh  chan struct{}
@@ -126,6 +201,7 @@ func NewEngine(cfg EngineConfig) *AccountEngine {
 
 	// 创建快照存储
 	snapshotStore := NewSnapshotStore()
+	fence := &epoch.Fence{}
 
 	// 创建分片
 	shards := make([]*Shard, cfg.NumShards)
@@ -133,28 +209,68 @@ func NewEngine(cfg EngineConfig) *AccountEngine {
 		var wal *WAL
 		if cfg.WALDir != "" {
 			walDir := filepath.Join(cfg.WALDir, fmt.Sprintf("shard_%d", i))
+			walCfg := WALConfig{Dir: walDir}
+			if cfg.WALArchiveDir != "" {
+				walCfg.ArchiveDir = filepath.Join(cfg.WALArchiveDir, fmt.Sprintf("shard_%d", i))
+			}
 			var err error
-			wal, err = NewWAL(WALConfig{Dir: walDir})
+			wal, err = NewWAL(walCfg)
 			if err != nil {
 				// 处理错误
 			}
 		}
 
+		var cpus []int
+		if i < len(cfg.ShardCPUs) {
+			cpus = cfg.ShardCPUs[i]
+		}
+
 		shards[i] = NewShard(ShardConfig{
 			ID:              i,
 			CommandQueueLen: cfg.CommandQueueLen,
+			AdminRateLimit:  cfg.AdminRateLimit,
 			SnapshotStore:   snapshotStore,
 			WAL:             wal, // 传入 WAL
-
+			LockOSThread:    cfg.LockOSThread,
+			CPUs:            cpus,
+			TrackDirty:      cfg.WriteBehind,
+			Fence:           fence,
+			Auditor:         cfg.Auditor,
 		})
 	}
 
-	return &AccountEngine{
+	e := &AccountEngine{
 		config:        cfg,
 		shards:        shards,
 		snapshotStore: snapshotStore,
+		fence:         fence,
 		stopCh:        make(chan struct{}),
 	}
+	if cfg.WALRemote != nil {
+		for _, shard := range shards {
+			if shard.wal == nil || shard.wal.Archive() == nil {
+				continue
+			}
+			e.archivers = append(e.archivers, walstore.NewArchiver(walstore.ArchiverConfig{
+				Source:   shard.wal.Archive(),
+				Remote:   e.shardRemote(shard.id),
+				Interval: cfg.WALArchiveInterval,
+			}))
+		}
+	}
+	return e
+}
+
+// shardRemote 分片在对象存储上的归档位置
+func (e *AccountEngine) shardRemote(shardID int) walstore.Remote {
+	remote := *e.config.WALRemote
+	remote.Prefix += fmt.Sprintf("shard_%d/", shardID)
+	return remote
+}
+
+// WALArchivers 各分片的归档上传器 (未配置 WALRemote 时为空)
+func (e *AccountEngine) WALArchivers() []*walstore.Archiver {
+	return e.archivers
 }
 
 // =============================================================================
@@ -175,6 +291,18 @@ func (e *AccountEngine) Start() error {
 		shard.Start()
 	}
 
+	if len(e.archivers) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		e.stopArchivers = cancel
+		for _, a := range e.archivers {
+			e.archiverWG.Add(1)
+			go func() {
+				defer e.archiverWG.Done()
+				a.Run(ctx)
+			}()
+		}
+	}
+
 	e.running.Store(true)
 	return nil
 }
@@ -193,6 +321,13 @@ func (e *AccountEngine) Stop() {
 		shard.Stop()
 	}
 
+	// 停止归档上传 (退出前再上传一轮)
+	if e.stopArchivers != nil {
+		e.stopArchivers()
+		e.archiverWG.Wait()
+		e.stopArchivers = nil
+	}
+
 	e.running.Store(false)
 }
 
@@ -220,8 +355,15 @@ func (e *AccountEngine) nextSequence() uint64 {
 }
 
 // RecoverAll 恢复所有分片
+//
+// 配置了 WALRemote 时先从对象存储拉回本地缺失的归档 (新机器 / 磁盘损坏)
 func (e *AccountEngine) RecoverAll() error {
 	for _, shard := range e.shards {
+		if e.config.WALRemote != nil && shard.wal != nil && shard.wal.Archive() != nil {
+			if _, err := walstore.Restore(context.Background(), e.shardRemote(shard.id), shard.wal.Archive(), nil); err != nil {
+				return fmt.Errorf("restore shard %d archive: %w", shard.id, err)
+			}
+		}
 		if _, err := shard.RecoverFromWAL(); err != nil {
 			return fmt.Errorf("recover shard %d: %w", shard.id, err)
 		}
@@ -280,7 +422,7 @@ func (e *AccountEngine) Reserve(userID int64, symbol string, amount int64, order
 
 	cmd := Command{
 		Type:   CmdReserve,
-		CmdID:  fmt.Sprintf("reserve_%d", orderID),
+		Key:    CmdKey{Kind: CmdKindReserve, ID: orderID},
 		UserID: userID,
 		Symbol: symbol,
 		Amount: amount,
@@ -306,7 +448,7 @@ func (e *AccountEngine) Release(userID int64, symbol string, amount int64, order
 
 	cmd := Command{
 		Type:   CmdRelease,
-		CmdID:  fmt.Sprintf("release_%d", orderID),
+		Key:    CmdKey{Kind: CmdKindRelease, ID: orderID},
 		UserID: userID,
 		Symbol: symbol,
 		Amount: amount,
@@ -322,6 +464,8 @@ func (e *AccountEngine) Release(userID int64, symbol string, amount int64, order
 // 2. 给买方增加资产
 // 3. 扣除双方手续费
 // 4. 可能涉及跨分片 (买卖方在不同分片)
+// 5. 手续费归集到手续费账户，支付 maker 返佣 (见 fee.go)
+// 6. 卖方已结算、买方被拒时撤销卖方 (见 revertSeller)
 //
 // 参数:
 //   - fill: 成交事件
@@ -340,25 +484,30 @@ func (e *AccountEngine) Release(userID int64, symbol string, amount int64, order
 //	    SellerFee:  0_00050000,      // 0.0005 BTC
 //	})
 func (e *AccountEngine) ApplyFill(fill *FillEvent) error {
-	// 计算金额
-	// 注意: 避免溢出! 先除后乘
-	// quoteAmount = Price * Quantity / Precision
-	// 如果 Price 和 Quantity 都很大，先乘会溢出
-	// 解决方案: (Price / Precision) * Quantity 或使用 big.Int
-	// 这里假设 Price 已经是带精度的值 (如 50000_00000000 表示 50000)
-	// Quantity 也是带精度的值 (如 1_00000000 表示 1)
-	// quoteAmount = Price * Quantity / Precision
-	// = (Price / Precision) * Quantity  (先除，可能丢失精度)
-	// 更好的方式: 使用 uint128 或分步计算
-	quoteAmount := (fill.Price / Precision) * fill.Quantity // 先除再乘，避免溢出
-	baseAmount := fill.Quantity                             // 卖方支付的 BTC
+	// 先整体检查纪元，避免旧主的成交只结算了一方
+	// (分片内还会再检查一次，覆盖入队后纪元才推进的情况)
+	if err := e.fence.Admit(fill.Epoch); err != nil {
+		return err
+	}
+
+	// 返佣校验放在最前面：不合法的成交一个分片都不动
+	feeLegs, err := e.collectFeeLegs(fill)
+	if err != nil {
+		return err
+	}
+
+	// 计算金额: quoteAmount = Price × Quantity / Precision
+	// Price、Quantity 都带精度 (50000_00000000 表示 50000)，直接相乘会溢出；
+	// 先除再乘又会丢掉价格的小数部分，128 位中间结果 (fixed.Mul) 两头都不丢
+	quoteAmount := fixed.Mul(fill.Price, fill.Quantity)
+	baseAmount := fill.Quantity // 卖方支付的 BTC
 
 	// ===== 处理卖方 =====
 	// 卖方: 扣 BTC (Locked), 加 USDT (Available), 扣 BTC 手续费
 	sellerShard := e.getShard(fill.SellerID)
 	sellerCmd := Command{
 		Type:     CmdTransfer,
-		CmdID:    fmt.Sprintf("fill_seller_%d", fill.TradeID),
+		Key:      CmdKey{Kind: CmdKindFillSeller, ID: fill.TradeID},
 		UserID:   fill.SellerID,
 		Symbol:   fill.BaseAsset, // 卖方扣 BTC
 		Amount:   baseAmount,
@@ -367,6 +516,7 @@ func (e *AccountEngine) ApplyFill(fill *FillEvent) error {
 		ToAmount: quoteAmount,         // 收到的 USDT
 		Fee:      fill.SellerFee,      // 手续费
 		FeeAsset: fill.SellerFeeAsset, // 手续费资产
+		Epoch:    fill.Epoch,
 	}
 
 	if err := sellerShard.Submit(sellerCmd, e.config.DefaultTimeout); err != nil {
@@ -378,7 +528,7 @@ func (e *AccountEngine) ApplyFill(fill *FillEvent) error {
 	buyerShard := e.getShard(fill.BuyerID)
 	buyerCmd := Command{
 		Type:     CmdTransfer,
-		CmdID:    fmt.Sprintf("fill_buyer_%d", fill.TradeID),
+		Key:      CmdKey{Kind: CmdKindFillBuyer, ID: fill.TradeID},
 		UserID:   fill.BuyerID,
 		Symbol:   fill.QuoteAsset, // 买方扣 USDT
 		Amount:   quoteAmount,
@@ -387,13 +537,43 @@ func (e *AccountEngine) ApplyFill(fill *FillEvent) error {
 		ToAmount: baseAmount,         // 收到的 BTC
 		Fee:      fill.BuyerFee,      // 手续费
 		FeeAsset: fill.BuyerFeeAsset, // 手续费资产
+		Epoch:    fill.Epoch,
 	}
 
 	if err := buyerShard.Submit(buyerCmd, e.config.DefaultTimeout); err != nil {
-		return fmt.Errorf("buyer transfer failed: %w", err)
+		return e.revertSeller(sellerShard, sellerCmd, fmt.Errorf("buyer transfer failed: %w", err))
 	}
 
-	return nil
+	// ===== 手续费归集 / maker 返佣 =====
+	return e.settleFees(fill, &feeLegs)
+}
+
+// revertSeller 买方腿被拒时撤销已结算的卖方腿，成交要么两边都结算、要么都不动
+//
+// 超时 / 分片关闭 (可重试错误) 时买方腿结果未知，可能已经执行，不能撤销，
+// 交给上游按 TradeID 重放补齐。撤销不带纪元：它只恢复本引擎刚做过的改动
+func (e *AccountEngine) revertSeller(shard *Shard, sellerCmd Command, buyerErr error) error {
+	if cexerr.IsRetryable(buyerErr) {
+		return buyerErr
+	}
+	revert := sellerCmd
+	revert.Type = CmdTransferRevert
+	revert.Epoch = 0
+	if err := shard.Submit(revert, e.config.DefaultTimeout); err != nil {
+		return fmt.Errorf("%w (seller rollback failed: %v)", buyerErr, err)
+	}
+	return buyerErr
+}
+
+// AdvanceEpoch 主备切换时由控制面调用：新主启动前先推进纪元，
+// 之后旧主发来的成交一律返回 ErrStaleEpoch
+func (e *AccountEngine) AdvanceEpoch(epoch uint64) {
+	e.fence.Advance(epoch)
+}
+
+// Epoch 已见过的最大撮合纪元
+func (e *AccountEngine) Epoch() uint64 {
+	return e.fence.Current()
 }
 
 // =============================================================================
@@ -412,17 +592,74 @@ func (e *AccountEngine) GetSnapshot(userID int64) *Snapshot {
 
 // GetAvailable 快速获取可用余额
 //
-// 先尝试从快照读取，如果没有则从分片读取
+// 先尝试从快照读取 (风控快路径)；用户还没有快照 (如刚从 WAL / 检查点恢复) 时
+// 走分片一致性读，而不是当作余额为 0。分片读失败 (超时/已关闭) 时返回 0，偏保守
 func (e *AccountEngine) GetAvailable(userID int64, symbol string) int64 {
-	snap := e.snapshotStore.Get(userID)
-	if snap != nil {
-		if asset, ok := snap.Assets[symbol]; ok {
-			return asset.Available
+	if snap := e.snapshotStore.Get(userID); snap != nil {
+		return snap.Assets[symbol].Available
+	}
+	asset, err := e.QueryBalance(userID, symbol)
+	if err != nil {
+		return 0
+	}
+	return asset.Available
+}
+
+// =============================================================================
+// 查询接口 (一致性读)
+// =============================================================================
+
+// QueryBalance 线性一致地读取资产余额
+//
+// 在分片线程内执行：之前已返回的所有命令都可见。比 GetSnapshot 慢 (一次分片往返)，
+// 用于下单前余额校验、用户资产页等不能读到旧值的场景
+func (e *AccountEngine) QueryBalance(userID int64, symbol string) (Asset, error) {
+	shard := e.getShard(userID)
+	if !e.running.Load() {
+		// 分片线程不存在，直接读
+		if user := shard.GetUser(userID); user != nil {
+			if a, ok := user.Assets[symbol]; ok {
+				return a.Clone(), nil
+			}
+		}
+		return Asset{}, nil
+	}
+	return shard.GetBalance(userID, symbol, e.config.DefaultTimeout)
+}
+
+// QueryUserState 线性一致地读取用户完整状态 (资产 + 持仓)
+func (e *AccountEngine) QueryUserState(userID int64) (*Snapshot, error) {
+	shard := e.getShard(userID)
+	if !e.running.Load() {
+		if user := shard.GetUser(userID); user != nil {
+			return user.CreateSnapshot(), nil
 		}
+		return &Snapshot{UserID: userID, Assets: map[string]Asset{}}, nil
 	}
-	return 0
+	return shard.GetUserState(userID, e.config.DefaultTimeout)
 }
 
+// OwnsUser 用户余额是否在热钱包里 (分片内已有该用户)，满足 fund.DustHotOwner
+//
+// 在热钱包里的用户以热钱包为准，冷库只是回写副本，直接改冷库的操作 (碎币兑换等) 必须避开
+func (e *AccountEngine) OwnsUser(userID int64) (bool, error) {
+	shard := e.getShard(userID)
+	if !e.running.Load() {
+		return shard.GetUser(userID) != nil, nil
+	}
+	out := make(chan bool, 1)
+	err := shard.Query(func(users map[int64]*UserState) {
+		_, ok := users[userID]
+		out <- ok
+	}, e.config.DefaultTimeout)
+	if err != nil {
+		return false, err
+	}
+	return <-out, nil
+}
+
+var _ fund.DustHotOwner = (*AccountEngine)(nil)
+
 // =============================================================================
 // 统计接口
 // =============================================================================
@@ -433,20 +670,33 @@ type EngineStats struct {
 	TotalUsers    int
 	TotalCommands uint64
 	ShardStats    []ShardStats
+	Placement     []ShardPlacement // 各分片线程放置情况
+
+	QueueDepth  [NumPriorities]int // 各优先级排队总数 (下标为 CmdPriority)
+	RateLimited uint64             // 管理/查询类被限速拒绝总数
+
+	FlowRecordErrors int64 // 充值 / 提现流量记录失败次数 (见 recordFundFlow)
 }
 
 // GetStats 获取引擎统计信息
 func (e *AccountEngine) GetStats() EngineStats {
 	stats := EngineStats{
-		TotalShards: len(e.shards),
-		ShardStats:  make([]ShardStats, len(e.shards)),
+		TotalShards:      len(e.shards),
+		ShardStats:       make([]ShardStats, len(e.shards)),
+		Placement:        make([]ShardPlacement, len(e.shards)),
+		FlowRecordErrors: e.flowRecordErrors.Load(),
 	}
 
 	for i, shard := range e.shards {
 		shardStats := shard.GetStats()
 		stats.ShardStats[i] = shardStats
+		stats.Placement[i] = shard.Placement()
 		stats.TotalUsers += shardStats.ActiveUserCount
 		stats.TotalCommands += shardStats.TotalCommands
+		stats.RateLimited += shardStats.RateLimitedCount
+		for p, depth := range shardStats.QueueDepth {
+			stats.QueueDepth[p] += depth
+		}
 	}
 
 	return stats
@@ -463,7 +713,8 @@ type BalanceChangeEvent struct {
 	EventID   string // 幂等键 (如 deposit_id, withdraw_id)
 	UserID    int64
 	Symbol    string
-	Amount    int64 // 金额 (正数)
+	Amount    int64  // 金额 (正数)
+	Address   string // 提现地址 (WITHDRAW 时供风控使用)
 	Timestamp int64
 }
 
@@ -490,17 +741,91 @@ func (e *AccountEngine) ApplyBalanceChange(event *BalanceChangeEvent) error {
 		cmdType = CmdAddBalance
 	} else {
 		cmdType = CmdDeductBalance
+		if e.config.WithdrawDisabled {
+			return ErrWithdrawDisabled
+		}
+		// 提现扣款前检查账户状态；充值已经上链确认，不在这里拦截
+		if e.config.AccountStatus != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), e.config.DefaultTimeout)
+			err := account.CheckWithdraw(ctx, e.config.AccountStatus, event.UserID)
+			cancel()
+			if err != nil {
+				return err
+			}
+		}
+		if err := e.checkWithdrawQuota(event); err != nil {
+			return err
+		}
+		if err := e.checkWithdrawRisk(event); err != nil {
+			return err
+		}
 	}
 
 	cmd := Command{
 		Type:   cmdType,
-		CmdID:  event.EventID,
+		Key:    ParseCmdKey(event.EventID),
 		UserID: event.UserID,
 		Symbol: event.Symbol,
 		Amount: event.Amount,
 	}
 
-	return shard.Submit(cmd, e.config.DefaultTimeout)
+	if err := shard.Submit(cmd, e.config.DefaultTimeout); err != nil {
+		return err
+	}
+	e.recordFundFlow(event)
+	return nil
+}
+
+// recordFundFlow 入账 / 扣款成功后记流量
+//
+// 钱已经动了，记录失败不能回滚，只计数 (EngineStats.FlowRecordErrors)：
+// Redis 里少算的部分在 key 过期重建时由 journal 回填
+func (e *AccountEngine) recordFundFlow(event *BalanceChangeEvent) {
+	if e.config.FundFlow == nil {
+		return
+	}
+	biz := fund.BizTypeWithdraw
+	if event.EventType == "DEPOSIT" {
+		biz = fund.BizTypeDeposit
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.DefaultTimeout)
+	defer cancel()
+	if err := e.config.FundFlow.Record(ctx, event.UserID, event.Symbol, biz, event.Amount, time.Now()); err != nil {
+		e.flowRecordErrors.Add(1)
+	}
+}
+
+// checkWithdrawQuota 提现日限额：先于风控评分，超限的提现不留风控决策
+func (e *AccountEngine) checkWithdrawQuota(event *BalanceChangeEvent) error {
+	if e.config.WithdrawQuota == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.DefaultTimeout)
+	defer cancel()
+	return e.config.WithdrawQuota.Check(ctx, withdrawRequest(event))
+}
+
+func withdrawRequest(event *BalanceChangeEvent) withdrawrisk.Request {
+	return withdrawrisk.Request{
+		EventID:   event.EventID,
+		UserID:    event.UserID,
+		Asset:     event.Symbol,
+		Amount:    event.Amount,
+		Address:   event.Address,
+		Timestamp: event.Timestamp,
+	}
+}
+
+// checkWithdrawRisk 提现风控：决策落库后才放行扣款
+//
+// 重复投递的提现事件会读到同一条决策；风控已放行、扣款命令重复时由分片幂等挡掉
+func (e *AccountEngine) checkWithdrawRisk(event *BalanceChangeEvent) error {
+	if e.config.WithdrawRisk == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.DefaultTimeout)
+	defer cancel()
+	return e.config.WithdrawRisk.Check(ctx, withdrawRequest(event))
 }
 
 // =============================================================================
@@ -515,13 +840,13 @@ func (e *AccountEngine) ApplyBalanceChange(event *BalanceChangeEvent) error {
 // - 发现差异则进入告警/调整流程
 //
 // 注意: 此方法会遍历所有分片，可能较慢，建议在低峰期调用
+// 引擎运行时通过管理/查询优先级的命令读取用户列表，不会挤占成交结算
+// 某个分片查询失败 (限速/超时) 时该分片的用户不在结果中，对账方应比对 TotalUsers
 func (e *AccountEngine) GetAllSnapshots() map[int64]*Snapshot {
 	result := make(map[int64]*Snapshot)
 
 	for _, shard := range e.shards {
-		// 注意: 这里直接访问 shard.users 存在竞态风险
-		// 生产环境应该通过命令队列实现安全访问
-		for userID := range shard.users {
+		for _, userID := range e.shardUserIDs(shard) {
 			if snap := e.snapshotStore.Get(userID); snap != nil {
 				result[userID] = snap
 			}
@@ -531,6 +856,32 @@ func (e *AccountEngine) GetAllSnapshots() map[int64]*Snapshot {
 	return result
 }
 
+// shardUserIDs 分片内的用户列表
+// 运行中走 CmdQuery 在分片线程内读取；未启动时分片线程不存在，直接读
+func (e *AccountEngine) shardUserIDs(shard *Shard) []int64 {
+	listUsers := func(users map[int64]*UserState) []int64 {
+		ids := make([]int64, 0, len(users))
+		for userID := range users {
+			ids = append(ids, userID)
+		}
+		return ids
+	}
+
+	if !e.running.Load() {
+		return listUsers(shard.users)
+	}
+
+	// 结果走 channel 而不是闭包写外部变量：超时后查询仍可能执行
+	out := make(chan []int64, 1)
+	err := shard.Query(func(users map[int64]*UserState) {
+		out <- listUsers(users)
+	}, e.config.DefaultTimeout)
+	if err != nil {
+		return nil
+	}
+	return <-out
+}
+
 // ReconcileResult 对账结果
 type ReconcileResult struct {
 	UserID      int64
@@ -565,6 +916,7 @@ type FillEvent struct {
 	Quantity int64 // 成交数量 (精度 Precision)
 
 	// ===== 手续费 =====
+	// 负数表示 maker 返佣 (资产与 taker 手续费相同，从手续费账户支出)
 	BuyerFee       int64  // 买方手续费
 	BuyerFeeAsset  string // 买方手续费资产 (通常是 BaseAsset)
 	SellerFee      int64  // 卖方手续费
@@ -572,4 +924,7 @@ type FillEvent struct {
 
 	// ===== 时间戳 =====
 	Timestamp int64 // 成交时间
+
+	// ===== 纪元 =====
+	Epoch uint64 // 撮合纪元 (mtrade.Trade.Epoch)，旧纪元的成交会被拒绝
 }
@@ -10,10 +10,17 @@
 package asset
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"max.com/pkg/audit"
+	"max.com/pkg/fund"
+	"max.com/pkg/withdrawrisk"
 )
 
 // =============================================================================
@@ -440,3 +447,624 @@ func BenchmarkEngine_ApplyBalanceChange(b *testing.B) {
 		})
 	}
 }
+
+// TestEngine_ShardPlacement 测试分片线程放置配置
+func TestEngine_ShardPlacement(t *testing.T) {
+	cfg := DefaultEngineConfig()
+	cfg.NumShards = 4
+	cfg.LockOSThread = true
+	cfg.ShardCPUs = [][]int{{0}} // 只给分片 0 绑核，其余只锁线程
+
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	// 放置在分片 goroutine 启动后记录，用一次同步命令确认已运行
+	for i := int64(0); i < int64(cfg.NumShards); i++ {
+		engine.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   fmt.Sprintf("placement_%d", i),
+			UserID:    i,
+			Symbol:    "USDT",
+			Amount:    1,
+		})
+	}
+
+	placement := engine.GetStats().Placement
+	if len(placement) != cfg.NumShards {
+		t.Fatalf("expected %d placements, got %d", cfg.NumShards, len(placement))
+	}
+	for i, p := range placement {
+		if p.ShardID != i || !p.LockedThread {
+			t.Errorf("shard %d: expected locked thread, got %+v", i, p)
+		}
+	}
+	if p := placement[0]; !p.Pinned && p.PinError == nil {
+		t.Errorf("shard 0 should be pinned or report why not: %+v", p)
+	}
+	if placement[1].Pinned || len(placement[1].CPUs) != 0 {
+		t.Errorf("shard 1 should not be pinned: %+v", placement[1])
+	}
+
+	if cpus := SpreadShardsAcrossNUMA(cfg.NumShards); len(cpus) != cfg.NumShards {
+		t.Errorf("expected a cpu set per shard, got %v", cpus)
+	}
+}
+
+// =============================================================================
+// 优先级队列测试
+// =============================================================================
+
+// TestShard_PriorityOrder 冻结 (下单) 优先于充值同步执行
+func TestShard_PriorityOrder(t *testing.T) {
+	shard := NewShard(ShardConfig{ID: 0})
+
+	// 启动前入队：先充值 (管理类) 后冻结 (下单类)
+	shard.Submit(Command{Type: CmdAddBalance, Key: ParseCmdKey("deposit_1"), UserID: 1, Symbol: "USDT", Amount: 100}, 0)
+	shard.Submit(Command{Type: CmdReserve, Key: ParseCmdKey("reserve_1"), UserID: 1, Symbol: "USDT", Amount: 100}, 0)
+
+	if depth := shard.GetStats().QueueDepth; depth != [NumPriorities]int{0, 1, 1} {
+		t.Fatalf("unexpected queue depth %v", depth)
+	}
+
+	shard.Start()
+	defer shard.Stop()
+	if err := shard.Query(func(map[int64]*UserState) {}, time.Second); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	// 冻结先执行，此时还没有余额，被拒绝；FIFO 时会冻结成功
+	asset := shard.GetUser(1).GetAsset("USDT")
+	if asset.Available != 100 || asset.Locked != 0 {
+		t.Errorf("expected reserve to run before deposit, got available=%d locked=%d", asset.Available, asset.Locked)
+	}
+	stats := shard.GetStats()
+	if stats.RejectCount != 1 {
+		t.Errorf("expected 1 rejected reserve, got %d", stats.RejectCount)
+	}
+	if stats.ProcessedByClass != [NumPriorities]uint64{0, 1, 2} {
+		t.Errorf("unexpected processed by class %v", stats.ProcessedByClass)
+	}
+}
+
+// TestEngine_AdminRateLimit 管理/查询类命令限速，不影响下单
+func TestEngine_AdminRateLimit(t *testing.T) {
+	cfg := DefaultEngineConfig()
+	cfg.NumShards = 1
+	cfg.AdminRateLimit = 2
+
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	var errs []error
+	for i := 0; i < 3; i++ {
+		errs = append(errs, engine.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   fmt.Sprintf("deposit_%d", i),
+			UserID:    1,
+			Symbol:    "USDT",
+			Amount:    100,
+		}))
+	}
+	if errs[0] != nil || errs[1] != nil || errs[2] != ErrRateLimited {
+		t.Fatalf("expected third deposit to be rate limited, got %v", errs)
+	}
+
+	// 下单类不受限速影响
+	for i := int64(0); i < 3; i++ {
+		if err := engine.Reserve(1, "USDT", 10, i); err != nil {
+			t.Errorf("reserve %d: %v", i, err)
+		}
+	}
+
+	if got := engine.GetStats().RateLimited; got != 1 {
+		t.Errorf("expected 1 rate limited command, got %d", got)
+	}
+}
+
+// TestEngine_StaleEpochFill 切换后旧撮合实例的成交被拒绝
+func TestEngine_StaleEpochFill(t *testing.T) {
+	engine := NewEngine(DefaultEngineConfig())
+	engine.Start()
+	defer engine.Stop()
+
+	for _, user := range []struct {
+		id     int64
+		symbol string
+		amount int64
+	}{{1, "USDT", 1000 * Precision}, {2, "BTC", 2 * Precision}} {
+		engine.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   fmt.Sprintf("epoch_deposit_%d", user.id),
+			UserID:    user.id,
+			Symbol:    user.symbol,
+			Amount:    user.amount,
+		})
+	}
+	engine.Reserve(1, "USDT", 1000*Precision, 1)
+	engine.Reserve(2, "BTC", 2*Precision, 2)
+
+	fill := func(tradeID int64, epoch uint64) error {
+		return engine.ApplyFill(&FillEvent{
+			TradeID: tradeID, BuyerID: 1, SellerID: 2,
+			BaseAsset: "BTC", QuoteAsset: "USDT",
+			Price: 500 * Precision, Quantity: 1 * Precision,
+			Epoch: epoch,
+		})
+	}
+
+	if err := fill(1, 1); err != nil {
+		t.Fatalf("fill at epoch 1: %v", err)
+	}
+
+	// 控制面切换到纪元 2，旧主 (纪元 1) 的成交被拒
+	engine.AdvanceEpoch(2)
+	if err := fill(2, 1); !errors.Is(err, ErrStaleEpoch) {
+		t.Fatalf("expected ErrStaleEpoch, got %v", err)
+	}
+	if err := fill(3, 2); err != nil {
+		t.Fatalf("fill at epoch 2: %v", err)
+	}
+
+	if snap := engine.GetSnapshot(1); snap.Assets["BTC"].Available != 2*Precision {
+		t.Errorf("expected buyer to receive exactly 2 BTC, got %d", snap.Assets["BTC"].Available)
+	}
+	if engine.Epoch() != 2 {
+		t.Errorf("expected engine epoch 2, got %d", engine.Epoch())
+	}
+}
+
+func TestEngine_MakerRebate(t *testing.T) {
+	const feeAccount = 900
+
+	cfg := DefaultEngineConfig()
+	cfg.FeeAccountID = feeAccount
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	// 买方 1 是 taker，卖方 2 是 maker
+	for _, user := range []struct {
+		id     int64
+		symbol string
+		amount int64
+	}{{1, "USDT", 1000 * Precision}, {2, "BTC", 2 * Precision}} {
+		engine.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   fmt.Sprintf("rebate_deposit_%d", user.id),
+			UserID:    user.id,
+			Symbol:    user.symbol,
+			Amount:    user.amount,
+		})
+	}
+	engine.Reserve(1, "USDT", 1000*Precision, 1)
+	engine.Reserve(2, "BTC", 2*Precision, 2)
+
+	fill := func(tradeID, rebate int64) error {
+		return engine.ApplyFill(&FillEvent{
+			TradeID: tradeID, BuyerID: 1, SellerID: 2,
+			BaseAsset: "BTC", QuoteAsset: "USDT",
+			Price: 500 * Precision, Quantity: 1 * Precision,
+			BuyerFee: 200000, BuyerFeeAsset: "BTC", // taker 0.2%
+			SellerFee: -rebate, SellerFeeAsset: "BTC", // maker 返佣
+		})
+	}
+
+	// 返佣超过 taker 手续费：整笔拒绝，任何余额都不动
+	if err := fill(1, 300000); !errors.Is(err, ErrRebateExceedsFee) {
+		t.Fatalf("expected ErrRebateExceedsFee, got %v", err)
+	}
+	if snap := engine.GetSnapshot(2); snap.Assets["BTC"].Locked != 2*Precision {
+		t.Fatalf("rejected fill must not settle, seller BTC %+v", snap.Assets["BTC"])
+	}
+
+	if err := fill(2, 100000); err != nil {
+		t.Fatalf("fill with rebate: %v", err)
+	}
+	// 重放同一笔成交不会重复返佣
+	if err := fill(2, 100000); err != nil && !errors.Is(err, ErrDuplicateCommand) {
+		t.Fatalf("replay: %v", err)
+	}
+
+	if got := engine.GetSnapshot(1).Assets["BTC"].Available; got != Precision-200000 {
+		t.Errorf("taker BTC: expected %d, got %d", Precision-200000, got)
+	}
+	if got := engine.GetSnapshot(2).Assets["BTC"].Available; got != 100000 {
+		t.Errorf("maker rebate: expected 100000, got %d", got)
+	}
+	if got := engine.GetSnapshot(feeAccount).Assets["BTC"].Available; got != 100000 {
+		t.Errorf("fee account: expected net 100000, got %d", got)
+	}
+
+	// 未配置手续费账户时不支持返佣
+	plain := NewEngine(DefaultEngineConfig())
+	if err := plain.ApplyFill(&FillEvent{TradeID: 1, SellerID: 2, SellerFee: -1, SellerFeeAsset: "BTC"}); !errors.Is(err, ErrNoFeeAccount) {
+		t.Errorf("expected ErrNoFeeAccount, got %v", err)
+	}
+}
+
+// TestEngine_FeeUnaffordable 付不起手续费的成交整笔拒绝，手续费账户不能凭空入账
+func TestEngine_FeeUnaffordable(t *testing.T) {
+	const feeAccount = 900
+
+	cfg := DefaultEngineConfig()
+	cfg.FeeAccountID = feeAccount
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	engine.ApplyBalanceChange(&BalanceChangeEvent{EventType: "DEPOSIT", EventID: "fee_dep_1", UserID: 1, Symbol: "USDT", Amount: 500 * Precision})
+	engine.ApplyBalanceChange(&BalanceChangeEvent{EventType: "DEPOSIT", EventID: "fee_dep_2", UserID: 2, Symbol: "BTC", Amount: 1 * Precision})
+	engine.Reserve(1, "USDT", 500*Precision, 1)
+	engine.Reserve(2, "BTC", 1*Precision, 2)
+
+	// 卖方手续费 600 USDT，收到的 500 USDT 加上原有余额 0 都不够扣
+	err := engine.ApplyFill(&FillEvent{
+		TradeID: 1, BuyerID: 1, SellerID: 2,
+		BaseAsset: "BTC", QuoteAsset: "USDT",
+		Price: 500 * Precision, Quantity: 1 * Precision,
+		SellerFee: 600 * Precision, SellerFeeAsset: "USDT",
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+
+	if snap := engine.GetSnapshot(feeAccount); snap != nil && snap.Assets["USDT"].Available != 0 {
+		t.Errorf("fee account must not be credited, got %d", snap.Assets["USDT"].Available)
+	}
+	if snap := engine.GetSnapshot(2); snap.Assets["BTC"].Locked != 1*Precision || snap.Assets["USDT"].Available != 0 {
+		t.Errorf("seller must be untouched: BTC %+v USDT %+v", snap.Assets["BTC"], snap.Assets["USDT"])
+	}
+	if snap := engine.GetSnapshot(1); snap.Assets["USDT"].Locked != 500*Precision || snap.Assets["BTC"].Available != 0 {
+		t.Errorf("buyer must be untouched: USDT %+v BTC %+v", snap.Assets["USDT"], snap.Assets["BTC"])
+	}
+
+	// 手续费刚好等于收到的金额：允许 (先入账再扣费)
+	if err := engine.ApplyFill(&FillEvent{
+		TradeID: 2, BuyerID: 1, SellerID: 2,
+		BaseAsset: "BTC", QuoteAsset: "USDT",
+		Price: 500 * Precision, Quantity: 1 * Precision,
+		SellerFee: 500 * Precision, SellerFeeAsset: "USDT",
+	}); err != nil {
+		t.Fatalf("fee equal to proceeds: %v", err)
+	}
+	if got := engine.GetSnapshot(feeAccount).Assets["USDT"].Available; got != 500*Precision {
+		t.Errorf("fee account: expected %d, got %d", 500*Precision, got)
+	}
+}
+
+// TestShard_TransferRevertRecover WAL 重放后回滚仍然生效，撤销只能针对已执行的划转
+func TestShard_TransferRevertRecover(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := NewWAL(WALConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shard := NewShard(ShardConfig{WAL: wal})
+	shard.Start()
+
+	transfer := Command{
+		Type: CmdTransfer, Key: CmdKey{Kind: CmdKindFillSeller, ID: 1},
+		UserID: 2, Symbol: "BTC", Amount: 1 * Precision,
+		ToUserID: 2, ToSymbol: "USDT", ToAmount: 500 * Precision,
+		Fee: 1 * Precision, FeeAsset: "USDT",
+	}
+	revert := transfer
+	revert.Type = CmdTransferRevert
+
+	for _, cmd := range []Command{
+		{Type: CmdAddBalance, Key: ParseCmdKey("deposit_1"), UserID: 2, Symbol: "BTC", Amount: 1 * Precision},
+		{Type: CmdReserve, Key: CmdKey{Kind: CmdKindReserve, ID: 1}, UserID: 2, Symbol: "BTC", Amount: 1 * Precision},
+		transfer,
+		revert,
+	} {
+		if err := shard.Submit(cmd, time.Second); err != nil {
+			t.Fatalf("%s: %v", cmd.Type, err)
+		}
+	}
+	shard.Stop()
+	wal.Close()
+
+	wal, err = NewWAL(WALConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	restarted := NewShard(ShardConfig{WAL: wal})
+	if _, err := restarted.RecoverFromWAL(); err != nil {
+		t.Fatal(err)
+	}
+	restarted.Start()
+	defer restarted.Stop()
+
+	user := restarted.GetUser(2)
+	if btc, usdt := user.Assets["BTC"], user.Assets["USDT"]; btc.Locked != 1*Precision || usdt.Available != 0 {
+		t.Fatalf("recovered state: BTC %+v USDT %+v", *btc, *usdt)
+	}
+	// 重放时撤销同样删除了幂等键: 不能再撤一次，同一笔成交可以重新结算
+	if err := restarted.Submit(revert, time.Second); !errors.Is(err, ErrRevertNotApplied) {
+		t.Fatalf("revert twice: %v", err)
+	}
+	if err := restarted.Submit(transfer, time.Second); err != nil {
+		t.Fatalf("re-settle after revert: %v", err)
+	}
+	if usdt := restarted.GetUser(2).Assets["USDT"]; usdt.Available != 499*Precision {
+		t.Errorf("re-settled USDT %+v", *usdt)
+	}
+}
+
+// coldDustLedger 只有冷库余额的碎币账本，记录是否被写过
+type coldDustLedger struct {
+	balances []*fund.BalanceRecord
+	applied  int
+}
+
+func (l *coldDustLedger) GetBalances(context.Context, int64) ([]*fund.BalanceRecord, error) {
+	return l.balances, nil
+}
+func (l *coldDustLedger) DustConverted(context.Context, int64, string, string) (bool, error) {
+	return false, nil
+}
+func (l *coldDustLedger) DustConversionsSince(context.Context, int64, time.Time) (int, error) {
+	return 0, nil
+}
+func (l *coldDustLedger) ApplyDustConversion(context.Context, *fund.DustConversion, int64) error {
+	l.applied++
+	return nil
+}
+
+// TestEngine_DustConversionConflict 热钱包持有的用户不能走冷库碎币兑换 (回写会覆盖兑换结果)
+func TestEngine_DustConversionConflict(t *testing.T) {
+	engine := NewEngine(DefaultEngineConfig())
+	engine.Start()
+	defer engine.Stop()
+
+	ledger := &coldDustLedger{balances: []*fund.BalanceRecord{{UserID: 1, Symbol: "DOGE", Available: Precision}}}
+	converter, err := fund.NewDustConverter(ledger,
+		fund.DustPriceFunc(func(string, string) int64 { return Precision / 10 }),
+		fund.DustConfig{TargetAsset: "USDT", Threshold: Precision, TreasuryAccountID: 900, HotOwner: engine})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if owned, err := engine.OwnsUser(1); err != nil || owned {
+		t.Fatalf("empty engine owns user 1: %v %v", owned, err)
+	}
+	if _, err := converter.Convert(context.Background(), fund.DustRequest{RequestID: "r1", UserID: 1}); err != nil {
+		t.Fatalf("cold-only user: %v", err)
+	}
+
+	engine.ApplyBalanceChange(&BalanceChangeEvent{EventType: "DEPOSIT", EventID: "dust_dep_1", UserID: 1, Symbol: "DOGE", Amount: Precision})
+	if owned, err := engine.OwnsUser(1); err != nil || !owned {
+		t.Fatalf("engine should own user 1: %v %v", owned, err)
+	}
+	_, err = converter.Convert(context.Background(), fund.DustRequest{RequestID: "r2", UserID: 1})
+	if !errors.Is(err, fund.ErrDustHotOwned) {
+		t.Fatalf("expected ErrDustHotOwned, got %v", err)
+	}
+	if ledger.applied != 1 {
+		t.Errorf("cold ledger written %d times, want 1", ledger.applied)
+	}
+	if got := engine.GetSnapshot(1).Assets["DOGE"].Available; got != Precision {
+		t.Errorf("hot DOGE %d", got)
+	}
+}
+
+func TestEngine_AuditBalanceChange(t *testing.T) {
+	store := audit.NewMemoryStore()
+	auditor, err := audit.NewLogger(context.Background(), store, audit.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultEngineConfig()
+	cfg.Auditor = auditor
+	engine := NewEngine(cfg)
+	engine.Start()
+
+	engine.ApplyBalanceChange(&BalanceChangeEvent{
+		EventType: "DEPOSIT", EventID: "audit_deposit", UserID: 1, Symbol: "USDT", Amount: 100,
+	})
+	engine.Reserve(1, "USDT", 40, 1)
+	engine.Reserve(1, "USDT", 1000, 2) // 余额不足，不记录
+	engine.Stop()
+	auditor.Close()
+
+	entries, _ := store.Query(context.Background(), audit.Query{ResourcePrefix: "balance:1:USDT"})
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 balance changes, got %d", len(entries))
+	}
+	if string(entries[1].Before) != `{"available":100,"locked":0}` ||
+		string(entries[1].After) != `{"available":60,"locked":40}` ||
+		!strings.Contains(string(entries[1].Meta), `"cmd":"RESERVE"`) {
+		t.Errorf("unexpected reserve audit %s -> %s %s", entries[1].Before, entries[1].After, entries[1].Meta)
+	}
+	if _, _, err := audit.VerifyStore(context.Background(), store); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEngine_PayFromFeeAccount(t *testing.T) {
+	const feeAccount = 900
+
+	cfg := DefaultEngineConfig()
+	cfg.FeeAccountID = feeAccount
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	engine.ApplyBalanceChange(&BalanceChangeEvent{
+		EventType: "DEPOSIT", EventID: "fee_seed", UserID: feeAccount, Symbol: "USDT", Amount: 100,
+	})
+
+	if err := engine.PayFromFeeAccount("pay_1", 1, "USDT", 60); err != nil {
+		t.Fatalf("payout: %v", err)
+	}
+	// 重试同一个 payID 不会重复付款
+	if err := engine.PayFromFeeAccount("pay_1", 1, "USDT", 60); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	// 手续费账户余额不足
+	if err := engine.PayFromFeeAccount("pay_2", 1, "USDT", 60); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+
+	if got := engine.GetAvailable(1, "USDT"); got != 60 {
+		t.Errorf("user: expected 60, got %d", got)
+	}
+	if got := engine.GetAvailable(feeAccount, "USDT"); got != 40 {
+		t.Errorf("fee account: expected 40, got %d", got)
+	}
+}
+
+func TestEngine_WithdrawRiskHold(t *testing.T) {
+	risk := withdrawrisk.NewEngine(withdrawrisk.NewMemoryStore(),
+		&withdrawrisk.LargeAmountRule{Thresholds: map[string]int64{"USDT": 1000}})
+
+	cfg := DefaultEngineConfig()
+	cfg.WithdrawRisk = risk
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	engine.ApplyBalanceChange(&BalanceChangeEvent{
+		EventType: "DEPOSIT", EventID: "risk_deposit", UserID: 1, Symbol: "USDT", Amount: 5000,
+	})
+	withdraw := &BalanceChangeEvent{
+		EventType: "WITHDRAW", EventID: "risk_withdraw", UserID: 1, Symbol: "USDT", Amount: 2000, Address: "addr1",
+	}
+	if err := engine.ApplyBalanceChange(withdraw); !errors.Is(err, withdrawrisk.ErrHeld) {
+		t.Fatalf("expected held, got %v", err)
+	}
+	if got := engine.GetAvailable(1, "USDT"); got != 5000 {
+		t.Fatalf("held withdrawal must not deduct, available %d", got)
+	}
+
+	if err := risk.Review(context.Background(), "risk_withdraw", true, "ops"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ApplyBalanceChange(withdraw); err != nil {
+		t.Fatalf("approved withdrawal: %v", err)
+	}
+	if got := engine.GetAvailable(1, "USDT"); got != 3000 {
+		t.Errorf("expected 3000 after withdrawal, got %d", got)
+	}
+}
+
+type fixedLevels int
+
+func (l fixedLevels) KYCLevel(ctx context.Context, userID int64) (int, error) { return int(l), nil }
+
+func TestEngine_WithdrawQuota(t *testing.T) {
+	flows := fund.NewMemoryFlowCounter(fund.FlowConfig{})
+	quota, err := withdrawrisk.NewQuota(withdrawrisk.QuotaConfig{
+		Tiers:  []withdrawrisk.QuotaTier{{Level: 1, QuotaLimits: withdrawrisk.QuotaLimits{MaxAmount: map[string]int64{"USDT": 3000}}}},
+		Levels: fixedLevels(1),
+		Flows:  flows,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultEngineConfig()
+	cfg.WithdrawQuota = quota
+	cfg.FundFlow = flows
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	engine.ApplyBalanceChange(&BalanceChangeEvent{
+		EventType: "DEPOSIT", EventID: "quota_deposit", UserID: 1, Symbol: "USDT", Amount: 10000,
+	})
+	withdraw := func(id string, amount int64) error {
+		return engine.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "WITHDRAW", EventID: id, UserID: 1, Symbol: "USDT", Amount: amount,
+		})
+	}
+	if err := withdraw("quota_w1", 2000); err != nil {
+		t.Fatalf("first withdrawal: %v", err)
+	}
+	if err := withdraw("quota_w2", 1500); !errors.Is(err, withdrawrisk.ErrQuotaAmountExceeded) {
+		t.Fatalf("expected quota exceeded, got %v", err)
+	}
+	if got := engine.GetAvailable(1, "USDT"); got != 8000 {
+		t.Errorf("rejected withdrawal must not deduct, available %d", got)
+	}
+
+	ctx := context.Background()
+	deposits, _ := flows.Usage(ctx, 1, "USDT", fund.BizTypeDeposit, time.Now())
+	withdrawals, _ := flows.Usage(ctx, 1, "USDT", fund.BizTypeWithdraw, time.Now())
+	if deposits != (fund.FlowUsage{Amount: 10000, Count: 1}) || withdrawals != (fund.FlowUsage{Amount: 2000, Count: 1}) {
+		t.Errorf("flows: deposits %+v, withdrawals %+v", deposits, withdrawals)
+	}
+}
+
+// TestEngine_ConsistentReadAfterRecovery 恢复后还没有快照，读余额走分片而不是返回 0
+func TestEngine_ConsistentReadAfterRecovery(t *testing.T) {
+	cfg := DefaultEngineConfig()
+	cfg.WALDir = t.TempDir()
+
+	engine := NewEngine(cfg)
+	engine.Start()
+	if err := engine.ApplyBalanceChange(&BalanceChangeEvent{
+		EventType: "DEPOSIT", EventID: "deposit_1", UserID: 7, Symbol: "USDT", Amount: 500,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reserve(7, "USDT", 200, 1); err != nil {
+		t.Fatal(err)
+	}
+	engine.Stop()
+
+	recovered := NewEngine(cfg)
+	if err := recovered.RecoverAll(); err != nil {
+		t.Fatal(err)
+	}
+	recovered.Start()
+	defer recovered.Stop()
+
+	if snap := recovered.GetSnapshot(7); snap != nil {
+		t.Fatalf("expected no snapshot after recovery, got %+v", snap)
+	}
+	if got := recovered.GetAvailable(7, "USDT"); got != 300 {
+		t.Fatalf("GetAvailable = %d, want 300", got)
+	}
+	bal, err := recovered.QueryBalance(7, "USDT")
+	if err != nil || bal.Available != 300 || bal.Locked != 200 {
+		t.Fatalf("QueryBalance = %+v, %v", bal, err)
+	}
+	state, err := recovered.QueryUserState(7)
+	if err != nil || state.Assets["USDT"].Locked != 200 {
+		t.Fatalf("QueryUserState = %+v, %v", state, err)
+	}
+
+	// 未知用户不懒创建
+	if bal, err := recovered.QueryBalance(8, "USDT"); err != nil || bal.Available != 0 {
+		t.Fatalf("unknown user: %+v, %v", bal, err)
+	}
+	if users := recovered.GetStats().TotalUsers; users != 1 {
+		t.Fatalf("read created a user: %d users", users)
+	}
+}
+
+// TestShard_ReadSeesPriorCommands 读命令排在之前提交的写命令之后
+func TestShard_ReadSeesPriorCommands(t *testing.T) {
+	shard := NewShard(ShardConfig{ID: 0})
+	shard.Start()
+	defer shard.Stop()
+
+	for i := 0; i < 100; i++ {
+		shard.Submit(Command{Type: CmdAddBalance, Key: ParseCmdKey(fmt.Sprintf("d%d", i)), UserID: 1, Symbol: "BTC", Amount: 1}, time.Second)
+		if err := shard.Submit(Command{Type: CmdReserve, Key: ParseCmdKey(fmt.Sprintf("r%d", i)), UserID: 1, Symbol: "BTC", Amount: 1}, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		bal, err := shard.GetBalance(1, "BTC", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bal.Locked != int64(i+1) {
+			t.Fatalf("round %d: locked %d", i, bal.Locked)
+		}
+	}
+}
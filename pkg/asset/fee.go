@@ -0,0 +1,194 @@
+// 文件: pkg/asset/fee.go
+// 成交手续费归集与 maker 返佣
+//
+// 【资金流向】
+//
+//	taker ──手续费──→ 手续费收入账户 (EngineConfig.FeeAccountID)
+//	                        │
+//	maker ←──返佣───────────┘
+//
+// FillEvent 中 BuyerFee / SellerFee 为负数表示返佣，返佣资产与 taker 手续费资产相同。
+// 同一笔成交先归集手续费再支付返佣，所以每笔返佣都有足额来源，
+// 不会出现"先垫付、后补收"导致手续费账户为负。
+//
+// 【面试】返佣为什么必须逐笔校验，而不是只校验费率？
+// 费率层面 maker 返佣率 <= taker 费率只保证"名义上"不亏，
+// 逐笔计算会有取整误差，且手续费和返佣可能按不同资产计价；
+// 逐笔保证 返佣 <= 同资产已收 taker 手续费，平台在任何一笔成交上都不会倒贴。
+
+package asset
+
+import (
+	"errors"
+	"fmt"
+
+	"max.com/pkg/cexerr"
+)
+
+var (
+	// ErrRebateExceedsFee 返佣超过同一笔成交收取的 taker 手续费
+	ErrRebateExceedsFee = cexerr.New("ASSET_REBATE_EXCEEDS_FEE", cexerr.CategoryFailedPrecondition, "maker rebate exceeds collected taker fee")
+	// ErrNoFeeAccount 未配置手续费收入账户，无法支付返佣
+	ErrNoFeeAccount = cexerr.New("ASSET_NO_FEE_ACCOUNT", cexerr.CategoryFailedPrecondition, "fee account not configured, rebates unsupported")
+	// ErrInvalidPayout 付款金额或收款人不合法
+	ErrInvalidPayout = cexerr.New("ASSET_INVALID_PAYOUT", cexerr.CategoryInvalidArgument, "invalid fee account payout")
+)
+
+// feeLeg 一笔成交中某个资产的手续费收支
+type feeLeg struct {
+	asset     string
+	collected int64     // 收取的手续费
+	rebates   [2]rebate // 一笔成交只有买卖两方，定长避免分配
+	nRebates  int
+}
+
+// rebate 一笔返佣
+type rebate struct {
+	userID int64
+	amount int64 // 正数
+}
+
+// feeLegs 一笔成交的手续费收支，最多两种资产 (买卖双方各一种)
+// 定长数组放在栈上，热路径上每笔成交不分配
+type feeLegs struct {
+	legs [2]feeLeg
+	n    int
+}
+
+// collectFeeLegs 按资产汇总手续费与返佣，并校验返佣不超过已收手续费
+func (e *AccountEngine) collectFeeLegs(fill *FillEvent) (feeLegs, error) {
+	var out feeLegs
+	add := func(userID, fee int64, feeAsset string) {
+		if fee == 0 || feeAsset == "" {
+			return
+		}
+		i := 0
+		for i < out.n && out.legs[i].asset != feeAsset {
+			i++
+		}
+		leg := &out.legs[i]
+		if i == out.n {
+			leg.asset = feeAsset
+			out.n++
+		}
+		if fee > 0 {
+			leg.collected += fee
+		} else {
+			leg.rebates[leg.nRebates] = rebate{userID: userID, amount: -fee}
+			leg.nRebates++
+		}
+	}
+	add(fill.SellerID, fill.SellerFee, fill.SellerFeeAsset)
+	add(fill.BuyerID, fill.BuyerFee, fill.BuyerFeeAsset)
+
+	for i := range out.n {
+		leg := &out.legs[i]
+		var paid int64
+		for _, r := range leg.rebates[:leg.nRebates] {
+			paid += r.amount
+		}
+		if paid > 0 && e.config.FeeAccountID == 0 {
+			return feeLegs{}, ErrNoFeeAccount
+		}
+		if paid > leg.collected {
+			return feeLegs{}, fmt.Errorf("%w: trade %d %s rebate %d > fee %d",
+				ErrRebateExceedsFee, fill.TradeID, leg.asset, paid, leg.collected)
+		}
+	}
+	// 固定顺序，便于排查
+	if out.n == 2 && out.legs[1].asset < out.legs[0].asset {
+		out.legs[0], out.legs[1] = out.legs[1], out.legs[0]
+	}
+	return out, nil
+}
+
+// settleFees 手续费入账 → 返佣出账 → 返佣入账 maker
+// 每一步都有独立的幂等键，结算重试时已完成的步骤会被跳过
+func (e *AccountEngine) settleFees(fill *FillEvent, legs *feeLegs) error {
+	if e.config.FeeAccountID == 0 {
+		// 未配置手续费账户：手续费只从用户侧扣除 (兼容旧行为)
+		return nil
+	}
+	feeShard := e.getShard(e.config.FeeAccountID)
+
+	for i := range legs.n {
+		leg := &legs.legs[i]
+		if leg.collected > 0 {
+			err := feeShard.Submit(Command{
+				Type:   CmdFeeSettle,
+				Key:    CmdKey{Kind: CmdKindFee, ID: fill.TradeID, Name: leg.asset},
+				UserID: e.config.FeeAccountID,
+				Symbol: leg.asset,
+				Amount: leg.collected,
+				Epoch:  fill.Epoch,
+			}, e.config.DefaultTimeout)
+			if err != nil {
+				return fmt.Errorf("collect fee failed: %w", err)
+			}
+		}
+
+		for _, r := range leg.rebates[:leg.nRebates] {
+			err := feeShard.Submit(Command{
+				Type:   CmdFeeSettle,
+				Key:    CmdKey{Kind: CmdKindRebateOut, ID: fill.TradeID, UserID: r.userID, Name: leg.asset},
+				UserID: e.config.FeeAccountID,
+				Symbol: leg.asset,
+				Amount: -r.amount,
+				Epoch:  fill.Epoch,
+			}, e.config.DefaultTimeout)
+			if err != nil {
+				return fmt.Errorf("debit rebate from fee account failed: %w", err)
+			}
+
+			err = e.getShard(r.userID).Submit(Command{
+				Type:   CmdFeeSettle,
+				Key:    CmdKey{Kind: CmdKindRebate, ID: fill.TradeID, UserID: r.userID, Name: leg.asset},
+				UserID: r.userID,
+				Symbol: leg.asset,
+				Amount: r.amount,
+				Epoch:  fill.Epoch,
+			}, e.config.DefaultTimeout)
+			if err != nil {
+				return fmt.Errorf("credit rebate to maker failed: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// PayFromFeeAccount 从手续费收入账户付款给用户 (推荐返佣、活动奖励等)
+//
+// payID 是幂等键：先从手续费账户出账 ({payID}_out)，再入账用户 ({payID})，
+// 中途失败用同一个 payID 重试，已完成的一步返回 ErrDuplicateCommand，按成功处理。
+// 手续费账户余额不足时返回 ErrInsufficientBalance，用户侧不动
+func (e *AccountEngine) PayFromFeeAccount(payID string, userID int64, symbol string, amount int64) error {
+	if e.config.FeeAccountID == 0 {
+		return ErrNoFeeAccount
+	}
+	if payID == "" || amount <= 0 || userID == e.config.FeeAccountID {
+		return ErrInvalidPayout
+	}
+
+	err := e.getShard(e.config.FeeAccountID).Submit(Command{
+		Type:   CmdFeeSettle,
+		Key:    ParseCmdKey(payID + "_out"),
+		UserID: e.config.FeeAccountID,
+		Symbol: symbol,
+		Amount: -amount,
+	}, e.config.DefaultTimeout)
+	if err != nil && !errors.Is(err, ErrDuplicateCommand) {
+		return fmt.Errorf("debit fee account failed: %w", err)
+	}
+
+	err = e.getShard(userID).Submit(Command{
+		Type:   CmdFeeSettle,
+		Key:    ParseCmdKey(payID),
+		UserID: userID,
+		Symbol: symbol,
+		Amount: amount,
+	}, e.config.DefaultTimeout)
+	if err != nil && !errors.Is(err, ErrDuplicateCommand) {
+		return fmt.Errorf("credit payout to user failed: %w", err)
+	}
+	return nil
+}
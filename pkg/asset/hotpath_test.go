@@ -0,0 +1,160 @@
+// 文件: pkg/asset/hotpath_test.go
+// 成交结算热路径：幂等键格式与分配次数
+
+package asset
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCmdKey_TextRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		key  CmdKey
+		text string
+	}{
+		{CmdKey{Kind: CmdKindReserve, ID: 1001}, "reserve_1001"},
+		{CmdKey{Kind: CmdKindRelease, ID: 1001}, "release_1001"},
+		{CmdKey{Kind: CmdKindFillSeller, ID: 12345}, "fill_seller_12345"},
+		{CmdKey{Kind: CmdKindFillBuyer, ID: 12345}, "fill_buyer_12345"},
+		{CmdKey{Kind: CmdKindFee, ID: 7, Name: "USDT"}, "fill_fee_7_USDT"},
+		{CmdKey{Kind: CmdKindRebateOut, ID: 7, UserID: 100, Name: "USDT"}, "fill_rebate_out_7_100_USDT"},
+		{CmdKey{Kind: CmdKindRebate, ID: 7, UserID: 100, Name: "BTC_OLD"}, "fill_rebate_7_100_BTC_OLD"},
+		{CmdKey{Kind: CmdKindExternal, Name: "deposit_12345"}, "deposit_12345"},
+		{CmdKey{Kind: CmdKindExternal, Name: "reserve_01"}, "reserve_01"}, // 前导零不是内部格式
+		{CmdKey{Kind: CmdKindExternal, Name: "fill_fee_7_"}, "fill_fee_7_"},
+	} {
+		if got := tc.key.String(); got != tc.text {
+			t.Errorf("%+v.String() = %q, want %q", tc.key, got, tc.text)
+		}
+		if got := ParseCmdKey(tc.text); got != tc.key {
+			t.Errorf("ParseCmdKey(%q) = %+v, want %+v", tc.text, got, tc.key)
+		}
+	}
+	if !ParseCmdKey("").IsZero() {
+		t.Error("empty text should parse to zero key")
+	}
+}
+
+// TestShard_RecoverTypedKeys WAL 里的文本键重放后仍能挡住重复命令
+func TestShard_RecoverTypedKeys(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := NewWAL(WALConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shard := NewShard(ShardConfig{WAL: wal})
+	shard.Start()
+	if err := shard.Submit(Command{Type: CmdAddBalance, Key: ParseCmdKey("deposit_1"), UserID: 1, Symbol: "USDT", Amount: 100}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := shard.Submit(Command{Type: CmdReserve, Key: CmdKey{Kind: CmdKindReserve, ID: 9}, UserID: 1, Symbol: "USDT", Amount: 40}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	shard.Stop()
+	wal.Close()
+
+	wal, err = NewWAL(WALConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	restarted := NewShard(ShardConfig{WAL: wal})
+	if _, err := restarted.RecoverFromWAL(); err != nil {
+		t.Fatal(err)
+	}
+	restarted.Start()
+	defer restarted.Stop()
+
+	err = restarted.Submit(Command{Type: CmdReserve, Key: CmdKey{Kind: CmdKindReserve, ID: 9}, UserID: 1, Symbol: "USDT", Amount: 40}, time.Second)
+	if err != ErrDuplicateCommand {
+		t.Fatalf("replayed reserve: %v, want ErrDuplicateCommand", err)
+	}
+	if a := restarted.GetUser(1).Assets["USDT"]; a.Available != 60 || a.Locked != 40 {
+		t.Fatalf("recovered balance %+v", *a)
+	}
+}
+
+// =============================================================================
+// 分配次数
+// =============================================================================
+
+// maxAllocsPerFill 每笔成交的分配上限
+//
+// 一笔带手续费的成交是 4 条命令 (买卖双方划转 + 两种资产的手续费归集)，
+// 剩下的分配都在快照发布上：每条命令生成一份快照 (Snapshot + Assets map + Asset)，
+// SnapshotStore 写时复制 (3 次)，共 4 × 6。幂等键、WAL 编码、结果通道、定时器都不再分配
+const maxAllocsPerFill = 24
+
+func newFillBenchEngine(tb testing.TB, walDir string) (*AccountEngine, func(tradeID int64) *FillEvent) {
+	cfg := DefaultEngineConfig()
+	cfg.NumShards = 2
+	cfg.WALDir = walDir
+	cfg.FeeAccountID = 99
+	engine := NewEngine(cfg)
+	if err := engine.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(engine.Stop)
+
+	const buyer, seller = 1, 2
+	const fills = 1 << 24
+	engine.ApplyBalanceChange(&BalanceChangeEvent{EventType: "DEPOSIT", EventID: "bench_usdt", UserID: buyer, Symbol: "USDT", Amount: fills * 1000})
+	engine.ApplyBalanceChange(&BalanceChangeEvent{EventType: "DEPOSIT", EventID: "bench_btc", UserID: seller, Symbol: "BTC", Amount: fills * 10})
+	if err := engine.Reserve(buyer, "USDT", fills*1000, 1); err != nil {
+		tb.Fatal(err)
+	}
+	if err := engine.Reserve(seller, "BTC", fills*10, 2); err != nil {
+		tb.Fatal(err)
+	}
+
+	fill := &FillEvent{
+		BuyerID: buyer, SellerID: seller,
+		BaseAsset: "BTC", QuoteAsset: "USDT",
+		Price: 100 * Precision, Quantity: 10,
+		BuyerFee: 1, BuyerFeeAsset: "BTC",
+		SellerFee: 10, SellerFeeAsset: "USDT",
+	}
+	return engine, func(tradeID int64) *FillEvent {
+		fill.TradeID = tradeID
+		return fill
+	}
+}
+
+func TestApplyFill_Allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+	for _, tc := range []struct {
+		name   string
+		walDir string
+	}{
+		{"memory", ""},
+		{"wal", t.TempDir()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			engine, next := newFillBenchEngine(t, tc.walDir)
+			var tradeID int64
+			allocs := testing.AllocsPerRun(1000, func() {
+				tradeID++
+				if err := engine.ApplyFill(next(tradeID)); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs > maxAllocsPerFill {
+				t.Fatalf("ApplyFill allocs/op = %.1f, want <= %d", allocs, maxAllocsPerFill)
+			}
+		})
+	}
+}
+
+func BenchmarkEngine_ApplyFill(b *testing.B) {
+	engine, next := newFillBenchEngine(b, b.TempDir())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := engine.ApplyFill(next(int64(i + 1))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,827 @@
+// 文件: pkg/asset/invariant_test.go
+// 热钱包账户引擎 - 账本不变量性质测试 (Property-Based Invariant Testing)
+//
+// 测试思路:
+// 1. 随机生成一串操作: 充值/提现/下单冻结/成交/撤单/资金费/强平
+// 2. 同时喂给真实引擎和一个纯内存参考模型 (ledgerModel)
+// 3. 断言账本守恒定律:
+//    - 每个资产: Σ用户(可用+冻结) + 保险基金 + 手续费 == 净充值 (充值 - 提现)
+//    - 任何用户 Available >= 0, Locked >= 0
+//    - 每一步引擎返回的错误与参考模型一致
+//    - 最终引擎状态与参考模型逐账户一致
+// 4. 失败时自动收缩 (shrink) 到最小复现序列，打印出来方便写回归用例
+//
+// 【设计】
+// - 操作本身携带全部参数 (金额/价格/数量)，不依赖生成时的上下文
+//   这样收缩时删掉任意操作，剩下的序列仍然可以独立重放
+// - 资金费、强平在资产引擎层面就是 "扣 A 加 B"，用 Deduct + Add 模拟
+// - 保险基金用一个系统账户 invInsuranceUserID 表示
+// - 引擎未配置手续费账户，手续费直接从可用余额扣除 (不入任何账户)，由参考模型记账
+// - 引擎开启回写 (write-behind)，结束时把冷库 (资金库侧) 余额与热钱包逐条对比
+
+package asset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// 操作定义
+// =============================================================================
+
+const (
+	invBase  = "BTC"
+	invQuote = "USDT"
+
+	// invInsuranceUserID 保险基金系统账户
+	invInsuranceUserID = int64(9999)
+
+	// 手续费率 (万分比)
+	invMakerFeeRate = 10
+	invTakerFeeRate = 20
+)
+
+// invOpKind 操作类型
+type invOpKind uint8
+
+const (
+	invDeposit   invOpKind = iota + 1 // 充值
+	invWithdraw                       // 提现
+	invPlace                          // 下单冻结
+	invCancel                         // 撤单解冻
+	invFill                           // 成交结算
+	invFunding                        // 资金费划转 (UserID 付给 PeerID)
+	invLiquidate                      // 强平 (UserID 的可用余额划入保险基金)
+)
+
+func (k invOpKind) String() string {
+	switch k {
+	case invDeposit:
+		return "DEPOSIT"
+	case invWithdraw:
+		return "WITHDRAW"
+	case invPlace:
+		return "PLACE"
+	case invCancel:
+		return "CANCEL"
+	case invFill:
+		return "FILL"
+	case invFunding:
+		return "FUNDING"
+	case invLiquidate:
+		return "LIQUIDATE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// invOp 一次账本操作
+//
+// 字段含义随 Kind 变化:
+//   - DEPOSIT/WITHDRAW: UserID, Asset, Amount
+//   - PLACE/CANCEL:     UserID, OrderID, Asset (冻结资产), Amount (冻结/解冻金额)
+//   - FILL:             UserID=买方, PeerID=卖方, Price, Qty, BuyerFee, SellerFee
+//   - FUNDING:          UserID=付款方, PeerID=收款方, Asset, Amount
+//   - LIQUIDATE:        UserID=被强平用户, PeerID=保险基金, Asset, Amount
+type invOp struct {
+	Kind      invOpKind
+	ID        int64 // 操作序号 (用于生成幂等键 / 成交 ID)
+	UserID    int64
+	PeerID    int64
+	OrderID   int64
+	Asset     string
+	Amount    int64
+	Price     int64
+	Qty       int64
+	BuyerFee  int64
+	SellerFee int64
+}
+
+func (op invOp) String() string {
+	switch op.Kind {
+	case invPlace, invCancel:
+		return fmt.Sprintf("%s id=%d user=%d order=%d %s=%d",
+			op.Kind, op.ID, op.UserID, op.OrderID, op.Asset, op.Amount)
+	case invFill:
+		return fmt.Sprintf("%s id=%d buyer=%d seller=%d price=%d qty=%d buyerFee=%d sellerFee=%d",
+			op.Kind, op.ID, op.UserID, op.PeerID, op.Price, op.Qty, op.BuyerFee, op.SellerFee)
+	case invFunding, invLiquidate:
+		return fmt.Sprintf("%s id=%d from=%d to=%d %s=%d",
+			op.Kind, op.ID, op.UserID, op.PeerID, op.Asset, op.Amount)
+	default:
+		return fmt.Sprintf("%s id=%d user=%d %s=%d",
+			op.Kind, op.ID, op.UserID, op.Asset, op.Amount)
+	}
+}
+
+// formatLedgerOps 格式化操作序列 (用于打印复现用例)
+func formatLedgerOps(ops []invOp) string {
+	var sb strings.Builder
+	for i, op := range ops {
+		fmt.Fprintf(&sb, "  %3d: %s\n", i, op)
+	}
+	return sb.String()
+}
+
+// =============================================================================
+// 参考模型 - 与 Shard 相同的语义，但是纯同步实现
+// =============================================================================
+
+// ledgerModel 账本参考模型
+type ledgerModel struct {
+	users   map[int64]map[string]*Asset
+	applied map[string]struct{}
+
+	// 外部资金流 & 手续费 (按资产)
+	deposits    map[string]int64
+	withdrawals map[string]int64
+	fees        map[string]int64
+}
+
+func newLedgerModel() *ledgerModel {
+	return &ledgerModel{
+		users:       make(map[int64]map[string]*Asset),
+		applied:     make(map[string]struct{}),
+		deposits:    make(map[string]int64),
+		withdrawals: make(map[string]int64),
+		fees:        make(map[string]int64),
+	}
+}
+
+func (m *ledgerModel) getOrCreate(userID int64) map[string]*Asset {
+	u, ok := m.users[userID]
+	if !ok {
+		u = make(map[string]*Asset)
+		m.users[userID] = u
+	}
+	return u
+}
+
+func (m *ledgerModel) asset(userID int64, symbol string) *Asset {
+	u := m.getOrCreate(userID)
+	a, ok := u[symbol]
+	if !ok {
+		a = &Asset{}
+		u[symbol] = a
+	}
+	return a
+}
+
+// run 幂等检查 + 执行 + 记录幂等键 (对应 Shard.handleCommand)
+func (m *ledgerModel) run(cmdID string, fn func() error) error {
+	if _, ok := m.applied[cmdID]; ok {
+		return ErrDuplicateCommand
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	m.applied[cmdID] = struct{}{}
+	return nil
+}
+
+func (m *ledgerModel) add(cmdID string, userID int64, symbol string, amount int64) error {
+	return m.run(cmdID, func() error {
+		m.asset(userID, symbol).Available += amount
+		return nil
+	})
+}
+
+func (m *ledgerModel) deduct(cmdID string, userID int64, symbol string, amount int64) error {
+	return m.run(cmdID, func() error {
+		if _, ok := m.users[userID]; !ok {
+			return ErrUserNotFound
+		}
+		a := m.asset(userID, symbol)
+		if a.Available < amount {
+			return ErrInsufficientBalance
+		}
+		a.Available -= amount
+		return nil
+	})
+}
+
+func (m *ledgerModel) reserve(cmdID string, userID int64, symbol string, amount int64) error {
+	return m.run(cmdID, func() error {
+		a := m.asset(userID, symbol) // 与 doReserve 一致: 失败也会创建用户
+		if a.Available < amount {
+			return ErrInsufficientBalance
+		}
+		a.Available -= amount
+		a.Locked += amount
+		return nil
+	})
+}
+
+func (m *ledgerModel) release(cmdID string, userID int64, symbol string, amount int64) error {
+	return m.run(cmdID, func() error {
+		if _, ok := m.users[userID]; !ok {
+			return ErrUserNotFound
+		}
+		a := m.asset(userID, symbol)
+		if a.Locked < amount {
+			return ErrInsufficientLocked
+		}
+		a.Locked -= amount
+		a.Available += amount
+		return nil
+	})
+}
+
+// transfer 对应 doTransfer (支付方与接收方是同一用户)
+func (m *ledgerModel) transfer(cmdID string, userID int64, symbol string, amount int64,
+	toSymbol string, toAmount int64, fee int64, feeAsset string) error {
+	return m.run(cmdID, func() error {
+		if _, ok := m.users[userID]; !ok {
+			return ErrUserNotFound
+		}
+		a := m.asset(userID, symbol)
+		if a.Locked < amount {
+			return ErrInsufficientLocked
+		}
+		// 手续费: 算上本次入账仍付不起时整笔拒绝 (与引擎一致)
+		if fee > 0 && feeAsset != "" {
+			avail := m.asset(userID, feeAsset).Available
+			if feeAsset == toSymbol {
+				avail += toAmount
+			}
+			if avail < fee {
+				return ErrInsufficientBalance
+			}
+		}
+		a.Locked -= amount
+		m.asset(userID, toSymbol).Available += toAmount
+		if fee > 0 && feeAsset != "" {
+			m.asset(userID, feeAsset).Available -= fee
+			m.fees[feeAsset] += fee
+		}
+		return nil
+	})
+}
+
+// revertTransfer 对应 doTransferRevert: 撤销已执行的 transfer 并删除其幂等键
+func (m *ledgerModel) revertTransfer(cmdID string, userID int64, symbol string, amount int64,
+	toSymbol string, toAmount int64, fee int64, feeAsset string) {
+	if fee > 0 && feeAsset != "" {
+		m.asset(userID, feeAsset).Available += fee
+		m.fees[feeAsset] -= fee
+	}
+	m.asset(userID, toSymbol).Available -= toAmount
+	m.asset(userID, symbol).Locked += amount
+	delete(m.applied, cmdID)
+}
+
+// apply 在模型上执行一次操作，返回预期错误
+func (m *ledgerModel) apply(op invOp) error {
+	switch op.Kind {
+	case invDeposit:
+		err := m.add(invCmdID(op, "dep"), op.UserID, op.Asset, op.Amount)
+		if err == nil {
+			m.deposits[op.Asset] += op.Amount
+		}
+		return err
+	case invWithdraw:
+		err := m.deduct(invCmdID(op, "wd"), op.UserID, op.Asset, op.Amount)
+		if err == nil {
+			m.withdrawals[op.Asset] += op.Amount
+		}
+		return err
+	case invPlace:
+		return m.reserve(fmt.Sprintf("reserve_%d", op.OrderID), op.UserID, op.Asset, op.Amount)
+	case invCancel:
+		return m.release(fmt.Sprintf("release_%d", op.OrderID), op.UserID, op.Asset, op.Amount)
+	case invFill:
+		quoteAmount := (op.Price / Precision) * op.Qty
+		sellerID := fmt.Sprintf("fill_seller_%d", op.ID)
+		if err := m.transfer(sellerID, op.PeerID,
+			invBase, op.Qty, invQuote, quoteAmount, op.SellerFee, invQuote); err != nil {
+			return err
+		}
+		err := m.transfer(fmt.Sprintf("fill_buyer_%d", op.ID), op.UserID,
+			invQuote, quoteAmount, invBase, op.Qty, op.BuyerFee, invBase)
+		if err != nil {
+			// 买方被拒: 卖方回滚，成交整笔不生效
+			m.revertTransfer(sellerID, op.PeerID,
+				invBase, op.Qty, invQuote, quoteAmount, op.SellerFee, invQuote)
+		}
+		return err
+	case invFunding, invLiquidate:
+		if err := m.deduct(invCmdID(op, "pay"), op.UserID, op.Asset, op.Amount); err != nil {
+			return err
+		}
+		return m.add(invCmdID(op, "recv"), op.PeerID, op.Asset, op.Amount)
+	}
+	return nil
+}
+
+// invCmdID 生成测试操作的幂等键
+func invCmdID(op invOp, suffix string) string {
+	return fmt.Sprintf("inv_%s_%d_%s", strings.ToLower(op.Kind.String()), op.ID, suffix)
+}
+
+// =============================================================================
+// 在真实引擎上执行
+// =============================================================================
+
+// applyLedgerOp 在引擎上执行一次操作
+func applyLedgerOp(e *AccountEngine, op invOp) error {
+	switch op.Kind {
+	case invDeposit:
+		return e.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   invCmdID(op, "dep"),
+			UserID:    op.UserID,
+			Symbol:    op.Asset,
+			Amount:    op.Amount,
+		})
+	case invWithdraw:
+		return e.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "WITHDRAW",
+			EventID:   invCmdID(op, "wd"),
+			UserID:    op.UserID,
+			Symbol:    op.Asset,
+			Amount:    op.Amount,
+		})
+	case invPlace:
+		return e.Reserve(op.UserID, op.Asset, op.Amount, op.OrderID)
+	case invCancel:
+		return e.Release(op.UserID, op.Asset, op.Amount, op.OrderID)
+	case invFill:
+		return e.ApplyFill(&FillEvent{
+			TradeID:        op.ID,
+			BuyerID:        op.UserID,
+			SellerID:       op.PeerID,
+			BaseAsset:      invBase,
+			QuoteAsset:     invQuote,
+			Price:          op.Price,
+			Quantity:       op.Qty,
+			BuyerFee:       op.BuyerFee,
+			BuyerFeeAsset:  invBase,
+			SellerFee:      op.SellerFee,
+			SellerFeeAsset: invQuote,
+		})
+	case invFunding, invLiquidate:
+		if err := e.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "WITHDRAW",
+			EventID:   invCmdID(op, "pay"),
+			UserID:    op.UserID,
+			Symbol:    op.Asset,
+			Amount:    op.Amount,
+		}); err != nil {
+			return err
+		}
+		return e.ApplyBalanceChange(&BalanceChangeEvent{
+			EventType: "DEPOSIT",
+			EventID:   invCmdID(op, "recv"),
+			UserID:    op.PeerID,
+			Symbol:    op.Asset,
+			Amount:    op.Amount,
+		})
+	}
+	return nil
+}
+
+// sameError 判断引擎错误与模型预期是否一致
+func sameError(got, want error) bool {
+	if want == nil || got == nil {
+		return got == want
+	}
+	return errors.Is(got, want)
+}
+
+// runLedgerOps 在新引擎上重放操作序列，返回违反的不变量 (为空表示通过)
+func runLedgerOps(ops []invOp) []string {
+	engine := NewEngine(EngineConfig{NumShards: 4, WriteBehind: true})
+	engine.Start()
+	cold := newMemColdStore()
+	wb, err := NewWriteBehind(engine, cold, DefaultWriteBehindConfig())
+	if err != nil {
+		return []string{fmt.Sprintf("write-behind: %v", err)}
+	}
+
+	model := newLedgerModel()
+	var violations []string
+
+	for i, op := range ops {
+		got := applyLedgerOp(engine, op)
+		want := model.apply(op)
+		if !sameError(got, want) {
+			violations = append(violations,
+				fmt.Sprintf("op %d (%s): engine err=%v, model err=%v", i, op, got, want))
+		}
+	}
+
+	// 回写要经过分片线程，必须在停止引擎之前
+	if err := wb.Flush(context.Background()); err != nil {
+		violations = append(violations, fmt.Sprintf("write-behind flush: %v", err))
+	}
+
+	// 停止引擎后分片 goroutine 已退出，可以安全读取内部状态
+	engine.Stop()
+
+	hot := make(map[int64]map[string]Asset)
+	for _, shard := range engine.shards {
+		for userID, user := range shard.users {
+			assets := make(map[string]Asset, len(user.Assets))
+			for symbol, a := range user.Assets {
+				assets[symbol] = *a
+			}
+			hot[userID] = assets
+		}
+	}
+
+	violations = append(violations, checkLedgerInvariants(hot, model)...)
+	violations = append(violations, checkColdBalances(hot, cold)...)
+	return violations
+}
+
+// checkColdBalances 资金库侧: 回写后冷库余额与热钱包逐条一致
+//
+// 只被拒绝命令顺带创建、从未变动过的余额不会回写，冷库里缺失按零值比较
+func checkColdBalances(hot map[int64]map[string]Asset, cold *memColdStore) []string {
+	var violations []string
+	for userID, assets := range hot {
+		for symbol, a := range assets {
+			got, _ := cold.get(userID, symbol)
+			if got.Available != a.Available || got.Locked != a.Locked {
+				violations = append(violations, fmt.Sprintf(
+					"cold store diverged: user=%d %s cold={%d %d} hot={%d %d}",
+					userID, symbol, got.Available, got.Locked, a.Available, a.Locked))
+			}
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+// checkLedgerInvariants 检查守恒定律 / 非负性 / 与模型一致性
+func checkLedgerInvariants(hot map[int64]map[string]Asset, model *ledgerModel) []string {
+	var violations []string
+
+	// 1. 非负性 + 与模型逐账户对比
+	userIDs := make([]int64, 0, len(hot)+len(model.users))
+	seen := make(map[int64]struct{})
+	for id := range hot {
+		userIDs = append(userIDs, id)
+		seen[id] = struct{}{}
+	}
+	for id := range model.users {
+		if _, ok := seen[id]; !ok {
+			userIDs = append(userIDs, id)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	totals := make(map[string]int64)
+	for _, userID := range userIDs {
+		for _, symbol := range []string{invBase, invQuote} {
+			got := hot[userID][symbol]
+			var want Asset
+			if a, ok := model.users[userID][symbol]; ok {
+				want = *a
+			}
+
+			if got.Available < 0 || got.Locked < 0 {
+				violations = append(violations, fmt.Sprintf(
+					"negative balance: user=%d %s available=%d locked=%d",
+					userID, symbol, got.Available, got.Locked))
+			}
+			if got != want {
+				violations = append(violations, fmt.Sprintf(
+					"state diverged: user=%d %s engine=%+v model=%+v",
+					userID, symbol, got, want))
+			}
+			totals[symbol] += got.Total()
+		}
+	}
+
+	// 2. 守恒: Σ用户 + 保险基金 + 手续费 == 充值 - 提现
+	for _, symbol := range []string{invBase, invQuote} {
+		fund := hot[invInsuranceUserID][symbol]
+		insurance := fund.Total()
+		users := totals[symbol] - insurance
+		fees := model.fees[symbol]
+		net := model.deposits[symbol] - model.withdrawals[symbol]
+		if users+insurance+fees != net {
+			violations = append(violations, fmt.Sprintf(
+				"conservation broken: %s users=%d insurance=%d fees=%d net_deposits=%d (diff=%d)",
+				symbol, users, insurance, fees, net, users+insurance+fees-net))
+		}
+	}
+
+	return violations
+}
+
+// =============================================================================
+// 随机序列生成
+// =============================================================================
+
+// invOrder 生成器跟踪的挂单
+type invOrder struct {
+	orderID   int64
+	userID    int64
+	buy       bool
+	price     int64
+	remaining int64
+}
+
+// genLedgerOps 随机生成一条 "大体合法" 的操作序列
+//
+// 生成器自带一个参考模型，尽量只生成能成功的成交/撤单，
+// 但充值不足的下单、超额提现等会故意保留，用来覆盖拒绝路径
+func genLedgerOps(r *rand.Rand, n int) []invOp {
+	const numUsers = 6
+
+	model := newLedgerModel()
+	var open []*invOrder
+	ops := make([]invOp, 0, n)
+	nextOrderID := int64(1)
+
+	randUser := func() int64 { return int64(r.Intn(numUsers) + 1) }
+	available := func(userID int64, symbol string) int64 {
+		if a, ok := model.users[userID][symbol]; ok {
+			return a.Available
+		}
+		return 0
+	}
+
+	for id := int64(1); len(ops) < n; id++ {
+		op := invOp{ID: id}
+
+		switch p := r.Intn(100); {
+		case p < 20:
+			op.Kind = invDeposit
+			op.UserID = randUser()
+			if r.Intn(2) == 0 {
+				op.Asset, op.Amount = invBase, int64(r.Intn(10)+1)*Precision/10
+			} else {
+				op.Asset, op.Amount = invQuote, int64(r.Intn(1000)+1)*Precision
+			}
+
+		case p < 28:
+			op.Kind = invWithdraw
+			op.UserID = randUser()
+			op.Asset = invQuote
+			if r.Intn(2) == 0 {
+				op.Asset = invBase
+			}
+			// 偶尔超额提现，覆盖余额不足路径
+			op.Amount = available(op.UserID, op.Asset)/2 + 1
+			if r.Intn(10) == 0 {
+				op.Amount = available(op.UserID, op.Asset) + 1
+			}
+
+		case p < 55:
+			price := int64(r.Intn(101)+100) * Precision // 100 ~ 200
+			qty := int64(r.Intn(5)+1) * Precision / 100 // 0.01 ~ 0.05
+			o := &invOrder{orderID: nextOrderID, userID: randUser(), buy: r.Intn(2) == 0, price: price, remaining: qty}
+			nextOrderID++
+
+			op.Kind = invPlace
+			op.UserID = o.userID
+			op.OrderID = o.orderID
+			if o.buy {
+				op.Asset, op.Amount = invQuote, (price/Precision)*qty
+			} else {
+				op.Asset, op.Amount = invBase, qty
+			}
+			if model.apply(op) == nil {
+				open = append(open, o)
+			}
+			ops = append(ops, op)
+			continue
+
+		case p < 75:
+			buy, sell := pickCrossing(r, open)
+			if buy == nil {
+				continue
+			}
+			qty := min(buy.remaining, sell.remaining)
+			if r.Intn(2) == 0 && qty > Precision/100 {
+				qty = Precision / 100 // 部分成交
+			}
+			quoteAmount := (buy.price / Precision) * qty
+
+			op.Kind = invFill
+			op.UserID = buy.userID
+			op.PeerID = sell.userID
+			op.Price = buy.price
+			op.Qty = qty
+			op.BuyerFee = qty * invTakerFeeRate / 10000
+			op.SellerFee = quoteAmount * invMakerFeeRate / 10000
+			if model.apply(op) == nil {
+				buy.remaining -= qty
+				sell.remaining -= qty
+				open = removeFilled(open)
+			}
+			ops = append(ops, op)
+			continue
+
+		case p < 85:
+			if len(open) == 0 {
+				continue
+			}
+			i := r.Intn(len(open))
+			o := open[i]
+			open = append(open[:i], open[i+1:]...)
+
+			op.Kind = invCancel
+			op.UserID = o.userID
+			op.OrderID = o.orderID
+			if o.buy {
+				op.Asset, op.Amount = invQuote, (o.price/Precision)*o.remaining
+			} else {
+				op.Asset, op.Amount = invBase, o.remaining
+			}
+
+		case p < 95:
+			op.Kind = invFunding
+			op.UserID = randUser()
+			op.PeerID = randUser()
+			op.Asset = invQuote
+			op.Amount = int64(r.Intn(10)+1) * Precision
+
+		default:
+			op.Kind = invLiquidate
+			op.UserID = randUser()
+			op.PeerID = invInsuranceUserID
+			op.Asset = invQuote
+			op.Amount = available(op.UserID, invQuote)
+			if op.Amount == 0 {
+				continue
+			}
+		}
+
+		model.apply(op)
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+// pickCrossing 随机挑一对可成交的买卖单 (买价 >= 卖价)
+func pickCrossing(r *rand.Rand, open []*invOrder) (buy, sell *invOrder) {
+	if len(open) < 2 {
+		return nil, nil
+	}
+	start := r.Intn(len(open))
+	for i := 0; i < len(open); i++ {
+		b := open[(start+i)%len(open)]
+		if !b.buy {
+			continue
+		}
+		for _, s := range open {
+			if !s.buy && b.price >= s.price {
+				return b, s
+			}
+		}
+	}
+	return nil, nil
+}
+
+// removeFilled 移除已完全成交的挂单
+func removeFilled(open []*invOrder) []*invOrder {
+	kept := open[:0]
+	for _, o := range open {
+		if o.remaining > 0 {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
+
+// =============================================================================
+// 收缩 (Shrinking)
+// =============================================================================
+
+// shrinkLedgerOps 把失败序列收缩为最小复现
+//
+// 算法 (简化版 delta debugging):
+// - 从大块开始尝试删除，删除后仍失败就保留删除结果
+// - 块大小逐步减半，直到单个操作
+// - 结果是 "1-minimal": 再删任意一个操作都不会失败
+func shrinkLedgerOps(ops []invOp, fails func([]invOp) bool) []invOp {
+	current := ops
+	for chunk := len(current) / 2; chunk >= 1; {
+		removed := false
+		for start := 0; start+chunk <= len(current); {
+			candidate := make([]invOp, 0, len(current)-chunk)
+			candidate = append(candidate, current[:start]...)
+			candidate = append(candidate, current[start+chunk:]...)
+			if fails(candidate) {
+				current = candidate
+				removed = true
+				continue // 同一位置继续尝试
+			}
+			start += chunk
+		}
+		if !removed {
+			chunk /= 2
+		}
+	}
+	return current
+}
+
+// =============================================================================
+// 测试用例
+// =============================================================================
+
+// TestLedgerInvariants_RandomSequences 随机序列下账本守恒
+func TestLedgerInvariants_RandomSequences(t *testing.T) {
+	seeds, length := 30, 200
+	if testing.Short() {
+		seeds, length = 5, 100
+	}
+
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		ops := genLedgerOps(rand.New(rand.NewSource(seed)), length)
+		violations := runLedgerOps(ops)
+		if len(violations) == 0 {
+			continue
+		}
+
+		minimal := shrinkLedgerOps(ops, func(c []invOp) bool {
+			return len(runLedgerOps(c)) > 0
+		})
+		t.Fatalf("seed=%d: %d violations, first: %s\nminimal reproducer (%d ops):\n%s",
+			seed, len(violations), violations[0], len(minimal), formatLedgerOps(minimal))
+	}
+}
+
+// TestLedgerInvariants_FailedFillRollsBack 买方结算失败的成交整笔回滚
+//
+// 构造场景: 买方没有冻结 USDT 就成交
+// ApplyFill 先结算卖方 (扣 BTC 加 USDT) 再结算买方，买方被拒时撤销卖方那一步，
+// 守恒成立、卖方 BTC 仍在冻结中，同一笔成交补齐冻结后可以重放
+func TestLedgerInvariants_FailedFillRollsBack(t *testing.T) {
+	price := int64(150 * Precision)
+	qty := int64(Precision / 100)
+
+	ops := []invOp{
+		{Kind: invDeposit, ID: 1, UserID: 1, Asset: invQuote, Amount: 1000 * Precision},
+		{Kind: invDeposit, ID: 2, UserID: 2, Asset: invBase, Amount: Precision},
+		{Kind: invPlace, ID: 3, UserID: 2, OrderID: 1, Asset: invBase, Amount: qty},
+		// 用户 1 从未冻结 USDT
+		{Kind: invFill, ID: 4, UserID: 1, PeerID: 2, Price: price, Qty: qty, SellerFee: Precision / 10},
+		// 补上冻结后重放同一笔成交
+		{Kind: invPlace, ID: 5, UserID: 1, OrderID: 2, Asset: invQuote, Amount: 150 * qty},
+		{Kind: invFill, ID: 4, UserID: 1, PeerID: 2, Price: price, Qty: qty, SellerFee: Precision / 10},
+	}
+	if violations := runLedgerOps(ops); len(violations) > 0 {
+		t.Fatalf("%d violations, first: %s", len(violations), violations[0])
+	}
+
+	// 直接看引擎: 失败的成交不留痕迹
+	engine := NewEngine(EngineConfig{NumShards: 4})
+	engine.Start()
+	defer engine.Stop()
+	for _, op := range ops[:3] {
+		if err := applyLedgerOp(engine, op); err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+	}
+	if err := applyLedgerOp(engine, ops[3]); !errors.Is(err, ErrInsufficientLocked) {
+		t.Fatalf("expected ErrInsufficientLocked, got %v", err)
+	}
+	seller := engine.GetSnapshot(2)
+	if seller.Assets[invBase].Locked != qty || seller.Assets[invQuote].Available != 0 {
+		t.Errorf("seller not rolled back: BTC %+v USDT %+v", seller.Assets[invBase], seller.Assets[invQuote])
+	}
+}
+
+// TestLedgerInvariants_ShrinkToMinimal 验证收缩器能把失败序列收缩到最小
+//
+// 用一个人为的判定 (同时出现对同一订单的下单和撤单即视为失败) 驱动收缩，
+// 不依赖引擎里真有缺陷
+func TestLedgerInvariants_ShrinkToMinimal(t *testing.T) {
+	ops := genLedgerOps(rand.New(rand.NewSource(7)), 200)
+
+	fails := func(c []invOp) bool {
+		placed := make(map[int64]bool)
+		for _, op := range c {
+			switch op.Kind {
+			case invPlace:
+				placed[op.OrderID] = true
+			case invCancel:
+				if placed[op.OrderID] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if !fails(ops) {
+		t.Fatal("generated sequence has no place/cancel pair")
+	}
+
+	minimal := shrinkLedgerOps(ops, fails)
+	if len(minimal) != 2 || minimal[0].Kind != invPlace || minimal[1].Kind != invCancel ||
+		minimal[0].OrderID != minimal[1].OrderID {
+		t.Fatalf("expected place+cancel reproducer, got %d ops:\n%s", len(minimal), formatLedgerOps(minimal))
+	}
+}
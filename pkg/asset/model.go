@@ -11,6 +11,8 @@ package asset
 import (
 	"sync/atomic"
 	"time"
+
+	"max.com/pkg/fixed"
 )
 
 // =============================================================================
@@ -22,7 +24,7 @@ const (
 	// 所有金额存储为 int64，乘以 10^8 (类似比特币的 satoshi)
 	// 1 BTC = 100,000,000 satoshi
 	// 1 USDT = 100,000,000 微单位
-	Precision = 100_000_000
+	Precision = fixed.Scale
 
 	// NumShards 分片数量
 	// 按 userID % NumShards 路由，每个分片单线程处理
@@ -286,10 +288,16 @@ func (u *UserState) CreateSnapshot() *Snapshot {
 	snap := &Snapshot{
 		UserID:    u.UserID,
 		Assets:    make(map[string]Asset, len(u.Assets)),
-		Positions: make(map[string]Position, len(u.Positions)),
 		Seq:       u.LastSeq,
 		CreatedAt: time.Now().UnixNano(),
 	}
+	// 现货用户没有合约/期权持仓，空的不分配 (只读，nil map 可以正常查询和遍历)
+	if len(u.Positions) > 0 {
+		snap.Positions = make(map[string]Position, len(u.Positions))
+	}
+	if len(u.Options) > 0 {
+		snap.Options = make(map[string]OptionPosition, len(u.Options))
+	}
 
 	// 深拷贝资产
 	for symbol, asset := range u.Assets {
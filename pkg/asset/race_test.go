@@ -0,0 +1,6 @@
+//go:build race
+
+package asset
+
+// raceEnabled 竞态检测会给每次内存访问插桩并额外分配，分配次数断言在 -race 下不成立
+const raceEnabled = true
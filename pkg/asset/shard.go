@@ -16,10 +16,16 @@ package asset
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"max.com/pkg/affinity"
+	"max.com/pkg/audit"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/epoch"
 )
 
 // =============================================================================
@@ -27,12 +33,15 @@ import (
 // =============================================================================
 
 var (
-	ErrInsufficientBalance = errors.New("insufficient available balance")
-	ErrInsufficientLocked  = errors.New("insufficient locked balance")
-	ErrUserNotFound        = errors.New("user not found in hot cache")
-	ErrShardClosed         = errors.New("shard is closed")
-	ErrCommandTimeout      = errors.New("command timeout")
-	ErrDuplicateCommand    = errors.New("duplicate command (idempotency)")
+	ErrInsufficientBalance = cexerr.New("ASSET_INSUFFICIENT_BALANCE", cexerr.CategoryInsufficientFunds, "insufficient available balance")
+	ErrInsufficientLocked  = cexerr.New("ASSET_INSUFFICIENT_LOCKED", cexerr.CategoryInsufficientFunds, "insufficient locked balance")
+	ErrUserNotFound        = cexerr.New("ASSET_USER_NOT_FOUND", cexerr.CategoryNotFound, "user not found in hot cache")
+	ErrShardClosed         = cexerr.NewRetryable("ASSET_SHARD_CLOSED", cexerr.CategoryUnavailable, "shard is closed")
+	ErrRevertNotApplied    = cexerr.New("ASSET_REVERT_NOT_APPLIED", cexerr.CategoryFailedPrecondition, "transfer to revert was not applied")
+	ErrCommandTimeout      = cexerr.NewRetryable("ASSET_COMMAND_TIMEOUT", cexerr.CategoryUnavailable, "command timeout")
+	ErrDuplicateCommand    = cexerr.New("ASSET_DUPLICATE_COMMAND", cexerr.CategoryConflict, "duplicate command (idempotency)")
+	ErrStaleEpoch          = epoch.ErrStale // 命令来自已被切换掉的撮合实例
+	ErrWithdrawDisabled    = cexerr.New("ASSET_WITHDRAW_DISABLED", cexerr.CategoryFailedPrecondition, "withdrawals are disabled")
 )
 
 // =============================================================================
@@ -43,23 +52,55 @@ var (
 type CmdType uint8
 
 const (
-	CmdReserve       CmdType = iota + 1 // 冻结 (下单)
-	CmdRelease                          // 解冻 (撤单)
-	CmdTransfer                         // 划转 (成交结算)
-	CmdAddBalance                       // 增加余额 (充值确认后)
-	CmdDeductBalance                    // 扣减余额 (提现确认后)
+	CmdReserve        CmdType = iota + 1 // 冻结 (下单)
+	CmdRelease                           // 解冻 (撤单)
+	CmdTransfer                          // 划转 (成交结算)
+	CmdAddBalance                        // 增加余额 (充值确认后)
+	CmdDeductBalance                     // 扣减余额 (提现确认后)
+	CmdQuery                             // 只读查询 (在分片线程内执行，不写 WAL)
+	CmdFeeSettle                         // 手续费结算 (Amount 为正入账，为负出账)
+	CmdGetBalance                        // 一致性读: 单个资产余额 (分片线程内执行，不写 WAL)
+	CmdGetUserState                      // 一致性读: 用户完整状态 (分片线程内执行，不写 WAL)
+	CmdTransferRevert                    // 撤销一笔已执行的划转 (成交另一方结算失败时回滚)
 )
 
+func (t CmdType) String() string {
+	switch t {
+	case CmdReserve:
+		return "RESERVE"
+	case CmdRelease:
+		return "RELEASE"
+	case CmdTransfer:
+		return "TRANSFER"
+	case CmdAddBalance:
+		return "ADD_BALANCE"
+	case CmdDeductBalance:
+		return "DEDUCT_BALANCE"
+	case CmdQuery:
+		return "QUERY"
+	case CmdFeeSettle:
+		return "FEE_SETTLE"
+	case CmdGetBalance:
+		return "GET_BALANCE"
+	case CmdGetUserState:
+		return "GET_USER_STATE"
+	case CmdTransferRevert:
+		return "TRANSFER_REVERT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 // Command 命令结构
 //
 // 所有资金操作都封装为 Command，通过 Channel 发送给分片处理
 // 这是"命令模式"的应用，便于:
 // 1. 序列化到 WAL
-// 2. 幂等性检查 (通过 CmdID)
+// 2. 幂等性检查 (通过 Key，见 cmd_key.go)
 // 3. 异步执行 + 结果回传
 type Command struct {
 	Type   CmdType // 命令类型
-	CmdID  string  // 幂等键 (如 order_id, trade_id)
+	Key    CmdKey  // 幂等键 (如 order_id, trade_id)，零值不检查
 	UserID int64   // 目标用户
 
 	// 操作参数
@@ -73,6 +114,15 @@ type Command struct {
 	Fee      int64  // 手续费
 	FeeAsset string // 手续费资产
 
+	// 撮合纪元 (成交结算带上，0 表示不检查)，见 pkg/epoch
+	Epoch uint64
+
+	// Query 专用: 在分片线程内执行，可以安全读取 users
+	Query func(users map[int64]*UserState)
+
+	// GetBalance / GetUserState 专用: 分片线程内生成的快照 (GetBalance 只含 Symbol 一项)
+	Reply chan *Snapshot
+
 	// 结果回传
 	Result chan error
 }
@@ -86,13 +136,13 @@ type Command struct {
 // 职责:
 // 1. 管理该分片下所有用户的 UserState
 // 2. 单线程处理所有命令 (Reserve/Release/Transfer...)
-// 3. 维护幂等性检查 (已处理的 CmdID)
+// 3. 维护幂等性检查 (已处理的幂等键)
 // 4. 发布快照供风控读取
 //
 // 内存结构:
 // - users: 热用户状态 map
 // - appliedCmds: 已应用命令 (用于幂等检查)
-// - cmdCh: 命令队列
+// - queues: 按优先级拆分的命令队列 (见 queue.go)
 type Shard struct {
 	id int // 分片编号 (0 ~ NumShards-1)
 
@@ -100,12 +150,14 @@ type Shard struct {
 	users map[int64]*UserState // UserID -> State
 
 	// ===== 幂等性 =====
-	// 存储最近已处理的 CmdID，防止重复执行
+	// 存储最近已处理的幂等键，防止重复执行
 	// 使用 LRU 或定时清理，避免无限增长
-	appliedCmds map[string]struct{}
+	appliedCmds map[CmdKey]struct{}
 
 	// ===== 命令队列 =====
-	cmdCh chan Command
+	queues      commandQueues
+	adminLimit  *tokenBucket  // 管理/查询类提交限速，nil 表示不限
+	rateLimited atomic.Uint64 // 被限速拒绝的命令数 (Submit 并发写)
 
 	// ===== 快照存储 =====
 	// 由 Engine 统一管理，分片只负责更新
@@ -121,6 +173,31 @@ type Shard struct {
 	// ===== WAL =====
 	wal *WAL // 可选，启用时会先写 WAL
 
+	// ===== 回写冷库 (write-behind) =====
+	// 记录自上次回写以来变动过的余额，由 WriteBehind 定期取走 (见 write_behind.go)
+	trackDirty bool
+	changeSeq  uint64                   // 分片内余额变更序号，与流水顺序一致
+	dirty      map[balanceKey]dirtyMark // 待回写的余额
+
+	// ===== 纪元栅栏 (引擎内所有分片共享) =====
+	fence *epoch.Fence
+
+	// ===== 审计 (可选，见 audit.go) =====
+	auditor audit.Recorder
+
+	// ===== 线程放置 =====
+	lockOSThread bool
+	cpus         []int
+	placement    atomic.Pointer[ShardPlacement]
+}
+
+// ShardPlacement 分片线程放置情况
+type ShardPlacement struct {
+	ShardID      int
+	LockedThread bool  // 是否独占 OS 线程
+	CPUs         []int // 请求绑定的 CPU
+	Pinned       bool  // 绑核是否成功
+	PinError     error // 绑核失败原因
 }
 
 // ShardStats 分片统计信息 (监控用)
@@ -131,16 +208,27 @@ type ShardStats struct {
 	TransferCount   uint64 // 划转次数
 	RejectCount     uint64 // 拒绝次数 (余额不足等)
 	DuplicateCount  uint64 // 重复命令次数
+	StaleEpochCount uint64 // 旧纪元命令被拒次数
 	ActiveUserCount int    // 活跃用户数
+
+	// 按优先级统计 (下标为 CmdPriority)
+	QueueDepth       [NumPriorities]int    // 当前排队数
+	ProcessedByClass [NumPriorities]uint64 // 已处理命令数
+	RateLimitedCount uint64                // 管理/查询类被限速拒绝数
 }
 
 // ShardConfig 分片配置
 type ShardConfig struct {
 	ID              int            // 分片编号
-	CommandQueueLen int            // 命令队列长度
+	CommandQueueLen int            // 每个优先级的命令队列长度
+	AdminRateLimit  int            // 管理/查询类每秒最多提交数，0 表示不限
 	SnapshotStore   *SnapshotStore // 快照存储 (共享)
 	WAL             *WAL           // 可选
-
+	LockOSThread    bool           // 独占 OS 线程
+	CPUs            []int          // 绑定的 CPU，非空时隐含 LockOSThread
+	TrackDirty      bool           // 记录变动余额供回写冷库
+	Fence           *epoch.Fence   // 纪元栅栏，nil 时分片自建
+	Auditor         audit.Recorder // 审计，nil 表示不记录
 }
 
 // =============================================================================
@@ -151,6 +239,11 @@ type ShardConfig struct {
 func NewShard(cfg ShardConfig) *Shard {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	fence := cfg.Fence
+	if fence == nil {
+		fence = &epoch.Fence{}
+	}
+
 	queueLen := cfg.CommandQueueLen
 	if queueLen <= 0 {
 		queueLen = 10000 // 默认队列长度
@@ -159,13 +252,19 @@ func NewShard(cfg ShardConfig) *Shard {
 	return &Shard{
 		id:            cfg.ID,
 		users:         make(map[int64]*UserState),
-		appliedCmds:   make(map[string]struct{}),
-		cmdCh:         make(chan Command, queueLen),
+		appliedCmds:   make(map[CmdKey]struct{}),
+		queues:        newCommandQueues(queueLen),
+		adminLimit:    newTokenBucket(cfg.AdminRateLimit),
 		snapshotStore: cfg.SnapshotStore,
 		ctx:           ctx,
 		cancel:        cancel,
 		wal:           cfg.WAL, // 添加这行
-
+		lockOSThread:  cfg.LockOSThread || len(cfg.CPUs) > 0,
+		cpus:          cfg.CPUs,
+		trackDirty:    cfg.TrackDirty,
+		fence:         fence,
+		dirty:         make(map[balanceKey]dirtyMark),
+		auditor:       cfg.Auditor,
 	}
 }
 
@@ -176,15 +275,20 @@ func (s *Shard) Start() {
 }
 
 // Stop 停止分片
+//
+// 处理循环退出后把 WAL 缓冲刷盘，否则重启恢复会丢掉最后一批命令
 func (s *Shard) Stop() {
 	s.cancel()
 	s.wg.Wait()
+	if s.wal != nil {
+		s.wal.Sync()
+	}
 }
 
 // processLoop 命令处理主循环 (单线程)
 //
 // 这是分片的核心:
-// - 从 cmdCh 取命令
+// - 按优先级从 queues 取命令 (结算 > 冻结/解冻 > 管理/查询)
 // - 执行命令 (修改 UserState)
 // - 返回结果
 // - 更新快照
@@ -192,29 +296,70 @@ func (s *Shard) Stop() {
 // 因为是单线程，所有操作都是原子的，无需加锁
 func (s *Shard) processLoop() {
 	defer s.wg.Done()
+	defer s.place()()
 
 	for {
+		// 每条命令处理完都从最高优先级重新检查
+		if cmd, ok := s.queues.next(); ok {
+			s.handleCommand(cmd)
+			continue
+		}
+
+		// 全部为空时阻塞等待，哪个队列先到就先处理哪个
 		select {
 		case <-s.ctx.Done():
 			// 优雅关闭：处理完队列中剩余命令
 			s.drainQueue()
 			return
 
-		case cmd := <-s.cmdCh:
+		case cmd := <-s.queues[PrioritySettlement]:
+			s.handleCommand(cmd)
+		case cmd := <-s.queues[PriorityOrder]:
+			s.handleCommand(cmd)
+		case cmd := <-s.queues[PriorityAdmin]:
 			s.handleCommand(cmd)
 		}
 	}
 }
 
+// place 在分片 goroutine 内调用：按配置锁定线程、绑核，并记录放置情况
+// 返回的函数在 processLoop 退出时调用
+func (s *Shard) place() func() {
+	placement := ShardPlacement{ShardID: s.id, CPUs: s.cpus}
+	if !s.lockOSThread {
+		s.placement.Store(&placement)
+		return func() {}
+	}
+
+	placement.LockedThread = true
+	placement.PinError = affinity.Pin(s.cpus)
+	placement.Pinned = len(s.cpus) > 0 && placement.PinError == nil
+	s.placement.Store(&placement)
+
+	// 【注意】绑过核的线程不还给调度器，goroutine 退出时线程随之销毁
+	return func() {
+		if !placement.Pinned {
+			runtime.UnlockOSThread()
+		}
+	}
+}
+
+// Placement 线程放置情况（Start 之前返回未锁定状态）
+func (s *Shard) Placement() ShardPlacement {
+	if p := s.placement.Load(); p != nil {
+		return *p
+	}
+	return ShardPlacement{ShardID: s.id, CPUs: s.cpus}
+}
+
 // drainQueue 关闭时处理剩余命令
 func (s *Shard) drainQueue() {
 	for {
-		select {
-		case cmd := <-s.cmdCh:
-			s.handleCommand(cmd)
-		default:
+		cmd, ok := s.queues.next()
+		if !ok {
 			return
 		}
+		s.handleCommand(cmd)
 	}
 }
 
@@ -225,10 +370,32 @@ func (s *Shard) drainQueue() {
 // handleCommand 处理单个命令
 func (s *Shard) handleCommand(cmd Command) {
 	s.stats.TotalCommands++
+	s.stats.ProcessedByClass[cmd.Type.Priority()]++
+
+	// 只读查询: 不做幂等检查、不写 WAL
+	if cmd.Type == CmdQuery {
+		if cmd.Query != nil {
+			cmd.Query(s.users)
+		}
+		s.sendResult(cmd, nil)
+		return
+	}
+	if cmd.Type == CmdGetBalance || cmd.Type == CmdGetUserState {
+		s.handleRead(cmd)
+		return
+	}
+
+	// 0. 纪元检查：旧主发来的成交直接拒绝，且不记录幂等键
+	if err := s.fence.Admit(cmd.Epoch); err != nil {
+		s.stats.StaleEpochCount++
+		s.stats.RejectCount++
+		s.sendResult(cmd, err)
+		return
+	}
 
-	// 1. 幂等性检查
-	if cmd.CmdID != "" {
-		if _, exists := s.appliedCmds[cmd.CmdID]; exists {
+	// 1. 幂等性检查 (撤销命令沿用被撤销划转的键，键必须已存在，见 doTransferRevert)
+	if !cmd.Key.IsZero() && cmd.Type != CmdTransferRevert {
+		if _, exists := s.appliedCmds[cmd.Key]; exists {
 			s.stats.DuplicateCount++
 			s.sendResult(cmd, ErrDuplicateCommand)
 			return
@@ -237,13 +404,14 @@ func (s *Shard) handleCommand(cmd Command) {
 	// 2. 【新增】先写 WAL
 	if s.wal != nil {
 		entry := s.cmdToWALEntry(cmd)
-		if err := s.wal.Write(entry); err != nil {
+		if err := s.wal.Write(&entry); err != nil {
 			s.sendResult(cmd, fmt.Errorf("wal write: %w", err))
 			return
 		}
 	}
 
 	// 2. 执行命令
+	before := s.auditBefore(cmd)
 	var err error
 	switch cmd.Type {
 	case CmdReserve:
@@ -265,15 +433,21 @@ func (s *Shard) handleCommand(cmd Command) {
 		err = s.doAddBalance(cmd)
 	case CmdDeductBalance:
 		err = s.doDeductBalance(cmd)
+	case CmdFeeSettle:
+		err = s.doFeeSettle(cmd)
+	case CmdTransferRevert:
+		err = s.doTransferRevert(cmd)
 	}
 
 	if err != nil {
 		s.stats.RejectCount++
 	}
 
-	// 3. 记录幂等键
-	if err == nil && cmd.CmdID != "" {
-		s.appliedCmds[cmd.CmdID] = struct{}{}
+	// 3. 记录幂等键 (撤销成功后删除，同一笔成交重放时可以重新结算)
+	s.recordKey(cmd, err)
+	if err == nil {
+		s.markDirty(cmd)
+		s.recordAudit(cmd, before)
 	}
 
 	// 4. 返回结果
@@ -285,8 +459,30 @@ func (s *Shard) handleCommand(cmd Command) {
 	}
 }
 
-// cmdToWALEntry 将命令转换为 WAL 条目
-func (s *Shard) cmdToWALEntry(cmd Command) *WALEntry {
+// handleRead 一致性读: 在分片线程内拷贝当前状态
+//
+// 排在它之前提交的命令都已执行完，结果反映的是最新状态 (线性一致)，
+// 不依赖快照是否已经发布。用户不存在时返回空快照，不懒创建
+func (s *Shard) handleRead(cmd Command) {
+	snap := &Snapshot{UserID: cmd.UserID, Assets: map[string]Asset{}, CreatedAt: time.Now().UnixNano()}
+	if user, ok := s.users[cmd.UserID]; ok {
+		if cmd.Type == CmdGetUserState {
+			snap = user.CreateSnapshot()
+		} else {
+			snap.Seq = user.LastSeq
+			if a, ok := user.Assets[cmd.Symbol]; ok {
+				snap.Assets[cmd.Symbol] = a.Clone()
+			}
+		}
+	}
+	if cmd.Reply != nil {
+		cmd.Reply <- snap // 缓冲为 1，不会阻塞分片线程
+	}
+	s.sendResult(cmd, nil)
+}
+
+// cmdToWALEntry 将命令转换为 WAL 条目 (值类型，不逃逸到堆上)
+func (s *Shard) cmdToWALEntry(cmd Command) WALEntry {
 	var entryType WALEntryType
 	switch cmd.Type {
 	case CmdReserve:
@@ -299,11 +495,15 @@ func (s *Shard) cmdToWALEntry(cmd Command) *WALEntry {
 		entryType = WALAddBalance
 	case CmdDeductBalance:
 		entryType = WALDeductBalance
+	case CmdFeeSettle:
+		entryType = WALFeeSettle
+	case CmdTransferRevert:
+		entryType = WALTransferRevert
 	}
 
-	return &WALEntry{
+	return WALEntry{
 		Type:     entryType,
-		CmdID:    cmd.CmdID,
+		Key:      cmd.Key,
 		UserID:   cmd.UserID,
 		Symbol:   cmd.Symbol,
 		Amount:   cmd.Amount,
@@ -315,6 +515,18 @@ func (s *Shard) cmdToWALEntry(cmd Command) *WALEntry {
 	}
 }
 
+// recordKey 命令成功后维护幂等键：撤销删除被撤销划转的键，其余命令记录自己的键
+func (s *Shard) recordKey(cmd Command, err error) {
+	if err != nil || cmd.Key.IsZero() {
+		return
+	}
+	if cmd.Type == CmdTransferRevert {
+		delete(s.appliedCmds, cmd.Key)
+		return
+	}
+	s.appliedCmds[cmd.Key] = struct{}{}
+}
+
 // sendResult 发送命令结果
 func (s *Shard) sendResult(cmd Command, err error) {
 	if cmd.Result != nil {
@@ -362,11 +574,17 @@ func (s *Shard) RecoverFromWAL() (uint64, error) {
 			err = s.doAddBalance(cmd)
 		case CmdDeductBalance:
 			err = s.doDeductBalance(cmd)
+		case CmdFeeSettle:
+			err = s.doFeeSettle(cmd)
+		case CmdTransferRevert:
+			err = s.doTransferRevert(cmd)
 		}
 
 		// 记录幂等键
-		if err == nil && cmd.CmdID != "" {
-			s.appliedCmds[cmd.CmdID] = struct{}{}
+		s.recordKey(cmd, err)
+		// 重放出的余额也要回写，冷库才能追上 WAL
+		if err == nil {
+			s.markDirty(cmd)
 		}
 
 		return err
@@ -387,11 +605,15 @@ func (s *Shard) walEntryToCmd(entry *WALEntry) Command {
 		cmdType = CmdAddBalance
 	case WALDeductBalance:
 		cmdType = CmdDeductBalance
+	case WALFeeSettle:
+		cmdType = CmdFeeSettle
+	case WALTransferRevert:
+		cmdType = CmdTransferRevert
 	}
 
 	return Command{
 		Type:     cmdType,
-		CmdID:    entry.CmdID,
+		Key:      entry.Key,
 		UserID:   entry.UserID,
 		Symbol:   entry.Symbol,
 		Amount:   entry.Amount,
@@ -467,6 +689,8 @@ func (s *Shard) RecoverFromCheckpoint() error {
 			return s.doAddBalance(cmd)
 		case CmdDeductBalance:
 			return s.doDeductBalance(cmd)
+		case CmdTransferRevert:
+			return s.doTransferRevert(cmd)
 		}
 		return nil
 	})
@@ -559,18 +783,21 @@ func (s *Shard) doTransfer(cmd Command) error {
 		return ErrInsufficientLocked
 	}
 
-	// 扣除支付方
-	payerAsset.Locked -= cmd.Amount
-
-	// 扣除手续费 (从可用余额扣)
+	// 手续费在改动任何余额之前检查：付不起就整笔拒绝，
+	// 否则手续费账户会按成交记的手续费入账，收到一笔没人付过的钱 (见 settleFees)
 	if cmd.Fee > 0 && cmd.FeeAsset != "" {
-		feeAsset := payer.GetAsset(cmd.FeeAsset)
-		if feeAsset.Available >= cmd.Fee {
-			feeAsset.Available -= cmd.Fee
+		avail := payer.GetAsset(cmd.FeeAsset).Available
+		if cmd.ToUserID == cmd.UserID && cmd.ToSymbol == cmd.FeeAsset {
+			avail += cmd.ToAmount // 先入账再扣费：手续费通常就是收到的资产
+		}
+		if avail < cmd.Fee {
+			return ErrInsufficientBalance
 		}
-		// 手续费不足时不阻止交易，记录日志即可
 	}
 
+	// 扣除支付方
+	payerAsset.Locked -= cmd.Amount
+
 	// 给接收方加款 (注意: 接收方可能在不同分片!)
 	// 如果在同一分片，直接操作
 	// 如果在不同分片，需要通过 Engine 路由
@@ -578,13 +805,63 @@ func (s *Shard) doTransfer(cmd Command) error {
 	receiverAsset := receiver.GetAsset(cmd.ToSymbol)
 	receiverAsset.Available += cmd.ToAmount
 
+	// 扣除手续费 (从可用余额扣，上面已检查够扣)
+	// Fee < 0 是 maker 返佣，由 Engine 从手续费账户另行划入 (见 settleFees)
+	if cmd.Fee > 0 && cmd.FeeAsset != "" {
+		payer.GetAsset(cmd.FeeAsset).Available -= cmd.Fee
+	}
+
 	// 更新活跃时间
 	payer.LastActiveAt = time.Now().UnixNano()
 	receiver.LastActiveAt = time.Now().UnixNano()
 
-	// 更新接收方快照
-	s.updateSnapshot(cmd.ToUserID)
+	// 更新接收方快照 (成交结算收付是同一个人，由 handleCommand 统一更新，不重复生成)
+	if cmd.ToUserID != cmd.UserID {
+		s.updateSnapshot(cmd.ToUserID)
+	}
+
+	return nil
+}
+
+// doTransferRevert 撤销 doTransfer (命令字段与被撤销的划转完全相同)
+//
+// 成交两条腿在不同分片上各自提交，卖方腿成功、买方腿被拒时由 ApplyFill 调用，
+// 把卖方恢复到结算前: 收到的资产扣回、手续费退回、冻结余额加回。
+// 只能撤销确实执行过的划转 (幂等键存在)，收款已被花掉时拒绝，不把余额扣成负数
+func (s *Shard) doTransferRevert(cmd Command) error {
+	if _, ok := s.appliedCmds[cmd.Key]; cmd.Key.IsZero() || !ok {
+		return ErrRevertNotApplied
+	}
+	payer, ok := s.users[cmd.UserID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	receiver, ok := s.users[cmd.ToUserID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	refundFee := cmd.Fee > 0 && cmd.FeeAsset != ""
+	receiverAsset := receiver.GetAsset(cmd.ToSymbol)
+	avail := receiverAsset.Available
+	if refundFee && cmd.ToUserID == cmd.UserID && cmd.ToSymbol == cmd.FeeAsset {
+		avail += cmd.Fee // 手续费就是收到的资产：先退费再扣回
+	}
+	if avail < cmd.ToAmount {
+		return ErrInsufficientBalance
+	}
+
+	if refundFee {
+		payer.GetAsset(cmd.FeeAsset).Available += cmd.Fee
+	}
+	receiverAsset.Available -= cmd.ToAmount
+	payer.GetAsset(cmd.Symbol).Locked += cmd.Amount
 
+	payer.LastActiveAt = time.Now().UnixNano()
+	receiver.LastActiveAt = time.Now().UnixNano()
+	if cmd.ToUserID != cmd.UserID {
+		s.updateSnapshot(cmd.ToUserID)
+	}
 	return nil
 }
 
@@ -616,6 +893,22 @@ func (s *Shard) doDeductBalance(cmd Command) error {
 	return nil
 }
 
+// doFeeSettle 手续费结算 (成交结算时调用，与 Transfer 同优先级)
+// - 手续费收入账户: +taker 手续费，-maker 返佣
+// - maker: +返佣
+// 出账时余额不足直接拒绝，不允许把手续费账户扣成负数
+func (s *Shard) doFeeSettle(cmd Command) error {
+	user := s.getOrCreateUser(cmd.UserID)
+	asset := user.GetAsset(cmd.Symbol)
+	if cmd.Amount < 0 && asset.Available < -cmd.Amount {
+		return ErrInsufficientBalance
+	}
+
+	asset.Available += cmd.Amount
+	user.LastActiveAt = time.Now().UnixNano()
+	return nil
+}
+
 // =============================================================================
 // 辅助方法
 // =============================================================================
@@ -640,6 +933,8 @@ func (s *Shard) GetUser(userID int64) *UserState {
 func (s *Shard) GetStats() ShardStats {
 	stats := s.stats
 	stats.ActiveUserCount = len(s.users)
+	stats.QueueDepth = s.queues.depths()
+	stats.RateLimitedCount = s.rateLimited.Load()
 	return stats
 }
 
@@ -647,19 +942,26 @@ func (s *Shard) GetStats() ShardStats {
 //
 // 这是外部调用的入口:
 // 1. 创建 Command
-// 2. 发送到 cmdCh
+// 2. 按优先级发送到对应队列 (管理/查询类先过限速)
 // 3. 等待结果 (可选)
 //
 // 参数 timeout: 等待结果的超时时间，0 表示不等待
 func (s *Shard) Submit(cmd Command, timeout time.Duration) error {
-	// 创建结果通道
+	priority := cmd.Type.Priority()
+	if priority == PriorityAdmin && !s.adminLimit.allow() {
+		s.rateLimited.Add(1)
+		return ErrRateLimited
+	}
+	cmdCh := s.queues[priority]
+
+	// 结果通道从池里取 (见 getResultChan)
 	if timeout > 0 {
-		cmd.Result = make(chan error, 1)
+		cmd.Result = getResultChan()
 	}
 
 	// 发送命令
 	select {
-	case s.cmdCh <- cmd:
+	case cmdCh <- cmd:
 		// 发送成功
 	case <-s.ctx.Done():
 		return ErrShardClosed
@@ -667,7 +969,7 @@ func (s *Shard) Submit(cmd Command, timeout time.Duration) error {
 		// 队列满，可以选择阻塞或拒绝
 		// 这里选择阻塞等待
 		select {
-		case s.cmdCh <- cmd:
+		case cmdCh <- cmd:
 		case <-s.ctx.Done():
 			return ErrShardClosed
 		}
@@ -675,15 +977,77 @@ func (s *Shard) Submit(cmd Command, timeout time.Duration) error {
 
 	// 等待结果
 	if timeout > 0 {
+		timer := getTimer(timeout)
+		defer timerPool.Put(timer)
 		select {
 		case err := <-cmd.Result:
+			timer.Stop()
+			resultChanPool.Put(cmd.Result)
 			return err
-		case <-time.After(timeout):
+		case <-timer.C:
 			return ErrCommandTimeout
 		case <-s.ctx.Done():
+			timer.Stop()
 			return ErrShardClosed
 		}
 	}
 
 	return nil
 }
+
+// =============================================================================
+// 结果通道 / 定时器复用
+// =============================================================================
+//
+// 每条等待结果的命令都要一个结果通道和一个超时定时器，成交结算每笔 2~4 条命令，
+// 放进池里复用。结果通道只有收到结果后才放回：超时或分片关闭时命令可能还会执行，
+// 分片晚到的结果会写进通道，这种通道直接丢弃，不能给下一条命令用
+
+var resultChanPool = sync.Pool{New: func() any { return make(chan error, 1) }}
+
+var timerPool sync.Pool
+
+func getResultChan() chan error {
+	return resultChanPool.Get().(chan error)
+}
+
+// getTimer 取一个已启动的定时器 (Go 1.23 起 Stop/Reset 后不会残留过期信号，可以直接复用)
+func getTimer(d time.Duration) *time.Timer {
+	if t, ok := timerPool.Get().(*time.Timer); ok {
+		t.Reset(d)
+		return t
+	}
+	return time.NewTimer(d)
+}
+
+// GetBalance 一致性读取单个资产余额（冻结/解冻优先级，不限速）
+func (s *Shard) GetBalance(userID int64, symbol string, timeout time.Duration) (Asset, error) {
+	snap, err := s.read(Command{Type: CmdGetBalance, UserID: userID, Symbol: symbol}, timeout)
+	if err != nil {
+		return Asset{}, err
+	}
+	return snap.Assets[symbol], nil
+}
+
+// GetUserState 一致性读取用户完整状态（管理/查询优先级）
+func (s *Shard) GetUserState(userID int64, timeout time.Duration) (*Snapshot, error) {
+	return s.read(Command{Type: CmdGetUserState, UserID: userID}, timeout)
+}
+
+func (s *Shard) read(cmd Command, timeout time.Duration) (*Snapshot, error) {
+	if timeout <= 0 {
+		return nil, ErrCommandTimeout // 读必须等结果
+	}
+	// 结果走带缓冲的 channel：超时后命令仍可能执行，不能写调用方的变量
+	cmd.Reply = make(chan *Snapshot, 1)
+	if err := s.Submit(cmd, timeout); err != nil {
+		return nil, err
+	}
+	return <-cmd.Reply, nil
+}
+
+// Query 在分片线程内执行只读查询（管理/查询优先级）
+// fn 在分片 goroutine 中调用，可以安全遍历 users，但不能修改，也不能持有引用
+func (s *Shard) Query(fn func(users map[int64]*UserState), timeout time.Duration) error {
+	return s.Submit(Command{Type: CmdQuery, Query: fn}, timeout)
+}
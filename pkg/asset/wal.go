@@ -5,6 +5,9 @@
 // 1. 先写日志，再修改内存
 // 2. 崩溃后通过重放日志恢复状态
 // 3. 定期创建检查点减少恢复时间
+//
+// 存储经 walstore.Store 读写 (默认本地目录)；配置归档后，检查点时旧日志封存为
+// asset_<序列号>.wal、快照复制为 snapshot_<序列号>.bin 放进归档，由 walstore.Archiver 上传对象存储
 
 package asset
 
@@ -15,10 +18,13 @@ import (
 	"fmt"
 	"hash/crc32"
 	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"max.com/pkg/walstore"
 )
 
 // =============================================================================
@@ -29,12 +35,14 @@ import (
 type WALEntryType uint8
 
 const (
-	WALReserve       WALEntryType = iota + 1 // 冻结
-	WALRelease                               // 解冻
-	WALTransfer                              // 划转
-	WALAddBalance                            // 增加余额
-	WALDeductBalance                         // 扣减余额
-	WALCheckpoint                            // 检查点
+	WALReserve        WALEntryType = iota + 1 // 冻结
+	WALRelease                                // 解冻
+	WALTransfer                               // 划转
+	WALAddBalance                             // 增加余额
+	WALDeductBalance                          // 扣减余额
+	WALCheckpoint                             // 检查点
+	WALFeeSettle                              // 手续费结算 (追加在末尾，保持已有编号不变)
+	WALTransferRevert                         // 撤销划转
 )
 
 // WALEntry WAL 条目
@@ -42,7 +50,7 @@ type WALEntry struct {
 	Seq       uint64       // 序列号 (递增)
 	Type      WALEntryType // 条目类型
 	Timestamp int64        // 时间戳
-	CmdID     string       // 幂等键
+	Key       CmdKey       // 幂等键 (按文本格式落盘，见 cmd_key.go)
 
 	// 操作参数
 	UserID   int64
@@ -61,12 +69,14 @@ type WALEntry struct {
 
 // WAL Write-Ahead Log
 type WAL struct {
-	dir    string   // 日志目录
-	file   *os.File // 当前日志文件
-	writer *bufio.Writer
+	store   walstore.Store   // 活动日志 asset.wal、快照
+	archive walstore.Store   // 封存的旧日志和快照副本，nil 表示检查点时直接清空
+	seg     walstore.Segment // 当前日志文件
+	writer  *bufio.Writer
 
-	seq      uint64    // 当前序列号
-	lastSync time.Time // 上次 fsync 时间
+	seq         uint64    // 当前序列号
+	snapshotSeq uint64    // LoadSnapshot 加载的快照序列号，Recover 只读之后封存的归档
+	lastSync    time.Time // 上次 fsync 时间
 
 	mu  sync.Mutex // 仅用于外部调用
 	buf []byte     // 复用缓冲区
@@ -76,30 +86,55 @@ type WAL struct {
 type WALConfig struct {
 	Dir          string        // 日志目录
 	SyncInterval time.Duration // fsync 间隔
+	ArchiveDir   string        // 检查点时封存旧日志和快照的目录，为空则直接清空
+
+	// Store / Archive 自定义存储 (见 pkg/walstore)，设置后分别忽略 Dir / ArchiveDir
+	Store   walstore.Store
+	Archive walstore.Store
 }
 
+// 文件名
+const (
+	walFileName      = "asset.wal"
+	snapshotFileName = "snapshot.bin"
+	metaFileName     = "checkpoint.meta"
+)
+
 // NewWAL 创建 WAL
 func NewWAL(cfg WALConfig) (*WAL, error) {
-	// 创建目录
-	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
-		return nil, fmt.Errorf("create wal dir: %w", err)
+	store, archive := cfg.Store, cfg.Archive
+	var err error
+	if store == nil {
+		if store, err = walstore.NewLocal(cfg.Dir); err != nil {
+			return nil, fmt.Errorf("create wal dir: %w", err)
+		}
+	}
+	if archive == nil && cfg.ArchiveDir != "" {
+		if archive, err = walstore.NewLocal(cfg.ArchiveDir); err != nil {
+			return nil, fmt.Errorf("create wal archive dir: %w", err)
+		}
 	}
 
 	// 打开日志文件
-	path := filepath.Join(cfg.Dir, "asset.wal")
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	seg, err := store.Append(walFileName)
 	if err != nil {
 		return nil, fmt.Errorf("open wal file: %w", err)
 	}
 
 	return &WAL{
-		dir:    cfg.Dir,
-		file:   file,
-		writer: bufio.NewWriterSize(file, 64*1024), // 64KB 缓冲
-		buf:    make([]byte, 512),
+		store:   store,
+		archive: archive,
+		seg:     seg,
+		writer:  bufio.NewWriterSize(seg, 64*1024), // 64KB 缓冲
+		buf:     make([]byte, 512),
 	}, nil
 }
 
+// Archive 归档存储，未配置归档时为 nil
+func (w *WAL) Archive() walstore.Store {
+	return w.archive
+}
+
 // Write 写入条目
 func (w *WAL) Write(entry *WALEntry) error {
 	w.mu.Lock()
@@ -112,28 +147,15 @@ func (w *WAL) Write(entry *WALEntry) error {
 		entry.Timestamp = time.Now().UnixNano()
 	}
 
-	// 序列化
-	data, err := w.encodeEntry(entry)
-	if err != nil {
-		return err
-	}
-
-	// 写入长度 + 数据 + CRC
-	length := uint32(len(data))
-	crc := crc32.ChecksumIEEE(data)
+	// 整帧 [长度 4B][数据][CRC 4B] 编码到复用缓冲区，一次写入，不分配
+	frame := w.encodeEntry(w.buf[:4], entry)
+	data := frame[4:]
+	binary.LittleEndian.PutUint32(frame, uint32(len(data)))
+	frame = binary.LittleEndian.AppendUint32(frame, crc32.ChecksumIEEE(data))
+	w.buf = frame[:0] // 扩容后保留，下次复用
 
-	// 写入: [长度 4B][数据][CRC 4B]
-	if err := binary.Write(w.writer, binary.LittleEndian, length); err != nil {
-		return err
-	}
-	if _, err := w.writer.Write(data); err != nil {
-		return err
-	}
-	if err := binary.Write(w.writer, binary.LittleEndian, crc); err != nil {
-		return err
-	}
-
-	return nil
+	_, err := w.writer.Write(frame)
+	return err
 }
 
 // =============================================================================
@@ -147,37 +169,94 @@ func (w *WAL) Checkpoint(snapshotData []byte, upToSeq uint64) error {
 	defer w.mu.Unlock()
 
 	// 1. 写入快照文件
-	snapshotPath := filepath.Join(w.dir, "snapshot.bin")
-	if err := os.WriteFile(snapshotPath, snapshotData, 0644); err != nil {
+	if err := walstore.WriteFile(w.store, snapshotFileName, snapshotData); err != nil {
 		return fmt.Errorf("write snapshot: %w", err)
 	}
 
 	// 2. 记录检查点序列号
-	metaPath := filepath.Join(w.dir, "checkpoint.meta")
 	meta := fmt.Sprintf("%d", upToSeq)
-	if err := os.WriteFile(metaPath, []byte(meta), 0644); err != nil {
+	if err := walstore.WriteFile(w.store, metaFileName, []byte(meta)); err != nil {
 		return fmt.Errorf("write meta: %w", err)
 	}
 
-	// 3. 截断 WAL (可选: 创建新文件)
-	// 这里简化处理：直接清空 WAL
+	// 3. 截断 WAL：配置了归档时旧日志封存进归档 (快照也放一份)，否则直接清空
 	w.writer.Flush()
-	w.file.Close()
+	w.seg.Close()
+
+	if w.archive != nil {
+		if err := walstore.Move(w.store, walFileName, w.archive, archivedName("asset_", upToSeq, ".wal")); err != nil {
+			return fmt.Errorf("archive wal: %w", err)
+		}
+		if err := walstore.WriteFile(w.archive, archivedName("snapshot_", upToSeq, ".bin"), snapshotData); err != nil {
+			return fmt.Errorf("archive snapshot: %w", err)
+		}
+	}
 
-	walPath := filepath.Join(w.dir, "asset.wal")
-	w.file, _ = os.Create(walPath)
-	w.writer = bufio.NewWriterSize(w.file, 64*1024)
+	seg, err := w.store.Create(walFileName)
+	if err != nil {
+		return fmt.Errorf("create wal file: %w", err)
+	}
+	w.seg = seg
+	w.writer = bufio.NewWriterSize(seg, 64*1024)
 
 	return nil
 }
 
+// archivedName 归档文件名：序列号补零，按名字排序即按序列号排序
+func archivedName(prefix string, seq uint64, suffix string) string {
+	return fmt.Sprintf("%s%020d%s", prefix, seq, suffix)
+}
+
+// archivedSeq 从归档文件名里取序列号
+func archivedSeq(name, prefix, suffix string) (uint64, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+	return seq, err == nil
+}
+
 // LoadSnapshot 加载快照
+//
+// 配置了归档时取本地和归档里序列号更大的那个 (本地快照丢失时用归档副本)
 func (w *WAL) LoadSnapshot() ([]byte, uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, seq, err := w.loadLocalSnapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+	if w.archive != nil {
+		names, err := w.archive.List("snapshot_")
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := len(names) - 1; i >= 0; i-- {
+			archived, ok := archivedSeq(names[i], "snapshot_", ".bin")
+			if !ok {
+				continue
+			}
+			if archived > seq || data == nil {
+				if data, err = walstore.ReadFile(w.archive, names[i]); err != nil {
+					return nil, 0, err
+				}
+				seq = archived
+			}
+			break
+		}
+	}
+
+	w.snapshotSeq = seq
+	w.seq = max(w.seq, seq)
+	return data, seq, nil
+}
+
+func (w *WAL) loadLocalSnapshot() ([]byte, uint64, error) {
 	// 读取检查点序列号
-	metaPath := filepath.Join(w.dir, "checkpoint.meta")
-	metaData, err := os.ReadFile(metaPath)
+	metaData, err := walstore.ReadFile(w.store, metaFileName)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return nil, 0, nil // 没有快照
 		}
 		return nil, 0, err
@@ -187,8 +266,7 @@ func (w *WAL) LoadSnapshot() ([]byte, uint64, error) {
 	fmt.Sscanf(string(metaData), "%d", &seq)
 
 	// 读取快照数据
-	snapshotPath := filepath.Join(w.dir, "snapshot.bin")
-	data, err := os.ReadFile(snapshotPath)
+	data, err := walstore.ReadFile(w.store, snapshotFileName)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -204,7 +282,7 @@ func (w *WAL) Sync() error {
 	if err := w.writer.Flush(); err != nil {
 		return err
 	}
-	return w.file.Sync()
+	return w.seg.Sync()
 }
 
 // Close 关闭
@@ -213,7 +291,7 @@ func (w *WAL) Close() error {
 	defer w.mu.Unlock()
 
 	w.writer.Flush()
-	return w.file.Close()
+	return w.seg.Close()
 }
 
 // GetSequence 获取当前序列号
@@ -225,20 +303,21 @@ func (w *WAL) GetSequence() uint64 {
 // 序列化
 // =============================================================================
 
-func (w *WAL) encodeEntry(e *WALEntry) ([]byte, error) {
+// encodeEntry 把条目追加到 buf
+func (w *WAL) encodeEntry(buf []byte, e *WALEntry) []byte {
 	// 简单的二进制序列化
 	// 格式: seq(8) + type(1) + ts(8) + cmdID_len(2) + cmdID + userID(8) + ...
 
-	buf := w.buf[:0]
-
 	// 固定字段
 	buf = binary.LittleEndian.AppendUint64(buf, e.Seq)
 	buf = append(buf, byte(e.Type))
 	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.Timestamp))
 
-	// CmdID (变长)
-	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(e.CmdID)))
-	buf = append(buf, e.CmdID...)
+	// 幂等键 (变长文本)：先占长度位，追加完再回填
+	keyAt := len(buf)
+	buf = append(buf, 0, 0)
+	buf = e.Key.AppendTo(buf)
+	binary.LittleEndian.PutUint16(buf[keyAt:], uint16(len(buf)-keyAt-2))
 
 	// 操作参数
 	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.UserID))
@@ -253,7 +332,7 @@ func (w *WAL) encodeEntry(e *WALEntry) ([]byte, error) {
 	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(e.FeeAsset)))
 	buf = append(buf, e.FeeAsset...)
 
-	return buf, nil
+	return buf
 }
 
 // =============================================================================
@@ -261,14 +340,42 @@ func (w *WAL) encodeEntry(e *WALEntry) ([]byte, error) {
 // =============================================================================
 
 // Recover 恢复：读取 WAL 并重放
+//
+// 配置了归档时先重放快照 (LoadSnapshot) 之后封存的归档日志，再重放活动日志
 func (w *WAL) Recover(applyFn func(*WALEntry) error) (uint64, error) {
-	// 回到文件开头
-	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
-		return 0, err
+	var lastSeq uint64
+	if w.archive != nil {
+		names, err := w.archive.List("asset_")
+		if err != nil {
+			return 0, err
+		}
+		for _, name := range names {
+			if seq, ok := archivedSeq(name, "asset_", ".wal"); !ok || seq <= w.snapshotSeq {
+				continue
+			}
+			if lastSeq, err = w.replay(w.archive, name, lastSeq, applyFn); err != nil {
+				return lastSeq, fmt.Errorf("%s: %w", name, err)
+			}
+		}
 	}
 
-	reader := bufio.NewReader(w.file)
-	var lastSeq uint64
+	lastSeq, err := w.replay(w.store, walFileName, lastSeq, applyFn)
+	w.seq = max(w.seq, lastSeq)
+	return lastSeq, err
+}
+
+// replay 重放一个日志文件，返回最后一条的序列号 (文件为空时原样返回 lastSeq)
+func (w *WAL) replay(store walstore.Store, name string, lastSeq uint64, applyFn func(*WALEntry) error) (uint64, error) {
+	r, err := store.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return lastSeq, nil
+		}
+		return lastSeq, err
+	}
+	defer r.Close()
+
+	reader := bufio.NewReader(r)
 	var count int
 
 	for {
@@ -313,7 +420,6 @@ func (w *WAL) Recover(applyFn func(*WALEntry) error) (uint64, error) {
 		count++
 	}
 
-	w.seq = lastSeq
 	return lastSeq, nil
 }
 
@@ -333,10 +439,10 @@ func (w *WAL) decodeEntry(data []byte) (*WALEntry, error) {
 	e.Timestamp = int64(binary.LittleEndian.Uint64(data[offset:]))
 	offset += 8
 
-	// CmdID
+	// 幂等键
 	cmdIDLen := int(binary.LittleEndian.Uint16(data[offset:]))
 	offset += 2
-	e.CmdID = string(data[offset : offset+cmdIDLen])
+	e.Key = ParseCmdKey(string(data[offset : offset+cmdIDLen]))
 	offset += cmdIDLen
 
 	// 操作参数
@@ -0,0 +1,323 @@
+// 文件: pkg/asset/write_behind.go
+// 热钱包账户引擎 - 余额异步回写冷库 (write-behind)
+//
+// 为什么需要?
+// - 热钱包状态只在内存 + WAL，磁盘损坏时只能靠完整重放 WAL 恢复
+// - 冷库 (fund.BalanceRepo) 原来只靠 Kafka 流水事件更新，链路长、可能整体落后
+//
+// 做法:
+// 1. 分片在每次余额变动后记录 (UserID, Symbol) → 变更序号 (dirty 集合)
+// 2. WriteBehind 每隔 Interval 通过查询命令到分片线程内取走 dirty 集合和当前余额
+// 3. 按变更序号 (即流水顺序) 依次 UpsertBalance 写入冷库
+// 4. 写失败的条目留在 pending，下一轮与新变动合并重试 (同一余额只保留最新值)
+//
+// 这样冷库落后热状态的时间有上界 (≈ Interval + 一次回写耗时)，
+// 灾难恢复时可以从冷库余额 + 少量 WAL 恢复，而不是只能全量重放
+//
+// 【注意】
+// - 回写的是余额快照而不是流水，流水仍由 DBWriter 写入
+// - 取 dirty 集合走管理/查询优先级，不会挤占成交结算；被限速时本轮跳过该分片
+// - 同一 (UserID, Symbol) 只会出现在一个分片，分片内按序号写即可保证冷库不回退
+
+package asset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fund"
+)
+
+// ErrWriteBehindDisabled 引擎未开启 EngineConfig.WriteBehind
+var ErrWriteBehindDisabled = cexerr.New("ASSET_WRITE_BEHIND_DISABLED", cexerr.CategoryFailedPrecondition, "write-behind not enabled on engine")
+
+// =============================================================================
+// 分片侧: dirty 集合
+// =============================================================================
+
+// balanceKey 用户某资产的余额
+type balanceKey struct {
+	UserID int64
+	Symbol string
+}
+
+// dirtyMark 最近一次变动
+type dirtyMark struct {
+	Seq       uint64 // 分片内变更序号
+	ChangedAt int64  // 变动时间 (纳秒)
+}
+
+// dirtyBalance 待回写的余额
+type dirtyBalance struct {
+	balanceKey
+	dirtyMark
+	Available int64
+	Locked    int64
+}
+
+// markDirty 记录命令影响的余额 (分片线程内调用)
+func (s *Shard) markDirty(cmd Command) {
+	if !s.trackDirty {
+		return
+	}
+	s.changeSeq++
+	mark := dirtyMark{Seq: s.changeSeq, ChangedAt: time.Now().UnixNano()}
+
+	for _, key := range touchedBalances(cmd) {
+		s.dirty[key] = mark
+	}
+}
+
+// takeDirty 取走 dirty 集合并附上当前余额 (分片线程内调用)
+func (s *Shard) takeDirty() []dirtyBalance {
+	if len(s.dirty) == 0 {
+		return nil
+	}
+	out := make([]dirtyBalance, 0, len(s.dirty))
+	for key, mark := range s.dirty {
+		b := dirtyBalance{balanceKey: key, dirtyMark: mark}
+		if user, ok := s.users[key.UserID]; ok {
+			if asset, ok := user.Assets[key.Symbol]; ok {
+				b.Available = asset.Available
+				b.Locked = asset.Locked
+			}
+		}
+		out = append(out, b)
+	}
+	s.dirty = make(map[balanceKey]dirtyMark)
+	return out
+}
+
+// =============================================================================
+// WriteBehind - 回写器
+// =============================================================================
+
+// ColdBalanceStore 冷库余额写入接口，*fund.BalanceRepo 满足此接口
+type ColdBalanceStore interface {
+	UpsertBalance(ctx context.Context, snapshot *fund.BalanceSnapshot) error
+}
+
+// WriteBehindConfig 回写配置
+type WriteBehindConfig struct {
+	Interval time.Duration // 回写间隔，决定冷库最大落后时间 (默认 1s)
+	Timeout  time.Duration // 单轮回写超时 (默认 10s)
+}
+
+// DefaultWriteBehindConfig 默认配置
+func DefaultWriteBehindConfig() WriteBehindConfig {
+	return WriteBehindConfig{
+		Interval: time.Second,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// WriteBehindStats 回写统计
+type WriteBehindStats struct {
+	Flushes     uint64        // 回写轮数
+	Written     uint64        // 写入冷库的余额条数
+	Errors      uint64        // 写入失败次数
+	Pending     int           // 等待重试的条数
+	Lag         time.Duration // 最早一条未写入变动距今的时间，0 表示已追平
+	LastFlushAt time.Time     // 最近一轮回写完成时间
+}
+
+// WriteBehind 把热钱包余额异步回写到冷库
+//
+// 使用示例:
+//
+//	cfg := asset.DefaultEngineConfig()
+//	cfg.WriteBehind = true
+//	engine := asset.NewEngine(cfg)
+//	engine.Start()
+//
+//	wb, _ := asset.NewWriteBehind(engine, fund.NewBalanceRepo(db), asset.DefaultWriteBehindConfig())
+//	wb.Start()
+//	defer wb.Stop()
+type WriteBehind struct {
+	engine *AccountEngine
+	store  ColdBalanceStore
+	config WriteBehindConfig
+
+	mu       sync.Mutex                    // 串行化 Flush
+	pending  []map[balanceKey]dirtyBalance // 按分片，写失败待重试
+	inflight []chan []dirtyBalance         // 按分片，已入队但等待超时的查询
+	stats    WriteBehindStats
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewWriteBehind 创建回写器，引擎需开启 EngineConfig.WriteBehind
+func NewWriteBehind(engine *AccountEngine, store ColdBalanceStore, cfg WriteBehindConfig) (*WriteBehind, error) {
+	if !engine.config.WriteBehind {
+		return nil, ErrWriteBehindDisabled
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	pending := make([]map[balanceKey]dirtyBalance, len(engine.shards))
+	for i := range pending {
+		pending[i] = make(map[balanceKey]dirtyBalance)
+	}
+
+	return &WriteBehind{
+		engine:   engine,
+		store:    store,
+		config:   cfg,
+		pending:  pending,
+		inflight: make([]chan []dirtyBalance, len(engine.shards)),
+		stopCh:   make(chan struct{}),
+	}, nil
+}
+
+// Start 启动定时回写
+func (w *WriteBehind) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stopCh:
+				w.flushWithTimeout() // 最后回写一次
+				return
+			case <-ticker.C:
+				w.flushWithTimeout()
+			}
+		}
+	}()
+}
+
+// Stop 停止回写 (会做最后一轮回写)，需在引擎 Stop 之前调用
+func (w *WriteBehind) Stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+func (w *WriteBehind) flushWithTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.Timeout)
+	defer cancel()
+	if err := w.Flush(ctx); err != nil {
+		fmt.Printf("[WriteBehind] flush error: %v\n", err)
+	}
+}
+
+// Flush 立即回写一轮
+// 某个分片写失败不影响其他分片，返回所有分片的错误
+func (w *WriteBehind) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	for i, shard := range w.engine.shards {
+		if err := w.flushShard(ctx, i, shard); err != nil {
+			errs = append(errs, fmt.Errorf("shard %d: %w", i, err))
+		}
+	}
+
+	w.stats.Flushes++
+	w.stats.LastFlushAt = time.Now()
+	return errors.Join(errs...)
+}
+
+// flushShard 合并新变动后按变更序号写入冷库，遇错即停 (保证同分片内有序)
+func (w *WriteBehind) flushShard(ctx context.Context, idx int, shard *Shard) error {
+	collected, err := w.collect(idx, shard)
+	pending := w.pending[idx]
+	for _, b := range collected {
+		pending[b.balanceKey] = b // 新值覆盖旧的待重试值
+	}
+	if err != nil {
+		return fmt.Errorf("collect dirty balances: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	batch := make([]dirtyBalance, 0, len(pending))
+	for _, b := range pending {
+		batch = append(batch, b)
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Seq < batch[j].Seq })
+
+	for _, b := range batch {
+		snapshot := &fund.BalanceSnapshot{
+			EventID:   fmt.Sprintf("wb_%d_%d", idx, b.Seq),
+			UserID:    b.UserID,
+			Symbol:    b.Symbol,
+			Available: b.Available,
+			Locked:    b.Locked,
+			UpdatedAt: time.Unix(0, b.ChangedAt),
+		}
+		if err := w.store.UpsertBalance(ctx, snapshot); err != nil {
+			w.stats.Errors++
+			return fmt.Errorf("upsert user=%d symbol=%s: %w", b.UserID, b.Symbol, err)
+		}
+		delete(pending, b.balanceKey)
+		w.stats.Written++
+	}
+	return nil
+}
+
+// collect 取走分片的 dirty 集合
+// 运行中走 CmdQuery 在分片线程内读取；未启动时分片线程不存在，直接读
+func (w *WriteBehind) collect(idx int, shard *Shard) ([]dirtyBalance, error) {
+	// 上一轮超时的查询仍在队列里：它执行时会取走 dirty 集合，结果不能丢
+	if out := w.inflight[idx]; out != nil {
+		select {
+		case collected := <-out:
+			w.inflight[idx] = nil
+			return collected, nil
+		default:
+			return nil, ErrCommandTimeout
+		}
+	}
+
+	if !w.engine.running.Load() {
+		return shard.takeDirty(), nil
+	}
+
+	out := make(chan []dirtyBalance, 1)
+	err := shard.Query(func(map[int64]*UserState) {
+		out <- shard.takeDirty()
+	}, w.engine.config.DefaultTimeout)
+	if err == ErrCommandTimeout {
+		w.inflight[idx] = out
+	}
+	if err != nil {
+		return nil, err
+	}
+	return <-out, nil
+}
+
+// Stats 回写统计
+func (w *WriteBehind) Stats() WriteBehindStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	var oldest int64
+	for _, pending := range w.pending {
+		stats.Pending += len(pending)
+		for _, b := range pending {
+			if oldest == 0 || b.ChangedAt < oldest {
+				oldest = b.ChangedAt
+			}
+		}
+	}
+	if oldest > 0 {
+		stats.Lag = time.Since(time.Unix(0, oldest))
+	}
+	return stats
+}
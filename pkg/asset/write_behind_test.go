@@ -0,0 +1,163 @@
+// 文件: pkg/asset/write_behind_test.go
+// 余额回写冷库测试 (内存冷库，不依赖 MySQL)
+
+package asset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"max.com/pkg/fund"
+)
+
+// memColdStore 内存冷库，记录写入顺序
+type memColdStore struct {
+	mu       sync.Mutex
+	balances map[balanceKey]fund.BalanceSnapshot
+	writes   []balanceKey
+	fail     error
+}
+
+func newMemColdStore() *memColdStore {
+	return &memColdStore{balances: make(map[balanceKey]fund.BalanceSnapshot)}
+}
+
+func (m *memColdStore) UpsertBalance(_ context.Context, s *fund.BalanceSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	key := balanceKey{s.UserID, s.Symbol}
+	m.balances[key] = *s
+	m.writes = append(m.writes, key)
+	return nil
+}
+
+func (m *memColdStore) get(userID int64, symbol string) (fund.BalanceSnapshot, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.balances[balanceKey{userID, symbol}]
+	return s, ok
+}
+
+func TestWriteBehind_Flush(t *testing.T) {
+	if _, err := NewWriteBehind(NewEngine(DefaultEngineConfig()), newMemColdStore(), DefaultWriteBehindConfig()); err != ErrWriteBehindDisabled {
+		t.Fatalf("expected ErrWriteBehindDisabled, got %v", err)
+	}
+
+	cfg := DefaultEngineConfig()
+	cfg.NumShards = 2
+	cfg.WriteBehind = true
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	store := newMemColdStore()
+	wb, err := NewWriteBehind(engine, store, DefaultWriteBehindConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 买方 1 (分片 1)、卖方 2 (分片 0)
+	deposit(t, engine, 1, "USDT", 1000*Precision)
+	deposit(t, engine, 2, "BTC", 1*Precision)
+	if err := engine.Reserve(1, "USDT", 500*Precision, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reserve(2, "BTC", 1*Precision, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ApplyFill(&FillEvent{
+		TradeID: 1, BuyerID: 1, SellerID: 2,
+		BaseAsset: "BTC", QuoteAsset: "USDT",
+		Price: 500 * Precision, Quantity: 1 * Precision,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	want := map[balanceKey][2]int64{ // {available, locked}
+		{1, "USDT"}: {500 * Precision, 0},
+		{1, "BTC"}:  {1 * Precision, 0},
+		{2, "BTC"}:  {0, 0},
+		{2, "USDT"}: {500 * Precision, 0},
+	}
+	for key, bal := range want {
+		got, ok := store.get(key.UserID, key.Symbol)
+		if !ok || got.Available != bal[0] || got.Locked != bal[1] {
+			t.Errorf("%v: expected %v, got %+v (found=%v)", key, bal, got, ok)
+		}
+	}
+	if stats := wb.Stats(); stats.Written != 4 || stats.Pending != 0 || stats.Lag != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// 没有新变动时不重复写
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.writes); n != 4 {
+		t.Errorf("expected no rewrites, got %d writes", n)
+	}
+}
+
+func TestWriteBehind_RetryAfterFailure(t *testing.T) {
+	cfg := DefaultEngineConfig()
+	cfg.NumShards = 1
+	cfg.WriteBehind = true
+	engine := NewEngine(cfg)
+	engine.Start()
+	defer engine.Stop()
+
+	store := newMemColdStore()
+	store.fail = errors.New("db down")
+	wb, _ := NewWriteBehind(engine, store, DefaultWriteBehindConfig())
+
+	deposit(t, engine, 1, "USDT", 100)
+	if err := wb.Flush(context.Background()); err == nil {
+		t.Fatal("expected flush error while store is down")
+	}
+	if stats := wb.Stats(); stats.Pending != 1 || stats.Errors != 1 || stats.Lag <= 0 {
+		t.Errorf("expected 1 pending balance with lag, got %+v", stats)
+	}
+
+	// 恢复前又有新变动：合并后只写最新值
+	deposit(t, engine, 1, "USDT", 50)
+	deposit(t, engine, 3, "USDT", 10)
+	store.fail = nil
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("flush after recovery: %v", err)
+	}
+
+	if got, _ := store.get(1, "USDT"); got.Available != 150 {
+		t.Errorf("expected latest balance 150, got %+v", got)
+	}
+	// 按变更序号写：用户 1 最后一次变动早于用户 3
+	if len(store.writes) != 2 || store.writes[0] != (balanceKey{1, "USDT"}) {
+		t.Errorf("unexpected write order %v", store.writes)
+	}
+	if stats := wb.Stats(); stats.Pending != 0 || stats.Lag != 0 {
+		t.Errorf("expected caught up, got %+v", stats)
+	}
+}
+
+func deposit(t *testing.T, engine *AccountEngine, userID int64, symbol string, amount int64) {
+	t.Helper()
+	seq := engine.nextSequence()
+	if err := engine.ApplyBalanceChange(&BalanceChangeEvent{
+		EventType: "DEPOSIT",
+		EventID:   fmt.Sprintf("wb_deposit_%d", seq),
+		UserID:    userID,
+		Symbol:    symbol,
+		Amount:    amount,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
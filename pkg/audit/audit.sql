@@ -0,0 +1,20 @@
+-- 审计日志表 (只追加)
+-- 写入账号只授予 INSERT / SELECT，禁止 UPDATE / DELETE
+
+CREATE TABLE IF NOT EXISTS `audit_log` (
+    `seq` BIGINT UNSIGNED NOT NULL PRIMARY KEY COMMENT '链上序号，由 Logger 分配',
+    `timestamp` BIGINT NOT NULL COMMENT '毫秒',
+    `actor_type` VARCHAR(16) NOT NULL COMMENT 'USER/ADMIN/SYSTEM',
+    `actor_id` BIGINT NOT NULL DEFAULT 0,
+    `action` VARCHAR(32) NOT NULL COMMENT 'BALANCE_CHANGE/ORDER_PLACE/ORDER_CANCEL/LIQUIDATION/ADMIN',
+    `resource` VARCHAR(128) NOT NULL COMMENT '如 balance:1001:USDT / order:123',
+    `before_state` BLOB NULL COMMENT '变更前 (JSON 原始字节，不能用 JSON 类型)',
+    `after_state` BLOB NULL COMMENT '变更后',
+    `meta` BLOB NULL,
+    `prev_hash` CHAR(64) NOT NULL,
+    `hash` CHAR(64) NOT NULL,
+    KEY `idx_timestamp` (`timestamp`),
+    KEY `idx_actor` (`actor_id`, `seq`),
+    KEY `idx_action` (`action`, `seq`),
+    KEY `idx_resource` (`resource`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '审计日志 (哈希链)';
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func record(l *Logger, n int) {
+	for i := 0; i < n; i++ {
+		l.Record(Event{
+			ActorType: ActorUser,
+			ActorID:   int64(i%3 + 1),
+			Action:    ActionOrderPlace,
+			Resource:  "order:" + string(rune('a'+i)),
+			After:     map[string]int{"qty": i},
+			Meta:      map[string]string{"i": "x"},
+		})
+	}
+}
+
+func TestLogger_ChainAndResume(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	l, err := NewLogger(ctx, store, Config{BatchSize: 2, FlushInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(l, 5)
+	l.Close()
+	if written, _ := l.Stats(); written != 5 {
+		t.Fatalf("expected 5 written, got %d", written)
+	}
+
+	// 重启后从存储的最后一条接上链
+	l, _ = NewLogger(ctx, store, Config{})
+	record(l, 3)
+	seq, head := l.Head()
+	l.Close()
+
+	n, verified, err := VerifyStore(ctx, store)
+	if err != nil || n != 8 || seq != 8 || verified != head {
+		t.Fatalf("expected intact chain of 8 ending at %s, got n=%d head=%s err=%v", head, n, verified, err)
+	}
+
+	// 关闭后不再接收
+	l.Record(Event{Action: ActionAdmin})
+	if _, dropped := l.Stats(); dropped != 1 {
+		t.Errorf("expected event after close to be dropped, got %d", dropped)
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	l, _ := NewLogger(ctx, store, Config{})
+	record(l, 4)
+	l.Close()
+
+	entries, _ := store.Query(ctx, Query{})
+	if _, err := Verify(entries, GenesisHash); err != nil {
+		t.Fatalf("untouched chain should verify: %v", err)
+	}
+
+	cases := map[string]func([]Entry) []Entry{
+		"modify": func(es []Entry) []Entry { es[1].After = []byte(`{"qty":100}`); return es },
+		"delete": func(es []Entry) []Entry { return append(es[:1], es[2:]...) },
+		"rehash": func(es []Entry) []Entry {
+			// 改内容并重算本条哈希，下一条的 PrevHash 对不上
+			es[1].ActorID = 99
+			es[1].Hash = computeHash(es[1].PrevHash, &es[1])
+			return es
+		},
+	}
+	for name, tamper := range cases {
+		copied := append([]Entry(nil), entries...)
+		if _, err := Verify(tamper(copied), GenesisHash); !errors.Is(err, ErrChainBroken) {
+			t.Errorf("%s: expected ErrChainBroken, got %v", name, err)
+		}
+	}
+}
+
+func TestStore_QueryAndExport(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	l, _ := NewLogger(ctx, store, Config{})
+	record(l, 7)
+	l.Record(Event{ActorType: ActorAdmin, ActorID: 500, Action: ActionAdmin, Resource: "insurance_fund:USDT"})
+	l.Close()
+
+	got, _ := store.Query(ctx, Query{ActorID: 1, Action: ActionOrderPlace})
+	if len(got) != 3 || got[0].Seq != 1 || got[2].Seq != 7 {
+		t.Fatalf("expected seq 1,4,7 for actor 1, got %+v", got)
+	}
+	got, _ = store.Query(ctx, Query{ResourcePrefix: "insurance_fund:"})
+	if len(got) != 1 || got[0].ActorType != ActorAdmin {
+		t.Fatalf("expected admin entry, got %+v", got)
+	}
+
+	// 分页导出：每页 3 条
+	var buf bytes.Buffer
+	n, err := Export(ctx, store, Query{Limit: 3}, &buf)
+	if err != nil || n != 8 || strings.Count(buf.String(), "\n") != 8 {
+		t.Fatalf("expected 8 exported lines, got n=%d err=%v", n, err)
+	}
+}
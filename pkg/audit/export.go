@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// =============================================================================
+// 合规导出与校验
+// =============================================================================
+
+// Export 按条件把记录逐页写成 JSON Lines，返回导出条数
+//
+// 导出内容带 Seq / PrevHash / Hash，接收方可以自己用 Verify 复核
+// (按条件过滤后的结果不连续，只能逐条核对哈希；要证明完整性需导出不带过滤的区间)
+func Export(ctx context.Context, store Store, q Query, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	total := 0
+	for {
+		entries, err := store.Query(ctx, q)
+		if err != nil {
+			return total, err
+		}
+		for i := range entries {
+			if err := enc.Encode(&entries[i]); err != nil {
+				return total, err
+			}
+		}
+		total += len(entries)
+		if len(entries) < q.limit() {
+			return total, nil
+		}
+		q.AfterSeq = entries[len(entries)-1].Seq
+	}
+}
+
+// VerifyStore 从头校验整条链，返回校验过的条数与链头哈希
+func VerifyStore(ctx context.Context, store Store) (int, string, error) {
+	q := Query{Limit: MaxQueryLimit}
+	prevHash := GenesisHash
+	var expectSeq uint64 = 1
+	total := 0
+	for {
+		entries, err := store.Query(ctx, q)
+		if err != nil {
+			return total, prevHash, err
+		}
+		if len(entries) > 0 && entries[0].Seq != expectSeq {
+			return total, prevHash, fmt.Errorf("%w: expected seq %d, got %d", ErrChainBroken, expectSeq, entries[0].Seq)
+		}
+		if prevHash, err = Verify(entries, prevHash); err != nil {
+			return total, prevHash, err
+		}
+		total += len(entries)
+		if len(entries) < q.limit() {
+			return total, prevHash, nil
+		}
+		q.AfterSeq = entries[len(entries)-1].Seq
+		expectSeq = q.AfterSeq + 1
+	}
+}
+
+// =============================================================================
+// HTTP 接口 (仅内网 / 合规后台)
+// =============================================================================
+
+// NewHandler 创建审计查询接口
+//
+//	GET /audit?actor_id=&actor_type=&action=&resource=&from=&to=&after_seq=&limit=
+//	    from/to 为毫秒时间戳，返回 JSON Lines
+//	GET /audit/verify  校验整条链
+func NewHandler(store Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := parseQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		// 分页由客户端用 after_seq 控制，单次最多 limit 条
+		entries, err := store.Query(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		enc := json.NewEncoder(w)
+		for i := range entries {
+			enc.Encode(&entries[i])
+		}
+	})
+	mux.HandleFunc("/audit/verify", func(w http.ResponseWriter, r *http.Request) {
+		n, head, err := VerifyStore(r.Context(), store)
+		resp := struct {
+			OK      bool   `json:"ok"`
+			Entries int    `json:"entries"`
+			Head    string `json:"head"`
+			Error   string `json:"error,omitempty"`
+		}{OK: err == nil, Entries: n, Head: head}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	v := r.URL.Query()
+	q := Query{
+		ActorType:      ActorType(v.Get("actor_type")),
+		Action:         Action(v.Get("action")),
+		ResourcePrefix: v.Get("resource"),
+	}
+	var err error
+	parseInt := func(key string) int64 {
+		s := v.Get(key)
+		if s == "" || err != nil {
+			return 0
+		}
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	q.ActorID = parseInt("actor_id")
+	q.AfterSeq = uint64(parseInt("after_seq"))
+	q.Limit = int(parseInt("limit"))
+	if ms := parseInt("from"); ms > 0 {
+		q.From = time.UnixMilli(ms)
+	}
+	if ms := parseInt("to"); ms > 0 {
+		q.To = time.UnixMilli(ms)
+	}
+	return q, err
+}
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// Logger - 串行分配 Seq / 哈希，异步批量落库
+// =============================================================================
+
+// Config Logger 配置
+type Config struct {
+	BufferSize    int           // 待落库队列长度，满了 Record 阻塞 (默认 10000)
+	BatchSize     int           // 单批最多条数 (默认 500)
+	FlushInterval time.Duration // 最长攒批时间 (默认 100ms)
+	RetryInterval time.Duration // 落库失败重试间隔 (默认 1s)
+}
+
+// Logger 审计日志写入器，实现 Recorder
+//
+// 【设计】
+// Record 在锁内分配 Seq、计算哈希并入队，入队顺序 = Seq 顺序；
+// 后台协程批量写 Store。落库失败不丢弃、不跳号，一直重试，
+// 否则链上出现空洞，之后的记录全部无法校验。
+//
+// 【注意】队列满时 Record 会阻塞调用方 (背压)。
+// 审计宁可拖慢业务也不能静默丢记录；BufferSize 按峰值写入量 × 可容忍的存储抖动时间配置
+type Logger struct {
+	store  Store
+	config Config
+
+	mu       sync.Mutex
+	seq      uint64
+	lastHash string
+	closed   bool
+
+	queue   chan Entry
+	done    chan struct{}
+	dropped atomic.Uint64 // 序列化失败的事件数
+	written atomic.Uint64
+}
+
+// NewLogger 创建 Logger，从 Store 的最后一条接上哈希链
+func NewLogger(ctx context.Context, store Store, cfg Config) (*Logger, error) {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 100 * time.Millisecond
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = time.Second
+	}
+
+	l := &Logger{
+		store:    store,
+		config:   cfg,
+		lastHash: GenesisHash,
+		queue:    make(chan Entry, cfg.BufferSize),
+		done:     make(chan struct{}),
+	}
+	last, err := store.Last(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		l.seq, l.lastHash = last.Seq, last.Hash
+	}
+
+	go l.writeLoop()
+	return l, nil
+}
+
+// Record 记录一条审计事件，实现 Recorder
+func (l *Logger) Record(ev Event) {
+	e, err := newEntry(ev)
+	if err != nil {
+		// 序列化失败是调用方的 bug (传了不可序列化的值)，记日志但不占用 Seq
+		l.dropped.Add(1)
+		log.Printf("[Audit] drop event %s %s: %v", ev.Action, ev.Resource, err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		l.dropped.Add(1)
+		log.Printf("[Audit] drop event %s %s: %v", ev.Action, ev.Resource, ErrLoggerClosed)
+		return
+	}
+	l.seq++
+	e.Seq = l.seq
+	e.PrevHash = l.lastHash
+	e.Hash = computeHash(e.PrevHash, &e)
+	l.lastHash = e.Hash
+	l.queue <- e
+}
+
+// Head 当前链头 (最新 Seq 与 Hash)，用于对外发布
+func (l *Logger) Head() (uint64, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq, l.lastHash
+}
+
+// Stats 已落库 / 丢弃的条数
+func (l *Logger) Stats() (written, dropped uint64) {
+	return l.written.Load(), l.dropped.Load()
+}
+
+// Close 停止接收新事件，等待已入队的全部落库
+func (l *Logger) Close() {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+	<-l.done
+}
+
+func (l *Logger) writeLoop() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, l.config.BatchSize)
+	for {
+		select {
+		case e, ok := <-l.queue:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= l.config.BatchSize {
+				l.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				l.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush 写入一批，失败一直重试 (见 Logger 注释)
+func (l *Logger) flush(batch []Entry) {
+	if len(batch) == 0 {
+		return
+	}
+	for {
+		err := l.store.Append(context.Background(), batch)
+		if err == nil {
+			l.written.Add(uint64(len(batch)))
+			return
+		}
+		log.Printf("[Audit] append seq %d..%d failed, retrying: %v",
+			batch[0].Seq, batch[len(batch)-1].Seq, err)
+		time.Sleep(l.config.RetryInterval)
+	}
+}
@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 存储接口
+// =============================================================================
+
+// Store 审计日志存储 (只追加，不提供修改/删除)
+type Store interface {
+	// Append 按 Seq 顺序追加一批记录
+	Append(ctx context.Context, entries []Entry) error
+	// Last 最后一条记录，空表返回 nil
+	Last(ctx context.Context) (*Entry, error)
+	// Query 按条件查询，结果按 Seq 升序
+	Query(ctx context.Context, q Query) ([]Entry, error)
+}
+
+// Query 查询条件 (零值字段不过滤)
+type Query struct {
+	ActorID        int64
+	ActorType      ActorType
+	Action         Action
+	ResourcePrefix string    // 如 "balance:1001:" 查某用户全部余额变动
+	From, To       time.Time // [From, To)
+	AfterSeq       uint64    // 翻页游标：只返回 Seq > AfterSeq
+	Limit          int       // 0 = DefaultQueryLimit
+}
+
+const (
+	DefaultQueryLimit = 500
+	MaxQueryLimit     = 10000
+)
+
+func (q Query) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultQueryLimit
+	case q.Limit > MaxQueryLimit:
+		return MaxQueryLimit
+	default:
+		return q.Limit
+	}
+}
+
+func (q Query) match(e *Entry) bool {
+	if e.Seq <= q.AfterSeq {
+		return false
+	}
+	if q.ActorID != 0 && e.ActorID != q.ActorID {
+		return false
+	}
+	if q.ActorType != "" && e.ActorType != q.ActorType {
+		return false
+	}
+	if q.Action != "" && e.Action != q.Action {
+		return false
+	}
+	if q.ResourcePrefix != "" && !strings.HasPrefix(e.Resource, q.ResourcePrefix) {
+		return false
+	}
+	if !q.From.IsZero() && e.Timestamp < q.From.UnixMilli() {
+		return false
+	}
+	if !q.To.IsZero() && e.Timestamp >= q.To.UnixMilli() {
+		return false
+	}
+	return true
+}
+
+// =============================================================================
+// MemoryStore - 内存实现 (测试 / 单机演示)
+// =============================================================================
+
+// MemoryStore 内存存储
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append 追加
+func (s *MemoryStore) Append(ctx context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+// Last 最后一条
+func (s *MemoryStore) Last(ctx context.Context) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.entries) == 0 {
+		return nil, nil
+	}
+	e := s.entries[len(s.entries)-1]
+	return &e, nil
+}
+
+// Query 查询
+func (s *MemoryStore) Query(ctx context.Context, q Query) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// entries 按 Seq 有序，游标之前的直接跳过
+	start := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].Seq > q.AfterSeq })
+	limit := q.limit()
+	var out []Entry
+	for i := start; i < len(s.entries) && len(out) < limit; i++ {
+		if q.match(&s.entries[i]) {
+			out = append(out, s.entries[i])
+		}
+	}
+	return out, nil
+}
+
+// =============================================================================
+// GormStore - MySQL 实现
+// =============================================================================
+
+// GormStore MySQL 存储 (表结构见 audit.sql)
+//
+// 【注意】生产环境应给写入账号只授予 INSERT/SELECT 权限，
+// 哈希链只能发现篡改，权限隔离才能防止篡改
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建 MySQL 存储
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Append 批量插入
+func (s *GormStore) Append(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).CreateInBatches(entries, 200).Error
+}
+
+// Last 最后一条
+func (s *GormStore) Last(ctx context.Context) (*Entry, error) {
+	var e Entry
+	err := s.db.WithContext(ctx).Order("seq DESC").Limit(1).Take(&e).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Query 查询
+func (s *GormStore) Query(ctx context.Context, q Query) ([]Entry, error) {
+	db := s.db.WithContext(ctx).Where("seq > ?", q.AfterSeq)
+	if q.ActorID != 0 {
+		db = db.Where("actor_id = ?", q.ActorID)
+	}
+	if q.ActorType != "" {
+		db = db.Where("actor_type = ?", q.ActorType)
+	}
+	if q.Action != "" {
+		db = db.Where("action = ?", q.Action)
+	}
+	if q.ResourcePrefix != "" {
+		db = db.Where("resource LIKE ?", escapeLike(q.ResourcePrefix)+"%")
+	}
+	if !q.From.IsZero() {
+		db = db.Where("timestamp >= ?", q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		db = db.Where("timestamp < ?", q.To.UnixMilli())
+	}
+
+	var entries []Entry
+	err := db.Order("seq ASC").Limit(q.limit()).Find(&entries).Error
+	return entries, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*GormStore)(nil)
+)
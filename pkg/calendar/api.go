@@ -0,0 +1,134 @@
+package calendar
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"max.com/pkg/cexerr"
+)
+
+// =============================================================================
+// 公开接口
+// =============================================================================
+
+// CalendarView 对外发布的日历：交易时段、未结束的维护窗口和当前状态
+type CalendarView struct {
+	Symbol      string    `json:"symbol"`
+	Timezone    string    `json:"timezone,omitempty"`
+	Sessions    []Session `json:"sessions"` // 为空表示全天开放
+	Maintenance []Window  `json:"maintenance"`
+	State       State     `json:"state"`
+}
+
+// View 交易对对外发布的日历，没有日历时为全天开放
+func (s *Service) View(symbol string) CalendarView {
+	v := CalendarView{Symbol: symbol, Sessions: []Session{}, Maintenance: []Window{}, State: s.State(symbol)}
+	c, ok := (*s.calendars.Load())[symbol]
+	if !ok {
+		return v
+	}
+	v.Timezone = c.cal.Timezone
+	if len(c.cal.Sessions) > 0 {
+		v.Sessions = c.cal.Sessions
+	}
+	if w := c.upcoming(s.cfg.Now()); len(w) > 0 {
+		v.Maintenance = w
+	}
+	return v
+}
+
+// NewHandler 创建交易日历公开接口 (只读内存，可以放在公开网关后面)
+//
+//	GET /calendars              全部配置了日历的交易对
+//	GET /calendars?symbol=X     单个交易对 (没有日历时返回全天开放)
+func NewHandler(s *Service) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /calendars", func(w http.ResponseWriter, r *http.Request) {
+		if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+			writeJSON(w, s.View(symbol))
+			return
+		}
+		out := []CalendarView{}
+		for _, c := range s.List() {
+			out = append(out, s.View(c.Symbol))
+		}
+		writeJSON(w, out)
+	})
+	return mux
+}
+
+// =============================================================================
+// 管理后台接口 (仅内网，鉴权由网关负责)
+// =============================================================================
+
+// NewAdminHandler 创建交易日历管理接口
+//
+//	GET    /admin/calendars?symbol=X                  完整日历 (含已结束的维护窗口)，不带 symbol 返回全部
+//	PUT    /admin/calendars                           新建或整体替换 (Calendar)
+//	DELETE /admin/calendars?symbol=X                  删除，交易对恢复 7×24
+//	POST   /admin/calendars/maintenance?symbol=X      排一个维护窗口 (Window，ID 可省略)
+//	DELETE /admin/calendars/maintenance?symbol=X&id=Y 撤销维护窗口
+func NewAdminHandler(s *Service) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/calendars", func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		if symbol == "" {
+			writeJSON(w, s.List())
+			return
+		}
+		c, ok := s.Get(symbol)
+		if !ok {
+			cexerr.WriteHTTP(w, ErrCalendarNotFound)
+			return
+		}
+		writeJSON(w, c)
+	})
+	mux.HandleFunc("PUT /admin/calendars", func(w http.ResponseWriter, r *http.Request) {
+		var c Calendar
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			cexerr.WriteHTTP(w, ErrInvalidCalendar.Wrap(err))
+			return
+		}
+		saved, err := s.Put(r.Context(), c)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		writeJSON(w, saved)
+	})
+	mux.HandleFunc("DELETE /admin/calendars", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Delete(r.Context(), r.URL.Query().Get("symbol")); err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /admin/calendars/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		var win Window
+		if err := json.NewDecoder(r.Body).Decode(&win); err != nil || symbol == "" {
+			cexerr.WriteHTTP(w, ErrInvalidCalendar.Wrapf("symbol and window body required"))
+			return
+		}
+		saved, err := s.AddMaintenance(r.Context(), symbol, win)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		writeJSON(w, saved)
+	})
+	mux.HandleFunc("DELETE /admin/calendars/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if err := s.CancelMaintenance(r.Context(), q.Get("symbol"), q.Get("id")); err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,266 @@
+// Package calendar 按交易对的交易日历：交易时段 + 计划维护窗口
+//
+// 【问题】永续合约 7×24 交易，但交割合约、跟踪传统市场的合约需要固定交易时段
+// (跟着标的市场开收盘)，所有交易对还需要提前公告的维护窗口。
+// 此前只能靠人工改合约状态，到点慢一拍就会在标的休市时继续撮合
+//
+// 【做法】
+//   - Calendar：每周重复的交易时段 (交易对本地时区) + 一次性维护窗口，没有日历的交易对视为 7×24
+//   - 下单入口：处理器下单前调 Service.CheckOpen，休市 / 维护中直接拒单，不冻结资金
+//   - 撮合引擎：Service 定时检查边界，到点对绑定的引擎 Halt / Resume (见 mtrade/halt.go)，
+//     入口检查和引擎暂停之间的竞态由引擎兜底
+//   - 日历通过公开接口发布 (见 api.go)，客户端据此提前撤单、展示休市时间
+//
+// 【判定顺序】维护窗口 → 交易时段 (未配置时段视为全天开放)
+//
+// 【面试】为什么时段存本地时间而不是 UTC？
+// 标的市场按当地时间开收盘，夏令时切换时 UTC 开盘时间会变；存 "America/New_York 09:30"
+// 由 time 包换算，切换前后都正确，存 UTC 每年要人工改两次
+package calendar
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+var (
+	ErrMarketClosed     = cexerr.New("CALENDAR_MARKET_CLOSED", cexerr.CategoryFailedPrecondition, "market is closed")
+	ErrMaintenance      = cexerr.New("CALENDAR_MAINTENANCE", cexerr.CategoryUnavailable, "market under scheduled maintenance")
+	ErrInvalidCalendar  = cexerr.New("CALENDAR_INVALID", cexerr.CategoryInvalidArgument, "invalid trading calendar")
+	ErrCalendarNotFound = cexerr.New("CALENDAR_NOT_FOUND", cexerr.CategoryNotFound, "trading calendar not found")
+	ErrWindowNotFound   = cexerr.New("CALENDAR_WINDOW_NOT_FOUND", cexerr.CategoryNotFound, "maintenance window not found")
+)
+
+// =============================================================================
+// 日历定义
+// =============================================================================
+
+// Phase 交易状态
+type Phase string
+
+const (
+	PhaseOpen        Phase = "OPEN"        // 正常交易
+	PhaseClosed      Phase = "CLOSED"      // 交易时段之外
+	PhaseMaintenance Phase = "MAINTENANCE" // 计划维护中
+)
+
+// Session 每周重复的交易时段 (交易对本地时区)
+type Session struct {
+	Weekday time.Weekday `json:"weekday"` // 开盘所在的星期
+	Open    string       `json:"open"`    // "09:30"
+	Close   string       `json:"close"`   // "16:00"，不晚于 Open 表示跨午夜到次日收盘
+}
+
+// Window 一次性维护窗口 [Start, End)
+type Window struct {
+	ID     string `json:"id"`
+	Start  int64  `json:"start"` // unix ms
+	End    int64  `json:"end"`   // unix ms
+	Reason string `json:"reason,omitempty"`
+}
+
+// Calendar 一个交易对的交易日历
+type Calendar struct {
+	Symbol      string    `gorm:"primaryKey;column:symbol;type:varchar(32)" json:"symbol"`
+	Timezone    string    `gorm:"column:timezone;type:varchar(64)" json:"timezone"`                // IANA 时区，空为 UTC
+	Sessions    []Session `gorm:"column:sessions;serializer:json" json:"sessions,omitempty"`       // 为空表示全天开放
+	Maintenance []Window  `gorm:"column:maintenance;serializer:json" json:"maintenance,omitempty"` // 按 Start 升序
+	UpdatedAt   int64     `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Calendar) TableName() string {
+	return "trading_calendar"
+}
+
+// State 某一时刻的交易状态
+type State struct {
+	Symbol string `json:"symbol"`
+	Phase  Phase  `json:"phase"`
+	Reason string `json:"reason,omitempty"` // 维护原因
+	Until  int64  `json:"until,omitempty"`  // 下一次状态变化的时间 (unix ms)，0 表示一周内不变
+}
+
+// Open 是否可以交易
+func (s State) Open() bool {
+	return s.Phase == PhaseOpen
+}
+
+// Err 不可交易时对应的错误
+func (s State) Err() error {
+	switch s.Phase {
+	case PhaseClosed:
+		return ErrMarketClosed.Wrapf("%s closed until %s", s.Symbol, formatMillis(s.Until))
+	case PhaseMaintenance:
+		return ErrMaintenance.Wrapf("%s under maintenance until %s", s.Symbol, formatMillis(s.Until))
+	}
+	return nil
+}
+
+func formatMillis(ms int64) string {
+	if ms == 0 {
+		return "further notice"
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+// =============================================================================
+// 编译与判定
+// =============================================================================
+
+// compiled 校验并解析过的日历 (Service 缓存的形式)
+type compiled struct {
+	cal      Calendar
+	loc      *time.Location
+	sessions []clock
+}
+
+// clock 解析后的时段，单位：自当日零点起的分钟
+type clock struct {
+	weekday     time.Weekday
+	open, close int
+}
+
+// horizon 计算下一次状态变化时向后看的范围 (时段按周重复，多看一天覆盖跨午夜)
+const horizon = 8 * 24 * time.Hour
+
+// compile 校验日历，维护窗口按开始时间排序
+func compile(c Calendar) (*compiled, error) {
+	if c.Symbol == "" {
+		return nil, ErrInvalidCalendar.Wrapf("symbol required")
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, ErrInvalidCalendar.Wrapf("timezone %q: %v", c.Timezone, err)
+	}
+	out := &compiled{cal: c, loc: loc}
+	for _, s := range c.Sessions {
+		if s.Weekday < time.Sunday || s.Weekday > time.Saturday {
+			return nil, ErrInvalidCalendar.Wrapf("weekday %d", s.Weekday)
+		}
+		open, err := parseClock(s.Open)
+		if err != nil {
+			return nil, err
+		}
+		closeAt, err := parseClock(s.Close)
+		if err != nil {
+			return nil, err
+		}
+		if closeAt <= open {
+			closeAt += 24 * 60 // 跨午夜
+		}
+		out.sessions = append(out.sessions, clock{weekday: s.Weekday, open: open, close: closeAt})
+	}
+	out.cal.Maintenance = slices.Clone(c.Maintenance)
+	for _, w := range out.cal.Maintenance {
+		if w.ID == "" || w.End <= w.Start {
+			return nil, ErrInvalidCalendar.Wrapf("maintenance window %q: end must be after start", w.ID)
+		}
+	}
+	slices.SortFunc(out.cal.Maintenance, func(a, b Window) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	out.cal.Sessions = slices.Clone(c.Sessions)
+	return out, nil
+}
+
+// parseClock "HH:MM" → 分钟，允许 "24:00" 表示当日结束
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 {
+		return 0, ErrInvalidCalendar.Wrapf("time %q: want HH:MM", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, ErrInvalidCalendar.Wrapf("time %q out of range", s)
+	}
+	return h*60 + m, nil
+}
+
+// stateAt t 时刻的状态
+func (c *compiled) stateAt(t time.Time) State {
+	phase, reason := c.phaseAt(t)
+	st := State{Symbol: c.cal.Symbol, Phase: phase, Reason: reason}
+	for _, b := range c.boundaries(t) {
+		if p, _ := c.phaseAt(b); p != phase {
+			st.Until = b.UnixMilli()
+			break
+		}
+	}
+	return st
+}
+
+// phaseAt 维护窗口优先，其次是交易时段
+func (c *compiled) phaseAt(t time.Time) (Phase, string) {
+	ms := t.UnixMilli()
+	for _, w := range c.cal.Maintenance {
+		if w.Start > ms {
+			break
+		}
+		if ms < w.End {
+			return PhaseMaintenance, w.Reason
+		}
+	}
+	if len(c.sessions) == 0 {
+		return PhaseOpen, ""
+	}
+	for _, iv := range c.intervals(t.Add(-24*time.Hour), t) {
+		if !t.Before(iv[0]) && t.Before(iv[1]) {
+			return PhaseOpen, ""
+		}
+	}
+	return PhaseClosed, ""
+}
+
+// intervals [from, to] 之间开盘的时段 (按本地日期逐日展开，夏令时由 time.Date 处理)
+func (c *compiled) intervals(from, to time.Time) [][2]time.Time {
+	var out [][2]time.Time
+	from, to = from.In(c.loc), to.In(c.loc)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, c.loc)
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, s := range c.sessions {
+			if s.weekday != day.Weekday() {
+				continue
+			}
+			open := time.Date(day.Year(), day.Month(), day.Day(), 0, s.open, 0, 0, c.loc)
+			closeAt := time.Date(day.Year(), day.Month(), day.Day(), 0, s.close, 0, 0, c.loc)
+			out = append(out, [2]time.Time{open, closeAt})
+		}
+	}
+	return out
+}
+
+// boundaries t 之后 horizon 内所有可能的状态变化点，升序
+func (c *compiled) boundaries(t time.Time) []time.Time {
+	end := t.Add(horizon)
+	var out []time.Time
+	add := func(b time.Time) {
+		if b.After(t) && !b.After(end) {
+			out = append(out, b)
+		}
+	}
+	for _, iv := range c.intervals(t.Add(-24*time.Hour), end) {
+		add(iv[0])
+		add(iv[1])
+	}
+	for _, w := range c.cal.Maintenance {
+		add(time.UnixMilli(w.Start))
+		add(time.UnixMilli(w.End))
+	}
+	slices.SortFunc(out, func(a, b time.Time) int { return a.Compare(b) })
+	return out
+}
+
+// upcoming 尚未结束的维护窗口
+func (c *compiled) upcoming(now time.Time) []Window {
+	ms := now.UnixMilli()
+	var out []Window
+	for _, w := range c.cal.Maintenance {
+		if w.End > ms {
+			out = append(out, w)
+		}
+	}
+	return out
+}
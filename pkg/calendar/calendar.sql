@@ -0,0 +1,9 @@
+-- 交易日历 (按交易对，见 calendar.go)
+CREATE TABLE IF NOT EXISTS `trading_calendar` (
+    `symbol` VARCHAR(32) NOT NULL COMMENT '交易对 / 合约',
+    `timezone` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'IANA 时区, 空为 UTC',
+    `sessions` JSON COMMENT '每周交易时段: [{"weekday":1,"open":"09:30","close":"16:00"}], 空为全天开放',
+    `maintenance` JSON COMMENT '维护窗口: [{"id":"mw-1","start":ms,"end":ms,"reason":""}]',
+    `updated_at` BIGINT NOT NULL DEFAULT 0 COMMENT '更新时间 (unix ms)',
+    PRIMARY KEY (`symbol`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='交易日历';
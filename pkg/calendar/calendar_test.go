@@ -0,0 +1,202 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// weekdays 周一到周五同一时段
+func weekdays(open, close string) []Session {
+	var out []Session
+	for d := time.Monday; d <= time.Friday; d++ {
+		out = append(out, Session{Weekday: d, Open: open, Close: close})
+	}
+	return out
+}
+
+func TestCalendar_StateAt(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	c, err := compile(Calendar{Symbol: "SPX_USD", Timezone: "America/New_York", Sessions: weekdays("09:30", "16:00")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		at    time.Time
+		phase Phase
+		until time.Time
+	}{
+		// 周一开盘前 → 09:30 开盘
+		{time.Date(2026, 3, 2, 9, 0, 0, 0, ny), PhaseClosed, time.Date(2026, 3, 2, 9, 30, 0, 0, ny)},
+		{time.Date(2026, 3, 2, 9, 30, 0, 0, ny), PhaseOpen, time.Date(2026, 3, 2, 16, 0, 0, 0, ny)},
+		// 周五收盘后 → 下周一开盘
+		{time.Date(2026, 3, 6, 16, 0, 0, 0, ny), PhaseClosed, time.Date(2026, 3, 9, 9, 30, 0, 0, ny)},
+	}
+	for _, tc := range cases {
+		st := c.stateAt(tc.at)
+		if st.Phase != tc.phase || st.Until != tc.until.UnixMilli() {
+			t.Errorf("%s: got %s until %s", tc.at, st.Phase, time.UnixMilli(st.Until).In(ny))
+		}
+	}
+
+	// 夏令时切换后 (3 月 8 日) 本地 09:30 对应的 UTC 提前一小时
+	if p, _ := c.phaseAt(time.Date(2026, 3, 9, 13, 30, 0, 0, time.UTC)); p != PhaseOpen {
+		t.Errorf("09:30 EDT should be open, got %s", p)
+	}
+	if p, _ := c.phaseAt(time.Date(2026, 3, 6, 14, 0, 0, 0, time.UTC)); p != PhaseClosed {
+		t.Errorf("09:00 EST should be closed, got %s", p)
+	}
+
+	// 跨午夜时段 + 维护窗口优先
+	start := time.Date(2026, 3, 3, 1, 0, 0, 0, time.UTC)
+	c, err = compile(Calendar{
+		Symbol:      "NIGHT",
+		Sessions:    []Session{{Weekday: time.Monday, Open: "20:00", Close: "04:00"}},
+		Maintenance: []Window{{ID: "m1", Start: start.UnixMilli(), End: start.Add(time.Hour).UnixMilli(), Reason: "upgrade"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := c.phaseAt(time.Date(2026, 3, 3, 0, 30, 0, 0, time.UTC)); p != PhaseOpen {
+		t.Errorf("overnight session: %s", p)
+	}
+	st := c.stateAt(start.Add(30 * time.Minute))
+	if st.Phase != PhaseMaintenance || st.Reason != "upgrade" || st.Until != start.Add(time.Hour).UnixMilli() {
+		t.Errorf("maintenance state %+v", st)
+	}
+
+	for _, bad := range []Calendar{
+		{Symbol: "X", Timezone: "Mars/Olympus"},
+		{Symbol: "X", Sessions: []Session{{Weekday: time.Monday, Open: "9:30", Close: "16:00"}}},
+		{Symbol: "X", Maintenance: []Window{{ID: "m", Start: 2, End: 1}}},
+	} {
+		if _, err := compile(bad); !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("%+v: %v", bad, err)
+		}
+	}
+}
+
+type fakeHalter struct{ halted bool }
+
+func (h *fakeHalter) Halt()   { h.halted = true }
+func (h *fakeHalter) Resume() { h.halted = false }
+
+func TestService_EnforceAndCheck(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC) // 周一
+	store := NewMemoryStore()
+	store.Save(ctx, &Calendar{Symbol: "BTC0327", Sessions: weekdays("00:00", "16:00")})
+	svc := NewService(store, Config{Now: func() time.Time { return now }})
+	if err := svc.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var transitions []Transition
+	svc.OnTransition(func(tr Transition) { transitions = append(transitions, tr) })
+	engine, other := &fakeHalter{}, &fakeHalter{}
+	svc.Bind("BTC0327", engine)
+	svc.Bind("ETHUSDT", other)
+
+	if err := svc.CheckOpen("BTC0327"); err != nil || engine.halted {
+		t.Fatalf("open session: err=%v halted=%v", err, engine.halted)
+	}
+
+	// 收盘：入口拒单，引擎暂停；没有日历的交易对不受影响
+	now = now.Add(time.Hour)
+	svc.Enforce()
+	if err := svc.CheckOpen("BTC0327"); !errors.Is(err, ErrMarketClosed) {
+		t.Fatalf("after close: %v", err)
+	}
+	if !engine.halted || other.halted || svc.CheckOpen("ETHUSDT") != nil {
+		t.Fatalf("halted engine=%v other=%v", engine.halted, other.halted)
+	}
+
+	// 次日开盘时段内排一个进行中的维护窗口
+	now = time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)
+	svc.Enforce()
+	win, err := svc.AddMaintenance(ctx, "BTC0327", Window{Start: now.UnixMilli(), End: now.Add(time.Hour).UnixMilli(), Reason: "db upgrade"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CheckOpen("BTC0327"); !errors.Is(err, ErrMaintenance) || !engine.halted {
+		t.Fatalf("maintenance: %v halted=%v", err, engine.halted)
+	}
+	if err := svc.CancelMaintenance(ctx, "BTC0327", win.ID); err != nil || engine.halted {
+		t.Fatalf("cancel maintenance: %v halted=%v", err, engine.halted)
+	}
+	if err := svc.CancelMaintenance(ctx, "BTC0327", win.ID); !errors.Is(err, ErrWindowNotFound) {
+		t.Fatalf("cancel twice: %v", err)
+	}
+
+	want := []Phase{PhaseClosed, PhaseOpen, PhaseMaintenance, PhaseOpen}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions %+v", transitions)
+	}
+	for i, tr := range transitions {
+		if tr.To != want[i] {
+			t.Errorf("transition %d: %+v", i, tr)
+		}
+	}
+	if transitions[2].Reason != "db upgrade" {
+		t.Errorf("maintenance reason %q", transitions[2].Reason)
+	}
+
+	// 删除日历：恢复 7×24，存储同步删除
+	now = now.Add(10 * time.Hour)
+	svc.Enforce()
+	if !engine.halted {
+		t.Fatal("should be halted after close")
+	}
+	if err := svc.Delete(ctx, "BTC0327"); err != nil || engine.halted {
+		t.Fatalf("delete: %v halted=%v", err, engine.halted)
+	}
+	if list, _ := store.List(ctx); len(list) != 0 {
+		t.Fatalf("store not cleared: %+v", list)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	now := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC) // 周六
+	svc := NewService(NewMemoryStore(), Config{Now: func() time.Time { return now }})
+	admin := httptest.NewServer(NewAdminHandler(svc))
+	defer admin.Close()
+	public := httptest.NewServer(NewHandler(svc))
+	defer public.Close()
+
+	body := `{"symbol":"BTC0327","timezone":"UTC","sessions":[{"weekday":1,"open":"00:00","close":"24:00"}],
+		"maintenance":[{"start":1,"end":2,"reason":"past"}]}`
+	req, _ := http.NewRequest(http.MethodPut, admin.URL+"/admin/calendars", strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put status %d", resp.StatusCode)
+	}
+
+	bad, _ := http.NewRequest(http.MethodPut, admin.URL+"/admin/calendars", strings.NewReader(`{"symbol":"X","timezone":"nowhere"}`))
+	if resp, _ := http.DefaultClient.Do(bad); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid calendar status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(public.URL + "/calendars?symbol=BTC0327")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var view CalendarView
+	if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	// 周六休市，周一 00:00 开盘；已结束的维护窗口不对外发布
+	monday := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	if view.State.Phase != PhaseClosed || view.State.Until != monday.UnixMilli() || len(view.Maintenance) != 0 || len(view.Sessions) != 1 {
+		t.Fatalf("public view %+v", view)
+	}
+}
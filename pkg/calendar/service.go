@@ -0,0 +1,359 @@
+package calendar
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 服务：下单入口检查 + 引擎暂停/恢复
+// =============================================================================
+
+// Halter 按日历暂停/恢复交易的撮合引擎 (*mtrade.Engine 实现)
+type Halter interface {
+	Halt()
+	Resume()
+}
+
+var _ Halter = (*mtrade.Engine)(nil)
+
+// Transition 交易状态变化
+type Transition struct {
+	Symbol string
+	From   Phase
+	To     Phase
+	Reason string // 进入维护时为维护原因
+	At     time.Time
+}
+
+// Config 服务配置
+type Config struct {
+	Interval time.Duration    // 边界检查间隔，默认 1s (到点最多晚一个间隔暂停，入口检查不受影响)
+	Now      func() time.Time // 时钟，默认 time.Now
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// Service 交易日历服务
+//
+// 【并发】日历表整体换指针 (写时复制)：下单热路径只做一次原子读 + map 查找；
+// 写操作 (管理后台) 由 mu 串行化
+type Service struct {
+	store Store
+	cfg   Config
+
+	calendars atomic.Pointer[map[string]*compiled]
+
+	mu           sync.Mutex
+	halters      map[string][]Halter
+	phases       map[string]Phase // 上次执行到引擎 / 通知出去的状态
+	onTransition []func(Transition)
+	windowSeq    int64
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewService 创建日历服务，需 Load 之后日历才生效
+func NewService(store Store, cfg Config) *Service {
+	s := &Service{
+		store:   store,
+		cfg:     cfg.withDefaults(),
+		halters: make(map[string][]Halter),
+		phases:  make(map[string]Phase),
+		stopCh:  make(chan struct{}),
+	}
+	empty := make(map[string]*compiled)
+	s.calendars.Store(&empty)
+	return s
+}
+
+// Load 从存储加载全部日历 (启动时调用)，任何一个日历非法都不替换当前日历
+func (s *Service) Load(ctx context.Context) error {
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	next := make(map[string]*compiled, len(list))
+	for _, c := range list {
+		cc, err := compile(c)
+		if err != nil {
+			return err
+		}
+		next[c.Symbol] = cc
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calendars.Store(&next)
+	s.enforceLocked(false)
+	return nil
+}
+
+// OnTransition 注册状态变化回调 (同步调用，不要做耗时操作)
+func (s *Service) OnTransition(fn func(Transition)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onTransition = append(s.onTransition, fn)
+}
+
+// Bind 绑定交易对的撮合引擎，立即按当前状态暂停或恢复
+func (s *Service) Bind(symbol string, h Halter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.halters[symbol] = append(s.halters[symbol], h)
+	phase := PhaseOpen
+	if c, ok := (*s.calendars.Load())[symbol]; ok {
+		phase, _ = c.phaseAt(s.cfg.Now())
+	}
+	apply(h, phase)
+}
+
+func apply(h Halter, phase Phase) {
+	if phase == PhaseOpen {
+		h.Resume()
+	} else {
+		h.Halt()
+	}
+}
+
+// =============================================================================
+// 查询
+// =============================================================================
+
+// CheckOpen 下单入口检查：休市返回 ErrMarketClosed，维护中返回 ErrMaintenance，
+// 没有日历的交易对始终开放
+func (s *Service) CheckOpen(symbol string) error {
+	c, ok := (*s.calendars.Load())[symbol]
+	if !ok {
+		return nil
+	}
+	now := s.cfg.Now()
+	if phase, _ := c.phaseAt(now); phase == PhaseOpen {
+		return nil
+	}
+	return c.stateAt(now).Err()
+}
+
+// State 交易对当前状态
+func (s *Service) State(symbol string) State {
+	c, ok := (*s.calendars.Load())[symbol]
+	if !ok {
+		return State{Symbol: symbol, Phase: PhaseOpen}
+	}
+	return c.stateAt(s.cfg.Now())
+}
+
+// Get 交易对的日历
+func (s *Service) Get(symbol string) (Calendar, bool) {
+	c, ok := (*s.calendars.Load())[symbol]
+	if !ok {
+		return Calendar{}, false
+	}
+	return c.cal, true
+}
+
+// List 全部日历，按交易对排序
+func (s *Service) List() []Calendar {
+	m := *s.calendars.Load()
+	out := make([]Calendar, 0, len(m))
+	for _, c := range m {
+		out = append(out, c.cal)
+	}
+	slices.SortFunc(out, func(a, b Calendar) int { return cmp.Compare(a.Symbol, b.Symbol) })
+	return out
+}
+
+// =============================================================================
+// 管理操作
+// =============================================================================
+
+// Put 新建或整体替换日历，先落库再生效
+func (s *Service) Put(ctx context.Context, c Calendar) (Calendar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range c.Maintenance {
+		if c.Maintenance[i].ID == "" {
+			c.Maintenance[i].ID = s.nextWindowID()
+		}
+	}
+	return s.saveLocked(ctx, c)
+}
+
+// Delete 删除日历，交易对恢复 7×24
+func (s *Service) Delete(ctx context.Context, symbol string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.store.Delete(ctx, symbol); err != nil {
+		return err
+	}
+	s.swapLocked(symbol, nil)
+	s.enforceLocked(true)
+	return nil
+}
+
+// AddMaintenance 为交易对排一个维护窗口 (没有日历时新建一个全天开放的日历)
+func (s *Service) AddMaintenance(ctx context.Context, symbol string, w Window) (Window, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, _ := s.Get(symbol)
+	c.Symbol = symbol
+	if w.ID == "" {
+		w.ID = s.nextWindowID()
+	}
+	if slices.ContainsFunc(c.Maintenance, func(x Window) bool { return x.ID == w.ID }) {
+		return Window{}, ErrInvalidCalendar.Wrapf("duplicate maintenance window %q", w.ID)
+	}
+	c.Maintenance = append(slices.Clone(c.Maintenance), w)
+	if _, err := s.saveLocked(ctx, c); err != nil {
+		return Window{}, err
+	}
+	return w, nil
+}
+
+// CancelMaintenance 撤销维护窗口 (进行中的窗口撤销即提前结束)
+func (s *Service) CancelMaintenance(ctx context.Context, symbol, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Get(symbol)
+	if !ok {
+		return ErrCalendarNotFound
+	}
+	i := slices.IndexFunc(c.Maintenance, func(w Window) bool { return w.ID == id })
+	if i < 0 {
+		return ErrWindowNotFound
+	}
+	c.Maintenance = slices.Delete(slices.Clone(c.Maintenance), i, i+1)
+	_, err := s.saveLocked(ctx, c)
+	return err
+}
+
+// saveLocked 校验、落库、替换缓存并立即执行一次边界检查，调用方持有 mu
+func (s *Service) saveLocked(ctx context.Context, c Calendar) (Calendar, error) {
+	c.UpdatedAt = s.cfg.Now().UnixMilli()
+	cc, err := compile(c)
+	if err != nil {
+		return Calendar{}, err
+	}
+	if err := s.store.Save(ctx, &cc.cal); err != nil {
+		return Calendar{}, err
+	}
+	s.swapLocked(c.Symbol, cc)
+	s.enforceLocked(true)
+	return cc.cal, nil
+}
+
+// swapLocked 写时复制替换一个交易对的日历，cc 为 nil 表示删除
+func (s *Service) swapLocked(symbol string, cc *compiled) {
+	old := *s.calendars.Load()
+	next := make(map[string]*compiled, len(old)+1)
+	for k, v := range old {
+		next[k] = v
+	}
+	if cc == nil {
+		delete(next, symbol)
+	} else {
+		next[symbol] = cc
+	}
+	s.calendars.Store(&next)
+}
+
+// nextWindowID 本进程内唯一的窗口 ID (毫秒时间戳 + 序号，重启后不会与已有窗口冲突)
+func (s *Service) nextWindowID() string {
+	s.windowSeq++
+	return "mw-" + strconv.FormatInt(s.cfg.Now().UnixMilli(), 10) + "-" + strconv.FormatInt(s.windowSeq, 10)
+}
+
+// =============================================================================
+// 边界执行
+// =============================================================================
+
+// Enforce 检查一次所有交易对的状态：状态变化时暂停/恢复绑定的引擎并通知
+func (s *Service) Enforce() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enforceLocked(true)
+}
+
+// enforceLocked 没有日历的交易对视为开放；emit = false 时只同步引擎不通知
+// (启动加载时不知道上次的状态，不能当作变化发出去)
+func (s *Service) enforceLocked(emit bool) {
+	now := s.cfg.Now()
+	m := *s.calendars.Load()
+
+	symbols := make(map[string]struct{}, len(m)+len(s.phases))
+	for symbol := range m {
+		symbols[symbol] = struct{}{}
+	}
+	for symbol := range s.phases {
+		symbols[symbol] = struct{}{} // 已删除日历的交易对恢复开放
+	}
+
+	for symbol := range symbols {
+		phase, reason := PhaseOpen, ""
+		c, ok := m[symbol]
+		if ok {
+			phase, reason = c.phaseAt(now)
+		}
+		prev, known := s.phases[symbol]
+		if !known {
+			prev = PhaseOpen
+		}
+		if ok || s.halters[symbol] != nil {
+			s.phases[symbol] = phase
+		} else {
+			delete(s.phases, symbol)
+		}
+		if prev == phase {
+			continue
+		}
+		for _, h := range s.halters[symbol] {
+			apply(h, phase)
+		}
+		if !emit {
+			continue
+		}
+		t := Transition{Symbol: symbol, From: prev, To: phase, Reason: reason, At: now}
+		for _, fn := range s.onTransition {
+			fn(t)
+		}
+	}
+}
+
+// Start 启动后台边界检查
+func (s *Service) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.Enforce()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查
+func (s *Service) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
@@ -0,0 +1,102 @@
+package calendar
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// =============================================================================
+// 存储
+// =============================================================================
+
+// Store 日历存储
+type Store interface {
+	List(ctx context.Context) ([]Calendar, error)
+	// Save 按交易对整体覆盖
+	Save(ctx context.Context, c *Calendar) error
+	// Delete 不存在时返回 ErrCalendarNotFound
+	Delete(ctx context.Context, symbol string) error
+}
+
+// MemoryStore 内存存储 (测试 / 单机)
+type MemoryStore struct {
+	mu        sync.RWMutex
+	calendars map[string]Calendar
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{calendars: make(map[string]Calendar)}
+}
+
+func (s *MemoryStore) List(context.Context) ([]Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Calendar, 0, len(s.calendars))
+	for _, c := range s.calendars {
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b Calendar) int { return cmp.Compare(a.Symbol, b.Symbol) })
+	return out, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, c *Calendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *c
+	cp.Sessions = slices.Clone(c.Sessions)
+	cp.Maintenance = slices.Clone(c.Maintenance)
+	s.calendars[c.Symbol] = cp
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, symbol string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.calendars[symbol]; !ok {
+		return ErrCalendarNotFound
+	}
+	delete(s.calendars, symbol)
+	return nil
+}
+
+// GormStore MySQL 存储 (calendar.sql)
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建 MySQL 存储
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) List(ctx context.Context) ([]Calendar, error) {
+	var rows []Calendar
+	err := s.db.WithContext(ctx).Order("symbol").Find(&rows).Error
+	return rows, err
+}
+
+func (s *GormStore) Save(ctx context.Context, c *Calendar) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(c).Error
+}
+
+func (s *GormStore) Delete(ctx context.Context, symbol string) error {
+	res := s.db.WithContext(ctx).Where("symbol = ?", symbol).Delete(&Calendar{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCalendarNotFound
+	}
+	return nil
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*GormStore)(nil)
+)
@@ -0,0 +1,212 @@
+// Package cexerr 统一错误分类：类别 + 错误码 + 是否可重试
+//
+// 【问题】各包各自 errors.New，调用方只能靠 errors.Is 逐个比对，
+// gorm.ErrRecordNotFound 之类的存储层错误还会一路漏到接口层；
+// 客户端和运维拿到的只有一句英文，没法按类型分支处理 (重试？提示充值？报警？)
+//
+// 【设计】
+//   - Code: 机器可读的错误码，全局唯一，形如 FUTURES_INSUFFICIENT_MARGIN，一经发布不改
+//   - Category: 粗粒度类别，决定 HTTP 状态码和告警级别
+//   - Retryable: 调用方原样重试是否可能成功 (超时、限流、分片关闭)
+//
+// 各包仍然以包级变量导出哨兵错误，只是用 cexerr.New 定义：
+//
+//	var ErrInsufficientMargin = cexerr.New("FUTURES_INSUFFICIENT_MARGIN", cexerr.CategoryInsufficientFunds, "insufficient margin")
+//
+// 原有 errors.Is(err, ErrXxx) 和 fmt.Errorf("%w: ...", ErrXxx) 写法不受影响；
+// 需要附带上下文或底层原因时用 Wrap / Wrapf，错误码随之保留
+package cexerr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// =============================================================================
+// 类别
+// =============================================================================
+
+// Category 错误类别
+type Category uint8
+
+const (
+	CategoryInternal           Category = iota // 内部错误 (未分类错误一律归此类)
+	CategoryInvalidArgument                    // 请求参数非法
+	CategoryNotFound                           // 资源不存在
+	CategoryConflict                           // 重复请求 / 状态冲突
+	CategoryFailedPrecondition                 // 当前状态不允许 (合约未开放交易等)
+	CategoryInsufficientFunds                  // 余额 / 保证金不足
+	CategoryRateLimited                        // 被限流
+	CategoryUnavailable                        // 暂时不可用 (超时、停机中)
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryInvalidArgument:
+		return "INVALID_ARGUMENT"
+	case CategoryNotFound:
+		return "NOT_FOUND"
+	case CategoryConflict:
+		return "CONFLICT"
+	case CategoryFailedPrecondition:
+		return "FAILED_PRECONDITION"
+	case CategoryInsufficientFunds:
+		return "INSUFFICIENT_FUNDS"
+	case CategoryRateLimited:
+		return "RATE_LIMITED"
+	case CategoryUnavailable:
+		return "UNAVAILABLE"
+	}
+	return "INTERNAL"
+}
+
+// =============================================================================
+// 错误码
+// =============================================================================
+
+// Code 机器可读错误码
+type Code string
+
+// CodeInternal 非 cexerr 错误 (存储层、网络库原始错误) 对外统一报这个码
+const CodeInternal Code = "INTERNAL"
+
+// ErrInvalidParam 通用的请求参数错误，接口层用 Wrapf 带上参数名
+var ErrInvalidParam = New("INVALID_PARAMETER", CategoryInvalidArgument, "invalid parameter")
+
+var (
+	registryMu sync.Mutex
+	registry   = map[Code]*Error{}
+)
+
+// Lookup 按错误码查找已定义的错误 (客户端 SDK / 文档生成用)
+func Lookup(code Code) (*Error, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	e, ok := registry[code]
+	return e, ok
+}
+
+// =============================================================================
+// Error
+// =============================================================================
+
+// Error 带错误码的错误
+//
+// 哨兵错误由 New 定义；Wrap / Wrapf 返回的副本与哨兵 errors.Is 相等 (按错误码比较)
+type Error struct {
+	code      Code
+	category  Category
+	message   string
+	retryable bool
+
+	detail string // Wrapf 附加的上下文
+	cause  error  // Wrap 包装的底层错误
+}
+
+// New 定义一个哨兵错误
+//
+// 【注意】错误码全局唯一，重复定义直接 panic (包初始化阶段就能发现)
+func New(code Code, category Category, message string) *Error {
+	return register(&Error{code: code, category: category, message: message})
+}
+
+// NewRetryable 定义一个可重试的哨兵错误
+func NewRetryable(code Code, category Category, message string) *Error {
+	return register(&Error{code: code, category: category, message: message, retryable: true})
+}
+
+func register(e *Error) *Error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[e.code]; dup {
+		panic(fmt.Sprintf("cexerr: duplicate error code %q", e.code))
+	}
+	registry[e.code] = e
+	return e
+}
+
+func (e *Error) Code() Code         { return e.code }
+func (e *Error) Category() Category { return e.category }
+func (e *Error) Message() string    { return e.message }
+func (e *Error) Retryable() bool    { return e.retryable }
+func (e *Error) Unwrap() error      { return e.cause }
+
+func (e *Error) Error() string {
+	msg := e.message
+	if e.detail != "" {
+		msg += ": " + e.detail
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+// publicMessage 对外消息：不含 Wrap 的底层原因
+func (e *Error) publicMessage() string {
+	if e.detail != "" {
+		return e.message + ": " + e.detail
+	}
+	return e.message
+}
+
+// Is 错误码相同即相等，Wrap 出来的副本能匹配原哨兵
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && e.code == t.code
+}
+
+// Wrap 附带底层原因，返回新错误 (错误码不变)
+func (e *Error) Wrap(cause error) *Error {
+	cp := *e
+	cp.cause = cause
+	return &cp
+}
+
+// Wrapf 附带上下文描述，返回新错误 (错误码不变)
+func (e *Error) Wrapf(format string, args ...any) *Error {
+	cp := *e
+	cp.detail = fmt.Sprintf(format, args...)
+	return &cp
+}
+
+// =============================================================================
+// 从任意 error 提取
+// =============================================================================
+
+// As 取错误链上第一个 *Error
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// CodeOf 错误码；nil 返回空串，非 cexerr 错误返回 CodeInternal
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	if e, ok := As(err); ok {
+		return e.code
+	}
+	return CodeInternal
+}
+
+// CategoryOf 错误类别；非 cexerr 错误归为 CategoryInternal
+func CategoryOf(err error) Category {
+	if e, ok := As(err); ok {
+		return e.category
+	}
+	return CategoryInternal
+}
+
+// IsRetryable 是否值得原样重试；非 cexerr 错误一律视为不可重试
+func IsRetryable(err error) bool {
+	if e, ok := As(err); ok {
+		return e.retryable
+	}
+	return false
+}
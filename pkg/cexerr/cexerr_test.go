@@ -0,0 +1,91 @@
+package cexerr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var (
+	errTestFunds   = New("TEST_INSUFFICIENT_FUNDS", CategoryInsufficientFunds, "insufficient funds")
+	errTestTimeout = NewRetryable("TEST_TIMEOUT", CategoryUnavailable, "timeout")
+)
+
+func TestError_WrapKeepsCode(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := fmt.Errorf("place order: %w", errTestTimeout.Wrap(cause))
+
+	if !errors.Is(err, errTestTimeout) {
+		t.Error("wrapped error should match sentinel")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("wrapped error should match cause")
+	}
+	if errors.Is(err, errTestFunds) {
+		t.Error("different code should not match")
+	}
+	if CodeOf(err) != "TEST_TIMEOUT" || CategoryOf(err) != CategoryUnavailable || !IsRetryable(err) {
+		t.Errorf("got code=%s category=%s retryable=%v", CodeOf(err), CategoryOf(err), IsRetryable(err))
+	}
+	if got := errTestFunds.Wrapf("user %d", 7).Error(); got != "insufficient funds: user 7" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestCodeOf_Plain(t *testing.T) {
+	if CodeOf(nil) != "" {
+		t.Error("nil error should have empty code")
+	}
+	plain := errors.New("record not found")
+	if CodeOf(plain) != CodeInternal || CategoryOf(plain) != CategoryInternal || IsRetryable(plain) {
+		t.Error("plain error should be internal and not retryable")
+	}
+}
+
+func TestNew_DuplicateCodePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate code")
+		}
+	}()
+	New("TEST_TIMEOUT", CategoryInternal, "dup")
+}
+
+func TestLookup(t *testing.T) {
+	e, ok := Lookup("TEST_INSUFFICIENT_FUNDS")
+	if !ok || e != errTestFunds {
+		t.Error("registered code not found")
+	}
+	if _, ok := Lookup("TEST_MISSING"); ok {
+		t.Error("unexpected lookup hit")
+	}
+}
+
+func TestWriteHTTP(t *testing.T) {
+	cases := []struct {
+		err     error
+		status  int
+		code    Code
+		message string
+	}{
+		{errTestFunds.Wrapf("need 10"), http.StatusUnprocessableEntity, "TEST_INSUFFICIENT_FUNDS", "insufficient funds: need 10"},
+		{errTestTimeout.Wrap(errors.New("dial tcp 10.0.0.1:3306")), http.StatusServiceUnavailable, "TEST_TIMEOUT", "timeout"},
+		{ErrInvalidParam.Wrapf("limit"), http.StatusBadRequest, "INVALID_PARAMETER", "invalid parameter: limit"},
+		{errors.New("Error 1062: Duplicate entry"), http.StatusInternalServerError, CodeInternal, "internal error"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		WriteHTTP(rec, c.err)
+
+		var resp Response
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != c.status || resp.Code != c.code || resp.Message != c.message {
+			t.Errorf("%v: got %d %+v", c.err, rec.Code, resp)
+		}
+	}
+}
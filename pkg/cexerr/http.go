@@ -0,0 +1,62 @@
+package cexerr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// =============================================================================
+// 接口层
+// =============================================================================
+
+// Response 错误响应体
+//
+//	{"code":"FUTURES_INSUFFICIENT_MARGIN","category":"INSUFFICIENT_FUNDS","message":"insufficient margin","retryable":false}
+type Response struct {
+	Code      Code   `json:"code"`
+	Category  string `json:"category"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// HTTPStatus 类别对应的 HTTP 状态码
+func (c Category) HTTPStatus() int {
+	switch c {
+	case CategoryInvalidArgument:
+		return http.StatusBadRequest
+	case CategoryNotFound:
+		return http.StatusNotFound
+	case CategoryConflict:
+		return http.StatusConflict
+	case CategoryFailedPrecondition, CategoryInsufficientFunds:
+		return http.StatusUnprocessableEntity
+	case CategoryRateLimited:
+		return http.StatusTooManyRequests
+	case CategoryUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// ToResponse 转成响应体
+//
+// 【注意】不回显原始错误 (可能带 SQL、内网地址)：非 cexerr 错误只给错误码，Wrap 的底层原因也不输出
+func ToResponse(err error) Response {
+	e, ok := As(err)
+	if !ok {
+		return Response{Code: CodeInternal, Category: CategoryInternal.String(), Message: "internal error"}
+	}
+	return Response{
+		Code:      e.code,
+		Category:  e.category.String(),
+		Message:   e.publicMessage(),
+		Retryable: e.retryable,
+	}
+}
+
+// WriteHTTP 按错误类别写状态码和 JSON 错误体
+func WriteHTTP(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(CategoryOf(err).HTTPStatus())
+	json.NewEncoder(w).Encode(ToResponse(err))
+}
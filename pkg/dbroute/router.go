@@ -0,0 +1,337 @@
+// 文件: pkg/dbroute/router.go
+// 读写分离路由
+//
+// 【问题】报表、对账单、流水列表这类大查询全打在主库上，和下单冻结、成交落账抢连接和 IO
+//
+// 【做法】
+//   - 写和默认的读都走主库：刚写完立刻读 (下单后查余额) 必须读到自己的写
+//   - 能容忍延迟的只读查询由调用方用 ReadOnly(ctx, maxStaleness) 标记，路由到从库
+//   - 后台按 CheckInterval 探测每个从库的复制延迟 (SHOW REPLICA STATUS)，
+//     陈旧度 = 上次探测到的延迟 + 距上次探测的时间，超过查询容忍度的从库不参与路由
+//   - 探测失败 (复制中断、连不上) 的从库摘除，下一次探测恢复后自动加回
+//   - 没有可用从库时回落主库 (Fallbacks 计数)，查询不会因为从库故障失败
+//   - 多个可用从库轮询
+//
+// 【面试】为什么陈旧度要加上距上次探测的时间?
+// 探测结果本身会过期：1 秒前延迟 0，不代表现在延迟 0 (可能刚好开始卡住)，
+// 按最坏情况估计才能保证读到的数据不比容忍度更旧
+//
+// 【注意】事务一律走主库：仓库的事务版本不带路由 (见 fund.BalanceRepo.Transaction)
+
+package dbroute
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/cexerr"
+)
+
+var (
+	ErrNotReplica         = cexerr.New("DBROUTE_NOT_REPLICA", cexerr.CategoryFailedPrecondition, "database is not a replica")
+	ErrReplicationStopped = cexerr.New("DBROUTE_REPLICATION_STOPPED", cexerr.CategoryUnavailable, "replication is not running")
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 路由配置
+type Config struct {
+	// MaxStaleness ReadOnly 未指定容忍度时的默认值
+	MaxStaleness time.Duration
+	// CheckInterval 复制延迟探测间隔
+	CheckInterval time.Duration
+	// ProbeTimeout 单次探测超时
+	ProbeTimeout time.Duration
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		MaxStaleness:  5 * time.Second,
+		CheckInterval: time.Second,
+		ProbeTimeout:  500 * time.Millisecond,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.MaxStaleness <= 0 {
+		c.MaxStaleness = d.MaxStaleness
+	}
+	if c.CheckInterval <= 0 {
+		c.CheckInterval = d.CheckInterval
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = d.ProbeTimeout
+	}
+	return c
+}
+
+// Replica 从库
+type Replica struct {
+	Name string
+	DB   *gorm.DB
+}
+
+// LagProbe 复制延迟探测
+type LagProbe func(ctx context.Context, db *gorm.DB) (time.Duration, error)
+
+// =============================================================================
+// 只读标记
+// =============================================================================
+
+type readOnlyKey struct{}
+
+// ReadOnly 标记查询可以读从库，maxStaleness 为可容忍的数据陈旧度，<=0 用路由默认值
+func ReadOnly(ctx context.Context, maxStaleness time.Duration) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, maxStaleness)
+}
+
+// IsReadOnly ctx 是否标记为可读从库
+func IsReadOnly(ctx context.Context) bool {
+	_, ok := ctx.Value(readOnlyKey{}).(time.Duration)
+	return ok
+}
+
+// =============================================================================
+// Router
+// =============================================================================
+
+// Router 主从路由
+type Router struct {
+	primary  *gorm.DB
+	replicas []*replica
+	cfg      Config
+	probe    LagProbe
+	now      func() time.Time
+
+	next      atomic.Uint64
+	fallbacks atomic.Int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type replica struct {
+	name   string
+	db     *gorm.DB
+	routed atomic.Int64
+
+	mu        sync.Mutex
+	lag       time.Duration
+	checkedAt time.Time // 零值表示还没探测成功过
+	err       error
+}
+
+// ReplicaStatus 从库状态
+type ReplicaStatus struct {
+	Name      string
+	Lag       time.Duration // 上次探测到的复制延迟
+	CheckedAt time.Time     // 上次探测成功时间
+	Err       error         // 上次探测错误，非 nil 时不参与路由
+	Routed    int64         // 路由到该从库的查询数
+}
+
+// New 创建路由，replicas 为空时所有查询走主库
+func New(primary *gorm.DB, replicas []Replica, cfg Config) *Router {
+	r := &Router{
+		primary: primary,
+		cfg:     cfg.withDefaults(),
+		probe:   MySQLLagProbe,
+		now:     time.Now,
+		stopCh:  make(chan struct{}),
+	}
+	for _, rep := range replicas {
+		r.replicas = append(r.replicas, &replica{name: rep.Name, db: rep.DB})
+	}
+	return r
+}
+
+// SetLagProbe 替换延迟探测 (测试用)
+func (r *Router) SetLagProbe(probe LagProbe) {
+	r.probe = probe
+}
+
+// SetClock 替换时钟，从库延迟检测结果的新鲜度按它判断
+func (r *Router) SetClock(now func() time.Time) {
+	r.now = now
+}
+
+// Primary 主库
+func (r *Router) Primary() *gorm.DB {
+	return r.primary
+}
+
+// DB 按 ctx 选库：未标记 ReadOnly 走主库，标记了走陈旧度在容忍范围内的从库，没有则回落主库
+func (r *Router) DB(ctx context.Context) *gorm.DB {
+	maxStaleness, ok := ctx.Value(readOnlyKey{}).(time.Duration)
+	if !ok || len(r.replicas) == 0 {
+		return r.primary
+	}
+	if maxStaleness <= 0 {
+		maxStaleness = r.cfg.MaxStaleness
+	}
+
+	now := r.now()
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.staleness(now) <= maxStaleness {
+			rep.routed.Add(1)
+			return rep.db
+		}
+	}
+	r.fallbacks.Add(1)
+	return r.primary
+}
+
+// staleness 最坏情况下的数据陈旧度，不可用时返回最大值
+func (rep *replica) staleness(now time.Time) time.Duration {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.err != nil || rep.checkedAt.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+	return rep.lag + max(now.Sub(rep.checkedAt), 0)
+}
+
+// Start 启动后台延迟探测 (启动时先同步探测一轮，从库立即可用)
+func (r *Router) Start() {
+	if len(r.replicas) == 0 {
+		return
+	}
+	r.CheckNow(context.Background())
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.CheckNow(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop 停止探测
+func (r *Router) Stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	r.wg.Wait()
+}
+
+// CheckNow 探测一轮所有从库的复制延迟
+func (r *Router) CheckNow(ctx context.Context) {
+	for _, rep := range r.replicas {
+		pctx, cancel := context.WithTimeout(ctx, r.cfg.ProbeTimeout)
+		lag, err := r.probe(pctx, rep.db)
+		cancel()
+
+		rep.mu.Lock()
+		wasDown := rep.err != nil
+		rep.err = err
+		if err == nil {
+			rep.lag, rep.checkedAt = lag, r.now()
+		}
+		rep.mu.Unlock()
+
+		switch {
+		case err != nil && !wasDown:
+			log.Printf("[DBRoute] replica %s removed: %v", rep.name, err)
+		case err == nil && wasDown:
+			log.Printf("[DBRoute] replica %s restored, lag %v", rep.name, lag)
+		}
+	}
+}
+
+// Status 各从库状态
+func (r *Router) Status() []ReplicaStatus {
+	out := make([]ReplicaStatus, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		rep.mu.Lock()
+		out = append(out, ReplicaStatus{
+			Name:      rep.name,
+			Lag:       rep.lag,
+			CheckedAt: rep.checkedAt,
+			Err:       rep.err,
+			Routed:    rep.routed.Load(),
+		})
+		rep.mu.Unlock()
+	}
+	return out
+}
+
+// Fallbacks 标记了只读但没有可用从库、回落主库的查询数
+func (r *Router) Fallbacks() int64 {
+	return r.fallbacks.Load()
+}
+
+// =============================================================================
+// MySQL 延迟探测
+// =============================================================================
+
+// MySQLLagProbe 读 SHOW REPLICA STATUS 的 Seconds_Behind_Source
+// (MySQL 8.0.22 之前为 SHOW SLAVE STATUS / Seconds_Behind_Master)
+func MySQLLagProbe(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	lag, err := showReplicaStatus(ctx, db, "SHOW REPLICA STATUS", "Seconds_Behind_Source")
+	if err != nil && err != ErrNotReplica && err != ErrReplicationStopped {
+		lag, err = showReplicaStatus(ctx, db, "SHOW SLAVE STATUS", "Seconds_Behind_Master")
+	}
+	return lag, err
+}
+
+func showReplicaStatus(ctx context.Context, db *gorm.DB, query, column string) (time.Duration, error) {
+	rows, err := db.WithContext(ctx).Raw(query).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, ErrNotReplica
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, c := range cols {
+		if c != column {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, ErrReplicationStopped // IO / SQL 线程没在跑时为 NULL
+		}
+		sec, err := strconv.ParseInt(values[i].String, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s %q: %w", column, values[i].String, err)
+		}
+		return time.Duration(sec) * time.Second, nil
+	}
+	return 0, fmt.Errorf("%s: column %s not found", query, column)
+}
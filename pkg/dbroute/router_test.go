@@ -0,0 +1,111 @@
+// 文件: pkg/dbroute/router_test.go
+
+package dbroute
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "u:p@tcp(127.0.0.1:1)/x", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type fakeLag struct {
+	lag map[*gorm.DB]time.Duration
+	err map[*gorm.DB]error
+}
+
+func (f *fakeLag) probe(_ context.Context, db *gorm.DB) (time.Duration, error) {
+	return f.lag[db], f.err[db]
+}
+
+func TestRouter_ReadOnlyRouting(t *testing.T) {
+	primary, r1, r2 := dryRunDB(t), dryRunDB(t), dryRunDB(t)
+	now := time.Unix(1700000000, 0)
+	lag := &fakeLag{
+		lag: map[*gorm.DB]time.Duration{r1: 0, r2: 3 * time.Second},
+		err: map[*gorm.DB]error{},
+	}
+	router := New(primary, []Replica{{"r1", r1}, {"r2", r2}}, Config{MaxStaleness: 5 * time.Second})
+	router.SetLagProbe(lag.probe)
+	router.SetClock(func() time.Time { return now })
+
+	ctx := context.Background()
+
+	// 还没探测过：从库不可用，只读也回落主库
+	if router.DB(ReadOnly(ctx, 0)) != primary {
+		t.Fatal("unprobed replicas must not serve reads")
+	}
+	router.CheckNow(ctx)
+
+	// 未标记只读始终走主库
+	if router.DB(ctx) != primary {
+		t.Fatal("untagged query must go to primary")
+	}
+
+	// 默认容忍度 5s：两个从库都可用，轮询
+	seen := map[*gorm.DB]int{}
+	for i := 0; i < 4; i++ {
+		seen[router.DB(ReadOnly(ctx, 0))]++
+	}
+	if seen[r1] != 2 || seen[r2] != 2 {
+		t.Fatalf("round robin: r1=%d r2=%d", seen[r1], seen[r2])
+	}
+
+	// 容忍 1s：只有 r1
+	for i := 0; i < 3; i++ {
+		if router.DB(ReadOnly(ctx, time.Second)) != r1 {
+			t.Fatal("lagging replica served a strict read")
+		}
+	}
+
+	// 探测结果过期也算陈旧：2s 后 r1 的陈旧度为 2s
+	now = now.Add(2 * time.Second)
+	if router.DB(ReadOnly(ctx, time.Second)) != primary {
+		t.Fatal("stale probe result must count toward staleness")
+	}
+	if router.Fallbacks() != 2 {
+		t.Fatalf("fallbacks = %d, want 2", router.Fallbacks())
+	}
+}
+
+func TestRouter_ReplicaFailover(t *testing.T) {
+	primary, r1 := dryRunDB(t), dryRunDB(t)
+	lag := &fakeLag{lag: map[*gorm.DB]time.Duration{}, err: map[*gorm.DB]error{}}
+	router := New(primary, []Replica{{"r1", r1}}, Config{})
+	router.SetLagProbe(lag.probe)
+
+	ctx := ReadOnly(context.Background(), 0)
+	router.CheckNow(ctx)
+	if router.DB(ctx) != r1 {
+		t.Fatal("healthy replica should serve reads")
+	}
+
+	// 复制中断：摘除
+	lag.err[r1] = ErrReplicationStopped
+	router.CheckNow(ctx)
+	if router.DB(ctx) != primary {
+		t.Fatal("broken replica must be removed")
+	}
+	if st := router.Status(); !errors.Is(st[0].Err, ErrReplicationStopped) || st[0].Routed != 1 {
+		t.Fatalf("status %+v", st[0])
+	}
+
+	// 恢复后加回
+	delete(lag.err, r1)
+	router.CheckNow(ctx)
+	if router.DB(ctx) != r1 {
+		t.Fatal("restored replica should serve reads again")
+	}
+}
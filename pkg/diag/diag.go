@@ -0,0 +1,198 @@
+// Package diag 运维诊断接口：各引擎的内部状态汇总到一个鉴权接口
+//
+// 【问题】排查 "撮合变慢 / 资金延迟 / 强平堆积" 时要同时看好几个引擎的内部计数：
+// 撮合队列积压与丢弃的事件、资金分片排队、强平各等级人数与任务积压、WAL 刷盘滞后、NATS 消费积压。
+// 这些统计散落在各包的 GetStats / Stats 里，每次排查都临时加接口
+//
+// 【做法】各组件以 Source (返回可 JSON 序列化的统计) 按名字注册，一个接口取全部或指定几项：
+//
+//	reg := diag.New(diag.Config{Tokens: []string{opsToken}})
+//	reg.Register("mtrade.BTC_USDT", diag.MatchingEngine(btcEngine))
+//	reg.Register("asset", diag.AssetEngine(accounts))
+//	reg.Register("liquidation", diag.Liquidation(liq))
+//	reg.Register("nats.orders", diag.NATS(sub))
+//	mux.Handle("/admin/diagnostics", reg.Handler())
+//
+// 【注意】
+//   - Source 在请求 goroutine 里同步调用，只能读原子量 / 快照，不能往撮合或分片队列里提交命令：
+//     引擎卡住时诊断接口还得能用
+//   - 单个 Source panic 只记在 Errors 里，不影响其他项
+//   - 鉴权：Authorization: Bearer <token>，常量时间比较；未配置 token 时拒绝所有请求
+package diag
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source 一项诊断数据，返回值须可 JSON 序列化
+type Source func() any
+
+// Config 诊断接口配置
+type Config struct {
+	// Tokens 允许访问的运维 token，为空时拒绝所有请求
+	Tokens []string
+
+	// Now 时钟 (测试用)，默认 time.Now
+	Now func() time.Time
+}
+
+// Registry 诊断数据源注册表
+type Registry struct {
+	tokens [][]byte
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	sources map[string]Source
+}
+
+// New 创建注册表
+func New(cfg Config) *Registry {
+	r := &Registry{
+		now:     cfg.Now,
+		sources: make(map[string]Source),
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	for _, t := range cfg.Tokens {
+		if t != "" {
+			r.tokens = append(r.tokens, []byte(t))
+		}
+	}
+	return r
+}
+
+// Register 注册数据源，同名覆盖 (引擎重建后重新注册)
+func (r *Registry) Register(name string, src Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[name] = src
+}
+
+// Unregister 移除数据源 (交易对下线)
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sources, name)
+}
+
+// Names 已注册的数据源，按名字排序
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.sources))
+	for name := range r.sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Snapshot 一次诊断快照
+type Snapshot struct {
+	At       time.Time         `json:"at"`
+	Sections map[string]any    `json:"sections"`
+	Errors   map[string]string `json:"errors,omitempty"` // 采集失败 (panic) 的数据源
+}
+
+// Snapshot 采集指定数据源，names 为空采集全部；未注册的名字记在 Errors 里
+func (r *Registry) Snapshot(names ...string) Snapshot {
+	r.mu.RLock()
+	sources := make(map[string]Source, len(r.sources))
+	for name, src := range r.sources {
+		if len(names) == 0 || slices.Contains(names, name) {
+			sources[name] = src
+		}
+	}
+	r.mu.RUnlock()
+
+	snap := Snapshot{At: r.now(), Sections: make(map[string]any, len(sources))}
+	for _, name := range names {
+		if _, ok := sources[name]; !ok {
+			snap.addError(name, "unknown section")
+		}
+	}
+	for name, src := range sources {
+		v, err := collect(src)
+		if err != nil {
+			snap.addError(name, err.Error())
+			continue
+		}
+		snap.Sections[name] = v
+	}
+	return snap
+}
+
+func (s *Snapshot) addError(name, msg string) {
+	if s.Errors == nil {
+		s.Errors = make(map[string]string)
+	}
+	s.Errors[name] = msg
+}
+
+// collect 调用数据源，panic 转成错误
+func collect(src Source) (v any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return src(), nil
+}
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// Handler 诊断接口
+//
+//	GET /admin/diagnostics                                    全部数据源
+//	GET /admin/diagnostics?section=mtrade.BTC_USDT&section=asset
+//	GET /admin/diagnostics/sections                           已注册的数据源名
+//
+// 未带或带错 token 返回 401
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/diagnostics", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, r.Snapshot(req.URL.Query()["section"]...))
+	})
+	mux.HandleFunc("GET /admin/diagnostics/sections", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, r.Names())
+	})
+	return r.authenticate(mux)
+}
+
+// authenticate 校验 Bearer token
+func (r *Registry) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || !r.validToken([]byte(token)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="diagnostics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// validToken 逐个常量时间比较，不因提前命中泄露是第几个 token
+func (r *Registry) validToken(token []byte) bool {
+	valid := 0
+	for _, t := range r.tokens {
+		valid |= subtle.ConstantTimeCompare(token, t)
+	}
+	return valid == 1
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,119 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+func get(t *testing.T, h http.Handler, target, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	reg := New(Config{Tokens: []string{"ops-a", "ops-b"}})
+	reg.Register("x", func() any { return 1 })
+	h := reg.Handler()
+
+	for _, token := range []string{"", "wrong", "ops-"} {
+		if rec := get(t, h, "/admin/diagnostics", token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, rec.Code)
+		}
+	}
+	if rec := get(t, h, "/admin/diagnostics", "ops-b"); rec.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", rec.Code)
+	}
+
+	// 未配置 token 时拒绝所有请求
+	open := New(Config{}).Handler()
+	if rec := get(t, open, "/admin/diagnostics", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no tokens configured: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestSnapshot_SectionsAndErrors(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	reg := New(Config{Tokens: []string{"t"}, Now: func() time.Time { return now }})
+	reg.Register("a", func() any { return map[string]int{"depth": 3} })
+	reg.Register("b", func() any { return "ok" })
+	reg.Register("broken", func() any { panic("boom") })
+
+	rec := get(t, reg.Handler(), "/admin/diagnostics?section=a&section=broken&section=missing", "t")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var snap struct {
+		At       time.Time                  `json:"at"`
+		Sections map[string]json.RawMessage `json:"sections"`
+		Errors   map[string]string          `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !snap.At.Equal(now) {
+		t.Errorf("At = %v, want %v", snap.At, now)
+	}
+	if len(snap.Sections) != 1 || string(snap.Sections["a"]) != `{"depth":3}` {
+		t.Errorf("Sections = %s", snap.Sections)
+	}
+	if snap.Errors["missing"] != "unknown section" || snap.Errors["broken"] == "" {
+		t.Errorf("Errors = %v", snap.Errors)
+	}
+
+	// 不指定 section 取全部
+	all := reg.Snapshot()
+	if len(all.Sections) != 2 || len(all.Errors) != 1 {
+		t.Errorf("all: sections = %d, errors = %v", len(all.Sections), all.Errors)
+	}
+
+	var names []string
+	json.Unmarshal(get(t, reg.Handler(), "/admin/diagnostics/sections", "t").Body.Bytes(), &names)
+	if len(names) != 3 || names[0] != "a" || names[2] != "broken" {
+		t.Errorf("names = %v", names)
+	}
+}
+
+func TestMatchingEngine(t *testing.T) {
+	cfg := mtrade.DefaultEngineConfig("BTC_USDT")
+	cfg.WALDir = t.TempDir()
+	engine, err := mtrade.NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.OnEventWithOptions(func(mtrade.Event) {}, mtrade.HandlerOptions{Name: "noop"})
+
+	st := MatchingEngine(engine)().(MatchingStats)
+	if st.Queues.OrderQueueCap != cfg.OrderQueueSize || st.Queues.EventQueueCap == 0 {
+		t.Errorf("Queues = %+v", st.Queues)
+	}
+	if len(st.Handlers) != 1 || st.Handlers[0].Name != "noop" {
+		t.Errorf("Handlers = %+v", st.Handlers)
+	}
+	if st.WAL == nil {
+		t.Error("WAL stats missing with WAL enabled")
+	}
+	body, _ := json.Marshal(st)
+	if !bytes.Contains(body, []byte(`"OrdersPerSec":`)) || !bytes.Contains(body, []byte(`"TradesPerSec":`)) {
+		t.Errorf("engine rates missing from %s", body)
+	}
+
+	plain, err := mtrade.NewEngine(mtrade.DefaultEngineConfig("ETH_USDT"))
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if st := MatchingEngine(plain)().(MatchingStats); st.WAL != nil {
+		t.Errorf("WAL = %+v, want nil without WAL", st.WAL)
+	}
+}
@@ -0,0 +1,80 @@
+package diag
+
+import (
+	"max.com/pkg/asset"
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/nats"
+)
+
+// =============================================================================
+// 各引擎的数据源
+// =============================================================================
+
+// MatchingStats 撮合引擎诊断
+type MatchingStats struct {
+	Engine   mtrade.EngineStats    // 处理计数、每秒下单 / 成交数、EventsDropped、延迟分位
+	Queues   mtrade.QueueStats     // 订单 / 撤单 / 事件队列积压
+	Handlers []mtrade.HandlerStats // 各事件 handler 的积压、丢弃与延迟
+	WAL      *mtrade.WALStats      `json:",omitempty"` // 序列号与刷盘滞后，未启用 WAL 时为空
+}
+
+// MatchingEngine 撮合引擎数据源，建议注册为 "mtrade.<symbol>"
+func MatchingEngine(e *mtrade.Engine) Source {
+	return func() any {
+		st := MatchingStats{
+			Engine:   e.GetStats(),
+			Queues:   e.QueueStats(),
+			Handlers: e.HandlerStats(),
+		}
+		if wal, ok := e.WALStats(); ok {
+			st.WAL = &wal
+		}
+		return st
+	}
+}
+
+// ShardPlacement 分片线程放置情况 (PinError 转成字符串，error 直接序列化是 {})
+type ShardPlacement struct {
+	ShardID      int
+	LockedThread bool
+	CPUs         []int
+	Pinned       bool
+	PinError     string `json:",omitempty"`
+}
+
+// AssetStats 资金引擎诊断：分片排队、处理计数与线程放置
+type AssetStats struct {
+	asset.EngineStats
+	Placement []ShardPlacement
+}
+
+// AssetEngine 资金引擎数据源
+func AssetEngine(e *asset.AccountEngine) Source {
+	return func() any {
+		st := AssetStats{EngineStats: e.GetStats()}
+		st.Placement = make([]ShardPlacement, len(st.EngineStats.Placement))
+		for i, p := range st.EngineStats.Placement {
+			st.Placement[i] = ShardPlacement{
+				ShardID:      p.ShardID,
+				LockedThread: p.LockedThread,
+				CPUs:         p.CPUs,
+				Pinned:       p.Pinned,
+			}
+			if p.PinError != nil {
+				st.Placement[i].PinError = p.PinError.Error()
+			}
+		}
+		return st
+	}
+}
+
+// Liquidation 强平引擎数据源：各风险等级人数、待执行任务数、当前扫描间隔
+func Liquidation(e *liquidation.Engine) Source {
+	return func() any { return e.GetStats() }
+}
+
+// NATS 订阅者数据源：各订阅的客户端积压、丢弃与处理失败数
+func NATS(s *nats.Subscriber) Source {
+	return func() any { return s.Stats() }
+}
@@ -0,0 +1,286 @@
+// Package emergency 紧急控制：全站停机开关 (kill switch) 与单用户暂停交易
+//
+// 【场景】
+//
+//   - 全站停机：撮合 / 结算出现严重事故 (价格异常、账不平)，先让所有新单停下来再排查；
+//     可选同时撤掉所有撮合引擎上的挂单，避免事故期间的挂单被成交
+//
+//   - 单用户暂停：疑似被盗号、刷量、API 失控的账户，立即停止下单，但允许撤单和平仓，
+//     不把用户锁在风险敞口里 (与账户状态 RESTRICTED 的区别：暂停是运营应急手段，
+//     不改 KYC / 合规状态，恢复后原状态不变)
+//
+//     开仓/买入   平仓/卖出   撤单
+//     停机 (kill switch)     ✗          ✗         ✓
+//     用户暂停              ✗          ✓         ✓
+//
+// 现货没有仓位，卖出视为平仓 (同 pkg/account)
+//
+// 【收紧一人，放宽双人】停机、暂停一人即可立即生效 (管理接口，见 http.go)；
+// 解除必须走双人复核 (approval.KillSwitchReleaseOperation / approval.TradingResumeOperation)
+//
+// 【注意】
+//   - 检查发生在处理器入口：已通过检查、正在提交撮合的订单 (含合约 outbox 里待中继的开仓单)
+//     可能在撤单之后才到达引擎，停机后需要时再撤一次 (重复调用 Kill)
+//   - 强平单由强平执行器直接提交撮合，不经过处理器，不受停机影响 (停机期间风险仍要能降)
+//   - 状态只在内存：进程重启后停机 / 暂停解除，需要重新执行
+package emergency
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/audit"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/mtrade"
+)
+
+var (
+	ErrKillSwitch    = cexerr.New("EMERGENCY_KILL_SWITCH", cexerr.CategoryUnavailable, "trading halted by exchange kill switch")
+	ErrUserSuspended = cexerr.New("EMERGENCY_USER_SUSPENDED", cexerr.CategoryFailedPrecondition, "trading suspended for user, only cancels and closes allowed")
+	ErrNotEngaged    = cexerr.New("EMERGENCY_NOT_ENGAGED", cexerr.CategoryFailedPrecondition, "kill switch not engaged")
+	ErrNotSuspended  = cexerr.New("EMERGENCY_NOT_SUSPENDED", cexerr.CategoryFailedPrecondition, "user not suspended")
+	ErrInvalidAction = cexerr.New("EMERGENCY_INVALID_ACTION", cexerr.CategoryInvalidArgument, "invalid emergency action")
+
+	ErrApprovalRequired = cexerr.New("EMERGENCY_APPROVAL_REQUIRED", cexerr.CategoryFailedPrecondition, "release and resume require dual approval")
+)
+
+// Config 紧急控制配置
+type Config struct {
+	Engines       []mtrade.EngineRouter // 停机 / 暂停时撤单的撮合引擎 (现货、合约各自的路由)
+	Auditor       audit.Recorder        // 可选，每次操作记一条 ADMIN 审计
+	CancelTimeout time.Duration         // 撤单等待回执的上限，默认 10s
+	Now           func() time.Time      // 默认 time.Now
+}
+
+// KillSwitch 全站停机状态
+type KillSwitch struct {
+	Engaged    bool      `json:"engaged"`
+	OperatorID int64     `json:"operator_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	At         time.Time `json:"at,omitzero"`
+}
+
+// Suspension 一个用户的暂停记录
+type Suspension struct {
+	UserID     int64     `json:"user_id"`
+	OperatorID int64     `json:"operator_id"`
+	Reason     string    `json:"reason"`
+	At         time.Time `json:"at"`
+}
+
+// State 当前紧急控制状态
+type State struct {
+	KillSwitch KillSwitch   `json:"kill_switch"`
+	Suspended  []Suspension `json:"suspended"` // 按用户排序
+}
+
+// CancelResult 撤单结果
+type CancelResult struct {
+	Canceled int    `json:"canceled"`        // 撤掉的挂单数
+	Error    string `json:"error,omitempty"` // 部分引擎失败 (已撤的照常生效)
+}
+
+// Control 紧急控制，nil 时所有检查放行 (未配置的部署)
+type Control struct {
+	engines       []mtrade.EngineRouter
+	auditor       audit.Recorder
+	cancelTimeout time.Duration
+	now           func() time.Time
+
+	killed atomic.Bool // 下单热路径只读这个
+
+	mu        sync.RWMutex
+	kill      KillSwitch
+	suspended map[int64]Suspension
+}
+
+// New 创建紧急控制
+func New(cfg Config) *Control {
+	c := &Control{
+		engines:       cfg.Engines,
+		auditor:       cfg.Auditor,
+		cancelTimeout: cmp.Or(cfg.CancelTimeout, 10*time.Second),
+		now:           cfg.Now,
+		suspended:     make(map[int64]Suspension),
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	return c
+}
+
+// =============================================================================
+// 下单检查 (处理器入口)
+// =============================================================================
+
+// CheckOpen 开仓 / 现货买入前检查：停机或用户被暂停时拒绝
+func (c *Control) CheckOpen(userID int64) error {
+	if c == nil {
+		return nil
+	}
+	if c.killed.Load() {
+		return ErrKillSwitch
+	}
+	if c.Suspended(userID) {
+		return ErrUserSuspended
+	}
+	return nil
+}
+
+// CheckClose 平仓 / 现货卖出前检查：只有停机时拒绝
+func (c *Control) CheckClose(userID int64) error {
+	if c == nil {
+		return nil
+	}
+	if c.killed.Load() {
+		return ErrKillSwitch
+	}
+	return nil
+}
+
+// Suspended 用户是否被暂停交易
+func (c *Control) Suspended(userID int64) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	_, ok := c.suspended[userID]
+	c.mu.RUnlock()
+	return ok
+}
+
+// State 当前状态
+func (c *Control) State() State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	st := State{KillSwitch: c.kill, Suspended: make([]Suspension, 0, len(c.suspended))}
+	for _, s := range c.suspended {
+		st.Suspended = append(st.Suspended, s)
+	}
+	slices.SortFunc(st.Suspended, func(a, b Suspension) int { return cmp.Compare(a.UserID, b.UserID) })
+	return st
+}
+
+// =============================================================================
+// 全站停机
+// =============================================================================
+
+// Kill 启动全站停机，之后所有新单拒绝；cancelResting 为 true 时撤掉所有引擎上的挂单
+//
+// 已经停机时只更新原因 (可以再次调用来补撤挂单)
+func (c *Control) Kill(ctx context.Context, operatorID int64, reason string, cancelResting bool) (CancelResult, error) {
+	if operatorID <= 0 || reason == "" {
+		return CancelResult{}, ErrInvalidAction.Wrapf("operator_id and reason are required")
+	}
+	c.mu.Lock()
+	before := c.kill
+	c.kill = KillSwitch{Engaged: true, OperatorID: operatorID, Reason: reason, At: c.now()}
+	c.killed.Store(true)
+	c.mu.Unlock()
+	c.record(operatorID, "kill_switch", before.Engaged, true, reason)
+
+	if !cancelResting {
+		return CancelResult{}, nil
+	}
+	return c.cancel(ctx, mtrade.RouteCancelAll), nil
+}
+
+// Release 解除全站停机 (由双人复核执行)
+func (c *Control) Release(operatorID int64, reason string) error {
+	c.mu.Lock()
+	if !c.kill.Engaged {
+		c.mu.Unlock()
+		return ErrNotEngaged
+	}
+	c.kill = KillSwitch{}
+	c.killed.Store(false)
+	c.mu.Unlock()
+	c.record(operatorID, "kill_switch", true, false, reason)
+	return nil
+}
+
+// Engaged 是否处于全站停机
+func (c *Control) Engaged() bool {
+	return c != nil && c.killed.Load()
+}
+
+// =============================================================================
+// 单用户暂停
+// =============================================================================
+
+// Suspend 暂停用户交易，cancelOrders 为 true 时撤掉该用户在所有引擎上的挂单
+func (c *Control) Suspend(ctx context.Context, userID, operatorID int64, reason string, cancelOrders bool) (CancelResult, error) {
+	if userID <= 0 || operatorID <= 0 || reason == "" {
+		return CancelResult{}, ErrInvalidAction.Wrapf("user_id, operator_id and reason are required")
+	}
+	c.mu.Lock()
+	_, already := c.suspended[userID]
+	c.suspended[userID] = Suspension{UserID: userID, OperatorID: operatorID, Reason: reason, At: c.now()}
+	c.mu.Unlock()
+	c.record(operatorID, "trading_suspension:"+strconv.FormatInt(userID, 10), already, true, reason)
+
+	if !cancelOrders {
+		return CancelResult{}, nil
+	}
+	return c.cancel(ctx, func(ctx context.Context, r mtrade.EngineRouter) ([]int64, error) {
+		return mtrade.RouteCancelAllByUser(ctx, r, userID)
+	}), nil
+}
+
+// Resume 恢复用户交易 (由双人复核执行)
+func (c *Control) Resume(userID, operatorID int64, reason string) error {
+	c.mu.Lock()
+	if _, ok := c.suspended[userID]; !ok {
+		c.mu.Unlock()
+		return ErrNotSuspended.Wrapf("user %d", userID)
+	}
+	delete(c.suspended, userID)
+	c.mu.Unlock()
+	c.record(operatorID, "trading_suspension:"+strconv.FormatInt(userID, 10), true, false, reason)
+	return nil
+}
+
+// =============================================================================
+// 内部
+// =============================================================================
+
+// cancel 在每个路由上撤单，撤单事件照常由处理器解冻资金
+func (c *Control) cancel(ctx context.Context, fn func(context.Context, mtrade.EngineRouter) ([]int64, error)) CancelResult {
+	ctx, cancel := context.WithTimeout(ctx, c.cancelTimeout)
+	defer cancel()
+	var res CancelResult
+	var errs []error
+	for _, r := range c.engines {
+		ids, err := fn(ctx, r)
+		res.Canceled += len(ids)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// record 记审计：before / after 为是否处于停机 / 暂停
+func (c *Control) record(operatorID int64, resource string, before, after bool, reason string) {
+	if c.auditor == nil {
+		return
+	}
+	c.auditor.Record(audit.Event{
+		ActorType: audit.ActorAdmin,
+		ActorID:   operatorID,
+		Action:    audit.ActionAdmin,
+		Resource:  resource,
+		Before:    before,
+		After:     after,
+		Meta:      map[string]string{"reason": reason},
+	})
+}
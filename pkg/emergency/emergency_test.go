@@ -0,0 +1,138 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"max.com/pkg/mtrade"
+)
+
+func newTestEngine(t *testing.T, symbol string) *mtrade.Engine {
+	t.Helper()
+	engine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig(symbol))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Start(context.Background())
+	t.Cleanup(engine.Stop)
+	return engine
+}
+
+func rest(t *testing.T, engine *mtrade.Engine, symbol string, id, userID int64) {
+	t.Helper()
+	o := &mtrade.Order{ID: id, UserID: userID, Symbol: symbol, Side: mtrade.SideBuy, Type: mtrade.OrderTypeLimit, Price: 100, Qty: 1}
+	if _, err := engine.SubmitOrderSync(context.Background(), o); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestControl_NilAllows(t *testing.T) {
+	var c *Control
+	if c.CheckOpen(1) != nil || c.CheckClose(1) != nil || c.Suspended(1) || c.Engaged() {
+		t.Fatal("nil control should allow everything")
+	}
+}
+
+func TestControl_KillSwitch(t *testing.T) {
+	btc := newTestEngine(t, "BTC_USDT")
+	eth := newTestEngine(t, "ETH_USDT")
+	rest(t, btc, "BTC_USDT", 1, 7)
+	rest(t, btc, "BTC_USDT", 2, 8)
+	rest(t, eth, "ETH_USDT", 3, 9)
+	c := New(Config{Engines: []mtrade.EngineRouter{mtrade.SingleEngine(btc), mtrade.SingleEngine(eth)}})
+	ctx := context.Background()
+
+	if _, err := c.Kill(ctx, 1, "", false); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("kill without reason: err = %v", err)
+	}
+	res, err := c.Kill(ctx, 1, "mark price feed broken", true)
+	if err != nil || res.Canceled != 3 || res.Error != "" {
+		t.Fatalf("Kill = %+v, %v", res, err)
+	}
+	if snap := btc.GetOrderBook().GetSnapshot(); snap.Orders != 0 {
+		t.Fatalf("btc book still has %d orders", snap.Orders)
+	}
+	if !errors.Is(c.CheckOpen(7), ErrKillSwitch) || !errors.Is(c.CheckClose(7), ErrKillSwitch) {
+		t.Fatal("kill switch should block opens and closes")
+	}
+	if st := c.State().KillSwitch; !st.Engaged || st.OperatorID != 1 {
+		t.Fatalf("state = %+v", st)
+	}
+
+	if err := c.Release(2, "feed recovered"); err != nil {
+		t.Fatal(err)
+	}
+	if c.CheckOpen(7) != nil || c.Engaged() {
+		t.Fatal("released kill switch should allow orders")
+	}
+	if err := c.Release(2, "again"); !errors.Is(err, ErrNotEngaged) {
+		t.Fatalf("second release: err = %v", err)
+	}
+}
+
+func TestControl_Suspend(t *testing.T) {
+	btc := newTestEngine(t, "BTC_USDT")
+	rest(t, btc, "BTC_USDT", 1, 7)
+	rest(t, btc, "BTC_USDT", 2, 8)
+	c := New(Config{Engines: []mtrade.EngineRouter{mtrade.SingleEngine(btc)}})
+
+	res, err := c.Suspend(context.Background(), 7, 1, "api key leaked", true)
+	if err != nil || res.Canceled != 1 {
+		t.Fatalf("Suspend = %+v, %v", res, err)
+	}
+	if snap := btc.GetOrderBook().GetSnapshot(); snap.Orders != 1 {
+		t.Fatalf("other users' orders should stay, got %d", snap.Orders)
+	}
+	if !errors.Is(c.CheckOpen(7), ErrUserSuspended) {
+		t.Fatal("suspended user should not open")
+	}
+	if c.CheckClose(7) != nil || c.CheckOpen(8) != nil {
+		t.Fatal("suspended user can close, others unaffected")
+	}
+
+	if err := c.Resume(7, 2, "key rotated"); err != nil {
+		t.Fatal(err)
+	}
+	if c.CheckOpen(7) != nil || len(c.State().Suspended) != 0 {
+		t.Fatal("resumed user should trade")
+	}
+	if err := c.Resume(7, 2, "again"); !errors.Is(err, ErrNotSuspended) {
+		t.Fatalf("second resume: err = %v", err)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	c := New(Config{})
+	h := NewAdminHandler(c)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := post("/emergency/suspend", `{"user_id":7,"operator_id":1}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("suspend without reason: %d", w.Code)
+	}
+	if w := post("/emergency/suspend", `{"user_id":7,"operator_id":1,"reason":"wash trading"}`); w.Code != http.StatusOK {
+		t.Fatalf("suspend: %d %s", w.Code, w.Body)
+	}
+	if w := post("/emergency/kill", `{"operator_id":1,"reason":"incident"}`); w.Code != http.StatusOK {
+		t.Fatalf("kill: %d %s", w.Code, w.Body)
+	}
+	// 放宽只能走双人复核
+	for _, path := range []string{"/emergency/release", "/emergency/resume"} {
+		if w := post(path, `{}`); w.Code != http.StatusForbidden {
+			t.Fatalf("%s: %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/emergency", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"engaged":true`) || !strings.Contains(body, `"user_id":7`) {
+		t.Fatalf("state: %s", body)
+	}
+}
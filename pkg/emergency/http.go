@@ -0,0 +1,88 @@
+package emergency
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// =============================================================================
+// 管理后台接口 (仅内网，鉴权由网关负责)
+// =============================================================================
+
+// KillRequest 全站停机请求
+type KillRequest struct {
+	OperatorID    int64  `json:"operator_id"`
+	Reason        string `json:"reason"`
+	CancelResting bool   `json:"cancel_resting"` // 同时撤掉所有挂单
+}
+
+// SuspendRequest 暂停用户交易请求
+type SuspendRequest struct {
+	UserID       int64  `json:"user_id"`
+	OperatorID   int64  `json:"operator_id"`
+	Reason       string `json:"reason"`
+	CancelOrders bool   `json:"cancel_orders"` // 同时撤掉该用户的挂单
+}
+
+// NewAdminHandler 创建紧急控制管理接口
+//
+//	GET  /emergency           当前停机状态与暂停名单
+//	POST /emergency/kill      全站停机 (KillRequest)
+//	POST /emergency/suspend   暂停用户交易 (SuspendRequest)
+//	POST /emergency/release   403，解除停机须走 /admin/approvals (KILL_SWITCH_RELEASE)
+//	POST /emergency/resume    403，恢复交易须走 /admin/approvals (TRADING_RESUME)
+func NewAdminHandler(c *Control) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /emergency", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.State())
+	})
+	mux.HandleFunc("POST /emergency/kill", func(w http.ResponseWriter, r *http.Request) {
+		var req KillRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		res, err := c.Kill(r.Context(), req.OperatorID, req.Reason, req.CancelResting)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, res)
+	})
+	mux.HandleFunc("POST /emergency/suspend", func(w http.ResponseWriter, r *http.Request) {
+		var req SuspendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		res, err := c.Suspend(r.Context(), req.UserID, req.OperatorID, req.Reason, req.CancelOrders)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, res)
+	})
+	requireApproval := func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, ErrApprovalRequired)
+	}
+	mux.HandleFunc("POST /emergency/release", requireApproval)
+	mux.HandleFunc("POST /emergency/resume", requireApproval)
+	return mux
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidAction):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrApprovalRequired):
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
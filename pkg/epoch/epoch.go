@@ -0,0 +1,61 @@
+// Package epoch 撮合 → 结算的纪元 (epoch) 隔离
+//
+// 【面试高频】主备切换后，旧主还活着怎么办？(zombie / split-brain)
+//
+//	网络分区时备机被提升为新主，旧主可能还在跑，继续往结算链路发成交事件。
+//	同一笔订单在两边各撮合一次，结算就会重复入账。
+//
+// 做法 (fencing token)：
+//  1. 每次主备切换，纪元号原子地 +1，新主以新纪元启动并写入 WAL
+//  2. 撮合产生的成交事件、下游的资金命令都带上纪元号
+//  3. 消费方记录见过的最大纪元，比它小的事件直接拒绝
+//
+// 纪元 0 表示未启用隔离（单机部署、测试），一律放行
+package epoch
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrStale 事件来自旧纪元（已被切换掉的撮合实例）
+var ErrStale = errors.New("epoch: stale epoch")
+
+// Fence 消费方的纪元栅栏
+// 并发安全：多个分片 / handler 可以共享同一个 Fence
+type Fence struct {
+	highest atomic.Uint64
+}
+
+// Admit 检查事件纪元：小于已知最大纪元则拒绝，否则放行并推进栅栏
+func (f *Fence) Admit(epoch uint64) error {
+	if epoch == 0 {
+		return nil
+	}
+	for {
+		cur := f.highest.Load()
+		if epoch < cur {
+			return fmt.Errorf("%w: %d < %d", ErrStale, epoch, cur)
+		}
+		if epoch == cur || f.highest.CompareAndSwap(cur, epoch) {
+			return nil
+		}
+	}
+}
+
+// Advance 切换时由控制面调用：新主启动前先推进栅栏，
+// 避免旧主在新主第一笔事件到达前还能写入
+func (f *Fence) Advance(epoch uint64) {
+	for {
+		cur := f.highest.Load()
+		if epoch <= cur || f.highest.CompareAndSwap(cur, epoch) {
+			return
+		}
+	}
+}
+
+// Current 已知的最大纪元
+func (f *Fence) Current() uint64 {
+	return f.highest.Load()
+}
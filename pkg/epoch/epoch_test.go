@@ -0,0 +1,38 @@
+package epoch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFence(t *testing.T) {
+	var f Fence
+
+	// 纪元 0 不参与隔离
+	if err := f.Admit(0); err != nil {
+		t.Fatalf("epoch 0 should always pass: %v", err)
+	}
+
+	if err := f.Admit(2); err != nil || f.Current() != 2 {
+		t.Fatalf("expected fence at 2, got %d (%v)", f.Current(), err)
+	}
+	if err := f.Admit(2); err != nil {
+		t.Errorf("same epoch should pass: %v", err)
+	}
+	if err := f.Admit(1); !errors.Is(err, ErrStale) {
+		t.Errorf("expected ErrStale for older epoch, got %v", err)
+	}
+
+	// 控制面先推进，旧主的事件在新主发出第一条之前就被拒
+	f.Advance(5)
+	f.Advance(4) // 不回退
+	if f.Current() != 5 {
+		t.Fatalf("expected fence at 5, got %d", f.Current())
+	}
+	if err := f.Admit(2); !errors.Is(err, ErrStale) {
+		t.Errorf("expected ErrStale after advance, got %v", err)
+	}
+	if err := f.Admit(0); err != nil {
+		t.Errorf("epoch 0 should still pass: %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+// Package eventlog 领域事件统一导出 (event sourcing export)
+//
+// 把撮合 (下单/撤单/成交)、资金 (流水)、强平、资金费等分散在各模块里的事件，
+// 汇成一条全局有序、只追加的事件流，写到文件和/或 Kafka，
+// 数据/分析团队按 Seq 顺序消费，不用再去扒 MySQL。
+//
+//	mtrade.Engine ──MatchHandler──┐
+//	spot 流水 ──────PublishJournal──┤
+//	强平成交 ──────LiquidationHandler┼──→ Log (单写者分配 Seq) ──→ FileSink  (分段文件，Replay 读取)
+//	资金费结算 ────FundingHandler───┘                          └─→ KafkaSink (topic: domain_events)
+//
+// 每条事件是一个 Record 信封 (JSON)：Seq / Type / Time / Key / Data，Data 的结构由 Type
+// 决定 (见 schema.go 和 schema.md)。Seq 从 1 开始严格递增、没有空洞，下游据此去重和发现丢失。
+//
+// 【面试】为什么是"一个写者"？
+// 全局顺序只能由一个地方决定。各来源并发调用 Append 只是入队，写者 goroutine 按出队顺序
+// 分配 Seq 并依次写入所有 Sink，文件和 Kafka 里看到的顺序完全一致。
+// 跨来源的顺序是"导出顺序"，不是业务因果顺序：同一来源内部 (同一交易对的撮合事件、
+// 同一次资金费结算) 的先后是可靠的，跨来源请用 Time 近似对齐。
+//
+// 【注意】导出是权威数据，不能丢：队列满时 Append 阻塞而不是丢弃，
+// 接在撮合引擎上时要用独立的 handler 队列 (DeliveryBlocking)，慢盘只拖慢导出，不拖慢撮合
+//
+// 【fail-closed】Sink 写失败时写者按退避重试同一条，直到写成功才分配下一个 Seq，
+// 后面的事件在队列里等 (Stats.Stalled 为 true)。Close 等 CloseTimeout 还写不完就放弃并返回 ErrStalled，
+// 已经出错的那条和队列里剩下的都算未导出，不会出现"Seq 跳过一条"的空洞
+package eventlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrClosed  = errors.New("eventlog: log is closed")
+	ErrCorrupt = errors.New("eventlog: corrupt record")
+	ErrGap     = errors.New("eventlog: sequence gap")
+	// ErrStalled Close 超时时 Sink 仍写不进去，队列里还有事件没导出
+	ErrStalled = errors.New("eventlog: sink stalled, records not exported")
+)
+
+// =============================================================================
+// 事件信封
+// =============================================================================
+
+// SchemaVersion 信封和 Data 结构的版本，不兼容变更时递增
+const SchemaVersion = 1
+
+// Record 一条导出事件
+type Record struct {
+	Seq  uint64          `json:"seq"`  // 全局序号，从 1 开始，严格递增无空洞
+	Type Type            `json:"type"` // 事件类型，决定 Data 的结构
+	Time int64           `json:"time"` // 事件发生时间 (Unix 毫秒)
+	Key  string          `json:"key"`  // 分区键：撮合事件为交易对，资金类事件为用户 ID
+	Data json.RawMessage `json:"data"`
+	V    int             `json:"v"` // SchemaVersion
+}
+
+// Decode 把 Data 解析到对应的结构 (如 *OrderData)
+func (r *Record) Decode(v any) error {
+	return json.Unmarshal(r.Data, v)
+}
+
+// Sink 事件落地目标
+//
+// Write 由写者 goroutine 串行调用，raw 为 Record 的 JSON 编码 (所有 Sink 共用，不能修改)
+type Sink interface {
+	Write(rec *Record, raw []byte) error
+	Flush() error
+	Close() error
+}
+
+// =============================================================================
+// Log
+// =============================================================================
+
+// Config 导出配置
+type Config struct {
+	Sinks         []Sink
+	QueueSize     int           // 入队缓冲，默认 8192
+	FlushInterval time.Duration // 定时刷盘间隔，默认 100ms；队列空闲时也会刷
+	StartSeq      uint64        // 已导出的最后一个 Seq (续写时传 FileSink.LastSeq())
+	RetryBackoff  time.Duration // Sink 写失败后的首次重试间隔，默认 10ms，之后翻倍直到 maxRetryBackoff
+	CloseTimeout  time.Duration // Close 等队列写完的上限，默认 5s；超时仍有 Sink 在重试则放弃
+}
+
+// maxRetryBackoff Sink 写失败重试间隔上限
+const maxRetryBackoff = 5 * time.Second
+
+// Stats 导出统计
+type Stats struct {
+	Appended int64  // 已入队
+	Written  int64  // 已写入所有 Sink
+	Errors   int64  // Sink 写入失败次数
+	LastSeq  uint64 // 最后写入所有 Sink 的 Seq
+	QueueLen int
+	Stalled  bool // Sink 正在写失败重试，后面的事件被挡住
+}
+
+type pending struct {
+	typ  Type
+	time int64
+	key  string
+	data json.RawMessage
+}
+
+// Log 全局有序事件流
+type Log struct {
+	config Config
+	ch     chan pending
+
+	mu     sync.RWMutex // 保护 closed 与 ch 的关闭
+	closed bool
+
+	seq      atomic.Uint64
+	appended atomic.Int64
+	written  atomic.Int64
+	errs     atomic.Int64
+	stalled  atomic.Bool
+
+	quit     chan struct{} // Close 超时后关闭：通知写者放弃重试、唤醒阻塞的 Append
+	quitOnce sync.Once
+	failure  error // 写者放弃时的原因，done 关闭后读取
+	done     chan struct{}
+}
+
+// New 创建并启动导出
+func New(cfg Config) *Log {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 8192
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 100 * time.Millisecond
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 10 * time.Millisecond
+	}
+	if cfg.CloseTimeout <= 0 {
+		cfg.CloseTimeout = 5 * time.Second
+	}
+	l := &Log{
+		config: cfg,
+		ch:     make(chan pending, cfg.QueueSize),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	l.seq.Store(cfg.StartSeq)
+	go l.writeLoop()
+	return l
+}
+
+// Append 追加一条事件 (并发安全)
+//
+// data 在调用方 goroutine 里序列化，调用返回后 data 可以被复用 (撮合事件的池化对象)
+func (l *Log) Append(typ Type, at time.Time, key string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("eventlog: encode %s: %w", typ, err)
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return ErrClosed
+	}
+	// Sink 卡住时队列会满：Close 超时要能把阻塞在这里的调用放出来，否则拿不到写锁
+	select {
+	case l.ch <- pending{typ: typ, time: at.UnixMilli(), key: key, data: raw}:
+	case <-l.quit:
+		return ErrClosed
+	}
+	l.appended.Add(1)
+	return nil
+}
+
+// Close 停止接收，写完队列中的事件后刷盘并关闭所有 Sink
+//
+// Sink 写失败时最多等 CloseTimeout：仍写不进去就返回 ErrStalled，未导出的事件要靠上游重放补齐
+func (l *Log) Close() error {
+	timer := time.AfterFunc(l.config.CloseTimeout, func() {
+		l.quitOnce.Do(func() { close(l.quit) })
+	})
+	defer timer.Stop()
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.ch)
+	l.mu.Unlock()
+
+	<-l.done
+	var errs []error
+	if l.failure != nil {
+		errs = append(errs, l.failure)
+	}
+	for _, s := range l.config.Sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats 返回统计
+func (l *Log) Stats() Stats {
+	return Stats{
+		Appended: l.appended.Load(),
+		Written:  l.written.Load(),
+		Errors:   l.errs.Load(),
+		LastSeq:  l.seq.Load(),
+		QueueLen: len(l.ch),
+		Stalled:  l.stalled.Load(),
+	}
+}
+
+// writeLoop 唯一的写者：分配 Seq，依次写入所有 Sink
+func (l *Log) writeLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+
+	dirty := false
+	for {
+		select {
+		case p, ok := <-l.ch:
+			if !ok {
+				l.flush()
+				return
+			}
+			if !l.write(p) {
+				l.flush()
+				return
+			}
+			dirty = true
+			// 队列暂时空了就刷一次，低流量时延迟不受 FlushInterval 影响
+			if len(l.ch) == 0 {
+				l.flush()
+				dirty = false
+			}
+		case <-ticker.C:
+			if dirty {
+				l.flush()
+				dirty = false
+			}
+		}
+	}
+}
+
+// write 写一条到所有 Sink，全部成功后才推进 Seq；返回 false 表示 Close 时仍写不进去，写者退出
+func (l *Log) write(p pending) bool {
+	rec := &Record{
+		Seq:  l.seq.Load() + 1,
+		Type: p.typ,
+		Time: p.time,
+		Key:  p.key,
+		Data: p.data,
+		V:    SchemaVersion,
+	}
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		// Data 已经是合法 JSON，这里不会失败
+		l.errs.Add(1)
+		return true
+	}
+	for _, s := range l.config.Sinks {
+		if !l.writeSink(s, rec, raw) {
+			return false
+		}
+	}
+	l.seq.Store(rec.Seq)
+	l.written.Add(1)
+	return true
+}
+
+// writeSink 写一个 Sink，失败按退避重试同一条直到成功
+//
+// 已写成功的 Sink 不重写，只重试失败的那个；Close 超时后不再等待，记下原因返回 false
+func (l *Log) writeSink(s Sink, rec *Record, raw []byte) bool {
+	backoff := l.config.RetryBackoff
+	for {
+		err := s.Write(rec, raw)
+		if err == nil {
+			l.stalled.Store(false)
+			return true
+		}
+		l.errs.Add(1)
+		l.stalled.Store(true)
+		log.Printf("[EventLog] sink write failed, retry in %v: seq=%d type=%s err=%v", backoff, rec.Seq, rec.Type, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-l.quit:
+			l.failure = fmt.Errorf("%w: seq %d onwards (%d records): %w",
+				ErrStalled, rec.Seq, l.appended.Load()-l.written.Load(), err)
+			return false
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+func (l *Log) flush() {
+	for _, s := range l.config.Sinks {
+		if err := s.Flush(); err != nil {
+			l.errs.Add(1)
+			log.Printf("[EventLog] sink flush failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package eventlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/mtrade"
+)
+
+func openLog(t *testing.T, dir string) (*Log, *FileSink) {
+	t.Helper()
+	sink, err := NewFileSink(FileSinkConfig{Dir: dir, SegmentSize: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(Config{Sinks: []Sink{sink}, StartSeq: sink.LastSeq()}), sink
+}
+
+func TestFileExportAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openLog(t, dir)
+
+	match := l.MatchHandler()
+	order := &mtrade.Order{ID: 1, UserID: 7, Symbol: "BTC_USDT", Side: mtrade.SideBuy, Price: 100, Qty: 2, Status: mtrade.OrderStatusNew}
+	match(mtrade.Event{Type: mtrade.EventOrderAccepted, Timestamp: time.Now().UnixNano(), Order: order, Seq: 1})
+	match(mtrade.Event{Type: mtrade.EventBookUpdate}) // 不导出
+	match(mtrade.Event{Type: mtrade.EventTrade, Trade: &mtrade.Trade{ID: 9, Symbol: "BTC_USDT", Price: 100, Qty: 1, TakerID: 2, MakerID: 1, TakerSide: mtrade.SideSell}})
+	for i := 0; i < 20; i++ {
+		l.PublishJournal(&fund.JournalEvent{EventID: "dep", UserID: 7, Symbol: "USDT", ChangeType: fund.ChangeTypeDeposit, Amount: int64(i), CreatedAt: time.Now()})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(TypeTrade, time.Now(), "", nil); !errors.Is(err, ErrClosed) {
+		t.Fatalf("append after close: %v", err)
+	}
+
+	segments, _ := listSegments(dir)
+	if len(segments) < 2 {
+		t.Fatalf("expected rotation, got %v", segments)
+	}
+
+	// 模拟写到一半宕机：最后一段末尾留半个帧
+	last := filepath.Join(dir, segments[len(segments)-1])
+	f, _ := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad})
+	f.Close()
+
+	var got []*Record
+	if err := Replay(dir, 0, func(r *Record) error { got = append(got, r); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 22 || got[0].Type != TypeOrderAccepted || got[1].Type != TypeTrade || got[21].Seq != 22 {
+		t.Fatalf("unexpected replay: %d records", len(got))
+	}
+	var od OrderData
+	if err := got[0].Decode(&od); err != nil || od.UserID != 7 || od.Side != "BUY" || od.Status != "NEW" {
+		t.Fatalf("order data: %+v %v", od, err)
+	}
+
+	// 重启续写：截掉半帧，Seq 接着往下
+	l, sink := openLog(t, dir)
+	if sink.LastSeq() != 22 {
+		t.Fatalf("last seq %d", sink.LastSeq())
+	}
+	l.PublishJournal(&fund.JournalEvent{EventID: "wd", UserID: 8, Symbol: "USDT", ChangeType: fund.ChangeTypeWithdraw, Amount: 1, CreatedAt: time.Now()})
+	l.Close()
+
+	var tail []*Record
+	if err := Replay(dir, 21, func(r *Record) error { tail = append(tail, r); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(tail) != 3 || tail[0].Seq != 21 || tail[2].Seq != 23 || tail[2].Key != "8" {
+		t.Fatalf("unexpected tail: %+v", tail)
+	}
+}
+
+// flakySink 前 failures 次写入失败，之后正常；down 为 true 时一直失败
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	down     bool
+	seqs     []uint64
+}
+
+func (s *flakySink) Write(rec *Record, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down || s.failures > 0 {
+		s.failures--
+		return errors.New("disk full")
+	}
+	s.seqs = append(s.seqs, rec.Seq)
+	return nil
+}
+
+func (s *flakySink) Flush() error { return nil }
+func (s *flakySink) Close() error { return nil }
+
+func (s *flakySink) written() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64(nil), s.seqs...)
+}
+
+func TestSinkFailureNoGap(t *testing.T) {
+	good := &flakySink{}
+	bad := &flakySink{failures: 3}
+	l := New(Config{Sinks: []Sink{good, bad}, RetryBackoff: time.Millisecond, StartSeq: 10})
+	for i := 0; i < 5; i++ {
+		if err := l.Append(TypeBalance, time.Now(), "1", BalanceData{Amount: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 失败的那条重试写成功后才继续，两个 Sink 都是 11..15，没有跳号也没有重复
+	want := []uint64{11, 12, 13, 14, 15}
+	for name, s := range map[string]*flakySink{"good": good, "bad": bad} {
+		if got := s.written(); !slices.Equal(got, want) {
+			t.Errorf("%s sink seqs = %v, want %v", name, got, want)
+		}
+	}
+	if st := l.Stats(); st.Errors != 3 || st.Written != 5 || st.LastSeq != 15 || st.Stalled {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestSinkStalledClose(t *testing.T) {
+	sink := &flakySink{down: true}
+	l := New(Config{Sinks: []Sink{sink}, QueueSize: 1, RetryBackoff: time.Millisecond, CloseTimeout: 50 * time.Millisecond})
+	if err := l.Append(TypeBalance, time.Now(), "1", BalanceData{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !l.Stats().Stalled {
+		if time.Now().After(deadline) {
+			t.Fatal("writer not stalled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 写者卡住时 Seq 不前进，后面的事件排在队列里
+	if err := l.Append(TypeBalance, time.Now(), "1", BalanceData{}); err != nil {
+		t.Fatal(err)
+	}
+	if st := l.Stats(); st.LastSeq != 0 || st.Written != 0 || st.QueueLen != 1 {
+		t.Fatalf("stats while stalled %+v", st)
+	}
+
+	// 队列满时阻塞的 Append 要在 Close 超时后被放出来
+	blocked := make(chan error, 1)
+	go func() { blocked <- l.Append(TypeBalance, time.Now(), "1", BalanceData{}) }()
+
+	if err := l.Close(); !errors.Is(err, ErrStalled) {
+		t.Fatalf("close: %v", err)
+	}
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("blocked append: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("append still blocked after close")
+	}
+	if got := sink.written(); len(got) != 0 {
+		t.Fatalf("stalled sink wrote %v", got)
+	}
+}
@@ -0,0 +1,386 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// 文件格式
+// =============================================================================
+//
+// 目录下按 Seq 分段，文件名为段内第一条事件的 Seq (20 位补零，字典序即数值序)：
+//
+//	events-00000000000000000001.log
+//	events-00000000000001048577.log
+//
+// 每条事件一个帧，大端序：
+//
+//	+----------------+----------------+------------------------+
+//	| length (4B)    | crc32 (4B)     | payload (length 字节)   |
+//	+----------------+----------------+------------------------+
+//
+// payload 为 Record 的 JSON 编码，crc32 为 payload 的 IEEE 校验和。
+// 只有最后一段的末尾允许出现不完整的帧 (写到一半宕机)，打开时截掉，读取时视为结束。
+
+const (
+	segmentPrefix      = "events-"
+	segmentSuffix      = ".log"
+	frameHeaderSize    = 8
+	maxFrameSize       = 16 << 20 // 单条事件上限，超过视为损坏 (防止坏长度导致巨量分配)
+	defaultSegmentSize = 256 << 20
+)
+
+func segmentName(firstSeq uint64) string {
+	return fmt.Sprintf("%s%020d%s", segmentPrefix, firstSeq, segmentSuffix)
+}
+
+// listSegments 按起始 Seq 升序返回分段文件
+func listSegments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		if _, err := segmentFirstSeq(name); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func segmentFirstSeq(name string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+}
+
+// readFrame 读一帧
+// 干净结束返回 io.EOF，帧不完整返回 io.ErrUnexpectedEOF，校验失败返回 ErrCorrupt
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err // io.EOF 或 io.ErrUnexpectedEOF
+	}
+	n := binary.BigEndian.Uint32(header[0:4])
+	if n == 0 || n > maxFrameSize {
+		return nil, fmt.Errorf("%w: frame length %d", ErrCorrupt, n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	return payload, nil
+}
+
+// =============================================================================
+// FileSink
+// =============================================================================
+
+// FileSinkConfig 文件导出配置
+type FileSinkConfig struct {
+	Dir         string
+	SegmentSize int64 // 单段大小上限，默认 256MB
+	Sync        bool  // Flush 时 fsync (宕机不丢已刷出的事件，代价是每次刷盘一次 IO 等待)
+}
+
+// FileSink 追加写分段文件
+type FileSink struct {
+	config  FileSinkConfig
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64
+	lastSeq uint64
+	header  [frameHeaderSize]byte
+}
+
+// NewFileSink 打开 (或创建) 导出目录
+//
+// 已有数据时扫描最后一段，截掉末尾不完整的帧，之后从 LastSeq()+1 续写
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &FileSink{config: cfg}
+
+	segments, err := listSegments(cfg.Dir)
+	if err != nil || len(segments) == 0 {
+		return s, err
+	}
+	last := filepath.Join(cfg.Dir, segments[len(segments)-1])
+	valid, lastSeq, err := scanSegment(last)
+	if err != nil {
+		return nil, err
+	}
+	if lastSeq == 0 && len(segments) > 1 {
+		// 最后一段为空，LastSeq 取前一段
+		if _, lastSeq, err = scanSegment(filepath.Join(cfg.Dir, segments[len(segments)-2])); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(last, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+	s.writer = bufio.NewWriterSize(file, 64<<10)
+	s.size = valid
+	s.lastSeq = lastSeq
+	return s, nil
+}
+
+// scanSegment 返回分段中完整帧的总长度和最后一条的 Seq
+func scanSegment(path string) (valid int64, lastSeq uint64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	for {
+		payload, err := readFrame(br)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return valid, lastSeq, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s at offset %d: %w", filepath.Base(path), valid, err)
+		}
+		var rec Record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return 0, 0, fmt.Errorf("%w: %s at offset %d: %v", ErrCorrupt, filepath.Base(path), valid, err)
+		}
+		valid += frameHeaderSize + int64(len(payload))
+		lastSeq = rec.Seq
+	}
+}
+
+// LastSeq 已写入的最后一个 Seq (作为 Config.StartSeq 续写)
+func (s *FileSink) LastSeq() uint64 {
+	return s.lastSeq
+}
+
+// Write 实现 Sink
+func (s *FileSink) Write(rec *Record, raw []byte) error {
+	if rec.Seq != s.lastSeq+1 {
+		return fmt.Errorf("%w: got seq %d after %d", ErrGap, rec.Seq, s.lastSeq)
+	}
+	if s.file == nil || s.size >= s.config.SegmentSize {
+		if err := s.rotate(rec.Seq); err != nil {
+			return err
+		}
+	}
+	binary.BigEndian.PutUint32(s.header[0:4], uint32(len(raw)))
+	binary.BigEndian.PutUint32(s.header[4:8], crc32.ChecksumIEEE(raw))
+	if _, err := s.writer.Write(s.header[:]); err != nil {
+		return err
+	}
+	if _, err := s.writer.Write(raw); err != nil {
+		return err
+	}
+	s.size += frameHeaderSize + int64(len(raw))
+	s.lastSeq = rec.Seq
+	return nil
+}
+
+// rotate 关闭当前段，以 firstSeq 命名新段
+func (s *FileSink) rotate(firstSeq uint64) error {
+	if err := s.closeSegment(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(s.config.Dir, segmentName(firstSeq)), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.writer = bufio.NewWriterSize(file, 64<<10)
+	s.size = 0
+	return nil
+}
+
+// Flush 实现 Sink
+func (s *FileSink) Flush() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.config.Sync {
+		return s.file.Sync()
+	}
+	return nil
+}
+
+// Close 实现 Sink
+func (s *FileSink) Close() error {
+	return s.closeSegment()
+}
+
+func (s *FileSink) closeSegment() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.writer.Flush()
+	if syncErr := s.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.writer = nil, nil
+	return err
+}
+
+// =============================================================================
+// Reader - 回放
+// =============================================================================
+
+// Reader 按 Seq 顺序读取导出目录
+//
+// 读到末尾返回 io.EOF；写者仍在追加时，之后可以用 LastSeq()+1 重新打开继续读
+type Reader struct {
+	dir      string
+	segments []string
+	idx      int
+	file     *os.File
+	br       *bufio.Reader
+	from     uint64
+	lastSeq  uint64
+}
+
+// NewReader 从 fromSeq (含) 开始读，fromSeq <= 1 表示从头读
+func NewReader(dir string, fromSeq uint64) (*Reader, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	// 跳过整段都在 fromSeq 之前的分段
+	start := 0
+	for i, name := range segments {
+		if first, _ := segmentFirstSeq(name); first <= fromSeq {
+			start = i
+		}
+	}
+	return &Reader{dir: dir, segments: segments, idx: start, from: fromSeq}, nil
+}
+
+// Next 读取下一条，没有更多事件时返回 io.EOF
+//
+// 段与段之间、段内 Seq 必须连续，否则返回 ErrGap
+func (r *Reader) Next() (*Record, error) {
+	for {
+		if r.br == nil {
+			if r.idx >= len(r.segments) {
+				return nil, io.EOF
+			}
+			f, err := os.Open(filepath.Join(r.dir, r.segments[r.idx]))
+			if err != nil {
+				return nil, err
+			}
+			r.file, r.br = f, bufio.NewReaderSize(f, 64<<10)
+		}
+
+		payload, err := readFrame(r.br)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if err == io.ErrUnexpectedEOF && r.idx < len(r.segments)-1 {
+				return nil, fmt.Errorf("%w: truncated frame in %s", ErrCorrupt, r.segments[r.idx])
+			}
+			r.closeFile()
+			if r.idx >= len(r.segments)-1 {
+				return nil, io.EOF // 最后一段的不完整帧：写者还没写完
+			}
+			r.idx++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.segments[r.idx], err)
+		}
+
+		var rec Record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, r.segments[r.idx], err)
+		}
+		if r.lastSeq != 0 && rec.Seq != r.lastSeq+1 {
+			return nil, fmt.Errorf("%w: seq %d after %d", ErrGap, rec.Seq, r.lastSeq)
+		}
+		r.lastSeq = rec.Seq
+		if rec.Seq < r.from {
+			continue
+		}
+		return &rec, nil
+	}
+}
+
+// LastSeq 最后读到的 Seq
+func (r *Reader) LastSeq() uint64 {
+	return r.lastSeq
+}
+
+// Close 关闭当前文件
+func (r *Reader) Close() error {
+	r.closeFile()
+	return nil
+}
+
+func (r *Reader) closeFile() {
+	if r.file != nil {
+		r.file.Close()
+		r.file, r.br = nil, nil
+	}
+}
+
+// Replay 从 fromSeq (含) 开始依次回调，fn 返回错误时停止并返回该错误
+func Replay(dir string, fromSeq uint64, fn func(*Record) error) error {
+	r, err := NewReader(dir, fromSeq)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package eventlog
+
+import "max.com/pkg/kafka"
+
+// DefaultTopic 默认导出 topic
+const DefaultTopic = "domain_events"
+
+// KafkaSink 写入 Kafka
+//
+// 消息 key 为 Record.Key：同一交易对 / 同一用户的事件落在同一分区，分区内有序。
+// 全局顺序以 Seq 为准，需要全局有序的消费者用单分区 topic，或按 Seq 归并各分区。
+//
+// 【注意】Producer 是异步发送，Flush 不等待 broker 确认；
+// 要求"导出即持久"的场景以 FileSink 为准，Kafka 用于实时分发
+type KafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+// NewKafkaSink 创建 Kafka 导出，topic 为空使用 DefaultTopic
+// producer 由调用方创建，Close 时一并关闭
+func NewKafkaSink(producer *kafka.Producer, topic string) *KafkaSink {
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	return &KafkaSink{producer: producer, topic: topic}
+}
+
+// Write 实现 Sink
+func (s *KafkaSink) Write(rec *Record, raw []byte) error {
+	return s.producer.SendRaw(s.topic, rec.Key, raw)
+}
+
+// Flush 实现 Sink (Producer 按自身配置批量发送)
+func (s *KafkaSink) Flush() error { return nil }
+
+// Close 实现 Sink
+func (s *KafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,94 @@
+package eventlog
+
+// =============================================================================
+// 事件类型与 Data 结构 (对外契约，字段只增不改；说明见 schema.md)
+// =============================================================================
+//
+// 金额、价格、数量都是定点整数 (× 1e8)，与库内存储一致，下游自行换算
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeOrderAccepted Type = "ORDER_ACCEPTED" // 订单进入撮合 (可能已部分/全部成交)
+	TypeOrderRejected Type = "ORDER_REJECTED" // 订单被撮合拒绝
+	TypeOrderCanceled Type = "ORDER_CANCELED" // 挂单被撤销
+	TypeTrade         Type = "TRADE"          // 成交
+	TypeBalance       Type = "BALANCE_CHANGE" // 余额流水
+	TypeLiquidation   Type = "LIQUIDATION"    // 强平成交
+	TypeFunding       Type = "FUNDING"        // 资金费落账
+)
+
+// OrderData ORDER_ACCEPTED / ORDER_REJECTED / ORDER_CANCELED
+type OrderData struct {
+	OrderID      int64  `json:"order_id"`
+	UserID       int64  `json:"user_id"`
+	Symbol       string `json:"symbol"`
+	Side         string `json:"side"`       // BUY / SELL
+	OrderType    string `json:"order_type"` // LIMIT / MARKET / IOC / FOK / POST_ONLY
+	Status       string `json:"status"`     // NEW / PARTIALLY_FILLED / FILLED / CANCELED / REJECTED
+	Price        int64  `json:"price"`
+	Qty          int64  `json:"qty"`
+	FilledQty    int64  `json:"filled_qty"`
+	RejectReason string `json:"reject_reason,omitempty"`
+	BookSeq      uint64 `json:"book_seq"` // 事件发生后该交易对订单簿的序列号
+}
+
+// TradeData TRADE
+//
+// 只带订单 ID，用户通过同一交易对的订单事件关联 (maker 的 ORDER_ACCEPTED 一定在前)
+type TradeData struct {
+	TradeID      int64  `json:"trade_id"`
+	Symbol       string `json:"symbol"`
+	Price        int64  `json:"price"`
+	Qty          int64  `json:"qty"`
+	TakerOrderID int64  `json:"taker_order_id"`
+	MakerOrderID int64  `json:"maker_order_id"`
+	TakerSide    string `json:"taker_side"`
+	BookSeq      uint64 `json:"book_seq"`
+}
+
+// BalanceData BALANCE_CHANGE (与 fund.JournalEvent 一一对应)
+type BalanceData struct {
+	EventID         string `json:"event_id"` // 幂等键
+	UserID          int64  `json:"user_id"`
+	Asset           string `json:"asset"`
+	ChangeType      string `json:"change_type"` // RESERVE / RELEASE / TRANSFER / DEPOSIT / WITHDRAW / FEE / REBATE / DUST / COMMISSION / FUNDING / SETTLEMENT
+	Amount          int64  `json:"amount"`      // 正数，方向由 ChangeType 决定
+	AvailableBefore int64  `json:"available_before"`
+	AvailableAfter  int64  `json:"available_after"`
+	LockedBefore    int64  `json:"locked_before"`
+	LockedAfter     int64  `json:"locked_after"`
+	BizType         string `json:"biz_type"`
+	BizID           string `json:"biz_id"`
+}
+
+// LiquidationData LIQUIDATION
+type LiquidationData struct {
+	UserID        int64  `json:"user_id"`
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"order_id"`
+	TradeID       int64  `json:"trade_id"`
+	Price         int64  `json:"price"`
+	Qty           int64  `json:"qty"`
+	PositionSize  int64  `json:"position_size"` // 强平前持仓，正=多，负=空
+	BankruptPrice int64  `json:"bankrupt_price"`
+	PnL           int64  `json:"pnl"`
+	Fee           int64  `json:"fee,omitempty"` // 强平手续费，归保险基金
+	Surplus       int64  `json:"surplus"`       // 注入保险基金 (扣除手续费后)
+	Shortfall     int64  `json:"shortfall"`     // 穿仓，由保险基金承担
+	Currency      string `json:"currency"`
+}
+
+// FundingData FUNDING (每个持仓一条)
+type FundingData struct {
+	UserID       int64  `json:"user_id"`
+	Symbol       string `json:"symbol"`
+	FundingTime  int64  `json:"funding_time"` // 资金费时间点 (毫秒)
+	FundingRate  int64  `json:"funding_rate"` // 万分比
+	MarkPrice    int64  `json:"mark_price"`
+	PositionSize int64  `json:"position_size"`
+	Payment      int64  `json:"payment"` // 按公式计算，正=收入，负=支出
+	Applied      int64  `json:"applied"` // 实际落账 (余额不足时支出被截断)
+	Error        string `json:"error,omitempty"`
+}
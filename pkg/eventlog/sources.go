@@ -0,0 +1,170 @@
+package eventlog
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 事件来源适配
+// =============================================================================
+//
+// 接线示例：
+//
+//	sink, _ := eventlog.NewFileSink(eventlog.FileSinkConfig{Dir: "/data/events"})
+//	events := eventlog.New(eventlog.Config{Sinks: []eventlog.Sink{sink}, StartSeq: sink.LastSeq()})
+//	engine.OnEventWithOptions(events.MatchHandler(), mtrade.HandlerOptions{Name: "eventlog", Policy: mtrade.DeliveryBlocking})
+//	spot.NewSpotProcessor(spot.ProcessorConfig{Publisher: eventlog.TeeJournals(kafkaPublisher, events), ...})
+//	liquidationExecutor.OnLiquidated(events.LiquidationHandler())
+//	fundingService.OnSettled(events.FundingHandler())
+
+// MatchHandler 撮合事件 → 订单 / 成交事件 (订单簿增量不导出)
+func (l *Log) MatchHandler() mtrade.EventHandler {
+	return func(ev mtrade.Event) {
+		at := time.Unix(0, ev.Timestamp)
+		var err error
+		switch ev.Type {
+		case mtrade.EventOrderAccepted, mtrade.EventOrderRejected, mtrade.EventOrderCanceled:
+			o := ev.Order
+			data := OrderData{
+				OrderID:   o.ID,
+				UserID:    o.UserID,
+				Symbol:    o.Symbol,
+				Side:      o.Side.String(),
+				OrderType: o.Type.String(),
+				Status:    o.Status.String(),
+				Price:     o.Price,
+				Qty:       o.Qty,
+				FilledQty: o.FilledQty,
+				BookSeq:   ev.Seq,
+			}
+			typ := TypeOrderAccepted
+			switch ev.Type {
+			case mtrade.EventOrderRejected:
+				typ = TypeOrderRejected
+				if ev.Result != nil {
+					data.RejectReason = ev.Result.RejectReason.String()
+				}
+			case mtrade.EventOrderCanceled:
+				typ = TypeOrderCanceled
+			}
+			err = l.Append(typ, at, o.Symbol, data)
+
+		case mtrade.EventTrade:
+			t := ev.Trade
+			err = l.Append(TypeTrade, at, t.Symbol, TradeData{
+				TradeID:      t.ID,
+				Symbol:       t.Symbol,
+				Price:        t.Price,
+				Qty:          t.Qty,
+				TakerOrderID: t.TakerID,
+				MakerOrderID: t.MakerID,
+				TakerSide:    t.TakerSide.String(),
+				BookSeq:      t.BookSeq,
+			})
+		}
+		if err != nil {
+			log.Printf("[EventLog] drop match event type=%d: %v", ev.Type, err)
+		}
+	}
+}
+
+// PublishJournal 资金流水 → BALANCE_CHANGE (实现 spot.JournalPublisher / referral.JournalPublisher)
+func (l *Log) PublishJournal(e *fund.JournalEvent) error {
+	return l.Append(TypeBalance, e.CreatedAt, strconv.FormatInt(e.UserID, 10), BalanceData{
+		EventID:         e.EventID,
+		UserID:          e.UserID,
+		Asset:           e.Symbol,
+		ChangeType:      e.ChangeType.String(),
+		Amount:          e.Amount,
+		AvailableBefore: e.AvailableBefore,
+		AvailableAfter:  e.AvailableAfter,
+		LockedBefore:    e.LockedBefore,
+		LockedAfter:     e.LockedAfter,
+		BizType:         string(e.BizType),
+		BizID:           e.BizID,
+	})
+}
+
+// LiquidationHandler 强平成交 → LIQUIDATION
+func (l *Log) LiquidationHandler() func(futures.LiquidationFill) {
+	return func(f futures.LiquidationFill) {
+		err := l.Append(TypeLiquidation, time.UnixMilli(f.At), strconv.FormatInt(f.UserID, 10), LiquidationData{
+			UserID:        f.UserID,
+			Symbol:        f.Symbol,
+			OrderID:       f.OrderID,
+			TradeID:       f.TradeID,
+			Price:         f.Price,
+			Qty:           f.Qty,
+			PositionSize:  f.PositionSize,
+			BankruptPrice: f.BankruptPrice,
+			PnL:           f.PnL,
+			Fee:           f.Fee,
+			Surplus:       f.Surplus,
+			Shortfall:     f.Shortfall,
+			Currency:      f.SettleCurrency,
+		})
+		if err != nil {
+			log.Printf("[EventLog] drop liquidation user=%d symbol=%s: %v", f.UserID, f.Symbol, err)
+		}
+	}
+}
+
+// FundingHandler 资金费结算 → 每个持仓一条 FUNDING
+func (l *Log) FundingHandler() func(*futures.FundingReport) {
+	return func(r *futures.FundingReport) {
+		at := time.UnixMilli(r.FundingTime)
+		if r.FundingTime == 0 {
+			at = time.Now()
+		}
+		for _, e := range r.Entries {
+			data := FundingData{
+				UserID:       e.UserID,
+				Symbol:       r.Symbol,
+				FundingTime:  r.FundingTime,
+				FundingRate:  r.FundingRate,
+				MarkPrice:    r.MarkPrice,
+				PositionSize: e.PositionSize,
+				Payment:      e.Payment,
+				Applied:      e.Applied,
+			}
+			if e.Err != nil {
+				data.Error = e.Err.Error()
+			}
+			if err := l.Append(TypeFunding, at, strconv.FormatInt(e.UserID, 10), data); err != nil {
+				log.Printf("[EventLog] drop funding user=%d symbol=%s: %v", e.UserID, r.Symbol, err)
+			}
+		}
+	}
+}
+
+// =============================================================================
+// 流水分发
+// =============================================================================
+
+// JournalPublisher 流水发布 (fund.EventPublisher / Log)
+type JournalPublisher interface {
+	PublishJournal(event *fund.JournalEvent) error
+}
+
+// TeeJournals 同一条流水发给多个发布器 (如冷库 Kafka + 事件导出)，返回第一个错误
+func TeeJournals(publishers ...JournalPublisher) JournalPublisher {
+	return journalTee(publishers)
+}
+
+type journalTee []JournalPublisher
+
+func (t journalTee) PublishJournal(event *fund.JournalEvent) error {
+	var first error
+	for _, p := range t {
+		if err := p.PublishJournal(event); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,153 @@
+package featureflag
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	testNewFeature = Define("test.new_feature", false)
+	testShipped    = Define("test.shipped", true)
+)
+
+func TestFlags_Defaults(t *testing.T) {
+	var nilFlags *Flags
+	if nilFlags.Enabled(testNewFeature, "BTCUSDT", 1) || !nilFlags.Enabled(testShipped, "BTCUSDT", 1) {
+		t.Fatal("nil flags must use defaults")
+	}
+	f := New(nil)
+	if f.Enabled(testNewFeature, "", 1) || !f.Enabled(testShipped, "", 1) {
+		t.Fatal("unconfigured flags must use defaults")
+	}
+	if err := f.Check(testNewFeature, "", 1); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+}
+
+func TestFlags_Rules(t *testing.T) {
+	cfg, err := Parse([]byte(`
+flags:
+  test.new_feature:
+    enabled: true
+    symbols: [BTCUSDT]
+    percent: 20
+    users: [42]
+  test.shipped:
+    enabled: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New(cfg)
+
+	if f.Enabled(testShipped, "BTCUSDT", 1) {
+		t.Error("enabled=false must turn off a default-on flag")
+	}
+	if f.Enabled(testNewFeature, "ETHUSDT", 42) {
+		t.Error("symbol outside the list must be off even for allowlisted users")
+	}
+	if !f.Enabled(testNewFeature, "BTCUSDT", 42) {
+		t.Error("allowlisted user must bypass percent")
+	}
+
+	on := 0
+	for uid := int64(1); uid <= 10000; uid++ {
+		if f.Enabled(testNewFeature, "BTCUSDT", uid) {
+			on++
+		}
+		if f.Enabled(testNewFeature, "BTCUSDT", uid) != f.Enabled(testNewFeature, "", uid) {
+			t.Fatal("bucket must be stable per user")
+		}
+	}
+	if on < 1800 || on > 2200 {
+		t.Errorf("expected ~20%% of users, got %d/10000", on)
+	}
+}
+
+func TestFlags_KillSurvivesReload(t *testing.T) {
+	f := New(nil)
+	f.Kill(testShipped.Name())
+	if f.Enabled(testShipped, "", 1) {
+		t.Fatal("killed flag must be off")
+	}
+	f.Replace(&Config{Flags: map[string]Rule{testShipped.Name(): {Enabled: true}}})
+	if f.Enabled(testShipped, "", 1) {
+		t.Fatal("reload must not revive a killed flag")
+	}
+	f.Revive(testShipped.Name())
+	if !f.Enabled(testShipped, "", 1) {
+		t.Fatal("revived flag must follow config")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	if _, err := Parse([]byte("flags:\n  test.shipped:\n    enable: true\n")); err == nil {
+		t.Error("unknown field must be rejected")
+	}
+	if _, err := Parse([]byte("flags:\n  test.shipped:\n    enabled: true\n    percent: 101\n")); err == nil {
+		t.Error("percent > 100 must be rejected")
+	}
+	if cfg, err := Parse(nil); err != nil || len(cfg.Flags) != 0 {
+		t.Errorf("empty config: %v %v", cfg, err)
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.yaml")
+	os.WriteFile(path, []byte("flags:\n  test.new_feature:\n    enabled: true\n"), 0o644)
+
+	f := New(nil)
+	w := NewWatcher(f, path, time.Hour)
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if !f.Enabled(testNewFeature, "", 1) {
+		t.Fatal("initial load not applied")
+	}
+
+	// 写坏的配置不生效
+	os.WriteFile(path, []byte("flags: [oops"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	if _, err := w.Reload(); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if !f.Enabled(testNewFeature, "", 1) {
+		t.Fatal("bad config must keep the previous one")
+	}
+
+	os.WriteFile(path, []byte("flags:\n  test.new_feature:\n    enabled: false\n"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second))
+	if changed, err := w.Reload(); err != nil || !changed {
+		t.Fatalf("reload: %v %v", changed, err)
+	}
+	if f.Enabled(testNewFeature, "", 1) {
+		t.Fatal("new config not applied")
+	}
+}
+
+func TestHandler_Kill(t *testing.T) {
+	f := New(nil)
+	h := NewHandler(f)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flags/kill?name=test.shipped", nil))
+	if rec.Code != http.StatusNoContent || f.Enabled(testShipped, "", 1) {
+		t.Fatalf("kill via http failed: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flags/kill?name=nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown flag: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/flags", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: %d", rec.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package featureflag
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// =============================================================================
+// 运维接口 (挂在内网管理端口，鉴权由网关负责)
+// =============================================================================
+
+// flagStatus 一个开关的当前状态
+type flagStatus struct {
+	Name       string `json:"name"`
+	Default    bool   `json:"default"`
+	Configured *Rule  `json:"configured,omitempty"`
+	Killed     bool   `json:"killed"`
+}
+
+// NewHandler 创建运维接口
+//
+//	GET  /flags                 全部已声明开关的默认值、配置与紧急关闭状态
+//	POST /flags/kill?name=xxx   紧急关闭
+//	POST /flags/revive?name=xxx 解除紧急关闭
+func NewHandler(f *Flags) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /flags", func(w http.ResponseWriter, r *http.Request) {
+		cfg := f.Config()
+		killed := *f.killed.Load()
+		var out []flagStatus
+		for _, flag := range Registered() {
+			st := flagStatus{Name: flag.name, Default: flag.def, Killed: killed[flag.name]}
+			if rule, ok := cfg.Flags[flag.name]; ok {
+				st.Configured = &rule
+			}
+			out = append(out, st)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("POST /flags/kill", func(w http.ResponseWriter, r *http.Request) {
+		if name := r.URL.Query().Get("name"); isRegistered(name) {
+			f.Kill(name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "unknown flag", http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /flags/revive", func(w http.ResponseWriter, r *http.Request) {
+		if name := r.URL.Query().Get("name"); isRegistered(name) {
+			f.Revive(name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "unknown flag", http.StatusNotFound)
+	})
+	return mux
+}
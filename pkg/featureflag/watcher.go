@@ -0,0 +1,135 @@
+package featureflag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultReloadInterval 配置文件检查间隔
+const DefaultReloadInterval = 5 * time.Second
+
+// Parse 解析 YAML 配置 (未知字段报错，防止拼错字段名导致规则被静默忽略)
+func Parse(data []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var cfg Config // 空文件 (io.EOF) 等同于没有配置
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("featureflag: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Load 从文件加载
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// =============================================================================
+// Watcher - 配置文件热加载
+// =============================================================================
+
+// Watcher 定时检查配置文件，修改时间或大小变化时重新加载
+//
+// 【注意】用轮询而不是 inotify：配置挂在 ConfigMap / 网络盘上时 inotify 不可靠，
+// 几秒的延迟对开关来说足够；要立即生效用 Flags.Kill
+// 解析失败保留旧配置并打日志，不会因为一次手滑把所有开关清掉
+type Watcher struct {
+	flags    *Flags
+	path     string
+	interval time.Duration
+
+	modTime time.Time
+	size    int64
+
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewWatcher 创建热加载器，interval <= 0 使用 DefaultReloadInterval
+func NewWatcher(flags *Flags, path string, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	return &Watcher{flags: flags, path: path, interval: interval, stopCh: make(chan struct{})}
+}
+
+// Start 先同步加载一次 (失败直接返回)，再启动定时检查
+func (w *Watcher) Start() error {
+	if w.running {
+		return errors.New("featureflag watcher already running")
+	}
+	if _, err := w.Reload(); err != nil {
+		return err
+	}
+	w.running = true
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				if changed, err := w.Reload(); err != nil {
+					log.Printf("[FeatureFlag] reload %s failed, keeping previous config: %v", w.path, err)
+				} else if changed {
+					log.Printf("[FeatureFlag] reloaded %s", w.path)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止检查
+func (w *Watcher) Stop() {
+	if !w.running {
+		return
+	}
+	close(w.stopCh)
+	w.wg.Wait()
+	w.running = false
+}
+
+// Reload 文件有变化时重新加载，返回是否替换了配置
+func (w *Watcher) Reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+	cfg, err := Load(w.path)
+	if err != nil {
+		return false, err
+	}
+	if err := w.flags.Replace(cfg); err != nil {
+		return false, err
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	return true, nil
+}
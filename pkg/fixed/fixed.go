@@ -0,0 +1,217 @@
+// Package fixed 确定性整数定点运算 - 金额、价格、费率计算的统一入口
+//
+// 【问题】各模块各写各的：有的先除后乘 ((price / Precision) * qty，价格的小数部分直接丢掉)，
+// 有的中途除精度，有的直接相乘 (BTC 量级的价格 × 数量超过 int64 静默溢出)，
+// 费率精度常量也各定义一份。同一笔成交在现货处理器和资产引擎里算出的金额可能不一样
+//
+// 【做法】
+//   - 所有 a × b / d 都走 MulDiv：128 位中间结果 (math/bits)，不会中途溢出
+//   - 取整方式显式指定 (Rounding)，默认向零截断，与 Go 整数除法一致
+//   - 结果超出 int64 时饱和到 MaxInt64 / MinInt64，不回绕 (回绕会把大额变成负数)
+//   - 分母为 0 返回 0：调用方常见的"数量为 0 按比例分配"不必各自判空
+//
+// 【精度约定】
+//
+//	Scale     1e8    价格、数量、金额 (asset.Precision / futures.Precision)
+//	BpsScale  1e4    费率、保证金率、资金费率、滑点 (万分比)
+//
+// 只用整数运算，不经过浮点：同样的输入在任何机器上结果逐位相同 (对账、重放依赖这一点)
+package fixed
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	// Scale 价格 / 数量 / 金额的精度因子：1.5 = 150_000_000
+	Scale = 100_000_000
+
+	// BpsScale 万分比精度：1 = 0.01%
+	BpsScale = 10_000
+
+	// PercentScale 百分比精度：1 = 1%
+	PercentScale = 100
+)
+
+// Rounding 取整方式
+type Rounding int8
+
+const (
+	RoundDown     Rounding = iota // 向零截断 (Go 整数除法)
+	RoundUp                       // 远离零：有余数就进一位
+	RoundFloor                    // 向负无穷
+	RoundCeil                     // 向正无穷
+	RoundHalfUp                   // 四舍五入，0.5 远离零
+	RoundHalfEven                 // 银行家舍入，0.5 取偶
+)
+
+func (r Rounding) String() string {
+	switch r {
+	case RoundDown:
+		return "DOWN"
+	case RoundUp:
+		return "UP"
+	case RoundFloor:
+		return "FLOOR"
+	case RoundCeil:
+		return "CEIL"
+	case RoundHalfUp:
+		return "HALF_UP"
+	case RoundHalfEven:
+		return "HALF_EVEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// =============================================================================
+// 核心运算
+// =============================================================================
+
+// MulDiv a × b / d，向零截断
+func MulDiv(a, b, d int64) int64 {
+	return MulDivRound(a, b, d, RoundDown)
+}
+
+// MulDivRound a × b / d，按 mode 取整
+//
+// 中间结果 128 位，只有最终结果超出 int64 时饱和；d 为 0 返回 0
+func MulDivRound(a, b, d int64, mode Rounding) int64 {
+	if d == 0 || a == 0 || b == 0 {
+		return 0
+	}
+	neg := (a < 0) != (b < 0) != (d < 0)
+	ua, ub, ud := absU64(a), absU64(b), absU64(d)
+
+	hi, lo := bits.Mul64(ua, ub)
+	if hi >= ud {
+		return saturate(neg) // 商超过 64 位
+	}
+	q, r := bits.Div64(hi, lo, ud)
+	if r != 0 && roundAway(mode, neg, q, r, ud) {
+		if q == math.MaxUint64 {
+			return saturate(neg)
+		}
+		q++
+	}
+
+	if neg {
+		if q > 1<<63 {
+			return math.MinInt64
+		}
+		return -int64(q) // q == 1<<63 时 int64(q) 就是 MinInt64，取负不变
+	}
+	if q > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(q)
+}
+
+// Div a / d 按 mode 取整 (d 为 0 返回 0)
+func Div(a, d int64, mode Rounding) int64 {
+	return MulDivRound(a, 1, d, mode)
+}
+
+// roundAway 截断后的商 q (绝对值) 是否需要再进一位，r 为余数 (非 0)
+func roundAway(mode Rounding, neg bool, q, r, d uint64) bool {
+	switch mode {
+	case RoundUp:
+		return true
+	case RoundFloor:
+		return neg
+	case RoundCeil:
+		return !neg
+	case RoundHalfUp, RoundHalfEven:
+		// d <= 2^63，r < d，2r 不会溢出
+		switch twice := r << 1; {
+		case twice > d:
+			return true
+		case twice < d:
+			return false
+		default:
+			return mode == RoundHalfUp || q&1 == 1
+		}
+	default:
+		return false
+	}
+}
+
+// absU64 |v|，MinInt64 也能正确表示 (2^63)
+func absU64(v int64) uint64 {
+	if v < 0 {
+		return -uint64(v)
+	}
+	return uint64(v)
+}
+
+func saturate(neg bool) int64 {
+	if neg {
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
+
+// =============================================================================
+// 定点数 (Scale = 1e8)
+// =============================================================================
+
+// Mul 两个定点数相乘：价格 × 数量 = 金额，向零截断
+func Mul(a, b int64) int64 {
+	return MulDiv(a, b, Scale)
+}
+
+// MulRound 两个定点数相乘，按 mode 取整
+func MulRound(a, b int64, mode Rounding) int64 {
+	return MulDivRound(a, b, Scale, mode)
+}
+
+// Quo 两个定点数相除：金额 / 数量 = 均价，向零截断 (b 为 0 返回 0)
+func Quo(a, b int64) int64 {
+	return MulDiv(a, Scale, b)
+}
+
+// =============================================================================
+// 万分比 / 百分比
+// =============================================================================
+
+// Bps v × bps / 10000：手续费、维持保证金、资金费，向零截断
+func Bps(v, bps int64) int64 {
+	return MulDiv(v, bps, BpsScale)
+}
+
+// BpsRound v × bps / 10000，按 mode 取整
+func BpsRound(v, bps int64, mode Rounding) int64 {
+	return MulDivRound(v, bps, BpsScale, mode)
+}
+
+// ApplyBps v × (1 + bps / 10000)：上浮 / 下浮 (滑点保护价、回调触发价、折价)
+func ApplyBps(v, bps int64, mode Rounding) int64 {
+	return MulDivRound(v, BpsScale+bps, BpsScale, mode)
+}
+
+// RatioBps num / den 折成万分比 (溢价率、成交率)，向零截断
+func RatioBps(num, den int64) int64 {
+	return MulDiv(num, BpsScale, den)
+}
+
+// Percent v × pct / 100，向零截断
+func Percent(v, pct int64) int64 {
+	return MulDiv(v, pct, PercentScale)
+}
+
+// =============================================================================
+// 步长对齐
+// =============================================================================
+
+// Quantize 把 v 对齐到 step 的整数倍 (价格对齐 TickSize、数量对齐 LotSize)，step <= 0 原样返回
+func Quantize(v, step int64, mode Rounding) int64 {
+	if step <= 0 {
+		return v
+	}
+	n := Div(v, step, mode)
+	if hi, lo := bits.Mul64(absU64(n), uint64(step)); hi != 0 || lo > math.MaxInt64 {
+		return saturate(n < 0)
+	}
+	return n * step
+}
@@ -0,0 +1,204 @@
+package fixed
+
+import (
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"testing"
+)
+
+var allModes = []Rounding{RoundDown, RoundUp, RoundFloor, RoundCeil, RoundHalfUp, RoundHalfEven}
+
+func TestMulDivRound_Modes(t *testing.T) {
+	// 每行: a × b / d 在六种取整方式下的结果 (DOWN, UP, FLOOR, CEIL, HALF_UP, HALF_EVEN)
+	tests := []struct {
+		a, b, d int64
+		want    [6]int64
+	}{
+		{7, 1, 2, [6]int64{3, 4, 3, 4, 4, 4}},        // 3.5
+		{5, 1, 2, [6]int64{2, 3, 2, 3, 3, 2}},        // 2.5 取偶
+		{-7, 1, 2, [6]int64{-3, -4, -4, -3, -4, -4}}, // -3.5
+		{-5, 1, 2, [6]int64{-2, -3, -3, -2, -3, -2}}, // -2.5 取偶
+		{5, -1, 2, [6]int64{-2, -3, -3, -2, -3, -2}}, // 符号在 b 上
+		{5, 1, -2, [6]int64{-2, -3, -3, -2, -3, -2}}, // 符号在 d 上
+		{-5, -1, -2, [6]int64{-2, -3, -3, -2, -3, -2}},
+		{10, 1, 3, [6]int64{3, 4, 3, 4, 3, 3}}, // 3.333
+		{20, 1, 3, [6]int64{6, 7, 6, 7, 7, 7}}, // 6.667
+		{-20, 1, 3, [6]int64{-6, -7, -7, -6, -7, -7}},
+		{6, 1, 3, [6]int64{2, 2, 2, 2, 2, 2}}, // 整除不取整
+		{0, 5, 3, [6]int64{0, 0, 0, 0, 0, 0}},
+		{5, 5, 0, [6]int64{0, 0, 0, 0, 0, 0}}, // 分母为 0
+	}
+	for _, tt := range tests {
+		for i, mode := range allModes {
+			if got := MulDivRound(tt.a, tt.b, tt.d, mode); got != tt.want[i] {
+				t.Errorf("MulDivRound(%d, %d, %d, %s) = %d, want %d", tt.a, tt.b, tt.d, mode, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestMulDiv_Overflow(t *testing.T) {
+	const maxI, minI = math.MaxInt64, math.MinInt64
+	tests := []struct {
+		name    string
+		a, b, d int64
+		want    int64
+	}{
+		// 价格 10 万 × 数量 10 万 (精度 1e8)：乘积 1e26 远超 int64，结果 1e18 在范围内
+		{"price x qty", 100_000 * Scale, 100_000 * Scale, Scale, 10_000_000_000 * Scale},
+		{"max x max / max", maxI, maxI, maxI, maxI},
+		{"min x 1 / 1", minI, 1, 1, minI},
+		{"min x -1 / 1", minI, -1, 1, maxI}, // 2^63 饱和
+		{"min x 1 / -1", minI, 1, -1, maxI},
+		{"min x min / min", minI, minI, minI, minI},
+		{"max x max / 1", maxI, maxI, 1, maxI},
+		{"max x -max / 1", maxI, -maxI, 1, minI},
+		{"max x 2 / 2", maxI, 2, 2, maxI},
+		{"min x 2 / 2", minI, 2, 2, minI},
+		{"max x 3 / 2", maxI, 3, 2, maxI},
+	}
+	for _, tt := range tests {
+		if got := MulDiv(tt.a, tt.b, tt.d); got != tt.want {
+			t.Errorf("%s: MulDiv(%d, %d, %d) = %d, want %d", tt.name, tt.a, tt.b, tt.d, got, tt.want)
+		}
+	}
+
+	// 进位后超出 int64 同样饱和
+	if got := MulDivRound(maxI, 3, 2, RoundUp); got != maxI {
+		t.Errorf("saturated quotient rounded up = %d", got)
+	}
+	if got := MulDivRound(minI, 3, 2, RoundFloor); got != minI {
+		t.Errorf("saturated negative quotient rounded down = %d", got)
+	}
+	// 商正好是 2^63：负数能表示，正数饱和
+	if got := MulDivRound(minI, 3, 3, RoundUp); got != minI {
+		t.Errorf("exact min = %d", got)
+	}
+	if got := MulDivRound(maxI, 2, 2, RoundCeil); got != maxI {
+		t.Errorf("exact max = %d", got)
+	}
+}
+
+// TestMulDivRound_Big 随机输入与 math/big 逐位对比 (固定种子，结果可复现)
+func TestMulDivRound_Big(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	edges := []int64{0, 1, -1, 2, -2, 3, Scale, -Scale, BpsScale, math.MaxInt64, math.MinInt64, math.MaxInt64 - 1, math.MinInt64 + 1, 1 << 32, -(1 << 32)}
+	pick := func() int64 {
+		switch rng.IntN(4) {
+		case 0:
+			return edges[rng.IntN(len(edges))]
+		case 1:
+			return rng.Int64N(1_000_000) - 500_000
+		case 2:
+			return int64(rng.Uint64())
+		default:
+			return (rng.Int64N(1<<40) - 1<<39) * Scale / 1000
+		}
+	}
+	for range 200_000 {
+		a, b, d := pick(), pick(), pick()
+		mode := allModes[rng.IntN(len(allModes))]
+		if got, want := MulDivRound(a, b, d, mode), bigMulDiv(a, b, d, mode); got != want {
+			t.Fatalf("MulDivRound(%d, %d, %d, %s) = %d, want %d", a, b, d, mode, got, want)
+		}
+	}
+}
+
+// bigMulDiv 参照实现
+func bigMulDiv(a, b, d int64, mode Rounding) int64 {
+	if d == 0 {
+		return 0
+	}
+	num := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	den := big.NewInt(d)
+	if den.Sign() < 0 {
+		num.Neg(num)
+		den.Neg(den)
+	}
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int)) // 向零截断
+	if r.Sign() != 0 {
+		neg := num.Sign() < 0
+		twice := new(big.Int).Abs(r)
+		twice.Lsh(twice, 1)
+		cmp := twice.Cmp(den)
+		var away bool
+		switch mode {
+		case RoundUp:
+			away = true
+		case RoundFloor:
+			away = neg
+		case RoundCeil:
+			away = !neg
+		case RoundHalfUp:
+			away = cmp >= 0
+		case RoundHalfEven:
+			away = cmp > 0 || (cmp == 0 && q.Bit(0) == 1)
+		}
+		if away {
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		}
+	}
+	switch {
+	case q.IsInt64():
+		return q.Int64()
+	case q.Sign() < 0:
+		return math.MinInt64
+	default:
+		return math.MaxInt64
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	const btc = 50_000 * Scale // 50000 USDT
+	tests := []struct {
+		name      string
+		got, want int64
+	}{
+		{"Mul price x qty", Mul(btc, 15*Scale/10), 75_000 * Scale},
+		{"Mul fractional price", Mul(12_345_678, 3*Scale), 37_037_034}, // 0.12345678 × 3
+		{"MulRound ceil", MulRound(1, 1, RoundCeil), 1},
+		{"Quo avg price", Quo(75_000*Scale, 15*Scale/10), btc},
+		{"Quo zero qty", Quo(75_000*Scale, 0), 0},
+		{"Bps fee 0.2%", Bps(75_000*Scale, 20), 150 * Scale},
+		{"Bps rebate -0.02%", Bps(75_000*Scale, -2), -15 * Scale},
+		{"Bps truncates", Bps(9_999, 1), 0},
+		{"BpsRound ceil", BpsRound(9_999, 1, RoundCeil), 1},
+		{"ApplyBps +1%", ApplyBps(btc, 100, RoundDown), 50_500 * Scale},
+		{"ApplyBps -1%", ApplyBps(btc, -100, RoundDown), 49_500 * Scale},
+		{"ApplyBps odd up", ApplyBps(101, -50, RoundCeil), 101},
+		{"ApplyBps odd down", ApplyBps(101, -50, RoundFloor), 100},
+		{"RatioBps premium", RatioBps(btc+25*Scale, btc), 10_005},
+		{"RatioBps negative", RatioBps(-25*Scale, btc), -5},
+		{"Percent", Percent(btc, 10), 5_000 * Scale},
+		{"Div floor", Div(-7, 2, RoundFloor), -4},
+		{"Quantize down", Quantize(123_456, 100, RoundDown), 123_400},
+		{"Quantize up", Quantize(123_456, 100, RoundUp), 123_500},
+		{"Quantize exact", Quantize(123_400, 100, RoundUp), 123_400},
+		{"Quantize negative floor", Quantize(-123_456, 100, RoundFloor), -123_500},
+		{"Quantize no step", Quantize(123_456, 0, RoundUp), 123_456},
+		{"Quantize saturate", Quantize(math.MaxInt64, 1000, RoundUp), math.MaxInt64},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRoundingString(t *testing.T) {
+	for _, mode := range allModes {
+		if mode.String() == "UNKNOWN" {
+			t.Errorf("mode %d has no name", mode)
+		}
+	}
+	if Rounding(99).String() != "UNKNOWN" {
+		t.Error("unknown mode")
+	}
+}
+
+func BenchmarkMulDiv(b *testing.B) {
+	for i := range b.N {
+		MulDiv(int64(i)*Scale, 50_000*Scale, Scale)
+	}
+}
@@ -0,0 +1,132 @@
+// 文件: pkg/fund/apply_once.go
+// 冷资产模块 - 幂等落账
+//
+// 批量结算类业务 (资金费、交割) 都是"对一批用户各入账/出账一次"，
+// 中途失败重跑时必须跳过已经落账的用户。做法统一为：
+//
+//	事务 { 行锁读余额 → INSERT IGNORE 流水(幂等键) → 没插进去就返回 → 改余额 → 写业务明细 }
+//
+// 流水表 event_id 唯一索引是唯一的去重依据，业务层不需要再维护"已结算用户"表。
+
+package fund
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+)
+
+// ErrInsufficientAvailable 出账金额超过可用余额 (未设置 ClipToAvailable 时)
+var ErrInsufficientAvailable = cexerr.New("FUND_INSUFFICIENT_AVAILABLE", cexerr.CategoryInsufficientFunds, "insufficient available balance")
+
+// IdempotentChange 一笔带幂等键的可用余额变动
+type IdempotentChange struct {
+	UserID   int64
+	Currency string
+	Amount   int64 // 正数入账，负数出账
+
+	// ClipToAvailable 出账按可用余额截断 (最多扣到 0)，否则余额不足返回 ErrInsufficientAvailable
+	ClipToAvailable bool
+
+	EventID    string // 幂等键
+	ChangeType ChangeType
+	BizType    BizType
+	BizID      string
+
+	// Record 可选，与流水同一事务写入的业务记录 (gorm 模型指针)，重复请求时不写
+	Record any
+}
+
+// ApplyOnce 幂等落账
+//
+// 返回实际落账金额；幂等键已存在时 duplicate = true，applied 为当时的落账金额
+func (r *BalanceRepo) ApplyOnce(ctx context.Context, c IdempotentChange) (applied int64, duplicate bool, err error) {
+	now := time.Now()
+	err = r.Transaction(ctx, func(tx *BalanceRepo) error {
+		var record BalanceRecord
+		err := tx.balanceTable(c.UserID).
+			WithContext(ctx).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND symbol = ?", c.UserID, c.Currency).
+			First(&record).Error
+		exists := err == nil
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		applied = c.Amount
+		if c.Amount < 0 && record.Available < -c.Amount {
+			if !c.ClipToAvailable {
+				return fmt.Errorf("%w: user %d %s available %d, need %d",
+					ErrInsufficientAvailable, c.UserID, c.Currency, record.Available, -c.Amount)
+			}
+			applied = -max(record.Available, 0)
+		}
+		after := record.Available + applied
+
+		result := tx.journalTable(c.UserID).
+			WithContext(ctx).
+			Clauses(clause.Insert{Modifier: "IGNORE"}).
+			Create(&JournalRecord{
+				EventID:         c.EventID,
+				UserID:          c.UserID,
+				Symbol:          c.Currency,
+				ChangeType:      c.ChangeType,
+				Amount:          max(applied, -applied),
+				AvailableBefore: record.Available,
+				AvailableAfter:  after,
+				LockedBefore:    record.Locked,
+				LockedAfter:     record.Locked,
+				BizType:         c.BizType,
+				BizID:           c.BizID,
+				CreatedAt:       now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 已落过账：返回当时的落账金额，重跑后的报告与一次跑完一致
+			var prev JournalRecord
+			err := tx.journalTable(c.UserID).
+				WithContext(ctx).
+				Where("event_id = ?", c.EventID).
+				First(&prev).Error
+			if err != nil {
+				return err
+			}
+			applied, duplicate = prev.AvailableAfter-prev.AvailableBefore, true
+			return nil
+		}
+
+		if c.Record != nil {
+			if err := tx.db.WithContext(ctx).Create(c.Record).Error; err != nil {
+				return err
+			}
+		}
+		if applied == 0 {
+			return nil
+		}
+		if !exists {
+			return tx.balanceTable(c.UserID).
+				WithContext(ctx).
+				Create(&BalanceRecord{UserID: c.UserID, Symbol: c.Currency, Available: after, UpdatedAt: now}).Error
+		}
+		return tx.balanceTable(c.UserID).
+			WithContext(ctx).
+			Where("user_id = ? AND symbol = ?", c.UserID, c.Currency).
+			Updates(map[string]interface{}{
+				"available":  after,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": now,
+			}).Error
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return applied, duplicate, nil
+}
@@ -14,16 +14,23 @@ import (
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/dbroute"
 )
 
 // =============================================================================
 // BalanceRepo - 余额仓库
 // =============================================================================
 
+// ErrInsufficientLocked 解冻金额超过冻结余额
+var ErrInsufficientLocked = cexerr.New("FUND_INSUFFICIENT_LOCKED", cexerr.CategoryInsufficientFunds, "insufficient locked balance")
+
 // BalanceRepo 余额仓库
 type BalanceRepo struct {
 	db             *gorm.DB
-	useSingleTable bool // 开发模式用单表 balances，生产用分片表 balance_XXX
+	useSingleTable bool            // 开发模式用单表 balances，生产用分片表 balance_XXX
+	router         *dbroute.Router // 读写分离，nil 表示全部走 db
 }
 
 // NewBalanceRepo 创建余额仓库 (默认分片模式)
@@ -36,10 +43,33 @@ func NewSingleTableBalanceRepo(db *gorm.DB) *BalanceRepo {
 	return &BalanceRepo{db: db, useSingleTable: true}
 }
 
+// SetRouter 启用读写分离：ctx 标记了 dbroute.ReadOnly 的查询读从库，写和事务始终走主库
+//
+// router 的主库应与 db 是同一个库
+func (r *BalanceRepo) SetRouter(router *dbroute.Router) {
+	r.router = router
+}
+
 // =============================================================================
 // 分片表操作
 // =============================================================================
 
+// reader 查询用的库 (读写分离时按 ctx 路由)
+func (r *BalanceRepo) reader(ctx context.Context) *gorm.DB {
+	if r.router == nil {
+		return r.db
+	}
+	return r.router.DB(ctx)
+}
+
+func (r *BalanceRepo) readTable(ctx context.Context, prefix string, userID int64) *gorm.DB {
+	db := r.reader(ctx)
+	if r.useSingleTable {
+		return db.Table(prefix + "s")
+	}
+	return db.Table(GetTableName(prefix, userID))
+}
+
 // shardTable 获取分片表的 GORM Scope
 func (r *BalanceRepo) balanceTable(userID int64) *gorm.DB {
 	if r.useSingleTable {
@@ -64,7 +94,7 @@ func (r *BalanceRepo) journalTable(userID int64) *gorm.DB {
 // GetBalance 获取用户余额
 func (r *BalanceRepo) GetBalance(ctx context.Context, userID int64, symbol string) (*BalanceRecord, error) {
 	var record BalanceRecord
-	err := r.balanceTable(userID).
+	err := r.readTable(ctx, "balance", userID).
 		WithContext(ctx).
 		Where("user_id = ? AND symbol = ?", userID, symbol).
 		First(&record).Error
@@ -81,7 +111,7 @@ func (r *BalanceRepo) GetBalance(ctx context.Context, userID int64, symbol strin
 // GetBalances 获取用户所有币种余额
 func (r *BalanceRepo) GetBalances(ctx context.Context, userID int64) ([]*BalanceRecord, error) {
 	var records []*BalanceRecord
-	err := r.balanceTable(userID).
+	err := r.readTable(ctx, "balance", userID).
 		WithContext(ctx).
 		Where("user_id = ?", userID).
 		Find(&records).Error
@@ -89,6 +119,39 @@ func (r *BalanceRepo) GetBalances(ctx context.Context, userID int64) ([]*Balance
 	return records, err
 }
 
+// ForEachBalance 分批遍历全部余额 (储备金证明、全量对账用)
+//
+// 逐个分片按主键分批读取，内存只占一批；fn 返回错误时停止遍历
+func (r *BalanceRepo) ForEachBalance(
+	ctx context.Context,
+	batchSize int,
+	fn func(records []*BalanceRecord) error,
+) error {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	tables := []string{"balances"}
+	if !r.useSingleTable {
+		tables = make([]string, NumShards)
+		for i := range tables {
+			tables[i] = "balance_" + shardSuffix(i)
+		}
+	}
+
+	for _, table := range tables {
+		var batch []*BalanceRecord
+		err := r.reader(ctx).Table(table).
+			WithContext(ctx).
+			FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+				return fn(batch)
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // UpsertBalance 更新或插入余额
 func (r *BalanceRepo) UpsertBalance(ctx context.Context, snapshot *BalanceSnapshot) error {
 	record := &BalanceRecord{
@@ -155,7 +218,7 @@ func (r *BalanceRepo) FreezeBalance(ctx context.Context, userID int64, symbol st
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound // 余额不足或记录不存在
+		return ErrInsufficientAvailable // 余额不足或记录不存在 (不把 gorm.ErrRecordNotFound 漏给调用方)
 	}
 	return nil
 }
@@ -177,7 +240,7 @@ func (r *BalanceRepo) UnfreezeBalance(ctx context.Context, userID int64, symbol
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return ErrInsufficientLocked
 	}
 	return nil
 }
@@ -255,7 +318,7 @@ func (r *BalanceRepo) InsertJournal(ctx context.Context, event *JournalEvent) er
 // GetJournalByEventID 根据 EventID 查询流水
 func (r *BalanceRepo) GetJournalByEventID(ctx context.Context, userID int64, eventID string) (*JournalRecord, error) {
 	var record JournalRecord
-	err := r.journalTable(userID).
+	err := r.readTable(ctx, "journal", userID).
 		WithContext(ctx).
 		Where("event_id = ?", eventID).
 		First(&record).Error
@@ -276,7 +339,7 @@ func (r *BalanceRepo) ListJournals(
 	symbol string,
 	limit, offset int,
 ) ([]*JournalRecord, error) {
-	query := r.journalTable(userID).
+	query := r.readTable(ctx, "journal", userID).
 		WithContext(ctx).
 		Where("user_id = ?", userID).
 		Order("created_at DESC").
@@ -300,7 +363,7 @@ func (r *BalanceRepo) ListJournalsByBiz(
 	bizID string,
 ) ([]*JournalRecord, error) {
 	var records []*JournalRecord
-	err := r.journalTable(userID).
+	err := r.readTable(ctx, "journal", userID).
 		WithContext(ctx).
 		Where("user_id = ? AND biz_type = ? AND biz_id = ?", userID, bizType, bizID).
 		Order("created_at ASC").
@@ -309,6 +372,23 @@ func (r *BalanceRepo) ListJournalsByBiz(
 	return records, err
 }
 
+// ListFlowsSince 查询 since 之后某币种的充值 / 提现流水 (流量计数回填用，见 flow.go)
+func (r *BalanceRepo) ListFlowsSince(
+	ctx context.Context,
+	userID int64,
+	symbol string,
+	bizType BizType,
+	since time.Time,
+) ([]*JournalRecord, error) {
+	var records []*JournalRecord
+	err := r.readTable(ctx, "journal", userID).
+		WithContext(ctx).
+		Where("user_id = ? AND symbol = ? AND biz_type = ? AND created_at > ?", userID, symbol, bizType, since).
+		Find(&records).Error
+
+	return records, err
+}
+
 // =============================================================================
 // 批量操作
 // =============================================================================
@@ -371,11 +451,22 @@ func (r *BalanceRepo) batchInsertToShard(ctx context.Context, shard int, events
 // Transaction 执行事务
 func (r *BalanceRepo) Transaction(ctx context.Context, fn func(tx *BalanceRepo) error) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		txRepo := &BalanceRepo{db: tx}
+		txRepo := &BalanceRepo{db: tx, useSingleTable: r.useSingleTable}
 		return fn(txRepo)
 	})
 }
 
+// TransactionWithDB 执行事务，同时把事务内的 *gorm.DB 交给调用方，
+// 用于余额变动和调用方自己的表 (订单、outbox) 同一事务提交
+//
+// 【注意】调用方的表必须和余额表在同一个库
+func (r *BalanceRepo) TransactionWithDB(ctx context.Context, fn func(tx *BalanceRepo, db *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := &BalanceRepo{db: tx, useSingleTable: r.useSingleTable}
+		return fn(txRepo, tx)
+	})
+}
+
 // SaveBalanceAndJournal 事务中同时保存余额和流水
 func (r *BalanceRepo) SaveBalanceAndJournal(
 	ctx context.Context,
@@ -0,0 +1,325 @@
+// 文件: pkg/fund/custody.go
+// 冷资产模块 - 外部托管余额同步 (储备金证明的资产端)
+//
+// 【为什么需要】
+// pkg/reserve 的 Merkle 树只证明负债端：每个用户都在树里、总额没有漏算。
+// 平台手里到底有没有这么多币 (链上热/冷钱包、第三方托管、综合钱包 omnibus) 需要另外核对，
+// 否则负债总额公布得再漂亮，资产缺口也要等到挤兑提现才暴露
+//
+// 【做法】
+//   - CustodyProvider: 托管方适配器 (链上节点、托管商 API)，按资产报告平台持有的余额
+//   - LiabilitySource: 平台负债总额 = 全部用户余额 (可用 + 冻结) 按资产求和，默认 BalanceRepo
+//   - CustodyMonitor.Sync: 拉取所有托管方余额 + 负债总额，按资产比对，资产 < 负债时报警
+//
+// 报警按资产去重：进入缺口报一次，缺口消失再报一次 Recovered=true (同 SettlementLagMonitor)
+//
+// 【注意】
+//   - 平台自有账户 (手续费、保险基金、做市) 的余额是平台权益不是负债，用 ExcludeUsers 排除
+//   - 托管方拉取失败时沿用 StaleAfter 以内的上次结果，超过后该托管方不计入资产 (宁可误报缺口，
+//     不拿过期余额掩盖缺口)，同时报一次 CustodyAlertProvider
+//   - 托管余额和负债不是同一时刻的快照，充提在途会造成小额偏差，用 Tolerance 吸收
+
+package fund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CustodyBalance 托管方报告的单个资产余额
+type CustodyBalance struct {
+	Asset  string
+	Amount int64
+	AsOf   time.Time // 托管方数据时间 (链上区块时间等)，零值表示拉取时刻
+}
+
+// CustodyProvider 外部托管 / 综合钱包适配器
+type CustodyProvider interface {
+	Name() string
+	Balances(ctx context.Context) ([]CustodyBalance, error)
+}
+
+// LiabilitySource 平台负债总额 (按资产)
+type LiabilitySource interface {
+	Liabilities(ctx context.Context, exclude map[int64]bool) (map[string]int64, error)
+}
+
+var _ LiabilitySource = (*BalanceRepo)(nil)
+
+// Liabilities 全部用户余额 (可用 + 冻结) 按资产求和，跳过 exclude 中的用户
+func (r *BalanceRepo) Liabilities(ctx context.Context, exclude map[int64]bool) (map[string]int64, error) {
+	totals := make(map[string]int64)
+	err := r.ForEachBalance(ctx, 0, func(records []*BalanceRecord) error {
+		for _, rec := range records {
+			if exclude[rec.UserID] {
+				continue
+			}
+			totals[rec.Symbol] += rec.Available + rec.Locked
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// CustodyConfig 托管对账配置
+type CustodyConfig struct {
+	ExcludeUsers []int64          // 平台自有账户，余额不计入负债
+	Tolerance    map[string]int64 // 各资产允许的缺口 (充提在途)，未配置为 0
+	StaleAfter   time.Duration    // 托管数据 (按最早的 AsOf) 超过多久视为过期，默认 30 分钟
+}
+
+func (c CustodyConfig) withDefaults() CustodyConfig {
+	if c.StaleAfter <= 0 {
+		c.StaleAfter = 30 * time.Minute
+	}
+	return c
+}
+
+// CustodyAsset 单个资产的资产 / 负债比对
+type CustodyAsset struct {
+	Asset       string           `json:"asset"`
+	Custody     int64            `json:"custody"`     // 各托管方合计
+	Liabilities int64            `json:"liabilities"` // 用户余额合计
+	Surplus     int64            `json:"surplus"`     // Custody - Liabilities，负数为缺口
+	Providers   map[string]int64 `json:"providers"`   // 各托管方余额
+	Shortfall   bool             `json:"shortfall"`   // 缺口超过 Tolerance
+}
+
+// CustodyReport 一次同步的结果
+type CustodyReport struct {
+	At             time.Time         `json:"at"`
+	Assets         []CustodyAsset    `json:"assets"`                    // 按资产排序
+	ProviderErrors map[string]string `json:"provider_errors,omitempty"` // 本次拉取失败的托管方
+	Stale          []string          `json:"stale,omitempty"`           // 数据已过期、未计入资产的托管方
+}
+
+// Shortfalls 缺口超过容忍度的资产
+func (r *CustodyReport) Shortfalls() []CustodyAsset {
+	var out []CustodyAsset
+	for _, a := range r.Assets {
+		if a.Shortfall {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// 托管报警类型
+const (
+	CustodyAlertShortfall = "SHORTFALL" // 资产 < 负债
+	CustodyAlertProvider  = "PROVIDER"  // 托管方数据过期 (持续拉取失败或托管方数据陈旧)
+)
+
+// CustodyAlert 托管对账报警
+type CustodyAlert struct {
+	Kind      string
+	Asset     CustodyAsset // Kind=SHORTFALL
+	Provider  string       // Kind=PROVIDER
+	Error     string       // Kind=PROVIDER
+	Recovered bool
+	At        time.Time
+}
+
+// custodyPull 托管方最近一次成功拉取的结果
+type custodyPull struct {
+	balances []CustodyBalance
+	at       time.Time
+}
+
+// CustodyMonitor 外部托管余额同步与资产负债比对
+type CustodyMonitor struct {
+	cfg         CustodyConfig
+	exclude     map[int64]bool
+	liabilities LiabilitySource
+	providers   []CustodyProvider
+	onAlert     []func(CustodyAlert)
+	now         func() time.Time
+
+	mu         sync.Mutex
+	pulls      map[string]custodyPull
+	shortfalls map[string]bool // 正在报警的资产
+	stale      map[string]bool // 正在报警的托管方
+	last       *CustodyReport
+
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewCustodyMonitor 创建托管对账
+func NewCustodyMonitor(cfg CustodyConfig, liabilities LiabilitySource, providers ...CustodyProvider) *CustodyMonitor {
+	cfg = cfg.withDefaults()
+	exclude := make(map[int64]bool, len(cfg.ExcludeUsers))
+	for _, id := range cfg.ExcludeUsers {
+		exclude[id] = true
+	}
+	return &CustodyMonitor{
+		cfg:         cfg,
+		exclude:     exclude,
+		liabilities: liabilities,
+		providers:   providers,
+		now:         time.Now,
+		pulls:       make(map[string]custodyPull),
+		shortfalls:  make(map[string]bool),
+		stale:       make(map[string]bool),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// SetClock 替换时钟，托管方数据是否过期 (StaleAfter) 按它判断
+func (m *CustodyMonitor) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+// OnAlert 注册报警回调 (在 Sync 的调用协程里同步执行，不要阻塞)
+func (m *CustodyMonitor) OnAlert(fn func(CustodyAlert)) {
+	m.onAlert = append(m.onAlert, fn)
+}
+
+// Last 最近一次同步结果，还没同步过返回 nil
+func (m *CustodyMonitor) Last() *CustodyReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Sync 拉取托管余额和负债总额并比对，越过 / 回落缺口时触发报警
+//
+// 负债读取失败时返回错误、不更新结果 (没有负债就无从比对)；托管方失败记入报告，不返回错误
+func (m *CustodyMonitor) Sync(ctx context.Context) (*CustodyReport, error) {
+	liabilities, err := m.liabilities.Liabilities(ctx, m.exclude)
+	if err != nil {
+		return nil, fmt.Errorf("custody sync: liabilities: %w", err)
+	}
+
+	now := m.now()
+	report := &CustodyReport{At: now}
+	fresh := make(map[string]custodyPull, len(m.providers))
+	for _, p := range m.providers {
+		balances, err := p.Balances(ctx)
+		if err != nil {
+			if report.ProviderErrors == nil {
+				report.ProviderErrors = make(map[string]string)
+			}
+			report.ProviderErrors[p.Name()] = err.Error()
+			continue
+		}
+		// 托管方自己的数据时间更早时以它为准 (托管商 API 返回的可能是缓存)
+		at := now
+		for _, b := range balances {
+			if !b.AsOf.IsZero() && b.AsOf.Before(at) {
+				at = b.AsOf
+			}
+		}
+		fresh[p.Name()] = custodyPull{balances: balances, at: at}
+	}
+
+	m.mu.Lock()
+	for name, pull := range fresh {
+		m.pulls[name] = pull
+	}
+	assets := make(map[string]*CustodyAsset)
+	assetOf := func(symbol string) *CustodyAsset {
+		a, ok := assets[symbol]
+		if !ok {
+			a = &CustodyAsset{Asset: symbol, Providers: make(map[string]int64)}
+			assets[symbol] = a
+		}
+		return a
+	}
+	var alerts []CustodyAlert
+	for _, p := range m.providers {
+		name := p.Name()
+		pull, ok := m.pulls[name]
+		stale := !ok || now.Sub(pull.at) > m.cfg.StaleAfter
+		if stale != m.stale[name] {
+			m.stale[name] = stale
+			alerts = append(alerts, CustodyAlert{
+				Kind: CustodyAlertProvider, Provider: name, Error: report.ProviderErrors[name],
+				Recovered: !stale, At: now,
+			})
+		}
+		if stale {
+			report.Stale = append(report.Stale, name)
+			continue
+		}
+		for _, b := range pull.balances {
+			a := assetOf(b.Asset)
+			a.Custody += b.Amount
+			a.Providers[name] += b.Amount
+		}
+	}
+	for symbol, total := range liabilities {
+		if total != 0 {
+			assetOf(symbol).Liabilities = total
+		}
+	}
+
+	report.Assets = make([]CustodyAsset, 0, len(assets))
+	for _, a := range assets {
+		a.Surplus = a.Custody - a.Liabilities
+		a.Shortfall = -a.Surplus > m.cfg.Tolerance[a.Asset]
+		report.Assets = append(report.Assets, *a)
+	}
+	sort.Slice(report.Assets, func(i, j int) bool { return report.Assets[i].Asset < report.Assets[j].Asset })
+	for _, a := range report.Assets {
+		if a.Shortfall != m.shortfalls[a.Asset] {
+			m.shortfalls[a.Asset] = a.Shortfall
+			alerts = append(alerts, CustodyAlert{Kind: CustodyAlertShortfall, Asset: a, Recovered: !a.Shortfall, At: now})
+		}
+	}
+	m.last = report
+	m.mu.Unlock()
+
+	for _, a := range alerts {
+		for _, fn := range m.onAlert {
+			fn(a)
+		}
+	}
+	return report, nil
+}
+
+// Start 按固定间隔同步，单次同步失败等下一轮
+func (m *CustodyMonitor) Start(interval time.Duration) error {
+	if m.running {
+		return errors.New("custody monitor already running")
+	}
+	m.running = true
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if _, err := m.Sync(ctx); err != nil {
+					fmt.Printf("[Custody] sync error: %v\n", err)
+				}
+				cancel()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止同步
+func (m *CustodyMonitor) Stop() {
+	if !m.running {
+		return
+	}
+	close(m.stopCh)
+	m.wg.Wait()
+	m.running = false
+}
@@ -0,0 +1,217 @@
+package fund
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeCustody struct {
+	name     string
+	balances []CustodyBalance
+	err      error
+}
+
+func (p *fakeCustody) Name() string { return p.name }
+
+func (p *fakeCustody) Balances(context.Context) ([]CustodyBalance, error) {
+	return p.balances, p.err
+}
+
+type fakeLiabilities map[string]int64
+
+func (l fakeLiabilities) Liabilities(context.Context, map[int64]bool) (map[string]int64, error) {
+	return l, nil
+}
+
+// manualClock 可手动推进的时钟 (配合各监控的 SetClock)
+type manualClock struct{ t time.Time }
+
+func (c *manualClock) now() time.Time { return c.t }
+
+func newCustodyMonitor(cfg CustodyConfig, liabilities LiabilitySource, providers ...CustodyProvider) (*CustodyMonitor, *manualClock, *[]CustodyAlert) {
+	m := NewCustodyMonitor(cfg, liabilities, providers...)
+	clock := &manualClock{t: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	m.SetClock(clock.now)
+	alerts := new([]CustodyAlert)
+	m.OnAlert(func(a CustodyAlert) { *alerts = append(*alerts, a) })
+	return m, clock, alerts
+}
+
+func assetByName(r *CustodyReport, symbol string) CustodyAsset {
+	for _, a := range r.Assets {
+		if a.Asset == symbol {
+			return a
+		}
+	}
+	return CustodyAsset{}
+}
+
+func TestCustodyMonitor_Aggregation(t *testing.T) {
+	cold := &fakeCustody{name: "cold", balances: []CustodyBalance{{Asset: "BTC", Amount: 80}, {Asset: "USDT", Amount: 5000}}}
+	hot := &fakeCustody{name: "hot", balances: []CustodyBalance{{Asset: "BTC", Amount: 30}}}
+	m, _, _ := newCustodyMonitor(CustodyConfig{}, fakeLiabilities{"BTC": 100, "USDT": 5000, "ETH": 7}, cold, hot)
+
+	report, err := m.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Assets) != 3 || report.Assets[0].Asset != "BTC" || report.Assets[2].Asset != "USDT" {
+		t.Fatalf("assets %+v", report.Assets)
+	}
+	btc := assetByName(report, "BTC")
+	if btc.Custody != 110 || btc.Surplus != 10 || btc.Providers["cold"] != 80 || btc.Providers["hot"] != 30 || btc.Shortfall {
+		t.Errorf("BTC %+v", btc)
+	}
+	if usdt := assetByName(report, "USDT"); usdt.Surplus != 0 || usdt.Shortfall {
+		t.Errorf("USDT %+v", usdt)
+	}
+	// 只有负债、没有任何托管余额的资产也要出现在报告里
+	if eth := assetByName(report, "ETH"); eth.Custody != 0 || eth.Surplus != -7 || !eth.Shortfall {
+		t.Errorf("ETH %+v", eth)
+	}
+	if got := report.Shortfalls(); len(got) != 1 || got[0].Asset != "ETH" {
+		t.Errorf("shortfalls %+v", got)
+	}
+	if m.Last() != report {
+		t.Error("Last should return the latest report")
+	}
+}
+
+func TestCustodyMonitor_Tolerance(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		custody   int64
+		shortfall bool
+	}{
+		{"surplus", 110, false},
+		{"within tolerance", 95, false},
+		{"at tolerance", 90, false},
+		{"over tolerance", 89, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &fakeCustody{name: "cold", balances: []CustodyBalance{{Asset: "BTC", Amount: tc.custody}}}
+			m, _, _ := newCustodyMonitor(CustodyConfig{Tolerance: map[string]int64{"BTC": 10}}, fakeLiabilities{"BTC": 100}, p)
+			report, err := m.Sync(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := assetByName(report, "BTC").Shortfall; got != tc.shortfall {
+				t.Errorf("custody %d: shortfall = %v, want %v", tc.custody, got, tc.shortfall)
+			}
+		})
+	}
+}
+
+func TestCustodyMonitor_ShortfallAlerts(t *testing.T) {
+	p := &fakeCustody{name: "cold", balances: []CustodyBalance{{Asset: "BTC", Amount: 100}}}
+	m, _, alerts := newCustodyMonitor(CustodyConfig{}, fakeLiabilities{"BTC": 100}, p)
+	ctx := context.Background()
+
+	sync := func() {
+		t.Helper()
+		if _, err := m.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sync()
+	if len(*alerts) != 0 {
+		t.Fatalf("balanced books should not alert: %+v", *alerts)
+	}
+
+	// 进入缺口：报一次，持续缺口不重复报
+	p.balances[0].Amount = 60
+	sync()
+	sync()
+	if len(*alerts) != 1 {
+		t.Fatalf("expected one shortfall alert, got %+v", *alerts)
+	}
+	if a := (*alerts)[0]; a.Kind != CustodyAlertShortfall || a.Recovered || a.Asset.Asset != "BTC" || a.Asset.Surplus != -40 {
+		t.Errorf("shortfall alert %+v", a)
+	}
+
+	// 缺口消失：报一次恢复
+	p.balances[0].Amount = 100
+	sync()
+	if len(*alerts) != 2 {
+		t.Fatalf("expected recovery alert, got %+v", *alerts)
+	}
+	if a := (*alerts)[1]; a.Kind != CustodyAlertShortfall || !a.Recovered || a.Asset.Surplus != 0 {
+		t.Errorf("recovery alert %+v", a)
+	}
+}
+
+func TestCustodyMonitor_StaleProvider(t *testing.T) {
+	p := &fakeCustody{name: "omnibus", balances: []CustodyBalance{{Asset: "BTC", Amount: 100}}}
+	m, clock, alerts := newCustodyMonitor(CustodyConfig{StaleAfter: 30 * time.Minute}, fakeLiabilities{"BTC": 100}, p)
+	ctx := context.Background()
+
+	if _, err := m.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 拉取失败但上次结果还在 StaleAfter 以内：沿用，不报警
+	p.err = errors.New("api down")
+	clock.t = clock.t.Add(20 * time.Minute)
+	report, err := m.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ProviderErrors["omnibus"] != "api down" || len(report.Stale) != 0 || assetByName(report, "BTC").Custody != 100 {
+		t.Fatalf("cached pull not used: %+v", report)
+	}
+	if len(*alerts) != 0 {
+		t.Fatalf("unexpected alerts %+v", *alerts)
+	}
+
+	// 超过 StaleAfter：不计入资产，报托管方过期 + 资产缺口
+	clock.t = clock.t.Add(11 * time.Minute)
+	report, err = m.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Stale) != 1 || report.Stale[0] != "omnibus" || assetByName(report, "BTC").Custody != 0 {
+		t.Fatalf("stale provider still counted: %+v", report)
+	}
+	if len(*alerts) != 2 || (*alerts)[0].Kind != CustodyAlertProvider || (*alerts)[0].Recovered ||
+		(*alerts)[0].Error != "api down" || (*alerts)[1].Kind != CustodyAlertShortfall {
+		t.Fatalf("alerts %+v", *alerts)
+	}
+
+	// 托管方恢复：两个报警都恢复
+	p.err = nil
+	if _, err := m.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*alerts) != 4 || !(*alerts)[2].Recovered || !(*alerts)[3].Recovered {
+		t.Fatalf("recovery alerts %+v", *alerts)
+	}
+
+	// 托管方自己的数据时间 (AsOf) 过旧：同样视为过期
+	p.balances = []CustodyBalance{{Asset: "BTC", Amount: 100, AsOf: clock.t.Add(-time.Hour)}}
+	report, err = m.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Stale) != 1 {
+		t.Fatalf("old AsOf should be stale: %+v", report)
+	}
+}
+
+func TestCustodyMonitor_LiabilityError(t *testing.T) {
+	m := NewCustodyMonitor(CustodyConfig{}, failingLiabilities{})
+	if _, err := m.Sync(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if m.Last() != nil {
+		t.Error("failed sync must not replace the last report")
+	}
+}
+
+type failingLiabilities struct{}
+
+func (failingLiabilities) Liabilities(context.Context, map[int64]bool) (map[string]int64, error) {
+	return nil, errors.New("db down")
+}
@@ -210,6 +210,20 @@ func (w *DBWriter) Stop() error {
 	return w.consumer.Stop()
 }
 
+// Pause 维护模式：停止消费，已收到的流水立即刷入数据库
+func (w *DBWriter) Pause(ctx context.Context) error {
+	if err := w.consumer.Pause(ctx); err != nil {
+		return err
+	}
+	w.flush()
+	return nil
+}
+
+// Resume 退出维护模式，继续消费
+func (w *DBWriter) Resume() error {
+	return w.consumer.Resume()
+}
+
 // Stats 获取统计
 func (w *DBWriter) Stats() DBWriterStats {
 	return w.stats
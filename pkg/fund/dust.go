@@ -0,0 +1,480 @@
+// 文件: pkg/fund/dust.go
+// 冷资产模块 - 碎币兑换 (小额余额清扫)
+//
+// 成交、手续费取整之后，用户账户里会留下大量"卖不掉"的小额余额 (低于最小下单量)。
+// DustConverter 把价值低于阈值的余额按指数价格兑换成目标资产 (如 USDT)：
+//
+//	用户 ──碎币 (BTC/ETH/...)──→ 平台碎币账户 (TreasuryAccountID)
+//	用户 ←──目标资产 (USDT)──── 平台碎币账户
+//
+// 每一腿都是一条流水 (ChangeTypeDust / BizTypeDust)，同一事务内完成，总量守恒。
+//
+// 【面试】碎币兑换怎么保证幂等？
+// 用户目标资产入账那条流水的 EventID 作为幂等键 (dust_{user}_{request}_{target}_in)，
+// 事务里第一个写入；INSERT IGNORE 没插进去说明这个请求已经处理过，整个事务回滚。
+// 先查后写只是快速路径，真正兜底的是流水表的唯一索引。
+//
+// 【注意】
+//   - 每日次数限制依赖进程内按用户加锁 + 流水计数；
+//     多实例部署时需要把同一用户路由到同一实例，否则并发请求可能都通过计数检查。
+//   - 兑换直接改冷库余额。用户 (或碎币账户) 的余额在热钱包 (asset.AccountEngine) 里时，
+//     热钱包看不到这次兑换，下一次回写 (write-behind) 还会把冷库改回去，
+//     所以配置了 HotOwner 时这类用户一律拒绝 (ErrDustHotOwned)
+
+package fund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+)
+
+// DustPricePrecision 指数价格精度 (与 asset.Precision 一致)
+const DustPricePrecision = fixed.Scale
+
+// maxDustRequestIDLen 请求ID长度上限，保证拼出来的 EventID 不超过 VARCHAR(64)
+const maxDustRequestIDLen = 24
+
+// dustSweepPrefix 平台清扫的请求ID前缀，用户请求不能使用
+const dustSweepPrefix = "sweep"
+
+var (
+	// ErrInvalidDustConfig 碎币兑换配置不合法
+	ErrInvalidDustConfig = cexerr.New("FUND_INVALID_DUST_CONFIG", cexerr.CategoryInvalidArgument, "invalid dust converter config")
+	// ErrInvalidDustRequest 请求参数不合法
+	ErrInvalidDustRequest = cexerr.New("FUND_INVALID_DUST_REQUEST", cexerr.CategoryInvalidArgument, "invalid dust conversion request")
+	// ErrNoDust 没有可兑换的碎币
+	ErrNoDust = cexerr.New("FUND_NO_DUST", cexerr.CategoryNotFound, "no dust balance to convert")
+	// ErrDustAlreadyConverted 同一请求已经处理过
+	ErrDustAlreadyConverted = cexerr.New("FUND_DUST_ALREADY_CONVERTED", cexerr.CategoryConflict, "dust conversion already applied")
+	// ErrDustDailyLimit 超过每日兑换次数
+	ErrDustDailyLimit = cexerr.New("FUND_DUST_DAILY_LIMIT", cexerr.CategoryRateLimited, "dust conversion daily limit exceeded")
+	// ErrDustInsufficientBalance 兑换时余额不足 (用户余额已变动，或碎币账户目标资产不足)
+	ErrDustInsufficientBalance = cexerr.New("FUND_DUST_INSUFFICIENT_BALANCE", cexerr.CategoryInsufficientFunds, "insufficient balance for dust conversion")
+	// ErrDustHotOwned 余额由热钱包持有，不能在冷库上兑换
+	ErrDustHotOwned = cexerr.New("FUND_DUST_HOT_OWNED", cexerr.CategoryFailedPrecondition, "balances are owned by the hot wallet")
+)
+
+// =============================================================================
+// 依赖接口
+// =============================================================================
+
+// DustPriceSource 指数价格来源
+type DustPriceSource interface {
+	// IndexPrice 返回 1 单位 asset 值多少 quote (精度 DustPricePrecision)，没有价格返回 0
+	IndexPrice(asset, quote string) int64
+}
+
+// DustPriceFunc 函数适配器，方便直接接 MarkPriceService.GetIndexPrice 之类的实现
+type DustPriceFunc func(asset, quote string) int64
+
+// IndexPrice 实现 DustPriceSource
+func (f DustPriceFunc) IndexPrice(asset, quote string) int64 { return f(asset, quote) }
+
+// DustLedger 碎币兑换需要的账本操作 (BalanceRepo 实现)
+type DustLedger interface {
+	GetBalances(ctx context.Context, userID int64) ([]*BalanceRecord, error)
+	// DustConverted 该请求是否已经处理过
+	DustConverted(ctx context.Context, userID int64, requestID, target string) (bool, error)
+	// DustConversionsSince 统计 since 之后用户主动完成的兑换次数 (不含平台清扫)
+	DustConversionsSince(ctx context.Context, userID int64, since time.Time) (int, error)
+	// ApplyDustConversion 在一个事务里完成全部资金腿和流水
+	ApplyDustConversion(ctx context.Context, conv *DustConversion, treasuryID int64) error
+}
+
+var _ DustLedger = (*BalanceRepo)(nil)
+
+// DustHotOwner 热钱包归属 (asset.AccountEngine 实现)
+type DustHotOwner interface {
+	// OwnsUser 用户余额是否由热钱包持有；查询失败时不能确定，兑换不进行
+	OwnsUser(userID int64) (bool, error)
+}
+
+// =============================================================================
+// 模型
+// =============================================================================
+
+// DustConfig 碎币兑换配置
+type DustConfig struct {
+	TargetAsset       string // 兑换成的资产，如 USDT
+	Threshold         int64  // 单个资产折合目标资产低于该值才算碎币
+	DailyLimit        int    // 每用户每天 (UTC) 最多兑换次数，0 = 不限
+	TreasuryAccountID int64  // 平台碎币账户：收碎币、付目标资产
+
+	// HotOwner 热钱包归属，用户或碎币账户归热钱包时拒绝兑换；
+	// 为 nil 表示没有热钱包 (冷库是唯一账本)
+	HotOwner DustHotOwner
+
+	Now func() time.Time // 测试注入，默认 time.Now
+}
+
+// DustRequest 用户兑换请求
+type DustRequest struct {
+	RequestID string // 幂等键 (客户端生成，同一用户内唯一)
+	UserID    int64
+	Assets    []string // 指定要兑换的资产，为空表示全部碎币
+}
+
+// DustItem 一个资产的兑换明细
+type DustItem struct {
+	Symbol string
+	Amount int64 // 兑换掉的数量
+	Price  int64 // 使用的指数价格
+	Value  int64 // 折合目标资产数量
+}
+
+// DustConversion 一次兑换
+type DustConversion struct {
+	RequestID string
+	UserID    int64
+	Target    string
+	Items     []DustItem // 按 Symbol 排序
+	Total     int64      // 用户收到的目标资产总量
+	CreatedAt time.Time
+}
+
+// DustSweepResult 批量清扫结果
+type DustSweepResult struct {
+	Converted int             // 成功兑换的用户数
+	Skipped   int             // 没有碎币或已经清扫过
+	Total     int64           // 发放的目标资产总量
+	Failed    map[int64]error // 失败的用户
+}
+
+// dustEventID 流水幂等键：同一请求的每条资金腿各自唯一
+func dustEventID(userID int64, requestID, symbol, leg string) string {
+	return fmt.Sprintf("dust_%d_%s_%s_%s", userID, requestID, symbol, leg)
+}
+
+// =============================================================================
+// DustConverter
+// =============================================================================
+
+// DustConverter 碎币兑换服务
+type DustConverter struct {
+	ledger DustLedger
+	prices DustPriceSource
+	config DustConfig
+
+	// 按用户分段加锁：同一用户的兑换串行，保证每日次数检查不被并发绕过
+	userLocks [64]sync.Mutex
+}
+
+// NewDustConverter 创建碎币兑换服务
+func NewDustConverter(ledger DustLedger, prices DustPriceSource, config DustConfig) (*DustConverter, error) {
+	if config.TargetAsset == "" || config.Threshold <= 0 || config.DailyLimit < 0 || config.TreasuryAccountID == 0 {
+		return nil, ErrInvalidDustConfig
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &DustConverter{ledger: ledger, prices: prices, config: config}, nil
+}
+
+// Quote 预览用户当前可兑换的碎币 (不落账)
+func (c *DustConverter) Quote(ctx context.Context, userID int64, assets []string) ([]DustItem, int64, error) {
+	balances, err := c.ledger.GetBalances(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, total := c.selectDust(balances, assets)
+	return items, total, nil
+}
+
+// Convert 用户发起兑换
+func (c *DustConverter) Convert(ctx context.Context, req DustRequest) (*DustConversion, error) {
+	if strings.HasPrefix(req.RequestID, dustSweepPrefix) {
+		return nil, ErrInvalidDustRequest
+	}
+	return c.convert(ctx, req, true)
+}
+
+// Sweep 管理员批量清扫 (定时任务)
+//
+// 请求ID按日期生成，同一天重复跑只会对每个用户生效一次；
+// 平台主动清扫不占用用户的每日兑换次数。
+func (c *DustConverter) Sweep(ctx context.Context, userIDs []int64) *DustSweepResult {
+	requestID := dustSweepPrefix + c.config.Now().UTC().Format("20060102")
+	result := &DustSweepResult{Failed: make(map[int64]error)}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			result.Failed[userID] = ctx.Err()
+			continue
+		}
+		if userID == c.config.TreasuryAccountID {
+			continue
+		}
+		conv, err := c.convert(ctx, DustRequest{RequestID: requestID, UserID: userID}, false)
+		switch {
+		case err == nil:
+			result.Converted++
+			result.Total += conv.Total
+		case errors.Is(err, ErrNoDust), errors.Is(err, ErrDustAlreadyConverted):
+			result.Skipped++
+		default:
+			result.Failed[userID] = err
+		}
+	}
+	return result
+}
+
+func (c *DustConverter) convert(ctx context.Context, req DustRequest, enforceLimit bool) (*DustConversion, error) {
+	if req.UserID == 0 || req.UserID == c.config.TreasuryAccountID ||
+		req.RequestID == "" || len(req.RequestID) > maxDustRequestIDLen {
+		return nil, ErrInvalidDustRequest
+	}
+
+	if err := c.checkHotOwner(req.UserID); err != nil {
+		return nil, err
+	}
+
+	mu := &c.userLocks[uint64(req.UserID)%uint64(len(c.userLocks))]
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 1. 幂等快速路径 (必须在次数检查之前，否则重试已成功的请求会报超限)
+	done, err := c.ledger.DustConverted(ctx, req.UserID, req.RequestID, c.config.TargetAsset)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return nil, ErrDustAlreadyConverted
+	}
+
+	// 2. 每日次数
+	now := c.config.Now()
+	if enforceLimit && c.config.DailyLimit > 0 {
+		y, m, d := now.UTC().Date()
+		n, err := c.ledger.DustConversionsSince(ctx, req.UserID, time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			return nil, err
+		}
+		if n >= c.config.DailyLimit {
+			return nil, ErrDustDailyLimit
+		}
+	}
+
+	// 3. 选出碎币并定价
+	balances, err := c.ledger.GetBalances(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	items, total := c.selectDust(balances, req.Assets)
+	if len(items) == 0 {
+		return nil, ErrNoDust
+	}
+
+	// 4. 落账
+	conv := &DustConversion{
+		RequestID: req.RequestID,
+		UserID:    req.UserID,
+		Target:    c.config.TargetAsset,
+		Items:     items,
+		Total:     total,
+		CreatedAt: now,
+	}
+	if err := c.ledger.ApplyDustConversion(ctx, conv, c.config.TreasuryAccountID); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// checkHotOwner 用户和碎币账户都不能归热钱包 (两边的冷库余额都会被改)
+func (c *DustConverter) checkHotOwner(userID int64) error {
+	if c.config.HotOwner == nil {
+		return nil
+	}
+	for _, id := range []int64{userID, c.config.TreasuryAccountID} {
+		owned, err := c.config.HotOwner.OwnsUser(id)
+		if err != nil {
+			return fmt.Errorf("dust: hot owner of user %d: %w", id, err)
+		}
+		if owned {
+			return ErrDustHotOwned.Wrapf("user %d", id)
+		}
+	}
+	return nil
+}
+
+// selectDust 选出折合价值在 (0, Threshold) 之间的余额
+//
+// 有冻结 (挂单中) 的资产跳过：只兑换可用部分会留下新的碎币，也可能和撤单结算冲突
+func (c *DustConverter) selectDust(balances []*BalanceRecord, assets []string) ([]DustItem, int64) {
+	var allow map[string]bool
+	if len(assets) > 0 {
+		allow = make(map[string]bool, len(assets))
+		for _, a := range assets {
+			allow[a] = true
+		}
+	}
+
+	var items []DustItem
+	var total int64
+	for _, b := range balances {
+		if b.Symbol == c.config.TargetAsset || b.Available <= 0 || b.Locked != 0 {
+			continue
+		}
+		if allow != nil && !allow[b.Symbol] {
+			continue
+		}
+		price := c.prices.IndexPrice(b.Symbol, c.config.TargetAsset)
+		if price <= 0 {
+			continue // 没有指数价格的资产不参与
+		}
+		// 折合价值向下取整，零头归平台；价值为 0 的资产兑换后用户什么也拿不到，不处理
+		value := fixed.MulDiv(b.Available, price, DustPricePrecision) // 溢出时饱和到 MaxInt64 (肯定不是碎币)
+		if value <= 0 || value >= c.config.Threshold {
+			continue
+		}
+		items = append(items, DustItem{Symbol: b.Symbol, Amount: b.Available, Price: price, Value: value})
+		total += value
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Symbol < items[j].Symbol })
+	return items, total
+}
+
+// =============================================================================
+// BalanceRepo 实现
+// =============================================================================
+
+// DustConverted 查询幂等键流水是否存在
+func (r *BalanceRepo) DustConverted(ctx context.Context, userID int64, requestID, target string) (bool, error) {
+	record, err := r.GetJournalByEventID(ctx, userID, dustEventID(userID, requestID, target, "in"))
+	return record != nil, err
+}
+
+// DustConversionsSince 统计用户主动兑换次数 (一个请求一个 biz_id，平台清扫不计)
+func (r *BalanceRepo) DustConversionsSince(ctx context.Context, userID int64, since time.Time) (int, error) {
+	var n int64
+	err := r.journalTable(userID).
+		WithContext(ctx).
+		Where("user_id = ? AND biz_type = ? AND created_at >= ? AND biz_id NOT LIKE ?",
+			userID, BizTypeDust, since, dustSweepPrefix+"%").
+		Distinct("biz_id").
+		Count(&n).Error
+	return int(n), err
+}
+
+// ApplyDustConversion 事务内完成：用户目标资产入账 → 用户碎币出账 → 碎币账户反向分录
+//
+// 【注意】加锁顺序固定：先用户行，再碎币账户行 (按币种排序)，
+// 不同用户并发兑换时碎币账户的行锁顺序一致，不会死锁
+func (r *BalanceRepo) ApplyDustConversion(ctx context.Context, conv *DustConversion, treasuryID int64) error {
+	return r.Transaction(ctx, func(tx *BalanceRepo) error {
+		bizID := conv.RequestID
+
+		// 幂等键流水第一个写，重复请求在这里回滚
+		if err := tx.moveAvailable(ctx, conv.UserID, conv.Target, conv.Total,
+			dustEventID(conv.UserID, bizID, conv.Target, "in"), bizID, conv.CreatedAt); err != nil {
+			return err
+		}
+
+		treasuryLegs := make(map[string]int64, len(conv.Items)+1)
+		treasuryLegs[conv.Target] -= conv.Total
+		for _, item := range conv.Items {
+			if err := tx.moveAvailable(ctx, conv.UserID, item.Symbol, -item.Amount,
+				dustEventID(conv.UserID, bizID, item.Symbol, "out"), bizID, conv.CreatedAt); err != nil {
+				return err
+			}
+			treasuryLegs[item.Symbol] += item.Amount
+		}
+
+		symbols := make([]string, 0, len(treasuryLegs))
+		for s := range treasuryLegs {
+			symbols = append(symbols, s)
+		}
+		sort.Strings(symbols)
+		for _, s := range symbols {
+			leg := "tin"
+			if treasuryLegs[s] < 0 {
+				leg = "tout"
+			}
+			if err := tx.moveAvailable(ctx, treasuryID, s, treasuryLegs[s],
+				dustEventID(conv.UserID, bizID, s, leg), bizID, conv.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// moveAvailable 加行锁读余额 → 写流水 → 改可用余额 (只能在事务内调用)
+// 流水已存在返回 ErrDustAlreadyConverted，余额不足返回 ErrDustInsufficientBalance
+func (r *BalanceRepo) moveAvailable(
+	ctx context.Context,
+	userID int64,
+	symbol string,
+	delta int64,
+	eventID, bizID string,
+	now time.Time,
+) error {
+	var record BalanceRecord
+	err := r.balanceTable(userID).
+		WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND symbol = ?", userID, symbol).
+		First(&record).Error
+	exists := err == nil
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	after := record.Available + delta
+	if after < 0 {
+		return fmt.Errorf("%w: user %d %s available %d, need %d",
+			ErrDustInsufficientBalance, userID, symbol, record.Available, -delta)
+	}
+
+	amount := delta
+	if amount < 0 {
+		amount = -amount
+	}
+	result := r.journalTable(userID).
+		WithContext(ctx).
+		Clauses(clause.Insert{Modifier: "IGNORE"}).
+		Create(&JournalRecord{
+			EventID:         eventID,
+			UserID:          userID,
+			Symbol:          symbol,
+			ChangeType:      ChangeTypeDust,
+			Amount:          amount,
+			AvailableBefore: record.Available,
+			AvailableAfter:  after,
+			LockedBefore:    record.Locked,
+			LockedAfter:     record.Locked,
+			BizType:         BizTypeDust,
+			BizID:           bizID,
+			CreatedAt:       now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDustAlreadyConverted
+	}
+
+	if !exists {
+		return r.balanceTable(userID).
+			WithContext(ctx).
+			Create(&BalanceRecord{UserID: userID, Symbol: symbol, Available: after, UpdatedAt: now}).Error
+	}
+	return r.balanceTable(userID).
+		WithContext(ctx).
+		Where("user_id = ? AND symbol = ?", userID, symbol).
+		Updates(map[string]interface{}{
+			"available":  after,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": now,
+		}).Error
+}
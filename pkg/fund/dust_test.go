@@ -0,0 +1,317 @@
+package fund
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memDustLedger 内存账本，语义与 BalanceRepo 的实现一致
+type memDustLedger struct {
+	mu       sync.Mutex
+	balances map[int64]map[string]*BalanceRecord
+	done     map[string]time.Time // requestKey -> 兑换时间
+	applied  int
+}
+
+func newMemDustLedger() *memDustLedger {
+	return &memDustLedger{balances: make(map[int64]map[string]*BalanceRecord), done: make(map[string]time.Time)}
+}
+
+func (l *memDustLedger) set(userID int64, symbol string, available, locked int64) {
+	if l.balances[userID] == nil {
+		l.balances[userID] = make(map[string]*BalanceRecord)
+	}
+	l.balances[userID][symbol] = &BalanceRecord{UserID: userID, Symbol: symbol, Available: available, Locked: locked}
+}
+
+func (l *memDustLedger) available(userID int64, symbol string) int64 {
+	if b := l.balances[userID][symbol]; b != nil {
+		return b.Available
+	}
+	return 0
+}
+
+func dustRequestKey(userID int64, requestID string) string {
+	return fmt.Sprintf("%d/%s", userID, requestID)
+}
+
+func (l *memDustLedger) GetBalances(_ context.Context, userID int64) ([]*BalanceRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []*BalanceRecord
+	for _, b := range l.balances[userID] {
+		cp := *b
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (l *memDustLedger) DustConverted(_ context.Context, userID int64, requestID, _ string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.done[dustRequestKey(userID, requestID)]
+	return ok, nil
+}
+
+func (l *memDustLedger) DustConversionsSince(_ context.Context, userID int64, since time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	prefix := dustRequestKey(userID, "")
+	for key, at := range l.done {
+		if strings.HasPrefix(key, prefix) && !strings.HasPrefix(key, prefix+dustSweepPrefix) && !at.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (l *memDustLedger) ApplyDustConversion(_ context.Context, conv *DustConversion, treasuryID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := dustRequestKey(conv.UserID, conv.RequestID)
+	if _, ok := l.done[key]; ok {
+		return ErrDustAlreadyConverted
+	}
+	if l.available(treasuryID, conv.Target) < conv.Total {
+		return ErrDustInsufficientBalance
+	}
+	for _, item := range conv.Items {
+		if l.available(conv.UserID, item.Symbol) < item.Amount {
+			return ErrDustInsufficientBalance
+		}
+	}
+	move := func(userID int64, symbol string, delta int64) {
+		if l.balances[userID][symbol] == nil {
+			l.set(userID, symbol, 0, 0)
+		}
+		l.balances[userID][symbol].Available += delta
+	}
+	move(conv.UserID, conv.Target, conv.Total)
+	move(treasuryID, conv.Target, -conv.Total)
+	for _, item := range conv.Items {
+		move(conv.UserID, item.Symbol, -item.Amount)
+		move(treasuryID, item.Symbol, item.Amount)
+	}
+	l.done[key] = conv.CreatedAt
+	l.applied++
+	return nil
+}
+
+// fixedHotOwner 热钱包归属桩
+type fixedHotOwner struct {
+	owned map[int64]bool
+	err   error
+}
+
+func (o fixedHotOwner) OwnsUser(userID int64) (bool, error) { return o.owned[userID], o.err }
+
+const dustTreasury = 900
+
+var dustPrices = DustPriceFunc(func(asset, quote string) int64 {
+	switch asset {
+	case "BTC":
+		return 50000 * DustPricePrecision
+	case "DOGE":
+		return DustPricePrecision / 10
+	}
+	return 0
+})
+
+func newDustTest(t *testing.T, cfg DustConfig) (*DustConverter, *memDustLedger) {
+	t.Helper()
+	ledger := newMemDustLedger()
+	ledger.set(dustTreasury, "USDT", 1000*DustPricePrecision, 0)
+	ledger.set(1, "BTC", DustPricePrecision/100000, 0) // 0.00001 BTC = 0.5 USDT
+	ledger.set(1, "DOGE", 3*DustPricePrecision, 0)     // 0.3 USDT
+	ledger.set(1, "ETH", 1, 0)                         // 没有价格
+	ledger.set(1, "USDT", 10*DustPricePrecision, 0)    // 目标资产本身
+	cfg.TargetAsset = "USDT"
+	cfg.Threshold = DustPricePrecision // 1 USDT
+	cfg.TreasuryAccountID = dustTreasury
+	if cfg.Now == nil {
+		cfg.Now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	}
+	c, err := NewDustConverter(ledger, dustPrices, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, ledger
+}
+
+func TestDustConverter_Quote(t *testing.T) {
+	c, ledger := newDustTest(t, DustConfig{})
+	ledger.set(2, "BTC", DustPricePrecision, 0)            // 50000 USDT，不是碎币
+	ledger.set(2, "DOGE", 3*DustPricePrecision, 1)         // 有冻结，跳过
+	ledger.set(2, "XRP", DustPricePrecision/1000000000, 0) // 没有价格
+
+	items, total, err := c.Quote(context.Background(), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Symbol != "BTC" || items[1].Symbol != "DOGE" ||
+		items[0].Value != DustPricePrecision/2 || items[1].Value != 3*DustPricePrecision/10 ||
+		total != 8*DustPricePrecision/10 {
+		t.Fatalf("items %+v total %d", items, total)
+	}
+
+	// 只兑换指定资产
+	if items, _, _ := c.Quote(context.Background(), 1, []string{"DOGE"}); len(items) != 1 || items[0].Symbol != "DOGE" {
+		t.Fatalf("filtered items %+v", items)
+	}
+	if items, _, _ := c.Quote(context.Background(), 2, nil); len(items) != 0 {
+		t.Fatalf("user 2 has no dust, got %+v", items)
+	}
+}
+
+func TestDustConverter_Convert(t *testing.T) {
+	c, ledger := newDustTest(t, DustConfig{DailyLimit: 1})
+	ctx := context.Background()
+
+	conv, err := c.Convert(ctx, DustRequest{RequestID: "r1", UserID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Total != 8*DustPricePrecision/10 || len(conv.Items) != 2 {
+		t.Fatalf("conversion %+v", conv)
+	}
+	if got := ledger.available(1, "USDT"); got != 10*DustPricePrecision+conv.Total {
+		t.Errorf("user USDT %d", got)
+	}
+	if ledger.available(1, "BTC") != 0 || ledger.available(1, "DOGE") != 0 || ledger.available(1, "ETH") != 1 {
+		t.Errorf("user dust not cleared: %+v", ledger.balances[1])
+	}
+	if got := ledger.available(dustTreasury, "BTC"); got != DustPricePrecision/100000 {
+		t.Errorf("treasury BTC %d", got)
+	}
+
+	// 重放同一请求：幂等，不占次数
+	if _, err := c.Convert(ctx, DustRequest{RequestID: "r1", UserID: 1}); !errors.Is(err, ErrDustAlreadyConverted) {
+		t.Fatalf("replay: %v", err)
+	}
+	ledger.set(1, "DOGE", DustPricePrecision, 0)
+	if _, err := c.Convert(ctx, DustRequest{RequestID: "r2", UserID: 1}); !errors.Is(err, ErrDustDailyLimit) {
+		t.Fatalf("daily limit: %v", err)
+	}
+
+	// 平台清扫不受每日次数限制，同一天只生效一次
+	res := c.Sweep(ctx, []int64{1, 2, dustTreasury})
+	if res.Converted != 1 || res.Skipped != 1 || len(res.Failed) != 0 {
+		t.Fatalf("sweep %+v", res)
+	}
+	if res := c.Sweep(ctx, []int64{1}); res.Converted != 0 || res.Skipped != 1 {
+		t.Fatalf("second sweep %+v", res)
+	}
+
+	for _, req := range []DustRequest{
+		{RequestID: "sweep20260301", UserID: 1},         // 用户不能冒用清扫前缀
+		{RequestID: "r3", UserID: dustTreasury},         // 碎币账户本身
+		{RequestID: strings.Repeat("x", 25), UserID: 1}, // 超长
+		{RequestID: "", UserID: 1},                      // 缺幂等键
+	} {
+		if _, err := c.Convert(ctx, req); !errors.Is(err, ErrInvalidDustRequest) {
+			t.Errorf("%+v: expected ErrInvalidDustRequest, got %v", req, err)
+		}
+	}
+}
+
+// TestDustConverter_HotOwnerConflict 余额归热钱包的用户不能在冷库上兑换
+func TestDustConverter_HotOwnerConflict(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name  string
+		owner fixedHotOwner
+		want  error
+	}{
+		{"user owned", fixedHotOwner{owned: map[int64]bool{1: true}}, ErrDustHotOwned},
+		{"treasury owned", fixedHotOwner{owned: map[int64]bool{dustTreasury: true}}, ErrDustHotOwned},
+		{"owner unknown", fixedHotOwner{err: errors.New("shard timeout")}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, ledger := newDustTest(t, DustConfig{HotOwner: tc.owner})
+			_, err := c.Convert(ctx, DustRequest{RequestID: "r1", UserID: 1})
+			if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+				t.Fatalf("expected conflict error %v, got %v", tc.want, err)
+			}
+			if ledger.applied != 0 || ledger.available(1, "DOGE") != 3*DustPricePrecision {
+				t.Fatalf("cold ledger must not change: %+v", ledger.balances[1])
+			}
+			if res := c.Sweep(ctx, []int64{1}); res.Converted != 0 || res.Failed[1] == nil {
+				t.Fatalf("sweep should fail for the user: %+v", res)
+			}
+		})
+	}
+
+	// 热钱包不持有的用户照常兑换
+	c, ledger := newDustTest(t, DustConfig{HotOwner: fixedHotOwner{owned: map[int64]bool{2: true}}})
+	if _, err := c.Convert(ctx, DustRequest{RequestID: "r1", UserID: 1}); err != nil || ledger.applied != 1 {
+		t.Fatalf("cold-only user: %v", err)
+	}
+}
+
+// TestBalanceRepo_ApplyDustConversion 幂等键流水第一个写，碎币账户的腿按币种排序
+func TestBalanceRepo_ApplyDustConversion(t *testing.T) {
+	balances := map[string]int64{"1/BTC": 10, "1/DOGE": 30, "900/USDT": 1000}
+	db, fake := newFakeGorm(t, func(query string, args []driver.NamedValue) fakeResult {
+		if !strings.Contains(query, "FROM `balances`") {
+			return fakeResult{}
+		}
+		key := ""
+		if len(args) >= 2 {
+			key = fmt.Sprintf("%v/%v", args[0].Value, args[1].Value)
+		}
+		available, ok := balances[key]
+		if !ok {
+			return fakeResult{}
+		}
+		return fakeResult{
+			columns: []string{"user_id", "symbol", "available", "locked"},
+			rows:    [][]driver.Value{{args[0].Value, args[1].Value, available, int64(0)}},
+		}
+	})
+	repo := NewSingleTableBalanceRepo(db)
+	conv := &DustConversion{
+		RequestID: "r1", UserID: 1, Target: "USDT", Total: 7,
+		Items:     []DustItem{{Symbol: "BTC", Amount: 10}, {Symbol: "DOGE", Amount: 30}},
+		CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := repo.ApplyDustConversion(context.Background(), conv, dustTreasury); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	for _, s := range fake.execsMatching("INSERT IGNORE INTO `journals`") {
+		for _, a := range s.Args {
+			if v, ok := a.Value.(string); ok && strings.HasPrefix(v, "dust_") {
+				events = append(events, v)
+			}
+		}
+	}
+	want := []string{
+		"dust_1_r1_USDT_in", // 幂等键
+		"dust_1_r1_BTC_out",
+		"dust_1_r1_DOGE_out",
+		"dust_1_r1_BTC_tin",
+		"dust_1_r1_DOGE_tin",
+		"dust_1_r1_USDT_tout",
+	}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("journal events:\n got %v\nwant %v", events, want)
+	}
+	if fake.commits != 1 {
+		t.Errorf("commits = %d", fake.commits)
+	}
+
+	// 用户碎币已经变动 (余额不足)：整笔失败
+	balances["1/DOGE"] = 5
+	err := repo.ApplyDustConversion(context.Background(), conv, dustTreasury)
+	if !errors.Is(err, ErrDustInsufficientBalance) {
+		t.Fatalf("expected ErrDustInsufficientBalance, got %v", err)
+	}
+}
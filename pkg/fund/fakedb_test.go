@@ -0,0 +1,136 @@
+package fund
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// =============================================================================
+// fakeDB - 记录语句的假 MySQL 连接 (不依赖外部数据库)
+// =============================================================================
+//
+// 查询由 rows 按 SQL 返回结果集，写语句只记录不执行；
+// 用来断言某条路径发出 / 没发出哪些语句 (如上传失败时不能有 DELETE)
+
+// fakeResult 一个查询结果集
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+type fakeDB struct {
+	mu      sync.Mutex
+	queries []fakeStmt
+	execs   []fakeStmt
+	commits int
+	rows    func(query string, args []driver.NamedValue) fakeResult
+}
+
+type fakeStmt struct {
+	SQL  string
+	Args []driver.NamedValue
+}
+
+// newFakeGorm 创建连到 fakeDB 的 gorm.DB
+func newFakeGorm(t *testing.T, rows func(query string, args []driver.NamedValue) fakeResult) (*gorm.DB, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{rows: rows}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sql.OpenDB(fake), SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent), DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fake
+}
+
+// execsMatching 包含 substr 的写语句
+func (f *fakeDB) execsMatching(substr string) []fakeStmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeStmt
+	for _, s := range f.execs {
+		if strings.Contains(s.SQL, substr) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return nil, errors.New("fakeDB: use Connect") }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB: prepared statements not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{c.db}, nil }
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{c.db}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	c.db.queries = append(c.db.queries, fakeStmt{query, args})
+	c.db.mu.Unlock()
+	var res fakeResult
+	if c.db.rows != nil {
+		res = c.db.rows(query, args)
+	}
+	return &fakeRows{result: res}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	c.db.execs = append(c.db.execs, fakeStmt{query, args})
+	c.db.mu.Unlock()
+	return fakeExecResult{}, nil
+}
+
+type fakeExecResult struct{}
+
+func (fakeExecResult) LastInsertId() (int64, error) { return 1, nil }
+func (fakeExecResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	tx.db.commits++
+	tx.db.mu.Unlock()
+	return nil
+}
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
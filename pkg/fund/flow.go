@@ -0,0 +1,306 @@
+// 文件: pkg/fund/flow.go
+// 资金流量计数 - 每个用户滚动 24 小时的充值 / 提现金额与笔数
+//
+// 【用途】提现日限额 (withdrawrisk.Quota) 要知道 "这个用户最近 24 小时已经提了多少"，
+// 充值流量用于速度类风控 (刚充就提) 和运营查询
+//
+// 【Redis 结构】每个 (用户, 币种, 方向) 一个 hash，按时间分桶：
+//
+//	fund:flow:{userID}:{asset}:{DEPOSIT|WITHDRAW}
+//	    a:{桶起始秒}  → 该桶累计金额
+//	    n:{桶起始秒}  → 该桶笔数
+//	    bf           → 已从 DB 回填的标记
+//
+// 统计时累加与窗口有交集的桶：最早的桶可能有一部分在窗口外，结果最多多算一个桶，
+// 限额场景下宁可多算 (少放) 不能少算
+//
+// 【DB 回填】key 不存在 (首次统计、Redis 清空、超过 24h 无流水后过期) 时，
+// 先按 journal 表窗口内的流水回填，再写入标记；回填用 WATCH 事务，并发回填只有一个生效
+//
+// 【注意】
+//   - 计数在热钱包扣款 / 入账成功后记录，journal 经 Kafka 异步落库：
+//     回填时若某笔已落库又刚好在 Redis 里记过，会多算一次 (只会偏严)
+//   - 扣款超时 (结果未知) 不计数，可能少算一笔，由 journal 对账兜底
+
+package fund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FlowUsage 窗口内的累计流量
+type FlowUsage struct {
+	Amount int64 `json:"amount"`
+	Count  int64 `json:"count"`
+}
+
+// FlowCounter 资金流量计数 (*RedisFlowCounter / *MemoryFlowCounter 实现)
+//
+// biz 只接受 BizTypeDeposit / BizTypeWithdraw
+type FlowCounter interface {
+	Record(ctx context.Context, userID int64, asset string, biz BizType, amount int64, at time.Time) error
+	Usage(ctx context.Context, userID int64, asset string, biz BizType, now time.Time) (FlowUsage, error)
+}
+
+var (
+	_ FlowCounter = (*RedisFlowCounter)(nil)
+	_ FlowCounter = (*MemoryFlowCounter)(nil)
+)
+
+// FlowHistory 回填用的历史流水 (*BalanceRepo 实现)
+type FlowHistory interface {
+	ListFlowsSince(ctx context.Context, userID int64, symbol string, biz BizType, since time.Time) ([]*JournalRecord, error)
+}
+
+var _ FlowHistory = (*BalanceRepo)(nil)
+
+// FlowConfig 流量计数配置
+type FlowConfig struct {
+	Window    time.Duration // 滚动窗口，默认 24h
+	Bucket    time.Duration // 分桶粒度，默认 5m
+	KeyPrefix string        // 默认 "fund:flow"
+}
+
+func (c FlowConfig) withDefaults() FlowConfig {
+	if c.Window <= 0 {
+		c.Window = 24 * time.Hour
+	}
+	if c.Bucket <= 0 {
+		c.Bucket = 5 * time.Minute
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "fund:flow"
+	}
+	return c
+}
+
+// bucketOf 时间所在桶的起始秒
+func (c FlowConfig) bucketOf(t time.Time) int64 {
+	return t.Truncate(c.Bucket).Unix()
+}
+
+// inWindow 桶与窗口 (now-Window, now] 是否有交集
+func (c FlowConfig) inWindow(bucket int64, now time.Time) bool {
+	return time.Unix(bucket, 0).Add(c.Bucket).After(now.Add(-c.Window))
+}
+
+func checkFlowBiz(biz BizType) error {
+	if biz != BizTypeDeposit && biz != BizTypeWithdraw {
+		return fmt.Errorf("fund: flow biz type %q not supported", biz)
+	}
+	return nil
+}
+
+// =============================================================================
+// RedisFlowCounter
+// =============================================================================
+
+const (
+	flowFieldAmount     = "a:"
+	flowFieldCount      = "n:"
+	flowFieldBackfilled = "bf"
+
+	flowBackfillRetries = 3
+)
+
+// RedisFlowCounter Redis 分桶计数，多实例共享
+type RedisFlowCounter struct {
+	rdb     *redis.Client
+	history FlowHistory // 可选，nil 表示不回填 (从零开始计)
+	cfg     FlowConfig
+}
+
+// NewRedisFlowCounter 创建 Redis 流量计数，history 为 nil 时不从 DB 回填
+func NewRedisFlowCounter(rdb *redis.Client, history FlowHistory, cfg FlowConfig) *RedisFlowCounter {
+	return &RedisFlowCounter{rdb: rdb, history: history, cfg: cfg.withDefaults()}
+}
+
+func (c *RedisFlowCounter) key(userID int64, asset string, biz BizType) string {
+	return fmt.Sprintf("%s:%d:%s:%s", c.cfg.KeyPrefix, userID, asset, biz)
+}
+
+// ttl 最后一次写入后保留多久：窗口 + 一个桶，过期即整个窗口都没有流水
+func (c *RedisFlowCounter) ttl() time.Duration {
+	return c.cfg.Window + c.cfg.Bucket
+}
+
+// Record 记一笔流量
+func (c *RedisFlowCounter) Record(ctx context.Context, userID int64, asset string, biz BizType, amount int64, at time.Time) error {
+	if err := checkFlowBiz(biz); err != nil {
+		return err
+	}
+	key := c.key(userID, asset, biz)
+	if err := c.backfill(ctx, key, userID, asset, biz, at); err != nil {
+		return err
+	}
+	b := strconv.FormatInt(c.cfg.bucketOf(at), 10)
+	pipe := c.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, key, flowFieldAmount+b, amount)
+	pipe.HIncrBy(ctx, key, flowFieldCount+b, 1)
+	pipe.Expire(ctx, key, c.ttl())
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Usage 窗口内的累计流量，顺带清理窗口外的桶
+func (c *RedisFlowCounter) Usage(ctx context.Context, userID int64, asset string, biz BizType, now time.Time) (FlowUsage, error) {
+	if err := checkFlowBiz(biz); err != nil {
+		return FlowUsage{}, err
+	}
+	key := c.key(userID, asset, biz)
+	if err := c.backfill(ctx, key, userID, asset, biz, now); err != nil {
+		return FlowUsage{}, err
+	}
+	fields, err := c.rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return FlowUsage{}, err
+	}
+
+	var usage FlowUsage
+	var expired []string
+	for field, v := range fields {
+		prefix, ts, ok := splitFlowField(field)
+		if !ok {
+			continue
+		}
+		if !c.cfg.inWindow(ts, now) {
+			expired = append(expired, field)
+			continue
+		}
+		n, _ := strconv.ParseInt(v, 10, 64)
+		if prefix == flowFieldAmount {
+			usage.Amount += n
+		} else {
+			usage.Count += n
+		}
+	}
+	if len(expired) > 0 {
+		// 清理失败不影响结果，下次再清
+		c.rdb.HDel(ctx, key, expired...)
+	}
+	return usage, nil
+}
+
+// splitFlowField 解析 "a:{ts}" / "n:{ts}"
+func splitFlowField(field string) (prefix string, ts int64, ok bool) {
+	for _, p := range []string{flowFieldAmount, flowFieldCount} {
+		if rest, found := strings.CutPrefix(field, p); found {
+			ts, err := strconv.ParseInt(rest, 10, 64)
+			return p, ts, err == nil
+		}
+	}
+	return "", 0, false
+}
+
+// backfill key 没有回填标记时从 DB 回填窗口内的流水
+func (c *RedisFlowCounter) backfill(ctx context.Context, key string, userID int64, asset string, biz BizType, now time.Time) error {
+	done, err := c.rdb.HExists(ctx, key, flowFieldBackfilled).Result()
+	if err != nil || done {
+		return err
+	}
+
+	var records []*JournalRecord
+	if c.history != nil {
+		if records, err = c.history.ListFlowsSince(ctx, userID, asset, biz, now.Add(-c.cfg.Window)); err != nil {
+			return fmt.Errorf("fund: backfill flow: %w", err)
+		}
+	}
+
+	for range flowBackfillRetries {
+		err = c.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			done, err := tx.HExists(ctx, key, flowFieldBackfilled).Result()
+			if err != nil || done {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, r := range records {
+					b := strconv.FormatInt(c.cfg.bucketOf(r.CreatedAt), 10)
+					pipe.HIncrBy(ctx, key, flowFieldAmount+b, r.Amount)
+					pipe.HIncrBy(ctx, key, flowFieldCount+b, 1)
+				}
+				pipe.HSet(ctx, key, flowFieldBackfilled, 1)
+				pipe.Expire(ctx, key, c.ttl())
+				return nil
+			})
+			return err
+		}, key)
+		// 回填期间有人写了这个 key：可能是另一个实例回填完成，重读标记
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// =============================================================================
+// MemoryFlowCounter
+// =============================================================================
+
+// MemoryFlowCounter 进程内计数 (单实例 / 测试用)，重启后清零，不回填
+type MemoryFlowCounter struct {
+	cfg FlowConfig
+
+	mu      sync.Mutex
+	buckets map[flowKey]map[int64]FlowUsage // → 桶起始秒 → 流量
+}
+
+type flowKey struct {
+	userID int64
+	asset  string
+	biz    BizType
+}
+
+// NewMemoryFlowCounter 创建进程内流量计数
+func NewMemoryFlowCounter(cfg FlowConfig) *MemoryFlowCounter {
+	return &MemoryFlowCounter{cfg: cfg.withDefaults(), buckets: make(map[flowKey]map[int64]FlowUsage)}
+}
+
+// Record 记一笔流量
+func (c *MemoryFlowCounter) Record(ctx context.Context, userID int64, asset string, biz BizType, amount int64, at time.Time) error {
+	if err := checkFlowBiz(biz); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := flowKey{userID, asset, biz}
+	if c.buckets[k] == nil {
+		c.buckets[k] = make(map[int64]FlowUsage)
+	}
+	b := c.cfg.bucketOf(at)
+	u := c.buckets[k][b]
+	u.Amount += amount
+	u.Count++
+	c.buckets[k][b] = u
+	return nil
+}
+
+// Usage 窗口内的累计流量
+func (c *MemoryFlowCounter) Usage(ctx context.Context, userID int64, asset string, biz BizType, now time.Time) (FlowUsage, error) {
+	if err := checkFlowBiz(biz); err != nil {
+		return FlowUsage{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := flowKey{userID, asset, biz}
+	var usage FlowUsage
+	for b, u := range c.buckets[k] {
+		if !c.cfg.inWindow(b, now) {
+			delete(c.buckets[k], b)
+			continue
+		}
+		usage.Amount += u.Amount
+		usage.Count += u.Count
+	}
+	if len(c.buckets[k]) == 0 {
+		delete(c.buckets, k)
+	}
+	return usage, nil
+}
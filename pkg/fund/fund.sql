@@ -28,13 +28,13 @@ CREATE TABLE IF NOT EXISTS `journal_000` (
     `event_id` VARCHAR(64) NOT NULL COMMENT '幂等键',
     `user_id` BIGINT NOT NULL,
     `symbol` VARCHAR(16) NOT NULL,
-    `change_type` TINYINT NOT NULL COMMENT '1=冻结,2=解冻,3=划转,4=充值,5=提现,6=手续费',
+    `change_type` TINYINT NOT NULL COMMENT '1=冻结,2=解冻,3=划转,4=充值,5=提现,6=手续费,7=返佣,8=碎币兑换,9=推荐返佣',
     `amount` BIGINT NOT NULL COMMENT '变动金额 (正数)',
     `available_before` BIGINT NOT NULL,
     `available_after` BIGINT NOT NULL,
     `locked_before` BIGINT NOT NULL,
     `locked_after` BIGINT NOT NULL,
-    `biz_type` VARCHAR(16) NOT NULL COMMENT 'ORDER/TRADE/DEPOSIT/WITHDRAW',
+    `biz_type` VARCHAR(16) NOT NULL COMMENT 'ORDER/TRADE/DEPOSIT/WITHDRAW/DUST/COMMISSION',
     `biz_id` VARCHAR(64) NOT NULL COMMENT '关联业务ID',
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     UNIQUE KEY `uk_event_id` (`event_id`),
@@ -81,6 +81,7 @@ DELIMITER;
 
 -- 执行分表创建
 -- CALL create_balance_shards();
+-- 部署环境用 pkg/migrate 建表 (分片由 0002_fund_shards 创建)，不再需要此存储过程
 
 -- =============================================================================
 -- 简化版: 单表余额 (不分片，适合开发测试)
@@ -115,4 +116,37 @@ CREATE TABLE IF NOT EXISTS `journals` (
     UNIQUE KEY `uk_event_id` (`event_id`),
     KEY `idx_user_symbol` (`user_id`, `symbol`),
     KEY `idx_created` (`created_at`)
-) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '余额流水表 (单表版)';
\ No newline at end of file
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '余额流水表 (单表版)';
+
+-- =============================================================================
+-- 流水归档 (journal_archive.go)：清单 + 余额检查点
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS `journal_archives` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `source_table` VARCHAR(32) NOT NULL COMMENT '来源流水表，如 journal_005',
+    `first_id` BIGINT UNSIGNED NOT NULL,
+    `last_id` BIGINT UNSIGNED NOT NULL,
+    `records` INT NOT NULL DEFAULT 0,
+    `from_time` BIGINT NOT NULL COMMENT '批内最早 created_at (毫秒)',
+    `to_time` BIGINT NOT NULL COMMENT '批内最晚 created_at (毫秒)',
+    `object_key` VARCHAR(255) NOT NULL,
+    `checksum` CHAR(64) NOT NULL COMMENT '对象内容 sha256',
+    `archived_at` BIGINT NOT NULL COMMENT '毫秒',
+    `restored_at` BIGINT NOT NULL DEFAULT 0 COMMENT '最近一次恢复回原表 (毫秒)，0 表示没恢复过',
+    UNIQUE KEY `uk_table_first` (`source_table`, `first_id`),
+    KEY `idx_table_time` (`source_table`, `from_time`, `to_time`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '流水归档清单';
+
+CREATE TABLE IF NOT EXISTS `journal_checkpoints` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(16) NOT NULL,
+    `available` BIGINT NOT NULL DEFAULT 0,
+    `locked` BIGINT NOT NULL DEFAULT 0,
+    `last_journal_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    `last_event_id` VARCHAR(64) NOT NULL DEFAULT '',
+    `as_of` BIGINT NOT NULL COMMENT '最后一条已归档流水的 created_at (毫秒)',
+    `updated_at` BIGINT NOT NULL COMMENT '毫秒',
+    UNIQUE KEY `uk_user_symbol` (`user_id`, `symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '流水归档余额检查点';
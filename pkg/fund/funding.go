@@ -0,0 +1,48 @@
+// 文件: pkg/fund/funding.go
+// 冷资产模块 - 合约资金费落账
+//
+// 【面试】资金费结算中途宕机，重跑会不会重复扣款？
+// 每个用户每个结算时间点一条流水，EventID = funding_{symbol}_{funding_time}_{user}，
+// 走 ApplyOnce：流水和余额在同一事务里写，重跑时已结算的用户被流水唯一索引挡住。
+//
+// 【注意】支出按余额截断 (最多扣到 0)，截断在行锁内计算：
+// 先查余额再扣的话，两次读写之间用户可能刚好提现，扣成负数
+
+package fund
+
+import (
+	"context"
+	"fmt"
+)
+
+// FundingEventID 资金费流水幂等键
+func FundingEventID(symbol string, fundingTime, userID int64) string {
+	return fmt.Sprintf("funding_%s_%d_%d", symbol, fundingTime, userID)
+}
+
+// FundingBizID 资金费流水业务ID (同一合约同一结算时间点)
+func FundingBizID(symbol string, fundingTime int64) string {
+	return fmt.Sprintf("%s_%d", symbol, fundingTime)
+}
+
+// ApplyFunding 资金费落账 (幂等)
+//
+// payment > 0 为收入，全额入账；payment < 0 为支出，按可用余额截断。
+// 返回实际落账金额；这一笔已经落过账时 duplicate = true，applied 为当时的落账金额
+func (r *BalanceRepo) ApplyFunding(
+	ctx context.Context,
+	userID int64,
+	currency, symbol string,
+	fundingTime, payment int64,
+) (applied int64, duplicate bool, err error) {
+	return r.ApplyOnce(ctx, IdempotentChange{
+		UserID:          userID,
+		Currency:        currency,
+		Amount:          payment,
+		ClipToAvailable: true,
+		EventID:         FundingEventID(symbol, fundingTime, userID),
+		ChangeType:      ChangeTypeFunding,
+		BizType:         BizTypeFunding,
+		BizID:           FundingBizID(symbol, fundingTime),
+	})
+}
@@ -0,0 +1,223 @@
+package fund
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"max.com/pkg/walstore"
+)
+
+// memObjects 内存对象存储，failPut 非空时上传失败
+type memObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	failPut error
+}
+
+func (m *memObjects) Put(_ context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failPut != nil {
+		return m.failPut
+	}
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memObjects) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func (m *memObjects) List(context.Context, string) ([]string, error) { return nil, nil }
+
+func testJournals() []JournalRecord {
+	at := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+	return []JournalRecord{
+		{ID: 11, EventID: "dep_1", UserID: 1, Symbol: "USDT", ChangeType: ChangeTypeDeposit, Amount: 100,
+			AvailableAfter: 100, BizType: BizTypeTrade, BizID: "b1", CreatedAt: at},
+		{ID: 12, EventID: "res_1", UserID: 1, Symbol: "USDT", ChangeType: ChangeTypeReserve, Amount: 40,
+			AvailableBefore: 100, AvailableAfter: 60, LockedAfter: 40, CreatedAt: at.Add(time.Minute)},
+		{ID: 13, EventID: "dep_2", UserID: 2, Symbol: "BTC", ChangeType: ChangeTypeDeposit, Amount: 5,
+			AvailableAfter: 5, CreatedAt: at.Add(2 * time.Minute)},
+		{ID: 14, EventID: "dep_3", UserID: 1, Symbol: "BTC", ChangeType: ChangeTypeDeposit, Amount: 1,
+			AvailableAfter: 1, CreatedAt: at.Add(3 * time.Minute)},
+	}
+}
+
+func TestEncodeDecodeJournals(t *testing.T) {
+	records := testJournals()
+	data, err := encodeJournals(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeJournals(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, records)
+	}
+
+	// 空批次
+	data, err = encodeJournals(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decodeJournals(data); err != nil || len(got) != 0 {
+		t.Fatalf("empty: %v %+v", err, got)
+	}
+
+	if _, err := decodeJournals([]byte("not gzip")); err == nil {
+		t.Error("expected error for non-gzip data")
+	}
+}
+
+func TestCheckpointsOf(t *testing.T) {
+	records := testJournals()
+	// 批内乱序：同一 (用户, 币种) 以 id 最大的那条为准
+	records[0], records[1] = records[1], records[0]
+
+	list := checkpointsOf(records, 777)
+	want := []JournalCheckpoint{
+		{UserID: 1, Symbol: "BTC", Available: 1, LastJournalID: 14, LastEventID: "dep_3", AsOf: records[3].CreatedAt.UnixMilli(), UpdatedAt: 777},
+		{UserID: 1, Symbol: "USDT", Available: 60, Locked: 40, LastJournalID: 12, LastEventID: "res_1", AsOf: records[0].CreatedAt.UnixMilli(), UpdatedAt: 777},
+		{UserID: 2, Symbol: "BTC", Available: 5, LastJournalID: 13, LastEventID: "dep_2", AsOf: records[2].CreatedAt.UnixMilli(), UpdatedAt: 777},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %d checkpoints, want %d", len(list), len(want))
+	}
+	for i := range want {
+		if *list[i] != want[i] {
+			t.Errorf("checkpoint %d:\n got %+v\nwant %+v", i, *list[i], want[i])
+		}
+	}
+	if len(checkpointsOf(nil, 0)) != 0 {
+		t.Error("empty batch should have no checkpoints")
+	}
+}
+
+// journalRows 把流水转换成结果集 (列名与 gorm 默认命名一致)
+func journalRows(records []JournalRecord) fakeResult {
+	res := fakeResult{columns: []string{
+		"id", "event_id", "user_id", "symbol", "change_type", "amount",
+		"available_before", "available_after", "locked_before", "locked_after",
+		"biz_type", "biz_id", "created_at",
+	}}
+	for _, j := range records {
+		res.rows = append(res.rows, []driver.Value{
+			j.ID, j.EventID, j.UserID, j.Symbol, int64(j.ChangeType), j.Amount,
+			j.AvailableBefore, j.AvailableAfter, j.LockedBefore, j.LockedAfter,
+			string(j.BizType), j.BizID, j.CreatedAt,
+		})
+	}
+	return res
+}
+
+// newArchiveTest 单表模式的归档任务，第一次查询旧流水返回 records，之后返回空
+func newArchiveTest(t *testing.T, records []JournalRecord) (*JournalArchiver, *fakeDB, *memObjects, *[]driver.NamedValue) {
+	var (
+		mu     sync.Mutex
+		served bool
+		args   []driver.NamedValue
+	)
+	db, fake := newFakeGorm(t, func(query string, a []driver.NamedValue) fakeResult {
+		if !strings.Contains(query, "FROM `journals`") {
+			return fakeResult{}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		args = a
+		if served {
+			return journalRows(nil)
+		}
+		served = true
+		return journalRows(records)
+	})
+	objects := &memObjects{}
+	a := NewJournalArchiver(NewSingleTableBalanceRepo(db), JournalArchiveConfig{
+		Remote:    walstore.Remote{Objects: objects, Prefix: "fund/journal/"},
+		Retention: 30 * 24 * time.Hour,
+		BatchSize: 10,
+	})
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	a.SetClock(func() time.Time { return now })
+	return a, fake, objects, &args
+}
+
+func TestJournalArchiver_UploadFailureKeepsRows(t *testing.T) {
+	a, fake, objects, _ := newArchiveTest(t, testJournals())
+	objects.failPut = errors.New("s3 unavailable")
+
+	n, err := a.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "s3 unavailable") {
+		t.Fatalf("expected upload error, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("archived %d, want 0", n)
+	}
+	// 上传失败: 不登记清单、不推进检查点、一行都不删
+	if len(fake.execs) != 0 || fake.commits != 0 {
+		t.Fatalf("no writes expected after failed upload, got %+v", fake.execs)
+	}
+	if st := a.Stats(); st.Failed != 1 || st.Archived != 0 || st.LastError == "" || !st.LastRun.IsZero() {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestJournalArchiver_Run(t *testing.T) {
+	records := testJournals()
+	a, fake, objects, args := newArchiveTest(t, records)
+
+	n, err := a.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(records) {
+		t.Fatalf("archived %d, want %d", n, len(records))
+	}
+
+	// 截止时间 = 时钟 - Retention
+	cutoff := time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC)
+	if len(*args) == 0 || (*args)[0].Value.(time.Time) != cutoff {
+		t.Errorf("select args %+v, want cutoff %s", *args, cutoff)
+	}
+
+	key := "fund/journal/journals/00000000000000000011-00000000000000000014.jsonl.gz"
+	data, ok := objects.objects[key]
+	if !ok {
+		t.Fatalf("object %s not uploaded, have %v", key, objects.objects)
+	}
+	if got, err := decodeJournals(data); err != nil || !reflect.DeepEqual(got, records) {
+		t.Fatalf("uploaded object: %v %+v", err, got)
+	}
+
+	if len(fake.execsMatching("INSERT INTO `journal_archives`")) != 1 ||
+		len(fake.execsMatching("INSERT INTO `journal_checkpoints`")) != 1 {
+		t.Errorf("expected archive + checkpoint inserts, got %+v", fake.execs)
+	}
+	deletes := fake.execsMatching("DELETE FROM `journals`")
+	if len(deletes) != 1 || len(deletes[0].Args) != len(records) {
+		t.Fatalf("expected one delete of %d ids, got %+v", len(records), deletes)
+	}
+	if fake.commits != 1 {
+		t.Errorf("commits = %d, want 1", fake.commits)
+	}
+	if st := a.Stats(); st.Archived != uint64(len(records)) || st.Objects != 1 || st.LastRun != cutoff.Add(30*24*time.Hour) {
+		t.Errorf("stats %+v", st)
+	}
+}
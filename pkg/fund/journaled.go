@@ -0,0 +1,166 @@
+// 文件: pkg/fund/journaled.go
+// 冷资产模块 - 带流水的冻结/解冻/扣冻结/入账
+//
+// 【问题】FreezeBalance / DeductLocked 这类条件 UPDATE 只改余额不记流水，
+// 也拿不到变动前后的金额：对账时冷钱包里的冻结对不上，查不出是哪笔订单冻的
+//
+// 【做法】和 ApplyOnce 同一套路，一次变动一个事务：
+//
+//	事务 { SELECT ... FOR UPDATE 读余额 → 算变动前后 → INSERT IGNORE 流水 → 没插进去就返回 → 检查余额 → 改余额 }
+//
+// 流水先于余额检查插入：重复请求直接按当时的流水返回，不会因为余额已经变了报余额不足；
+// 余额不足时整个事务回滚，流水也不留
+//
+// 【注意】EventID 必须由调用方按业务生成 (同一笔订单的冻结、退回各一个)，
+// 它既是流水唯一键也是幂等键，重试时要保持不变
+
+package fund
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+)
+
+// ErrMissingEventID 带流水的余额变动没有幂等键
+var ErrMissingEventID = cexerr.New("FUND_MISSING_EVENT_ID", cexerr.CategoryInvalidArgument, "journal event id required")
+
+// JournalRef 一笔余额变动对应的流水 (幂等键 + 业务归属)
+type JournalRef struct {
+	EventID    string
+	ChangeType ChangeType
+	BizType    BizType
+	BizID      string
+}
+
+// FreezeWithJournal 冻结余额并记流水 (下单)
+// available -= amount, locked += amount
+//
+// 返回本次 (或重复请求时当时) 的流水；余额不足返回 ErrInsufficientAvailable
+func (r *BalanceRepo) FreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref JournalRef) (*JournalRecord, bool, error) {
+	return r.applyJournaled(ctx, userID, symbol, -amount, amount, ref)
+}
+
+// UnfreezeWithJournal 解冻余额并记流水 (撤单、退回未用完的保证金)
+// available += amount, locked -= amount
+func (r *BalanceRepo) UnfreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref JournalRef) (*JournalRecord, bool, error) {
+	return r.applyJournaled(ctx, userID, symbol, amount, -amount, ref)
+}
+
+// DeductLockedWithJournal 扣除冻结余额并记流水 (成交)
+// locked -= amount，冻结不足返回 ErrInsufficientLocked
+func (r *BalanceRepo) DeductLockedWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref JournalRef) (*JournalRecord, bool, error) {
+	return r.applyJournaled(ctx, userID, symbol, 0, -amount, ref)
+}
+
+// AddAvailableWithJournal 增加可用余额并记流水 (成交收款、平仓结算)，余额记录不存在时创建
+func (r *BalanceRepo) AddAvailableWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref JournalRef) (*JournalRecord, bool, error) {
+	return r.applyJournaled(ctx, userID, symbol, amount, 0, ref)
+}
+
+// applyJournaled 行锁内按 (可用, 冻结) 增量改余额并记流水
+//
+// 重复请求返回 duplicate = true 和当时的流水，余额不动
+func (r *BalanceRepo) applyJournaled(
+	ctx context.Context,
+	userID int64,
+	symbol string,
+	deltaAvailable, deltaLocked int64,
+	ref JournalRef,
+) (journal *JournalRecord, duplicate bool, err error) {
+	if ref.EventID == "" {
+		return nil, false, ErrMissingEventID
+	}
+	now := time.Now()
+	err = r.Transaction(ctx, func(tx *BalanceRepo) error {
+		var record BalanceRecord
+		err := tx.balanceTable(userID).
+			WithContext(ctx).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND symbol = ?", userID, symbol).
+			First(&record).Error
+		exists := err == nil
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		journal = &JournalRecord{
+			EventID:         ref.EventID,
+			UserID:          userID,
+			Symbol:          symbol,
+			ChangeType:      ref.ChangeType,
+			Amount:          max(abs(deltaAvailable), abs(deltaLocked)),
+			AvailableBefore: record.Available,
+			AvailableAfter:  record.Available + deltaAvailable,
+			LockedBefore:    record.Locked,
+			LockedAfter:     record.Locked + deltaLocked,
+			BizType:         ref.BizType,
+			BizID:           ref.BizID,
+			CreatedAt:       now,
+		}
+		result := tx.journalTable(userID).
+			WithContext(ctx).
+			Clauses(clause.Insert{Modifier: "IGNORE"}).
+			Create(journal)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 已落过账：返回当时的流水
+			var prev JournalRecord
+			err := tx.journalTable(userID).
+				WithContext(ctx).
+				Where("event_id = ?", ref.EventID).
+				First(&prev).Error
+			if err != nil {
+				return err
+			}
+			journal, duplicate = &prev, true
+			return nil
+		}
+
+		// 余额检查放在流水之后，不足时事务回滚，流水一起撤掉
+		if journal.AvailableAfter < 0 {
+			return fmt.Errorf("%w: user %d %s available %d, need %d",
+				ErrInsufficientAvailable, userID, symbol, record.Available, -deltaAvailable)
+		}
+		if journal.LockedAfter < 0 {
+			return fmt.Errorf("%w: user %d %s locked %d, need %d",
+				ErrInsufficientLocked, userID, symbol, record.Locked, -deltaLocked)
+		}
+
+		if !exists {
+			return tx.balanceTable(userID).
+				WithContext(ctx).
+				Create(&BalanceRecord{
+					UserID:    userID,
+					Symbol:    symbol,
+					Available: journal.AvailableAfter,
+					Locked:    journal.LockedAfter,
+					UpdatedAt: now,
+				}).Error
+		}
+		return tx.balanceTable(userID).
+			WithContext(ctx).
+			Where("user_id = ? AND symbol = ?", userID, symbol).
+			Updates(map[string]interface{}{
+				"available":  journal.AvailableAfter,
+				"locked":     journal.LockedAfter,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": now,
+			}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return journal, duplicate, nil
+}
+
+func abs(x int64) int64 {
+	return max(x, -x)
+}
@@ -28,12 +28,18 @@ const (
 type ChangeType uint8
 
 const (
-	ChangeTypeReserve  ChangeType = 1 // 冻结 (下单)
-	ChangeTypeRelease  ChangeType = 2 // 解冻 (撤单)
-	ChangeTypeTransfer ChangeType = 3 // 划转 (成交)
-	ChangeTypeDeposit  ChangeType = 4 // 充值
-	ChangeTypeWithdraw ChangeType = 5 // 提现
-	ChangeTypeFee      ChangeType = 6 // 手续费
+	ChangeTypeReserve    ChangeType = 1  // 冻结 (下单)
+	ChangeTypeRelease    ChangeType = 2  // 解冻 (撤单)
+	ChangeTypeTransfer   ChangeType = 3  // 划转 (成交)
+	ChangeTypeDeposit    ChangeType = 4  // 充值
+	ChangeTypeWithdraw   ChangeType = 5  // 提现
+	ChangeTypeFee        ChangeType = 6  // 手续费
+	ChangeTypeRebate     ChangeType = 7  // maker 返佣
+	ChangeTypeDust       ChangeType = 8  // 碎币兑换
+	ChangeTypeCommission ChangeType = 9  // 推荐返佣
+	ChangeTypeFunding    ChangeType = 10 // 合约资金费
+	ChangeTypeSettlement ChangeType = 11 // 合约交割
+	ChangeTypeSocialLoss ChangeType = 12 // 穿仓分摊 (保险基金不足时从盈利持仓扣回)
 )
 
 func (t ChangeType) String() string {
@@ -50,6 +56,18 @@ func (t ChangeType) String() string {
 		return "WITHDRAW"
 	case ChangeTypeFee:
 		return "FEE"
+	case ChangeTypeRebate:
+		return "REBATE"
+	case ChangeTypeDust:
+		return "DUST"
+	case ChangeTypeCommission:
+		return "COMMISSION"
+	case ChangeTypeFunding:
+		return "FUNDING"
+	case ChangeTypeSettlement:
+		return "SETTLEMENT"
+	case ChangeTypeSocialLoss:
+		return "SOCIALIZED_LOSS"
 	default:
 		return "UNKNOWN"
 	}
@@ -59,10 +77,16 @@ func (t ChangeType) String() string {
 type BizType string
 
 const (
-	BizTypeOrder    BizType = "ORDER"    // 订单相关
-	BizTypeTrade    BizType = "TRADE"    // 成交相关
-	BizTypeDeposit  BizType = "DEPOSIT"  // 充值
-	BizTypeWithdraw BizType = "WITHDRAW" // 提现
+	BizTypeOrder      BizType = "ORDER"           // 订单相关
+	BizTypeTrade      BizType = "TRADE"           // 成交相关
+	BizTypeDeposit    BizType = "DEPOSIT"         // 充值
+	BizTypeWithdraw   BizType = "WITHDRAW"        // 提现
+	BizTypeDust       BizType = "DUST"            // 碎币兑换
+	BizTypeCommission BizType = "COMMISSION"      // 推荐返佣 (BizID 为结算日)
+	BizTypeFunding    BizType = "FUNDING"         // 合约资金费 (BizID 为 {symbol}_{funding_time})
+	BizTypeSettlement BizType = "SETTLEMENT"      // 合约交割 (BizID 为 {symbol}_{expiry})
+	BizTypeSocialLoss BizType = "SOCIALIZED_LOSS" // 穿仓分摊 (BizID 为 {symbol}_{cycle})
+	BizTypeAutoMargin BizType = "AUTO_MARGIN"     // 自动追加保证金 (BizID 为 {symbol}_{level_change_at})
 )
 
 // =============================================================================
@@ -94,6 +118,11 @@ type JournalEvent struct {
 	BizType BizType `json:"biz_type"` // ORDER/TRADE/DEPOSIT/WITHDRAW
 	BizID   string  `json:"biz_id"`   // 订单ID/成交ID/充值ID
 
+	// ===== 手续费明细 (仅成交的 FEE / REBATE 流水，不落库) =====
+	Liquidity string `json:"liquidity,omitempty"` // maker / taker
+	FeeRate   int64  `json:"fee_rate,omitempty"`  // 适用的费率 (万分比，返佣为负)
+	FeeTier   string `json:"fee_tier,omitempty"`  // 费率档位
+
 	// ===== 时间 =====
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -10,6 +10,7 @@ package fund
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -35,12 +36,25 @@ type TradeEvent struct {
 	Price          int64  `json:"price"`
 	Qty            int64  `json:"qty"`
 	Timestamp      int64  `json:"timestamp"`
+
+	// 双方实际收取的手续费 (负数为返佣) 及适用的费率 (万分比)、费率档位；
+	// 不收交易手续费的成交 (合约) 为空
+	TakerFee      int64  `json:"taker_fee,omitempty"`
+	TakerFeeAsset string `json:"taker_fee_asset,omitempty"`
+	TakerFeeRate  int64  `json:"taker_fee_rate,omitempty"`
+	TakerFeeTier  string `json:"taker_fee_tier,omitempty"`
+	MakerFee      int64  `json:"maker_fee,omitempty"`
+	MakerFeeAsset string `json:"maker_fee_asset,omitempty"`
+	MakerFeeRate  int64  `json:"maker_fee_rate,omitempty"`
+	MakerFeeTier  string `json:"maker_fee_tier,omitempty"`
 }
 
 // NatsDBWriter NATS 数据库写入器
 type NatsDBWriter struct {
 	repo       *BalanceRepo
 	subscriber *nats.Subscriber
+	lag        *SettlementLagMonitor // 结算延迟监控 (可选)
+	deduper    nats.Deduper          // 消费端去重 (可选)，重投的成交不会重复扣冻结
 
 	// 统计
 	stats struct {
@@ -48,6 +62,7 @@ type NatsDBWriter struct {
 		CancelsReceived int64
 		WrittenCount    int64
 		ErrorCount      int64
+		DuplicateCount  int64
 	}
 	mu sync.Mutex
 }
@@ -65,6 +80,27 @@ func NewNatsDBWriter(repo *BalanceRepo, natsURL string) (*NatsDBWriter, error) {
 	return w, nil
 }
 
+// SetLagMonitor 设置结算延迟监控，每笔成交落库后上报进度
+func (w *NatsDBWriter) SetLagMonitor(m *SettlementLagMonitor) {
+	w.lag = m
+}
+
+// SetDeduper 设置消费端去重，多实例部署时用共享存储 (nats.RedisDeduper)
+func (w *NatsDBWriter) SetDeduper(d nats.Deduper) {
+	w.deduper = d
+}
+
+// EnableJetStream 改用 JetStream 持久化消费者 (nats.MatchEventStream)，需在 Start 之前调用
+//
+// 写入器宕机期间的成交留在流里，恢复后补扣冻结，不再只靠对账发现漏写；
+// 失败的消息按 cfg.Consumer 重投，重复投递由 Deduper 和条件更新兜住
+func (w *NatsDBWriter) EnableJetStream(cfg nats.JetStreamConfig) error {
+	if len(cfg.Streams) == 0 {
+		cfg.Streams = []nats.StreamConfig{nats.MatchEventStream()}
+	}
+	return w.subscriber.EnableJetStream(cfg)
+}
+
 // Start 启动监听
 func (w *NatsDBWriter) Start() error {
 	// 订阅成交事件
@@ -83,6 +119,21 @@ func (w *NatsDBWriter) Stop() error {
 	return w.subscriber.Close()
 }
 
+// Pause 维护模式：停止拉取新事件，等已收到和正在处理的事件处理完 (见 nats.Subscriber.Pause)
+func (w *NatsDBWriter) Pause(ctx context.Context) error {
+	return w.subscriber.Pause(ctx)
+}
+
+// Resume 退出维护模式，重新订阅
+func (w *NatsDBWriter) Resume() error {
+	return w.subscriber.Resume()
+}
+
+// Drained 是否已暂停且排空
+func (w *NatsDBWriter) Drained() bool {
+	return w.subscriber.Drained()
+}
+
 // handleMessage 处理消息
 func (w *NatsDBWriter) handleMessage(subject string, data []byte) error {
 	switch subject {
@@ -117,45 +168,59 @@ func (w *NatsDBWriter) handleTrade(data []byte) error {
 	}
 
 	// 扣除 Taker 的冻结 (保证金已用于持仓)
+	var errs []error
 	if event.TakerUserID > 0 && event.TakerMargin > 0 {
-		if err := w.repo.DeductLocked(ctx, event.TakerUserID, currency, event.TakerMargin); err != nil {
-			fmt.Printf("[NatsDBWriter] deduct taker locked failed: %v\n", err)
-		}
-		// 记录流水
-		w.repo.InsertJournal(ctx, &JournalEvent{
-			EventID:    fmt.Sprintf("trade_taker_%d", event.TradeID),
-			UserID:     event.TakerUserID,
-			Symbol:     currency,
-			ChangeType: ChangeTypeTransfer,
-			Amount:     event.TakerMargin,
-			BizType:    BizTypeTrade,
-			BizID:      fmt.Sprintf("%d", event.TradeID),
-			CreatedAt:  time.Now(),
-		})
+		errs = append(errs, w.deductOnce(ctx, "taker", event.TradeID, event.TakerUserID, currency, event.TakerMargin))
 	}
 
 	// 扣除 Maker 的冻结
 	if event.MakerUserID > 0 && event.MakerMargin > 0 {
-		if err := w.repo.DeductLocked(ctx, event.MakerUserID, currency, event.MakerMargin); err != nil {
-			fmt.Printf("[NatsDBWriter] deduct maker locked failed: %v\n", err)
-		}
-		// 记录流水
-		w.repo.InsertJournal(ctx, &JournalEvent{
-			EventID:    fmt.Sprintf("trade_maker_%d", event.TradeID),
-			UserID:     event.MakerUserID,
-			Symbol:     currency,
-			ChangeType: ChangeTypeTransfer,
-			Amount:     event.MakerMargin,
-			BizType:    BizTypeTrade,
-			BizID:      fmt.Sprintf("%d", event.TradeID),
-			CreatedAt:  time.Now(),
-		})
+		errs = append(errs, w.deductOnce(ctx, "maker", event.TradeID, event.MakerUserID, currency, event.MakerMargin))
+	}
+
+	// 任一方失败返回错误：JetStream 下整条成交重投，已扣过的一方被去重跳过
+	if err := errors.Join(errs...); err != nil {
+		w.mu.Lock()
+		w.stats.ErrorCount++
+		w.mu.Unlock()
+		return err
 	}
 
 	w.mu.Lock()
 	w.stats.WrittenCount++
 	w.mu.Unlock()
 
+	if w.lag != nil {
+		w.lag.ObserveApplied(event.Symbol, event.TradeID, event.Timestamp)
+	}
+
+	return nil
+}
+
+// deductOnce 扣一方的冻结并记流水，按流水 EventID 去重
+//
+// Taker / Maker 分开去重：一边失败重投时，已扣过的另一边不会再扣一次
+func (w *NatsDBWriter) deductOnce(ctx context.Context, role string, tradeID, userID int64, currency string, amount int64) error {
+	eventID := fmt.Sprintf("trade_%s_%d", role, tradeID)
+	handled, err := nats.HandleOnce(ctx, w.deduper, "trades", eventID, func() error {
+		// 扣冻结和流水同一事务，流水带变动前后金额
+		_, _, err := w.repo.DeductLockedWithJournal(ctx, userID, currency, amount, JournalRef{
+			EventID:    eventID,
+			ChangeType: ChangeTypeTransfer,
+			BizType:    BizTypeTrade,
+			BizID:      fmt.Sprintf("%d", tradeID),
+		})
+		return err
+	})
+	if err != nil {
+		fmt.Printf("[NatsDBWriter] deduct %s locked failed: %v\n", role, err)
+		return fmt.Errorf("deduct %s locked: %w", role, err)
+	}
+	if !handled {
+		w.mu.Lock()
+		w.stats.DuplicateCount++
+		w.mu.Unlock()
+	}
 	return nil
 }
 
@@ -188,9 +253,10 @@ func (w *NatsDBWriter) handleCancel(data []byte) error {
 		CreatedAt:  time.Now(),
 	}
 
-	w.repo.InsertJournal(ctx, journal)
-
-	return nil
+	_, err := nats.HandleOnce(ctx, w.deduper, "order.canceled", journal.EventID, func() error {
+		return w.repo.InsertJournal(ctx, journal)
+	})
+	return err
 }
 
 // Stats 获取统计
@@ -202,5 +268,6 @@ func (w *NatsDBWriter) Stats() map[string]int64 {
 		"cancels_received": w.stats.CancelsReceived,
 		"written_count":    w.stats.WrittenCount,
 		"error_count":      w.stats.ErrorCount,
+		"duplicate_count":  w.stats.DuplicateCount,
 	}
 }
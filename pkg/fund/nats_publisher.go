@@ -30,6 +30,11 @@ func NewNatsEventPublisher(natsURL string) (*NatsEventPublisher, error) {
 	return &NatsEventPublisher{publisher: publisher}, nil
 }
 
+// EnableJetStream 成交 / 撤单改为写入 JetStream 流 (nats.MatchEventStream)，等服务端确认后返回
+func (p *NatsEventPublisher) EnableJetStream() error {
+	return p.publisher.EnableJetStream(nats.MatchEventStream())
+}
+
 // PublishJournal 发布流水事件
 func (p *NatsEventPublisher) PublishJournal(event *JournalEvent) error {
 	return p.publisher.Publish(TopicJournalEvents, event)
@@ -81,7 +86,16 @@ func (p *NatsEventPublisher) PublishTrade(tradeID, takerOrderID, makerOrderID, p
 		"timestamp":      time.Now().UnixMilli(),
 	}
 	data, _ := json.Marshal(event)
-	return p.publisher.PublishRaw("trades", data)
+	return p.publisher.PublishWithID("trades", fmt.Sprintf("trade_%d", tradeID), data)
+}
+
+// PublishTradeEvent 发布带双方用户和手续费明细的成交事件 (现货处理器用，见 spot.TradePublisher)
+func (p *NatsEventPublisher) PublishTradeEvent(event *TradeEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.publisher.PublishWithID("trades", fmt.Sprintf("trade_%d", event.TradeID), data)
 }
 
 // PublishCancel 发布撤单事件
@@ -92,7 +106,7 @@ func (p *NatsEventPublisher) PublishCancel(orderID int64, reason string) error {
 		"timestamp": time.Now().UnixMilli(),
 	}
 	data, _ := json.Marshal(event)
-	return p.publisher.PublishRaw("order.canceled", data)
+	return p.publisher.PublishWithID("order.canceled", fmt.Sprintf("cancel_%d", orderID), data)
 }
 
 // Close 关闭发布器
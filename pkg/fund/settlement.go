@@ -0,0 +1,41 @@
+// 文件: pkg/fund/settlement.go
+// 冷资产模块 - 合约交割落账
+//
+// 交割把保证金 + 盈亏一次性返还到可用余额。每个 (合约, 到期时间, 用户) 一条流水，
+// EventID = settle_{symbol}_{expiry}_{user}，走 ApplyOnce：交割中途失败重跑时
+// 已返还的用户被流水唯一索引挡住，不会重复入账；交割明细与流水同一事务写入。
+
+package fund
+
+import (
+	"context"
+	"fmt"
+)
+
+// SettlementEventID 交割流水幂等键
+func SettlementEventID(symbol string, expiry, userID int64) string {
+	return fmt.Sprintf("settle_%s_%d_%d", symbol, expiry, userID)
+}
+
+// ApplySettlement 交割返还落账 (幂等)
+//
+// amount 为返还金额 (>= 0，穿仓时为 0 也会写流水，标记该用户已交割)；
+// detail 为可选的交割明细，与流水同一事务写入
+func (r *BalanceRepo) ApplySettlement(
+	ctx context.Context,
+	userID int64,
+	currency, symbol string,
+	expiry, amount int64,
+	detail any,
+) (applied int64, duplicate bool, err error) {
+	return r.ApplyOnce(ctx, IdempotentChange{
+		UserID:     userID,
+		Currency:   currency,
+		Amount:     amount,
+		EventID:    SettlementEventID(symbol, expiry, userID),
+		ChangeType: ChangeTypeSettlement,
+		BizType:    BizTypeSettlement,
+		BizID:      fmt.Sprintf("%s_%d", symbol, expiry),
+		Record:     detail,
+	})
+}
@@ -0,0 +1,277 @@
+// 文件: pkg/fund/settlement_lag.go
+// 冷资产模块 - 成交结算延迟监控
+//
+// 【为什么需要】
+// 热路径 (撮合 -> FuturesProcessor) 成交后只是把事件发到 NATS，冷钱包由 NatsDBWriter 异步落库。
+// DB 变慢、消费者挂掉、NATS 堆积时热路径毫无感知，冷存储余额悄悄落后，
+// 直到对账或用户提现才暴露。
+//
+// 【做法】按交易对分别记录两端的进度：
+//   - 撮合端: ObserveMatched(symbol, tradeID, ts)，挂在 FuturesProcessor.OnEventHandled 上
+//   - 落库端: ObserveApplied(symbol, tradeID, ts)，NatsDBWriter 写完一笔成交后调用 (SetLagMonitor)
+//
+// 延迟两个维度：
+//   - Pending: 已撮合未落库的成交笔数
+//   - Delay:   最早一笔未落库成交被撮合端看到至今的时长 (用本监控的时钟，不依赖两端时钟对齐)
+//
+// 【面试】为什么不直接用 "最新撮合时间 - 最新落库时间"？
+// 写入端卡死后不再有新成交时，这个差值不会增长，恰好在最需要报警的时候失灵；
+// 用 "最早未落库成交的等待时长" 卡死时会随时间持续增长。
+//
+// 【注意】
+//   - 成交 ID 在同一交易对内单调递增 (见 mtrade.Matcher.nextTradeID)，落库进度取已落库的最大 ID
+//   - 未落库成交按 lagBucket 合并成桶记录，积压再多内存也只和积压时长成正比
+
+package fund
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lagBucket 未落库成交的合并粒度，Delay 的精度也是这个量级
+const lagBucket = 100 * time.Millisecond
+
+// LagThreshold 报警阈值，字段为 0 表示不检查该维度
+type LagThreshold struct {
+	MaxPending int64         // 未落库成交笔数上限
+	MaxDelay   time.Duration // 最早未落库成交等待时长上限
+}
+
+// SettlementLag 单个交易对的结算延迟指标
+type SettlementLag struct {
+	Symbol        string        `json:"symbol"`
+	MatchedSeq    int64         `json:"matched_seq"`     // 撮合端最新成交 ID
+	MatchedTime   int64         `json:"matched_time"`    // 撮合端最新成交时间 (成交时间戳，纳秒)
+	AppliedSeq    int64         `json:"applied_seq"`     // 已落库的最大成交 ID
+	AppliedTime   int64         `json:"applied_time"`    // 对应成交的时间戳 (纳秒)
+	Pending       int64         `json:"pending"`         // 已撮合未落库笔数
+	Delay         time.Duration `json:"delay_ns"`        // 最早未落库成交的等待时长
+	MatchedTotal  int64         `json:"matched_total"`   // 监控启动以来撮合端成交笔数
+	AppliedTotal  int64         `json:"applied_total"`   // 监控启动以来落库笔数
+	LastAppliedAt int64         `json:"last_applied_at"` // 最近一次落库的本地时间 (毫秒)
+}
+
+// LagAlert 延迟报警
+//
+// 越过阈值时触发一次；回落到阈值以内时再触发一次 Recovered=true，
+// 持续超阈值期间不重复触发
+type LagAlert struct {
+	Lag       SettlementLag
+	Threshold LagThreshold
+	Recovered bool
+}
+
+// lagPending 一段时间内撮合、尚未落库的成交
+type lagPending struct {
+	lastSeq int64 // 桶内最大成交 ID
+	firstAt int64 // 桶内第一笔被撮合端看到的本地时间 (纳秒)
+	count   int64
+}
+
+// symbolLag 单个交易对的两端进度
+type symbolLag struct {
+	SettlementLag
+	pending  []lagPending // 按成交 ID 递增
+	alerting bool
+}
+
+// SettlementLagMonitor 成交结算延迟监控
+type SettlementLagMonitor struct {
+	mu        sync.Mutex
+	symbols   map[string]*symbolLag
+	threshold LagThreshold
+	onAlert   []func(LagAlert)
+	now       func() time.Time
+
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSettlementLagMonitor 创建结算延迟监控
+func NewSettlementLagMonitor(threshold LagThreshold) *SettlementLagMonitor {
+	return &SettlementLagMonitor{
+		symbols:   make(map[string]*symbolLag),
+		threshold: threshold,
+		now:       time.Now,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetClock 替换时钟，积压时长 (Delay) 按它计算
+func (m *SettlementLagMonitor) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+// OnAlert 注册报警回调 (在 Check 的调用协程里同步执行，不要阻塞)
+func (m *SettlementLagMonitor) OnAlert(fn func(LagAlert)) {
+	m.onAlert = append(m.onAlert, fn)
+}
+
+// ObserveMatched 撮合端产生一笔成交
+func (m *SettlementLagMonitor) ObserveMatched(symbol string, tradeID, ts int64) {
+	now := m.now().UnixNano()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.symbol(symbol)
+	s.MatchedTotal++
+	if tradeID > s.MatchedSeq {
+		s.MatchedSeq, s.MatchedTime = tradeID, ts
+	}
+	if tradeID <= s.AppliedSeq {
+		return // 落库端先到 (事件乱序)，不算积压
+	}
+	if n := len(s.pending); n > 0 && now-s.pending[n-1].firstAt < int64(lagBucket) {
+		last := &s.pending[n-1]
+		last.lastSeq = max(last.lastSeq, tradeID)
+		last.count++
+		return
+	}
+	s.pending = append(s.pending, lagPending{lastSeq: tradeID, firstAt: now, count: 1})
+}
+
+// ObserveApplied 落库端写完一笔成交
+func (m *SettlementLagMonitor) ObserveApplied(symbol string, tradeID, ts int64) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.symbol(symbol)
+	s.AppliedTotal++
+	s.LastAppliedAt = now.UnixMilli()
+	if tradeID <= s.AppliedSeq {
+		return
+	}
+	s.AppliedSeq, s.AppliedTime = tradeID, ts
+
+	drop := 0
+	for drop < len(s.pending) && s.pending[drop].lastSeq <= tradeID {
+		drop++
+	}
+	s.pending = s.pending[drop:]
+}
+
+// symbol 取交易对进度，不存在时创建 (调用方持锁)
+func (m *SettlementLagMonitor) symbol(symbol string) *symbolLag {
+	s, ok := m.symbols[symbol]
+	if !ok {
+		s = &symbolLag{SettlementLag: SettlementLag{Symbol: symbol}}
+		m.symbols[symbol] = s
+	}
+	return s
+}
+
+// lagOf 计算当前指标 (调用方持锁)
+func (s *symbolLag) lagOf(now int64) SettlementLag {
+	lag := s.SettlementLag
+	for _, p := range s.pending {
+		lag.Pending += p.count
+	}
+	if len(s.pending) > 0 {
+		lag.Delay = time.Duration(now - s.pending[0].firstAt)
+	}
+	return lag
+}
+
+// Lag 查询单个交易对的延迟
+func (m *SettlementLagMonitor) Lag(symbol string) (SettlementLag, bool) {
+	now := m.now().UnixNano()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.symbols[symbol]
+	if !ok {
+		return SettlementLag{}, false
+	}
+	return s.lagOf(now), true
+}
+
+// Snapshot 所有交易对的延迟指标 (按交易对排序)，供监控采集
+func (m *SettlementLagMonitor) Snapshot() []SettlementLag {
+	now := m.now().UnixNano()
+
+	m.mu.Lock()
+	list := make([]SettlementLag, 0, len(m.symbols))
+	for _, s := range m.symbols {
+		list = append(list, s.lagOf(now))
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	return list
+}
+
+// exceeded 是否超过阈值
+func (t LagThreshold) exceeded(lag SettlementLag) bool {
+	return (t.MaxPending > 0 && lag.Pending > t.MaxPending) ||
+		(t.MaxDelay > 0 && lag.Delay > t.MaxDelay)
+}
+
+// Check 检查所有交易对，越过 / 回落阈值时触发报警，返回本次触发的报警
+//
+// 【注意】Delay 在写入端卡死时只随时间增长、没有事件驱动，所以需要定时调用 (见 Start)
+func (m *SettlementLagMonitor) Check() []LagAlert {
+	now := m.now().UnixNano()
+
+	var alerts []LagAlert
+	m.mu.Lock()
+	for _, s := range m.symbols {
+		lag := s.lagOf(now)
+		over := m.threshold.exceeded(lag)
+		if over == s.alerting {
+			continue
+		}
+		s.alerting = over
+		alerts = append(alerts, LagAlert{Lag: lag, Threshold: m.threshold, Recovered: !over})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Lag.Symbol < alerts[j].Lag.Symbol })
+	for _, a := range alerts {
+		for _, fn := range m.onAlert {
+			fn(a)
+		}
+	}
+	return alerts
+}
+
+// Start 按固定间隔检查
+func (m *SettlementLagMonitor) Start(interval time.Duration) error {
+	if m.running {
+		return errors.New("settlement lag monitor already running")
+	}
+	m.running = true
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ticker.C:
+				m.Check()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止检查
+func (m *SettlementLagMonitor) Stop() {
+	if !m.running {
+		return
+	}
+	close(m.stopCh)
+	m.wg.Wait()
+	m.running = false
+}
@@ -0,0 +1,132 @@
+package fund
+
+import (
+	"testing"
+	"time"
+)
+
+func newLagMonitor(threshold LagThreshold) (*SettlementLagMonitor, *manualClock, *[]LagAlert) {
+	m := NewSettlementLagMonitor(threshold)
+	clock := &manualClock{t: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	m.SetClock(clock.now)
+	alerts := new([]LagAlert)
+	m.OnAlert(func(a LagAlert) { *alerts = append(*alerts, a) })
+	return m, clock, alerts
+}
+
+func TestSettlementLag_Observe(t *testing.T) {
+	m, clock, _ := newLagMonitor(LagThreshold{})
+
+	if _, ok := m.Lag("BTC_USDT"); ok {
+		t.Fatal("unknown symbol should not exist")
+	}
+
+	// 同一个 lagBucket 内的成交合并成一桶，Delay 从桶内第一笔算起
+	m.ObserveMatched("BTC_USDT", 1, 1001)
+	clock.t = clock.t.Add(50 * time.Millisecond)
+	m.ObserveMatched("BTC_USDT", 2, 1002)
+	clock.t = clock.t.Add(200 * time.Millisecond)
+	m.ObserveMatched("BTC_USDT", 3, 1003)
+	clock.t = clock.t.Add(time.Second)
+
+	lag, ok := m.Lag("BTC_USDT")
+	if !ok {
+		t.Fatal("symbol missing")
+	}
+	if lag.Pending != 3 || lag.Delay != 1250*time.Millisecond || lag.MatchedSeq != 3 || lag.MatchedTime != 1003 || lag.MatchedTotal != 3 {
+		t.Fatalf("after matches: %+v", lag)
+	}
+
+	// 落库到 2：第一桶 (1, 2) 整体出队，Delay 变为第二桶的等待时长
+	m.ObserveApplied("BTC_USDT", 2, 1002)
+	lag, _ = m.Lag("BTC_USDT")
+	if lag.Pending != 1 || lag.Delay != time.Second || lag.AppliedSeq != 2 || lag.AppliedTime != 1002 ||
+		lag.LastAppliedAt != clock.t.UnixMilli() {
+		t.Fatalf("after apply 2: %+v", lag)
+	}
+
+	// 重复 / 更旧的落库只计数，不回退进度
+	m.ObserveApplied("BTC_USDT", 1, 1001)
+	if lag, _ = m.Lag("BTC_USDT"); lag.AppliedSeq != 2 || lag.AppliedTotal != 2 || lag.Pending != 1 {
+		t.Fatalf("after stale apply: %+v", lag)
+	}
+
+	// 落库端先于撮合端看到 (事件乱序)：不算积压
+	m.ObserveApplied("BTC_USDT", 5, 1005)
+	m.ObserveMatched("BTC_USDT", 4, 1004)
+	m.ObserveMatched("BTC_USDT", 5, 1005)
+	lag, _ = m.Lag("BTC_USDT")
+	if lag.Pending != 0 || lag.Delay != 0 || lag.MatchedSeq != 5 || lag.AppliedSeq != 5 {
+		t.Fatalf("after out-of-order: %+v", lag)
+	}
+
+	// 交易对之间互不影响，Snapshot 按交易对排序
+	m.ObserveMatched("ETH_USDT", 1, 2001)
+	snap := m.Snapshot()
+	if len(snap) != 2 || snap[0].Symbol != "BTC_USDT" || snap[1].Symbol != "ETH_USDT" || snap[1].Pending != 1 {
+		t.Fatalf("snapshot %+v", snap)
+	}
+}
+
+func TestSettlementLag_CheckThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		threshold LagThreshold
+		matched   int64
+		wait      time.Duration
+		over      bool
+	}{
+		{"pending at limit", LagThreshold{MaxPending: 3}, 3, 0, false},
+		{"pending over limit", LagThreshold{MaxPending: 3}, 4, 0, true},
+		{"delay at limit", LagThreshold{MaxDelay: time.Second}, 1, time.Second, false},
+		{"delay over limit", LagThreshold{MaxDelay: time.Second}, 1, time.Second + time.Millisecond, true},
+		{"zero threshold disabled", LagThreshold{}, 100, time.Hour, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, clock, _ := newLagMonitor(tc.threshold)
+			for id := int64(1); id <= tc.matched; id++ {
+				m.ObserveMatched("BTC_USDT", id, id)
+			}
+			clock.t = clock.t.Add(tc.wait)
+			if got := len(m.Check()) == 1; got != tc.over {
+				t.Errorf("alert = %v, want %v", got, tc.over)
+			}
+		})
+	}
+}
+
+// TestSettlementLag_StalledWriter 写入端卡死后没有新事件，Delay 随时钟增长触发报警，追上后报恢复
+func TestSettlementLag_StalledWriter(t *testing.T) {
+	m, clock, alerts := newLagMonitor(LagThreshold{MaxDelay: 5 * time.Second})
+
+	m.ObserveMatched("BTC_USDT", 1, 1)
+	m.ObserveMatched("ETH_USDT", 1, 1)
+	m.ObserveApplied("ETH_USDT", 1, 1)
+
+	clock.t = clock.t.Add(3 * time.Second)
+	if got := m.Check(); len(got) != 0 {
+		t.Fatalf("below threshold: %+v", got)
+	}
+
+	clock.t = clock.t.Add(3 * time.Second)
+	got := m.Check()
+	if len(got) != 1 || got[0].Recovered || got[0].Lag.Symbol != "BTC_USDT" || got[0].Lag.Delay != 6*time.Second ||
+		got[0].Threshold.MaxDelay != 5*time.Second {
+		t.Fatalf("expected stall alert, got %+v", got)
+	}
+
+	// 持续超阈值不重复报
+	clock.t = clock.t.Add(time.Minute)
+	if got := m.Check(); len(got) != 0 {
+		t.Fatalf("repeated alert: %+v", got)
+	}
+
+	m.ObserveApplied("BTC_USDT", 1, 1)
+	got = m.Check()
+	if len(got) != 1 || !got[0].Recovered || got[0].Lag.Pending != 0 {
+		t.Fatalf("expected recovery, got %+v", got)
+	}
+	if len(*alerts) != 2 {
+		t.Errorf("OnAlert saw %d alerts, want 2", len(*alerts))
+	}
+}
@@ -0,0 +1,43 @@
+// 文件: pkg/fund/social_loss.go
+// 冷资产模块 - 穿仓分摊落账
+//
+// 保险基金不足、又没有 ADL 时，穿仓亏损在结算周期末按盈利比例从盈利持仓扣回。
+// 每个 (合约, 周期, 用户) 一条流水，EventID = socloss_{symbol}_{cycle}_{user}，走 ApplyOnce：
+// 分摊中途失败重跑时已扣回的用户被流水唯一索引挡住，不会重复扣款。
+//
+// 【注意】扣款按可用余额截断 (最多扣到 0)，扣不到的部分由上层计入下一周期
+
+package fund
+
+import (
+	"context"
+	"fmt"
+)
+
+// SocialLossEventID 穿仓分摊流水幂等键
+func SocialLossEventID(symbol string, cycle, userID int64) string {
+	return fmt.Sprintf("socloss_%s_%d_%d", symbol, cycle, userID)
+}
+
+// ApplySocialLoss 穿仓分摊扣款 (幂等)
+//
+// amount 为扣款金额 (> 0)，按可用余额截断。
+// 返回实际扣款金额 (正数)；这一笔已经落过账时 duplicate = true，deducted 为当时的扣款金额
+func (r *BalanceRepo) ApplySocialLoss(
+	ctx context.Context,
+	userID int64,
+	currency, symbol string,
+	cycle, amount int64,
+) (deducted int64, duplicate bool, err error) {
+	applied, duplicate, err := r.ApplyOnce(ctx, IdempotentChange{
+		UserID:          userID,
+		Currency:        currency,
+		Amount:          -amount,
+		ClipToAvailable: true,
+		EventID:         SocialLossEventID(symbol, cycle, userID),
+		ChangeType:      ChangeTypeSocialLoss,
+		BizType:         BizTypeSocialLoss,
+		BizID:           fmt.Sprintf("%s_%d", symbol, cycle),
+	})
+	return -applied, duplicate, err
+}
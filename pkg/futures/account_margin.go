@@ -0,0 +1,166 @@
+// 文件: pkg/futures/account_margin.go
+// 开仓可用保证金
+//
+// 【问题】开仓只看冷钱包 Available，持仓浮亏不影响下单：
+// 仓位亏到快强平了，用户还能拿账面上的余额继续开新仓
+//
+// 【做法】按账户权益计算可用于新订单的保证金：
+//
+//	钱包余额 = 冷钱包可用 + 持仓保证金 + 挂单保证金
+//	权益     = 钱包余额 + 未实现盈亏 (标记价格，经 RiskCalculator) + 抵押品价值 (见 collateral.go)
+//	可开仓   = 权益 − 持仓保证金 − 挂单保证金
+//
+// 浮盈不能用来开仓 (冻结的是冷钱包里的钱)，可开仓再以冷钱包可用为上限，
+// 效果上等于 可用 + min(未实现盈亏 + 抵押品价值, 0)：抵押品只用来吸收浮亏
+//
+// 【fail-closed】查不到合约规格、持仓没有标记价格时返回错误，开仓因此被拒，
+// 不能按 USDT / 开仓价猜：猜错币种或把浮亏当 0 都会让可开仓虚高
+//
+// 【范围】只统计结算币种相同的持仓；挂单只统计本处理器的订单元数据，
+// 其他处理器的挂单保证金已经从冷钱包可用里冻结，不影响结果
+
+package futures
+
+import (
+	"context"
+	"fmt"
+)
+
+// AccountMargin 账户保证金概况 (单一结算币种)
+type AccountMargin struct {
+	Currency string
+
+	Available       int64 // 冷钱包可用
+	PositionMargin  int64 // 持仓占用保证金
+	OrderMargin     int64 // 挂单冻结保证金 (未成交部分)
+	UnrealizedPnL   int64 // 未实现盈亏合计
+	CollateralValue int64 // 其他币种抵押品折算价值 (已折价，未配置抵押品服务时为 0)
+
+	Balance            int64 // 钱包余额
+	Equity             int64 // 权益 = 钱包余额 + 未实现盈亏
+	AvailableForOrders int64 // 可用于新订单的保证金
+}
+
+// AccountMargin 计算用户在结算币种 currency 下的保证金概况
+func (p *FuturesProcessor) AccountMargin(ctx context.Context, userID int64, currency string) (*AccountMargin, error) {
+	m := &AccountMargin{Currency: currency}
+
+	balance, err := p.balanceRepo.GetBalance(ctx, userID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if balance != nil {
+		m.Available = balance.Available
+	}
+
+	// 持仓：保证金 + 按标记价格的未实现盈亏
+	positions, err := p.positionRepo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pos := range positions {
+		if pos == nil || pos.Size == 0 {
+			continue
+		}
+		spec, err := p.contractManager.GetContract(ctx, pos.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %w", pos.Symbol, err)
+		}
+		if settleCurrency(spec) != currency {
+			continue
+		}
+		m.PositionMargin += pos.Margin
+
+		// 没有标记价格就算不出浮亏，按开仓价估会把浮亏当成 0 放行开仓
+		markPrice := p.markPriceService.GetMarkPrice(pos.Symbol)
+		if markPrice <= 0 {
+			return nil, ErrNoMarkPrice.Wrapf("%s", pos.Symbol)
+		}
+		if risk := p.riskCalculator.CalculatePositionRiskWithSpec(spec, pos, markPrice, 0); risk != nil {
+			m.UnrealizedPnL += risk.UnrealizedPnL
+		}
+	}
+
+	// 挂单：开仓单冻结但尚未转入持仓的部分 (平仓单不冻结保证金)
+	specs := make(map[string]*ContractSpec)
+	p.orderMetas.Range(func(_, val any) bool {
+		meta := val.(*OrderMeta)
+		if meta.UserID != userID || meta.IsClose {
+			return true
+		}
+		spec, ok := specs[meta.Symbol]
+		if !ok {
+			if spec, err = p.contractManager.GetContract(ctx, meta.Symbol); err != nil {
+				err = fmt.Errorf("contract %s: %w", meta.Symbol, err)
+				return false
+			}
+			specs[meta.Symbol] = spec
+		}
+		if settleCurrency(spec) == currency {
+			m.OrderMargin += max(meta.Margin-meta.MarginUsed, 0)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m.CollateralValue, err = collateralValue(ctx, p.collateral, userID, currency); err != nil {
+		return nil, err
+	}
+
+	m.Balance = m.Available + m.PositionMargin + m.OrderMargin
+	m.Equity = m.Balance + m.UnrealizedPnL + m.CollateralValue
+	m.AvailableForOrders = max(min(m.Equity-m.PositionMargin-m.OrderMargin, m.Available), 0)
+	return m, nil
+}
+
+// PositionValue 一个持仓的保证金与未实现盈亏 (结算币种)
+type PositionValue struct {
+	Symbol        string
+	Settle        string // 结算币种
+	Size          int64  // 正=多, 负=空
+	MarkPrice     int64  // 估值用的标记价格 (无标记价格时为开仓价)
+	Margin        int64
+	UnrealizedPnL int64
+}
+
+// PositionValues 用户全部持仓的保证金与按标记价格的未实现盈亏 (资产估值用，见 pkg/valuation)
+//
+// 与 AccountMargin 口径一致，只是不按结算币种过滤、不汇总；
+// 查不到合约规格时返回错误 (不知道结算币种和正反向，估出来的数是错的)
+func (p *FuturesProcessor) PositionValues(ctx context.Context, userID int64) ([]PositionValue, error) {
+	positions, err := p.positionRepo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out []PositionValue
+	for _, pos := range positions {
+		if pos == nil || pos.Size == 0 {
+			continue
+		}
+		spec, err := p.contractManager.GetContract(ctx, pos.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %w", pos.Symbol, err)
+		}
+		v := PositionValue{Symbol: pos.Symbol, Settle: settleCurrency(spec), Size: pos.Size, Margin: pos.Margin}
+
+		v.MarkPrice = p.markPriceService.GetMarkPrice(pos.Symbol)
+		if v.MarkPrice == 0 {
+			v.MarkPrice = pos.EntryPrice // 无标记价格时用开仓价
+		}
+		if risk := p.riskCalculator.CalculatePositionRiskWithSpec(spec, pos, v.MarkPrice, 0); risk != nil {
+			v.UnrealizedPnL = risk.UnrealizedPnL
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// settleCurrency 合约结算币种，spec 为空时按 USDT (调用方须先确认合约存在)
+func settleCurrency(spec *ContractSpec) string {
+	if spec == nil {
+		return "USDT"
+	}
+	return spec.SettleCurrency
+}
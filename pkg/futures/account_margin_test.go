@@ -0,0 +1,134 @@
+// 文件: pkg/futures/account_margin_test.go
+// 开仓可用保证金测试 (内存夹具，见 harness_test.go)
+
+package futures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/mtrade"
+)
+
+func TestHarness_AvailableForOrdersDeductsUnrealizedLoss(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+	h.ledger.AddAvailable(h.ctx, 2, "USDT", 10000*Precision)
+
+	// 1 BTC @ 50000，10 倍，各占 5000 保证金
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 2, Symbol: symbol, Side: SideShort, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	h.waitFor(symbol, mtrade.EventTrade, 1)
+
+	// 标记价格跌到 46000：多头浮亏 4000，可开仓 = 5000 − 4000
+	proc.UpdateMarkPrice(symbol, 46000*Precision)
+	m, err := proc.AccountMargin(h.ctx, 1, "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000*Precision), m.Available)
+	assert.Equal(t, int64(5000*Precision), m.PositionMargin)
+	assert.Equal(t, int64(-4000*Precision), m.UnrealizedPnL)
+	assert.Equal(t, int64(6000*Precision), m.Equity)
+	assert.Equal(t, int64(1000*Precision), m.AvailableForOrders)
+
+	// 冷钱包还有 5000，但 1500 的新单超出可开仓
+	assert.ErrorIs(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: 3 * Precision / 10, Price: 50000 * Precision, Leverage: 10,
+	}), ErrInsufficientMargin)
+
+	// 500 的挂单可以下，计入挂单保证金
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision / 10, Price: 50000 * Precision, Leverage: 10,
+	}))
+	m, err = proc.AccountMargin(h.ctx, 1, "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(500*Precision), m.OrderMargin)
+	assert.Equal(t, int64(500*Precision), m.AvailableForOrders)
+
+	// 空头浮盈 4000 不能用来开仓，可开仓以冷钱包可用为上限
+	m, err = proc.AccountMargin(h.ctx, 2, "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4000*Precision), m.UnrealizedPnL)
+	assert.Equal(t, int64(5000*Precision), m.AvailableForOrders)
+}
+
+func TestHarness_AccountMarginFailsClosed(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+	h.ledger.AddAvailable(h.ctx, 2, "USDT", 10000*Precision)
+
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 2, Symbol: symbol, Side: SideShort, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	h.waitFor(symbol, mtrade.EventTrade, 1)
+
+	// 有持仓但没有标记价格：算不出浮亏，拒绝开仓且不冻结
+	_, err := proc.AccountMargin(h.ctx, 1, "USDT")
+	assert.ErrorIs(t, err, ErrNoMarkPrice)
+	assert.ErrorIs(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision / 10, Price: 50000 * Precision, Leverage: 10,
+	}), ErrNoMarkPrice)
+	balance, _ := h.ledger.GetBalance(h.ctx, 1, "USDT")
+	assert.Equal(t, int64(5000*Precision), balance.Available)
+
+	// 其他结算币种不需要这个合约的标记价格
+	_, err = proc.AccountMargin(h.ctx, 1, "BTC")
+	assert.NoError(t, err)
+
+	proc.UpdateMarkPrice(symbol, 50000*Precision)
+	_, err = proc.AccountMargin(h.ctx, 1, "USDT")
+	require.NoError(t, err)
+
+	// 查不到合约规格：不按 USDT 猜，直接报错
+	require.NoError(t, h.contracts.repo.Delete(h.ctx, symbol))
+	_, err = proc.AccountMargin(h.ctx, 1, "USDT")
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+}
+
+func TestHarness_PositionValues(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+	h.ledger.AddAvailable(h.ctx, 2, "USDT", 10000*Precision)
+
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 2, Symbol: symbol, Side: SideShort, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	h.waitFor(symbol, mtrade.EventTrade, 1)
+
+	// 没有标记价格：按开仓价估值，浮盈亏为 0
+	values, err := proc.PositionValues(h.ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+	assert.Equal(t, PositionValue{Symbol: symbol, Settle: "USDT", Size: -Precision, MarkPrice: 50000 * Precision, Margin: 5000 * Precision}, values[0])
+
+	proc.UpdateMarkPrice(symbol, 46000*Precision)
+	values, err = proc.PositionValues(h.ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4000*Precision), values[0].UnrealizedPnL)
+
+	values, err = proc.PositionValues(h.ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	// 合约规格查不到：报错，不按 USDT 正向合约猜
+	require.NoError(t, h.contracts.repo.Delete(h.ctx, symbol))
+	_, err = proc.PositionValues(h.ctx, 2)
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+}
@@ -0,0 +1,82 @@
+// 文件: pkg/futures/audit.go
+// 合约侧审计：开仓/平仓下单、撤单、强平、保险基金管理操作
+//
+// 审计记录器都是可选的，未设置时不记录
+
+package futures
+
+import (
+	"fmt"
+	"strconv"
+
+	"max.com/pkg/audit"
+)
+
+// SetAuditor 设置审计记录器
+func (p *FuturesProcessor) SetAuditor(auditor audit.Recorder) {
+	p.auditor = auditor
+}
+
+// SetAuditor 设置审计记录器
+func (e *LiquidationExecutor) SetAuditor(auditor audit.Recorder) {
+	e.auditor = auditor
+}
+
+// SetAuditor 设置审计记录器 (记录管理员注资/提取)
+func (f *InsuranceFund) SetAuditor(auditor audit.Recorder) {
+	f.auditor = auditor
+}
+
+// auditOrder 记录下单 / 撤单
+func (p *FuturesProcessor) auditOrder(action audit.Action, userID, orderID int64, after any, meta map[string]string) {
+	if p.auditor == nil {
+		return
+	}
+	p.auditor.Record(audit.Event{
+		ActorType: audit.ActorUser,
+		ActorID:   userID,
+		Action:    action,
+		Resource:  orderResource(orderID),
+		After:     after,
+		Meta:      meta,
+	})
+}
+
+// auditLiquidation 记录强平单提交：Before 为强平前持仓，After 为强平单
+func (e *LiquidationExecutor) auditLiquidation(plan *liquidationPlan, orderID int64) {
+	if e.auditor == nil {
+		return
+	}
+	e.auditor.Record(audit.Event{
+		ActorType: audit.ActorSystem,
+		Action:    audit.ActionLiquidation,
+		Resource:  fmt.Sprintf("position:%d:%s", plan.task.UserID, plan.task.Symbol),
+		Before:    plan.pos,
+		After:     plan.order,
+		Meta: map[string]string{
+			"order_id":       strconv.FormatInt(orderID, 10),
+			"mark_price":     strconv.FormatInt(plan.markPrice, 10),
+			"bankrupt_price": strconv.FormatInt(plan.bankruptPrice, 10),
+		},
+	})
+}
+
+// auditInsurance 记录保险基金管理操作
+func (f *InsuranceFund) auditInsurance(operatorID int64, changeType, currency string, before, after int64, remark string) {
+	if f.auditor == nil {
+		return
+	}
+	f.auditor.Record(audit.Event{
+		ActorType: audit.ActorAdmin,
+		ActorID:   operatorID,
+		Action:    audit.ActionAdmin,
+		Resource:  "insurance_fund:" + currency,
+		Before:    map[string]int64{"balance": before},
+		After:     map[string]int64{"balance": after},
+		Meta:      map[string]string{"op": changeType, "remark": remark},
+	})
+}
+
+func orderResource(orderID int64) string {
+	return "order:" + strconv.FormatInt(orderID, 10)
+}
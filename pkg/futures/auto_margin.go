@@ -0,0 +1,419 @@
+// 文件: pkg/futures/auto_margin.go
+// 自动追加保证金 (用户主动开启)
+//
+// 【问题】行情急跌时用户来不及手动加保证金，风险率一路走到强平，被收强平手续费、按不利价格平掉；
+// 钱包里明明还有可用余额
+//
+// 【做法】订阅强平引擎的风险等级变化 (liquidation.Engine.OnLevelChange)：
+// 用户升到 TriggerLevel (默认 DANGER) 时，对开启了自动追加的每个持仓，
+// 从冷钱包可用余额冻结一笔转入持仓保证金：
+//
+//	追加金额 = min(固定金额 或 可用余额 × 比例, 本轮剩余额度, 可用余额)
+//
+// 每轮持仓 (开仓到清零) 累计追加不超过用户设置的 MaxTotal；
+// 同一持仓两次追加至少间隔 Cooldown，避免在等级边界来回抖动时连续扣款。
+// 每次追加 (以及因余额不足 / 额度用完没追加成) 都回调 OnTopUp，由通知服务推给用户
+//
+// 【资金流向】与开仓冻结一致：可用 → 冻结 (fund.FreezeWithJournal，流水 BizType AUTO_MARGIN)，
+// 持仓 Margin 同额增加；平仓时随持仓保证金一起释放
+//
+// 【注意】
+//   - 等级变化回调在强平检查器 goroutine 里同步执行，这里只入队，IO 在工作协程里做；队列满丢弃并计数
+//   - 追加写的是持仓单列 (PositionRepository.AddMargin)，和资金费一样不覆盖整行；
+//     撮合回调读改写持仓的窗口内追加会被覆盖，冻结和持仓保证金的差额由对账发现
+//   - 冻结成功、写持仓前进程崩溃同样留下差额：幂等键按等级变化时间生成，重放同一次变化不会重复冻结
+//   - 强平等级 (LIQUIDATE) 不追加：强平任务已经入队，这时加钱只会被强平一起吃掉
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+	"max.com/pkg/liquidation"
+)
+
+var (
+	ErrInvalidAutoMargin     = cexerr.New("FUTURES_INVALID_AUTO_MARGIN", cexerr.CategoryInvalidArgument, "invalid auto margin setting")
+	ErrAutoMarginCapReached  = cexerr.New("FUTURES_AUTO_MARGIN_CAP_REACHED", cexerr.CategoryFailedPrecondition, "auto margin cap reached")
+	ErrAutoMarginNoAvailable = cexerr.New("FUTURES_AUTO_MARGIN_NO_AVAILABLE", cexerr.CategoryInsufficientFunds, "no available balance for auto margin")
+)
+
+// =============================================================================
+// 用户设置
+// =============================================================================
+
+// AutoMarginSetting 用户对某合约持仓的自动追加设置
+type AutoMarginSetting struct {
+	UserID  int64  `gorm:"column:user_id;primaryKey" json:"user_id"`
+	Symbol  string `gorm:"column:symbol;primaryKey" json:"symbol"`
+	Enabled bool   `gorm:"column:enabled" json:"enabled"`
+
+	// 每次追加金额：Amount > 0 时按固定金额，否则按可用余额的 PercentBps (万分比)
+	Amount     int64 `gorm:"column:amount" json:"amount"`
+	PercentBps int64 `gorm:"column:percent_bps" json:"percent_bps"`
+
+	// MaxTotal 每轮持仓累计自动追加上限 (必填)
+	MaxTotal int64 `gorm:"column:max_total" json:"max_total"`
+
+	// 本轮已追加，UsedCycle 与持仓 OpenedAt 不一致时视为 0 (新一轮持仓)
+	Used      int64 `gorm:"column:used" json:"used"`
+	UsedCycle int64 `gorm:"column:used_cycle" json:"used_cycle"`
+
+	UpdatedAt int64 `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (AutoMarginSetting) TableName() string {
+	return "auto_margin_settings"
+}
+
+// Validate 校验用户设置：金额二选一，上限必填
+func (s *AutoMarginSetting) Validate() error {
+	switch {
+	case s.UserID <= 0 || s.Symbol == "":
+		return ErrInvalidAutoMargin.Wrapf("user and symbol required")
+	case s.Amount < 0 || s.PercentBps < 0 || s.PercentBps > fixed.BpsScale:
+		return ErrInvalidAutoMargin.Wrapf("amount must be non-negative, percent in [0, 10000] bps")
+	case (s.Amount > 0) == (s.PercentBps > 0):
+		return ErrInvalidAutoMargin.Wrapf("exactly one of amount and percent must be set")
+	case s.MaxTotal <= 0:
+		return ErrInvalidAutoMargin.Wrapf("max total must be positive")
+	}
+	return nil
+}
+
+// usedIn 本轮持仓已追加的金额
+func (s *AutoMarginSetting) usedIn(cycle int64) int64 {
+	if s.UsedCycle != cycle {
+		return 0
+	}
+	return s.Used
+}
+
+// AutoMarginSettingStore 设置存储
+type AutoMarginSettingStore interface {
+	// ListByUser 用户的全部设置 (含未开启的)
+	ListByUser(ctx context.Context, userID int64) ([]*AutoMarginSetting, error)
+
+	// Save 保存用户设置 (不改 Used / UsedCycle)
+	Save(ctx context.Context, s *AutoMarginSetting) error
+
+	// AddUsed 累加本轮已追加金额；cycle 与记录的不同时先清零 (原子操作，不覆盖用户设置)
+	AddUsed(ctx context.Context, userID int64, symbol string, cycle, amount int64) error
+}
+
+var _ AutoMarginSettingStore = (*MySQLAutoMarginSettingStore)(nil)
+
+// MySQLAutoMarginSettingStore MySQL 实现
+type MySQLAutoMarginSettingStore struct {
+	db *gorm.DB
+}
+
+// NewMySQLAutoMarginSettingStore 创建设置存储
+func NewMySQLAutoMarginSettingStore(db *gorm.DB) *MySQLAutoMarginSettingStore {
+	return &MySQLAutoMarginSettingStore{db: db}
+}
+
+func (r *MySQLAutoMarginSettingStore) ListByUser(ctx context.Context, userID int64) ([]*AutoMarginSetting, error) {
+	var list []*AutoMarginSetting
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("symbol ASC").Find(&list).Error
+	return list, err
+}
+
+func (r *MySQLAutoMarginSettingStore) Save(ctx context.Context, s *AutoMarginSetting) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	s.UpdatedAt = time.Now().UnixMilli()
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "amount", "percent_bps", "max_total", "updated_at"}),
+	}).Create(s).Error
+}
+
+func (r *MySQLAutoMarginSettingStore) AddUsed(ctx context.Context, userID int64, symbol string, cycle, amount int64) error {
+	return r.db.WithContext(ctx).
+		Model(&AutoMarginSetting{}).
+		Where("user_id = ? AND symbol = ?", userID, symbol).
+		Updates(map[string]interface{}{
+			"used":       gorm.Expr("IF(used_cycle = ?, used + ?, ?)", cycle, amount, amount),
+			"used_cycle": cycle,
+			"updated_at": time.Now().UnixMilli(),
+		}).Error
+}
+
+// =============================================================================
+// AutoMarginService
+// =============================================================================
+
+// AutoMarginConfig 自动追加配置
+type AutoMarginConfig struct {
+	TriggerLevel liquidation.RiskLevel // 升到该等级及以上 (不含强平) 时追加，默认 DANGER
+	Cooldown     time.Duration         // 同一持仓两次追加的最小间隔，默认 1 分钟
+	QueueSize    int                   // 等级变化队列长度，默认 1024
+}
+
+func (c AutoMarginConfig) withDefaults() AutoMarginConfig {
+	if c.TriggerLevel <= liquidation.RiskLevelSafe || c.TriggerLevel >= liquidation.RiskLevelLiquidate {
+		c.TriggerLevel = liquidation.RiskLevelDanger
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = time.Minute
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1024
+	}
+	return c
+}
+
+// AutoMarginEvent 一次自动追加的结果 (Err 非空表示没追加成)
+type AutoMarginEvent struct {
+	UserID       int64
+	Symbol       string
+	Currency     string
+	Amount       int64 // 实际追加金额
+	MarginBefore int64
+	MarginAfter  int64
+	Level        liquidation.RiskLevel
+	RiskRatio    float64
+	At           int64 // 触发的等级变化时间 (Unix 毫秒)
+	Err          error // ErrAutoMarginCapReached / ErrAutoMarginNoAvailable / 存储错误
+}
+
+// AutoMarginStats 运行统计
+type AutoMarginStats struct {
+	Received  int64 // 入队的等级变化
+	Dropped   int64 // 队列满丢弃
+	ToppedUp  int64 // 成功追加的次数
+	Failed    int64 // 没追加成 (余额不足 / 额度用完 / 存储错误)
+	Throttled int64 // 冷却期内跳过
+}
+
+// AutoMarginService 自动追加保证金服务
+type AutoMarginService struct {
+	cfg       AutoMarginConfig
+	settings  AutoMarginSettingStore
+	positions PositionRepository
+	contracts *ContractManager
+	ledger    MarginLedger
+	now       func() time.Time
+
+	queue    chan liquidation.LevelChange
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	lastAdd  map[autoMarginKey]time.Time // 只在工作协程里读写
+	onTopUp  []func(AutoMarginEvent)
+	received atomic.Int64
+	dropped  atomic.Int64
+	toppedUp atomic.Int64
+	failed   atomic.Int64
+	throttle atomic.Int64
+}
+
+// autoMarginKey 持仓 (用户, 合约)
+type autoMarginKey struct {
+	UserID int64
+	Symbol string
+}
+
+// NewAutoMarginService 创建自动追加服务
+//
+// 接线：
+//
+//	svc := futures.NewAutoMarginService(futures.AutoMarginConfig{}, settings, positionRepo, contractManager, balanceRepo)
+//	svc.OnTopUp(notifySvc.AutoMarginHandler())
+//	liquidationEngine.OnLevelChange(svc.Handler())
+//	svc.Start()
+func NewAutoMarginService(cfg AutoMarginConfig, settings AutoMarginSettingStore, positions PositionRepository, contracts *ContractManager, ledger MarginLedger) *AutoMarginService {
+	cfg = cfg.withDefaults()
+	return &AutoMarginService{
+		cfg:       cfg,
+		settings:  settings,
+		positions: positions,
+		contracts: contracts,
+		ledger:    ledger,
+		now:       time.Now,
+		queue:     make(chan liquidation.LevelChange, cfg.QueueSize),
+		lastAdd:   make(map[autoMarginKey]time.Time),
+	}
+}
+
+// SetClock 替换追加保证金冷却期的时钟，须在 Start 之前调用
+func (s *AutoMarginService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// OnTopUp 注册追加结果回调 (通知用户)，须在 Start 之前调用；回调在工作协程里同步执行
+func (s *AutoMarginService) OnTopUp(fn func(AutoMarginEvent)) {
+	s.onTopUp = append(s.onTopUp, fn)
+}
+
+// Handler 等级变化回调 (注册到 liquidation.Engine.OnLevelChange)，只入队不阻塞
+func (s *AutoMarginService) Handler() func(liquidation.LevelChange) {
+	return func(c liquidation.LevelChange) {
+		if !c.Escalated() || c.To < s.cfg.TriggerLevel || c.To >= liquidation.RiskLevelLiquidate {
+			return
+		}
+		select {
+		case s.queue <- c:
+			s.received.Add(1)
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Start 启动工作协程
+func (s *AutoMarginService) Start() {
+	s.stopCh = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case c := <-s.queue:
+				s.process(context.Background(), c)
+			}
+		}
+	}()
+}
+
+// Stop 停止工作协程，队列里未处理的等级变化丢弃 (下次升级会再触发)
+func (s *AutoMarginService) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// Stats 运行统计
+func (s *AutoMarginService) Stats() AutoMarginStats {
+	return AutoMarginStats{
+		Received:  s.received.Load(),
+		Dropped:   s.dropped.Load(),
+		ToppedUp:  s.toppedUp.Load(),
+		Failed:    s.failed.Load(),
+		Throttled: s.throttle.Load(),
+	}
+}
+
+// process 对用户开启了自动追加的每个持仓追加一次
+func (s *AutoMarginService) process(ctx context.Context, c liquidation.LevelChange) {
+	settings, err := s.settings.ListByUser(ctx, c.UserID)
+	if err != nil {
+		log.Printf("[AutoMargin] list settings user=%d: %v", c.UserID, err)
+		return
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Symbol < settings[j].Symbol })
+	for _, setting := range settings {
+		if !setting.Enabled {
+			continue
+		}
+		ev, ok := s.topUp(ctx, c, setting)
+		if !ok {
+			continue
+		}
+		if ev.Err != nil {
+			s.failed.Add(1)
+		} else {
+			s.toppedUp.Add(1)
+		}
+		for _, fn := range s.onTopUp {
+			fn(ev)
+		}
+	}
+}
+
+// topUp 追加一个持仓；ok 为 false 表示无需处理 (没有持仓 / 冷却中 / 重放)，不回调
+func (s *AutoMarginService) topUp(ctx context.Context, c liquidation.LevelChange, setting *AutoMarginSetting) (ev AutoMarginEvent, ok bool) {
+	key := autoMarginKey{UserID: c.UserID, Symbol: setting.Symbol}
+	ev = AutoMarginEvent{UserID: c.UserID, Symbol: setting.Symbol, Level: c.To, RiskRatio: c.RiskRatio, At: c.At}
+
+	pos, err := s.positions.GetByUserAndSymbol(ctx, c.UserID, setting.Symbol)
+	if err != nil {
+		ev.Err = err
+		return ev, true
+	}
+	if pos == nil || pos.Size == 0 {
+		return ev, false
+	}
+	now := s.now()
+	if last, seen := s.lastAdd[key]; seen && now.Sub(last) < s.cfg.Cooldown {
+		s.throttle.Add(1)
+		return ev, false
+	}
+	spec, err := s.contracts.GetContract(ctx, setting.Symbol)
+	if err != nil {
+		ev.Err = err
+		return ev, true
+	}
+	ev.Currency = settleCurrency(spec)
+	ev.MarginBefore, ev.MarginAfter = pos.Margin, pos.Margin
+
+	cycle := pos.OpenedAt
+	if cycle == 0 {
+		cycle = pos.CreatedAt // 新增 OpenedAt 之前开的仓
+	}
+	remaining := setting.MaxTotal - setting.usedIn(cycle)
+	if remaining <= 0 {
+		ev.Err = ErrAutoMarginCapReached
+		return ev, true
+	}
+	balance, err := s.ledger.GetBalance(ctx, c.UserID, ev.Currency)
+	if err != nil {
+		ev.Err = err
+		return ev, true
+	}
+	var available int64
+	if balance != nil {
+		available = balance.Available
+	}
+	amount := setting.Amount
+	if amount == 0 {
+		amount = fixed.Bps(available, setting.PercentBps)
+	}
+	amount = min(amount, remaining, available)
+	if amount <= 0 {
+		ev.Err = ErrAutoMarginNoAvailable
+		return ev, true
+	}
+
+	// 先冻结再写持仓：写持仓失败 (持仓刚被平掉) 时退回冻结
+	_, dup, err := s.ledger.FreezeWithJournal(ctx, c.UserID, ev.Currency, amount, marginAutoAddRef(c.UserID, setting.Symbol, c.At))
+	if err != nil {
+		ev.Err = err
+		return ev, true
+	}
+	if dup {
+		return ev, false // 同一次等级变化已经处理过
+	}
+	if err := s.positions.AddMargin(ctx, c.UserID, setting.Symbol, amount); err != nil {
+		if _, _, undoErr := s.ledger.UnfreezeWithJournal(ctx, c.UserID, ev.Currency, amount, marginAutoUndoRef(c.UserID, setting.Symbol, c.At)); undoErr != nil {
+			log.Printf("[AutoMargin] ERROR: undo freeze user=%d symbol=%s amount=%d: %v", c.UserID, setting.Symbol, amount, undoErr)
+		}
+		if errors.Is(err, ErrNoPosition) {
+			return ev, false
+		}
+		ev.Err = err
+		return ev, true
+	}
+	if err := s.settings.AddUsed(ctx, c.UserID, setting.Symbol, cycle, amount); err != nil {
+		// 资金已经划转，额度记账失败只会让下次多追加，记日志由人工处理
+		log.Printf("[AutoMargin] ERROR: record used user=%d symbol=%s amount=%d: %v", c.UserID, setting.Symbol, amount, err)
+	}
+	s.lastAdd[key] = now
+	ev.Amount = amount
+	ev.MarginAfter = pos.Margin + amount
+	log.Printf("[AutoMargin] user=%d symbol=%s added %d %s (level=%s riskRatio=%.4f)",
+		c.UserID, setting.Symbol, amount, ev.Currency, c.To, c.RiskRatio)
+	return ev, true
+}
@@ -0,0 +1,218 @@
+package futures
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/liquidation"
+)
+
+// memAutoMarginStore 内存设置存储
+type memAutoMarginStore struct {
+	mu       sync.Mutex
+	settings map[autoMarginKey]*AutoMarginSetting
+}
+
+func newMemAutoMarginStore(settings ...*AutoMarginSetting) *memAutoMarginStore {
+	s := &memAutoMarginStore{settings: make(map[autoMarginKey]*AutoMarginSetting)}
+	for _, st := range settings {
+		s.settings[autoMarginKey{st.UserID, st.Symbol}] = st
+	}
+	return s
+}
+
+func (s *memAutoMarginStore) ListByUser(ctx context.Context, userID int64) ([]*AutoMarginSetting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*AutoMarginSetting
+	for k, st := range s.settings {
+		if k.UserID == userID {
+			cp := *st
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memAutoMarginStore) Save(ctx context.Context, st *AutoMarginSetting) error {
+	if err := st.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *st
+	if old, ok := s.settings[autoMarginKey{st.UserID, st.Symbol}]; ok {
+		cp.Used, cp.UsedCycle = old.Used, old.UsedCycle
+	}
+	s.settings[autoMarginKey{st.UserID, st.Symbol}] = &cp
+	return nil
+}
+
+func (s *memAutoMarginStore) AddUsed(ctx context.Context, userID int64, symbol string, cycle, amount int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.settings[autoMarginKey{userID, symbol}]; ok {
+		st.Used = st.usedIn(cycle) + amount
+		st.UsedCycle = cycle
+	}
+	return nil
+}
+
+type autoMarginFixture struct {
+	svc       *AutoMarginService
+	ledger    *memLedger
+	positions *memPositionRepo
+	clock     *fakeClock
+	events    []AutoMarginEvent
+}
+
+func newAutoMarginFixture(settings ...*AutoMarginSetting) *autoMarginFixture {
+	spec := harnessLinearSpec()
+	f := &autoMarginFixture{
+		ledger: newMemLedger(),
+		positions: newMemPositionRepo(
+			&Position{ID: 1, UserID: 1, Symbol: spec.Symbol, Size: Precision, Margin: 100 * Precision, Leverage: 10, OpenedAt: 1},
+			&Position{ID: 2, UserID: 2, Symbol: spec.Symbol, Size: -Precision, Margin: 100 * Precision, Leverage: 10, OpenedAt: 1},
+		),
+		clock: newFakeClock(),
+	}
+	f.svc = NewAutoMarginService(AutoMarginConfig{}, newMemAutoMarginStore(settings...), f.positions, NewContractManager(newMemContractRepo(spec)), f.ledger)
+	f.svc.SetClock(f.clock.Now)
+	f.svc.OnTopUp(func(e AutoMarginEvent) { f.events = append(f.events, e) })
+	return f
+}
+
+func (f *autoMarginFixture) escalate(userID, at int64) {
+	f.svc.process(context.Background(), liquidation.LevelChange{UserID: userID, From: liquidation.RiskLevelWarning, To: liquidation.RiskLevelDanger, RiskRatio: 0.85, At: at})
+}
+
+func TestAutoMargin_FixedAmountWithCap(t *testing.T) {
+	symbol := harnessLinearSpec().Symbol
+	f := newAutoMarginFixture(&AutoMarginSetting{UserID: 1, Symbol: symbol, Enabled: true, Amount: 300 * Precision, MaxTotal: 500 * Precision})
+	require.NoError(t, f.ledger.AddAvailable(context.Background(), 1, "USDT", 1_000*Precision))
+
+	f.escalate(1, 1000)
+	require.Len(t, f.events, 1)
+	e := f.events[0]
+	require.NoError(t, e.Err)
+	assert.Equal(t, int64(300*Precision), e.Amount)
+	assert.Equal(t, "USDT", e.Currency)
+	assert.Equal(t, int64(100*Precision), e.MarginBefore)
+	assert.Equal(t, int64(400*Precision), e.MarginAfter)
+
+	pos, _ := f.positions.GetByUserAndSymbol(context.Background(), 1, symbol)
+	assert.Equal(t, int64(400*Precision), pos.Margin)
+	available, locked := f.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(700*Precision), available)
+	assert.Equal(t, int64(300*Precision), locked)
+	j := f.ledger.journal("fmargin_auto_1_" + symbol + "_1000")
+	require.NotNil(t, j)
+	assert.Equal(t, fund.BizTypeAutoMargin, j.BizType)
+
+	// 冷却期内再次升级：跳过，不通知
+	f.escalate(1, 2000)
+	assert.Len(t, f.events, 1)
+	assert.Equal(t, int64(1), f.svc.Stats().Throttled)
+
+	// 冷却过后只剩 200 额度
+	f.clock.Advance(2 * time.Minute)
+	f.escalate(1, 3000)
+	require.Len(t, f.events, 2)
+	assert.Equal(t, int64(200*Precision), f.events[1].Amount)
+
+	// 额度用完：通知失败原因，余额不动
+	f.clock.Advance(2 * time.Minute)
+	f.escalate(1, 4000)
+	require.Len(t, f.events, 3)
+	assert.ErrorIs(t, f.events[2].Err, ErrAutoMarginCapReached)
+	available, _ = f.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(500*Precision), available)
+
+	// 新一轮持仓额度重新计算
+	f.positions.pos[memPositionKey{1, symbol}].OpenedAt = 2
+	f.clock.Advance(2 * time.Minute)
+	f.escalate(1, 5000)
+	require.Len(t, f.events, 4)
+	assert.Equal(t, int64(300*Precision), f.events[3].Amount)
+}
+
+func TestAutoMargin_PercentBoundedByAvailable(t *testing.T) {
+	symbol := harnessLinearSpec().Symbol
+	f := newAutoMarginFixture(
+		&AutoMarginSetting{UserID: 2, Symbol: symbol, Enabled: true, PercentBps: 5_000, MaxTotal: 1_000 * Precision},
+		&AutoMarginSetting{UserID: 2, Symbol: "OTHER", Enabled: true, Amount: 1, MaxTotal: 1}, // 没有持仓
+	)
+	require.NoError(t, f.ledger.AddAvailable(context.Background(), 2, "USDT", 80*Precision))
+
+	f.escalate(2, 1000)
+	require.Len(t, f.events, 1)
+	assert.Equal(t, int64(40*Precision), f.events[0].Amount) // 可用 80 的 50%
+
+	// 可用余额用完后通知余额不足
+	f.ledger.FreezeWithJournal(context.Background(), 2, "USDT", 40*Precision, fund.JournalRef{EventID: "drain"})
+	f.clock.Advance(2 * time.Minute)
+	f.escalate(2, 2000)
+	require.Len(t, f.events, 2)
+	assert.ErrorIs(t, f.events[1].Err, ErrAutoMarginNoAvailable)
+}
+
+func TestAutoMargin_ClosedPositionRollsBack(t *testing.T) {
+	symbol := harnessLinearSpec().Symbol
+	f := newAutoMarginFixture(&AutoMarginSetting{UserID: 1, Symbol: symbol, Enabled: true, Amount: 10 * Precision, MaxTotal: 100 * Precision})
+	require.NoError(t, f.ledger.AddAvailable(context.Background(), 1, "USDT", 100*Precision))
+
+	// 读持仓之后、写持仓之前被平掉：冻结退回
+	f.svc.positions = &closingPositionRepo{memPositionRepo: f.positions}
+	f.escalate(1, 1000)
+	assert.Empty(t, f.events)
+	available, locked := f.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(100*Precision), available)
+	assert.Zero(t, locked)
+	assert.NotNil(t, f.ledger.journal("fmargin_auto_undo_1_"+symbol+"_1000"))
+}
+
+// closingPositionRepo AddMargin 之前持仓被平掉
+type closingPositionRepo struct {
+	*memPositionRepo
+}
+
+func (r *closingPositionRepo) AddMargin(ctx context.Context, userID int64, symbol string, amount int64) error {
+	r.mu.Lock()
+	r.pos[memPositionKey{userID, symbol}].Size = 0
+	r.mu.Unlock()
+	return r.memPositionRepo.AddMargin(ctx, userID, symbol, amount)
+}
+
+func TestAutoMargin_HandlerFilters(t *testing.T) {
+	f := newAutoMarginFixture()
+	h := f.svc.Handler()
+	h(liquidation.LevelChange{UserID: 1, From: liquidation.RiskLevelSafe, To: liquidation.RiskLevelWarning})       // 未到触发等级
+	h(liquidation.LevelChange{UserID: 1, From: liquidation.RiskLevelCritical, To: liquidation.RiskLevelDanger})    // 降级
+	h(liquidation.LevelChange{UserID: 1, From: liquidation.RiskLevelCritical, To: liquidation.RiskLevelLiquidate}) // 已强平
+	h(liquidation.LevelChange{UserID: 1, From: liquidation.RiskLevelWarning, To: liquidation.RiskLevelCritical})
+	assert.Equal(t, int64(1), f.svc.Stats().Received)
+	assert.Len(t, f.svc.queue, 1)
+}
+
+func TestAutoMarginSetting_Validate(t *testing.T) {
+	valid := AutoMarginSetting{UserID: 1, Symbol: "BTCUSDT", Amount: 1, MaxTotal: 10}
+	assert.NoError(t, valid.Validate())
+
+	for name, mutate := range map[string]func(*AutoMarginSetting){
+		"both amount and percent": func(s *AutoMarginSetting) { s.PercentBps = 100 },
+		"neither":                 func(s *AutoMarginSetting) { s.Amount = 0 },
+		"no cap":                  func(s *AutoMarginSetting) { s.MaxTotal = 0 },
+		"percent over 100%":       func(s *AutoMarginSetting) { s.Amount, s.PercentBps = 0, 10_001 },
+		"no symbol":               func(s *AutoMarginSetting) { s.Symbol = "" },
+	} {
+		s := valid
+		mutate(&s)
+		assert.ErrorIs(t, s.Validate(), ErrInvalidAutoMargin, name)
+	}
+}
@@ -0,0 +1,148 @@
+// 文件: pkg/futures/book_price.go
+// 按盘口深度定价 (市价平仓 / 强平单)
+//
+// 【问题】
+//   - 市价平仓只按标记价格 ± 最大滑点给保护价：盘口比标记价格好时保护价离盘口很远，
+//     下单到撮合之间盘口被抽走，IOC 会一路吃到保护价
+//   - 强平单以破产价挂限价单：破产价离盘口往往很远，一笔强平单把对手盘扫到破产价，
+//     本该进保险基金的剩余保证金全被滑点吃掉
+//
+// 【做法】沿对手盘逐档累计，找到吃满数量需要的最差一档 (扫单价)，再加两道上限：
+//   - 滑点上限：不劣于 对手最优价 × (1 ± 最大滑点)，超出的档位不吃
+//   - 标记价格带：不劣于 标记价格 ± 最大滑点 (见 protectedPrice)，防止盘口被操纵后跟着走
+//
+// 盘口吃不满时以上限价下单：市价平仓 (IOC) 剩余撤销，强平单剩余挂在上限价等对手盘，
+// 由看门狗兜底 (见 liquidation_executor.go)；对手盘为空时只用标记价格带
+//
+// 【注意】深度来自撮合发布的快照，和真正撮合时的盘口可能差几个事件：
+// 定价只决定限价，真实成交价由撮合决定，只会更好不会更差
+
+package futures
+
+import (
+	"max.com/pkg/fixed"
+	"max.com/pkg/mtrade"
+)
+
+// bookDepthLevels 定价时最多看的档位数
+const bookDepthLevels = 50
+
+// BookDepth 订单簿深度 (*mtrade.Engine 实现)
+type BookDepth interface {
+	GetDepth(n int) (bids, asks []mtrade.DepthLevel)
+}
+
+var _ BookDepth = (*mtrade.Engine)(nil)
+
+// BookQuote 按盘口估算的一笔吃单
+type BookQuote struct {
+	Touch    int64 // 对手最优价，0 表示对手盘为空
+	Price    int64 // 建议限价 (扫单价，已按上限截断)
+	AvgPrice int64 // 盘口内可成交部分的均价，Fillable = 0 时为 0
+	Fillable int64 // 限价以内盘口可成交的数量 (不超过请求数量)
+}
+
+// Complete 盘口是否能按建议限价一次吃满
+func (q BookQuote) Complete(qty int64) bool {
+	return q.Fillable >= qty
+}
+
+// QuoteBook 沿对手盘为 side 方向吃 qty 定价
+//
+// levels 为对手盘 (买单看 asks，卖单看 bids)，按价格由优到劣排序；
+// markPrice <= 0 时不加标记价格带
+func QuoteBook(spec *ContractSpec, side Side, levels []mtrade.DepthLevel, qty, markPrice, slippage int64) BookQuote {
+	var limit int64
+	if markPrice > 0 {
+		limit = protectedPrice(spec, side, markPrice, slippage)
+	}
+	if len(levels) == 0 || qty <= 0 {
+		return BookQuote{Price: limit}
+	}
+
+	q := BookQuote{Touch: levels[0].Price}
+	limit = tighter(side, limit, protectedPrice(spec, side, q.Touch, slippage))
+
+	var value int64
+	for _, lv := range levels {
+		if !within(side, lv.Price, limit) {
+			break
+		}
+		take := min(lv.Quantity, qty-q.Fillable)
+		q.Fillable += take
+		q.Price = lv.Price
+		value += fixed.Mul(lv.Price, take)
+		if q.Fillable >= qty {
+			break
+		}
+	}
+	if q.Fillable > 0 {
+		q.AvgPrice = fixed.Quo(value, q.Fillable)
+	}
+	if !q.Complete(qty) {
+		q.Price = limit // 吃不满：剩余以上限价下单
+	}
+	return q
+}
+
+// within price 是否不劣于 limit (limit = 0 表示不限)
+func within(side Side, price, limit int64) bool {
+	if limit <= 0 {
+		return true
+	}
+	if side == SideLong {
+		return price <= limit
+	}
+	return price >= limit
+}
+
+// tighter 两个上限里更保守的 (0 表示不限)
+func tighter(side Side, a, b int64) int64 {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0:
+		return a
+	case side == SideLong:
+		return min(a, b)
+	default:
+		return max(a, b)
+	}
+}
+
+// quoteDepth 从 depth 读对手盘定价，depth 为 nil 时只用标记价格带
+func quoteDepth(depth BookDepth, spec *ContractSpec, side Side, qty, markPrice, slippage int64) BookQuote {
+	if depth == nil {
+		return QuoteBook(spec, side, nil, qty, markPrice, slippage)
+	}
+	bids, asks := depth.GetDepth(bookDepthLevels)
+	if side == SideLong {
+		return QuoteBook(spec, side, asks, qty, markPrice, slippage)
+	}
+	return QuoteBook(spec, side, bids, qty, markPrice, slippage)
+}
+
+// resolveClosePrice 市价平仓的限价：盘口扫单价，按对手最优价和标记价格的滑点上限截断
+func (p *FuturesProcessor) resolveClosePrice(spec *ContractSpec, side Side, qty, slippage int64) (int64, error) {
+	if slippage == 0 {
+		slippage = p.maxSlippage
+	}
+	if slippage <= 0 || slippage >= RatePrecision {
+		return 0, ErrInvalidSlippage
+	}
+	mark := p.markPriceService.GetMarkPrice(spec.Symbol)
+	if mark <= 0 {
+		return 0, ErrNoMarkPrice
+	}
+	return quoteDepth(p.bookDepth(spec.Symbol), spec, side, qty, mark, slippage).Price, nil
+}
+
+// bookDepth 交易对所在撮合引擎的深度，引擎客户端不支持深度查询 (远程引擎) 时返回 nil
+func (p *FuturesProcessor) bookDepth(symbol string) BookDepth {
+	client, err := p.engines.Route(symbol)
+	if err != nil {
+		return nil
+	}
+	depth, _ := client.(BookDepth)
+	return depth
+}
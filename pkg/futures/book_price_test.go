@@ -0,0 +1,105 @@
+// 文件: pkg/futures/book_price_test.go
+// 按盘口深度定价测试
+
+package futures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+)
+
+func TestQuoteBook(t *testing.T) {
+	spec := &ContractSpec{TickSize: Precision}
+	asks := []mtrade.DepthLevel{
+		{Price: 50000 * Precision, Quantity: Precision / 2},
+		{Price: 50200 * Precision, Quantity: Precision / 2},
+		{Price: 50600 * Precision, Quantity: Precision},
+	}
+
+	// 买 1：吃两档，扫单价 50200，均价 50100
+	q := QuoteBook(spec, SideLong, asks, Precision, 50000*Precision, 100)
+	assert.True(t, q.Complete(Precision))
+	assert.Equal(t, int64(50000*Precision), q.Touch)
+	assert.Equal(t, int64(50200*Precision), q.Price)
+	assert.Equal(t, int64(50100*Precision), q.AvgPrice)
+
+	// 买 2：第三档超出 对手最优价 × 1.01 = 50500，吃不满，以上限价下单
+	q = QuoteBook(spec, SideLong, asks, 2*Precision, 50000*Precision, 100)
+	assert.False(t, q.Complete(2*Precision))
+	assert.Equal(t, int64(Precision), q.Fillable)
+	assert.Equal(t, int64(50500*Precision), q.Price)
+
+	// 标记价格更低时标记价格带更紧：49800 × 1.01 = 50298
+	q = QuoteBook(spec, SideLong, asks, 2*Precision, 49800*Precision, 100)
+	assert.Equal(t, int64(50298*Precision), q.Price)
+
+	// 卖单看买盘，最优买价已经低于标记价格带：一档都不吃
+	bids := []mtrade.DepthLevel{{Price: 49000 * Precision, Quantity: Precision}}
+	q = QuoteBook(spec, SideShort, bids, Precision, 50000*Precision, 100)
+	assert.Zero(t, q.Fillable)
+	assert.Zero(t, q.AvgPrice)
+	assert.Equal(t, int64(49500*Precision), q.Price)
+
+	// 对手盘为空：只用标记价格带
+	q = QuoteBook(spec, SideShort, nil, Precision, 50000*Precision, 100)
+	assert.Zero(t, q.Touch)
+	assert.Equal(t, int64(49500*Precision), q.Price)
+}
+
+func TestHarness_LiquidationPricedFromBook(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 2, "USDT", 20000*Precision)
+	marks := NewMarkPriceService()
+	marks.UpdateMarkPrice(symbol, 49000*Precision)
+
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, marks, nil, nil)
+	// 多 1 BTC @50000，保证金 5000，破产价 45000
+	pos := &Position{UserID: 1, Symbol: symbol, Size: Precision, EntryPrice: 50000 * Precision, Margin: 5000 * Precision, Leverage: 10}
+	require.NoError(t, h.positions.Save(h.ctx, pos))
+
+	// 买盘: 0.5 @ 49900, 0.5 @ 49800, 1 @ 46000
+	for _, bid := range []struct{ qty, price int64 }{
+		{Precision / 2, 49900}, {Precision / 2, 49800}, {Precision, 46000},
+	} {
+		require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+			UserID: 2, Symbol: symbol, Side: SideLong, Qty: bid.qty, Price: bid.price * Precision, Leverage: 10,
+		}))
+	}
+	h.waitFor(symbol, mtrade.EventOrderAccepted, 3)
+	require.Eventually(t, func() bool {
+		bids, _ := h.engines[symbol].GetDepth(bookDepthLevels)
+		return len(bids) == 3
+	}, time.Second, time.Millisecond)
+
+	// 前两档吃满：挂 49800 而不是破产价 45000，按盘口均价估算成交
+	task := liquidation.LiquidationTask{UserID: 1, Symbol: symbol}
+	preview, err := executor.Preview(h.ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, int64(45000*Precision), preview.BankruptPrice)
+	assert.Equal(t, int64(49800*Precision), preview.OrderPrice)
+	assert.Equal(t, int64(49850*Precision), preview.EstimatedFillPrice)
+
+	// 持仓加到 2 BTC：46000 超出 对手最优价 × 0.99 = 49401，以上限价挂单等对手盘
+	pos.Size, pos.Margin = 2*Precision, 10000*Precision
+	require.NoError(t, h.positions.Save(h.ctx, pos))
+	preview, err = executor.Preview(h.ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, int64(49401*Precision), preview.OrderPrice)
+	assert.Equal(t, int64(49401*Precision), preview.EstimatedFillPrice)
+
+	// 滑点放宽到 10% 且加到 3 BTC：盘口吃不满，上限 49900 × 0.9 = 44910 截到破产价
+	executor.SetBookSlippage(1000)
+	pos.Size, pos.Margin = 3*Precision, 15000*Precision
+	require.NoError(t, h.positions.Save(h.ctx, pos))
+	preview, err = executor.Preview(h.ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, int64(45000*Precision), preview.OrderPrice)
+}
@@ -8,16 +8,24 @@
 // 【缓存策略】
 // - 读: 先查 Redis，miss 则查 DB 并回填
 // - 写: 先写 DB，成功后删除缓存 (Cache Aside)
+//
+// 【缓存击穿 / 穿透防护】
+// - 击穿: 热点 key 过期瞬间大量请求同时回源，singleflight 合并为一次 DB 查询
+// - 穿透: 不存在的 symbol 每次都 miss，写一个短 TTL 的空值标记 (负缓存)
+// - 热点 key 快过期时后台提前刷新，请求永远命中缓存
 
 package futures
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 // 确保实现了接口
@@ -45,8 +53,41 @@ const (
 
 	// 列表缓存过期时间 (较短，因为可能有状态变化)
 	listCacheTTL = 5 * time.Minute
+
+	// 负缓存: 不存在的 symbol 写入该标记，TTL 很短，新上线的合约最多晚这么久可见
+	// (Create 会主动删除，正常上线流程不受影响)
+	negativeCacheValue = "-"
+	negativeCacheTTL   = 30 * time.Second
+
+	// 热点 key: 1 分钟内命中达到阈值，且剩余 TTL 小于 refreshAhead 时后台提前刷新
+	hotKeyWindow    = time.Minute
+	hotKeyThreshold = 10
+	refreshAhead    = time.Hour
 )
 
+// CacheOptions 缓存参数，零值字段使用上面的默认值
+type CacheOptions struct {
+	TTL             time.Duration
+	NegativeTTL     time.Duration
+	RefreshAhead    time.Duration
+	HotKeyThreshold int64
+}
+
+func (o *CacheOptions) withDefaults() {
+	if o.TTL <= 0 {
+		o.TTL = cacheTTL
+	}
+	if o.NegativeTTL <= 0 {
+		o.NegativeTTL = negativeCacheTTL
+	}
+	if o.RefreshAhead <= 0 || o.RefreshAhead >= o.TTL {
+		o.RefreshAhead = min(refreshAhead, o.TTL/2)
+	}
+	if o.HotKeyThreshold <= 0 {
+		o.HotKeyThreshold = hotKeyThreshold
+	}
+}
+
 // =============================================================================
 // CachedContractRepository - 带缓存的 Repository
 // =============================================================================
@@ -58,8 +99,23 @@ const (
 // 2. 可组合: 可以选择用或不用缓存
 // 3. 可替换: 换 Memcached 只需新建装饰器
 type CachedContractRepository struct {
-	repo  ContractRepository // 被装饰的底层 Repository
-	redis *redis.Client
+	repo    ContractRepository // 被装饰的底层 Repository
+	redis   *redis.Client
+	options CacheOptions
+
+	// 同一个 key 的并发回源合并为一次
+	loads singleflight.Group
+
+	mu sync.Mutex
+	// 本进程内每个 symbol 的失效代数：回源期间发生了写操作，回源结果不再回填
+	generations map[string]uint64
+	// 热点统计 (按分钟窗口)
+	hits map[string]*hotCounter
+}
+
+type hotCounter struct {
+	window int64 // 窗口起始 (Unix 秒 / 60)
+	count  int64
 }
 
 // NewCachedContractRepository 创建带缓存的 Repository
@@ -70,9 +126,18 @@ type CachedContractRepository struct {
 //	cachedRepo := NewCachedContractRepository(mysqlRepo, redisClient)
 //	manager := NewContractManager(cachedRepo)  // manager 用缓存版
 func NewCachedContractRepository(repo ContractRepository, rds *redis.Client) *CachedContractRepository {
+	return NewCachedContractRepositoryWithOptions(repo, rds, CacheOptions{})
+}
+
+// NewCachedContractRepositoryWithOptions 创建带缓存的 Repository (自定义 TTL 等参数)
+func NewCachedContractRepositoryWithOptions(repo ContractRepository, rds *redis.Client, opts CacheOptions) *CachedContractRepository {
+	opts.withDefaults()
 	return &CachedContractRepository{
-		repo:  repo,
-		redis: rds,
+		repo:        repo,
+		redis:       rds,
+		options:     opts,
+		generations: make(map[string]uint64),
+		hits:        make(map[string]*hotCounter),
 	}
 }
 
@@ -84,27 +149,103 @@ func NewCachedContractRepository(repo ContractRepository, rds *redis.Client) *Ca
 func (r *CachedContractRepository) GetBySymbol(ctx context.Context, symbol string) (*ContractSpec, error) {
 	cacheKey := fmt.Sprintf(cacheKeySymbol, symbol)
 
-	// 1. 查缓存
-	data, err := r.redis.Get(ctx, cacheKey).Bytes()
-	if err == nil {
+	// 1. 查缓存 (GET + PTTL 一次往返，剩余 TTL 用于判断是否需要提前刷新)
+	pipe := r.redis.Pipeline()
+	getCmd := pipe.Get(ctx, cacheKey)
+	ttlCmd := pipe.PTTL(ctx, cacheKey)
+	pipe.Exec(ctx)
+
+	if data, err := getCmd.Bytes(); err == nil {
+		if string(data) == negativeCacheValue {
+			return nil, ErrSymbolNotFound // 负缓存命中
+		}
 		var spec ContractSpec
 		if json.Unmarshal(data, &spec) == nil {
+			if ttl, err := ttlCmd.Result(); err == nil && ttl > 0 && ttl < r.options.RefreshAhead && r.isHot(symbol) {
+				go r.refresh(symbol)
+			}
 			return &spec, nil // Cache hit
 		}
 	}
 
-	// 2. Cache miss, 查底层
+	// 2. Cache miss, 合并回源
+	return r.load(ctx, symbol)
+}
+
+// load 回源并回填缓存，同一 symbol 同时只有一个请求打到底层
+//
+// 【注意】回源用不带取消的 context：领头请求被取消不应该让排队的请求全部失败；
+// 每个调用方各自等待自己的 ctx
+func (r *CachedContractRepository) load(ctx context.Context, symbol string) (*ContractSpec, error) {
+	ch := r.loads.DoChan("symbol:"+symbol, func() (any, error) {
+		return r.fetch(context.WithoutCancel(ctx), symbol)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// 共享结果是同一个指针，复制一份避免调用方互相修改
+		spec := *res.Val.(*ContractSpec)
+		return &spec, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch 查底层并回填：存在写正常缓存，不存在写负缓存
+func (r *CachedContractRepository) fetch(ctx context.Context, symbol string) (*ContractSpec, error) {
+	cacheKey := fmt.Sprintf(cacheKeySymbol, symbol)
+	gen := r.generation(symbol)
+
 	spec, err := r.repo.GetBySymbol(ctx, symbol)
+	if errors.Is(err, ErrSymbolNotFound) {
+		if r.generation(symbol) == gen {
+			r.redis.Set(ctx, cacheKey, negativeCacheValue, r.options.NegativeTTL)
+		}
+		return nil, err
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// 3. 回填缓存 (异步，不阻塞主流程)
-	go r.setCache(context.Background(), cacheKey, spec, cacheTTL)
-
+	// 回填缓存 (已在 singleflight 内，同步写即可，跟随者不会被多阻塞)
+	if r.generation(symbol) == gen {
+		r.setCache(ctx, cacheKey, spec, r.options.TTL)
+	}
 	return spec, nil
 }
 
+// refresh 后台提前刷新热点 key，与回源共用 singleflight key
+func (r *CachedContractRepository) refresh(symbol string) {
+	r.loads.Do("symbol:"+symbol, func() (any, error) {
+		return r.fetch(context.Background(), symbol)
+	})
+}
+
+// isHot 记录一次命中，返回当前窗口内是否达到热点阈值
+func (r *CachedContractRepository) isHot(symbol string) bool {
+	window := time.Now().Unix() / int64(hotKeyWindow/time.Second)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.hits[symbol]
+	if !ok {
+		c = &hotCounter{}
+		r.hits[symbol] = c
+	}
+	if c.window != window {
+		c.window, c.count = window, 0
+	}
+	c.count++
+	return c.count >= r.options.HotKeyThreshold
+}
+
+func (r *CachedContractRepository) generation(symbol string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generations[symbol]
+}
+
 // ListByStatus 按状态查询 (带缓存)
 func (r *CachedContractRepository) ListByStatus(ctx context.Context, status ContractStatus) ([]*ContractSpec, error) {
 	// 只缓存 Trading 状态的列表
@@ -126,16 +267,20 @@ func (r *CachedContractRepository) getTradingListCached(ctx context.Context) ([]
 		}
 	}
 
-	// 2. 查底层
-	specs, err := r.repo.ListByStatus(ctx, StatusTrading)
+	// 2. 查底层 (合并并发回源)
+	v, err, _ := r.loads.Do("list:trading", func() (any, error) {
+		specs, err := r.repo.ListByStatus(context.WithoutCancel(ctx), StatusTrading)
+		if err != nil {
+			return nil, err
+		}
+		// 3. 回填
+		r.setCacheList(context.Background(), cacheKeyTradingList, specs, listCacheTTL)
+		return specs, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	// 3. 回填
-	go r.setCacheList(context.Background(), cacheKeyTradingList, specs, listCacheTTL)
-
-	return specs, nil
+	return v.([]*ContractSpec), nil
 }
 
 // List 列出所有合约
@@ -156,8 +301,8 @@ func (r *CachedContractRepository) Create(ctx context.Context, spec *ContractSpe
 	}
 
 	// 2. 不需要主动缓存，下次读取时会自动缓存
-	// 3. 删除列表缓存 (新增合约可能影响列表)
-	r.invalidateListCache(ctx)
+	// 3. 删除该 symbol 的负缓存和列表缓存 (新增合约可能影响列表)
+	r.invalidateCache(ctx, spec.Symbol)
 
 	return nil
 }
@@ -218,6 +363,11 @@ func (r *CachedContractRepository) setCacheList(ctx context.Context, key string,
 
 // invalidateCache 删除指定合约的缓存
 func (r *CachedContractRepository) invalidateCache(ctx context.Context, symbol string) {
+	// 进行中的回源结果作废
+	r.mu.Lock()
+	r.generations[symbol]++
+	r.mu.Unlock()
+
 	// 删除单个缓存
 	r.redis.Del(ctx, fmt.Sprintf(cacheKeySymbol, symbol))
 	// 删除列表缓存
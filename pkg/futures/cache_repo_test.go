@@ -0,0 +1,367 @@
+// 文件: pkg/futures/cache_repo_test.go
+// 合约规格缓存层测试 (内存仓储 + 进程内假 Redis，不依赖外部服务)
+
+package futures
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// fakeRedis - 只实现缓存层用到的 GET / SET / PTTL / DEL (RESP2)
+// =============================================================================
+//
+// 过期时间按 now 计算，测试推进时钟即可让 key 过期，不用真的等
+
+type fakeRedis struct {
+	mu     sync.Mutex
+	now    time.Time
+	values map[string]string
+	expire map[string]time.Time
+	gets   map[string]int
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+	f := &fakeRedis{
+		now:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		values: make(map[string]string),
+		expire: make(map[string]time.Time),
+		gets:   make(map[string]int),
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		rdb.Close()
+		ln.Close()
+	})
+	return f, rdb
+}
+
+// advance 推进时钟
+func (f *fakeRedis) advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
+
+// getCount key 被 GET 的次数
+func (f *fakeRedis) getCount(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets[key]
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand 读一条 *N\r\n$len\r\narg\r\n... 格式的命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	for key, at := range f.expire {
+		if !f.now.Before(at) {
+			delete(f.values, key)
+			delete(f.expire, key)
+		}
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		f.gets[args[1]]++
+		v, ok := f.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		key := args[1]
+		f.values[key] = args[2]
+		delete(f.expire, key)
+		if len(args) == 5 {
+			n, _ := strconv.ParseInt(args[4], 10, 64)
+			switch strings.ToUpper(args[3]) {
+			case "EX":
+				f.expire[key] = f.now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				f.expire[key] = f.now.Add(time.Duration(n) * time.Millisecond)
+			}
+		}
+		return "+OK\r\n"
+	case "PTTL":
+		if _, ok := f.values[args[1]]; !ok {
+			return ":-2\r\n"
+		}
+		at, ok := f.expire[args[1]]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", at.Sub(f.now).Milliseconds())
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.values[key]; ok {
+				n++
+			}
+			delete(f.values, key)
+			delete(f.expire, key)
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+// =============================================================================
+// countingRepo - 统计回源次数，可选阻塞回源
+// =============================================================================
+
+type countingRepo struct {
+	*memContractRepo
+	loads   atomic.Int64
+	started chan struct{} // 非 nil 时每次回源先发信号
+	release chan struct{} // 非 nil 时回源阻塞到关闭
+}
+
+func (r *countingRepo) GetBySymbol(ctx context.Context, symbol string) (*ContractSpec, error) {
+	r.loads.Add(1)
+	if r.started != nil {
+		r.started <- struct{}{}
+	}
+	if r.release != nil {
+		<-r.release
+	}
+	return r.memContractRepo.GetBySymbol(ctx, symbol)
+}
+
+func newCachedRepoTest(t *testing.T, opts CacheOptions, specs ...*ContractSpec) (*CachedContractRepository, *countingRepo, *fakeRedis) {
+	fake, rdb := newFakeRedis(t)
+	repo := &countingRepo{memContractRepo: newMemContractRepo(specs...)}
+	return NewCachedContractRepositoryWithOptions(repo, rdb, opts), repo, fake
+}
+
+// =============================================================================
+// 测试
+// =============================================================================
+
+func TestCachedRepo_HitAfterLoad(t *testing.T) {
+	cached, repo, _ := newCachedRepoTest(t, CacheOptions{}, &ContractSpec{Symbol: "BTCUSDT", MaxLeverage: 100})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		spec, err := cached.GetBySymbol(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, 100, spec.MaxLeverage)
+		spec.MaxLeverage = 1 // 调用方修改自己的副本不影响缓存
+	}
+	assert.Equal(t, int64(1), repo.loads.Load())
+}
+
+func TestCachedRepo_ConcurrentMissLoadsOnce(t *testing.T) {
+	cached, repo, fake := newCachedRepoTest(t, CacheOptions{}, &ContractSpec{Symbol: "BTCUSDT", MaxLeverage: 100})
+	repo.started = make(chan struct{}, 16)
+	repo.release = make(chan struct{})
+	ctx := context.Background()
+
+	const callers = 16
+	var wg sync.WaitGroup
+	results := make([]*ContractSpec, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = cached.GetBySymbol(ctx, "BTCUSDT")
+		}(i)
+	}
+
+	// 领头请求卡在回源，等所有请求都 miss 过缓存再放行
+	<-repo.started
+	key := fmt.Sprintf(cacheKeySymbol, "BTCUSDT")
+	require.Eventually(t, func() bool { return fake.getCount(key) == callers }, 5*time.Second, time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), repo.loads.Load(), "concurrent misses must share one load")
+	for i := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, 100, results[i].MaxLeverage)
+		if i > 0 {
+			assert.NotSame(t, results[0], results[i], "callers must get their own copy")
+		}
+	}
+}
+
+func TestCachedRepo_NegativeCacheExpires(t *testing.T) {
+	cached, repo, fake := newCachedRepoTest(t, CacheOptions{NegativeTTL: 30 * time.Second})
+	ctx := context.Background()
+
+	// 不存在的 symbol：第一次回源，之后命中负缓存
+	for i := 0; i < 3; i++ {
+		_, err := cached.GetBySymbol(ctx, "NEWUSDT")
+		assert.True(t, errors.Is(err, ErrSymbolNotFound))
+	}
+	assert.Equal(t, int64(1), repo.loads.Load())
+
+	// 绕过缓存层直接上线：负缓存 TTL 内仍不可见
+	require.NoError(t, repo.memContractRepo.Create(ctx, &ContractSpec{Symbol: "NEWUSDT", MaxLeverage: 20}))
+	fake.advance(29 * time.Second)
+	_, err := cached.GetBySymbol(ctx, "NEWUSDT")
+	assert.True(t, errors.Is(err, ErrSymbolNotFound))
+	assert.Equal(t, int64(1), repo.loads.Load())
+
+	// 负缓存过期后重新回源
+	fake.advance(time.Second)
+	spec, err := cached.GetBySymbol(ctx, "NEWUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 20, spec.MaxLeverage)
+	assert.Equal(t, int64(2), repo.loads.Load())
+}
+
+func TestCachedRepo_CreateClearsNegativeCache(t *testing.T) {
+	cached, repo, _ := newCachedRepoTest(t, CacheOptions{})
+	ctx := context.Background()
+
+	_, err := cached.GetBySymbol(ctx, "NEWUSDT")
+	assert.True(t, errors.Is(err, ErrSymbolNotFound))
+
+	require.NoError(t, cached.Create(ctx, &ContractSpec{Symbol: "NEWUSDT", MaxLeverage: 20}))
+	spec, err := cached.GetBySymbol(ctx, "NEWUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 20, spec.MaxLeverage)
+	assert.Equal(t, int64(2), repo.loads.Load())
+}
+
+func TestCachedRepo_HotKeyRefresh(t *testing.T) {
+	opts := CacheOptions{TTL: 10 * time.Minute, RefreshAhead: 5 * time.Minute, HotKeyThreshold: 3}
+	cached, repo, fake := newCachedRepoTest(t, opts, &ContractSpec{Symbol: "BTCUSDT", MaxLeverage: 100})
+	ctx := context.Background()
+
+	_, err := cached.GetBySymbol(ctx, "BTCUSDT")
+	require.NoError(t, err)
+
+	// 绕过缓存层改库，缓存里是旧值
+	require.NoError(t, repo.memContractRepo.Update(ctx, &ContractSpec{Symbol: "BTCUSDT", MaxLeverage: 50}))
+
+	// 剩余 TTL 还多：不刷新
+	for i := 0; i < 5; i++ {
+		spec, err := cached.GetBySymbol(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, 100, spec.MaxLeverage)
+	}
+	assert.Equal(t, int64(1), repo.loads.Load())
+
+	// 进入提前刷新区间：命中次数未到热点阈值前不刷新
+	fake.advance(6 * time.Minute)
+	for i := 0; i < 2; i++ {
+		spec, err := cached.GetBySymbol(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, 100, spec.MaxLeverage)
+	}
+	assert.Equal(t, int64(1), repo.loads.Load())
+
+	// 成为热点：本次仍返回旧值，后台刷新把新值写回缓存
+	spec, err := cached.GetBySymbol(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 100, spec.MaxLeverage)
+
+	require.Eventually(t, func() bool {
+		spec, err := cached.GetBySymbol(ctx, "BTCUSDT")
+		return err == nil && spec.MaxLeverage == 50
+	}, 5*time.Second, time.Millisecond)
+
+	// 刷新后 TTL 重新计满，原来的过期时间点过去也不会回源
+	loads := repo.loads.Load()
+	fake.advance(5 * time.Minute)
+	spec, err = cached.GetBySymbol(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 50, spec.MaxLeverage)
+	assert.Equal(t, loads, repo.loads.Load())
+}
+
+func TestCachedRepo_ColdKeyNotRefreshed(t *testing.T) {
+	opts := CacheOptions{TTL: 10 * time.Minute, RefreshAhead: 5 * time.Minute, HotKeyThreshold: 100}
+	cached, repo, fake := newCachedRepoTest(t, opts, &ContractSpec{Symbol: "BTCUSDT", MaxLeverage: 100})
+	ctx := context.Background()
+
+	_, err := cached.GetBySymbol(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	fake.advance(6 * time.Minute)
+	for i := 0; i < 5; i++ {
+		_, err := cached.GetBySymbol(ctx, "BTCUSDT")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), repo.loads.Load())
+
+	// 过期后正常回源
+	fake.advance(5 * time.Minute)
+	_, err = cached.GetBySymbol(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), repo.loads.Load())
+}
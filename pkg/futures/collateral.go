@@ -0,0 +1,281 @@
+// 文件: pkg/futures/collateral.go
+// 多币种抵押品估值 (cross-collateral)
+//
+// 【问题】用户钱包里有 BTC，开的是 USDT 结算的合约：只看 USDT 余额，BTC 对保证金毫无贡献，
+// 浮亏稍大就被强平，哪怕 BTC 的价值远超亏损
+//
+// 【做法】每种抵押币按指数价格折算成结算币种，再打折 (haircut)：
+//
+//	抵押价值 = 数量 × 指数价 × (1 − 折价率)
+//
+// 指数来自 indexprice.Service (Track 注册 "BTC/USDT" 这样的现货指数)，折价率按币种配置：
+// 波动越大、流动性越差，折得越狠
+//
+// 【过旧降级】
+//   - 指数超过 StaleAfter 未更新：照用上一次的价格，但额外折价 StaleHaircut
+//   - 超过 MaxStale：该币不计入 (价值为 0)，估值里列为 Unpriced
+//
+// 宁可少算：抵押品多算了，强平就晚了，亏损会落到保险基金
+//
+// 【消费方】
+//   - 可开仓保证金 (AccountMargin)：抵押价值计入权益，吸收持仓浮亏
+//   - 持仓风险率 (GetPositionWithRisk)：抵押价值计入余额
+//   - 强平定量 (LiquidationExecutor)：抵押品撑得住的部分保留，只强平超出的部分
+//
+// 【注意】
+//   - 只计冷钱包可用余额：冻结在挂单里的币随时会被成交拿走
+//   - 抵押品只抬高权益，开仓冻结的仍是结算币种 (没有自动借贷 / 兑换)，可开仓仍以结算币种可用为上限
+//   - 同一用户的多个持仓共享抵押品，强平定量按整体价值计算，不按持仓分摊
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+	"max.com/pkg/fund"
+	"max.com/pkg/indexprice"
+)
+
+var (
+	ErrInvalidCollateral          = cexerr.New("FUTURES_INVALID_COLLATERAL", cexerr.CategoryInvalidArgument, "invalid collateral config")
+	ErrCollateralNotAccepted      = cexerr.New("FUTURES_COLLATERAL_NOT_ACCEPTED", cexerr.CategoryInvalidArgument, "collateral asset not accepted")
+	ErrCollateralPriceUnavailable = cexerr.NewRetryable("FUTURES_COLLATERAL_PRICE_UNAVAILABLE", cexerr.CategoryUnavailable, "collateral price unavailable")
+	ErrLiquidationNotNeeded       = cexerr.New("FUTURES_LIQUIDATION_NOT_NEEDED", cexerr.CategoryFailedPrecondition, "position supported by collateral")
+)
+
+// CollateralAsset 一种抵押币在一个结算币种下的估值配置
+type CollateralAsset struct {
+	Asset   string // 抵押币 (BTC)
+	Settle  string // 结算币种 (USDT)
+	Haircut int64  // 折价率 (万分比)，500 = 按 95% 计价
+
+	// IndexSymbol 估值用的指数 (indexprice 的 symbol)，默认 "Asset/Settle"
+	IndexSymbol string
+	// Invert 指数是 Settle/Asset 报价时取倒数 (币本位合约用 USDT 抵押：复用 "BTC/USDT" 指数)
+	Invert bool
+	// Sources 指数来源交易所，Track 时注册到 indexprice.Service；为空表示指数已由别处注册
+	Sources []string
+}
+
+// pair 指数对应的现货交易对
+func (a CollateralAsset) pair() indexprice.Pair {
+	if a.Invert {
+		return indexprice.Pair{Base: a.Settle, Quote: a.Asset}
+	}
+	return indexprice.Pair{Base: a.Asset, Quote: a.Settle}
+}
+
+func (a CollateralAsset) indexSymbol() string {
+	if a.IndexSymbol != "" {
+		return a.IndexSymbol
+	}
+	return a.pair().String()
+}
+
+// CollateralConfig 抵押品估值配置
+type CollateralConfig struct {
+	Assets []CollateralAsset
+
+	StaleAfter   time.Duration // 指数多久没更新算过旧，默认 30s
+	StaleHaircut int64         // 过旧期间额外折价 (万分比)，默认 500
+	MaxStale     time.Duration // 超过多久不再计入，默认 5m
+}
+
+func (c CollateralConfig) withDefaults() CollateralConfig {
+	if c.StaleAfter <= 0 {
+		c.StaleAfter = 30 * time.Second
+	}
+	if c.StaleHaircut <= 0 {
+		c.StaleHaircut = 500
+	}
+	if c.MaxStale <= 0 {
+		c.MaxStale = 5 * time.Minute
+	}
+	return c
+}
+
+// CollateralIndex 指数价格 (*indexprice.Service 实现)
+type CollateralIndex interface {
+	Index(symbol string) (indexprice.Index, bool)
+}
+
+// CollateralBalances 抵押币余额 (*fund.BalanceRepo 实现)
+type CollateralBalances interface {
+	GetBalance(ctx context.Context, userID int64, currency string) (*fund.BalanceRecord, error)
+}
+
+var (
+	_ CollateralIndex    = (*indexprice.Service)(nil)
+	_ CollateralBalances = (*fund.BalanceRepo)(nil)
+)
+
+// CollateralService 抵押品估值服务
+type CollateralService struct {
+	cfg      CollateralConfig
+	index    CollateralIndex
+	balances CollateralBalances
+	assets   map[[2]string]CollateralAsset // (抵押币, 结算币种) → 配置
+	now      func() time.Time
+}
+
+// NewCollateralService 创建抵押品估值服务，配置非法返回 ErrInvalidCollateral
+func NewCollateralService(cfg CollateralConfig, index CollateralIndex, balances CollateralBalances) (*CollateralService, error) {
+	s := &CollateralService{
+		cfg:      cfg.withDefaults(),
+		index:    index,
+		balances: balances,
+		assets:   make(map[[2]string]CollateralAsset, len(cfg.Assets)),
+		now:      time.Now,
+	}
+	for _, a := range cfg.Assets {
+		key := [2]string{a.Asset, a.Settle}
+		switch {
+		case a.Asset == "" || a.Settle == "" || a.Asset == a.Settle:
+			return nil, ErrInvalidCollateral.Wrapf("asset %q settle %q", a.Asset, a.Settle)
+		case a.Haircut < 0 || a.Haircut >= RatePrecision:
+			return nil, ErrInvalidCollateral.Wrapf("%s haircut %d out of [0, %d)", a.Asset, a.Haircut, RatePrecision)
+		}
+		if _, dup := s.assets[key]; dup {
+			return nil, ErrInvalidCollateral.Wrapf("duplicate %s/%s", a.Asset, a.Settle)
+		}
+		s.assets[key] = a
+	}
+	return s, nil
+}
+
+// SetClock 替换判断抵押品价格是否过期的时钟
+func (s *CollateralService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Track 向指数服务注册抵押币的指数 (配置了 Sources 的)
+func (s *CollateralService) Track(svc *indexprice.Service) error {
+	for _, a := range s.cfg.Assets {
+		if len(a.Sources) == 0 {
+			continue
+		}
+		if err := svc.Track(a.indexSymbol(), a.pair(), a.Sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// 估值
+// =============================================================================
+
+// CollateralQuote 抵押币的折算价格
+type CollateralQuote struct {
+	Asset   string
+	Settle  string
+	Price   int64 // 1 个抵押币值多少结算币种 (×Precision)
+	Haircut int64 // 实际折价率 (万分比，含过旧折价)
+	Stale   bool  // 指数过旧，已额外折价
+}
+
+// Quote 抵押币当前的折算价格
+//
+// 不接受的币种返回 ErrCollateralNotAccepted，没有指数或指数超过 MaxStale 返回 ErrCollateralPriceUnavailable
+func (s *CollateralService) Quote(asset, settle string) (CollateralQuote, error) {
+	a, ok := s.assets[[2]string{asset, settle}]
+	if !ok {
+		return CollateralQuote{}, ErrCollateralNotAccepted.Wrapf("%s for %s", asset, settle)
+	}
+	idx, ok := s.index.Index(a.indexSymbol())
+	if !ok {
+		return CollateralQuote{}, ErrCollateralPriceUnavailable.Wrapf("no index %s", a.indexSymbol())
+	}
+	age := s.now().Sub(time.UnixMilli(idx.UpdatedAt))
+	if age > s.cfg.MaxStale {
+		return CollateralQuote{}, ErrCollateralPriceUnavailable.Wrapf("index %s stale for %s", a.indexSymbol(), age.Round(time.Second))
+	}
+
+	q := CollateralQuote{Asset: asset, Settle: settle, Price: idx.Price, Haircut: a.Haircut}
+	if a.Invert {
+		q.Price = fixed.Quo(Precision, idx.Price)
+	}
+	if age > s.cfg.StaleAfter {
+		q.Stale = true
+		q.Haircut = min(q.Haircut+s.cfg.StaleHaircut, RatePrecision)
+	}
+	return q, nil
+}
+
+// Value 按报价折算一笔抵押币 (结算币种，已折价)
+func (q CollateralQuote) Value(amount int64) int64 {
+	if amount <= 0 {
+		return 0
+	}
+	return fixed.Bps(fixed.Mul(amount, q.Price), RatePrecision-q.Haircut)
+}
+
+// Convert 把 amount 个抵押币折算成结算币种
+func (s *CollateralService) Convert(asset, settle string, amount int64) (int64, error) {
+	q, err := s.Quote(asset, settle)
+	if err != nil {
+		return 0, err
+	}
+	return q.Value(amount), nil
+}
+
+// CollateralLine 一种抵押币的估值
+type CollateralLine struct {
+	Quote  CollateralQuote
+	Amount int64 // 冷钱包可用数量
+	Value  int64 // 折算价值 (结算币种)
+}
+
+// CollateralValuation 用户在一个结算币种下的抵押品估值
+type CollateralValuation struct {
+	UserID   int64
+	Settle   string
+	Value    int64 // 合计折算价值
+	Lines    []CollateralLine
+	Unpriced []string // 有余额但拿不到可用价格、未计入的币种
+}
+
+// Valuate 估值用户在结算币种 settle 下的全部抵押品
+func (s *CollateralService) Valuate(ctx context.Context, userID int64, settle string) (*CollateralValuation, error) {
+	v := &CollateralValuation{UserID: userID, Settle: settle}
+	for _, a := range s.cfg.Assets {
+		if a.Settle != settle {
+			continue
+		}
+		balance, err := s.balances.GetBalance(ctx, userID, a.Asset)
+		if err != nil {
+			return nil, err
+		}
+		if balance == nil || balance.Available <= 0 {
+			continue
+		}
+		q, err := s.Quote(a.Asset, settle)
+		if errors.Is(err, ErrCollateralPriceUnavailable) {
+			v.Unpriced = append(v.Unpriced, a.Asset)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line := CollateralLine{Quote: q, Amount: balance.Available, Value: q.Value(balance.Available)}
+		v.Value += line.Value
+		v.Lines = append(v.Lines, line)
+	}
+	return v, nil
+}
+
+// collateralValue 用户在 settle 下的抵押价值，未配置抵押品服务时为 0
+func collateralValue(ctx context.Context, s *CollateralService, userID int64, settle string) (int64, error) {
+	if s == nil {
+		return 0, nil
+	}
+	v, err := s.Valuate(ctx, userID, settle)
+	if err != nil {
+		return 0, err
+	}
+	return v.Value, nil
+}
@@ -0,0 +1,132 @@
+// 文件: pkg/futures/collateral_test.go
+// 多币种抵押品估值测试 (内存夹具，见 harness_test.go)
+
+package futures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/indexprice"
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+)
+
+// fakeIndex 固定的指数价格
+type fakeIndex map[string]indexprice.Index
+
+func (f fakeIndex) Index(symbol string) (indexprice.Index, bool) {
+	idx, ok := f[symbol]
+	return idx, ok
+}
+
+func TestCollateralService_Quote(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	index := fakeIndex{
+		"BTC/USDT": {Price: 50000 * Precision, UpdatedAt: now.UnixMilli()},
+		"ETH/USDT": {Price: 2000 * Precision, UpdatedAt: now.Add(-time.Minute).UnixMilli()},
+		"SOL/USDT": {Price: 100 * Precision, UpdatedAt: now.Add(-time.Hour).UnixMilli()},
+	}
+	svc, err := NewCollateralService(CollateralConfig{Assets: []CollateralAsset{
+		{Asset: "BTC", Settle: "USDT", Haircut: 500},
+		{Asset: "ETH", Settle: "USDT", Haircut: 1000},
+		{Asset: "SOL", Settle: "USDT", Haircut: 2000},
+		{Asset: "USDT", Settle: "BTC", Haircut: 0, IndexSymbol: "BTC/USDT", Invert: true},
+	}}, index, newMemLedger())
+	require.NoError(t, err)
+	svc.SetClock(func() time.Time { return now })
+
+	// 新鲜指数：2 BTC × 50000 × 95%
+	v, err := svc.Convert("BTC", "USDT", 2*Precision)
+	require.NoError(t, err)
+	assert.Equal(t, int64(95000*Precision), v)
+
+	// 过旧 (1 分钟 > 30s)：额外折价 5%
+	q, err := svc.Quote("ETH", "USDT")
+	require.NoError(t, err)
+	assert.True(t, q.Stale)
+	assert.Equal(t, int64(1500), q.Haircut)
+	assert.Equal(t, int64(1700*Precision), q.Value(Precision))
+
+	// 超过 MaxStale：不可用
+	_, err = svc.Quote("SOL", "USDT")
+	assert.ErrorIs(t, err, ErrCollateralPriceUnavailable)
+
+	// 币本位合约用 USDT 抵押：BTC/USDT 指数取倒数
+	v, err = svc.Convert("USDT", "BTC", 25000*Precision)
+	require.NoError(t, err)
+	assert.Equal(t, int64(Precision/2), v)
+
+	_, err = svc.Quote("DOGE", "USDT")
+	assert.ErrorIs(t, err, ErrCollateralNotAccepted)
+
+	_, err = NewCollateralService(CollateralConfig{Assets: []CollateralAsset{{Asset: "BTC", Settle: "USDT", Haircut: RatePrecision}}}, index, nil)
+	assert.ErrorIs(t, err, ErrInvalidCollateral)
+}
+
+func TestHarness_CollateralMarginAndLiquidationSizing(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	spec := harnessLinearSpec()
+	spec.MaintMarginRate = 100 // 1%
+	spec.MinOrderQty = Precision / 100
+	h := newHarness(t, spec)
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 1000*Precision)
+	h.ledger.AddAvailable(h.ctx, 1, "ETH", Precision) // 折算 2000 × 90% = 1800
+	h.ledger.AddAvailable(h.ctx, 1, "SOL", Precision) // 指数过期，不计入
+
+	now := time.Now()
+	index := fakeIndex{
+		"ETH/USDT": {Price: 2000 * Precision, UpdatedAt: now.UnixMilli()},
+		"SOL/USDT": {Price: 100 * Precision, UpdatedAt: now.Add(-time.Hour).UnixMilli()},
+	}
+	collateral, err := NewCollateralService(CollateralConfig{Assets: []CollateralAsset{
+		{Asset: "ETH", Settle: "USDT", Haircut: 1000},
+		{Asset: "SOL", Settle: "USDT", Haircut: 1000},
+	}}, index, h.ledger)
+	require.NoError(t, err)
+
+	valuation, err := collateral.Valuate(h.ctx, 1, "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1800*Precision), valuation.Value)
+	assert.Equal(t, []string{"SOL"}, valuation.Unpriced)
+
+	// 多 1 BTC @50000，保证金 1000 (50 倍)，标记价 49500：浮亏 500
+	pos := &Position{UserID: 1, Symbol: symbol, Size: Precision, EntryPrice: 50000 * Precision, Margin: 1000 * Precision, Leverage: 50}
+	require.NoError(t, h.positions.Save(h.ctx, pos))
+	proc.UpdateMarkPrice(symbol, 49500*Precision)
+
+	m, err := proc.AccountMargin(h.ctx, 1, "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(500*Precision), m.AvailableForOrders) // 没有抵押品：浮亏 500 吃掉一半
+	proc.SetCollateral(collateral)
+	m, err = proc.AccountMargin(h.ctx, 1, "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1800*Precision), m.CollateralValue)
+	assert.Equal(t, int64(1000*Precision), m.AvailableForOrders) // 抵押品吸收浮亏，仍以 USDT 可用为上限
+
+	// 标记价跌到 47500：持仓权益 1000 − 2500 = −1500，维持保证金 475
+	// 保留 k BTC 需 −1500k + 1800 ≥ 475 × 1.1 × k → k ≤ 0.89，强平 0.11
+	marks := NewMarkPriceService()
+	marks.UpdateMarkPrice(symbol, 47500*Precision)
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, marks, nil, nil)
+	task := liquidation.LiquidationTask{UserID: 1, Symbol: symbol}
+
+	preview, err := executor.Preview(h.ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, int64(Precision), preview.Qty) // 未配置抵押品：全部强平
+
+	executor.SetCollateral(collateral)
+	preview, err = executor.Preview(h.ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, mtrade.SideSell, preview.Side)
+	assert.InDelta(t, 11*Precision/100, preview.Qty, float64(Precision/1000))
+
+	// 标记价回到 49500：抵押品撑得住全部持仓，不用强平
+	marks.UpdateMarkPrice(symbol, 49500*Precision)
+	_, err = executor.Preview(h.ctx, task)
+	assert.ErrorIs(t, err, ErrLiquidationNotNeeded)
+}
@@ -0,0 +1,310 @@
+// 文件: pkg/futures/dryrun_test.go
+// 交割 / 强平 dry-run 测试 (内存仓储，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 内存仓储
+// =============================================================================
+
+type memContractRepo struct {
+	mu    sync.Mutex
+	specs map[string]*ContractSpec
+}
+
+func newMemContractRepo(specs ...*ContractSpec) *memContractRepo {
+	r := &memContractRepo{specs: make(map[string]*ContractSpec)}
+	for _, s := range specs {
+		r.specs[s.Symbol] = s
+	}
+	return r
+}
+
+func (r *memContractRepo) Create(ctx context.Context, spec *ContractSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.specs[spec.Symbol]; ok {
+		return ErrSymbolExists
+	}
+	r.specs[spec.Symbol] = spec
+	return nil
+}
+
+func (r *memContractRepo) GetBySymbol(ctx context.Context, symbol string) (*ContractSpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[symbol]
+	if !ok {
+		return nil, ErrSymbolNotFound
+	}
+	cp := *spec
+	return &cp, nil
+}
+
+func (r *memContractRepo) Update(ctx context.Context, spec *ContractSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs[spec.Symbol] = spec
+	return nil
+}
+
+func (r *memContractRepo) UpdateStatus(ctx context.Context, symbol string, from, to ContractStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[symbol]
+	if !ok {
+		return ErrSymbolNotFound
+	}
+	spec.Status = to
+	return nil
+}
+
+func (r *memContractRepo) List(ctx context.Context) ([]*ContractSpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*ContractSpec
+	for _, s := range r.specs {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (r *memContractRepo) ListByStatus(ctx context.Context, status ContractStatus) ([]*ContractSpec, error) {
+	all, _ := r.List(ctx)
+	var out []*ContractSpec
+	for _, s := range all {
+		if s.Status == status {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (r *memContractRepo) Delete(ctx context.Context, symbol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.specs, symbol)
+	return nil
+}
+
+type memPositionKey struct {
+	userID int64
+	symbol string
+}
+
+type memPositionRepo struct {
+	mu     sync.Mutex
+	saves  int
+	nextID uint
+	pos    map[memPositionKey]*Position
+}
+
+func newMemPositionRepo(positions ...*Position) *memPositionRepo {
+	r := &memPositionRepo{pos: make(map[memPositionKey]*Position)}
+	for _, p := range positions {
+		r.pos[memPositionKey{p.UserID, p.Symbol}] = p
+		r.nextID = max(r.nextID, p.ID)
+	}
+	return r
+}
+
+func (r *memPositionRepo) GetByUserAndSymbol(ctx context.Context, userID int64, symbol string) (*Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pos[memPositionKey{userID, symbol}]
+	if !ok {
+		return nil, nil
+	}
+	cp := *p
+	return &cp, nil
+}
+
+func (r *memPositionRepo) GetByUser(ctx context.Context, userID int64) ([]*Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*Position
+	for k, p := range r.pos {
+		if k.userID == userID {
+			cp := *p
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out, nil
+}
+
+func (r *memPositionRepo) Save(ctx context.Context, pos *Position) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saves++
+	if pos.ID == 0 {
+		r.nextID++
+		pos.ID = r.nextID
+	}
+	cp := *pos
+	r.pos[memPositionKey{pos.UserID, pos.Symbol}] = &cp
+	return nil
+}
+
+func (r *memPositionRepo) Delete(ctx context.Context, userID int64, symbol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pos, memPositionKey{userID, symbol})
+	return nil
+}
+
+func (r *memPositionRepo) AddFunding(ctx context.Context, userID int64, symbol string, amount int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pos[memPositionKey{userID, symbol}]; ok {
+		p.CycleFunding += amount
+	}
+	return nil
+}
+
+func (r *memPositionRepo) AddMargin(ctx context.Context, userID int64, symbol string, amount int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pos[memPositionKey{userID, symbol}]
+	if !ok || p.Size == 0 {
+		return ErrNoPosition
+	}
+	p.Margin += amount
+	return nil
+}
+
+func (r *memPositionRepo) ListBySymbol(ctx context.Context, symbol string, limit, offset int) ([]*Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []*Position
+	for _, p := range r.pos {
+		if p.Symbol == symbol {
+			cp := *p
+			all = append(all, &cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].UserID < all[j].UserID })
+	if offset >= len(all) {
+		return nil, nil
+	}
+	return all[offset:min(offset+limit, len(all))], nil
+}
+
+func (r *memPositionRepo) ListBySymbolAfter(ctx context.Context, symbol string, afterID uint, limit int) ([]*Position, error) {
+	all, _ := r.ListBySymbol(ctx, symbol, 1<<30, 0)
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	var out []*Position
+	for _, p := range all {
+		if p.ID > afterID && p.Size != 0 && len(out) < limit {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// =============================================================================
+// 测试
+// =============================================================================
+
+const dryRunSymbol = "TESTBTCUSDT0628"
+
+func dryRunFixture() (*ContractManager, *memPositionRepo, *MarkPriceService) {
+	contracts := newMemContractRepo(&ContractSpec{
+		Symbol:         dryRunSymbol,
+		ContractType:   TypeDelivery,
+		SettleCurrency: "USDT",
+		Status:         StatusTrading,
+		ExpiryAt:       time.Now().Add(24 * time.Hour).UnixMilli(), // 未到期也能预演
+	})
+	positions := newMemPositionRepo(
+		// 多头 1 张，开仓 50000，保证金 5000
+		&Position{ID: 1, UserID: 1, Symbol: dryRunSymbol, Size: Precision, EntryPrice: 50000, Margin: 5000},
+		// 空头 2 张，开仓 52000，保证金 1000 → 结算价 55000 时穿仓
+		&Position{ID: 2, UserID: 2, Symbol: dryRunSymbol, Size: -2 * Precision, EntryPrice: 52000, Margin: 1000},
+	)
+	markPrices := NewMarkPriceService()
+	markPrices.UpdateMarkPrice(dryRunSymbol, 55000)
+	return NewContractManager(contracts), positions, markPrices
+}
+
+func TestSettleContract_DryRun(t *testing.T) {
+	manager, positions, markPrices := dryRunFixture()
+	engine := NewSettlementEngine(nil, manager, positions, nil, markPrices)
+
+	report, err := engine.SettleContract(context.Background(), dryRunSymbol, true)
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, int64(55000), report.SettlementPrice)
+	require.Len(t, report.Entries, 2)
+
+	long, short := report.Entries[0], report.Entries[1]
+	assert.Equal(t, int64(5000), long.PnL)
+	assert.Equal(t, int64(10000), long.SettlementAmount)
+	assert.Equal(t, int64(-6000), short.PnL)
+	assert.Equal(t, int64(0), short.SettlementAmount)
+	assert.Equal(t, int64(5000), short.Shortfall)
+
+	assert.Equal(t, int64(-1000), report.TotalPnL)
+	assert.Equal(t, int64(10000), report.TotalReturned)
+	assert.Equal(t, int64(5000), report.TotalShortfall)
+
+	// 持仓和合约状态都没有变化
+	assert.Equal(t, 0, positions.saves)
+	spec, _ := manager.GetContract(context.Background(), dryRunSymbol)
+	assert.Equal(t, StatusTrading, spec.Status)
+	assert.False(t, engine.IsSettling(dryRunSymbol))
+
+	// 正式交割仍然要求到期
+	_, err = engine.SettleContract(context.Background(), dryRunSymbol, false)
+	assert.ErrorIs(t, err, ErrContractNotExpired)
+}
+
+func TestLiquidationExecutor_DryRun(t *testing.T) {
+	manager, positions, markPrices := dryRunFixture()
+	matchEngine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig(dryRunSymbol))
+	require.NoError(t, err)
+	executor := NewLiquidationExecutor(manager, matchEngine, positions, nil, markPrices, nil, nil)
+
+	task := liquidation.LiquidationTask{UserID: 2, Symbol: dryRunSymbol, DryRun: true}
+
+	preview, err := executor.Preview(context.Background(), task)
+	require.NoError(t, err)
+	assert.Equal(t, mtrade.SideBuy, preview.Side)
+	assert.Equal(t, int64(2*Precision), preview.Qty)
+	assert.Equal(t, int64(52500), preview.BankruptPrice) // 52000 + 1000/2
+	assert.Equal(t, int64(52500), preview.EstimatedFillPrice)
+	assert.Equal(t, int64(-1000), preview.EstimatedPnL)
+	assert.Equal(t, int64(0), preview.EstimatedRemaining)
+
+	result := executor.Execute(context.Background(), task)
+	require.NoError(t, result.Error)
+	assert.True(t, result.Success)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 1, result.Details.ClosedPositions)
+	assert.Equal(t, float64(-1000), result.Details.TotalPnL)
+
+	// 没有下单，也没有改持仓
+	assert.Equal(t, 0, positions.saves)
+	assert.Equal(t, int64(0), matchEngine.GetStats().OrdersReceived)
+	pending := 0
+	executor.pendingTasks.Range(func(_, _ any) bool { pending++; return true })
+	assert.Equal(t, 0, pending)
+
+	_, err = executor.Preview(context.Background(), liquidation.LiquidationTask{UserID: 3, Symbol: dryRunSymbol})
+	assert.Error(t, err)
+}
@@ -0,0 +1,244 @@
+// 文件: pkg/futures/execution_guard.go
+// 最优执行保护 - 限价不得偏离指数 / 标记价格太远
+//
+// 【问题】盘口薄时内部价格可能和外部指数差得很远：
+//   - 市价单保护价按标记价格 ± 滑点算 (见 market_order.go)，用户把滑点放得很大，
+//     或标记价格的基差被内部盘口带偏时，照样成交在离谱的价格
+//   - 强平单按盘口扫单价定价 (见 book_price.go)，只受破产价约束
+//
+// 【做法】每个合约配置最大执行偏离 (万分比)，执行边界 = 参考价 × (1 ± 偏离)，对齐 TickSize：
+//   - 参考价取指数价格和标记价格，两个边界取更保守的那个 (没有指数时只用标记价格)
+//   - 市价单 (开仓 / 平仓) 限价超出边界：转成以边界价挂的限价单 (按合约最长存活到期)
+//   - 强平单限价超出边界：截到边界价，吃不满的部分挂着等对手盘，由看门狗兜底
+//   - 每次转换发 ExecutionGuardEvent，写明原限价、边界价和定边界的参考价
+//
+// 指数与标记价格本身偏离超过边界 (外部指数和内部价格交叉，说明有一边失真)：
+// 市价单直接拒绝 (ErrIndexDislocated，可重试)；强平单不能停，只按标记价格定边界
+//
+// 【注意】边界只约束限价，真实成交价由撮合决定，只会更好不会更差
+
+package futures
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+)
+
+// DefaultExecutionBand 默认最大执行偏离 (万分比)：偏离参考价 5%
+const DefaultExecutionBand = 500
+
+var (
+	ErrInvalidExecutionBand = cexerr.New("FUTURES_INVALID_EXECUTION_BAND", cexerr.CategoryInvalidArgument, "invalid execution band")
+	ErrIndexDislocated      = cexerr.NewRetryable("FUTURES_INDEX_DISLOCATED", cexerr.CategoryUnavailable, "index price dislocated from mark price")
+)
+
+// GuardOrderKind 被执行保护约束的订单类型
+type GuardOrderKind string
+
+const (
+	GuardMarketOpen  GuardOrderKind = "MARKET_OPEN"  // 市价开仓
+	GuardMarketClose GuardOrderKind = "MARKET_CLOSE" // 市价平仓
+	GuardLiquidation GuardOrderKind = "LIQUIDATION"  // 强平单
+)
+
+// 定边界的参考价
+const (
+	GuardRefIndex = "INDEX"
+	GuardRefMark  = "MARK"
+)
+
+// ExecutionGuardConfig 执行保护配置
+type ExecutionGuardConfig struct {
+	DefaultBand int64            // 最大执行偏离 (万分比)，0 使用 DefaultExecutionBand
+	Bands       map[string]int64 // 按合约覆盖 (symbol → 万分比)
+}
+
+func (c ExecutionGuardConfig) withDefaults() ExecutionGuardConfig {
+	if c.DefaultBand == 0 {
+		c.DefaultBand = DefaultExecutionBand
+	}
+	return c
+}
+
+// ExecutionGuardEvent 订单被执行保护改价
+type ExecutionGuardEvent struct {
+	Kind       GuardOrderKind
+	OrderID    int64
+	UserID     int64
+	Symbol     string
+	Side       Side
+	Requested  int64  // 原限价 (市价单的保护价 / 强平单的扫单价)
+	Bound      int64  // 改价后的限价
+	Reference  string // 定边界的参考价 (GuardRefIndex / GuardRefMark)
+	IndexPrice int64  // 0 表示没有指数
+	MarkPrice  int64
+	Band       int64 // 最大执行偏离 (万分比)
+	Dislocated bool  // 指数与标记价格偏离超过边界，只按标记价格定边界 (仅强平单)
+	At         int64 // 毫秒
+}
+
+// Reason 给用户 / 运营看的转换说明
+func (e ExecutionGuardEvent) Reason() string {
+	ref := e.MarkPrice
+	if e.Reference == GuardRefIndex {
+		ref = e.IndexPrice
+	}
+	reason := fmt.Sprintf("%s price %d exceeds %d bps from %s price %d, converted to limit at %d",
+		e.Kind, e.Requested, e.Band, e.Reference, ref, e.Bound)
+	if e.Dislocated {
+		reason += fmt.Sprintf(" (index %d dislocated from mark, mark band only)", e.IndexPrice)
+	}
+	return reason
+}
+
+// ExecutionGuard 最优执行保护 (可选)，FuturesProcessor 与 LiquidationExecutor 共用
+type ExecutionGuard struct {
+	prices *MarkPriceService
+	now    func() time.Time
+
+	mu          sync.RWMutex
+	cfg         ExecutionGuardConfig
+	onConverted []func(ExecutionGuardEvent)
+
+	converted atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// NewExecutionGuard 创建执行保护，参考价从 prices 读取
+func NewExecutionGuard(prices *MarkPriceService, cfg ExecutionGuardConfig) (*ExecutionGuard, error) {
+	cfg = cfg.withDefaults()
+	if !validBand(cfg.DefaultBand) {
+		return nil, ErrInvalidExecutionBand.Wrapf("default band %d", cfg.DefaultBand)
+	}
+	bands := make(map[string]int64, len(cfg.Bands))
+	for symbol, band := range cfg.Bands {
+		if !validBand(band) {
+			return nil, ErrInvalidExecutionBand.Wrapf("%s band %d", symbol, band)
+		}
+		bands[symbol] = band
+	}
+	cfg.Bands = bands
+	return &ExecutionGuard{prices: prices, now: time.Now, cfg: cfg}, nil
+}
+
+func validBand(band int64) bool {
+	return band > 0 && band < RatePrecision
+}
+
+// SetBand 设置合约的最大执行偏离 (万分比)
+func (g *ExecutionGuard) SetBand(symbol string, band int64) error {
+	if !validBand(band) {
+		return ErrInvalidExecutionBand.Wrapf("%s band %d", symbol, band)
+	}
+	g.mu.Lock()
+	g.cfg.Bands[symbol] = band
+	g.mu.Unlock()
+	return nil
+}
+
+// Band 合约的最大执行偏离 (万分比)
+func (g *ExecutionGuard) Band(symbol string) int64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if band, ok := g.cfg.Bands[symbol]; ok {
+		return band
+	}
+	return g.cfg.DefaultBand
+}
+
+// OnConverted 注册改价回调 (通知、监控)，在下单 goroutine 中同步执行
+func (g *ExecutionGuard) OnConverted(callback func(ExecutionGuardEvent)) {
+	g.mu.Lock()
+	g.onConverted = append(g.onConverted, callback)
+	g.mu.Unlock()
+}
+
+// Stats 累计改价 / 拒单次数
+func (g *ExecutionGuard) Stats() (converted, rejected uint64) {
+	return g.converted.Load(), g.rejected.Load()
+}
+
+// executionBound 某个方向的执行边界
+type executionBound struct {
+	price      int64 // 边界价，0 表示没有参考价 (不约束)
+	reference  string
+	index      int64
+	mark       int64
+	band       int64
+	dislocated bool
+}
+
+// bound 计算 side 方向的执行边界：指数与标记价格各算一个，取更保守的
+func (g *ExecutionGuard) bound(spec *ContractSpec, side Side) executionBound {
+	b := executionBound{
+		index: g.prices.GetIndexPrice(spec.Symbol),
+		mark:  g.prices.GetMarkPrice(spec.Symbol),
+		band:  g.Band(spec.Symbol),
+	}
+	if b.mark > 0 {
+		b.price, b.reference = protectedPrice(spec, side, b.mark, b.band), GuardRefMark
+	}
+	if b.index <= 0 {
+		return b
+	}
+	if b.mark > 0 && fixed.MulDiv(absInt64(b.mark-b.index), RatePrecision, b.index) > b.band {
+		b.dislocated = true
+		return b
+	}
+	if ib := protectedPrice(spec, side, b.index, b.band); b.price <= 0 || tighter(side, b.price, ib) != b.price {
+		b.price, b.reference = ib, GuardRefIndex
+	}
+	return b
+}
+
+// check 限价 price 是否在执行边界内，超出时返回 converted = true，新限价为 b.price
+//
+// 市价单遇到指数失真直接拒单；强平单只按标记价格定边界
+func (g *ExecutionGuard) check(spec *ContractSpec, kind GuardOrderKind, side Side, price int64) (b executionBound, converted bool, err error) {
+	if g == nil {
+		return executionBound{}, false, nil
+	}
+	b = g.bound(spec, side)
+	if b.dislocated && kind != GuardLiquidation {
+		g.rejected.Add(1)
+		return b, false, ErrIndexDislocated.Wrapf("%s index=%d mark=%d band=%d", spec.Symbol, b.index, b.mark, b.band)
+	}
+	if b.price <= 0 || within(side, price, b.price) {
+		return b, false, nil
+	}
+	return b, true, nil
+}
+
+// emit 记一次改价并通知回调 (订单已提交后调用，此时才有订单 ID)
+func (g *ExecutionGuard) emit(kind GuardOrderKind, userID, orderID int64, spec *ContractSpec, side Side, requested int64, b executionBound) {
+	ev := ExecutionGuardEvent{
+		Kind:       kind,
+		OrderID:    orderID,
+		UserID:     userID,
+		Symbol:     spec.Symbol,
+		Side:       side,
+		Requested:  requested,
+		Bound:      b.price,
+		Reference:  b.reference,
+		IndexPrice: b.index,
+		MarkPrice:  b.mark,
+		Band:       b.band,
+		Dislocated: b.dislocated,
+		At:         g.now().UnixMilli(),
+	}
+	g.converted.Add(1)
+	log.Printf("[ExecutionGuard] order=%d user=%d %s: %s", orderID, userID, spec.Symbol, ev.Reason())
+
+	g.mu.RLock()
+	callbacks := g.onConverted
+	g.mu.RUnlock()
+	for _, cb := range callbacks {
+		cb(ev)
+	}
+}
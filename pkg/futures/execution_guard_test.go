@@ -0,0 +1,153 @@
+// 文件: pkg/futures/execution_guard_test.go
+// 最优执行保护测试
+
+package futures
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+func TestExecutionGuard_Bound(t *testing.T) {
+	spec := &ContractSpec{Symbol: "BTCUSDT", TickSize: 10 * Precision}
+	prices := NewMarkPriceService()
+	guard, err := NewExecutionGuard(prices, ExecutionGuardConfig{Bands: map[string]int64{"BTCUSDT": 100}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), guard.Band("BTCUSDT"))
+	assert.Equal(t, int64(DefaultExecutionBand), guard.Band("ETHUSDT"))
+
+	// 没有参考价：不约束
+	_, converted, err := guard.check(spec, GuardMarketOpen, SideLong, 90000*Precision)
+	require.NoError(t, err)
+	assert.False(t, converted)
+
+	// 只有标记价格
+	prices.UpdateMarkPrice("BTCUSDT", 50000*Precision)
+	b, converted, err := guard.check(spec, GuardMarketOpen, SideLong, 52000*Precision)
+	require.NoError(t, err)
+	assert.True(t, converted)
+	assert.Equal(t, int64(50500*Precision), b.price)
+	assert.Equal(t, GuardRefMark, b.reference)
+
+	// 指数 49800：买单指数边界 50298 → 50290 更保守，卖单标记边界 49500 更保守
+	prices.UpdateIndexPrice("BTCUSDT", 49800*Precision)
+	b, converted, _ = guard.check(spec, GuardMarketOpen, SideLong, 50400*Precision)
+	assert.True(t, converted)
+	assert.Equal(t, int64(50290*Precision), b.price)
+	assert.Equal(t, GuardRefIndex, b.reference)
+	b, converted, _ = guard.check(spec, GuardMarketClose, SideShort, 49600*Precision)
+	assert.False(t, converted)
+	assert.Equal(t, int64(49500*Precision), b.price)
+	assert.Equal(t, GuardRefMark, b.reference)
+
+	// 指数偏离标记价格 2% > 1%：市价单拒绝，强平单只按标记价格定边界
+	prices.UpdateIndexPrice("BTCUSDT", 49000*Precision)
+	_, _, err = guard.check(spec, GuardMarketOpen, SideLong, 50100*Precision)
+	assert.ErrorIs(t, err, ErrIndexDislocated)
+	b, converted, err = guard.check(spec, GuardLiquidation, SideShort, 48000*Precision)
+	require.NoError(t, err)
+	assert.True(t, converted)
+	assert.True(t, b.dislocated)
+	assert.Equal(t, int64(49500*Precision), b.price)
+	_, rejected := guard.Stats()
+	assert.Equal(t, uint64(1), rejected)
+
+	// 配置校验
+	assert.ErrorIs(t, guard.SetBand("BTCUSDT", RatePrecision), ErrInvalidExecutionBand)
+	_, err = NewExecutionGuard(prices, ExecutionGuardConfig{Bands: map[string]int64{"X": -1}})
+	assert.ErrorIs(t, err, ErrInvalidExecutionBand)
+}
+
+func TestHarness_ExecutionGuardConvertsMarketOrder(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	for uid := int64(1); uid <= 3; uid++ {
+		h.ledger.AddAvailable(h.ctx, uid, "USDT", 20000*Precision)
+	}
+	proc.UpdateMarkPrice(symbol, 50000*Precision)
+	proc.GetMarkPriceService().UpdateIndexPrice(symbol, 50000*Precision)
+
+	guard, err := NewExecutionGuard(proc.GetMarkPriceService(), ExecutionGuardConfig{DefaultBand: 100})
+	require.NoError(t, err)
+	proc.SetExecutionGuard(guard)
+	var events []ExecutionGuardEvent
+	guard.OnConverted(func(ev ExecutionGuardEvent) { events = append(events, ev) })
+
+	// 卖盘: 0.5 @ 50200, 1 @ 51000 (薄盘口)
+	for _, ask := range []struct{ uid, qty, price int64 }{{2, Precision / 2, 50200}, {3, Precision, 51000}} {
+		require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+			UserID: ask.uid, Symbol: symbol, Side: SideShort, Qty: ask.qty, Price: ask.price * Precision, Leverage: 10,
+		}))
+	}
+
+	// 市价买 1，滑点放到 10%：保护价 55000 超出 1% 边界，转成 50500 限价单
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Leverage: 10, Type: OrderTypeMarket, MaxSlippage: 1000,
+	}))
+	h.waitFor(symbol, mtrade.EventTrade, 1)
+
+	require.Len(t, events, 1)
+	ev := events[0]
+	assert.Equal(t, GuardMarketOpen, ev.Kind)
+	assert.Equal(t, int64(55000*Precision), ev.Requested)
+	assert.Equal(t, int64(50500*Precision), ev.Bound)
+	assert.Equal(t, GuardRefMark, ev.Reference)
+	assert.NotEmpty(t, ev.Reason())
+
+	// 只吃到 50200 的 0.5，剩余 0.5 挂在 50500，51000 的卖单没被吃到
+	pos := h.position(1, symbol)
+	require.NotNil(t, pos)
+	assert.Equal(t, int64(Precision/2), pos.Size)
+	assert.Nil(t, h.position(3, symbol))
+	o, err := h.orders.GetByOrderID(h.ctx, ev.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, order.OrderTypeLimit, o.OrderType)
+	assert.Equal(t, int64(50500*Precision), o.Price)
+	_, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(5050*Precision), locked)
+
+	// 指数失真：市价单拒绝，不冻结
+	proc.GetMarkPriceService().UpdateIndexPrice(symbol, 48000*Precision)
+	err = proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Leverage: 10, Type: OrderTypeMarket,
+	})
+	assert.ErrorIs(t, err, ErrIndexDislocated)
+	_, locked = h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(5050*Precision), locked)
+}
+
+func TestExecutionGuard_LiquidationClampedToBound(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	prices := NewMarkPriceService()
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, prices, nil, nil)
+
+	// 多 1 BTC @50000，保证金 5000 (破产价 45000)，对手盘为空
+	require.NoError(t, h.positions.Save(h.ctx, &Position{UserID: 1, Symbol: symbol, Size: Precision, EntryPrice: 50000 * Precision, Margin: 5000 * Precision, Leverage: 10}))
+	prices.UpdateMarkPrice(symbol, 49000*Precision)
+	task := liquidation.LiquidationTask{UserID: 1, Symbol: symbol}
+
+	// 未配置：按标记价格 − 1% 定价
+	preview, err := executor.Preview(context.Background(), task)
+	require.NoError(t, err)
+	assert.Equal(t, int64(48510*Precision), preview.OrderPrice)
+
+	// 边界 0.5%：截到 48755；指数失真时强平照常，只按标记价格定边界
+	guard, err := NewExecutionGuard(prices, ExecutionGuardConfig{DefaultBand: 50})
+	require.NoError(t, err)
+	executor.SetExecutionGuard(guard)
+	for _, index := range []int64{49000, 46000} {
+		prices.UpdateIndexPrice(symbol, index*Precision)
+		preview, err = executor.Preview(context.Background(), task)
+		require.NoError(t, err)
+		assert.Equal(t, int64(48755*Precision), preview.OrderPrice, "index %d", index)
+	}
+}
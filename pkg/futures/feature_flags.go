@@ -0,0 +1,16 @@
+// 文件: pkg/futures/feature_flags.go
+// 功能开关 - 合约侧高风险路径按合约 / 用户比例灰度，出问题一键关闭 (见 pkg/featureflag)
+
+package futures
+
+import "max.com/pkg/featureflag"
+
+// FlagMarketOrder 市价开仓 (见 market_order.go)
+//
+// 【注意】只拦开仓：市价平仓是用户止损的唯一手段，关掉会把人锁在仓位里
+var FlagMarketOrder = featureflag.Define("futures.market_order", true)
+
+// SetFeatureFlags 设置功能开关，不设置时全部取默认值
+func (p *FuturesProcessor) SetFeatureFlags(flags *featureflag.Flags) {
+	p.flags = flags
+}
@@ -7,8 +7,10 @@
 // 简化版 (大多数交易所用这个):
 // 资金费率 = Clamp((合约价格 - 现货价格) / 现货价格, -0.75%, 0.75%)
 //
+// 上下限、利率、结算间隔按合约配置 (ContractSpec，见 funding_params.go)，未配置时用下面的常量
+//
 // 【结算周期】
-// 每 8 小时结算一次: 00:00, 08:00, 16:00 UTC
+// 默认每 8 小时结算一次: 00:00, 08:00, 16:00 UTC，1 小时合约每个整点结算
 //
 // 【资金费公式】
 // 资金费 = 持仓价值 × 资金费率
@@ -26,6 +28,8 @@ import (
 	"sync"
 	"time"
 
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
 	"max.com/pkg/fund"
 )
 
@@ -41,11 +45,11 @@ const (
 	// 这是借贷市场的无风险利率
 	DefaultInterestRate = 10 // 万分之一 = 0.01%
 
-	// MaxFundingRate 最大资金费率 (±0.75%)
+	// MaxFundingRate 最大资金费率 (±0.75%)，合约未配置 MaxFundingRate 时使用
 	MaxFundingRate = 75 // 万分之75 = 0.75%
 
 	// 精度
-	FundingPrecision = 10000 // 万分比
+	FundingPrecision = fixed.BpsScale // 万分比，与 RatePrecision 同一精度
 )
 
 // =============================================================================
@@ -53,8 +57,8 @@ const (
 // =============================================================================
 
 var (
-	ErrFundingInProgress = errors.New("funding settlement in progress")
-	ErrNoFundingDue      = errors.New("no funding settlement due")
+	ErrFundingInProgress = cexerr.NewRetryable("FUTURES_FUNDING_IN_PROGRESS", cexerr.CategoryConflict, "funding settlement in progress")
+	ErrNoFundingDue      = cexerr.New("FUTURES_NO_FUNDING_DUE", cexerr.CategoryFailedPrecondition, "no funding settlement due")
 )
 
 // =============================================================================
@@ -84,6 +88,16 @@ type FundingService struct {
 	// 结算锁 (防止同一合约并发结算)
 	settlingSymbols sync.Map
 
+	// 结算游标 (可选)：断点续跑，见 funding_cursor.go
+	cursors FundingCursorStore
+
+	// 资金费率历史 (可选)，见 market_history.go
+	history MarketHistoryRepository
+
+	// 结算完成回调 (见 OnSettled)
+	callbackMu sync.RWMutex
+	onSettled  []func(*FundingReport)
+
 	// 配置
 	batchSize   int
 	workerCount int
@@ -111,6 +125,12 @@ func NewFundingService(
 	}
 }
 
+// SetCursorStore 设置结算游标存储
+// 不设置时宕机中断的那一期不会自动续跑 (重启后下次结算时间已跳到下一期)
+func (s *FundingService) SetCursorStore(store FundingCursorStore) {
+	s.cursors = store
+}
+
 // =============================================================================
 // 生命周期
 // =============================================================================
@@ -169,24 +189,32 @@ func (s *FundingService) GetNextFundingTime(symbol string) int64 {
 	return 0
 }
 
-// CalculateFundingRate 计算资金费率
+// CalculateFundingRate 计算资金费率 (按合约配置的利率和上下限)
 //
 // 【公式】
 // 溢价指数 = (合约价格 - 现货价格) / 现货价格
-// 资金费率 = Clamp(溢价指数, -MaxRate, MaxRate)
+// 资金费率 = spec.FundingRateOf(溢价指数)
 //
 // 【面试考点】
 // Q: 为什么要 Clamp 限制范围？
 // A: 防止极端行情下资金费过高，导致用户仓位被大量扣款
 func (s *FundingService) CalculateFundingRate(symbol string) int64 {
+	spec, err := s.contractManager.GetContract(context.Background(), symbol)
+	if err != nil {
+		return 0
+	}
+	return s.calculateFundingRate(spec)
+}
+
+func (s *FundingService) calculateFundingRate(spec *ContractSpec) int64 {
 	// 1. 获取合约价格 (使用标记价格或订单簿中间价)
-	contractPrice := s.markPriceService.GetMarkPrice(symbol)
+	contractPrice := s.markPriceService.GetMarkPrice(spec.Symbol)
 	if contractPrice <= 0 {
 		return 0
 	}
 
 	// 2. 获取现货价格 (指数价格)
-	indexPrice := s.markPriceService.GetIndexPrice(symbol)
+	indexPrice := s.markPriceService.GetIndexPrice(spec.Symbol)
 	if indexPrice <= 0 {
 		return 0
 	}
@@ -194,17 +222,10 @@ func (s *FundingService) CalculateFundingRate(symbol string) int64 {
 	// 3. 计算溢价指数
 	// premiumIndex = (contractPrice - indexPrice) / indexPrice
 	// 转换为万分比: premiumIndex * 10000
-	premiumIndex := (contractPrice - indexPrice) * FundingPrecision / indexPrice
+	premiumIndex := fixed.RatioBps(contractPrice-indexPrice, indexPrice)
 
-	// 4. 加上利率基差 (通常很小，可以忽略)
-	// fundingRate = premiumIndex + (interestRate - premiumIndex) * dampening
-	// 简化: fundingRate ≈ premiumIndex
-	fundingRate := premiumIndex
-
-	// 5. Clamp 到合理范围
-	fundingRate = clamp(fundingRate, -MaxFundingRate, MaxFundingRate)
-
-	return fundingRate
+	// 4. 加利率项并 Clamp 到合约的上下限
+	return spec.FundingRateOf(premiumIndex)
 }
 
 // clamp 限制值在 [min, max] 范围内
@@ -248,7 +269,7 @@ func (s *FundingService) updateAllFundingRates() {
 			continue
 		}
 
-		rate := s.CalculateFundingRate(spec.Symbol)
+		rate := s.calculateFundingRate(spec)
 		s.fundingRates.Store(spec.Symbol, rate)
 	}
 }
@@ -257,10 +278,12 @@ func (s *FundingService) updateAllFundingRates() {
 // 资金费结算
 // =============================================================================
 
-// settlementLoop 资金费结算循环
+// settlementLoop 资金费结算循环 (先续跑未完成的结算)
 func (s *FundingService) settlementLoop() {
 	defer s.wg.Done()
 
+	s.resumeUnfinished(context.Background())
+
 	ticker := time.NewTicker(time.Second) // 每秒检查
 	defer ticker.Stop()
 
@@ -274,6 +297,23 @@ func (s *FundingService) settlementLoop() {
 	}
 }
 
+// resumeUnfinished 续跑宕机前没结完的资金费
+func (s *FundingService) resumeUnfinished(ctx context.Context) {
+	if s.cursors == nil {
+		return
+	}
+	cursors, err := s.cursors.ListUnfinished(ctx)
+	if err != nil {
+		log.Printf("[Funding] Failed to list unfinished settlements: %v", err)
+		return
+	}
+	for _, c := range cursors {
+		if _, err := s.settle(ctx, c.Symbol, c.FundingTime, false); err != nil {
+			log.Printf("[Funding] Resume settlement %s@%d failed: %v", c.Symbol, c.FundingTime, err)
+		}
+	}
+}
+
 // checkAndSettle 检查并执行结算
 func (s *FundingService) checkAndSettle() {
 	ctx := context.Background()
@@ -289,107 +329,199 @@ func (s *FundingService) checkAndSettle() {
 		// 检查是否到达结算时间
 		nextTime := s.GetNextFundingTime(spec.Symbol)
 		if now >= nextTime {
-			go s.settleFunding(ctx, spec.Symbol)
+			go s.settleFunding(ctx, spec.Symbol, false)
 		}
 	}
 }
 
 // SettleFunding 手动触发资金费结算 (公开方法)
-func (s *FundingService) SettleFunding(ctx context.Context, symbol string) error {
-	return s.settleFunding(ctx, symbol)
+//
+// dryRun=true 时走同一套计算逻辑 (包括余额不足时的截断)，
+// 但不修改余额、不推进下次结算时间，只返回报告
+func (s *FundingService) SettleFunding(ctx context.Context, symbol string, dryRun bool) (*FundingReport, error) {
+	return s.settleFunding(ctx, symbol, dryRun)
+}
+
+// settleFunding 执行资金费结算 (结算时间点为当前的下次结算时间)
+func (s *FundingService) settleFunding(ctx context.Context, symbol string, dryRun bool) (*FundingReport, error) {
+	return s.settle(ctx, symbol, s.GetNextFundingTime(symbol), dryRun)
 }
 
-// settleFunding 执行资金费结算
+// settle 结算 fundingTime 这一期的资金费
 //
 // 【核心流程】
-// 1. 获取当前资金费率
-// 2. 遍历所有持仓
-// 3. 计算每个用户的资金费
-// 4. 多头付钱给空头 (或反过来)
+// 1. 获取资金费率、标记价格 (有游标时以游标记录的为准)
+// 2. 按持仓 ID 分批读取，每轮最多 workerCount 批并发处理
+// 3. 计算每个用户的资金费，多头付钱给空头 (或反过来)，已落账的用户跳过
+// 4. 每轮结束推进游标，全部完成后标记游标完成
 // 5. 更新下次结算时间
-func (s *FundingService) settleFunding(ctx context.Context, symbol string) error {
-	// 1. 防止并发结算
-	if _, loaded := s.settlingSymbols.LoadOrStore(symbol, true); loaded {
-		return ErrFundingInProgress
+//
+// 【dry-run】不占锁、不写游标、不推进下次结算时间，第 3 步只计算实际会落账的金额
+//
+// 【注意】落账失败的用户只计入 FailedCount，游标照常推进，需要人工补单
+func (s *FundingService) settle(ctx context.Context, symbol string, fundingTime int64, dryRun bool) (*FundingReport, error) {
+	// 防止并发结算 (dry-run 只读，不占锁)
+	if !dryRun {
+		if _, loaded := s.settlingSymbols.LoadOrStore(symbol, true); loaded {
+			return nil, ErrFundingInProgress
+		}
+		defer s.settlingSymbols.Delete(symbol)
 	}
-	defer s.settlingSymbols.Delete(symbol)
 
-	// 2. 获取合约规格
 	spec, err := s.contractManager.GetContract(ctx, symbol)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 3. 获取资金费率
-	fundingRate := s.GetFundingRate(symbol)
-	if fundingRate == 0 {
-		// 费率为 0，无需结算 (多空平衡)
-		s.updateNextFundingTime(symbol)
-		return nil
+	// 1. 资金费率 + 标记价格 (用于计算持仓价值)
+	report := &FundingReport{
+		Symbol:      symbol,
+		DryRun:      dryRun,
+		FundingRate: s.GetFundingRate(symbol),
+		MarkPrice:   s.markPriceService.GetMarkPrice(symbol),
+		FundingTime: fundingTime,
 	}
-
-	// 4. 获取标记价格 (用于计算持仓价值)
-	markPrice := s.markPriceService.GetMarkPrice(symbol)
-
-	log.Printf("[Funding] Starting settlement for %s, rate=%d/10000, markPrice=%d",
-		symbol, fundingRate, markPrice)
-
-	// 5. 分批处理所有持仓
-	var offset int
-	var totalPaid, totalReceived int64
-	var paidCount, receivedCount int
-
-	for {
-		positions, err := s.positionRepo.ListBySymbol(ctx, spec.Symbol, s.batchSize, offset)
+	var afterID uint
+	if !dryRun && s.cursors != nil {
+		cur, err := s.cursors.Begin(ctx, &FundingCursor{
+			Symbol:      symbol,
+			FundingTime: fundingTime,
+			FundingRate: report.FundingRate,
+			MarkPrice:   report.MarkPrice,
+		})
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if cur.Done {
+			s.updateNextFundingTime(spec)
+			return report, nil
+		}
+		report.FundingRate, report.MarkPrice, afterID = cur.FundingRate, cur.MarkPrice, cur.LastPositionID
+		if afterID > 0 {
+			log.Printf("[Funding] Resuming settlement for %s@%d after position %d", symbol, fundingTime, afterID)
 		}
+	}
+
+	if report.FundingRate != 0 {
+		log.Printf("[Funding] Starting settlement for %s, rate=%d/10000, markPrice=%d, dryRun=%v",
+			symbol, report.FundingRate, report.MarkPrice, dryRun)
 
-		if len(positions) == 0 {
-			break
+		// 2~4. 分批并发结算
+		if err := s.settlePositions(ctx, spec, report, afterID); err != nil {
+			return report, err
 		}
+	}
+	// 费率为 0 时无需结算 (多空平衡)，同样标记完成
 
-		for _, pos := range positions {
-			if pos.Size == 0 {
-				continue
+	// 5. 标记完成，更新下次结算时间，通知下游
+	if !dryRun {
+		if s.cursors != nil {
+			if err := s.cursors.Finish(ctx, symbol, fundingTime); err != nil {
+				return report, err
 			}
+		}
+		s.updateNextFundingTime(spec)
+		s.recordFundingRate(ctx, report)
+		s.notifySettled(report)
+	}
 
-			// 6. 计算资金费
-			payment := s.calculateFundingPayment(pos, fundingRate, markPrice)
+	log.Printf("[Funding] Settlement complete for %s: %d paid (total=%d), %d received (total=%d), %d skipped, dryRun=%v",
+		symbol, report.PaidCount, report.TotalPaid, report.ReceivedCount, report.TotalReceived, report.SkippedCount, dryRun)
 
-			// 7. 执行资金转移
-			if err := s.applyFundingPayment(ctx, spec, pos, payment); err != nil {
-				log.Printf("[Funding] Failed to apply payment for user %d: %v", pos.UserID, err)
-				continue
-			}
+	return report, nil
+}
 
-			// 统计
-			if payment > 0 {
-				totalReceived += payment
-				receivedCount++
-			} else if payment < 0 {
-				totalPaid += -payment
-				paidCount++
+// settlePositions 按持仓 ID 游标分批，每轮读取 workerCount 批并发处理
+//
+// 【面试】为什么按"轮"推进游标，而不是每批完成就推进?
+// 批次并发完成，第 3 批先于第 2 批结束时游标不能跳到第 3 批末尾，
+// 否则宕机后第 2 批剩下的用户就漏了。按轮等待全部完成再推进最简单，
+// 代价是宕机时最多重跑一轮，而重跑由流水幂等键挡住
+func (s *FundingService) settlePositions(ctx context.Context, spec *ContractSpec, report *FundingReport, afterID uint) error {
+	var mu sync.Mutex // 保护 report
+
+	for {
+		// 读一轮
+		batches := make([][]*Position, 0, s.workerCount)
+		for len(batches) < s.workerCount {
+			positions, err := s.positionRepo.ListBySymbolAfter(ctx, spec.Symbol, afterID, s.batchSize)
+			if err != nil {
+				return err
 			}
+			if len(positions) == 0 {
+				break
+			}
+			batches = append(batches, positions)
+			afterID = positions[len(positions)-1].ID
+		}
+		if len(batches) == 0 {
+			return nil
 		}
 
-		offset += len(positions)
+		// 并发处理，每批一个 worker
+		var wg sync.WaitGroup
+		for _, batch := range batches {
+			wg.Add(1)
+			go func(batch []*Position) {
+				defer wg.Done()
+				for _, pos := range batch {
+					entry := s.settlePosition(ctx, spec, report, pos)
+					mu.Lock()
+					report.add(entry)
+					mu.Unlock()
+				}
+			}(batch)
+		}
+		wg.Wait()
+
+		// 推进游标
+		if !report.DryRun && s.cursors != nil {
+			if err := s.cursors.Advance(ctx, spec.Symbol, report.FundingTime, afterID); err != nil {
+				return err
+			}
+		}
 	}
+}
+
+// settlePosition 结算单个持仓的资金费
+func (s *FundingService) settlePosition(ctx context.Context, spec *ContractSpec, report *FundingReport, pos *Position) FundingEntry {
+	payment := s.calculateFundingPayment(spec, pos, report.FundingRate, report.MarkPrice)
+	entry := FundingEntry{UserID: pos.UserID, PositionSize: pos.Size, Payment: payment}
 
-	// 8. 更新下次结算时间
-	s.updateNextFundingTime(symbol)
+	if report.DryRun {
+		entry.Applied, entry.Err = s.planFundingPayment(ctx, spec, pos, payment)
+	} else {
+		entry.Applied, entry.Skipped, entry.Err = s.applyFundingPayment(ctx, spec, pos, report.FundingTime, payment)
+	}
+	if entry.Err != nil {
+		log.Printf("[Funding] Failed to apply payment for user %d: %v", pos.UserID, entry.Err)
+	}
+	return entry
+}
 
-	log.Printf("[Funding] Settlement complete for %s: %d paid (total=%d), %d received (total=%d)",
-		symbol, paidCount, totalPaid, receivedCount, totalReceived)
+// OnSettled 注册结算完成回调 (可注册多个)
+// 只在实际结算 (非 dry-run) 完成后调用，report 包含每个用户的落账明细；
+// 回调在结算 goroutine 中同步执行，不要在回调里做耗时操作
+func (s *FundingService) OnSettled(callback func(report *FundingReport)) {
+	s.callbackMu.Lock()
+	defer s.callbackMu.Unlock()
+	s.onSettled = append(s.onSettled, callback)
+}
 
-	return nil
+func (s *FundingService) notifySettled(report *FundingReport) {
+	s.callbackMu.RLock()
+	callbacks := s.onSettled
+	s.callbackMu.RUnlock()
+	for _, cb := range callbacks {
+		cb(report)
+	}
 }
 
 // calculateFundingPayment 计算资金费
 //
 // 【公式】
 // 资金费 = 持仓价值 × 资金费率
-// 持仓价值 = |持仓数量| × 标记价格
+// 持仓价值 = |持仓数量| × 标记价格 (反向合约: 张数 × 面值 / 标记价格，以基础币收付)
 //
 // 【方向规则】
 // 资金费率 > 0 (多军付):
@@ -400,49 +532,63 @@ func (s *FundingService) settleFunding(ctx context.Context, symbol string) error
 //   - 多头 (Size > 0): payment > 0 (收入)
 //   - 空头 (Size < 0): payment < 0 (付出)
 //
-// 统一公式: payment = -Size * markPrice * fundingRate / Precision / FundingPrecision
-func (s *FundingService) calculateFundingPayment(pos *Position, fundingRate, markPrice int64) int64 {
-	// 持仓价值 = Size * markPrice / Precision
+// 统一公式: payment = -sign(Size) × 持仓价值 × fundingRate / FundingPrecision
+func (s *FundingService) calculateFundingPayment(spec *ContractSpec, pos *Position, fundingRate, markPrice int64) int64 {
 	// 资金费 = -持仓价值 * fundingRate / FundingPrecision
 	// 负号是因为: 做多且费率为正时，多头要付钱 (payment < 0)
-
-	payment := -pos.Size * markPrice * fundingRate / Precision / FundingPrecision
+	payment := fixed.Bps(spec.PositionValue(pos.AbsSize(), markPrice), fundingRate)
+	if pos.Size > 0 {
+		return -payment
+	}
 	return payment
 }
 
-// applyFundingPayment 应用资金费
+// planFundingPayment 计算实际落账金额 (只读)
+//
+// payment > 0: 用户收到资金费，全额入账
+// payment < 0: 用户支付资金费，从可用余额扣，余额不足时最多扣到 0
+func (s *FundingService) planFundingPayment(
+	ctx context.Context,
+	spec *ContractSpec,
+	pos *Position,
+	payment int64,
+) (int64, error) {
+	if payment >= 0 {
+		return payment, nil
+	}
+
+	balance, err := s.balanceRepo.GetBalance(ctx, pos.UserID, spec.SettleCurrency)
+	if err != nil {
+		return 0, err
+	}
+	deductAmount := -payment
+	if balance != nil && balance.Available < deductAmount {
+		deductAmount = max(balance.Available, 0) // 最多扣到 0
+	}
+	return -deductAmount, nil
+}
+
+// applyFundingPayment 资金费落账 (幂等，支出在行锁内按余额截断)
+// skipped = true 表示这一期已经落过账 (续跑 / 重跑)
 func (s *FundingService) applyFundingPayment(
 	ctx context.Context,
 	spec *ContractSpec,
 	pos *Position,
+	fundingTime int64,
 	payment int64,
-) error {
+) (applied int64, skipped bool, err error) {
 	if payment == 0 {
-		return nil
+		return 0, false, nil
 	}
-
-	// payment > 0: 用户收到资金费
-	// payment < 0: 用户支付资金费
-	if payment > 0 {
-		// 增加余额
-		return s.balanceRepo.AddAvailable(ctx, pos.UserID, spec.SettleCurrency, payment)
-	} else {
-		// 扣除余额 (从可用余额扣)
-		// 如果余额不足，直接扣成 0 (不会变成负数)
-		balance, err := s.balanceRepo.GetBalance(ctx, pos.UserID, spec.SettleCurrency)
-		if err != nil {
-			return err
-		}
-		deductAmount := -payment
-		if balance != nil && balance.Available < deductAmount {
-			deductAmount = balance.Available // 最多扣到 0
-		}
-		if deductAmount > 0 {
-			// 使用事务确保原子性
-			return s.balanceRepo.AddAvailable(ctx, pos.UserID, spec.SettleCurrency, -deductAmount)
-		}
+	applied, skipped, err = s.balanceRepo.ApplyFunding(ctx, pos.UserID, spec.SettleCurrency, spec.Symbol, fundingTime, payment)
+	if err != nil || skipped || applied == 0 {
+		return applied, skipped, err
 	}
-	return nil
+	// 计入本轮持仓统计 (历史持仓展示用)，失败不影响已落账的资金费
+	if err := s.positionRepo.AddFunding(ctx, pos.UserID, pos.Symbol, applied); err != nil {
+		log.Printf("[Funding] Failed to record funding on position for user %d: %v", pos.UserID, err)
+	}
+	return applied, false, nil
 }
 
 // =============================================================================
@@ -458,29 +604,20 @@ func (s *FundingService) initNextFundingTimes() {
 		if spec.ContractType != TypePerpetual {
 			continue
 		}
-		s.updateNextFundingTime(spec.Symbol)
+		s.updateNextFundingTime(spec)
 	}
 }
 
 // updateNextFundingTime 更新下次结算时间
 //
 // 【规则】
-// 结算时间固定在 00:00, 08:00, 16:00 UTC
-func (s *FundingService) updateNextFundingTime(symbol string) {
-	now := time.Now().UTC()
-
-	// 计算下一个 8 小时整点
-	hour := now.Hour()
-	nextHour := ((hour / 8) + 1) * 8
-	if nextHour >= 24 {
-		nextHour = 0
-		now = now.AddDate(0, 0, 1)
-	}
-
-	nextTime := time.Date(now.Year(), now.Month(), now.Day(), nextHour, 0, 0, 0, time.UTC)
-	s.nextFundingTime.Store(symbol, nextTime.UnixMilli())
+// 结算时间对齐到合约结算间隔的整数倍 (UTC)，8 小时合约为 00:00, 08:00, 16:00。
+// 结算间隔变更后已公布的下次结算时间不变，结算完成后才按新间隔推算
+func (s *FundingService) updateNextFundingTime(spec *ContractSpec) {
+	nextTime := spec.NextFundingTime(time.Now())
+	s.nextFundingTime.Store(spec.Symbol, nextTime.UnixMilli())
 
-	log.Printf("[Funding] Next funding time for %s: %s", symbol, nextTime.Format(time.RFC3339))
+	log.Printf("[Funding] Next funding time for %s: %s", spec.Symbol, nextTime.Format(time.RFC3339))
 }
 
 // GetFundingInfo 获取资金费信息 (供 API 使用)
@@ -0,0 +1,123 @@
+// 文件: pkg/futures/funding_cursor.go
+// 资金费结算游标 (断点续跑)
+//
+// 【为什么需要】
+// 一个合约几十万持仓，结算要跑几分钟。中途宕机重启后：
+// - 下次结算时间按当前时间重新计算，已经跳到下一个 8 小时，这一期没结完的用户就被漏掉了
+// - 从头重跑的话，前面已结算的用户又要扣一次
+//
+// 【设计】
+// - 每个 (合约, 结算时间点) 一行游标，记录费率、标记价格和已完成的最大持仓 ID
+// - 费率/标记价格以首次开始结算时为准，续跑时不用重启后重新算出来的值，同一期所有用户口径一致
+// - 游标只保证"从哪里继续"，不保证"不重复"：游标推进前宕机，会重跑最后一轮，
+//   重复落账由资金流水的幂等键兜底 (见 fund.ApplyFunding)
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FundingCursor 资金费结算游标
+type FundingCursor struct {
+	ID             uint   `gorm:"primaryKey;autoIncrement"`
+	Symbol         string `gorm:"column:symbol;type:varchar(32);uniqueIndex:uk_symbol_time,priority:1"`
+	FundingTime    int64  `gorm:"column:funding_time;uniqueIndex:uk_symbol_time,priority:2"`
+	FundingRate    int64  `gorm:"column:funding_rate"` // 万分比
+	MarkPrice      int64  `gorm:"column:mark_price"`
+	LastPositionID uint   `gorm:"column:last_position_id"` // 已完成的最大持仓 ID
+	Done           bool   `gorm:"column:done;index"`
+	CreatedAt      int64  `gorm:"column:created_at"`
+	UpdatedAt      int64  `gorm:"column:updated_at"`
+}
+
+func (FundingCursor) TableName() string {
+	return "funding_settlement_cursors"
+}
+
+// FundingCursorStore 结算游标存储
+type FundingCursorStore interface {
+	// Begin 创建游标；(symbol, funding_time) 已存在时返回已有游标，c 中的费率等字段被忽略
+	Begin(ctx context.Context, c *FundingCursor) (*FundingCursor, error)
+
+	// Advance 推进游标 (只增不减)
+	Advance(ctx context.Context, symbol string, fundingTime int64, lastPositionID uint) error
+
+	// Finish 标记本期结算完成
+	Finish(ctx context.Context, symbol string, fundingTime int64) error
+
+	// ListUnfinished 未完成的游标 (启动时续跑)
+	ListUnfinished(ctx context.Context) ([]*FundingCursor, error)
+}
+
+// 确保实现了接口
+var _ FundingCursorStore = (*MySQLFundingCursorStore)(nil)
+
+// MySQLFundingCursorStore MySQL 实现
+type MySQLFundingCursorStore struct {
+	db *gorm.DB
+}
+
+// NewMySQLFundingCursorStore 创建结算游标存储
+func NewMySQLFundingCursorStore(db *gorm.DB) *MySQLFundingCursorStore {
+	return &MySQLFundingCursorStore{db: db}
+}
+
+// Begin 创建或读取游标
+func (s *MySQLFundingCursorStore) Begin(ctx context.Context, c *FundingCursor) (*FundingCursor, error) {
+	now := time.Now().UnixMilli()
+	c.CreatedAt, c.UpdatedAt = now, now
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(c).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var cur FundingCursor
+	err = s.db.WithContext(ctx).
+		Where("symbol = ? AND funding_time = ?", c.Symbol, c.FundingTime).
+		First(&cur).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("funding cursor vanished after insert")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+// Advance 推进游标
+func (s *MySQLFundingCursorStore) Advance(ctx context.Context, symbol string, fundingTime int64, lastPositionID uint) error {
+	return s.db.WithContext(ctx).
+		Model(&FundingCursor{}).
+		Where("symbol = ? AND funding_time = ? AND last_position_id < ?", symbol, fundingTime, lastPositionID).
+		Updates(map[string]interface{}{
+			"last_position_id": lastPositionID,
+			"updated_at":       time.Now().UnixMilli(),
+		}).Error
+}
+
+// Finish 标记完成
+func (s *MySQLFundingCursorStore) Finish(ctx context.Context, symbol string, fundingTime int64) error {
+	return s.db.WithContext(ctx).
+		Model(&FundingCursor{}).
+		Where("symbol = ? AND funding_time = ?", symbol, fundingTime).
+		Updates(map[string]interface{}{
+			"done":       true,
+			"updated_at": time.Now().UnixMilli(),
+		}).Error
+}
+
+// ListUnfinished 未完成的游标
+func (s *MySQLFundingCursorStore) ListUnfinished(ctx context.Context) ([]*FundingCursor, error) {
+	var list []*FundingCursor
+	err := s.db.WithContext(ctx).
+		Where("done = ?", false).
+		Order("funding_time ASC, id ASC").
+		Find(&list).Error
+	return list, err
+}
@@ -31,14 +31,15 @@ func (FundingPayment) TableName() string {
 // =============================================================================
 
 // FundingRateHistory 资金费率历史
+// 每期结算完成后写入一条，见 market_history.go
 type FundingRateHistory struct {
-	ID          uint   `gorm:"primaryKey;autoIncrement"`
-	Symbol      string `gorm:"column:symbol;type:varchar(32);index"`
-	FundingRate int64  `gorm:"column:funding_rate"` // 万分比
-	MarkPrice   int64  `gorm:"column:mark_price"`
-	IndexPrice  int64  `gorm:"column:index_price"`
-	FundingTime int64  `gorm:"column:funding_time;uniqueIndex:idx_symbol_time"`
-	CreatedAt   int64  `gorm:"column:created_at"`
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Symbol      string `gorm:"column:symbol;type:varchar(32);uniqueIndex:idx_symbol_time,priority:1" json:"symbol"`
+	FundingRate int64  `gorm:"column:funding_rate" json:"funding_rate"` // 万分比
+	MarkPrice   int64  `gorm:"column:mark_price" json:"mark_price"`
+	IndexPrice  int64  `gorm:"column:index_price" json:"index_price"`
+	FundingTime int64  `gorm:"column:funding_time;uniqueIndex:idx_symbol_time,priority:2" json:"funding_time"` // 毫秒
+	CreatedAt   int64  `gorm:"column:created_at" json:"-"`
 }
 
 func (FundingRateHistory) TableName() string {
@@ -65,3 +66,54 @@ func NewFundingPayment(
 		CreatedAt:    time.Now().UnixMilli(),
 	}
 }
+
+// =============================================================================
+// 资金费结算报告 (支持 dry-run 预览)
+// =============================================================================
+
+// FundingEntry 单个用户的资金费明细
+type FundingEntry struct {
+	UserID       int64
+	PositionSize int64
+	Payment      int64 // 按公式计算的资金费 (正=收入, 负=支出)
+	Applied      int64 // 实际落账金额 (余额不足时支出被截断)
+	Skipped      bool  // 这一期已落过账 (续跑/重跑)，Applied 为当时的落账金额，本次未重复落账
+	Err          error // 落账失败原因 (dry-run 时为读取余额失败)
+}
+
+// FundingReport 一次资金费结算的汇总
+//
+// 【用途】运维在改费率 / 手动结算前先 dry-run，确认影响面再执行
+type FundingReport struct {
+	Symbol      string
+	DryRun      bool
+	FundingRate int64 // 万分比
+	MarkPrice   int64
+	FundingTime int64 // 本次结算的资金费时间点 (毫秒)
+
+	Entries       []FundingEntry
+	TotalPaid     int64 // 实际支出合计 (正数)
+	TotalReceived int64 // 实际收入合计
+	PaidCount     int
+	ReceivedCount int
+	FailedCount   int
+	SkippedCount  int // 已落过账的用户 (续跑时)，金额仍计入上面的合计
+}
+
+// add 记录一条明细并更新汇总
+func (r *FundingReport) add(entry FundingEntry) {
+	r.Entries = append(r.Entries, entry)
+	if entry.Skipped {
+		r.SkippedCount++
+	}
+	switch {
+	case entry.Err != nil:
+		r.FailedCount++
+	case entry.Applied > 0:
+		r.TotalReceived += entry.Applied
+		r.ReceivedCount++
+	case entry.Applied < 0:
+		r.TotalPaid += -entry.Applied
+		r.PaidCount++
+	}
+}
@@ -0,0 +1,95 @@
+// 文件: pkg/futures/funding_params.go
+// 按合约的资金费参数
+//
+// 【为什么需要】
+// 资金费率上限、利率、结算间隔原来是全局常量 (±0.75%、0.01%、8 小时)：
+// 新上线的山寨币合约波动大，需要更宽的上下限和 1 小时结算，
+// 主流合约又不想跟着放宽。参数挂到 ContractSpec 上，每个合约单独配置，
+// 通过 ScheduleParamChange 提前公告、到点生效 (见 param_schedule.go、param_admin.go)
+//
+// 【公式】(与主流交易所一致)
+//
+//	资金费率 = Clamp(溢价指数 + Clamp(利率 - 溢价指数, -0.05%, 0.05%), 下限, 上限)
+//
+// 溢价指数在利率附近 ±0.05% 以内时资金费率就等于利率 (多空平衡时多头付一个很小的固定利率)。
+// 未配置利率 (InterestRate=0) 的合约沿用简化公式 Clamp(溢价指数, 下限, 上限)，上线前后费率不变
+//
+// 【结算时间】按 Unix 纪元对齐到间隔的整数倍，间隔整除 24 小时，所以每天 UTC 0 点一定是结算点：
+// 8 小时 → 00/08/16 点，1 小时 → 每个整点
+//
+// 【注意】结算间隔变更生效后，已公布的下次结算时间不变，之后按新间隔推算
+
+package futures
+
+import "time"
+
+const (
+	// InterestDampening 利率项的钳制范围 (±0.05%)
+	InterestDampening = 5
+
+	// MaxFundingIntervalSeconds 结算间隔上限 (24 小时)
+	MaxFundingIntervalSeconds = 24 * 3600
+)
+
+// validateFundingParams 资金费参数约束 (创建合约、参数变更共用)
+//
+// interval / maxRate 为 0 表示使用默认值，不在这里报错
+func validateFundingParams(interval, maxRate, minRate, interestRate int64) error {
+	if interval < 0 || (interval > 0 && MaxFundingIntervalSeconds%interval != 0) {
+		return ErrInvalidSpec.Wrapf("funding interval must divide 24h, got %ds", interval)
+	}
+	if maxRate < 0 || maxRate >= RatePrecision {
+		return ErrInvalidSpec.Wrapf("max funding rate must be between 0 and 100%%")
+	}
+	if maxRate == 0 {
+		maxRate = MaxFundingRate
+	}
+	if minRate > 0 || minRate <= -RatePrecision {
+		return ErrInvalidSpec.Wrapf("min funding rate must be between -100%% and 0")
+	}
+	if minRate == 0 {
+		minRate = -maxRate
+	}
+	if interestRate < minRate || interestRate > maxRate {
+		return ErrInvalidSpec.Wrapf("interest rate must be within funding rate bounds")
+	}
+	return nil
+}
+
+// FundingPeriod 资金费结算间隔
+func (s *ContractSpec) FundingPeriod() time.Duration {
+	if s.FundingInterval <= 0 {
+		return FundingInterval
+	}
+	return time.Duration(s.FundingInterval) * time.Second
+}
+
+// FundingRateBounds 资金费率下限、上限 (万分比)
+func (s *ContractSpec) FundingRateBounds() (floor, ceiling int64) {
+	ceiling = s.MaxFundingRate
+	if ceiling <= 0 {
+		ceiling = MaxFundingRate
+	}
+	floor = s.MinFundingRate
+	if floor == 0 {
+		floor = -ceiling
+	}
+	return floor, ceiling
+}
+
+// FundingRateOf 由溢价指数 (万分比) 计算资金费率
+func (s *ContractSpec) FundingRateOf(premiumIndex int64) int64 {
+	rate := premiumIndex
+	if s.InterestRate != 0 {
+		rate += clamp(s.InterestRate-premiumIndex, -InterestDampening, InterestDampening)
+	}
+	floor, ceiling := s.FundingRateBounds()
+	return clamp(rate, floor, ceiling)
+}
+
+// NextFundingTime now 之后的下一个结算点 (恰好在结算点上时取下一个)
+func (s *ContractSpec) NextFundingTime(now time.Time) time.Time {
+	period := s.FundingPeriod().Milliseconds()
+	next := (now.UnixMilli()/period + 1) * period
+	return time.UnixMilli(next).UTC()
+}
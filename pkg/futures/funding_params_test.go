@@ -0,0 +1,139 @@
+// 文件: pkg/futures/funding_params_test.go
+// 按合约资金费参数测试
+
+package futures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFundingParams_Defaults(t *testing.T) {
+	spec := harnessLinearSpec()
+
+	assert.Equal(t, FundingInterval, spec.FundingPeriod())
+	floor, ceiling := spec.FundingRateBounds()
+	assert.Equal(t, int64(-MaxFundingRate), floor)
+	assert.Equal(t, int64(MaxFundingRate), ceiling)
+
+	// 未配置利率：资金费率 = Clamp(溢价指数)，与原公式一致
+	assert.Equal(t, int64(3), spec.FundingRateOf(3))
+	assert.Equal(t, int64(-MaxFundingRate), spec.FundingRateOf(-500))
+
+	now := time.Date(2026, 3, 1, 7, 59, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC), spec.NextFundingTime(now))
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), spec.NextFundingTime(now.Add(9*time.Hour)))
+}
+
+func TestFundingParams_PerContract(t *testing.T) {
+	spec := harnessLinearSpec()
+	spec.FundingInterval = 3600
+	spec.MaxFundingRate = 200
+	spec.MinFundingRate = -50
+	spec.InterestRate = 1
+
+	now := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	assert.Equal(t, now.Add(time.Hour), spec.NextFundingTime(now), "on the boundary takes the next slot")
+	assert.Equal(t, now.Add(time.Hour), spec.NextFundingTime(now.Add(30*time.Minute)))
+
+	// 溢价在利率 ±0.05% 以内 → 利率
+	assert.Equal(t, int64(1), spec.FundingRateOf(0))
+	assert.Equal(t, int64(1), spec.FundingRateOf(6))
+	// 超出范围 → 溢价 ± 0.05%
+	assert.Equal(t, int64(95), spec.FundingRateOf(100))
+	// 非对称上下限
+	assert.Equal(t, int64(200), spec.FundingRateOf(1000))
+	assert.Equal(t, int64(-50), spec.FundingRateOf(-1000))
+}
+
+func TestFundingParams_Validate(t *testing.T) {
+	assert.NoError(t, validateFundingParams(0, 0, 0, 0))
+	assert.NoError(t, validateFundingParams(3600, 200, -50, 1))
+	assert.ErrorIs(t, validateFundingParams(7000, 0, 0, 0), ErrInvalidSpec, "must divide 24h")
+	assert.ErrorIs(t, validateFundingParams(-1, 0, 0, 0), ErrInvalidSpec)
+	assert.ErrorIs(t, validateFundingParams(3600, -1, 0, 0), ErrInvalidSpec)
+	assert.ErrorIs(t, validateFundingParams(3600, 100, 10, 0), ErrInvalidSpec, "floor above zero")
+	assert.ErrorIs(t, validateFundingParams(3600, 100, -10, -20), ErrInvalidSpec, "interest below floor")
+
+	req := &CreateContractRequest{
+		Symbol: "ALTUSDT", BaseCurrency: "ALT", QuoteCurrency: "USDT", SettleCurrency: "USDT",
+		ContractType: TypePerpetual, ContractSize: Precision, TickSize: 1, MaxLeverage: 20,
+		MaintMarginRate: 100, FundingInterval: 5 * 3600, PriceSources: []string{"binance"},
+	}
+	assert.ErrorIs(t, ValidateCreateRequest(req), ErrInvalidSpec)
+	req.FundingInterval = 4 * 3600
+	assert.NoError(t, ValidateCreateRequest(req))
+}
+
+func TestFundingParams_ScheduledChange(t *testing.T) {
+	ctx := context.Background()
+	m, repo, _ := newParamTestManager(t)
+	symbol := harnessLinearSpec().Symbol
+	effective := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC).UnixMilli()
+
+	interval, maxRate, interest := int64(3600), int64(300), int64(1)
+	_, err := m.ScheduleParamChange(ctx, symbol, ParamChange{
+		FundingInterval: &interval, MaxFundingRate: &maxRate, InterestRate: &interest,
+	}, effective, "1h funding")
+	require.NoError(t, err)
+
+	bad := int64(5)
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{MinFundingRate: &bad}, effective, "")
+	assert.ErrorIs(t, err, ErrInvalidSpec)
+
+	n, err := m.ProcessParamChanges(ctx, effective)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	spec, _ := repo.GetBySymbol(ctx, symbol)
+	assert.Equal(t, time.Hour, spec.FundingPeriod())
+	_, ceiling := spec.FundingRateBounds()
+	assert.Equal(t, int64(300), ceiling)
+	assert.Equal(t, int64(1), spec.FundingRateOf(0))
+}
+
+func TestParamChangeHandler(t *testing.T) {
+	m, _, _ := newParamTestManager(t)
+	h := NewParamChangeHandler(m)
+	symbol := harnessLinearSpec().Symbol
+
+	maxRate := int64(150)
+	body, _ := json.Marshal(ScheduleParamChangeRequest{
+		Symbol: symbol, Change: ParamChange{MaxFundingRate: &maxRate},
+		EffectiveAt: time.Now().Add(time.Hour).UnixMilli(), Reason: "widen cap",
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/contracts/params", bytes.NewReader(body)))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created ContractParamChange
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, ParamChangePending, created.Status)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/contracts/params?symbol="+symbol, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp ParamChangesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Pending, 1)
+	assert.Equal(t, int64(150), *resp.Pending[0].Change.MaxFundingRate)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/contracts/params/1", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/contracts/params/1", nil))
+	assert.NotEqual(t, http.StatusNoContent, rec.Code, "already canceled")
+
+	bad := int64(-1)
+	body, _ = json.Marshal(ScheduleParamChangeRequest{Symbol: symbol, Change: ParamChange{FundingInterval: &bad}})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/contracts/params", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -7,15 +7,20 @@ CREATE TABLE IF NOT EXISTS `contract_specs` (
     `settle_currency` VARCHAR(16) NOT NULL COMMENT '结算货币: USDT',
     `contract_type` TINYINT NOT NULL DEFAULT 0 COMMENT '0=永续, 1=交割',
     `contract_size` BIGINT NOT NULL COMMENT '合约面值 (精度单位)',
+    `inverse` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '1=反向合约 (币本位，基础币结算)',
     `tick_size` BIGINT NOT NULL COMMENT '最小价格变动',
     `min_order_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最小下单量',
     `max_order_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最大下单量',
     `max_position_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最大持仓量',
+    `max_order_lifetime` BIGINT NOT NULL DEFAULT 0 COMMENT '挂单最长存活(秒), 0=默认',
     `max_leverage` INT NOT NULL DEFAULT 100 COMMENT '最大杠杆倍数',
     `initial_margin_rate` BIGINT NOT NULL COMMENT '初始保证金率 (万分比)',
     `maint_margin_rate` BIGINT NOT NULL COMMENT '维持保证金率 (万分比)',
+    `liquidation_fee_rate` BIGINT NOT NULL DEFAULT 0 COMMENT '强平手续费率 (万分比)',
     `funding_interval` BIGINT NOT NULL DEFAULT 28800 COMMENT '资金费结算间隔(秒)',
     `max_funding_rate` BIGINT NOT NULL DEFAULT 75 COMMENT '最大资金费率(万分比)',
+    `min_funding_rate` BIGINT NOT NULL DEFAULT 0 COMMENT '最小资金费率(万分比), 0=与最大对称',
+    `interest_rate` BIGINT NOT NULL DEFAULT 0 COMMENT '每期利率(万分比), 0=不计利率项',
     `price_sources` JSON COMMENT '价格来源: ["binance","okx"]',
     `status` TINYINT NOT NULL DEFAULT 0 COMMENT '0=待上线,1=交易中,2=结算中,3=已结算,4=已下架',
     `listed_at` BIGINT NOT NULL DEFAULT 0 COMMENT '上线时间 (unix ms)',
@@ -38,6 +43,13 @@ CREATE TABLE IF NOT EXISTS `positions` (
     `margin` BIGINT NOT NULL DEFAULT 0 COMMENT '占用保证金',
     `leverage` INT NOT NULL DEFAULT 1 COMMENT '杠杆倍数',
     `realized_pnl` BIGINT NOT NULL DEFAULT 0 COMMENT '累计已实现盈亏',
+    `opened_at` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮开仓时间',
+    `closed_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮累计平仓数量',
+    `close_value` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮累计平仓成交额',
+    `cycle_pnl` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮已实现盈亏',
+    `cycle_fee` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮手续费 (含强平手续费)',
+    `cycle_liq_fee` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮强平手续费',
+    `cycle_funding` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮资金费 (正=收入)',
     `created_at` BIGINT NOT NULL,
     `updated_at` BIGINT NOT NULL,
     UNIQUE KEY `uk_user_symbol` (`user_id`, `symbol`),
@@ -45,6 +57,37 @@ CREATE TABLE IF NOT EXISTS `positions` (
     KEY `idx_symbol` (`symbol`)
 ) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '合约持仓表';
 
+-- 历史持仓表 (每轮持仓清零时写入一条)
+CREATE TABLE IF NOT EXISTS `position_history` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `side` TINYINT NOT NULL COMMENT '1=多,-1=空',
+    `close_reason` VARCHAR(16) NOT NULL COMMENT 'CLOSE/LIQUIDATION/SETTLEMENT',
+    `leverage` INT NOT NULL DEFAULT 1 COMMENT '杠杆倍数',
+    `qty` BIGINT NOT NULL COMMENT '累计平仓数量',
+    `entry_price` BIGINT NOT NULL COMMENT '开仓均价',
+    `exit_price` BIGINT NOT NULL COMMENT '平仓均价',
+    `realized_pnl` BIGINT NOT NULL COMMENT '平仓盈亏',
+    `fee` BIGINT NOT NULL DEFAULT 0 COMMENT '手续费 (含强平手续费)',
+    `liq_fee` BIGINT NOT NULL DEFAULT 0 COMMENT '强平手续费',
+    `funding` BIGINT NOT NULL DEFAULT 0 COMMENT '资金费 (正=收入)',
+    `net_pnl` BIGINT NOT NULL COMMENT '净盈亏 = 平仓盈亏 - 手续费 + 资金费',
+    `opened_at` BIGINT NOT NULL COMMENT '开仓时间',
+    `closed_at` BIGINT NOT NULL COMMENT '平仓时间',
+    KEY `idx_user_closed` (`user_id`, `closed_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '历史持仓表';
+
+-- 标记/指数价格历史 (每分钟采样)
+CREATE TABLE IF NOT EXISTS `price_history` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `minute` BIGINT NOT NULL COMMENT '整分钟 (毫秒)',
+    `mark_price` BIGINT NOT NULL COMMENT '标记价格',
+    `index_price` BIGINT NOT NULL DEFAULT 0 COMMENT '指数价格',
+    UNIQUE KEY `uk_symbol_minute` (`symbol`, `minute`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '标记/指数价格分钟历史';
+
 -- 统一订单表
 CREATE TABLE IF NOT EXISTS `orders` (
     `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
@@ -72,14 +115,17 @@ CREATE TABLE IF NOT EXISTS `orders` (
 CREATE TABLE settlement_records (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     symbol VARCHAR(32) NOT NULL,
+    expiry_at BIGINT NOT NULL,
     settlement_price BIGINT NOT NULL,
     total_positions INT NOT NULL DEFAULT 0,
     total_pnl BIGINT NOT NULL DEFAULT 0,
-    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    total_returned BIGINT NOT NULL DEFAULT 0,
+    total_shortfall BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'RUNNING',
     started_at BIGINT NOT NULL,
     finished_at BIGINT,
     error_msg TEXT,
-    INDEX idx_symbol (symbol),
+    UNIQUE KEY uk_symbol_expiry (symbol, expiry_at),
     INDEX idx_started_at (started_at)
 ) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
 
@@ -96,12 +142,69 @@ CREATE TABLE settlement_details (
     margin BIGINT NOT NULL,
     pnl BIGINT NOT NULL,
     settlement_amount BIGINT NOT NULL,
+    shortfall BIGINT NOT NULL DEFAULT 0,
     created_at BIGINT NOT NULL,
-    INDEX idx_settlement_id (settlement_id),
+    UNIQUE KEY uk_settlement_user (settlement_id, user_id),
     INDEX idx_user_id (user_id),
     INDEX idx_symbol (symbol)
 ) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
 
+-- 合约参数定时变更 (生效后即为参数版本历史)
+CREATE TABLE IF NOT EXISTS `contract_param_changes` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `change_set` JSON NOT NULL COMMENT '变更目标值',
+    `reason` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '公告说明',
+    `effective_at` BIGINT NOT NULL COMMENT '生效时间 (unix ms)',
+    `status` VARCHAR(16) NOT NULL COMMENT 'PENDING/APPLIED/CANCELED/FAILED',
+    `notified_at` BIGINT NOT NULL DEFAULT 0 COMMENT '预告发出时间',
+    `version` INT NOT NULL DEFAULT 0 COMMENT '生效后的参数版本号',
+    `params_before` JSON NULL COMMENT '生效前参数',
+    `params_after` JSON NULL COMMENT '生效后参数',
+    `applied_at` BIGINT NOT NULL DEFAULT 0,
+    `fail_reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` BIGINT NOT NULL,
+    KEY `idx_symbol_version` (`symbol`, `version`),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '合约参数定时变更';
+
+-- 资金费结算游标 (断点续跑)
+CREATE TABLE IF NOT EXISTS `funding_settlement_cursors` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `funding_time` BIGINT NOT NULL COMMENT '结算时间点 (unix ms)',
+    `funding_rate` BIGINT NOT NULL COMMENT '本期费率 (万分比)',
+    `mark_price` BIGINT NOT NULL COMMENT '本期标记价格',
+    `last_position_id` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '已完成的最大持仓ID',
+    `done` TINYINT(1) NOT NULL DEFAULT 0,
+    `created_at` BIGINT NOT NULL,
+    `updated_at` BIGINT NOT NULL,
+    UNIQUE KEY `uk_symbol_time` (`symbol`, `funding_time`),
+    KEY `idx_done` (`done`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '资金费结算游标';
+
+-- 开仓下单 outbox (冻结 + 写订单 + outbox 同一事务，中继提交撮合)
+CREATE TABLE IF NOT EXISTS `futures_order_outbox` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `order_id` BIGINT NOT NULL COMMENT '订单ID',
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `currency` VARCHAR(16) NOT NULL COMMENT '冻结币种',
+    `side` TINYINT NOT NULL,
+    `order_type` TINYINT NOT NULL DEFAULT 0 COMMENT '0=LIMIT 1=MARKET (price 为保护价)',
+    `price` BIGINT NOT NULL,
+    `qty` BIGINT NOT NULL,
+    `leverage` INT NOT NULL,
+    `margin` BIGINT NOT NULL COMMENT '冻结的保证金',
+    `expire_at` BIGINT NOT NULL DEFAULT 0 COMMENT '挂单到期时间 (unix ms)',
+    `client_order_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '客户端订单号',
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '0=PENDING 1=SENT 2=ABORTED',
+    `created_at` BIGINT NOT NULL,
+    `updated_at` BIGINT NOT NULL,
+    UNIQUE KEY `uk_order_id` (`order_id`),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '开仓下单 outbox';
+
 -- 资金费支付记录
 CREATE TABLE funding_payments (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
@@ -161,4 +264,40 @@ VALUES (
         'USDT',
         0,
         UNIX_TIMESTAMP() * 1000
-    );
\ No newline at end of file
+    );
+-- 条件单表 (止盈/止损/计划委托/跟踪止损)
+CREATE TABLE trigger_orders (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    symbol VARCHAR(32) NOT NULL,
+    kind TINYINT NOT NULL, -- 1=STOP 2=TAKE_PROFIT 3=STOP_LOSS 4=TRAILING_STOP
+    side TINYINT NOT NULL, -- 1=多 -1=空
+    qty BIGINT NOT NULL DEFAULT 0, -- 平仓类 0=全部
+    leverage INT NOT NULL DEFAULT 0,
+    trigger_price BIGINT NOT NULL DEFAULT 0,
+    order_price BIGINT NOT NULL DEFAULT 0, -- 0=按触发时标记价
+    callback_rate BIGINT NOT NULL DEFAULT 0, -- 跟踪止损回撤 (万分比)
+    extreme_price BIGINT NOT NULL DEFAULT 0,
+    status TINYINT NOT NULL, -- 1=PENDING 2=TRIGGERED 3=CANCELED 4=FAILED
+    trigger_mark_price BIGINT NOT NULL DEFAULT 0,
+    triggered_at BIGINT NOT NULL DEFAULT 0,
+    fail_reason VARCHAR(255) DEFAULT '',
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    INDEX idx_user_status (user_id, status),
+    INDEX idx_status (status)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 自动追加保证金设置 (用户主动开启，见 auto_margin.go)
+CREATE TABLE IF NOT EXISTS `auto_margin_settings` (
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `enabled` TINYINT(1) NOT NULL DEFAULT 0,
+    `amount` BIGINT NOT NULL DEFAULT 0 COMMENT '每次追加固定金额 (结算币种)，0=按比例',
+    `percent_bps` BIGINT NOT NULL DEFAULT 0 COMMENT '每次按可用余额的比例追加 (万分比)',
+    `max_total` BIGINT NOT NULL COMMENT '每轮持仓累计自动追加上限',
+    `used` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮已自动追加',
+    `used_cycle` BIGINT NOT NULL DEFAULT 0 COMMENT 'used 所属持仓轮次 (positions.opened_at)',
+    `updated_at` BIGINT NOT NULL,
+    PRIMARY KEY (`user_id`, `symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '自动追加保证金设置';
@@ -0,0 +1,449 @@
+// 文件: pkg/futures/harness_test.go
+// 内存测试夹具：账本、订单、合约、持仓都在内存，撮合用进程内引擎
+//
+// 【用途】go test ./pkg/futures 不依赖 MySQL/Redis/NATS 也能跑完整的开仓 → 成交 → 平仓链路
+// - 多币种账本：正向合约 (USDT 结算) 和反向合约 (BTC 结算) 共用一个账本
+// - 假时钟：持仓时间戳可断言
+// - 通过 FuturesProcessor.OnEventHandled 等待事件落地，不用 time.Sleep
+
+package futures
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fund"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+// =============================================================================
+// 内存账本 (实现 MarginLedger)
+// =============================================================================
+
+type memBalanceKey struct {
+	userID int64
+	symbol string
+}
+
+type memLedger struct {
+	mu       sync.Mutex
+	bal      map[memBalanceKey]*fund.BalanceRecord
+	journals map[string]*fund.JournalRecord // EventID -> 流水
+}
+
+func newMemLedger() *memLedger {
+	return &memLedger{
+		bal:      make(map[memBalanceKey]*fund.BalanceRecord),
+		journals: make(map[string]*fund.JournalRecord),
+	}
+}
+
+func (l *memLedger) record(userID int64, symbol string) *fund.BalanceRecord {
+	k := memBalanceKey{userID, symbol}
+	if l.bal[k] == nil {
+		l.bal[k] = &fund.BalanceRecord{UserID: userID, Symbol: symbol}
+	}
+	return l.bal[k]
+}
+
+func (l *memLedger) GetBalance(ctx context.Context, userID int64, symbol string) (*fund.BalanceRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.bal[memBalanceKey{userID, symbol}]
+	if !ok {
+		return nil, nil
+	}
+	cp := *b
+	return &cp, nil
+}
+
+func (l *memLedger) FreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	return l.apply(userID, symbol, -amount, amount, ref)
+}
+
+func (l *memLedger) UnfreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	return l.apply(userID, symbol, amount, -amount, ref)
+}
+
+func (l *memLedger) AddAvailableWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	return l.apply(userID, symbol, amount, 0, ref)
+}
+
+// apply 与 fund.BalanceRepo.applyJournaled 同语义：按 EventID 幂等，余额不足不记流水
+func (l *memLedger) apply(userID int64, symbol string, deltaAvailable, deltaLocked int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if j, ok := l.journals[ref.EventID]; ok {
+		return j, true, nil
+	}
+	b := l.record(userID, symbol)
+	if b.Available+deltaAvailable < 0 {
+		return nil, false, fund.ErrInsufficientAvailable
+	}
+	if b.Locked+deltaLocked < 0 {
+		return nil, false, fund.ErrInsufficientLocked
+	}
+	j := &fund.JournalRecord{
+		EventID:         ref.EventID,
+		UserID:          userID,
+		Symbol:          symbol,
+		ChangeType:      ref.ChangeType,
+		AvailableBefore: b.Available,
+		AvailableAfter:  b.Available + deltaAvailable,
+		LockedBefore:    b.Locked,
+		LockedAfter:     b.Locked + deltaLocked,
+		BizType:         ref.BizType,
+		BizID:           ref.BizID,
+	}
+	b.Available, b.Locked = j.AvailableAfter, j.LockedAfter
+	l.journals[ref.EventID] = j
+	return j, false, nil
+}
+
+// AddAvailable 直接入账 (测试准备资金，不记流水)
+func (l *memLedger) AddAvailable(ctx context.Context, userID int64, symbol string, amount int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record(userID, symbol).Available += amount
+	return nil
+}
+
+// journal 按幂等键查流水
+func (l *memLedger) journal(eventID string) *fund.JournalRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.journals[eventID]
+}
+
+// balance 返回 (可用, 冻结)
+func (l *memLedger) balance(userID int64, symbol string) (int64, int64) {
+	b, _ := l.GetBalance(context.Background(), userID, symbol)
+	if b == nil {
+		return 0, 0
+	}
+	return b.Available, b.Locked
+}
+
+// =============================================================================
+// 内存订单仓储 (实现 order.OrderRepository)
+// =============================================================================
+
+type memOrderRepo struct {
+	mu     sync.Mutex
+	orders map[int64]*order.Order
+}
+
+func newMemOrderRepo() *memOrderRepo {
+	return &memOrderRepo{orders: make(map[int64]*order.Order)}
+}
+
+func (r *memOrderRepo) Create(ctx context.Context, o *order.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *o
+	r.orders[o.OrderID] = &cp
+	return nil
+}
+
+func (r *memOrderRepo) GetByOrderID(ctx context.Context, orderID int64) (*order.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[orderID]
+	if !ok {
+		return nil, nil
+	}
+	cp := *o
+	return &cp, nil
+}
+
+func (r *memOrderRepo) GetActiveByUser(ctx context.Context, userID int64) ([]*order.Order, error) {
+	return r.list(func(o *order.Order) bool { return o.UserID == userID && o.IsActive() }), nil
+}
+
+func (r *memOrderRepo) GetByUserAndSymbol(ctx context.Context, userID int64, symbol string, limit int) ([]*order.Order, error) {
+	out := r.list(func(o *order.Order) bool { return o.UserID == userID && o.Symbol == symbol })
+	return out[:min(limit, len(out))], nil
+}
+
+func (r *memOrderRepo) UpdateFill(ctx context.Context, orderID int64, prevFilledQty, filledQty, avgPrice int64, status order.OrderStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[orderID]
+	if !ok || o.FilledQty != prevFilledQty || filledQty <= o.FilledQty {
+		return false, nil
+	}
+	o.FilledQty, o.AvgPrice, o.Status = filledQty, avgPrice, status
+	return true, nil
+}
+
+func (r *memOrderRepo) UpdateStatus(ctx context.Context, orderID int64, status order.OrderStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if o, ok := r.orders[orderID]; ok && o.IsActive() {
+		o.Status = status
+	}
+	return nil
+}
+
+func (r *memOrderRepo) list(match func(*order.Order) bool) []*order.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*order.Order
+	for _, o := range r.orders {
+		if match(o) {
+			cp := *o
+			out = append(out, &cp)
+		}
+	}
+	return out
+}
+
+// =============================================================================
+// 假时钟
+// =============================================================================
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// =============================================================================
+// harness - 每个合约一个撮合引擎 + 处理器，账本/仓储共享
+// =============================================================================
+
+// handledEvent 处理完成的事件 (只留类型，事件里的池化对象回调返回后就会被回收)
+type handledEvent struct {
+	symbol string
+	typ    mtrade.EventType
+}
+
+type harness struct {
+	t         *testing.T
+	ctx       context.Context
+	clock     *fakeClock
+	ledger    *memLedger
+	orders    *memOrderRepo
+	positions *memPositionRepo
+	contracts *ContractManager
+	procs     map[string]*FuturesProcessor
+	engines   map[string]*mtrade.Engine
+	events    chan handledEvent
+}
+
+func newHarness(t *testing.T, specs ...*ContractSpec) *harness {
+	h := &harness{
+		t:         t,
+		ctx:       context.Background(),
+		clock:     newFakeClock(),
+		ledger:    newMemLedger(),
+		orders:    newMemOrderRepo(),
+		positions: newMemPositionRepo(),
+		contracts: NewContractManager(newMemContractRepo(specs...)),
+		procs:     make(map[string]*FuturesProcessor),
+		engines:   make(map[string]*mtrade.Engine),
+		events:    make(chan handledEvent, 1024),
+	}
+	orderService := order.NewOrderService(h.orders)
+
+	for _, spec := range specs {
+		engine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig(spec.Symbol))
+		require.NoError(t, err)
+
+		proc := NewFuturesProcessor(h.contracts, engine, h.positions, orderService, h.ledger)
+		proc.SetClock(h.clock.Now)
+		symbol := spec.Symbol
+		proc.OnEventHandled(func(e mtrade.Event) {
+			if e.Type != mtrade.EventBookUpdate { // 行情增量可能被丢弃，不能用来计数
+				h.events <- handledEvent{symbol: symbol, typ: e.Type}
+			}
+		})
+
+		engine.Start(h.ctx)
+		t.Cleanup(engine.Stop)
+		h.procs[symbol] = proc
+		h.engines[symbol] = engine
+	}
+	return h
+}
+
+// waitFor 等待 symbol 上 n 个 typ 类型事件处理完成
+func (h *harness) waitFor(symbol string, typ mtrade.EventType, n int) {
+	h.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for n > 0 {
+		select {
+		case e := <-h.events:
+			if e.symbol == symbol && e.typ == typ {
+				n--
+			}
+		case <-timeout:
+			h.t.Fatalf("timeout waiting for %d events on %s", n, symbol)
+		}
+	}
+}
+
+func (h *harness) position(userID int64, symbol string) *Position {
+	pos, _ := h.positions.GetByUserAndSymbol(h.ctx, userID, symbol)
+	return pos
+}
+
+// =============================================================================
+// 夹具合约
+// =============================================================================
+
+func harnessLinearSpec() *ContractSpec {
+	return &ContractSpec{
+		Symbol:         "TESTBTCUSDT",
+		BaseCurrency:   "BTC",
+		QuoteCurrency:  "USDT",
+		SettleCurrency: "USDT",
+		ContractType:   TypePerpetual,
+		ContractSize:   Precision,
+		MaxLeverage:    100,
+		Status:         StatusTrading,
+	}
+}
+
+func harnessInverseSpec() *ContractSpec {
+	return &ContractSpec{
+		Symbol:         "TESTBTCUSD",
+		BaseCurrency:   "BTC",
+		QuoteCurrency:  "USD",
+		SettleCurrency: "BTC",
+		Inverse:        true,
+		ContractType:   TypePerpetual,
+		ContractSize:   100 * Precision,
+		MaxLeverage:    100,
+		Status:         StatusTrading,
+	}
+}
+
+// =============================================================================
+// 测试
+// =============================================================================
+
+func TestHarness_LinearOpenClose(t *testing.T) {
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs["TESTBTCUSDT"]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+	h.ledger.AddAvailable(h.ctx, 2, "USDT", 10000*Precision)
+
+	// 1 BTC @ 50000，10 倍 → 各冻结 5000 USDT
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: "TESTBTCUSDT", Side: SideLong, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 2, Symbol: "TESTBTCUSDT", Side: SideShort, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	h.waitFor("TESTBTCUSDT", mtrade.EventTrade, 1)
+
+	long := h.position(1, "TESTBTCUSDT")
+	require.NotNil(t, long)
+	assert.Equal(t, int64(Precision), long.Size)
+	assert.Equal(t, int64(50000*Precision), long.EntryPrice)
+	assert.Equal(t, int64(5000*Precision), long.Margin)
+	assert.Equal(t, h.clock.Now().UnixMilli(), long.OpenedAt)
+	assert.Equal(t, int64(-Precision), h.position(2, "TESTBTCUSDT").Size)
+
+	avail, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(5000*Precision), avail)
+	assert.Equal(t, int64(5000*Precision), locked)
+
+	// 一小时后在 51000 双方平仓：多头 +1000，空头 -1000
+	h.clock.Advance(time.Hour)
+	require.NoError(t, proc.ClosePosition(h.ctx, &ClosePositionRequest{UserID: 1, Symbol: "TESTBTCUSDT", Price: 51000 * Precision}))
+	require.NoError(t, proc.ClosePosition(h.ctx, &ClosePositionRequest{UserID: 2, Symbol: "TESTBTCUSDT", Price: 51000 * Precision}))
+	h.waitFor("TESTBTCUSDT", mtrade.EventTrade, 1)
+
+	long = h.position(1, "TESTBTCUSDT")
+	assert.True(t, long.IsEmpty())
+	assert.Equal(t, int64(1000*Precision), long.RealizedPnL)
+	assert.Equal(t, h.clock.Now().UnixMilli(), long.UpdatedAt)
+	assert.Equal(t, int64(-1000*Precision), h.position(2, "TESTBTCUSDT").RealizedPnL)
+
+	avail, _ = h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(11000*Precision), avail)
+	avail, _ = h.ledger.balance(2, "USDT")
+	assert.Equal(t, int64(9000*Precision), avail)
+
+	orders, _ := h.orders.GetByUserAndSymbol(h.ctx, 1, "TESTBTCUSDT", 10)
+	assert.Len(t, orders, 2)
+}
+
+func TestHarness_MultiAsset(t *testing.T) {
+	h := newHarness(t, harnessLinearSpec(), harnessInverseSpec())
+	inverse := h.procs["TESTBTCUSD"]
+	h.ledger.AddAvailable(h.ctx, 3, "BTC", Precision)
+	h.ledger.AddAvailable(h.ctx, 3, "USDT", 1000*Precision)
+	h.ledger.AddAvailable(h.ctx, 4, "BTC", Precision)
+
+	// 反向合约保证金按 BTC 冻结：100 张 × 100 USD / 50000 / 10 = 0.02 BTC
+	open := &OpenPositionRequest{
+		OrderID: order.GenerateOrderID(),
+		UserID:  3, Symbol: "TESTBTCUSD", Side: SideLong, Qty: 100 * Precision, Price: 50000 * Precision, Leverage: 10,
+	}
+	require.NoError(t, inverse.OpenPosition(h.ctx, open))
+	avail, locked := h.ledger.balance(3, "BTC")
+	assert.Equal(t, int64(Precision-Precision/50), avail)
+	assert.Equal(t, int64(Precision/50), locked)
+	usdt, _ := h.ledger.balance(3, "USDT")
+	assert.Equal(t, int64(1000*Precision), usdt)
+
+	// 撤单后解冻 (撤单和下单走不同队列，先等订单进簿)
+	h.waitFor("TESTBTCUSD", mtrade.EventOrderAccepted, 1)
+	require.True(t, inverse.CancelOrder(open.OrderID))
+	h.waitFor("TESTBTCUSD", mtrade.EventOrderCanceled, 1)
+	avail, locked = h.ledger.balance(3, "BTC")
+	assert.Equal(t, int64(Precision), avail)
+	assert.Zero(t, locked)
+
+	// 余额不足：USDT 余额不能给 BTC 结算的合约用
+	err := inverse.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 3, Symbol: "TESTBTCUSD", Side: SideLong, Qty: 10000 * Precision, Price: 50000 * Precision, Leverage: 1,
+	})
+	assert.ErrorIs(t, err, ErrInsufficientMargin)
+	assert.Equal(t, cexerr.Code("FUTURES_INSUFFICIENT_MARGIN"), cexerr.CodeOf(err))
+
+	// 成交后涨到 55000 平仓：多头赚 10000 × (1/50000 − 1/55000) ≈ 0.01818182 BTC
+	open.OrderID = 0
+	require.NoError(t, inverse.OpenPosition(h.ctx, open))
+	require.NoError(t, inverse.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 4, Symbol: "TESTBTCUSD", Side: SideShort, Qty: 100 * Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	h.waitFor("TESTBTCUSD", mtrade.EventTrade, 1)
+	assert.Equal(t, int64(100*Precision), h.position(3, "TESTBTCUSD").Size)
+	assert.Nil(t, h.position(3, "TESTBTCUSDT"))
+
+	require.NoError(t, inverse.ClosePosition(h.ctx, &ClosePositionRequest{UserID: 3, Symbol: "TESTBTCUSD", Price: 55000 * Precision}))
+	require.NoError(t, inverse.ClosePosition(h.ctx, &ClosePositionRequest{UserID: 4, Symbol: "TESTBTCUSD", Price: 55000 * Precision}))
+	h.waitFor("TESTBTCUSD", mtrade.EventTrade, 1)
+
+	assert.Equal(t, int64(1818182), h.position(3, "TESTBTCUSD").RealizedPnL)
+	avail, _ = h.ledger.balance(3, "BTC")
+	assert.Equal(t, int64(Precision-Precision/50+Precision/50+1818182), avail)
+	avail, _ = h.ledger.balance(4, "BTC")
+	assert.Equal(t, int64(Precision-Precision/50+Precision/50-1818182), avail)
+}
@@ -0,0 +1,35 @@
+// 文件: pkg/futures/index_price.go
+// 指数价格接入 - 按合约 PriceSources 向指数服务注册
+//
+// indexprice.Service 聚合外部交易所现货价格，发布到 MarkPriceService.UpdateIndexPrice，
+// 资金费率 (GetIndexPrice) 直接读到聚合后的指数
+
+package futures
+
+import (
+	"context"
+
+	"max.com/pkg/indexprice"
+)
+
+var _ indexprice.Publisher = (*MarkPriceService)(nil)
+
+// TrackIndexPrices 为全部合约注册指数跟踪 (交易对取 Base/Quote，来源取 PriceSources)
+//
+// 已下架的合约也注册：交割结算还要用到期前的指数
+func TrackIndexPrices(ctx context.Context, manager *ContractManager, svc *indexprice.Service) error {
+	specs, err := manager.GetAllContracts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if len(spec.PriceSources) == 0 {
+			continue
+		}
+		pair := indexprice.Pair{Base: spec.BaseCurrency, Quote: spec.QuoteCurrency}
+		if err := svc.Track(spec.Symbol, pair, spec.PriceSources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
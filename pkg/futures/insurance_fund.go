@@ -18,12 +18,15 @@ package futures
 
 import (
 	"context"
-	"errors"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"gorm.io/gorm"
+
+	"max.com/pkg/audit"
+	"max.com/pkg/cexerr"
 )
 
 // =============================================================================
@@ -31,7 +34,17 @@ import (
 // =============================================================================
 
 var (
-	ErrInsufficientInsuranceFund = errors.New("insufficient insurance fund")
+	ErrInsufficientInsuranceFund = cexerr.New("FUTURES_INSUFFICIENT_INSURANCE_FUND", cexerr.CategoryInsufficientFunds, "insufficient insurance fund")
+	ErrInvalidInsuranceAmount    = cexerr.New("FUTURES_INVALID_INSURANCE_AMOUNT", cexerr.CategoryInvalidArgument, "insurance fund amount must be positive")
+)
+
+// 流水类型
+const (
+	InsuranceChangeDeposit           = "DEPOSIT"            // 平台注资 (管理员)
+	InsuranceChangeWithdraw          = "WITHDRAW"           // 平台提取 (管理员)
+	InsuranceChangeLiquidationProfit = "LIQUIDATION_PROFIT" // 强平盈余注入
+	InsuranceChangeBankruptCover     = "BANKRUPT_COVER"     // 穿仓兜底
+	InsuranceChangeLiquidationFee    = "LIQUIDATION_FEE"    // 强平手续费
 )
 
 // =============================================================================
@@ -50,6 +63,8 @@ type InsuranceFund struct {
 	// 内存缓存 (减少 DB 查询)
 	// currency -> balance
 	balanceCache sync.Map
+
+	auditor audit.Recorder // 审计管理操作 (可选，见 audit.go)
 }
 
 func NewInsuranceFund(db *gorm.DB) *InsuranceFund {
@@ -118,11 +133,26 @@ func (f *InsuranceFund) AddFunds(
 	symbol string,
 	remark string,
 ) error {
+	_, err := f.addFunds(ctx, currency, amount, changeType, userID, symbol, remark)
+	return err
+}
+
+// addFunds 增加保险基金，返回增加后的余额
+func (f *InsuranceFund) addFunds(
+	ctx context.Context,
+	currency string,
+	amount int64,
+	changeType string,
+	userID int64,
+	symbol string,
+	remark string,
+) (int64, error) {
 	if amount <= 0 {
-		return errors.New("amount must be positive")
+		return 0, ErrInvalidInsuranceAmount
 	}
 
-	return f.db.Transaction(func(tx *gorm.DB) error {
+	var newBalance int64
+	err := f.db.Transaction(func(tx *gorm.DB) error {
 		// 1. 查询或创建余额记录
 		var balance InsuranceFundBalance
 		err := tx.Where("currency = ?", currency).First(&balance).Error
@@ -138,7 +168,7 @@ func (f *InsuranceFund) AddFunds(
 		}
 
 		// 2. 增加余额
-		newBalance := balance.Balance + amount
+		newBalance = balance.Balance + amount
 		err = tx.Model(&balance).Updates(map[string]any{
 			"balance":    newBalance,
 			"updated_at": time.Now().UnixMilli(),
@@ -170,6 +200,7 @@ func (f *InsuranceFund) AddFunds(
 
 		return nil
 	})
+	return newBalance, err
 }
 
 // CoverBankruptcy 穿仓兜底
@@ -223,7 +254,7 @@ func (f *InsuranceFund) CoverBankruptcy(
 		// 4. 记录流水
 		logEntry := &InsuranceFundLog{
 			Currency:      currency,
-			ChangeType:    "BANKRUPT_COVER",
+			ChangeType:    InsuranceChangeBankruptCover,
 			Amount:        -coveredAmount, // 负数表示减少
 			BalanceAfter:  newBalance,
 			RelatedUserID: userID,
@@ -280,3 +311,208 @@ func (f *InsuranceFund) GetAllBalances() map[string]int64 {
 	})
 	return result
 }
+
+// =============================================================================
+// 管理操作 (平台注资 / 提取)
+// =============================================================================
+
+// Deposit 平台注资
+//
+// operatorID 记录在流水的 RelatedUserID 上，便于追溯是哪个管理员操作的
+func (f *InsuranceFund) Deposit(ctx context.Context, currency string, amount int64, operatorID int64, remark string) error {
+	newBalance, err := f.addFunds(ctx, currency, amount, InsuranceChangeDeposit, operatorID, "", remark)
+	if err != nil {
+		return err
+	}
+	f.auditInsurance(operatorID, InsuranceChangeDeposit, currency, newBalance-amount, newBalance, remark)
+	return nil
+}
+
+// Withdraw 平台提取
+//
+// 【与 CoverBankruptcy 的区别】
+// 穿仓兜底余额不足时扣到 0 为止 (能补多少补多少)
+// 管理员提取必须全额，余额不足直接拒绝
+func (f *InsuranceFund) Withdraw(ctx context.Context, currency string, amount int64, operatorID int64, remark string) error {
+	if amount <= 0 {
+		return ErrInvalidInsuranceAmount
+	}
+
+	var newBalance int64
+	err := f.db.Transaction(func(tx *gorm.DB) error {
+		// 1. 获取当前余额
+		var balance InsuranceFundBalance
+		err := tx.Where("currency = ?", currency).First(&balance).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrInsufficientInsuranceFund
+		} else if err != nil {
+			return err
+		}
+
+		// 2. 检查余额
+		if balance.Balance < amount {
+			return ErrInsufficientInsuranceFund
+		}
+
+		// 3. 扣除余额
+		newBalance = balance.Balance - amount
+		err = tx.Model(&balance).Updates(map[string]any{
+			"balance":    newBalance,
+			"updated_at": time.Now().UnixMilli(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// 4. 记录流水
+		logEntry := &InsuranceFundLog{
+			Currency:      currency,
+			ChangeType:    InsuranceChangeWithdraw,
+			Amount:        -amount,
+			BalanceAfter:  newBalance,
+			RelatedUserID: operatorID,
+			Remark:        remark,
+			CreatedAt:     time.Now().UnixMilli(),
+		}
+		if err := tx.Create(logEntry).Error; err != nil {
+			return err
+		}
+
+		// 5. 更新缓存
+		f.balanceCache.Store(currency, newBalance)
+
+		log.Printf("[InsuranceFund] Operator %d withdrew %d %s, remaining: %d",
+			operatorID, amount, currency, newBalance)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.auditInsurance(operatorID, InsuranceChangeWithdraw, currency, newBalance+amount, newBalance, remark)
+	return nil
+}
+
+// =============================================================================
+// 查询接口
+// =============================================================================
+
+const (
+	defaultInsuranceLogLimit = 100
+	maxInsuranceLogLimit     = 1000
+)
+
+// InsuranceFundLogQuery 流水查询条件
+type InsuranceFundLogQuery struct {
+	Currency    string   // 空表示全部币种
+	ChangeTypes []string // 空表示全部类型
+	StartTime   int64    // 毫秒，包含；0 表示不限
+	EndTime     int64    // 毫秒，不包含；0 表示不限
+	Limit       int      // <=0 使用默认值 100，最大 1000
+	Offset      int
+}
+
+// ListLogs 查询流水 (按时间倒序)
+//
+// 【用途】
+// - 注入历史: ChangeTypes = [LIQUIDATION_PROFIT]
+// - 兜底历史: ChangeTypes = [BANKRUPT_COVER]
+// - 管理操作: ChangeTypes = [DEPOSIT, WITHDRAW]
+func (f *InsuranceFund) ListLogs(ctx context.Context, q InsuranceFundLogQuery) ([]InsuranceFundLog, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultInsuranceLogLimit
+	}
+	limit = min(limit, maxInsuranceLogLimit)
+
+	tx := f.db.WithContext(ctx).Model(&InsuranceFundLog{})
+	if q.Currency != "" {
+		tx = tx.Where("currency = ?", q.Currency)
+	}
+	if len(q.ChangeTypes) > 0 {
+		tx = tx.Where("change_type IN ?", q.ChangeTypes)
+	}
+	if q.StartTime > 0 {
+		tx = tx.Where("created_at >= ?", q.StartTime)
+	}
+	if q.EndTime > 0 {
+		tx = tx.Where("created_at < ?", q.EndTime)
+	}
+
+	var logs []InsuranceFundLog
+	err := tx.Order("created_at DESC, id DESC").Limit(limit).Offset(q.Offset).Find(&logs).Error
+	return logs, err
+}
+
+// InsuranceFundSummary 保险基金统计
+type InsuranceFundSummary struct {
+	Currency string
+	Balance  int64 // 当前余额
+	Since    int64 // 统计起始时间 (毫秒)，0 表示全部历史
+
+	Contributions     int64 // 强平盈余注入合计
+	ContributionCount int
+	Drawdowns         int64 // 穿仓兜底合计 (正数)
+	DrawdownCount     int
+	Deposits          int64 // 平台注资合计
+	Withdrawals       int64 // 平台提取合计 (正数)
+}
+
+// Summary 统计某币种自 since 以来的注入与消耗
+func (f *InsuranceFund) Summary(ctx context.Context, currency string, since int64) (*InsuranceFundSummary, error) {
+	var rows []struct {
+		ChangeType string
+		Total      int64
+		Cnt        int
+	}
+	tx := f.db.WithContext(ctx).Model(&InsuranceFundLog{}).
+		Select("change_type, SUM(amount) AS total, COUNT(*) AS cnt").
+		Where("currency = ?", currency)
+	if since > 0 {
+		tx = tx.Where("created_at >= ?", since)
+	}
+	if err := tx.Group("change_type").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summary := &InsuranceFundSummary{
+		Currency: currency,
+		Balance:  f.GetBalance(currency),
+		Since:    since,
+	}
+	for _, r := range rows {
+		switch r.ChangeType {
+		case InsuranceChangeLiquidationProfit:
+			summary.Contributions = r.Total
+			summary.ContributionCount = r.Cnt
+		case InsuranceChangeBankruptCover:
+			summary.Drawdowns = -r.Total
+			summary.DrawdownCount = r.Cnt
+		case InsuranceChangeDeposit:
+			summary.Deposits = r.Total
+		case InsuranceChangeWithdraw:
+			summary.Withdrawals = -r.Total
+		}
+	}
+	return summary, nil
+}
+
+// InsuranceFundPublicBalance 对外公开的保险基金余额
+//
+// 【为什么公开】
+// 主流交易所都会公布保险基金余额，用户据此评估穿仓后触发 ADL 的概率
+type InsuranceFundPublicBalance struct {
+	Currency string `json:"currency"`
+	Balance  int64  `json:"balance"`
+}
+
+// PublicBalances 按币种排序的公开余额 (只读缓存，不查 DB)
+func (f *InsuranceFund) PublicBalances() []InsuranceFundPublicBalance {
+	all := f.GetAllBalances()
+	result := make([]InsuranceFundPublicBalance, 0, len(all))
+	for currency, balance := range all {
+		result = append(result, InsuranceFundPublicBalance{Currency: currency, Balance: balance})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
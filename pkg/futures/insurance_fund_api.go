@@ -0,0 +1,45 @@
+// 文件: pkg/futures/insurance_fund_api.go
+// 保险基金公开接口
+//
+// 【对外暴露】
+// GET /insurance-fund             所有币种余额
+// GET /insurance-fund?currency=X  单个币种余额
+//
+// 只读内存缓存，不访问 DB，可以放在公开网关后面直接对外
+// 注资/提取等管理操作不在这里暴露，由管理后台调用 Deposit / Withdraw
+
+package futures
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// InsuranceFundResponse 公开接口响应
+type InsuranceFundResponse struct {
+	Timestamp int64                        `json:"timestamp"` // 毫秒
+	Balances  []InsuranceFundPublicBalance `json:"balances"`
+}
+
+// NewInsuranceFundHandler 创建保险基金公开查询接口
+func NewInsuranceFundHandler(fund *InsuranceFund) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		balances := fund.PublicBalances()
+		if currency := r.URL.Query().Get("currency"); currency != "" {
+			balances = []InsuranceFundPublicBalance{{Currency: currency, Balance: fund.GetBalance(currency)}}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(InsuranceFundResponse{
+			Timestamp: time.Now().UnixMilli(),
+			Balances:  balances,
+		})
+	})
+}
@@ -0,0 +1,44 @@
+// 文件: pkg/futures/insurance_fund_test.go
+// 保险基金公开接口测试 (只读缓存，不依赖 MySQL)
+
+package futures
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsuranceFundHandler(t *testing.T) {
+	fund := &InsuranceFund{}
+	fund.balanceCache.Store("USDT", int64(1_000_000))
+	fund.balanceCache.Store("BTC", int64(50))
+	handler := NewInsuranceFundHandler(fund)
+
+	get := func(target string) InsuranceFundResponse {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp InsuranceFundResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	all := get("/insurance-fund")
+	assert.Equal(t, []InsuranceFundPublicBalance{
+		{Currency: "BTC", Balance: 50},
+		{Currency: "USDT", Balance: 1_000_000},
+	}, all.Balances)
+	assert.NotZero(t, all.Timestamp)
+
+	one := get("/insurance-fund?currency=ETH")
+	assert.Equal(t, []InsuranceFundPublicBalance{{Currency: "ETH", Balance: 0}}, one.Balances)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/insurance-fund", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
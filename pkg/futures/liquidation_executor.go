@@ -8,17 +8,22 @@
 // 4. 调用保险基金兜底穿仓
 //
 // 【强平流程】
-// 强平引擎 → LiquidationExecutor → 撮合引擎 → 成交回调 → 保险基金
+// 强平引擎 → LiquidationExecutor → 撤用户挂单 → 撮合引擎 → 成交回调 → 保险基金
+//
+// 【为什么先撤挂单】挂单占着保证金，成交后还会把仓位加回去：
+// 先撤单、等保证金解冻，再按撤单后的持仓计算强平单。
+// 本仓库只有逐仓 (每个合约一个处理器/撮合)，只撤强平合约上的挂单
 
 package futures
 
 import (
 	"context"
-	"errors"
 	"log"
 	"sync"
 	"time"
 
+	"max.com/pkg/audit"
+	"max.com/pkg/fixed"
 	"max.com/pkg/fund"
 	"max.com/pkg/liquidation"
 	"max.com/pkg/mtrade"
@@ -38,12 +43,29 @@ type LiquidationExecutor struct {
 	positionRepo     PositionRepository
 	balanceRepo      *fund.BalanceRepo
 	markPriceService *MarkPriceService
-	insuranceFund    *InsuranceFund
+	insuranceFund    liquidationInsurance
 	orderService     *order.OrderService
+	auditor          audit.Recorder            // 审计 (可选，见 audit.go)
+	positionHistory  PositionHistoryRepository // 历史持仓 (可选，见 position_history.go)
+	onLiquidated     []func(LiquidationFill)
+	orderCanceler    UserOrderCanceler // 强平前撤单 (可选，未设置时直接调撮合引擎全撤)
+	onEscalated      []func(LiquidationEscalation)
+	bookSlippage     int64              // 强平单定价的最大滑点 (万分比，见 book_price.go)
+	collateral       *CollateralService // 多币种抵押品 (可选)：抵押品撑得住的部分不强平
+	guard            *ExecutionGuard    // 最优执行保护 (可选，见 execution_guard.go)
 
 	// 强平订单追踪
-	// orderID -> LiquidationTask
+	// orderID -> *PendingLiquidation，成交完或看门狗升级后删除
 	pendingTasks sync.Map
+	settleMu     sync.Mutex // 成交结算与看门狗升级互斥
+}
+
+// liquidationInsurance 强平用到的保险基金操作，*InsuranceFund 实现
+//
+// 【设计】抽成接口只为测试能注入内存保险基金，生产环境始终是 *InsuranceFund
+type liquidationInsurance interface {
+	AddFunds(ctx context.Context, currency string, amount int64, changeType string, userID int64, symbol string, remark string) error
+	CoverBankruptcy(ctx context.Context, currency string, amount int64, userID int64, symbol string) (int64, error)
 }
 
 func NewLiquidationExecutor(
@@ -63,14 +85,49 @@ func NewLiquidationExecutor(
 		markPriceService: markPriceService,
 		insuranceFund:    insuranceFund,
 		orderService:     orderService,
+		bookSlippage:     DefaultMaxSlippage,
 	}
 
 	// 注册成交回调
-	matchEngine.OnEvent(executor.handleEvent)
+	matchEngine.OnEventWithOptions(executor.handleEvent, mtrade.HandlerOptions{Name: "futures-liquidation"})
 
 	return executor
 }
 
+// liquidationCancelTimeout 强平前撤单 (含保证金解冻) 的最长等待
+const liquidationCancelTimeout = 2 * time.Second
+
+// UserOrderCanceler 撤掉用户在合约上的全部挂单并等保证金解冻 (FuturesProcessor 实现)
+type UserOrderCanceler interface {
+	CancelUserOrders(ctx context.Context, userID int64) ([]int64, error)
+}
+
+var _ UserOrderCanceler = (*FuturesProcessor)(nil)
+
+// SetOrderCanceler 设置强平前撤单的处理器 (同一合约的 FuturesProcessor)
+//
+// 未设置时直接调撮合引擎全撤，只等订单离开订单簿，不等保证金解冻
+func (e *LiquidationExecutor) SetOrderCanceler(c UserOrderCanceler) {
+	e.orderCanceler = c
+}
+
+// SetBookSlippage 设置强平单按盘口定价的最大滑点 (万分比，默认 DefaultMaxSlippage)
+func (e *LiquidationExecutor) SetBookSlippage(rate int64) {
+	if rate > 0 && rate < RatePrecision {
+		e.bookSlippage = rate
+	}
+}
+
+// SetCollateral 设置多币种抵押品估值：强平只平掉抵押品撑不住的部分 (见 liquidationQty)
+func (e *LiquidationExecutor) SetCollateral(c *CollateralService) {
+	e.collateral = c
+}
+
+// SetExecutionGuard 设置最优执行保护：强平单限价超出指数 / 标记价格边界时截到边界价
+func (e *LiquidationExecutor) SetExecutionGuard(g *ExecutionGuard) {
+	e.guard = g
+}
+
 // =============================================================================
 // 实现 liquidation.LiquidationExecutor 接口
 // =============================================================================
@@ -78,102 +135,331 @@ func NewLiquidationExecutor(
 // Execute 执行强平
 //
 // 【核心逻辑】
+// 0. 撤掉用户在该合约上的挂单，等保证金解冻
 // 1. 获取用户持仓
 // 2. 计算破产价格和强平价格
 // 3. 发送强平单到撮合
 // 4. 等待成交
+//
+// task.DryRun=true 时只做 1、2 步，返回预估结果 (见 Preview)
 func (e *LiquidationExecutor) Execute(
 	ctx context.Context,
 	task liquidation.LiquidationTask,
 ) liquidation.LiquidationResult {
-	log.Printf("[Liquidation] Executing task for user %d, symbol %s",
-		task.UserID, task.Symbol)
-
-	// 1. 获取用户持仓
-	pos, err := e.positionRepo.GetByUserAndSymbol(ctx, task.UserID, task.Symbol)
-	if err != nil || pos == nil || pos.Size == 0 {
-		return liquidation.LiquidationResult{
-			UserID:  task.UserID,
-			Success: false,
-			Error:   errors.New("no position found"),
+	log.Printf("[Liquidation] Executing task for user %d, symbol %s, dryRun=%v",
+		task.UserID, task.Symbol, task.DryRun)
+
+	if !task.DryRun {
+		if err := e.cancelOpenOrders(ctx, task.UserID); err != nil {
+			return liquidation.LiquidationResult{
+				UserID:  task.UserID,
+				Success: false,
+				Error:   err,
+			}
 		}
 	}
 
-	// 2. 获取合约规格
-	spec, err := e.contractManager.GetContract(ctx, task.Symbol)
+	plan, err := e.planLiquidation(ctx, task)
 	if err != nil {
 		return liquidation.LiquidationResult{
 			UserID:  task.UserID,
 			Success: false,
 			Error:   err,
+			DryRun:  task.DryRun,
 		}
+	}
+
+	if task.DryRun {
+		preview := plan.preview()
+		return liquidation.LiquidationResult{
+			UserID:     task.UserID,
+			Success:    true,
+			ExecutedAt: time.Now(),
+			DryRun:     true,
+			Details: liquidation.LiquidationDetails{
+				ClosedPositions:  1,
+				TotalPnL:         float64(preview.EstimatedPnL),
+				RemainingBalance: float64(preview.EstimatedRemaining),
+			},
+		}
+	}
+
+	return e.submitLiquidation(plan)
+}
+
+// cancelOpenOrders 强平前撤掉用户的挂单
+//
+// 撤单没完成 (队列满、超时) 不下强平单，返回错误由强平引擎重试：
+// 挂单还在的话，成交会让按旧持仓算出的强平单数量不对
+func (e *LiquidationExecutor) cancelOpenOrders(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, liquidationCancelTimeout)
+	defer cancel()
+
+	var ids []int64
+	var err error
+	if e.orderCanceler != nil {
+		ids, err = e.orderCanceler.CancelUserOrders(ctx, userID)
+	} else {
+		ids, err = e.matchEngine.CancelAllByUser(ctx, userID)
+	}
+	if err != nil {
+		log.Printf("[Liquidation] Cancel open orders of user %d failed: %v", userID, err)
+		return err
+	}
+	// 上一轮没成交的强平单也被撤掉了，本轮按最新持仓重新下
+	for _, id := range ids {
+		e.pendingTasks.Delete(id)
+	}
+	return nil
+}
 
+// Preview 预演强平 (运维用)
+//
+// 与 Execute 共用持仓、破产价、强平单的计算，
+// 盘口吃得满按盘口均价、否则按标记价格估算成交 (限价单不会劣于强平价)，不下单、不改持仓
+func (e *LiquidationExecutor) Preview(
+	ctx context.Context,
+	task liquidation.LiquidationTask,
+) (*LiquidationPreview, error) {
+	plan, err := e.planLiquidation(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	preview := plan.preview()
+	return &preview, nil
+}
+
+// LiquidationPreview 强平预演结果
+type LiquidationPreview struct {
+	UserID        int64
+	Symbol        string
+	Side          mtrade.Side
+	Qty           int64
+	OrderPrice    int64 // 强平单价格
+	BankruptPrice int64
+	MarkPrice     int64
+
+	EstimatedFillPrice int64
+	EstimatedPnL       int64
+	// EstimatedRemaining 保证金 + 盈亏
+	// > 0: 强平盈余，归保险基金
+	// < 0: 穿仓，需保险基金补足
+	EstimatedRemaining int64
+}
+
+// liquidationPlan 强平单计划 (Execute 与 Preview 共用)
+type liquidationPlan struct {
+	task          liquidation.LiquidationTask
+	pos           *Position
+	spec          *ContractSpec
+	markPrice     int64
+	bankruptPrice int64
+	quote         BookQuote    // 下单时的盘口估算
+	order         mtrade.Order // ID 在提交时生成
+
+	// 执行保护改价 (见 execution_guard.go)，提交后发事件
+	guarded   bool
+	requested int64 // 改价前的限价
+	bound     executionBound
+}
+
+// planLiquidation 计算强平单 (只读)
+func (e *LiquidationExecutor) planLiquidation(
+	ctx context.Context,
+	task liquidation.LiquidationTask,
+) (*liquidationPlan, error) {
+	// 1. 获取用户持仓
+	pos, err := e.positionRepo.GetByUserAndSymbol(ctx, task.UserID, task.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil || pos.Size == 0 {
+		return nil, ErrNoPosition
+	}
+
+	// 2. 获取合约规格
+	spec, err := e.contractManager.GetContract(ctx, task.Symbol)
+	if err != nil {
+		return nil, err
 	}
 
 	// 3. 获取当前标记价格
 	markPrice := e.markPriceService.GetMarkPrice(task.Symbol)
 	if markPrice <= 0 {
-		return liquidation.LiquidationResult{
-			UserID:  task.UserID,
-			Success: false,
-			Error:   errors.New("no mark price"),
-		}
-
+		return nil, ErrNoMarkPrice
 	}
 
 	// 4. 计算破产价格 (用户亏光保证金的价格)
 	// 多头: 破产价 = 开仓价 - 保证金 / 数量
 	// 空头: 破产价 = 开仓价 + 保证金 / 数量
-	bankruptPrice := e.calculateBankruptPrice(pos)
+	bankruptPrice := e.calculateBankruptPrice(spec, pos)
 
-	// 5. 强平价格 = 破产价格 (简化处理)
-	// 实际交易所会留一点缓冲给保险基金
-	liquidationPrice := bankruptPrice
+	// 4.1 强平数量：抵押品撑得住的部分保留，只平超出的部分
+	qty, err := e.liquidationQty(ctx, spec, pos, markPrice)
+	if err != nil {
+		return nil, err
+	}
 
-	// 6. 确定强平方向
+	// 5. 确定强平方向
 	var liqSide mtrade.Side
+	closeSide := SideLong
 	if pos.Size > 0 {
 		liqSide = mtrade.SideSell // 多头 → 卖出平仓
+		closeSide = SideShort
 	} else {
 		liqSide = mtrade.SideBuy // 空头 → 买入平仓
 	}
 
+	// 6. 强平价格：按盘口扫单价，滑点封顶 (见 book_price.go)，不劣于破产价
+	// 直接挂破产价会把对手盘一路扫到破产价，本该进保险基金的剩余保证金被滑点吃掉
+	var depth BookDepth
+	if e.matchEngine != nil {
+		depth = e.matchEngine
+	}
+	quote := quoteDepth(depth, spec, closeSide, qty, markPrice, e.bookSlippage)
+	liquidationPrice := tighter(closeSide, quote.Price, bankruptPrice)
+
+	// 6.1 超出指数 / 标记价格执行边界时截到边界价 (边界比限价保守，自然也不劣于破产价)
+	bound, guarded, err := e.guard.check(spec, GuardLiquidation, closeSide, liquidationPrice)
+	if err != nil {
+		return nil, err
+	}
+	requested := liquidationPrice
+	if guarded {
+		liquidationPrice = bound.price
+	}
+
+	return &liquidationPlan{
+		task:          task,
+		pos:           pos,
+		spec:          spec,
+		markPrice:     markPrice,
+		bankruptPrice: bankruptPrice,
+		quote:         quote,
+		guarded:       guarded,
+		requested:     requested,
+		bound:         bound,
+		order: mtrade.Order{
+			UserID: task.UserID,
+			Symbol: task.Symbol,
+			Side:   liqSide,
+			Type:   mtrade.OrderTypeLimit, // 限价单，吃不满的部分挂在限价等对手盘
+			Price:  liquidationPrice,
+			Qty:    qty,
+		},
+	}, nil
+}
+
+// collateralLiquidationBuffer 部分强平后保留的持仓要多留的维持保证金余量 (万分比)，
+// 避免价格稍一波动又触发下一轮强平
+const collateralLiquidationBuffer = 1000
+
+// liquidationQty 强平数量，未配置抵押品时平掉全部持仓
+//
+// 保留 k 后：权益 = (保证金 + 未实现盈亏) × k / |size| + 抵押品价值，
+// 要求 权益 ≥ 维持保证金(k) × (1 + 余量)，二分找最大的 k，强平 |size| − k (不低于最小下单量)
+//
+// 抵押品撑得住全部持仓时返回 ErrLiquidationNotNeeded：触发强平用的数据已过时
+func (e *LiquidationExecutor) liquidationQty(ctx context.Context, spec *ContractSpec, pos *Position, markPrice int64) (int64, error) {
+	size := pos.AbsSize()
+	if e.collateral == nil {
+		return size, nil
+	}
+	collateral, err := collateralValue(ctx, e.collateral, pos.UserID, spec.SettleCurrency)
+	if err != nil || collateral <= 0 {
+		return size, nil // 估值失败按没有抵押品处理，宁可多平
+	}
+
+	equity := pos.Margin + spec.PnL(pos.Size, pos.EntryPrice, markPrice)
+	supports := func(k int64) bool {
+		required := fixed.ApplyBps(spec.CalcMaintMargin(spec.PositionValue(k, markPrice)), collateralLiquidationBuffer, fixed.RoundDown)
+		return fixed.MulDiv(equity, k, size)+collateral >= required
+	}
+	if supports(size) {
+		return 0, ErrLiquidationNotNeeded
+	}
+	lo, hi := int64(0), size // supports(lo) 恒成立，supports(hi) 不成立
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if supports(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return min(size, max(size-lo, spec.MinOrderQty)), nil
+}
+
+// preview 估算成交结果：盘口吃得满按盘口均价，否则按标记价格
+func (p *liquidationPlan) preview() LiquidationPreview {
+	// 限价单只会以不劣于强平价的价格成交
+	fillPrice := p.markPrice
+	if p.quote.Complete(p.order.Qty) {
+		fillPrice = p.quote.AvgPrice
+	}
+	if p.order.Side == mtrade.SideSell {
+		fillPrice = max(fillPrice, p.order.Price)
+	} else {
+		fillPrice = min(fillPrice, p.order.Price)
+	}
+	pnl := liquidationPnL(p.spec, p.pos, fillPrice, p.order.Qty)
+	margin := fixed.MulDiv(p.pos.Margin, p.order.Qty, p.pos.AbsSize()) // 部分强平只动用对应比例的保证金
+
+	return LiquidationPreview{
+		UserID:             p.task.UserID,
+		Symbol:             p.task.Symbol,
+		Side:               p.order.Side,
+		Qty:                p.order.Qty,
+		OrderPrice:         p.order.Price,
+		BankruptPrice:      p.bankruptPrice,
+		MarkPrice:          p.markPrice,
+		EstimatedFillPrice: fillPrice,
+		EstimatedPnL:       pnl,
+		EstimatedRemaining: margin + pnl,
+	}
+}
+
+// submitLiquidation 提交强平单到撮合引擎
+func (e *LiquidationExecutor) submitLiquidation(plan *liquidationPlan) liquidation.LiquidationResult {
 	// 7. 生成订单ID
 	orderID := order.GenerateOrderID()
 
 	// 8. 创建强平订单
-	liqOrder := &mtrade.Order{
-		ID:     orderID,
-		UserID: task.UserID,
-		Symbol: task.Symbol,
-		Side:   liqSide,
-		Type:   mtrade.OrderTypeLimit, // 限价单，价格为破产价
-		Price:  liquidationPrice,
-		Qty:    pos.AbsSize(),
-	}
+	liqOrder := plan.order
+	liqOrder.ID = orderID
 
 	// 9. 保存任务信息 (用于成交后处理)
 	e.pendingTasks.Store(orderID, &PendingLiquidation{
-		Task:           task,
-		Position:       *pos,
-		BankruptPrice:  bankruptPrice,
-		SettleCurrency: spec.SettleCurrency,
+		Task:           plan.task,
+		Position:       *plan.pos,
+		BankruptPrice:  plan.bankruptPrice,
+		SettleCurrency: plan.spec.SettleCurrency,
+		Spec:           plan.spec,
 		SubmittedAt:    time.Now().UnixMilli(),
+		OrderQty:       liqOrder.Qty,
 	})
 
 	// 10. 提交到撮合引擎
 	// 【特殊处理】强平单可能需要优先成交
 	// 部分交易所会让强平单优先于普通订单
-	if !e.matchEngine.SubmitOrder(liqOrder) {
+	if !e.matchEngine.SubmitOrder(&liqOrder) {
 		e.pendingTasks.Delete(orderID)
 		return liquidation.LiquidationResult{
 			Success: false,
-			Error:   errors.New("submit liquidation order failed"),
+			Error:   ErrSubmitOrderFailed,
 		}
 	}
 
 	log.Printf("[Liquidation] Order submitted: orderID=%d, user=%d, size=%d, price=%d",
-		orderID, task.UserID, pos.AbsSize(), liquidationPrice)
+		orderID, plan.task.UserID, liqOrder.Qty, liqOrder.Price)
+	e.auditLiquidation(plan, orderID)
+	if plan.guarded {
+		closeSide := SideLong
+		if liqOrder.Side == mtrade.SideSell {
+			closeSide = SideShort
+		}
+		e.guard.emit(GuardLiquidation, plan.task.UserID, orderID, plan.spec, closeSide, plan.requested, plan.bound)
+	}
 
 	// 11. 返回结果 (实际成交在回调中处理)
 	return liquidation.LiquidationResult{
@@ -183,12 +469,47 @@ func (e *LiquidationExecutor) Execute(
 }
 
 // PendingLiquidation 待处理的强平任务
+//
+// 强平单可能分多笔成交，每笔按成交量结算，Position 随之减仓，
+// 全部成交 (或看门狗升级) 后任务结束
 type PendingLiquidation struct {
 	Task           liquidation.LiquidationTask
-	Position       Position
+	Position       Position // 剩余未强平的持仓 (每笔成交后减少)
 	BankruptPrice  int64
 	SettleCurrency string
+	Spec           *ContractSpec // 合约规格 (正向/反向盈亏公式不同)
 	SubmittedAt    int64
+
+	OrderQty    int64 // 强平单数量
+	FilledQty   int64 // 累计成交量
+	RealizedPnL int64 // 累计强平盈亏
+	done        bool  // 已成交完或已升级，迟到的成交不再结算
+}
+
+// LiquidationFill 强平单成交后的处理结果 (通知下游)
+type LiquidationFill struct {
+	UserID         int64
+	Symbol         string
+	OrderID        int64 // 强平单 ID
+	TradeID        int64
+	Price          int64 // 成交价
+	Qty            int64
+	PositionSize   int64 // 强平前持仓 (正=多, 负=空)
+	BankruptPrice  int64
+	PnL            int64 // 强平盈亏
+	Fee            int64 // 强平手续费 (归保险基金)
+	Surplus        int64 // 注入保险基金的盈余 (扣除手续费后)
+	Shortfall      int64 // 穿仓金额 (由保险基金承担)
+	Uncovered      int64 // 保险基金未能覆盖的穿仓金额 (穿仓分摊，见 social_loss.go)
+	RemainingQty   int64 // 本笔成交后强平单剩余未成交的数量，0 表示强平完成 (部分强平时持仓可能还有剩余)
+	SettleCurrency string
+	At             int64 // 毫秒
+}
+
+// OnLiquidated 注册强平成交回调 (可注册多个，在撮合引擎启动前调用)
+// 回调在撮合事件 goroutine 中同步执行
+func (e *LiquidationExecutor) OnLiquidated(callback func(LiquidationFill)) {
+	e.onLiquidated = append(e.onLiquidated, callback)
 }
 
 // =============================================================================
@@ -203,49 +524,82 @@ func (e *LiquidationExecutor) handleEvent(event mtrade.Event) {
 
 	trade := event.Trade
 
-	// 检查是否是强平订单
+	// 检查是否是强平订单 (部分成交时任务保留，等剩余成交)
 	if pending, ok := e.pendingTasks.Load(trade.TakerID); ok {
-		e.handleLiquidationFill(trade, pending.(*PendingLiquidation), true)
-		e.pendingTasks.Delete(trade.TakerID)
+		if e.handleLiquidationFill(trade, trade.TakerID, pending.(*PendingLiquidation), true) {
+			e.pendingTasks.Delete(trade.TakerID)
+		}
 	}
 	if pending, ok := e.pendingTasks.Load(trade.MakerID); ok {
-		e.handleLiquidationFill(trade, pending.(*PendingLiquidation), false)
-		e.pendingTasks.Delete(trade.MakerID)
+		if e.handleLiquidationFill(trade, trade.MakerID, pending.(*PendingLiquidation), false) {
+			e.pendingTasks.Delete(trade.MakerID)
+		}
 	}
 }
 
-// handleLiquidationFill 处理强平成交
+// handleLiquidationFill 处理强平成交，返回强平任务是否结束
 //
-// 【核心逻辑】
-// 1. 计算强平盈亏
-// 2. 如果成交价优于破产价 → 差额归保险基金
-// 3. 如果成交价劣于破产价 → 从保险基金扣除
-// 4. 清空用户持仓
+// 【核心逻辑】每笔成交按成交量结算：
+// 1. 按剩余持仓比例分摊保证金 (最后一笔拿走全部剩余保证金，不留取整零头)
+// 2. 计算本笔强平盈亏
+// 3. 从剩余保证金收强平手续费 (ContractSpec.LiquidationFeeRate)，归保险基金
+// 4. 如果成交价优于破产价 → 差额归保险基金
+// 5. 如果成交价劣于破产价 → 从保险基金扣除
+// 6. 减仓，全部成交后清空持仓
 func (e *LiquidationExecutor) handleLiquidationFill(
 	trade *mtrade.Trade,
+	orderID int64,
 	pending *PendingLiquidation,
 	isTaker bool,
-) {
+) bool {
+	e.settleMu.Lock()
+	defer e.settleMu.Unlock()
+	if pending.done {
+		return true // 看门狗已经升级，剩余仓位交给下一轮强平
+	}
+
 	ctx := context.Background()
 	pos := &pending.Position
+	qty := min(int64(trade.Qty), pos.AbsSize())
+	if qty <= 0 {
+		pending.done = true
+		return true
+	}
 
-	log.Printf("[Liquidation] Fill received: user=%d, price=%d, qty=%d",
-		pending.Task.UserID, trade.Price, trade.Qty)
+	log.Printf("[Liquidation] Fill received: user=%d, price=%d, qty=%d, remaining=%d",
+		pending.Task.UserID, trade.Price, qty, pos.AbsSize()-qty)
 
-	// 1. 计算强平盈亏
+	// 1. 本笔成交对应的保证金
+	margin := pos.Margin
+	if qty < pos.AbsSize() {
+		margin = fixed.MulDiv(pos.Margin, qty, pos.AbsSize())
+	}
+
+	// 2. 计算强平盈亏
 	// 多头: PnL = (成交价 - 开仓价) × 数量
 	// 空头: PnL = (开仓价 - 成交价) × 数量
-	var pnl int64
-	if pos.Size > 0 {
-		pnl = (trade.Price - pos.EntryPrice) * int64(trade.Qty) / Precision
-	} else {
-		pnl = (pos.EntryPrice - trade.Price) * int64(trade.Qty) / Precision
-	}
+	pnl := liquidationPnL(pending.Spec, pos, trade.Price, qty)
+
+	// 3. 计算剩余金额 = 保证金 + 盈亏
+	remaining := margin + pnl
 
-	// 2. 计算剩余金额 = 保证金 + 盈亏
-	remaining := pos.Margin + pnl
+	// 4. 从剩余金额里收强平手续费，不够就收到剩余为止 (穿仓不收)
+	fee := min(pending.Spec.CalcLiquidationFee(pending.Spec.PositionValue(qty, trade.Price)), max(remaining, 0))
+	if fee > 0 {
+		e.insuranceFund.AddFunds(
+			ctx,
+			pending.SettleCurrency,
+			fee,
+			InsuranceChangeLiquidationFee,
+			pending.Task.UserID,
+			pending.Task.Symbol,
+			"Liquidation fee",
+		)
+		remaining -= fee
+	}
 
-	// 3. 处理强平剩余/穿仓
+	// 5. 处理强平剩余/穿仓
+	var uncovered int64
 	if remaining > 0 {
 		// 【强平盈余】成交价格优于破产价格
 		// 剩余金额归保险基金
@@ -253,7 +607,7 @@ func (e *LiquidationExecutor) handleLiquidationFill(
 			ctx,
 			pending.SettleCurrency,
 			remaining,
-			"LIQUIDATION_PROFIT",
+			InsuranceChangeLiquidationProfit,
 			pending.Task.UserID,
 			pending.Task.Symbol,
 			"Liquidation surplus",
@@ -273,30 +627,157 @@ func (e *LiquidationExecutor) handleLiquidationFill(
 		)
 
 		if err != nil || covered < bankruptAmount {
-			// 保险基金不足，需要触发 ADL
-			log.Printf("[Liquidation] WARNING: Insurance fund insufficient, need ADL!")
-			// TODO: 触发 ADL
+			// 保险基金不足：未覆盖部分随回调交给穿仓分摊 (见 social_loss.go)
+			uncovered = bankruptAmount - max(covered, 0)
+			log.Printf("[Liquidation] WARNING: Insurance fund insufficient, uncovered %d", uncovered)
 		} else {
 			log.Printf("[Liquidation] Bankruptcy %d covered by insurance fund", covered)
 		}
 	}
 
-	// 4. 清空用户持仓
-	pos.RealizedPnL += pnl
-	pos.Size = 0
-	pos.Margin = 0
-	pos.EntryPrice = 0
+	fill := LiquidationFill{
+		UserID:         pending.Task.UserID,
+		Symbol:         pending.Task.Symbol,
+		OrderID:        orderID,
+		TradeID:        trade.ID,
+		Price:          trade.Price,
+		Qty:            qty,
+		PositionSize:   pos.Size,
+		BankruptPrice:  pending.BankruptPrice,
+		PnL:            pnl,
+		Fee:            fee,
+		Surplus:        max(remaining, 0),
+		Shortfall:      max(-remaining, 0),
+		Uncovered:      uncovered,
+		RemainingQty:   min(pos.AbsSize(), pending.OrderQty-pending.FilledQty) - qty,
+		SettleCurrency: pending.SettleCurrency,
+	}
+
+	// 6. 减仓，全部成交后清空持仓；部分强平 (见 liquidationQty) 的强平单成交完即结束
+	side, entryPrice := pos.Side(), pos.EntryPrice
+	pos.recordClose(trade.Price, qty, pnl)
+	pos.CycleFee += fee
+	pos.CycleLiqFee += fee
+	if pos.Size > 0 {
+		pos.Size -= qty
+	} else {
+		pos.Size += qty
+	}
+	pos.Margin -= margin
+	pending.FilledQty += qty
+	pending.RealizedPnL += pnl
+	pending.done = pos.Size == 0 || pending.FilledQty >= pending.OrderQty
+	if pos.Size == 0 {
+		pos.Margin = 0
+		pos.EntryPrice = 0
+	}
 	pos.UpdatedAt = time.Now().UnixMilli()
 
 	e.positionRepo.Save(ctx, pos)
+	if pos.Size == 0 {
+		recordPositionHistory(ctx, e.positionHistory, pos, side, entryPrice, CloseReasonLiquidation)
+		log.Printf("[Liquidation] User %d position liquidated, PnL=%d", pending.Task.UserID, pending.RealizedPnL)
+	}
+
+	fill.At = pos.UpdatedAt
+	for _, cb := range e.onLiquidated {
+		cb(fill)
+	}
+	return pending.done
+}
+
+// =============================================================================
+// 看门狗
+// =============================================================================
+
+// LiquidationEscalation 超时未成交完的强平单
+type LiquidationEscalation struct {
+	UserID       int64
+	Symbol       string
+	OrderID      int64
+	OrderQty     int64
+	FilledQty    int64
+	RemainingQty int64 // 剩余持仓，留给下一轮强平
+	SubmittedAt  int64 // 毫秒
+}
+
+// OnEscalated 注册看门狗升级回调 (告警、转人工)
+func (e *LiquidationExecutor) OnEscalated(callback func(LiquidationEscalation)) {
+	e.onEscalated = append(e.onEscalated, callback)
+}
 
-	log.Printf("[Liquidation] User %d position liquidated, PnL=%d", pending.Task.UserID, pnl)
+// EscalateStale 看门狗：提交超过 maxAge 仍未成交完的强平单撤单并结束任务
+//
+// 已成交部分已经逐笔结算，持仓里只剩未成交的数量和对应保证金，
+// 风险率仍超标时强平引擎会按剩余持仓重新下强平单
+func (e *LiquidationExecutor) EscalateStale(maxAge time.Duration) []LiquidationEscalation {
+	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	var out []LiquidationEscalation
+
+	e.settleMu.Lock()
+	e.pendingTasks.Range(func(key, val any) bool {
+		pending := val.(*PendingLiquidation)
+		if pending.done || pending.SubmittedAt > cutoff {
+			return true
+		}
+		orderID := key.(int64)
+		pending.done = true
+		e.pendingTasks.Delete(orderID)
+		e.matchEngine.CancelOrder(orderID)
+		out = append(out, LiquidationEscalation{
+			UserID:       pending.Task.UserID,
+			Symbol:       pending.Task.Symbol,
+			OrderID:      orderID,
+			OrderQty:     pending.OrderQty,
+			FilledQty:    pending.FilledQty,
+			RemainingQty: pending.Position.AbsSize(),
+			SubmittedAt:  pending.SubmittedAt,
+		})
+		return true
+	})
+	e.settleMu.Unlock()
+
+	for _, esc := range out {
+		log.Printf("[Liquidation] Escalated order %d: user=%d, filled=%d/%d, remaining=%d",
+			esc.OrderID, esc.UserID, esc.FilledQty, esc.OrderQty, esc.RemainingQty)
+		for _, cb := range e.onEscalated {
+			cb(esc)
+		}
+	}
+	return out
+}
+
+// StartWatchdog 每 interval 检查一次超过 maxAge 的强平单，ctx 取消时退出
+func (e *LiquidationExecutor) StartWatchdog(ctx context.Context, interval, maxAge time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.EscalateStale(maxAge)
+			}
+		}
+	}()
 }
 
 // =============================================================================
 // 辅助方法
 // =============================================================================
 
+// liquidationPnL 强平成交盈亏
+// 多头: PnL = (成交价 - 开仓价) × 数量
+// 空头: PnL = (开仓价 - 成交价) × 数量
+// 反向合约见 ContractSpec.PnL
+func liquidationPnL(spec *ContractSpec, pos *Position, price, qty int64) int64 {
+	if pos.Size < 0 {
+		qty = -qty
+	}
+	return spec.PnL(qty, pos.EntryPrice, price)
+}
+
 // calculateBankruptPrice 计算破产价格
 //
 // 【公式】
@@ -306,19 +787,8 @@ func (e *LiquidationExecutor) handleLiquidationFill(
 // 【直观理解】
 // 破产价就是"亏光保证金"的价格
 // 100x 杠杆多仓: 价格跌 1% 就破产
-func (e *LiquidationExecutor) calculateBankruptPrice(pos *Position) int64 {
-	if pos.Size == 0 {
-		return 0
-	}
-
-	// 保证金对应的价格变动 = Margin / |Size| * Precision
-	marginPerUnit := pos.Margin * Precision / pos.AbsSize()
-
-	if pos.Size > 0 {
-		// 多头: 破产价 = 开仓价 - 保证金/数量
-		return pos.EntryPrice - marginPerUnit
-	} else {
-		// 空头: 破产价 = 开仓价 + 保证金/数量
-		return pos.EntryPrice + marginPerUnit
-	}
+//
+// 反向合约见 ContractSpec.BankruptPrice
+func (e *LiquidationExecutor) calculateBankruptPrice(spec *ContractSpec, pos *Position) int64 {
+	return spec.BankruptPrice(pos.Size, pos.EntryPrice, pos.Margin)
 }
@@ -0,0 +1,210 @@
+// 文件: pkg/futures/liquidation_executor_test.go
+// 强平执行器测试 (内存夹具，见 harness_test.go)
+
+package futures
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+)
+
+func TestHarness_LiquidationCancelsOpenOrdersFirst(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 20000*Precision)
+	h.ledger.AddAvailable(h.ctx, 2, "USDT", 20000*Precision)
+
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, nil, nil, nil)
+	executor.SetOrderCanceler(proc)
+
+	// 用户 1 两笔挂单各冻结 5000，用户 2 一笔
+	for _, o := range []*OpenPositionRequest{
+		{UserID: 1, Side: SideLong, Price: 50000 * Precision},
+		{UserID: 1, Side: SideShort, Price: 52000 * Precision},
+		{UserID: 2, Side: SideLong, Price: 49000 * Precision},
+	} {
+		o.Symbol, o.Qty, o.Leverage = symbol, Precision, 10
+		require.NoError(t, proc.OpenPosition(h.ctx, o))
+	}
+	// 上一轮没成交的强平单也挂在簿上
+	stale := &mtrade.Order{ID: 999, UserID: 1, Symbol: symbol, Side: mtrade.SideSell, Type: mtrade.OrderTypeLimit, Price: 60000 * Precision, Qty: Precision}
+	executor.pendingTasks.Store(stale.ID, &PendingLiquidation{})
+	require.True(t, h.engines[symbol].SubmitOrder(stale))
+	h.waitFor(symbol, mtrade.EventOrderAccepted, 4)
+
+	require.NoError(t, executor.cancelOpenOrders(h.ctx, 1))
+
+	// 返回时保证金已经解冻
+	avail, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(20000*Precision), avail)
+	assert.Zero(t, locked)
+	_, ok := executor.pendingTasks.Load(stale.ID)
+	assert.False(t, ok, "stale liquidation order should be forgotten")
+
+	// 其他用户不受影响
+	_, locked = h.ledger.balance(2, "USDT")
+	assert.Equal(t, int64(4900*Precision), locked)
+	assert.Equal(t, 1, h.engines[symbol].GetOrderBook().GetSnapshot().Orders)
+}
+
+// memInsurance 内存保险基金，余额足够时全额兜底
+type memInsurance struct {
+	balance int64
+	added   int64
+	covered int64
+}
+
+func (f *memInsurance) AddFunds(ctx context.Context, currency string, amount int64, changeType string, userID int64, symbol string, remark string) error {
+	f.balance += amount
+	f.added += amount
+	return nil
+}
+
+func (f *memInsurance) CoverBankruptcy(ctx context.Context, currency string, amount int64, userID int64, symbol string) (int64, error) {
+	covered := min(amount, f.balance)
+	f.balance -= covered
+	f.covered += covered
+	return covered, nil
+}
+
+func TestHarness_LiquidationPartialFills(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	insurance := &memInsurance{}
+
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, nil, nil, nil)
+	executor.insuranceFund = insurance
+	var fills []LiquidationFill
+	executor.OnLiquidated(func(f LiquidationFill) { fills = append(fills, f) })
+
+	// 多 2 BTC @50000，保证金 10000
+	pos := &Position{UserID: 1, Symbol: symbol, Size: 2 * Precision, EntryPrice: 50000 * Precision, Margin: 10000 * Precision, Leverage: 10}
+	require.NoError(t, h.positions.Save(h.ctx, pos))
+	const liqOrderID = 777
+	executor.pendingTasks.Store(int64(liqOrderID), &PendingLiquidation{
+		Position:       *pos,
+		SettleCurrency: "USDT",
+		Spec:           harnessLinearSpec(),
+		SubmittedAt:    time.Now().UnixMilli(),
+		OrderQty:       2 * Precision,
+	})
+	trade := func(id, price int64) mtrade.Event {
+		return mtrade.Event{Type: mtrade.EventTrade, Trade: &mtrade.Trade{ID: id, Price: price * Precision, Qty: Precision, TakerID: liqOrderID, MakerID: 1000 + id}}
+	}
+
+	// 第一笔成交 1 BTC @49000：保证金分一半 5000，亏 1000，盈余 4000 归保险基金
+	executor.handleEvent(trade(1, 49000))
+	val, ok := executor.pendingTasks.Load(int64(liqOrderID))
+	require.True(t, ok, "partially filled task must stay pending")
+	pending := val.(*PendingLiquidation)
+	assert.Equal(t, int64(Precision), pending.FilledQty)
+	assert.Equal(t, int64(4000*Precision), insurance.added)
+	got := h.position(1, symbol)
+	assert.Equal(t, int64(Precision), got.Size)
+	assert.Equal(t, int64(5000*Precision), got.Margin)
+	assert.Equal(t, int64(50000*Precision), got.EntryPrice)
+	require.Len(t, fills, 1)
+	assert.Equal(t, int64(Precision), fills[0].RemainingQty)
+
+	// 第二笔成交 1 BTC @44000：亏 6000，剩余保证金 5000，穿仓 1000 由保险基金兜底
+	executor.handleEvent(trade(2, 44000))
+	_, ok = executor.pendingTasks.Load(int64(liqOrderID))
+	assert.False(t, ok, "fully filled task should complete")
+	assert.Equal(t, int64(1000*Precision), insurance.covered)
+	got = h.position(1, symbol)
+	assert.Zero(t, got.Size)
+	assert.Zero(t, got.Margin)
+	assert.Equal(t, int64(-7000*Precision), got.RealizedPnL)
+	require.Len(t, fills, 2)
+	assert.Zero(t, fills[1].RemainingQty)
+	assert.Zero(t, fills[1].Uncovered)
+
+	// 迟到的成交不再结算
+	executor.handleLiquidationFill(trade(3, 40000).Trade, liqOrderID, pending, true)
+	assert.Len(t, fills, 2)
+}
+
+func TestHarness_LiquidationWatchdogEscalates(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, nil, nil, nil)
+	executor.insuranceFund = &memInsurance{}
+	var escalated []LiquidationEscalation
+	executor.OnEscalated(func(e LiquidationEscalation) { escalated = append(escalated, e) })
+
+	// 强平单挂在簿上没人接
+	liq := &mtrade.Order{ID: 888, UserID: 1, Symbol: symbol, Side: mtrade.SideSell, Type: mtrade.OrderTypeLimit, Price: 60000 * Precision, Qty: 2 * Precision}
+	require.True(t, h.engines[symbol].SubmitOrder(liq))
+	h.waitFor(symbol, mtrade.EventOrderAccepted, 1)
+
+	pos := Position{UserID: 1, Symbol: symbol, Size: 2 * Precision, EntryPrice: 50000 * Precision, Margin: 10000 * Precision}
+	executor.pendingTasks.Store(liq.ID, &PendingLiquidation{
+		Task:        liquidation.LiquidationTask{UserID: 1, Symbol: symbol},
+		Position:    pos,
+		Spec:        harnessLinearSpec(),
+		SubmittedAt: time.Now().Add(-time.Minute).UnixMilli(),
+		OrderQty:    liq.Qty,
+	})
+	executor.pendingTasks.Store(int64(889), &PendingLiquidation{Position: pos, SubmittedAt: time.Now().UnixMilli()})
+
+	out := executor.EscalateStale(30 * time.Second)
+	require.Len(t, out, 1)
+	assert.Equal(t, liq.ID, out[0].OrderID)
+	assert.Equal(t, int64(2*Precision), out[0].RemainingQty)
+	assert.Equal(t, out, escalated)
+
+	_, ok := executor.pendingTasks.Load(liq.ID)
+	assert.False(t, ok)
+	_, ok = executor.pendingTasks.Load(int64(889))
+	assert.True(t, ok, "fresh task stays pending")
+	h.waitFor(symbol, mtrade.EventOrderCanceled, 1)
+}
+
+func TestHarness_LiquidationFee(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	insurance := &memInsurance{}
+	executor := NewLiquidationExecutor(h.contracts, h.engines[symbol], h.positions, nil, nil, nil, nil)
+	executor.insuranceFund = insurance
+	var fills []LiquidationFill
+	executor.OnLiquidated(func(f LiquidationFill) { fills = append(fills, f) })
+
+	spec := harnessLinearSpec()
+	spec.LiquidationFeeRate = 50 // 0.5%
+	liquidate := func(userID, orderID, price int64) {
+		pos := Position{UserID: userID, Symbol: symbol, Size: Precision, EntryPrice: 50000 * Precision, Margin: 5000 * Precision}
+		executor.pendingTasks.Store(orderID, &PendingLiquidation{Position: pos, SettleCurrency: "USDT", Spec: spec, OrderQty: Precision})
+		executor.handleEvent(mtrade.Event{Type: mtrade.EventTrade, Trade: &mtrade.Trade{ID: orderID, Price: price * Precision, Qty: Precision, TakerID: orderID}})
+	}
+
+	// @48000：剩余 3000，手续费 48000 × 0.5% = 240，盈余 2760
+	liquidate(1, 101, 48000)
+	require.Len(t, fills, 1)
+	assert.Equal(t, int64(240*Precision), fills[0].Fee)
+	assert.Equal(t, int64(2760*Precision), fills[0].Surplus)
+	assert.Equal(t, int64(3000*Precision), insurance.added)
+	pos := h.position(1, symbol)
+	assert.Equal(t, int64(240*Precision), pos.CycleLiqFee)
+	assert.Equal(t, int64(240*Precision), pos.CycleFee)
+
+	// @45100：剩余 100 不够 225.5 的手续费，收到剩余为止
+	liquidate(2, 102, 45100)
+	require.Len(t, fills, 2)
+	assert.Equal(t, int64(100*Precision), fills[1].Fee)
+	assert.Zero(t, fills[1].Surplus)
+
+	// @44000：穿仓，不收手续费
+	liquidate(3, 103, 44000)
+	require.Len(t, fills, 3)
+	assert.Zero(t, fills[2].Fee)
+	assert.Equal(t, int64(1000*Precision), fills[2].Shortfall)
+}
@@ -11,8 +11,10 @@ package futures
 
 import (
 	"context"
-	"errors"
+	"sync"
 	"time"
+
+	"max.com/pkg/cexerr"
 )
 
 // =============================================================================
@@ -20,11 +22,11 @@ import (
 // =============================================================================
 
 var (
-	ErrSymbolExists      = errors.New("contract symbol already exists")
-	ErrSymbolNotFound    = errors.New("contract symbol not found")
-	ErrInvalidSpec       = errors.New("invalid contract specification")
-	ErrContractNotActive = errors.New("contract is not active for trading")
-	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrSymbolExists      = cexerr.New("FUTURES_SYMBOL_EXISTS", cexerr.CategoryConflict, "contract symbol already exists")
+	ErrSymbolNotFound    = cexerr.New("FUTURES_SYMBOL_NOT_FOUND", cexerr.CategoryNotFound, "contract symbol not found")
+	ErrInvalidSpec       = cexerr.New("FUTURES_INVALID_SPEC", cexerr.CategoryInvalidArgument, "invalid contract specification")
+	ErrContractNotActive = cexerr.New("FUTURES_CONTRACT_NOT_ACTIVE", cexerr.CategoryFailedPrecondition, "contract is not active for trading")
+	ErrInvalidTransition = cexerr.New("FUTURES_INVALID_STATUS_TRANSITION", cexerr.CategoryFailedPrecondition, "invalid status transition")
 )
 
 // =============================================================================
@@ -39,6 +41,12 @@ var (
 // - 单元测试时可以传入 MockRepository
 type ContractManager struct {
 	repo ContractRepository
+
+	// 定时参数变更 (可选)，见 param_schedule.go
+	paramStore    ParamChangeStore
+	paramNotice   time.Duration
+	paramMu       sync.Mutex
+	onParamChange []func(ParamChangeEvent)
 }
 
 // NewContractManager 创建合约管理器
@@ -58,18 +66,25 @@ type CreateContractRequest struct {
 	SettleCurrency string
 
 	ContractType   ContractType
+	Inverse        bool // 反向合约 (币本位)，ContractSize 为每张面值 (报价币)
 	ContractSize   int64
 	TickSize       int64
 	MinOrderQty    int64
 	MaxOrderQty    int64
 	MaxPositionQty int64
 
+	MaxOrderLifetime int64 // 挂单最长存活 (秒)，0 使用默认值
+
 	MaxLeverage       int
 	InitialMarginRate int64 // 万分比
 	MaintMarginRate   int64 // 万分比
 
+	LiquidationFeeRate int64 // 万分比，0 不收强平手续费
+
 	FundingInterval int64    // 秒
 	MaxFundingRate  int64    // 万分比
+	MinFundingRate  int64    // 万分比，0 与上限对称
+	InterestRate    int64    // 万分比 (每期)，0 不计利率项
 	PriceSources    []string // 价格来源
 
 	ExpiryAt int64 // 到期时间 (交割合约)
@@ -85,26 +100,31 @@ func (m *ContractManager) CreateContract(ctx context.Context, req *CreateContrac
 	// 2. 构建 Spec
 	now := time.Now().UnixMilli()
 	spec := &ContractSpec{
-		Symbol:            req.Symbol,
-		BaseCurrency:      req.BaseCurrency,
-		QuoteCurrency:     req.QuoteCurrency,
-		SettleCurrency:    req.SettleCurrency,
-		ContractType:      req.ContractType,
-		ContractSize:      req.ContractSize,
-		TickSize:          req.TickSize,
-		MinOrderQty:       req.MinOrderQty,
-		MaxOrderQty:       req.MaxOrderQty,
-		MaxPositionQty:    req.MaxPositionQty,
-		MaxLeverage:       req.MaxLeverage,
-		InitialMarginRate: req.InitialMarginRate,
-		MaintMarginRate:   req.MaintMarginRate,
-		FundingInterval:   req.FundingInterval,
-		MaxFundingRate:    req.MaxFundingRate,
-		PriceSources:      req.PriceSources,
-		Status:            StatusPending,
-		ExpiryAt:          req.ExpiryAt,
-		CreatedAt:         now,
-		UpdatedAt:         now,
+		Symbol:             req.Symbol,
+		BaseCurrency:       req.BaseCurrency,
+		QuoteCurrency:      req.QuoteCurrency,
+		SettleCurrency:     req.SettleCurrency,
+		ContractType:       req.ContractType,
+		Inverse:            req.Inverse,
+		ContractSize:       req.ContractSize,
+		TickSize:           req.TickSize,
+		MinOrderQty:        req.MinOrderQty,
+		MaxOrderQty:        req.MaxOrderQty,
+		MaxPositionQty:     req.MaxPositionQty,
+		MaxOrderLifetime:   req.MaxOrderLifetime,
+		MaxLeverage:        req.MaxLeverage,
+		InitialMarginRate:  req.InitialMarginRate,
+		MaintMarginRate:    req.MaintMarginRate,
+		LiquidationFeeRate: req.LiquidationFeeRate,
+		FundingInterval:    req.FundingInterval,
+		MaxFundingRate:     req.MaxFundingRate,
+		MinFundingRate:     req.MinFundingRate,
+		InterestRate:       req.InterestRate,
+		PriceSources:       req.PriceSources,
+		Status:             StatusPending,
+		ExpiryAt:           req.ExpiryAt,
+		CreatedAt:          now,
+		UpdatedAt:          now,
 	}
 
 	// 3. 保存
@@ -170,7 +190,7 @@ func (m *ContractManager) UpdateLeverage(ctx context.Context, symbol string, max
 	}
 
 	if maxLeverage <= 0 || maxLeverage > 200 {
-		return errors.New("invalid leverage: must be between 1 and 200")
+		return ErrInvalidLeverage.Wrapf("must be between 1 and 200")
 	}
 
 	spec.MaxLeverage = maxLeverage
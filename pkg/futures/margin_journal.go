@@ -0,0 +1,67 @@
+// 文件: pkg/futures/margin_journal.go
+// 保证金冷钱包流水
+//
+// 开仓冻结、撤单/剩余退回、平仓结算都走带流水的余额操作 (fund.FreezeWithJournal 等)，
+// 每笔冷钱包变动都能按订单/成交追溯，流水里带变动前后金额。
+//
+// 幂等键按业务动作生成，同一动作重试不会重复改余额：
+//
+//	fmargin_freeze_{orderID}                  开仓冻结 (一笔订单一次)
+//	fmargin_release_{orderID}                 退回未用完的冻结 (下单失败回滚 / 订单结束，一笔订单一次)
+//	fmargin_settle_{orderID}_{tradeID}        平仓结算 (释放保证金 + 已实现盈亏，每笔成交一次)
+//	fmargin_auto_{userID}_{symbol}_{at}       自动追加保证金 (每次风险等级变化每个持仓一次，见 auto_margin.go)
+//	fmargin_auto_undo_{userID}_{symbol}_{at}  自动追加写持仓失败，退回冻结
+
+package futures
+
+import (
+	"fmt"
+	"strconv"
+
+	"max.com/pkg/fund"
+)
+
+func marginFreezeRef(orderID int64) fund.JournalRef {
+	return fund.JournalRef{
+		EventID:    fmt.Sprintf("fmargin_freeze_%d", orderID),
+		ChangeType: fund.ChangeTypeReserve,
+		BizType:    fund.BizTypeOrder,
+		BizID:      strconv.FormatInt(orderID, 10),
+	}
+}
+
+func marginReleaseRef(orderID int64) fund.JournalRef {
+	return fund.JournalRef{
+		EventID:    fmt.Sprintf("fmargin_release_%d", orderID),
+		ChangeType: fund.ChangeTypeRelease,
+		BizType:    fund.BizTypeOrder,
+		BizID:      strconv.FormatInt(orderID, 10),
+	}
+}
+
+func marginSettleRef(orderID, tradeID int64) fund.JournalRef {
+	return fund.JournalRef{
+		EventID:    fmt.Sprintf("fmargin_settle_%d_%d", orderID, tradeID),
+		ChangeType: fund.ChangeTypeTransfer,
+		BizType:    fund.BizTypeTrade,
+		BizID:      strconv.FormatInt(tradeID, 10),
+	}
+}
+
+func marginAutoAddRef(userID int64, symbol string, at int64) fund.JournalRef {
+	return fund.JournalRef{
+		EventID:    fmt.Sprintf("fmargin_auto_%d_%s_%d", userID, symbol, at),
+		ChangeType: fund.ChangeTypeReserve,
+		BizType:    fund.BizTypeAutoMargin,
+		BizID:      fmt.Sprintf("%s_%d", symbol, at),
+	}
+}
+
+func marginAutoUndoRef(userID int64, symbol string, at int64) fund.JournalRef {
+	return fund.JournalRef{
+		EventID:    fmt.Sprintf("fmargin_auto_undo_%d_%s_%d", userID, symbol, at),
+		ChangeType: fund.ChangeTypeRelease,
+		BizType:    fund.BizTypeAutoMargin,
+		BizID:      fmt.Sprintf("%s_%d", symbol, at),
+	}
+}
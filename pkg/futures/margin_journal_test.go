@@ -0,0 +1,54 @@
+// 文件: pkg/futures/margin_journal_test.go
+// 保证金流水测试 (内存夹具，见 harness_test.go)
+
+package futures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/mtrade"
+)
+
+func TestHarness_MarginJournalTrail(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	const orderID = 4242
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+
+	// 1 BTC @ 50000, 10x → 冻结 5000
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		OrderID: orderID, UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Price: 50000 * Precision, Leverage: 10,
+	}))
+	freeze := h.ledger.journal(marginFreezeRef(orderID).EventID)
+	require.NotNil(t, freeze)
+	assert.Equal(t, fund.ChangeTypeReserve, freeze.ChangeType)
+	assert.Equal(t, int64(10000*Precision), freeze.AvailableBefore)
+	assert.Equal(t, int64(5000*Precision), freeze.AvailableAfter)
+	assert.Equal(t, int64(5000*Precision), freeze.LockedAfter)
+
+	// 同一幂等键重试不重复冻结
+	_, dup, err := h.ledger.FreezeWithJournal(h.ctx, 1, "USDT", 5000*Precision, marginFreezeRef(orderID))
+	require.NoError(t, err)
+	assert.True(t, dup)
+	avail, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(5000*Precision), avail)
+	assert.Equal(t, int64(5000*Precision), locked)
+
+	// 撤单退回全部冻结，记一条解冻流水 (先等订单进簿)
+	h.waitFor(symbol, mtrade.EventOrderAccepted, 1)
+	require.True(t, proc.CancelOrder(orderID))
+	h.waitFor(symbol, mtrade.EventOrderCanceled, 1)
+	release := h.ledger.journal(marginReleaseRef(orderID).EventID)
+	require.NotNil(t, release)
+	assert.Equal(t, fund.ChangeTypeRelease, release.ChangeType)
+	assert.Equal(t, int64(5000*Precision), release.LockedBefore)
+	assert.Zero(t, release.LockedAfter)
+	avail, locked = h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(10000*Precision), avail)
+	assert.Zero(t, locked)
+}
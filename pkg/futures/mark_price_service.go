@@ -22,13 +22,13 @@ import (
 //
 // 【标记价格来源】
 // 实际生产中，标记价格来自多交易所现货指数 + 资金费率修正
-// 这里简化为直接接收外部推送
+// 这里简化为直接接收外部推送；指数价格由 indexprice.Service 按合约 PriceSources 聚合后推送 (见 index_price.go)
 type MarkPriceService struct {
 	mu     sync.RWMutex
 	prices map[string]*MarkPriceInfo
 
-	// 价格更新回调
-	onPriceUpdate func(symbol string, price *MarkPriceInfo)
+	// 价格更新回调 (强平引擎、条件单服务等，按注册顺序调用)
+	onPriceUpdate []func(symbol string, price *MarkPriceInfo)
 }
 
 // MarkPriceInfo 标记价格信息
@@ -118,14 +118,29 @@ func (s *MarkPriceService) UpdateMarkPrice(symbol string, markPrice int64) {
 	}
 	info.MarkPrice = markPrice
 	info.UpdatedAt = time.Now().UnixMilli()
+	callbacks := s.onPriceUpdate
 	s.mu.Unlock()
 
 	// 触发回调
-	if s.onPriceUpdate != nil {
-		s.onPriceUpdate(symbol, info)
+	for _, cb := range callbacks {
+		cb(symbol, info)
 	}
 }
 
+// UpdateIndexPrice 更新指数价格 (indexprice.Service 发布)
+//
+// 只改指数不改标记价格，不触发价格回调 (回调关心的是标记价格变化)
+func (s *MarkPriceService) UpdateIndexPrice(symbol string, indexPrice int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.prices[symbol]
+	if !ok {
+		info = &MarkPriceInfo{Symbol: symbol}
+		s.prices[symbol] = info
+	}
+	info.IndexPrice = indexPrice // UpdatedAt 表示标记价格的新鲜度，这里不动
+}
+
 // UpdatePriceInfo 更新完整价格信息
 func (s *MarkPriceService) UpdatePriceInfo(info *MarkPriceInfo) {
 	if info == nil {
@@ -135,18 +150,21 @@ func (s *MarkPriceService) UpdatePriceInfo(info *MarkPriceInfo) {
 	s.mu.Lock()
 	info.UpdatedAt = time.Now().UnixMilli()
 	s.prices[info.Symbol] = info
+	callbacks := s.onPriceUpdate
 	s.mu.Unlock()
 
 	// 触发回调
-	if s.onPriceUpdate != nil {
-		s.onPriceUpdate(info.Symbol, info)
+	for _, cb := range callbacks {
+		cb(info.Symbol, info)
 	}
 }
 
-// OnPriceUpdate 设置价格更新回调
-// 用于通知强平引擎检查风险
+// OnPriceUpdate 注册价格更新回调 (可注册多个)
+// 用于通知强平引擎检查风险、条件单服务检查触发
 func (s *MarkPriceService) OnPriceUpdate(callback func(symbol string, price *MarkPriceInfo)) {
-	s.onPriceUpdate = callback
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPriceUpdate = append(s.onPriceUpdate, callback)
 }
 
 // GetAllPrices 获取所有价格
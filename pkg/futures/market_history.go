@@ -0,0 +1,339 @@
+// 文件: pkg/futures/market_history.go
+// 资金费率 / 标记价格历史
+//
+// 【为什么需要】
+// GetFundingInfo 只有当前费率，MarkPriceService 只有最新价格：
+// - K 线页面要画资金费率曲线、标记价格线
+// - 用户对账 "那一期为什么扣了这么多资金费" 要查当期费率和结算标记价
+// - 强平申诉要回看强平时刻前后的标记价格 / 指数价格
+//
+// 【写入】
+// - 资金费率: 每期结算完成后一条 (FundingService.SetMarketHistory)，dry-run 不写
+// - 标记/指数价格: PriceHistoryRecorder 每分钟对 MarkPriceService 采样一次
+// 两者都以 (symbol, 时间点) 唯一，重跑/重启重复写入直接忽略
+//
+// 【注意】写失败只打日志：历史是展示/审计用的派生数据，不能反过来影响结算
+
+package futures
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+)
+
+// =============================================================================
+// 数据结构
+// =============================================================================
+
+// PriceHistory 一分钟的标记价格 / 指数价格采样
+type PriceHistory struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement" json:"-"`
+	Symbol     string `gorm:"column:symbol;type:varchar(32);uniqueIndex:uk_symbol_minute,priority:1" json:"symbol"`
+	Minute     int64  `gorm:"column:minute;uniqueIndex:uk_symbol_minute,priority:2" json:"minute"` // 整分钟 (毫秒)
+	MarkPrice  int64  `gorm:"column:mark_price" json:"mark_price"`
+	IndexPrice int64  `gorm:"column:index_price" json:"index_price"` // 0 表示当时没有指数
+}
+
+func (PriceHistory) TableName() string {
+	return "price_history"
+}
+
+// =============================================================================
+// 存储
+// =============================================================================
+
+const (
+	defaultMarketHistoryLimit = 100
+	maxMarketHistoryLimit     = 1000
+)
+
+// MarketHistoryQuery 历史查询条件 (时间都是毫秒)
+//
+// StartTime > 0: 从 StartTime 起按时间升序取 Limit 条
+// StartTime = 0: 取 EndTime 之前最近的 Limit 条 (仍按时间升序返回)
+type MarketHistoryQuery struct {
+	Symbol    string
+	StartTime int64 // 包含；0 表示不限
+	EndTime   int64 // 不包含；0 表示不限
+	Limit     int   // <=0 使用默认值 100，最大 1000
+}
+
+func (q MarketHistoryQuery) limit() int {
+	if q.Limit <= 0 {
+		return defaultMarketHistoryLimit
+	}
+	return min(q.Limit, maxMarketHistoryLimit)
+}
+
+// MarketHistoryRepository 资金费率 / 价格历史存储
+type MarketHistoryRepository interface {
+	// SaveFundingRate 写入一期资金费率，(symbol, funding_time) 已存在时忽略
+	SaveFundingRate(ctx context.Context, h *FundingRateHistory) error
+
+	// ListFundingRates 按结算时间查询
+	ListFundingRates(ctx context.Context, q MarketHistoryQuery) ([]*FundingRateHistory, error)
+
+	// SavePrices 批量写入价格采样，(symbol, minute) 已存在时忽略
+	SavePrices(ctx context.Context, list []*PriceHistory) error
+
+	// ListPrices 按采样时间查询
+	ListPrices(ctx context.Context, q MarketHistoryQuery) ([]*PriceHistory, error)
+}
+
+// 确保实现了接口
+var _ MarketHistoryRepository = (*MySQLMarketHistoryRepository)(nil)
+
+// MySQLMarketHistoryRepository MySQL 实现
+type MySQLMarketHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewMySQLMarketHistoryRepository 创建历史存储
+func NewMySQLMarketHistoryRepository(db *gorm.DB) *MySQLMarketHistoryRepository {
+	return &MySQLMarketHistoryRepository{db: db}
+}
+
+// SaveFundingRate 写入资金费率
+func (r *MySQLMarketHistoryRepository) SaveFundingRate(ctx context.Context, h *FundingRateHistory) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(h).Error
+}
+
+// ListFundingRates 查询资金费率
+func (r *MySQLMarketHistoryRepository) ListFundingRates(ctx context.Context, q MarketHistoryQuery) ([]*FundingRateHistory, error) {
+	var list []*FundingRateHistory
+	err := historyRange(r.db.WithContext(ctx), "funding_time", q).Find(&list).Error
+	if q.StartTime <= 0 {
+		slices.Reverse(list)
+	}
+	return list, err
+}
+
+// SavePrices 批量写入价格采样
+func (r *MySQLMarketHistoryRepository) SavePrices(ctx context.Context, list []*PriceHistory) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&list).Error
+}
+
+// ListPrices 查询价格采样
+func (r *MySQLMarketHistoryRepository) ListPrices(ctx context.Context, q MarketHistoryQuery) ([]*PriceHistory, error) {
+	var list []*PriceHistory
+	err := historyRange(r.db.WithContext(ctx), "minute", q).Find(&list).Error
+	if q.StartTime <= 0 {
+		slices.Reverse(list)
+	}
+	return list, err
+}
+
+// historyRange 拼时间范围条件
+// 没有起始时间时倒序取最近 N 条，由调用方翻转回升序；两种情况都走 (symbol, 时间) 唯一索引
+func historyRange(tx *gorm.DB, column string, q MarketHistoryQuery) *gorm.DB {
+	tx = tx.Where("symbol = ?", q.Symbol)
+	if q.StartTime > 0 {
+		tx = tx.Where(column+" >= ?", q.StartTime)
+	}
+	if q.EndTime > 0 {
+		tx = tx.Where(column+" < ?", q.EndTime)
+	}
+	order := column + " ASC"
+	if q.StartTime <= 0 {
+		order = column + " DESC"
+	}
+	return tx.Order(order).Limit(q.limit())
+}
+
+// =============================================================================
+// 写入
+// =============================================================================
+
+// SetMarketHistory 设置历史存储，每期结算完成后记录资金费率
+func (s *FundingService) SetMarketHistory(repo MarketHistoryRepository) {
+	s.history = repo
+}
+
+// recordFundingRate 记录一期资金费率，history 为 nil 时跳过
+func (s *FundingService) recordFundingRate(ctx context.Context, report *FundingReport) {
+	if s.history == nil {
+		return
+	}
+	err := s.history.SaveFundingRate(ctx, &FundingRateHistory{
+		Symbol:      report.Symbol,
+		FundingTime: report.FundingTime,
+		FundingRate: report.FundingRate,
+		MarkPrice:   report.MarkPrice,
+		IndexPrice:  s.markPriceService.GetIndexPrice(report.Symbol),
+		CreatedAt:   time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("[Funding] WARNING: record funding rate history failed: symbol=%s time=%d: %v",
+			report.Symbol, report.FundingTime, err)
+	}
+}
+
+// PriceHistoryRecorder 每分钟采样标记价格 / 指数价格
+//
+// 【注意】采样的是 MarkPriceService 里的最新值，不是一分钟内的均价；
+// 标记价格本身已经平滑过，图表和审计按分钟采样足够
+type PriceHistoryRecorder struct {
+	markPrices *MarkPriceService
+	repo       MarketHistoryRepository
+	now        func() time.Time
+
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPriceHistoryRecorder 创建价格采样器
+func NewPriceHistoryRecorder(markPrices *MarkPriceService, repo MarketHistoryRepository) *PriceHistoryRecorder {
+	return &PriceHistoryRecorder{
+		markPrices: markPrices,
+		repo:       repo,
+		now:        time.Now,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// SetClock 替换采样时钟，采样时间点取它的整分钟，须在 Start 之前调用
+func (r *PriceHistoryRecorder) SetClock(now func() time.Time) {
+	r.now = now
+}
+
+// Start 启动采样 (对齐到整分钟)
+func (r *PriceHistoryRecorder) Start() error {
+	if r.running {
+		return errors.New("price history recorder already running")
+	}
+	r.running = true
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			now := r.now()
+			wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+			select {
+			case <-r.stopCh:
+				return
+			case <-time.After(wait):
+				if err := r.RecordOnce(context.Background()); err != nil {
+					log.Printf("[Futures] WARNING: record price history failed: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止采样
+func (r *PriceHistoryRecorder) Stop() {
+	if !r.running {
+		return
+	}
+	close(r.stopCh)
+	r.wg.Wait()
+	r.running = false
+}
+
+// RecordOnce 采样一次，时间点取当前整分钟
+func (r *PriceHistoryRecorder) RecordOnce(ctx context.Context) error {
+	minute := r.now().Truncate(time.Minute).UnixMilli()
+	prices := r.markPrices.GetAllPrices()
+	list := make([]*PriceHistory, 0, len(prices))
+	for symbol, info := range prices {
+		if info.MarkPrice <= 0 {
+			continue // 只有指数、还没有标记价格
+		}
+		list = append(list, &PriceHistory{
+			Symbol:     symbol,
+			Minute:     minute,
+			MarkPrice:  info.MarkPrice,
+			IndexPrice: info.IndexPrice,
+		})
+	}
+	return r.repo.SavePrices(ctx, list)
+}
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// NewFundingRateHistoryHandler 资金费率历史接口 (公开)
+//
+//	GET /futures/funding-rate/history?symbol=BTCUSDT&start_time=&end_time=&limit=100
+func NewFundingRateHistoryHandler(repo MarketHistoryRepository) http.Handler {
+	return historyHandler(func(ctx context.Context, q MarketHistoryQuery) (any, error) {
+		list, err := repo.ListFundingRates(ctx, q)
+		if list == nil {
+			list = []*FundingRateHistory{}
+		}
+		return list, err
+	})
+}
+
+// NewPriceHistoryHandler 标记价格 / 指数价格历史接口 (公开，分钟粒度)
+//
+//	GET /futures/mark-price/history?symbol=BTCUSDT&start_time=&end_time=&limit=100
+func NewPriceHistoryHandler(repo MarketHistoryRepository) http.Handler {
+	return historyHandler(func(ctx context.Context, q MarketHistoryQuery) (any, error) {
+		list, err := repo.ListPrices(ctx, q)
+		if list == nil {
+			list = []*PriceHistory{}
+		}
+		return list, err
+	})
+}
+
+// historyHandler 解析公共查询参数 (symbol 必填)，结果按时间升序以 JSON 数组返回
+func historyHandler(list func(ctx context.Context, q MarketHistoryQuery) (any, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		v := r.URL.Query()
+		query := MarketHistoryQuery{Symbol: v.Get("symbol")}
+		if query.Symbol == "" {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("symbol"))
+			return
+		}
+		var err error
+		for name, dst := range map[string]*int64{"start_time": &query.StartTime, "end_time": &query.EndTime} {
+			if s := v.Get(name); s != "" {
+				if *dst, err = strconv.ParseInt(s, 10, 64); err != nil {
+					cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("%s", name))
+					return
+				}
+			}
+		}
+		if s := v.Get("limit"); s != "" {
+			if query.Limit, err = strconv.Atoi(s); err != nil {
+				cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("limit"))
+				return
+			}
+		}
+
+		items, err := list(r.Context(), query)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	})
+}
@@ -0,0 +1,144 @@
+// 文件: pkg/futures/market_history_test.go
+// 资金费率 / 价格历史测试 (内存仓储 + gorm DryRun，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type memMarketHistoryRepo struct {
+	mu     sync.Mutex
+	rates  []*FundingRateHistory
+	prices []*PriceHistory
+}
+
+func (r *memMarketHistoryRepo) SaveFundingRate(ctx context.Context, h *FundingRateHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rates = append(r.rates, h)
+	return nil
+}
+
+func (r *memMarketHistoryRepo) ListFundingRates(ctx context.Context, q MarketHistoryQuery) ([]*FundingRateHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*FundingRateHistory
+	for _, h := range r.rates {
+		if h.Symbol == q.Symbol && h.FundingTime >= q.StartTime && (q.EndTime == 0 || h.FundingTime < q.EndTime) {
+			out = append(out, h)
+		}
+	}
+	return out[:min(len(out), q.limit())], nil
+}
+
+func (r *memMarketHistoryRepo) SavePrices(ctx context.Context, list []*PriceHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prices = append(r.prices, list...)
+	return nil
+}
+
+func (r *memMarketHistoryRepo) ListPrices(ctx context.Context, q MarketHistoryQuery) ([]*PriceHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*PriceHistory
+	for _, h := range r.prices {
+		if h.Symbol == q.Symbol && h.Minute >= q.StartTime && (q.EndTime == 0 || h.Minute < q.EndTime) {
+			out = append(out, h)
+		}
+	}
+	return out[:min(len(out), q.limit())], nil
+}
+
+func TestPriceHistoryRecorder_RecordOnce(t *testing.T) {
+	marks := NewMarkPriceService()
+	marks.UpdateMarkPrice("BTCUSDT", 50000*Precision)
+	marks.UpdateIndexPrice("BTCUSDT", 49990*Precision)
+	marks.UpdateIndexPrice("ETHUSDT", 3000*Precision) // 只有指数，不采样
+
+	repo := &memMarketHistoryRepo{}
+	rec := NewPriceHistoryRecorder(marks, repo)
+	now := time.Date(2026, 1, 2, 3, 4, 35, 0, time.UTC)
+	rec.SetClock(func() time.Time { return now })
+
+	require.NoError(t, rec.RecordOnce(context.Background()))
+	require.Len(t, repo.prices, 1)
+	p := repo.prices[0]
+	assert.Equal(t, "BTCUSDT", p.Symbol)
+	assert.Equal(t, now.Truncate(time.Minute).UnixMilli(), p.Minute)
+	assert.Equal(t, int64(50000*Precision), p.MarkPrice)
+	assert.Equal(t, int64(49990*Precision), p.IndexPrice)
+}
+
+func TestMarketHistoryHandlers(t *testing.T) {
+	repo := &memMarketHistoryRepo{}
+	for i := int64(0); i < 3; i++ {
+		repo.SaveFundingRate(context.Background(), &FundingRateHistory{
+			Symbol: "BTCUSDT", FundingTime: i * int64(FundingInterval/time.Millisecond), FundingRate: i + 1,
+		})
+	}
+	repo.SavePrices(context.Background(), []*PriceHistory{
+		{Symbol: "BTCUSDT", Minute: 60_000, MarkPrice: 1},
+		{Symbol: "ETHUSDT", Minute: 60_000, MarkPrice: 2},
+	})
+
+	get := func(h http.Handler, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get(NewFundingRateHistoryHandler(repo), "/futures/funding-rate/history?symbol=BTCUSDT&start_time=1&limit=5")
+	require.Equal(t, http.StatusOK, w.Code)
+	var rates []FundingRateHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rates))
+	require.Len(t, rates, 2)
+	assert.True(t, sort.SliceIsSorted(rates, func(i, j int) bool { return rates[i].FundingTime < rates[j].FundingTime }))
+	assert.Equal(t, int64(2), rates[0].FundingRate)
+
+	w = get(NewPriceHistoryHandler(repo), "/futures/mark-price/history?symbol=ETHUSDT")
+	require.Equal(t, http.StatusOK, w.Code)
+	var prices []PriceHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prices))
+	require.Len(t, prices, 1)
+	assert.Equal(t, int64(2), prices[0].MarkPrice)
+
+	// 空结果返回 [] 而不是 null
+	w = get(NewPriceHistoryHandler(repo), "/futures/mark-price/history?symbol=SOLUSDT")
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get(NewPriceHistoryHandler(repo), "/futures/mark-price/history").Code)
+	assert.Equal(t, http.StatusBadRequest, get(NewFundingRateHistoryHandler(repo), "/x?symbol=BTCUSDT&end_time=abc").Code)
+}
+
+func TestMySQLMarketHistoryRepository_Range(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "u:p@tcp(127.0.0.1:1)/x", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+
+	sql := func(q MarketHistoryQuery) string {
+		var list []*PriceHistory
+		tx := historyRange(db, "minute", q).Find(&list)
+		return tx.Statement.SQL.String()
+	}
+
+	// 有起始时间：升序向后取
+	assert.Equal(t, "SELECT * FROM `price_history` WHERE symbol = ? AND minute >= ? AND minute < ? ORDER BY minute ASC LIMIT ?",
+		sql(MarketHistoryQuery{Symbol: "BTCUSDT", StartTime: 1, EndTime: 2}))
+	// 没有起始时间：倒序取最近 N 条 (仓储再翻转为升序)
+	assert.Equal(t, "SELECT * FROM `price_history` WHERE symbol = ? ORDER BY minute DESC LIMIT ?",
+		sql(MarketHistoryQuery{Symbol: "BTCUSDT", Limit: 5000}))
+}
@@ -0,0 +1,186 @@
+// 文件: pkg/futures/market_order.go
+// 市价单与价格保护
+//
+// 【问题】纯市价单在薄盘口会一路扫穿到离谱的价格 (插针)，成交价不可控，保证金也没法预先冻结
+//
+// 【做法】市价单 = 以保护价为限价的 IOC
+//   - 保护价 = 标记价格 × (1 ± 最大滑点)，买单向上、卖单向下，对齐 TickSize
+//   - 撮合按保护价截断：超出保护价的档位不吃，剩余部分直接撤销
+//   - 保证金按最坏成交价冻结 (见 marketMarginPrice)，成交后按实际成交价转入持仓，多冻的部分订单结束时退回
+//
+// 【面试】为什么按标记价格而不是盘口最优价算保护价？
+// 盘口可以被操纵 (先撤光对手盘再下市价单)，标记价格来自多个现货指数，更难被单一市场带偏
+
+package futures
+
+import (
+	"context"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+// OrderType 下单类型
+type OrderType int8
+
+const (
+	OrderTypeLimit  OrderType = iota // 限价单 (默认)
+	OrderTypeMarket                  // 市价单：带保护价的 IOC
+)
+
+func (t OrderType) String() string {
+	if t == OrderTypeMarket {
+		return "MARKET"
+	}
+	return "LIMIT"
+}
+
+// DefaultMaxSlippage 市价单默认最大滑点 (万分比)：偏离标记价格 1%
+const DefaultMaxSlippage = 100
+
+// ErrInvalidSlippage 最大滑点不在 (0, RatePrecision) 区间
+var ErrInvalidSlippage = cexerr.New("FUTURES_INVALID_SLIPPAGE", cexerr.CategoryInvalidArgument, "invalid max slippage")
+
+// SetMaxSlippage 设置市价单默认最大滑点 (万分比)，请求里未指定时使用
+func (p *FuturesProcessor) SetMaxSlippage(rate int64) {
+	if rate > 0 && rate < RatePrecision {
+		p.maxSlippage = rate
+	}
+}
+
+// protectedPrice 市价单保护价
+//
+// 买单: mark × (1 + slippage)，向下对齐 TickSize (不超过上限)
+// 卖单: mark × (1 − slippage)，向上对齐 TickSize (不低于下限)
+func protectedPrice(spec *ContractSpec, side Side, markPrice, slippage int64) int64 {
+	tick := int64(1)
+	if spec != nil && spec.TickSize > 0 {
+		tick = spec.TickSize
+	}
+	if side == SideLong {
+		price := fixed.ApplyBps(markPrice, slippage, fixed.RoundDown)
+		return fixed.Quantize(price, tick, fixed.RoundDown)
+	}
+	price := fixed.ApplyBps(markPrice, -slippage, fixed.RoundDown)
+	return max(fixed.Quantize(price, tick, fixed.RoundUp), tick)
+}
+
+// marketMarginPrice 市价开仓冻结保证金用的价格：标记价格和保护价里仓位价值更大的那个
+//
+// 正向合约价值随价格上升：买单最坏是保护价 (更高)
+// 反向合约价值随价格下降：卖单最坏是保护价 (更低)
+// 另一侧保护价反而让仓位更"便宜"，仍按标记价格冻结；
+// 成交价偏离到标记价格另一侧时按剩余冻结额封顶 (见 OrderMeta.takeMargin)
+func marketMarginPrice(spec *ContractSpec, qty, markPrice, protected int64) int64 {
+	if spec.PositionValue(qty, protected) > spec.PositionValue(qty, markPrice) {
+		return protected
+	}
+	return markPrice
+}
+
+// resolveMarketPrice 市价单的保护价和冻结保证金用的价格
+func (p *FuturesProcessor) resolveMarketPrice(spec *ContractSpec, side Side, qty, slippage int64) (protected, marginPrice int64, err error) {
+	if slippage == 0 {
+		slippage = p.maxSlippage
+	}
+	if slippage <= 0 || slippage >= RatePrecision {
+		return 0, 0, ErrInvalidSlippage
+	}
+	mark := p.markPriceService.GetMarkPrice(spec.Symbol)
+	if mark <= 0 {
+		return 0, 0, ErrNoMarkPrice
+	}
+	protected = protectedPrice(spec, side, mark, slippage)
+	return protected, marketMarginPrice(spec, qty, mark, protected), nil
+}
+
+// recordType 订单记录里的类型
+func (t OrderType) recordType() order.OrderType {
+	if t == OrderTypeMarket {
+		return order.OrderTypeMarket
+	}
+	return order.OrderTypeLimit
+}
+
+// matchOrderType 撮合订单类型：市价单以保护价挂 IOC
+func (t OrderType) matchOrderType() mtrade.OrderType {
+	if t == OrderTypeMarket {
+		return mtrade.OrderTypeIOC
+	}
+	return mtrade.OrderTypeLimit
+}
+
+// =============================================================================
+// 成交进度 (部分成交 / 市价单扫多档)
+// =============================================================================
+
+// takeMargin 记一笔成交，返回这笔成交对应的保证金
+//
+// 开仓: 转入持仓的保证金。市价单按实际成交价计算 (不超过剩余冻结额)，限价单按数量比例
+// 平仓: 释放的持仓保证金，按数量比例
+// 最后一笔拿走余数，避免按比例取整留下零头
+func (m *OrderMeta) takeMargin(spec *ContractSpec, qty, price int64) int64 {
+	remaining := m.Margin - m.MarginUsed
+	var margin int64
+	switch {
+	case m.Type == OrderTypeMarket && !m.IsClose:
+		margin = min(spec.PositionValue(qty, price)/int64(m.Leverage), remaining)
+	case m.FilledQty+qty >= m.Qty:
+		margin = remaining
+	default:
+		margin = min(fixed.MulDiv(m.Margin, qty, m.Qty), remaining)
+	}
+	m.FilledQty += qty
+	m.MarginUsed += margin
+	return margin
+}
+
+// done 订单是否不会再有成交：全部成交，或剩余已撤销 / 被拒且已成交部分都处理完
+func (m *OrderMeta) done() bool {
+	if m.Closed {
+		return m.FilledQty >= m.FinalQty
+	}
+	return m.FilledQty >= m.Qty
+}
+
+// finishOrder 订单结束：开仓单退回未用完的冻结保证金，清理元数据
+//
+// 返回退回的保证金
+func (p *FuturesProcessor) finishOrder(orderID int64, meta *OrderMeta) int64 {
+	p.orderMetas.Delete(orderID)
+	if meta.IsClose {
+		return 0 // 平仓单不冻结保证金
+	}
+	refund := meta.Margin - meta.MarginUsed
+	if refund <= 0 || p.balanceRepo == nil {
+		return 0
+	}
+	spec, _ := p.contractManager.GetContract(context.Background(), meta.Symbol)
+	if spec == nil {
+		return 0
+	}
+	p.balanceRepo.UnfreezeWithJournal(context.Background(), meta.UserID, spec.SettleCurrency, refund, marginReleaseRef(orderID))
+	return refund
+}
+
+// handleOrderStatus 下单结果：IOC 剩余撤销 / 价格带拒单
+//
+// 【注意】撮合先发订单状态事件、再发成交事件，此时成交还没处理，
+// 只能记下最终成交量，等成交处理完 (done) 再退保证金
+func (p *FuturesProcessor) handleOrderStatus(order *mtrade.Order) {
+	if order.Status != mtrade.OrderStatusCanceled && order.Status != mtrade.OrderStatusRejected {
+		return
+	}
+	val, ok := p.orderMetas.Load(order.ID)
+	if !ok {
+		return
+	}
+	meta := val.(*OrderMeta)
+	meta.Closed = true
+	meta.FinalQty = order.FilledQty
+	if meta.done() {
+		p.finishOrder(order.ID, meta)
+	}
+}
@@ -0,0 +1,115 @@
+// 文件: pkg/futures/market_order_test.go
+// 市价单测试 (内存夹具，见 harness_test.go)
+
+package futures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/featureflag"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+func TestProtectedPrice(t *testing.T) {
+	spec := &ContractSpec{TickSize: 10 * Precision}
+
+	// 1% 滑点：买单 50505 → 向下对齐 50500，卖单 49995 → 向上对齐 50000
+	assert.Equal(t, int64(50500*Precision), protectedPrice(spec, SideLong, 50005*Precision, 100))
+	assert.Equal(t, int64(49510*Precision), protectedPrice(spec, SideShort, 50005*Precision, 100))
+
+	// 反向合约卖单保护价更低，仓位价值更大，按保护价冻结
+	inverse := harnessInverseSpec()
+	assert.Equal(t, int64(49500*Precision), marketMarginPrice(inverse, 100*Precision, 50000*Precision, 49500*Precision))
+	assert.Equal(t, int64(50000*Precision), marketMarginPrice(inverse, 100*Precision, 50000*Precision, 50500*Precision))
+}
+
+func TestHarness_MarketOrder(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	for uid := int64(1); uid <= 5; uid++ {
+		h.ledger.AddAvailable(h.ctx, uid, "USDT", 20000*Precision)
+	}
+
+	// 没有标记价格不能下市价单
+	market := &OpenPositionRequest{UserID: 1, Symbol: symbol, Side: SideLong, Qty: 2 * Precision, Leverage: 10, Type: OrderTypeMarket}
+	assert.ErrorIs(t, proc.OpenPosition(h.ctx, market), ErrNoMarkPrice)
+	proc.UpdateMarkPrice(symbol, 50000*Precision)
+
+	bad := *market
+	bad.MaxSlippage = RatePrecision
+	assert.ErrorIs(t, proc.OpenPosition(h.ctx, &bad), ErrInvalidSlippage)
+
+	// 卖盘: 0.5 @ 50000, 0.5 @ 50200, 1 @ 51000 (超出 1% 保护价 50500)
+	for _, ask := range []struct{ uid, qty, price int64 }{
+		{2, Precision / 2, 50000}, {3, Precision / 2, 50200}, {4, Precision, 51000},
+	} {
+		require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+			UserID: ask.uid, Symbol: symbol, Side: SideShort, Qty: ask.qty, Price: ask.price * Precision, Leverage: 10,
+		}))
+	}
+
+	// 市价买 2：按保护价 50500 冻结 2 × 50500 / 10 = 10100
+	require.NoError(t, proc.OpenPosition(h.ctx, market))
+	h.waitFor(symbol, mtrade.EventTrade, 2)
+
+	// 只吃到保护价以内的 1 BTC，保证金按实际成交价 (50000×0.5 + 50200×0.5) / 10 = 5010，多冻的退回
+	pos := h.position(1, symbol)
+	require.NotNil(t, pos)
+	assert.Equal(t, int64(Precision), pos.Size)
+	assert.Equal(t, int64(50100*Precision), pos.EntryPrice)
+	assert.Equal(t, int64(5010*Precision), pos.Margin)
+	avail, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(14990*Precision), avail)
+	assert.Equal(t, int64(5010*Precision), locked)
+
+	// 51000 的卖单没被吃到，还挂着
+	assert.Nil(t, h.position(4, symbol))
+
+	// 市价平仓：卖单保护价 49500，吃掉 49800 的买单
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 5, Symbol: symbol, Side: SideLong, Qty: Precision, Price: 49800 * Precision, Leverage: 10,
+	}))
+	require.NoError(t, proc.ClosePosition(h.ctx, &ClosePositionRequest{UserID: 1, Symbol: symbol}))
+	h.waitFor(symbol, mtrade.EventTrade, 1)
+
+	pos = h.position(1, symbol)
+	assert.True(t, pos.IsEmpty())
+	assert.Equal(t, int64(-300*Precision), pos.RealizedPnL)
+
+	orders, _ := h.orders.GetByUserAndSymbol(h.ctx, 1, symbol, 10)
+	require.Len(t, orders, 2)
+	for _, o := range orders {
+		assert.Equal(t, order.OrderTypeMarket, o.OrderType)
+	}
+}
+
+func TestHarness_MarketOrderFeatureFlag(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs[symbol]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 20000*Precision)
+	proc.UpdateMarkPrice(symbol, 50000*Precision)
+
+	flags := featureflag.New(nil)
+	proc.SetFeatureFlags(flags)
+	flags.Kill(FlagMarketOrder.Name())
+
+	market := &OpenPositionRequest{UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Leverage: 10, Type: OrderTypeMarket}
+	assert.ErrorIs(t, proc.OpenPosition(h.ctx, market), featureflag.ErrDisabled)
+	avail, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(20000*Precision), avail)
+	assert.Zero(t, locked)
+
+	// 限价单不受影响
+	require.NoError(t, proc.OpenPosition(h.ctx, &OpenPositionRequest{
+		UserID: 1, Symbol: symbol, Side: SideLong, Qty: Precision, Price: 49000 * Precision, Leverage: 10,
+	}))
+
+	flags.Revive(FlagMarketOrder.Name())
+	assert.NotErrorIs(t, proc.OpenPosition(h.ctx, market), featureflag.ErrDisabled)
+}
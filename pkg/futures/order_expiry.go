@@ -0,0 +1,150 @@
+// 文件: pkg/futures/order_expiry.go
+// 挂单到期 (GTD) 与过期扫描
+//
+// 【问题】挂单冻结的保证金只有成交或用户撤单才释放，
+// 用户忘了撤、价格再也回不来的单子会把保证金永远锁住
+//
+// 【做法】
+//   - 每笔订单带到期时间：请求里指定 (GTD)，不指定则取合约的 MaxOrderLifetime，再不配置则 DefaultMaxOrderLifetime
+//   - 指定的到期时间超过合约最长存活时按最长存活截断
+//   - OrderExpirySweeper 定时扫一遍订单元数据，到期的通过撮合撤单，
+//     保证金在撤单事件里解冻 (和用户撤单同一条路径，不会出现撮合里还挂着、钱已经退了的情况)
+//
+// 【注意】市价单 (IOC) 不会挂单，不参与到期
+
+package futures
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+const (
+	// DefaultMaxOrderLifetime 合约未配置时的挂单最长存活时间
+	DefaultMaxOrderLifetime = 30 * 24 * time.Hour
+
+	// DefaultOrderExpiryInterval 过期扫描间隔
+	DefaultOrderExpiryInterval = 10 * time.Second
+)
+
+// ErrInvalidExpiry 到期时间早于当前时间
+var ErrInvalidExpiry = cexerr.New("FUTURES_INVALID_EXPIRY", cexerr.CategoryInvalidArgument, "order expire time must be in the future")
+
+// maxOrderLifetime 合约的挂单最长存活时间
+func (s *ContractSpec) maxOrderLifetime() time.Duration {
+	if s == nil || s.MaxOrderLifetime <= 0 {
+		return DefaultMaxOrderLifetime
+	}
+	return time.Duration(s.MaxOrderLifetime) * time.Second
+}
+
+// orderExpireAt 计算挂单到期时间 (unix ms)
+//
+// requested 为 0 时取合约最长存活；超过最长存活时截断
+func (p *FuturesProcessor) orderExpireAt(spec *ContractSpec, requested int64) (int64, error) {
+	now := p.now()
+	limit := now.Add(spec.maxOrderLifetime()).UnixMilli()
+	if requested == 0 {
+		return limit, nil
+	}
+	if requested <= now.UnixMilli() {
+		return 0, ErrInvalidExpiry
+	}
+	return min(requested, limit), nil
+}
+
+// cancelReason 撤单事件里的原因
+func cancelReason(meta *OrderMeta) string {
+	if meta.expiring.Load() {
+		return "expired"
+	}
+	return "user_cancel"
+}
+
+// =============================================================================
+// OrderExpirySweeper
+// =============================================================================
+
+// OrderExpirySweeper 过期挂单扫描器
+type OrderExpirySweeper struct {
+	proc     *FuturesProcessor
+	interval time.Duration
+
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewOrderExpirySweeper 创建过期扫描器，interval <= 0 使用 DefaultOrderExpiryInterval
+func NewOrderExpirySweeper(proc *FuturesProcessor, interval time.Duration) *OrderExpirySweeper {
+	if interval <= 0 {
+		interval = DefaultOrderExpiryInterval
+	}
+	return &OrderExpirySweeper{
+		proc:     proc,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start 启动定时扫描
+func (s *OrderExpirySweeper) Start() error {
+	if s.running {
+		return errors.New("order expiry sweeper already running")
+	}
+	s.running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				if n := s.Sweep(); n > 0 {
+					log.Printf("[OrderExpiry] %d expired orders sent to cancel", n)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止扫描
+func (s *OrderExpirySweeper) Stop() {
+	if !s.running {
+		return
+	}
+	close(s.stopCh)
+	s.wg.Wait()
+	s.running = false
+}
+
+// Sweep 扫描一遍，把到期订单提交撤单，返回提交的数量
+//
+// 撤单已提交的订单做标记不再重复提交；撤单队列满时留到下一轮
+func (s *OrderExpirySweeper) Sweep() int {
+	now := s.proc.now().UnixMilli()
+	submitted := 0
+	s.proc.orderMetas.Range(func(key, val any) bool {
+		meta := val.(*OrderMeta)
+		if meta.ExpireAt <= 0 || meta.ExpireAt > now || meta.expiring.Load() {
+			return true
+		}
+		meta.expiring.Store(true)
+		if !s.proc.CancelOrder(key.(int64)) {
+			meta.expiring.Store(false)
+			return true
+		}
+		submitted++
+		return true
+	})
+	return submitted
+}
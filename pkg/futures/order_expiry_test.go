@@ -0,0 +1,61 @@
+// 文件: pkg/futures/order_expiry_test.go
+// 挂单到期测试 (内存夹具，见 harness_test.go)
+
+package futures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/mtrade"
+	"max.com/pkg/order"
+)
+
+func TestHarness_OrderExpiry(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	spec := harnessLinearSpec()
+	spec.MaxOrderLifetime = 60
+	h := newHarness(t, spec)
+	proc := h.procs[symbol]
+	sweeper := NewOrderExpirySweeper(proc, 0)
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+
+	// 到期时间不能在过去
+	_, err := proc.orderExpireAt(spec, h.clock.Now().UnixMilli())
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+
+	// 不指定到期时间：按合约最长存活 60 秒；GTD 10 秒
+	defaultOrder := &OpenPositionRequest{
+		OrderID: order.GenerateOrderID(),
+		UserID:  1, Symbol: symbol, Side: SideLong, Qty: Precision / 10, Price: 40000 * Precision, Leverage: 10,
+	}
+	gtdOrder := *defaultOrder
+	gtdOrder.OrderID = order.GenerateOrderID()
+	gtdOrder.ExpireAt = h.clock.Now().Add(10 * time.Second).UnixMilli()
+	require.NoError(t, proc.OpenPosition(h.ctx, defaultOrder))
+	require.NoError(t, proc.OpenPosition(h.ctx, &gtdOrder))
+	h.waitFor(symbol, mtrade.EventOrderAccepted, 2)
+
+	_, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(800*Precision), locked)
+	assert.Zero(t, sweeper.Sweep())
+
+	// 11 秒后 GTD 单到期
+	h.clock.Advance(11 * time.Second)
+	assert.Equal(t, 1, sweeper.Sweep())
+	assert.Zero(t, sweeper.Sweep(), "cancel already submitted")
+	h.waitFor(symbol, mtrade.EventOrderCanceled, 1)
+	_, locked = h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(400*Precision), locked)
+
+	// 超过合约最长存活，默认单到期，保证金全部释放
+	h.clock.Advance(time.Minute)
+	assert.Equal(t, 1, sweeper.Sweep())
+	h.waitFor(symbol, mtrade.EventOrderCanceled, 1)
+	avail, locked := h.ledger.balance(1, "USDT")
+	assert.Equal(t, int64(10000*Precision), avail)
+	assert.Zero(t, locked)
+}
@@ -0,0 +1,328 @@
+// 文件: pkg/futures/order_outbox.go
+// 开仓下单 outbox - 冻结保证金 + 写订单 + 提交撮合 的原子化
+//
+// 【问题】
+// 原来的开仓是三步：冻结冷钱包 → 写订单 → 提交撮合，失败时手动回滚。
+// 任意两步之间进程挂掉，冻结的保证金就没人解冻了 (订单也永远停在 NEW)。
+//
+// 【设计】事务 outbox
+//  1. 下单: 冻结保证金 + 写订单 + 写 outbox 消息，同一个 MySQL 事务
+//  2. 中继 (relay): 单协程轮询 PENDING 的 outbox，提交撮合后标记 SENT
+//  3. 回收 (reaper): PENDING 超过 ReapAfter 仍没进撮合的，同一事务里
+//     标记 ABORTED + 解冻保证金 + 订单置 REJECTED
+//
+// 【面试】提交撮合成功但标记 SENT 之前宕机怎么办？
+// 重启后这条还是 PENDING，会再提交一次。撮合引擎在内存里，进程挂了订单簿也没了，
+// 重新提交正好把订单补回去；多实例部署改 gRPC 后要靠撮合侧按订单 ID 去重。
+//
+// 【注意】
+// - 中继和回收在同一个协程里串行执行，同一条消息不会一边提交一边被回收
+// - 下单接口返回成功只代表"已受理"，撮合拒单/超时回收是异步的，订单状态以 orders 表为准
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/order"
+)
+
+// =============================================================================
+// 数据模型
+// =============================================================================
+
+// OutboxStatus outbox 消息状态
+type OutboxStatus int8
+
+const (
+	OutboxPending OutboxStatus = 0 // 已落库，未进撮合
+	OutboxSent    OutboxStatus = 1 // 已提交撮合
+	OutboxAborted OutboxStatus = 2 // 超时未进撮合，保证金已解冻
+)
+
+// OrderOutbox 待提交撮合的开仓单
+//
+// 保存提交撮合和解冻保证金需要的全部字段，中继/回收不依赖内存状态
+type OrderOutbox struct {
+	ID            uint         `gorm:"primaryKey;autoIncrement"`
+	OrderID       int64        `gorm:"column:order_id;uniqueIndex"`
+	UserID        int64        `gorm:"column:user_id"`
+	Symbol        string       `gorm:"column:symbol;type:varchar(32)"`
+	Currency      string       `gorm:"column:currency;type:varchar(16)"` // 冻结币种 (结算币种)
+	Side          Side         `gorm:"column:side"`
+	Type          OrderType    `gorm:"column:order_type"` // 市价单以保护价 (Price) 挂 IOC
+	Price         int64        `gorm:"column:price"`
+	Qty           int64        `gorm:"column:qty"`
+	Leverage      int          `gorm:"column:leverage"`
+	Margin        int64        `gorm:"column:margin"`                           // 冻结的保证金
+	ExpireAt      int64        `gorm:"column:expire_at"`                        // 挂单到期时间 (unix ms)
+	ClientOrderID string       `gorm:"column:client_order_id;type:varchar(64)"` // 客户端订单号，提交撮合时带上
+	Status        OutboxStatus `gorm:"column:status;index"`
+	CreatedAt     int64        `gorm:"column:created_at"`
+	UpdatedAt     int64        `gorm:"column:updated_at"`
+}
+
+func (OrderOutbox) TableName() string {
+	return "futures_order_outbox"
+}
+
+// =============================================================================
+// 存储
+// =============================================================================
+
+// OrderOutboxStore outbox 存储
+type OrderOutboxStore interface {
+	// Place 冻结保证金 + 写订单 + 写 outbox，同一事务；余额不足返回 ErrInsufficientMargin
+	Place(ctx context.Context, o *OrderOutbox, ord *order.Order) error
+
+	// ListPending 未进撮合的消息，按 ID 升序
+	ListPending(ctx context.Context, limit int) ([]*OrderOutbox, error)
+
+	// MarkSent 标记已提交撮合
+	MarkSent(ctx context.Context, orderID int64) error
+
+	// Abort 标记放弃 + 解冻保证金 + 订单置 REJECTED，同一事务；已不是 PENDING 时什么都不做
+	Abort(ctx context.Context, o *OrderOutbox) error
+}
+
+// 确保实现了接口
+var _ OrderOutboxStore = (*MySQLOrderOutboxStore)(nil)
+
+// MySQLOrderOutboxStore MySQL 实现
+//
+// 【注意】futures_order_outbox、orders 必须和冷钱包余额表在同一个库，才能同一事务提交
+type MySQLOrderOutboxStore struct {
+	db       *gorm.DB
+	balances *fund.BalanceRepo
+}
+
+// NewMySQLOrderOutboxStore 创建 outbox 存储
+func NewMySQLOrderOutboxStore(db *gorm.DB, balances *fund.BalanceRepo) *MySQLOrderOutboxStore {
+	return &MySQLOrderOutboxStore{db: db, balances: balances}
+}
+
+// Place 原子下单
+func (s *MySQLOrderOutboxStore) Place(ctx context.Context, o *OrderOutbox, ord *order.Order) error {
+	return s.balances.TransactionWithDB(ctx, func(tx *fund.BalanceRepo, db *gorm.DB) error {
+		if _, _, err := tx.FreezeWithJournal(ctx, o.UserID, o.Currency, o.Margin, marginFreezeRef(o.OrderID)); err != nil {
+			if errors.Is(err, fund.ErrInsufficientAvailable) {
+				return ErrInsufficientMargin
+			}
+			return err
+		}
+		if err := db.Create(ord).Error; err != nil {
+			return err
+		}
+		return db.Create(o).Error
+	})
+}
+
+// ListPending 查询未进撮合的消息
+func (s *MySQLOrderOutboxStore) ListPending(ctx context.Context, limit int) ([]*OrderOutbox, error) {
+	var list []*OrderOutbox
+	err := s.db.WithContext(ctx).
+		Where("status = ?", OutboxPending).
+		Order("id ASC").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+// MarkSent 标记已提交
+func (s *MySQLOrderOutboxStore) MarkSent(ctx context.Context, orderID int64) error {
+	return s.db.WithContext(ctx).
+		Model(&OrderOutbox{}).
+		Where("order_id = ? AND status = ?", orderID, OutboxPending).
+		Updates(map[string]interface{}{
+			"status":     OutboxSent,
+			"updated_at": time.Now().UnixMilli(),
+		}).Error
+}
+
+// Abort 回收
+func (s *MySQLOrderOutboxStore) Abort(ctx context.Context, o *OrderOutbox) error {
+	return s.balances.TransactionWithDB(ctx, func(tx *fund.BalanceRepo, db *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		result := db.Model(&OrderOutbox{}).
+			Where("order_id = ? AND status = ?", o.OrderID, OutboxPending).
+			Updates(map[string]interface{}{
+				"status":     OutboxAborted,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // 已提交或已回收
+		}
+		if _, _, err := tx.UnfreezeWithJournal(ctx, o.UserID, o.Currency, o.Margin, marginReleaseRef(o.OrderID)); err != nil {
+			return err
+		}
+		return db.Model(&order.Order{}).
+			Where("order_id = ?", o.OrderID).
+			Updates(map[string]interface{}{
+				"status":     order.StatusRejected,
+				"updated_at": now,
+			}).Error
+	})
+}
+
+// =============================================================================
+// 中继 + 回收
+// =============================================================================
+
+// OutboxRelayConfig 中继配置
+type OutboxRelayConfig struct {
+	// PollInterval 轮询间隔 (下单时会主动唤醒，轮询只兜底重启和撮合队列满的情况)
+	PollInterval time.Duration
+
+	// ReapAfter 超过这个时间仍未进撮合的消息被回收
+	ReapAfter time.Duration
+
+	// BatchSize 每次读取的消息数
+	BatchSize int
+}
+
+func DefaultOutboxRelayConfig() *OutboxRelayConfig {
+	return &OutboxRelayConfig{
+		PollInterval: 500 * time.Millisecond,
+		ReapAfter:    30 * time.Second,
+		BatchSize:    100,
+	}
+}
+
+// OrderOutboxRelay outbox 中继
+//
+// 通过 FuturesProcessor.SetOrderOutbox 挂到处理器上，之后开仓走 outbox
+type OrderOutboxRelay struct {
+	store  OrderOutboxStore
+	config *OutboxRelayConfig
+
+	// submit 提交撮合，返回 false 表示撮合队列满 (由 SetOrderOutbox 设置)
+	submit func(o *OrderOutbox) bool
+
+	// 已提交撮合但 MarkSent 失败的订单，只重试标记，不再重复提交
+	sent map[int64]struct{}
+
+	kick     chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+}
+
+// NewOrderOutboxRelay 创建 outbox 中继
+func NewOrderOutboxRelay(store OrderOutboxStore, config *OutboxRelayConfig) *OrderOutboxRelay {
+	if config == nil {
+		config = DefaultOutboxRelayConfig()
+	}
+	return &OrderOutboxRelay{
+		store:    store,
+		config:   config,
+		sent:     make(map[int64]struct{}),
+		kick:     make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 启动中继 (启动时先处理上次遗留的消息)
+func (r *OrderOutboxRelay) Start() error {
+	if r.running {
+		return errors.New("order outbox relay already running")
+	}
+	if r.submit == nil {
+		return errors.New("order outbox relay not attached to a processor")
+	}
+	r.running = true
+	r.wg.Add(1)
+	go r.loop()
+
+	log.Println("[OrderOutbox] Relay started")
+	return nil
+}
+
+// Stop 停止中继
+func (r *OrderOutboxRelay) Stop() {
+	if !r.running {
+		return
+	}
+	close(r.stopChan)
+	r.wg.Wait()
+	r.running = false
+
+	log.Println("[OrderOutbox] Relay stopped")
+}
+
+// Notify 唤醒中继 (下单后调用，不阻塞)
+func (r *OrderOutboxRelay) Notify() {
+	select {
+	case r.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (r *OrderOutboxRelay) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	r.drain(context.Background())
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+		case <-r.kick:
+		}
+		r.drain(context.Background())
+	}
+}
+
+// drain 处理所有 PENDING 消息，撮合队列满时留到下一轮
+func (r *OrderOutboxRelay) drain(ctx context.Context) {
+	for {
+		list, err := r.store.ListPending(ctx, r.config.BatchSize)
+		if err != nil {
+			log.Printf("[OrderOutbox] List pending failed: %v", err)
+			return
+		}
+		if !r.process(ctx, list) || len(list) < r.config.BatchSize {
+			return
+		}
+	}
+}
+
+// process 处理一批消息，返回 false 表示这一轮应该停下 (撮合队列满或标记失败)
+func (r *OrderOutboxRelay) process(ctx context.Context, list []*OrderOutbox) bool {
+	now := time.Now().UnixMilli()
+	for _, o := range list {
+		if _, ok := r.sent[o.OrderID]; !ok {
+			if now-o.CreatedAt > r.config.ReapAfter.Milliseconds() {
+				if err := r.store.Abort(ctx, o); err != nil {
+					log.Printf("[OrderOutbox] Abort order %d failed: %v", o.OrderID, err)
+					return false
+				}
+				log.Printf("[OrderOutbox] Order %d never reached the engine, margin %d %s released",
+					o.OrderID, o.Margin, o.Currency)
+				continue
+			}
+			if !r.submit(o) {
+				return false
+			}
+			r.sent[o.OrderID] = struct{}{}
+		}
+		if err := r.store.MarkSent(ctx, o.OrderID); err != nil {
+			log.Printf("[OrderOutbox] Mark order %d sent failed: %v", o.OrderID, err)
+			return false
+		}
+		delete(r.sent, o.OrderID)
+	}
+	return true
+}
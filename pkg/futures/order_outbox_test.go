@@ -0,0 +1,115 @@
+// 文件: pkg/futures/order_outbox_test.go
+// 开仓 outbox 中继/回收测试 (内存存储，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/order"
+)
+
+type memOrderOutboxStore struct {
+	msgs     []*OrderOutbox
+	released int64 // 回收解冻的保证金
+	failMark bool
+}
+
+func (s *memOrderOutboxStore) Place(ctx context.Context, o *OrderOutbox, ord *order.Order) error {
+	o.ID = uint(len(s.msgs) + 1)
+	s.msgs = append(s.msgs, o)
+	return nil
+}
+
+func (s *memOrderOutboxStore) ListPending(ctx context.Context, limit int) ([]*OrderOutbox, error) {
+	var out []*OrderOutbox
+	for _, o := range s.msgs {
+		if o.Status == OutboxPending && len(out) < limit {
+			cp := *o
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memOrderOutboxStore) find(orderID int64) *OrderOutbox {
+	for _, o := range s.msgs {
+		if o.OrderID == orderID {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *memOrderOutboxStore) MarkSent(ctx context.Context, orderID int64) error {
+	if s.failMark {
+		return errors.New("db down")
+	}
+	if o := s.find(orderID); o != nil && o.Status == OutboxPending {
+		o.Status = OutboxSent
+	}
+	return nil
+}
+
+func (s *memOrderOutboxStore) Abort(ctx context.Context, o *OrderOutbox) error {
+	if cur := s.find(o.OrderID); cur != nil && cur.Status == OutboxPending {
+		cur.Status = OutboxAborted
+		s.released += cur.Margin
+	}
+	return nil
+}
+
+func TestOrderOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	store := &memOrderOutboxStore{}
+	relay := NewOrderOutboxRelay(store, &OutboxRelayConfig{ReapAfter: time.Minute, BatchSize: 2})
+
+	var submitted []int64
+	engineFull := false
+	relay.submit = func(o *OrderOutbox) bool {
+		if engineFull {
+			return false
+		}
+		submitted = append(submitted, o.OrderID)
+		return true
+	}
+
+	now := time.Now().UnixMilli()
+	for id := int64(1); id <= 3; id++ {
+		store.Place(ctx, &OrderOutbox{OrderID: id, Margin: 100, CreatedAt: now}, nil)
+	}
+	// 宕机前遗留、早已超时的消息
+	store.Place(ctx, &OrderOutbox{OrderID: 4, Margin: 500, CreatedAt: now - time.Hour.Milliseconds()}, nil)
+
+	// 撮合队列满：一条都不提交，也不回收未超时的
+	engineFull = true
+	relay.drain(ctx)
+	assert.Empty(t, submitted)
+	assert.Equal(t, OutboxPending, store.find(1).Status)
+
+	// 恢复后全部处理完 (跨多批)
+	engineFull = false
+	relay.drain(ctx)
+	assert.Equal(t, []int64{1, 2, 3}, submitted)
+	for id := int64(1); id <= 3; id++ {
+		assert.Equal(t, OutboxSent, store.find(id).Status)
+	}
+	assert.Equal(t, OutboxAborted, store.find(4).Status)
+	assert.Equal(t, int64(500), store.released)
+
+	// 提交成功但标记失败：下一轮只重试标记，不重复提交
+	store.Place(ctx, &OrderOutbox{OrderID: 5, CreatedAt: now}, nil)
+	store.failMark = true
+	relay.drain(ctx)
+	store.failMark = false
+	relay.drain(ctx)
+	require.Equal(t, []int64{1, 2, 3, 5}, submitted)
+	assert.Equal(t, OutboxSent, store.find(5).Status)
+	assert.Empty(t, relay.sent)
+}
@@ -0,0 +1,95 @@
+// 文件: pkg/futures/param_admin.go
+// 合约参数变更管理接口 (仅内网，鉴权由网关负责)
+//
+// 【对外暴露】
+// GET    /admin/contracts/params?symbol=X   待生效变更 + 参数版本历史
+// POST   /admin/contracts/params            排期一次变更 (资金费率上下限、利率、结算间隔、保证金率等)
+// DELETE /admin/contracts/params/{id}       撤销未生效的变更
+//
+// 变更都走 ScheduleParamChange：提前公告、到点生效，不提供 "立即修改" 的入口。
+// effective_at 为 0 表示下一个调度周期生效 (紧急调整，仍会发 SCHEDULED / EFFECTIVE 事件)
+
+package futures
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+// ScheduleParamChangeRequest 排期请求
+type ScheduleParamChangeRequest struct {
+	Symbol      string      `json:"symbol"`
+	Change      ParamChange `json:"change"`
+	EffectiveAt int64       `json:"effective_at"` // 毫秒，0 表示立即 (下一个调度周期)
+	Reason      string      `json:"reason"`
+}
+
+// ParamChangesResponse 合约参数变更查询结果
+type ParamChangesResponse struct {
+	Symbol  string                 `json:"symbol"`
+	Current ContractParams         `json:"current"`
+	Pending []*ContractParamChange `json:"pending"`
+	History []*ContractParamChange `json:"history"`
+}
+
+// NewParamChangeHandler 创建合约参数变更管理接口
+func NewParamChangeHandler(m *ContractManager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/contracts/params", func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		spec, err := m.GetContract(ctx, r.URL.Query().Get("symbol"))
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		resp := ParamChangesResponse{Symbol: spec.Symbol, Current: paramsOf(spec)}
+		if resp.Pending, err = m.PendingParamChanges(ctx, spec.Symbol); err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		if resp.History, err = m.ParamHistory(ctx, spec.Symbol); err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		writeParamJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("POST /admin/contracts/params", func(w http.ResponseWriter, r *http.Request) {
+		var req ScheduleParamChangeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("request body: %v", err))
+			return
+		}
+		if req.EffectiveAt == 0 {
+			req.EffectiveAt = time.Now().UnixMilli()
+		}
+		c, err := m.ScheduleParamChange(r.Context(), req.Symbol, req.Change, req.EffectiveAt, req.Reason)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		writeParamJSON(w, http.StatusCreated, c)
+	})
+	mux.HandleFunc("DELETE /admin/contracts/params/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("id"))
+			return
+		}
+		if err := m.CancelParamChange(r.Context(), id); err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func writeParamJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
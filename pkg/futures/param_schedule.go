@@ -0,0 +1,641 @@
+// 文件: pkg/futures/param_schedule.go
+// 合约风控参数定时变更
+//
+// 【为什么需要】
+// 直接在盘中改维持保证金率 / 最大杠杆 / TickSize，用户毫无准备：
+// - MMR 调高的一瞬间，一批仓位直接跌破维持保证金被强平
+// - TickSize 变了，做市商按旧精度挂的单全部被拒
+// 交易所的做法是提前公告 "X 时刻起 BTCUSDT 维持保证金率调整为 Y"，到点统一生效。
+//
+// 【流程】
+//
+//	ScheduleParamChange ──► PENDING ──(生效前 notice)──► 预告事件 (Upcoming)
+//	                          │
+//	                          ├── 到点 ──► 同一合约到期的变更合并为一次 Update ──► APPLIED (版本号 +1)
+//	                          ├── 校验失败 ──► FAILED
+//	                          └── CancelParamChange ──► CANCELED
+//
+// 【设计】
+// - 变更里存的是目标值 (不是增量)，生效时重复执行结果相同：合约已更新但标记 APPLIED 前宕机，
+//   下一轮再应用一次也不会把参数改错
+// - 生效时以当时的合约参数为基础重新校验 (排队期间可能有别的变更先生效)
+// - 每次生效记录前后参数和版本号，就是该合约的参数版本历史
+//
+// 【注意】
+// - 调低最大杠杆不会自动降低已有持仓的杠杆；调高 MMR 会立刻影响已有持仓的强平价，这正是要提前公告的原因
+// - 精度是调度间隔：到点后最多晚一个 interval 生效
+// - 资金费参数 (上下限、利率) 下一次计算费率就用新值；结算间隔在当前这一期结算完成后才按新间隔排期
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/cexerr"
+)
+
+var (
+	ErrParamChangeNotFound   = cexerr.New("FUTURES_PARAM_CHANGE_NOT_FOUND", cexerr.CategoryNotFound, "contract parameter change not found")
+	ErrParamChangeNotPending = cexerr.New("FUTURES_PARAM_CHANGE_NOT_PENDING", cexerr.CategoryFailedPrecondition, "contract parameter change is not pending")
+	ErrParamChangeDisabled   = cexerr.New("FUTURES_PARAM_CHANGE_DISABLED", cexerr.CategoryFailedPrecondition, "parameter change store not configured")
+)
+
+const (
+	// DefaultParamChangeNotice 生效前多久发预告事件
+	DefaultParamChangeNotice = 24 * time.Hour
+
+	// DefaultParamScheduleInterval 调度检查间隔
+	DefaultParamScheduleInterval = time.Second
+)
+
+// =============================================================================
+// 参数与变更
+// =============================================================================
+
+// ContractParams 可定时变更的合约参数
+type ContractParams struct {
+	TickSize           int64 `json:"tick_size"`
+	MinOrderQty        int64 `json:"min_order_qty"`
+	MaxOrderQty        int64 `json:"max_order_qty"`
+	MaxPositionQty     int64 `json:"max_position_qty"`
+	MaxLeverage        int   `json:"max_leverage"`
+	InitialMarginRate  int64 `json:"initial_margin_rate"`  // 万分比
+	MaintMarginRate    int64 `json:"maint_margin_rate"`    // 万分比
+	MaxFundingRate     int64 `json:"max_funding_rate"`     // 万分比
+	MinFundingRate     int64 `json:"min_funding_rate"`     // 万分比
+	InterestRate       int64 `json:"interest_rate"`        // 万分比 (每期)
+	FundingInterval    int64 `json:"funding_interval"`     // 秒
+	LiquidationFeeRate int64 `json:"liquidation_fee_rate"` // 万分比
+}
+
+func paramsOf(spec *ContractSpec) ContractParams {
+	return ContractParams{
+		TickSize:           spec.TickSize,
+		MinOrderQty:        spec.MinOrderQty,
+		MaxOrderQty:        spec.MaxOrderQty,
+		MaxPositionQty:     spec.MaxPositionQty,
+		MaxLeverage:        spec.MaxLeverage,
+		InitialMarginRate:  spec.InitialMarginRate,
+		MaintMarginRate:    spec.MaintMarginRate,
+		MaxFundingRate:     spec.MaxFundingRate,
+		MinFundingRate:     spec.MinFundingRate,
+		InterestRate:       spec.InterestRate,
+		FundingInterval:    spec.FundingInterval,
+		LiquidationFeeRate: spec.LiquidationFeeRate,
+	}
+}
+
+func (p ContractParams) applyTo(spec *ContractSpec) {
+	spec.TickSize = p.TickSize
+	spec.MinOrderQty = p.MinOrderQty
+	spec.MaxOrderQty = p.MaxOrderQty
+	spec.MaxPositionQty = p.MaxPositionQty
+	spec.MaxLeverage = p.MaxLeverage
+	spec.InitialMarginRate = p.InitialMarginRate
+	spec.MaintMarginRate = p.MaintMarginRate
+	spec.MaxFundingRate = p.MaxFundingRate
+	spec.MinFundingRate = p.MinFundingRate
+	spec.InterestRate = p.InterestRate
+	spec.FundingInterval = p.FundingInterval
+	spec.LiquidationFeeRate = p.LiquidationFeeRate
+}
+
+// validate 与 ValidateCreateRequest 相同的约束
+func (p ContractParams) validate() error {
+	if p.TickSize <= 0 {
+		return ErrInvalidSpec.Wrapf("tick size must be positive")
+	}
+	if p.MaxLeverage <= 0 || p.MaxLeverage > 200 {
+		return ErrInvalidSpec.Wrapf("max leverage must be between 1 and 200")
+	}
+	if p.InitialMarginRate <= 0 || p.MaintMarginRate <= 0 {
+		return ErrInvalidSpec.Wrapf("margin rates must be positive")
+	}
+	if p.MaintMarginRate >= p.InitialMarginRate {
+		return ErrInvalidSpec.Wrapf("maint margin rate must be less than initial margin rate")
+	}
+	if p.LiquidationFeeRate < 0 || p.LiquidationFeeRate > p.MaintMarginRate {
+		return ErrInvalidSpec.Wrapf("liquidation fee rate must be between 0 and maint margin rate")
+	}
+	if p.MaxOrderQty > 0 && p.MinOrderQty > p.MaxOrderQty {
+		return ErrInvalidSpec.Wrapf("min order qty exceeds max order qty")
+	}
+	return validateFundingParams(p.FundingInterval, p.MaxFundingRate, p.MinFundingRate, p.InterestRate)
+}
+
+// ParamChange 一次变更的目标值，nil 表示不改
+type ParamChange struct {
+	TickSize           *int64 `json:"tick_size,omitempty"`
+	MinOrderQty        *int64 `json:"min_order_qty,omitempty"`
+	MaxOrderQty        *int64 `json:"max_order_qty,omitempty"`
+	MaxPositionQty     *int64 `json:"max_position_qty,omitempty"`
+	MaxLeverage        *int   `json:"max_leverage,omitempty"`
+	InitialMarginRate  *int64 `json:"initial_margin_rate,omitempty"`
+	MaintMarginRate    *int64 `json:"maint_margin_rate,omitempty"`
+	MaxFundingRate     *int64 `json:"max_funding_rate,omitempty"`
+	MinFundingRate     *int64 `json:"min_funding_rate,omitempty"`
+	InterestRate       *int64 `json:"interest_rate,omitempty"`
+	FundingInterval    *int64 `json:"funding_interval,omitempty"`
+	LiquidationFeeRate *int64 `json:"liquidation_fee_rate,omitempty"`
+}
+
+// IsEmpty 没有任何字段要改
+func (c ParamChange) IsEmpty() bool {
+	return c == ParamChange{}
+}
+
+// apply 在 p 上应用变更
+// 只改最大杠杆时，初始保证金率跟着变成 1/杠杆 (与 UpdateLeverage 一致)
+func (c ParamChange) apply(p ContractParams) ContractParams {
+	set := func(dst *int64, v *int64) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	set(&p.TickSize, c.TickSize)
+	set(&p.MinOrderQty, c.MinOrderQty)
+	set(&p.MaxOrderQty, c.MaxOrderQty)
+	set(&p.MaxPositionQty, c.MaxPositionQty)
+	set(&p.MaintMarginRate, c.MaintMarginRate)
+	set(&p.MaxFundingRate, c.MaxFundingRate)
+	set(&p.MinFundingRate, c.MinFundingRate)
+	set(&p.InterestRate, c.InterestRate)
+	set(&p.FundingInterval, c.FundingInterval)
+	set(&p.LiquidationFeeRate, c.LiquidationFeeRate)
+	if c.MaxLeverage != nil {
+		p.MaxLeverage = *c.MaxLeverage
+		if c.InitialMarginRate == nil && p.MaxLeverage > 0 {
+			p.InitialMarginRate = int64(RatePrecision / p.MaxLeverage)
+		}
+	}
+	set(&p.InitialMarginRate, c.InitialMarginRate)
+	return p
+}
+
+// ParamChangeStatus 变更状态
+type ParamChangeStatus string
+
+const (
+	ParamChangePending  ParamChangeStatus = "PENDING"
+	ParamChangeApplied  ParamChangeStatus = "APPLIED"
+	ParamChangeCanceled ParamChangeStatus = "CANCELED"
+	ParamChangeFailed   ParamChangeStatus = "FAILED"
+)
+
+// ContractParamChange 一条定时变更 (生效后即为一个参数版本)
+type ContractParamChange struct {
+	ID          uint64            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Symbol      string            `gorm:"column:symbol;type:varchar(32);index:idx_symbol_version,priority:1" json:"symbol"`
+	Change      ParamChange       `gorm:"column:change_set;serializer:json" json:"change"`
+	Reason      string            `gorm:"column:reason;type:varchar(255)" json:"reason,omitempty"` // 公告说明
+	EffectiveAt int64             `gorm:"column:effective_at" json:"effective_at"`                 // 生效时间 (毫秒)
+	Status      ParamChangeStatus `gorm:"column:status;type:varchar(16);index" json:"status"`
+	NotifiedAt  int64             `gorm:"column:notified_at" json:"notified_at,omitempty"` // 预告事件发出时间
+
+	// 生效后填写
+	Version    int            `gorm:"column:version;index:idx_symbol_version,priority:2" json:"version,omitempty"` // 合约参数版本号，从 1 递增
+	Before     ContractParams `gorm:"column:params_before;serializer:json" json:"before"`
+	After      ContractParams `gorm:"column:params_after;serializer:json" json:"after"`
+	AppliedAt  int64          `gorm:"column:applied_at" json:"applied_at,omitempty"`
+	FailReason string         `gorm:"column:fail_reason;type:varchar(255)" json:"fail_reason,omitempty"`
+
+	CreatedAt int64 `gorm:"column:created_at" json:"created_at"`
+}
+
+func (ContractParamChange) TableName() string {
+	return "contract_param_changes"
+}
+
+// =============================================================================
+// 存储
+// =============================================================================
+
+// ParamChangeStore 定时变更存储
+type ParamChangeStore interface {
+	// Create 写入新变更 (回填 ID)
+	Create(ctx context.Context, c *ContractParamChange) error
+
+	// Get 不存在返回 ErrParamChangeNotFound
+	Get(ctx context.Context, id uint64) (*ContractParamChange, error)
+
+	// ListPending 所有待生效变更，按 (生效时间, ID) 升序
+	ListPending(ctx context.Context) ([]*ContractParamChange, error)
+
+	// MarkNotified 记录预告已发出
+	MarkNotified(ctx context.Context, id uint64, at int64) error
+
+	// Finish PENDING → c.Status (APPLIED / CANCELED / FAILED)，同时写入版本、前后参数等字段
+	// 已不是 PENDING 时返回 ErrParamChangeNotPending
+	Finish(ctx context.Context, c *ContractParamChange) error
+
+	// LatestVersion 合约当前参数版本号，没有生效过的变更返回 0
+	LatestVersion(ctx context.Context, symbol string) (int, error)
+
+	// ListHistory 合约已生效的变更，按版本号升序
+	ListHistory(ctx context.Context, symbol string) ([]*ContractParamChange, error)
+}
+
+// 确保实现了接口
+var _ ParamChangeStore = (*MySQLParamChangeStore)(nil)
+
+// MySQLParamChangeStore MySQL 实现
+type MySQLParamChangeStore struct {
+	db *gorm.DB
+}
+
+// NewMySQLParamChangeStore 创建定时变更存储
+func NewMySQLParamChangeStore(db *gorm.DB) *MySQLParamChangeStore {
+	return &MySQLParamChangeStore{db: db}
+}
+
+// Create 写入新变更
+func (s *MySQLParamChangeStore) Create(ctx context.Context, c *ContractParamChange) error {
+	return s.db.WithContext(ctx).Create(c).Error
+}
+
+// Get 查询变更
+func (s *MySQLParamChangeStore) Get(ctx context.Context, id uint64) (*ContractParamChange, error) {
+	var c ContractParamChange
+	err := s.db.WithContext(ctx).First(&c, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrParamChangeNotFound
+	}
+	return &c, err
+}
+
+// ListPending 待生效变更
+func (s *MySQLParamChangeStore) ListPending(ctx context.Context) ([]*ContractParamChange, error) {
+	var list []*ContractParamChange
+	err := s.db.WithContext(ctx).Where("status = ?", ParamChangePending).
+		Order("effective_at ASC, id ASC").Find(&list).Error
+	return list, err
+}
+
+// MarkNotified 记录预告已发出
+func (s *MySQLParamChangeStore) MarkNotified(ctx context.Context, id uint64, at int64) error {
+	return s.db.WithContext(ctx).Model(&ContractParamChange{}).
+		Where("id = ? AND status = ?", id, ParamChangePending).
+		Update("notified_at", at).Error
+}
+
+// Finish 结束变更 (条件更新，防止并发生效/撤销)
+func (s *MySQLParamChangeStore) Finish(ctx context.Context, c *ContractParamChange) error {
+	res := s.db.WithContext(ctx).Model(&ContractParamChange{}).
+		Where("id = ? AND status = ?", c.ID, ParamChangePending).
+		Select("status", "version", "params_before", "params_after", "applied_at", "fail_reason").
+		Updates(c)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrParamChangeNotPending
+	}
+	return nil
+}
+
+// LatestVersion 当前参数版本号
+func (s *MySQLParamChangeStore) LatestVersion(ctx context.Context, symbol string) (int, error) {
+	var v int
+	err := s.db.WithContext(ctx).Model(&ContractParamChange{}).
+		Where("symbol = ? AND status = ?", symbol, ParamChangeApplied).
+		Select("COALESCE(MAX(version), 0)").Scan(&v).Error
+	return v, err
+}
+
+// ListHistory 参数版本历史
+func (s *MySQLParamChangeStore) ListHistory(ctx context.Context, symbol string) ([]*ContractParamChange, error) {
+	var list []*ContractParamChange
+	err := s.db.WithContext(ctx).Where("symbol = ? AND status = ?", symbol, ParamChangeApplied).
+		Order("version ASC").Find(&list).Error
+	return list, err
+}
+
+// =============================================================================
+// 事件
+// =============================================================================
+
+// ParamChangeEventType 变更事件类型
+type ParamChangeEventType int
+
+const (
+	ParamChangeScheduled ParamChangeEventType = iota + 1 // 已排期
+	ParamChangeUpcoming                                  // 即将生效 (生效前 notice 发出一次)
+	ParamChangeEffective                                 // 已生效
+	ParamChangeCancelled                                 // 已撤销
+	ParamChangeRejected                                  // 到点校验失败，未生效
+)
+
+func (t ParamChangeEventType) String() string {
+	switch t {
+	case ParamChangeScheduled:
+		return "SCHEDULED"
+	case ParamChangeUpcoming:
+		return "UPCOMING"
+	case ParamChangeEffective:
+		return "EFFECTIVE"
+	case ParamChangeCancelled:
+		return "CANCELLED"
+	case ParamChangeRejected:
+		return "REJECTED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParamChangeEvent 变更事件 (推送公告、刷新各服务的合约缓存)
+type ParamChangeEvent struct {
+	Type   ParamChangeEventType
+	Change ContractParamChange // 值拷贝
+}
+
+// =============================================================================
+// ContractManager 集成
+// =============================================================================
+
+// SetParamChangeStore 设置定时变更存储，notice <= 0 使用 DefaultParamChangeNotice
+func (m *ContractManager) SetParamChangeStore(store ParamChangeStore, notice time.Duration) {
+	if notice <= 0 {
+		notice = DefaultParamChangeNotice
+	}
+	m.paramStore = store
+	m.paramNotice = notice
+}
+
+// OnParamChange 注册变更事件回调 (可注册多个，同步调用，不要做耗时操作)
+func (m *ContractManager) OnParamChange(callback func(ParamChangeEvent)) {
+	m.paramMu.Lock()
+	defer m.paramMu.Unlock()
+	m.onParamChange = append(m.onParamChange, callback)
+}
+
+func (m *ContractManager) notifyParamChange(t ParamChangeEventType, c *ContractParamChange) {
+	m.paramMu.Lock()
+	callbacks := m.onParamChange
+	m.paramMu.Unlock()
+	for _, cb := range callbacks {
+		cb(ParamChangeEvent{Type: t, Change: *c})
+	}
+}
+
+// ScheduleParamChange 排期一次参数变更
+//
+// 按 "当前参数 + 更早生效的待生效变更" 预先校验一次，尽早发现填错的值；
+// 生效时还会再校验 (之后可能有撤销或插队的变更)
+func (m *ContractManager) ScheduleParamChange(ctx context.Context, symbol string, change ParamChange, effectiveAt int64, reason string) (*ContractParamChange, error) {
+	if m.paramStore == nil {
+		return nil, ErrParamChangeDisabled
+	}
+	if change.IsEmpty() {
+		return nil, ErrInvalidSpec.Wrapf("empty parameter change")
+	}
+	spec, err := m.repo.GetBySymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := m.paramStore.ListPending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := paramsOf(spec)
+	for _, c := range pending {
+		if c.Symbol == symbol && c.EffectiveAt <= effectiveAt {
+			params = c.Change.apply(params)
+		}
+	}
+	if err := change.apply(params).validate(); err != nil {
+		return nil, err
+	}
+
+	c := &ContractParamChange{
+		Symbol:      symbol,
+		Change:      change,
+		Reason:      reason,
+		EffectiveAt: effectiveAt,
+		Status:      ParamChangePending,
+		CreatedAt:   time.Now().UnixMilli(),
+	}
+	if err := m.paramStore.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	m.notifyParamChange(ParamChangeScheduled, c)
+	return c, nil
+}
+
+// CancelParamChange 撤销未生效的变更
+func (m *ContractManager) CancelParamChange(ctx context.Context, id uint64) error {
+	if m.paramStore == nil {
+		return ErrParamChangeDisabled
+	}
+	c, err := m.paramStore.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	c.Status = ParamChangeCanceled
+	if err := m.paramStore.Finish(ctx, c); err != nil {
+		return err
+	}
+	m.notifyParamChange(ParamChangeCancelled, c)
+	return nil
+}
+
+// ParamHistory 合约参数版本历史 (已生效的变更，按版本升序)
+func (m *ContractManager) ParamHistory(ctx context.Context, symbol string) ([]*ContractParamChange, error) {
+	if m.paramStore == nil {
+		return nil, ErrParamChangeDisabled
+	}
+	return m.paramStore.ListHistory(ctx, symbol)
+}
+
+// PendingParamChanges 合约待生效的变更，按生效时间升序
+func (m *ContractManager) PendingParamChanges(ctx context.Context, symbol string) ([]*ContractParamChange, error) {
+	if m.paramStore == nil {
+		return nil, ErrParamChangeDisabled
+	}
+	pending, err := m.paramStore.ListPending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ContractParamChange, 0, len(pending))
+	for _, c := range pending {
+		if c.Symbol == symbol {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// ProcessParamChanges 发出到期的预告，应用到点的变更 (由 ParamChangeScheduler 定时调用)
+// 返回本轮生效的变更数
+func (m *ContractManager) ProcessParamChanges(ctx context.Context, now int64) (int, error) {
+	if m.paramStore == nil {
+		return 0, ErrParamChangeDisabled
+	}
+	pending, err := m.paramStore.ListPending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	due := make(map[string][]*ContractParamChange)
+	for _, c := range pending {
+		switch {
+		case now >= c.EffectiveAt:
+			due[c.Symbol] = append(due[c.Symbol], c)
+		case c.NotifiedAt == 0 && now >= c.EffectiveAt-m.paramNotice.Milliseconds():
+			if err := m.paramStore.MarkNotified(ctx, c.ID, now); err != nil {
+				return 0, err
+			}
+			c.NotifiedAt = now
+			m.notifyParamChange(ParamChangeUpcoming, c)
+		}
+	}
+
+	symbols := make([]string, 0, len(due))
+	for symbol := range due {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	applied := 0
+	var firstErr error
+	for _, symbol := range symbols {
+		n, err := m.applyParamChanges(ctx, symbol, due[symbol], now)
+		applied += n
+		if err != nil {
+			log.Printf("[Futures] apply parameter changes for %s failed: %v", symbol, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return applied, firstErr
+}
+
+// applyParamChanges 把同一合约到点的变更按顺序合并，一次写入合约
+//
+// 【面试】为什么合并成一次 Update？
+// 同一时刻生效的 "杠杆 50→20" 和 "MMR 0.5%→1%" 如果分两次写，
+// 中间读到合约的服务会看到一个从没公告过的组合 (20 倍 + 0.5%)
+func (m *ContractManager) applyParamChanges(ctx context.Context, symbol string, changes []*ContractParamChange, now int64) (int, error) {
+	spec, err := m.repo.GetBySymbol(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	version, err := m.paramStore.LatestVersion(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	params := paramsOf(spec)
+	var ok, failed []*ContractParamChange
+	for _, c := range changes {
+		next := c.Change.apply(params)
+		if err := next.validate(); err != nil {
+			c.Status, c.FailReason, c.AppliedAt = ParamChangeFailed, err.Error(), now
+			failed = append(failed, c)
+			continue
+		}
+		version++
+		c.Status, c.Version, c.Before, c.After, c.AppliedAt = ParamChangeApplied, version, params, next, now
+		params = next
+		ok = append(ok, c)
+	}
+
+	if len(ok) > 0 {
+		params.applyTo(spec)
+		spec.UpdatedAt = now
+		if err := m.repo.Update(ctx, spec); err != nil {
+			return 0, err // 全部保持 PENDING，下一轮重试
+		}
+	}
+
+	// 合约已更新；标记失败时下一轮会再次应用同样的目标值 (幂等)
+	for _, c := range ok {
+		if err := m.paramStore.Finish(ctx, c); err != nil {
+			return 0, err
+		}
+		log.Printf("[Futures] %s parameters v%d effective (change #%d)", symbol, c.Version, c.ID)
+		m.notifyParamChange(ParamChangeEffective, c)
+	}
+	for _, c := range failed {
+		if err := m.paramStore.Finish(ctx, c); err != nil {
+			return len(ok), err
+		}
+		log.Printf("[Futures] %s parameter change #%d rejected: %s", symbol, c.ID, c.FailReason)
+		m.notifyParamChange(ParamChangeRejected, c)
+	}
+	return len(ok), nil
+}
+
+// =============================================================================
+// 调度器
+// =============================================================================
+
+// ParamChangeScheduler 定时执行 ContractManager.ProcessParamChanges
+type ParamChangeScheduler struct {
+	manager  *ContractManager
+	interval time.Duration
+	now      func() time.Time
+
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewParamChangeScheduler 创建调度器，interval <= 0 使用 DefaultParamScheduleInterval
+func NewParamChangeScheduler(manager *ContractManager, interval time.Duration) *ParamChangeScheduler {
+	if interval <= 0 {
+		interval = DefaultParamScheduleInterval
+	}
+	return &ParamChangeScheduler{
+		manager:  manager,
+		interval: interval,
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// SetClock 替换时钟，预告 / 生效按它取当前时间，须在 Start 之前调用
+func (s *ParamChangeScheduler) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Start 启动定时检查
+func (s *ParamChangeScheduler) Start() error {
+	if s.running {
+		return errors.New("param change scheduler already running")
+	}
+	s.running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.manager.ProcessParamChanges(context.Background(), s.now().UnixMilli())
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止检查
+func (s *ParamChangeScheduler) Stop() {
+	if !s.running {
+		return
+	}
+	close(s.stopCh)
+	s.wg.Wait()
+	s.running = false
+}
@@ -0,0 +1,254 @@
+// 文件: pkg/futures/param_schedule_test.go
+// 合约参数定时变更测试 (内存仓储，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memParamChangeStore struct {
+	mu      sync.Mutex
+	changes []*ContractParamChange
+}
+
+func (s *memParamChangeStore) Create(ctx context.Context, c *ContractParamChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.ID = uint64(len(s.changes) + 1)
+	cp := *c
+	s.changes = append(s.changes, &cp)
+	return nil
+}
+
+func (s *memParamChangeStore) Get(ctx context.Context, id uint64) (*ContractParamChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || id > uint64(len(s.changes)) {
+		return nil, ErrParamChangeNotFound
+	}
+	cp := *s.changes[id-1]
+	return &cp, nil
+}
+
+func (s *memParamChangeStore) ListPending(ctx context.Context) ([]*ContractParamChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*ContractParamChange
+	for _, c := range s.changes {
+		if c.Status == ParamChangePending {
+			cp := *c
+			out = append(out, &cp)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].EffectiveAt < out[j].EffectiveAt })
+	return out, nil
+}
+
+func (s *memParamChangeStore) MarkNotified(ctx context.Context, id uint64, at int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes[id-1].NotifiedAt = at
+	return nil
+}
+
+func (s *memParamChangeStore) Finish(ctx context.Context, c *ContractParamChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur := s.changes[c.ID-1]
+	if cur.Status != ParamChangePending {
+		return ErrParamChangeNotPending
+	}
+	cur.Status, cur.Version, cur.Before, cur.After = c.Status, c.Version, c.Before, c.After
+	cur.AppliedAt, cur.FailReason = c.AppliedAt, c.FailReason
+	return nil
+}
+
+func (s *memParamChangeStore) LatestVersion(ctx context.Context, symbol string) (int, error) {
+	list, _ := s.ListHistory(ctx, symbol)
+	if len(list) == 0 {
+		return 0, nil
+	}
+	return list[len(list)-1].Version, nil
+}
+
+func (s *memParamChangeStore) ListHistory(ctx context.Context, symbol string) ([]*ContractParamChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*ContractParamChange
+	for _, c := range s.changes {
+		if c.Symbol == symbol && c.Status == ParamChangeApplied {
+			cp := *c
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+func paramTestSpec() *ContractSpec {
+	spec := harnessLinearSpec()
+	spec.TickSize = Precision / 10
+	spec.InitialMarginRate = RatePrecision / 100 // 1%
+	spec.MaintMarginRate = 50                    // 0.5%
+	return spec
+}
+
+func newParamTestManager(t *testing.T) (*ContractManager, *memContractRepo, *[]ParamChangeEvent) {
+	repo := newMemContractRepo(paramTestSpec())
+
+	m := NewContractManager(repo)
+	m.SetParamChangeStore(&memParamChangeStore{}, time.Hour)
+	var events []ParamChangeEvent
+	m.OnParamChange(func(e ParamChangeEvent) { events = append(events, e) })
+	return m, repo, &events
+}
+
+func TestParamSchedule_NoticeAndApply(t *testing.T) {
+	ctx := context.Background()
+	m, repo, events := newParamTestManager(t)
+	symbol := harnessLinearSpec().Symbol
+	effective := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC).UnixMilli()
+
+	lev, mmr := 20, int64(100)
+	_, err := m.ScheduleParamChange(ctx, symbol, ParamChange{MaxLeverage: &lev}, effective, "lower leverage")
+	require.NoError(t, err)
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{MaintMarginRate: &mmr}, effective, "raise mmr")
+	require.NoError(t, err)
+
+	// 预告窗口之前：什么都不做
+	n, err := m.ProcessParamChanges(ctx, effective-2*time.Hour.Milliseconds())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	require.Len(t, *events, 2)
+
+	// 进入预告窗口：每条只预告一次
+	m.ProcessParamChanges(ctx, effective-30*time.Minute.Milliseconds())
+	m.ProcessParamChanges(ctx, effective-20*time.Minute.Milliseconds())
+	require.Len(t, *events, 4)
+	assert.Equal(t, ParamChangeUpcoming, (*events)[2].Type)
+	assert.Equal(t, ParamChangeUpcoming, (*events)[3].Type)
+
+	// 到点：两条合并生效，合约参数一次写入
+	n, err = m.ProcessParamChanges(ctx, effective)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	spec, _ := repo.GetBySymbol(ctx, symbol)
+	assert.Equal(t, 20, spec.MaxLeverage)
+	assert.Equal(t, int64(RatePrecision/20), spec.InitialMarginRate, "IMR follows max leverage")
+	assert.Equal(t, int64(100), spec.MaintMarginRate)
+
+	history, err := m.ParamHistory(ctx, symbol)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 1, history[0].Version)
+	assert.Equal(t, 100, history[0].Before.MaxLeverage)
+	assert.Equal(t, history[0].After, history[1].Before, "versions chain")
+	assert.Equal(t, int64(100), history[1].After.MaintMarginRate)
+	assert.Equal(t, ParamChangeEffective, (*events)[len(*events)-1].Type)
+
+	// 再跑一轮没有可做的
+	n, err = m.ProcessParamChanges(ctx, effective+1000)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestParamSchedule_ValidationAndCancel(t *testing.T) {
+	ctx := context.Background()
+	m, repo, events := newParamTestManager(t)
+	symbol := harnessLinearSpec().Symbol
+	now := time.Now().UnixMilli()
+
+	// 排期时按当前参数校验
+	bad := int64(RatePrecision)
+	_, err := m.ScheduleParamChange(ctx, symbol, ParamChange{MaintMarginRate: &bad}, now, "")
+	assert.ErrorIs(t, err, ErrInvalidSpec)
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{}, now, "")
+	assert.ErrorIs(t, err, ErrInvalidSpec)
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{LiquidationFeeRate: &bad}, now, "")
+	assert.ErrorIs(t, err, ErrInvalidSpec, "liquidation fee above MMR")
+	_, err = m.ScheduleParamChange(ctx, "NOPE", ParamChange{MaintMarginRate: &bad}, now, "")
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+
+	// 撤销
+	tick := int64(Precision / 100)
+	c, err := m.ScheduleParamChange(ctx, symbol, ParamChange{TickSize: &tick}, now+1000, "")
+	require.NoError(t, err)
+	require.NoError(t, m.CancelParamChange(ctx, c.ID))
+	assert.ErrorIs(t, m.CancelParamChange(ctx, c.ID), ErrParamChangeNotPending)
+	assert.Equal(t, ParamChangeCancelled, (*events)[len(*events)-1].Type)
+
+	// 排期时合法、生效时与先生效的变更冲突：拒绝，不影响同批次其他变更
+	mmr := int64(90) // 0.9% < 当前 IMR 1%，排期时合法
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{MaintMarginRate: &mmr}, now+2000, "")
+	require.NoError(t, err)
+	low := 10 // IMR 变为 10%
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{MaxLeverage: &low}, now+1000, "")
+	require.NoError(t, err)
+	imr := int64(80) // 0.8%，低于已排期的 MMR
+	_, err = m.ScheduleParamChange(ctx, symbol, ParamChange{InitialMarginRate: &imr}, now+1500, "")
+	require.NoError(t, err)
+
+	n, err := m.ProcessParamChanges(ctx, now+2000)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, ParamChangeRejected, (*events)[len(*events)-1].Type)
+	spec, _ := repo.GetBySymbol(ctx, symbol)
+	assert.Equal(t, 10, spec.MaxLeverage)
+	assert.Equal(t, int64(80), spec.InitialMarginRate)
+	assert.Equal(t, int64(50), spec.MaintMarginRate, "conflicting MMR change not applied")
+	assert.Equal(t, int64(Precision/10), spec.TickSize, "canceled change not applied")
+}
+
+// TestParamSchedule_Scheduler 调度器按注入的时钟判断预告 / 生效，不依赖墙钟
+func TestParamSchedule_Scheduler(t *testing.T) {
+	ctx := context.Background()
+	store := &memParamChangeStore{}
+	repo := newMemContractRepo(paramTestSpec())
+	m := NewContractManager(repo)
+	m.SetParamChangeStore(store, time.Hour)
+	symbol := harnessLinearSpec().Symbol
+
+	clock := newFakeClock()
+	effective := clock.Now().Add(2 * time.Hour).UnixMilli()
+	lev := 20
+	_, err := m.ScheduleParamChange(ctx, symbol, ParamChange{MaxLeverage: &lev}, effective, "")
+	require.NoError(t, err)
+
+	s := NewParamChangeScheduler(m, time.Millisecond)
+	s.SetClock(clock.Now)
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	change := func() ContractParamChange {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return *store.changes[0]
+	}
+	maxLeverage := func() int {
+		spec, _ := repo.GetBySymbol(ctx, symbol)
+		return spec.MaxLeverage
+	}
+
+	// 进入预告窗口：只预告，参数不变
+	clock.Advance(90 * time.Minute)
+	require.Eventually(t, func() bool { return change().NotifiedAt != 0 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, clock.Now().UnixMilli(), change().NotifiedAt)
+	assert.Equal(t, ParamChangePending, change().Status)
+	assert.Equal(t, 100, maxLeverage())
+
+	// 到点生效，生效时间取调度器时钟
+	clock.Advance(30 * time.Minute)
+	require.Eventually(t, func() bool { return change().Status == ParamChangeApplied }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, effective, change().AppliedAt)
+	assert.Equal(t, 20, maxLeverage())
+
+	assert.Error(t, s.Start(), "already running")
+}
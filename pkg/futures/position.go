@@ -8,7 +8,11 @@
 
 package futures
 
-import "time"
+import (
+	"time"
+
+	"max.com/pkg/fixed"
+)
 
 // =============================================================================
 // 持仓方向
@@ -58,6 +62,17 @@ type Position struct {
 	// 未实现盈亏 (uPnL) 不存这里，实时用 UnrealizedPnL(markPrice) 计算
 	RealizedPnL int64 `gorm:"column:realized_pnl"`
 
+	// ===== 本轮持仓统计 =====
+	// 从开仓到清零为一轮：清零时写入 position_history (见 position_history.go)，新开仓时重置
+	// 持仓行本身会被复用，不重置的话历史记录就混在一起了
+	OpenedAt     int64 `gorm:"column:opened_at"`
+	ClosedQty    int64 `gorm:"column:closed_qty"`    // 本轮累计平仓数量
+	CloseValue   int64 `gorm:"column:close_value"`   // 本轮累计平仓成交额，平仓均价 = CloseValue × Precision / ClosedQty
+	CyclePnL     int64 `gorm:"column:cycle_pnl"`     // 本轮已实现盈亏
+	CycleFee     int64 `gorm:"column:cycle_fee"`     // 本轮手续费 (含强平手续费)
+	CycleLiqFee  int64 `gorm:"column:cycle_liq_fee"` // 本轮强平手续费
+	CycleFunding int64 `gorm:"column:cycle_funding"` // 本轮资金费 (正=收入, 负=支出)
+
 	CreatedAt int64 `gorm:"column:created_at"`
 	UpdatedAt int64 `gorm:"column:updated_at"`
 }
@@ -87,14 +102,40 @@ func (p *Position) IsEmpty() bool {
 	return p.Size == 0
 }
 
-// UnrealizedPnL 未实现盈亏
+// UnrealizedPnL 未实现盈亏 (正向合约，反向合约用 ContractSpec.PnL)
 func (p *Position) UnrealizedPnL(markPrice int64) int64 {
-	return (markPrice - p.EntryPrice) * p.Size / Precision
+	return fixed.Mul(markPrice-p.EntryPrice, p.Size)
 }
 
-// PositionValue 仓位价值
+// PositionValue 仓位价值 (正向合约，反向合约用 ContractSpec.PositionValue)
 func (p *Position) PositionValue(markPrice int64) int64 {
-	return p.AbsSize() * markPrice / Precision
+	return fixed.Mul(p.AbsSize(), markPrice)
+}
+
+// startCycle 新一轮开仓，清空本轮统计
+func (p *Position) startCycle(now int64) {
+	p.OpenedAt = now
+	p.ClosedQty = 0
+	p.CloseValue = 0
+	p.CyclePnL = 0
+	p.CycleFee = 0
+	p.CycleLiqFee = 0
+	p.CycleFunding = 0
+}
+
+// recordClose 记一笔平仓成交 (平仓/强平/交割)
+func (p *Position) recordClose(price, qty, pnl int64) {
+	p.ClosedQty += qty
+	p.CloseValue += fixed.Mul(price, qty)
+	p.CyclePnL += pnl
+	p.RealizedPnL += pnl
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
 }
 
 // =============================================================================
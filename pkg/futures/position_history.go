@@ -0,0 +1,274 @@
+// 文件: pkg/futures/position_history.go
+// 历史持仓 (已平仓记录)
+//
+// 【为什么需要】
+// positions 表每个 (用户, 合约) 只有一行，平仓后清零复用，开平仓价、盈亏全部丢失。
+// 用户的"历史持仓"页面、对账、税务报表都需要每一轮持仓的完整记录。
+//
+// 【写入时机】持仓清零的三条路径各写一条:
+// - 主动平仓: FuturesProcessor.handleCloseFill
+// - 强平:     LiquidationExecutor.handleLiquidationFill
+// - 交割:     SettlementEngine.settlePosition
+//
+// 【注意】历史记录在持仓保存之后写入，写失败只打日志不回滚：
+// 资金和持仓已经落账，历史记录是展示用的派生数据，缺失可以从流水补
+
+package futures
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+)
+
+// =============================================================================
+// 数据结构
+// =============================================================================
+
+// CloseReason 持仓结束原因
+type CloseReason string
+
+const (
+	CloseReasonClose       CloseReason = "CLOSE"       // 主动平仓
+	CloseReasonLiquidation CloseReason = "LIQUIDATION" // 强平
+	CloseReasonSettlement  CloseReason = "SETTLEMENT"  // 交割
+)
+
+// PositionHistory 一轮已结束的持仓
+type PositionHistory struct {
+	ID          uint64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int64       `gorm:"column:user_id;index:idx_user_closed,priority:1" json:"user_id"`
+	Symbol      string      `gorm:"column:symbol;type:varchar(32)" json:"symbol"`
+	Side        Side        `gorm:"column:side" json:"side"` // 1=多, -1=空
+	CloseReason CloseReason `gorm:"column:close_reason;type:varchar(16)" json:"close_reason"`
+	Leverage    int         `gorm:"column:leverage" json:"leverage"`
+
+	Qty        int64 `gorm:"column:qty" json:"qty"`                 // 累计平仓数量
+	EntryPrice int64 `gorm:"column:entry_price" json:"entry_price"` // 开仓均价
+	ExitPrice  int64 `gorm:"column:exit_price" json:"exit_price"`   // 平仓均价
+
+	RealizedPnL int64 `gorm:"column:realized_pnl" json:"realized_pnl"` // 平仓盈亏
+	Fee         int64 `gorm:"column:fee" json:"fee"`                   // 手续费 (正数，含强平手续费)
+	LiqFee      int64 `gorm:"column:liq_fee" json:"liq_fee"`           // 其中的强平手续费 (归保险基金)
+	Funding     int64 `gorm:"column:funding" json:"funding"`           // 资金费 (正=收入, 负=支出)
+	NetPnL      int64 `gorm:"column:net_pnl" json:"net_pnl"`           // 净盈亏 = RealizedPnL - Fee + Funding
+
+	OpenedAt int64 `gorm:"column:opened_at" json:"opened_at"`
+	ClosedAt int64 `gorm:"column:closed_at;index:idx_user_closed,priority:2" json:"closed_at"`
+}
+
+func (PositionHistory) TableName() string {
+	return "position_history"
+}
+
+// newPositionHistory 从刚清零的持仓生成历史记录
+// side / entryPrice 由调用方传入：清零后 pos.Size / pos.EntryPrice 已经不可用
+func newPositionHistory(pos *Position, side Side, entryPrice int64, reason CloseReason) *PositionHistory {
+	h := &PositionHistory{
+		UserID:      pos.UserID,
+		Symbol:      pos.Symbol,
+		Side:        side,
+		CloseReason: reason,
+		Leverage:    pos.Leverage,
+		Qty:         pos.ClosedQty,
+		EntryPrice:  entryPrice,
+		RealizedPnL: pos.CyclePnL,
+		Fee:         pos.CycleFee,
+		LiqFee:      pos.CycleLiqFee,
+		Funding:     pos.CycleFunding,
+		NetPnL:      pos.CyclePnL - pos.CycleFee + pos.CycleFunding,
+		OpenedAt:    pos.OpenedAt,
+		ClosedAt:    pos.UpdatedAt,
+	}
+	if pos.ClosedQty > 0 {
+		h.ExitPrice = fixed.Quo(pos.CloseValue, pos.ClosedQty)
+	}
+	if h.OpenedAt == 0 {
+		h.OpenedAt = pos.CreatedAt // 新增字段之前开的仓
+	}
+	return h
+}
+
+// =============================================================================
+// 存储
+// =============================================================================
+
+const (
+	defaultPositionHistoryLimit = 50
+	maxPositionHistoryLimit     = 500
+)
+
+// PositionHistoryQuery 历史持仓查询条件
+type PositionHistoryQuery struct {
+	UserID    int64
+	Symbol    string // 空表示全部合约
+	StartTime int64  // 平仓时间 (毫秒)，包含；0 表示不限
+	EndTime   int64  // 平仓时间 (毫秒)，不包含；0 表示不限
+	BeforeID  uint64 // 游标翻页：只返回 ID 小于它的记录；0 表示从最新开始
+	Limit     int    // <=0 使用默认值 50，最大 500
+}
+
+// PositionHistoryRepository 历史持仓存储
+type PositionHistoryRepository interface {
+	// Create 写入一条历史记录 (回填 ID)
+	Create(ctx context.Context, h *PositionHistory) error
+
+	// List 按写入顺序 (即平仓顺序) 倒序查询
+	List(ctx context.Context, q PositionHistoryQuery) ([]*PositionHistory, error)
+}
+
+// 确保实现了接口
+var _ PositionHistoryRepository = (*MySQLPositionHistoryRepository)(nil)
+
+// MySQLPositionHistoryRepository MySQL 实现
+type MySQLPositionHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewMySQLPositionHistoryRepository 创建历史持仓存储
+func NewMySQLPositionHistoryRepository(db *gorm.DB) *MySQLPositionHistoryRepository {
+	return &MySQLPositionHistoryRepository{db: db}
+}
+
+// Create 写入历史记录
+func (r *MySQLPositionHistoryRepository) Create(ctx context.Context, h *PositionHistory) error {
+	return r.db.WithContext(ctx).Create(h).Error
+}
+
+// List 查询历史记录
+//
+// 【面试】为什么用 BeforeID 游标而不是 Offset?
+// 历史持仓只增不减，用户翻到几百页时 OFFSET N 要扫过前 N 行；
+// 游标 id < ? 直接定位，翻页期间有新记录插入也不会重复/漏行。
+// ID 自增，写入顺序就是平仓顺序，按 ID 排序与按平仓时间排序等价
+func (r *MySQLPositionHistoryRepository) List(ctx context.Context, q PositionHistoryQuery) ([]*PositionHistory, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultPositionHistoryLimit
+	}
+	limit = min(limit, maxPositionHistoryLimit)
+
+	tx := r.db.WithContext(ctx).Where("user_id = ?", q.UserID)
+	if q.Symbol != "" {
+		tx = tx.Where("symbol = ?", q.Symbol)
+	}
+	if q.StartTime > 0 {
+		tx = tx.Where("closed_at >= ?", q.StartTime)
+	}
+	if q.EndTime > 0 {
+		tx = tx.Where("closed_at < ?", q.EndTime)
+	}
+	if q.BeforeID > 0 {
+		tx = tx.Where("id < ?", q.BeforeID)
+	}
+
+	var list []*PositionHistory
+	err := tx.Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// SetPositionHistory 设置历史持仓存储 (主动平仓)
+func (p *FuturesProcessor) SetPositionHistory(repo PositionHistoryRepository) {
+	p.positionHistory = repo
+}
+
+// SetPositionHistory 设置历史持仓存储 (强平)
+func (e *LiquidationExecutor) SetPositionHistory(repo PositionHistoryRepository) {
+	e.positionHistory = repo
+}
+
+// SetPositionHistory 设置历史持仓存储 (交割)
+func (e *SettlementEngine) SetPositionHistory(repo PositionHistoryRepository) {
+	e.positionHistory = repo
+}
+
+// recordPositionHistory 写历史记录，repo 为 nil 时跳过 (三条平仓路径共用)
+func recordPositionHistory(ctx context.Context, repo PositionHistoryRepository, pos *Position, side Side, entryPrice int64, reason CloseReason) {
+	if repo == nil {
+		return
+	}
+	h := newPositionHistory(pos, side, entryPrice, reason)
+	if err := repo.Create(ctx, h); err != nil {
+		log.Printf("[Futures] WARNING: record position history failed: user=%d symbol=%s reason=%s: %v",
+			pos.UserID, pos.Symbol, reason, err)
+	}
+}
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// PositionHistoryResponse 历史持仓接口响应
+type PositionHistoryResponse struct {
+	Items  []*PositionHistory `json:"items"`
+	NextID uint64             `json:"next_id,omitempty"` // 下一页的 before_id，为空表示没有更多
+}
+
+// NewPositionHistoryHandler 历史持仓查询接口 (用户"已平仓"页面)
+//
+//	GET /futures/positions/history?user_id=1&symbol=BTCUSDT&start_time=&end_time=&before_id=&limit=50
+//
+// user_id 由网关鉴权后注入，这里不做身份校验
+func NewPositionHistoryHandler(repo PositionHistoryRepository) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		userID, err := strconv.ParseInt(q.Get("user_id"), 10, 64)
+		if err != nil || userID <= 0 {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("user_id"))
+			return
+		}
+		query := PositionHistoryQuery{UserID: userID, Symbol: q.Get("symbol")}
+		for name, dst := range map[string]*int64{"start_time": &query.StartTime, "end_time": &query.EndTime} {
+			if v := q.Get(name); v != "" {
+				if *dst, err = strconv.ParseInt(v, 10, 64); err != nil {
+					cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("%s", name))
+					return
+				}
+			}
+		}
+		if v := q.Get("before_id"); v != "" {
+			if query.BeforeID, err = strconv.ParseUint(v, 10, 64); err != nil {
+				cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("before_id"))
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			if query.Limit, err = strconv.Atoi(v); err != nil {
+				cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("limit"))
+				return
+			}
+		}
+
+		items, err := repo.List(r.Context(), query)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		resp := PositionHistoryResponse{Items: items}
+		if items == nil {
+			resp.Items = []*PositionHistory{}
+		}
+		limit := query.Limit
+		if limit <= 0 {
+			limit = defaultPositionHistoryLimit
+		}
+		if len(items) == min(limit, maxPositionHistoryLimit) {
+			resp.NextID = items[len(items)-1].ID
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,109 @@
+// 文件: pkg/futures/position_history_test.go
+// 历史持仓测试 (内存仓储，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memPositionHistoryRepo struct {
+	mu   sync.Mutex
+	list []*PositionHistory
+}
+
+func (r *memPositionHistoryRepo) Create(ctx context.Context, h *PositionHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h.ID = uint64(len(r.list) + 1)
+	r.list = append(r.list, h)
+	return nil
+}
+
+func (r *memPositionHistoryRepo) List(ctx context.Context, q PositionHistoryQuery) ([]*PositionHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultPositionHistoryLimit
+	}
+	var out []*PositionHistory
+	for i := len(r.list) - 1; i >= 0 && len(out) < limit; i-- {
+		h := r.list[i]
+		if h.UserID != q.UserID || (q.Symbol != "" && h.Symbol != q.Symbol) ||
+			(q.BeforeID > 0 && h.ID >= q.BeforeID) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func TestPositionHistory_Cycle(t *testing.T) {
+	proc := &FuturesProcessor{now: time.Now}
+	repo := &memPositionHistoryRepo{}
+
+	// 开空 2 @ 50000，收 10 资金费，分两笔平仓 1 @ 49000、1 @ 48000
+	pos := &Position{UserID: 1, Symbol: "BTCUSDT", RealizedPnL: 500 * Precision}
+	proc.updatePosition(nil, pos, -2*Precision, 50000*Precision, 10000*Precision, 10, true)
+	pos.CycleFunding += 10 * Precision
+	pos.recordClose(49000*Precision, Precision, 1000*Precision)
+	pos.recordClose(48000*Precision, Precision, 2000*Precision)
+	pos.Size = 0
+	pos.UpdatedAt = pos.OpenedAt + 1000
+	recordPositionHistory(context.Background(), repo, pos, SideShort, 50000*Precision, CloseReasonClose)
+
+	require.Len(t, repo.list, 1)
+	h := repo.list[0]
+	assert.Equal(t, SideShort, h.Side)
+	assert.Equal(t, int64(2*Precision), h.Qty)
+	assert.Equal(t, int64(48500*Precision), h.ExitPrice)
+	assert.Equal(t, int64(3000*Precision), h.RealizedPnL)
+	assert.Equal(t, int64(3010*Precision), h.NetPnL)
+	assert.Equal(t, pos.OpenedAt+1000, h.ClosedAt)
+	assert.Equal(t, int64(3500*Precision), pos.RealizedPnL, "lifetime PnL keeps accumulating")
+
+	// 同一行重新开仓：本轮统计清零
+	proc.updatePosition(nil, pos, Precision, 51000*Precision, 5100*Precision, 10, false)
+	assert.Zero(t, pos.ClosedQty)
+	assert.Zero(t, pos.CyclePnL)
+	assert.Zero(t, pos.CycleFunding)
+}
+
+func TestPositionHistoryHandler(t *testing.T) {
+	repo := &memPositionHistoryRepo{}
+	for i := 0; i < 3; i++ {
+		repo.Create(context.Background(), &PositionHistory{UserID: 7, Symbol: "BTCUSDT"})
+	}
+	handler := NewPositionHistoryHandler(repo)
+
+	get := func(url string) (int, PositionHistoryResponse) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		var resp PositionHistoryResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	code, resp := get("/futures/positions/history?user_id=7&limit=2")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Items, 2)
+	assert.Equal(t, uint64(3), resp.Items[0].ID)
+	assert.Equal(t, uint64(2), resp.NextID)
+
+	_, resp = get("/futures/positions/history?user_id=7&limit=2&before_id=2")
+	require.Len(t, resp.Items, 1)
+	assert.Zero(t, resp.NextID)
+
+	code, _ = get("/futures/positions/history?user_id=abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -29,6 +29,15 @@ type PositionRepository interface {
 	// 删除
 	Delete(ctx context.Context, userID int64, symbol string) error
 	ListBySymbol(ctx context.Context, symbol string, limit, offset int) ([]*Position, error)
+
+	// ListBySymbolAfter 按 ID 升序分页 (keyset)，返回 ID > afterID 的非空持仓
+	ListBySymbolAfter(ctx context.Context, symbol string, afterID uint, limit int) ([]*Position, error)
+
+	// AddFunding 累加本轮资金费 (原子自增，不覆盖整行)
+	AddFunding(ctx context.Context, userID int64, symbol string, amount int64) error
+
+	// AddMargin 追加持仓保证金 (原子自增，不覆盖整行)，没有非空持仓时返回 ErrNoPosition
+	AddMargin(ctx context.Context, userID int64, symbol string, amount int64) error
 }
 
 // =============================================================================
@@ -145,6 +154,40 @@ func (r *CachedPositionRepository) Delete(ctx context.Context, userID int64, sym
 	return nil
 }
 
+// AddFunding 累加本轮资金费
+//
+// 【注意】不能用 Save：资金费结算拿的是分页快照，期间撮合可能已经更新了持仓，
+// 整行写回会覆盖成交结果。这里只对单列做 UPDATE ... SET x = x + ?，并删缓存让下次读回源
+func (r *CachedPositionRepository) AddFunding(ctx context.Context, userID int64, symbol string, amount int64) error {
+	err := r.db.WithContext(ctx).
+		Model(&Position{}).
+		Where("user_id = ? AND symbol = ? AND size != 0", userID, symbol).
+		UpdateColumn("cycle_funding", gorm.Expr("cycle_funding + ?", amount)).Error
+	if err != nil {
+		return err
+	}
+	r.redis.Del(ctx, positionKey(userID, symbol))
+	return nil
+}
+
+// AddMargin 追加持仓保证金，与 AddFunding 同理只改单列
+//
+// 持仓已清零时不写 (0 行)，返回 ErrNoPosition 让调用方退回已冻结的资金
+func (r *CachedPositionRepository) AddMargin(ctx context.Context, userID int64, symbol string, amount int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&Position{}).
+		Where("user_id = ? AND symbol = ? AND size != 0", userID, symbol).
+		UpdateColumn("margin", gorm.Expr("margin + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoPosition
+	}
+	r.redis.Del(ctx, positionKey(userID, symbol))
+	return nil
+}
+
 func (r *CachedPositionRepository) cachePosition(ctx context.Context, pos *Position) {
 	key := positionKey(pos.UserID, pos.Symbol)
 	data, _ := json.Marshal(pos)
@@ -169,3 +212,24 @@ func (r *CachedPositionRepository) ListBySymbol(
 
 	return positions, err
 }
+
+// ListBySymbolAfter 按 ID 游标分页 (资金费结算用)
+//
+// 【面试】为什么不用 OFFSET?
+// 结算过程中有用户平仓 (size 变 0 被过滤掉)，OFFSET 分页后面的行整体前移，会漏掉用户；
+// 按主键游标翻页不受影响，游标本身还能持久化做断点续跑
+func (r *CachedPositionRepository) ListBySymbolAfter(
+	ctx context.Context,
+	symbol string,
+	afterID uint,
+	limit int,
+) ([]*Position, error) {
+	var positions []*Position
+	err := r.db.WithContext(ctx).
+		Where("symbol = ? AND size != 0 AND id > ?", symbol, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&positions).Error
+
+	return positions, err
+}
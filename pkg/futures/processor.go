@@ -8,20 +8,49 @@ import (
 	"errors"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"max.com/pkg/account"
+	"max.com/pkg/audit"
+	"max.com/pkg/calendar"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/emergency"
+	"max.com/pkg/epoch"
+	"max.com/pkg/featureflag"
+	"max.com/pkg/fixed"
 	"max.com/pkg/fund"
 	"max.com/pkg/mtrade"
 	"max.com/pkg/nats"
 	"max.com/pkg/order"
+	"max.com/pkg/risk/limits"
 )
 
 var (
-	ErrInsufficientMargin = errors.New("insufficient margin")
-	ErrInvalidLeverage    = errors.New("invalid leverage")
-	ErrContractNotTrading = errors.New("contract not trading")
+	ErrInsufficientMargin = cexerr.New("FUTURES_INSUFFICIENT_MARGIN", cexerr.CategoryInsufficientFunds, "insufficient margin")
+	ErrInvalidLeverage    = cexerr.New("FUTURES_INVALID_LEVERAGE", cexerr.CategoryInvalidArgument, "invalid leverage")
+	ErrContractNotTrading = cexerr.New("FUTURES_CONTRACT_NOT_TRADING", cexerr.CategoryFailedPrecondition, "contract not trading")
+	ErrNoPosition         = cexerr.New("FUTURES_NO_POSITION", cexerr.CategoryNotFound, "no position")
+	ErrNoMarkPrice        = cexerr.NewRetryable("FUTURES_NO_MARK_PRICE", cexerr.CategoryUnavailable, "no mark price available")
+	ErrSubmitOrderFailed  = cexerr.NewRetryable("FUTURES_SUBMIT_ORDER_FAILED", cexerr.CategoryUnavailable, "submit order to matching engine failed")
+
+	ErrDuplicateClientOrderID = cexerr.New("FUTURES_DUPLICATE_CLIENT_ORDER_ID", cexerr.CategoryConflict, "duplicate client order id")
+	ErrInvalidClientOrderID   = cexerr.New("FUTURES_INVALID_CLIENT_ORDER_ID", cexerr.CategoryInvalidArgument, "client order id too long")
 )
 
+// MarginLedger 处理器用到的冷钱包余额操作，*fund.BalanceRepo 实现
+//
+// 【设计】抽成接口只为测试能注入内存账本 (见 harness_test.go)，生产环境始终是 MySQL。
+// 余额变动一律带流水 (幂等键见 margin_journal.go)
+type MarginLedger interface {
+	GetBalance(ctx context.Context, userID int64, symbol string) (*fund.BalanceRecord, error)
+	FreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error)
+	UnfreezeWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error)
+	AddAvailableWithJournal(ctx context.Context, userID int64, symbol string, amount int64, ref fund.JournalRef) (*fund.JournalRecord, bool, error)
+}
+
+var _ MarginLedger = (*fund.BalanceRepo)(nil)
+
 // =============================================================================
 // FuturesProcessor - 合约交易处理器
 // =============================================================================
@@ -29,7 +58,7 @@ var (
 // FuturesProcessor 合约交易处理器
 //
 // 【职责】
-// 1. 开仓: 检查冷钱包余额 → 冻结冷钱包 → 提交撮合
+// 1. 开仓: 检查冷钱包余额 → 冻结冷钱包 → 提交撮合 (可选走事务 outbox，见 order_outbox.go)
 // 2. 成交: 更新持仓 + 发布 NATS 事件
 // 3. 撤单: 发布 NATS 事件
 // 4. 风险计算: 实时计算 PnL、强平价格、风险等级
@@ -40,16 +69,39 @@ var (
 // - 风险模块 (risk/perp): 集成用于 PnL 和强平计算
 type FuturesProcessor struct {
 	contractManager  *ContractManager
-	matchEngine      *mtrade.Engine // TODO: 生产环境改为 gRPC 客户端
+	engines          mtrade.EngineRouter // 交易对 → 撮合引擎 (进程内或远程，见 mtrade/router.go)
 	positionRepo     PositionRepository
 	orderService     *order.OrderService
-	balanceRepo      *fund.BalanceRepo // 冷钱包余额 (MySQL)
-	riskCalculator   *RiskCalculator   // 风险计算器
-	markPriceService *MarkPriceService // 标记价格服务
-	publisher        *nats.Publisher   // NATS 事件发布器 (可选)
+	balanceRepo      MarginLedger              // 冷钱包余额 (MySQL)
+	riskCalculator   *RiskCalculator           // 风险计算器
+	markPriceService *MarkPriceService         // 标记价格服务
+	publisher        *nats.Publisher           // NATS 事件发布器 (可选)
+	riskLimits       *limits.Service           // 下单前风控 (可选)
+	auditor          audit.Recorder            // 审计 (可选，见 audit.go)
+	accounts         account.Provider          // 账户状态 (可选)：受限账户只能平仓
+	positionHistory  PositionHistoryRepository // 历史持仓 (可选，见 position_history.go)
+	outbox           *OrderOutboxRelay         // 开仓 outbox (可选，见 order_outbox.go)
+	maxSlippage      int64                     // 市价单默认最大滑点 (万分比，见 market_order.go)
+	flags            *featureflag.Flags        // 功能开关 (可选，见 feature_flags.go)
+	calendar         *calendar.Service         // 交易日历 (可选)：休市 / 维护中拒单
+	collateral       *CollateralService        // 多币种抵押品估值 (可选，见 collateral.go)
+	emergency        *emergency.Control        // 全站停机 / 用户暂停 (可选)
+	guard            *ExecutionGuard           // 最优执行保护 (可选，见 execution_guard.go)
 
 	// 订单元数据缓存
 	orderMetas sync.Map
+
+	// 撤单完成通知 (orderID -> chan struct{})，撤单事件处理完 (保证金已解冻) 后关闭
+	cancelWaiters sync.Map
+
+	now          func() time.Time     // 时钟 (测试可注入)
+	eventHandled []func(mtrade.Event) // 事件处理完成回调
+
+	// 纪元栅栏：丢弃已被切换掉的撮合实例发来的事件
+	fence       epoch.Fence
+	staleEvents atomic.Uint64
+
+	warming atomic.Bool // 启动预热中，开仓入口关闭 (见 warmup.go)
 }
 
 // ClosePositionRequest 平仓请求
@@ -57,7 +109,11 @@ type ClosePositionRequest struct {
 	UserID int64
 	Symbol string
 	Qty    int64 // 平仓数量，0 表示全部平仓
-	Price  int64 // 限价，0 表示市价
+	Price  int64 // 限价，0 表示市价 (带保护价的 IOC，见 market_order.go)
+
+	MaxSlippage int64 // 市价单最大滑点 (万分比)，0 使用处理器默认值
+
+	ClientOrderID string // 客户端订单号 (可选)，同一用户在去重窗口内唯一
 }
 
 func NewFuturesProcessor(
@@ -65,26 +121,111 @@ func NewFuturesProcessor(
 	matchEngine *mtrade.Engine,
 	positionRepo PositionRepository,
 	orderService *order.OrderService,
-	balanceRepo *fund.BalanceRepo,
+	balanceRepo MarginLedger,
+) *FuturesProcessor {
+	return NewRoutedFuturesProcessor(contractManager, mtrade.SingleEngine(matchEngine), positionRepo, orderService, balanceRepo)
+}
+
+// NewRoutedFuturesProcessor 多引擎部署：按交易对路由到不同的撮合引擎
+func NewRoutedFuturesProcessor(
+	contractManager *ContractManager,
+	engines mtrade.EngineRouter,
+	positionRepo PositionRepository,
+	orderService *order.OrderService,
+	balanceRepo MarginLedger,
 ) *FuturesProcessor {
 	p := &FuturesProcessor{
 		contractManager:  contractManager,
-		matchEngine:      matchEngine,
+		engines:          engines,
 		positionRepo:     positionRepo,
 		orderService:     orderService,
 		balanceRepo:      balanceRepo,
 		riskCalculator:   NewRiskCalculator(),
 		markPriceService: NewMarkPriceService(),
+		maxSlippage:      DefaultMaxSlippage,
+		now:              time.Now,
 	}
-	matchEngine.OnEvent(p.handleEvent)
+	engines.Subscribe(p.handleEvent, mtrade.HandlerOptions{Name: "futures-processor"})
+	reserveRecoveredIDs(engines)
 	return p
 }
 
+// reserveRecoveredIDs 订单 ID 由本处理器生成 (order.GenerateOrderID)，
+// 从各引擎 WAL 恢复出的最大订单 ID 之上开始，避免时钟回拨后撞上恢复出的挂单
+//
+// 远程引擎拿不到高水位，由撮合服务入口的重复 ID 拒单兜底 (mtrade.RejectDuplicateOrderID)
+func reserveRecoveredIDs(engines mtrade.EngineRouter) {
+	for _, c := range engines.Engines() {
+		if r, ok := c.(interface{ RecoveredIDs() mtrade.IDWatermark }); ok {
+			order.AdvanceOrderID(r.RecoveredIDs().OrderID)
+		}
+	}
+}
+
 // SetPublisher 设置 NATS 发布器
 func (p *FuturesProcessor) SetPublisher(publisher *nats.Publisher) {
 	p.publisher = publisher
 }
 
+// SetRiskLimits 设置下单前风控
+func (p *FuturesProcessor) SetRiskLimits(riskLimits *limits.Service) {
+	p.riskLimits = riskLimits
+}
+
+// SetAccountStatus 设置账户状态检查 (KYC/封禁)
+func (p *FuturesProcessor) SetAccountStatus(accounts account.Provider) {
+	p.accounts = accounts
+}
+
+// SetTradingCalendar 设置交易日历，休市 / 维护中开平仓都在入口拒单，不冻结保证金
+//
+// 引擎按同一日历暂停 (calendar.Service.Bind)，入口检查只是提前拒掉，避免冻结再解冻
+func (p *FuturesProcessor) SetTradingCalendar(c *calendar.Service) {
+	p.calendar = c
+}
+
+// SetEmergency 设置紧急控制：停机时开平仓都拒绝，被暂停的用户只能平仓和撤单
+func (p *FuturesProcessor) SetEmergency(c *emergency.Control) {
+	p.emergency = c
+}
+
+// SetCollateral 设置多币种抵押品估值：抵押品计入权益和风险率 (见 collateral.go)
+func (p *FuturesProcessor) SetCollateral(c *CollateralService) {
+	p.collateral = c
+}
+
+// SetExecutionGuard 设置最优执行保护：市价单限价超出指数 / 标记价格边界时转成边界价限价单
+func (p *FuturesProcessor) SetExecutionGuard(g *ExecutionGuard) {
+	p.guard = g
+}
+
+// SetOrderOutbox 开仓改走事务 outbox：冻结 + 写订单 + outbox 同一事务，由中继提交撮合
+func (p *FuturesProcessor) SetOrderOutbox(relay *OrderOutboxRelay) {
+	relay.submit = p.submitOutboxOrder
+	p.outbox = relay
+}
+
+// SetMarkPriceService 多个处理器共用一个标记价格服务
+//
+// 可开仓保证金要按用户全部持仓算浮亏 (见 account_margin.go)，
+// 每个处理器只知道自己合约的标记价格时，有其他合约持仓的用户会因缺标记价格被拒单
+func (p *FuturesProcessor) SetMarkPriceService(s *MarkPriceService) {
+	p.markPriceService = s
+}
+
+// SetClock 替换时钟，持仓 / 事件时间戳都取自这里
+func (p *FuturesProcessor) SetClock(now func() time.Time) {
+	p.now = now
+}
+
+// OnEventHandled 注册事件处理完成回调，在处理器的 handler 协程里同步调用
+//
+// 【用途】测试据此等待成交/撤单落地，替代 time.Sleep
+// 【注意】须在提交订单之前注册；回调返回后 event 内的池化对象会被回收，不要持有指针
+func (p *FuturesProcessor) OnEventHandled(fn func(mtrade.Event)) {
+	p.eventHandled = append(p.eventHandled, fn)
+}
+
 // GetRiskCalculator 获取风险计算器
 func (p *FuturesProcessor) GetRiskCalculator() *RiskCalculator {
 	return p.riskCalculator
@@ -113,15 +254,24 @@ func (p *FuturesProcessor) GetPositionWithRisk(ctx context.Context, userID int64
 		markPrice = pos.EntryPrice // 无标记价格时用开仓价
 	}
 
-	// 获取用户余额
-	balance, _ := p.balanceRepo.GetBalance(ctx, userID, "USDT")
+	// 获取用户余额 (结算币种：正向合约 USDT，反向合约为基础币)
+	spec, _ := p.contractManager.GetContract(ctx, symbol)
+	currency := "USDT"
+	if spec != nil {
+		currency = spec.SettleCurrency
+	}
+	balance, _ := p.balanceRepo.GetBalance(ctx, userID, currency)
 	var balanceAmount int64
 	if balance != nil {
 		balanceAmount = balance.Available + balance.Locked
 	}
+	// 其他币种抵押品折算后计入余额 (见 collateral.go)，估值失败时不计入
+	if collateral, err := collateralValue(ctx, p.collateral, userID, currency); err == nil {
+		balanceAmount += collateral
+	}
 
 	// 计算风险
-	risk := p.riskCalculator.CalculatePositionRisk(pos, markPrice, balanceAmount)
+	risk := p.riskCalculator.CalculatePositionRiskWithSpec(spec, pos, markPrice, balanceAmount)
 
 	return &PositionWithRisk{
 		Position:     pos,
@@ -129,20 +279,54 @@ func (p *FuturesProcessor) GetPositionWithRisk(ctx context.Context, userID int64
 	}, nil
 }
 
+// OpenNotional 当前持仓价值，结算币种计价 (实现 limits.ExposureProvider)
+// 查询失败时返回错误，风控据此拒单
+func (p *FuturesProcessor) OpenNotional(ctx context.Context, userID int64, symbol string) (int64, error) {
+	pos, err := p.positionRepo.GetByUserAndSymbol(ctx, userID, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if pos == nil || pos.Size == 0 {
+		return 0, nil
+	}
+
+	spec, _ := p.contractManager.GetContract(ctx, symbol) // 查不到按正向合约
+
+	markPrice := p.markPriceService.GetMarkPrice(symbol)
+	if markPrice == 0 {
+		markPrice = pos.EntryPrice // 无标记价格时用开仓价
+	}
+	return spec.PositionValue(pos.AbsSize(), markPrice), nil
+}
+
 // =============================================================================
 // 开仓
 // =============================================================================
 
 type OpenPositionRequest struct {
+	OrderID  int64 // 可选：调用方指定订单 ID (算法单需要按 ID 归集成交)，0 表示自动生成
 	UserID   int64
 	Symbol   string
 	Side     Side
 	Qty      int64
-	Price    int64
+	Price    int64 // 限价；市价单忽略，按标记价格和 MaxSlippage 计算保护价
 	Leverage int
+
+	Type        OrderType // 默认限价单
+	MaxSlippage int64     // 市价单最大滑点 (万分比)，0 使用处理器默认值
+	ExpireAt    int64     // 限价单到期时间 (GTD，unix ms)，0 使用合约最长存活，见 order_expiry.go
+
+	ClientOrderID string // 客户端订单号 (可选)，同一用户在去重窗口内唯一，重试时带同一个值
 }
 
 func (p *FuturesProcessor) OpenPosition(ctx context.Context, req *OpenPositionRequest) error {
+	if p.warming.Load() {
+		return ErrWarmingUp
+	}
+	if err := p.emergency.CheckOpen(req.UserID); err != nil {
+		return err
+	}
+
 	// 1. 获取合约规格
 	spec, err := p.contractManager.GetContract(ctx, req.Symbol)
 	if err != nil {
@@ -151,79 +335,314 @@ func (p *FuturesProcessor) OpenPosition(ctx context.Context, req *OpenPositionRe
 	if !spec.IsTrading() {
 		return ErrContractNotTrading
 	}
+	if err := p.checkCalendar(spec.Symbol); err != nil {
+		return err
+	}
 
 	// 2. 验证杠杆
 	if req.Leverage <= 0 || req.Leverage > spec.MaxLeverage {
 		return ErrInvalidLeverage
 	}
+	if err := p.checkClientOrderID(req.UserID, req.ClientOrderID); err != nil {
+		return err
+	}
+
+	if p.accounts != nil {
+		if err := account.CheckOpen(ctx, p.accounts, req.UserID); err != nil {
+			return err
+		}
+	}
 
 	// 3. 计算保证金
-	positionValue := req.Qty * req.Price / Precision
+	// 正向合约按 USDT 计，反向合约按基础币计 (见 ContractSpec.PositionValue)
+	// 市价单以保护价下单，保证金按最坏成交价估算 (见 market_order.go)；
+	// 保护价超出执行边界时转成边界价限价单 (见 execution_guard.go)
+	orderType := req.Type
+	price, marginPrice := req.Price, req.Price
+	var expireAt int64
+	var bound executionBound
+	var converted bool
+	if req.Type == OrderTypeMarket {
+		if err := p.flags.Check(FlagMarketOrder, spec.Symbol, req.UserID); err != nil {
+			return err
+		}
+		price, marginPrice, err = p.resolveMarketPrice(spec, req.Side, req.Qty, req.MaxSlippage)
+		if err == nil {
+			bound, converted, err = p.guard.check(spec, GuardMarketOpen, req.Side, price)
+		}
+		if converted {
+			orderType, marginPrice = OrderTypeLimit, bound.price
+			expireAt, err = p.orderExpireAt(spec, 0)
+		}
+	} else {
+		expireAt, err = p.orderExpireAt(spec, req.ExpireAt)
+	}
+	if err != nil {
+		return err
+	}
+	requested := price
+	if converted {
+		price = bound.price
+	}
+	positionValue := spec.PositionValue(req.Qty, marginPrice)
 	requiredMargin := positionValue / int64(req.Leverage)
 
-	// 4. 冻结冷钱包余额 (MySQL)
-	balance, err := p.balanceRepo.GetBalance(ctx, req.UserID, spec.SettleCurrency)
+	// 风控检查 (冻结之前，拒单无需回滚)
+	if p.riskLimits != nil {
+		err := p.riskLimits.Check(ctx, p, limits.Request{
+			UserID:   req.UserID,
+			Symbol:   req.Symbol,
+			Notional: positionValue,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// 4. 检查可开仓保证金 (扣除持仓浮亏，见 account_margin.go)，再冻结冷钱包余额 (MySQL)
+	margin, err := p.AccountMargin(ctx, req.UserID, spec.SettleCurrency)
 	if err != nil {
 		return err
 	}
-	if balance == nil || balance.Available < requiredMargin {
-		return ErrInsufficientMargin
-	}
-	if err := p.balanceRepo.FreezeBalance(ctx, req.UserID, spec.SettleCurrency, requiredMargin); err != nil {
+	if margin.AvailableForOrders < requiredMargin {
 		return ErrInsufficientMargin
 	}
 
 	// 5. 生成订单ID (雪花算法)
-	orderID := order.GenerateOrderID()
+	orderID := req.OrderID
+	if orderID == 0 {
+		orderID = order.GenerateOrderID()
+	}
+
+	if p.outbox != nil {
+		if err := p.openViaOutbox(ctx, req, spec, orderID, orderType, price, requiredMargin, expireAt); err != nil {
+			return err
+		}
+		if converted {
+			p.guard.emit(GuardMarketOpen, req.UserID, orderID, spec, req.Side, requested, bound)
+		}
+		return nil
+	}
+
+	if _, _, err := p.balanceRepo.FreezeWithJournal(ctx, req.UserID, spec.SettleCurrency, requiredMargin, marginFreezeRef(orderID)); err != nil {
+		if errors.Is(err, fund.ErrInsufficientAvailable) {
+			return ErrInsufficientMargin
+		}
+		return err
+	}
 
 	// 6. 创建订单记录 (同步写DB)
-	err = p.orderService.CreateFuturesOrder(
-		ctx,
-		orderID,
-		req.UserID,
-		req.Symbol,
-		toOrderSide(req.Side),
-		req.Price,
-		req.Qty,
-		req.Leverage,
-		requiredMargin,
-	)
-	if err != nil {
+	ord := order.NewFuturesOrder(orderID, req.UserID, req.Symbol, toOrderSide(req.Side),
+		price, req.Qty, req.Leverage, requiredMargin)
+	ord.OrderType = orderType.recordType()
+	if err = p.orderService.CreateOrder(ctx, ord); err != nil {
 		// 回滚冷钱包冻结
-		p.balanceRepo.UnfreezeBalance(ctx, req.UserID, spec.SettleCurrency, requiredMargin)
+		p.balanceRepo.UnfreezeWithJournal(ctx, req.UserID, spec.SettleCurrency, requiredMargin, marginReleaseRef(orderID))
 		return err
 	}
 
 	// 7. 构建撮合订单
 	matchOrder := &mtrade.Order{
-		ID:     orderID,
-		UserID: req.UserID,
-		Symbol: req.Symbol,
-		Side:   toMtradeSide(req.Side),
-		Type:   mtrade.OrderTypeLimit,
-		Price:  req.Price,
-		Qty:    req.Qty,
-	}
-
-	// 8. 提交撮合 (TODO: 生产环境改为 gRPC 调用)
-	if !p.matchEngine.SubmitOrder(matchOrder) {
-		// 回滚冷钱包冻结
-		p.balanceRepo.UnfreezeBalance(ctx, req.UserID, spec.SettleCurrency, requiredMargin)
-		// TODO: 更新订单状态为 REJECTED
-		return errors.New("submit order failed")
+		ID:            orderID,
+		UserID:        req.UserID,
+		Symbol:        req.Symbol,
+		Side:          toMtradeSide(req.Side),
+		Type:          orderType.matchOrderType(),
+		Price:         price,
+		Qty:           req.Qty,
+		ClientOrderID: req.ClientOrderID,
 	}
 
-	// 9. 保存元数据 (用于成交回调)
-	p.orderMetas.Store(orderID, &OrderMeta{
+	// 8. 保存元数据 (用于成交回调)
+	// 【注意】必须先于提交撮合：吃单可能在 SubmitOrder 返回前就成交，晚存会丢成交回调
+	meta := &OrderMeta{
 		UserID:   req.UserID,
 		Symbol:   req.Symbol,
 		Side:     req.Side,
+		Type:     orderType,
 		Qty:      req.Qty,
-		Price:    req.Price,
+		Price:    price,
 		Leverage: req.Leverage,
 		Margin:   requiredMargin,
+		ExpireAt: expireAt,
+	}
+	p.orderMetas.Store(orderID, meta)
+
+	// 9. 提交撮合
+	if !mtrade.RouteSubmit(p.engines, matchOrder) {
+		p.orderMetas.Delete(orderID)
+		// 回滚冷钱包冻结
+		p.balanceRepo.UnfreezeWithJournal(ctx, req.UserID, spec.SettleCurrency, requiredMargin, marginReleaseRef(orderID))
+		// TODO: 更新订单状态为 REJECTED
+		return ErrSubmitOrderFailed
+	}
+	p.auditOrder(audit.ActionOrderPlace, req.UserID, orderID, meta, nil)
+	if converted {
+		p.guard.emit(GuardMarketOpen, req.UserID, orderID, spec, req.Side, requested, bound)
+	}
+
+	return nil
+}
+
+// openViaOutbox 冻结 + 写订单 + 写 outbox 一个事务提交，唤醒中继提交撮合
+//
+// 返回 nil 只代表已受理：撮合队列一直满的话，订单会被回收 (REJECTED，保证金解冻)
+func (p *FuturesProcessor) openViaOutbox(
+	ctx context.Context,
+	req *OpenPositionRequest,
+	spec *ContractSpec,
+	orderID int64,
+	orderType OrderType,
+	price, requiredMargin, expireAt int64,
+) error {
+	now := p.now().UnixMilli()
+	msg := &OrderOutbox{
+		OrderID:       orderID,
+		UserID:        req.UserID,
+		Symbol:        req.Symbol,
+		Currency:      spec.SettleCurrency,
+		Side:          req.Side,
+		Type:          orderType,
+		Price:         price,
+		Qty:           req.Qty,
+		Leverage:      req.Leverage,
+		Margin:        requiredMargin,
+		ExpireAt:      expireAt,
+		ClientOrderID: req.ClientOrderID,
+		Status:        OutboxPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	ord := order.NewFuturesOrder(orderID, req.UserID, req.Symbol, toOrderSide(req.Side),
+		price, req.Qty, req.Leverage, requiredMargin)
+	ord.OrderType = orderType.recordType()
+	if err := p.outbox.store.Place(ctx, msg, ord); err != nil {
+		return err
+	}
+	p.outbox.Notify()
+	return nil
+}
+
+// submitOutboxOrder 中继回调：提交撮合，返回 false 表示撮合队列满
+//
+// 元数据要在提交前保存，否则撮合很快回来的成交事件找不到订单
+func (p *FuturesProcessor) submitOutboxOrder(o *OrderOutbox) bool {
+	meta := &OrderMeta{
+		UserID:   o.UserID,
+		Symbol:   o.Symbol,
+		Side:     o.Side,
+		Type:     o.Type,
+		Qty:      o.Qty,
+		Price:    o.Price,
+		Leverage: o.Leverage,
+		Margin:   o.Margin,
+		ExpireAt: o.ExpireAt,
+	}
+	p.orderMetas.Store(o.OrderID, meta)
+
+	ok := mtrade.RouteSubmit(p.engines, &mtrade.Order{
+		ID:            o.OrderID,
+		UserID:        o.UserID,
+		Symbol:        o.Symbol,
+		Side:          toMtradeSide(o.Side),
+		Type:          o.Type.matchOrderType(),
+		Price:         o.Price,
+		Qty:           o.Qty,
+		ClientOrderID: o.ClientOrderID,
+	})
+	if !ok {
+		p.orderMetas.Delete(o.OrderID)
+		return false
+	}
+	p.auditOrder(audit.ActionOrderPlace, o.UserID, o.OrderID, meta, nil)
+	return true
+}
+
+// CancelOrder 撤单 (异步)，保证金在撤单事件中解冻
+// 返回 false 表示撮合撤单队列已满
+// 订单元数据不在 (如强平单) 时发给每个撮合引擎
+func (p *FuturesProcessor) CancelOrder(orderID int64) bool {
+	var meta *OrderMeta
+	symbol := ""
+	if v, ok := p.orderMetas.Load(orderID); ok {
+		meta = v.(*OrderMeta)
+		symbol = meta.Symbol
+	}
+	if !mtrade.RouteCancel(p.engines, symbol, orderID) {
+		return false
+	}
+	if meta != nil {
+		p.auditOrder(audit.ActionOrderCancel, meta.UserID, orderID, nil, nil)
+	}
+	return true
+}
+
+// CancelUserOrders 撤掉用户在本合约上的全部挂单 (强平前调用)，
+// 等撤单事件处理完、冻结的保证金解冻后再返回撤掉的订单 ID
+//
+// 等待只针对本处理器登记过的订单：撤单前先按订单元数据登记通知，
+// 撮合回执里其他来源的订单 (如强平单) 不等
+func (p *FuturesProcessor) CancelUserOrders(ctx context.Context, userID int64) ([]int64, error) {
+	waiters := make(map[int64]chan struct{})
+	p.orderMetas.Range(func(key, val any) bool {
+		if val.(*OrderMeta).UserID == userID {
+			id := key.(int64)
+			ch := make(chan struct{})
+			p.cancelWaiters.Store(id, ch)
+			waiters[id] = ch
+		}
+		return true
 	})
+	defer func() {
+		for id := range waiters {
+			p.cancelWaiters.Delete(id) // 没被撤掉的 (还在撮合队列里) 不再等
+		}
+	}()
 
+	ids, err := mtrade.RouteCancelAllByUser(ctx, p.engines, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		ch, ok := waiters[id]
+		if !ok {
+			continue
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ids, ctx.Err()
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("[Futures] Canceled %d open orders of user %d", len(ids), userID)
+	}
+	return ids, nil
+}
+
+// CancelByClientOrderID 按客户端订单号撤单 (异步)
+// 返回 false 表示订单不存在 (或已过去重窗口且不在簿上) 或撮合撤单队列已满
+func (p *FuturesProcessor) CancelByClientOrderID(userID int64, clientOrderID string) bool {
+	orderID, ok := mtrade.RouteLookupClientOrder(p.engines, userID, clientOrderID)
+	if !ok {
+		return false
+	}
+	return p.CancelOrder(orderID)
+}
+
+// checkClientOrderID 冻结保证金之前挡掉重复的 clientOid
+//
+// 只是快速路径：还在撮合队列里的同号订单查不到，最终由撮合引擎拒单 (拒单事件解冻保证金)
+func (p *FuturesProcessor) checkClientOrderID(userID int64, clientOrderID string) error {
+	if clientOrderID == "" {
+		return nil
+	}
+	if len(clientOrderID) > mtrade.MaxClientOrderIDLen {
+		return ErrInvalidClientOrderID
+	}
+	if _, ok := mtrade.RouteLookupClientOrder(p.engines, userID, clientOrderID); ok {
+		return ErrDuplicateClientOrderID
+	}
 	return nil
 }
 
@@ -239,12 +658,41 @@ func toOrderSide(side Side) order.OrderSide {
 // 成交处理
 // =============================================================================
 
+// AdvanceEpoch 主备切换时由控制面调用，之后旧纪元的事件一律丢弃
+func (p *FuturesProcessor) AdvanceEpoch(epoch uint64) {
+	p.fence.Advance(epoch)
+}
+
+// StaleEvents 因纪元过旧被丢弃的事件数
+func (p *FuturesProcessor) StaleEvents() uint64 {
+	return p.staleEvents.Load()
+}
+
 func (p *FuturesProcessor) handleEvent(event mtrade.Event) {
+	defer p.notifyHandled(event)
+
+	// 旧纪元的成交会重复开平仓，直接丢弃
+	if err := p.fence.Admit(event.Epoch); err != nil {
+		p.staleEvents.Add(1)
+		return
+	}
+
 	switch event.Type {
 	case mtrade.EventTrade:
 		p.handleTrade(event.Trade)
+	case mtrade.EventOrderAccepted, mtrade.EventOrderRejected:
+		p.handleOrderStatus(event.Order)
 	case mtrade.EventOrderCanceled:
-		p.handleCancel(event.Order)
+		p.handleCancel(event.Order, event.OrderSeq)
+		if ch, ok := p.cancelWaiters.LoadAndDelete(event.Order.ID); ok {
+			close(ch.(chan struct{}))
+		}
+	}
+}
+
+func (p *FuturesProcessor) notifyHandled(event mtrade.Event) {
+	for _, fn := range p.eventHandled {
+		fn(event)
 	}
 }
 
@@ -272,6 +720,12 @@ func (p *FuturesProcessor) handleTrade(trade *mtrade.Trade) {
 			"price":          trade.Price,
 			"qty":            trade.Qty,
 			"timestamp":      trade.Timestamp,
+			// 双方订单 / 用户的事件序号，消费方据此判重、重排 (见 mtrade/event_seq.go)
+			"seq_session":    trade.TakerSeq.Session,
+			"taker_seq":      trade.TakerSeq.Order,
+			"maker_seq":      trade.MakerSeq.Order,
+			"taker_user_seq": trade.TakerSeq.User,
+			"maker_user_seq": trade.MakerSeq.User,
 		}
 		// 添加 Taker 信息
 		if takerMeta != nil {
@@ -303,10 +757,15 @@ func (p *FuturesProcessor) applyFill(orderID int64, trade *mtrade.Trade) {
 	// 获取合约规格
 	spec, _ := p.contractManager.GetContract(ctx, meta.Symbol)
 
+	// 一笔订单可能分多笔成交 (部分成交、市价单扫多档)，每笔只处理对应份额的保证金
+	margin := meta.takeMargin(spec, trade.Qty, trade.Price)
+
 	// ========== 平仓单处理 ==========
 	if meta.IsClose {
-		p.handleCloseFill(ctx, spec, meta, trade)
-		p.orderMetas.Delete(orderID)
+		p.handleCloseFill(ctx, spec, orderID, meta, trade, margin)
+		if meta.done() {
+			p.finishOrder(orderID, meta)
+		}
 		return
 	}
 
@@ -318,7 +777,7 @@ func (p *FuturesProcessor) applyFill(orderID int64, trade *mtrade.Trade) {
 		pos = &Position{
 			UserID:    meta.UserID,
 			Symbol:    meta.Symbol,
-			CreatedAt: time.Now().UnixMilli(),
+			CreatedAt: p.now().UnixMilli(),
 		}
 	}
 
@@ -327,10 +786,11 @@ func (p *FuturesProcessor) applyFill(orderID int64, trade *mtrade.Trade) {
 		fillQty = -fillQty
 	}
 
-	p.updatePosition(pos, fillQty, trade.Price, meta.Margin, meta.Leverage, isNewPosition)
+	p.updatePosition(spec, pos, fillQty, trade.Price, margin, meta.Leverage, isNewPosition)
 	p.positionRepo.Save(ctx, pos)
-	p.orderMetas.Delete(orderID)
-
+	if meta.done() {
+		p.finishOrder(orderID, meta)
+	}
 }
 
 // handleCloseFill 处理平仓成交
@@ -343,8 +803,10 @@ func (p *FuturesProcessor) applyFill(orderID int64, trade *mtrade.Trade) {
 func (p *FuturesProcessor) handleCloseFill(
 	ctx context.Context,
 	spec *ContractSpec,
+	orderID int64,
 	meta *OrderMeta,
 	trade *mtrade.Trade,
+	margin int64, // 本笔成交释放的持仓保证金
 ) {
 	// 1. 获取当前持仓
 	pos, err := p.positionRepo.GetByUserAndSymbol(ctx, meta.UserID, meta.Symbol)
@@ -360,21 +822,20 @@ func (p *FuturesProcessor) handleCloseFill(
 	//
 	// 【面试】为什么用 meta.OriginalEntry 而不是 pos.EntryPrice?
 	// 因为可能有多笔成交，第一笔成交后 pos.EntryPrice 会变
-	var realizedPnL int64
-	if meta.OriginalSize > 0 {
-		// 原本是多头
-		realizedPnL = (trade.Price - meta.OriginalEntry) * int64(trade.Qty) / Precision
-	} else {
-		// 原本是空头
-		realizedPnL = (meta.OriginalEntry - trade.Price) * int64(trade.Qty) / Precision
+	//
+	// 反向合约按 张数 × 面值 × (1/开仓价 − 1/平仓价) 计算，见 ContractSpec.PnL
+	closedSize := int64(trade.Qty)
+	if meta.OriginalSize < 0 {
+		closedSize = -closedSize // 原本是空头
 	}
+	realizedPnL := spec.PnL(closedSize, meta.OriginalEntry, trade.Price)
 
 	log.Printf("[Futures] User %d close position: qty=%d, price=%d, entry=%d, PnL=%d",
 		meta.UserID, trade.Qty, trade.Price, meta.OriginalEntry, realizedPnL)
 
 	// 3. 结算到余额: 释放保证金 + 盈亏
 	// 结算金额 = 释放的保证金 + 已实现盈亏
-	settlementAmount := meta.Margin + realizedPnL
+	settlementAmount := margin + realizedPnL
 
 	// 穿仓保护: 最少返还 0
 	if settlementAmount < 0 {
@@ -384,7 +845,7 @@ func (p *FuturesProcessor) handleCloseFill(
 	}
 
 	if settlementAmount > 0 && spec != nil {
-		p.balanceRepo.AddAvailable(ctx, meta.UserID, spec.SettleCurrency, settlementAmount)
+		p.balanceRepo.AddAvailableWithJournal(ctx, meta.UserID, spec.SettleCurrency, settlementAmount, marginSettleRef(orderID, trade.ID))
 	}
 
 	// 4. 更新持仓
@@ -397,11 +858,11 @@ func (p *FuturesProcessor) handleCloseFill(
 		pos.Size += closeQty
 	}
 
-	// 5. 更新已实现盈亏累计
-	pos.RealizedPnL += realizedPnL
+	// 5. 更新已实现盈亏累计 (含本轮平仓统计)
+	pos.recordClose(trade.Price, closeQty, realizedPnL)
 
 	// 6. 按比例减少保证金
-	pos.Margin -= meta.Margin
+	pos.Margin -= margin
 
 	// 7. 如果仓位清空
 	if pos.Size == 0 {
@@ -409,10 +870,17 @@ func (p *FuturesProcessor) handleCloseFill(
 		pos.EntryPrice = 0
 	}
 
-	pos.UpdatedAt = time.Now().UnixMilli()
+	pos.UpdatedAt = p.now().UnixMilli()
 
-	// 8. 保存持仓
+	// 8. 保存持仓，清零时写历史持仓
 	p.positionRepo.Save(ctx, pos)
+	if pos.Size == 0 {
+		side := SideLong
+		if meta.OriginalSize < 0 {
+			side = SideShort
+		}
+		recordPositionHistory(ctx, p.positionHistory, pos, side, meta.OriginalEntry, CloseReasonClose)
+	}
 
 	// 9. 发布平仓事件
 	if p.publisher != nil {
@@ -424,15 +892,16 @@ func (p *FuturesProcessor) handleCloseFill(
 			"close_price":   trade.Price,
 			"realized_pnl":  realizedPnL,
 			"remaining_pos": pos.Size,
-			"timestamp":     time.Now().UnixMilli(),
+			"timestamp":     p.now().UnixMilli(),
 		}
 		p.publisher.Publish("position.closed", event)
 	}
 }
 
-func (p *FuturesProcessor) updatePosition(pos *Position, deltaSize, price, margin int64, leverage int, isNew bool) PositionChangeType {
+func (p *FuturesProcessor) updatePosition(spec *ContractSpec, pos *Position, deltaSize, price, margin int64, leverage int, isNew bool) PositionChangeType {
 	if isNew || pos.Size == 0 {
 		// 新开仓
+		pos.startCycle(p.now().UnixMilli())
 		pos.Size = deltaSize
 		pos.EntryPrice = price
 		pos.Margin = margin
@@ -442,10 +911,8 @@ func (p *FuturesProcessor) updatePosition(pos *Position, deltaSize, price, margi
 
 	// 同向加仓
 	if (pos.Size > 0 && deltaSize > 0) || (pos.Size < 0 && deltaSize < 0) {
-		oldValue := pos.Size * pos.EntryPrice
-		newValue := deltaSize * price
+		pos.EntryPrice = spec.AvgEntryPrice(pos.Size, pos.EntryPrice, deltaSize, price)
 		pos.Size += deltaSize
-		pos.EntryPrice = (oldValue + newValue) / pos.Size
 		pos.Margin += margin
 		return PositionAdd
 	}
@@ -458,30 +925,29 @@ func (p *FuturesProcessor) updatePosition(pos *Position, deltaSize, price, margi
 	return PositionReduce
 }
 
-func (p *FuturesProcessor) handleCancel(order *mtrade.Order) {
+func (p *FuturesProcessor) handleCancel(order *mtrade.Order, seq mtrade.EventSeq) {
 	val, ok := p.orderMetas.Load(order.ID)
 	if !ok {
 		return
 	}
 	meta := val.(*OrderMeta)
 
-	spec, _ := p.contractManager.GetContract(context.Background(), meta.Symbol)
-
-	// 解冻冷钱包 (热钱包由撮合服务内部管理)
-	if spec != nil && p.balanceRepo != nil {
-		p.balanceRepo.UnfreezeBalance(context.Background(), meta.UserID, spec.SettleCurrency, meta.Margin)
-	}
-	p.orderMetas.Delete(order.ID)
+	// 解冻未成交部分的冷钱包保证金 (热钱包由撮合服务内部管理)
+	refund := p.finishOrder(order.ID, meta)
 
 	// 发布撤单事件到 NATS (包含完整信息)
 	if p.publisher != nil {
+		spec, _ := p.contractManager.GetContract(context.Background(), meta.Symbol)
 		event := map[string]any{
 			"order_id":        order.ID,
 			"user_id":         meta.UserID,
-			"margin":          meta.Margin,
+			"margin":          refund,
 			"settle_currency": spec.SettleCurrency,
-			"reason":          "user_cancel",
-			"timestamp":       time.Now().UnixMilli(),
+			"reason":          cancelReason(meta),
+			"timestamp":       p.now().UnixMilli(),
+			"seq_session":     seq.Session,
+			"seq":             seq.Order,
+			"user_seq":        seq.User,
 		}
 		p.publisher.Publish("order.canceled", event)
 	}
@@ -504,13 +970,22 @@ func (p *FuturesProcessor) handleCancel(order *mtrade.Order) {
 // Q: 平仓后保证金怎么处理？
 // A: 释放保证金到可用余额 + 盈亏结算
 func (p *FuturesProcessor) ClosePosition(ctx context.Context, req *ClosePositionRequest) error {
+	if err := p.emergency.CheckClose(req.UserID); err != nil {
+		return err
+	}
+
 	// 1. 获取用户持仓
 	pos, err := p.positionRepo.GetByUserAndSymbol(ctx, req.UserID, req.Symbol)
 	if err != nil {
 		return err
 	}
 	if pos == nil || pos.Size == 0 {
-		return errors.New("no position to close")
+		return ErrNoPosition
+	}
+	if p.accounts != nil {
+		if err := account.CheckClose(ctx, p.accounts, req.UserID); err != nil {
+			return err
+		}
 	}
 
 	// 2. 获取合约规格
@@ -521,6 +996,12 @@ func (p *FuturesProcessor) ClosePosition(ctx context.Context, req *ClosePosition
 	if !spec.IsTrading() {
 		return ErrContractNotTrading
 	}
+	if err := p.checkCalendar(spec.Symbol); err != nil {
+		return err
+	}
+	if err := p.checkClientOrderID(req.UserID, req.ClientOrderID); err != nil {
+		return err
+	}
 
 	// 3. 确定平仓数量
 	closeQty := req.Qty
@@ -539,69 +1020,84 @@ func (p *FuturesProcessor) ClosePosition(ctx context.Context, req *ClosePosition
 	}
 
 	// 5. 确定价格
+	// 市价平仓：按盘口深度定价挂 IOC，超出滑点上限的档位不吃，剩余撤销 (见 book_price.go)；
+	// 扫单价超出执行边界时转成边界价限价单 (见 execution_guard.go)
 	closePrice := req.Price
+	closeType := OrderTypeLimit
+	var expireAt, requested int64
+	var bound executionBound
+	var converted bool
 	if closePrice <= 0 {
-		// 市价单：使用标记价格作为参考
-		// 实际撮合时会使用订单簿最优价
-		closePrice = p.markPriceService.GetMarkPrice(req.Symbol)
-		if closePrice <= 0 {
-			return errors.New("no market price available")
+		closeType = OrderTypeMarket
+		closePrice, err = p.resolveClosePrice(spec, closeSide, closeQty, req.MaxSlippage)
+		if err == nil {
+			bound, converted, err = p.guard.check(spec, GuardMarketClose, closeSide, closePrice)
+		}
+		if converted {
+			requested, closePrice, closeType = closePrice, bound.price, OrderTypeLimit
+			expireAt, err = p.orderExpireAt(spec, 0)
 		}
+	} else {
+		expireAt, err = p.orderExpireAt(spec, 0) // 限价平仓单同样不能永久挂着
+	}
+	if err != nil {
+		return err
 	}
 
 	// 6. 计算应释放的保证金 (按比例)
 	// 如果平掉 50% 仓位，释放 50% 保证金
-	marginToRelease := pos.Margin * closeQty / pos.AbsSize()
+	marginToRelease := fixed.MulDiv(pos.Margin, closeQty, pos.AbsSize())
 
 	// 7. 生成订单ID
 	orderID := order.GenerateOrderID()
 
-	// 8. 创建平仓订单记录
-	err = p.orderService.CreateFuturesOrder(
-		ctx,
-		orderID,
-		req.UserID,
-		req.Symbol,
-		toOrderSide(closeSide),
-		closePrice,
-		closeQty,
-		pos.Leverage, // 沿用原杠杆
-		0,            // 平仓不需要新增保证金
-	)
-	if err != nil {
+	// 8. 创建平仓订单记录 (沿用原杠杆，平仓不需要新增保证金)
+	ord := order.NewFuturesOrder(orderID, req.UserID, req.Symbol, toOrderSide(closeSide),
+		closePrice, closeQty, pos.Leverage, 0)
+	ord.OrderType = closeType.recordType()
+	if err = p.orderService.CreateOrder(ctx, ord); err != nil {
 		return err
 	}
 
 	// 9. 构建撮合订单
 	matchOrder := &mtrade.Order{
-		ID:     orderID,
-		UserID: req.UserID,
-		Symbol: req.Symbol,
-		Side:   toMtradeSide(closeSide),
-		Type:   mtrade.OrderTypeLimit,
-		Price:  closePrice,
-		Qty:    closeQty,
-	}
-
-	// 10. 提交撮合
-	if !p.matchEngine.SubmitOrder(matchOrder) {
-		return errors.New("submit close order failed")
+		ID:            orderID,
+		UserID:        req.UserID,
+		Symbol:        req.Symbol,
+		Side:          toMtradeSide(closeSide),
+		Type:          closeType.matchOrderType(),
+		Price:         closePrice,
+		Qty:           closeQty,
+		ClientOrderID: req.ClientOrderID,
 	}
 
-	// 11. 保存订单元数据 (用于成交回调)
+	// 10. 保存订单元数据 (用于成交回调，先于提交撮合，见 OpenPosition)
 	// 【重要】IsClose = true 标记这是平仓单
-	p.orderMetas.Store(orderID, &OrderMeta{
+	meta := &OrderMeta{
 		UserID:        req.UserID,
 		Symbol:        req.Symbol,
 		Side:          closeSide,
+		Type:          closeType,
 		Qty:           closeQty,
 		Price:         closePrice,
 		Leverage:      pos.Leverage,
 		Margin:        marginToRelease,
+		ExpireAt:      expireAt,
 		IsClose:       true, // 🔑 平仓标记
 		OriginalSize:  pos.Size,
 		OriginalEntry: pos.EntryPrice,
-	})
+	}
+	p.orderMetas.Store(orderID, meta)
+
+	// 11. 提交撮合
+	if !mtrade.RouteSubmit(p.engines, matchOrder) {
+		p.orderMetas.Delete(orderID)
+		return ErrSubmitOrderFailed
+	}
+	p.auditOrder(audit.ActionOrderPlace, req.UserID, orderID, meta, map[string]string{"reduce_only": "true"})
+	if converted {
+		p.guard.emit(GuardMarketClose, req.UserID, orderID, spec, closeSide, requested, bound)
+	}
 
 	return nil
 }
@@ -614,10 +1110,21 @@ type OrderMeta struct {
 	UserID   int64
 	Symbol   string
 	Side     Side
+	Type     OrderType
 	Qty      int64
 	Price    int64
 	Leverage int
-	Margin   int64
+	Margin   int64 // 开仓: 冻结的保证金；平仓: 要释放的持仓保证金
+
+	// 成交进度 (见 market_order.go)
+	FilledQty  int64 // 已处理的成交数量
+	MarginUsed int64 // 已转入持仓 (开仓) / 已释放 (平仓) 的保证金
+	Closed     bool  // 剩余部分已撤销或被拒 (IOC / 价格带)
+	FinalQty   int64 // Closed 时的最终成交数量
+
+	// 挂单到期 (见 order_expiry.go)
+	ExpireAt int64       // 到期时间 (unix ms)，0 表示不过期 (市价单)
+	expiring atomic.Bool // 已提交到期撤单
 
 	// 平仓相关
 	IsClose       bool  // 是否是平仓单
@@ -626,6 +1133,14 @@ type OrderMeta struct {
 
 }
 
+// checkCalendar 交易日历检查，未设置日历时始终放行
+func (p *FuturesProcessor) checkCalendar(symbol string) error {
+	if p.calendar == nil {
+		return nil
+	}
+	return p.calendar.CheckOpen(symbol)
+}
+
 func toMtradeSide(side Side) mtrade.Side {
 	if side == SideLong {
 		return mtrade.SideBuy
@@ -18,6 +18,7 @@ import (
 
 	"max.com/pkg/asset"
 	"max.com/pkg/fund"
+	"max.com/pkg/migrate"
 	"max.com/pkg/mtrade"
 	"max.com/pkg/nats"
 	"max.com/pkg/order"
@@ -27,9 +28,11 @@ import (
 // 测试配置
 // =============================================================================
 
+// 依赖外部 MySQL/Redis/NATS，连不上时跳过；不依赖外部服务的链路测试见 harness_test.go
 const (
 	testDSN      = "root:123456@tcp(127.0.0.1:3307)/my_cex?charset=utf8mb4&parseTime=True&loc=Local"
 	testRedisURL = "localhost:6379"
+	testNatsURL  = "nats://localhost:4222"
 )
 
 // =============================================================================
@@ -40,10 +43,15 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(mysql.Open(testDSN), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
-	require.NoError(t, err)
+	if err != nil {
+		t.Skipf("MySQL 不可用，跳过: %v", err)
+	}
 
-	// 自动迁移
-	db.AutoMigrate(&ContractSpec{}, &Position{}, &order.Order{})
+	// 建表走正式迁移，测试库和生产库表结构一致
+	m, err := migrate.New(db, migrate.Config{})
+	require.NoError(t, err)
+	_, err = m.Up(context.Background(), 0)
+	require.NoError(t, err)
 
 	return db
 }
@@ -52,10 +60,44 @@ func setupTestRedis(t *testing.T) *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
 		Addr: testRedisURL,
 	})
-	require.NoError(t, rdb.Ping(context.Background()).Err())
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis 不可用，跳过: %v", err)
+	}
 	return rdb
 }
 
+func setupTestNATS(t *testing.T) *nats.Publisher {
+	publisher, err := nats.NewPublisher(testNatsURL)
+	if err != nil {
+		t.Skipf("NATS 不可用，跳过: %v", err)
+	}
+	t.Cleanup(publisher.Close)
+	return publisher
+}
+
+// tradeSignals 处理器每处理完一笔成交发一个信号，替代 time.Sleep 等撮合
+func tradeSignals(p *FuturesProcessor) <-chan struct{} {
+	ch := make(chan struct{}, 64)
+	p.OnEventHandled(func(e mtrade.Event) {
+		if e.Type == mtrade.EventTrade {
+			ch <- struct{}{}
+		}
+	})
+	return ch
+}
+
+func waitSignals(t *testing.T, ch <-chan struct{}, n int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for ; n > 0; n-- {
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatalf("timeout waiting for %d trades", n)
+		}
+	}
+}
+
 func setupMatchEngine(t *testing.T) *mtrade.Engine {
 	engine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig("TESTBTCUSDT"))
 	require.NoError(t, err)
@@ -203,15 +245,13 @@ func TestMatchTrade(t *testing.T) {
 		contractManager, matchEngine, positionRepo, orderService, balanceRepo,
 	)
 
+	trades := tradeSignals(processor)
+
 	// 设置 NATS 发布器
-	natsURL := "nats://localhost:4222"
-	publisher, err := nats.NewPublisher(natsURL)
-	require.NoError(t, err)
-	defer publisher.Close()
-	processor.SetPublisher(publisher)
+	processor.SetPublisher(setupTestNATS(t))
 
 	// 启动 OrderConsumer 监听成交事件
-	orderConsumer, err := order.NewOrderConsumer(orderService, natsURL)
+	orderConsumer, err := order.NewOrderConsumer(orderService, testNatsURL)
 	require.NoError(t, err)
 	err = orderConsumer.Start()
 	require.NoError(t, err)
@@ -250,7 +290,7 @@ func TestMatchTrade(t *testing.T) {
 	t.Log("买家下单成功")
 
 	// 等待撮合处理
-	time.Sleep(200 * time.Millisecond)
+	waitSignals(t, trades, 1)
 
 	// 验证买家持仓
 	buyerPos, err := positionRepo.GetByUserAndSymbol(ctx, buyer, "TESTBTCUSDT")
@@ -380,7 +420,6 @@ func TestFullTradeFlow(t *testing.T) {
 	db := setupTestDB(t)
 	rdb := setupTestRedis(t)
 	ctx := context.Background()
-	natsURL := "nats://localhost:4222"
 
 	// ===== 1. 初始化所有组件 =====
 	contractRepo := NewCachedContractRepository(NewMySQLContractRepository(db), rdb)
@@ -397,20 +436,19 @@ func TestFullTradeFlow(t *testing.T) {
 		contractManager, matchEngine, positionRepo, orderService, balanceRepo,
 	)
 
+	trades := tradeSignals(processor)
+
 	// NATS 发布器
-	publisher, err := nats.NewPublisher(natsURL)
-	require.NoError(t, err)
-	defer publisher.Close()
-	processor.SetPublisher(publisher)
+	processor.SetPublisher(setupTestNATS(t))
 
 	// 订单状态消费者
-	orderConsumer, err := order.NewOrderConsumer(orderService, natsURL)
+	orderConsumer, err := order.NewOrderConsumer(orderService, testNatsURL)
 	require.NoError(t, err)
 	orderConsumer.Start()
 	defer orderConsumer.Stop()
 
 	// 冷钱包写入器 (消费成交事件，写入流水，更新余额)
-	dbWriter, err := fund.NewNatsDBWriter(balanceRepo, natsURL)
+	dbWriter, err := fund.NewNatsDBWriter(balanceRepo, testNatsURL)
 	require.NoError(t, err)
 	err = dbWriter.Start()
 	require.NoError(t, err)
@@ -491,8 +529,8 @@ func TestFullTradeFlow(t *testing.T) {
 	assert.Greater(t, coldBuyerAfter.Locked, int64(0))
 	t.Log("✅ 买家余额冻结正确")
 
-	// ===== 6. 等待撮合 + NATS 事件处理 =====
-	time.Sleep(500 * time.Millisecond)
+	// ===== 6. 等待撮合 =====
+	waitSignals(t, trades, 1)
 
 	// ===== 7. 验证持仓 =====
 	buyerPos, _ := positionRepo.GetByUserAndSymbol(ctx, buyer, "TESTBTCUSDT")
@@ -642,7 +680,7 @@ func setupTestDBForBench(b *testing.B) *gorm.DB {
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
-		b.Fatalf("连接数据库失败: %v", err)
+		b.Skipf("MySQL 不可用，跳过: %v", err)
 	}
 	return db
 }
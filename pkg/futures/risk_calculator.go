@@ -82,7 +82,16 @@ func NewRiskCalculator() *RiskCalculator {
 //   - balance: 账户可用余额 (用于计算风险率)
 //
 // 返回: 风险计算结果
+//
+// 按正向合约 (U 本位) 计算，反向合约用 CalculatePositionRiskWithSpec
 func (c *RiskCalculator) CalculatePositionRisk(pos *Position, markPrice int64, balance int64) *PositionRisk {
+	return c.CalculatePositionRiskWithSpec(nil, pos, markPrice, balance)
+}
+
+// CalculatePositionRiskWithSpec 按合约规格计算风险指标
+//
+// 反向合约 (spec.Inverse) 的余额、盈亏、保证金需求都以基础币计价；spec 为 nil 时按正向合约
+func (c *RiskCalculator) CalculatePositionRiskWithSpec(spec *ContractSpec, pos *Position, markPrice int64, balance int64) *PositionRisk {
 	if pos == nil || pos.Size == 0 {
 		return nil
 	}
@@ -98,15 +107,27 @@ func (c *RiskCalculator) CalculatePositionRisk(pos *Position, markPrice int64, b
 
 	// 调用 risk/perp 计算
 	balanceFloat := float64(balance) / float64(Precision)
-	metrics := perp.CalculateRisk(perpPos, balanceFloat)
-
-	// 计算强平价格
-	liqPrice := perp.CalculateLiquidationPrice(
-		perpPos.Qty,
-		perpPos.EntryPrice,
-		balanceFloat,
-		c.maintenanceRate,
-	)
+	var metrics perp.RiskMetrics
+	var liqPrice float64
+	if spec != nil && spec.Inverse {
+		contractSize := float64(spec.ContractSize) / float64(Precision)
+		metrics = perp.CalculateInverseRisk(perpPos, contractSize, balanceFloat)
+		liqPrice = perp.CalculateInverseLiquidationPrice(
+			perpPos.Qty,
+			contractSize,
+			perpPos.EntryPrice,
+			balanceFloat,
+			c.maintenanceRate,
+		)
+	} else {
+		metrics = perp.CalculateRisk(perpPos, balanceFloat)
+		liqPrice = perp.CalculateLiquidationPrice(
+			perpPos.Qty,
+			perpPos.EntryPrice,
+			balanceFloat,
+			c.maintenanceRate,
+		)
+	}
 
 	// 转换回 int64 精度
 	result := &PositionRisk{
@@ -19,6 +19,11 @@
 //
 // Q: 结算价为什么用均价而不是最新价？
 // A: 防止操纵价格套利，均价更难被操控
+//
+// Q: 交割跑到一半失败，重跑会不会重复返还？
+// A: 不会。每个 (合约, 到期时间, 用户) 一条资金流水，与交割明细同一事务写入，
+//    重跑时已返还的用户被流水幂等键挡住 (见 fund.ApplySettlement)；
+//    结算价以首次交割写入的主记录为准 (见 settlement_store.go)
 
 package futures
 
@@ -26,9 +31,11 @@ import (
 	"context"
 	"errors"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
+	"max.com/pkg/cexerr"
 	"max.com/pkg/fund"
 )
 
@@ -37,10 +44,12 @@ import (
 // =============================================================================
 
 var (
-	ErrContractNotExpired   = errors.New("contract has not expired yet")
-	ErrContractNotSettling  = errors.New("contract is not in settling status")
-	ErrNoPositionsToSettle  = errors.New("no positions to settle")
-	ErrSettlementInProgress = errors.New("settlement already in progress")
+	ErrContractNotExpired   = cexerr.New("FUTURES_CONTRACT_NOT_EXPIRED", cexerr.CategoryFailedPrecondition, "contract has not expired yet")
+	ErrContractNotSettling  = cexerr.New("FUTURES_CONTRACT_NOT_SETTLING", cexerr.CategoryFailedPrecondition, "contract is not in settling status")
+	ErrNoPositionsToSettle  = cexerr.New("FUTURES_NO_POSITIONS_TO_SETTLE", cexerr.CategoryNotFound, "no positions to settle")
+	ErrNotSettled           = cexerr.New("FUTURES_NOT_SETTLED", cexerr.CategoryNotFound, "contract not settled")
+	ErrNoSettlementPrice    = cexerr.NewRetryable("FUTURES_NO_SETTLEMENT_PRICE", cexerr.CategoryUnavailable, "no settlement price")
+	ErrSettlementInProgress = cexerr.NewRetryable("FUTURES_SETTLEMENT_IN_PROGRESS", cexerr.CategoryConflict, "settlement already in progress")
 )
 
 // =============================================================================
@@ -92,6 +101,8 @@ type SettlementEngine struct {
 	positionRepo     PositionRepository
 	balanceRepo      *fund.BalanceRepo
 	markPriceService *MarkPriceService
+	positionHistory  PositionHistoryRepository // 历史持仓 (可选，见 position_history.go)
+	store            SettlementStore           // 交割记录 (可选，见 settlement_store.go)
 
 	// 状态
 	running  bool
@@ -122,6 +133,13 @@ func NewSettlementEngine(
 	}
 }
 
+// SetStore 设置交割记录存储
+//
+// 设置后每次交割写主记录和用户明细，重跑复用首次的结算价，并可通过报告接口查询
+func (e *SettlementEngine) SetStore(store SettlementStore) {
+	e.store = store
+}
+
 // =============================================================================
 // 生命周期
 // =============================================================================
@@ -199,7 +217,7 @@ func (e *SettlementEngine) scanExpiredContracts() {
 		// 检查是否到期
 		if spec.IsExpired(now) {
 			log.Printf("[Settlement] Contract %s expired, starting settlement", spec.Symbol)
-			go e.settleContract(ctx, spec.Symbol)
+			go e.settleContract(ctx, spec.Symbol, false)
 		}
 	}
 }
@@ -209,8 +227,11 @@ func (e *SettlementEngine) scanExpiredContracts() {
 // =============================================================================
 
 // SettleContract 手动触发合约交割 (公开方法)
-func (e *SettlementEngine) SettleContract(ctx context.Context, symbol string) error {
-	return e.settleContract(ctx, symbol)
+//
+// dryRun=true 时按当前标记价格预演交割：不检查到期、不切换合约状态、
+// 不修改余额和持仓，只返回每个持仓的盈亏与返还金额
+func (e *SettlementEngine) SettleContract(ctx context.Context, symbol string, dryRun bool) (*SettlementReport, error) {
+	return e.settleContract(ctx, symbol, dryRun)
 }
 
 // settleContract 执行合约交割
@@ -222,58 +243,109 @@ func (e *SettlementEngine) SettleContract(ctx context.Context, symbol string) er
 // 4. 获取结算价
 // 5. 分批处理持仓
 // 6. 完成交割: 状态 -> SETTLED
-func (e *SettlementEngine) settleContract(ctx context.Context, symbol string) error {
-	// 1. 检查是否已在交割中
-	if _, loaded := e.settlingContracts.LoadOrStore(symbol, true); loaded {
-		return ErrSettlementInProgress
+//
+// 【dry-run】跳过 1、3、6 步和第 2 步的到期检查，第 5 步只计算不落账
+func (e *SettlementEngine) settleContract(ctx context.Context, symbol string, dryRun bool) (*SettlementReport, error) {
+	// 1. 检查是否已在交割中 (dry-run 只读，不占锁)
+	if !dryRun {
+		if _, loaded := e.settlingContracts.LoadOrStore(symbol, true); loaded {
+			return nil, ErrSettlementInProgress
+		}
+		defer e.settlingContracts.Delete(symbol)
 	}
-	defer e.settlingContracts.Delete(symbol)
 
 	// 2. 获取合约规格
 	spec, err := e.contractManager.GetContract(ctx, symbol)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// 3. 检查合约状态
-	now := time.Now().UnixMilli()
-	if !spec.IsExpired(now) {
-		return ErrContractNotExpired
-	}
+	if dryRun {
+		if spec.Status != StatusTrading && spec.Status != StatusSettling {
+			return nil, ErrContractNotSettling
+		}
+	} else {
+		now := time.Now().UnixMilli()
+		if !spec.IsExpired(now) {
+			return nil, ErrContractNotExpired
+		}
 
-	// 4. 切换状态: TRADING -> SETTLING
-	if spec.Status == StatusTrading {
-		if err := e.contractManager.StartSettlement(ctx, symbol); err != nil {
-			return err
+		// 4. 切换状态: TRADING -> SETTLING
+		if spec.Status == StatusTrading {
+			if err := e.contractManager.StartSettlement(ctx, symbol); err != nil {
+				return nil, err
+			}
+			log.Printf("[Settlement] %s status changed to SETTLING", symbol)
+		} else if spec.Status != StatusSettling {
+			return nil, ErrContractNotSettling
 		}
-		log.Printf("[Settlement] %s status changed to SETTLING", symbol)
-	} else if spec.Status != StatusSettling {
-		return ErrContractNotSettling
 	}
 
 	// 5. 获取结算价
 	// 【重要】结算价通常是到期前1小时的TWAP (Time-Weighted Average Price)
 	// 这里简化为使用当前标记价格
-	settlementPrice := e.getSettlementPrice(symbol)
+	// 【注意】重跑时沿用主记录里的结算价，否则同一合约的用户会按不同价格交割
+	var record *SettlementRecord
+	if !dryRun && e.store != nil {
+		if record, err = e.store.Get(ctx, symbol, spec.ExpiryAt); err != nil {
+			return nil, err
+		}
+	}
+	var settlementPrice int64
+	if record != nil {
+		settlementPrice = record.SettlementPrice
+	} else {
+		settlementPrice = e.getSettlementPrice(symbol)
+	}
 	if settlementPrice <= 0 {
 		log.Printf("[Settlement] %s: no settlement price available", symbol)
-		return errors.New("no settlement price")
+		return nil, ErrNoSettlementPrice
+	}
+	if !dryRun && e.store != nil && record == nil {
+		record, err = e.store.Begin(ctx, &SettlementRecord{
+			Symbol:          symbol,
+			ExpiryAt:        spec.ExpiryAt,
+			SettlementPrice: settlementPrice,
+			Status:          SettlementStatusRunning,
+			StartedAt:       time.Now().UnixMilli(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		settlementPrice = record.SettlementPrice
 	}
-	log.Printf("[Settlement] %s settlement price: %d", symbol, settlementPrice)
+	log.Printf("[Settlement] %s settlement price: %d, dryRun=%v", symbol, settlementPrice, dryRun)
 
 	// 6. 批量结算所有持仓
-	if err := e.settleAllPositions(ctx, spec, settlementPrice); err != nil {
+	report := &SettlementReport{Symbol: symbol, DryRun: dryRun, SettlementPrice: settlementPrice}
+	err = e.settleAllPositions(ctx, spec, settlementPrice, record, report)
+	sort.Slice(report.Entries, func(i, j int) bool {
+		return report.Entries[i].UserID < report.Entries[j].UserID
+	})
+	if err == nil && !dryRun {
+		// 7. 切换状态: SETTLING -> SETTLED
+		err = e.contractManager.FinishSettlement(ctx, symbol)
+	}
+	if record != nil {
+		status, errMsg := SettlementStatusSuccess, ""
+		if err != nil {
+			status, errMsg = SettlementStatusFailed, err.Error()
+		}
+		if ferr := e.store.Finish(ctx, record, status, errMsg); ferr != nil {
+			log.Printf("[Settlement] %s: failed to finish settlement record: %v", symbol, ferr)
+		}
+	}
+	if err != nil {
 		log.Printf("[Settlement] %s failed: %v", symbol, err)
-		return err
+		return report, err
 	}
-
-	// 7. 切换状态: SETTLING -> SETTLED
-	if err := e.contractManager.FinishSettlement(ctx, symbol); err != nil {
-		return err
+	if dryRun {
+		return report, nil
 	}
 
-	log.Printf("[Settlement] %s completed successfully", symbol)
-	return nil
+	log.Printf("[Settlement] %s completed successfully (skipped %d already settled)", symbol, report.SkippedCount)
+	return report, nil
 }
 
 // getSettlementPrice 获取结算价
@@ -298,17 +370,22 @@ func (e *SettlementEngine) getSettlementPrice(symbol string) int64 {
 // settleAllPositions 结算所有持仓
 //
 // 【设计】分批处理，避免一次性加载太多数据
+//
+// 【注意】按持仓 ID 游标分页，不能用 OFFSET：结算过的持仓 Size 置 0 后
+// 不再出现在 size != 0 的结果里，OFFSET 会跳过后面一批
 func (e *SettlementEngine) settleAllPositions(
 	ctx context.Context,
 	spec *ContractSpec,
 	settlementPrice int64,
+	record *SettlementRecord,
+	report *SettlementReport,
 ) error {
-	var offset int
+	var afterID uint
 	totalSettled := 0
 
 	for {
 		// 分批获取持仓
-		positions, err := e.positionRepo.ListBySymbol(ctx, spec.Symbol, e.config.BatchSize, offset)
+		positions, err := e.positionRepo.ListBySymbolAfter(ctx, spec.Symbol, afterID, e.config.BatchSize)
 		if err != nil {
 			return err
 		}
@@ -318,13 +395,13 @@ func (e *SettlementEngine) settleAllPositions(
 		}
 
 		// 并行处理这一批
-		settled, err := e.settleBatch(ctx, spec, positions, settlementPrice)
+		settled, err := e.settleBatch(ctx, spec, positions, settlementPrice, record, report)
 		if err != nil {
 			return err
 		}
 
 		totalSettled += settled
-		offset += len(positions)
+		afterID = positions[len(positions)-1].ID
 
 		log.Printf("[Settlement] %s: settled %d positions, total %d",
 			spec.Symbol, len(positions), totalSettled)
@@ -340,6 +417,8 @@ func (e *SettlementEngine) settleBatch(
 	spec *ContractSpec,
 	positions []*Position,
 	settlementPrice int64,
+	record *SettlementRecord,
+	report *SettlementReport,
 ) (int, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -361,13 +440,14 @@ func (e *SettlementEngine) settleBatch(
 			defer wg.Done()
 			defer func() { <-sem }() // 释放信号量
 
-			err := e.settlePosition(ctx, spec, p, settlementPrice)
+			entry, err := e.settlePosition(ctx, spec, p, settlementPrice, record, report.DryRun)
 
 			mu.Lock()
 			if err != nil {
 				errors = append(errors, err)
 			} else {
 				settled++
+				report.add(entry)
 			}
 			mu.Unlock()
 		}(pos)
@@ -389,52 +469,96 @@ func (e *SettlementEngine) settleBatch(
 // 3. 结算盈亏: 盈利加到余额，亏损从余额扣除
 // 4. 清空持仓: Size = 0, Margin = 0
 // 5. 记录交割流水
+//
+// 【幂等】2、3、5 在同一事务里完成 (fund.ApplySettlement)，已返还过的用户只清空持仓，
+// 不再入账，entry.Skipped = true
+//
+// dryRun=true 时只计算明细，不修改余额和持仓
 func (e *SettlementEngine) settlePosition(
 	ctx context.Context,
 	spec *ContractSpec,
 	pos *Position,
 	settlementPrice int64,
-) error {
+	record *SettlementRecord,
+	dryRun bool,
+) (SettlementEntry, error) {
 	// 1. 计算盈亏
 	// 多头: PnL = (结算价 - 开仓价) × 数量
 	// 空头: PnL = (开仓价 - 结算价) × 数量 = -(结算价 - 开仓价) × (-数量)
 	// 统一公式: PnL = (结算价 - 开仓价) × Size / Precision
-	pnl := (settlementPrice - pos.EntryPrice) * pos.Size / Precision
+	// 反向合约: PnL = Size × 面值 × (1/开仓价 - 1/结算价)，以基础币结算
+	pnl := spec.PnL(pos.Size, pos.EntryPrice, settlementPrice)
 
 	// 2. 结算金额 = 保证金 + 盈亏
 	// 如果亏损超过保证金，结算金额可能为负 (穿仓)
 	settlementAmount := pos.Margin + pnl
+	entry := SettlementEntry{
+		UserID:     pos.UserID,
+		Size:       pos.Size,
+		EntryPrice: pos.EntryPrice,
+		Margin:     pos.Margin,
+		PnL:        pnl,
+	}
 	if settlementAmount < 0 {
 		// 穿仓情况: 用户亏得比保证金还多
 		// 生产环境应该从保险基金扣除
 		log.Printf("[Settlement] WARNING: user %d position %s has negative settlement: %d (穿仓)",
 			pos.UserID, spec.Symbol, settlementAmount)
+		entry.Shortfall = -settlementAmount
 		settlementAmount = 0 // 最多亏光保证金
 	}
+	entry.SettlementAmount = settlementAmount
+	if dryRun {
+		return entry, nil
+	}
 
 	// 3. 更新用户余额
 	// 释放保证金 + 结算盈亏 = 直接增加可用余额
-	if settlementAmount > 0 {
-		err := e.balanceRepo.AddAvailable(ctx, pos.UserID, spec.SettleCurrency, settlementAmount)
-		if err != nil {
-			return err
+	// 穿仓 (金额为 0) 也写一条流水，标记该用户已交割
+	var detail any
+	if record != nil {
+		detail = &SettlementDetail{
+			SettlementID:     record.ID,
+			UserID:           pos.UserID,
+			Symbol:           spec.Symbol,
+			Side:             pos.Side(),
+			Size:             pos.AbsSize(),
+			EntryPrice:       pos.EntryPrice,
+			SettlementPrice:  settlementPrice,
+			Margin:           pos.Margin,
+			PnL:              pnl,
+			SettlementAmount: settlementAmount,
+			Shortfall:        entry.Shortfall,
+			CreatedAt:        time.Now().UnixMilli(),
 		}
 	}
+	applied, duplicate, err := e.balanceRepo.ApplySettlement(
+		ctx, pos.UserID, spec.SettleCurrency, spec.Symbol, spec.ExpiryAt, settlementAmount, detail)
+	if err != nil {
+		return entry, err
+	}
+	if duplicate {
+		// 上次交割已返还，但持仓没来得及清空
+		entry.Skipped = true
+		entry.SettlementAmount = applied
+	}
 
 	// 4. 更新持仓 (记录已实现盈亏，清空持仓)
-	pos.RealizedPnL += pnl
+	side, entryPrice := pos.Side(), pos.EntryPrice
+	pos.recordClose(settlementPrice, pos.AbsSize(), pnl)
 	pos.Size = 0
 	pos.Margin = 0
 	pos.UpdatedAt = time.Now().UnixMilli()
 
 	if err := e.positionRepo.Save(ctx, pos); err != nil {
-		return err
+		return entry, err
 	}
+	recordPositionHistory(ctx, e.positionHistory, pos, side, entryPrice, CloseReasonSettlement)
 
 	log.Printf("[Settlement] User %d position %s settled: PnL=%d, Amount=%d",
 		pos.UserID, spec.Symbol, pnl, settlementAmount)
 
-	return nil
+	return entry, nil
 }
 
 // =============================================================================
@@ -9,16 +9,27 @@ import "time"
 // 交割记录
 // =============================================================================
 
+// 交割主记录状态
+const (
+	SettlementStatusRunning = "RUNNING"
+	SettlementStatusSuccess = "SUCCESS"
+	SettlementStatusFailed  = "FAILED"
+)
+
 // SettlementRecord 交割记录 (存储到 MySQL)
 //
-// 每次交割产生一条主记录记录
+// 每个交割合约一条主记录：首次交割时创建，结算价以首次为准，失败重跑复用同一条记录；
+// 汇总字段在交割结束时按明细表重新统计，包含所有重跑批次
 type SettlementRecord struct {
 	ID              uint   `gorm:"primaryKey;autoIncrement"`
-	Symbol          string `gorm:"column:symbol;type:varchar(32);index"`
-	SettlementPrice int64  `gorm:"column:settlement_price"` // 结算价
-	TotalPositions  int    `gorm:"column:total_positions"`  // 结算的持仓数
-	TotalPnL        int64  `gorm:"column:total_pnl"`        // 总盈亏
-	Status          string `gorm:"column:status"`           // SUCCESS / FAILED
+	Symbol          string `gorm:"column:symbol;type:varchar(32);uniqueIndex:uk_symbol_expiry,priority:1"`
+	ExpiryAt        int64  `gorm:"column:expiry_at;uniqueIndex:uk_symbol_expiry,priority:2"` // 到期时间
+	SettlementPrice int64  `gorm:"column:settlement_price"`                                  // 结算价
+	TotalPositions  int    `gorm:"column:total_positions"`                                   // 结算的持仓数
+	TotalPnL        int64  `gorm:"column:total_pnl"`                                         // 总盈亏
+	TotalReturned   int64  `gorm:"column:total_returned"`                                    // 返还给用户的总金额
+	TotalShortfall  int64  `gorm:"column:total_shortfall"`                                   // 穿仓总额
+	Status          string `gorm:"column:status"`                                            // RUNNING / SUCCESS / FAILED
 	StartedAt       int64  `gorm:"column:started_at"`
 	FinishedAt      int64  `gorm:"column:finished_at"`
 	ErrorMsg        string `gorm:"column:error_msg;type:text"`
@@ -34,11 +45,12 @@ func (SettlementRecord) TableName() string {
 
 // SettlementDetail 用户交割明细
 //
-// 每个用户的每个持仓产生一条明细
+// 每个用户的每个持仓产生一条明细，与返还资金的流水同一事务写入 (见 fund.ApplySettlement)，
+// 有明细就说明这个用户已经交割过
 type SettlementDetail struct {
 	ID               uint   `gorm:"primaryKey;autoIncrement"`
-	SettlementID     uint   `gorm:"column:settlement_id;index"` // 关联主记录
-	UserID           int64  `gorm:"column:user_id;index"`
+	SettlementID     uint   `gorm:"column:settlement_id;uniqueIndex:uk_settlement_user,priority:1"` // 关联主记录
+	UserID           int64  `gorm:"column:user_id;uniqueIndex:uk_settlement_user,priority:2;index"`
 	Symbol           string `gorm:"column:symbol;type:varchar(32)"`
 	Side             Side   `gorm:"column:side"`              // 持仓方向
 	Size             int64  `gorm:"column:size"`              // 持仓数量
@@ -47,6 +59,7 @@ type SettlementDetail struct {
 	Margin           int64  `gorm:"column:margin"`            // 占用保证金
 	PnL              int64  `gorm:"column:pnl"`               // 盈亏
 	SettlementAmount int64  `gorm:"column:settlement_amount"` // 结算金额 (返还给用户)
+	Shortfall        int64  `gorm:"column:shortfall"`         // 穿仓金额
 	CreatedAt        int64  `gorm:"column:created_at"`
 }
 
@@ -113,3 +126,43 @@ func NewUserSettlementEvent(pos *Position, settlementPrice, pnl, amount int64) *
 		Timestamp:        time.Now().UnixMilli(),
 	}
 }
+
+// =============================================================================
+// 交割报告 (支持 dry-run 预览)
+// =============================================================================
+
+// SettlementEntry 单个持仓的交割明细
+type SettlementEntry struct {
+	UserID           int64
+	Size             int64
+	EntryPrice       int64
+	Margin           int64
+	PnL              int64
+	SettlementAmount int64 // 返还到可用余额的金额 (穿仓时为 0)
+	Shortfall        int64 // 穿仓金额 (亏损超过保证金的部分)
+	Skipped          bool  // 之前的交割批次已返还过 (重跑)，本次未重复入账
+}
+
+// SettlementReport 一次交割的汇总
+type SettlementReport struct {
+	Symbol          string
+	DryRun          bool
+	SettlementPrice int64
+
+	Entries        []SettlementEntry // 按 UserID 排序
+	TotalPnL       int64
+	TotalReturned  int64 // 返还给用户的总金额
+	TotalShortfall int64 // 穿仓总额 (需保险基金承担)
+	SkippedCount   int   // 已返还过的持仓 (重跑时)，金额仍计入上面的合计
+}
+
+// add 记录一条明细并更新汇总 (调用方负责加锁)
+func (r *SettlementReport) add(entry SettlementEntry) {
+	r.Entries = append(r.Entries, entry)
+	if entry.Skipped {
+		r.SkippedCount++
+	}
+	r.TotalPnL += entry.PnL
+	r.TotalReturned += entry.SettlementAmount
+	r.TotalShortfall += entry.Shortfall
+}
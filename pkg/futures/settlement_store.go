@@ -0,0 +1,206 @@
+// 文件: pkg/futures/settlement_store.go
+// 交割记录存储 + 交割完成报告
+//
+// 【幂等】
+// - 主记录按 (symbol, expiry_at) 唯一：重跑复用同一条，结算价以首次为准
+// - 明细按 (settlement_id, user_id) 唯一，与返还资金流水同一事务写入 (fund.ApplySettlement)
+// - 真正挡住重复入账的是资金流水的幂等键，不依赖主记录是否存在
+//
+// 【对外暴露】
+// GET /futures/settlements?symbol=X                                交割完成报告
+// GET /futures/settlements?symbol=X&details=1&after_user_id=&limit= 附带用户明细
+
+package futures
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/cexerr"
+)
+
+// SettlementStore 交割记录存储
+type SettlementStore interface {
+	// Get 查询合约的交割主记录，不存在返回 nil
+	Get(ctx context.Context, symbol string, expiryAt int64) (*SettlementRecord, error)
+
+	// Begin 创建主记录；已存在时返回已有记录 (rec 中的结算价被忽略)
+	Begin(ctx context.Context, rec *SettlementRecord) (*SettlementRecord, error)
+
+	// Finish 按明细表重新统计汇总，写入状态
+	Finish(ctx context.Context, rec *SettlementRecord, status, errMsg string) error
+
+	// ListDetails 交割明细，按 user_id 升序游标分页
+	ListDetails(ctx context.Context, settlementID uint, afterUserID int64, limit int) ([]*SettlementDetail, error)
+}
+
+// 确保实现了接口
+var _ SettlementStore = (*MySQLSettlementStore)(nil)
+
+// MySQLSettlementStore MySQL 实现
+//
+// 【注意】明细与余额流水同一事务写入，settlement_details 必须和冷钱包余额表在同一个库
+type MySQLSettlementStore struct {
+	db *gorm.DB
+}
+
+// NewMySQLSettlementStore 创建交割记录存储
+func NewMySQLSettlementStore(db *gorm.DB) *MySQLSettlementStore {
+	return &MySQLSettlementStore{db: db}
+}
+
+// Get 查询主记录
+func (s *MySQLSettlementStore) Get(ctx context.Context, symbol string, expiryAt int64) (*SettlementRecord, error) {
+	var rec SettlementRecord
+	err := s.db.WithContext(ctx).
+		Where("symbol = ? AND expiry_at = ?", symbol, expiryAt).
+		First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Begin 创建或读取主记录
+func (s *MySQLSettlementStore) Begin(ctx context.Context, rec *SettlementRecord) (*SettlementRecord, error) {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(rec).Error
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.Get(ctx, rec.Symbol, rec.ExpiryAt)
+	if err != nil {
+		return nil, err
+	}
+	if cur == nil {
+		return nil, errors.New("settlement record vanished after insert")
+	}
+	return cur, nil
+}
+
+// Finish 统计明细并更新主记录
+func (s *MySQLSettlementStore) Finish(ctx context.Context, rec *SettlementRecord, status, errMsg string) error {
+	var sum struct {
+		Positions int
+		Pnl       int64
+		Returned  int64
+		Shortfall int64
+	}
+	err := s.db.WithContext(ctx).
+		Model(&SettlementDetail{}).
+		Select("COUNT(*) AS positions, COALESCE(SUM(pnl),0) AS pnl, "+
+			"COALESCE(SUM(settlement_amount),0) AS returned, COALESCE(SUM(shortfall),0) AS shortfall").
+		Where("settlement_id = ?", rec.ID).
+		Scan(&sum).Error
+	if err != nil {
+		return err
+	}
+
+	rec.TotalPositions = sum.Positions
+	rec.TotalPnL = sum.Pnl
+	rec.TotalReturned = sum.Returned
+	rec.TotalShortfall = sum.Shortfall
+	rec.Status = status
+	rec.ErrorMsg = errMsg
+	rec.FinishedAt = time.Now().UnixMilli()
+	return s.db.WithContext(ctx).
+		Model(&SettlementRecord{}).
+		Where("id = ?", rec.ID).
+		Updates(map[string]interface{}{
+			"total_positions": rec.TotalPositions,
+			"total_pnl":       rec.TotalPnL,
+			"total_returned":  rec.TotalReturned,
+			"total_shortfall": rec.TotalShortfall,
+			"status":          rec.Status,
+			"error_msg":       rec.ErrorMsg,
+			"finished_at":     rec.FinishedAt,
+		}).Error
+}
+
+// ListDetails 查询明细
+func (s *MySQLSettlementStore) ListDetails(ctx context.Context, settlementID uint, afterUserID int64, limit int) ([]*SettlementDetail, error) {
+	var list []*SettlementDetail
+	err := s.db.WithContext(ctx).
+		Where("settlement_id = ? AND user_id > ?", settlementID, afterUserID).
+		Order("user_id ASC").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+// =============================================================================
+// 交割完成报告接口
+// =============================================================================
+
+const (
+	defaultSettlementDetailLimit = 100
+	maxSettlementDetailLimit     = 1000
+)
+
+// SettlementReportResponse 交割完成报告
+type SettlementReportResponse struct {
+	Record  *SettlementRecord   `json:"record"`
+	Details []*SettlementDetail `json:"details,omitempty"`
+}
+
+// NewSettlementReportHandler 交割完成报告查询接口 (运维/对账)
+func NewSettlementReportHandler(store SettlementStore, contracts *ContractManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		spec, err := contracts.GetContract(r.Context(), q.Get("symbol"))
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		rec, err := store.Get(r.Context(), spec.Symbol, spec.ExpiryAt)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		if rec == nil {
+			cexerr.WriteHTTP(w, ErrNotSettled)
+			return
+		}
+
+		resp := SettlementReportResponse{Record: rec}
+		if q.Get("details") == "1" {
+			var afterUserID int64
+			if v := q.Get("after_user_id"); v != "" {
+				if afterUserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+					cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("after_user_id"))
+					return
+				}
+			}
+			limit := defaultSettlementDetailLimit
+			if v := q.Get("limit"); v != "" {
+				if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+					cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("limit"))
+					return
+				}
+			}
+			resp.Details, err = store.ListDetails(r.Context(), rec.ID, afterUserID, min(limit, maxSettlementDetailLimit))
+			if err != nil {
+				cexerr.WriteHTTP(w, err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
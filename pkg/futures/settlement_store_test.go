@@ -0,0 +1,96 @@
+// 文件: pkg/futures/settlement_store_test.go
+// 交割完成报告接口测试 (内存存储，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memSettlementStore struct {
+	records []*SettlementRecord
+	details []*SettlementDetail
+}
+
+func (s *memSettlementStore) Get(ctx context.Context, symbol string, expiryAt int64) (*SettlementRecord, error) {
+	for _, r := range s.records {
+		if r.Symbol == symbol && r.ExpiryAt == expiryAt {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memSettlementStore) Begin(ctx context.Context, rec *SettlementRecord) (*SettlementRecord, error) {
+	if cur, _ := s.Get(ctx, rec.Symbol, rec.ExpiryAt); cur != nil {
+		return cur, nil
+	}
+	rec.ID = uint(len(s.records) + 1)
+	s.records = append(s.records, rec)
+	return rec, nil
+}
+
+func (s *memSettlementStore) Finish(ctx context.Context, rec *SettlementRecord, status, errMsg string) error {
+	rec.Status, rec.ErrorMsg = status, errMsg
+	return nil
+}
+
+func (s *memSettlementStore) ListDetails(ctx context.Context, settlementID uint, afterUserID int64, limit int) ([]*SettlementDetail, error) {
+	var out []*SettlementDetail
+	for _, d := range s.details {
+		if d.SettlementID == settlementID && d.UserID > afterUserID && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func TestSettlementReportHandler(t *testing.T) {
+	manager, _, _ := dryRunFixture()
+	spec, err := manager.GetContract(context.Background(), dryRunSymbol)
+	require.NoError(t, err)
+
+	store := &memSettlementStore{}
+	handler := NewSettlementReportHandler(store, manager)
+	get := func(url string) (int, SettlementReportResponse) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		var resp SettlementReportResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	// 还没交割
+	code, _ := get("/futures/settlements?symbol=" + dryRunSymbol)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// 重跑复用首次的主记录和结算价
+	first, _ := store.Begin(context.Background(), &SettlementRecord{Symbol: dryRunSymbol, ExpiryAt: spec.ExpiryAt, SettlementPrice: 55000})
+	again, _ := store.Begin(context.Background(), &SettlementRecord{Symbol: dryRunSymbol, ExpiryAt: spec.ExpiryAt, SettlementPrice: 56000})
+	assert.Equal(t, int64(55000), again.SettlementPrice)
+	store.Finish(context.Background(), first, SettlementStatusSuccess, "")
+	for _, uid := range []int64{1, 2, 3} {
+		store.details = append(store.details, &SettlementDetail{SettlementID: first.ID, UserID: uid})
+	}
+
+	code, resp := get("/futures/settlements?symbol=" + dryRunSymbol)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, SettlementStatusSuccess, resp.Record.Status)
+	assert.Empty(t, resp.Details)
+
+	_, resp = get("/futures/settlements?symbol=" + dryRunSymbol + "&details=1&after_user_id=1&limit=1")
+	require.Len(t, resp.Details, 1)
+	assert.Equal(t, int64(2), resp.Details[0].UserID)
+
+	code, _ = get("/futures/settlements?symbol=" + dryRunSymbol + "&details=1&limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("/futures/settlements?symbol=NOPE")
+	assert.Equal(t, http.StatusNotFound, code)
+}
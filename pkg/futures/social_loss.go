@@ -0,0 +1,284 @@
+// 文件: pkg/futures/social_loss.go
+// 穿仓分摊 (Socialized Loss)
+//
+// 【问题】强平成交价劣于破产价时由保险基金兜底，保险基金也不够、又没有 ADL 时，
+// 剩下的亏损没人承担：盈利方已经拿到了全额盈利，平台账上凭空少了一块
+//
+// 【做法】
+//   - 强平回调登记保险基金未覆盖的部分 (LiquidationFill.Uncovered)，按合约累计
+//   - 每个结算周期末 (资金费结算完成后) 按标记价格找出盈利持仓，
+//     按未实现盈利占比扣回：扣款_i = 待分摊 × 盈利_i / Σ盈利，总额不超过 Σ盈利
+//   - 扣款走幂等流水 (fund.ApplySocialLoss)，按可用余额截断，
+//     扣不到的部分、取整的零头和没有盈利持仓时的全部亏损都顺延到下一周期
+//
+// 【面试】为什么按未实现盈利比例，而不是按持仓价值?
+// 亏损最终是对手方的盈利，只向赚钱的人收；按持仓价值会让亏钱的一方再亏一次
+//
+// 【注意】待分摊金额只在内存累计，重启会丢失未分摊的部分，
+// 需要按保险基金兜底流水 (BANKRUPT_COVER) 与强平记录人工核对后重新登记
+
+package futures
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	"max.com/pkg/fixed"
+	"max.com/pkg/fund"
+)
+
+// SocialLossLedger 分摊扣款账本 (冷钱包)
+type SocialLossLedger interface {
+	// ApplySocialLoss 幂等扣款，按可用余额截断，返回实际扣款金额
+	ApplySocialLoss(ctx context.Context, userID int64, currency, symbol string, cycle, amount int64) (int64, bool, error)
+}
+
+var _ SocialLossLedger = (*fund.BalanceRepo)(nil)
+
+const defaultSocialLossBatchSize = 500
+
+// =============================================================================
+// 报告
+// =============================================================================
+
+// SocialLossEntry 单个盈利持仓的分摊明细
+type SocialLossEntry struct {
+	UserID        int64
+	PositionSize  int64
+	UnrealizedPnL int64 // 周期末未实现盈利
+	Share         int64 // 应扣金额
+	Deducted      int64 // 实际扣款 (余额不足时小于 Share)
+	Skipped       bool  // 这一周期已经扣过 (重跑)，不计入本次回收
+	Err           error
+}
+
+// SocialLossReport 一个周期的分摊结果
+type SocialLossReport struct {
+	Symbol    string
+	Currency  string
+	Cycle     int64 // 周期时间点 (毫秒，资金费结算时间)
+	MarkPrice int64
+
+	Deficit     int64 // 本周期待分摊 (含上周期顺延)
+	TotalProfit int64 // 盈利持仓未实现盈利合计
+	Recovered   int64 // 实际扣回
+	Carried     int64 // 顺延到下一周期
+
+	Entries      []SocialLossEntry // 按 UserID 排序
+	FailedCount  int
+	SkippedCount int
+}
+
+// SocialLossTotals 某合约的累计统计
+type SocialLossTotals struct {
+	Symbol    string
+	Deficit   int64 // 累计登记的未覆盖亏损
+	Recovered int64 // 累计扣回
+	Pending   int64 // 尚未分摊
+	Cycles    int   // 发生过扣款的周期数
+}
+
+// =============================================================================
+// LossSocializer
+// =============================================================================
+
+// LossSocializer 穿仓分摊处理器
+type LossSocializer struct {
+	contractManager *ContractManager
+	positionRepo    PositionRepository
+	ledger          SocialLossLedger
+	batchSize       int
+
+	mu     sync.Mutex
+	totals map[string]*SocialLossTotals // symbol -> 累计
+
+	settleMu sync.Mutex // 同一时刻只跑一个周期
+}
+
+func NewLossSocializer(contractManager *ContractManager, positionRepo PositionRepository, ledger SocialLossLedger) *LossSocializer {
+	return &LossSocializer{
+		contractManager: contractManager,
+		positionRepo:    positionRepo,
+		ledger:          ledger,
+		batchSize:       defaultSocialLossBatchSize,
+		totals:          make(map[string]*SocialLossTotals),
+	}
+}
+
+// Attach 注册到强平执行器和资金费服务：强平时登记、资金费结算后分摊
+func (s *LossSocializer) Attach(liquidation *LiquidationExecutor, funding *FundingService) {
+	if liquidation != nil {
+		liquidation.OnLiquidated(s.OnLiquidated)
+	}
+	if funding != nil {
+		funding.OnSettled(s.OnFundingSettled)
+	}
+}
+
+// RecordDeficit 登记一笔保险基金未覆盖的穿仓亏损
+func (s *LossSocializer) RecordDeficit(symbol string, amount int64) {
+	if amount <= 0 {
+		return
+	}
+	s.mu.Lock()
+	t := s.totalsLocked(symbol)
+	t.Deficit += amount
+	t.Pending += amount
+	pending := t.Pending
+	s.mu.Unlock()
+	log.Printf("[SocialLoss] %s deficit +%d, pending %d", symbol, amount, pending)
+}
+
+// OnLiquidated 强平成交回调
+func (s *LossSocializer) OnLiquidated(fill LiquidationFill) {
+	s.RecordDeficit(fill.Symbol, fill.Uncovered)
+}
+
+// OnFundingSettled 资金费结算完成回调，以资金费时间点为周期分摊
+func (s *LossSocializer) OnFundingSettled(report *FundingReport) {
+	if report.DryRun || s.Pending(report.Symbol) == 0 {
+		return
+	}
+	if _, err := s.Settle(context.Background(), report.Symbol, report.FundingTime, report.MarkPrice); err != nil {
+		log.Printf("[SocialLoss] %s cycle %d failed: %v", report.Symbol, report.FundingTime, err)
+	}
+}
+
+// Pending 尚未分摊的金额
+func (s *LossSocializer) Pending(symbol string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.totals[symbol]; ok {
+		return t.Pending
+	}
+	return 0
+}
+
+// Totals 各合约的累计统计，按合约排序
+func (s *LossSocializer) Totals() []SocialLossTotals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SocialLossTotals, 0, len(s.totals))
+	for _, t := range s.totals {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+// Settle 按 markPrice 分摊 symbol 的待分摊亏损，cycle 为周期时间点 (流水幂等键的一部分)
+//
+// 同一周期重跑时已扣过的用户被流水幂等键挡住 (Skipped)，不会重复扣款
+func (s *LossSocializer) Settle(ctx context.Context, symbol string, cycle, markPrice int64) (*SocialLossReport, error) {
+	s.settleMu.Lock()
+	defer s.settleMu.Unlock()
+
+	spec, err := s.contractManager.GetContract(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if markPrice <= 0 {
+		return nil, ErrNoMarkPrice
+	}
+
+	report := &SocialLossReport{
+		Symbol:    symbol,
+		Currency:  spec.SettleCurrency,
+		Cycle:     cycle,
+		MarkPrice: markPrice,
+		Deficit:   s.Pending(symbol),
+	}
+	if report.Deficit == 0 {
+		return report, nil
+	}
+
+	// 1. 找出盈利持仓
+	winners, err := s.winningPositions(ctx, spec, markPrice, report)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 按盈利比例扣回，总额不超过盈利合计
+	target := min(report.Deficit, report.TotalProfit)
+	for _, entry := range winners {
+		entry.Share = fixed.MulDiv(target, entry.UnrealizedPnL, report.TotalProfit)
+		if entry.Share > 0 {
+			s.deduct(ctx, spec, cycle, &entry)
+		}
+		report.add(entry)
+	}
+
+	// 3. 扣不到的顺延
+	report.Carried = report.Deficit - report.Recovered
+	s.mu.Lock()
+	t := s.totalsLocked(symbol)
+	t.Pending -= report.Recovered
+	t.Recovered += report.Recovered
+	if report.Recovered > 0 {
+		t.Cycles++
+	}
+	s.mu.Unlock()
+
+	log.Printf("[SocialLoss] %s cycle %d: deficit=%d, profit=%d, recovered=%d, carried=%d, failed=%d",
+		symbol, cycle, report.Deficit, report.TotalProfit, report.Recovered, report.Carried, report.FailedCount)
+	return report, nil
+}
+
+// winningPositions 按持仓 ID 分批扫描，返回按标记价格盈利的持仓
+func (s *LossSocializer) winningPositions(ctx context.Context, spec *ContractSpec, markPrice int64, report *SocialLossReport) ([]SocialLossEntry, error) {
+	var winners []SocialLossEntry
+	var afterID uint
+	for {
+		positions, err := s.positionRepo.ListBySymbolAfter(ctx, spec.Symbol, afterID, s.batchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(positions) == 0 {
+			break
+		}
+		for _, pos := range positions {
+			if pnl := spec.PnL(pos.Size, pos.EntryPrice, markPrice); pnl > 0 {
+				winners = append(winners, SocialLossEntry{UserID: pos.UserID, PositionSize: pos.Size, UnrealizedPnL: pnl})
+				report.TotalProfit += pnl
+			}
+		}
+		afterID = positions[len(positions)-1].ID
+	}
+	sort.Slice(winners, func(i, j int) bool { return winners[i].UserID < winners[j].UserID })
+	return winners, nil
+}
+
+// deduct 扣款落账
+func (s *LossSocializer) deduct(ctx context.Context, spec *ContractSpec, cycle int64, entry *SocialLossEntry) {
+	deducted, duplicate, err := s.ledger.ApplySocialLoss(ctx, entry.UserID, spec.SettleCurrency, spec.Symbol, cycle, entry.Share)
+	if err != nil {
+		entry.Err = err
+		log.Printf("[SocialLoss] Failed to deduct %d from user %d: %v", entry.Share, entry.UserID, err)
+		return
+	}
+	entry.Deducted, entry.Skipped = deducted, duplicate
+}
+
+// add 记录一条明细并更新汇总
+func (r *SocialLossReport) add(entry SocialLossEntry) {
+	r.Entries = append(r.Entries, entry)
+	switch {
+	case entry.Err != nil:
+		r.FailedCount++
+	case entry.Skipped:
+		r.SkippedCount++
+	default:
+		r.Recovered += entry.Deducted
+	}
+}
+
+func (s *LossSocializer) totalsLocked(symbol string) *SocialLossTotals {
+	t, ok := s.totals[symbol]
+	if !ok {
+		t = &SocialLossTotals{Symbol: symbol}
+		s.totals[symbol] = t
+	}
+	return t
+}
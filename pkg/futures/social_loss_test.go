@@ -0,0 +1,87 @@
+// 文件: pkg/futures/social_loss_test.go
+// 穿仓分摊测试 (内存仓储，见 dryrun_test.go)
+
+package futures
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memSocialLossLedger 按幂等键记账的内存账本
+type memSocialLossLedger struct {
+	mu        sync.Mutex
+	available map[int64]int64
+	applied   map[string]int64
+}
+
+func (l *memSocialLossLedger) ApplySocialLoss(ctx context.Context, userID int64, currency, symbol string, cycle, amount int64) (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := fmt.Sprintf("%s_%d_%d", symbol, cycle, userID)
+	if prev, ok := l.applied[key]; ok {
+		return prev, true, nil
+	}
+	deducted := min(amount, max(l.available[userID], 0))
+	l.available[userID] -= deducted
+	l.applied[key] = deducted
+	return deducted, false, nil
+}
+
+func TestLossSocializer_ProRataClawback(t *testing.T) {
+	const symbol = "TESTBTCUSDT"
+	ctx := context.Background()
+	positions := newMemPositionRepo()
+	for _, p := range []*Position{
+		{UserID: 1, Symbol: symbol, Size: Precision, EntryPrice: 50000 * Precision},      // 51000 时盈利 1000
+		{UserID: 2, Symbol: symbol, Size: 3 * Precision, EntryPrice: 50000 * Precision},  // 盈利 3000
+		{UserID: 3, Symbol: symbol, Size: -4 * Precision, EntryPrice: 50000 * Precision}, // 亏损，不参与
+	} {
+		require.NoError(t, positions.Save(ctx, p))
+	}
+	ledger := &memSocialLossLedger{
+		available: map[int64]int64{1: 10000 * Precision, 2: 1000 * Precision, 3: 10000 * Precision},
+		applied:   make(map[string]int64),
+	}
+	s := NewLossSocializer(NewContractManager(newMemContractRepo(harnessLinearSpec())), positions, ledger)
+
+	// 保险基金不足的部分随强平回调登记
+	s.OnLiquidated(LiquidationFill{Symbol: symbol, Shortfall: 2500 * Precision, Uncovered: 2000 * Precision})
+	s.OnLiquidated(LiquidationFill{Symbol: symbol, Shortfall: 300 * Precision}) // 保险基金已覆盖
+	assert.Equal(t, int64(2000*Precision), s.Pending(symbol))
+
+	// 2000 按盈利 1:3 分摊：用户 1 扣 500，用户 2 应扣 1500 但只有 1000
+	report, err := s.Settle(ctx, symbol, 1000, 51000*Precision)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4000*Precision), report.TotalProfit)
+	require.Len(t, report.Entries, 2)
+	assert.Equal(t, int64(500*Precision), report.Entries[0].Deducted)
+	assert.Equal(t, int64(1500*Precision), report.Entries[1].Share)
+	assert.Equal(t, int64(1000*Precision), report.Entries[1].Deducted)
+	assert.Equal(t, int64(1500*Precision), report.Recovered)
+	assert.Equal(t, int64(500*Precision), report.Carried)
+	assert.Equal(t, int64(10000*Precision), ledger.available[3])
+
+	// 同一周期重跑不重复扣款
+	report, err = s.Settle(ctx, symbol, 1000, 51000*Precision)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.SkippedCount)
+	assert.Zero(t, report.Recovered)
+	assert.Equal(t, int64(9500*Precision), ledger.available[1])
+
+	// 顺延的 500 在下一周期分摊
+	ledger.available[2] = 1000 * Precision
+	report, err = s.Settle(ctx, symbol, 2000, 51000*Precision)
+	require.NoError(t, err)
+	assert.Equal(t, int64(500*Precision), report.Recovered)
+	assert.Zero(t, report.Carried)
+
+	totals := s.Totals()
+	require.Len(t, totals, 1)
+	assert.Equal(t, SocialLossTotals{Symbol: symbol, Deficit: 2000 * Precision, Recovered: 2000 * Precision, Cycles: 2}, totals[0])
+}
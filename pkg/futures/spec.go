@@ -9,6 +9,11 @@
 
 package futures
 
+import (
+	"max.com/pkg/fixed"
+	"max.com/pkg/mtrade"
+)
+
 // =============================================================================
 // 精度常量
 // =============================================================================
@@ -17,11 +22,11 @@ const (
 	// Precision 价格/数量精度因子
 	// 所有金额存储为 int64，乘以 10^8
 	// 例: 1.5 BTC = 150_000_000
-	Precision = 100_000_000
+	Precision = fixed.Scale
 
 	// RatePrecision 费率精度 (万分比)
 	// 例: 0.01% = 1, 0.1% = 10, 1% = 100
-	RatePrecision = 10000
+	RatePrecision = fixed.BpsScale
 )
 
 // =============================================================================
@@ -108,14 +113,30 @@ type ContractSpec struct {
 	MaxOrderQty    int64        `gorm:"column:max_order_qty"`
 	MaxPositionQty int64        `gorm:"column:max_position_qty"`
 
+	// MaxOrderLifetime 挂单最长存活时间 (秒)，0 使用 DefaultMaxOrderLifetime，到期由 OrderExpirySweeper 撤单
+	MaxOrderLifetime int64 `gorm:"column:max_order_lifetime"`
+
+	// ===== 计价方式 =====
+	// Inverse 反向合约 (币本位)：数量单位为张，每张面值 ContractSize 个报价币，
+	// 保证金/盈亏/资金费都以基础币结算 (SettleCurrency = BaseCurrency)
+	Inverse bool `gorm:"column:inverse"`
+
 	// ===== 杠杆与保证金 =====
 	MaxLeverage       int   `gorm:"column:max_leverage"`
 	InitialMarginRate int64 `gorm:"column:initial_margin_rate"`
 	MaintMarginRate   int64 `gorm:"column:maint_margin_rate"`
 
-	// ===== 资金费率 (仅永续) =====
+	// LiquidationFeeRate 强平手续费率 (万分比，按强平成交额收取，归保险基金)，不超过维持保证金率
+	LiquidationFeeRate int64 `gorm:"column:liquidation_fee_rate"`
+
+	// ===== 资金费率 (仅永续，见 funding_params.go) =====
+	// FundingInterval 结算间隔 (秒)，须整除 24 小时，0 使用 8 小时
 	FundingInterval int64 `gorm:"column:funding_interval"`
-	MaxFundingRate  int64 `gorm:"column:max_funding_rate"`
+	// MaxFundingRate / MinFundingRate 资金费率上下限 (万分比)，上限 0 使用 MaxFundingRate 常量，下限 0 与上限对称
+	MaxFundingRate int64 `gorm:"column:max_funding_rate"`
+	MinFundingRate int64 `gorm:"column:min_funding_rate"`
+	// InterestRate 每期利率 (万分比)，0 表示不计利率项 (资金费率 = 溢价指数)
+	InterestRate int64 `gorm:"column:interest_rate"`
 
 	// ===== 指数价格 =====
 	PriceSources []string `gorm:"column:price_sources;serializer:json"`
@@ -147,6 +168,97 @@ func (s *ContractSpec) IsExpired(now int64) bool {
 	return s.ExpiryAt > 0 && now >= s.ExpiryAt
 }
 
+// =============================================================================
+// 正向 / 反向合约计价
+// =============================================================================
+//
+// 【面试】正向 (U 本位) 和反向 (币本位) 合约的区别？
+//   正向: 数量单位是基础币，价值 = 数量 × 价格，盈亏 = 数量 × (平仓价 − 开仓价)，USDT 结算
+//   反向: 数量单位是张 (每张 ContractSize 美元)，价值 = 张数 × 面值 / 价格，
+//         盈亏 = 张数 × 面值 × (1/开仓价 − 1/平仓价)，基础币结算
+// 反向合约的盈亏对价格是非线性的：多头涨时赚的币越来越少，空头涨时亏的币有上限
+//
+// 以下方法 nil 接收者按正向合约处理
+
+// PositionValue 仓位价值 (结算币种)
+//
+// 正向: qty × price / Precision
+// 反向: qty × ContractSize / price
+func (s *ContractSpec) PositionValue(qty, price int64) int64 {
+	if s == nil || !s.Inverse {
+		return fixed.Mul(qty, price)
+	}
+	if price <= 0 {
+		return 0
+	}
+	return fixed.MulDiv(qty, s.ContractSize, price)
+}
+
+// PnL 盈亏 (结算币种)，size 带方向 (多正空负)
+//
+// 正向: size × (exit − entry) / Precision
+// 反向: size × ContractSize × (1/entry − 1/exit) = 按开仓价的价值 − 按平仓价的价值
+func (s *ContractSpec) PnL(size, entryPrice, exitPrice int64) int64 {
+	if s == nil || !s.Inverse {
+		return fixed.Mul(exitPrice-entryPrice, size)
+	}
+	qty := absInt64(size)
+	pnl := s.PositionValue(qty, entryPrice) - s.PositionValue(qty, exitPrice)
+	if size < 0 {
+		return -pnl
+	}
+	return pnl
+}
+
+// AvgEntryPrice 同向加仓后的开仓均价 (size、addSize 同号)
+//
+// 正向: 按数量加权的算术平均
+// 反向: 调和平均 = 总张数 × 面值 / 总价值，保证按均价算出的盈亏与分笔相加一致
+func (s *ContractSpec) AvgEntryPrice(size, entryPrice, addSize, price int64) int64 {
+	size, addSize = absInt64(size), absInt64(addSize)
+	total := size + addSize
+	if total == 0 {
+		return 0
+	}
+	value := s.PositionValue(size, entryPrice) + s.PositionValue(addSize, price)
+	if s == nil || !s.Inverse {
+		return fixed.Quo(value, total)
+	}
+	if value <= 0 {
+		return price
+	}
+	return fixed.MulDiv(total, s.ContractSize, value)
+}
+
+// BankruptPrice 破产价格 (亏光保证金的价格)，size 带方向
+//
+// 正向: 多头 entry − margin/|size|，空头 entry + margin/|size|
+// 反向: 多头 entry × V / (V + margin × entry)，空头 entry × V / (V − margin × entry)，
+// 其中 V = |size| × ContractSize 为报价币面值；空头保证金不低于开仓价值时永不破产，返回 0
+func (s *ContractSpec) BankruptPrice(size, entryPrice, margin int64) int64 {
+	if size == 0 {
+		return 0
+	}
+	qty := absInt64(size)
+	if s == nil || !s.Inverse {
+		marginPerUnit := fixed.Quo(margin, qty)
+		if size > 0 {
+			return entryPrice - marginPerUnit
+		}
+		return entryPrice + marginPerUnit
+	}
+
+	face := fixed.Mul(qty, s.ContractSize)       // 面值 (报价币)
+	marginValue := fixed.Mul(margin, entryPrice) // 保证金按开仓价折算 (报价币)
+	if size > 0 {
+		return fixed.MulDiv(entryPrice, face, face+marginValue)
+	}
+	if marginValue >= face {
+		return 0
+	}
+	return fixed.MulDiv(entryPrice, face, face-marginValue)
+}
+
 // CalcInitialMargin 计算开仓初始保证金
 //
 // 公式: 初始保证金 = 仓位价值 × 初始保证金率
@@ -170,7 +282,17 @@ func (s *ContractSpec) CalcInitialMargin(positionValue int64, leverage int) int6
 // 【面试】维持保证金 < 初始保证金
 // 当账户权益 < 维持保证金时触发强平
 func (s *ContractSpec) CalcMaintMargin(positionValue int64) int64 {
-	return positionValue * s.MaintMarginRate / RatePrecision
+	return fixed.Bps(positionValue, s.MaintMarginRate)
+}
+
+// CalcLiquidationFee 计算强平手续费
+//
+// 公式: 强平手续费 = 强平成交额 × 强平手续费率
+//
+// 【面试】强平手续费从被强平用户剩余的维持保证金里扣，扣完为止，
+// 所以费率不能超过维持保证金率；穿仓的用户收不到手续费
+func (s *ContractSpec) CalcLiquidationFee(positionValue int64) int64 {
+	return fixed.Bps(positionValue, s.LiquidationFeeRate)
 }
 
 // ValidatePrice 验证价格是否符合 TickSize
@@ -178,6 +300,28 @@ func (s *ContractSpec) ValidatePrice(price int64) bool {
 	return price > 0 && price%s.TickSize == 0
 }
 
+// BookTickConfig 生成撮合订单簿的价格带（跳价数组索引使用）
+//
+// 【面试】合约只规定了 TickSize，价格上下限由风控价格带决定
+// (如标记价格 ±50%)，这里把上下限对齐到 TickSize
+func (s *ContractSpec) BookTickConfig(minPrice, maxPrice int64) mtrade.TickConfig {
+	lo := fixed.Quantize(minPrice, s.TickSize, fixed.RoundCeil)
+	hi := fixed.Quantize(maxPrice, s.TickSize, fixed.RoundFloor)
+	return mtrade.TickConfig{MinPrice: lo, MaxPrice: hi, TickSize: s.TickSize}
+}
+
+// MatchEngineConfig 合约撮合引擎配置：订单簿用跳价数组索引，价格带见 BookTickConfig
+//
+// 未配置 TickSize 的合约退回跳表索引（价格不受限）
+func (s *ContractSpec) MatchEngineConfig(minPrice, maxPrice int64) mtrade.EngineConfig {
+	cfg := mtrade.DefaultEngineConfig(s.Symbol)
+	if s.TickSize > 0 {
+		cfg.BookIndex = mtrade.BookIndexTickArray
+		cfg.Tick = s.BookTickConfig(minPrice, maxPrice)
+	}
+	return cfg
+}
+
 // ValidateQty 验证数量是否合法
 func (s *ContractSpec) ValidateQty(qty int64) bool {
 	return qty >= s.MinOrderQty && qty <= s.MaxOrderQty
@@ -0,0 +1,93 @@
+// 文件: pkg/futures/spec_test.go
+// 正向 / 反向合约计价测试
+
+package futures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/mtrade"
+)
+
+// 反向合约: BTCUSD，每张 100 USD，以 BTC 结算
+var inverseSpec = &ContractSpec{
+	Symbol:         "BTCUSD",
+	BaseCurrency:   "BTC",
+	QuoteCurrency:  "USD",
+	SettleCurrency: "BTC",
+	Inverse:        true,
+	ContractSize:   100 * Precision,
+}
+
+func TestContractSpec_Linear(t *testing.T) {
+	var linear *ContractSpec // nil 按正向合约
+	assert.Equal(t, int64(50000*Precision), linear.PositionValue(Precision, 50000*Precision))
+	assert.Equal(t, int64(1000*Precision), linear.PnL(Precision, 50000*Precision, 51000*Precision))
+	assert.Equal(t, int64(-1000*Precision), linear.PnL(-Precision, 50000*Precision, 51000*Precision))
+	assert.Equal(t, int64(45000*Precision), linear.AvgEntryPrice(Precision, 50000*Precision, Precision, 40000*Precision))
+	assert.Equal(t, int64(45000*Precision), linear.BankruptPrice(Precision, 50000*Precision, 5000*Precision))
+}
+
+func TestContractSpec_Inverse(t *testing.T) {
+	// 100 张 @ 50000 = 10000 USD = 0.2 BTC
+	assert.Equal(t, int64(Precision/5), inverseSpec.PositionValue(100*Precision, 50000*Precision))
+
+	// 涨到 55000: 多头赚 10000 × (1/50000 - 1/55000) ≈ 0.01818182 BTC，空头亏同样多
+	assert.Equal(t, int64(1818182), inverseSpec.PnL(100*Precision, 50000*Precision, 55000*Precision))
+	assert.Equal(t, int64(-1818182), inverseSpec.PnL(-100*Precision, 50000*Precision, 55000*Precision))
+
+	// 加仓用调和平均: 100 张 @ 50000 + 100 张 @ 40000 → 20000 USD / 0.45 BTC
+	avg := inverseSpec.AvgEntryPrice(100*Precision, 50000*Precision, 100*Precision, 40000*Precision)
+	assert.Equal(t, int64(4444444444444), avg)
+	// 按均价算出的盈亏 = 分笔盈亏之和 (0 + 0.05 BTC)
+	assert.InDelta(t, Precision/20, inverseSpec.PnL(200*Precision, avg, 50000*Precision), 2)
+
+	// 破产价: 0.02 BTC 保证金 (10 倍)
+	long := inverseSpec.BankruptPrice(100*Precision, 50000*Precision, Precision/50)
+	assert.InDelta(t, 45454.5454*Precision, long, Precision)
+	assert.InDelta(t, -Precision/50, inverseSpec.PnL(100*Precision, 50000*Precision, long), 2)
+
+	short := inverseSpec.BankruptPrice(-100*Precision, 50000*Precision, Precision/50)
+	assert.InDelta(t, 55555.5555*Precision, short, Precision)
+
+	// 1 倍空单永不破产
+	assert.Zero(t, inverseSpec.BankruptPrice(-100*Precision, 50000*Precision, Precision/5))
+}
+
+func TestRiskCalculator_Inverse(t *testing.T) {
+	calc := NewRiskCalculator()
+	pos := &Position{Size: 100 * Precision, EntryPrice: 50000 * Precision}
+
+	risk := calc.CalculatePositionRiskWithSpec(inverseSpec, pos, 55000*Precision, Precision/50)
+	assert.InDelta(t, 1818182, risk.UnrealizedPnL, 2)
+	assert.InDelta(t, 18181818, risk.Notional, 2) // 10000 USD / 55000 = 0.1818 BTC
+	assert.InDelta(t, 45681.82*Precision, risk.LiquidationPrice, Precision)
+	assert.Equal(t, RiskLevelSafe, risk.RiskLevel)
+}
+
+func TestContractSpec_MatchEngineConfig(t *testing.T) {
+	spec := &ContractSpec{Symbol: "BTCUSDT", TickSize: Precision / 2} // 0.5
+
+	// 价格带向内对齐 TickSize
+	cfg := spec.MatchEngineConfig(25000*Precision+1, 75000*Precision+Precision/2+1)
+	assert.Equal(t, "BTCUSDT", cfg.Symbol)
+	assert.Equal(t, mtrade.BookIndexTickArray, cfg.BookIndex)
+	assert.Equal(t, mtrade.TickConfig{MinPrice: 25000*Precision + Precision/2, MaxPrice: 75000*Precision + Precision/2, TickSize: Precision / 2}, cfg.Tick)
+
+	engine, err := mtrade.NewEngine(cfg)
+	require.NoError(t, err)
+	book := engine.GetOrderBook()
+	assert.True(t, book.ValidPrice(50000*Precision+Precision/2))
+	assert.False(t, book.ValidPrice(50000*Precision+1), "off-tick price")
+	assert.False(t, book.ValidPrice(25000*Precision), "below band")
+
+	// 未配置 TickSize：跳表索引，价格不受限
+	cfg = (&ContractSpec{Symbol: "ETHUSDT"}).MatchEngineConfig(0, 0)
+	assert.Equal(t, mtrade.BookIndexSkipList, cfg.BookIndex)
+	engine, err = mtrade.NewEngine(cfg)
+	require.NoError(t, err)
+	assert.True(t, engine.GetOrderBook().ValidPrice(12345))
+}
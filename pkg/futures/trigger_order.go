@@ -0,0 +1,321 @@
+// 文件: pkg/futures/trigger_order.go
+// 条件单服务 - 止盈/止损/计划委托/跟踪止损
+//
+// 【设计】条件单不进撮合引擎
+// 撮合只认"现在就能成交的订单"，条件单在触发前只是用户的意图：
+//   - 意图持久化在 DB (trigger_orders)，重启后 ListPending 重新加载到内存
+//   - 订阅 MarkPriceService 的价格更新，按标记价格判断触发 (防插针)
+//   - 触发后通过 FuturesProcessor 下真实订单 (开仓 / 平仓)
+//
+// 【面试】条件单怎么保证只触发一次？
+//   1. 进程内: 判断触发时在锁内把单子移出活跃集合，同一条价格推送不会重复处理
+//   2. 跨实例: DB 上 CAS (status: PENDING → TRIGGERED)，只有一个实例能改成功，
+//      改成功的实例才去下单；其他实例看到 false 直接跳过
+//   3. 下单失败不重试 (价格已经变了)，标记为 FAILED 并记录原因，由用户决定
+//
+// 【注意】回调在 MarkPriceService 的推送协程里同步执行，
+// 触发后的下单会阻塞价格推送；条件单量大时应改为投递到工作协程
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxFailReasonLen 与表结构 fail_reason VARCHAR(255) 对齐
+const maxFailReasonLen = 255
+
+// errNoMatchingPosition 平仓类条件单触发时找不到对应方向的持仓
+var errNoMatchingPosition = errors.New("no matching position to close")
+
+// TriggerOrderExecutor 触发后的下单入口 (FuturesProcessor 实现)
+type TriggerOrderExecutor interface {
+	OpenPosition(ctx context.Context, req *OpenPositionRequest) error
+	ClosePosition(ctx context.Context, req *ClosePositionRequest) error
+}
+
+var _ TriggerOrderExecutor = (*FuturesProcessor)(nil)
+
+// =============================================================================
+// TriggerOrderService
+// =============================================================================
+
+// TriggerOrderService 条件单服务
+type TriggerOrderService struct {
+	repo       TriggerOrderRepository
+	executor   TriggerOrderExecutor
+	positions  PositionRepository
+	markPrices *MarkPriceService
+
+	mu sync.Mutex
+	// symbol -> id -> 等待触发的条件单
+	active map[string]map[int64]*TriggerOrder
+}
+
+// NewTriggerOrderService 创建条件单服务
+func NewTriggerOrderService(
+	repo TriggerOrderRepository,
+	executor TriggerOrderExecutor,
+	positions PositionRepository,
+	markPrices *MarkPriceService,
+) *TriggerOrderService {
+	return &TriggerOrderService{
+		repo:       repo,
+		executor:   executor,
+		positions:  positions,
+		markPrices: markPrices,
+		active:     make(map[string]map[int64]*TriggerOrder),
+	}
+}
+
+// Start 加载未触发的条件单并订阅标记价格
+func (s *TriggerOrderService) Start(ctx context.Context) error {
+	pending, err := s.repo.ListPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, t := range pending {
+		s.addLocked(t)
+	}
+	s.mu.Unlock()
+
+	log.Printf("[TriggerOrder] Loaded %d pending trigger orders", len(pending))
+
+	s.markPrices.OnPriceUpdate(func(symbol string, info *MarkPriceInfo) {
+		s.OnMarkPrice(context.Background(), symbol, info.MarkPrice)
+	})
+	return nil
+}
+
+// =============================================================================
+// 用户接口
+// =============================================================================
+
+// Place 下条件单
+func (s *TriggerOrderService) Place(ctx context.Context, t *TriggerOrder) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	// 跟踪止损从当前标记价格开始跟踪
+	if t.Kind == TriggerTrailingStop {
+		t.ExtremePrice = s.markPrices.GetMarkPrice(t.Symbol)
+	}
+	t.Status = TriggerPending
+	t.TriggerMarkPrice = 0
+	t.TriggeredAt = 0
+	t.FailReason = ""
+
+	if err := s.repo.Create(ctx, t); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.addLocked(t)
+	s.mu.Unlock()
+	return nil
+}
+
+// Cancel 撤销条件单，已触发/已撤销返回 ErrTriggerOrderNotPending
+func (s *TriggerOrderService) Cancel(ctx context.Context, userID, id int64) error {
+	t, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	t.Status = TriggerCanceled
+	ok, err := s.repo.CompareAndSetStatus(ctx, t, TriggerPending)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTriggerOrderNotPending
+	}
+
+	s.mu.Lock()
+	s.removeLocked(t.Symbol, t.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// Get 查询用户的条件单
+func (s *TriggerOrderService) Get(ctx context.Context, userID, id int64) (*TriggerOrder, error) {
+	t, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// 不暴露其他用户的单子是否存在
+	if t.UserID != userID {
+		return nil, ErrTriggerOrderNotFound
+	}
+	return t, nil
+}
+
+// ListByUser 查询用户条件单，status 为 0 表示全部
+func (s *TriggerOrderService) ListByUser(ctx context.Context, userID int64, status TriggerStatus, limit int) ([]*TriggerOrder, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	return s.repo.ListByUser(ctx, userID, status, limit)
+}
+
+// =============================================================================
+// 触发
+// =============================================================================
+
+// OnMarkPrice 标记价格更新：检查该合约的条件单并触发
+func (s *TriggerOrderService) OnMarkPrice(ctx context.Context, symbol string, markPrice int64) {
+	if markPrice <= 0 {
+		return
+	}
+
+	type extremeUpdate struct {
+		id    int64
+		price int64
+	}
+	var (
+		fired []*TriggerOrder
+		moved []extremeUpdate
+	)
+
+	s.mu.Lock()
+	for id, t := range s.active[symbol] {
+		fire, extremeMoved := t.shouldTrigger(markPrice)
+		switch {
+		case fire:
+			// 锁内移出活跃集合：并发的价格推送不会重复触发
+			s.removeLocked(symbol, id)
+			fired = append(fired, t)
+		case extremeMoved:
+			moved = append(moved, extremeUpdate{id, t.ExtremePrice})
+		}
+	}
+	s.mu.Unlock()
+
+	// 极值只是优化重启后的跟踪起点，写失败不影响触发
+	for _, m := range moved {
+		if err := s.repo.UpdateExtremePrice(ctx, m.id, m.price); err != nil {
+			log.Printf("[TriggerOrder] Update extreme price for %d failed: %v", m.id, err)
+		}
+	}
+
+	for _, t := range fired {
+		s.fire(ctx, t, markPrice)
+	}
+}
+
+// fire 触发条件单：CAS 抢到触发权后下单
+func (s *TriggerOrderService) fire(ctx context.Context, t *TriggerOrder, markPrice int64) {
+	t.Status = TriggerTriggered
+	t.TriggerMarkPrice = markPrice
+	t.TriggeredAt = time.Now().UnixMilli()
+
+	ok, err := s.repo.CompareAndSetStatus(ctx, t, TriggerPending)
+	if err != nil {
+		// DB 故障：放回活跃集合，下一次价格推送再试
+		log.Printf("[TriggerOrder] Mark %d triggered failed: %v", t.ID, err)
+		t.Status = TriggerPending
+		t.TriggerMarkPrice = 0
+		t.TriggeredAt = 0
+		s.mu.Lock()
+		s.addLocked(t)
+		s.mu.Unlock()
+		return
+	}
+	if !ok {
+		// 已被其他实例触发或被用户撤销
+		return
+	}
+
+	if err := s.execute(ctx, t, markPrice); err != nil {
+		log.Printf("[TriggerOrder] Order %d (%s) execute failed: %v", t.ID, t.Kind, err)
+		t.Status = TriggerFailed
+		t.FailReason = err.Error()
+		if len(t.FailReason) > maxFailReasonLen {
+			t.FailReason = t.FailReason[:maxFailReasonLen]
+		}
+		if _, err := s.repo.CompareAndSetStatus(ctx, t, TriggerTriggered); err != nil {
+			log.Printf("[TriggerOrder] Mark %d failed: %v", t.ID, err)
+		}
+		return
+	}
+
+	log.Printf("[TriggerOrder] Order %d (%s) triggered: user=%d, symbol=%s, mark=%d",
+		t.ID, t.Kind, t.UserID, t.Symbol, markPrice)
+}
+
+// execute 转成真实订单
+func (s *TriggerOrderService) execute(ctx context.Context, t *TriggerOrder, markPrice int64) error {
+	if t.Kind == TriggerStop {
+		price := t.OrderPrice
+		if price == 0 {
+			price = markPrice
+		}
+		return s.executor.OpenPosition(ctx, &OpenPositionRequest{
+			UserID:   t.UserID,
+			Symbol:   t.Symbol,
+			Side:     t.Side,
+			Qty:      t.Qty,
+			Price:    price,
+			Leverage: t.Leverage,
+		})
+	}
+
+	// 平仓类：持仓方向必须与条件单一致，
+	// 防止多头止损在用户反手做空后把空仓平掉
+	pos, err := s.positions.GetByUserAndSymbol(ctx, t.UserID, t.Symbol)
+	if err != nil {
+		return err
+	}
+	if pos == nil || pos.Size == 0 || (pos.Size > 0) != (t.Side == SideLong) {
+		return errNoMatchingPosition
+	}
+
+	// OrderPrice 为 0 时 ClosePosition 按标记价格市价平仓
+	return s.executor.ClosePosition(ctx, &ClosePositionRequest{
+		UserID: t.UserID,
+		Symbol: t.Symbol,
+		Qty:    t.Qty,
+		Price:  t.OrderPrice,
+	})
+}
+
+// =============================================================================
+// 内存索引
+// =============================================================================
+
+func (s *TriggerOrderService) addLocked(t *TriggerOrder) {
+	bySymbol, ok := s.active[t.Symbol]
+	if !ok {
+		bySymbol = make(map[int64]*TriggerOrder)
+		s.active[t.Symbol] = bySymbol
+	}
+	// 存副本：调用方持有的对象不会被触发逻辑改写
+	cp := *t
+	bySymbol[t.ID] = &cp
+}
+
+func (s *TriggerOrderService) removeLocked(symbol string, id int64) {
+	bySymbol := s.active[symbol]
+	delete(bySymbol, id)
+	if len(bySymbol) == 0 {
+		delete(s.active, symbol)
+	}
+}
+
+// ActiveCount 内存中等待触发的条件单数量
+func (s *TriggerOrderService) ActiveCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, bySymbol := range s.active {
+		n += len(bySymbol)
+	}
+	return n
+}
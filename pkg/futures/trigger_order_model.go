@@ -0,0 +1,181 @@
+// 文件: pkg/futures/trigger_order_model.go
+// 条件单 (止盈/止损/计划委托/跟踪止损) 数据模型
+
+package futures
+
+import (
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrTriggerOrderNotFound   = cexerr.New("FUTURES_TRIGGER_ORDER_NOT_FOUND", cexerr.CategoryNotFound, "trigger order not found")
+	ErrTriggerOrderNotPending = cexerr.New("FUTURES_TRIGGER_ORDER_NOT_PENDING", cexerr.CategoryFailedPrecondition, "trigger order is not pending")
+	ErrInvalidTriggerOrder    = cexerr.New("FUTURES_INVALID_TRIGGER_ORDER", cexerr.CategoryInvalidArgument, "invalid trigger order")
+)
+
+// =============================================================================
+// 条件单类型 / 状态
+// =============================================================================
+
+// TriggerKind 条件单类型
+//
+// Side 的含义：
+//   - Stop: 要开的仓位方向
+//   - TakeProfit / StopLoss / TrailingStop: 要平的持仓方向
+type TriggerKind int8
+
+const (
+	TriggerStop         TriggerKind = iota + 1 // 计划委托：突破触发价开仓（多: 标记价 >= 触发价；空: <=）
+	TriggerTakeProfit                          // 止盈平仓（多: 标记价 >= 触发价；空: <=）
+	TriggerStopLoss                            // 止损平仓（多: 标记价 <= 触发价；空: >=）
+	TriggerTrailingStop                        // 跟踪止损：从最有利价格回撤 CallbackRate 时平仓
+)
+
+func (k TriggerKind) String() string {
+	switch k {
+	case TriggerStop:
+		return "STOP"
+	case TriggerTakeProfit:
+		return "TAKE_PROFIT"
+	case TriggerStopLoss:
+		return "STOP_LOSS"
+	case TriggerTrailingStop:
+		return "TRAILING_STOP"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// IsClose 是否为平仓类条件单
+func (k TriggerKind) IsClose() bool {
+	return k == TriggerTakeProfit || k == TriggerStopLoss || k == TriggerTrailingStop
+}
+
+// TriggerStatus 条件单状态
+//
+//	Pending ──→ Triggered ──→ (下单失败) Failed
+//	   └──────→ Canceled
+type TriggerStatus int8
+
+const (
+	TriggerPending   TriggerStatus = iota + 1 // 等待触发
+	TriggerTriggered                          // 已触发并下单
+	TriggerCanceled                           // 用户撤销
+	TriggerFailed                             // 已触发但下单失败
+)
+
+func (s TriggerStatus) String() string {
+	switch s {
+	case TriggerPending:
+		return "PENDING"
+	case TriggerTriggered:
+		return "TRIGGERED"
+	case TriggerCanceled:
+		return "CANCELED"
+	case TriggerFailed:
+		return "FAILED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// =============================================================================
+// TriggerOrder
+// =============================================================================
+
+// TriggerOrder 条件单
+type TriggerOrder struct {
+	ID       int64       `gorm:"primaryKey;autoIncrement"`
+	UserID   int64       `gorm:"column:user_id;index"`
+	Symbol   string      `gorm:"column:symbol;type:varchar(32)"`
+	Kind     TriggerKind `gorm:"column:kind"`
+	Side     Side        `gorm:"column:side"`
+	Qty      int64       `gorm:"column:qty"`      // 数量，平仓类 0 表示全部
+	Leverage int         `gorm:"column:leverage"` // 仅计划委托 (开仓)
+
+	TriggerPrice int64 `gorm:"column:trigger_price"` // 触发价 (标记价格)，跟踪止损不用
+	OrderPrice   int64 `gorm:"column:order_price"`   // 触发后的委托价，0 表示按触发时标记价
+
+	// 跟踪止损
+	CallbackRate int64 `gorm:"column:callback_rate"` // 回撤比例 (万分比)
+	ExtremePrice int64 `gorm:"column:extreme_price"` // 触发前见过的最有利价格 (多: 最高；空: 最低)
+
+	Status           TriggerStatus `gorm:"column:status;index"`
+	TriggerMarkPrice int64         `gorm:"column:trigger_mark_price"` // 触发时的标记价格
+	TriggeredAt      int64         `gorm:"column:triggered_at"`
+	FailReason       string        `gorm:"column:fail_reason;type:varchar(255)"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+	UpdatedAt int64 `gorm:"column:updated_at"`
+}
+
+// TableName GORM 表名
+func (TriggerOrder) TableName() string {
+	return "trigger_orders"
+}
+
+// shouldTrigger 按最新标记价格判断是否触发
+// 跟踪止损会顺带推进 ExtremePrice，返回 moved 表示极值有变化
+func (t *TriggerOrder) shouldTrigger(markPrice int64) (fire, moved bool) {
+	long := t.Side == SideLong
+	switch t.Kind {
+	case TriggerStop, TriggerTakeProfit:
+		if long {
+			return markPrice >= t.TriggerPrice, false
+		}
+		return markPrice <= t.TriggerPrice, false
+
+	case TriggerStopLoss:
+		if long {
+			return markPrice <= t.TriggerPrice, false
+		}
+		return markPrice >= t.TriggerPrice, false
+
+	case TriggerTrailingStop:
+		if t.ExtremePrice == 0 || (long && markPrice > t.ExtremePrice) || (!long && markPrice < t.ExtremePrice) {
+			t.ExtremePrice = markPrice
+			return false, true
+		}
+		if long {
+			return markPrice <= fixed.ApplyBps(t.ExtremePrice, -t.CallbackRate, fixed.RoundDown), false
+		}
+		return markPrice >= fixed.ApplyBps(t.ExtremePrice, t.CallbackRate, fixed.RoundDown), false
+	}
+	return false, false
+}
+
+// validate 下单参数检查
+func (t *TriggerOrder) validate() error {
+	if t.UserID == 0 || t.Symbol == "" {
+		return ErrInvalidTriggerOrder
+	}
+	if t.Side != SideLong && t.Side != SideShort {
+		return ErrInvalidTriggerOrder
+	}
+	if t.Qty < 0 || t.OrderPrice < 0 {
+		return ErrInvalidTriggerOrder
+	}
+
+	switch t.Kind {
+	case TriggerStop:
+		if t.Qty == 0 || t.TriggerPrice <= 0 || t.Leverage <= 0 {
+			return ErrInvalidTriggerOrder
+		}
+	case TriggerTakeProfit, TriggerStopLoss:
+		if t.TriggerPrice <= 0 {
+			return ErrInvalidTriggerOrder
+		}
+	case TriggerTrailingStop:
+		if t.CallbackRate <= 0 || t.CallbackRate >= RatePrecision {
+			return ErrInvalidTriggerOrder
+		}
+	default:
+		return ErrInvalidTriggerOrder
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// 文件: pkg/futures/trigger_order_repo.go
+// 条件单存储 (MySQL)
+//
+// 【幂等触发】
+// 状态迁移一律用 UPDATE ... WHERE id = ? AND status = ? 做 CAS：
+// 多个实例同时看到价格穿越，只有一个能把 Pending 改成 Triggered，其余直接跳过
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TriggerOrderRepository 条件单存储接口
+type TriggerOrderRepository interface {
+	// Create 创建条件单 (回填 ID)
+	Create(ctx context.Context, t *TriggerOrder) error
+
+	// Get 根据 ID 查询，不存在返回 ErrTriggerOrderNotFound
+	Get(ctx context.Context, id int64) (*TriggerOrder, error)
+
+	// ListPending 所有等待触发的条件单 (启动时加载)
+	ListPending(ctx context.Context) ([]*TriggerOrder, error)
+
+	// ListByUser 用户条件单，status 为 0 表示全部，按 ID 倒序
+	ListByUser(ctx context.Context, userID int64, status TriggerStatus, limit int) ([]*TriggerOrder, error)
+
+	// CompareAndSetStatus 仅当当前状态为 from 时，写入 t 的状态及触发信息
+	// 返回 false 表示状态已被其他实例/请求改变
+	CompareAndSetStatus(ctx context.Context, t *TriggerOrder, from TriggerStatus) (bool, error)
+
+	// UpdateExtremePrice 更新跟踪止损的极值价格
+	UpdateExtremePrice(ctx context.Context, id int64, price int64) error
+}
+
+// 确保实现了接口
+var _ TriggerOrderRepository = (*MySQLTriggerOrderRepository)(nil)
+
+// MySQLTriggerOrderRepository MySQL 实现
+type MySQLTriggerOrderRepository struct {
+	db *gorm.DB
+}
+
+// NewMySQLTriggerOrderRepository 创建条件单存储
+func NewMySQLTriggerOrderRepository(db *gorm.DB) *MySQLTriggerOrderRepository {
+	return &MySQLTriggerOrderRepository{db: db}
+}
+
+// Create 创建条件单
+func (r *MySQLTriggerOrderRepository) Create(ctx context.Context, t *TriggerOrder) error {
+	now := time.Now().UnixMilli()
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+// Get 根据 ID 查询
+func (r *MySQLTriggerOrderRepository) Get(ctx context.Context, id int64) (*TriggerOrder, error) {
+	var t TriggerOrder
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTriggerOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListPending 所有等待触发的条件单
+func (r *MySQLTriggerOrderRepository) ListPending(ctx context.Context) ([]*TriggerOrder, error) {
+	var list []*TriggerOrder
+	err := r.db.WithContext(ctx).
+		Where("status = ?", TriggerPending).
+		Order("id ASC").
+		Find(&list).Error
+	return list, err
+}
+
+// ListByUser 用户条件单
+func (r *MySQLTriggerOrderRepository) ListByUser(ctx context.Context, userID int64, status TriggerStatus, limit int) ([]*TriggerOrder, error) {
+	query := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+
+	var list []*TriggerOrder
+	err := query.Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// CompareAndSetStatus CAS 状态迁移
+func (r *MySQLTriggerOrderRepository) CompareAndSetStatus(ctx context.Context, t *TriggerOrder, from TriggerStatus) (bool, error) {
+	t.UpdatedAt = time.Now().UnixMilli()
+	result := r.db.WithContext(ctx).
+		Model(&TriggerOrder{}).
+		Where("id = ? AND status = ?", t.ID, from).
+		Updates(map[string]interface{}{
+			"status":             t.Status,
+			"trigger_mark_price": t.TriggerMarkPrice,
+			"triggered_at":       t.TriggeredAt,
+			"fail_reason":        t.FailReason,
+			"updated_at":         t.UpdatedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateExtremePrice 更新跟踪止损极值
+func (r *MySQLTriggerOrderRepository) UpdateExtremePrice(ctx context.Context, id int64, price int64) error {
+	return r.db.WithContext(ctx).
+		Model(&TriggerOrder{}).
+		Where("id = ? AND status = ?", id, TriggerPending).
+		Updates(map[string]interface{}{
+			"extreme_price": price,
+			"updated_at":    time.Now().UnixMilli(),
+		}).Error
+}
@@ -0,0 +1,275 @@
+// 文件: pkg/futures/trigger_order_test.go
+// 条件单服务测试 (内存仓储，不依赖 MySQL)
+
+package futures
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// 内存条件单仓储 / 下单桩
+// =============================================================================
+
+type memTriggerOrderRepo struct {
+	mu     sync.Mutex
+	nextID int64
+	orders map[int64]*TriggerOrder
+}
+
+func newMemTriggerOrderRepo() *memTriggerOrderRepo {
+	return &memTriggerOrderRepo{orders: make(map[int64]*TriggerOrder)}
+}
+
+func (r *memTriggerOrderRepo) Create(ctx context.Context, t *TriggerOrder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	t.ID = r.nextID
+	cp := *t
+	r.orders[t.ID] = &cp
+	return nil
+}
+
+func (r *memTriggerOrderRepo) Get(ctx context.Context, id int64) (*TriggerOrder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.orders[id]
+	if !ok {
+		return nil, ErrTriggerOrderNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (r *memTriggerOrderRepo) ListPending(ctx context.Context) ([]*TriggerOrder, error) {
+	return r.list(func(t *TriggerOrder) bool { return t.Status == TriggerPending }), nil
+}
+
+func (r *memTriggerOrderRepo) ListByUser(ctx context.Context, userID int64, status TriggerStatus, limit int) ([]*TriggerOrder, error) {
+	out := r.list(func(t *TriggerOrder) bool {
+		return t.UserID == userID && (status == 0 || t.Status == status)
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out[:min(limit, len(out))], nil
+}
+
+func (r *memTriggerOrderRepo) CompareAndSetStatus(ctx context.Context, t *TriggerOrder, from TriggerStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.orders[t.ID]
+	if !ok || cur.Status != from {
+		return false, nil
+	}
+	cur.Status = t.Status
+	cur.TriggerMarkPrice = t.TriggerMarkPrice
+	cur.TriggeredAt = t.TriggeredAt
+	cur.FailReason = t.FailReason
+	return true, nil
+}
+
+func (r *memTriggerOrderRepo) UpdateExtremePrice(ctx context.Context, id int64, price int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.orders[id]; ok && t.Status == TriggerPending {
+		t.ExtremePrice = price
+	}
+	return nil
+}
+
+func (r *memTriggerOrderRepo) list(match func(*TriggerOrder) bool) []*TriggerOrder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*TriggerOrder
+	for _, t := range r.orders {
+		if match(t) {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+type fakeTriggerExecutor struct {
+	mu     sync.Mutex
+	opens  []OpenPositionRequest
+	closes []ClosePositionRequest
+	err    error
+}
+
+func (f *fakeTriggerExecutor) OpenPosition(ctx context.Context, req *OpenPositionRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.opens = append(f.opens, *req)
+	return nil
+}
+
+func (f *fakeTriggerExecutor) ClosePosition(ctx context.Context, req *ClosePositionRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.closes = append(f.closes, *req)
+	return nil
+}
+
+// =============================================================================
+// 测试
+// =============================================================================
+
+const triggerSymbol = "TESTBTCUSDT"
+
+func triggerFixture(t *testing.T, positions ...*Position) (*TriggerOrderService, *memTriggerOrderRepo, *fakeTriggerExecutor, *MarkPriceService) {
+	repo := newMemTriggerOrderRepo()
+	exec := &fakeTriggerExecutor{}
+	markPrices := NewMarkPriceService()
+	markPrices.UpdateMarkPrice(triggerSymbol, 50000)
+
+	svc := NewTriggerOrderService(repo, exec, newMemPositionRepo(positions...), markPrices)
+	require.NoError(t, svc.Start(context.Background()))
+	return svc, repo, exec, markPrices
+}
+
+func TestTriggerOrder_StopLossAndTakeProfit(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, exec, markPrices := triggerFixture(t,
+		&Position{UserID: 1, Symbol: triggerSymbol, Size: 2 * Precision, EntryPrice: 50000})
+
+	sl := &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerStopLoss, Side: SideLong, TriggerPrice: 48000}
+	tp := &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerTakeProfit, Side: SideLong, TriggerPrice: 55000, Qty: Precision}
+	require.NoError(t, svc.Place(ctx, sl))
+	require.NoError(t, svc.Place(ctx, tp))
+	assert.Equal(t, 2, svc.ActiveCount())
+
+	// 未到触发价
+	markPrices.UpdateMarkPrice(triggerSymbol, 49000)
+	assert.Empty(t, exec.closes)
+
+	// 穿过止损价：只触发止损，且同一价格重复推送不会再次下单
+	markPrices.UpdateMarkPrice(triggerSymbol, 47900)
+	markPrices.UpdateMarkPrice(triggerSymbol, 47900)
+	require.Len(t, exec.closes, 1)
+	assert.Equal(t, ClosePositionRequest{UserID: 1, Symbol: triggerSymbol}, exec.closes[0])
+
+	got, err := svc.Get(ctx, 1, sl.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TriggerTriggered, got.Status)
+	assert.Equal(t, int64(47900), got.TriggerMarkPrice)
+
+	// 止盈仍在等待；撤销后价格再高也不触发
+	require.NoError(t, svc.Cancel(ctx, 1, tp.ID))
+	assert.ErrorIs(t, svc.Cancel(ctx, 1, tp.ID), ErrTriggerOrderNotPending)
+	markPrices.UpdateMarkPrice(triggerSymbol, 56000)
+	assert.Len(t, exec.closes, 1)
+	assert.Equal(t, 0, svc.ActiveCount())
+
+	// 其他用户看不到
+	_, err = svc.Get(ctx, 2, sl.ID)
+	assert.ErrorIs(t, err, ErrTriggerOrderNotFound)
+
+	list, err := svc.ListByUser(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, TriggerCanceled, list[0].Status)
+
+	pending, _ := repo.ListPending(ctx)
+	assert.Empty(t, pending)
+}
+
+func TestTriggerOrder_StopOpensAtMarkPrice(t *testing.T) {
+	ctx := context.Background()
+	svc, _, exec, markPrices := triggerFixture(t)
+
+	assert.ErrorIs(t, svc.Place(ctx, &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerStop, Side: SideShort, TriggerPrice: 45000}),
+		ErrInvalidTriggerOrder) // 缺数量和杠杆
+
+	stop := &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerStop, Side: SideShort,
+		TriggerPrice: 45000, Qty: Precision, Leverage: 10}
+	require.NoError(t, svc.Place(ctx, stop))
+
+	markPrices.UpdateMarkPrice(triggerSymbol, 44500)
+	require.Len(t, exec.opens, 1)
+	assert.Equal(t, OpenPositionRequest{UserID: 1, Symbol: triggerSymbol, Side: SideShort,
+		Qty: Precision, Price: 44500, Leverage: 10}, exec.opens[0])
+}
+
+func TestTriggerOrder_TrailingStop(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, exec, markPrices := triggerFixture(t,
+		&Position{UserID: 1, Symbol: triggerSymbol, Size: Precision, EntryPrice: 50000})
+
+	// 回撤 2%
+	ts := &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerTrailingStop, Side: SideLong, CallbackRate: 200}
+	require.NoError(t, svc.Place(ctx, ts))
+	assert.Equal(t, int64(50000), ts.ExtremePrice)
+
+	markPrices.UpdateMarkPrice(triggerSymbol, 60000) // 新高
+	markPrices.UpdateMarkPrice(triggerSymbol, 59000) // 回撤 1.7%
+	assert.Empty(t, exec.closes)
+
+	stored, _ := repo.Get(ctx, ts.ID)
+	assert.Equal(t, int64(60000), stored.ExtremePrice)
+
+	markPrices.UpdateMarkPrice(triggerSymbol, 58800) // 回撤 2%
+	require.Len(t, exec.closes, 1)
+}
+
+func TestTriggerOrder_IdempotentAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	pos := &Position{UserID: 1, Symbol: triggerSymbol, Size: -Precision, EntryPrice: 50000}
+	svc1, repo, exec1, _ := triggerFixture(t, pos)
+	require.NoError(t, svc1.Place(ctx, &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerStopLoss,
+		Side: SideShort, TriggerPrice: 52000}))
+
+	// 第二个实例从同一个库加载
+	exec2 := &fakeTriggerExecutor{}
+	svc2 := NewTriggerOrderService(repo, exec2, newMemPositionRepo(pos), NewMarkPriceService())
+	require.NoError(t, svc2.Start(ctx))
+	require.Equal(t, 1, svc2.ActiveCount())
+
+	svc1.OnMarkPrice(ctx, triggerSymbol, 52500)
+	svc2.OnMarkPrice(ctx, triggerSymbol, 52500)
+
+	assert.Len(t, exec1.closes, 1)
+	assert.Empty(t, exec2.closes, "second instance must lose the CAS")
+}
+
+func TestTriggerOrder_ExecuteFailure(t *testing.T) {
+	ctx := context.Background()
+	// 持仓方向与止损单不一致 (用户已反手做空)
+	svc, _, exec, _ := triggerFixture(t,
+		&Position{UserID: 1, Symbol: triggerSymbol, Size: -Precision, EntryPrice: 50000})
+
+	sl := &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerStopLoss, Side: SideLong, TriggerPrice: 48000}
+	require.NoError(t, svc.Place(ctx, sl))
+	svc.OnMarkPrice(ctx, triggerSymbol, 47000)
+
+	assert.Empty(t, exec.closes)
+	got, err := svc.Get(ctx, 1, sl.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TriggerFailed, got.Status)
+	assert.Equal(t, errNoMatchingPosition.Error(), got.FailReason)
+
+	// 下单报错同样记为失败
+	exec.err = errors.New("insufficient margin")
+	stop := &TriggerOrder{UserID: 1, Symbol: triggerSymbol, Kind: TriggerStop, Side: SideLong,
+		TriggerPrice: 49000, Qty: Precision, Leverage: 5}
+	require.NoError(t, svc.Place(ctx, stop))
+	svc.OnMarkPrice(ctx, triggerSymbol, 49500)
+
+	got, _ = svc.Get(ctx, 1, stop.ID)
+	assert.Equal(t, TriggerFailed, got.Status)
+	assert.Equal(t, "insufficient margin", got.FailReason)
+}
@@ -3,27 +3,34 @@
 
 package futures
 
-import "errors"
-
 // ValidateCreateRequest 验证创建请求
 func ValidateCreateRequest(req *CreateContractRequest) error {
 	if req.Symbol == "" {
-		return errors.New("symbol is required")
+		return ErrInvalidSpec.Wrapf("symbol is required")
 	}
 	if req.BaseCurrency == "" || req.QuoteCurrency == "" {
-		return errors.New("base/quote currency is required")
+		return ErrInvalidSpec.Wrapf("base/quote currency is required")
+	}
+	if req.Inverse {
+		// 反向合约用基础币结算
+		if req.SettleCurrency == "" {
+			req.SettleCurrency = req.BaseCurrency
+		}
+		if req.SettleCurrency != req.BaseCurrency {
+			return ErrInvalidSpec.Wrapf("inverse contract must settle in base currency")
+		}
 	}
 	if req.SettleCurrency == "" {
 		req.SettleCurrency = req.QuoteCurrency // 默认用报价货币结算
 	}
 	if req.ContractSize <= 0 {
-		return errors.New("contract size must be positive")
+		return ErrInvalidSpec.Wrapf("contract size must be positive")
 	}
 	if req.TickSize <= 0 {
-		return errors.New("tick size must be positive")
+		return ErrInvalidSpec.Wrapf("tick size must be positive")
 	}
 	if req.MaxLeverage <= 0 || req.MaxLeverage > 200 {
-		return errors.New("max leverage must be between 1 and 200")
+		return ErrInvalidSpec.Wrapf("max leverage must be between 1 and 200")
 	}
 	if req.InitialMarginRate <= 0 {
 		// 自动计算: 初始保证金率 = 1/杠杆
@@ -34,21 +41,27 @@ func ValidateCreateRequest(req *CreateContractRequest) error {
 		req.MaintMarginRate = req.InitialMarginRate / 2
 	}
 	if req.MaintMarginRate >= req.InitialMarginRate {
-		return errors.New("maint margin rate must be less than initial margin rate")
+		return ErrInvalidSpec.Wrapf("maint margin rate must be less than initial margin rate")
+	}
+	if req.LiquidationFeeRate < 0 || req.LiquidationFeeRate > req.MaintMarginRate {
+		return ErrInvalidSpec.Wrapf("liquidation fee rate must be between 0 and maint margin rate")
 	}
 	if req.ContractType == TypePerpetual {
 		if req.FundingInterval <= 0 {
 			req.FundingInterval = 8 * 3600 // 默认 8 小时
 		}
 		if req.MaxFundingRate <= 0 {
-			req.MaxFundingRate = 75 // 默认 0.75%
+			req.MaxFundingRate = MaxFundingRate // 默认 0.75%
+		}
+		if err := validateFundingParams(req.FundingInterval, req.MaxFundingRate, req.MinFundingRate, req.InterestRate); err != nil {
+			return err
 		}
 	}
 	if req.ContractType == TypeDelivery && req.ExpiryAt <= 0 {
-		return errors.New("delivery contract requires expiry time")
+		return ErrInvalidSpec.Wrapf("delivery contract requires expiry time")
 	}
 	if len(req.PriceSources) == 0 {
-		return errors.New("at least one price source is required")
+		return ErrInvalidSpec.Wrapf("at least one price source is required")
 	}
 	return nil
 }
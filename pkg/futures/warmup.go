@@ -0,0 +1,179 @@
+// 文件: pkg/futures/warmup.go
+// 启动预热 - 开放下单之前预加载全部可交易合约和热点用户的余额 / 持仓
+//
+// 【问题】重启后缓存全是冷的：大户的第一笔单要依次回源合约规格、持仓、冷钱包余额、
+// 热钱包资产状态，延迟是平时的几十倍，而大户恰好对延迟最敏感
+//
+// 【做法】启动编排在开放下单入口之前调用 Warmup：
+//
+//	HoldIntake ──→ 合约规格 (列表 + 逐个) ──→ 热点用户并发预热 ──→ 开放下单
+//	                                          ├─ 持仓 (回填 Redis)
+//	                                          ├─ 各结算币种保证金 (余额 + 持仓 + 抵押品)
+//	                                          └─ WarmupConfig.Preload (如热钱包资产状态)
+//
+// 预热期间开仓返回可重试的 ErrWarmingUp；平仓不拦 (止损不能等预热)
+//
+// 【注意】
+//   - 预热只是优化：单个用户失败只计数，超时放弃剩余用户，最后总会开放下单
+//   - 合约列表读不到时返回错误 (DB 多半不可用)，同样会开放下单，由调用方决定是否继续启动
+//   - 入口在 Warmup 之前就已对外时，先调 HoldIntake 关闭开仓，否则预热期间的开仓照常处理
+
+package futures
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+var ErrWarmingUp = cexerr.NewRetryable("FUTURES_WARMING_UP", cexerr.CategoryUnavailable, "futures processor warming up")
+
+// HotUserSource 近期最活跃的用户 (按成交额 / 下单数排序，由统计服务实现)
+type HotUserSource interface {
+	HotUsers(ctx context.Context, limit int) ([]int64, error)
+}
+
+// WarmupConfig 预热配置
+type WarmupConfig struct {
+	Users       HotUserSource                                 // 可选，nil 时只预热合约
+	TopN        int                                           // 预热的用户数，默认 1000
+	Concurrency int                                           // 并发预热的用户数，默认 16
+	Timeout     time.Duration                                 // 整个预热的上限，默认 30s
+	Preload     func(ctx context.Context, userID int64) error // 额外的按用户预热 (可选)，如热钱包 QueryUserState
+}
+
+func (c WarmupConfig) withDefaults() WarmupConfig {
+	if c.TopN <= 0 {
+		c.TopN = 1000
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 16
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	return c
+}
+
+// WarmupReport 预热结果
+type WarmupReport struct {
+	Contracts int           // 预加载的可交易合约数
+	Users     int           // 预热完成的用户数
+	Failed    int           // 预热失败的用户数
+	Skipped   int           // 超时未预热的用户数
+	Duration  time.Duration // 总耗时 (含合约)
+}
+
+// HoldIntake 关闭开仓入口 (返回 ErrWarmingUp)，直到 Warmup 结束
+func (p *FuturesProcessor) HoldIntake() {
+	p.warming.Store(true)
+}
+
+// WarmingUp 是否处于预热中 (开仓入口关闭)
+func (p *FuturesProcessor) WarmingUp() bool {
+	return p.warming.Load()
+}
+
+// Warmup 预加载可交易合约与热点用户，结束后开放开仓入口 (无论成功与否)
+func (p *FuturesProcessor) Warmup(ctx context.Context, cfg WarmupConfig) (report WarmupReport, err error) {
+	cfg = cfg.withDefaults()
+	p.warming.Store(true)
+	defer p.warming.Store(false)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	defer func() { report.Duration = time.Since(start) }()
+
+	// 1. 合约：列表缓存 + 逐个规格缓存 (下单走 GetContract)
+	specs, err := p.contractManager.GetTradingContracts(ctx)
+	if err != nil {
+		return report, err
+	}
+	var currencies []string
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if _, err := p.contractManager.GetContract(ctx, spec.Symbol); err != nil {
+			return report, err
+		}
+		if c := settleCurrency(spec); !seen[c] {
+			seen[c] = true
+			currencies = append(currencies, c)
+		}
+	}
+	report.Contracts = len(specs)
+
+	if cfg.Users == nil {
+		return report, nil
+	}
+	users, err := cfg.Users.HotUsers(ctx, cfg.TopN)
+	if err != nil {
+		return report, err
+	}
+	if len(users) > cfg.TopN {
+		users = users[:cfg.TopN]
+	}
+
+	// 2. 热点用户：固定并发，超时后剩余的跳过
+	var warmed, failed atomic.Int64
+	queue := make(chan int64)
+	var wg sync.WaitGroup
+	for range min(cfg.Concurrency, len(users)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for userID := range queue {
+				if ctx.Err() != nil {
+					continue // 已超时，算作跳过
+				}
+				if err := p.warmUser(ctx, userID, currencies, cfg.Preload); err != nil {
+					failed.Add(1)
+				} else {
+					warmed.Add(1)
+				}
+			}
+		}()
+	}
+feed:
+	for _, userID := range users {
+		select {
+		case queue <- userID:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	report.Users = int(warmed.Load())
+	report.Failed = int(failed.Load())
+	report.Skipped = len(users) - report.Users - report.Failed
+	return report, nil
+}
+
+// warmUser 预热单个用户：持仓缓存、各结算币种的保证金 (余额 + 持仓 + 抵押品)、额外预热
+func (p *FuturesProcessor) warmUser(ctx context.Context, userID int64, currencies []string, preload func(context.Context, int64) error) error {
+	positions, err := p.positionRepo.GetByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// GetByUser 直接读 DB，逐个读一次把持仓回填进缓存 (成交处理走 GetByUserAndSymbol)
+	for _, pos := range positions {
+		if _, err := p.positionRepo.GetByUserAndSymbol(ctx, userID, pos.Symbol); err != nil {
+			return err
+		}
+	}
+	for _, c := range currencies {
+		if _, err := p.AccountMargin(ctx, userID, c); err != nil {
+			return err
+		}
+	}
+	if preload != nil {
+		return preload(ctx, userID)
+	}
+	return nil
+}
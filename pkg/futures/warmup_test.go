@@ -0,0 +1,78 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"max.com/pkg/cexerr"
+)
+
+type staticHotUsers []int64
+
+func (s staticHotUsers) HotUsers(ctx context.Context, limit int) ([]int64, error) {
+	return s, nil
+}
+
+func TestWarmup_HoldsIntakeUntilDone(t *testing.T) {
+	h := newHarness(t, harnessLinearSpec(), harnessInverseSpec())
+	proc := h.procs["TESTBTCUSDT"]
+	h.ledger.AddAvailable(h.ctx, 1, "USDT", 10000*Precision)
+
+	open := &OpenPositionRequest{UserID: 1, Symbol: "TESTBTCUSDT", Side: SideLong, Qty: Precision, Price: 50000 * Precision, Leverage: 10}
+	proc.HoldIntake()
+	err := proc.OpenPosition(h.ctx, open)
+	assert.ErrorIs(t, err, ErrWarmingUp)
+	assert.True(t, cexerr.IsRetryable(err))
+
+	var mu sync.Mutex
+	var preloaded []int64
+	report, err := proc.Warmup(h.ctx, WarmupConfig{
+		Users:       staticHotUsers{1, 2, 3, 4},
+		TopN:        3,
+		Concurrency: 2,
+		Preload: func(ctx context.Context, userID int64) error {
+			assert.True(t, proc.WarmingUp())
+			mu.Lock()
+			defer mu.Unlock()
+			preloaded = append(preloaded, userID)
+			if userID == 2 {
+				return errors.New("asset engine unavailable")
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Contracts)
+	assert.Equal(t, 2, report.Users)
+	assert.Equal(t, 1, report.Failed) // 单个用户失败不影响开放下单
+	assert.Zero(t, report.Skipped)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, preloaded) // 只取前 TopN 个
+
+	assert.False(t, proc.WarmingUp())
+	require.NoError(t, proc.OpenPosition(h.ctx, open))
+}
+
+func TestWarmup_TimeoutSkipsRemaining(t *testing.T) {
+	h := newHarness(t, harnessLinearSpec())
+	proc := h.procs["TESTBTCUSDT"]
+
+	report, err := proc.Warmup(h.ctx, WarmupConfig{
+		Users:       staticHotUsers{1, 2, 3, 4, 5},
+		Concurrency: 1,
+		Timeout:     50 * time.Millisecond,
+		Preload: func(ctx context.Context, userID int64) error {
+			<-ctx.Done() // 卡住直到超时
+			return ctx.Err()
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 4, report.Skipped)
+	assert.False(t, proc.WarmingUp())
+}
@@ -0,0 +1,111 @@
+package indexprice
+
+import (
+	"sort"
+)
+
+// =============================================================================
+// 聚合: 过期过滤 → 偏离过滤 → 加权中位数
+// =============================================================================
+
+// Exclusion 被剔除的报价及原因
+type Exclusion struct {
+	Source string
+	Price  int64
+	Reason string // "stale" / "deviation"
+}
+
+// Index 一次聚合结果
+type Index struct {
+	Symbol    string
+	Price     int64
+	Sources   []string    // 参与计算的源
+	Excluded  []Exclusion // 被剔除的源
+	UpdatedAt int64       // 毫秒
+}
+
+// aggregate 聚合一个交易对的报价
+//
+// 1. 过期: now - Timestamp > staleAfter 的报价剔除
+// 2. 偏离: 与剩余报价 (不加权) 中位数偏离超过 maxDeviation (万分比) 的剔除
+// 3. 剩余源数不足 minSources 返回 ErrNoValidSource
+// 4. 加权中位数
+//
+// 【注意】只有两个源且互相偏离时，两个都会被剔除：分不清谁是错的，宁可不更新
+func aggregate(quotes []Quote, weights func(string) int, now, staleAfter, maxDeviation int64, minSources int) (int64, []string, []Exclusion, error) {
+	var excluded []Exclusion
+	fresh := make([]Quote, 0, len(quotes))
+	for _, q := range quotes {
+		if now-q.Timestamp > staleAfter {
+			excluded = append(excluded, Exclusion{Source: q.Source, Price: q.Price, Reason: "stale"})
+			continue
+		}
+		fresh = append(fresh, q)
+	}
+
+	valid := fresh
+	if maxDeviation > 0 && len(fresh) > 1 {
+		ref := median(fresh)
+		valid = fresh[:0:0]
+		for _, q := range fresh {
+			diff := q.Price - ref
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff*10000 > ref*maxDeviation {
+				excluded = append(excluded, Exclusion{Source: q.Source, Price: q.Price, Reason: "deviation"})
+				continue
+			}
+			valid = append(valid, q)
+		}
+	}
+
+	if len(valid) == 0 || len(valid) < minSources {
+		return 0, nil, excluded, ErrNoValidSource
+	}
+
+	sources := make([]string, len(valid))
+	for i, q := range valid {
+		sources[i] = q.Source
+	}
+	return weightedMedian(valid, weights), sources, excluded, nil
+}
+
+// median 不加权中位数 (偶数个取中间两个的均值)
+func median(quotes []Quote) int64 {
+	prices := make([]int64, len(quotes))
+	for i, q := range quotes {
+		prices[i] = q.Price
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	mid := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return (prices[mid-1] + prices[mid]) / 2
+	}
+	return prices[mid]
+}
+
+// weightedMedian 加权中位数
+//
+// 按价格排序后累加权重，第一个累计权重过半的价格；恰好一半时取与下一个价格的均值
+func weightedMedian(quotes []Quote, weights func(string) int) int64 {
+	sorted := make([]Quote, len(quotes))
+	copy(sorted, quotes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Price < sorted[j].Price })
+
+	total := 0
+	for _, q := range sorted {
+		total += weights(q.Source)
+	}
+	cum := 0
+	for i, q := range sorted {
+		cum += weights(q.Source)
+		switch {
+		case cum*2 > total:
+			return q.Price
+		case cum*2 == total && i+1 < len(sorted):
+			return (q.Price + sorted[i+1].Price) / 2
+		}
+	}
+	return sorted[len(sorted)-1].Price
+}
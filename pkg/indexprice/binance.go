@@ -0,0 +1,135 @@
+package indexprice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// =============================================================================
+// Binance 现货
+// =============================================================================
+//
+// REST: GET /api/v3/ticker/price?symbol=BTCUSDT → {"symbol":"BTCUSDT","price":"65000.01000000"}
+// WS:   /stream?streams=btcusdt@miniTicker/...   → {"stream":"btcusdt@miniTicker","data":{"s":"BTCUSDT","c":"65000.01",...}}
+//
+// 服务端每 3 分钟发 ping，wsConn 自动回 pong
+
+const (
+	BinanceRESTURL = "https://api.binance.com"
+	BinanceWSURL   = "wss://stream.binance.com:9443"
+)
+
+// BinanceAdapter Binance 现货行情
+type BinanceAdapter struct {
+	RESTURL string       // 为空使用 BinanceRESTURL
+	WSURL   string       // 为空使用 BinanceWSURL
+	Client  *http.Client // 为空使用 http.DefaultClient
+}
+
+var (
+	_ Poller   = (*BinanceAdapter)(nil)
+	_ Streamer = (*BinanceAdapter)(nil)
+)
+
+func (a *BinanceAdapter) Name() string { return "binance" }
+
+func binanceSymbol(p Pair) string {
+	return strings.ToUpper(p.Base) + p.venueQuote()
+}
+
+// FetchPrice 实现 Poller
+func (a *BinanceAdapter) FetchPrice(ctx context.Context, pair Pair) (Quote, error) {
+	base := a.RESTURL
+	if base == "" {
+		base = BinanceRESTURL
+	}
+	var body struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+		Code   int    `json:"code"`
+		Msg    string `json:"msg"`
+	}
+	target := base + "/api/v3/ticker/price?symbol=" + url.QueryEscape(binanceSymbol(pair))
+	if err := getJSON(ctx, a.Client, target, &body); err != nil {
+		return Quote{}, err
+	}
+	if body.Code != 0 {
+		return Quote{}, ErrVenueRejected.Wrapf("binance %d: %s", body.Code, body.Msg)
+	}
+	price, err := parsePrice(body.Price)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Source: a.Name(), Pair: pair, Price: price}, nil
+}
+
+// Stream 实现 Streamer：组合流订阅 miniTicker，取最新成交价
+func (a *BinanceAdapter) Stream(ctx context.Context, pairs []Pair, emit func(Quote)) error {
+	base := a.WSURL
+	if base == "" {
+		base = BinanceWSURL
+	}
+	streams := make([]string, len(pairs))
+	bySymbol := make(map[string]Pair, len(pairs))
+	for i, p := range pairs {
+		sym := binanceSymbol(p)
+		streams[i] = strings.ToLower(sym) + "@miniTicker"
+		bySymbol[sym] = p
+	}
+
+	conn, err := wsDial(ctx, base+"/stream?streams="+strings.Join(streams, "/"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer conn.closeOnDone(ctx)()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var frame struct {
+			Data struct {
+				Symbol string `json:"s"`
+				Close  string `json:"c"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(msg, &frame); err != nil {
+			continue
+		}
+		pair, ok := bySymbol[frame.Data.Symbol]
+		if !ok {
+			continue
+		}
+		price, err := parsePrice(frame.Data.Close)
+		if err != nil {
+			continue
+		}
+		emit(Quote{Source: a.Name(), Pair: pair, Price: price})
+	}
+}
+
+// getJSON GET 并解析 JSON；非 2xx 也尝试解析 (交易所错误体带 code/msg)
+func getJSON(ctx context.Context, client *http.Client, target string, out any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("indexprice: decode %s (HTTP %d): %w", target, resp.StatusCode, err)
+	}
+	return nil
+}
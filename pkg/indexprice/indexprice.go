@@ -0,0 +1,109 @@
+// Package indexprice 指数价格服务：从外部交易所现货价格聚合合约指数价格
+//
+// 合约的 PriceSources 列出参与指数的交易所 ("binance"/"okx" ...)，
+// 每个交易所一个适配器，WS 推送为主、REST 轮询兜底：
+//
+//	Binance WS/REST ─┐
+//	OKX     WS/REST ─┼──→ Service.OnQuote ──→ 过期/偏离过滤 ──→ 加权中位数 ──→ Publisher (MarkPriceService)
+//	...             ─┘
+//
+// 【面试】为什么用加权中位数而不是加权平均？
+// 加权平均会被单个交易所的插针拉偏 (一个源报 0，指数直接腰斩)；
+// 中位数天然忽略极端值，权重只决定"谁更靠近中间"，单个源被操纵很难带偏指数。
+//
+// 【注意】过滤后有效源不足时不发布，价格服务里保留上一次的指数；
+// 下游 (资金费率) 应结合 Index.UpdatedAt 判断指数是否过旧
+package indexprice
+
+import (
+	"context"
+	"strings"
+
+	"max.com/pkg/cexerr"
+)
+
+var (
+	ErrUnknownSource  = cexerr.New("INDEX_UNKNOWN_SOURCE", cexerr.CategoryInvalidArgument, "indexprice: unknown price source")
+	ErrNoValidSource  = cexerr.NewRetryable("INDEX_NO_VALID_SOURCE", cexerr.CategoryUnavailable, "indexprice: not enough valid price sources")
+	ErrInvalidPrice   = cexerr.New("INDEX_INVALID_PRICE", cexerr.CategoryInvalidArgument, "indexprice: invalid price")
+	ErrVenueRejected  = cexerr.NewRetryable("INDEX_VENUE_REJECTED", cexerr.CategoryUnavailable, "indexprice: venue returned an error")
+	ErrAlreadyRunning = cexerr.New("INDEX_ALREADY_RUNNING", cexerr.CategoryConflict, "indexprice: service already running")
+)
+
+// Pair 现货交易对
+type Pair struct {
+	Base  string // BTC
+	Quote string // USDT
+}
+
+func (p Pair) String() string { return p.Base + "/" + p.Quote }
+
+// venueQuote 外部交易所的计价币：反向合约以 USD 计价，外部取 USDT 现货
+func (p Pair) venueQuote() string {
+	if strings.EqualFold(p.Quote, "USD") {
+		return "USDT"
+	}
+	return strings.ToUpper(p.Quote)
+}
+
+// Quote 一个交易所的一次报价
+type Quote struct {
+	Source    string
+	Pair      Pair
+	Price     int64 // 定点价格 (×1e8)
+	Timestamp int64 // 本地收到的时间 (毫秒)，适配器留 0 由 Service 填；过期判断用本地时间，避免交易所时钟偏差
+}
+
+// =============================================================================
+// 适配器
+// =============================================================================
+
+// Adapter 交易所适配器，至少实现 Poller / Streamer 之一
+type Adapter interface {
+	Name() string
+}
+
+// Poller REST 轮询
+type Poller interface {
+	Adapter
+	FetchPrice(ctx context.Context, pair Pair) (Quote, error)
+}
+
+// Streamer WS 推送
+//
+// Stream 订阅 pairs，每收到一条报价调用 emit，连接断开或 ctx 取消时返回 (由 Service 负责重连)
+type Streamer interface {
+	Adapter
+	Stream(ctx context.Context, pairs []Pair, emit func(Quote)) error
+}
+
+// Publisher 指数价格的发布目标 (futures.MarkPriceService 实现)
+type Publisher interface {
+	UpdateIndexPrice(symbol string, indexPrice int64)
+}
+
+// =============================================================================
+// 工具
+// =============================================================================
+
+// parsePrice 十进制字符串转定点价格 (×1e8，与 futures.Precision 一致)，超过 8 位的小数截断
+func parsePrice(s string) (int64, error) {
+	intPart, fracPart, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if intPart == "" || len(intPart) > 10 {
+		return 0, ErrInvalidPrice.Wrapf("%q", s)
+	}
+	if len(fracPart) > 8 {
+		fracPart = fracPart[:8]
+	}
+	var v int64
+	for _, c := range intPart + fracPart + strings.Repeat("0", 8-len(fracPart)) {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidPrice.Wrapf("%q", s)
+		}
+		v = v*10 + int64(c-'0')
+	}
+	if v <= 0 {
+		return 0, ErrInvalidPrice.Wrapf("%q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,234 @@
+package indexprice
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const p1 = 100_000_000 // 1.0
+
+func TestParsePrice(t *testing.T) {
+	cases := map[string]int64{
+		"65000.01":          6500001000000,
+		"1":                 p1,
+		"0.123456789":       12345678,
+		"42000.10000000000": 4200010000000,
+	}
+	for in, want := range cases {
+		got, err := parsePrice(in)
+		if err != nil || got != want {
+			t.Errorf("parsePrice(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "-1", "abc", "1e5"} {
+		if _, err := parsePrice(in); !errors.Is(err, ErrInvalidPrice) {
+			t.Errorf("parsePrice(%q) expected ErrInvalidPrice, got %v", in, err)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	weights := func(src string) int { return map[string]int{"a": 50, "b": 30, "c": 20}[src] }
+	now := int64(100_000)
+	q := func(src string, price, ts int64) Quote { return Quote{Source: src, Price: price, Timestamp: ts} }
+
+	// 加权中位数: 累计权重恰好一半时取相邻两价均值
+	price, used, _, err := aggregate([]Quote{q("a", 100, now), q("b", 102, now), q("c", 104, now)}, weights, now, 10_000, 0, 1)
+	if err != nil || price != 101 || len(used) != 3 {
+		t.Fatalf("weighted median: %d %v %v", price, used, err)
+	}
+	price, _, _, _ = aggregate([]Quote{q("a", 103, now), q("b", 101, now), q("c", 102, now)}, weights, now, 10_000, 0, 1)
+	if price != 102 {
+		t.Fatalf("expected (102+103)/2, got %d", price)
+	}
+
+	// 过期剔除
+	price, used, excl, err := aggregate([]Quote{q("a", 100, now-20_000), q("b", 110, now)}, weights, now, 10_000, 0, 1)
+	if err != nil || price != 110 || len(used) != 1 || len(excl) != 1 || excl[0].Reason != "stale" {
+		t.Fatalf("stale: %d %v %v %v", price, used, excl, err)
+	}
+
+	// 偏离剔除: c 插针
+	price, used, excl, err = aggregate([]Quote{q("a", 10000, now), q("b", 10010, now), q("c", 5000, now)}, weights, now, 10_000, 300, 1)
+	if err != nil || len(used) != 2 || len(excl) != 1 || excl[0].Source != "c" || excl[0].Reason != "deviation" {
+		t.Fatalf("deviation: %d %v %v %v", price, used, excl, err)
+	}
+
+	// 有效源不足
+	if _, _, _, err := aggregate([]Quote{q("a", 100, now)}, weights, now, 10_000, 0, 2); !errors.Is(err, ErrNoValidSource) {
+		t.Fatalf("expected ErrNoValidSource, got %v", err)
+	}
+}
+
+type recordPublisher struct {
+	mu     sync.Mutex
+	prices map[string]int64
+}
+
+func (p *recordPublisher) UpdateIndexPrice(symbol string, price int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prices[symbol] = price
+}
+
+func (p *recordPublisher) get(symbol string) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.prices[symbol]
+}
+
+func TestService_PublishAndStale(t *testing.T) {
+	pub := &recordPublisher{prices: make(map[string]int64)}
+	now := time.UnixMilli(1_000_000)
+	svc := NewService(Config{StaleAfter: 5 * time.Second, MinSources: 2}, pub)
+	svc.SetClock(func() time.Time { return now })
+	svc.RegisterAdapter(&BinanceAdapter{})
+	svc.RegisterAdapter(&OKXAdapter{})
+
+	if err := svc.Track("BTCUSDT", Pair{"BTC", "USDT"}, []string{"binance", "huobi"}); !errors.Is(err, ErrUnknownSource) {
+		t.Fatalf("expected ErrUnknownSource, got %v", err)
+	}
+	pair := Pair{"BTC", "USDT"}
+	svc.Track("BTCUSDT", pair, []string{"binance", "okx"})
+	svc.Track("BTCUSDT_Q", pair, []string{"binance", "okx"})
+
+	svc.OnQuote(Quote{Source: "binance", Pair: pair, Price: 100 * p1})
+	if pub.get("BTCUSDT") != 0 {
+		t.Fatal("one source must not publish when MinSources=2")
+	}
+	svc.OnQuote(Quote{Source: "okx", Pair: pair, Price: 102 * p1})
+	if got := pub.get("BTCUSDT"); got != 100*p1 {
+		t.Fatalf("expected binance-weighted 100, got %d", got)
+	}
+	if got := pub.get("BTCUSDT_Q"); got != 100*p1 {
+		t.Fatalf("symbols sharing a pair must both publish, got %d", got)
+	}
+
+	// binance 过期后只剩 okx，不足 2 个源：保留上一次的指数
+	now = now.Add(6 * time.Second)
+	svc.OnQuote(Quote{Source: "okx", Pair: pair, Price: 103 * p1})
+	idx, ok := svc.Index("BTCUSDT")
+	if !ok || idx.Price != 100*p1 || len(idx.Excluded) != 1 || idx.Excluded[0].Source != "binance" {
+		t.Fatalf("unexpected index after stale %+v", idx)
+	}
+}
+
+func TestAdapters_REST(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/ticker/price" && r.URL.Query().Get("symbol") == "BTCUSDT":
+			io.WriteString(w, `{"symbol":"BTCUSDT","price":"65000.01000000"}`)
+		case r.URL.Path == "/api/v3/ticker/price":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"code":-1121,"msg":"Invalid symbol."}`)
+		case r.URL.Path == "/api/v5/market/ticker" && r.URL.Query().Get("instId") == "BTC-USDT":
+			io.WriteString(w, `{"code":"0","msg":"","data":[{"instId":"BTC-USDT","last":"65001.5"}]}`)
+		default:
+			io.WriteString(w, `{"code":"51001","msg":"Instrument ID does not exist","data":[]}`)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	bn := &BinanceAdapter{RESTURL: srv.URL}
+	q, err := bn.FetchPrice(ctx, Pair{"BTC", "USD"}) // 反向合约取 USDT 现货
+	if err != nil || q.Price != 6500001000000 || q.Source != "binance" {
+		t.Fatalf("binance: %+v %v", q, err)
+	}
+	if _, err := bn.FetchPrice(ctx, Pair{"XXX", "USDT"}); !errors.Is(err, ErrVenueRejected) {
+		t.Fatalf("binance error: %v", err)
+	}
+
+	okx := &OKXAdapter{RESTURL: srv.URL}
+	q, err = okx.FetchPrice(ctx, Pair{"BTC", "USDT"})
+	if err != nil || q.Price != 6500150000000 {
+		t.Fatalf("okx: %+v %v", q, err)
+	}
+	if _, err := okx.FetchPrice(ctx, Pair{"XXX", "USDT"}); !errors.Is(err, ErrVenueRejected) {
+		t.Fatalf("okx error: %v", err)
+	}
+}
+
+// wsServer 测试用 WebSocket 服务端：完成握手后交给 handle
+func wsServer(t *testing.T, handle func(conn net.Conn, br *bufio.Reader, r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+wsAcceptKey(r.Header.Get("Sec-WebSocket-Key"))+"\r\n\r\n")
+		handle(conn, brw.Reader, r)
+	}))
+}
+
+// wsWrite 服务端发帧 (不加掩码)
+func wsWrite(conn net.Conn, op byte, payload string) {
+	hdr := []byte{0x80 | op}
+	if len(payload) < 126 {
+		hdr = append(hdr, byte(len(payload)))
+	} else {
+		hdr = append(hdr, 126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(payload)))
+	}
+	conn.Write(append(hdr, payload...))
+}
+
+func TestBinance_Stream(t *testing.T) {
+	srv := wsServer(t, func(conn net.Conn, br *bufio.Reader, r *http.Request) {
+		if r.URL.Query().Get("streams") != "btcusdt@miniTicker/ethusdt@miniTicker" {
+			t.Errorf("unexpected streams %q", r.URL.RawQuery)
+		}
+		wsWrite(conn, wsOpPing, "")
+		wsWrite(conn, wsOpText, `{"stream":"btcusdt@miniTicker","data":{"e":"24hrMiniTicker","s":"BTCUSDT","c":"65000.5"}}`)
+		wsWrite(conn, wsOpText, `{"stream":"ethusdt@miniTicker","data":{"e":"24hrMiniTicker","s":"ETHUSDT","c":"3500"}}`)
+		wsWrite(conn, wsOpClose, "")
+	})
+	defer srv.Close()
+
+	var got []Quote
+	a := &BinanceAdapter{WSURL: "ws" + strings.TrimPrefix(srv.URL, "http")}
+	err := a.Stream(context.Background(), []Pair{{"BTC", "USDT"}, {"ETH", "USDT"}}, func(q Quote) { got = append(got, q) })
+	if !errors.Is(err, errWSClosed) {
+		t.Fatalf("expected close, got %v", err)
+	}
+	if len(got) != 2 || got[0].Price != 6500050000000 || got[1].Pair != (Pair{"ETH", "USDT"}) {
+		t.Fatalf("unexpected quotes %+v", got)
+	}
+}
+
+func TestOKX_Stream(t *testing.T) {
+	srv := wsServer(t, func(conn net.Conn, br *bufio.Reader, r *http.Request) {
+		c := &wsConn{conn: conn, br: br}
+		sub, err := c.ReadMessage() // 客户端帧带掩码，readFrame 负责解掩码
+		if err != nil || !strings.Contains(string(sub), `"instId":"BTC-USDT"`) {
+			t.Errorf("unexpected subscribe %s %v", sub, err)
+		}
+		wsWrite(conn, wsOpText, `{"event":"subscribe","arg":{"channel":"tickers","instId":"BTC-USDT"}}`)
+		wsWrite(conn, wsOpText, `{"arg":{"channel":"tickers","instId":"BTC-USDT"},"data":[{"instId":"BTC-USDT","last":"64999.9"}]}`)
+		wsWrite(conn, wsOpText, `{"event":"error","code":"60012","msg":"Invalid request"}`)
+	})
+	defer srv.Close()
+
+	var got []Quote
+	a := &OKXAdapter{WSURL: "ws" + strings.TrimPrefix(srv.URL, "http")}
+	err := a.Stream(context.Background(), []Pair{{"BTC", "USDT"}}, func(q Quote) { got = append(got, q) })
+	if !errors.Is(err, ErrVenueRejected) {
+		t.Fatalf("expected ErrVenueRejected, got %v", err)
+	}
+	if len(got) != 1 || got[0].Price != 6499990000000 || got[0].Source != "okx" {
+		t.Fatalf("unexpected quotes %+v", got)
+	}
+}
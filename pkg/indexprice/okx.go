@@ -0,0 +1,153 @@
+package indexprice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// OKX 现货
+// =============================================================================
+//
+// REST: GET /api/v5/market/ticker?instId=BTC-USDT → {"code":"0","data":[{"instId":"BTC-USDT","last":"65000.1"}]}
+// WS:   /ws/v5/public，发送 {"op":"subscribe","args":[{"channel":"tickers","instId":"BTC-USDT"}]}
+//       推送 {"arg":{...},"data":[{"instId":"BTC-USDT","last":"65000.1"}]}
+//
+// 【注意】OKX 30 秒没有消息会断开连接，需要客户端定时发文本 "ping" (服务端回 "pong")
+
+const (
+	OKXRESTURL = "https://www.okx.com"
+	OKXWSURL   = "wss://ws.okx.com:8443"
+
+	okxPingInterval = 20 * time.Second
+)
+
+// OKXAdapter OKX 现货行情
+type OKXAdapter struct {
+	RESTURL string       // 为空使用 OKXRESTURL
+	WSURL   string       // 为空使用 OKXWSURL
+	Client  *http.Client // 为空使用 http.DefaultClient
+}
+
+var (
+	_ Poller   = (*OKXAdapter)(nil)
+	_ Streamer = (*OKXAdapter)(nil)
+)
+
+func (a *OKXAdapter) Name() string { return "okx" }
+
+func okxInstID(p Pair) string {
+	return strings.ToUpper(p.Base) + "-" + p.venueQuote()
+}
+
+type okxTicker struct {
+	InstID string `json:"instId"`
+	Last   string `json:"last"`
+}
+
+// FetchPrice 实现 Poller
+func (a *OKXAdapter) FetchPrice(ctx context.Context, pair Pair) (Quote, error) {
+	base := a.RESTURL
+	if base == "" {
+		base = OKXRESTURL
+	}
+	var body struct {
+		Code string      `json:"code"`
+		Msg  string      `json:"msg"`
+		Data []okxTicker `json:"data"`
+	}
+	target := base + "/api/v5/market/ticker?instId=" + url.QueryEscape(okxInstID(pair))
+	if err := getJSON(ctx, a.Client, target, &body); err != nil {
+		return Quote{}, err
+	}
+	if body.Code != "0" || len(body.Data) == 0 {
+		return Quote{}, ErrVenueRejected.Wrapf("okx %s: %s", body.Code, body.Msg)
+	}
+	price, err := parsePrice(body.Data[0].Last)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Source: a.Name(), Pair: pair, Price: price}, nil
+}
+
+// Stream 实现 Streamer：订阅 tickers 频道
+func (a *OKXAdapter) Stream(ctx context.Context, pairs []Pair, emit func(Quote)) error {
+	base := a.WSURL
+	if base == "" {
+		base = OKXWSURL
+	}
+	type arg struct {
+		Channel string `json:"channel"`
+		InstID  string `json:"instId"`
+	}
+	args := make([]arg, len(pairs))
+	byInst := make(map[string]Pair, len(pairs))
+	for i, p := range pairs {
+		id := okxInstID(p)
+		args[i] = arg{Channel: "tickers", InstID: id}
+		byInst[id] = p
+	}
+
+	conn, err := wsDial(ctx, base+"/ws/v5/public")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer conn.closeOnDone(ctx)()
+
+	sub, _ := json.Marshal(map[string]any{"op": "subscribe", "args": args})
+	if err := conn.WriteText(sub); err != nil {
+		return err
+	}
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go func() {
+		ticker := time.NewTicker(okxPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopPing:
+				return
+			case <-ticker.C:
+				if conn.WriteText([]byte("ping")) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var push struct {
+			Event string      `json:"event"`
+			Code  string      `json:"code"`
+			Msg   string      `json:"msg"`
+			Data  []okxTicker `json:"data"`
+		}
+		if json.Unmarshal(msg, &push) != nil {
+			continue // "pong"
+		}
+		if push.Event == "error" {
+			return ErrVenueRejected.Wrapf("okx subscribe %s: %s", push.Code, push.Msg)
+		}
+		for _, t := range push.Data {
+			pair, ok := byInst[t.InstID]
+			if !ok {
+				continue
+			}
+			price, err := parsePrice(t.Last)
+			if err != nil {
+				continue
+			}
+			emit(Quote{Source: a.Name(), Pair: pair, Price: price})
+		}
+	}
+}
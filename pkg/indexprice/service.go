@@ -0,0 +1,346 @@
+package indexprice
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 指数服务配置
+type Config struct {
+	// PollInterval REST 轮询间隔；某个源的 WS 报价比这更新时跳过轮询
+	PollInterval time.Duration
+
+	// StaleAfter 报价多久没更新视为过期
+	StaleAfter time.Duration
+
+	// MaxDeviation 与各源中位数的最大偏离 (万分比)，0 不检查
+	MaxDeviation int64
+
+	// MinSources 至少几个有效源才发布
+	MinSources int
+
+	// Weights 交易所权重，未配置的交易所使用 DefaultWeight
+	Weights       map[string]int
+	DefaultWeight int
+
+	// ReconnectDelay WS 断线重连间隔
+	ReconnectDelay time.Duration
+
+	// RequestTimeout 单次 REST 请求超时
+	RequestTimeout time.Duration
+}
+
+// DefaultConfig 默认配置 (权重与 futures.MarkPriceCalculator 的默认权重一致)
+func DefaultConfig() Config {
+	return Config{
+		PollInterval:   2 * time.Second,
+		StaleAfter:     10 * time.Second,
+		MaxDeviation:   300, // 3%
+		MinSources:     1,
+		Weights:        map[string]int{"binance": 35, "okx": 25, "huobi": 20, "bybit": 20},
+		DefaultWeight:  10,
+		ReconnectDelay: 3 * time.Second,
+		RequestTimeout: 3 * time.Second,
+	}
+}
+
+// =============================================================================
+// Service
+// =============================================================================
+
+// Service 指数价格服务
+type Service struct {
+	cfg      Config
+	pub      Publisher
+	adapters map[string]Adapter
+	now      func() time.Time
+
+	mu      sync.RWMutex
+	symbols map[string]*tracked       // symbol → 配置
+	byPair  map[Pair][]string         // pair → symbols (永续、交割共用一个现货交易对)
+	quotes  map[Pair]map[string]Quote // pair → source → 最新报价
+	indexes map[string]Index          // symbol → 最近一次聚合结果 (含失败时的剔除明细)
+
+	running bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type tracked struct {
+	pair    Pair
+	sources []string
+}
+
+// NewService 创建指数服务，pub 为 nil 时只计算不发布
+func NewService(cfg Config, pub Publisher) *Service {
+	def := DefaultConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = def.StaleAfter
+	}
+	if cfg.MinSources <= 0 {
+		cfg.MinSources = def.MinSources
+	}
+	if cfg.Weights == nil {
+		cfg.Weights = def.Weights
+	}
+	if cfg.DefaultWeight <= 0 {
+		cfg.DefaultWeight = def.DefaultWeight
+	}
+	if cfg.ReconnectDelay <= 0 {
+		cfg.ReconnectDelay = def.ReconnectDelay
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = def.RequestTimeout
+	}
+	return &Service{
+		cfg:      cfg,
+		pub:      pub,
+		adapters: make(map[string]Adapter),
+		now:      time.Now,
+		symbols:  make(map[string]*tracked),
+		byPair:   make(map[Pair][]string),
+		quotes:   make(map[Pair]map[string]Quote),
+		indexes:  make(map[string]Index),
+	}
+}
+
+// SetClock 替换时钟：报价缺省时间戳、过期报价剔除和轮询间隔都按它计算
+func (s *Service) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// RegisterAdapter 注册交易所适配器 (Start 之前调用)
+func (s *Service) RegisterAdapter(a Adapter) {
+	s.adapters[a.Name()] = a
+}
+
+// Track 跟踪一个合约的指数
+//
+// sources 必须都已注册适配器。运行中新增的交易对由 REST 轮询覆盖，WS 在下次重连时订阅
+func (s *Service) Track(symbol string, pair Pair, sources []string) error {
+	for _, src := range sources {
+		if _, ok := s.adapters[src]; !ok {
+			return ErrUnknownSource.Wrapf("%s (%s)", src, symbol)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.symbols[symbol]; ok {
+		s.byPair[old.pair] = removeString(s.byPair[old.pair], symbol)
+	}
+	s.symbols[symbol] = &tracked{pair: pair, sources: sources}
+	s.byPair[pair] = append(s.byPair[pair], symbol)
+	return nil
+}
+
+// Index 最近一次聚合结果；ok=false 表示还没有成功聚合过
+func (s *Service) Index(symbol string) (Index, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx, ok := s.indexes[symbol]
+	return idx, ok && idx.Price > 0
+}
+
+// OnQuote 接收一条报价，重算用到该交易对的合约指数并发布
+func (s *Service) OnQuote(q Quote) {
+	if q.Timestamp == 0 {
+		q.Timestamp = s.now().UnixMilli()
+	}
+	s.mu.Lock()
+	m := s.quotes[q.Pair]
+	if m == nil {
+		m = make(map[string]Quote)
+		s.quotes[q.Pair] = m
+	}
+	m[q.Source] = q
+	symbols := append([]string(nil), s.byPair[q.Pair]...)
+	s.mu.Unlock()
+
+	for _, symbol := range symbols {
+		s.Recompute(symbol)
+	}
+}
+
+// Recompute 用当前报价重算一个合约的指数，成功时发布
+func (s *Service) Recompute(symbol string) (Index, error) {
+	now := s.now().UnixMilli()
+
+	s.mu.Lock()
+	t, ok := s.symbols[symbol]
+	if !ok {
+		s.mu.Unlock()
+		return Index{}, ErrUnknownSource.Wrapf("symbol %s not tracked", symbol)
+	}
+	quotes := make([]Quote, 0, len(t.sources))
+	for _, src := range t.sources {
+		if q, ok := s.quotes[t.pair][src]; ok {
+			quotes = append(quotes, q)
+		}
+	}
+	price, used, excluded, err := aggregate(quotes, s.weight, now,
+		s.cfg.StaleAfter.Milliseconds(), s.cfg.MaxDeviation, s.cfg.MinSources)
+	idx := s.indexes[symbol]
+	idx.Symbol = symbol
+	idx.Excluded = excluded
+	if err == nil {
+		idx.Price = price
+		idx.Sources = used
+		idx.UpdatedAt = now
+	}
+	s.indexes[symbol] = idx
+	s.mu.Unlock()
+
+	if err != nil {
+		return idx, err
+	}
+	if s.pub != nil {
+		s.pub.UpdateIndexPrice(symbol, price)
+	}
+	return idx, nil
+}
+
+func (s *Service) weight(source string) int {
+	if w, ok := s.cfg.Weights[source]; ok && w > 0 {
+		return w
+	}
+	return s.cfg.DefaultWeight
+}
+
+// =============================================================================
+// 运行: WS 推送 + REST 兜底
+// =============================================================================
+
+// Start 为每个适配器启动 WS 订阅和 REST 轮询
+func (s *Service) Start() error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	s.running = true
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	for _, a := range s.adapters {
+		if st, ok := a.(Streamer); ok {
+			s.wg.Add(1)
+			go s.streamLoop(ctx, st)
+		}
+		if p, ok := a.(Poller); ok {
+			s.wg.Add(1)
+			go s.pollLoop(ctx, p)
+		}
+	}
+	return nil
+}
+
+// Stop 停止所有订阅和轮询
+func (s *Service) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.cancel()
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// pairsFor 某个源需要的交易对
+func (s *Service) pairsFor(source string) []Pair {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[Pair]bool)
+	var out []Pair
+	for _, t := range s.symbols {
+		if seen[t.pair] {
+			continue
+		}
+		for _, src := range t.sources {
+			if src == source {
+				seen[t.pair] = true
+				out = append(out, t.pair)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// streamLoop WS 订阅，断线后等待 ReconnectDelay 重连
+func (s *Service) streamLoop(ctx context.Context, st Streamer) {
+	defer s.wg.Done()
+	for {
+		if pairs := s.pairsFor(st.Name()); len(pairs) > 0 {
+			err := st.Stream(ctx, pairs, s.OnQuote)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[IndexPrice] %s stream disconnected: %v", st.Name(), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.ReconnectDelay):
+		}
+	}
+}
+
+// pollLoop REST 轮询：该源的报价在 PollInterval 内有更新 (WS 正常) 时跳过
+func (s *Service) pollLoop(ctx context.Context, p Poller) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll(ctx, p)
+		}
+	}
+}
+
+// poll 轮询一遍该源需要的交易对
+func (s *Service) poll(ctx context.Context, p Poller) {
+	cutoff := s.now().Add(-s.cfg.PollInterval).UnixMilli()
+	for _, pair := range s.pairsFor(p.Name()) {
+		s.mu.RLock()
+		last, ok := s.quotes[pair][p.Name()]
+		s.mu.RUnlock()
+		if ok && last.Timestamp > cutoff {
+			continue
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, s.cfg.RequestTimeout)
+		q, err := p.FetchPrice(reqCtx, pair)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[IndexPrice] %s poll %s failed: %v", p.Name(), pair, err)
+			}
+			continue
+		}
+		s.OnQuote(q)
+	}
+}
+
+func removeString(list []string, v string) []string {
+	out := list[:0]
+	for _, s := range list {
+		if s != v {
+			out = append(out, s)
+		}
+	}
+	return out
+}
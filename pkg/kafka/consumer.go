@@ -60,7 +60,11 @@ type Consumer struct {
 
 	ctx    context.Context
 	cancel context.CancelFunc
-	wg     sync.WaitGroup
+
+	// 当前一轮消费循环 (Pause 结束它，Resume 重新开始)
+	mu        sync.Mutex
+	runCancel context.CancelFunc
+	done      chan struct{}
 }
 
 // NewConsumer 创建消费者
@@ -90,29 +94,84 @@ func NewConsumer(cfg ConsumerConfig, handler MessageHandler) (*Consumer, error)
 
 // Start 启动消费
 func (c *Consumer) Start() {
-	c.wg.Add(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.run()
+}
+
+// run 启动一轮消费循环，调用方持 c.mu
+func (c *Consumer) run() {
+	ctx, cancel := context.WithCancel(c.ctx)
+	done := make(chan struct{})
+	c.runCancel, c.done = cancel, done
 	go func() {
-		defer c.wg.Done()
+		defer close(done)
 		for {
 			// 加入消费者组
 			handler := &consumerGroupHandler{handler: c.handler}
-			err := c.client.Consume(c.ctx, c.config.Topics, handler)
+			err := c.client.Consume(ctx, c.config.Topics, handler)
 			if err != nil {
 				fmt.Printf("[Kafka] consume error: %v\n", err)
 			}
 
 			// 检查是否应该退出
-			if c.ctx.Err() != nil {
+			if ctx.Err() != nil {
 				return
 			}
 		}
 	}()
 }
 
+// Pause 维护模式：处理完当前这条消息后退出消费者组 (分区重平衡给其他实例)，提交已处理的 offset
+//
+// ctx 到期仍未退出返回错误，消费循环在后台继续退出；已暂停时直接返回
+func (c *Consumer) Pause(ctx context.Context) error {
+	c.mu.Lock()
+	if c.runCancel != nil {
+		c.runCancel()
+		c.runCancel = nil
+	}
+	done := c.done
+	c.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("pause consumer %s: %w", c.config.GroupID, ctx.Err())
+	}
+}
+
+// Resume 重新加入消费者组，从已提交的 offset 继续；未暂停时什么都不做
+func (c *Consumer) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runCancel != nil {
+		return nil
+	}
+	if c.done != nil {
+		select {
+		case <-c.done:
+		default:
+			return fmt.Errorf("resume consumer %s: still draining", c.config.GroupID)
+		}
+	}
+	c.run()
+	return nil
+}
+
 // Stop 停止消费
 func (c *Consumer) Stop() error {
 	c.cancel()
-	c.wg.Wait()
+	c.mu.Lock()
+	done := c.done
+	c.mu.Unlock()
+	if done != nil {
+		<-done
+	}
 	return c.client.Close()
 }
 
@@ -128,15 +187,23 @@ func (h *consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { re
 func (h *consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 
 func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		// 调用用户处理器
-		if err := h.handler(msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Value); err != nil {
-			fmt.Printf("[Kafka] handle error: topic=%s, offset=%d, err=%v\n", msg.Topic, msg.Offset, err)
-			// 继续处理下一条，不中断
-		}
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			// 调用用户处理器
+			if err := h.handler(msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Value); err != nil {
+				fmt.Printf("[Kafka] handle error: topic=%s, offset=%d, err=%v\n", msg.Topic, msg.Offset, err)
+				// 继续处理下一条，不中断
+			}
 
-		// 标记已处理
-		session.MarkMessage(msg, "")
+			// 标记已处理
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			// Pause / Stop：当前消息已处理完，未处理的留给下一个拿到分区的消费者
+			return nil
+		}
 	}
-	return nil
 }
@@ -0,0 +1,161 @@
+package liquidation
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 自适应扫描间隔
+// =============================================================================
+//
+// 【问题】固定 5 秒全量扫描：暴跌时太慢 (5 秒里价格能走好几个点)，平静时又白白耗 CPU
+//
+// 【策略】每轮扫描结束后按市场压力调整下一轮间隔：
+// - 压力：观察窗口内任一交易对价格变动超过阈值，或 Danger + Critical 用户数超过阈值
+// - 有压力时间隔乘以 ShrinkFactor，直到下限 MinInterval
+// - 无压力时间隔乘以 GrowFactor，慢慢放回上限 MaxInterval
+//
+// 收缩快、放宽慢：行情反复时不会刚放宽又被打个措手不及
+// 平静状态下突然出现剧烈变动时立即唤醒扫描器，不等当前 (最长的) 间隔走完
+
+// AdaptiveScanConfig 自适应扫描间隔配置
+type AdaptiveScanConfig struct {
+	MinInterval time.Duration // 压力下的最短间隔，默认 500ms
+	MaxInterval time.Duration // 平静时的间隔，默认 DefaultScanInterval
+
+	PriceWindow        time.Duration // 价格变动观察窗口，默认 1 分钟
+	PriceMoveThreshold float64       // 窗口内变动比例超过此值视为压力，默认 0.01 (1%)
+	StressedUsers      int           // Danger + Critical 用户数超过此值视为压力，默认 1000
+
+	ShrinkFactor float64 // 有压力时的间隔倍数，默认 0.5
+	GrowFactor   float64 // 无压力时的间隔倍数，默认 1.5
+
+	Now func() time.Time // 时钟 (测试注入)，默认 time.Now
+}
+
+// DefaultAdaptiveScanConfig 默认配置
+func DefaultAdaptiveScanConfig() AdaptiveScanConfig {
+	return AdaptiveScanConfig{
+		MinInterval:        500 * time.Millisecond,
+		MaxInterval:        DefaultScanInterval,
+		PriceWindow:        time.Minute,
+		PriceMoveThreshold: 0.01,
+		StressedUsers:      1000,
+		ShrinkFactor:       0.5,
+		GrowFactor:         1.5,
+		Now:                time.Now,
+	}
+}
+
+func (c *AdaptiveScanConfig) applyDefaults() {
+	def := DefaultAdaptiveScanConfig()
+	if c.MinInterval <= 0 {
+		c.MinInterval = def.MinInterval
+	}
+	if c.MaxInterval <= 0 {
+		c.MaxInterval = def.MaxInterval
+	}
+	if c.MaxInterval < c.MinInterval {
+		c.MaxInterval = c.MinInterval
+	}
+	if c.PriceWindow <= 0 {
+		c.PriceWindow = def.PriceWindow
+	}
+	if c.PriceMoveThreshold <= 0 {
+		c.PriceMoveThreshold = def.PriceMoveThreshold
+	}
+	if c.StressedUsers <= 0 {
+		c.StressedUsers = def.StressedUsers
+	}
+	if c.ShrinkFactor <= 0 || c.ShrinkFactor >= 1 {
+		c.ShrinkFactor = def.ShrinkFactor
+	}
+	if c.GrowFactor <= 1 {
+		c.GrowFactor = def.GrowFactor
+	}
+	if c.Now == nil {
+		c.Now = def.Now
+	}
+}
+
+// priceAnchor 交易对在当前观察窗口起点的价格
+type priceAnchor struct {
+	price float64
+	at    time.Time
+}
+
+// adaptiveInterval 根据市场压力计算下一轮扫描间隔
+type adaptiveInterval struct {
+	cfg AdaptiveScanConfig
+
+	mu          sync.Mutex
+	anchors     map[string]priceAnchor
+	lastStress  time.Time // 最近一次价格剧烈变动的时间
+	current     time.Duration
+	stressedNow bool          // 上一次调整时是否处于压力状态
+	wake        chan struct{} // 从平静转入压力时唤醒扫描器
+}
+
+func newAdaptiveInterval(cfg AdaptiveScanConfig) *adaptiveInterval {
+	cfg.applyDefaults()
+	return &adaptiveInterval{
+		cfg:     cfg,
+		anchors: make(map[string]priceAnchor),
+		current: cfg.MaxInterval,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// observePrice 记录一次价格，窗口内相对窗口起点变动超过阈值则记为压力
+func (a *adaptiveInterval) observePrice(symbol string, price float64) {
+	if price <= 0 {
+		return
+	}
+	now := a.cfg.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	anchor, ok := a.anchors[symbol]
+	if !ok || now.Sub(anchor.at) > a.cfg.PriceWindow {
+		a.anchors[symbol] = priceAnchor{price: price, at: now}
+		return
+	}
+	if math.Abs(price-anchor.price)/anchor.price >= a.cfg.PriceMoveThreshold {
+		calm := !a.stressedNow && (a.lastStress.IsZero() || now.Sub(a.lastStress) > a.cfg.PriceWindow)
+		a.lastStress = now
+		if calm {
+			select {
+			case a.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// next 一轮扫描结束后调用，返回下一轮间隔
+func (a *adaptiveInterval) next(stressedUsers int) time.Duration {
+	now := a.cfg.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	priceStress := !a.lastStress.IsZero() && now.Sub(a.lastStress) <= a.cfg.PriceWindow
+	a.stressedNow = priceStress || stressedUsers >= a.cfg.StressedUsers
+
+	if a.stressedNow {
+		a.current = max(a.cfg.MinInterval, time.Duration(float64(a.current)*a.cfg.ShrinkFactor))
+	} else {
+		a.current = min(a.cfg.MaxInterval, time.Duration(float64(a.current)*a.cfg.GrowFactor))
+	}
+	return a.current
+}
+
+// interval 当前间隔，以及上一轮是否处于压力状态
+func (a *adaptiveInterval) interval() (time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current, a.stressedNow
+}
@@ -288,6 +288,67 @@ func BenchmarkRiskLevelIndex_UpdateUser(b *testing.B) {
 	}
 }
 
+// 50 万高风险用户：暴跌时的规模
+
+const benchHighRiskUsers = 500_000
+
+func newBenchIndex500K() *RiskLevelIndex {
+	idx := NewRiskLevelIndex()
+	users := make([]UserRiskData, benchHighRiskUsers)
+	for i := range users {
+		users[i] = UserRiskData{UserID: int64(i + 1), RiskRatio: 0.70 + float64(i%30)*0.01}
+	}
+	idx.UpdateUsers(users)
+	return idx
+}
+
+// BenchmarkCowMap_Set_500K - 未分片：单用户更新复制整张 Map (对照组)
+func BenchmarkCowMap_Set_500K(b *testing.B) {
+	m := NewCowMap()
+	users := make([]UserRiskData, benchHighRiskUsers)
+	for i := range users {
+		users[i] = UserRiskData{UserID: int64(i + 1), RiskRatio: 0.75}
+	}
+	m.BatchUpdate(users, nil)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		m.Set(UserRiskData{UserID: int64(i%benchHighRiskUsers + 1), RiskRatio: 0.85})
+	}
+}
+
+// BenchmarkRiskLevelIndex_UpdateUser_500K - 分片后单用户升降级
+func BenchmarkRiskLevelIndex_UpdateUser_500K(b *testing.B) {
+	idx := newBenchIndex500K()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		userID := int64(i%benchHighRiskUsers + 1)
+		idx.UpdateUser(UserRiskData{UserID: userID, RiskRatio: 0.70 + float64((i+7)%30)*0.01})
+	}
+}
+
+// BenchmarkRiskLevelIndex_UpdateUsers_500K - 一轮检查 1 万用户升降级，批量写入
+func BenchmarkRiskLevelIndex_UpdateUsers_500K(b *testing.B) {
+	idx := newBenchIndex500K()
+	batch := make([]UserRiskData, 10_000)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		for j := range batch {
+			userID := int64((i*len(batch)+j)%benchHighRiskUsers + 1)
+			batch[j] = UserRiskData{UserID: userID, RiskRatio: 0.70 + float64((i+j)%30)*0.01}
+		}
+		idx.UpdateUsers(batch)
+	}
+}
+
 // =============================================================================
 // Benchmark: 强平触发性能
 // =============================================================================
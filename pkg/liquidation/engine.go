@@ -74,6 +74,17 @@ type Engine struct {
 	// executor: 强平执行器接口（由外部实现）
 	executor LiquidationExecutor
 
+	// taskStore: 积压任务持久化（可选），停机时落盘，启动时取回
+	taskStore TaskStore
+
+	// levelObservers: 风险等级变化回调（可选，见 level_change.go）
+	levelObservers levelObservers
+
+	// queueMu: 保护队列的投递与关闭
+	// 生产者持读锁投递，Stop 持写锁标记关闭后才 close 队列，避免 send on closed channel
+	queueMu     sync.RWMutex
+	queueClosed bool
+
 	// ========== 生命周期 ==========
 
 	// running: 是否正在运行
@@ -82,9 +93,12 @@ type Engine struct {
 	// stopCh: 停止信号
 	stopCh chan struct{}
 
-	// wg: 等待所有 Goroutine 完成
+	// wg: 等待检查器（生产者）退出
 	wg sync.WaitGroup
 
+	// workerWg: 等待 Worker（消费者）退出，和检查器分开，停机时先停生产者再停消费者
+	workerWg sync.WaitGroup
+
 	// mu: 保护 running 状态
 	mu sync.Mutex
 }
@@ -105,6 +119,17 @@ type LiquidationExecutor interface {
 	Execute(ctx context.Context, task LiquidationTask) LiquidationResult
 }
 
+// TaskStore 强平任务持久化接口
+//
+// 由外部实现（MySQL/Redis 等）。引擎只在停机时写入积压任务、启动时取回，正常运行不落盘
+type TaskStore interface {
+	// SavePending 保存停机时尚未执行的任务
+	SavePending(ctx context.Context, tasks []LiquidationTask) error
+
+	// TakePending 取出并删除之前保存的任务
+	TakePending(ctx context.Context) ([]LiquidationTask, error)
+}
+
 // =============================================================================
 // 引擎生命周期
 // =============================================================================
@@ -132,6 +157,18 @@ func NewEngine(
 	}
 }
 
+// SetTaskStore 设置积压任务存储，须在 Start 之前调用
+//
+// 不设置时，Stop 会等 Worker 把队列里的任务执行完再返回
+func (e *Engine) SetTaskStore(store TaskStore) {
+	e.taskStore = store
+}
+
+// SetAdaptiveScan 全量扫描改用自适应间隔，行情由 OnPriceChange 喂入，须在 Start 之前调用
+func (e *Engine) SetAdaptiveScan(cfg AdaptiveScanConfig) {
+	e.scanner.SetAdaptiveInterval(cfg)
+}
+
 // Start 启动引擎
 //
 // 会启动以下组件:
@@ -151,6 +188,14 @@ func (e *Engine) Start() error {
 	e.running = true
 	e.stopCh = make(chan struct{})
 
+	// 重启：上次 Stop 已关闭队列，换一个新的
+	e.queueMu.Lock()
+	if e.queueClosed {
+		e.liquidationQueue = make(chan LiquidationTask, LiquidationQueueSize)
+		e.queueClosed = false
+	}
+	e.queueMu.Unlock()
+
 	// 1. 启动扫描器
 	e.scanner.Start()
 
@@ -162,11 +207,24 @@ func (e *Engine) Start() error {
 	// 3. 启动强平 Worker Pool
 	e.startWorkers()
 
+	// 4. 取回上次停机时落盘的任务
+	e.restorePending()
+
 	log.Println("[Engine] Started")
 	return nil
 }
 
-// Stop 停止引擎
+// Stop 停止引擎，不丢弃已入队的强平任务
+//
+// 【顺序】先停生产者，再处理积压，最后停消费者：
+// 1. 关闭 stopCh、停扫描器，等检查器退出
+// 2. 标记队列关闭，之后 OnPriceChange 等外部触发直接拒绝；拿到写锁时已没有正在投递的生产者
+// 3. 配置了 TaskStore 时把积压任务落盘，下次 Start 取回；未配置或落盘失败则留给 Worker 执行完
+// 4. 关闭队列，等 Worker 处理完手头任务退出
+//
+// 【面试】为什么不能直接 close 队列？
+// 检查器和行情回调随时可能在投递，向已关闭的 channel 发送会 panic；
+// 而先关 stopCh 只能停掉检查器，管不住 OnPriceChange 这种外部调用方，所以要靠锁 + 标记
 func (e *Engine) Stop() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -175,22 +233,89 @@ func (e *Engine) Stop() {
 		return
 	}
 
-	// 发送停止信号
+	// 1. 停止生产者
 	close(e.stopCh)
-
-	// 停止扫描器
 	e.scanner.Stop()
+	e.wg.Wait()
 
-	// 关闭任务队列
-	close(e.liquidationQueue)
+	// 2. 拒绝新任务
+	e.queueMu.Lock()
+	e.queueClosed = true
+	queue := e.liquidationQueue
+	e.queueMu.Unlock()
 
-	// 等待所有 Goroutine 完成
-	e.wg.Wait()
+	// 3. 积压任务落盘
+	e.persistPending(queue)
+
+	// 4. 关闭队列，等 Worker 退出
+	close(queue)
+	e.workerWg.Wait()
 
 	e.running = false
 	log.Println("[Engine] Stopped")
 }
 
+// persistPending 把队列里尚未被 Worker 取走的任务写入 TaskStore
+func (e *Engine) persistPending(queue chan LiquidationTask) {
+	if e.taskStore == nil {
+		return
+	}
+
+	var pending []LiquidationTask
+drain:
+	for {
+		select {
+		case task := <-queue:
+			pending = append(pending, task)
+		default:
+			break drain
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.taskStore.SavePending(ctx, pending); err != nil {
+		// 落盘失败就放回队列，由 Worker 在退出前执行（容量足够：都是从这个队列取出来的）
+		log.Printf("[Engine] Failed to persist %d pending liquidation tasks, executing before stop: %v", len(pending), err)
+		for _, task := range pending {
+			queue <- task
+		}
+		return
+	}
+	log.Printf("[Engine] %d pending liquidation tasks persisted", len(pending))
+}
+
+// restorePending 取回上次停机落盘的任务重新入队，放不下的写回存储
+func (e *Engine) restorePending() {
+	if e.taskStore == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tasks, err := e.taskStore.TakePending(ctx)
+	if err != nil {
+		log.Printf("[Engine] Failed to restore pending liquidation tasks: %v", err)
+		return
+	}
+
+	var overflow []LiquidationTask
+	for _, task := range tasks {
+		if !e.enqueue(task) {
+			overflow = append(overflow, task)
+		}
+	}
+	if len(overflow) > 0 {
+		if err := e.taskStore.SavePending(ctx, overflow); err != nil {
+			log.Printf("[Engine] ERROR: %d restored liquidation tasks lost: %v", len(overflow), err)
+		}
+	}
+	log.Printf("[Engine] %d pending liquidation tasks restored", len(tasks)-len(overflow))
+}
+
 // =============================================================================
 // 检查器
 // =============================================================================
@@ -226,40 +351,49 @@ func (e *Engine) runChecker(level RiskLevel, interval time.Duration) {
 func (e *Engine) checkLevel(level RiskLevel) {
 	ctx := context.Background()
 
-	// 获取该等级的所有用户（只读，零分配）
-	usersMap := e.index.GetByLevelReadOnly(level)
-	if usersMap == nil || len(*usersMap) == 0 {
+	count := e.index.CountByLevel(level)
+	if count == 0 {
 		return
 	}
 
-	log.Printf("[Checker] Checking level=%s, users=%d", level, len(*usersMap))
+	log.Printf("[Checker] Checking level=%s, users=%d", level, count)
 
-	// 直接遍历 map，避免复制到切片
-	for _, user := range *usersMap {
+	// 逐分片遍历快照（零复制），本轮的索引变更攒起来最后批量写入：
+	// 暴跌时整个等级的用户都在变，逐个写会反复复制同一个分片
+	updates := make([]UserRiskData, 0, count)
+	e.index.ForEachByLevel(level, func(user UserRiskData) {
 		// 重新获取用户数据
 		riskInput, err := e.userProvider.GetUserRiskInput(ctx, user.UserID)
 		if err != nil {
 			log.Printf("[Checker] Failed to get risk input for user %d: %v", user.UserID, err)
-			continue
+			return
 		}
 
 		// 重新计算风险
 		riskOutput, err := e.riskEngine.ComputeRisk(riskInput)
 		if err != nil {
 			log.Printf("[Checker] Failed to compute risk for user %d: %v", user.UserID, err)
-			continue
+			return
 		}
 
 		// 判断新等级
 		newLevel := CalculateRiskLevel(riskOutput.RiskRatio)
 
 		// 处理等级变化
-		e.handleLevelChange(user, newLevel, riskOutput)
-	}
+		updates = append(updates, e.resolveLevelChange(user, newLevel, riskOutput))
+	})
+	e.index.UpdateUsers(updates)
 }
 
 // handleLevelChange 处理用户等级变化
 func (e *Engine) handleLevelChange(user UserRiskData, newLevel RiskLevel, output risk.RiskOutput) {
+	e.index.UpdateUser(e.resolveLevelChange(user, newLevel, output))
+}
+
+// resolveLevelChange 计算等级变化后要写入索引的数据，需要强平时同时投递任务
+//
+// 返回 Safe 等级 (RiskRatio = 0) 的数据表示从索引移除
+func (e *Engine) resolveLevelChange(user UserRiskData, newLevel RiskLevel, output risk.RiskOutput) UserRiskData {
 	oldLevel := user.Level
 
 	if newLevel == oldLevel {
@@ -268,31 +402,31 @@ func (e *Engine) handleLevelChange(user UserRiskData, newLevel RiskLevel, output
 		user.Equity = output.Equity
 		user.MaintMargin = output.MaintMarginReq
 		user.UpdatedAt = time.Now().UnixNano()
-		e.index.UpdateUser(user)
-		return
+		return user
 	}
 
 	// 等级发生变化
 	log.Printf("[Checker] User %d level changed: %s -> %s (riskRatio=%.4f)",
 		user.UserID, oldLevel, newLevel, output.RiskRatio)
+	e.levelObservers.emit(user.UserID, oldLevel, newLevel, output.RiskRatio)
 
 	if newLevel == RiskLevelLiquidate {
 		// 需要强平！
 		e.triggerLiquidation(user, output)
 		// 从索引中移除
-		e.index.UpdateUser(UserRiskData{UserID: user.UserID, Level: RiskLevelSafe})
+		return UserRiskData{UserID: user.UserID, Level: RiskLevelSafe}
 	} else if newLevel == RiskLevelSafe {
 		// 脱离危险，从索引中移除
-		e.index.UpdateUser(UserRiskData{UserID: user.UserID, Level: RiskLevelSafe})
-	} else {
-		// 升级或降级到其他等级
-		user.Level = newLevel
-		user.RiskRatio = output.RiskRatio
-		user.Equity = output.Equity
-		user.MaintMargin = output.MaintMarginReq
-		user.UpdatedAt = time.Now().UnixNano()
-		e.index.UpdateUser(user)
+		return UserRiskData{UserID: user.UserID, Level: RiskLevelSafe}
 	}
+
+	// 升级或降级到其他等级
+	user.Level = newLevel
+	user.RiskRatio = output.RiskRatio
+	user.Equity = output.Equity
+	user.MaintMargin = output.MaintMarginReq
+	user.UpdatedAt = time.Now().UnixNano()
+	return user
 }
 
 // =============================================================================
@@ -308,15 +442,29 @@ func (e *Engine) triggerLiquidation(user UserRiskData, output risk.RiskOutput) {
 		Priority:  output.RiskRatio, // 风险率越高，优先级越高
 	}
 
-	// 非阻塞发送到队列
-	select {
-	case e.liquidationQueue <- task:
+	if e.enqueue(task) {
 		log.Printf("[Engine] Liquidation task queued: user=%d, riskRatio=%.4f",
 			user.UserID, output.RiskRatio)
+	}
+}
+
+// enqueue 非阻塞投递到队列，返回是否成功
+func (e *Engine) enqueue(task LiquidationTask) bool {
+	e.queueMu.RLock()
+	defer e.queueMu.RUnlock()
+
+	if e.queueClosed {
+		log.Printf("[Engine] WARNING: Engine stopped, liquidation task rejected: user=%d", task.UserID)
+		return false
+	}
+
+	select {
+	case e.liquidationQueue <- task:
+		return true
 	default:
 		// 队列满了，记录日志（生产环境应该告警）
-		log.Printf("[Engine] WARNING: Liquidation queue full, task dropped: user=%d",
-			user.UserID)
+		log.Printf("[Engine] WARNING: Liquidation queue full, task dropped: user=%d", task.UserID)
+		return false
 	}
 }
 
@@ -326,19 +474,20 @@ func (e *Engine) triggerLiquidation(user UserRiskData, output risk.RiskOutput) {
 
 // startWorkers 启动 Worker Pool
 func (e *Engine) startWorkers() {
+	queue := e.liquidationQueue
 	for i := 0; i < LiquidationWorkers; i++ {
-		e.wg.Add(1)
+		e.workerWg.Add(1)
 		go func(workerID int) {
-			defer e.wg.Done()
-			e.runWorker(workerID)
+			defer e.workerWg.Done()
+			e.runWorker(workerID, queue)
 		}(i)
 	}
 	log.Printf("[Engine] %d liquidation workers started", LiquidationWorkers)
 }
 
 // runWorker 单个 Worker 的主循环
-func (e *Engine) runWorker(workerID int) {
-	for task := range e.liquidationQueue {
+func (e *Engine) runWorker(workerID int, queue <-chan LiquidationTask) {
+	for task := range queue {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 
 		log.Printf("[Worker-%d] Processing liquidation: user=%d", workerID, task.UserID)
@@ -367,6 +516,9 @@ func (e *Engine) runWorker(workerID int) {
 // 由行情系统调用，当价格变化时检查 Level 3 用户
 // 这实现了 "毫秒级强平触发" 的需求
 func (e *Engine) OnPriceChange(symbol string, price float64) {
+	// 价格变动幅度决定全量扫描的频率
+	e.scanner.OnPriceChange(symbol, price)
+
 	// 获取持有该交易对的高风险用户
 	userIDs := e.index.GetUsersBySymbol(symbol)
 	if len(userIDs) == 0 {
@@ -408,12 +560,19 @@ func (e *Engine) OnPriceChange(symbol string, price float64) {
 
 // GetStats 获取引擎统计信息
 func (e *Engine) GetStats() EngineStats {
+	e.queueMu.RLock()
+	queued := len(e.liquidationQueue)
+	e.queueMu.RUnlock()
+	interval, stressed := e.scanner.CurrentInterval()
+
 	return EngineStats{
 		TotalHighRiskUsers: e.index.TotalCount(),
 		WarningUsers:       len(e.index.GetByLevel(RiskLevelWarning)),
 		DangerUsers:        len(e.index.GetByLevel(RiskLevelDanger)),
 		CriticalUsers:      len(e.index.GetByLevel(RiskLevelCritical)),
-		QueuedTasks:        len(e.liquidationQueue),
+		QueuedTasks:        queued,
+		ScanInterval:       interval,
+		MarketStressed:     stressed,
 	}
 }
 
@@ -424,4 +583,6 @@ type EngineStats struct {
 	DangerUsers        int
 	CriticalUsers      int
 	QueuedTasks        int
+	ScanInterval       time.Duration // 当前全量扫描间隔 (自适应时随行情变化)
+	MarketStressed     bool          // 自适应调度是否判定为市场压力状态
 }
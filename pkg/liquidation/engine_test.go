@@ -436,3 +436,134 @@ func TestEngine_Integration_FullFlow(t *testing.T) {
 	// 注意：由于扫描器直接创建 LiquidationTask 但没有发送到队列
 	// 这里可能需要检查 scanner 的逻辑
 }
+
+// =============================================================================
+// 停机测试
+// =============================================================================
+
+// memTaskStore 内存版 TaskStore
+type memTaskStore struct {
+	mu    sync.Mutex
+	tasks []LiquidationTask
+}
+
+func (s *memTaskStore) SavePending(ctx context.Context, tasks []LiquidationTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, tasks...)
+	return nil
+}
+
+func (s *memTaskStore) TakePending(ctx context.Context) ([]LiquidationTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := s.tasks
+	s.tasks = nil
+	return tasks, nil
+}
+
+// hammerStop 多个生产者持续投递任务的同时 Stop，返回成功入队的用户
+func hammerStop(t *testing.T, engine *Engine) map[int64]bool {
+	var (
+		nextID   atomic.Int64
+		mu       sync.Mutex
+		accepted = make(map[int64]bool)
+		done     = make(chan struct{})
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				userID := nextID.Add(1)
+				if engine.enqueue(LiquidationTask{UserID: userID}) {
+					mu.Lock()
+					accepted[userID] = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	engine.Stop() // 生产者还在投递，不能 panic
+	close(done)
+	wg.Wait()
+
+	if len(accepted) == 0 {
+		t.Fatal("no task accepted before Stop")
+	}
+	return accepted
+}
+
+func TestEngine_StopDrainsQueue(t *testing.T) {
+	executor := &MockLiquidationExecutor{ExecuteDelay: time.Millisecond}
+	engine := NewEngine(risk.NewEngine(), &MockUserDataProvider{}, executor)
+	engine.Start()
+
+	accepted := hammerStop(t, engine)
+
+	// 无 TaskStore：入队的任务在 Stop 返回前全部执行，一个不丢
+	executed := make(map[int64]bool)
+	for _, task := range executor.GetExecutedTasks() {
+		executed[task.UserID] = true
+	}
+	if len(executed) != len(accepted) {
+		t.Fatalf("executed %d tasks, accepted %d", len(executed), len(accepted))
+	}
+	for userID := range accepted {
+		if !executed[userID] {
+			t.Fatalf("accepted task for user %d was dropped", userID)
+		}
+	}
+
+	// 停机后的触发直接拒绝
+	if engine.enqueue(LiquidationTask{UserID: -1}) {
+		t.Error("enqueue after Stop should be rejected")
+	}
+}
+
+func TestEngine_StopPersistsQueue(t *testing.T) {
+	executor := &MockLiquidationExecutor{ExecuteDelay: 5 * time.Millisecond}
+	store := &memTaskStore{}
+	engine := NewEngine(risk.NewEngine(), &MockUserDataProvider{}, executor)
+	engine.SetTaskStore(store)
+	engine.Start()
+
+	accepted := hammerStop(t, engine)
+
+	// 执行过的 + 落盘的 = 入队的，且不重复
+	seen := make(map[int64]int)
+	for _, task := range executor.GetExecutedTasks() {
+		seen[task.UserID]++
+	}
+	stored := len(store.tasks)
+	if stored == 0 {
+		t.Fatal("expected backlog to be persisted on Stop")
+	}
+	for _, task := range store.tasks {
+		seen[task.UserID]++
+	}
+	if len(seen) != len(accepted) {
+		t.Fatalf("executed+persisted %d tasks, accepted %d", len(seen), len(accepted))
+	}
+	for userID, n := range seen {
+		if !accepted[userID] || n != 1 {
+			t.Fatalf("user %d: accepted=%v, handled %d times", userID, accepted[userID], n)
+		}
+	}
+
+	// 重启后取回落盘任务并执行
+	before := len(executor.GetExecutedTasks())
+	engine.Start()
+	engine.Stop()
+	if got := len(executor.GetExecutedTasks()) - before + len(store.tasks); got != stored {
+		t.Errorf("restored tasks: executed+persisted = %d, want %d", got, stored)
+	}
+}
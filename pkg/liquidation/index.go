@@ -228,6 +228,140 @@ func (m *CowMap) Remove(userID int64) {
 	m.BatchUpdate(nil, []int64{userID})
 }
 
+// =============================================================================
+// ShardedCowMap - 按 userID 分片的 CowMap
+// =============================================================================
+
+// IndexShards 风险等级索引的分片数 (2 的幂)
+//
+// 50 万高风险用户时每片约 8000 条，单次更新只复制一片
+const IndexShards = 64
+
+// ShardedCowMap 按 userID 分片的 Copy-on-Write Map
+//
+// 【为什么分片】
+// CowMap 每次写都复制整张 Map：几千用户时无所谓，暴跌时几十万用户同时升降级，
+// 每次单用户更新都复制几十 MB，写锁排队 + GC 压力直接把检查器拖垮
+// 分片后单次更新只复制所在分片，代价 O(总数 / 分片数)，产生的垃圾也按分片封顶
+//
+// 【代价】读仍然无锁；但跨分片的批量写不是原子的，读者可能看到一半分片已更新
+// 风险索引本身就是近实时的，检查器下一轮会重新计算，可以接受
+type ShardedCowMap struct {
+	shards [IndexShards]*CowMap
+}
+
+// NewShardedCowMap 创建分片 CowMap
+func NewShardedCowMap() *ShardedCowMap {
+	m := &ShardedCowMap{}
+	for i := range m.shards {
+		m.shards[i] = NewCowMap()
+	}
+	return m
+}
+
+// shardOf 用户所在分片
+func shardOf(userID int64) int {
+	return int(uint64(userID) & (IndexShards - 1))
+}
+
+// Get 获取指定用户的风险数据 (无锁)
+func (m *ShardedCowMap) Get(userID int64) (UserRiskData, bool) {
+	return m.shards[shardOf(userID)].Get(userID)
+}
+
+// Contains 检查用户是否存在 (无锁)
+func (m *ShardedCowMap) Contains(userID int64) bool {
+	return m.shards[shardOf(userID)].Contains(userID)
+}
+
+// Len 所有分片的用户总数
+func (m *ShardedCowMap) Len() int {
+	total := 0
+	for _, shard := range m.shards {
+		total += shard.Len()
+	}
+	return total
+}
+
+// GetAll 获取所有用户的风险数据 (复制)
+func (m *ShardedCowMap) GetAll() []UserRiskData {
+	result := make([]UserRiskData, 0, m.Len())
+	m.ForEach(func(data UserRiskData) {
+		result = append(result, data)
+	})
+	return result
+}
+
+// ForEach 逐分片遍历所有用户 (零分配)
+//
+// 每个分片读的是遍历到它时的快照，fn 里修改索引不影响本次遍历
+func (m *ShardedCowMap) ForEach(fn func(UserRiskData)) {
+	for _, shard := range m.shards {
+		shard.ForEach(fn)
+	}
+}
+
+// BatchUpdate 批量更新，按分片分组后每个涉及的分片只复制一次
+func (m *ShardedCowMap) BatchUpdate(updates []UserRiskData, removes []int64) {
+	if len(updates) == 0 && len(removes) == 0 {
+		return
+	}
+
+	// 单条更新 (检查器最常见的路径) 不用分组
+	if len(updates)+len(removes) == 1 {
+		if len(updates) == 1 {
+			m.shards[shardOf(updates[0].UserID)].BatchUpdate(updates, nil)
+		} else {
+			m.shards[shardOf(removes[0])].BatchUpdate(nil, removes)
+		}
+		return
+	}
+
+	var shardUpdates [IndexShards][]UserRiskData
+	var shardRemoves [IndexShards][]int64
+	for _, data := range updates {
+		i := shardOf(data.UserID)
+		shardUpdates[i] = append(shardUpdates[i], data)
+	}
+	for _, userID := range removes {
+		i := shardOf(userID)
+		shardRemoves[i] = append(shardRemoves[i], userID)
+	}
+	for i, shard := range m.shards {
+		if len(shardUpdates[i]) > 0 || len(shardRemoves[i]) > 0 {
+			shard.BatchUpdate(shardUpdates[i], shardRemoves[i])
+		}
+	}
+}
+
+// ReplaceAll 用 users 整体替换 (全量扫描后调用)
+//
+// 直接构建各分片的新 Map，不复制旧数据
+func (m *ShardedCowMap) ReplaceAll(users []UserRiskData) {
+	var maps [IndexShards]map[int64]UserRiskData
+	for i := range maps {
+		maps[i] = make(map[int64]UserRiskData, len(users)/IndexShards+1)
+	}
+	for _, data := range users {
+		maps[shardOf(data.UserID)][data.UserID] = data
+	}
+	for i, shard := range m.shards {
+		shard.writeMu.Lock()
+		shard.data.Store(&maps[i])
+		shard.writeMu.Unlock()
+	}
+}
+
+// Set 设置单个用户数据，只复制所在分片
+func (m *ShardedCowMap) Set(data UserRiskData) {
+	m.shards[shardOf(data.UserID)].Set(data)
+}
+
+// Remove 删除单个用户，只复制所在分片
+func (m *ShardedCowMap) Remove(userID int64) {
+	m.shards[shardOf(userID)].Remove(userID)
+}
+
 // =============================================================================
 // RiskLevelIndex - 风险等级索引
 // =============================================================================
@@ -235,7 +369,7 @@ func (m *CowMap) Remove(userID int64) {
 // RiskLevelIndex 风险等级索引
 //
 // 管理所有风险等级的用户数据
-// 每个等级使用独立的 CowMap，互不影响
+// 每个等级使用独立的 ShardedCowMap，互不影响
 //
 // 结构:
 //
@@ -251,7 +385,7 @@ type RiskLevelIndex struct {
 	//   index 0 = Warning
 	//   index 1 = Danger
 	//   index 2 = Critical
-	levels [3]*CowMap
+	levels [3]*ShardedCowMap
 
 	// symbolToUsers: 交易对 → 用户ID 列表
 	// 用于：行情变化时，快速找到持有该交易对的高风险用户
@@ -260,9 +394,6 @@ type RiskLevelIndex struct {
 	// 而不是检查所有高风险用户
 	symbolToUsers atomic.Pointer[map[string][]int64]
 
-	// 新增：userId -> level 的快速查找索引
-	userLevelIndex atomic.Pointer[map[int64]RiskLevel]
-
 	// symbolMu: 保护 symbolToUsers 的更新
 	symbolMu sync.Mutex
 }
@@ -270,10 +401,10 @@ type RiskLevelIndex struct {
 // NewRiskLevelIndex 创建新的风险等级索引
 func NewRiskLevelIndex() *RiskLevelIndex {
 	idx := &RiskLevelIndex{
-		levels: [3]*CowMap{
-			NewCowMap(), // Warning
-			NewCowMap(), // Danger
-			NewCowMap(), // Critical
+		levels: [3]*ShardedCowMap{
+			NewShardedCowMap(), // Warning
+			NewShardedCowMap(), // Danger
+			NewShardedCowMap(), // Critical
 		},
 	}
 
@@ -281,10 +412,6 @@ func NewRiskLevelIndex() *RiskLevelIndex {
 	emptySymbolMap := make(map[string][]int64)
 	idx.symbolToUsers.Store(&emptySymbolMap)
 
-	// 初始化 userLevelIndex
-	emptyUserLevelMap := make(map[int64]RiskLevel)
-	idx.userLevelIndex.Store(&emptyUserLevelMap)
-
 	return idx
 }
 
@@ -312,15 +439,13 @@ func (idx *RiskLevelIndex) GetByLevel(level RiskLevel) []UserRiskData {
 	return idx.levels[i].GetAll()
 }
 
-// GetByLevelReadOnly 返回指定等级用户的只读 Map 指针
-// 优化: 零内存分配
-// 警告: 不要修改返回的 Map！
-func (idx *RiskLevelIndex) GetByLevelReadOnly(level RiskLevel) *map[int64]UserRiskData {
+// CountByLevel 指定等级的用户数
+func (idx *RiskLevelIndex) CountByLevel(level RiskLevel) int {
 	i := levelToIndex(level)
 	if i < 0 {
-		return nil
+		return 0
 	}
-	return idx.levels[i].GetAllReadOnly()
+	return idx.levels[i].Len()
 }
 
 // ForEachByLevel 遍历指定等级的所有用户
@@ -334,20 +459,17 @@ func (idx *RiskLevelIndex) ForEachByLevel(level RiskLevel, fn func(UserRiskData)
 }
 
 // GetUser 获取指定用户（从所有等级中查找）
+//
+// 依次查三个等级的分片，每次都是 O(1) 无锁读
+// 【设计】原先另维护一份 userId → level 的全量 CoW Map，每次更新都要整表复制，
+// 而且和等级 Map 两处写入不是原子的，去掉后反而更一致
 func (idx *RiskLevelIndex) GetUser(userID int64) (UserRiskData, bool) {
-	levelMap := idx.userLevelIndex.Load()
-	level, ok := (*levelMap)[userID]
-	if !ok {
-		return UserRiskData{}, false
-	}
-
-	// 2. 直接去对应 level 查找，O(1)
-	i := levelToIndex(level)
-	if i < 0 {
-		return UserRiskData{}, false
+	for i := len(idx.levels) - 1; i >= 0; i-- {
+		if data, ok := idx.levels[i].Get(userID); ok {
+			return data, true
+		}
 	}
-	return idx.levels[i].Get(userID)
-
+	return UserRiskData{}, false
 }
 
 // UpdateUser 更新用户数据（自动处理等级变化）
@@ -356,47 +478,44 @@ func (idx *RiskLevelIndex) GetUser(userID int64) (UserRiskData, bool) {
 //  1. 根据新的风险率计算新等级
 //  2. 如果等级变化，从旧等级移除，加入新等级
 //  3. 如果等级不变，直接更新数据
+//
+// 大量用户同时变化时用 UpdateUsers 批量更新
 func (idx *RiskLevelIndex) UpdateUser(data UserRiskData) {
-	newLevel := CalculateRiskLevel(data.RiskRatio)
-	newIndex := levelToIndex(newLevel)
+	idx.UpdateUsers([]UserRiskData{data})
+}
 
-	// 从所有等级中移除（如果存在）
-	for i, level := range idx.levels {
-		if level.Contains(data.UserID) {
-			if i != newIndex {
-				// 等级变化，需要移除
-				level.Remove(data.UserID)
+// UpdateUsers 批量更新用户数据
+//
+// 按等级、分片分组后每个涉及的分片只复制一次，暴跌时检查器一轮的升降级合并写入
+// 先加入新等级再从旧等级移除，读者不会看到用户"消失"
+func (idx *RiskLevelIndex) UpdateUsers(users []UserRiskData) {
+	var updates [3][]UserRiskData
+	var removes [3][]int64
+
+	for _, data := range users {
+		newLevel := CalculateRiskLevel(data.RiskRatio)
+		newIndex := levelToIndex(newLevel)
+
+		// 从其他等级中移除（如果存在）
+		for i, level := range idx.levels {
+			if i != newIndex && level.Contains(data.UserID) {
+				removes[i] = append(removes[i], data.UserID)
 			}
 		}
-	}
 
-	// 加入新等级（如果不是 Safe 或 Liquidate）
-	if newIndex >= 0 {
-		data.Level = newLevel
-		// 同步更新 userLevelIndex
-		idx.updateUserLevelIndex(data.UserID, newLevel)
-		idx.levels[newIndex].Set(data)
+		// 加入新等级（如果不是 Safe 或 Liquidate）
+		if newIndex >= 0 {
+			data.Level = newLevel
+			updates[newIndex] = append(updates[newIndex], data)
+		}
 	}
 
-}
-
-func (idx *RiskLevelIndex) updateUserLevelIndex(userID int64, level RiskLevel) {
-	idx.symbolMu.Lock()
-	defer idx.symbolMu.Unlock()
-
-	oldMap := idx.userLevelIndex.Load()
-	newMap := make(map[int64]RiskLevel, len(*oldMap)+1)
-	for k, v := range *oldMap {
-		newMap[k] = v
+	for i, level := range idx.levels {
+		level.BatchUpdate(updates[i], nil)
 	}
-
-	if level == RiskLevelSafe || level == RiskLevelLiquidate {
-		delete(newMap, userID) // 安全或强平，从索引移除
-	} else {
-		newMap[userID] = level
+	for i, level := range idx.levels {
+		level.BatchUpdate(nil, removes[i])
 	}
-
-	idx.userLevelIndex.Store(&newMap)
 }
 
 // BatchUpdateLevel 批量更新指定等级的数据
@@ -409,22 +528,8 @@ func (idx *RiskLevelIndex) BatchUpdateLevel(level RiskLevel, users []UserRiskDat
 		return
 	}
 
-	// 提取要删除的用户（现在在这个等级，但不在新数据中）
-	currentUsers := idx.levels[i].GetAll()
-	newUserSet := make(map[int64]struct{}, len(users))
-	for _, u := range users {
-		newUserSet[u.UserID] = struct{}{}
-	}
-
-	var removes []int64
-	for _, u := range currentUsers {
-		if _, exists := newUserSet[u.UserID]; !exists {
-			removes = append(removes, u.UserID)
-		}
-	}
-
-	// 批量更新
-	idx.levels[i].BatchUpdate(users, removes)
+	// 全量替换：直接构建新分片，不用先算差集再复制旧数据
+	idx.levels[i].ReplaceAll(users)
 }
 
 // GetUsersBySymbol 获取持有指定交易对的高风险用户
@@ -210,6 +210,84 @@ func TestCowMap_ConcurrentReadWrite(t *testing.T) {
 // RiskLevelIndex 单元测试
 // =============================================================================
 
+func TestShardedCowMap_BatchUpdate(t *testing.T) {
+	m := NewShardedCowMap()
+
+	// 跨越所有分片
+	updates := make([]UserRiskData, 0, 1000)
+	for i := int64(1); i <= 1000; i++ {
+		updates = append(updates, UserRiskData{UserID: i, RiskRatio: 0.75})
+	}
+	m.BatchUpdate(updates, nil)
+	if m.Len() != 1000 {
+		t.Fatalf("Len = %d, want 1000", m.Len())
+	}
+
+	// 同一批里更新 + 删除
+	m.BatchUpdate([]UserRiskData{{UserID: 1, RiskRatio: 0.85}}, []int64{2, 3, 64, 65})
+	if m.Len() != 996 {
+		t.Errorf("Len = %d, want 996", m.Len())
+	}
+	if data, ok := m.Get(1); !ok || data.RiskRatio != 0.85 {
+		t.Errorf("user 1 = %+v, want RiskRatio 0.85", data)
+	}
+	for _, id := range []int64{2, 3, 64, 65} {
+		if m.Contains(id) {
+			t.Errorf("user %d should be removed", id)
+		}
+	}
+	if got := len(m.GetAll()); got != 996 {
+		t.Errorf("GetAll len = %d, want 996", got)
+	}
+
+	// 整体替换
+	m.ReplaceAll([]UserRiskData{{UserID: 7}, {UserID: 71}})
+	if m.Len() != 2 || !m.Contains(71) || m.Contains(1) {
+		t.Errorf("after ReplaceAll: len=%d", m.Len())
+	}
+}
+
+func TestRiskLevelIndex_UpdateUsers(t *testing.T) {
+	idx := NewRiskLevelIndex()
+
+	var batch []UserRiskData
+	for i := int64(1); i <= 300; i++ {
+		batch = append(batch, UserRiskData{UserID: i, RiskRatio: 0.75})
+	}
+	idx.UpdateUsers(batch)
+	if idx.CountByLevel(RiskLevelWarning) != 300 {
+		t.Fatalf("Warning count = %d, want 300", idx.CountByLevel(RiskLevelWarning))
+	}
+
+	// 一批里：100 个升 Danger，100 个升 Critical，100 个回到 Safe
+	batch = batch[:0]
+	for i := int64(1); i <= 300; i++ {
+		ratio := 0.85
+		switch {
+		case i > 200:
+			ratio = 0.50
+		case i > 100:
+			ratio = 0.95
+		}
+		batch = append(batch, UserRiskData{UserID: i, RiskRatio: ratio})
+	}
+	idx.UpdateUsers(batch)
+
+	if idx.CountByLevel(RiskLevelWarning) != 0 {
+		t.Errorf("Warning count = %d, want 0", idx.CountByLevel(RiskLevelWarning))
+	}
+	if idx.CountByLevel(RiskLevelDanger) != 100 || idx.CountByLevel(RiskLevelCritical) != 100 {
+		t.Errorf("Danger=%d Critical=%d, want 100/100",
+			idx.CountByLevel(RiskLevelDanger), idx.CountByLevel(RiskLevelCritical))
+	}
+	if user, ok := idx.GetUser(150); !ok || user.Level != RiskLevelCritical {
+		t.Errorf("user 150 = %+v, want Critical", user)
+	}
+	if _, ok := idx.GetUser(250); ok {
+		t.Error("user 250 should be removed when Safe")
+	}
+}
+
 func TestRiskLevelIndex_GetByLevel(t *testing.T) {
 	idx := NewRiskLevelIndex()
 
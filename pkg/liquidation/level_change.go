@@ -0,0 +1,47 @@
+package liquidation
+
+import "time"
+
+// =============================================================================
+// 风险等级变化通知
+// =============================================================================
+//
+// 检查器 (resolveLevelChange) 和全量扫描 (Scan) 都可能改变用户等级，两处都回调：
+//   - 检查器：索引里已有的用户等级变化
+//   - 全量扫描：新进入索引的用户 (Safe → Warning 等)、扫描时等级变化的用户
+//
+// 【注意】回调在检查器 / 扫描 goroutine 里同步执行，必须很快返回 (只入队，不做 IO)；
+// 脱离风险 (→ Safe) 的用户在全量扫描里直接被替换出索引，不回调
+
+// LevelChange 用户风险等级变化
+type LevelChange struct {
+	UserID    int64
+	From      RiskLevel
+	To        RiskLevel
+	RiskRatio float64
+	At        int64 // Unix 毫秒
+}
+
+// Escalated 风险等级是否升高
+func (c LevelChange) Escalated() bool {
+	return c.To > c.From
+}
+
+// levelObservers 等级变化回调列表 (Start 之前注册，之后只读)
+type levelObservers []func(LevelChange)
+
+func (o levelObservers) emit(userID int64, from, to RiskLevel, riskRatio float64) {
+	if len(o) == 0 || from == to {
+		return
+	}
+	change := LevelChange{UserID: userID, From: from, To: to, RiskRatio: riskRatio, At: time.Now().UnixMilli()}
+	for _, fn := range o {
+		fn(change)
+	}
+}
+
+// OnLevelChange 注册风险等级变化回调 (预警通知)，须在 Start 之前调用
+func (e *Engine) OnLevelChange(fn func(LevelChange)) {
+	e.levelObservers = append(e.levelObservers, fn)
+	e.scanner.observers = e.levelObservers
+}
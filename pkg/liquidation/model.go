@@ -177,6 +177,10 @@ type LiquidationTask struct {
 	// Priority 优先级（风险率越高，优先级越高）
 	// 用于优先级队列排序
 	Priority float64
+
+	// DryRun 只预演不执行
+	// 执行器按生产路径计算强平单和预计盈亏，填入 Details 后返回，不下单、不改持仓
+	DryRun bool
 }
 
 // LiquidationResult 强平执行结果
@@ -193,8 +197,11 @@ type LiquidationResult struct {
 	// ExecutedAt 执行时间
 	ExecutedAt time.Time
 
-	// Details 详细信息
+	// Details 详细信息（DryRun 时为预估值）
 	Details LiquidationDetails
+
+	// DryRun 是否为预演结果
+	DryRun bool
 }
 
 // LiquidationDetails 强平详情
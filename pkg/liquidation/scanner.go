@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"max.com/pkg/featureflag"
 	"max.com/pkg/risk"
 )
 
@@ -98,6 +99,10 @@ type Scanner struct {
 	riskEngine   *risk.Engine // 使用已有的风控引擎
 	numShards    int
 	scanInterval time.Duration
+	adaptive     *adaptiveInterval  // 自适应间隔 (可选)，nil 时固定 scanInterval
+	observers    levelObservers     // 等级变化回调 (可选，见 level_change.go)
+	shadow       *risk.Shadow       // 影子风控模型比对 (可选，见 shadow.go)
+	flags        *featureflag.Flags // 影子比对的开关与用户采样比例
 	running      bool
 	stopCh       chan struct{}
 	wg           sync.WaitGroup
@@ -133,6 +138,35 @@ func (s *Scanner) SetScanInterval(d time.Duration) {
 	}
 }
 
+// SetAdaptiveInterval 启用自适应扫描间隔 (见 adaptive.go)，须在 Start 之前调用
+func (s *Scanner) SetAdaptiveInterval(cfg AdaptiveScanConfig) {
+	s.adaptive = newAdaptiveInterval(cfg)
+}
+
+// OnPriceChange 喂价格给自适应调度，未启用时忽略
+func (s *Scanner) OnPriceChange(symbol string, price float64) {
+	if s.adaptive != nil {
+		s.adaptive.observePrice(symbol, price)
+	}
+}
+
+// CurrentInterval 当前扫描间隔，以及是否处于市场压力状态
+func (s *Scanner) CurrentInterval() (time.Duration, bool) {
+	if s.adaptive == nil {
+		return s.scanInterval, false
+	}
+	return s.adaptive.interval()
+}
+
+// nextInterval 一轮扫描后决定下一轮间隔
+func (s *Scanner) nextInterval() time.Duration {
+	if s.adaptive == nil {
+		return s.scanInterval
+	}
+	stressed := s.index.CountByLevel(RiskLevelDanger) + s.index.CountByLevel(RiskLevelCritical)
+	return s.adaptive.next(stressed)
+}
+
 // =============================================================================
 // 扫描器生命周期
 // =============================================================================
@@ -173,16 +207,26 @@ func (s *Scanner) runLoop() {
 	// 启动时立即执行一次扫描
 	s.Scan(context.Background())
 
-	ticker := time.NewTicker(s.scanInterval)
-	defer ticker.Stop()
+	// 间隔可变，用 Timer 每轮重设（自适应关闭时等价于固定间隔的 Ticker）
+	timer := time.NewTimer(s.nextInterval())
+	defer timer.Stop()
+
+	var wake <-chan struct{} // nil channel：未启用自适应时永不触发
+	if s.adaptive != nil {
+		wake = s.adaptive.wake
+	}
 
 	for {
 		select {
 		case <-s.stopCh:
 			return
-		case <-ticker.C:
-			s.Scan(context.Background())
+		case <-timer.C:
+		case <-wake:
+			// 行情突变，提前扫描
+			timer.Stop()
 		}
+		s.Scan(context.Background())
+		timer.Reset(s.nextInterval())
 	}
 }
 
@@ -251,7 +295,16 @@ func (s *Scanner) Scan(ctx context.Context) {
 		putShardResultMap(result)
 	}
 
-	// 5. 批量更新索引
+	// 5. 批量更新索引 (替换前先和旧索引比对，通知等级变化)
+	if len(s.observers) > 0 {
+		for _, group := range [][]UserRiskData{levelWarning, levelDanger, levelCritical} {
+			s.emitLevelChanges(group)
+		}
+		for _, task := range liquidateTasks {
+			old, _ := s.index.GetUser(task.UserID)
+			s.observers.emit(task.UserID, old.Level, RiskLevelLiquidate, task.RiskRatio)
+		}
+	}
 	s.index.BatchUpdateLevel(RiskLevelWarning, levelWarning)
 	s.index.BatchUpdateLevel(RiskLevelDanger, levelDanger)
 	s.index.BatchUpdateLevel(RiskLevelCritical, levelCritical)
@@ -274,6 +327,14 @@ func (s *Scanner) Scan(ctx context.Context) {
 	// 这部分在 engine.go 中实现
 }
 
+// emitLevelChanges 和索引里的旧等级比对，回调等级变化 (不在索引里的视为 Safe)
+func (s *Scanner) emitLevelChanges(users []UserRiskData) {
+	for _, data := range users {
+		old, _ := s.index.GetUser(data.UserID)
+		s.observers.emit(data.UserID, old.Level, data.Level, data.RiskRatio)
+	}
+}
+
 // shardUsers 将用户ID分片
 //
 // 使用取模方式分片，保证同一用户始终在同一分片
@@ -335,8 +396,8 @@ func (s *Scanner) processShard(ctx context.Context, userIDs []int64, scanTime in
 			continue
 		}
 
-		// 调用已有的风控引擎计算
-		riskOutput, err := s.riskEngine.ComputeRisk(riskInput)
+		// 调用已有的风控引擎计算 (开启影子比对的用户同时跑影子模型，结果仍以线上模型为准)
+		riskOutput, err := s.computeRisk(userID, riskInput)
 		if err != nil {
 			log.Printf("[Scanner] Failed to compute risk for user %d: %v", userID, err)
 			continue
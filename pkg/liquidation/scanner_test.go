@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"max.com/pkg/featureflag"
 	"max.com/pkg/risk"
 )
 
@@ -232,6 +233,38 @@ func TestScanner_Scan_Basic(t *testing.T) {
 	}
 }
 
+func TestScanner_Scan_LevelChanges(t *testing.T) {
+	index := NewRiskLevelIndex()
+	provider := &MockUserDataProvider{
+		UserIDs: []int64{1, 2},
+		UserRiskInputs: map[int64]risk.RiskInput{
+			1: createMockRiskInput(1, "BTC_USDT", 0.75), // Warning
+			2: createMockRiskInput(2, "BTC_USDT", 0.85), // Danger
+		},
+	}
+	scanner := NewScanner(index, provider, risk.NewEngine())
+	var changes []LevelChange
+	scanner.observers = levelObservers{func(c LevelChange) { changes = append(changes, c) }}
+
+	scanner.Scan(context.Background())
+	if len(changes) != 2 {
+		t.Fatalf("first scan changes = %d, want 2", len(changes))
+	}
+	for _, c := range changes {
+		if c.From != RiskLevelSafe || !c.Escalated() {
+			t.Errorf("user %d: %s -> %s, want escalation from Safe", c.UserID, c.From, c.To)
+		}
+	}
+
+	// 等级没变的用户不重复回调
+	changes = nil
+	provider.UserRiskInputs[2] = createMockRiskInput(2, "BTC_USDT", 0.95) // Critical
+	scanner.Scan(context.Background())
+	if len(changes) != 1 || changes[0].UserID != 2 || changes[0].From != RiskLevelDanger || changes[0].To != RiskLevelCritical {
+		t.Fatalf("second scan changes = %+v", changes)
+	}
+}
+
 func TestScanner_Scan_EmptyUsers(t *testing.T) {
 	index := NewRiskLevelIndex()
 	provider := &MockUserDataProvider{
@@ -353,3 +386,147 @@ func TestScanner_ConvertToUserRiskData(t *testing.T) {
 		t.Errorf("Symbols length = %d, want 2", len(data.Symbols))
 	}
 }
+
+// =============================================================================
+// 自适应扫描间隔测试
+// =============================================================================
+
+func TestAdaptiveInterval_PriceStress(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := newAdaptiveInterval(AdaptiveScanConfig{
+		MinInterval: time.Second,
+		MaxInterval: 8 * time.Second,
+		Now:         func() time.Time { return now },
+	})
+
+	if got := a.next(0); got != 8*time.Second {
+		t.Errorf("calm interval = %v, want 8s", got)
+	}
+
+	// 窗口内跌 2% → 压力，间隔减半直到下限，并唤醒扫描器
+	a.observePrice("BTCUSDT", 50000)
+	now = now.Add(10 * time.Second)
+	a.observePrice("BTCUSDT", 49000)
+	select {
+	case <-a.wake:
+	default:
+		t.Error("expected wake signal on calm -> stressed transition")
+	}
+	for _, want := range []time.Duration{4 * time.Second, 2 * time.Second, time.Second, time.Second} {
+		if got := a.next(0); got != want {
+			t.Errorf("stressed interval = %v, want %v", got, want)
+		}
+	}
+	if _, stressed := a.interval(); !stressed {
+		t.Error("expected stressed state")
+	}
+
+	// 压力期间再次剧烈变动不重复唤醒
+	a.observePrice("BTCUSDT", 48000)
+	select {
+	case <-a.wake:
+		t.Error("unexpected wake while already stressed")
+	default:
+	}
+
+	// 窗口过后恢复平静，按 1.5 倍放宽回上限
+	now = now.Add(2 * time.Minute)
+	for _, want := range []time.Duration{1500 * time.Millisecond, 2250 * time.Millisecond} {
+		if got := a.next(0); got != want {
+			t.Errorf("calm interval = %v, want %v", got, want)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		a.next(0)
+	}
+	if got, stressed := a.interval(); got != 8*time.Second || stressed {
+		t.Errorf("interval = %v stressed=%v, want 8s calm", got, stressed)
+	}
+}
+
+func TestAdaptiveInterval_SmallMoveIgnored(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := newAdaptiveInterval(AdaptiveScanConfig{Now: func() time.Time { return now }})
+
+	a.observePrice("BTCUSDT", 50000)
+	now = now.Add(time.Second)
+	a.observePrice("BTCUSDT", 50200) // 0.4%
+	if got := a.next(0); got != DefaultScanInterval {
+		t.Errorf("interval = %v, want %v", got, DefaultScanInterval)
+	}
+}
+
+func TestScanner_AdaptiveStressedUsers(t *testing.T) {
+	index := NewRiskLevelIndex()
+	scanner := NewScanner(index, &MockUserDataProvider{}, risk.NewEngine())
+	scanner.SetAdaptiveInterval(AdaptiveScanConfig{
+		MinInterval:   time.Second,
+		MaxInterval:   4 * time.Second,
+		StressedUsers: 2,
+	})
+
+	index.UpdateUser(UserRiskData{UserID: 1, RiskRatio: ThresholdDanger})
+	if got := scanner.nextInterval(); got != 4*time.Second {
+		t.Errorf("interval = %v, want 4s", got)
+	}
+
+	index.UpdateUser(UserRiskData{UserID: 2, RiskRatio: ThresholdCritical})
+	if got := scanner.nextInterval(); got != 2*time.Second {
+		t.Errorf("interval = %v, want 2s", got)
+	}
+	if got, stressed := scanner.CurrentInterval(); got != 2*time.Second || !stressed {
+		t.Errorf("CurrentInterval = %v stressed=%v, want 2s stressed", got, stressed)
+	}
+}
+
+// =============================================================================
+// 影子风控模型测试
+// =============================================================================
+
+// doubledMMR 影子模型：维持保证金率翻倍
+type doubledMMR struct{}
+
+func (doubledMMR) ComputeRisk(in risk.RiskInput) (risk.RiskOutput, error) {
+	scaled := in
+	scaled.Positions = make([]risk.Position, len(in.Positions))
+	for i, p := range in.Positions {
+		p.MaintenanceMarginRate *= 2
+		scaled.Positions[i] = p
+	}
+	return risk.NewEngine().ComputeRisk(scaled)
+}
+
+func TestScanner_ShadowRisk(t *testing.T) {
+	provider := &MockUserDataProvider{
+		UserIDs: []int64{1, 2},
+		UserRiskInputs: map[int64]risk.RiskInput{
+			1: createMockRiskInput(1, "BTC_USDT", 0.50), // 影子: 1.00
+			2: createMockRiskInput(2, "BTC_USDT", 0.75), // 影子: 1.50
+		},
+	}
+	shadow := risk.NewShadow(risk.NewEngine(), doubledMMR{}, risk.ShadowConfig{})
+
+	// 开关关闭：不跑影子模型
+	index := NewRiskLevelIndex()
+	scanner := NewScanner(index, provider, risk.NewEngine())
+	scanner.SetShadow(shadow, nil)
+	scanner.Scan(context.Background())
+	if st := shadow.Stats(); st.Evaluations != 0 {
+		t.Fatalf("Evaluations = %d with flag off, want 0", st.Evaluations)
+	}
+
+	// 开关打开：全部用户比对，强平判定仍以线上模型为准
+	flags := featureflag.New(&featureflag.Config{Flags: map[string]featureflag.Rule{
+		FlagShadowRisk.Name(): {Enabled: true},
+	}})
+	scanner.SetShadow(shadow, flags)
+	scanner.Scan(context.Background())
+
+	st := shadow.Stats()
+	if st.Evaluations != 2 || st.Divergences != 2 || st.LiquidationFlips != 2 {
+		t.Errorf("stats = %+v, want 2 evaluations, 2 flips", st)
+	}
+	if index.TotalCount() != 1 || len(index.GetByLevel(RiskLevelWarning)) != 1 {
+		t.Errorf("index should follow primary model: total = %d", index.TotalCount())
+	}
+}
@@ -0,0 +1,37 @@
+package liquidation
+
+import (
+	"max.com/pkg/featureflag"
+	"max.com/pkg/risk"
+)
+
+// =============================================================================
+// 影子风控模型接入 (见 risk/shadow.go)
+// =============================================================================
+//
+// 全量扫描是覆盖所有持仓用户的唯一路径，在这里并行跑新旧模型，拿到的就是全量真实持仓的比对结果。
+// 强平判定始终以线上模型为准，影子模型只产出统计 (Shadow.Stats)。
+//
+// 由功能开关控制，percent 即采样比例 (按用户分桶，同一用户每轮扫描要么都比对、要么都不比对)：
+//
+//	flags:
+//	  liquidation.shadow_risk:
+//	    enabled: true
+//	    percent: 10
+
+// FlagShadowRisk 扫描时运行影子风控模型
+var FlagShadowRisk = featureflag.Define("liquidation.shadow_risk", false)
+
+// SetShadow 设置影子评估，须在 Start 之前调用；flags 为 nil 时取开关默认值 (关闭)
+func (s *Scanner) SetShadow(shadow *risk.Shadow, flags *featureflag.Flags) {
+	s.shadow = shadow
+	s.flags = flags
+}
+
+// computeRisk 计算用户风险，开关打开时经影子评估
+func (s *Scanner) computeRisk(userID int64, in risk.RiskInput) (risk.RiskOutput, error) {
+	if s.shadow != nil && s.flags.Enabled(FlagShadowRisk, "", userID) {
+		return s.shadow.Evaluate(userID, in)
+	}
+	return s.riskEngine.ComputeRisk(in)
+}
@@ -0,0 +1,157 @@
+package market
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+
+	"max.com/pkg/mtrade"
+)
+
+// DepthBook 可查询深度的订单簿（*mtrade.Engine 实现）
+type DepthBook interface {
+	GetAggregatedDepth(n int, step int64) mtrade.DepthSnapshot
+}
+
+var _ DepthBook = (*mtrade.Engine)(nil)
+
+const (
+	defaultDepthLimit = 20
+	maxDepthLimit     = 500
+)
+
+// DepthService 深度查询（按交易对注册订单簿）
+//
+// 聚合步长由运营按交易对配置（如 BTC_USDT: 0.5 / 1 / 10 USD），
+// 只接受配置过的步长：任意步长意味着任意组合都要计算，前端也只会展示固定几个选项
+type DepthService struct {
+	mu    sync.RWMutex
+	books map[string]depthEntry
+}
+
+type depthEntry struct {
+	book  DepthBook
+	steps []int64 // 允许的聚合步长（定点数），为空表示只提供原始深度
+}
+
+// NewDepthService 创建深度查询服务
+func NewDepthService() *DepthService {
+	return &DepthService{books: make(map[string]depthEntry)}
+}
+
+// Register 注册交易对的订单簿与允许的聚合步长
+func (s *DepthService) Register(symbol string, book DepthBook, steps ...int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.books[symbol] = depthEntry{book: book, steps: slices.Clone(steps)}
+}
+
+// Steps 交易对允许的聚合步长
+func (s *DepthService) Steps(symbol string) ([]int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.books[symbol]
+	return e.steps, ok
+}
+
+// Depth 查询深度，step 为 0 返回原始档位；步长未配置返回 ok=false
+func (s *DepthService) Depth(symbol string, n int, step int64) (mtrade.DepthSnapshot, bool) {
+	s.mu.RLock()
+	e, ok := s.books[symbol]
+	s.mu.RUnlock()
+	if !ok || (step != 0 && !slices.Contains(e.steps, step)) {
+		return mtrade.DepthSnapshot{}, false
+	}
+	return e.book.GetAggregatedDepth(n, step), true
+}
+
+// DepthResponse 深度接口响应，档位为 [price, quantity]
+type DepthResponse struct {
+	Symbol string     `json:"symbol"`
+	Seq    uint64     `json:"seq"`
+	Step   int64      `json:"step"`
+	Bids   [][2]int64 `json:"bids"`
+	Asks   [][2]int64 `json:"asks"`
+}
+
+// Handler 深度接口
+//
+//	GET /market/depth?symbol=BTC_USDT&limit=20&step=1000000000
+//	    limit 默认 20，最大 500；step 为空或 0 返回原始档位，否则必须是已配置的步长
+//	GET /market/depth/steps?symbol=BTC_USDT
+func (s *DepthService) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/market/depth", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		symbol := q.Get("symbol")
+		limit, step, bad := depthParams(q)
+		if bad != "" {
+			http.Error(w, "invalid "+bad, http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.Steps(symbol); !ok {
+			http.Error(w, "unknown symbol", http.StatusNotFound)
+			return
+		}
+		depth, ok := s.Depth(symbol, limit, step)
+		if !ok {
+			http.Error(w, "unsupported step", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, DepthResponse{
+			Symbol: symbol,
+			Seq:    depth.Seq,
+			Step:   step,
+			Bids:   levelPairs(depth.Bids),
+			Asks:   levelPairs(depth.Asks),
+		})
+	})
+	mux.HandleFunc("/market/depth/steps", func(w http.ResponseWriter, r *http.Request) {
+		steps, ok := s.Steps(r.URL.Query().Get("symbol"))
+		if !ok {
+			http.Error(w, "unknown symbol", http.StatusNotFound)
+			return
+		}
+		if steps == nil {
+			steps = []int64{}
+		}
+		writeJSON(w, steps)
+	})
+	return mux
+}
+
+// depthParams 解析 limit / step，非法时返回参数名
+func depthParams(q url.Values) (limit int, step int64, bad string) {
+	limit = defaultDepthLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDepthLimit {
+			return 0, 0, "limit"
+		}
+		limit = n
+	}
+	if v := q.Get("step"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, "step"
+		}
+		step = n
+	}
+	return limit, step, ""
+}
+
+func levelPairs(levels []mtrade.DepthLevel) [][2]int64 {
+	out := make([][2]int64, len(levels))
+	for i, lv := range levels {
+		out[i] = [2]int64{lv.Price, lv.Quantity}
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
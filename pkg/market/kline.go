@@ -0,0 +1,212 @@
+package market
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// K 线
+// =============================================================================
+//
+// 成交实时聚合成各周期 K 线，每个 (交易对, 周期) 保留最近 N 根（默认 1000）。
+// 更久远的 K 线属于历史数据，由离线任务从成交落库生成，不在内存里维护。
+//
+// 只有有成交的周期才有 K 线（不补空 K 线），与多数交易所一致：前端按上一根收盘价补齐。
+
+// KlineIntervals 支持的 K 线周期
+var KlineIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+const (
+	defaultKlineHistory = 1000
+	defaultKlineLimit   = 500
+	maxKlineLimit       = 1000
+)
+
+// Kline 一根 K 线（价格、数量为定点数，时间为毫秒）
+type Kline struct {
+	OpenTime    int64 `json:"open_time"`
+	CloseTime   int64 `json:"close_time"` // 周期最后一毫秒
+	Open        int64 `json:"open"`
+	High        int64 `json:"high"`
+	Low         int64 `json:"low"`
+	Close       int64 `json:"close"`
+	Volume      int64 `json:"volume"`
+	QuoteVolume int64 `json:"quote_volume"`
+	Trades      int64 `json:"trades"`
+}
+
+// KlineQuery K 线查询条件（时间都是毫秒）
+//
+// StartTime > 0: 从 StartTime 起按时间升序取 Limit 根
+// StartTime = 0: 取 EndTime 之前最近的 Limit 根（仍按时间升序返回）
+type KlineQuery struct {
+	Symbol    string
+	Interval  string
+	StartTime int64 // 包含（按开盘时间）；0 表示不限
+	EndTime   int64 // 不包含（按开盘时间）；0 表示不限
+	Limit     int   // <=0 使用默认值 500，最大 1000
+}
+
+func (q KlineQuery) limit() int {
+	if q.Limit <= 0 {
+		return defaultKlineLimit
+	}
+	return min(q.Limit, maxKlineLimit)
+}
+
+// klineSeries 一个 (交易对, 周期) 的 K 线，按开盘时间升序
+type klineSeries struct {
+	width int64 // 周期毫秒数
+	bars  []Kline
+}
+
+// add 记一笔成交（调用方持锁）
+func (k *klineSeries) add(price, qty, atMillis int64, keep int) {
+	open := atMillis - atMillis%k.width
+	i := len(k.bars) - 1
+	if i < 0 || k.bars[i].OpenTime != open {
+		// 新周期追加在末尾；乱序成交（恢复回放）落在保留范围内才补进去
+		var found bool
+		i, found = slices.BinarySearchFunc(k.bars, open, byOpenTime)
+		if !found {
+			if i == 0 && len(k.bars) >= keep {
+				return
+			}
+			k.bars = slices.Insert(k.bars, i, Kline{OpenTime: open, CloseTime: open + k.width - 1, Open: price, High: price, Low: price})
+			if len(k.bars) > keep {
+				k.bars = slices.Delete(k.bars, 0, 1)
+				i--
+			}
+		}
+	}
+	b := &k.bars[i]
+	b.High = max(b.High, price)
+	b.Low = min(b.Low, price)
+	b.Close = price
+	b.Volume += qty
+	b.QuoteVolume += quoteAmount(price, qty)
+	b.Trades++
+}
+
+func byOpenTime(b Kline, t int64) int {
+	return cmp.Compare(b.OpenTime, t)
+}
+
+// query 按条件截取（调用方持锁）
+func (k *klineSeries) query(q KlineQuery) []Kline {
+	from := 0
+	if q.StartTime > 0 {
+		from, _ = slices.BinarySearchFunc(k.bars, q.StartTime, byOpenTime)
+	}
+	to := len(k.bars)
+	if q.EndTime > 0 {
+		to, _ = slices.BinarySearchFunc(k.bars, q.EndTime, byOpenTime)
+	}
+	if from >= to {
+		return []Kline{}
+	}
+	if q.StartTime > 0 {
+		to = min(to, from+q.limit())
+	} else {
+		from = max(from, to-q.limit())
+	}
+	return slices.Clone(k.bars[from:to])
+}
+
+// symbolKlines 单个交易对的全部周期
+type symbolKlines struct {
+	mu     sync.Mutex
+	series map[string]*klineSeries
+	last   int64 // 最后一笔成交时间（毫秒）
+}
+
+// KlineService 按交易对聚合 K 线
+type KlineService struct {
+	keep int
+
+	mu      sync.RWMutex
+	symbols map[string]*symbolKlines
+}
+
+// NewKlineService 创建 K 线服务，每个周期保留 keep 根（<=0 默认 1000）
+func NewKlineService(keep int) *KlineService {
+	if keep <= 0 {
+		keep = defaultKlineHistory
+	}
+	return &KlineService{keep: keep, symbols: make(map[string]*symbolKlines)}
+}
+
+// Register 注册交易对，未注册的交易对的成交忽略
+func (s *KlineService) Register(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.symbols[symbol]; ok {
+		return
+	}
+	series := make(map[string]*klineSeries, len(KlineIntervals))
+	for name, d := range KlineIntervals {
+		series[name] = &klineSeries{width: d.Milliseconds()}
+	}
+	s.symbols[symbol] = &symbolKlines{series: series}
+}
+
+func (s *KlineService) klines(symbol string) *symbolKlines {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.symbols[symbol]
+}
+
+// EventHandler 撮合事件处理器，只消费成交事件
+//
+//	engine.OnEventWithOptions(klines.EventHandler(), mtrade.HandlerOptions{Name: "kline"})
+func (s *KlineService) EventHandler() mtrade.EventHandler {
+	return func(ev mtrade.Event) {
+		if ev.Type != mtrade.EventTrade {
+			return
+		}
+		s.OnTrade(ev.Trade.Symbol, ev.Trade.Price, ev.Trade.Qty, ev.Trade.Timestamp/int64(time.Millisecond))
+	}
+}
+
+// OnTrade 记一笔成交（atMillis 为成交时间，毫秒），未注册的交易对忽略
+func (s *KlineService) OnTrade(symbol string, price, qty, atMillis int64) {
+	k := s.klines(symbol)
+	if k == nil || price <= 0 || qty <= 0 {
+		return
+	}
+	k.mu.Lock()
+	for _, series := range k.series {
+		series.add(price, qty, atMillis, s.keep)
+	}
+	k.last = max(k.last, atMillis)
+	k.mu.Unlock()
+}
+
+// Klines 查询 K 线，同时返回最后一笔成交时间（毫秒）
+// 未注册的交易对或不支持的周期返回 ok=false
+func (s *KlineService) Klines(q KlineQuery) (bars []Kline, lastTrade int64, ok bool) {
+	k := s.klines(q.Symbol)
+	if k == nil {
+		return nil, 0, false
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	series, ok := k.series[q.Interval]
+	if !ok {
+		return nil, 0, false
+	}
+	return series.query(q), k.last, true
+}
@@ -0,0 +1,344 @@
+package market
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+// =============================================================================
+// 公共行情 REST 接口
+// =============================================================================
+//
+//	GET /depth?symbol=BTC_USDT&limit=20&step=            深度（同 DepthService.Handler）
+//	GET /trades?symbol=BTC_USDT&limit=100                最近成交，从新到旧，limit 最大为保留笔数
+//	GET /klines?symbol=BTC_USDT&interval=1m&start_time=&end_time=&limit=500
+//	GET /ticker?symbol=BTC_USDT                          24h 行情，symbol 为空返回全部
+//	GET /funding-rate?symbol=BTCUSDT                     当前资金费率与下次结算时间
+//
+// 【限流】每个 IP 一个令牌桶，超限返回 429 + Retry-After，304 也消耗令牌
+//
+// 【缓存】响应都是快照：
+//   - ETag = 响应体哈希（弱校验，gzip 前后同一个 ETag），If-None-Match 命中返回 304
+//   - Last-Modified 只在 "最后一笔成交时间" 能完整代表内容时给出（trades / klines）：
+//     深度、BBO 没有成交也会变，给了 Last-Modified 反而让只带 If-Modified-Since 的客户端拿到旧数据
+//   - Cache-Control: public, max-age=MaxAge，CDN 可以在前面挡掉热点交易对的重复请求
+//
+// 【压缩】客户端接受 gzip 且响应超过 GzipMinSize 时压缩；小响应压缩收益抵不过 CPU
+
+var (
+	ErrUnknownSymbol = cexerr.New("MARKET_UNKNOWN_SYMBOL", cexerr.CategoryNotFound, "unknown symbol")
+	ErrRateLimited   = cexerr.NewRetryable("MARKET_RATE_LIMITED", cexerr.CategoryRateLimited, "too many requests")
+)
+
+// FundingSource 当前资金费率（*futures.FundingService 实现）
+type FundingSource interface {
+	GetFundingRate(symbol string) int64     // 万分比
+	GetNextFundingTime(symbol string) int64 // 毫秒，0 表示未知交易对
+}
+
+// FundingRateResponse 资金费率接口响应
+type FundingRateResponse struct {
+	Symbol          string `json:"symbol"`
+	FundingRate     int64  `json:"funding_rate"`      // 万分比
+	NextFundingTime int64  `json:"next_funding_time"` // 毫秒
+}
+
+// PublicAPIConfig 公共行情接口配置，数据源为空的接口返回 404
+type PublicAPIConfig struct {
+	Depth   *DepthService
+	Trades  *TradeService
+	Klines  *KlineService
+	Tickers *TickerService
+	Funding FundingSource
+
+	RateLimit   RateLimit     // 每个 IP 的限流，默认 Burst 50、每 50ms 恢复一个（20 次/秒）
+	TrustProxy  bool          // 按 X-Forwarded-For 识别客户端 IP，只在可信网关之后开启
+	MaxAge      time.Duration // Cache-Control max-age，默认 1s
+	GzipMinSize int           // 超过多少字节才压缩，默认 1024
+	Now         func() time.Time
+}
+
+func (c PublicAPIConfig) withDefaults() PublicAPIConfig {
+	if c.RateLimit.Burst <= 0 || c.RateLimit.Per <= 0 {
+		c.RateLimit = RateLimit{Burst: 50, Per: 50 * time.Millisecond}
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = time.Second
+	}
+	if c.GzipMinSize <= 0 {
+		c.GzipMinSize = 1024
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// snapshot 一次查询的结果
+type snapshot struct {
+	body         any
+	lastModified int64 // 毫秒，0 表示不给 Last-Modified
+}
+
+type publicAPI struct {
+	cfg     PublicAPIConfig
+	limiter *ipLimiter
+}
+
+// NewPublicHandler 创建公共行情接口
+func NewPublicHandler(cfg PublicAPIConfig) http.Handler {
+	cfg = cfg.withDefaults()
+	api := &publicAPI{cfg: cfg, limiter: newIPLimiter(cfg.RateLimit, cfg.Now)}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /depth", api.serve(api.depth))
+	mux.Handle("GET /trades", api.serve(api.trades))
+	mux.Handle("GET /klines", api.serve(api.klines))
+	mux.Handle("GET /ticker", api.serve(api.ticker))
+	mux.Handle("GET /funding-rate", api.serve(api.fundingRate))
+	return api.rateLimit(mux)
+}
+
+// rateLimit 按 IP 限流
+func (a *publicAPI) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := a.limiter.allow(clientIP(r, a.cfg.TrustProxy)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			cexerr.WriteHTTP(w, ErrRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serve 查询快照，处理条件请求与压缩
+func (a *publicAPI) serve(query func(r *http.Request) (snapshot, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap, err := query(r)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		body, err := json.Marshal(snap.body)
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+
+		h := w.Header()
+		etag := bodyETag(body)
+		h.Set("ETag", etag)
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(a.cfg.MaxAge.Seconds())))
+		h.Set("Vary", "Accept-Encoding")
+		var modified time.Time
+		if snap.lastModified > 0 {
+			modified = time.UnixMilli(snap.lastModified).UTC().Truncate(time.Second)
+			h.Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+		if notModified(r, etag, modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		h.Set("Content-Type", "application/json")
+		if len(body) < a.cfg.GzipMinSize || !acceptsGzip(r) {
+			h.Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body)
+			return
+		}
+		h.Set("Content-Encoding", "gzip")
+		gz := gzipPool.Get().(*gzip.Writer)
+		defer gzipPool.Put(gz)
+		gz.Reset(w)
+		gz.Write(body)
+		gz.Close()
+	})
+}
+
+var gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// bodyETag 响应体的弱 ETag
+func bodyETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`W/"%016x"`, h.Sum64())
+}
+
+// notModified 条件请求是否命中：有 If-None-Match 时只看它（RFC 9110 13.2.2）
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for tag := range strings.SplitSeq(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
+
+// acceptsGzip Accept-Encoding 是否包含 gzip（q=0 视为拒绝）
+func acceptsGzip(r *http.Request) bool {
+	for enc := range strings.SplitSeq(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// =============================================================================
+// 各接口
+// =============================================================================
+
+func (a *publicAPI) depth(r *http.Request) (snapshot, error) {
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	limit, step, bad := depthParams(q)
+	if bad != "" {
+		return snapshot{}, cexerr.ErrInvalidParam.Wrapf("%s", bad)
+	}
+	if a.cfg.Depth == nil {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	if _, ok := a.cfg.Depth.Steps(symbol); !ok {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	depth, ok := a.cfg.Depth.Depth(symbol, limit, step)
+	if !ok {
+		return snapshot{}, cexerr.ErrInvalidParam.Wrapf("step")
+	}
+	return snapshot{body: DepthResponse{
+		Symbol: symbol,
+		Seq:    depth.Seq,
+		Step:   step,
+		Bids:   levelPairs(depth.Bids),
+		Asks:   levelPairs(depth.Asks),
+	}}, nil
+}
+
+func (a *publicAPI) trades(r *http.Request) (snapshot, error) {
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if a.cfg.Trades == nil {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	limit, err := intParam(q.Get("limit"), min(defaultTradesLimit, a.cfg.Trades.size), "limit")
+	if err != nil {
+		return snapshot{}, err
+	}
+	if limit > a.cfg.Trades.size {
+		return snapshot{}, cexerr.ErrInvalidParam.Wrapf("limit")
+	}
+	list, ok := a.cfg.Trades.Recent(symbol, limit)
+	if !ok {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	var last int64
+	if len(list) > 0 {
+		last = list[0].Time
+	}
+	return snapshot{body: list, lastModified: last}, nil
+}
+
+func (a *publicAPI) klines(r *http.Request) (snapshot, error) {
+	v := r.URL.Query()
+	q := KlineQuery{Symbol: v.Get("symbol"), Interval: v.Get("interval")}
+	if _, ok := KlineIntervals[q.Interval]; !ok {
+		return snapshot{}, cexerr.ErrInvalidParam.Wrapf("interval")
+	}
+	var err error
+	if q.StartTime, err = int64Param(v.Get("start_time"), "start_time"); err != nil {
+		return snapshot{}, err
+	}
+	if q.EndTime, err = int64Param(v.Get("end_time"), "end_time"); err != nil {
+		return snapshot{}, err
+	}
+	if q.Limit, err = intParam(v.Get("limit"), defaultKlineLimit, "limit"); err != nil {
+		return snapshot{}, err
+	}
+	if q.Limit > maxKlineLimit {
+		return snapshot{}, cexerr.ErrInvalidParam.Wrapf("limit")
+	}
+	if a.cfg.Klines == nil {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", q.Symbol)
+	}
+	bars, last, ok := a.cfg.Klines.Klines(q)
+	if !ok {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", q.Symbol)
+	}
+	return snapshot{body: bars, lastModified: last}, nil
+}
+
+func (a *publicAPI) ticker(r *http.Request) (snapshot, error) {
+	symbol := r.URL.Query().Get("symbol")
+	if a.cfg.Tickers == nil {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	if symbol == "" {
+		return snapshot{body: a.cfg.Tickers.All()}, nil
+	}
+	stats, ok := a.cfg.Tickers.Get(symbol)
+	if !ok {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	return snapshot{body: stats}, nil
+}
+
+func (a *publicAPI) fundingRate(r *http.Request) (snapshot, error) {
+	symbol := r.URL.Query().Get("symbol")
+	if a.cfg.Funding == nil {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	next := a.cfg.Funding.GetNextFundingTime(symbol)
+	if next == 0 {
+		return snapshot{}, ErrUnknownSymbol.Wrapf("%s", symbol)
+	}
+	return snapshot{body: FundingRateResponse{
+		Symbol:          symbol,
+		FundingRate:     a.cfg.Funding.GetFundingRate(symbol),
+		NextFundingTime: next,
+	}}, nil
+}
+
+// intParam 解析正整数参数，为空取默认值
+func intParam(s string, def int, name string) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, cexerr.ErrInvalidParam.Wrapf("%s", name)
+	}
+	return n, nil
+}
+
+// int64Param 解析非负时间参数，为空为 0
+func int64Param(s, name string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, cexerr.ErrInvalidParam.Wrapf("%s", name)
+	}
+	return n, nil
+}
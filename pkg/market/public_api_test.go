@@ -0,0 +1,147 @@
+package market
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+func newTestPublicAPI(now *time.Time, limit RateLimit) http.Handler {
+	trades := NewTradeService(10)
+	klines := NewKlineService(0)
+	trades.Register("BTC_USDT")
+	klines.Register("BTC_USDT")
+	base := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC).UnixMilli()
+	for i, p := range []int64{100, 105, 95, 102} {
+		at := base + int64(i)*20_000 // 0s / 20s / 40s / 60s：前三笔同一根 1m K 线
+		trades.OnTrade(&mtrade.Trade{ID: int64(i + 1), Symbol: "BTC_USDT", Price: p * pricePrecision, Qty: pricePrecision, TakerSide: mtrade.SideBuy, Timestamp: at * int64(time.Millisecond)})
+		klines.OnTrade("BTC_USDT", p*pricePrecision, pricePrecision, at)
+	}
+	return NewPublicHandler(PublicAPIConfig{
+		Trades:      trades,
+		Klines:      klines,
+		RateLimit:   limit,
+		GzipMinSize: 64,
+		Now:         func() time.Time { return *now },
+	})
+}
+
+func get(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPublicAPI_KlinesAndTrades(t *testing.T) {
+	now := time.Now()
+	h := newTestPublicAPI(&now, RateLimit{})
+
+	rec := get(h, "/klines?symbol=BTC_USDT&interval=1m", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("klines status = %d, body %s", rec.Code, rec.Body)
+	}
+	var bars []Kline
+	json.Unmarshal(rec.Body.Bytes(), &bars)
+	if len(bars) != 2 {
+		t.Fatalf("bars = %d, want 2", len(bars))
+	}
+	b := bars[0]
+	if b.Open != 100*pricePrecision || b.High != 105*pricePrecision || b.Low != 95*pricePrecision || b.Close != 95*pricePrecision || b.Trades != 3 {
+		t.Fatalf("first bar = %+v", b)
+	}
+	if got := rec.Header().Get("Last-Modified"); got != "Fri, 02 Jan 2026 03:05:00 GMT" {
+		t.Fatalf("Last-Modified = %q", got)
+	}
+
+	rec = get(h, "/trades?symbol=BTC_USDT&limit=2", nil)
+	var trades []PublicTrade
+	json.Unmarshal(rec.Body.Bytes(), &trades)
+	if len(trades) != 2 || trades[0].ID != 4 || trades[1].ID != 3 {
+		t.Fatalf("trades = %+v, want newest first", trades)
+	}
+
+	for target, want := range map[string]int{
+		"/klines?symbol=BTC_USDT&interval=2m": http.StatusBadRequest,
+		"/klines?symbol=ETH_USDT&interval=1m": http.StatusNotFound,
+		"/trades?symbol=BTC_USDT&limit=11":    http.StatusBadRequest,
+		"/depth?symbol=BTC_USDT":              http.StatusNotFound,
+	} {
+		if rec := get(h, target, nil); rec.Code != want {
+			t.Errorf("%s status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestPublicAPI_ConditionalAndGzip(t *testing.T) {
+	now := time.Now()
+	h := newTestPublicAPI(&now, RateLimit{})
+
+	rec := get(h, "/trades?symbol=BTC_USDT", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip response")
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	var trades []PublicTrade
+	if err := json.Unmarshal(body, &trades); err != nil || len(trades) != 4 {
+		t.Fatalf("gzip body = %s (%v)", body, err)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if rec := get(h, "/trades?symbol=BTC_USDT", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match status = %d, want 304", rec.Code)
+	}
+	if rec := get(h, "/trades?symbol=BTC_USDT&limit=1", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusOK {
+		t.Fatalf("different snapshot status = %d, want 200", rec.Code)
+	}
+	lastModified := rec.Header().Get("Last-Modified")
+	if rec := get(h, "/trades?symbol=BTC_USDT", http.Header{"If-Modified-Since": {lastModified}}); rec.Code != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since status = %d, want 304", rec.Code)
+	}
+	if rec := get(h, "/trades?symbol=BTC_USDT", http.Header{"Accept-Encoding": {"gzip;q=0"}}); rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("gzip;q=0 must not be compressed")
+	}
+}
+
+func TestPublicAPI_RateLimit(t *testing.T) {
+	now := time.Now()
+	h := newTestPublicAPI(&now, RateLimit{Burst: 2, Per: 500 * time.Millisecond})
+
+	for i := range 2 {
+		if rec := get(h, "/trades?symbol=BTC_USDT", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d", i, rec.Code)
+		}
+	}
+	rec := get(h, "/trades?symbol=BTC_USDT", nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("status = %d Retry-After = %q, want 429 / 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// 另一个 IP 不受影响
+	req := httptest.NewRequest(http.MethodGet, "/trades?symbol=BTC_USDT", nil)
+	req.RemoteAddr = "10.0.0.2:5555"
+	other := httptest.NewRecorder()
+	h.ServeHTTP(other, req)
+	if other.Code != http.StatusOK {
+		t.Fatalf("other ip status = %d", other.Code)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if rec := get(h, "/trades?symbol=BTC_USDT", nil); rec.Code != http.StatusOK {
+		t.Fatalf("after refill status = %d", rec.Code)
+	}
+}
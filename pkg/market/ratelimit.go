@@ -0,0 +1,102 @@
+package market
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 公共接口限流：每个 IP 一个令牌桶
+// =============================================================================
+
+// RateLimit 令牌桶：最多连发 Burst 个请求，之后每 Per 恢复一个
+type RateLimit struct {
+	Burst int
+	Per   time.Duration
+}
+
+type ipBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ipLimiter 每个客户端 IP 一个令牌桶
+//
+// 桶按需创建；回满的桶和新建的桶等价，定期清掉，扫过一遍的 IP 不常驻内存
+type ipLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*ipBucket
+	lastSweep time.Time
+}
+
+// ipSweepInterval 清理回满令牌桶的间隔
+const ipSweepInterval = time.Minute
+
+func newIPLimiter(limit RateLimit, now func() time.Time) *ipLimiter {
+	return &ipLimiter{limit: limit, now: now, buckets: make(map[string]*ipBucket)}
+}
+
+// allow 取一个令牌；取不到时返回需要等待的时间
+func (l *ipLimiter) allow(ip string) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= ipSweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &ipBucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[ip] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(l.limit.Per))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *ipLimiter) sweep(now time.Time) {
+	for ip, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, ip)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *ipLimiter) refill(b *ipBucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.tokens+float64(elapsed)/float64(l.limit.Per), float64(l.limit.Burst))
+		b.last = now
+	}
+}
+
+// clientIP 请求方 IP
+//
+// trustProxy 时取 X-Forwarded-For 最左侧地址：只能在会覆盖该头的可信网关之后开启，
+// 否则客户端自己填一个随机地址就绕过了限流
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,511 @@
+package market
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/bits"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 24 小时滚动行情（Ticker）
+// =============================================================================
+//
+// 【面试】24h 最高价 / 成交量怎么做到 O(1) 读？
+// 每笔成交都扫 24 小时的成交记录显然不行。做法是按分钟分桶：
+//
+//	ring[1440]，每个桶 = 1 分钟的 {open, high, low, close, volume, turnover, trades}
+//
+//   - 成交：只更新当前分钟桶 + 滚动累计量（volume/turnover/trades 直接加）
+//   - 跨分钟：过期桶的累计量直接减掉；如果过期桶里有最高/最低价，
+//     扫一遍 1440 个桶重算 high/low（每分钟最多一次）
+//   - 读取：加锁拷贝结构体，O(1)
+//
+// 代价是精度只到分钟：窗口边界是"24 小时前那一分钟"，与主流交易所一致。
+//
+// 买一卖一（BBO）不在这里维护：撮合引擎的快照本身就是无锁的，读取时直接取。
+
+const windowMinutes = 24 * 60
+
+// BBOSource 提供最优买卖价（*mtrade.Engine 实现）
+type BBOSource interface {
+	GetDepthSnapshot(n int) mtrade.DepthSnapshot
+}
+
+var _ BBOSource = (*mtrade.Engine)(nil)
+
+// TickerStats 交易对 24 小时行情（价格、数量为定点数）
+type TickerStats struct {
+	Symbol      string `json:"symbol"`
+	BestBid     int64  `json:"best_bid"`
+	BestBidQty  int64  `json:"best_bid_qty"`
+	BestAsk     int64  `json:"best_ask"`
+	BestAskQty  int64  `json:"best_ask_qty"`
+	LastPrice   int64  `json:"last_price"`
+	LastQty     int64  `json:"last_qty"`
+	Open        int64  `json:"open"` // 窗口内第一笔成交价
+	High        int64  `json:"high"`
+	Low         int64  `json:"low"`
+	Volume      int64  `json:"volume"`       // 成交量（基础资产）
+	QuoteVolume int64  `json:"quote_volume"` // 成交额（计价资产）
+	Trades      int64  `json:"trades"`
+	PriceChange int64  `json:"price_change"` // LastPrice - Open
+	ChangeRate  int64  `json:"change_rate"`  // 涨跌幅，万分比
+	OpenTime    int64  `json:"open_time"`    // 窗口起点（毫秒）
+	CloseTime   int64  `json:"close_time"`   // 最后一笔成交时间（毫秒）
+}
+
+// minuteBucket 一分钟的成交汇总
+type minuteBucket struct {
+	Minute   int64 `json:"m"` // Unix 分钟数，0 表示空桶
+	Open     int64 `json:"o"`
+	High     int64 `json:"h"`
+	Low      int64 `json:"l"`
+	Close    int64 `json:"c"`
+	Volume   int64 `json:"v"`
+	Turnover int64 `json:"q"`
+	Trades   int64 `json:"n"`
+}
+
+// symbolTicker 单个交易对的滚动窗口
+type symbolTicker struct {
+	mu  sync.Mutex
+	bbo BBOSource
+
+	ring       [windowMinutes]minuteBucket
+	headMinute int64 // 已推进到的分钟
+
+	volume, turnover, trades int64
+	high, low                int64
+	lastPrice, lastQty       int64
+	lastTime                 int64 // 毫秒
+	version                  uint64
+}
+
+// advance 推进到 minute，淘汰窗口外的桶（调用方持锁）
+func (t *symbolTicker) advance(minute int64) {
+	if minute <= t.headMinute {
+		return
+	}
+	from := t.headMinute + 1
+	if minute-from >= windowMinutes {
+		from = minute - windowMinutes + 1
+	}
+	recompute := false
+	for m := from; m <= minute; m++ {
+		b := &t.ring[m%windowMinutes]
+		if b.Minute != 0 && b.Minute <= minute-windowMinutes {
+			t.volume -= b.Volume
+			t.turnover -= b.Turnover
+			t.trades -= b.Trades
+			if b.High >= t.high || b.Low <= t.low {
+				recompute = true
+			}
+			*b = minuteBucket{}
+		}
+	}
+	t.headMinute = minute
+	if recompute {
+		t.recomputeRange()
+	}
+}
+
+// recomputeRange 重算窗口内最高/最低价
+func (t *symbolTicker) recomputeRange() {
+	t.high, t.low = 0, 0
+	for i := range t.ring {
+		b := &t.ring[i]
+		if b.Minute == 0 {
+			continue
+		}
+		if b.High > t.high {
+			t.high = b.High
+		}
+		if t.low == 0 || b.Low < t.low {
+			t.low = b.Low
+		}
+	}
+}
+
+// addTrade 记一笔成交（调用方持锁）
+func (t *symbolTicker) addTrade(price, qty, atMillis int64) {
+	minute := atMillis / 60000
+	t.advance(minute)
+	if minute <= t.headMinute-windowMinutes {
+		return // 太旧（恢复或乱序），已在窗口外
+	}
+	b := &t.ring[minute%windowMinutes]
+	if b.Minute != minute {
+		*b = minuteBucket{Minute: minute, Open: price, High: price, Low: price}
+	}
+	b.High = max(b.High, price)
+	b.Low = min(b.Low, price)
+	b.Close = price
+	quote := quoteAmount(price, qty)
+	b.Volume += qty
+	b.Turnover += quote
+	b.Trades++
+
+	t.volume += qty
+	t.turnover += quote
+	t.trades++
+	t.high = max(t.high, price)
+	if t.low == 0 || price < t.low {
+		t.low = price
+	}
+	if atMillis >= t.lastTime {
+		t.lastPrice, t.lastQty, t.lastTime = price, qty, atMillis
+	}
+	t.version++
+}
+
+// openBucket 窗口内最早的非空桶（调用方持锁）
+func (t *symbolTicker) openBucket() *minuteBucket {
+	for m := t.headMinute - windowMinutes + 1; m <= t.headMinute; m++ {
+		if b := &t.ring[m%windowMinutes]; b.Minute == m {
+			return b
+		}
+	}
+	return nil
+}
+
+// stats 当前行情（调用方持锁）
+func (t *symbolTicker) stats(symbol string) TickerStats {
+	s := TickerStats{
+		Symbol:      symbol,
+		LastPrice:   t.lastPrice,
+		LastQty:     t.lastQty,
+		High:        t.high,
+		Low:         t.low,
+		Volume:      t.volume,
+		QuoteVolume: t.turnover,
+		Trades:      t.trades,
+		OpenTime:    (t.headMinute - windowMinutes + 1) * 60000,
+		CloseTime:   t.lastTime,
+	}
+	if b := t.openBucket(); b != nil {
+		s.Open = b.Open
+		s.PriceChange = s.LastPrice - s.Open
+		if s.Open > 0 {
+			s.ChangeRate = s.PriceChange * 10000 / s.Open
+		}
+	}
+	return s
+}
+
+// pricePrecision 价格定点精度：成交额 = price × qty / 1e8
+const pricePrecision = 100_000_000
+
+// quoteAmount price*qty/1e8，128 位中间结果防溢出（BTC 价格 × 1 BTC 已超过 int64）
+func quoteAmount(price, qty int64) int64 {
+	hi, lo := bits.Mul64(uint64(price), uint64(qty))
+	if hi >= pricePrecision {
+		return math.MaxInt64
+	}
+	q, _ := bits.Div64(hi, lo, pricePrecision)
+	if q > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(q)
+}
+
+// =============================================================================
+// TickerService
+// =============================================================================
+
+// StreamPublisher 公共行情推送（*nats.Publisher 实现）
+type StreamPublisher interface {
+	Publish(subject string, data any) error
+}
+
+// TickerConfig 行情服务配置
+type TickerConfig struct {
+	Store            TickerStore     // 可选，周期性持久化窗口，重启后恢复
+	SnapshotInterval time.Duration   // 持久化间隔，默认 30s
+	Publisher        StreamPublisher // 可选，周期性推送有变化的行情到 market.ticker.{symbol}
+	PublishInterval  time.Duration   // 推送间隔，默认 1s
+	Now              func() time.Time
+}
+
+// TickerService 按交易对维护 BBO + 24h 滚动统计
+type TickerService struct {
+	config TickerConfig
+
+	mu      sync.RWMutex
+	symbols map[string]*symbolTicker
+
+	published map[string]uint64 // 上次推送时的版本，仅 loop 访问
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewTickerService 创建行情服务
+func NewTickerService(cfg TickerConfig) *TickerService {
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = 30 * time.Second
+	}
+	if cfg.PublishInterval <= 0 {
+		cfg.PublishInterval = time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &TickerService{
+		config:    cfg,
+		symbols:   make(map[string]*symbolTicker),
+		published: make(map[string]uint64),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Register 注册交易对，bbo 可为 nil（只统计成交）
+func (s *TickerService) Register(symbol string, bbo BBOSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.symbols[symbol]; ok {
+		t.mu.Lock()
+		t.bbo = bbo
+		t.mu.Unlock()
+		return
+	}
+	s.symbols[symbol] = &symbolTicker{bbo: bbo}
+}
+
+func (s *TickerService) ticker(symbol string) *symbolTicker {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.symbols[symbol]
+}
+
+// EventHandler 撮合事件处理器，只消费成交事件
+//
+//	engine.OnEventWithOptions(tickers.EventHandler(), mtrade.HandlerOptions{Name: "ticker"})
+func (s *TickerService) EventHandler() mtrade.EventHandler {
+	return func(ev mtrade.Event) {
+		if ev.Type != mtrade.EventTrade {
+			return
+		}
+		s.OnTrade(ev.Trade.Symbol, ev.Trade.Price, ev.Trade.Qty, ev.Trade.Timestamp/int64(time.Millisecond))
+	}
+}
+
+// OnTrade 记一笔成交（atMillis 为成交时间，毫秒），未注册的交易对忽略
+func (s *TickerService) OnTrade(symbol string, price, qty, atMillis int64) {
+	t := s.ticker(symbol)
+	if t == nil || price <= 0 || qty <= 0 {
+		return
+	}
+	t.mu.Lock()
+	t.addTrade(price, qty, atMillis)
+	t.mu.Unlock()
+}
+
+// Get 查询单个交易对行情
+func (s *TickerService) Get(symbol string) (TickerStats, bool) {
+	t := s.ticker(symbol)
+	if t == nil {
+		return TickerStats{}, false
+	}
+	now := s.config.Now().UnixMilli() / 60000
+	t.mu.Lock()
+	t.advance(now)
+	stats := t.stats(symbol)
+	bbo := t.bbo
+	t.mu.Unlock()
+
+	if bbo != nil {
+		d := bbo.GetDepthSnapshot(1)
+		if len(d.Bids) > 0 {
+			stats.BestBid, stats.BestBidQty = d.Bids[0].Price, d.Bids[0].Quantity
+		}
+		if len(d.Asks) > 0 {
+			stats.BestAsk, stats.BestAskQty = d.Asks[0].Price, d.Asks[0].Quantity
+		}
+	}
+	return stats, true
+}
+
+// All 全部交易对行情（按交易对排序）
+func (s *TickerService) All() []TickerStats {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.symbols))
+	for name := range s.symbols {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	out := make([]TickerStats, 0, len(names))
+	for _, name := range names {
+		if st, ok := s.Get(name); ok {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// =============================================================================
+// 生命周期：恢复、持久化、推送
+// =============================================================================
+
+// Start 从 Store 恢复窗口，启动持久化与推送
+func (s *TickerService) Start(ctx context.Context) error {
+	if s.config.Store != nil {
+		states, err := s.config.Store.Load(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			s.restore(st)
+		}
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+// Stop 停止后台任务，并做最后一次持久化
+func (s *TickerService) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	s.saveSnapshot()
+}
+
+func (s *TickerService) loop() {
+	defer s.wg.Done()
+	snapshot := time.NewTicker(s.config.SnapshotInterval)
+	defer snapshot.Stop()
+	publish := time.NewTicker(s.config.PublishInterval)
+	defer publish.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-snapshot.C:
+			s.saveSnapshot()
+		case <-publish.C:
+			s.publishChanged()
+		}
+	}
+}
+
+// publishChanged 推送有新成交的交易对
+// 【注意】BBO 变化不触发推送：盘口推送走订单簿增量，这里只推 24h 统计
+func (s *TickerService) publishChanged() {
+	if s.config.Publisher == nil {
+		return
+	}
+	s.mu.RLock()
+	changed := make(map[string]*symbolTicker)
+	for name, t := range s.symbols {
+		t.mu.Lock()
+		if t.version != s.published[name] {
+			changed[name] = t
+		}
+		t.mu.Unlock()
+	}
+	s.mu.RUnlock()
+
+	for name, t := range changed {
+		stats, _ := s.Get(name)
+		if err := s.config.Publisher.Publish("market.ticker."+name, stats); err != nil {
+			log.Printf("[Ticker] publish %s failed: %v", name, err)
+			continue
+		}
+		t.mu.Lock()
+		s.published[name] = t.version
+		t.mu.Unlock()
+	}
+}
+
+// TickerState 持久化的窗口状态（只保存非空桶）
+type TickerState struct {
+	Symbol    string         `json:"symbol"`
+	LastPrice int64          `json:"last_price"`
+	LastQty   int64          `json:"last_qty"`
+	LastTime  int64          `json:"last_time"`
+	Buckets   []minuteBucket `json:"buckets"`
+}
+
+func (s *TickerService) saveSnapshot() {
+	if s.config.Store == nil {
+		return
+	}
+	s.mu.RLock()
+	states := make([]TickerState, 0, len(s.symbols))
+	for name, t := range s.symbols {
+		t.mu.Lock()
+		st := TickerState{Symbol: name, LastPrice: t.lastPrice, LastQty: t.lastQty, LastTime: t.lastTime}
+		for _, b := range t.ring {
+			if b.Minute != 0 {
+				st.Buckets = append(st.Buckets, b)
+			}
+		}
+		t.mu.Unlock()
+		states = append(states, st)
+	}
+	s.mu.RUnlock()
+
+	if err := s.config.Store.Save(context.Background(), states); err != nil {
+		log.Printf("[Ticker] save snapshot failed: %v", err)
+	}
+}
+
+// restore 恢复一个交易对的窗口（未注册的交易对跳过）
+func (s *TickerService) restore(st TickerState) {
+	t := s.ticker(st.Symbol)
+	if t == nil {
+		return
+	}
+	now := s.config.Now().UnixMilli() / 60000
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(now)
+	for _, b := range st.Buckets {
+		if b.Minute <= now-windowMinutes || b.Minute > now {
+			continue
+		}
+		t.ring[b.Minute%windowMinutes] = b
+		t.volume += b.Volume
+		t.turnover += b.Turnover
+		t.trades += b.Trades
+	}
+	t.recomputeRange()
+	if st.LastTime > t.lastTime {
+		t.lastPrice, t.lastQty, t.lastTime = st.LastPrice, st.LastQty, st.LastTime
+	}
+	t.version++
+}
+
+// =============================================================================
+// HTTP
+// =============================================================================
+
+// Handler 行情接口
+//
+//	GET /market/ticker?symbol=BTC_USDT   单个交易对
+//	GET /market/ticker                    全部交易对
+func (s *TickerService) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/market/ticker", func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		if symbol == "" {
+			writeJSON(w, s.All())
+			return
+		}
+		stats, ok := s.Get(symbol)
+		if !ok {
+			http.Error(w, "unknown symbol", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, stats)
+	})
+	return mux
+}
@@ -0,0 +1,173 @@
+package market
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var tickerBase = time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+func newTestTicker(now *time.Time) *TickerService {
+	s := NewTickerService(TickerConfig{Now: func() time.Time { return *now }})
+	s.Register("BTC_USDT", nil)
+	return s
+}
+
+// tradeAt 在 tickerBase 之后 offset 处成交，价格 / 数量按整数单位
+func tradeAt(s *TickerService, offset time.Duration, price, qty int64) {
+	s.OnTrade("BTC_USDT", price*pricePrecision, qty*pricePrecision, tickerBase.Add(offset).UnixMilli())
+}
+
+func TestTicker_RollingWindow(t *testing.T) {
+	// 四个分钟桶：100×1 / 120×2 (最高) / 90×3 (最低) / 110×4
+	trades := []struct {
+		offset     time.Duration
+		price, qty int64
+	}{
+		{0, 100, 1},
+		{time.Minute, 120, 2},
+		{2 * time.Minute, 90, 3},
+		{3 * time.Minute, 110, 4},
+	}
+	day := 24 * time.Hour
+	for _, tc := range []struct {
+		name                         string
+		at                           time.Duration
+		open, high, low, volume, cnt int64
+	}{
+		{"all in window", 3 * time.Minute, 100, 120, 90, 10, 4},
+		{"first bucket expired", day, 120, 120, 90, 9, 3},
+		{"high expired", day + time.Minute, 90, 110, 90, 7, 2},
+		{"low expired", day + 2*time.Minute, 110, 110, 110, 4, 1},
+		{"all expired", day + 3*time.Minute, 0, 0, 0, 0, 0},
+		{"long gap", 3 * day, 0, 0, 0, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			now := tickerBase.Add(tc.at)
+			s := newTestTicker(&now)
+			for _, tr := range trades {
+				tradeAt(s, tr.offset, tr.price, tr.qty)
+			}
+			st, ok := s.Get("BTC_USDT")
+			if !ok {
+				t.Fatal("symbol missing")
+			}
+			p := int64(pricePrecision)
+			if st.Open != tc.open*p || st.High != tc.high*p || st.Low != tc.low*p || st.Volume != tc.volume*p || st.Trades != tc.cnt {
+				t.Errorf("open/high/low/volume/trades = %d/%d/%d/%d/%d, want %d/%d/%d/%d/%d",
+					st.Open/p, st.High/p, st.Low/p, st.Volume/p, st.Trades, tc.open, tc.high, tc.low, tc.volume, tc.cnt)
+			}
+			// 最新价不随窗口过期
+			if st.LastPrice != 110*p || st.CloseTime != tickerBase.Add(3*time.Minute).UnixMilli() {
+				t.Errorf("last price %d at %d", st.LastPrice/p, st.CloseTime)
+			}
+			if want := now.Truncate(time.Minute).Add(-day + time.Minute).UnixMilli(); st.OpenTime != want {
+				t.Errorf("open time = %d, want %d", st.OpenTime, want)
+			}
+		})
+	}
+}
+
+func TestTicker_BucketRollover(t *testing.T) {
+	now := tickerBase.Add(time.Minute)
+	s := newTestTicker(&now)
+	p := int64(pricePrecision)
+
+	// 同一分钟内合并成一个桶：开盘取第一笔，收盘取最后一笔
+	tradeAt(s, 0, 100, 1)
+	tradeAt(s, 20*time.Second, 130, 1)
+	tradeAt(s, 40*time.Second, 80, 1)
+	tradeAt(s, time.Minute+10*time.Second, 105, 2)
+
+	st, _ := s.Get("BTC_USDT")
+	if st.Open != 100*p || st.High != 130*p || st.Low != 80*p || st.Volume != 5*p || st.Trades != 4 {
+		t.Fatalf("merged stats %+v", st)
+	}
+	if st.QuoteVolume != (100+130+80+105*2)*p || st.PriceChange != 5*p || st.ChangeRate != 500 {
+		t.Fatalf("quote volume / change %+v", st)
+	}
+
+	// 24h 后同一个环形槽位被复用：旧桶整体淘汰，不能与新成交合并
+	now = tickerBase.Add(24 * time.Hour)
+	tradeAt(s, 24*time.Hour+5*time.Second, 90, 3)
+	st, _ = s.Get("BTC_USDT")
+	if st.Open != 105*p || st.High != 105*p || st.Low != 90*p || st.Volume != 5*p || st.Trades != 2 {
+		t.Fatalf("after slot reuse %+v", st)
+	}
+	if st.LastPrice != 90*p || st.ChangeRate != -1428 {
+		t.Fatalf("change after slot reuse %+v", st)
+	}
+
+	// 已在窗口外的迟到成交直接丢弃
+	tradeAt(s, 30*time.Second, 1, 100)
+	if again, _ := s.Get("BTC_USDT"); again.Volume != st.Volume || again.Low != st.Low || again.LastPrice != st.LastPrice {
+		t.Fatalf("late trade counted: %+v", again)
+	}
+}
+
+func TestTicker_EmptyWindow(t *testing.T) {
+	now := tickerBase.Add(time.Hour)
+	s := newTestTicker(&now)
+
+	if _, ok := s.Get("ETH_USDT"); ok {
+		t.Fatal("unregistered symbol should not exist")
+	}
+	// 未注册 / 非法价格数量的成交忽略
+	s.OnTrade("ETH_USDT", pricePrecision, pricePrecision, now.UnixMilli())
+	s.OnTrade("BTC_USDT", 0, pricePrecision, now.UnixMilli())
+	s.OnTrade("BTC_USDT", pricePrecision, -1, now.UnixMilli())
+
+	st, ok := s.Get("BTC_USDT")
+	if !ok {
+		t.Fatal("symbol missing")
+	}
+	want := TickerStats{Symbol: "BTC_USDT", OpenTime: now.Add(-24*time.Hour + time.Minute).UnixMilli()}
+	if st != want {
+		t.Fatalf("empty window = %+v, want %+v", st, want)
+	}
+	if all := s.All(); len(all) != 1 || all[0] != want {
+		t.Fatalf("All() = %+v", all)
+	}
+}
+
+func TestTicker_SnapshotRestore(t *testing.T) {
+	store := NewFileTickerStore(filepath.Join(t.TempDir(), "ticker", "snapshot.json"))
+	ctx := context.Background()
+
+	if states, err := store.Load(ctx); err != nil || states != nil {
+		t.Fatalf("missing file: %v, %v", states, err)
+	}
+
+	now := tickerBase.Add(2 * time.Minute)
+	s := NewTickerService(TickerConfig{Store: store, Now: func() time.Time { return now }})
+	s.Register("BTC_USDT", nil)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tradeAt(s, 0, 100, 1)
+	tradeAt(s, time.Minute, 120, 2)
+	tradeAt(s, 2*time.Minute, 90, 3)
+	s.Stop() // 最后一次持久化
+
+	states, err := store.Load(ctx)
+	if err != nil || len(states) != 1 || len(states[0].Buckets) != 3 {
+		t.Fatalf("saved states %+v, %v", states, err)
+	}
+
+	// 重启时第一个桶已滑出窗口：恢复时跳过，high/low 按剩余桶重算
+	later := tickerBase.Add(24*time.Hour + 10*time.Second)
+	r := NewTickerService(TickerConfig{Store: store, Now: func() time.Time { return later }})
+	r.Register("BTC_USDT", nil)
+	if err := r.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	p := int64(pricePrecision)
+	st, _ := r.Get("BTC_USDT")
+	if st.Open != 120*p || st.High != 120*p || st.Low != 90*p || st.Volume != 5*p || st.Trades != 2 || st.LastPrice != 90*p {
+		t.Fatalf("restored %+v", st)
+	}
+}
@@ -0,0 +1,60 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// TickerStore 行情窗口快照存储
+//
+// 【设计】只存快照不存成交流水：重启后丢失最近一个快照间隔内的成交统计，
+// 对 24h 行情来说可以接受；精确数据以成交记录 / K 线为准
+type TickerStore interface {
+	Save(ctx context.Context, states []TickerState) error
+	Load(ctx context.Context) ([]TickerState, error)
+}
+
+// FileTickerStore JSON 文件快照，写临时文件后 rename，宕机不会留下半个文件
+type FileTickerStore struct {
+	Path string
+}
+
+// NewFileTickerStore 创建文件快照存储
+func NewFileTickerStore(path string) *FileTickerStore {
+	return &FileTickerStore{Path: path}
+}
+
+// Save 覆盖写入快照
+func (s *FileTickerStore) Save(_ context.Context, states []TickerState) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// Load 读取快照，文件不存在返回空
+func (s *FileTickerStore) Load(_ context.Context) ([]TickerState, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var states []TickerState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
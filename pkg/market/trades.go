@@ -0,0 +1,118 @@
+package market
+
+import (
+	"sync"
+	"time"
+
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// 最近成交
+// =============================================================================
+//
+// 每个交易对一个环形缓冲，保留最近 N 笔公开成交（不含订单 ID / 客户端订单号）。
+// 成交事件由撮合线程推送，查询只拷贝环形缓冲的一段，不访问撮合引擎。
+
+const (
+	defaultTradeHistory = 1000
+	defaultTradesLimit  = 100
+)
+
+// PublicTrade 公开成交（价格、数量为定点数）
+type PublicTrade struct {
+	ID    int64  `json:"id"`
+	Price int64  `json:"price"`
+	Qty   int64  `json:"qty"`
+	Side  string `json:"side"` // taker 方向：buy / sell
+	Time  int64  `json:"time"` // 毫秒
+}
+
+// symbolTrades 单个交易对的成交环
+type symbolTrades struct {
+	mu    sync.Mutex
+	ring  []PublicTrade
+	next  int // 下一个写入位置
+	count int
+}
+
+// TradeService 按交易对保留最近成交
+type TradeService struct {
+	size int
+
+	mu      sync.RWMutex
+	symbols map[string]*symbolTrades
+}
+
+// NewTradeService 创建最近成交服务，每个交易对保留 size 笔（<=0 默认 1000）
+func NewTradeService(size int) *TradeService {
+	if size <= 0 {
+		size = defaultTradeHistory
+	}
+	return &TradeService{size: size, symbols: make(map[string]*symbolTrades)}
+}
+
+// Register 注册交易对，未注册的交易对的成交忽略
+func (s *TradeService) Register(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.symbols[symbol]; !ok {
+		s.symbols[symbol] = &symbolTrades{ring: make([]PublicTrade, s.size)}
+	}
+}
+
+func (s *TradeService) trades(symbol string) *symbolTrades {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.symbols[symbol]
+}
+
+// EventHandler 撮合事件处理器，只消费成交事件
+//
+//	engine.OnEventWithOptions(trades.EventHandler(), mtrade.HandlerOptions{Name: "trades"})
+func (s *TradeService) EventHandler() mtrade.EventHandler {
+	return func(ev mtrade.Event) {
+		if ev.Type == mtrade.EventTrade {
+			s.OnTrade(ev.Trade)
+		}
+	}
+}
+
+// OnTrade 记一笔成交
+func (s *TradeService) OnTrade(trade *mtrade.Trade) {
+	t := s.trades(trade.Symbol)
+	if t == nil {
+		return
+	}
+	side := "buy"
+	if trade.TakerSide == mtrade.SideSell {
+		side = "sell"
+	}
+	t.mu.Lock()
+	t.ring[t.next] = PublicTrade{
+		ID:    trade.ID,
+		Price: trade.Price,
+		Qty:   trade.Qty,
+		Side:  side,
+		Time:  trade.Timestamp / int64(time.Millisecond),
+	}
+	t.next = (t.next + 1) % len(t.ring)
+	t.count = min(t.count+1, len(t.ring))
+	t.mu.Unlock()
+}
+
+// Recent 最近 limit 笔成交，按时间从新到旧；未注册的交易对返回 ok=false
+func (s *TradeService) Recent(symbol string, limit int) ([]PublicTrade, bool) {
+	t := s.trades(symbol)
+	if t == nil {
+		return nil, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := min(limit, t.count)
+	out := make([]PublicTrade, n)
+	for i := range n {
+		out[i] = t.ring[(t.next-1-i+len(t.ring))%len(t.ring)]
+	}
+	return out, true
+}
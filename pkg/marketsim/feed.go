@@ -0,0 +1,109 @@
+package marketsim
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"max.com/pkg/futures"
+	"max.com/pkg/mtrade"
+)
+
+// =============================================================================
+// Feed: 按固定节拍推进生成器，把每步价格分发给下游
+// =============================================================================
+
+// Sink 价格消费者 (标记价格服务、下单流量、模拟用户数据 ...)
+type Sink func(Tick)
+
+// Feed 驱动生成器
+type Feed struct {
+	gen      *Generator
+	interval time.Duration
+	sinks    []Sink
+}
+
+// NewFeed 创建 Feed，interval 为每步的真实时间间隔
+func NewFeed(gen *Generator, interval time.Duration, sinks ...Sink) *Feed {
+	return &Feed{gen: gen, interval: interval, sinks: sinks}
+}
+
+// Run 阻塞运行直到 ctx 取消或走满 maxSteps 步 (<= 0 不限)
+func (f *Feed) Run(ctx context.Context, maxSteps int) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for maxSteps <= 0 || f.gen.step < maxSteps {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t := f.gen.Next()
+			for _, s := range f.sinks {
+				s(t)
+			}
+		}
+	}
+}
+
+// =============================================================================
+// 下游适配
+// =============================================================================
+
+// MarkPriceSink 把模拟价格同时作为指数价格和标记价格推给 MarkPriceService
+// (价格按 futures.Precision 定点化；标记价格回调会驱动强平 / 条件单)
+func MarkPriceSink(svc *futures.MarkPriceService) Sink {
+	return func(t Tick) {
+		for symbol, p := range t.Prices {
+			v := int64(math.Round(p * futures.Precision))
+			svc.UpdateIndexPrice(symbol, v)
+			svc.UpdateMarkPrice(symbol, v)
+		}
+	}
+}
+
+// OrderFlow 围绕模拟价格挂单 / 吃单的流量生成器
+//
+// 每步每个交易对：买卖各挂一笔限价单 (偏离 ±Spread 内随机)，
+// 再以 TakerProb 的概率随机方向打一笔市价单
+type OrderFlow struct {
+	Engines   map[string]*mtrade.Engine // 交易对 -> 撮合引擎，没有引擎的交易对跳过
+	Spread    int64                     // 挂单最大偏离 (价格单位与撮合引擎一致)
+	MaxQty    int64                     // 单笔最大数量
+	TakerProb float64
+	Users     int64 // 随机用户 ID 范围 [0, Users)
+	Rand      *rand.Rand
+}
+
+// Sink 转换为 Feed 消费者
+func (o *OrderFlow) Sink() Sink {
+	return func(t Tick) {
+		for symbol, p := range t.Prices {
+			eng, ok := o.Engines[symbol]
+			if !ok {
+				continue
+			}
+			price := int64(p)
+			eng.SubmitOrder(o.order(symbol, mtrade.SideBuy, mtrade.OrderTypeLimit, price-o.Rand.Int63n(o.Spread+1)))
+			eng.SubmitOrder(o.order(symbol, mtrade.SideSell, mtrade.OrderTypeLimit, price+o.Rand.Int63n(o.Spread+1)))
+			if o.Rand.Float64() < o.TakerProb {
+				side := mtrade.SideBuy
+				if o.Rand.Intn(2) == 0 {
+					side = mtrade.SideSell
+				}
+				eng.SubmitOrder(o.order(symbol, side, mtrade.OrderTypeMarket, 0))
+			}
+		}
+	}
+}
+
+func (o *OrderFlow) order(symbol string, side mtrade.Side, typ mtrade.OrderType, price int64) *mtrade.Order {
+	return &mtrade.Order{
+		UserID: o.Rand.Int63n(o.Users),
+		Symbol: symbol,
+		Side:   side,
+		Type:   typ,
+		Price:  price,
+		Qty:    o.Rand.Int63n(o.MaxQty) + 1,
+	}
+}
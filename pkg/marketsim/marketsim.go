@@ -0,0 +1,338 @@
+// Package marketsim 多交易对相关价格路径生成器 (压测风控 / 强平用)
+//
+// 模型：相关几何布朗运动 (GBM) + Merton 跳跃扩散
+//
+//	dS/S = μ·dt + σ·regime·dW + (J-1)·dN
+//
+// dW 各交易对之间按相关系数矩阵相关 (Cholesky 分解)；dN 泊松跳跃，
+// 跳跃幅度 ln(J) ~ N(JumpMean, JumpStd)；regime 为波动率区间 (平静 / 高波动 ...)，
+// 按步数轮换，整体放大 σ。Crash 脚本化暴跌：在指定步把指定交易对打下 Drop，
+// 之后按正常模型继续走。
+//
+// 同一个 Seed 产生同一条路径，方便复现强平场景
+package marketsim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrInvalidConfig = errors.New("marketsim: invalid config")
+	ErrNotPositive   = errors.New("marketsim: correlation matrix not positive definite")
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 生成器配置
+type Config struct {
+	Symbols []SymbolConfig `yaml:"symbols"`
+
+	// Correlation 相关系数矩阵，按 Symbols 顺序，nil = 互不相关
+	Correlation [][]float64 `yaml:"correlation"`
+
+	// Dt 每步时长 (年)，默认 1 分钟 = 1/525600
+	Dt float64 `yaml:"dt"`
+
+	Regimes []Regime `yaml:"regimes"` // 为空 = 单一区间 (倍数 1)
+	Crashes []Crash  `yaml:"crashes"`
+
+	Seed int64 `yaml:"seed"`
+}
+
+// SymbolConfig 单个交易对的参数 (年化)
+type SymbolConfig struct {
+	Symbol string  `yaml:"symbol"`
+	Start  float64 `yaml:"start"` // 初始价格
+	Drift  float64 `yaml:"drift"` // μ
+	Vol    float64 `yaml:"vol"`   // σ
+
+	// 跳跃：JumpIntensity 每年期望跳跃次数，ln(J) ~ N(JumpMean, JumpStd)
+	JumpIntensity float64 `yaml:"jump_intensity"`
+	JumpMean      float64 `yaml:"jump_mean"`
+	JumpStd       float64 `yaml:"jump_std"`
+}
+
+// Regime 波动率区间
+type Regime struct {
+	Name  string  `yaml:"name"`
+	Steps int     `yaml:"steps"`    // 持续步数，<= 0 表示一直持续
+	VolX  float64 `yaml:"vol_mult"` // σ 倍数
+}
+
+// Crash 脚本化暴跌
+type Crash struct {
+	Step    int      `yaml:"step"`    // 第几步触发 (从 1 开始)
+	Symbols []string `yaml:"symbols"` // 为空 = 全部交易对
+	Drop    float64  `yaml:"drop"`    // 跌幅，0.2 = 跌 20%；负数表示暴涨
+	Steps   int      `yaml:"steps"`   // 分几步跌完，<= 1 表示一步到位
+}
+
+// defaultDt 1 分钟
+const defaultDt = 1.0 / (365 * 24 * 60)
+
+// Validate 静态校验并补默认值
+func (c *Config) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...))
+	}
+	if len(c.Symbols) == 0 {
+		return invalid("no symbols")
+	}
+	if c.Dt == 0 {
+		c.Dt = defaultDt
+	}
+	if c.Dt < 0 {
+		return invalid("negative dt")
+	}
+	idx := make(map[string]bool, len(c.Symbols))
+	for _, s := range c.Symbols {
+		if s.Symbol == "" || idx[s.Symbol] {
+			return invalid("empty or duplicate symbol %q", s.Symbol)
+		}
+		idx[s.Symbol] = true
+		if s.Start <= 0 || s.Vol < 0 || s.JumpIntensity < 0 || s.JumpStd < 0 {
+			return invalid("%s: start must be > 0, vol/jump params >= 0", s.Symbol)
+		}
+	}
+	if c.Correlation != nil {
+		n := len(c.Symbols)
+		if len(c.Correlation) != n {
+			return invalid("correlation is %dx?, want %dx%d", len(c.Correlation), n, n)
+		}
+		for i, row := range c.Correlation {
+			if len(row) != n {
+				return invalid("correlation row %d has %d columns, want %d", i, len(row), n)
+			}
+			if row[i] != 1 {
+				return invalid("correlation[%d][%d] = %v, want 1", i, i, row[i])
+			}
+			for j, v := range row {
+				if v < -1 || v > 1 || v != c.Correlation[j][i] {
+					return invalid("correlation[%d][%d] = %v out of range or asymmetric", i, j, v)
+				}
+			}
+		}
+	}
+	for _, r := range c.Regimes {
+		if r.VolX < 0 {
+			return invalid("regime %q: negative vol_mult", r.Name)
+		}
+	}
+	for _, cr := range c.Crashes {
+		if cr.Step < 1 || cr.Drop >= 1 {
+			return invalid("crash at step %d: step must be >= 1, drop < 1", cr.Step)
+		}
+		for _, s := range cr.Symbols {
+			if !idx[s] {
+				return invalid("crash at step %d: unknown symbol %q", cr.Step, s)
+			}
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// Generator
+// =============================================================================
+
+// Tick 一步的价格
+type Tick struct {
+	Step   int
+	Regime string
+	Prices map[string]float64
+}
+
+// Generator 价格路径生成器 (非并发安全，由一个 goroutine 驱动)
+type Generator struct {
+	cfg    Config
+	rng    *rand.Rand
+	chol   [][]float64 // 相关矩阵的下三角 Cholesky 因子
+	prices []float64
+	step   int
+
+	regime      int // 当前区间下标
+	regimeSteps int // 当前区间已走的步数
+
+	// 多步暴跌：每个交易对剩余的每步对数跌幅 / 剩余步数
+	crashLog   []float64
+	crashSteps []int
+}
+
+// NewGenerator 创建生成器
+func NewGenerator(cfg Config) (*Generator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	n := len(cfg.Symbols)
+	corr := cfg.Correlation
+	if corr == nil {
+		corr = identity(n)
+	}
+	chol, err := cholesky(corr)
+	if err != nil {
+		return nil, err
+	}
+	g := &Generator{
+		cfg:        cfg,
+		rng:        rand.New(rand.NewSource(cfg.Seed)),
+		chol:       chol,
+		prices:     make([]float64, n),
+		crashLog:   make([]float64, n),
+		crashSteps: make([]int, n),
+	}
+	for i, s := range cfg.Symbols {
+		g.prices[i] = s.Start
+	}
+	return g, nil
+}
+
+// Symbols 交易对列表 (配置顺序)
+func (g *Generator) Symbols() []string {
+	out := make([]string, len(g.cfg.Symbols))
+	for i, s := range g.cfg.Symbols {
+		out[i] = s.Symbol
+	}
+	return out
+}
+
+// Current 当前价格 (还没走第一步时为初始价格)
+func (g *Generator) Current() Tick {
+	return g.tick()
+}
+
+// Next 前进一步
+func (g *Generator) Next() Tick {
+	g.step++
+	volX := g.advanceRegime()
+	g.scheduleCrashes()
+
+	n := len(g.prices)
+	z := make([]float64, n)
+	for i := range z {
+		z[i] = g.rng.NormFloat64()
+	}
+	dt := g.cfg.Dt
+	sqrtDt := math.Sqrt(dt)
+	for i, s := range g.cfg.Symbols {
+		// 相关噪声 ε = L·z
+		var eps float64
+		for k := 0; k <= i; k++ {
+			eps += g.chol[i][k] * z[k]
+		}
+		sigma := s.Vol * volX
+		logRet := (s.Drift-0.5*sigma*sigma)*dt + sigma*sqrtDt*eps
+
+		// 泊松跳跃：dt 很小时一步至多一次跳跃，概率 λ·dt
+		if s.JumpIntensity > 0 && g.rng.Float64() < s.JumpIntensity*dt {
+			logRet += s.JumpMean + s.JumpStd*g.rng.NormFloat64()
+		}
+		if g.crashSteps[i] > 0 {
+			logRet += g.crashLog[i]
+			g.crashSteps[i]--
+		}
+		g.prices[i] *= math.Exp(logRet)
+	}
+	return g.tick()
+}
+
+// Path 连续走 steps 步
+func (g *Generator) Path(steps int) []Tick {
+	out := make([]Tick, 0, steps)
+	for range steps {
+		out = append(out, g.Next())
+	}
+	return out
+}
+
+// advanceRegime 轮换波动率区间，返回当前 σ 倍数
+func (g *Generator) advanceRegime() float64 {
+	if len(g.cfg.Regimes) == 0 {
+		return 1
+	}
+	r := g.cfg.Regimes[g.regime]
+	if r.Steps > 0 && g.regimeSteps >= r.Steps {
+		g.regime = (g.regime + 1) % len(g.cfg.Regimes)
+		g.regimeSteps = 0
+		r = g.cfg.Regimes[g.regime]
+	}
+	g.regimeSteps++
+	return r.VolX
+}
+
+// scheduleCrashes 本步触发的暴跌摊到后续若干步
+func (g *Generator) scheduleCrashes() {
+	for _, cr := range g.cfg.Crashes {
+		if cr.Step != g.step {
+			continue
+		}
+		steps := max(cr.Steps, 1)
+		perStep := math.Log(1-cr.Drop) / float64(steps)
+		for i, s := range g.cfg.Symbols {
+			if len(cr.Symbols) > 0 && !slices.Contains(cr.Symbols, s.Symbol) {
+				continue
+			}
+			g.crashLog[i] = perStep
+			g.crashSteps[i] = steps
+		}
+	}
+}
+
+func (g *Generator) tick() Tick {
+	t := Tick{Step: g.step, Prices: make(map[string]float64, len(g.prices))}
+	if len(g.cfg.Regimes) > 0 {
+		t.Regime = g.cfg.Regimes[g.regime].Name
+	}
+	for i, s := range g.cfg.Symbols {
+		t.Prices[s.Symbol] = g.prices[i]
+	}
+	return t
+}
+
+// =============================================================================
+// 线性代数
+// =============================================================================
+
+func identity(n int) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	return m
+}
+
+// cholesky 对称正定矩阵 A = L·Lᵀ，返回下三角 L
+func cholesky(a [][]float64) ([][]float64, error) {
+	n := len(a)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := a[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, ErrNotPositive
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
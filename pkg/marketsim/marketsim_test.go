@@ -0,0 +1,121 @@
+package marketsim
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"max.com/pkg/futures"
+)
+
+func twoSymbols(rho float64) Config {
+	return Config{
+		Symbols: []SymbolConfig{
+			{Symbol: "BTC_USDT", Start: 50000, Vol: 0.8},
+			{Symbol: "ETH_USDT", Start: 3000, Vol: 1.0},
+		},
+		Correlation: [][]float64{{1, rho}, {rho, 1}},
+		Seed:        42,
+	}
+}
+
+func TestGenerator_Deterministic(t *testing.T) {
+	a, _ := NewGenerator(twoSymbols(0.5))
+	b, _ := NewGenerator(twoSymbols(0.5))
+	for i := 0; i < 100; i++ {
+		ta, tb := a.Next(), b.Next()
+		if ta.Prices["BTC_USDT"] != tb.Prices["BTC_USDT"] || ta.Prices["ETH_USDT"] != tb.Prices["ETH_USDT"] {
+			t.Fatalf("step %d diverged: %v vs %v", i+1, ta.Prices, tb.Prices)
+		}
+	}
+}
+
+func TestGenerator_Correlation(t *testing.T) {
+	g, err := NewGenerator(twoSymbols(0.8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 20000
+	prev := g.Current().Prices
+	var sx, sy, sxx, syy, sxy float64
+	for range n {
+		cur := g.Next().Prices
+		x := math.Log(cur["BTC_USDT"] / prev["BTC_USDT"])
+		y := math.Log(cur["ETH_USDT"] / prev["ETH_USDT"])
+		sx, sy, sxx, syy, sxy = sx+x, sy+y, sxx+x*x, syy+y*y, sxy+x*y
+		prev = cur
+	}
+	cov := sxy/n - sx/n*sy/n
+	rho := cov / math.Sqrt((sxx/n-sx/n*sx/n)*(syy/n-sy/n*sy/n))
+	if math.Abs(rho-0.8) > 0.05 {
+		t.Fatalf("sample correlation %.3f, want ~0.8", rho)
+	}
+}
+
+func TestGenerator_Crash(t *testing.T) {
+	cfg := twoSymbols(0)
+	cfg.Symbols[0].Vol, cfg.Symbols[1].Vol = 0, 0
+	cfg.Crashes = []Crash{{Step: 3, Symbols: []string{"BTC_USDT"}, Drop: 0.2, Steps: 2}}
+	g, _ := NewGenerator(cfg)
+
+	path := g.Path(5)
+	if p := path[1].Prices["BTC_USDT"]; p != 50000 {
+		t.Fatalf("before crash %v, want 50000", p)
+	}
+	if p := path[4].Prices["BTC_USDT"]; math.Abs(p-40000) > 1e-6 {
+		t.Fatalf("after crash %v, want 40000", p)
+	}
+	if p := path[4].Prices["ETH_USDT"]; p != 3000 {
+		t.Fatalf("ETH moved to %v, crash should be BTC only", p)
+	}
+}
+
+func TestGenerator_Regimes(t *testing.T) {
+	cfg := twoSymbols(0)
+	cfg.Regimes = []Regime{{Name: "calm", Steps: 2, VolX: 1}, {Name: "stress", Steps: 1, VolX: 3}}
+	g, _ := NewGenerator(cfg)
+	var got []string
+	for _, tk := range g.Path(5) {
+		got = append(got, tk.Regime)
+	}
+	want := []string{"calm", "calm", "stress", "calm", "calm"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("regimes %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNewGenerator_Invalid(t *testing.T) {
+	if _, err := NewGenerator(Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("empty config: %v", err)
+	}
+	cfg := twoSymbols(0.5)
+	cfg.Correlation[0][1] = 0.4
+	if _, err := NewGenerator(cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("asymmetric correlation: %v", err)
+	}
+	cfg = twoSymbols(0.5)
+	cfg.Symbols = append(cfg.Symbols, SymbolConfig{Symbol: "SOL_USDT", Start: 100})
+	cfg.Correlation = [][]float64{{1, 0.9, -0.9}, {0.9, 1, 0.9}, {-0.9, 0.9, 1}}
+	if _, err := NewGenerator(cfg); !errors.Is(err, ErrNotPositive) {
+		t.Fatalf("non-PSD correlation: %v", err)
+	}
+}
+
+func TestMarkPriceSink(t *testing.T) {
+	svc := futures.NewMarkPriceService()
+	var called int
+	svc.OnPriceUpdate(func(string, *futures.MarkPriceInfo) { called++ })
+
+	MarkPriceSink(svc)(Tick{Prices: map[string]float64{"BTC_USDT": 50000.5}})
+	if got := svc.GetMarkPrice("BTC_USDT"); got != 50000.5*futures.Precision {
+		t.Fatalf("mark price %d", got)
+	}
+	if got := svc.GetIndexPrice("BTC_USDT"); got != 50000.5*futures.Precision {
+		t.Fatalf("index price %d", got)
+	}
+	if called != 1 {
+		t.Fatalf("callback called %d times, want 1", called)
+	}
+}
@@ -0,0 +1,305 @@
+// Package migrate 数据库表结构迁移：有序的 SQL / Go 迁移 + 版本表，支持升级、回滚和状态查询
+//
+// 【问题】表结构散落在各包的 *.sql 和测试里的 AutoMigrate：新环境冷启动要人工按顺序执行一堆脚本，
+// 分片表靠存储过程，哪个环境执行到了哪一步没有记录，漏一张表要到运行时才报错
+//
+// 【做法】
+//   - 迁移按版本号排序，每个迁移有 Up / Down；SQL 迁移放在 sql/NNNN_name.{up,down}.sql (编译时嵌入)，
+//     需要循环或判断的 (如 128 张分片表) 用 Go 迁移
+//   - 已执行的版本记在 schema_migrations，Up 只执行没记录的版本，Down 按执行顺序倒序回滚
+//   - 执行期间持有 MySQL 命名锁 (GET_LOCK)：多个实例同时启动时只有一个在迁移，其余等待后发现已是最新
+//
+// 启动编排在启动任何服务之前执行 `migrate up` (cmd/migrate)，或在进程内调用：
+//
+//	m, err := migrate.New(db, migrate.Config{})
+//	applied, err := m.Up(ctx, 0)
+//
+// 【注意】
+//   - MySQL 的 DDL 会隐式提交，迁移不能放在事务里：迁移执行一半失败时，已建的表留在库里、版本不记录。
+//     所以每个迁移必须可重入 (CREATE TABLE IF NOT EXISTS / DROP TABLE IF EXISTS / INSERT IGNORE)，
+//     修复后重跑 Up 从失败的版本继续
+//   - 已发布的迁移不要再改：改表结构加新版本。各包的 *.sql 仍是表结构说明，改表时两边一起改
+//   - 库里有代码不认识的版本 (新版本迁移过、又部署了旧二进制) 时拒绝 Up / Down，避免旧代码回滚新表
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/cexerr"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrInvalidMigration = cexerr.New("MIGRATE_INVALID_MIGRATION", cexerr.CategoryInvalidArgument, "migrate: invalid migration")
+	ErrUnknownVersion   = cexerr.New("MIGRATE_UNKNOWN_VERSION", cexerr.CategoryFailedPrecondition, "migrate: database has versions unknown to this build")
+	ErrIrreversible     = cexerr.New("MIGRATE_IRREVERSIBLE", cexerr.CategoryFailedPrecondition, "migrate: migration has no down step")
+	ErrLocked           = cexerr.NewRetryable("MIGRATE_LOCKED", cexerr.CategoryUnavailable, "migrate: another migration is running")
+)
+
+// =============================================================================
+// 迁移定义
+// =============================================================================
+
+// Migration 一个版本的表结构变更
+type Migration struct {
+	Version int64  // 递增版本号，不要求连续
+	Name    string // 简短描述 (snake_case)
+	Up      func(db *gorm.DB) error
+	Down    func(db *gorm.DB) error // nil 表示不可回滚
+}
+
+// Status 一个版本的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt int64 // Unix 毫秒，未执行为 0
+	Unknown   bool  // 库里有记录但当前代码没有这个版本
+}
+
+// appliedRecord schema_migrations 的一行
+type appliedRecord struct {
+	Version   int64  `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string `gorm:"column:name"`
+	AppliedAt int64  `gorm:"column:applied_at"`
+}
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 迁移配置
+type Config struct {
+	Table       string        // 版本表名 (默认 schema_migrations)
+	LockName    string        // MySQL 命名锁 (默认 <Table>_lock)
+	LockTimeout time.Duration // 等锁超时 (默认 60s)，超时返回 ErrLocked
+	Migrations  []Migration   // 迁移列表 (默认 All())
+}
+
+func (c Config) withDefaults() Config {
+	if c.Table == "" {
+		c.Table = "schema_migrations"
+	}
+	if c.LockName == "" {
+		c.LockName = c.Table + "_lock"
+	}
+	if c.LockTimeout <= 0 {
+		c.LockTimeout = time.Minute
+	}
+	if c.Migrations == nil {
+		c.Migrations = All()
+	}
+	return c
+}
+
+// =============================================================================
+// Migrator
+// =============================================================================
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db         *gorm.DB
+	cfg        Config
+	migrations []Migration // 按版本升序
+	now        func() time.Time
+}
+
+// New 创建迁移执行器，检查迁移列表 (版本 > 0、不重复、Up 非空)
+func New(db *gorm.DB, cfg Config) (*Migrator, error) {
+	cfg = cfg.withDefaults()
+	migrations := append([]Migration(nil), cfg.Migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version <= 0 || m.Up == nil {
+			return nil, ErrInvalidMigration.Wrapf("version %d (%s): version must be positive and Up is required", m.Version, m.Name)
+		}
+		if i > 0 && migrations[i-1].Version == m.Version {
+			return nil, ErrInvalidMigration.Wrapf("duplicate version %d (%s, %s)", m.Version, migrations[i-1].Name, m.Name)
+		}
+	}
+	return &Migrator{db: db, cfg: cfg, migrations: migrations, now: time.Now}, nil
+}
+
+// Up 按版本升序执行所有未执行、且版本 <= target 的迁移 (target = 0 表示最新)
+//
+// 比已执行的最大版本还小的未执行版本 (分支合并进来的) 同样会补上
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.checkApplied(conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if target > 0 && mg.Version > target {
+				break
+			}
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			start := time.Now()
+			if err := mg.Up(conn); err != nil {
+				return fmt.Errorf("migrate: up %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			rec := appliedRecord{Version: mg.Version, Name: mg.Name, AppliedAt: m.now().UnixMilli()}
+			if err := conn.Table(m.cfg.Table).Create(&rec).Error; err != nil {
+				return fmt.Errorf("migrate: record %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			log.Printf("[Migrate] up %d_%s (%s)", mg.Version, mg.Name, time.Since(start).Round(time.Millisecond))
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按执行时间倒序回滚最近 steps 个已执行的迁移
+//
+// 遇到不可回滚的迁移时停止，返回 ErrIrreversible (之前回滚的保持回滚)
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, ErrInvalidMigration.Wrapf("steps must be positive, got %d", steps)
+	}
+	var done []Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.checkApplied(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if mg.Down == nil {
+				return ErrIrreversible.Wrapf("%d_%s", mg.Version, mg.Name)
+			}
+			start := time.Now()
+			if err := mg.Down(conn); err != nil {
+				return fmt.Errorf("migrate: down %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			if err := conn.Table(m.cfg.Table).Where("version = ?", mg.Version).Delete(&appliedRecord{}).Error; err != nil {
+				return fmt.Errorf("migrate: unrecord %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			log.Printf("[Migrate] down %d_%s (%s)", mg.Version, mg.Name, time.Since(start).Round(time.Millisecond))
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 全部版本的执行状态 (按版本升序，含库里有但代码不认识的版本)
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := m.loadApplied(db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := Status{Version: mg.Version, Name: mg.Name}
+		if rec, ok := applied[mg.Version]; ok {
+			st.Applied, st.AppliedAt = true, rec.AppliedAt
+			delete(applied, mg.Version)
+		}
+		out = append(out, st)
+	}
+	for _, rec := range applied {
+		out = append(out, Status{Version: rec.Version, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Unknown: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Migrations 当前代码的迁移列表 (按版本升序)
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// =============================================================================
+// 内部实现
+// =============================================================================
+
+// withLock 在同一个连接上持有命名锁执行 fn (GET_LOCK 是会话级的，连接池换连接会丢锁)
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var got sql.NullInt64
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", m.cfg.LockName, int(m.cfg.LockTimeout.Seconds())).Scan(&got).Error; err != nil {
+			return fmt.Errorf("migrate: acquire lock: %w", err)
+		}
+		if !got.Valid || got.Int64 != 1 {
+			return ErrLocked.Wrapf("lock %q not acquired within %s", m.cfg.LockName, m.cfg.LockTimeout)
+		}
+		defer func() {
+			var released sql.NullInt64
+			if err := conn.Raw("SELECT RELEASE_LOCK(?)", m.cfg.LockName).Scan(&released).Error; err != nil {
+				log.Printf("[Migrate] release lock %s: %v", m.cfg.LockName, err)
+			}
+		}()
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ("+
+		"`version` BIGINT NOT NULL PRIMARY KEY, "+
+		"`name` VARCHAR(128) NOT NULL, "+
+		"`applied_at` BIGINT NOT NULL COMMENT '毫秒'"+
+		") ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '表结构迁移版本'", m.cfg.Table)).Error
+	if err != nil {
+		return fmt.Errorf("migrate: create %s: %w", m.cfg.Table, err)
+	}
+	return nil
+}
+
+func (m *Migrator) loadApplied(db *gorm.DB) (map[int64]appliedRecord, error) {
+	var recs []appliedRecord
+	if err := db.Table(m.cfg.Table).Find(&recs).Error; err != nil {
+		return nil, fmt.Errorf("migrate: load %s: %w", m.cfg.Table, err)
+	}
+	applied := make(map[int64]appliedRecord, len(recs))
+	for _, r := range recs {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// checkApplied 读已执行版本，有代码不认识的版本时返回 ErrUnknownVersion
+func (m *Migrator) checkApplied(db *gorm.DB) (map[int64]appliedRecord, error) {
+	applied, err := m.loadApplied(db)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int64]bool, len(m.migrations))
+	for _, mg := range m.migrations {
+		known[mg.Version] = true
+	}
+	var unknown []int64
+	for v := range applied {
+		if !known[v] {
+			unknown = append(unknown, v)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+		return nil, ErrUnknownVersion.Wrapf("versions %v", unknown)
+	}
+	return applied, nil
+}
@@ -0,0 +1,206 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 依赖外部 MySQL，连不上时跳过
+const testDSN = "root:123456@tcp(127.0.0.1:3307)/my_cex?charset=utf8mb4&parseTime=True&loc=Local"
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- header; not a statement\n" +
+		"CREATE TABLE `a;b` (x INT COMMENT 'semi; colon', y INT); -- trailing; comment\n" +
+		"INSERT INTO t VALUES ('it''s;', \"q\\\";\");\n" +
+		"  ;\n" +
+		"SELECT 1"
+	got := splitStatements(script)
+	if len(got) != 3 {
+		t.Fatalf("got %d statements: %q", len(got), got)
+	}
+	if !strings.HasPrefix(got[0], "CREATE TABLE `a;b`") || !strings.HasSuffix(got[0], "y INT)") {
+		t.Errorf("stmt 0: %q", got[0])
+	}
+	if got[1] != `INSERT INTO t VALUES ('it''s;', "q\";")` {
+		t.Errorf("stmt 1: %q", got[1])
+	}
+	if got[2] != "SELECT 1" {
+		t.Errorf("stmt 2: %q", got[2])
+	}
+}
+
+func TestNew_Validates(t *testing.T) {
+	noop := func(*gorm.DB) error { return nil }
+	cases := map[string][]Migration{
+		"zero version": {{Version: 0, Name: "a", Up: noop}},
+		"no up":        {{Version: 1, Name: "a"}},
+		"duplicate":    {{Version: 2, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}, {Version: 2, Name: "c", Up: noop}},
+	}
+	for name, migrations := range cases {
+		if _, err := New(nil, Config{Migrations: migrations}); !errors.Is(err, ErrInvalidMigration) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	m, err := New(nil, Config{Migrations: []Migration{{Version: 3, Up: noop}, {Version: 1, Up: noop}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Migrations(); got[0].Version != 1 || got[1].Version != 3 {
+		t.Errorf("not sorted: %+v", got)
+	}
+}
+
+var (
+	createRe = regexp.MustCompile("(?i)^CREATE TABLE (IF NOT EXISTS )?`?(\\w+)`?")
+	dropRe   = regexp.MustCompile("(?i)^DROP TABLE (IF EXISTS )?`?(\\w+)`?")
+)
+
+// TestAll_Reentrant 内置迁移必须可重入、可回滚，且 down 删掉 up 建的每张表
+func TestAll_Reentrant(t *testing.T) {
+	m, err := New(nil, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	referenced := make(map[string]bool)
+	for _, mg := range m.Migrations() {
+		if mg.Down == nil {
+			t.Errorf("%d_%s: no down step", mg.Version, mg.Name)
+		}
+		base := sqlBase(mg.Version, mg.Name)
+		up, err := sqlFiles.ReadFile(base + ".up.sql")
+		if err != nil {
+			continue // Go 迁移
+		}
+		down, err := sqlFiles.ReadFile(base + ".down.sql")
+		if err != nil {
+			t.Errorf("%s: %v", base, err)
+			continue
+		}
+		referenced[base+".up.sql"], referenced[base+".down.sql"] = true, true
+
+		created := make(map[string]bool)
+		for _, s := range splitStatements(string(up)) {
+			switch {
+			case createRe.MatchString(s):
+				sub := createRe.FindStringSubmatch(s)
+				if sub[1] == "" {
+					t.Errorf("%s: CREATE TABLE %s without IF NOT EXISTS", base, sub[2])
+				}
+				created[sub[2]] = true
+			case strings.HasPrefix(strings.ToUpper(s), "INSERT ") && !strings.HasPrefix(strings.ToUpper(s), "INSERT IGNORE "):
+				t.Errorf("%s: INSERT without IGNORE: %s", base, s)
+			}
+		}
+		for _, s := range splitStatements(string(down)) {
+			if sub := dropRe.FindStringSubmatch(s); sub != nil {
+				if sub[1] == "" {
+					t.Errorf("%s: DROP TABLE %s without IF EXISTS", base, sub[2])
+				}
+				delete(created, sub[2])
+			}
+		}
+		for table := range created {
+			t.Errorf("%s: down does not drop %s", base, table)
+		}
+	}
+
+	files, _ := fs.Glob(sqlFiles, "sql/*.sql")
+	for _, f := range files {
+		if !referenced[f] {
+			t.Errorf("%s not registered in All", f)
+		}
+	}
+}
+
+// TestAll_CoversCoreTables 冷启动必需的表都有迁移
+func TestAll_CoversCoreTables(t *testing.T) {
+	created := make(map[string]bool)
+	files, _ := fs.Glob(sqlFiles, "sql/*.up.sql")
+	for _, f := range files {
+		script, _ := sqlFiles.ReadFile(f)
+		for _, s := range splitStatements(string(script)) {
+			if sub := createRe.FindStringSubmatch(s); sub != nil {
+				created[sub[2]] = true
+			}
+		}
+	}
+	for _, table := range []string{
+		"balance_000", "journal_000", "balances", "journals", "orders", "positions", "contract_specs",
+		"insurance_fund_balances", "insurance_fund_logs", "position_history", "price_history", "funding_rate_history",
+	} {
+		if !created[table] {
+			t.Errorf("no migration creates %s", table)
+		}
+	}
+}
+
+func TestMigrator_MySQL(t *testing.T) {
+	db, err := gorm.Open(mysql.Open(testDSN), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Skipf("MySQL 不可用，跳过: %v", err)
+	}
+	if sqlDB, err := db.DB(); err != nil || sqlDB.Ping() != nil {
+		t.Skip("MySQL 不可用，跳过")
+	}
+	ctx := context.Background()
+	const table = "schema_migrations_test"
+	cleanup := func() {
+		db.Exec("DROP TABLE IF EXISTS `" + table + "`, `migrate_test_a`, `migrate_test_b`")
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	migrations := []Migration{
+		{Version: 1, Name: "a", Up: execStatements("CREATE TABLE IF NOT EXISTS `migrate_test_a` (id INT PRIMARY KEY)"), Down: execStatements("DROP TABLE IF EXISTS `migrate_test_a`")},
+		{Version: 2, Name: "b", Up: execStatements("CREATE TABLE IF NOT EXISTS `migrate_test_b` (id INT PRIMARY KEY)"), Down: execStatements("DROP TABLE IF EXISTS `migrate_test_b`")},
+	}
+	m, err := New(db, Config{Table: table, Migrations: migrations})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if done, err := m.Up(ctx, 1); err != nil || len(done) != 1 {
+		t.Fatalf("up to 1: %d %v", len(done), err)
+	}
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("up: %+v %v", done, err)
+	}
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Fatalf("second up should be a no-op: %+v %v", done, err)
+	}
+	if !db.Migrator().HasTable("migrate_test_b") {
+		t.Fatal("migrate_test_b not created")
+	}
+
+	if done, err := m.Down(ctx, 1); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("down: %+v %v", done, err)
+	}
+	if db.Migrator().HasTable("migrate_test_b") {
+		t.Fatal("migrate_test_b not dropped")
+	}
+	st, err := m.Status(ctx)
+	if err != nil || len(st) != 2 || !st[0].Applied || st[1].Applied {
+		t.Fatalf("status: %+v %v", st, err)
+	}
+
+	// 旧二进制 (只认识版本 1) 遇到新版本记录：拒绝
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := New(db, Config{Table: table, Migrations: migrations[:1]})
+	if _, err := old.Up(ctx, 0); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("old build up: %v", err)
+	}
+	if st, _ := old.Status(ctx); len(st) != 2 || !st[1].Unknown {
+		t.Fatalf("old build status: %+v", st)
+	}
+}
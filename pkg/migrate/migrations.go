@@ -0,0 +1,184 @@
+package migrate
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/fund"
+)
+
+// =============================================================================
+// 迁移列表
+// =============================================================================
+//
+//	0001 fund                       冷资产余额 / 流水 (分片模板 000 + 单表)
+//	0002 fund_shards                余额 / 流水分片 001 ~ 127 (Go，CREATE TABLE ... LIKE 模板)
+//	0003 futures_core               合约规格、持仓、订单、历史持仓、价格历史
+//	0004 futures_funding_settlement 交割、参数变更、资金费、开仓 outbox
+//	0005 insurance_fund             保险基金 (含 USDT 初始余额)
+//	0006 futures_user_orders        条件单、自动追加保证金设置
+//	0007 spot                       现货交易对
+//	0008 platform                   API Key、审计、交易日历、通知偏好、提现风控
+//	0009 report                     运营日报、日终关账
+//	0010 futures_funding_params     合约资金费率下限、利率 (Go，按列是否存在 ALTER)
+//	0011 journal_archive            流水归档清单、余额检查点
+//
+// 新增迁移：SQL 的在 sql/ 下放 NNNN_name.up.sql / NNNN_name.down.sql 并在 All 里登记，
+// 版本号取当前最大 + 1
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// All 全部迁移 (按版本升序)
+func All() []Migration {
+	return []Migration{
+		sqlMigration(1, "fund"),
+		{Version: 2, Name: "fund_shards", Up: createFundShards, Down: dropFundShards},
+		sqlMigration(3, "futures_core"),
+		sqlMigration(4, "futures_funding_settlement"),
+		sqlMigration(5, "insurance_fund"),
+		sqlMigration(6, "futures_user_orders"),
+		sqlMigration(7, "spot"),
+		sqlMigration(8, "platform"),
+		sqlMigration(9, "report"),
+		{Version: 10, Name: "futures_funding_params", Up: addFundingParams, Down: dropFundingParams},
+		sqlMigration(11, "journal_archive"),
+	}
+}
+
+// sqlMigration 从嵌入的 sql/NNNN_name.{up,down}.sql 生成迁移；down 文件不存在表示不可回滚
+//
+// 文件缺失属于编译期就该发现的错误 (测试覆盖 All)，这里直接 panic
+func sqlMigration(version int64, name string) Migration {
+	base := sqlBase(version, name)
+	up, err := sqlFiles.ReadFile(base + ".up.sql")
+	if err != nil {
+		panic(fmt.Sprintf("migrate: %v", err))
+	}
+	m := Migration{Version: version, Name: name, Up: execStatements(string(up))}
+	if down, err := sqlFiles.ReadFile(base + ".down.sql"); err == nil {
+		m.Down = execStatements(string(down))
+	}
+	return m
+}
+
+func sqlBase(version int64, name string) string {
+	return fmt.Sprintf("sql/%04d_%s", version, name)
+}
+
+func execStatements(script string) func(db *gorm.DB) error {
+	stmts := splitStatements(script)
+	return func(db *gorm.DB) error {
+		for _, s := range stmts {
+			if err := db.Exec(s).Error; err != nil {
+				return fmt.Errorf("%w\n%s", err, s)
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements 按分号切分 SQL 脚本，去掉 -- 注释；引号和反引号内的分号不切
+//
+// 不支持存储过程 (DELIMITER)：需要循环的用 Go 迁移
+func splitStatements(script string) []string {
+	var (
+		out   []string
+		cur   strings.Builder
+		quote byte // 当前所在的引号 (' " `)，0 表示不在引号内
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			out = append(out, s)
+		}
+		cur.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				cur.WriteByte(c)
+				i++
+				c = script[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			c = '\n'
+		case c == ';':
+			flush()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	flush()
+	return out
+}
+
+// =============================================================================
+// Go 迁移
+// =============================================================================
+
+// createFundShards 按模板 000 建余额 / 流水分片 (替代 fund.sql 里的存储过程)
+func createFundShards(db *gorm.DB) error {
+	for i := 1; i < fund.NumShards; i++ {
+		for _, table := range []string{"balance", "journal"} {
+			stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s_%03d` LIKE `%s_000`", table, i, table)
+			if err := db.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dropFundShards(db *gorm.DB) error {
+	for i := fund.NumShards - 1; i >= 1; i-- {
+		stmt := fmt.Sprintf("DROP TABLE IF EXISTS `journal_%03d`, `balance_%03d`", i, i)
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fundingParamColumns 0010 给 contract_specs 加的列 (MySQL 的 ADD COLUMN 没有 IF NOT EXISTS)
+var fundingParamColumns = []struct{ name, def string }{
+	{"min_funding_rate", "BIGINT NOT NULL DEFAULT 0 COMMENT '最小资金费率(万分比), 0=与最大对称' AFTER `max_funding_rate`"},
+	{"interest_rate", "BIGINT NOT NULL DEFAULT 0 COMMENT '每期利率(万分比), 0=不计利率项' AFTER `min_funding_rate`"},
+}
+
+func addFundingParams(db *gorm.DB) error {
+	for _, col := range fundingParamColumns {
+		if db.Migrator().HasColumn("contract_specs", col.name) {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE `contract_specs` ADD COLUMN `%s` %s", col.name, col.def)
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropFundingParams(db *gorm.DB) error {
+	for i := len(fundingParamColumns) - 1; i >= 0; i-- {
+		name := fundingParamColumns[i].name
+		if !db.Migrator().HasColumn("contract_specs", name) {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("ALTER TABLE `contract_specs` DROP COLUMN `%s`", name)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+-- 回滚 fund
+DROP TABLE IF EXISTS `journals`;
+DROP TABLE IF EXISTS `balances`;
+DROP TABLE IF EXISTS `journal_000`;
+DROP TABLE IF EXISTS `balance_000`;
@@ -0,0 +1,64 @@
+-- 冷资产余额 / 流水 (分片模板 000 + 开发用单表)，其余分片见 0002 (Go 迁移)
+
+CREATE TABLE IF NOT EXISTS `balance_000` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `symbol` VARCHAR(16) NOT NULL COMMENT '资产符号 (USDT/BTC)',
+    `available` BIGINT NOT NULL DEFAULT 0 COMMENT '可用余额',
+    `locked` BIGINT NOT NULL DEFAULT 0 COMMENT '冻结余额',
+    `version` INT NOT NULL DEFAULT 0 COMMENT '乐观锁版本号',
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY `uk_user_symbol` (`user_id`, `symbol`),
+    KEY `idx_user` (`user_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户余额表 (分片000)';
+
+CREATE TABLE IF NOT EXISTS `journal_000` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `event_id` VARCHAR(64) NOT NULL COMMENT '幂等键',
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(16) NOT NULL,
+    `change_type` TINYINT NOT NULL COMMENT '1=冻结,2=解冻,3=划转,4=充值,5=提现,6=手续费,7=返佣,8=碎币兑换,9=推荐返佣',
+    `amount` BIGINT NOT NULL COMMENT '变动金额 (正数)',
+    `available_before` BIGINT NOT NULL,
+    `available_after` BIGINT NOT NULL,
+    `locked_before` BIGINT NOT NULL,
+    `locked_after` BIGINT NOT NULL,
+    `biz_type` VARCHAR(16) NOT NULL COMMENT 'ORDER/TRADE/DEPOSIT/WITHDRAW/DUST/COMMISSION',
+    `biz_id` VARCHAR(64) NOT NULL COMMENT '关联业务ID',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uk_event_id` (`event_id`),
+    KEY `idx_user_symbol` (`user_id`, `symbol`),
+    KEY `idx_biz` (`biz_type`, `biz_id`),
+    KEY `idx_created` (`created_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '余额流水表 (分片000)';
+
+CREATE TABLE IF NOT EXISTS `balances` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(16) NOT NULL,
+    `available` BIGINT NOT NULL DEFAULT 0,
+    `locked` BIGINT NOT NULL DEFAULT 0,
+    `version` INT NOT NULL DEFAULT 0,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY `uk_user_symbol` (`user_id`, `symbol`),
+    KEY `idx_user` (`user_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户余额表 (单表版)';
+
+CREATE TABLE IF NOT EXISTS `journals` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `event_id` VARCHAR(64) NOT NULL,
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(16) NOT NULL,
+    `change_type` TINYINT NOT NULL,
+    `amount` BIGINT NOT NULL,
+    `available_before` BIGINT NOT NULL,
+    `available_after` BIGINT NOT NULL,
+    `locked_before` BIGINT NOT NULL,
+    `locked_after` BIGINT NOT NULL,
+    `biz_type` VARCHAR(16) NOT NULL,
+    `biz_id` VARCHAR(64) NOT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uk_event_id` (`event_id`),
+    KEY `idx_user_symbol` (`user_id`, `symbol`),
+    KEY `idx_created` (`created_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '余额流水表 (单表版)';
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- 回滚 futures_core
+DROP TABLE IF EXISTS `price_history`;
+DROP TABLE IF EXISTS `position_history`;
+DROP TABLE IF EXISTS `orders`;
+DROP TABLE IF EXISTS `positions`;
+DROP TABLE IF EXISTS `contract_specs`;
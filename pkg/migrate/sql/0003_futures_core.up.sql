@@ -0,0 +1,112 @@
+-- 合约核心表：合约规格、持仓、统一订单、历史持仓、价格历史
+
+-- 合约规格表
+CREATE TABLE IF NOT EXISTS `contract_specs` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '合约ID',
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识: BTCUSDT',
+    `base_currency` VARCHAR(16) NOT NULL COMMENT '标的资产: BTC',
+    `quote_currency` VARCHAR(16) NOT NULL COMMENT '报价货币: USDT',
+    `settle_currency` VARCHAR(16) NOT NULL COMMENT '结算货币: USDT',
+    `contract_type` TINYINT NOT NULL DEFAULT 0 COMMENT '0=永续, 1=交割',
+    `contract_size` BIGINT NOT NULL COMMENT '合约面值 (精度单位)',
+    `inverse` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '1=反向合约 (币本位，基础币结算)',
+    `tick_size` BIGINT NOT NULL COMMENT '最小价格变动',
+    `min_order_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最小下单量',
+    `max_order_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最大下单量',
+    `max_position_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最大持仓量',
+    `max_order_lifetime` BIGINT NOT NULL DEFAULT 0 COMMENT '挂单最长存活(秒), 0=默认',
+    `max_leverage` INT NOT NULL DEFAULT 100 COMMENT '最大杠杆倍数',
+    `initial_margin_rate` BIGINT NOT NULL COMMENT '初始保证金率 (万分比)',
+    `maint_margin_rate` BIGINT NOT NULL COMMENT '维持保证金率 (万分比)',
+    `liquidation_fee_rate` BIGINT NOT NULL DEFAULT 0 COMMENT '强平手续费率 (万分比)',
+    `funding_interval` BIGINT NOT NULL DEFAULT 28800 COMMENT '资金费结算间隔(秒)',
+    `max_funding_rate` BIGINT NOT NULL DEFAULT 75 COMMENT '最大资金费率(万分比)',
+    `price_sources` JSON COMMENT '价格来源: ["binance","okx"]',
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '0=待上线,1=交易中,2=结算中,3=已结算,4=已下架',
+    `listed_at` BIGINT NOT NULL DEFAULT 0 COMMENT '上线时间 (unix ms)',
+    `expiry_at` BIGINT NOT NULL DEFAULT 0 COMMENT '到期时间 (unix ms), 永续为0',
+    `created_at` BIGINT NOT NULL COMMENT '创建时间',
+    `updated_at` BIGINT NOT NULL COMMENT '更新时间',
+    UNIQUE KEY `uk_symbol` (`symbol`),
+    KEY `idx_status` (`status`),
+    KEY `idx_contract_type` (`contract_type`),
+    KEY `idx_expiry` (`expiry_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '合约规格表';
+
+-- 持仓表
+CREATE TABLE IF NOT EXISTS `positions` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `size` BIGINT NOT NULL DEFAULT 0 COMMENT '持仓量 (正=多,负=空)',
+    `entry_price` BIGINT NOT NULL DEFAULT 0 COMMENT '开仓均价',
+    `margin` BIGINT NOT NULL DEFAULT 0 COMMENT '占用保证金',
+    `leverage` INT NOT NULL DEFAULT 1 COMMENT '杠杆倍数',
+    `realized_pnl` BIGINT NOT NULL DEFAULT 0 COMMENT '累计已实现盈亏',
+    `opened_at` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮开仓时间',
+    `closed_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮累计平仓数量',
+    `close_value` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮累计平仓成交额',
+    `cycle_pnl` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮已实现盈亏',
+    `cycle_fee` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮手续费 (含强平手续费)',
+    `cycle_liq_fee` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮强平手续费',
+    `cycle_funding` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮资金费 (正=收入)',
+    `created_at` BIGINT NOT NULL,
+    `updated_at` BIGINT NOT NULL,
+    UNIQUE KEY `uk_user_symbol` (`user_id`, `symbol`),
+    KEY `idx_user` (`user_id`),
+    KEY `idx_symbol` (`symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '合约持仓表';
+
+-- 统一订单表
+CREATE TABLE IF NOT EXISTS `orders` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `order_id` BIGINT NOT NULL COMMENT '雪花ID',
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `product_type` VARCHAR(16) NOT NULL COMMENT 'SPOT/FUTURES/OPTIONS',
+    `side` TINYINT NOT NULL COMMENT '1=买,2=卖',
+    `order_type` TINYINT NOT NULL COMMENT '1=限价,2=市价',
+    `price` BIGINT NOT NULL,
+    `qty` BIGINT NOT NULL,
+    `filled_qty` BIGINT NOT NULL DEFAULT 0,
+    `avg_price` BIGINT NOT NULL DEFAULT 0,
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '0=新建,1=部分成交,2=全部成交,3=已撤销',
+    `extra` JSON COMMENT '产品特有字段',
+    `created_at` BIGINT NOT NULL,
+    `updated_at` BIGINT NOT NULL,
+    UNIQUE KEY `uk_order_id` (`order_id`),
+    KEY `idx_user_status` (`user_id`, `status`),
+    KEY `idx_user_symbol` (`user_id`, `symbol`),
+    KEY `idx_created` (`created_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '统一订单表';
+
+-- 历史持仓表 (每轮持仓清零时写入一条)
+CREATE TABLE IF NOT EXISTS `position_history` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `side` TINYINT NOT NULL COMMENT '1=多,-1=空',
+    `close_reason` VARCHAR(16) NOT NULL COMMENT 'CLOSE/LIQUIDATION/SETTLEMENT',
+    `leverage` INT NOT NULL DEFAULT 1 COMMENT '杠杆倍数',
+    `qty` BIGINT NOT NULL COMMENT '累计平仓数量',
+    `entry_price` BIGINT NOT NULL COMMENT '开仓均价',
+    `exit_price` BIGINT NOT NULL COMMENT '平仓均价',
+    `realized_pnl` BIGINT NOT NULL COMMENT '平仓盈亏',
+    `fee` BIGINT NOT NULL DEFAULT 0 COMMENT '手续费 (含强平手续费)',
+    `liq_fee` BIGINT NOT NULL DEFAULT 0 COMMENT '强平手续费',
+    `funding` BIGINT NOT NULL DEFAULT 0 COMMENT '资金费 (正=收入)',
+    `net_pnl` BIGINT NOT NULL COMMENT '净盈亏 = 平仓盈亏 - 手续费 + 资金费',
+    `opened_at` BIGINT NOT NULL COMMENT '开仓时间',
+    `closed_at` BIGINT NOT NULL COMMENT '平仓时间',
+    KEY `idx_user_closed` (`user_id`, `closed_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '历史持仓表';
+
+-- 标记/指数价格历史 (每分钟采样)
+CREATE TABLE IF NOT EXISTS `price_history` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `minute` BIGINT NOT NULL COMMENT '整分钟 (毫秒)',
+    `mark_price` BIGINT NOT NULL COMMENT '标记价格',
+    `index_price` BIGINT NOT NULL DEFAULT 0 COMMENT '指数价格',
+    UNIQUE KEY `uk_symbol_minute` (`symbol`, `minute`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '标记/指数价格分钟历史';
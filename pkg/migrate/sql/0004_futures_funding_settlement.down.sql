@@ -0,0 +1,8 @@
+-- 回滚 futures_funding_settlement
+DROP TABLE IF EXISTS `funding_rate_history`;
+DROP TABLE IF EXISTS `funding_payments`;
+DROP TABLE IF EXISTS `futures_order_outbox`;
+DROP TABLE IF EXISTS `funding_settlement_cursors`;
+DROP TABLE IF EXISTS `contract_param_changes`;
+DROP TABLE IF EXISTS `settlement_details`;
+DROP TABLE IF EXISTS `settlement_records`;
@@ -0,0 +1,123 @@
+-- 资金费与交割：结算记录、参数变更、资金费游标 / 支付 / 费率历史、开仓 outbox
+
+-- 交割记录表
+CREATE TABLE IF NOT EXISTS settlement_records (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    symbol VARCHAR(32) NOT NULL,
+    expiry_at BIGINT NOT NULL,
+    settlement_price BIGINT NOT NULL,
+    total_positions INT NOT NULL DEFAULT 0,
+    total_pnl BIGINT NOT NULL DEFAULT 0,
+    total_returned BIGINT NOT NULL DEFAULT 0,
+    total_shortfall BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'RUNNING',
+    started_at BIGINT NOT NULL,
+    finished_at BIGINT,
+    error_msg TEXT,
+    UNIQUE KEY uk_symbol_expiry (symbol, expiry_at),
+    INDEX idx_started_at (started_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 用户交割明细表
+CREATE TABLE IF NOT EXISTS settlement_details (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    settlement_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT NOT NULL,
+    symbol VARCHAR(32) NOT NULL,
+    side TINYINT NOT NULL,
+    size BIGINT NOT NULL,
+    entry_price BIGINT NOT NULL,
+    settlement_price BIGINT NOT NULL,
+    margin BIGINT NOT NULL,
+    pnl BIGINT NOT NULL,
+    settlement_amount BIGINT NOT NULL,
+    shortfall BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL,
+    UNIQUE KEY uk_settlement_user (settlement_id, user_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_symbol (symbol)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 合约参数定时变更 (生效后即为参数版本历史)
+CREATE TABLE IF NOT EXISTS `contract_param_changes` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `change_set` JSON NOT NULL COMMENT '变更目标值',
+    `reason` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '公告说明',
+    `effective_at` BIGINT NOT NULL COMMENT '生效时间 (unix ms)',
+    `status` VARCHAR(16) NOT NULL COMMENT 'PENDING/APPLIED/CANCELED/FAILED',
+    `notified_at` BIGINT NOT NULL DEFAULT 0 COMMENT '预告发出时间',
+    `version` INT NOT NULL DEFAULT 0 COMMENT '生效后的参数版本号',
+    `params_before` JSON NULL COMMENT '生效前参数',
+    `params_after` JSON NULL COMMENT '生效后参数',
+    `applied_at` BIGINT NOT NULL DEFAULT 0,
+    `fail_reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` BIGINT NOT NULL,
+    KEY `idx_symbol_version` (`symbol`, `version`),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '合约参数定时变更';
+
+-- 资金费结算游标 (断点续跑)
+CREATE TABLE IF NOT EXISTS `funding_settlement_cursors` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `symbol` VARCHAR(32) NOT NULL COMMENT '合约标识',
+    `funding_time` BIGINT NOT NULL COMMENT '结算时间点 (unix ms)',
+    `funding_rate` BIGINT NOT NULL COMMENT '本期费率 (万分比)',
+    `mark_price` BIGINT NOT NULL COMMENT '本期标记价格',
+    `last_position_id` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '已完成的最大持仓ID',
+    `done` TINYINT(1) NOT NULL DEFAULT 0,
+    `created_at` BIGINT NOT NULL,
+    `updated_at` BIGINT NOT NULL,
+    UNIQUE KEY `uk_symbol_time` (`symbol`, `funding_time`),
+    KEY `idx_done` (`done`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '资金费结算游标';
+
+-- 开仓下单 outbox (冻结 + 写订单 + outbox 同一事务，中继提交撮合)
+CREATE TABLE IF NOT EXISTS `futures_order_outbox` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `order_id` BIGINT NOT NULL COMMENT '订单ID',
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `currency` VARCHAR(16) NOT NULL COMMENT '冻结币种',
+    `side` TINYINT NOT NULL,
+    `order_type` TINYINT NOT NULL DEFAULT 0 COMMENT '0=LIMIT 1=MARKET (price 为保护价)',
+    `price` BIGINT NOT NULL,
+    `qty` BIGINT NOT NULL,
+    `leverage` INT NOT NULL,
+    `margin` BIGINT NOT NULL COMMENT '冻结的保证金',
+    `expire_at` BIGINT NOT NULL DEFAULT 0 COMMENT '挂单到期时间 (unix ms)',
+    `client_order_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '客户端订单号',
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '0=PENDING 1=SENT 2=ABORTED',
+    `created_at` BIGINT NOT NULL,
+    `updated_at` BIGINT NOT NULL,
+    UNIQUE KEY `uk_order_id` (`order_id`),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '开仓下单 outbox';
+
+-- 资金费支付记录
+CREATE TABLE IF NOT EXISTS funding_payments (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    symbol VARCHAR(32) NOT NULL,
+    position_size BIGINT NOT NULL,
+    mark_price BIGINT NOT NULL,
+    funding_rate BIGINT NOT NULL,
+    payment BIGINT NOT NULL,
+    funding_time BIGINT NOT NULL,
+    created_at BIGINT NOT NULL,
+    INDEX idx_user_id (user_id),
+    INDEX idx_symbol (symbol),
+    INDEX idx_funding_time (funding_time)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 资金费率历史
+CREATE TABLE IF NOT EXISTS funding_rate_history (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    symbol VARCHAR(32) NOT NULL,
+    funding_rate BIGINT NOT NULL,
+    mark_price BIGINT NOT NULL,
+    index_price BIGINT NOT NULL,
+    funding_time BIGINT NOT NULL,
+    created_at BIGINT NOT NULL,
+    UNIQUE INDEX idx_symbol_time (symbol, funding_time)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+-- 回滚 insurance_fund
+DROP TABLE IF EXISTS `insurance_fund_logs`;
+DROP TABLE IF EXISTS `insurance_fund_balances`;
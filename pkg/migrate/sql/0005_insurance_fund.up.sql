@@ -0,0 +1,29 @@
+-- 保险基金余额 / 流水，初始化 USDT 保险池
+
+-- 保险基金余额表
+CREATE TABLE IF NOT EXISTS insurance_fund_balances (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    currency VARCHAR(16) NOT NULL,
+    balance BIGINT NOT NULL DEFAULT 0,
+    updated_at BIGINT NOT NULL,
+    UNIQUE INDEX idx_currency (currency)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 保险基金流水表
+CREATE TABLE IF NOT EXISTS insurance_fund_logs (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    currency VARCHAR(16) NOT NULL,
+    change_type VARCHAR(32) NOT NULL, -- DEPOSIT/WITHDRAW/LIQUIDATION_PROFIT/BANKRUPT_COVER
+    amount BIGINT NOT NULL, -- 正=增加，负=减少
+    balance_after BIGINT NOT NULL,
+    related_user_id BIGINT DEFAULT 0,
+    related_symbol VARCHAR(32) DEFAULT '',
+    remark TEXT,
+    created_at BIGINT NOT NULL,
+    INDEX idx_currency (currency),
+    INDEX idx_created_at (created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 初始化 USDT 保险池 (已存在则跳过)
+INSERT IGNORE INTO `insurance_fund_balances` (`currency`, `balance`, `updated_at`)
+VALUES ('USDT', 0, UNIX_TIMESTAMP() * 1000);
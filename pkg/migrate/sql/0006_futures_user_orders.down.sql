@@ -0,0 +1,3 @@
+-- 回滚 futures_user_orders
+DROP TABLE IF EXISTS `auto_margin_settings`;
+DROP TABLE IF EXISTS `trigger_orders`;
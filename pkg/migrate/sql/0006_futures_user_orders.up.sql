@@ -0,0 +1,38 @@
+-- 条件单、自动追加保证金设置
+
+-- 条件单表 (止盈/止损/计划委托/跟踪止损)
+CREATE TABLE IF NOT EXISTS trigger_orders (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    symbol VARCHAR(32) NOT NULL,
+    kind TINYINT NOT NULL, -- 1=STOP 2=TAKE_PROFIT 3=STOP_LOSS 4=TRAILING_STOP
+    side TINYINT NOT NULL, -- 1=多 -1=空
+    qty BIGINT NOT NULL DEFAULT 0, -- 平仓类 0=全部
+    leverage INT NOT NULL DEFAULT 0,
+    trigger_price BIGINT NOT NULL DEFAULT 0,
+    order_price BIGINT NOT NULL DEFAULT 0, -- 0=按触发时标记价
+    callback_rate BIGINT NOT NULL DEFAULT 0, -- 跟踪止损回撤 (万分比)
+    extreme_price BIGINT NOT NULL DEFAULT 0,
+    status TINYINT NOT NULL, -- 1=PENDING 2=TRIGGERED 3=CANCELED 4=FAILED
+    trigger_mark_price BIGINT NOT NULL DEFAULT 0,
+    triggered_at BIGINT NOT NULL DEFAULT 0,
+    fail_reason VARCHAR(255) DEFAULT '',
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    INDEX idx_user_status (user_id, status),
+    INDEX idx_status (status)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- 自动追加保证金设置 (用户主动开启，见 futures/auto_margin.go)
+CREATE TABLE IF NOT EXISTS `auto_margin_settings` (
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `enabled` TINYINT(1) NOT NULL DEFAULT 0,
+    `amount` BIGINT NOT NULL DEFAULT 0 COMMENT '每次追加固定金额 (结算币种)，0=按比例',
+    `percent_bps` BIGINT NOT NULL DEFAULT 0 COMMENT '每次按可用余额的比例追加 (万分比)',
+    `max_total` BIGINT NOT NULL COMMENT '每轮持仓累计自动追加上限',
+    `used` BIGINT NOT NULL DEFAULT 0 COMMENT '本轮已自动追加',
+    `used_cycle` BIGINT NOT NULL DEFAULT 0 COMMENT 'used 所属持仓轮次 (positions.opened_at)',
+    `updated_at` BIGINT NOT NULL,
+    PRIMARY KEY (`user_id`, `symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '自动追加保证金设置';
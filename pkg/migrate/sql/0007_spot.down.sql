@@ -0,0 +1,2 @@
+-- 回滚 spot
+DROP TABLE IF EXISTS `spot_symbols`;
@@ -0,0 +1,6 @@
+-- 回滚 platform
+DROP TABLE IF EXISTS `withdraw_risk_decision`;
+DROP TABLE IF EXISTS `notify_preference`;
+DROP TABLE IF EXISTS `trading_calendar`;
+DROP TABLE IF EXISTS `audit_log`;
+DROP TABLE IF EXISTS `api_key`;
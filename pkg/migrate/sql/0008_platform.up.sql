@@ -0,0 +1,71 @@
+-- 平台服务：API Key、审计日志、交易日历、通知偏好、提现风控
+
+-- API Key (secret 不落盘，secret_hash = SHA256(secret))
+CREATE TABLE IF NOT EXISTS `api_key` (
+    `id` CHAR(32) NOT NULL PRIMARY KEY COMMENT '公开 key (X-API-KEY)',
+    `user_id` BIGINT NOT NULL,
+    `label` VARCHAR(64) NOT NULL DEFAULT '',
+    `permissions` TINYINT UNSIGNED NOT NULL COMMENT '位掩码: 1=read,2=trade,4=withdraw',
+    `secret_hash` BINARY(32) NOT NULL,
+    `created_at` BIGINT NOT NULL COMMENT '毫秒',
+    `expires_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒，0=不过期',
+    `revoked_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒，0=有效',
+    KEY `idx_user` (`user_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = 'API Key';
+
+-- 审计日志 (只追加，写入账号只授予 INSERT / SELECT)
+CREATE TABLE IF NOT EXISTS `audit_log` (
+    `seq` BIGINT UNSIGNED NOT NULL PRIMARY KEY COMMENT '链上序号，由 Logger 分配',
+    `timestamp` BIGINT NOT NULL COMMENT '毫秒',
+    `actor_type` VARCHAR(16) NOT NULL COMMENT 'USER/ADMIN/SYSTEM',
+    `actor_id` BIGINT NOT NULL DEFAULT 0,
+    `action` VARCHAR(32) NOT NULL COMMENT 'BALANCE_CHANGE/ORDER_PLACE/ORDER_CANCEL/LIQUIDATION/ADMIN',
+    `resource` VARCHAR(128) NOT NULL COMMENT '如 balance:1001:USDT / order:123',
+    `before_state` BLOB NULL COMMENT '变更前 (JSON 原始字节，不能用 JSON 类型)',
+    `after_state` BLOB NULL COMMENT '变更后',
+    `meta` BLOB NULL,
+    `prev_hash` CHAR(64) NOT NULL,
+    `hash` CHAR(64) NOT NULL,
+    KEY `idx_timestamp` (`timestamp`),
+    KEY `idx_actor` (`actor_id`, `seq`),
+    KEY `idx_action` (`action`, `seq`),
+    KEY `idx_resource` (`resource`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '审计日志 (哈希链)';
+
+-- 交易日历 (按交易对，见 calendar/calendar.go)
+CREATE TABLE IF NOT EXISTS `trading_calendar` (
+    `symbol` VARCHAR(32) NOT NULL COMMENT '交易对 / 合约',
+    `timezone` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'IANA 时区, 空为 UTC',
+    `sessions` JSON COMMENT '每周交易时段: [{"weekday":1,"open":"09:30","close":"16:00"}], 空为全天开放',
+    `maintenance` JSON COMMENT '维护窗口: [{"id":"mw-1","start":ms,"end":ms,"reason":""}]',
+    `updated_at` BIGINT NOT NULL DEFAULT 0 COMMENT '更新时间 (unix ms)',
+    PRIMARY KEY (`symbol`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='交易日历';
+
+-- 用户通知偏好 (没有记录的用户按默认渠道)
+CREATE TABLE IF NOT EXISTS `notify_preference` (
+    `user_id` BIGINT NOT NULL PRIMARY KEY,
+    `email` VARCHAR(128) NOT NULL DEFAULT '',
+    `webhook_url` VARCHAR(512) NOT NULL DEFAULT '',
+    `webhook_secret` VARCHAR(128) NOT NULL DEFAULT '' COMMENT 'HMAC-SHA256 签名密钥，空=不签名',
+    `channels` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'JSON: 通知类型 -> 渠道列表，空=全部按默认',
+    `updated_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒'
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户通知偏好';
+
+-- 提现风控决策 (每笔提现一条)
+CREATE TABLE IF NOT EXISTS `withdraw_risk_decision` (
+    `event_id` VARCHAR(64) NOT NULL PRIMARY KEY COMMENT '提现单号，与热钱包命令幂等键相同',
+    `user_id` BIGINT NOT NULL,
+    `asset` VARCHAR(16) NOT NULL,
+    `amount` BIGINT NOT NULL,
+    `address` VARCHAR(128) NOT NULL DEFAULT '',
+    `decision` TINYINT NOT NULL COMMENT '1=APPROVE,2=HOLD,3=DENY',
+    `score` INT NOT NULL DEFAULT 0,
+    `reasons` VARCHAR(1024) NOT NULL DEFAULT '',
+    `reviewer` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '人工复核人，自动决策为空',
+    `created_at` BIGINT NOT NULL COMMENT '毫秒',
+    `updated_at` BIGINT NOT NULL COMMENT '毫秒',
+    `reviewed_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒，0=未复核',
+    KEY `idx_user` (`user_id`, `created_at`),
+    KEY `idx_decision` (`decision`, `created_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '提现风控决策';
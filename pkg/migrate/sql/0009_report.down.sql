@@ -0,0 +1,7 @@
+-- 回滚 report
+DROP TABLE IF EXISTS `report_user_pnl`;
+DROP TABLE IF EXISTS `report_account_equity`;
+DROP TABLE IF EXISTS `report_eod_mark`;
+DROP TABLE IF EXISTS `report_daily_close`;
+DROP TABLE IF EXISTS `report_exchange_daily`;
+DROP TABLE IF EXISTS `report_symbol_daily`;
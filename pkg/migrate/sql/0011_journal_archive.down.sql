@@ -0,0 +1,3 @@
+-- 回滚 journal_archive (只删清单和检查点，对象存储里的归档不动)
+DROP TABLE IF EXISTS `journal_checkpoints`;
+DROP TABLE IF EXISTS `journal_archives`;
@@ -0,0 +1,32 @@
+-- 流水归档 (fund.JournalArchiver)
+
+-- 归档清单：一个对象 = 一张流水表的一批流水
+CREATE TABLE IF NOT EXISTS `journal_archives` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `source_table` VARCHAR(32) NOT NULL COMMENT '来源流水表，如 journal_005',
+    `first_id` BIGINT UNSIGNED NOT NULL,
+    `last_id` BIGINT UNSIGNED NOT NULL,
+    `records` INT NOT NULL DEFAULT 0,
+    `from_time` BIGINT NOT NULL COMMENT '批内最早 created_at (毫秒)',
+    `to_time` BIGINT NOT NULL COMMENT '批内最晚 created_at (毫秒)',
+    `object_key` VARCHAR(255) NOT NULL,
+    `checksum` CHAR(64) NOT NULL COMMENT '对象内容 sha256',
+    `archived_at` BIGINT NOT NULL COMMENT '毫秒',
+    `restored_at` BIGINT NOT NULL DEFAULT 0 COMMENT '最近一次恢复回原表 (毫秒)，0 表示没恢复过',
+    UNIQUE KEY `uk_table_first` (`source_table`, `first_id`),
+    KEY `idx_table_time` (`source_table`, `from_time`, `to_time`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '流水归档清单';
+
+-- 余额检查点：最后一条已归档流水之后的余额
+CREATE TABLE IF NOT EXISTS `journal_checkpoints` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(16) NOT NULL,
+    `available` BIGINT NOT NULL DEFAULT 0,
+    `locked` BIGINT NOT NULL DEFAULT 0,
+    `last_journal_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    `last_event_id` VARCHAR(64) NOT NULL DEFAULT '',
+    `as_of` BIGINT NOT NULL COMMENT '最后一条已归档流水的 created_at (毫秒)',
+    `updated_at` BIGINT NOT NULL COMMENT '毫秒',
+    UNIQUE KEY `uk_user_symbol` (`user_id`, `symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '流水归档余额检查点';
@@ -0,0 +1,158 @@
+package mtrade
+
+import "sync/atomic"
+
+// =============================================================================
+// 订单簿挂单上限
+// =============================================================================
+//
+// 【面试】恶意用户在同一价位挂几百万笔最小数量的订单会怎样？
+//   - 内存：每笔挂单常驻订单簿，直到成交或撤单
+//   - 撮合：吃单要逐笔遍历 FIFO 队列，一个档位几百万笔，单次撮合就是几百万次循环
+//   - 推送：每笔挂单都产生一条增量更新
+//
+// 防护：挂单前检查三道上限，超出直接拒绝（与 Binance MAX_NUM_ORDERS 一致，
+// 按"当前挂单数"判断，不管新订单会不会立即成交，行为可预期）
+//   - 单用户挂单数：挡住单个账户刷单
+//   - 单档位挂单数：挡住多个账户合伙堆同一价位
+//   - 全簿挂单数：最后一道内存防线
+//
+// 市价单 / IOC / FOK 不会挂单，不受限制
+
+// BookLimits 挂单上限（每个交易对一份，随 EngineConfig 配置），0 表示不限
+type BookLimits struct {
+	MaxOrdersPerUser  int // 单用户在本交易对的挂单数
+	MaxOrdersPerLevel int // 单个价格档位的挂单数
+	MaxOrders         int // 全簿挂单总数
+}
+
+// RejectReason 拒单原因
+type RejectReason uint8
+
+const (
+	RejectNone          RejectReason = iota
+	RejectPriceBand                  // 超出价格带 / 未对齐 TickSize
+	RejectUserOrderCap               // 用户挂单数达到上限
+	RejectLevelOrderCap              // 档位挂单数达到上限
+	RejectBookOrderCap               // 订单簿挂单总数达到上限
+
+	RejectDuplicateClientOrderID // clientOid 在去重窗口内重复（见 client_order.go）
+	RejectInvalidClientOrderID   // clientOid 超长
+
+	RejectMarketHalted // 暂停交易中（见 halt.go）
+
+	RejectDuplicateOrderID // 订单 ID 与挂单重复（见 id_watermark.go）
+
+	RejectInvalidQuoteQty // 按金额下单但不是市价单，或同时指定了数量（见 quote_order.go）
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectNone:
+		return "NONE"
+	case RejectPriceBand:
+		return "PRICE_BAND"
+	case RejectUserOrderCap:
+		return "USER_ORDER_CAP"
+	case RejectLevelOrderCap:
+		return "LEVEL_ORDER_CAP"
+	case RejectBookOrderCap:
+		return "BOOK_ORDER_CAP"
+	case RejectDuplicateClientOrderID:
+		return "DUPLICATE_CLIENT_ORDER_ID"
+	case RejectInvalidClientOrderID:
+		return "INVALID_CLIENT_ORDER_ID"
+	case RejectMarketHalted:
+		return "MARKET_HALTED"
+	case RejectDuplicateOrderID:
+		return "DUPLICATE_ORDER_ID"
+	case RejectInvalidQuoteQty:
+		return "INVALID_QUOTE_QTY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// BookLimitRejects 各上限触发的拒单次数
+type BookLimitRejects struct {
+	UserCap  int64
+	LevelCap int64
+	BookCap  int64
+}
+
+// bookLimiter 订单簿内的上限状态
+// 【无锁】计数只由 matchLoop 修改；拒单统计用原子变量，供外部读取
+type bookLimiter struct {
+	limits     BookLimits
+	userOrders map[int64]int // 仅在 MaxOrdersPerUser > 0 时维护
+
+	userRejects  atomic.Int64
+	levelRejects atomic.Int64
+	bookRejects  atomic.Int64
+}
+
+// SetLimits 设置挂单上限（引擎启动前调用，之后只由 matchLoop 读取）
+// 已在簿中的订单会被计入，但不会因超限被移除
+func (ob *OrderBook) SetLimits(limits BookLimits) {
+	ob.limiter.limits = limits
+	ob.limiter.userOrders = nil
+	if limits.MaxOrdersPerUser > 0 {
+		ob.limiter.userOrders = make(map[int64]int)
+		for _, o := range ob.orderIndex {
+			ob.limiter.userOrders[o.UserID]++
+		}
+	}
+}
+
+// admit 检查订单挂单后是否超限
+// 【无锁】仅由 matchLoop 调用
+func (ob *OrderBook) admit(order *Order) RejectReason {
+	l := &ob.limiter
+	if l.limits.MaxOrders > 0 && len(ob.orderIndex) >= l.limits.MaxOrders {
+		l.bookRejects.Add(1)
+		return RejectBookOrderCap
+	}
+	if l.userOrders != nil && l.userOrders[order.UserID] >= l.limits.MaxOrdersPerUser {
+		l.userRejects.Add(1)
+		return RejectUserOrderCap
+	}
+	if l.limits.MaxOrdersPerLevel > 0 {
+		if node := ob.getSideIndex(order.Side).Find(order.Price); node != nil && node.GetLevel().Len() >= l.limits.MaxOrdersPerLevel {
+			l.levelRejects.Add(1)
+			return RejectLevelOrderCap
+		}
+	}
+	return RejectNone
+}
+
+// trackAdd / trackRemove 维护用户挂单数，未启用用户上限时为空操作
+func (l *bookLimiter) trackAdd(order *Order) {
+	if l.userOrders != nil {
+		l.userOrders[order.UserID]++
+	}
+}
+
+func (l *bookLimiter) trackRemove(order *Order) {
+	if l.userOrders == nil {
+		return
+	}
+	if n := l.userOrders[order.UserID] - 1; n > 0 {
+		l.userOrders[order.UserID] = n
+	} else {
+		delete(l.userOrders, order.UserID)
+	}
+}
+
+// LimitRejects 返回各上限触发的拒单次数（可在任意 goroutine 调用）
+func (ob *OrderBook) LimitRejects() BookLimitRejects {
+	return BookLimitRejects{
+		UserCap:  ob.limiter.userRejects.Load(),
+		LevelCap: ob.limiter.levelRejects.Load(),
+		BookCap:  ob.limiter.bookRejects.Load(),
+	}
+}
+
+// restsOnBook 订单类型是否可能挂单
+func restsOnBook(t OrderType) bool {
+	return t == OrderTypeLimit || t == OrderTypeGTC || t == OrderTypePostOnly
+}
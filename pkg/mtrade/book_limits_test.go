@@ -0,0 +1,49 @@
+package mtrade
+
+import "testing"
+
+func limitOrder(id, user int64, side Side, price, qty int64) *Order {
+	return &Order{ID: id, UserID: user, Symbol: "BTC_USDT", Side: side, Type: OrderTypeLimit, Price: price, Qty: qty}
+}
+
+func TestBookLimits(t *testing.T) {
+	ob := NewOrderBook("BTC_USDT")
+	ob.SetLimits(BookLimits{MaxOrdersPerUser: 2, MaxOrdersPerLevel: 3, MaxOrders: 4})
+	m := NewMatcher(ob)
+
+	expect := func(o *Order, want RejectReason) {
+		t.Helper()
+		res := m.ProcessOrder(o)
+		if res.RejectReason != want {
+			t.Fatalf("order %d: reject reason %v, want %v", o.ID, res.RejectReason, want)
+		}
+		if (want != RejectNone) != (o.Status == OrderStatusRejected) {
+			t.Fatalf("order %d: status %v", o.ID, o.Status)
+		}
+	}
+
+	expect(limitOrder(1, 1, SideBuy, 100, 1), RejectNone)
+	expect(limitOrder(2, 1, SideBuy, 100, 1), RejectNone)
+	expect(limitOrder(3, 1, SideBuy, 99, 1), RejectUserOrderCap)
+	expect(limitOrder(4, 2, SideBuy, 100, 1), RejectNone)
+	expect(limitOrder(5, 3, SideBuy, 100, 1), RejectLevelOrderCap)
+	expect(limitOrder(6, 3, SideBuy, 98, 1), RejectNone)
+	expect(limitOrder(7, 4, SideBuy, 97, 1), RejectBookOrderCap)
+
+	// 市价单不受限
+	market := &Order{ID: 8, UserID: 1, Symbol: "BTC_USDT", Side: SideSell, Type: OrderTypeMarket, Qty: 1}
+	expect(market, RejectNone)
+	if market.FilledQty != 1 {
+		t.Fatalf("market order should fill, got %d", market.FilledQty)
+	}
+
+	// 成交 / 撤单释放名额：用户 1 的订单 1 被吃掉后可以再挂一笔
+	expect(limitOrder(9, 1, SideBuy, 99, 1), RejectNone)
+	ob.CancelOrder(9)
+	expect(limitOrder(10, 1, SideBuy, 99, 1), RejectNone)
+
+	rejects := ob.LimitRejects()
+	if rejects != (BookLimitRejects{UserCap: 1, LevelCap: 1, BookCap: 1}) {
+		t.Fatalf("unexpected reject stats: %+v", rejects)
+	}
+}
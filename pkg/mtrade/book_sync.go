@@ -0,0 +1,163 @@
+package mtrade
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// =============================================================================
+// 订单簿序列号：快照 + 增量同步
+// =============================================================================
+//
+// 【面试高频】WebSocket 客户端如何维护本地订单簿？（Binance 的做法）
+//
+//   1. 先订阅增量推送，缓存收到的更新
+//   2. 拉取深度快照，得到 snapshot.Seq
+//   3. 丢弃缓存中 Seq <= snapshot.Seq 的更新，之后的按顺序应用
+//   4. 每条更新的 Seq 必须连续（= 上一条 + 1），出现空洞说明丢了推送 → 回到第 2 步
+//
+// 为此订单簿维护一个单调递增的 seq：
+//   - 每次改变某个价格档位（挂单、成交、撤单）seq+1
+//   - 每次改变产生一条 BookUpdate（档位改变后的总量，0 表示档位删除）
+//   - 深度快照、成交事件都带上 seq，客户端可以对齐
+//
+// 【注意】增量更新是非关键事件，队列满时会被丢弃，客户端依赖 seq 空洞检测后重新拉快照
+
+// BookUpdate 价格档位增量更新
+type BookUpdate struct {
+	Seq      uint64 // 本次变更后的订单簿序列号
+	Side     Side   // 档位方向
+	Price    int64  // 价格
+	Quantity int64  // 变更后的档位总量，0 表示档位已删除
+	Orders   int    // 变更后的订单数
+}
+
+// DepthSnapshot 带序列号的深度快照
+type DepthSnapshot struct {
+	Symbol string
+	Seq    uint64 // 快照对应的订单簿序列号
+	Bids   []DepthLevel
+	Asks   []DepthLevel
+}
+
+// snapshotWaiters 等待新快照的调用方
+// 【优化】只有存在等待者时才分配 channel，撮合路径上只多一次原子读
+type snapshotWaiters struct {
+	active atomic.Bool
+	mu     sync.Mutex
+	ch     chan struct{}
+}
+
+// wait 返回一个在下次快照更新时关闭的 channel
+func (w *snapshotWaiters) wait() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ch == nil {
+		w.ch = make(chan struct{})
+	}
+	w.active.Store(true)
+	return w.ch
+}
+
+// notify 唤醒所有等待者（仅 matchLoop 调用）
+func (w *snapshotWaiters) notify() {
+	if !w.active.Load() {
+		return
+	}
+	w.mu.Lock()
+	if w.ch != nil {
+		close(w.ch)
+		w.ch = nil
+	}
+	w.active.Store(false)
+	w.mu.Unlock()
+}
+
+// =============================================================================
+// 序列号维护（仅 matchLoop 调用）
+// =============================================================================
+
+// touch 档位发生变化：seq+1，并在开启增量记录时追加一条 BookUpdate
+func (ob *OrderBook) touch(side Side, level *PriceLevel) uint64 {
+	ob.seq++
+	if ob.trackUpdates {
+		ob.updates = append(ob.updates, BookUpdate{
+			Seq:      ob.seq,
+			Side:     side,
+			Price:    level.Price,
+			Quantity: level.TotalQty,
+			Orders:   level.Len(),
+		})
+	}
+	if ob.trackQueue {
+		ob.markQueueDirty(level)
+	}
+	return ob.seq
+}
+
+// Seq 当前订单簿序列号
+// 【无锁】仅由 matchLoop 调用；外部请读 GetSnapshot().Seq
+func (ob *OrderBook) Seq() uint64 {
+	return ob.seq
+}
+
+// EnableUpdates 开启增量更新记录（引擎启用，调用方需定期 TakeUpdates 取走）
+func (ob *OrderBook) EnableUpdates() {
+	ob.trackUpdates = true
+}
+
+// TakeUpdates 取走上次调用以来的增量更新
+// 返回的切片由调用方持有，订单簿不再引用
+func (ob *OrderBook) TakeUpdates() []BookUpdate {
+	if len(ob.updates) == 0 {
+		return nil
+	}
+	out := make([]BookUpdate, len(ob.updates))
+	copy(out, ob.updates)
+	ob.updates = ob.updates[:0]
+	return out
+}
+
+// =============================================================================
+// 快照查询（线程安全）
+// =============================================================================
+
+// DepthSnapshot 获取带序列号的深度快照
+func (ob *OrderBook) DepthSnapshot(n int) DepthSnapshot {
+	return depthFromSnapshot(ob.Symbol, ob.GetSnapshot(), n)
+}
+
+// WaitDepthSnapshot 获取序列号 >= minSeq 的深度快照
+// 当前快照已满足条件时立即返回，否则等待撮合线程发布新快照，直到 ctx 结束
+//
+// 【用途】客户端收到 Seq=N 的增量后发现本地落后，请求 "至少到 N" 的快照
+func (ob *OrderBook) WaitDepthSnapshot(ctx context.Context, minSeq uint64, n int) (DepthSnapshot, error) {
+	for {
+		if snap := ob.GetSnapshot(); snap.Seq >= minSeq {
+			return depthFromSnapshot(ob.Symbol, snap, n), nil
+		}
+
+		ch := ob.waiters.wait()
+		// 登记后再检查一次，防止在登记前刚好错过更新
+		if snap := ob.GetSnapshot(); snap.Seq >= minSeq {
+			return depthFromSnapshot(ob.Symbol, snap, n), nil
+		}
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return DepthSnapshot{}, ctx.Err()
+		}
+	}
+}
+
+// depthFromSnapshot 截取快照前 n 档
+func depthFromSnapshot(symbol string, snap *OrderBookSnapshot, n int) DepthSnapshot {
+	return DepthSnapshot{
+		Symbol: symbol,
+		Seq:    snap.Seq,
+		Bids:   snap.BidDepth[:min(int64(n), int64(len(snap.BidDepth)))],
+		Asks:   snap.AskDepth[:min(int64(n), int64(len(snap.AskDepth)))],
+	}
+}
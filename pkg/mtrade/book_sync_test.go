@@ -0,0 +1,195 @@
+package mtrade
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 订单簿序列号测试
+// =============================================================================
+
+func TestOrderBook_SeqAndUpdates(t *testing.T) {
+	ob := NewOrderBook("BTC_USDT")
+	ob.EnableUpdates()
+	matcher := NewMatcher(ob)
+
+	ob.AddOrder(&Order{ID: 1, Side: SideSell, Price: 50000, Qty: 10}) // seq 1
+	ob.AddOrder(&Order{ID: 2, Side: SideSell, Price: 50000, Qty: 5})  // seq 2
+	result := matcher.ProcessOrder(&Order{ID: 3, Side: SideBuy, Price: 50000, Qty: 12, Type: OrderTypeIOC})
+	ob.CancelOrder(2) // seq 5
+
+	if len(result.Trades) != 2 || result.Trades[0].BookSeq != 3 || result.Trades[1].BookSeq != 4 {
+		t.Fatalf("unexpected trade seqs: %+v", result.Trades)
+	}
+	PutMatchResult(result)
+
+	want := []BookUpdate{
+		{Seq: 1, Side: SideSell, Price: 50000, Quantity: 10, Orders: 1},
+		{Seq: 2, Side: SideSell, Price: 50000, Quantity: 15, Orders: 2},
+		{Seq: 3, Side: SideSell, Price: 50000, Quantity: 5, Orders: 1},
+		{Seq: 4, Side: SideSell, Price: 50000, Quantity: 3, Orders: 1},
+		{Seq: 5, Side: SideSell, Price: 50000, Quantity: 0, Orders: 0},
+	}
+	got := ob.TakeUpdates()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d updates, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("update %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if ob.TakeUpdates() != nil {
+		t.Errorf("updates should be drained")
+	}
+
+	ob.UpdateSnapshot()
+	if snap := ob.DepthSnapshot(10); snap.Seq != 5 || len(snap.Asks) != 0 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
+
+// TestOrderBook_ClientSync 模拟客户端：快照 + 之后的增量，结果必须与服务端深度一致
+func TestOrderBook_ClientSync(t *testing.T) {
+	ob := NewOrderBook("BTC_USDT")
+	ob.EnableUpdates()
+	matcher := NewMatcher(ob)
+	r := rand.New(rand.NewSource(7))
+
+	nextID := int64(1)
+	step := func() {
+		side := SideBuy
+		price := int64(50000 - r.Intn(20))
+		if r.Intn(2) == 0 {
+			side, price = SideSell, int64(49990+r.Intn(20))
+		}
+		if r.Intn(4) == 0 && nextID > 1 {
+			ob.CancelOrder(r.Int63n(nextID) + 1)
+		} else {
+			PutMatchResult(matcher.ProcessOrder(&Order{ID: nextID, Side: side, Price: price, Qty: 1 + r.Int63n(10)}))
+			nextID++
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		step()
+	}
+	ob.UpdateSnapshot()
+	snap := ob.DepthSnapshot(100)
+	ob.TakeUpdates() // 快照之前的增量客户端不需要
+
+	type key struct {
+		side  Side
+		price int64
+	}
+	local := make(map[key]int64)
+	for _, l := range snap.Bids {
+		local[key{SideBuy, l.Price}] = l.Quantity
+	}
+	for _, l := range snap.Asks {
+		local[key{SideSell, l.Price}] = l.Quantity
+	}
+
+	last := snap.Seq
+	for i := 0; i < 500; i++ {
+		step()
+		for _, u := range ob.TakeUpdates() {
+			if u.Seq != last+1 {
+				t.Fatalf("gap in updates: %d after %d", u.Seq, last)
+			}
+			last = u.Seq
+			if u.Quantity == 0 {
+				delete(local, key{u.Side, u.Price})
+			} else {
+				local[key{u.Side, u.Price}] = u.Quantity
+			}
+		}
+	}
+
+	ob.UpdateSnapshot()
+	final := ob.DepthSnapshot(100)
+	if final.Seq != last {
+		t.Fatalf("snapshot seq %d != last update %d", final.Seq, last)
+	}
+	if len(local) != len(final.Bids)+len(final.Asks) {
+		t.Fatalf("local book has %d levels, server %d", len(local), len(final.Bids)+len(final.Asks))
+	}
+	for _, l := range final.Bids {
+		if local[key{SideBuy, l.Price}] != l.Quantity {
+			t.Errorf("bid %d: local %d, server %d", l.Price, local[key{SideBuy, l.Price}], l.Quantity)
+		}
+	}
+	for _, l := range final.Asks {
+		if local[key{SideSell, l.Price}] != l.Quantity {
+			t.Errorf("ask %d: local %d, server %d", l.Price, local[key{SideSell, l.Price}], l.Quantity)
+		}
+	}
+}
+
+func TestEngine_SeqInEventsAndWaitSnapshot(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+
+	var (
+		mu       sync.Mutex
+		tradeSeq uint64
+		updates  []BookUpdate
+	)
+	engine.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Type {
+		case EventTrade:
+			tradeSeq = e.Seq
+		case EventBookUpdate:
+			updates = append(updates, e.Updates...)
+		}
+	})
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	engine.SubmitOrder(&Order{ID: 1, Side: SideSell, Price: 50000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	engine.SubmitOrder(&Order{ID: 2, Side: SideBuy, Price: 50000, Qty: 4, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	snap, err := engine.WaitDepthSnapshot(ctx, 2, 5)
+	if err != nil {
+		t.Fatalf("wait snapshot: %v", err)
+	}
+	if snap.Seq != 2 || len(snap.Asks) != 1 || snap.Asks[0].Quantity != 6 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+
+	// 撤单也会推进序列号并刷新快照
+	engine.CancelOrder(1)
+	if snap, err = engine.WaitDepthSnapshot(ctx, 3, 5); err != nil || len(snap.Asks) != 0 {
+		t.Fatalf("expected empty asks at seq 3, got %+v (err=%v)", snap, err)
+	}
+
+	// 永远到不了的序列号：等到超时
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	if _, err := engine.WaitDepthSnapshot(short, 100, 5); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	waitFor(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(updates) == 3
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if tradeSeq != 2 {
+		t.Errorf("expected trade seq 2, got %d", tradeSeq)
+	}
+	for i, u := range updates {
+		if u.Seq != uint64(i+1) {
+			t.Errorf("update %d has seq %d", i, u.Seq)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package mtrade
+
+import (
+	"context"
+	"runtime"
+
+	"max.com/pkg/affinity"
+)
+
+// =============================================================================
+// 忙轮询 (busy-poll) 模式
+// =============================================================================
+//
+// 【面试高频】为什么 select 阻塞会带来延迟？
+//   matchLoop 空闲时阻塞在 select 上，goroutine 被挂起（park）。
+//   新订单到达时要经过：channel send → goready → 调度器找 P → 线程可能还在 futex 上睡眠
+//   这一串唤醒在空闲的机器上通常是几微秒到几十微秒，p99 更差。
+//
+// 忙轮询：空闲时不睡，原地反复检查队列，订单一到立刻处理。
+//   代价：空闲时也占满一个核。所以只在延迟敏感的部署中打开，并且：
+//   - SpinBudget 限制连续空转次数，用完后退回阻塞等待（低峰期不烧 CPU）
+//   - 撮合线程 LockOSThread，可选绑核（MatchLoopCPUs），避免被迁移到其他核导致缓存失效
+//
+// 【注意】
+//   - GOMAXPROCS < 2 时自旋只会抢生产者的 CPU，没有意义；这里每 spinYieldInterval 次
+//     让出一次，保证单核环境下也不会饿死生产者
+//   - 绑核只是提示：非 Linux 平台或 CPU 编号非法时忽略，见 Engine.MatchLoopPlacement
+
+// WaitStrategy matchLoop 空闲时的等待策略
+type WaitStrategy int
+
+const (
+	WaitBlocking WaitStrategy = iota // select 阻塞（默认），空闲零 CPU
+	WaitBusyPoll                     // 先自旋 SpinBudget 次，仍无订单再阻塞
+)
+
+func (w WaitStrategy) String() string {
+	if w == WaitBusyPoll {
+		return "BUSY_POLL"
+	}
+	return "BLOCKING"
+}
+
+const (
+	// DefaultSpinBudget 默认连续空转次数（约几十到几百微秒，视 CPU 而定）
+	DefaultSpinBudget = 1 << 14
+
+	// spinYieldInterval 每自旋多少次让出一次 CPU
+	spinYieldInterval = 64
+)
+
+// ErrAffinityUnsupported 当前平台不支持绑核
+var ErrAffinityUnsupported = affinity.ErrUnsupported
+
+// MatchLoopPlacement 撮合线程的放置情况
+type MatchLoopPlacement struct {
+	Strategy     WaitStrategy
+	SpinBudget   int
+	LockedThread bool  // 是否 LockOSThread
+	CPUs         []int // 请求绑定的 CPU
+	Pinned       bool  // 绑核是否成功
+	PinError     error // 绑核失败原因
+}
+
+// idleSpinner 空闲自旋计数（仅 matchLoop 使用）
+type idleSpinner struct {
+	budget int
+	n      int
+}
+
+// spin 空转一次；返回 false 表示预算用完，调用方应当阻塞等待
+func (s *idleSpinner) spin() bool {
+	if s.n >= s.budget {
+		return false
+	}
+	s.n++
+	if s.n%spinYieldInterval == 0 {
+		runtime.Gosched()
+	}
+	return true
+}
+
+func (s *idleSpinner) reset() {
+	s.n = 0
+}
+
+// =============================================================================
+// 线程放置
+// =============================================================================
+
+// placeMatchLoop 在撮合 goroutine 内调用：锁定线程并按配置绑核
+// 返回的函数在 matchLoop 退出时调用
+func (e *Engine) placeMatchLoop() func() {
+	cfg := e.config
+	placement := MatchLoopPlacement{
+		Strategy:   cfg.WaitStrategy,
+		SpinBudget: e.spinBudget(),
+		CPUs:       cfg.MatchLoopCPUs,
+	}
+
+	if cfg.WaitStrategy != WaitBusyPoll && len(cfg.MatchLoopCPUs) == 0 {
+		e.placement.Store(&placement)
+		return func() {}
+	}
+
+	placement.LockedThread = true
+	placement.PinError = affinity.Pin(cfg.MatchLoopCPUs)
+	placement.Pinned = len(cfg.MatchLoopCPUs) > 0 && placement.PinError == nil
+	e.placement.Store(&placement)
+
+	// 【注意】绑过核的线程不还给调度器，goroutine 退出时线程随之销毁
+	return func() {
+		if !placement.Pinned {
+			runtime.UnlockOSThread()
+		}
+	}
+}
+
+// spinBudget 生效的自旋预算（阻塞模式为 0）
+func (e *Engine) spinBudget() int {
+	if e.config.WaitStrategy != WaitBusyPoll {
+		return 0
+	}
+	if e.config.SpinBudget <= 0 {
+		return DefaultSpinBudget
+	}
+	return e.config.SpinBudget
+}
+
+// MatchLoopPlacement 撮合线程放置情况（Start 之后可用）
+func (e *Engine) MatchLoopPlacement() MatchLoopPlacement {
+	if p := e.placement.Load(); p != nil {
+		return *p
+	}
+	return MatchLoopPlacement{Strategy: e.config.WaitStrategy, SpinBudget: e.spinBudget()}
+}
+
+// =============================================================================
+// channel 模式的忙轮询撮合循环
+// =============================================================================
+
+// busyPollMatchLoop 与 matchLoop 处理逻辑相同，只是空闲时先自旋
+func (e *Engine) busyPollMatchLoop(ctx context.Context) {
+	defer e.wg.Done()
+	defer e.placeMatchLoop()()
+
+	spinner := idleSpinner{budget: e.spinBudget()}
+	for {
+		select {
+		case order := <-e.orderCh:
+			e.processOrder(order)
+			spinner.reset()
+			continue
+		case req := <-e.cancelCh:
+			e.processCancel(req)
+			spinner.reset()
+			continue
+		default:
+		}
+
+		if spinner.spin() {
+			// 自旋期间低频检查退出信号
+			if spinner.n%spinYieldInterval == 0 && e.stopping(ctx) {
+				return
+			}
+			continue
+		}
+
+		// 预算用完：退回阻塞等待
+		e.spinParks.Add(1)
+		spinner.reset()
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopCh:
+			return
+		case order := <-e.orderCh:
+			e.processOrder(order)
+		case req := <-e.cancelCh:
+			e.processCancel(req)
+		}
+	}
+}
+
+// stopping 是否收到退出信号（非阻塞）
+func (e *Engine) stopping(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-e.stopCh:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,162 @@
+package mtrade
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 忙轮询模式测试
+// =============================================================================
+
+func busyPollConfig(intake IntakeMode) EngineConfig {
+	cfg := DefaultEngineConfig("BTC_USDT")
+	cfg.IntakeMode = intake
+	cfg.WaitStrategy = WaitBusyPoll
+	cfg.SpinBudget = 256
+	return cfg
+}
+
+func TestEngine_BusyPoll(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		intake IntakeMode
+	}{
+		{"Channel", IntakeChannel},
+		{"RingBuffer", IntakeRingBuffer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := mustNewEngine(t, busyPollConfig(tc.intake))
+
+			var trades atomic.Int64
+			engine.OnEvent(func(e Event) {
+				if e.Type == EventTrade {
+					trades.Add(1)
+				}
+			})
+			engine.Start(context.Background())
+
+			const pairs = 100
+			submitCrossingPairs(t, engine, pairs)
+			if !waitFor(t, time.Second, func() bool { return trades.Load() == pairs }) {
+				t.Fatalf("expected %d trades, got %d", pairs, trades.Load())
+			}
+
+			// 空闲后自旋预算用完，退回阻塞
+			if !waitFor(t, time.Second, func() bool { return engine.GetStats().SpinParks > 0 }) {
+				t.Errorf("expected match loop to park after spin budget")
+			}
+
+			// 阻塞后仍能被新订单唤醒
+			submitCrossingPairs(t, engine, 1)
+			if !waitFor(t, time.Second, func() bool { return trades.Load() == pairs+1 }) {
+				t.Errorf("match loop did not wake up after parking")
+			}
+
+			p := engine.MatchLoopPlacement()
+			if p.Strategy != WaitBusyPoll || p.SpinBudget != 256 || !p.LockedThread {
+				t.Errorf("unexpected placement %+v", p)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				engine.Stop()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Stop did not return while busy polling")
+			}
+		})
+	}
+}
+
+func TestEngine_MatchLoopPinning(t *testing.T) {
+	cfg := busyPollConfig(IntakeChannel)
+	cfg.MatchLoopCPUs = []int{0}
+	engine := mustNewEngine(t, cfg)
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	var p MatchLoopPlacement
+	waitFor(t, time.Second, func() bool {
+		p = engine.MatchLoopPlacement()
+		return p.LockedThread
+	})
+	if !p.LockedThread {
+		t.Fatalf("match loop thread not locked: %+v", p)
+	}
+	if runtime.GOOS == "linux" && !p.Pinned {
+		t.Errorf("expected pinned to cpu 0 on linux, got %+v", p)
+	}
+	if runtime.GOOS != "linux" && p.PinError != ErrAffinityUnsupported {
+		t.Errorf("expected unsupported affinity error, got %+v", p)
+	}
+}
+
+// =============================================================================
+// 提交到成交的延迟
+// =============================================================================
+//
+// 逐笔提交 taker，等成交事件回来再发下一笔，matchLoop 每次都从空闲状态被唤醒，
+// 正好衡量唤醒延迟。延迟 = 成交时间戳 - 提交时间戳（不含事件分发）。
+//
+//   go test ./pkg/mtrade -run=^$ -bench=SubmitToTrade -benchtime=20000x
+//
+// 【注意】GOMAXPROCS=1 时忙轮询没有收益（自旋会抢生产者的 CPU），需要多核环境对比
+
+func benchmarkSubmitToTrade(b *testing.B, cfg EngineConfig) {
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	matchedAt := make(chan int64, 1)
+	engine.OnEvent(func(e Event) {
+		if e.Type == EventTrade {
+			matchedAt <- e.Trade.Timestamp
+		}
+	})
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	engine.SubmitOrder(&Order{ID: 1, Side: SideSell, Price: 50000, Qty: 1 << 40, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+
+	h := NewLatencyHistogram()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		taker := &Order{ID: int64(i + 2), Side: SideBuy, Price: 50000, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit}
+		submitted := time.Now().UnixNano()
+		for !engine.SubmitOrder(taker) {
+			runtime.Gosched()
+		}
+		h.Record(time.Duration(<-matchedAt - submitted))
+	}
+	b.StopTimer()
+	reportLatency(b, h)
+}
+
+func BenchmarkSubmitToTrade_Channel_Blocking(b *testing.B) {
+	benchmarkSubmitToTrade(b, DefaultEngineConfig("BTC_USDT"))
+}
+
+func BenchmarkSubmitToTrade_Channel_BusyPoll(b *testing.B) {
+	cfg := busyPollConfig(IntakeChannel)
+	cfg.SpinBudget = DefaultSpinBudget
+	benchmarkSubmitToTrade(b, cfg)
+}
+
+func BenchmarkSubmitToTrade_Ring_Blocking(b *testing.B) {
+	cfg := DefaultEngineConfig("BTC_USDT")
+	cfg.IntakeMode = IntakeRingBuffer
+	benchmarkSubmitToTrade(b, cfg)
+}
+
+func BenchmarkSubmitToTrade_Ring_BusyPoll(b *testing.B) {
+	cfg := busyPollConfig(IntakeRingBuffer)
+	cfg.SpinBudget = DefaultSpinBudget
+	benchmarkSubmitToTrade(b, cfg)
+}
@@ -0,0 +1,109 @@
+package mtrade
+
+import (
+	"context"
+	"slices"
+)
+
+// =============================================================================
+// 按用户全撤 (CancelAllByUser)
+// =============================================================================
+//
+// 【场景】强平前先撤掉用户的挂单：挂单占着保证金，成交后还会把仓位加回去；
+// 紧急停机 (pkg/emergency) 时撤掉整个订单簿 (CancelAll)
+//
+// 【做法】和单笔撤单走同一个撤单队列，由 matchLoop 一次处理完：
+//   1. matchLoop 从订单索引里找出该用户的全部挂单，按订单 ID 排序逐笔撤销
+//      （每笔照常写 WAL、发 EventOrderCanceled，重放结果与逐笔撤单一致）
+//   2. 全部撤完后把撤掉的订单 ID 写回执通道（容量 1，非阻塞写，调用方放弃等待不会卡住撮合）
+//
+// 【注意】
+//   - 回执只表示订单已离开订单簿：撤单事件由 handler 异步处理，保证金解冻要等 handler
+//   - 只撤已经在簿上的订单；回执之后才入队的新订单不受影响
+
+// cancelRequest 撤单队列元素
+type cancelRequest struct {
+	orderID int64
+	userID  int64        // ack 非 nil 时为按用户全撤
+	all     bool         // 撤掉所有用户的挂单 (忽略 userID)
+	ack     chan []int64 // 全撤回执：撤掉的订单 ID
+}
+
+// CancelAllByUser 撤销用户在本订单簿上的全部挂单，返回撤掉的订单 ID (按 ID 升序)
+//
+// 返回 error 时：ErrQueueFull / ErrEngineFrozen 表示未入队；ctx 错误或 ErrEngineStopped 表示已入队但没等到回执
+func (e *Engine) CancelAllByUser(ctx context.Context, userID int64) ([]int64, error) {
+	return e.cancelAll(ctx, cancelRequest{userID: userID})
+}
+
+// CancelAll 撤销订单簿上所有用户的全部挂单，返回值与错误同 CancelAllByUser
+func (e *Engine) CancelAll(ctx context.Context) ([]int64, error) {
+	return e.cancelAll(ctx, cancelRequest{all: true})
+}
+
+func (e *Engine) cancelAll(ctx context.Context, req cancelRequest) ([]int64, error) {
+	if !e.enterIntake() {
+		return nil, ErrEngineFrozen
+	}
+	ack := make(chan []int64, 1)
+	req.ack = ack
+	select {
+	case e.cancelCh <- req:
+	default:
+		e.inflight.Add(-1)
+		return nil, ErrQueueFull
+	}
+
+	select {
+	case ids := <-ack:
+		return ids, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-e.stopCh:
+		select {
+		case ids := <-ack:
+			return ids, nil
+		default:
+			return nil, ErrEngineStopped
+		}
+	}
+}
+
+// processCancel 处理撤单队列元素
+func (e *Engine) processCancel(req cancelRequest) {
+	if req.ack == nil {
+		e.processCancelOrder(req.orderID)
+		return
+	}
+	e.processCancelAll(req)
+}
+
+// processCancelAll 撤掉用户 (或所有用户) 的全部挂单（仅 matchLoop 调用）
+func (e *Engine) processCancelAll(req cancelRequest) {
+	defer e.inflight.Add(-1)
+
+	var ids []int64
+	for id, order := range e.orderBook.orderIndex {
+		if req.all || order.UserID == req.userID {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids) // map 遍历无序，排序后 WAL 顺序确定
+
+	canceled := ids[:0]
+	for _, id := range ids {
+		if e.cancelResting(id) {
+			canceled = append(canceled, id)
+		}
+	}
+	if len(canceled) > 0 {
+		e.publishBookUpdates()
+		e.publishQueueUpdates()
+		e.orderBook.UpdateSnapshot()
+	}
+
+	select {
+	case req.ack <- canceled:
+	default:
+	}
+}
@@ -0,0 +1,93 @@
+package mtrade
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEngine_CancelAllByUser(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	var canceledEvents atomic.Int64
+	engine.OnEvent(func(e Event) {
+		if e.Type == EventOrderCanceled && e.Order.UserID == 7 {
+			canceledEvents.Add(1)
+		}
+	})
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	ctx := context.Background()
+	for _, o := range []*Order{
+		{ID: 30, UserID: 7, Side: SideBuy, Price: 90, Qty: 1},
+		{ID: 10, UserID: 7, Side: SideBuy, Price: 95, Qty: 1},
+		{ID: 20, UserID: 7, Side: SideSell, Price: 110, Qty: 1},
+		{ID: 40, UserID: 8, Side: SideSell, Price: 120, Qty: 1},
+	} {
+		o.Symbol, o.Type = "BTC_USDT", OrderTypeLimit
+		if _, err := engine.SubmitOrderSync(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, err := engine.CancelAllByUser(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids, []int64{10, 20, 30}) {
+		t.Fatalf("canceled %v", ids)
+	}
+	// 回执时订单已离开订单簿，其他用户的挂单不受影响
+	if snap := engine.GetOrderBook().GetSnapshot(); snap.Orders != 1 || snap.BestBid != 0 || snap.BestAsk != 120 {
+		t.Fatalf("snapshot %+v", snap)
+	}
+	if !waitFor(t, time.Second, func() bool { return canceledEvents.Load() == 3 }) {
+		t.Fatalf("cancel events = %d", canceledEvents.Load())
+	}
+
+	// 没有挂单：空回执
+	ids, err = engine.CancelAllByUser(ctx, 7)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("second cancel-all: %v %v", ids, err)
+	}
+}
+
+func TestEngine_CancelAllByUserFrozen(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	if err := engine.Freeze(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.CancelAllByUser(context.Background(), 7); err != ErrEngineFrozen {
+		t.Fatalf("err = %v, want ErrEngineFrozen", err)
+	}
+}
+
+func TestEngine_CancelAll(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	ctx := context.Background()
+	for _, o := range []*Order{
+		{ID: 2, UserID: 7, Side: SideBuy, Price: 95, Qty: 1},
+		{ID: 1, UserID: 8, Side: SideSell, Price: 110, Qty: 1},
+	} {
+		o.Symbol, o.Type = "BTC_USDT", OrderTypeLimit
+		if _, err := engine.SubmitOrderSync(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, err := engine.CancelAll(ctx)
+	if err != nil || !slices.Equal(ids, []int64{1, 2}) {
+		t.Fatalf("CancelAll = %v, %v", ids, err)
+	}
+	if snap := engine.GetOrderBook().GetSnapshot(); snap.Orders != 0 {
+		t.Fatalf("snapshot %+v", snap)
+	}
+}
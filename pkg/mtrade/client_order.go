@@ -0,0 +1,159 @@
+package mtrade
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// 客户端订单号 (clientOid) 防重放
+// =============================================================================
+//
+// 【面试】客户端下单超时后重试，第一次请求其实已经到了撮合，会不会下出两笔单？
+//   - 订单 ID 由服务端生成，两次请求拿到两个 ID，撮合无从判断是不是同一笔
+//   - 让客户端自带一个订单号 (clientOid)，同一用户在窗口内不能重复，重试的那笔直接拒掉
+//
+// 【做法】撮合线程在写 WAL 之前检查 (UserID, ClientOrderID)：
+//   - 已存在：拒单 RejectDuplicateClientOrderID，不写 WAL、不进撮合
+//   - 不存在：登记 clientOid → 订单 ID，之后照常撮合（拒单也占用，和 Binance 一致）
+//
+// 【窗口】登记在下单 ClientOrderIDWindow 之后过期，但仍挂在簿上的订单顺延，
+// 保证挂单期间 clientOid 始终唯一、可按 clientOid 查询/撤单
+//
+// 【恢复】被拒的重复单不写 WAL，重放 WAL 时按相同规则登记即可重建索引；
+// 过期按订单 CreatedAt 推进，重放结果与原来一致
+//
+// 【并发】只有 matchLoop 写入；查询接口在任意 goroutine 调用，用读写锁保护。
+// 不带 clientOid 的订单不加锁，对撮合热路径没有影响
+
+// MaxClientOrderIDLen clientOid 最大长度（WAL 中用 1 字节记录长度）
+const MaxClientOrderIDLen = 64
+
+// defaultClientOrderIDWindow clientOid 默认去重窗口
+const defaultClientOrderIDWindow = 10 * time.Minute
+
+type clientOrderKey struct {
+	userID int64
+	id     string
+}
+
+type clientOrderEntry struct {
+	key     clientOrderKey
+	orderID int64
+	at      int64 // 登记时间（订单 CreatedAt，纳秒）
+}
+
+// clientOrderIndex clientOid → 订单 ID
+type clientOrderIndex struct {
+	window int64 // 纳秒
+
+	mu  sync.RWMutex
+	ids map[clientOrderKey]clientOrderEntry
+
+	// fifo 按登记时间排列，用于滚动过期（只由 matchLoop 访问）
+	fifo []clientOrderEntry
+	head int
+
+	duplicates atomic.Int64
+}
+
+func newClientOrderIndex(window time.Duration) *clientOrderIndex {
+	if window <= 0 {
+		window = defaultClientOrderIDWindow
+	}
+	return &clientOrderIndex{
+		window: int64(window),
+		ids:    make(map[clientOrderKey]clientOrderEntry),
+	}
+}
+
+// claim 登记订单的 clientOid，返回拒单原因
+// 【无锁写】仅由 matchLoop / 恢复流程调用；resting 判断订单是否仍挂在簿上
+func (x *clientOrderIndex) claim(order *Order, resting func(int64) bool) RejectReason {
+	if order.ClientOrderID == "" {
+		return RejectNone
+	}
+	if len(order.ClientOrderID) > MaxClientOrderIDLen {
+		return RejectInvalidClientOrderID
+	}
+	x.expire(order.CreatedAt, resting)
+
+	key := clientOrderKey{userID: order.UserID, id: order.ClientOrderID}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.ids[key]; ok {
+		x.duplicates.Add(1)
+		return RejectDuplicateClientOrderID
+	}
+	entry := clientOrderEntry{key: key, orderID: order.ID, at: order.CreatedAt}
+	x.ids[key] = entry
+	x.fifo = append(x.fifo, entry)
+	return RejectNone
+}
+
+// expire 清理 now 之前窗口外的登记；仍挂单的顺延到队尾
+func (x *clientOrderIndex) expire(now int64, resting func(int64) bool) {
+	cutoff := now - x.window
+	n := len(x.fifo) - x.head // 每个条目本轮最多检查一次，顺延的不会被重复处理
+	for ; n > 0 && x.fifo[x.head].at <= cutoff; n-- {
+		entry := x.fifo[x.head]
+		x.fifo[x.head] = clientOrderEntry{}
+		x.head++
+		if resting(entry.orderID) {
+			entry.at = now
+			x.fifo = append(x.fifo, entry)
+			continue
+		}
+		x.mu.Lock()
+		delete(x.ids, entry.key)
+		x.mu.Unlock()
+	}
+	// 已消费的前半段超过一半时压缩，避免切片无限增长
+	if x.head > 0 && x.head*2 >= len(x.fifo) {
+		x.fifo = append(x.fifo[:0], x.fifo[x.head:]...)
+		x.head = 0
+	}
+}
+
+// lookup 按 clientOid 查订单 ID（可在任意 goroutine 调用）
+func (x *clientOrderIndex) lookup(userID int64, clientOrderID string) (int64, bool) {
+	x.mu.RLock()
+	entry, ok := x.ids[clientOrderKey{userID: userID, id: clientOrderID}]
+	x.mu.RUnlock()
+	return entry.orderID, ok
+}
+
+// =============================================================================
+// 对外接口
+// =============================================================================
+
+// LookupClientOrder 按 (用户, clientOid) 查找订单 ID
+//
+// 只反映 matchLoop 已处理的订单：刚提交、尚在队列中的订单查不到。
+// 窗口过期且已不在簿上的订单同样查不到
+func (e *Engine) LookupClientOrder(userID int64, clientOrderID string) (int64, bool) {
+	if clientOrderID == "" {
+		return 0, false
+	}
+	return e.clientOrders.lookup(userID, clientOrderID)
+}
+
+// CancelByClientOrderID 按 clientOid 撤单，找不到订单或撤单队列满时返回 false
+func (e *Engine) CancelByClientOrderID(userID int64, clientOrderID string) bool {
+	orderID, ok := e.LookupClientOrder(userID, clientOrderID)
+	if !ok {
+		return false
+	}
+	return e.CancelOrder(orderID)
+}
+
+// DuplicateClientOrders 因 clientOid 重复被拒的订单数（可在任意 goroutine 调用）
+func (e *Engine) DuplicateClientOrders() int64 {
+	return e.clientOrders.duplicates.Load()
+}
+
+// isResting 订单是否仍挂在簿上（仅 matchLoop 调用）
+func (e *Engine) isResting(orderID int64) bool {
+	return e.orderBook.GetOrder(orderID) != nil
+}
@@ -0,0 +1,109 @@
+package mtrade
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_ClientOrderID(t *testing.T) {
+	cfg := DefaultEngineConfig("BTC_USDT")
+	cfg.WALDir = t.TempDir()
+	engine := mustNewEngine(t, cfg)
+	engine.Start(context.Background())
+
+	ctx := context.Background()
+	ask := &Order{ID: 1, UserID: 7, Side: SideSell, Price: 100, Qty: 5, Symbol: "BTC_USDT", Type: OrderTypeLimit, ClientOrderID: "sell-1"}
+	if _, err := engine.SubmitOrderSync(ctx, ask); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超时重试：同一用户同一 clientOid 的第二笔被拒
+	retry := &Order{ID: 2, UserID: 7, Side: SideSell, Price: 100, Qty: 5, Symbol: "BTC_USDT", Type: OrderTypeLimit, ClientOrderID: "sell-1"}
+	ack, err := engine.SubmitOrderSync(ctx, retry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Accepted() || ack.RejectReason != RejectDuplicateClientOrderID || ack.ClientOrderID != "sell-1" {
+		t.Fatalf("retry ack %+v", ack)
+	}
+	if engine.DuplicateClientOrders() != 1 {
+		t.Fatalf("duplicates = %d", engine.DuplicateClientOrders())
+	}
+
+	// 其他用户可以用同一个 clientOid；成交带上双方的 clientOid
+	bid := &Order{ID: 3, UserID: 8, Side: SideBuy, Price: 100, Qty: 2, Symbol: "BTC_USDT", Type: OrderTypeLimit, ClientOrderID: "sell-1"}
+	ack, err = engine.SubmitOrderSync(ctx, bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ack.Trades) != 1 || ack.Trades[0].MakerClientOrderID != "sell-1" || ack.Trades[0].TakerClientOrderID != "sell-1" {
+		t.Fatalf("trades %+v", ack.Trades)
+	}
+
+	if id, ok := engine.LookupClientOrder(7, "sell-1"); !ok || id != 1 {
+		t.Fatalf("lookup = %d, %v", id, ok)
+	}
+	if _, ok := engine.LookupClientOrder(9, "sell-1"); ok {
+		t.Fatal("lookup matched another user's clientOid")
+	}
+	if !engine.CancelByClientOrderID(7, "sell-1") {
+		t.Fatal("cancel by clientOid failed")
+	}
+	if !waitFor(t, time.Second, func() bool { return engine.inflight.Load() == 0 && engine.orderBook.GetSnapshot().Orders == 0 }) {
+		t.Fatal("order not canceled")
+	}
+
+	tooLong := &Order{ID: 4, UserID: 7, Side: SideBuy, Price: 90, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit, ClientOrderID: strings.Repeat("x", MaxClientOrderIDLen+1)}
+	if ack, _ := engine.SubmitOrderSync(ctx, tooLong); ack.RejectReason != RejectInvalidClientOrderID {
+		t.Fatalf("too long clientOid ack %+v", ack)
+	}
+	engine.Stop()
+
+	// 重启后从 WAL 重建索引，重复单仍被拒
+	restarted := mustNewEngine(t, cfg)
+	restarted.Start(context.Background())
+	defer restarted.Stop()
+	if id, ok := restarted.LookupClientOrder(8, "sell-1"); !ok || id != 3 {
+		t.Fatalf("lookup after restart = %d, %v", id, ok)
+	}
+	ack, err = restarted.SubmitOrderSync(ctx, &Order{ID: 5, UserID: 7, Side: SideSell, Price: 100, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit, ClientOrderID: "sell-1"})
+	if err != nil || ack.RejectReason != RejectDuplicateClientOrderID {
+		t.Fatalf("duplicate after restart: %+v, %v", ack, err)
+	}
+}
+
+func TestClientOrderIndex_Window(t *testing.T) {
+	x := newClientOrderIndex(time.Minute)
+	resting := map[int64]bool{1: true}
+	isResting := func(id int64) bool { return resting[id] }
+
+	at := func(d time.Duration) int64 { return int64(d) }
+	claim := func(id, user int64, cid string, ts time.Duration) RejectReason {
+		return x.claim(&Order{ID: id, UserID: user, ClientOrderID: cid, CreatedAt: at(ts)}, isResting)
+	}
+
+	claim(1, 1, "open", 0)
+	claim(2, 1, "done", 0)
+	if r := claim(3, 1, "done", 30*time.Second); r != RejectDuplicateClientOrderID {
+		t.Fatalf("within window: %v", r)
+	}
+
+	// 窗口过后：已结束的订单释放 clientOid，仍挂单的继续占用
+	if r := claim(4, 1, "done", 2*time.Minute); r != RejectNone {
+		t.Fatalf("after window: %v", r)
+	}
+	if r := claim(5, 1, "open", 2*time.Minute); r != RejectDuplicateClientOrderID {
+		t.Fatalf("resting order clientOid reused: %v", r)
+	}
+	if id, _ := x.lookup(1, "done"); id != 4 {
+		t.Fatalf("lookup = %d, want 4", id)
+	}
+
+	// 挂单结束后下一个窗口释放
+	delete(resting, 1)
+	if r := claim(6, 1, "open", 4*time.Minute); r != RejectNone {
+		t.Fatalf("after resting order closed: %v", r)
+	}
+}
@@ -0,0 +1,81 @@
+package mtrade
+
+// =============================================================================
+// 深度聚合
+// =============================================================================
+//
+// 【面试】盘口为什么要按价格步长聚合？
+// BTC_USDT 的 tick 是 0.01，最优价附近几十档加起来可能只覆盖几美元，
+// 前端想看"每 10 美元一档"的大盘分布，就要把相邻 tick 合并到同一个桶里。
+//
+// 桶的取整方向：
+//   - 买盘向下取整：100.7 归入 100 档（"至少能以 100 卖出这么多"）
+//   - 卖盘向上取整：100.3 归入 101 档（"最多花 101 能买到这么多"）
+//   这样聚合后买一仍然低于卖一，盘口不会交叉
+//
+// 聚合在读取侧完成：从无锁快照读原始档位，单遍合并，撮合线程不做任何额外工作。
+// 原始档位按价格优先排列，同一个桶的档位必然相邻，所以一次遍历即可。
+//
+// 【注意】聚合只能覆盖快照里的档位（EngineConfig.SnapshotDepth），
+// 快照被截断时，最后一个桶的数量可能偏小
+
+const defaultSnapshotDepth = 20
+
+// SetSnapshotDepth 设置快照每侧保留的档位数，<=0 使用默认值 20
+// 【无锁】引擎启动前调用；调大会增加撮合线程每次刷新快照的拷贝量
+func (ob *OrderBook) SetSnapshotDepth(n int) {
+	ob.snapshotDepth = n
+}
+
+func (ob *OrderBook) snapshotLevels() int {
+	if ob.snapshotDepth <= 0 {
+		return defaultSnapshotDepth
+	}
+	return ob.snapshotDepth
+}
+
+// AggregatedDepth 按价格步长聚合的深度快照，每侧最多 n 档
+// step <= 1 时等同于 DepthSnapshot(n)
+// 【线程安全】可从任意 goroutine 调用
+func (ob *OrderBook) AggregatedDepth(n int, step int64) DepthSnapshot {
+	snap := ob.GetSnapshot()
+	n = max(n, 0)
+	if step <= 1 {
+		return depthFromSnapshot(ob.Symbol, snap, n)
+	}
+	return DepthSnapshot{
+		Symbol: ob.Symbol,
+		Seq:    snap.Seq,
+		Bids:   AppendAggregated(make([]DepthLevel, 0, min(int64(n), int64(len(snap.BidDepth)))), snap.BidDepth, SideBuy, step, n),
+		Asks:   AppendAggregated(make([]DepthLevel, 0, min(int64(n), int64(len(snap.AskDepth)))), snap.AskDepth, SideSell, step, n),
+	}
+}
+
+// AppendAggregated 把一侧的原始档位（价格优先排列）按 step 聚合后追加到 dst，最多 n 档
+//
+// 不分配内存（dst 容量足够时），调用方可复用 dst 做高频推送
+func AppendAggregated(dst []DepthLevel, levels []DepthLevel, side Side, step int64, n int) []DepthLevel {
+	start := len(dst)
+	for _, lv := range levels {
+		bucket := bucketPrice(lv.Price, side, step)
+		if last := len(dst) - 1; last >= start && dst[last].Price == bucket {
+			dst[last].Quantity += lv.Quantity
+			dst[last].Orders += lv.Orders
+			continue
+		}
+		if len(dst)-start >= n {
+			break
+		}
+		dst = append(dst, DepthLevel{Price: bucket, Quantity: lv.Quantity, Orders: lv.Orders})
+	}
+	return dst
+}
+
+// bucketPrice 买盘向下取整，卖盘向上取整到 step 的整数倍
+func bucketPrice(price int64, side Side, step int64) int64 {
+	floor := price / step * step
+	if side == SideSell && floor != price {
+		return floor + step
+	}
+	return floor
+}
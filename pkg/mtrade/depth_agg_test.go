@@ -0,0 +1,51 @@
+package mtrade
+
+import "testing"
+
+func TestAggregatedDepth(t *testing.T) {
+	ob := NewOrderBook("BTC_USDT")
+	ob.SetSnapshotDepth(100)
+	for i, p := range []int64{1007, 1003, 1000, 995, 990, 981} {
+		ob.AddOrder(&Order{ID: int64(i + 1), Side: SideBuy, Price: p, Qty: 1})
+	}
+	for i, p := range []int64{1010, 1012, 1020, 1021, 1035} {
+		ob.AddOrder(&Order{ID: int64(i + 100), Side: SideSell, Price: p, Qty: 2})
+	}
+	ob.AddOrder(&Order{ID: 200, Side: SideSell, Price: 1012, Qty: 3})
+	ob.UpdateSnapshot()
+
+	d := ob.AggregatedDepth(3, 10)
+	wantBids := []DepthLevel{{1000, 3, 3}, {990, 2, 2}, {980, 1, 1}}
+	wantAsks := []DepthLevel{{1010, 2, 1}, {1020, 7, 3}, {1030, 2, 1}}
+	if len(d.Bids) != len(wantBids) || len(d.Asks) != len(wantAsks) {
+		t.Fatalf("unexpected depth %+v", d)
+	}
+	for i := range wantBids {
+		if d.Bids[i] != wantBids[i] {
+			t.Errorf("bid %d: got %+v, want %+v", i, d.Bids[i], wantBids[i])
+		}
+	}
+	for i := range wantAsks {
+		if d.Asks[i] != wantAsks[i] {
+			t.Errorf("ask %d: got %+v, want %+v", i, d.Asks[i], wantAsks[i])
+		}
+	}
+
+	// n 截断：第二个桶之后停止
+	if d := ob.AggregatedDepth(2, 10); len(d.Bids) != 2 || d.Bids[1].Quantity != 2 {
+		t.Fatalf("truncated depth %+v", d.Bids)
+	}
+	// step <= 1 退化为原始深度
+	if d := ob.AggregatedDepth(2, 1); len(d.Bids) != 2 || d.Bids[0].Price != 1007 {
+		t.Fatalf("raw depth %+v", d.Bids)
+	}
+
+	// 复用 dst 不分配
+	snap := ob.GetSnapshot()
+	buf := make([]DepthLevel, 0, 8)
+	if allocs := testing.AllocsPerRun(100, func() {
+		buf = AppendAggregated(buf[:0], snap.BidDepth, SideBuy, 10, 8)
+	}); allocs != 0 {
+		t.Fatalf("AppendAggregated allocated %.0f times", allocs)
+	}
+}
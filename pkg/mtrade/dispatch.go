@@ -0,0 +1,209 @@
+package mtrade
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// 事件分发：每个 handler 独立队列 + 独立 worker
+// =============================================================================
+//
+// 【面试高频】为什么不在 eventLoop 里同步调用所有 handler？
+//   FuturesProcessor 的 handler 要写数据库，一次慢 SQL 会拖住：
+//     1. 同一 eventLoop 上的其他 handler（行情推送、风控）
+//     2. eventCh 被写满后，matchLoop 的 publishCriticalEvent 阻塞 → 撮合停摆
+//
+// 现在的结构：
+//
+//   matchLoop ──► eventCh ──► eventLoop (批量取出, fan-out)
+//                                 ├──► queue[0] ──► worker ──► handler 0 (清算, 阻塞)
+//                                 ├──► queue[1] ──► worker ──► handler 1 (行情, 丢弃)
+//                                 └──► ...
+//
+// 投递策略按 handler 的重要程度区分：
+//   - DeliveryBlocking：资金相关，不能丢。队列满时 eventLoop 等待（反压）
+//   - DeliveryDropping：行情、监控等可以丢。队列满时丢弃并计数，不影响其他 handler
+//
+// 【注意】
+//   - 同一 handler 内事件严格按发布顺序处理；不同 handler 之间不保证先后
+//   - 池化对象（Trade/Result/终态 Order）在所有 handler 处理完（或丢弃）后才归还，
+//     用引用计数实现，见 eventRefs
+
+// DeliveryPolicy handler 队列满时的投递策略
+type DeliveryPolicy int
+
+const (
+	DeliveryBlocking DeliveryPolicy = iota // 阻塞等待（关键 handler，默认）
+	DeliveryDropping                       // 丢弃新事件（非关键 handler）
+)
+
+func (p DeliveryPolicy) String() string {
+	if p == DeliveryDropping {
+		return "DROPPING"
+	}
+	return "BLOCKING"
+}
+
+const (
+	// DefaultHandlerQueueSize 每个 handler 的默认队列长度
+	DefaultHandlerQueueSize = 4096
+
+	// eventDispatchBatch eventLoop 每次最多批量取出的事件数
+	eventDispatchBatch = 64
+)
+
+// HandlerOptions handler 注册选项
+type HandlerOptions struct {
+	Name      string         // 名称（用于统计和排查）
+	QueueSize int            // 队列长度，<=0 使用默认值
+	Policy    DeliveryPolicy // 队列满时的策略
+}
+
+// HandlerStats handler 运行统计
+type HandlerStats struct {
+	Name      string
+	Policy    DeliveryPolicy
+	QueueLen  int           // 当前积压
+	QueueCap  int           // 队列容量
+	Processed int64         // 已处理事件数
+	Dropped   int64         // 因队列满被丢弃的事件数
+	LastLag   time.Duration // 最近一个事件从入队到处理完的耗时
+	MaxLag    time.Duration // 历史最大耗时
+}
+
+// =============================================================================
+// 引用计数：多个 handler 共享同一批池化对象
+// =============================================================================
+
+// eventRefs 事件的引用计数（只有持有池化对象、且 handler 多于一个时才分配）
+type eventRefs struct {
+	pending atomic.Int32
+}
+
+var eventRefsPool = sync.Pool{
+	New: func() interface{} {
+		return &eventRefs{}
+	},
+}
+
+// queuedEvent handler 队列中的元素
+type queuedEvent struct {
+	event    Event
+	refs     *eventRefs // nil 表示该 handler 独占，处理完直接归还
+	enqueued int64      // 入队时间（纳秒），用于计算 lag
+}
+
+// done 当前 handler 处理完毕（或丢弃）；最后一个引用负责归还池化对象
+func (q queuedEvent) done() {
+	if q.refs == nil {
+		recycleEvent(q.event)
+		return
+	}
+	if q.refs.pending.Add(-1) == 0 {
+		eventRefsPool.Put(q.refs)
+		recycleEvent(q.event)
+	}
+}
+
+// =============================================================================
+// handler worker
+// =============================================================================
+
+// handlerWorker 每个 handler 一个 goroutine + 一个有界队列
+type handlerWorker struct {
+	name    string
+	policy  DeliveryPolicy
+	handler EventHandler
+	queue   chan queuedEvent
+
+	processed atomic.Int64
+	dropped   atomic.Int64
+	lastLag   atomic.Int64
+	maxLag    atomic.Int64
+}
+
+func newHandlerWorker(handler EventHandler, opts HandlerOptions) *handlerWorker {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultHandlerQueueSize
+	}
+	return &handlerWorker{
+		name:    opts.Name,
+		policy:  opts.Policy,
+		handler: handler,
+		queue:   make(chan queuedEvent, opts.QueueSize),
+	}
+}
+
+// deliver 投递事件到 handler 队列
+// 没能入队（丢弃或引擎已停止）时立即释放引用
+func (w *handlerWorker) deliver(q queuedEvent, stopCh <-chan struct{}) {
+	if w.policy == DeliveryDropping {
+		select {
+		case w.queue <- q:
+		default:
+			w.dropped.Add(1)
+			q.done()
+		}
+		return
+	}
+
+	select {
+	case w.queue <- q:
+	case <-stopCh:
+		q.done()
+	}
+}
+
+// run worker 主循环
+// 【注意】停止时把已入队的事件处理完再退出，避免关键事件在队列里丢失
+func (w *handlerWorker) run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		select {
+		case q := <-w.queue:
+			w.handle(q)
+		case <-stopCh:
+			for {
+				select {
+				case q := <-w.queue:
+					w.handle(q)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle 调用 handler 并记录 lag
+func (w *handlerWorker) handle(q queuedEvent) {
+	w.handler(q.event)
+	q.done()
+
+	lag := time.Now().UnixNano() - q.enqueued
+	w.lastLag.Store(lag)
+	for {
+		old := w.maxLag.Load()
+		if lag <= old || w.maxLag.CompareAndSwap(old, lag) {
+			break
+		}
+	}
+	w.processed.Add(1)
+}
+
+// stats 统计快照
+func (w *handlerWorker) stats() HandlerStats {
+	return HandlerStats{
+		Name:      w.name,
+		Policy:    w.policy,
+		QueueLen:  len(w.queue),
+		QueueCap:  cap(w.queue),
+		Processed: w.processed.Load(),
+		Dropped:   w.dropped.Load(),
+		LastLag:   time.Duration(w.lastLag.Load()),
+		MaxLag:    time.Duration(w.maxLag.Load()),
+	}
+}
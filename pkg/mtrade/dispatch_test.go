@@ -0,0 +1,176 @@
+package mtrade
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 事件分发测试
+// =============================================================================
+
+// submitCrossingPairs 提交 n 对可完全成交的买卖单
+func submitCrossingPairs(t *testing.T, engine *Engine, n int) {
+	t.Helper()
+	for i := int64(0); i < int64(n); i++ {
+		for _, o := range []*Order{
+			{ID: 2*i + 1, Side: SideSell, Price: 50000, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit},
+			{ID: 2*i + 2, Side: SideBuy, Price: 50000, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit},
+		} {
+			for !engine.SubmitOrder(o) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestEngine_SlowHandlerDoesNotBlockOthers(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+
+	release := make(chan struct{})
+	var slowSeen, fastTrades atomic.Int64
+	engine.OnEventWithOptions(func(e Event) {
+		slowSeen.Add(1)
+		<-release // 模拟卡住的数据库写入
+	}, HandlerOptions{Name: "slow"})
+	engine.OnEventWithOptions(func(e Event) {
+		if e.Type == EventTrade {
+			fastTrades.Add(1)
+		}
+	}, HandlerOptions{Name: "fast"})
+
+	engine.Start(context.Background())
+	defer engine.Stop()
+	defer close(release)
+
+	const pairs = 50
+	submitCrossingPairs(t, engine, pairs)
+
+	if !waitFor(t, time.Second, func() bool { return fastTrades.Load() == pairs }) {
+		t.Fatalf("fast handler blocked by slow handler: %d/%d trades", fastTrades.Load(), pairs)
+	}
+	if slowSeen.Load() != 1 {
+		t.Errorf("slow handler should still be stuck on its first event, saw %d", slowSeen.Load())
+	}
+
+	stats := engine.HandlerStats()
+	if len(stats) != 2 || stats[0].Name != "slow" || stats[0].QueueLen == 0 {
+		t.Errorf("expected backlog on slow handler, got %+v", stats)
+	}
+}
+
+func TestEngine_DroppingHandler(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+
+	release := make(chan struct{})
+	var critical, total atomic.Int64
+	engine.OnEventWithOptions(func(e Event) {
+		<-release
+	}, HandlerOptions{Name: "market-data", QueueSize: 4, Policy: DeliveryDropping})
+	engine.OnEvent(func(e Event) {
+		if e.Type != EventBookUpdate {
+			critical.Add(1)
+		}
+		total.Add(1)
+	})
+
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	const pairs = 50 // 每对 2 个订单事件 + 1 个成交事件
+	submitCrossingPairs(t, engine, pairs)
+
+	if !waitFor(t, time.Second, func() bool { return critical.Load() == 3*pairs }) {
+		t.Fatalf("critical handler lost events: %d/%d", critical.Load(), 3*pairs)
+	}
+
+	md := engine.HandlerStats()[0]
+	if md.Dropped == 0 || md.Policy != DeliveryDropping {
+		t.Errorf("expected dropping handler to drop events, got %+v", md)
+	}
+	close(release)
+
+	// 丢弃 + 处理 = 全部事件
+	if !waitFor(t, time.Second, func() bool {
+		s := engine.HandlerStats()[0]
+		return s.Processed+s.Dropped == total.Load()
+	}) {
+		t.Errorf("dropped+processed mismatch: %+v", engine.HandlerStats()[0])
+	}
+}
+
+// TestEngine_PooledTradeSharedAcrossHandlers 池化 Trade 要等所有 handler 都处理完才归还
+func TestEngine_PooledTradeSharedAcrossHandlers(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+
+	var (
+		mu  sync.Mutex
+		bad int
+	)
+	check := func(delay time.Duration) EventHandler {
+		return func(e Event) {
+			if e.Type != EventTrade {
+				return
+			}
+			makerID := e.Trade.MakerID
+			time.Sleep(delay) // 快慢不同，另一个 handler 可能已经处理完
+			if e.Trade.MakerID != makerID || e.Trade.TakerID != makerID+1 {
+				mu.Lock()
+				bad++
+				mu.Unlock()
+			}
+		}
+	}
+	var processedSlow atomic.Int64
+	engine.OnEvent(check(0))
+	engine.OnEvent(func(e Event) {
+		check(50 * time.Microsecond)(e)
+		processedSlow.Add(1)
+	})
+
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	const pairs = 200
+	submitCrossingPairs(t, engine, pairs)
+	waitFor(t, 2*time.Second, func() bool { return processedSlow.Load() >= 3*pairs })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if bad != 0 {
+		t.Errorf("%d trades were recycled while a handler was still reading them", bad)
+	}
+}
+
+func TestEngine_HandlerRegisteredAfterStart(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	var trades atomic.Int64
+	engine.OnEvent(func(e Event) {
+		if e.Type == EventTrade {
+			trades.Add(1)
+		}
+	})
+
+	submitCrossingPairs(t, engine, 1)
+	if !waitFor(t, time.Second, func() bool { return trades.Load() == 1 }) {
+		t.Errorf("handler registered after Start did not receive events")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"max.com/pkg/walstore"
 )
 
 // =============================================================================
@@ -20,19 +23,74 @@ import (
 //   │  OrderInput │ ──► Channel ──► MatchingLoop ──► EventBus
 //   └─────────────┘
 
+// IntakeMode 订单入口队列实现
+type IntakeMode int
+
+const (
+	IntakeChannel    IntakeMode = iota // Go channel（默认）
+	IntakeRingBuffer                   // 无锁 MPSC 环形队列，适合多生产者高并发下单
+)
+
+// BookIndex 订单簿价格索引实现
+type BookIndex int
+
+const (
+	BookIndexSkipList  BookIndex = iota // 跳表（默认），价格范围不受限
+	BookIndexTickArray                  // 跳价数组，需配置 Tick 价格带
+)
+
 // EngineConfig 引擎配置
 type EngineConfig struct {
-	Symbol         string // 交易对
-	OrderQueueSize int    // 订单队列大小
-	WALDir         string // WAL 文件目录（为空则不启用 WAL）
+	Symbol          string     // 交易对
+	OrderQueueSize  int        // 订单队列大小
+	WALDir          string     // WAL 文件目录（为空且未设置 WALStore 则不启用 WAL）
+	WALArchiveDir   string     // 截断时归档旧 WAL 段的目录（见 replay.go），为空则丢弃
+	IntakeMode      IntakeMode // 订单入口实现
+	IntakeBatchSize int        // 环形队列模式下 matchLoop 每批最多取出的订单数
+	BookIndex       BookIndex  // 订单簿价格索引实现
+	Tick            TickConfig // 价格带（BookIndexTickArray 时必填）
+	Limits          BookLimits // 挂单上限（见 book_limits.go），零值不限
+	SnapshotDepth   int        // 快照每侧保留的档位数（深度查询/聚合的上限），<=0 为 20
+
+	// 撮合线程等待策略与放置（见 busy_poll.go）
+	WaitStrategy  WaitStrategy // 空闲时阻塞还是忙轮询
+	SpinBudget    int          // 忙轮询模式下连续空转次数，<=0 使用默认值
+	MatchLoopCPUs []int        // 撮合线程绑定的 CPU，为空则不绑核
+
+	// 挂单队列位置（见 queue_position.go），默认关闭
+	QueueTracking    bool          // 跟踪每笔挂单的排队位置并推送 EventQueueUpdate
+	QueueRateWindow  time.Duration // 吃单速率的衰减时间常数，<=0 为 1 分钟
+	QueueFillHorizon time.Duration // 成交概率的预估窗口，<=0 为 1 分钟
+
+	// ClientOrderIDWindow clientOid 去重窗口（见 client_order.go），<=0 为 10 分钟
+	ClientOrderIDWindow time.Duration
+
+	// WAL 存储（见 pkg/walstore）：WALStore / WALArchive 设置后分别忽略 WALDir / WALArchiveDir
+	WALStore   walstore.Store
+	WALArchive walstore.Store
+
+	// WALRemote 归档同步到对象存储（需配置归档）：恢复前拉回本地缺失的检查点和归档段，
+	// Start 后由 walstore.Archiver 每 WALArchiveInterval（<=0 为 30s）上传新封存的文件
+	WALRemote          *walstore.Remote
+	WALArchiveInterval time.Duration
+
+	// StatsInterval 下单 / 成交速率的采样间隔（见 engine_stats.go），<=0 为 1s
+	StatsInterval time.Duration
+
+	// Epoch 本实例的纪元号（主备切换时由控制面分配），0 表示不启用隔离
+	// 与 WAL 中恢复出的纪元取较大者，见 epoch.go
+	Epoch uint64
 }
 
 // DefaultEngineConfig 默认配置
 func DefaultEngineConfig(symbol string) EngineConfig {
 	return EngineConfig{
-		Symbol:         symbol,
-		OrderQueueSize: 10000,
-		WALDir:         "", // 默认不启用 WAL
+		Symbol:          symbol,
+		OrderQueueSize:  10000,
+		WALDir:          "", // 默认不启用 WAL
+		IntakeMode:      IntakeChannel,
+		IntakeBatchSize: 64,
+		BookIndex:       BookIndexSkipList,
 	}
 }
 
@@ -48,15 +106,27 @@ const (
 	EventOrderAccepted                  // 订单接受
 	EventOrderRejected                  // 订单拒绝
 	EventOrderCanceled                  // 订单取消
+	EventBookUpdate                     // 订单簿增量更新（非关键，可能被丢弃）
+	EventQueueUpdate                    // 挂单队列位置变化（非关键，可能被丢弃）
+	EventMigration                      // 交易对迁移的序列连续性标记（见 migrate.go）
 )
 
 // Event 事件
+// 【注意】Order/Trade/Result 指针只在 handler 回调期间有效，见 pool.go 的所有权规则
 type Event struct {
 	Type      EventType
 	Timestamp int64
-	Order     *Order       // 相关订单
-	Trade     *Trade       // 成交记录（仅 EventTrade）
-	Result    *MatchResult // 撮合结果
+	Order     *Order           // 相关订单
+	Trade     *Trade           // 成交记录（仅 EventTrade）
+	Result    *MatchResult     // 撮合结果
+	Seq       uint64           // 事件发生后的订单簿序列号
+	Updates   []BookUpdate     // 档位增量更新（仅 EventBookUpdate，按 Seq 递增）
+	Queue     []QueueUpdate    // 排队位置变化（仅 EventQueueUpdate）
+	Migration *MigrationMarker // 迁移标记（仅 EventMigration）
+	Epoch     uint64           // 发布事件时的撮合纪元，下游据此拒绝旧主的事件
+	OrderSeq  EventSeq         // 订单事件（受理 / 拒绝 / 撤单）的序号，见 event_seq.go
+
+	owns eventOwnership // 分发完毕后需要归还的对象
 }
 
 // EventHandler 事件处理器
@@ -74,58 +144,140 @@ type Engine struct {
 	matcher   *Matcher
 
 	// WAL（可选）
-	wal *WAL
+	wal          *WAL
+	archiver     *walstore.Archiver // 归档上传（配置 WALRemote 时非 nil）
+	stopArchiver context.CancelFunc
 
 	// 订单输入队列
 	orderCh chan *Order
 
-	// 取消订单队列
-	cancelCh chan int64
+	// 订单输入环形队列（IntakeRingBuffer 模式，替代 orderCh）
+	ring        *MPSCRing
+	ringWaiting atomic.Bool   // matchLoop 是否已休眠
+	ringNotify  chan struct{} // 唤醒 matchLoop
+
+	// 取消订单队列 (单笔撤单 / 按用户全撤，见 cancel_all.go)
+	cancelCh chan cancelRequest
 
 	// 异步事件队列
 	eventCh chan Event
 
-	// 事件处理器
-	handlers []EventHandler
+	// 事件处理器（每个 handler 独立队列 + worker，见 dispatch.go）
+	handlers []*handlerWorker
+	started  bool // Start 之后注册的 handler 立即启动 worker
 	mu       sync.RWMutex
 
 	// 生命周期
 	stopCh chan struct{}
 	wg     sync.WaitGroup
 
-	// 统计
-	stats EngineStats
+	// 统计计数与速率（原子量，见 engine_stats.go）
+	counters engineCounters
+	rates    engineRates
+
+	// 单笔订单处理延迟（matchLoop 内部从开始处理到事件发布完成）
+	latency *LatencyHistogram
+
+	// 成交事件暂存（仅 matchLoop 使用，复用底层数组）
+	tradeScratch []Event
+
+	// 撮合线程放置情况与忙轮询统计
+	placement atomic.Pointer[MatchLoopPlacement]
+	spinParks atomic.Int64 // 自旋预算用完后退回阻塞的次数
+
+	// 撮合纪元（见 epoch.go）
+	epoch atomic.Uint64
+
+	// 挂单队列位置（QueueTracking 开启时非 nil，见 queue_position.go）
+	queue *queueTracker
+
+	// 交易对迁移（见 migrate.go）
+	frozen       atomic.Bool      // 入口冻结，拒绝新的下单 / 撤单
+	inflight     atomic.Int64     // 已入队、matchLoop 尚未处理完的下单 / 撤单数
+	resumeMarker *MigrationMarker // ImportMigration 之后待 Start 发布的 Resume 标记
+
+	// 客户端订单号去重索引（见 client_order.go）
+	clientOrders *clientOrderIndex
+
+	// 暂停交易（见 halt.go）
+	halted      atomic.Bool
+	haltRejects atomic.Int64
+
+	// WAL 恢复 / 迁移导入得到的 ID 高水位（见 id_watermark.go）
+	recoveredIDs IDWatermark
+
+	// 订单 / 用户事件序号（仅 matchLoop 使用，见 event_seq.go）
+	seqs *eventSequencer
 }
 
-// EngineStats 引擎统计
+// EngineStats 引擎统计（GetStats 返回的快照，计数见 engine_stats.go）
 type EngineStats struct {
 	OrdersReceived int64
 	OrdersMatched  int64
 	TradesExecuted int64
 	OrdersCanceled int64
 	EventsDropped  int64 // 事件队列满时丢弃的事件数
+
+	// 最近一个采样间隔（EngineConfig.StatsInterval）的每秒下单数 / 成交数，Start 之前为 0
+	OrdersPerSec float64
+	TradesPerSec float64
+
+	// 挂单上限触发的拒单次数
+	LimitRejects BookLimitRejects
+
+	// 单笔订单处理延迟（HDR 直方图统计）
+	LatencySamples uint64
+	LatencyP50     time.Duration
+	LatencyP99     time.Duration
+	LatencyP999    time.Duration
+	LatencyMax     time.Duration
+
+	// 忙轮询模式下自旋预算用完、退回阻塞的次数（越大说明流量越稀疏）
+	SpinParks int64
 }
 
 // NewEngine 创建撮合引擎
 func NewEngine(config EngineConfig) (*Engine, error) {
 	ob := NewOrderBook(config.Symbol)
+	if config.BookIndex == BookIndexTickArray {
+		var err error
+		if ob, err = NewTickOrderBook(config.Symbol, config.Tick); err != nil {
+			return nil, fmt.Errorf("failed to create order book: %w", err)
+		}
+	}
+	ob.SetSnapshotDepth(config.SnapshotDepth)
 
 	engine := &Engine{
 		config:    config,
 		orderBook: ob,
 		matcher:   NewMatcher(ob),
 		orderCh:   make(chan *Order, config.OrderQueueSize),
-		cancelCh:  make(chan int64, 1000),
+		cancelCh:  make(chan cancelRequest, 1000),
 		eventCh:   make(chan Event, 10000),
-		handlers:  make([]EventHandler, 0),
+		handlers:  make([]*handlerWorker, 0),
 		stopCh:    make(chan struct{}),
+		latency:   NewLatencyHistogram(),
+
+		clientOrders: newClientOrderIndex(config.ClientOrderIDWindow),
+		seqs:         newEventSequencer(),
+	}
+
+	if config.IntakeMode == IntakeRingBuffer {
+		engine.ring = NewMPSCRing(config.OrderQueueSize)
+		engine.ringNotify = make(chan struct{}, 1)
+		if engine.config.IntakeBatchSize <= 0 {
+			engine.config.IntakeBatchSize = 64
+		}
 	}
 
 	// 初始化 WAL（如果配置了）
-	if config.WALDir != "" {
+	if config.WALDir != "" || config.WALStore != nil {
 		walConfig := WALConfig{
-			Dir:      config.WALDir,
-			SyncMode: SyncModeBatch, // 批量刷盘
+			Dir:        config.WALDir,
+			SyncMode:   SyncModeBatch, // 批量刷盘
+			ArchiveDir: config.WALArchiveDir,
+			Store:      config.WALStore,
+			Archive:    config.WALArchive,
 		}
 		wal, err := NewWAL(walConfig)
 		if err != nil {
@@ -133,13 +285,46 @@ func NewEngine(config EngineConfig) (*Engine, error) {
 		}
 		engine.wal = wal
 
+		// 从对象存储拉回本地缺失的归档 (新机器 / 磁盘损坏)，再按本地 + 归档恢复
+		if config.WALRemote != nil {
+			if wal.Archive() == nil {
+				return nil, fmt.Errorf("WAL remote archive requires WALArchiveDir or WALArchive")
+			}
+			if _, err := walstore.Restore(context.Background(), *config.WALRemote, wal.Archive(), restorePick); err != nil {
+				return nil, fmt.Errorf("failed to restore WAL archive: %w", err)
+			}
+			engine.archiver = walstore.NewArchiver(walstore.ArchiverConfig{
+				Source:   wal.Archive(),
+				Remote:   *config.WALRemote,
+				Interval: config.WALArchiveInterval,
+			})
+		}
+
 		// 执行恢复
 		recovery := NewWALRecovery(wal)
 		if err := recovery.Recover(engine); err != nil {
-			return nil, fmt.Errorf("failed to recover from WAL: %v", err)
+			return nil, fmt.Errorf("failed to recover from WAL: %w", err)
 		}
 	}
 
+	// 纪元：配置值与 WAL 恢复值取较大者，绝不回退
+	if config.Epoch > engine.epoch.Load() {
+		if err := engine.setEpoch(config.Epoch); err != nil {
+			return nil, err
+		}
+	}
+
+	// 挂单上限在恢复之后设置：恢复出的挂单全部计入，但不会被拒
+	ob.SetLimits(config.Limits)
+
+	// 恢复完成后再开启增量记录，恢复过程不产生推送
+	ob.EnableUpdates()
+	if config.QueueTracking {
+		engine.queue = newQueueTracker(config)
+		ob.EnableQueueTracking()
+		engine.publishQueueUpdates() // 恢复出的挂单先算一次排队位置
+	}
+
 	return engine, nil
 }
 
@@ -150,15 +335,46 @@ func NewEngine(config EngineConfig) (*Engine, error) {
 // Start 启动撮合引擎
 // 【Go最佳实践】ctx 作为第一个参数传入，而不是存储在 struct 中
 func (e *Engine) Start(ctx context.Context) {
-	e.wg.Add(2) // matchLoop + eventLoop
-	go e.matchLoop(ctx)
+	// 先标记已启动（与 Promote 互斥，之后纪元不再变化），并启动已注册 handler 的 worker
+	e.mu.Lock()
+	e.started = true
+	for _, w := range e.handlers {
+		e.wg.Add(1)
+		go w.run(e.stopCh, &e.wg)
+	}
+	e.publishResumeMarker() // matchLoop 启动前发布，保证排在所有订单事件之前
+	e.mu.Unlock()
+	e.seqs.start(time.Now().UnixNano()) // matchLoop 启动前，之后只由 matchLoop 访问
+
+	e.wg.Add(3) // matchLoop + eventLoop + statsLoop
+	switch {
+	case e.ring != nil:
+		go e.ringMatchLoop(ctx)
+	case e.config.WaitStrategy == WaitBusyPoll || len(e.config.MatchLoopCPUs) > 0:
+		go e.busyPollMatchLoop(ctx)
+	default:
+		go e.matchLoop(ctx)
+	}
 	go e.eventLoop(ctx) // 独立的事件分发线程
+	go e.statsLoop(ctx) // 速率采样
+	if e.archiver != nil {
+		actx, cancel := context.WithCancel(ctx)
+		e.stopArchiver = cancel
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.archiver.Run(actx)
+		}()
+	}
 	// log.Printf("[Engine] %s started", e.config.Symbol)
 }
 
 // Stop 停止撮合引擎
 func (e *Engine) Stop() {
 	close(e.stopCh)
+	if e.stopArchiver != nil {
+		e.stopArchiver() // 退出前再上传一轮
+	}
 	e.wg.Wait()
 
 	// 关闭 WAL
@@ -168,6 +384,11 @@ func (e *Engine) Stop() {
 	}
 }
 
+// WALArchiver 归档上传器（未配置 WALRemote 时为 nil），用于查看上传进度或手动触发一轮
+func (e *Engine) WALArchiver() *walstore.Archiver {
+	return e.archiver
+}
+
 // CreateCheckpoint 创建检查点
 // 【运维】手动触发或定时触发
 func (e *Engine) CreateCheckpoint() error {
@@ -184,13 +405,23 @@ func (e *Engine) CreateCheckpoint() error {
 	orders := e.orderBook.GetAllOrders()
 	seq := e.wal.GetSequence()
 
-	// 创建 Checkpoint
-	if err := e.wal.CreateCheckpoint(seq, orders); err != nil {
+	// 创建 Checkpoint（带 ID 高水位，见 id_watermark.go）
+	if err := e.wal.CreateCheckpoint(seq, orders, e.matcher.ids); err != nil {
 		return err
 	}
 
 	// 截断 WAL
-	return e.wal.Truncate()
+	if err := e.wal.Truncate(); err != nil {
+		return err
+	}
+
+	// 截断后重新记下纪元，否则从新 WAL 恢复会丢失纪元
+	if epoch := e.epoch.Load(); epoch > 0 {
+		if _, err := e.wal.WriteEpoch(epoch); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // matchLoop 撮合主循环
@@ -210,8 +441,82 @@ func (e *Engine) matchLoop(ctx context.Context) {
 		case order := <-e.orderCh:
 			e.processOrder(order)
 
-		case orderID := <-e.cancelCh:
-			e.processCancelOrder(orderID)
+		case req := <-e.cancelCh:
+			e.processCancel(req)
+		}
+	}
+}
+
+// ringMatchLoop 环形队列模式的撮合主循环
+// 【面试】批量取单 + 空闲时休眠，避免忙等占满 CPU
+//
+// 休眠/唤醒协议（防止丢失唤醒）：
+//  1. 消费者先置 ringWaiting=true，再检查一次队列
+//  2. 生产者入队后，若 ringWaiting=true 则 CAS 置 false 并发送通知
+//  3. 两步都是原子操作，任意交错下都不会出现 "有数据但消费者睡着"
+//
+// 忙轮询模式下，队列空时先自旋 SpinBudget 次再走休眠协议
+func (e *Engine) ringMatchLoop(ctx context.Context) {
+	defer e.wg.Done()
+	defer e.placeMatchLoop()()
+
+	batch := make([]*Order, e.config.IntakeBatchSize)
+	spinner := idleSpinner{budget: e.spinBudget()}
+
+	for {
+		n := e.ring.PollBatch(batch)
+		for i := 0; i < n; i++ {
+			e.processOrder(batch[i])
+			batch[i] = nil
+		}
+
+		if n > 0 || spinner.spin() {
+			if n > 0 {
+				spinner.reset()
+			}
+			// 有流量：顺带处理撤单，然后继续取下一批
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.stopCh:
+				return
+			case req := <-e.cancelCh:
+				e.processCancel(req)
+			default:
+			}
+			continue
+		}
+
+		// 队列空：准备休眠
+		if spinner.budget > 0 {
+			e.spinParks.Add(1)
+			spinner.reset()
+		}
+		e.ringWaiting.Store(true)
+		if !e.ring.IsEmpty() {
+			e.ringWaiting.Store(false)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopCh:
+			return
+		case req := <-e.cancelCh:
+			e.processCancel(req)
+		case <-e.ringNotify:
+		}
+		e.ringWaiting.Store(false)
+	}
+}
+
+// wakeRingMatchLoop 唤醒休眠中的 matchLoop（仅在其休眠时才发送通知）
+func (e *Engine) wakeRingMatchLoop() {
+	if e.ringWaiting.Load() && e.ringWaiting.CompareAndSwap(true, false) {
+		select {
+		case e.ringNotify <- struct{}{}:
+		default:
 		}
 	}
 }
@@ -222,29 +527,60 @@ func (e *Engine) matchLoop(ctx context.Context) {
 
 // SubmitOrder 提交订单
 // 【面试】异步提交，放入队列等待处理
+// 迁移冻结期间返回 false（先计入 inflight 再检查冻结，Freeze 不会漏等这笔订单）
 func (e *Engine) SubmitOrder(order *Order) bool {
+	if !e.enterIntake() {
+		return false
+	}
+	if e.ring != nil {
+		if !e.ring.Offer(order) {
+			e.inflight.Add(-1)
+			return false // 队列满了
+		}
+		e.counters.ordersReceived.Add(1)
+		e.wakeRingMatchLoop()
+		return true
+	}
+
 	select {
 	case e.orderCh <- order:
-		e.stats.OrdersReceived++
+		e.counters.ordersReceived.Add(1)
 		return true
 	default:
 		// 队列满了
+		e.inflight.Add(-1)
 		return false
 	}
 }
 
 // CancelOrder 取消订单
 func (e *Engine) CancelOrder(orderID int64) bool {
+	if !e.enterIntake() {
+		return false
+	}
 	select {
-	case e.cancelCh <- orderID:
+	case e.cancelCh <- cancelRequest{orderID: orderID}:
 		return true
 	default:
+		e.inflight.Add(-1)
 		return false
 	}
 }
 
+// enterIntake 计入 inflight；已冻结则撤销并返回 false
+func (e *Engine) enterIntake() bool {
+	e.inflight.Add(1)
+	if e.frozen.Load() {
+		e.inflight.Add(-1)
+		return false
+	}
+	return true
+}
+
 // processOrder 处理订单
 func (e *Engine) processOrder(order *Order) {
+	start := time.Now()
+
 	// 设置时间戳
 	if order.CreatedAt == 0 {
 		order.CreatedAt = time.Now().UnixNano()
@@ -255,51 +591,147 @@ func (e *Engine) processOrder(order *Order) {
 		order.ID = NextOrderID()
 	}
 
-	// 【WAL】先写日志，再撮合
-	if e.wal != nil {
-		e.wal.WriteOrder(order)
+	// 暂停交易 / 订单 ID 或 clientOid 重复的订单直接拒绝，不写 WAL、不进撮合
+	// （见 halt.go、id_watermark.go、client_order.go）
+	var result *MatchResult
+	if e.halted.Load() {
+		e.haltRejects.Add(1)
+		result = rejectOrder(order, RejectMarketHalted)
+	} else if e.isResting(order.ID) {
+		result = rejectOrder(order, RejectDuplicateOrderID)
+	} else if reason := e.clientOrders.claim(order, e.isResting); reason != RejectNone {
+		result = rejectOrder(order, reason)
+	} else {
+		// 【WAL】先写日志，再撮合
+		if e.wal != nil {
+			e.wal.WriteOrder(order)
+		}
+
+		// 撮合
+		result = e.matcher.ProcessOrder(order)
+		e.counters.ordersMatched.Add(1)
 	}
 
-	// 撮合
-	result := e.matcher.ProcessOrder(order)
-	e.stats.OrdersMatched++
+	// 先把成交拷贝到池化的 Trade 中
+	// 【注意】result 随订单事件交给 eventLoop 后，本线程不能再读它
+	// 序号按发布顺序分配：先受理事件，再逐笔成交
+	tradeEvents := e.tradeScratch[:0]
+	epoch := e.epoch.Load()
+	orderSeq := e.seqs.accept(order)
+	for i := range result.Trades {
+		trade := AcquireTrade()
+		*trade = result.Trades[i]
+		trade.Epoch = epoch
+		trade.TakerSeq = e.seqs.next(order)
+		trade.MakerSeq = e.seqs.next(result.makers[i])
+
+		event := Event{
+			Type:      EventTrade,
+			Timestamp: trade.Timestamp,
+			Trade:     trade,
+			Seq:       trade.BookSeq,
+			owns:      eventOwnership{trade: true},
+		}
+		// 被吃完的 Maker 已离开订单簿，随这条成交事件一起回收
+		if maker := result.makers[i]; maker.IsFilled() {
+			e.seqs.done(maker.ID)
+			if maker.pooled {
+				event.owns.recycle = maker
+			}
+		}
+		tradeEvents = append(tradeEvents, event)
+	}
+	if order.Status != OrderStatusRejected && isTerminal(order) {
+		e.seqs.done(order.ID)
+	}
 
-	// 发布事件
-	e.publishOrderEvent(order, result)
+	if e.queue != nil {
+		e.queue.recordFills(result.Trades)
+	}
+
+	// 同步下单回执：必须在 result、order 交给事件之前拷贝，处理完再发出
+	ackCh := order.ack
+	var ack OrderAck
+	if ackCh != nil {
+		ack = e.buildAck(order, result)
+	}
+
+	// 发布事件（result 的所有权交给事件）
+	e.publishOrderEvent(order, result, orderSeq)
 
 	// 发布成交事件（关键事件，不可丢弃）
-	for i := range result.Trades {
-		e.stats.TradesExecuted++
-		e.publishCriticalEvent(Event{
-			Type:      EventTrade,
-			Timestamp: result.Trades[i].Timestamp,
-			Trade:     &result.Trades[i],
-		})
+	for i := range tradeEvents {
+		e.counters.tradesExecuted.Add(1)
+		e.publishCriticalEvent(tradeEvents[i])
+		tradeEvents[i] = Event{}
 	}
+	e.tradeScratch = tradeEvents[:0]
 
-	// 更新快照（供外部无锁读取）
+	// 发布增量更新，并更新快照（供外部无锁读取）
+	e.publishBookUpdates()
+	e.publishQueueUpdates()
 	e.orderBook.UpdateSnapshot()
 
-	// 归还结果到对象池
-	PutMatchResult(result)
+	if ackCh != nil {
+		sendAck(ackCh, ack)
+	}
+
+	e.latency.Record(time.Since(start))
+	e.inflight.Add(-1)
 }
 
 // processCancelOrder 处理取消订单
 func (e *Engine) processCancelOrder(orderID int64) {
+	defer e.inflight.Add(-1)
+
+	if e.cancelResting(orderID) {
+		e.publishBookUpdates()
+		e.publishQueueUpdates()
+		e.orderBook.UpdateSnapshot()
+	}
+}
+
+// cancelResting 撤掉一笔挂单并发布撤单事件，订单不在簿上时返回 false
+// (档位增量、排队位置、快照由调用方统一发布)
+func (e *Engine) cancelResting(orderID int64) bool {
 	// 【WAL】先写日志
 	if e.wal != nil {
 		e.wal.WriteCancelOrder(orderID)
 	}
 
 	order := e.orderBook.CancelOrder(orderID)
-	if order != nil {
-		e.stats.OrdersCanceled++
-		e.publishCriticalEvent(Event{
-			Type:      EventOrderCanceled,
-			Timestamp: time.Now().UnixNano(),
-			Order:     order,
-		})
+	if order == nil {
+		return false
+	}
+	e.counters.ordersCanceled.Add(1)
+	event := Event{
+		Type:      EventOrderCanceled,
+		Timestamp: time.Now().UnixNano(),
+		Order:     order,
+		Seq:       e.orderBook.Seq(),
+		OrderSeq:  e.seqs.next(order),
+	}
+	e.seqs.done(orderID)
+	if order.pooled {
+		event.owns.recycle = order
 	}
+	e.publishCriticalEvent(event)
+	return true
+}
+
+// publishBookUpdates 发布本次处理产生的档位增量更新
+// 【注意】非关键事件，丢了由客户端通过 Seq 空洞发现并重新拉快照
+func (e *Engine) publishBookUpdates() {
+	updates := e.orderBook.TakeUpdates()
+	if len(updates) == 0 {
+		return
+	}
+	e.publishEvent(Event{
+		Type:      EventBookUpdate,
+		Timestamp: time.Now().UnixNano(),
+		Seq:       updates[len(updates)-1].Seq,
+		Updates:   updates,
+	})
 }
 
 // =============================================================================
@@ -308,15 +740,38 @@ func (e *Engine) processCancelOrder(orderID int64) {
 
 // OnEvent 注册事件处理器
 // 【支持多订阅者】可以注册多个 handler
+// 默认按关键 handler 处理：独立队列，队列满时阻塞（不丢事件）
 func (e *Engine) OnEvent(handler EventHandler) {
+	e.OnEventWithOptions(handler, HandlerOptions{Policy: DeliveryBlocking})
+}
+
+// OnEventWithOptions 注册事件处理器，并指定队列长度和投递策略
+// 【注意】慢 handler 只会拖慢自己的队列；阻塞策略的 handler 积压满后才会反压到撮合
+func (e *Engine) OnEventWithOptions(handler EventHandler, opts HandlerOptions) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.handlers = append(e.handlers, handler)
+
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("handler-%d", len(e.handlers))
+	}
+	w := newHandlerWorker(handler, opts)
+
+	// 写时复制：eventLoop 持有的旧切片不受影响
+	handlers := make([]*handlerWorker, len(e.handlers), len(e.handlers)+1)
+	copy(handlers, e.handlers)
+	e.handlers = append(handlers, w)
+
+	if e.started {
+		e.wg.Add(1)
+		go w.run(e.stopCh, &e.wg)
+	}
 }
 
 // publishCriticalEvent 发布关键事件（阻塞，保证不丢）
 // 【用于】Trade、OrderAccepted、OrderRejected、OrderCanceled
 func (e *Engine) publishCriticalEvent(event Event) {
+	event.Epoch = e.epoch.Load()
+
 	// 监控队列长度
 	queueLen := len(e.eventCh)
 	if queueLen > cap(e.eventCh)*8/10 { // 超过 80%
@@ -330,20 +785,24 @@ func (e *Engine) publishCriticalEvent(event Event) {
 // publishEvent 发布普通事件（非阻塞，可丢弃）
 // 【用于】Depth 更新等非关键事件
 func (e *Engine) publishEvent(event Event) {
+	event.Epoch = e.epoch.Load()
 	select {
 	case e.eventCh <- event:
 		// 发送成功
 	default:
-		// 队列满了，丢弃
-		e.stats.EventsDropped++
+		// 队列满了，丢弃（持有的池化对象直接归还）
+		e.counters.eventsDropped.Add(1)
+		recycleEvent(event)
 	}
 }
 
 // eventLoop 事件分发循环（独立 goroutine）
-// 【异步】从 eventCh 读取事件，分发到所有 handler
+// 【异步】从 eventCh 读取事件，投递到每个 handler 的队列
+// 【优化】有积压时一次取出一批，handler 列表每批只读一次
 func (e *Engine) eventLoop(ctx context.Context) {
 	defer e.wg.Done()
 
+	batch := make([]Event, 0, eventDispatchBatch)
 	for {
 		select {
 		case <-ctx.Done():
@@ -353,24 +812,102 @@ func (e *Engine) eventLoop(ctx context.Context) {
 			return
 
 		case event := <-e.eventCh:
-			e.dispatchEvent(event)
+			batch = append(batch[:0], event)
+		drain:
+			for len(batch) < eventDispatchBatch {
+				select {
+				case event = <-e.eventCh:
+					batch = append(batch, event)
+				default:
+					break drain
+				}
+			}
+			e.dispatchBatch(batch)
+			clear(batch)
 		}
 	}
 }
 
-// dispatchEvent 分发事件到所有 handler
-func (e *Engine) dispatchEvent(event Event) {
+// dispatchBatch 把一批事件投递到所有 handler 队列
+func (e *Engine) dispatchBatch(batch []Event) {
 	e.mu.RLock()
 	handlers := e.handlers
 	e.mu.RUnlock()
 
-	for _, h := range handlers {
-		h(event)
+	now := time.Now().UnixNano()
+	for i := range batch {
+		e.dispatchEvent(handlers, batch[i], now)
 	}
 }
 
+// dispatchEvent 分发单个事件
+// 池化对象由最后一个处理完（或丢弃）的 handler 归还
+func (e *Engine) dispatchEvent(handlers []*handlerWorker, event Event, now int64) {
+	if len(handlers) == 0 {
+		recycleEvent(event)
+		return
+	}
+
+	q := queuedEvent{event: event, enqueued: now}
+	if len(handlers) > 1 && event.owns != (eventOwnership{}) {
+		q.refs = eventRefsPool.Get().(*eventRefs)
+		q.refs.pending.Store(int32(len(handlers)))
+	}
+
+	for _, w := range handlers {
+		w.deliver(q, e.stopCh)
+	}
+}
+
+// HandlerStats 获取每个 handler 的队列积压、丢弃数和处理延迟
+func (e *Engine) HandlerStats() []HandlerStats {
+	e.mu.RLock()
+	handlers := e.handlers
+	e.mu.RUnlock()
+
+	stats := make([]HandlerStats, len(handlers))
+	for i, w := range handlers {
+		stats[i] = w.stats()
+	}
+	return stats
+}
+
+// QueueStats 撮合引擎内部队列积压
+type QueueStats struct {
+	OrderQueueLen  int // 待撮合订单（IntakeRingBuffer 模式为环形队列）
+	OrderQueueCap  int
+	CancelQueueLen int // 待处理撤单
+	CancelQueueCap int
+	EventQueueLen  int // 待分发事件（分发到各 handler 之前，handler 自己的积压见 HandlerStats）
+	EventQueueCap  int
+}
+
+// QueueStats 获取内部队列积压
+func (e *Engine) QueueStats() QueueStats {
+	qs := QueueStats{
+		OrderQueueLen:  len(e.orderCh),
+		OrderQueueCap:  cap(e.orderCh),
+		CancelQueueLen: len(e.cancelCh),
+		CancelQueueCap: cap(e.cancelCh),
+		EventQueueLen:  len(e.eventCh),
+		EventQueueCap:  cap(e.eventCh),
+	}
+	if e.ring != nil {
+		qs.OrderQueueLen, qs.OrderQueueCap = e.ring.Len(), e.ring.Cap()
+	}
+	return qs
+}
+
+// WALStats 获取 WAL 写入 / 刷盘进度，未配置 WAL 返回 false
+func (e *Engine) WALStats() (WALStats, bool) {
+	if e.wal == nil {
+		return WALStats{}, false
+	}
+	return e.wal.Stats(), true
+}
+
 // publishOrderEvent 发布订单状态事件
-func (e *Engine) publishOrderEvent(order *Order, result *MatchResult) {
+func (e *Engine) publishOrderEvent(order *Order, result *MatchResult, seq EventSeq) {
 	var eventType EventType
 	switch order.Status {
 	case OrderStatusRejected:
@@ -379,12 +916,20 @@ func (e *Engine) publishOrderEvent(order *Order, result *MatchResult) {
 		eventType = EventOrderAccepted
 	}
 
-	e.publishCriticalEvent(Event{ // 订单状态变更是关键事件
+	event := Event{ // 订单状态变更是关键事件
 		Type:      eventType,
 		Timestamp: time.Now().UnixNano(),
 		Order:     order,
 		Result:    result,
-	})
+		Seq:       e.orderBook.Seq(),
+		OrderSeq:  seq,
+		owns:      eventOwnership{result: true},
+	}
+	// 终态订单不会进入订单簿，分发完即可回收
+	if order.pooled && isTerminal(order) {
+		event.owns.recycle = order
+	}
+	e.publishCriticalEvent(event)
 }
 
 // =============================================================================
@@ -396,12 +941,27 @@ func (e *Engine) GetOrderBook() *OrderBook {
 	return e.orderBook
 }
 
-// GetStats 获取统计信息
-func (e *Engine) GetStats() EngineStats {
-	return e.stats
+// ResetLatency 清空延迟统计（压测分阶段观察时使用）
+func (e *Engine) ResetLatency() {
+	e.latency.Reset()
 }
 
 // GetDepth 获取深度
 func (e *Engine) GetDepth(n int) (bids, asks []DepthLevel) {
 	return e.orderBook.Depth(n)
 }
+
+// GetDepthSnapshot 获取带序列号的深度快照（客户端对齐增量推送用）
+func (e *Engine) GetDepthSnapshot(n int) DepthSnapshot {
+	return e.orderBook.DepthSnapshot(n)
+}
+
+// GetAggregatedDepth 按价格步长聚合的深度快照（见 depth_agg.go）
+func (e *Engine) GetAggregatedDepth(n int, step int64) DepthSnapshot {
+	return e.orderBook.AggregatedDepth(n, step)
+}
+
+// WaitDepthSnapshot 获取序列号 >= minSeq 的深度快照，必要时等待撮合线程发布
+func (e *Engine) WaitDepthSnapshot(ctx context.Context, minSeq uint64, n int) (DepthSnapshot, error) {
+	return e.orderBook.WaitDepthSnapshot(ctx, minSeq, n)
+}
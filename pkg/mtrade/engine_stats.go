@@ -0,0 +1,108 @@
+package mtrade
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// 引擎统计计数与速率
+// =============================================================================
+//
+// 【问题】EngineStats 原来是普通 int64 字段：matchLoop 写、GetStats 在诊断接口的
+// goroutine 里直接整体拷贝读，OrdersReceived 还由多个下单 goroutine 同时 ++，
+// EventsDropped 在发布事件时写 —— 全是数据竞争，-race 下必报，计数也会丢
+//
+// 【做法】
+//   - 计数器全部换成原子量 (engineCounters)，写方只做 Add(1)，GetStats 逐个 Load 拼出快照
+//   - 速率 (每秒下单数 / 成交数) 由独立的采样 goroutine 每 StatsInterval 算一次：
+//     (本次计数 - 上次计数) / 实际间隔，结果以 float64 位模式存进原子量
+//   - 采样只读计数器，不进 matchLoop，热路径只多了原子加
+//
+// 并发模型：
+//   - 计数器：多写者 (下单入口、matchLoop、事件发布)，任意 goroutine 读
+//   - 速率：采样 goroutine 单写者，任意 goroutine 读
+//
+// 【注意】GetStats 拼出的快照各字段不是同一时刻的 (例如 OrdersMatched 可能比 OrdersReceived 新)，
+// 作为监控指标足够；需要严格一致的数字看 WAL 序列号
+
+// defaultStatsInterval 速率采样间隔默认值
+const defaultStatsInterval = time.Second
+
+// engineCounters 引擎处理计数 (全部原子操作)
+type engineCounters struct {
+	ordersReceived atomic.Int64
+	ordersMatched  atomic.Int64
+	tradesExecuted atomic.Int64
+	ordersCanceled atomic.Int64
+	eventsDropped  atomic.Int64
+}
+
+// engineRates 最近一个采样间隔的速率 (float64 位模式)
+type engineRates struct {
+	ordersPerSec atomic.Uint64
+	tradesPerSec atomic.Uint64
+
+	// 上一次采样的计数与时间（仅采样 goroutine 使用）
+	lastOrders int64
+	lastTrades int64
+	lastAt     time.Time
+}
+
+// sample 按两次采样之间的计数增量更新速率，第一次调用只记录基准
+func (r *engineRates) sample(c *engineCounters, now time.Time) {
+	orders, trades := c.ordersReceived.Load(), c.tradesExecuted.Load()
+	if !r.lastAt.IsZero() {
+		if elapsed := now.Sub(r.lastAt).Seconds(); elapsed > 0 {
+			r.ordersPerSec.Store(math.Float64bits(float64(orders-r.lastOrders) / elapsed))
+			r.tradesPerSec.Store(math.Float64bits(float64(trades-r.lastTrades) / elapsed))
+		}
+	}
+	r.lastOrders, r.lastTrades, r.lastAt = orders, trades, now
+}
+
+// statsLoop 定时采样速率，随引擎停止退出
+func (e *Engine) statsLoop(ctx context.Context) {
+	defer e.wg.Done()
+
+	interval := e.config.StatsInterval
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	e.rates.sample(&e.counters, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopCh:
+			return
+		case now := <-ticker.C:
+			e.rates.sample(&e.counters, now)
+		}
+	}
+}
+
+// GetStats 获取统计信息（任意 goroutine 可调用）
+func (e *Engine) GetStats() EngineStats {
+	return EngineStats{
+		OrdersReceived: e.counters.ordersReceived.Load(),
+		OrdersMatched:  e.counters.ordersMatched.Load(),
+		TradesExecuted: e.counters.tradesExecuted.Load(),
+		OrdersCanceled: e.counters.ordersCanceled.Load(),
+		EventsDropped:  e.counters.eventsDropped.Load(),
+		OrdersPerSec:   math.Float64frombits(e.rates.ordersPerSec.Load()),
+		TradesPerSec:   math.Float64frombits(e.rates.tradesPerSec.Load()),
+		LimitRejects:   e.orderBook.LimitRejects(),
+		LatencySamples: e.latency.Count(),
+		LatencyP50:     e.latency.Percentile(0.50),
+		LatencyP99:     e.latency.Percentile(0.99),
+		LatencyP999:    e.latency.Percentile(0.999),
+		LatencyMax:     e.latency.Max(),
+		SpinParks:      e.spinParks.Load(),
+	}
+}
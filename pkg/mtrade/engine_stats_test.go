@@ -0,0 +1,74 @@
+package mtrade
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEngineRates_Sample(t *testing.T) {
+	var c engineCounters
+	var r engineRates
+	rate := func(v *atomic.Uint64) float64 { return math.Float64frombits(v.Load()) }
+	t0 := time.Unix(1000, 0)
+
+	c.ordersReceived.Add(100)
+	r.sample(&c, t0) // 第一次只记基准
+	if got := rate(&r.ordersPerSec); got != 0 {
+		t.Fatalf("first sample orders/sec = %v", got)
+	}
+
+	c.ordersReceived.Add(500)
+	c.tradesExecuted.Add(250)
+	r.sample(&c, t0.Add(2*time.Second))
+	if o, tr := rate(&r.ordersPerSec), rate(&r.tradesPerSec); o != 250 || tr != 125 {
+		t.Errorf("rates = %v / %v, want 250 / 125", o, tr)
+	}
+
+	// 空闲一个间隔，速率回到 0
+	r.sample(&c, t0.Add(3*time.Second))
+	if o, tr := rate(&r.ordersPerSec), rate(&r.tradesPerSec); o != 0 || tr != 0 {
+		t.Errorf("idle rates = %v / %v", o, tr)
+	}
+}
+
+// TestEngine_StatsConcurrentSubmit 多个 goroutine 同时下单、同时读统计：计数不丢 (配合 -race)
+func TestEngine_StatsConcurrentSubmit(t *testing.T) {
+	config := DefaultEngineConfig("BTC_USDT")
+	config.StatsInterval = time.Millisecond // 采样 goroutine 同时在读计数
+	engine := mustNewEngine(t, config)
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	const writers, perWriter = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				// 同一用户的买卖单价格错开不成交，只测计数
+				side, price := SideBuy, int64(100+i%10)
+				if w%2 == 1 {
+					side, price = SideSell, int64(1000+i%10)
+				}
+				for !engine.SubmitOrder(&Order{UserID: int64(w + 1), Side: side, Price: price, Qty: 1, Type: OrderTypeLimit, Symbol: "BTC_USDT"}) {
+					time.Sleep(time.Millisecond)
+				}
+				engine.GetStats()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := int64(writers * perWriter)
+	if !waitFor(t, time.Second, func() bool { return engine.GetStats().OrdersMatched == total }) {
+		t.Fatalf("matched = %d, want %d", engine.GetStats().OrdersMatched, total)
+	}
+	if got := engine.GetStats().OrdersReceived; got != total {
+		t.Errorf("received = %d, want %d", got, total)
+	}
+}
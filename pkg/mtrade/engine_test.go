@@ -351,3 +351,63 @@ func BenchmarkEngine_FullPipeline(b *testing.B) {
 	b.StopTimer()
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestEngine_SubmitOrderSync(t *testing.T) {
+	for _, mode := range []IntakeMode{IntakeChannel, IntakeRingBuffer} {
+		config := DefaultEngineConfig("BTC_USDT")
+		config.IntakeMode = mode
+		config.Limits = BookLimits{MaxOrdersPerUser: 1}
+		engine := mustNewEngine(t, config)
+		engine.Start(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		// 挂单：无成交，状态 New，拿到引擎分配的 ID
+		ack, err := engine.SubmitOrderSync(ctx, &Order{UserID: 1, Side: SideSell, Price: 50000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+		if err != nil {
+			t.Fatalf("mode %d: maker: %v", mode, err)
+		}
+		if ack.OrderID == 0 || !ack.Accepted() || ack.FilledQty != 0 || ack.RemainingQty != 10 || len(ack.Trades) != 0 {
+			t.Fatalf("mode %d: unexpected maker ack %+v", mode, ack)
+		}
+		makerID := ack.OrderID
+
+		// 同一用户第二笔挂单触发上限：被拒并带原因
+		ack, err = engine.SubmitOrderSync(ctx, &Order{UserID: 1, Side: SideSell, Price: 50100, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+		if err != nil {
+			t.Fatalf("mode %d: reject: %v", mode, err)
+		}
+		if ack.Accepted() || ack.RejectReason != RejectUserOrderCap {
+			t.Fatalf("mode %d: expected user cap reject, got %+v", mode, ack)
+		}
+
+		// 池化 IOC 吃单：部分成交，剩余被撤，成交明细随回执返回
+		taker := AcquireOrder()
+		taker.UserID, taker.Side, taker.Price, taker.Qty, taker.Symbol, taker.Type = 2, SideBuy, 50000, 15, "BTC_USDT", OrderTypeIOC
+		ack, err = engine.SubmitOrderSync(ctx, taker)
+		if err != nil {
+			t.Fatalf("mode %d: taker: %v", mode, err)
+		}
+		if ack.Status != OrderStatusCanceled || ack.FilledQty != 10 || ack.RemainingQty != 5 {
+			t.Fatalf("mode %d: unexpected taker ack %+v", mode, ack)
+		}
+		if len(ack.Trades) != 1 || ack.Trades[0].MakerID != makerID || ack.Trades[0].Qty != 10 {
+			t.Fatalf("mode %d: unexpected trades %+v", mode, ack.Trades)
+		}
+
+		cancel()
+		engine.Stop()
+	}
+}
+
+func TestEngine_SubmitOrderSyncContext(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+
+	// 引擎未启动，订单入队但不会被处理：按 ctx 超时返回
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := engine.SubmitOrderSync(ctx, &Order{Side: SideBuy, Price: 50000, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
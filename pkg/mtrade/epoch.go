@@ -0,0 +1,71 @@
+package mtrade
+
+import (
+	"errors"
+	"fmt"
+)
+
+// =============================================================================
+// 撮合纪元 (epoch fencing)
+// =============================================================================
+//
+// 【面试高频】主备切换后怎么防止旧主重复发成交？
+//   备机提升为新主时纪元号 +1，新纪元先写入 WAL 再开始撮合；
+//   所有事件 (Event.Epoch) 和成交 (Trade.Epoch) 都带上发布时的纪元，
+//   结算侧 (asset / spot / futures) 用 epoch.Fence 拒绝比已见最大纪元小的事件。
+//
+// 切换流程：
+//   1. 控制面原子地分配新纪元 N+1（如 etcd/Redis 自增）
+//   2. 控制面先推进各结算方的 Fence 到 N+1（旧主的事件从此被拒）
+//   3. 备机 NewEngine 从 WAL 恢复订单簿 → Promote(N+1) → Start
+//
+// 【注意】纪元写在 WAL 里：重启后恢复出原纪元，不会因为重启而回退
+
+var (
+	// ErrEngineStarted 引擎已启动，不能再切换纪元
+	ErrEngineStarted = errors.New("engine already started")
+	// ErrEpochRegression 新纪元不大于当前纪元
+	ErrEpochRegression = errors.New("epoch must increase")
+)
+
+// Epoch 当前纪元
+func (e *Engine) Epoch() uint64 {
+	return e.epoch.Load()
+}
+
+// Promote 备机提升为主：切换到新纪元并写入 WAL，必须在 Start 之前调用
+// epoch 为 0 时在当前纪元上 +1；返回生效的纪元
+func (e *Engine) Promote(epoch uint64) (uint64, error) {
+	// 持有 e.mu 与 Start 互斥：切换完成前 matchLoop 不会启动
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.started {
+		return 0, ErrEngineStarted
+	}
+
+	cur := e.epoch.Load()
+	if epoch == 0 {
+		epoch = cur + 1
+	}
+	if epoch <= cur {
+		return 0, fmt.Errorf("%w: %d <= %d", ErrEpochRegression, epoch, cur)
+	}
+	if err := e.setEpoch(epoch); err != nil {
+		return 0, err
+	}
+	return epoch, nil
+}
+
+// setEpoch 先写 WAL 再生效（matchLoop 未启动时调用）
+func (e *Engine) setEpoch(epoch uint64) error {
+	if e.wal != nil {
+		if _, err := e.wal.WriteEpoch(epoch); err != nil {
+			return fmt.Errorf("write epoch to WAL: %w", err)
+		}
+		if err := e.wal.Sync(); err != nil {
+			return fmt.Errorf("sync epoch to WAL: %w", err)
+		}
+	}
+	e.epoch.Store(epoch)
+	return nil
+}
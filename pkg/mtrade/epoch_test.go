@@ -0,0 +1,71 @@
+package mtrade
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 撮合纪元测试
+// =============================================================================
+
+func TestEngine_EpochPersistsInWAL(t *testing.T) {
+	cfg := DefaultEngineConfig("BTC_USDT")
+	cfg.WALDir = t.TempDir()
+	cfg.Epoch = 3
+
+	engine := mustNewEngine(t, cfg)
+	if engine.Epoch() != 3 {
+		t.Fatalf("expected epoch 3, got %d", engine.Epoch())
+	}
+
+	var tradeEpoch, eventEpoch atomic.Uint64
+	engine.OnEvent(func(e Event) {
+		if e.Type == EventTrade {
+			tradeEpoch.Store(e.Trade.Epoch)
+			eventEpoch.Store(e.Epoch)
+		}
+	})
+	engine.Start(context.Background())
+
+	if _, err := engine.Promote(0); !errors.Is(err, ErrEngineStarted) {
+		t.Errorf("expected ErrEngineStarted, got %v", err)
+	}
+
+	submitCrossingPairs(t, engine, 1)
+	if !waitFor(t, time.Second, func() bool { return tradeEpoch.Load() != 0 }) {
+		t.Fatal("no trade event")
+	}
+	if tradeEpoch.Load() != 3 || eventEpoch.Load() != 3 {
+		t.Errorf("expected trade and event stamped with epoch 3, got %d/%d", tradeEpoch.Load(), eventEpoch.Load())
+	}
+	engine.Stop()
+
+	// 备机从同一份 WAL 恢复：纪元不回退，Promote 后 +1
+	cfg.Epoch = 0
+	standby := mustNewEngine(t, cfg)
+	if standby.Epoch() != 3 {
+		t.Fatalf("expected recovered epoch 3, got %d", standby.Epoch())
+	}
+	if _, err := standby.Promote(2); !errors.Is(err, ErrEpochRegression) {
+		t.Errorf("expected ErrEpochRegression, got %v", err)
+	}
+	if epoch, err := standby.Promote(0); err != nil || epoch != 4 {
+		t.Fatalf("expected promote to epoch 4, got %d (%v)", epoch, err)
+	}
+
+	// 检查点截断 WAL 后纪元仍然保留
+	if err := standby.CreateCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	standby.Start(context.Background())
+	standby.Stop()
+
+	restarted := mustNewEngine(t, cfg)
+	if restarted.Epoch() != 4 {
+		t.Errorf("expected epoch 4 after checkpoint and restart, got %d", restarted.Epoch())
+	}
+}
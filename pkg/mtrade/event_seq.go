@@ -0,0 +1,73 @@
+package mtrade
+
+// =============================================================================
+// 订单 / 用户事件序号
+// =============================================================================
+//
+// 【问题】OnEvent 的 handler 按引擎顺序收到事件，但每个 handler 一个队列、再经 NATS 发给多实例消费者后，
+// 同一订单的成交、撤单可能乱序到达：撤单先落库、成交后到，或者重投的旧成交排在新成交后面
+//
+// 【做法】matchLoop 给每个关键事件盖章 (EventSeq)：
+//   - Order：该订单的第几个事件，从 1 连续递增，1 固定是受理 / 拒绝事件
+//   - User：该用户在本引擎的第几个事件（订单事件一次，成交双方各一次）
+//   - Session：序号会话，引擎每次 Start 取当时的纳秒时间戳，计数从头开始
+//
+// 订单事件的序号在 Event.OrderSeq，成交事件双方各一个 (Trade.TakerSeq / MakerSeq)。
+// 消费方按 (Session, Order) 判重、补空洞，见 order.OrderConsumer
+//
+// 【注意】
+//   - 序号不写 WAL：重启后是新会话，恢复出的挂单下一个事件从 2 开始（1 留给受理事件）
+//   - 入口拒绝（暂停交易、ID 重复）的订单只有一个序号为 1 的拒绝事件
+//   - 用户序号按引擎（交易对）独立计数，跨交易对没有全序
+//   - MatchResult.Trades 不带序号，以成交事件为准
+
+// EventSeq 事件序号
+type EventSeq struct {
+	Session int64  // 序号会话（引擎启动时间，纳秒）
+	Order   uint64 // 订单内序号，从 1 开始
+	User    uint64 // 用户在本引擎内的序号，从 1 开始
+}
+
+// eventSequencer 序号计数（只在 matchLoop 访问）
+type eventSequencer struct {
+	session int64
+	orders  map[int64]uint64 // 订单 ID → 已分配的最大序号，终态后删除
+	users   map[int64]uint64 // 用户 ID → 已分配的最大序号
+}
+
+func newEventSequencer() *eventSequencer {
+	return &eventSequencer{orders: make(map[int64]uint64), users: make(map[int64]uint64)}
+}
+
+// start 开始新会话
+func (s *eventSequencer) start(session int64) {
+	s.session = session
+	clear(s.orders)
+	clear(s.users)
+}
+
+// accept 受理 / 拒绝事件的序号
+// 被拒的订单不登记：它可能与挂单同 ID (RejectDuplicateOrderID)，不能覆盖挂单的计数
+func (s *eventSequencer) accept(o *Order) EventSeq {
+	if o.Status != OrderStatusRejected {
+		s.orders[o.ID] = 1
+	}
+	return EventSeq{Session: s.session, Order: 1, User: s.nextUser(o.UserID)}
+}
+
+// next 订单下一个事件的序号
+func (s *eventSequencer) next(o *Order) EventSeq {
+	n := max(s.orders[o.ID], 1) + 1
+	s.orders[o.ID] = n
+	return EventSeq{Session: s.session, Order: n, User: s.nextUser(o.UserID)}
+}
+
+func (s *eventSequencer) nextUser(userID int64) uint64 {
+	s.users[userID]++
+	return s.users[userID]
+}
+
+// done 订单进入终态，之后不会再有事件
+func (s *eventSequencer) done(orderID int64) {
+	delete(s.orders, orderID)
+}
@@ -0,0 +1,80 @@
+package mtrade
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// seqRecord 事件回调里拷贝出的序号（指针只在回调期间有效）
+type seqRecord struct {
+	typ     EventType
+	orderID int64
+	seq     EventSeq
+}
+
+func TestEngine_EventSeq(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	records := make(chan seqRecord, 64)
+	engine.OnEvent(func(ev Event) {
+		switch ev.Type {
+		case EventTrade:
+			records <- seqRecord{EventTrade, ev.Trade.TakerID, ev.Trade.TakerSeq}
+			records <- seqRecord{EventTrade, ev.Trade.MakerID, ev.Trade.MakerSeq}
+		case EventOrderAccepted, EventOrderRejected, EventOrderCanceled:
+			records <- seqRecord{ev.Type, ev.Order.ID, ev.OrderSeq}
+		}
+	})
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	ctx := context.Background()
+	submit := func(o *Order) {
+		t.Helper()
+		o.Symbol, o.Type = "BTC_USDT", OrderTypeLimit
+		if _, err := engine.SubmitOrderSync(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	submit(&Order{ID: 1, UserID: 7, Side: SideSell, Price: 100, Qty: 1})
+	submit(&Order{ID: 2, UserID: 7, Side: SideSell, Price: 101, Qty: 1})
+	submit(&Order{ID: 3, UserID: 8, Side: SideBuy, Price: 101, Qty: 3}) // 吃掉两笔，剩 1 挂单
+	submit(&Order{ID: 3, UserID: 9, Side: SideBuy, Price: 90, Qty: 1})  // 与挂单同 ID，被拒
+	if !engine.CancelOrder(3) {
+		t.Fatal("cancel rejected")
+	}
+
+	want := []struct {
+		typ     EventType
+		orderID int64
+		order   uint64
+		user    uint64
+	}{
+		{EventOrderAccepted, 1, 1, 1},
+		{EventOrderAccepted, 2, 1, 2},
+		{EventOrderAccepted, 3, 1, 1},
+		{EventTrade, 3, 2, 2},
+		{EventTrade, 1, 2, 3},
+		{EventTrade, 3, 3, 3},
+		{EventTrade, 2, 2, 4},
+		{EventOrderRejected, 3, 1, 1}, // 用户 9 的第一个事件，不影响挂单 3 的计数
+		{EventOrderCanceled, 3, 4, 4},
+	}
+	var session int64
+	for i, w := range want {
+		select {
+		case got := <-records:
+			if got.typ != w.typ || got.orderID != w.orderID || got.seq.Order != w.order || got.seq.User != w.user {
+				t.Fatalf("event %d = %+v, want %+v", i, got, w)
+			}
+			if session == 0 {
+				session = got.seq.Session
+			}
+			if got.seq.Session == 0 || got.seq.Session != session {
+				t.Fatalf("event %d session %d, want %d", i, got.seq.Session, session)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for event %d", i)
+		}
+	}
+}
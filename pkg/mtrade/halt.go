@@ -0,0 +1,35 @@
+package mtrade
+
+// =============================================================================
+// 暂停交易 (trading halt)
+// =============================================================================
+//
+// 【场景】交易时段之外 / 计划维护 (见 pkg/calendar)：不再撮合新订单，挂单留在簿上
+//
+// 与迁移冻结 (Freeze) 的区别：
+//   - Freeze 在入口直接返回 false，连撤单也拒，是几毫秒的交接窗口
+//   - Halt 只拒新订单，撤单照常；拒单在 matchLoop 内按顺序产生 RejectMarketHalted，
+//     下游处理器按普通拒单解冻资金，不需要额外的补偿逻辑
+//
+// 【注意】判断的是 matchLoop 处理订单的时刻：Halt 之前已入队、尚未处理的订单同样被拒，
+// 恢复之前不会有任何一笔成交
+
+// Halt 暂停交易：之后处理的新订单全部拒绝 (RejectMarketHalted)，撤单不受影响
+func (e *Engine) Halt() {
+	e.halted.Store(true)
+}
+
+// Resume 恢复交易
+func (e *Engine) Resume() {
+	e.halted.Store(false)
+}
+
+// Halted 是否处于暂停交易状态
+func (e *Engine) Halted() bool {
+	return e.halted.Load()
+}
+
+// HaltRejects 暂停期间被拒的订单数
+func (e *Engine) HaltRejects() int64 {
+	return e.haltRejects.Load()
+}
@@ -0,0 +1,53 @@
+package mtrade
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEngine_Halt(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	ctx := context.Background()
+	ask := &Order{ID: 1, UserID: 7, Side: SideSell, Price: 100, Qty: 5, Symbol: "BTC_USDT", Type: OrderTypeLimit}
+	if _, err := engine.SubmitOrderSync(ctx, ask); err != nil {
+		t.Fatal(err)
+	}
+
+	// 暂停后新订单被拒，挂单留在簿上
+	engine.Halt()
+	bid := &Order{ID: 2, UserID: 8, Side: SideBuy, Price: 100, Qty: 2, Symbol: "BTC_USDT", Type: OrderTypeLimit}
+	ack, err := engine.SubmitOrderSync(ctx, bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Accepted() || ack.RejectReason != RejectMarketHalted || len(ack.Trades) != 0 {
+		t.Fatalf("halted ack %+v", ack)
+	}
+	if !engine.Halted() || engine.HaltRejects() != 1 {
+		t.Fatalf("halted=%v rejects=%d", engine.Halted(), engine.HaltRejects())
+	}
+	if _, asks := engine.GetDepth(5); len(asks) != 1 || asks[0].Quantity != 5 {
+		t.Fatalf("resting asks %+v", asks)
+	}
+
+	// 撤单照常
+	if ids, err := engine.CancelAllByUser(ctx, ask.UserID); err != nil || len(ids) != 1 {
+		t.Fatalf("cancel during halt: %v %v", ids, err)
+	}
+	if _, asks := engine.GetDepth(5); len(asks) != 0 {
+		t.Fatalf("cancel during halt left %+v", asks)
+	}
+
+	// 恢复后正常接单
+	engine.Resume()
+	ack, err = engine.SubmitOrderSync(ctx, &Order{ID: 3, UserID: 8, Side: SideBuy, Price: 100, Qty: 2, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ack.Accepted() {
+		t.Fatalf("resumed ack %+v", ack)
+	}
+}
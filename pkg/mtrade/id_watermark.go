@@ -0,0 +1,69 @@
+package mtrade
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// =============================================================================
+// 订单 / 成交 ID 高水位
+// =============================================================================
+//
+// 【问题】ID 都是 "毫秒时间戳 + 序号"：进程重启后序号从 0 开始，时钟回拨 (NTP 校时、换机器)
+// 时新分配的 ID 可能与 WAL 恢复出的挂单 / 已发出的成交撞车，撞上挂单会覆盖订单簿索引
+//
+// 【做法】
+//   - 检查点记录截至当时见过的最大订单 ID / 成交 ID (IDWatermark，检查点版本 3)
+//   - 恢复时取 检查点高水位、检查点挂单、WAL 下单条目 三者的最大值，校验后抬高生成器：
+//     NextOrderID / Matcher 成交 ID 都取 max(时间戳 ID, 上一个 + 1)，只增不减
+//   - 入口拒绝与挂单同 ID 的新订单 (RejectDuplicateOrderID)，不写 WAL、不进撮合
+//   - 外部生成 ID 的处理器 (futures 用 order.GenerateOrderID) 启动时按 Engine.RecoveredIDs 抬高自己的生成器
+//
+// 【注意】WAL 里出现与挂单同 ID 的下单条目说明日志或检查点已损坏，恢复直接失败，不带病启动
+
+var (
+	// ErrDuplicateOrderID 恢复时 WAL 下单条目与订单簿上的挂单 ID 重复
+	ErrDuplicateOrderID = errors.New("duplicate order id")
+	// ErrIDWatermark 检查点挂单 ID 超过检查点记录的高水位
+	ErrIDWatermark = errors.New("order id above checkpoint watermark")
+)
+
+// IDWatermark 已分配的最大订单 ID / 成交 ID
+type IDWatermark struct {
+	OrderID int64
+	TradeID int64
+}
+
+// merge 逐项取较大者
+func (w IDWatermark) merge(o IDWatermark) IDWatermark {
+	return IDWatermark{OrderID: max(w.OrderID, o.OrderID), TradeID: max(w.TradeID, o.TradeID)}
+}
+
+// lastOrderID NextOrderID 已分配的最大 ID
+var lastOrderID atomic.Int64
+
+// AdvanceOrderID 保证之后 NextOrderID 分配的 ID 都大于 id
+func AdvanceOrderID(id int64) {
+	for {
+		last := lastOrderID.Load()
+		if id <= last || lastOrderID.CompareAndSwap(last, id) {
+			return
+		}
+	}
+}
+
+// claimOrderID 分配 max(candidate, 上一个 + 1)
+func claimOrderID(candidate int64) int64 {
+	for {
+		last := lastOrderID.Load()
+		id := max(candidate, last+1)
+		if lastOrderID.CompareAndSwap(last, id) {
+			return id
+		}
+	}
+}
+
+// RecoveredIDs WAL 恢复 / 迁移导入得到的 ID 高水位，NewEngine 之后不再变化
+func (e *Engine) RecoveredIDs() IDWatermark {
+	return e.recoveredIDs
+}
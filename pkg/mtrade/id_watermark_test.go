@@ -0,0 +1,81 @@
+package mtrade
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecover_IDWatermark(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := NewWAL(DefaultWALConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟时钟回拨：恢复出的 ID 比现在的时间戳 ID 大
+	ahead := time.Now().Add(time.Hour).UnixMilli() << 20
+	resting := []*Order{{ID: ahead, UserID: 1, Symbol: "BTC_USDT", Side: SideSell, Price: 100, Qty: 5}}
+	wal.WriteOrder(resting[0])
+	// 检查点高水位高于挂单：已成交离簿的订单 / 成交 ID
+	if err := wal.CreateCheckpoint(1, resting, IDWatermark{OrderID: ahead + 10, TradeID: ahead + 20}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Truncate()
+	wal.WriteOrder(&Order{ID: ahead + 30, UserID: 2, Symbol: "BTC_USDT", Side: SideBuy, Price: 99, Qty: 1})
+	wal.Close()
+
+	cfg := DefaultEngineConfig("BTC_USDT")
+	cfg.WALDir = dir
+	engine := mustNewEngine(t, cfg)
+	if ids := engine.RecoveredIDs(); ids.OrderID != ahead+30 || ids.TradeID != ahead+20 {
+		t.Fatalf("recovered %+v", ids)
+	}
+	if id := NextOrderID(); id <= ahead+30 {
+		t.Fatalf("NextOrderID %d not above watermark", id)
+	}
+
+	// 新成交 ID 接在检查点高水位之后；与挂单同 ID 的新订单被拒
+	engine.Start(context.Background())
+	defer engine.Stop()
+	ack, err := engine.SubmitOrderSync(context.Background(), &Order{ID: ahead + 40, UserID: 3, Symbol: "BTC_USDT", Side: SideBuy, Price: 100, Qty: 1})
+	if err != nil || len(ack.Trades) != 1 || ack.Trades[0].ID <= ahead+20 {
+		t.Fatalf("taker ack %+v err=%v", ack, err)
+	}
+	ack, err = engine.SubmitOrderSync(context.Background(), &Order{ID: ahead, UserID: 3, Symbol: "BTC_USDT", Side: SideBuy, Price: 90, Qty: 1})
+	if err != nil || ack.RejectReason != RejectDuplicateOrderID {
+		t.Fatalf("duplicate ack %+v err=%v", ack, err)
+	}
+}
+
+func TestRecover_DuplicateOrderIDFails(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := NewWAL(DefaultWALConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.WriteOrder(&Order{ID: 7, UserID: 1, Symbol: "BTC_USDT", Side: SideSell, Price: 100, Qty: 5})
+	wal.WriteOrder(&Order{ID: 7, UserID: 2, Symbol: "BTC_USDT", Side: SideSell, Price: 101, Qty: 5})
+	wal.Close()
+
+	cfg := DefaultEngineConfig("BTC_USDT")
+	cfg.WALDir = dir
+	if _, err := NewEngine(cfg); !errors.Is(err, ErrDuplicateOrderID) {
+		t.Fatalf("expected ErrDuplicateOrderID, got %v", err)
+	}
+}
+
+// 多个交易对共用资产引擎时按成交 ID 做结算幂等，不同撮合器的成交 ID 不能重复
+func TestTradeID_UniqueAcrossMatchers(t *testing.T) {
+	a, b := NewMatcher(NewOrderBook("BTC_USDT")), NewMatcher(NewOrderBook("ETH_USDT"))
+	seen := make(map[int64]bool)
+	for range 10000 {
+		for _, m := range []*Matcher{a, b} {
+			id := m.nextTradeID()
+			if seen[id] {
+				t.Fatalf("trade id %d issued twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
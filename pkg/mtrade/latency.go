@@ -0,0 +1,128 @@
+package mtrade
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// 延迟直方图 (HDR 风格)
+// =============================================================================
+//
+// 【面试高频】为什么不用平均值？撮合延迟是长尾分布，平均值会掩盖 p99/p999 毛刺
+//
+// 分桶方式（对数-线性，与 HdrHistogram 思路一致）：
+//   - 每个 2 的幂区间 [2^k, 2^(k+1)) 再线性切成 32 个子桶
+//   - 相对误差 ≤ 1/32 ≈ 3%，与数值大小无关
+//   - 桶数固定（~2K），记录是 O(1)，无内存分配
+//
+//   值(ns):   0..63     64..127    128..255   ...
+//   桶宽:       1          2          4       ...
+//
+// 并发模型：
+//   - 只有 matchLoop 写入（单写者）
+//   - GetStats 可在任意 goroutine 读取，全部字段使用原子操作
+
+const (
+	// latencySubBucketBits 每个 2 的幂区间的子桶数 = 2^5 = 32
+	latencySubBucketBits  = 5
+	latencySubBucketCount = 1 << latencySubBucketBits
+
+	// latencyBucketCount 覆盖 int64 全范围所需的桶数
+	latencyBucketCount = (64-latencySubBucketBits)*latencySubBucketCount + 2*latencySubBucketCount
+)
+
+// LatencyHistogram 延迟直方图
+type LatencyHistogram struct {
+	counts [latencyBucketCount]atomic.Uint64
+	total  atomic.Uint64
+	max    atomic.Int64
+}
+
+// NewLatencyHistogram 创建延迟直方图
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{}
+}
+
+// latencyBucketIndex 数值 → 桶下标
+func latencyBucketIndex(v int64) int {
+	if v < 2*latencySubBucketCount {
+		return int(v)
+	}
+	// shift 使得 v>>shift 落在 [32, 64)
+	shift := bits.Len64(uint64(v)) - latencySubBucketBits - 1
+	return shift*latencySubBucketCount + int(v>>shift)
+}
+
+// latencyBucketUpper 桶下标 → 该桶的上界（百分位取上界，宁可高估）
+func latencyBucketUpper(idx int) int64 {
+	if idx < 2*latencySubBucketCount {
+		return int64(idx)
+	}
+	shift := idx/latencySubBucketCount - 1
+	mantissa := int64(idx - shift*latencySubBucketCount)
+	return mantissa<<shift + (1<<shift - 1)
+}
+
+// Record 记录一次延迟
+func (h *LatencyHistogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	h.counts[latencyBucketIndex(v)].Add(1)
+	h.total.Add(1)
+
+	// 单写者，无需 CAS 循环
+	if v > h.max.Load() {
+		h.max.Store(v)
+	}
+}
+
+// Count 样本总数
+func (h *LatencyHistogram) Count() uint64 {
+	return h.total.Load()
+}
+
+// Max 最大延迟
+func (h *LatencyHistogram) Max() time.Duration {
+	return time.Duration(h.max.Load())
+}
+
+// Percentile 计算百分位延迟，q 取值 (0, 1]，如 0.99
+// 【注意】与写入并发时读到的是近似值，监控场景足够
+func (h *LatencyHistogram) Percentile(q float64) time.Duration {
+	total := h.total.Load()
+	if total == 0 {
+		return 0
+	}
+
+	target := uint64(q * float64(total))
+	if target == 0 {
+		target = 1
+	}
+
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		if cumulative >= target {
+			upper := latencyBucketUpper(i)
+			// 上界不超过实际最大值
+			if m := h.max.Load(); upper > m {
+				upper = m
+			}
+			return time.Duration(upper)
+		}
+	}
+	return h.Max()
+}
+
+// Reset 清空直方图
+func (h *LatencyHistogram) Reset() {
+	for i := range h.counts {
+		h.counts[i].Store(0)
+	}
+	h.total.Store(0)
+	h.max.Store(0)
+}
@@ -0,0 +1,254 @@
+package mtrade
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 延迟直方图测试
+// =============================================================================
+
+func TestLatencyHistogram_BucketPrecision(t *testing.T) {
+	// 任意数值落桶后，上界 >= 原值，且相对误差 <= 1/32
+	for v := int64(0); v < 1<<20; v += 7 {
+		upper := latencyBucketUpper(latencyBucketIndex(v))
+		if upper < v {
+			t.Fatalf("v=%d: bucket upper %d < value", v, upper)
+		}
+		if v > 0 && float64(upper-v)/float64(v) > 1.0/latencySubBucketCount {
+			t.Fatalf("v=%d: bucket upper %d exceeds relative error", v, upper)
+		}
+	}
+
+	// 大数值不越界
+	for _, v := range []int64{1 << 40, 1<<62 + 12345, 1<<63 - 1} {
+		if idx := latencyBucketIndex(v); idx >= latencyBucketCount {
+			t.Fatalf("v=%d: index %d out of range", v, idx)
+		}
+	}
+}
+
+func TestLatencyHistogram_Percentiles(t *testing.T) {
+	h := NewLatencyHistogram()
+
+	// 1µs ~ 10000µs 均匀分布
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	if h.Count() != 10000 {
+		t.Fatalf("expected 10000 samples, got %d", h.Count())
+	}
+
+	cases := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.50, 5000 * time.Microsecond},
+		{0.99, 9900 * time.Microsecond},
+		{0.999, 9990 * time.Microsecond},
+	}
+	for _, c := range cases {
+		got := h.Percentile(c.q)
+		diff := float64(got-c.want) / float64(c.want)
+		if diff < 0 || diff > 1.0/latencySubBucketCount {
+			t.Errorf("p%v: expected ~%v, got %v", c.q*100, c.want, got)
+		}
+	}
+
+	if h.Max() != 10000*time.Microsecond {
+		t.Errorf("expected max 10ms, got %v", h.Max())
+	}
+
+	h.Reset()
+	if h.Count() != 0 || h.Percentile(0.99) != 0 {
+		t.Errorf("expected empty histogram after reset")
+	}
+}
+
+func TestEngine_LatencyStats(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	for i := 0; i < 100; i++ {
+		engine.SubmitOrder(&Order{
+			ID:     int64(i + 1),
+			Side:   SideBuy,
+			Price:  int64(50000 - i),
+			Qty:    10,
+			Symbol: "BTC_USDT",
+			Type:   OrderTypeLimit,
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stats := engine.GetStats()
+	if stats.LatencySamples != 100 {
+		t.Fatalf("expected 100 latency samples, got %d", stats.LatencySamples)
+	}
+	if stats.LatencyP50 <= 0 || stats.LatencyP50 > stats.LatencyP99 ||
+		stats.LatencyP99 > stats.LatencyP999 || stats.LatencyP999 > stats.LatencyMax {
+		t.Errorf("percentiles not ordered: p50=%v p99=%v p999=%v max=%v",
+			stats.LatencyP50, stats.LatencyP99, stats.LatencyP999, stats.LatencyMax)
+	}
+
+	engine.ResetLatency()
+	if engine.GetStats().LatencySamples != 0 {
+		t.Errorf("expected latency reset")
+	}
+}
+
+// =============================================================================
+// 撮合延迟基准测试
+// =============================================================================
+//
+// 直接驱动 Matcher（不经过 channel），单笔订单用单调时钟计时，
+// 除 ns/op 外额外输出 p50/p99/p999，便于发现长尾回归：
+//
+//   go test ./pkg/mtrade -run=^$ -bench=Latency -benchtime=200000x
+
+// reportLatency 把直方图百分位输出到 benchmark 结果
+func reportLatency(b *testing.B, h *LatencyHistogram) {
+	b.ReportMetric(float64(h.Percentile(0.50).Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(h.Percentile(0.99).Nanoseconds()), "p99-ns")
+	b.ReportMetric(float64(h.Percentile(0.999).Nanoseconds()), "p999-ns")
+}
+
+// BenchmarkLatency_LimitCross 限价单吃单：每笔只成交最优档
+func BenchmarkLatency_LimitCross(b *testing.B) {
+	ob := NewOrderBook("BTC_USDT")
+	matcher := NewMatcher(ob)
+	h := NewLatencyHistogram()
+
+	for i := 0; i < 100; i++ {
+		ob.AddOrder(&Order{
+			ID:     int64(i + 1),
+			Side:   SideSell,
+			Price:  int64(50000 + i),
+			Qty:    1 << 40, // 足够大，不会被吃完
+			Symbol: "BTC_USDT",
+		})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		taker := &Order{
+			ID:     int64(1_000_000 + i),
+			Side:   SideBuy,
+			Price:  50000,
+			Qty:    10,
+			Symbol: "BTC_USDT",
+			Type:   OrderTypeLimit,
+		}
+		start := time.Now()
+		result := matcher.ProcessOrder(taker)
+		h.Record(time.Since(start))
+		PutMatchResult(result)
+	}
+	b.StopTimer()
+	reportLatency(b, h)
+}
+
+// BenchmarkLatency_DeepBookSweep 大单扫穿 50 档深度
+func BenchmarkLatency_DeepBookSweep(b *testing.B) {
+	const levels, perLevel = 50, 4
+
+	ob := NewOrderBook("BTC_USDT")
+	matcher := NewMatcher(ob)
+	h := NewLatencyHistogram()
+	nextID := int64(1)
+
+	refill := func() {
+		for l := 0; l < levels; l++ {
+			for k := 0; k < perLevel; k++ {
+				ob.AddOrder(&Order{
+					ID:     nextID,
+					Side:   SideSell,
+					Price:  int64(50000 + l),
+					Qty:    10,
+					Symbol: "BTC_USDT",
+				})
+				nextID++
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		refill()
+		taker := &Order{
+			ID:     nextID,
+			Side:   SideBuy,
+			Price:  int64(50000 + levels),
+			Qty:    levels * perLevel * 10,
+			Symbol: "BTC_USDT",
+			Type:   OrderTypeIOC,
+		}
+		nextID++
+		b.StartTimer()
+
+		start := time.Now()
+		result := matcher.ProcessOrder(taker)
+		h.Record(time.Since(start))
+		PutMatchResult(result)
+	}
+	b.StopTimer()
+	reportLatency(b, h)
+}
+
+// BenchmarkLatency_CancelHeavy 撤单密集：90% 撤单 + 10% 挂单（做市商典型流量）
+func BenchmarkLatency_CancelHeavy(b *testing.B) {
+	ob := NewOrderBook("BTC_USDT")
+	matcher := NewMatcher(ob)
+	h := NewLatencyHistogram()
+
+	// 1000 笔挂单，分布在 100 个价位
+	live := make([]int64, 0, 2048)
+	nextID := int64(1)
+	place := func() {
+		order := &Order{
+			ID:     nextID,
+			Side:   SideBuy,
+			Price:  int64(50000 - nextID%100),
+			Qty:    10,
+			Symbol: "BTC_USDT",
+			Type:   OrderTypeLimit,
+		}
+		PutMatchResult(matcher.ProcessOrder(order))
+		live = append(live, nextID)
+		nextID++
+	}
+	for i := 0; i < 1000; i++ {
+		place()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if i%10 == 0 || len(live) == 0 {
+			place()
+		} else {
+			// 撤最早的挂单，模拟报价刷新
+			ob.CancelOrder(live[0])
+			live = live[1:]
+		}
+		h.Record(time.Since(start))
+
+		if len(live) < 500 {
+			b.StopTimer()
+			for j := 0; j < 500; j++ {
+				place()
+			}
+			b.StartTimer()
+		}
+	}
+	b.StopTimer()
+	reportLatency(b, h)
+}
@@ -0,0 +1,146 @@
+package mtrade
+
+// =============================================================================
+// 侵入式双向链表 PriceLevel
+// =============================================================================
+//
+// 【面试进阶】为什么从 Ring Buffer 换成侵入式链表？
+//
+// RingPriceLevel 的问题：
+//   头部弹出 O(1)，但撤单要先线性查找、再搬移元素，O(n)
+//   HFT 做市商大量 "撤单-重挂"，深档位上撤单成为瓶颈
+//
+// 侵入式链表：
+//   链表指针直接放在 Order 里（prev/next/level），不额外分配节点
+//   撤单时通过 orderIndex 拿到 *Order，直接摘链，O(1)
+//
+//   level.head ⇄ order1 ⇄ order2 ⇄ order3 ⇄ level.tail
+//
+// 代价：撮合遍历时指针跳转，不如连续数组 cache 友好
+// 但同一价位的订单数通常不多，撤单收益远大于遍历损失
+
+// PriceLevel 价格档位（侵入式双向链表，FIFO）
+// 【注意】TotalQty / count 在挂单、成交、撤单时增量维护，深度查询直接读取
+type PriceLevel struct {
+	Price    int64 // 价格
+	TotalQty int64 // 剩余挂单总量（聚合值）
+
+	head  *Order // 队首（最早的订单）
+	tail  *Order // 队尾
+	count int    // 订单数量
+
+	queueDirty bool // 本轮已登记待重算队列位置（见 queue_position.go）
+}
+
+// NewPriceLevel 创建价格档位
+func NewPriceLevel(price int64) *PriceLevel {
+	return &PriceLevel{Price: price}
+}
+
+// AddOrder 添加订单到队尾
+// 时间复杂度：O(1)
+func (pl *PriceLevel) AddOrder(order *Order) {
+	order.level = pl
+	order.prev = pl.tail
+	order.next = nil
+
+	if pl.tail != nil {
+		pl.tail.next = order
+	} else {
+		pl.head = order
+	}
+	pl.tail = order
+
+	pl.count++
+	pl.TotalQty += order.RemainingQty()
+}
+
+// PopFront 弹出队首订单
+// 时间复杂度：O(1)
+func (pl *PriceLevel) PopFront() *Order {
+	order := pl.head
+	if order == nil {
+		return nil
+	}
+	pl.Remove(order)
+	return order
+}
+
+// Front 获取队首订单（不移除）
+// 时间复杂度：O(1)
+func (pl *PriceLevel) Front() *Order {
+	return pl.head
+}
+
+// Remove 从队列中摘除指定订单
+// 【面试核心】时间复杂度：O(1)，无需查找
+func (pl *PriceLevel) Remove(order *Order) {
+	if order.level != pl {
+		return // 不在本档位
+	}
+
+	if order.prev != nil {
+		order.prev.next = order.next
+	} else {
+		pl.head = order.next
+	}
+	if order.next != nil {
+		order.next.prev = order.prev
+	} else {
+		pl.tail = order.prev
+	}
+
+	order.prev, order.next, order.level = nil, nil, nil
+	pl.count--
+	pl.TotalQty -= order.RemainingQty()
+}
+
+// RemoveOrder 按订单 ID 移除（兼容 RingPriceLevel 的接口）
+// 时间复杂度：O(n)，订单簿内部走 Remove
+func (pl *PriceLevel) RemoveOrder(orderID int64) *Order {
+	for o := pl.head; o != nil; o = o.next {
+		if o.ID == orderID {
+			pl.Remove(o)
+			return o
+		}
+	}
+	return nil
+}
+
+// Fill 记录档位内订单成交，同步扣减聚合数量
+// 【注意】撮合修改 maker.FilledQty 时必须同时调用，否则深度数量会偏大
+func (pl *PriceLevel) Fill(qty int64) {
+	pl.TotalQty -= qty
+}
+
+// Len 返回订单数量
+func (pl *PriceLevel) Len() int {
+	return pl.count
+}
+
+// IsEmpty 是否为空
+func (pl *PriceLevel) IsEmpty() bool {
+	return pl.count == 0
+}
+
+// MatchQty 计算可成交数量
+func (pl *PriceLevel) MatchQty(takerQty int64) int64 {
+	if takerQty >= pl.TotalQty {
+		return pl.TotalQty
+	}
+	return takerQty
+}
+
+// ForEach 按时间优先顺序遍历所有订单
+func (pl *PriceLevel) ForEach(fn func(*Order)) {
+	for o := pl.head; o != nil; {
+		next := o.next // 允许 fn 中摘除当前订单
+		fn(o)
+		o = next
+	}
+}
+
+// reset 清空档位（TickIndex 复用节点时调用）
+func (pl *PriceLevel) reset(price int64) {
+	*pl = PriceLevel{Price: price}
+}
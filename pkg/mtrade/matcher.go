@@ -2,7 +2,10 @@ package mtrade
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"max.com/pkg/fixed"
 )
 
 // =============================================================================
@@ -14,6 +17,7 @@ var matchResultPool = sync.Pool{
 	New: func() interface{} {
 		return &MatchResult{
 			Trades: make([]Trade, 0, 8), // 预分配容量
+			makers: make([]*Order, 0, 8),
 		}
 	},
 }
@@ -23,10 +27,13 @@ func getMatchResult() *MatchResult {
 	result := matchResultPool.Get().(*MatchResult)
 	// 重置状态
 	result.Trades = result.Trades[:0]
+	result.makers = result.makers[:0]
 	result.TakerOrder = nil
 	result.FilledQty = 0
+	result.FilledQuote = 0
 	result.RemainingQty = 0
 	result.FullyFilled = false
+	result.RejectReason = RejectNone
 	return result
 }
 
@@ -53,6 +60,16 @@ type Trade struct {
 	MakerID   int64  // Maker 订单 ID
 	TakerSide Side   // Taker 方向
 	Timestamp int64  // 成交时间
+	BookSeq   uint64 // 成交后的订单簿序列号
+	Epoch     uint64 // 产生成交的撮合实例纪元（见 epoch.go）
+
+	// 双方订单的事件序号，仅成交事件里的 Trade 带（见 event_seq.go）
+	TakerSeq EventSeq
+	MakerSeq EventSeq
+
+	// 双方的客户端订单号（未设置为空），客户端据此关联自己的订单
+	TakerClientOrderID string
+	MakerClientOrderID string
 }
 
 // =============================================================================
@@ -64,8 +81,14 @@ type MatchResult struct {
 	Trades       []Trade // 成交记录
 	TakerOrder   *Order  // Taker 订单（更新后）
 	FilledQty    int64   // 本次成交总量
+	FilledQuote  int64   // 本次成交金额（仅按金额下单的订单）
 	RemainingQty int64   // 剩余未成交量
 	FullyFilled  bool    // 是否完全成交
+
+	RejectReason RejectReason // 拒单原因（仅 OrderStatusRejected 时有意义）
+
+	// makers 与 Trades 一一对应的 Maker 订单（引擎用于回收被吃完的池化订单）
+	makers []*Order
 }
 
 // =============================================================================
@@ -76,7 +99,7 @@ type MatchResult struct {
 // 【面试核心】实现价格优先、时间优先的撮合算法
 type Matcher struct {
 	orderBook *OrderBook
-	tradeSeq  int64 // 成交序列号
+	ids       IDWatermark // 见过的最大订单 ID / 已分配的最大成交 ID (见 id_watermark.go)
 }
 
 // NewMatcher 创建撮合器
@@ -108,9 +131,9 @@ func (m *Matcher) Match(taker *Order) *MatchResult {
 	oppositeIndex := m.orderBook.GetOppositeIndex(taker.Side)
 
 	// 循环撮合，直到：
-	// 1. Taker 订单完全成交
+	// 1. Taker 订单完全成交（按金额下单：剩余预算买不起一个最小单位）
 	// 2. 对手盘没有可成交的订单
-	for taker.RemainingQty() > 0 {
+	for {
 		// 获取对手盘最优价格
 		bestNode := oppositeIndex.First()
 		if bestNode == nil {
@@ -121,6 +144,9 @@ func (m *Matcher) Match(taker *Order) *MatchResult {
 		if !m.canMatch(taker, bestNode.GetPrice()) {
 			break // 价格不匹配
 		}
+		if taker.matchableQty(bestNode.GetPrice()) <= 0 {
+			break // 已完全成交
+		}
 
 		// 撮合这个价位
 		m.matchAtLevel(taker, bestNode.GetLevel(), result)
@@ -132,12 +158,16 @@ func (m *Matcher) Match(taker *Order) *MatchResult {
 	}
 
 	// 更新结果
+	if taker.QuoteQty > 0 {
+		m.finishQuoteOrder(taker, oppositeIndex, result)
+	} else {
+		result.FullyFilled = taker.IsFilled()
+	}
 	result.FilledQty = taker.FilledQty
 	result.RemainingQty = taker.RemainingQty()
-	result.FullyFilled = taker.IsFilled()
 
 	// 更新 Taker 状态
-	if taker.IsFilled() {
+	if result.FullyFilled {
 		taker.Status = OrderStatusFilled
 	} else if taker.FilledQty > 0 {
 		taker.Status = OrderStatusPartiallyFilled
@@ -161,17 +191,25 @@ func (m *Matcher) canMatch(taker *Order, makerPrice int64) bool {
 
 // matchAtLevel 在一个价位上撮合
 // 【面试】时间优先：FIFO 队列
-func (m *Matcher) matchAtLevel(taker *Order, level *RingPriceLevel, result *MatchResult) {
-	for taker.RemainingQty() > 0 && !level.IsEmpty() {
+func (m *Matcher) matchAtLevel(taker *Order, level *PriceLevel, result *MatchResult) {
+	for !level.IsEmpty() {
 		// 获取队首订单（最早的 Maker）
 		maker := level.Front()
 
 		// 计算成交数量
-		matchQty := min(taker.RemainingQty(), maker.RemainingQty())
+		matchQty := min(taker.matchableQty(maker.Price), maker.RemainingQty())
+		if matchQty <= 0 {
+			break
+		}
 
 		// 更新订单
 		taker.FilledQty += matchQty
+		if taker.QuoteQty > 0 {
+			taker.FilledQuote += fixed.Mul(maker.Price, matchQty)
+		}
 		maker.FilledQty += matchQty
+		level.Fill(matchQty) // 同步维护档位聚合数量
+		m.orderBook.trackExposureFill(maker, matchQty)
 
 		// 生成成交记录
 		trade := Trade{
@@ -183,24 +221,54 @@ func (m *Matcher) matchAtLevel(taker *Order, level *RingPriceLevel, result *Matc
 			MakerID:   maker.ID,
 			TakerSide: taker.Side,
 			Timestamp: time.Now().UnixNano(),
+
+			TakerClientOrderID: taker.ClientOrderID,
+			MakerClientOrderID: maker.ClientOrderID,
 		}
 		result.Trades = append(result.Trades, trade)
+		result.makers = append(result.makers, maker)
 
 		// 如果 Maker 完全成交，从队列移除
 		if maker.IsFilled() {
 			maker.Status = OrderStatusFilled
 			level.PopFront()
-			delete(m.orderBook.orderIndex, maker.ID)
+			m.orderBook.forget(maker)
 		} else {
 			maker.Status = OrderStatusPartiallyFilled
 		}
+
+		// 档位数量变化，推进订单簿序列号
+		result.Trades[len(result.Trades)-1].BookSeq = m.orderBook.touch(maker.Side, level)
 	}
 }
 
+// tradeSeq 成交序列号，进程内所有撮合器共享：
+// 多个交易对共用一个资产引擎时按成交 ID 做结算幂等，各撮合器各自计数会在同一毫秒生成相同的 ID，
+// 后结算的那笔被当成重复命令丢掉
+var tradeSeq atomic.Int64
+
 // nextTradeID 生成成交 ID
+// 单调递增：时钟回拨或恢复出更大的高水位时取 上一个 + 1
 func (m *Matcher) nextTradeID() int64 {
-	m.tradeSeq++
-	return time.Now().UnixNano()/1000000<<20 | (m.tradeSeq & 0xFFFFF)
+	seq := tradeSeq.Add(1)
+	id := max(time.Now().UnixNano()/1000000<<20|(seq&0xFFFFF), m.ids.TradeID+1)
+	m.ids.TradeID = id
+	return id
+}
+
+// advanceIDs 抬高 ID 高水位 (恢复 / 迁移导入后调用)
+func (m *Matcher) advanceIDs(w IDWatermark) {
+	m.ids = m.ids.merge(w)
+}
+
+// rejectOrder 未经撮合直接拒单的结果
+func rejectOrder(order *Order, reason RejectReason) *MatchResult {
+	result := getMatchResult()
+	result.TakerOrder = order
+	result.RemainingQty = order.RemainingQty()
+	result.RejectReason = reason
+	order.Status = OrderStatusRejected
+	return result
 }
 
 // =============================================================================
@@ -210,6 +278,25 @@ func (m *Matcher) nextTradeID() int64 {
 // ProcessOrder 处理订单（完整流程）
 // 【面试】根据订单类型决定撮合后的行为
 func (m *Matcher) ProcessOrder(order *Order) *MatchResult {
+	m.ids.OrderID = max(m.ids.OrderID, order.ID)
+
+	// 0. 按金额下单只支持市价单
+	if order.QuoteQty != 0 && !validQuoteOrder(order) {
+		return rejectOrder(order, RejectInvalidQuoteQty)
+	}
+
+	// 0.1 价格带校验：挂不上的订单在撮合前拒绝，避免成交一半后无法挂单
+	if order.Type != OrderTypeMarket && !m.orderBook.ValidPrice(order.Price) {
+		return rejectOrder(order, RejectPriceBand)
+	}
+
+	// 0.2 挂单上限：按当前挂单数判断，超限直接拒绝（见 book_limits.go）
+	if restsOnBook(order.Type) {
+		if reason := m.orderBook.admit(order); reason != RejectNone {
+			return rejectOrder(order, reason)
+		}
+	}
+
 	// 1. 尝试撮合
 	result := m.Match(order)
 
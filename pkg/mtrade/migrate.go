@@ -0,0 +1,456 @@
+package mtrade
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"time"
+)
+
+// =============================================================================
+// 交易对迁移 (symbol migration)
+// =============================================================================
+//
+// 【场景】拆分负载时把一个交易对从引擎实例 A 搬到实例 B，停顿只有冻结期间的几毫秒
+//
+// 协议：
+//   源 A                                  目标 B
+//   1. Freeze: 入口拒单，等待已入队的订单处理完
+//   2. ExportMigration: 刷 WAL，打包
+//      最近检查点 + 之后的 WAL 尾部，
+//      发出 EventMigration(Handoff)  ───►  3. NewEngine (空订单簿) → ImportMigration:
+//                                            重放检查点 + 尾部，校验挂单数，
+//                                            订单簿序列号接上 A，纪元 = A 的纪元 + 1，
+//                                            在自己的 WAL 落检查点
+//                                         4. Start: 先发 EventMigration(Resume)，再接单
+//   5. 网关把该交易对的流量切到 B，A 保持冻结直到下线 (中途放弃用 Unfreeze 恢复)
+//
+// 【下游去重】两个标记携带同一个 BookSeq：
+//   - Handoff 之后 A 不会再发该交易对的事件；
+//   - B 的事件 Seq 从 BookSeq 之后继续，纪元比 A 大，epoch.Fence 推进到 Resume 的纪元即可拒绝 A 的迟到事件；
+//   - 消费者看到 Resume 且 BookSeq 与 Handoff 一致，即可确认中间没有空洞、也没有重复
+
+var (
+	// ErrEngineFrozen 交易对迁移中，入口已冻结
+	ErrEngineFrozen = errors.New("engine frozen for migration")
+	// ErrNotFrozen 导出前必须先 Freeze
+	ErrNotFrozen = errors.New("engine not frozen")
+	// ErrBookNotEmpty 导入目标的订单簿必须为空
+	ErrBookNotEmpty = errors.New("target order book not empty")
+	// ErrMigrationMismatch 目标重放结果与源不一致
+	ErrMigrationMismatch = errors.New("migration replay mismatch")
+	// ErrInvalidBundle 迁移包格式错误
+	ErrInvalidBundle = errors.New("invalid migration bundle")
+)
+
+// MigrationPhase 迁移标记类型
+type MigrationPhase uint8
+
+const (
+	MigrationHandoff MigrationPhase = iota + 1 // 源引擎交出交易对，之后不再发事件
+	MigrationResume                            // 目标引擎接管，之后的事件接着 BookSeq
+)
+
+func (p MigrationPhase) String() string {
+	switch p {
+	case MigrationHandoff:
+		return "HANDOFF"
+	case MigrationResume:
+		return "RESUME"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MigrationMarker 序列连续性标记（随 EventMigration 发布）
+type MigrationMarker struct {
+	Phase      MigrationPhase
+	Symbol     string
+	Source     string // 源实例标识
+	Target     string // 目标实例标识
+	BookSeq    uint64 // 交接时的订单簿序列号
+	WALSeq     int64  // 交接时的 WAL 序列号
+	Epoch      uint64 // 发布方的纪元
+	OpenOrders int    // 交接时的挂单数
+}
+
+// MigrationBundle 源引擎导出的迁移包
+type MigrationBundle struct {
+	Symbol     string
+	Source     string
+	Target     string
+	Epoch      uint64
+	BookSeq    uint64
+	WALSeq     int64
+	OpenOrders int // 源订单簿挂单数，目标重放后校验
+
+	CheckpointSeq int64      // 检查点对应的 WAL 序列号
+	Checkpoint    []*Order   // 检查点中的挂单
+	Tail          []WALEntry // 检查点之后的 WAL 条目
+
+	IDs IDWatermark // 源的订单 / 成交 ID 高水位，目标从这之上分配（见 id_watermark.go）
+}
+
+// =============================================================================
+// 源：冻结与导出
+// =============================================================================
+
+// Frozen 入口是否已冻结
+func (e *Engine) Frozen() bool {
+	return e.frozen.Load()
+}
+
+// Freeze 冻结入口并等待已入队的下单 / 撤单处理完
+//
+// 返回后 matchLoop 空闲，订单簿不再变化；ctx 到期时保持冻结并返回错误，由调用方决定 Unfreeze
+func (e *Engine) Freeze(ctx context.Context) error {
+	e.frozen.Store(true)
+	for e.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("drain intake: %w", ctx.Err())
+		case <-e.stopCh:
+			return ErrEngineStopped
+		case <-time.After(50 * time.Microsecond):
+		}
+	}
+	return nil
+}
+
+// Unfreeze 放弃迁移，恢复接单
+func (e *Engine) Unfreeze() {
+	e.frozen.Store(false)
+}
+
+// ExportMigration 打包检查点 + WAL 尾部并发出 Handoff 标记，必须在 Freeze 之后调用
+//
+// 未启用 WAL 时直接用当前订单簿作为检查点，尾部为空
+func (e *Engine) ExportMigration(source, target string) (*MigrationBundle, error) {
+	if !e.frozen.Load() || e.inflight.Load() > 0 {
+		return nil, ErrNotFrozen
+	}
+
+	b := &MigrationBundle{
+		Symbol:     e.config.Symbol,
+		Source:     source,
+		Target:     target,
+		Epoch:      e.epoch.Load(),
+		BookSeq:    e.orderBook.Seq(),
+		OpenOrders: len(e.orderBook.orderIndex),
+		IDs:        e.matcher.ids, // 已冻结，matchLoop 空闲
+	}
+	if e.wal == nil {
+		for _, o := range e.orderBook.GetAllOrders() {
+			b.Checkpoint = append(b.Checkpoint, decodeOrder(appendOrder(nil, o))) // 拷贝不带档位链表指针
+		}
+	} else {
+		if err := e.wal.Sync(); err != nil {
+			return nil, fmt.Errorf("sync WAL: %w", err)
+		}
+		cp, err := e.wal.LoadCheckpoint()
+		if err != nil {
+			return nil, fmt.Errorf("load checkpoint: %w", err)
+		}
+		entries, err := e.wal.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("read WAL: %w", err)
+		}
+		b.CheckpointSeq, b.Checkpoint, b.WALSeq = cp.Seq, cp.Orders, e.wal.GetSequence()
+		for _, entry := range entries {
+			if entry.Sequence > cp.Seq {
+				b.Tail = append(b.Tail, entry)
+			}
+		}
+	}
+
+	e.publishCriticalEvent(Event{
+		Type:      EventMigration,
+		Timestamp: time.Now().UnixNano(),
+		Seq:       b.BookSeq,
+		Migration: b.marker(MigrationHandoff, b.Epoch),
+	})
+	return b, nil
+}
+
+func (b *MigrationBundle) marker(phase MigrationPhase, epoch uint64) *MigrationMarker {
+	return &MigrationMarker{
+		Phase:      phase,
+		Symbol:     b.Symbol,
+		Source:     b.Source,
+		Target:     b.Target,
+		BookSeq:    b.BookSeq,
+		WALSeq:     b.WALSeq,
+		Epoch:      epoch,
+		OpenOrders: b.OpenOrders,
+	}
+}
+
+// =============================================================================
+// 目标：导入
+// =============================================================================
+
+// ImportMigration 重放迁移包接管交易对，必须在 Start 之前、订单簿为空时调用
+//
+// 成功后纪元为 max(当前, 源) + 1，Start 时先发布 Resume 标记
+func (e *Engine) ImportMigration(b *MigrationBundle) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.started {
+		return ErrEngineStarted
+	}
+	if b.Symbol != e.config.Symbol {
+		return fmt.Errorf("%w: bundle symbol %s, engine %s", ErrMigrationMismatch, b.Symbol, e.config.Symbol)
+	}
+	if len(e.orderBook.orderIndex) > 0 {
+		return ErrBookNotEmpty
+	}
+
+	// 与 WALRecovery 相同的重放规则：检查点挂单直接入簿，尾部重新撮合
+	// 检查点里的挂单无序，按 (CreatedAt, ID) 入簿恢复同价位的时间优先
+	orders := make([]*Order, len(b.Checkpoint))
+	for i, o := range b.Checkpoint {
+		orders[i] = decodeOrder(appendOrder(nil, o))
+	}
+	slices.SortFunc(orders, func(a, b *Order) int {
+		if c := cmp.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, o := range orders {
+		e.orderBook.AddOrder(o)
+		e.clientOrders.claim(o, e.isResting)
+	}
+	for _, entry := range b.Tail {
+		switch entry.Type {
+		case EntryPlaceOrder:
+			o := decodeOrder(entry.Data)
+			e.clientOrders.claim(o, e.isResting)
+			e.matcher.ProcessOrder(o)
+		case EntryCancelOrder:
+			e.orderBook.CancelOrder(int64(binary.LittleEndian.Uint64(entry.Data)))
+		}
+	}
+	if n := len(e.orderBook.orderIndex); n != b.OpenOrders {
+		return fmt.Errorf("%w: replayed %d open orders, source has %d", ErrMigrationMismatch, n, b.OpenOrders)
+	}
+
+	// 重放产生的增量不推送，序列号接上源
+	e.orderBook.TakeUpdates()
+	e.orderBook.seq = b.BookSeq
+
+	// ID 高水位接上源：检查点挂单没经过 Matcher，一并计入
+	for _, o := range orders {
+		e.matcher.ids.OrderID = max(e.matcher.ids.OrderID, o.ID)
+	}
+	e.matcher.advanceIDs(b.IDs)
+	e.recoveredIDs = e.matcher.ids
+	AdvanceOrderID(e.recoveredIDs.OrderID)
+
+	if e.wal != nil {
+		e.wal.sequence = max(e.wal.sequence, b.WALSeq)
+		if err := e.wal.CreateCheckpoint(e.wal.sequence, e.orderBook.GetAllOrders(), e.matcher.ids); err != nil {
+			return fmt.Errorf("write checkpoint: %w", err)
+		}
+		if err := e.wal.Truncate(); err != nil {
+			return fmt.Errorf("truncate WAL: %w", err)
+		}
+	}
+	epoch := max(e.epoch.Load(), b.Epoch) + 1
+	if err := e.setEpoch(epoch); err != nil {
+		return err
+	}
+
+	if e.queue != nil {
+		e.publishQueueUpdates()
+	}
+	e.orderBook.UpdateSnapshot()
+
+	e.resumeMarker = b.marker(MigrationResume, epoch)
+	return nil
+}
+
+// publishResumeMarker Start 时先于任何订单事件发布 Resume 标记
+func (e *Engine) publishResumeMarker() {
+	if e.resumeMarker == nil {
+		return
+	}
+	e.publishCriticalEvent(Event{
+		Type:      EventMigration,
+		Timestamp: time.Now().UnixNano(),
+		Seq:       e.resumeMarker.BookSeq,
+		Migration: e.resumeMarker,
+	})
+	e.resumeMarker = nil
+}
+
+// =============================================================================
+// 迁移包编码（跨进程传输）
+// =============================================================================
+//
+// Magic(4) "MIG1" + Header + Checkpoint 订单 (与 checkpoint 文件相同的格式，见 appendOrder)
+// + Tail 条目 (与 WAL 文件相同格式，读取时校验 CRC)
+
+const migrationMagic = 0x4d494731 // "MIG1"
+
+// WriteTo 编码迁移包
+func (b *MigrationBundle) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	le := binary.LittleEndian
+
+	put := func(v any) {
+		if cw.err == nil {
+			cw.err = binary.Write(cw, le, v)
+		}
+	}
+	putString := func(s string) {
+		put(uint16(len(s)))
+		if cw.err == nil {
+			_, cw.err = cw.Write([]byte(s))
+		}
+	}
+
+	put(uint32(migrationMagic))
+	putString(b.Symbol)
+	putString(b.Source)
+	putString(b.Target)
+	put(b.Epoch)
+	put(b.BookSeq)
+	put(b.WALSeq)
+	put(int64(b.OpenOrders))
+	put(b.CheckpointSeq)
+
+	put(uint64(len(b.Checkpoint)))
+	for _, o := range b.Checkpoint {
+		if cw.err == nil {
+			_, cw.err = cw.Write(appendOrder(nil, o))
+		}
+	}
+
+	put(uint64(len(b.Tail)))
+	for i := range b.Tail {
+		entry := &b.Tail[i]
+		put(entry.Sequence)
+		put(entry.Timestamp)
+		put(uint8(entry.Type))
+		put(uint32(len(entry.Data)))
+		if cw.err == nil {
+			_, cw.err = cw.Write(entry.Data)
+		}
+		put(entry.Checksum)
+	}
+
+	// ID 高水位放在末尾，旧版本的包没有这一段
+	put(b.IDs.OrderID)
+	put(b.IDs.TradeID)
+
+	if cw.err == nil {
+		cw.err = bw.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ReadMigrationBundle 解码迁移包
+func ReadMigrationBundle(r io.Reader) (*MigrationBundle, error) {
+	br := bufio.NewReader(r)
+	le := binary.LittleEndian
+	var err error
+	get := func(v any) {
+		if err == nil {
+			err = binary.Read(br, le, v)
+		}
+	}
+	getString := func() string {
+		var n uint16
+		get(&n)
+		if err != nil {
+			return ""
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(br, buf)
+		return string(buf)
+	}
+
+	var magic uint32
+	get(&magic)
+	if err == nil && magic != migrationMagic {
+		return nil, fmt.Errorf("%w: bad magic %#x", ErrInvalidBundle, magic)
+	}
+	b := &MigrationBundle{}
+	b.Symbol, b.Source, b.Target = getString(), getString(), getString()
+	var openOrders int64
+	get(&b.Epoch)
+	get(&b.BookSeq)
+	get(&b.WALSeq)
+	get(&openOrders)
+	get(&b.CheckpointSeq)
+	b.OpenOrders = int(openOrders)
+
+	var count uint64
+	get(&count)
+	for i := uint64(0); i < count && err == nil; i++ {
+		var o *Order
+		if o, err = readOrder(br, true); err != nil {
+			break
+		}
+		b.Checkpoint = append(b.Checkpoint, o)
+	}
+
+	get(&count)
+	h := crc32.NewIEEE()
+	for i := uint64(0); i < count && err == nil; i++ {
+		var entry WALEntry
+		var typ uint8
+		var n uint32
+		get(&entry.Sequence)
+		get(&entry.Timestamp)
+		get(&typ)
+		get(&n)
+		if err != nil {
+			break
+		}
+		entry.Type = EntryType(typ)
+		entry.Data = make([]byte, n)
+		if _, err = io.ReadFull(br, entry.Data); err != nil {
+			break
+		}
+		get(&entry.Checksum)
+		if err == nil && entry.Checksum != entryChecksum(h, &entry) {
+			return nil, fmt.Errorf("%w: checksum mismatch at WAL seq %d", ErrInvalidBundle, entry.Sequence)
+		}
+		b.Tail = append(b.Tail, entry)
+	}
+	if err == nil {
+		var ids [2]int64
+		switch e := binary.Read(br, le, &ids); e {
+		case nil:
+			b.IDs = IDWatermark{OrderID: ids[0], TradeID: ids[1]}
+		case io.EOF:
+		default:
+			err = e
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return b, nil
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,215 @@
+package mtrade
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 交易对迁移测试
+// =============================================================================
+
+// seedMigrationSource 源引擎：检查点之前 4 笔挂单，之后再挂单、成交、撤单
+func seedMigrationSource(t *testing.T, cfg EngineConfig) *Engine {
+	t.Helper()
+	src := mustNewEngine(t, cfg)
+	for i := int64(1); i <= 4; i++ {
+		src.processOrderForTest(&Order{ID: i, UserID: i, Side: SideBuy, Price: 49990 - i, Qty: 5, Symbol: cfg.Symbol, Type: OrderTypeLimit})
+	}
+	if err := src.CreateCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	src.Start(context.Background())
+	t.Cleanup(src.Stop)
+
+	ctx := context.Background()
+	for _, o := range []*Order{
+		{ID: 10, UserID: 10, Side: SideSell, Price: 50010, Qty: 3, Symbol: cfg.Symbol, Type: OrderTypeLimit},
+		{ID: 11, UserID: 11, Side: SideSell, Price: 50010, Qty: 2, Symbol: cfg.Symbol, Type: OrderTypeLimit},
+		{ID: 12, UserID: 12, Side: SideBuy, Price: 50010, Qty: 1, Symbol: cfg.Symbol, Type: OrderTypeLimit},
+	} {
+		if _, err := src.SubmitOrderSync(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src.CancelOrder(2)
+	if !waitFor(t, time.Second, func() bool { return src.inflight.Load() == 0 }) {
+		t.Fatal("cancel not processed")
+	}
+	return src
+}
+
+// processOrderForTest 启动前直接撮合（经过 WAL，与 SubmitOrder 路径一致）
+func (e *Engine) processOrderForTest(o *Order) {
+	e.inflight.Add(1)
+	e.processOrder(o)
+}
+
+func TestEngine_MigrateSymbol(t *testing.T) {
+	srcCfg := DefaultEngineConfig("BTC_USDT")
+	srcCfg.WALDir = t.TempDir()
+	srcCfg.Epoch = 5
+	src := seedMigrationSource(t, srcCfg)
+
+	var mu sync.Mutex
+	var srcMarkers []*MigrationMarker
+	src.OnEvent(func(e Event) {
+		if e.Type == EventMigration {
+			mu.Lock()
+			srcMarkers = append(srcMarkers, e.Migration)
+			mu.Unlock()
+		}
+	})
+
+	if err := src.Freeze(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if src.SubmitOrder(&Order{Symbol: "BTC_USDT", Side: SideBuy, Price: 1, Qty: 1}) {
+		t.Fatal("frozen engine accepted an order")
+	}
+	if _, err := src.SubmitOrderSync(context.Background(), &Order{Symbol: "BTC_USDT", Side: SideBuy, Price: 1, Qty: 1}); !errors.Is(err, ErrEngineFrozen) {
+		t.Fatalf("SubmitOrderSync while frozen: %v", err)
+	}
+
+	bundle, err := src.ExportMigration("engine-a", "engine-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Tail) == 0 || bundle.OpenOrders != 5 {
+		t.Fatalf("bundle tail=%d open=%d", len(bundle.Tail), bundle.OpenOrders)
+	}
+
+	// 跨进程传输
+	var buf bytes.Buffer
+	if _, err := bundle.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	received, err := ReadMigrationBundle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstCfg := DefaultEngineConfig("BTC_USDT")
+	dstCfg.WALDir = t.TempDir()
+	dst := mustNewEngine(t, dstCfg)
+	var events []Event
+	dst.OnEvent(func(e Event) {
+		mu.Lock()
+		events = append(events, Event{Type: e.Type, Seq: e.Seq, Epoch: e.Epoch, Migration: e.Migration})
+		mu.Unlock()
+	})
+	if err := dst.ImportMigration(received); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Epoch() != 6 {
+		t.Fatalf("target epoch %d, want 6", dst.Epoch())
+	}
+	srcDepth, dstDepth := src.orderBook.DepthSnapshot(100), dst.orderBook.DepthSnapshot(100)
+	if !reflect.DeepEqual(srcDepth, dstDepth) {
+		t.Fatalf("depth mismatch\nsrc %+v\ndst %+v", srcDepth, dstDepth)
+	}
+
+	dst.Start(context.Background())
+	defer dst.Stop()
+	if _, err := dst.SubmitOrderSync(context.Background(), &Order{ID: 20, Side: SideBuy, Price: 50010, Qty: 4, Symbol: "BTC_USDT", Type: OrderTypeLimit}); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(t, time.Second, func() bool { mu.Lock(); defer mu.Unlock(); return len(srcMarkers) == 1 && len(events) >= 3 }) {
+		t.Fatal("missing migration events")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	handoff, resume := srcMarkers[0], events[0].Migration
+	if handoff.Phase != MigrationHandoff || handoff.Epoch != 5 {
+		t.Fatalf("handoff marker %+v", handoff)
+	}
+	if events[0].Type != EventMigration || resume.Phase != MigrationResume || resume.BookSeq != handoff.BookSeq || resume.Epoch != 6 {
+		t.Fatalf("first target event %+v, marker %+v", events[0], resume)
+	}
+	for _, e := range events[1:] {
+		if e.Seq <= handoff.BookSeq || e.Epoch != 6 {
+			t.Fatalf("target event %+v not after handoff seq %d", e, handoff.BookSeq)
+		}
+	}
+}
+
+func TestEngine_MigrateSymbol_TargetRecoversFromOwnWAL(t *testing.T) {
+	srcCfg := DefaultEngineConfig("BTC_USDT")
+	srcCfg.WALDir = t.TempDir()
+	src := seedMigrationSource(t, srcCfg)
+	if err := src.Freeze(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := src.ExportMigration("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstCfg := DefaultEngineConfig("BTC_USDT")
+	dstCfg.WALDir = t.TempDir()
+	dst := mustNewEngine(t, dstCfg)
+	if err := dst.ImportMigration(bundle); err != nil {
+		t.Fatal(err)
+	}
+	want := dst.orderBook.DepthSnapshot(100)
+	dst.Stop()
+
+	restarted := mustNewEngine(t, dstCfg)
+	defer restarted.Stop()
+	got := restarted.orderBook.DepthSnapshot(100)
+	if !reflect.DeepEqual(got.Bids, want.Bids) || !reflect.DeepEqual(got.Asks, want.Asks) {
+		t.Fatalf("restarted target depth %+v, want %+v", got, want)
+	}
+	if restarted.Epoch() != dst.Epoch() {
+		t.Fatalf("restarted epoch %d, want %d", restarted.Epoch(), dst.Epoch())
+	}
+}
+
+func TestEngine_MigrateSymbol_Rejects(t *testing.T) {
+	src := seedMigrationSource(t, DefaultEngineConfig("BTC_USDT")) // 无 WAL：订单簿直接作为检查点
+	if _, err := src.ExportMigration("a", "b"); !errors.Is(err, ErrNotFrozen) {
+		t.Fatalf("export before freeze: %v", err)
+	}
+	if err := src.Freeze(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := src.ExportMigration("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := *bundle
+	bad.OpenOrders++
+	if err := mustNewEngine(t, DefaultEngineConfig("BTC_USDT")).ImportMigration(&bad); !errors.Is(err, ErrMigrationMismatch) {
+		t.Fatalf("tampered bundle: %v", err)
+	}
+	if err := mustNewEngine(t, DefaultEngineConfig("ETH_USDT")).ImportMigration(bundle); !errors.Is(err, ErrMigrationMismatch) {
+		t.Fatalf("wrong symbol: %v", err)
+	}
+	started := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	started.Start(context.Background())
+	defer started.Stop()
+	if err := started.ImportMigration(bundle); !errors.Is(err, ErrEngineStarted) {
+		t.Fatalf("import after start: %v", err)
+	}
+
+	// 放弃迁移
+	src.Unfreeze()
+	if _, err := src.SubmitOrderSync(context.Background(), &Order{ID: 99, Side: SideBuy, Price: 1, Qty: 1, Symbol: "BTC_USDT", Type: OrderTypeLimit}); err != nil {
+		t.Fatalf("unfrozen engine rejected order: %v", err)
+	}
+
+	var buf bytes.Buffer
+	bundle.WriteTo(&buf)
+	raw := buf.Bytes()
+	raw[0] ^= 0xff
+	if _, err := ReadMigrationBundle(bytes.NewReader(raw)); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("corrupted bundle: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package mtrade
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -116,14 +117,36 @@ type Order struct {
 	FilledQty int64 // 已成交数量
 	CreatedAt int64 // 创建时间（Unix 纳秒）
 
+	// 按金额下单（仅市价单，见 quote_order.go）：QuoteQty 为报价资产预算，下单时 Qty 为 0，
+	// 撮合按预算逐档换算成交量，结束后 Qty 回填为实际成交量
+	QuoteQty    int64 // 报价资产预算（定点数）
+	FilledQuote int64 // 已成交金额 Σ 成交价 × 成交量 / 1e8（仅 QuoteQty > 0 时维护）
+
 	// ========== 小字段放后面 ==========
 
 	Side   Side        // 买卖方向
 	Type   OrderType   // 订单类型
 	Status OrderStatus // 订单状态
+	pooled bool        // 是否来自对象池（AcquireOrder），终态后由引擎归还
+
+	// 侵入式链表指针（仅订单簿内部使用，见 level_list.go）
+	prev  *Order
+	next  *Order
+	level *PriceLevel
+
+	// 队列位置（仅开启 QueueTracking 时由订单簿维护，见 queue_position.go）
+	queueAheadQty   int64
+	queueAheadCount int32
+	queueSeen       bool
+
+	// 同步下单的回执通道（仅 SubmitOrderSync 设置，见 submit_sync.go）
+	ack chan OrderAck
 
 	// Symbol 放最后（string 是 16 字节）
 	Symbol string // 交易对，如 "BTC_USDT"
+
+	// ClientOrderID 客户端订单号，同一用户在去重窗口内唯一，为空表示不使用（见 client_order.go）
+	ClientOrderID string
 }
 
 // RemainingQty 返回剩余未成交数量
@@ -182,13 +205,15 @@ func FromFixedPrice(price int64) float64 {
 // 订单 ID 生成器（简化版，生产用 Snowflake）
 // =============================================================================
 
-var orderIDSeq int64
+var orderIDSeq atomic.Int64
 
 // NextOrderID 生成下一个订单 ID
 // 【面试】生产环境用 Snowflake ID
 // 结构：时间戳(41位) + 机器ID(10位) + 序列号(12位)
+//
+// 单调递增，且不低于 AdvanceOrderID 设置的高水位 (见 id_watermark.go)
 func NextOrderID() int64 {
-	orderIDSeq++
+	seq := orderIDSeq.Add(1)
 	// 简化版：时间戳左移 + 序列号
-	return time.Now().UnixNano()/1000000<<20 | (orderIDSeq & 0xFFFFF)
+	return claimOrderID(time.Now().UnixNano()/1000000<<20 | (seq & 0xFFFFF))
 }
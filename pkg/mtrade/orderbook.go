@@ -24,21 +24,47 @@ type OrderBook struct {
 	bids PriceIndex // 买盘（价格降序）
 	asks PriceIndex // 卖盘（价格升序）
 
+	// 价格带（仅 TickIndex 实现有效），超出价格带的订单直接拒绝
+	tick    TickConfig
+	bounded bool
+
 	// 订单索引：OrderID → Order
 	orderIndex map[int64]*Order
 
+	// 挂单上限（见 book_limits.go）
+	limiter bookLimiter
+
+	// 按用户的挂单敞口（见 user_exposure.go）
+	exposure exposureBook
+
+	// 序列号与增量更新（见 book_sync.go）
+	seq          uint64
+	trackUpdates bool
+	updates      []BookUpdate
+
+	// 挂单队列位置跟踪（见 queue_position.go）
+	trackQueue   bool
+	queueDirty   []*PriceLevel
+	queueRemoved []int64
+
+	// 快照每侧保留的档位数（见 depth_agg.go），0 使用默认值
+	snapshotDepth int
+
 	// 快照（供外部查询，原子更新）
 	snapshot atomic.Pointer[OrderBookSnapshot]
+	waiters  snapshotWaiters
 }
 
 // OrderBookSnapshot 订单簿快照（只读）
 // 【面试】外部查询使用快照，无锁读
 type OrderBookSnapshot struct {
+	Seq       uint64 // 快照对应的订单簿序列号
 	BestBid   int64
 	BestAsk   int64
 	Spread    int64
 	BidLevels int
 	AskLevels int
+	Orders    int // 挂单总数
 	BidDepth  []DepthLevel
 	AskDepth  []DepthLevel
 }
@@ -63,6 +89,36 @@ func NewOrderBook(symbol string) *OrderBook {
 	return ob
 }
 
+// NewTickOrderBook 创建跳价数组版订单簿
+// 【面试】价格带有界（合约规格给出 TickSize 和价格上下限）时，
+// 用数组按 tick 直接寻址，最优价 O(1)，见 tick_index.go
+func NewTickOrderBook(symbol string, cfg TickConfig) (*OrderBook, error) {
+	bids, err := NewTickIndex(cfg, false) // 降序
+	if err != nil {
+		return nil, err
+	}
+	asks, err := NewTickIndex(cfg, true) // 升序
+	if err != nil {
+		return nil, err
+	}
+
+	ob := &OrderBook{
+		Symbol:     symbol,
+		bids:       bids,
+		asks:       asks,
+		tick:       cfg,
+		bounded:    true,
+		orderIndex: make(map[int64]*Order),
+	}
+	ob.snapshot.Store(&OrderBookSnapshot{})
+	return ob, nil
+}
+
+// ValidPrice 价格是否可以挂单（跳价数组版需要落在价格带内并对齐 TickSize）
+func (ob *OrderBook) ValidPrice(price int64) bool {
+	return !ob.bounded || ob.tick.Contains(price)
+}
+
 // =============================================================================
 // 订单操作（无锁，仅供 matchLoop 调用）
 // =============================================================================
@@ -80,13 +136,19 @@ func (ob *OrderBook) AddOrder(order *Order) bool {
 
 	// 插入或获取价格档位
 	node := priceIndex.Insert(order.Price)
+	if node == nil {
+		return false // 超出价格带
+	}
 	level := node.GetLevel()
 
 	// 添加订单到价格档位
 	level.AddOrder(order)
+	ob.touch(order.Side, level)
 
 	// 添加到订单索引
 	ob.orderIndex[order.ID] = order
+	ob.limiter.trackAdd(order)
+	ob.trackExposureAdd(order)
 	order.Status = OrderStatusNew
 
 	return true
@@ -101,26 +163,12 @@ func (ob *OrderBook) CancelOrder(orderID int64) *Order {
 		return nil
 	}
 
-	// 2. 获取对应的价格索引
-	priceIndex := ob.getSideIndex(order.Side)
-
-	// 3. 找到价格档位
-	node := priceIndex.Find(order.Price)
-	if node == nil {
-		return nil
-	}
-
-	// 4. 从价格档位中移除订单
-	level := node.GetLevel()
-	level.RemoveOrder(orderID)
+	// 2. 从价格档位中摘除订单
+	// 【优化】订单自带所在档位指针，O(1) 摘链，无需查找价格和遍历队列
+	ob.unlink(order)
 
-	// 5. 如果价格档位空了，删除它
-	if level.IsEmpty() {
-		priceIndex.Delete(order.Price)
-	}
-
-	// 6. 从索引中移除
-	delete(ob.orderIndex, orderID)
+	// 3. 从索引中移除
+	ob.forget(order)
 	order.Status = OrderStatusCanceled
 
 	return order
@@ -165,19 +213,32 @@ func (ob *OrderBook) getSideIndex(side Side) PriceIndex {
 // RemoveFromLevel 从价格档位移除订单
 // 【无锁】仅由 matchLoop 调用
 func (ob *OrderBook) RemoveFromLevel(order *Order) {
-	priceIndex := ob.getSideIndex(order.Side)
-	node := priceIndex.Find(order.Price)
+	ob.unlink(order)
+	ob.forget(order)
+}
 
-	if node != nil {
-		level := node.GetLevel()
-		level.PopFront()
+// forget 订单离开订单簿（成交完、撤单）后从索引和上限计数中移除
+func (ob *OrderBook) forget(order *Order) {
+	delete(ob.orderIndex, order.ID)
+	ob.limiter.trackRemove(order)
+	ob.trackExposureRemove(order)
+	if ob.trackQueue {
+		ob.queueRemoved = append(ob.queueRemoved, order.ID)
+	}
+}
 
-		if level.IsEmpty() {
-			priceIndex.Delete(order.Price)
-		}
+// unlink 把订单从所在档位摘除，档位空了则从价格索引删除
+func (ob *OrderBook) unlink(order *Order) {
+	level := order.level
+	if level == nil {
+		return
 	}
+	level.Remove(order)
+	ob.touch(order.Side, level)
 
-	delete(ob.orderIndex, order.ID)
+	if level.IsEmpty() {
+		ob.getSideIndex(order.Side).Delete(level.Price)
+	}
 }
 
 // =============================================================================
@@ -188,10 +249,12 @@ func (ob *OrderBook) RemoveFromLevel(order *Order) {
 // 【无锁】仅由 matchLoop 调用，撮合后执行
 func (ob *OrderBook) UpdateSnapshot() {
 	snap := &OrderBookSnapshot{
+		Seq:       ob.seq,
 		BidLevels: ob.bids.Len(),
 		AskLevels: ob.asks.Len(),
-		BidDepth:  ob.getDepth(ob.bids, 20),
-		AskDepth:  ob.getDepth(ob.asks, 20),
+		Orders:    len(ob.orderIndex),
+		BidDepth:  ob.getDepth(ob.bids, ob.snapshotLevels()),
+		AskDepth:  ob.getDepth(ob.asks, ob.snapshotLevels()),
 	}
 
 	if node := ob.bids.First(); node != nil {
@@ -205,6 +268,7 @@ func (ob *OrderBook) UpdateSnapshot() {
 	}
 
 	ob.snapshot.Store(snap)
+	ob.waiters.notify()
 }
 
 // GetSnapshot 获取快照（无锁读）
@@ -214,18 +278,19 @@ func (ob *OrderBook) GetSnapshot() *OrderBookSnapshot {
 }
 
 // getDepth 获取一侧的深度
+// 【优化】直接读取档位上增量维护的 TotalQty / count，不遍历订单
 func (ob *OrderBook) getDepth(index PriceIndex, n int) []DepthLevel {
-	nodes := index.GetTopN(n)
-	result := make([]DepthLevel, len(nodes))
+	result := make([]DepthLevel, 0, min(int64(n), int64(index.Len())))
 
-	for i, node := range nodes {
+	index.ForEach(func(node PriceLevelNode) bool {
 		level := node.GetLevel()
-		result[i] = DepthLevel{
+		result = append(result, DepthLevel{
 			Price:    node.GetPrice(),
 			Quantity: level.TotalQty,
 			Orders:   level.Len(),
-		}
-	}
+		})
+		return len(result) < n
+	})
 
 	return result
 }
@@ -265,30 +330,21 @@ func (ob *OrderBook) Spread() (int64, bool) {
 func (ob *OrderBook) Depth(n int) (bids, asks []DepthLevel) {
 	snap := ob.GetSnapshot()
 
-	// 返回快照中的前 n 档
-	if n > len(snap.BidDepth) {
-		n = len(snap.BidDepth)
-	}
-	bids = snap.BidDepth[:n]
-
-	m := n
-	if m > len(snap.AskDepth) {
-		m = len(snap.AskDepth)
-	}
-	asks = snap.AskDepth[:m]
-
-	return bids, asks
+	// 返回快照中的前 n 档（两侧各自截断）
+	depth := depthFromSnapshot(ob.Symbol, snap, n)
+	return depth.Bids, depth.Asks
 }
 
 // GetStats 获取统计信息（从快照读取）
 func (ob *OrderBook) GetStats() OrderBookStats {
 	snap := ob.GetSnapshot()
 	return OrderBookStats{
-		BidLevels: snap.BidLevels,
-		AskLevels: snap.AskLevels,
-		BestBid:   snap.BestBid,
-		BestAsk:   snap.BestAsk,
-		Spread:    snap.Spread,
+		BidLevels:   snap.BidLevels,
+		AskLevels:   snap.AskLevels,
+		TotalOrders: snap.Orders,
+		BestBid:     snap.BestBid,
+		BestAsk:     snap.BestAsk,
+		Spread:      snap.Spread,
 	}
 }
 
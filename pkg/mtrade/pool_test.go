@@ -0,0 +1,140 @@
+package mtrade
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// 对象池测试
+// =============================================================================
+
+func TestReleaseOrder_OnlyPooled(t *testing.T) {
+	// 普通订单不会被清零（调用方仍持有）
+	plain := &Order{ID: 1, Qty: 10}
+	ReleaseOrder(plain)
+	if plain.ID != 1 || plain.Qty != 10 {
+		t.Errorf("plain order should be untouched, got %v", plain)
+	}
+
+	// 池化订单归还时清零
+	o := AcquireOrder()
+	o.ID, o.Qty = 2, 20
+	ReleaseOrder(o)
+	if o.ID != 0 || o.Qty != 0 || o.pooled {
+		t.Errorf("pooled order should be reset, got %+v", *o)
+	}
+}
+
+func TestEngine_PooledEventsValidInHandler(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+
+	// handler 内读取 Trade / Result，验证回调期间数据未被复用
+	type pair struct{ taker, maker int64 }
+	var (
+		mu     sync.Mutex
+		trades []pair
+		bad    int
+	)
+	engine.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Type {
+		case EventTrade:
+			trades = append(trades, pair{e.Trade.TakerID, e.Trade.MakerID})
+		case EventOrderAccepted:
+			if e.Result != nil && e.Result.TakerOrder != e.Order {
+				bad++
+			}
+		}
+	})
+
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	// 每一对订单：maker 卖单 2i+1，taker 买单 2i+2，刚好完全成交
+	const pairs = 2000
+	for i := int64(0); i < pairs; i++ {
+		maker := AcquireOrder()
+		maker.ID, maker.Side, maker.Price, maker.Qty = 2*i+1, SideSell, 50000, 10
+		maker.Symbol, maker.Type = "BTC_USDT", OrderTypeLimit
+		for !engine.SubmitOrder(maker) {
+			time.Sleep(time.Millisecond)
+		}
+
+		taker := AcquireOrder()
+		taker.ID, taker.Side, taker.Price, taker.Qty = 2*i+2, SideBuy, 50000, 10
+		taker.Symbol, taker.Type = "BTC_USDT", OrderTypeLimit
+		for !engine.SubmitOrder(taker) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(trades)
+		mu.Unlock()
+		if n == pairs {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(trades) != pairs {
+		t.Fatalf("expected %d trades, got %d", pairs, len(trades))
+	}
+	for i, p := range trades {
+		if p.maker != int64(2*i+1) || p.taker != int64(2*i+2) {
+			t.Fatalf("trade %d corrupted: taker=%d maker=%d", i, p.taker, p.maker)
+		}
+	}
+	if bad != 0 {
+		t.Errorf("%d accepted events carried a recycled MatchResult", bad)
+	}
+	if st := engine.GetOrderBook().GetStats(); st.BidLevels+st.AskLevels != 0 {
+		t.Errorf("expected empty book after all pairs matched")
+	}
+}
+
+// BenchmarkEngine_PooledOrders 池化订单 vs 普通订单的完整撮合流程
+func BenchmarkEngine_PooledOrders(b *testing.B) {
+	for _, pooled := range []bool{false, true} {
+		name := "new"
+		if pooled {
+			name = "pooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			engine := mustNewEngine(b, DefaultEngineConfig("BTC_USDT"))
+			engine.OnEvent(func(e Event) {})
+			engine.Start(context.Background())
+			defer engine.Stop()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var o *Order
+				if pooled {
+					o = AcquireOrder()
+				} else {
+					o = &Order{}
+				}
+				o.ID = int64(i + 1)
+				o.Side = Side(1 - 2*(i&1)) // 买卖交替，每两笔成交一次
+				o.Price, o.Qty = 50000, 1
+				o.Symbol, o.Type = "BTC_USDT", OrderTypeLimit
+				for !engine.SubmitOrder(o) {
+					runtime.Gosched()
+				}
+			}
+			for engine.GetStats().LatencySamples < uint64(b.N) {
+				time.Sleep(100 * time.Microsecond)
+			}
+		})
+	}
+}
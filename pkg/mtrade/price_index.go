@@ -7,7 +7,8 @@ package mtrade
 // 【面试加分】基于接口编程，方便替换实现
 //
 // 目的：
-//   - 当前实现：跳表 (SkipList)
+//   - 跳表 (SkipList)：价格范围不受限，O(log n)
+//   - 跳价数组 (TickIndex)：价格范围有界，最优价 O(1)
 //   - 未来可替换：红黑树 (RedBlackTree)
 //   - 测试时可用：Mock 实现
 
@@ -18,7 +19,7 @@ type PriceLevelNode interface {
 	GetPrice() int64
 
 	// GetLevel 获取价格档位（存储订单的队列）
-	GetLevel() *RingPriceLevel
+	GetLevel() *PriceLevel
 }
 
 // PriceIndex 价格索引接口
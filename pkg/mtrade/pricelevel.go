@@ -13,6 +13,9 @@ package mtrade
 // 环形队列解决：
 //   用 head/tail 指针标记有效区域
 //   头部删除只需移动 head 指针 O(1)
+//
+// 【注意】订单簿现在使用侵入式链表版 PriceLevel（见 level_list.go），
+// 撤单 O(1)；本实现保留作对比基准
 
 const (
 	// DefaultRingCapacity 默认环形缓冲区容量
@@ -0,0 +1,256 @@
+package mtrade
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 挂单队列位置 (Queue Position)
+// =============================================================================
+//
+// 【面试】做市商为什么关心排队位置？
+//   同价位按时间优先成交，排在前面的挂单先被吃。前面还有多少量，
+//   决定了这笔挂单多久能成交、要不要撤单重挂抢位置。
+//
+// 【做法】订单簿只在 "档位被改动" 时重算该档位的排队位置：
+//   1. touch()（挂单、成交、撤单都会调用）把档位登记为待重算
+//   2. 本轮处理结束后遍历登记过的档位，从队首累加前方的量和笔数
+//   3. 和订单上记录的旧值比较，只输出变化了的订单（QueueUpdate）
+//
+//   level: head ⇄ o1(前方 0) ⇄ o2(前方 o1) ⇄ o3(前方 o1+o2)
+//          o1 部分成交 → o2、o3 前方量减少；o2 撤单 → o3 前方笔数 -1
+//
+// 代价：每个被改动的档位 O(档位订单数)，所以默认关闭（EngineConfig.QueueTracking）
+//
+// 【成交概率】粗略估计，供做市商参考，不作任何保证：
+//   待成交量 = 同方向更优价格的挂单量（快照深度内）+ 前方排队量 + 自身剩余量
+//   吃单速率 = 对手方吃掉该方向挂单的速率（指数衰减平均，单位 量/秒）
+//   预计成交时间 = 待成交量 / 吃单速率
+//   窗口内成交概率 = 1 - exp(-吃单速率 × 窗口 / 待成交量)
+//
+// 【注意】
+//   - EventQueueUpdate 是非关键事件，丢了可以用 GetOrderQueueInfo 补查
+//   - 完全成交、撤单离开订单簿的订单不再推送，以订单/成交事件为准
+
+// QueueUpdate 一笔挂单的排队位置
+type QueueUpdate struct {
+	OrderID    int64
+	UserID     int64 // 私有推送按用户路由
+	Side       Side
+	Price      int64
+	AheadQty   int64  // 同价位排在前面的剩余挂单量
+	AheadCount int    // 同价位排在前面的订单数
+	Remaining  int64  // 自身剩余未成交量
+	Seq        uint64 // 计算时的订单簿序列号
+}
+
+// QueueInfo 挂单排队位置与成交预估
+type QueueInfo struct {
+	QueueUpdate
+
+	BetterQty         int64         // 同方向更优价格的挂单量（快照深度内）
+	FillRate          float64       // 对手方吃单速率（量/秒）
+	EstimatedFillTime time.Duration // 预计成交时间，0 表示无法估计（近期无吃单）
+	FillProbability   float64       // QueueFillHorizon 窗口内完全成交的概率 [0, 1]
+}
+
+// =============================================================================
+// 订单簿侧：排队位置维护（仅 matchLoop 调用）
+// =============================================================================
+
+// EnableQueueTracking 开启排队位置跟踪，已有挂单全部登记待计算
+func (ob *OrderBook) EnableQueueTracking() {
+	ob.trackQueue = true
+	for _, order := range ob.orderIndex {
+		if order.level != nil {
+			ob.markQueueDirty(order.level)
+		}
+	}
+}
+
+// markQueueDirty 登记档位待重算（同一轮只登记一次）
+func (ob *OrderBook) markQueueDirty(level *PriceLevel) {
+	if level.queueDirty {
+		return
+	}
+	level.queueDirty = true
+	ob.queueDirty = append(ob.queueDirty, level)
+}
+
+// TakeQueueUpdates 重算登记过的档位，返回排队位置有变化的挂单，以及离开订单簿的订单 ID
+func (ob *OrderBook) TakeQueueUpdates() (updates []QueueUpdate, removed []int64) {
+	for i, level := range ob.queueDirty {
+		level.queueDirty = false
+		ob.queueDirty[i] = nil
+
+		var ahead int64
+		var count int32
+		for o := level.head; o != nil; o = o.next {
+			if !o.queueSeen || o.queueAheadQty != ahead || o.queueAheadCount != count {
+				o.queueSeen, o.queueAheadQty, o.queueAheadCount = true, ahead, count
+				updates = append(updates, QueueUpdate{
+					OrderID:    o.ID,
+					UserID:     o.UserID,
+					Side:       o.Side,
+					Price:      o.Price,
+					AheadQty:   ahead,
+					AheadCount: int(count),
+					Remaining:  o.RemainingQty(),
+					Seq:        ob.seq,
+				})
+			}
+			ahead += o.RemainingQty()
+			count++
+		}
+	}
+	ob.queueDirty = ob.queueDirty[:0]
+
+	if len(ob.queueRemoved) > 0 {
+		removed = make([]int64, len(ob.queueRemoved))
+		copy(removed, ob.queueRemoved)
+		ob.queueRemoved = ob.queueRemoved[:0]
+	}
+	return updates, removed
+}
+
+// =============================================================================
+// 引擎侧：对外查询
+// =============================================================================
+
+// queueTracker 供外部线程查询的排队位置表 + 吃单速率
+// matchLoop 每轮处理结束写一次，查询方读锁
+type queueTracker struct {
+	window  float64 // 衰减时间常数（秒）
+	horizon time.Duration
+
+	mu     sync.RWMutex
+	orders map[int64]QueueUpdate
+	bids   decayRate // 买单被吃的速率
+	asks   decayRate // 卖单被吃的速率
+}
+
+func newQueueTracker(config EngineConfig) *queueTracker {
+	window := config.QueueRateWindow
+	if window <= 0 {
+		window = time.Minute
+	}
+	horizon := config.QueueFillHorizon
+	if horizon <= 0 {
+		horizon = time.Minute
+	}
+	return &queueTracker{
+		window:  window.Seconds(),
+		horizon: horizon,
+		orders:  make(map[int64]QueueUpdate),
+	}
+}
+
+// decayRate 指数衰减的速率估计：每次成交先按间隔衰减，再加上 qty/window
+type decayRate struct {
+	rate float64 // 量/秒
+	last int64   // 上次更新时间（Unix 纳秒）
+}
+
+func (r *decayRate) add(qty int64, now int64, window float64) {
+	r.rate = r.at(now, window) + float64(qty)/window
+	r.last = now
+}
+
+func (r *decayRate) at(now int64, window float64) float64 {
+	if r.last == 0 || now <= r.last {
+		return r.rate
+	}
+	return r.rate * math.Exp(-float64(now-r.last)/1e9/window)
+}
+
+// recordFills 记录本轮成交（Maker 方向即被吃掉的挂单方向）
+func (q *queueTracker) recordFills(trades []Trade) {
+	if len(trades) == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range trades {
+		t := &trades[i]
+		if t.TakerSide == SideSell {
+			q.bids.add(t.Qty, t.Timestamp, q.window)
+		} else {
+			q.asks.add(t.Qty, t.Timestamp, q.window)
+		}
+	}
+}
+
+// apply 写入本轮的排队位置变化
+func (q *queueTracker) apply(updates []QueueUpdate, removed []int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range removed {
+		delete(q.orders, id)
+	}
+	for _, u := range updates {
+		q.orders[u.OrderID] = u
+	}
+}
+
+// publishQueueUpdates 取出排队位置变化，更新查询表并推送
+func (e *Engine) publishQueueUpdates() {
+	if e.queue == nil {
+		return
+	}
+	updates, removed := e.orderBook.TakeQueueUpdates()
+	if len(updates) == 0 && len(removed) == 0 {
+		return
+	}
+	e.queue.apply(updates, removed)
+	if len(updates) > 0 {
+		e.publishEvent(Event{
+			Type:      EventQueueUpdate,
+			Timestamp: time.Now().UnixNano(),
+			Seq:       e.orderBook.Seq(),
+			Queue:     updates,
+		})
+	}
+}
+
+// GetOrderQueueInfo 查询挂单的排队位置与成交预估（线程安全）
+// 订单不在订单簿中或未开启 QueueTracking 时返回 false
+func (e *Engine) GetOrderQueueInfo(orderID int64) (QueueInfo, bool) {
+	q := e.queue
+	if q == nil {
+		return QueueInfo{}, false
+	}
+
+	now := time.Now().UnixNano()
+	q.mu.RLock()
+	u, ok := q.orders[orderID]
+	rate := q.asks.at(now, q.window)
+	if u.Side == SideBuy {
+		rate = q.bids.at(now, q.window)
+	}
+	q.mu.RUnlock()
+	if !ok {
+		return QueueInfo{}, false
+	}
+
+	info := QueueInfo{QueueUpdate: u, FillRate: rate}
+	snap := e.orderBook.GetSnapshot()
+	depth := snap.AskDepth
+	if u.Side == SideBuy {
+		depth = snap.BidDepth
+	}
+	for _, lv := range depth {
+		if lv.Price*int64(u.Side) <= u.Price*int64(u.Side) {
+			break // 深度按从优到劣排列
+		}
+		info.BetterQty += lv.Quantity
+	}
+
+	toFill := float64(info.BetterQty + u.AheadQty + u.Remaining)
+	if rate > 0 && toFill > 0 {
+		info.EstimatedFillTime = time.Duration(toFill / rate * float64(time.Second))
+		info.FillProbability = 1 - math.Exp(-rate*q.horizon.Seconds()/toFill)
+	}
+	return info, true
+}
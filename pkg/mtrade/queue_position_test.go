@@ -0,0 +1,128 @@
+package mtrade
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOrderBook_QueueUpdates(t *testing.T) {
+	ob := NewOrderBook("BTC_USDT")
+	m := NewMatcher(ob)
+	ob.EnableQueueTracking()
+
+	for i := int64(1); i <= 3; i++ {
+		m.ProcessOrder(&Order{ID: i, UserID: 100 + i, Side: SideSell, Price: 50000, Qty: 10, Type: OrderTypeLimit})
+	}
+	updates, _ := ob.TakeQueueUpdates()
+	if len(updates) != 3 {
+		t.Fatalf("expected 3 initial updates, got %+v", updates)
+	}
+	if u := updates[2]; u.OrderID != 3 || u.AheadQty != 20 || u.AheadCount != 2 || u.UserID != 103 {
+		t.Fatalf("unexpected rank for order 3: %+v", u)
+	}
+
+	// 未改动的档位不产生更新
+	if updates, removed := ob.TakeQueueUpdates(); len(updates) != 0 || len(removed) != 0 {
+		t.Fatalf("expected no updates, got %+v %v", updates, removed)
+	}
+
+	// 队首部分成交：后面两笔前方量减少，队首自身位置不变
+	m.ProcessOrder(&Order{ID: 10, Side: SideBuy, Price: 50000, Qty: 4, Type: OrderTypeIOC})
+	updates, _ = ob.TakeQueueUpdates()
+	if len(updates) != 2 || updates[0].OrderID != 2 || updates[0].AheadQty != 6 || updates[1].AheadQty != 16 {
+		t.Fatalf("unexpected updates after partial fill: %+v", updates)
+	}
+
+	// 中间撤单：只有队尾变化，撤掉的订单进入 removed
+	ob.CancelOrder(2)
+	updates, removed := ob.TakeQueueUpdates()
+	if len(updates) != 1 || updates[0].OrderID != 3 || updates[0].AheadQty != 6 || updates[0].AheadCount != 1 {
+		t.Fatalf("unexpected updates after cancel: %+v", updates)
+	}
+	if len(removed) != 1 || removed[0] != 2 {
+		t.Fatalf("expected order 2 removed, got %v", removed)
+	}
+}
+
+func TestEngine_GetOrderQueueInfo(t *testing.T) {
+	config := DefaultEngineConfig("BTC_USDT")
+	config.QueueTracking = true
+	engine := mustNewEngine(t, config)
+
+	queued := make(chan []QueueUpdate, 16)
+	engine.OnEvent(func(e Event) {
+		if e.Type == EventQueueUpdate {
+			queued <- e.Queue
+		}
+	})
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	submit := func(o *Order) OrderAck {
+		t.Helper()
+		ack, err := engine.SubmitOrderSync(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ack
+	}
+
+	better := submit(&Order{UserID: 1, Side: SideBuy, Price: 50100, Qty: 5, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	first := submit(&Order{UserID: 1, Side: SideBuy, Price: 50000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	second := submit(&Order{UserID: 2, Side: SideBuy, Price: 50000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+
+	info, ok := engine.GetOrderQueueInfo(second.OrderID)
+	if !ok {
+		t.Fatal("expected queue info for resting order")
+	}
+	if info.AheadQty != 10 || info.AheadCount != 1 || info.BetterQty != 5 || info.Remaining != 10 {
+		t.Fatalf("unexpected queue info: %+v", info)
+	}
+	if info.FillRate != 0 || info.EstimatedFillTime != 0 || info.FillProbability != 0 {
+		t.Fatalf("expected no estimate without taker flow: %+v", info)
+	}
+
+	// 卖方吃掉更优档位和第一笔的一部分：第二笔前移，并有了吃单速率
+	submit(&Order{UserID: 3, Side: SideSell, Price: 50000, Qty: 8, Symbol: "BTC_USDT", Type: OrderTypeIOC})
+	info, _ = engine.GetOrderQueueInfo(second.OrderID)
+	if info.AheadQty != 7 || info.BetterQty != 0 {
+		t.Fatalf("unexpected queue info after fill: %+v", info)
+	}
+	if info.FillRate <= 0 || info.EstimatedFillTime <= 0 || info.FillProbability <= 0 || info.FillProbability >= 1 {
+		t.Fatalf("expected fill estimate: %+v", info)
+	}
+
+	// 离开订单簿的订单查不到
+	if _, ok := engine.GetOrderQueueInfo(better.OrderID); ok {
+		t.Fatal("filled order should have no queue info")
+	}
+	engine.CancelOrder(first.OrderID)
+	deadline := time.After(time.Second)
+	for {
+		if _, ok := engine.GetOrderQueueInfo(first.OrderID); !ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("canceled order still has queue info")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	// 私有推送：最终收到第二笔排到队首的更新
+	for {
+		select {
+		case updates := <-queued:
+			for _, u := range updates {
+				if u.OrderID == second.OrderID && u.AheadCount == 0 && u.UserID == 2 {
+					return
+				}
+			}
+		case <-deadline:
+			t.Fatal("no queue update moving the order to the front")
+		}
+	}
+}
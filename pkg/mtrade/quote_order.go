@@ -0,0 +1,56 @@
+package mtrade
+
+import "max.com/pkg/fixed"
+
+// =============================================================================
+// 按金额下单 (Quote-Quantity Market Order)
+// =============================================================================
+//
+// 【问题】现货用户常用 "花 100 USDT 买 BTC"：下单时不知道能买多少 BTC，
+// 按数量下市价单要么买不够，要么冻结不够导致结算失败
+//
+// 【做法】市价单带 QuoteQty (报价资产预算)、Qty 为 0：
+//   - 撮合逐档按 剩余预算 × 1e8 / 成交价 (向下取整) 换算本档最多能成交的数量，
+//     FilledQuote 累计 Σ 成交价 × 成交量 / 1e8 (与资产结算同口径，不会超出预算)
+//   - 剩余预算在当前最优价买不起一个最小单位时视为完全成交 (FILLED)，
+//     对手盘被吃空时剩余预算取消 (CANCELED，与普通市价单一致)
+//   - 撮合结束后 Qty 回填为实际成交量，下游按普通订单处理 (RemainingQty 为 0)
+//
+// 【注意】只支持市价单：限价 / IOC / FOK 等带价格的订单按数量下单即可；
+// 预算对应的冻结与未用部分的解冻由上层 (spot.SpotProcessor) 负责
+
+// validQuoteOrder 按金额下单的订单是否合法：市价单、预算为正、不指定数量
+func validQuoteOrder(order *Order) bool {
+	return order.Type == OrderTypeMarket && order.QuoteQty > 0 && order.Qty == 0
+}
+
+// RemainingQuote 剩余预算（仅按金额下单的订单）
+func (o *Order) RemainingQuote() int64 {
+	return o.QuoteQty - o.FilledQuote
+}
+
+// matchableQty 以 price 成交时 Taker 最多还能成交的数量
+func (o *Order) matchableQty(price int64) int64 {
+	if o.QuoteQty > 0 {
+		if price <= 0 {
+			return 0
+		}
+		return fixed.MulDiv(o.RemainingQuote(), PriceMultiplier, price)
+	}
+	return o.RemainingQty()
+}
+
+// finishQuoteOrder 按金额下单的订单撮合结束：回填数量，判断预算是否用尽
+func (m *Matcher) finishQuoteOrder(taker *Order, opposite PriceIndex, result *MatchResult) {
+	taker.Qty = taker.FilledQty
+	result.FilledQuote = taker.FilledQuote
+
+	exhausted := taker.RemainingQuote() <= 0
+	if !exhausted {
+		// 对手盘还有可成交的价位，只是剩余预算买不起一个最小单位
+		if best := opposite.First(); best != nil && m.canMatch(taker, best.GetPrice()) {
+			exhausted = taker.matchableQty(best.GetPrice()) <= 0
+		}
+	}
+	result.FullyFilled = exhausted && taker.FilledQty > 0
+}
@@ -0,0 +1,92 @@
+package mtrade
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func quoteTestBook() (*OrderBook, *Matcher) {
+	ob := NewOrderBook("BTC_USDT")
+	ob.AddOrder(&Order{ID: 1, UserID: 1, Side: SideSell, Price: 100 * PriceMultiplier, Qty: PriceMultiplier, Symbol: "BTC_USDT"})
+	ob.AddOrder(&Order{ID: 2, UserID: 1, Side: SideSell, Price: 101 * PriceMultiplier, Qty: PriceMultiplier, Symbol: "BTC_USDT"})
+	return ob, NewMatcher(ob)
+}
+
+func TestQuoteOrder_SpendsBudgetAcrossLevels(t *testing.T) {
+	_, m := quoteTestBook()
+
+	// 花 150 USDT：100 买 1 BTC，剩 50 USDT 按 101 买 0.4950495 BTC
+	taker := &Order{ID: 10, UserID: 2, Side: SideBuy, Type: OrderTypeMarket, QuoteQty: 150 * PriceMultiplier, Symbol: "BTC_USDT"}
+	result := m.ProcessOrder(taker)
+
+	if len(result.Trades) != 2 {
+		t.Fatalf("trades = %d, want 2", len(result.Trades))
+	}
+	if got := result.Trades[1].Qty; got != 49504950 {
+		t.Errorf("second fill = %d, want 49504950", got)
+	}
+	if taker.FilledQty != PriceMultiplier+49504950 || taker.Qty != taker.FilledQty {
+		t.Errorf("filled = %d qty = %d", taker.FilledQty, taker.Qty)
+	}
+	if taker.FilledQuote > taker.QuoteQty || taker.RemainingQuote() != 50 {
+		t.Errorf("filled quote = %d, remaining = %d", taker.FilledQuote, taker.RemainingQuote())
+	}
+	if !result.FullyFilled || taker.Status != OrderStatusFilled || result.RemainingQty != 0 {
+		t.Errorf("status = %s fully = %v remaining = %d", taker.Status, result.FullyFilled, result.RemainingQty)
+	}
+	if result.FilledQuote != taker.FilledQuote {
+		t.Errorf("result filled quote = %d", result.FilledQuote)
+	}
+}
+
+func TestQuoteOrder_BookExhausted(t *testing.T) {
+	ob, m := quoteTestBook()
+
+	taker := &Order{ID: 10, UserID: 2, Side: SideBuy, Type: OrderTypeMarket, QuoteQty: 500 * PriceMultiplier, Symbol: "BTC_USDT"}
+	result := m.ProcessOrder(taker)
+
+	if taker.FilledQty != 2*PriceMultiplier || taker.FilledQuote != 201*PriceMultiplier {
+		t.Errorf("filled = %d / %d", taker.FilledQty, taker.FilledQuote)
+	}
+	if result.FullyFilled || taker.Status != OrderStatusCanceled {
+		t.Errorf("status = %s, want CANCELED with unspent budget", taker.Status)
+	}
+	if ob.asks.First() != nil {
+		t.Error("asks should be empty")
+	}
+}
+
+func TestQuoteOrder_Rejects(t *testing.T) {
+	_, m := quoteTestBook()
+
+	cases := []*Order{
+		{ID: 10, Side: SideBuy, Type: OrderTypeLimit, Price: 100 * PriceMultiplier, QuoteQty: PriceMultiplier, Symbol: "BTC_USDT"},
+		{ID: 11, Side: SideBuy, Type: OrderTypeMarket, Qty: 1, QuoteQty: PriceMultiplier, Symbol: "BTC_USDT"},
+		{ID: 12, Side: SideBuy, Type: OrderTypeMarket, QuoteQty: -1, Symbol: "BTC_USDT"},
+	}
+	for _, o := range cases {
+		result := m.ProcessOrder(o)
+		if o.Status != OrderStatusRejected || result.RejectReason != RejectInvalidQuoteQty {
+			t.Errorf("order %d: status = %s reason = %s", o.ID, o.Status, result.RejectReason)
+		}
+	}
+
+	// 预算不够买一个最小单位：不成交，直接取消
+	tiny := &Order{ID: 13, Side: SideBuy, Type: OrderTypeMarket, QuoteQty: 50, Symbol: "BTC_USDT"}
+	if result := m.ProcessOrder(tiny); len(result.Trades) != 0 || tiny.Status != OrderStatusCanceled {
+		t.Errorf("tiny budget: trades = %d status = %s", len(result.Trades), tiny.Status)
+	}
+}
+
+func TestQuoteOrder_WALEntryKeepsBudget(t *testing.T) {
+	o := &Order{ID: 10, UserID: 2, Side: SideBuy, Type: OrderTypeMarket, QuoteQty: 150 * PriceMultiplier, Symbol: "BTC_USDT", ClientOrderID: "c1"}
+	data := binary.LittleEndian.AppendUint64(appendOrder(nil, o), uint64(o.QuoteQty))
+
+	got := decodeOrder(data)
+	if got.QuoteQty != o.QuoteQty || got.ClientOrderID != "c1" {
+		t.Errorf("decoded quote qty = %d client id = %q", got.QuoteQty, got.ClientOrderID)
+	}
+	if plain := decodeOrder(appendOrder(nil, &Order{ID: 11, Symbol: "BTC_USDT"})); plain.QuoteQty != 0 {
+		t.Errorf("plain order quote qty = %d", plain.QuoteQty)
+	}
+}
@@ -0,0 +1,409 @@
+package mtrade
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// =============================================================================
+// 历史订单簿重建 (WAL replay)
+// =============================================================================
+//
+// 【场景】事故复盘要回答 "那一刻盘口长什么样、这笔成交吃穿了几档"，量化研究要逐笔盘口数据，
+// 生产引擎只有当前状态，行情推送的增量可能丢
+//
+// 【做法】离线读取 WAL 目录 / 归档目录 (见 WALConfig.ArchiveDir) 里的检查点和 WAL 段，
+// 在独立的 OrderBook + Matcher 上按序列号重放，与 WALRecovery 的规则相同：
+//   - 检查点挂单直接入簿 (按 CreatedAt, ID 恢复时间优先)，之后的下单条目重新撮合、撤单条目直接撤
+//   - 区间开始之前的条目只用来建簿，区间内按 WAL 时间输出周期 L2 快照和逐笔成交上下文
+//
+// 【注意】
+//   - 完全离线，不连接、不影响生产引擎
+//   - 撮合是确定性的，重放出的成交与当时一致；成交 ID 是重放时重新分配的，
+//     关联生产数据用 (TakerID, MakerID, WALSeq)
+//   - 时间取 WAL 条目的写入时间 (撮合前一刻)，与成交事件时间相差微秒级
+//   - 序列号有空洞 (缺段) 时报错，不输出不完整的盘口
+
+var (
+	// ErrReplayGap WAL 段不连续，或找不到能覆盖起点的检查点
+	ErrReplayGap = errors.New("WAL replay gap")
+)
+
+// ReplaySource 一次重放的输入：作为起点的检查点 + 按序列号排好的 WAL 条目
+type ReplaySource struct {
+	Checkpoints map[int64][]*Order // WAL 序列号 → 检查点挂单
+	Entries     []WALEntry         // 去重后按 Sequence 升序
+}
+
+// LoadReplaySource 读取若干目录下的检查点 (checkpoint_*.dat) 和 WAL 段 (wal*.log)
+//
+// 同一序列号在多个段里出现 (归档和当前文件重叠) 时只保留一条
+func LoadReplaySource(dirs ...string) (*ReplaySource, error) {
+	src := &ReplaySource{Checkpoints: make(map[int64][]*Order)}
+	seen := make(map[int64]bool)
+	for _, dir := range dirs {
+		checkpoints, err := filepath.Glob(filepath.Join(dir, "checkpoint_*.dat"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range checkpoints {
+			cp, err := LoadCheckpointFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("load %s: %w", f, err)
+			}
+			src.Checkpoints[cp.Seq] = cp.Orders
+		}
+		segments, err := filepath.Glob(filepath.Join(dir, "wal*.log"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range segments {
+			entries, err := ReadWALFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", f, err)
+			}
+			for _, e := range entries {
+				if !seen[e.Sequence] {
+					seen[e.Sequence] = true
+					src.Entries = append(src.Entries, e)
+				}
+			}
+		}
+	}
+	slices.SortFunc(src.Entries, func(a, b WALEntry) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	return src, nil
+}
+
+// ReplayConfig 重放参数
+type ReplayConfig struct {
+	Symbol           string        // 交易对 (WAL 按交易对分目录，这里用于校验和输出)
+	From, To         time.Time     // 输出区间 [From, To)，零值表示不限
+	SnapshotInterval time.Duration // 周期 L2 快照间隔 (按 WAL 时间)，<=0 不输出周期快照
+	Depth            int           // 快照每侧档位数，<=0 为 20
+	ContextDepth     int           // 成交上下文每侧档位数，<=0 为 5
+}
+
+func (c ReplayConfig) withDefaults() ReplayConfig {
+	if c.Depth <= 0 {
+		c.Depth = 20
+	}
+	if c.ContextDepth <= 0 {
+		c.ContextDepth = 5
+	}
+	return c
+}
+
+// BookSnapshot 某一时刻的 L2 快照
+type BookSnapshot struct {
+	Symbol string       `json:"symbol"`
+	Time   int64        `json:"time"`    // unix ns
+	WALSeq int64        `json:"wal_seq"` // 快照包含的最后一条 WAL
+	Bids   []DepthLevel `json:"bids"`
+	Asks   []DepthLevel `json:"asks"`
+}
+
+// TradeContext 一笔成交及 Taker 到达前的盘口
+type TradeContext struct {
+	Symbol      string `json:"symbol"`
+	Time        int64  `json:"time"`    // Taker 的 WAL 写入时间 (unix ns)
+	WALSeq      int64  `json:"wal_seq"` // Taker 的 WAL 序列号
+	Index       int    `json:"index"`   // 同一 Taker 的第几笔成交，从 0 开始
+	TakerID     int64  `json:"taker_id"`
+	MakerID     int64  `json:"maker_id"`
+	TakerUserID int64  `json:"taker_user_id"`
+	MakerUserID int64  `json:"maker_user_id"`
+	TakerSide   string `json:"taker_side"`
+	TakerType   string `json:"taker_type"`
+	TakerQty    int64  `json:"taker_qty"`
+	Price       int64  `json:"price"`
+	Qty         int64  `json:"qty"`
+
+	// Taker 到达前的盘口
+	BestBid     int64        `json:"best_bid"`
+	BestAsk     int64        `json:"best_ask"`
+	Bids        []DepthLevel `json:"bids"`
+	Asks        []DepthLevel `json:"asks"`
+	LevelsSwept int          `json:"levels_swept"` // 这个 Taker 一共吃到的价位数
+}
+
+// ReplaySink 重放输出
+type ReplaySink interface {
+	Snapshot(s *BookSnapshot) error
+	Trade(t *TradeContext) error
+}
+
+// ReplayStats 重放统计
+type ReplayStats struct {
+	BaseSeq   int64 // 作为起点的检查点序列号，0 表示从头重放
+	FirstSeq  int64 // 实际应用的第一条 / 最后一条 WAL
+	LastSeq   int64
+	Orders    int64 // 区间内的下单 / 撤单条目
+	Cancels   int64
+	Trades    int64
+	Snapshots int64
+}
+
+// Replay 按 cfg 重放 src，快照和成交写到 sink
+func Replay(src *ReplaySource, cfg ReplayConfig, sink ReplaySink) (ReplayStats, error) {
+	cfg = cfg.withDefaults()
+	var stats ReplayStats
+
+	base, err := src.base(cfg.From)
+	if err != nil {
+		return stats, err
+	}
+	stats.BaseSeq = base
+
+	ob := NewOrderBook(cfg.Symbol)
+	matcher := NewMatcher(ob)
+	orders := make([]*Order, 0, len(src.Checkpoints[base]))
+	for _, o := range src.Checkpoints[base] {
+		orders = append(orders, decodeOrder(appendOrder(nil, o)))
+	}
+	slices.SortFunc(orders, func(a, b *Order) int {
+		if c := cmp.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, o := range orders {
+		ob.AddOrder(o)
+	}
+
+	from, to := cfg.From.UnixNano(), cfg.To.UnixNano()
+	if cfg.From.IsZero() {
+		from = 0
+	}
+	if cfg.To.IsZero() {
+		to = 0
+	}
+	interval := cfg.SnapshotInterval.Nanoseconds()
+	next := from // 下一次周期快照的时间，0 表示按第一条区间内条目对齐
+	var lastSeq int64 = base
+	covered := false // WAL 覆盖到了 To，区间末尾的周期快照也要补齐
+
+	snapshot := func(at int64) error {
+		stats.Snapshots++
+		return sink.Snapshot(&BookSnapshot{
+			Symbol: cfg.Symbol,
+			Time:   at,
+			WALSeq: lastSeq,
+			Bids:   ob.getDepth(ob.bids, cfg.Depth),
+			Asks:   ob.getDepth(ob.asks, cfg.Depth),
+		})
+	}
+
+	for _, entry := range src.Entries {
+		if entry.Sequence <= base {
+			continue
+		}
+		if entry.Sequence != lastSeq+1 {
+			return stats, fmt.Errorf("%w: missing sequence %d..%d", ErrReplayGap, lastSeq+1, entry.Sequence-1)
+		}
+		ts := entry.Timestamp
+		if to != 0 && ts >= to {
+			covered = true
+			break
+		}
+		inRange := ts >= from
+
+		// 区间内：先补齐到当前时刻为止的周期快照 (反映本条之前的盘口)
+		if inRange && interval > 0 {
+			if next == 0 {
+				next = ts - ts%interval
+			}
+			for next <= ts {
+				if err := snapshot(next); err != nil {
+					return stats, err
+				}
+				next += interval
+			}
+		}
+
+		switch entry.Type {
+		case EntryPlaceOrder:
+			o := decodeOrder(entry.Data)
+			if !inRange {
+				PutMatchResult(matcher.ProcessOrder(o))
+				break
+			}
+			stats.Orders++
+			n, err := replayOrder(ob, matcher, o, entry, cfg, sink)
+			stats.Trades += int64(n)
+			if err != nil {
+				return stats, err
+			}
+		case EntryCancelOrder:
+			ob.CancelOrder(int64(binary.LittleEndian.Uint64(entry.Data)))
+			if inRange {
+				stats.Cancels++
+			}
+		}
+		if stats.FirstSeq == 0 {
+			stats.FirstSeq = entry.Sequence
+		}
+		lastSeq = entry.Sequence
+		stats.LastSeq = lastSeq
+	}
+	for covered && interval > 0 && next != 0 && next < to {
+		if err := snapshot(next); err != nil {
+			return stats, err
+		}
+		next += interval
+	}
+	return stats, nil
+}
+
+// replayOrder 撮合一笔区间内的订单，逐笔输出成交上下文
+func replayOrder(ob *OrderBook, matcher *Matcher, o *Order, entry WALEntry, cfg ReplayConfig, sink ReplaySink) (int, error) {
+	bids, asks := ob.getDepth(ob.bids, cfg.ContextDepth), ob.getDepth(ob.asks, cfg.ContextDepth)
+	var bestBid, bestAsk int64
+	if len(bids) > 0 {
+		bestBid = bids[0].Price
+	}
+	if len(asks) > 0 {
+		bestAsk = asks[0].Price
+	}
+	takerQty := o.Qty
+
+	result := matcher.ProcessOrder(o)
+	defer PutMatchResult(result)
+
+	levels := 0
+	for i, t := range result.Trades {
+		if i == 0 || t.Price != result.Trades[i-1].Price {
+			levels++
+		}
+	}
+	for i, t := range result.Trades {
+		tc := &TradeContext{
+			Symbol:      cfg.Symbol,
+			Time:        entry.Timestamp,
+			WALSeq:      entry.Sequence,
+			Index:       i,
+			TakerID:     t.TakerID,
+			MakerID:     t.MakerID,
+			TakerUserID: o.UserID,
+			MakerUserID: result.makers[i].UserID,
+			TakerSide:   o.Side.String(),
+			TakerType:   o.Type.String(),
+			TakerQty:    takerQty,
+			Price:       t.Price,
+			Qty:         t.Qty,
+			BestBid:     bestBid,
+			BestAsk:     bestAsk,
+			Bids:        bids,
+			Asks:        asks,
+			LevelsSwept: levels,
+		}
+		if err := sink.Trade(tc); err != nil {
+			return i, err
+		}
+	}
+	return len(result.Trades), nil
+}
+
+// base 选择重放起点：能连上后续条目、且第一条不晚于 from 的最新检查点；
+// 条目从序列号 1 开始时可以不用检查点
+func (s *ReplaySource) base(from time.Time) (int64, error) {
+	if len(s.Entries) == 0 {
+		return 0, fmt.Errorf("%w: no WAL entries", ErrReplayGap)
+	}
+	index := make(map[int64]WALEntry, len(s.Entries))
+	for _, e := range s.Entries {
+		index[e.Sequence] = e
+	}
+	covers := func(seq int64) bool {
+		e, ok := index[seq+1]
+		return ok && (from.IsZero() || e.Timestamp <= from.UnixNano())
+	}
+
+	candidates := make([]int64, 0, len(s.Checkpoints)+1)
+	for seq := range s.Checkpoints {
+		candidates = append(candidates, seq)
+	}
+	if _, ok := index[1]; ok {
+		candidates = append(candidates, 0)
+	}
+	slices.Sort(candidates)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if covers(candidates[i]) {
+			return candidates[i], nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no checkpoint at or before %s followed by WAL entries", ErrReplayGap, from.Format(time.RFC3339))
+}
+
+// =============================================================================
+// JSONL 输出
+// =============================================================================
+
+// JSONLSink 快照和成交各写一个 JSON Lines 流
+type JSONLSink struct {
+	snapshots *json.Encoder
+	trades    *json.Encoder
+}
+
+// NewJSONLSink 创建 JSONL 输出，任一 writer 为 nil 时丢弃对应数据
+func NewJSONLSink(snapshots, trades io.Writer) *JSONLSink {
+	s := &JSONLSink{}
+	if snapshots != nil {
+		s.snapshots = json.NewEncoder(snapshots)
+	}
+	if trades != nil {
+		s.trades = json.NewEncoder(trades)
+	}
+	return s
+}
+
+func (s *JSONLSink) Snapshot(b *BookSnapshot) error {
+	if s.snapshots == nil {
+		return nil
+	}
+	return s.snapshots.Encode(b)
+}
+
+func (s *JSONLSink) Trade(t *TradeContext) error {
+	if s.trades == nil {
+		return nil
+	}
+	return s.trades.Encode(t)
+}
+
+// ReplayToFiles 重放并写出 <outDir>/<symbol>_snapshots.jsonl 和 <outDir>/<symbol>_trades.jsonl
+func ReplayToFiles(src *ReplaySource, cfg ReplayConfig, outDir string) (ReplayStats, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return ReplayStats{}, err
+	}
+	name := cfg.Symbol
+	if name == "" {
+		name = "book"
+	}
+	snapFile, err := os.Create(filepath.Join(outDir, name+"_snapshots.jsonl"))
+	if err != nil {
+		return ReplayStats{}, err
+	}
+	defer snapFile.Close()
+	tradeFile, err := os.Create(filepath.Join(outDir, name+"_trades.jsonl"))
+	if err != nil {
+		return ReplayStats{}, err
+	}
+	defer tradeFile.Close()
+
+	stats, err := Replay(src, cfg, NewJSONLSink(snapFile, tradeFile))
+	if err != nil {
+		return stats, err
+	}
+	if err := snapFile.Sync(); err != nil {
+		return stats, err
+	}
+	return stats, tradeFile.Sync()
+}
@@ -0,0 +1,117 @@
+package mtrade
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// memorySink 收集重放输出
+type memorySink struct {
+	snapshots []*BookSnapshot
+	trades    []*TradeContext
+}
+
+func (s *memorySink) Snapshot(b *BookSnapshot) error {
+	s.snapshots = append(s.snapshots, b)
+	return nil
+}
+func (s *memorySink) Trade(t *TradeContext) error { s.trades = append(s.trades, t); return nil }
+
+func TestReplay_FromCheckpointAndArchive(t *testing.T) {
+	dir, archive := t.TempDir(), t.TempDir()
+	cfg := DefaultWALConfig(dir)
+	cfg.ArchiveDir = archive
+	wal, err := NewWAL(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+
+	resting := []*Order{
+		{ID: 1, UserID: 11, Symbol: "BTC_USDT", Side: SideSell, Price: 101, Qty: 5, CreatedAt: 1},
+		{ID: 2, UserID: 12, Symbol: "BTC_USDT", Side: SideSell, Price: 102, Qty: 5, CreatedAt: 2},
+		{ID: 3, UserID: 13, Symbol: "BTC_USDT", Side: SideBuy, Price: 99, Qty: 5, CreatedAt: 3},
+	}
+	for _, o := range resting {
+		if _, err := wal.WriteOrder(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 检查点之后截断，旧段进归档目录
+	if err := wal.CreateCheckpoint(3, resting, IDWatermark{OrderID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Truncate(); err != nil {
+		t.Fatal(err)
+	}
+	// 吃穿两档，再撤掉买单
+	wal.WriteOrder(&Order{ID: 4, UserID: 20, Symbol: "BTC_USDT", Side: SideBuy, Price: 102, Qty: 7, CreatedAt: 4})
+	wal.WriteCancelOrder(3)
+	if err := wal.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := LoadReplaySource(dir, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(src.Entries) != 5 || len(src.Checkpoints[3]) != 3 {
+		t.Fatalf("entries=%d checkpoints=%v", len(src.Entries), src.Checkpoints)
+	}
+	// WAL 时间用的是写入时刻，改成每秒一条方便断言
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for i := range src.Entries {
+		src.Entries[i].Timestamp = start.Add(time.Duration(i) * time.Second).UnixNano()
+	}
+
+	from := start.Add(3 * time.Second) // 第 4 条 (Taker) 的时间
+	sink := &memorySink{}
+	stats, err := Replay(src, ReplayConfig{
+		Symbol:           "BTC_USDT",
+		From:             from,
+		To:               from.Add(time.Minute),
+		SnapshotInterval: time.Second,
+	}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BaseSeq != 3 || stats.FirstSeq != 4 || stats.LastSeq != 5 || stats.Trades != 2 || stats.Cancels != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	if len(sink.trades) != 2 {
+		t.Fatalf("trades %+v", sink.trades)
+	}
+	for i, want := range []struct{ price, qty, maker, makerUser int64 }{{101, 5, 1, 11}, {102, 2, 2, 12}} {
+		tc := sink.trades[i]
+		if tc.Price != want.price || tc.Qty != want.qty || tc.MakerID != want.maker || tc.MakerUserID != want.makerUser {
+			t.Errorf("trade %d: %+v", i, tc)
+		}
+		if tc.TakerID != 4 || tc.TakerUserID != 20 || tc.TakerSide != "BUY" || tc.WALSeq != 4 || tc.Index != i {
+			t.Errorf("trade %d taker: %+v", i, tc)
+		}
+		// Taker 到达前的盘口
+		if tc.BestBid != 99 || tc.BestAsk != 101 || len(tc.Asks) != 2 || tc.LevelsSwept != 2 {
+			t.Errorf("trade %d context: %+v", i, tc)
+		}
+	}
+
+	// 快照反映条目应用之前的盘口：from 时刻 (Taker 之前)、from+1s (撤单之前)
+	if len(sink.snapshots) != 2 {
+		t.Fatalf("snapshots %d", len(sink.snapshots))
+	}
+	before, after := sink.snapshots[0], sink.snapshots[1]
+	if before.WALSeq != 3 || len(before.Asks) != 2 || len(before.Bids) != 1 {
+		t.Errorf("snapshot before taker %+v", before)
+	}
+	if after.WALSeq != 4 || len(after.Asks) != 1 || after.Asks[0].Price != 102 || after.Asks[0].Quantity != 3 || len(after.Bids) != 1 {
+		t.Errorf("snapshot after taker %+v", after)
+	}
+
+	// 缺段：报错而不是输出不完整的盘口
+	src.Entries = append(src.Entries[:3:3], src.Entries[4])
+	if _, err := Replay(src, ReplayConfig{Symbol: "BTC_USDT"}, &memorySink{}); !errors.Is(err, ErrReplayGap) {
+		t.Fatalf("gap: %v", err)
+	}
+}
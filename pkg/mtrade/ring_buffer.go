@@ -0,0 +1,133 @@
+package mtrade
+
+import (
+	"sync/atomic"
+)
+
+// =============================================================================
+// 无锁 MPSC 环形队列 (Multi-Producer Single-Consumer Ring Buffer)
+// =============================================================================
+//
+// 【面试高频】为什么 channel 在高并发下成为瓶颈？
+//   - channel 内部是一把 mutex + 环形数组，所有生产者、消费者抢同一把锁
+//   - 多个下单 goroutine 同时写入时，锁竞争 + goroutine 调度开销占大头
+//
+// 本实现（Dmitry Vyukov 的有界队列算法，简化为单消费者）：
+//   - 每个槽位带一个序列号 seq，用来判断 "可写 / 可读"
+//   - 生产者：CAS 抢占 tail，写入数据后发布 seq = pos+1
+//   - 消费者：只有一个（matchLoop），head 无需 CAS
+//
+//   slot.seq == pos        → 槽位空闲，生产者可写
+//   slot.seq == pos+1      → 数据已发布，消费者可读
+//   slot.seq == pos+cap    → 已被消费，留给下一圈的生产者
+//
+// 与 Disruptor 的区别：这里不预分配事件对象，只传 *Order 指针
+
+// cacheLinePad 填充到缓存行，避免 head/tail 伪共享
+type cacheLinePad [64]byte
+
+// ringSlot 环形队列槽位
+type ringSlot struct {
+	seq   atomic.Uint64
+	order *Order
+}
+
+// MPSCRing 无锁多生产者单消费者环形队列
+type MPSCRing struct {
+	_    cacheLinePad
+	tail atomic.Uint64 // 生产者竞争的写位置
+	_    cacheLinePad
+	head atomic.Uint64 // 消费者读位置（仅消费者写，原子是为了 Len 可被外部读取）
+	_    cacheLinePad
+
+	mask  uint64
+	slots []ringSlot
+}
+
+// NewMPSCRing 创建环形队列，容量向上取整到 2 的幂
+func NewMPSCRing(capacity int) *MPSCRing {
+	size := uint64(2)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+
+	r := &MPSCRing{
+		mask:  size - 1,
+		slots: make([]ringSlot, size),
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// Offer 入队（多生产者并发安全）
+// 队列满时返回 false，不阻塞
+func (r *MPSCRing) Offer(order *Order) bool {
+	for {
+		pos := r.tail.Load()
+		slot := &r.slots[pos&r.mask]
+		seq := slot.seq.Load()
+
+		switch diff := int64(seq) - int64(pos); {
+		case diff == 0:
+			// 槽位空闲，抢占
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				slot.order = order
+				slot.seq.Store(pos + 1) // 发布
+				return true
+			}
+		case diff < 0:
+			// 上一圈的数据还没被消费：队列满
+			return false
+		}
+		// diff > 0: 其他生产者已抢走该位置，重试
+	}
+}
+
+// Poll 出队一个元素（仅消费者调用）
+func (r *MPSCRing) Poll() (*Order, bool) {
+	pos := r.head.Load()
+	slot := &r.slots[pos&r.mask]
+	if slot.seq.Load() != pos+1 {
+		return nil, false // 空，或生产者尚未发布
+	}
+
+	order := slot.order
+	slot.order = nil
+	slot.seq.Store(pos + r.mask + 1) // 归还给下一圈
+	r.head.Store(pos + 1)
+	return order, true
+}
+
+// PollBatch 批量出队（仅消费者调用），返回取到的数量
+// 【优化】matchLoop 一次取一批，摊薄唤醒和循环开销
+func (r *MPSCRing) PollBatch(buf []*Order) int {
+	n := 0
+	for n < len(buf) {
+		order, ok := r.Poll()
+		if !ok {
+			break
+		}
+		buf[n] = order
+		n++
+	}
+	return n
+}
+
+// IsEmpty 是否没有可消费的元素（仅消费者调用）
+func (r *MPSCRing) IsEmpty() bool {
+	pos := r.head.Load()
+	return r.slots[pos&r.mask].seq.Load() != pos+1
+}
+
+// Len 当前队列长度（近似值，监控用）
+func (r *MPSCRing) Len() int {
+	head := r.head.Load() // 先读 head，保证 tail >= head
+	return int(r.tail.Load() - head)
+}
+
+// Cap 队列容量
+func (r *MPSCRing) Cap() int {
+	return len(r.slots)
+}
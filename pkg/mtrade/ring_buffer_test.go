@@ -0,0 +1,234 @@
+package mtrade
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// MPSC 环形队列测试
+// =============================================================================
+
+func TestMPSCRing_FIFO(t *testing.T) {
+	r := NewMPSCRing(5) // 取整为 8
+	if r.Cap() != 8 {
+		t.Fatalf("expected cap 8, got %d", r.Cap())
+	}
+
+	// 多绕几圈，验证序列号回收正确
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 8; i++ {
+			if !r.Offer(&Order{ID: int64(i)}) {
+				t.Fatalf("round %d: offer %d failed", round, i)
+			}
+		}
+		if r.Offer(&Order{ID: 99}) {
+			t.Fatalf("round %d: expected full ring to reject", round)
+		}
+		if r.Len() != 8 {
+			t.Fatalf("round %d: expected len 8, got %d", round, r.Len())
+		}
+
+		for i := 0; i < 8; i++ {
+			o, ok := r.Poll()
+			if !ok || o.ID != int64(i) {
+				t.Fatalf("round %d: expected order %d, got %v (ok=%v)", round, i, o, ok)
+			}
+		}
+		if !r.IsEmpty() {
+			t.Fatalf("round %d: expected empty ring", round)
+		}
+	}
+}
+
+func TestMPSCRing_MultiProducer(t *testing.T) {
+	const producers, perProducer = 8, 10000
+
+	r := NewMPSCRing(1024)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				o := &Order{ID: int64(p*perProducer + i)}
+				for !r.Offer(o) {
+					runtime.Gosched() // 队列满，让出给消费者
+				}
+			}
+		}(p)
+	}
+
+	// 单消费者：每个订单恰好收到一次，且同一生产者内保持 FIFO
+	seen := make([]bool, producers*perProducer)
+	lastPerProducer := make([]int64, producers)
+	for i := range lastPerProducer {
+		lastPerProducer[i] = -1
+	}
+
+	batch := make([]*Order, 64)
+	for received := 0; received < producers*perProducer; {
+		n := r.PollBatch(batch)
+		if n == 0 {
+			runtime.Gosched()
+		}
+		for i := 0; i < n; i++ {
+			id := batch[i].ID
+			if seen[id] {
+				t.Fatalf("order %d received twice", id)
+			}
+			seen[id] = true
+
+			p := id / perProducer
+			if id <= lastPerProducer[p] {
+				t.Fatalf("producer %d out of order: %d after %d", p, id, lastPerProducer[p])
+			}
+			lastPerProducer[p] = id
+		}
+		received += n
+	}
+	wg.Wait()
+}
+
+func TestEngine_RingBufferIntake(t *testing.T) {
+	config := DefaultEngineConfig("BTC_USDT")
+	config.IntakeMode = IntakeRingBuffer
+	engine := mustNewEngine(t, config)
+
+	var trades, canceled atomic.Int64
+	engine.OnEvent(func(e Event) {
+		switch e.Type {
+		case EventTrade:
+			trades.Add(1)
+		case EventOrderCanceled:
+			canceled.Add(1)
+		}
+	})
+
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	// 先挂卖单，等 matchLoop 进入休眠后再下买单，验证唤醒路径
+	engine.SubmitOrder(&Order{ID: 1, Side: SideSell, Price: 50000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	time.Sleep(20 * time.Millisecond)
+	engine.SubmitOrder(&Order{ID: 2, Side: SideBuy, Price: 50000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+
+	deadline := time.Now().Add(time.Second)
+	for trades.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if trades.Load() != 1 {
+		t.Fatalf("expected 1 trade, got %d", trades.Load())
+	}
+
+	// 撤单在环形队列模式下仍然走 cancelCh
+	engine.SubmitOrder(&Order{ID: 3, Side: SideBuy, Price: 49000, Qty: 10, Symbol: "BTC_USDT", Type: OrderTypeLimit})
+	time.Sleep(10 * time.Millisecond)
+	engine.CancelOrder(3)
+	time.Sleep(10 * time.Millisecond)
+	if canceled.Load() != 1 {
+		t.Errorf("expected order 3 to be canceled")
+	}
+}
+
+// =============================================================================
+// 入口队列基准测试：channel vs MPSC ring
+// =============================================================================
+//
+//   go test ./pkg/mtrade -run=^$ -bench=Intake -cpu=1,4,8
+
+// BenchmarkIntake_Channel 多生产者写 channel，单消费者读
+func BenchmarkIntake_Channel(b *testing.B) {
+	ch := make(chan *Order, 10000)
+	done := make(chan struct{})
+	go func() {
+		for o := range ch {
+			_ = o
+		}
+		close(done)
+	}()
+
+	order := &Order{ID: 1}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ch <- order
+		}
+	})
+	b.StopTimer()
+	close(ch)
+	<-done
+}
+
+// BenchmarkIntake_MPSCRing 多生产者写环形队列，单消费者批量读
+func BenchmarkIntake_MPSCRing(b *testing.B) {
+	r := NewMPSCRing(10000)
+	var stop atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		batch := make([]*Order, 64)
+		for !stop.Load() || !r.IsEmpty() {
+			if r.PollBatch(batch) == 0 {
+				runtime.Gosched()
+			}
+		}
+		close(done)
+	}()
+
+	order := &Order{ID: 1}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for !r.Offer(order) {
+				runtime.Gosched()
+			}
+		}
+	})
+	b.StopTimer()
+	stop.Store(true)
+	<-done
+}
+
+// benchmarkEngineIntake 引擎级别对比：并发下单直到全部撮合完成
+func benchmarkEngineIntake(b *testing.B, mode IntakeMode) {
+	config := DefaultEngineConfig("BTC_USDT")
+	config.IntakeMode = mode
+	config.OrderQueueSize = 65536
+	engine := mustNewEngine(b, config)
+	engine.Start(context.Background())
+	defer engine.Stop()
+
+	var nextID atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := nextID.Add(1)
+			o := &Order{
+				ID:     id,
+				Side:   Side(1 - 2*(id&1)), // 买卖交替，保持盘口不膨胀
+				Price:  50000,
+				Qty:    1,
+				Symbol: "BTC_USDT",
+				Type:   OrderTypeLimit,
+			}
+			for !engine.SubmitOrder(o) {
+				runtime.Gosched()
+			}
+		}
+	})
+	for engine.GetStats().LatencySamples < uint64(b.N) {
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func BenchmarkEngineIntake_Channel(b *testing.B) {
+	benchmarkEngineIntake(b, IntakeChannel)
+}
+
+func BenchmarkEngineIntake_MPSCRing(b *testing.B) {
+	benchmarkEngineIntake(b, IntakeRingBuffer)
+}
@@ -0,0 +1,447 @@
+package mtrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 按交易对路由撮合引擎 (EngineRouter)
+// =============================================================================
+//
+// 【场景】多引擎部署：一部分交易对在进程内撮合，另一部分跑在独立的撮合服务上 (gRPC)，
+// 每个交易对还可以有备用副本。结算侧 (spot / futures 处理器) 不应该关心订单落到哪个实例
+//
+// 【做法】
+//   - EngineClient 抽象出处理器用到的撮合接口，*Engine 直接实现，远程客户端实现同一接口
+//   - EngineRouter 按交易对给出当前负责的引擎；SingleEngine 是单引擎部署的退化形式
+//   - SymbolRouter 每个交易对登记一组副本 (第一个为主)，后台按 CheckInterval Ping 每个副本：
+//     当前副本连续 FailThreshold 次失败且有健康的副本时切换过去
+//   - 事件处理器在所有副本上注册 (含后登记的)：切换后新主的事件直接就能收到，
+//     旧主的迟到事件由 epoch.Fence 丢弃
+//
+// 【注意】
+//   - 切换只改路由，不负责提升备机：备机要先 Promote(新纪元) + Start 才能 Ping 通
+//   - 旧主恢复后不自动切回，避免两个实例来回抖动；需要切回时调用 SetActive
+//   - 按订单 ID / 用户的操作 (撤单、全撤、查 clientOid) 拿不到交易对时，发给每个交易对的当前引擎
+
+var (
+	// ErrEngineNotStarted 引擎还没启动（备机未提升）
+	ErrEngineNotStarted = errors.New("engine not started")
+	// ErrSymbolNotRouted 交易对没有登记引擎
+	ErrSymbolNotRouted = errors.New("symbol not routed")
+	// ErrNoHealthyEngine 交易对的所有副本都不可用
+	ErrNoHealthyEngine = errors.New("no healthy engine")
+)
+
+// EngineClient 撮合引擎客户端（进程内 *Engine 或远程客户端）
+type EngineClient interface {
+	SubmitOrder(order *Order) bool
+	CancelOrder(orderID int64) bool
+	CancelAllByUser(ctx context.Context, userID int64) ([]int64, error)
+	CancelAll(ctx context.Context) ([]int64, error)
+	LookupClientOrder(userID int64, clientOrderID string) (int64, bool)
+	OnEventWithOptions(handler EventHandler, opts HandlerOptions)
+	// Ping 健康检查，nil 表示可以接单
+	Ping(ctx context.Context) error
+}
+
+var _ EngineClient = (*Engine)(nil)
+
+// EngineRouter 交易对 → 撮合引擎
+type EngineRouter interface {
+	// Route 交易对当前负责的引擎
+	Route(symbol string) (EngineClient, error)
+	// Engines 各交易对当前负责的引擎（去重），拿不到交易对的操作逐个发送
+	Engines() []EngineClient
+	// Subscribe 在所有引擎（含备用副本）上注册事件处理器
+	Subscribe(handler EventHandler, opts HandlerOptions)
+}
+
+// Ping 健康检查：已停止、迁移冻结、尚未启动时返回对应错误
+func (e *Engine) Ping(ctx context.Context) error {
+	select {
+	case <-e.stopCh:
+		return ErrEngineStopped
+	default:
+	}
+	if e.frozen.Load() {
+		return ErrEngineFrozen
+	}
+	e.mu.Lock()
+	started := e.started
+	e.mu.Unlock()
+	if !started {
+		return ErrEngineNotStarted
+	}
+	return ctx.Err()
+}
+
+// =============================================================================
+// 按路由下单 / 撤单
+// =============================================================================
+
+// RouteSubmit 按 order.Symbol 路由提交，false 表示没有可用引擎或撮合队列满
+func RouteSubmit(r EngineRouter, order *Order) bool {
+	c, err := r.Route(order.Symbol)
+	if err != nil {
+		log.Printf("[EngineRouter] submit order %d: %v", order.ID, err)
+		return false
+	}
+	return c.SubmitOrder(order)
+}
+
+// RouteCancel 撤单：symbol 非空时发给该交易对的当前引擎，否则发给每个引擎
+// （不在簿上的订单引擎直接忽略），任一引擎入队即返回 true
+func RouteCancel(r EngineRouter, symbol string, orderID int64) bool {
+	if symbol != "" {
+		c, err := r.Route(symbol)
+		if err != nil {
+			log.Printf("[EngineRouter] cancel order %d: %v", orderID, err)
+			return false
+		}
+		return c.CancelOrder(orderID)
+	}
+	ok := false
+	for _, c := range r.Engines() {
+		ok = c.CancelOrder(orderID) || ok
+	}
+	return ok
+}
+
+// RouteCancelAllByUser 在每个引擎上撤掉用户的全部挂单，返回撤掉的订单 ID
+// 某个引擎失败时返回已撤掉的部分和第一个错误
+func RouteCancelAllByUser(ctx context.Context, r EngineRouter, userID int64) ([]int64, error) {
+	var all []int64
+	var firstErr error
+	for _, c := range r.Engines() {
+		ids, err := c.CancelAllByUser(ctx, userID)
+		all = append(all, ids...)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return all, firstErr
+}
+
+// RouteCancelAll 在每个引擎上撤掉全部挂单，返回撤掉的订单 ID
+// 某个引擎失败时返回已撤掉的部分和第一个错误
+func RouteCancelAll(ctx context.Context, r EngineRouter) ([]int64, error) {
+	var all []int64
+	var firstErr error
+	for _, c := range r.Engines() {
+		ids, err := c.CancelAll(ctx)
+		all = append(all, ids...)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return all, firstErr
+}
+
+// RouteLookupClientOrder 在每个引擎上按 clientOid 查订单 ID
+func RouteLookupClientOrder(r EngineRouter, userID int64, clientOrderID string) (int64, bool) {
+	for _, c := range r.Engines() {
+		if id, ok := c.LookupClientOrder(userID, clientOrderID); ok {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// =============================================================================
+// SingleEngine
+// =============================================================================
+
+// singleEngine 所有交易对走同一个引擎
+type singleEngine struct {
+	client EngineClient
+}
+
+// SingleEngine 单引擎路由（所有交易对走 client）
+func SingleEngine(client EngineClient) EngineRouter {
+	return singleEngine{client: client}
+}
+
+func (s singleEngine) Route(string) (EngineClient, error) { return s.client, nil }
+func (s singleEngine) Engines() []EngineClient            { return []EngineClient{s.client} }
+func (s singleEngine) Subscribe(handler EventHandler, opts HandlerOptions) {
+	s.client.OnEventWithOptions(handler, opts)
+}
+
+// =============================================================================
+// SymbolRouter
+// =============================================================================
+
+// SymbolRouterConfig 路由配置
+type SymbolRouterConfig struct {
+	// CheckInterval 健康检查间隔
+	CheckInterval time.Duration
+	// PingTimeout 单次 Ping 超时
+	PingTimeout time.Duration
+	// FailThreshold 当前副本连续失败多少次后切换
+	FailThreshold int
+}
+
+// DefaultSymbolRouterConfig 默认配置
+func DefaultSymbolRouterConfig() SymbolRouterConfig {
+	return SymbolRouterConfig{
+		CheckInterval: time.Second,
+		PingTimeout:   200 * time.Millisecond,
+		FailThreshold: 3,
+	}
+}
+
+func (c SymbolRouterConfig) withDefaults() SymbolRouterConfig {
+	d := DefaultSymbolRouterConfig()
+	if c.CheckInterval <= 0 {
+		c.CheckInterval = d.CheckInterval
+	}
+	if c.PingTimeout <= 0 {
+		c.PingTimeout = d.PingTimeout
+	}
+	if c.FailThreshold <= 0 {
+		c.FailThreshold = d.FailThreshold
+	}
+	return c
+}
+
+// SymbolRouter 按交易对路由，带健康检查和副本切换
+type SymbolRouter struct {
+	cfg SymbolRouterConfig
+
+	mu          sync.RWMutex
+	routes      map[string]*route
+	subscribers []subscriber
+	subscribed  map[EngineClient]bool // 已注册过事件处理器的客户端（同一客户端可服务多个交易对）
+	failovers   int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type route struct {
+	replicas []*engineReplica
+	active   int
+}
+
+type engineReplica struct {
+	client   EngineClient
+	failures int   // 连续失败次数
+	err      error // 上次检查的错误
+}
+
+type subscriber struct {
+	handler EventHandler
+	opts    HandlerOptions
+}
+
+// RouteStatus 交易对路由状态
+type RouteStatus struct {
+	Symbol   string
+	Active   int     // 当前副本下标
+	Errors   []error // 各副本上次检查的错误，nil 表示健康
+	Failures []int   // 各副本连续失败次数
+}
+
+// NewSymbolRouter 创建路由
+func NewSymbolRouter(cfg SymbolRouterConfig) *SymbolRouter {
+	return &SymbolRouter{
+		cfg:        cfg.withDefaults(),
+		routes:     make(map[string]*route),
+		subscribed: make(map[EngineClient]bool),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Register 登记交易对的副本，第一个为主；已登记的交易对整体替换
+// 已注册的事件处理器会补注册到新客户端上
+func (r *SymbolRouter) Register(symbol string, replicas ...EngineClient) error {
+	if len(replicas) == 0 {
+		return fmt.Errorf("register %s: no replicas", symbol)
+	}
+	rt := &route{}
+	for _, c := range replicas {
+		rt.replicas = append(rt.replicas, &engineReplica{client: c})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[symbol] = rt
+	for _, c := range replicas {
+		r.subscribeLocked(c)
+	}
+	return nil
+}
+
+// Route 交易对当前负责的引擎
+func (r *SymbolRouter) Route(symbol string) (EngineClient, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rt, ok := r.routes[symbol]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSymbolNotRouted, symbol)
+	}
+	rep := rt.replicas[rt.active]
+	if rep.failures >= r.cfg.FailThreshold {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNoHealthyEngine, symbol, rep.err)
+	}
+	return rep.client, nil
+}
+
+// Engines 各交易对当前负责的引擎（去重）
+func (r *SymbolRouter) Engines() []EngineClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[EngineClient]bool, len(r.routes))
+	out := make([]EngineClient, 0, len(r.routes))
+	for _, rt := range r.routes {
+		c := rt.replicas[rt.active].client
+		if !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Subscribe 在所有已登记和之后登记的引擎上注册事件处理器
+func (r *SymbolRouter) Subscribe(handler EventHandler, opts HandlerOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub := subscriber{handler: handler, opts: opts}
+	r.subscribers = append(r.subscribers, sub)
+	for c := range r.subscribed {
+		c.OnEventWithOptions(sub.handler, sub.opts)
+	}
+}
+
+// subscribeLocked 新客户端补注册所有事件处理器（调用方持有 r.mu）
+func (r *SymbolRouter) subscribeLocked(c EngineClient) {
+	if r.subscribed[c] {
+		return
+	}
+	r.subscribed[c] = true
+	for _, sub := range r.subscribers {
+		c.OnEventWithOptions(sub.handler, sub.opts)
+	}
+}
+
+// SetActive 手动把交易对切到第 idx 个副本（运维切回原主）
+func (r *SymbolRouter) SetActive(symbol string, idx int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt, ok := r.routes[symbol]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSymbolNotRouted, symbol)
+	}
+	if idx < 0 || idx >= len(rt.replicas) {
+		return fmt.Errorf("set active %s: replica %d out of range", symbol, idx)
+	}
+	rt.active = idx
+	return nil
+}
+
+// Start 启动后台健康检查（启动时先同步检查一轮）
+func (r *SymbolRouter) Start() {
+	r.CheckNow(context.Background())
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.CheckNow(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop 停止健康检查
+func (r *SymbolRouter) Stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	r.wg.Wait()
+}
+
+// CheckNow Ping 一轮所有副本，当前副本不可用时切换到第一个健康的副本
+func (r *SymbolRouter) CheckNow(ctx context.Context) {
+	// Ping 可能是网络调用，不持锁
+	r.mu.RLock()
+	results := make(map[*engineReplica]error)
+	for _, rt := range r.routes {
+		for _, rep := range rt.replicas {
+			results[rep] = nil
+		}
+	}
+	r.mu.RUnlock()
+	for rep := range results {
+		pctx, cancel := context.WithTimeout(ctx, r.cfg.PingTimeout)
+		results[rep] = rep.client.Ping(pctx)
+		cancel()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for symbol, rt := range r.routes {
+		for _, rep := range rt.replicas {
+			err, ok := results[rep]
+			if !ok {
+				continue // 检查期间新登记的
+			}
+			rep.err = err
+			if err == nil {
+				rep.failures = 0
+			} else {
+				rep.failures++
+			}
+		}
+
+		cur := rt.replicas[rt.active]
+		if cur.failures < r.cfg.FailThreshold {
+			continue
+		}
+		for i, rep := range rt.replicas {
+			if i != rt.active && rep.failures == 0 && rep.err == nil {
+				if _, checked := results[rep]; !checked {
+					continue
+				}
+				log.Printf("[EngineRouter] %s failover: replica %d -> %d (%v)", symbol, rt.active, i, cur.err)
+				rt.active = i
+				r.failovers++
+				break
+			}
+		}
+	}
+}
+
+// Status 各交易对的路由状态
+func (r *SymbolRouter) Status() []RouteStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]RouteStatus, 0, len(r.routes))
+	for symbol, rt := range r.routes {
+		st := RouteStatus{Symbol: symbol, Active: rt.active}
+		for _, rep := range rt.replicas {
+			st.Errors = append(st.Errors, rep.err)
+			st.Failures = append(st.Failures, rep.failures)
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Failovers 自动切换次数
+func (r *SymbolRouter) Failovers() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.failovers
+}
@@ -0,0 +1,119 @@
+package mtrade
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeClient 可控健康状态的引擎客户端
+type fakeClient struct {
+	pingErr  error
+	handlers int
+	orders   []int64
+	cancels  []int64
+}
+
+func (c *fakeClient) SubmitOrder(order *Order) bool {
+	c.orders = append(c.orders, order.ID)
+	return true
+}
+func (c *fakeClient) CancelOrder(orderID int64) bool {
+	c.cancels = append(c.cancels, orderID)
+	return true
+}
+func (c *fakeClient) CancelAllByUser(context.Context, int64) ([]int64, error) { return nil, nil }
+func (c *fakeClient) CancelAll(context.Context) ([]int64, error)              { return nil, nil }
+func (c *fakeClient) LookupClientOrder(int64, string) (int64, bool)           { return 0, false }
+func (c *fakeClient) OnEventWithOptions(EventHandler, HandlerOptions)         { c.handlers++ }
+func (c *fakeClient) Ping(context.Context) error                              { return c.pingErr }
+
+func TestSymbolRouter_RouteAndSubscribe(t *testing.T) {
+	r := NewSymbolRouter(SymbolRouterConfig{})
+	btc, eth := &fakeClient{}, &fakeClient{}
+	if err := r.Register("BTC_USDT", btc); err != nil {
+		t.Fatal(err)
+	}
+	r.Subscribe(func(Event) {}, HandlerOptions{})
+	// 后登记的引擎补注册事件处理器，共用的客户端只注册一次
+	if err := r.Register("ETH_USDT", eth, btc); err != nil {
+		t.Fatal(err)
+	}
+	if btc.handlers != 1 || eth.handlers != 1 {
+		t.Fatalf("handlers btc=%d eth=%d", btc.handlers, eth.handlers)
+	}
+
+	if !RouteSubmit(r, &Order{ID: 1, Symbol: "ETH_USDT"}) || len(eth.orders) != 1 || len(btc.orders) != 0 {
+		t.Fatalf("eth orders %v, btc orders %v", eth.orders, btc.orders)
+	}
+	if RouteSubmit(r, &Order{ID: 2, Symbol: "SOL_USDT"}) {
+		t.Fatal("unrouted symbol should fail")
+	}
+	if _, err := r.Route("SOL_USDT"); !errors.Is(err, ErrSymbolNotRouted) {
+		t.Fatalf("route unknown: %v", err)
+	}
+
+	// 不知道交易对的撤单发给每个当前引擎
+	if !RouteCancel(r, "", 9) || len(btc.cancels) != 1 || len(eth.cancels) != 1 {
+		t.Fatalf("broadcast cancel btc=%v eth=%v", btc.cancels, eth.cancels)
+	}
+}
+
+func TestSymbolRouter_Failover(t *testing.T) {
+	r := NewSymbolRouter(SymbolRouterConfig{FailThreshold: 2})
+	primary, standby := &fakeClient{}, &fakeClient{}
+	if err := r.Register("BTC_USDT", primary, standby); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 备机未提升前 Ping 不通，不会被切过去
+	primary.pingErr, standby.pingErr = ErrEngineStopped, ErrEngineNotStarted
+	r.CheckNow(ctx)
+	if c, _ := r.Route("BTC_USDT"); c != primary {
+		t.Fatal("one failure below threshold should keep primary")
+	}
+	r.CheckNow(ctx)
+	if _, err := r.Route("BTC_USDT"); !errors.Is(err, ErrNoHealthyEngine) {
+		t.Fatalf("no healthy replica: %v", err)
+	}
+
+	// 备机提升后切换
+	standby.pingErr = nil
+	r.CheckNow(ctx)
+	if c, err := r.Route("BTC_USDT"); err != nil || c != standby {
+		t.Fatalf("route after failover: %v %v", c, err)
+	}
+	if r.Failovers() != 1 {
+		t.Fatalf("failovers = %d", r.Failovers())
+	}
+
+	// 原主恢复后不自动切回
+	primary.pingErr = nil
+	r.CheckNow(ctx)
+	if c, _ := r.Route("BTC_USDT"); c != standby {
+		t.Fatal("should not fail back automatically")
+	}
+	if err := r.SetActive("BTC_USDT", 0); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := r.Route("BTC_USDT"); c != primary {
+		t.Fatal("SetActive should switch back")
+	}
+}
+
+func TestEngine_Ping(t *testing.T) {
+	engine := mustNewEngine(t, DefaultEngineConfig("BTC_USDT"))
+	ctx := context.Background()
+	if err := engine.Ping(ctx); !errors.Is(err, ErrEngineNotStarted) {
+		t.Fatalf("before start: %v", err)
+	}
+	engine.Start(ctx)
+	if err := engine.Ping(ctx); err != nil {
+		t.Fatalf("running: %v", err)
+	}
+	engine.Stop()
+	if err := engine.Ping(ctx); !errors.Is(err, ErrEngineStopped) {
+		t.Fatalf("after stop: %v", err)
+	}
+}
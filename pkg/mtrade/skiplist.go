@@ -34,7 +34,7 @@ const (
 // SkipListNode 跳表节点
 type SkipListNode struct {
 	price int64           // 价格（排序键）
-	level *RingPriceLevel // 价格档位
+	level *PriceLevel     // 价格档位
 	next  []*SkipListNode // 各层的下一个节点
 }
 
@@ -44,7 +44,7 @@ func (n *SkipListNode) GetPrice() int64 {
 }
 
 // GetLevel 实现 PriceLevelNode 接口
-func (n *SkipListNode) GetLevel() *RingPriceLevel {
+func (n *SkipListNode) GetLevel() *PriceLevel {
 	return n.level
 }
 
@@ -52,7 +52,7 @@ func (n *SkipListNode) GetLevel() *RingPriceLevel {
 func newNode(price int64, height int) *SkipListNode {
 	return &SkipListNode{
 		price: price,
-		level: NewRingPriceLevel(price),
+		level: NewPriceLevel(price),
 		next:  make([]*SkipListNode, height),
 	}
 }
@@ -0,0 +1,113 @@
+package mtrade
+
+import (
+	"context"
+	"errors"
+)
+
+// =============================================================================
+// 同步下单 (SubmitOrderSync)
+// =============================================================================
+//
+// 【面试】SubmitOrder 只返回 "是否入队"，调用方拿不到订单 ID、是否被拒、有没有立即成交，
+// 只能注册 handler 再按订单 ID 对事件。网关回 REST 应答时需要的正是这些信息。
+//
+// 【做法】每笔同步订单自带一个容量为 1 的回执通道：
+//   1. SubmitOrderSync 创建通道挂到 order.ack 上，正常入队
+//   2. matchLoop 撮合完、result 交给事件之前拷贝出 OrderAck，
+//      快照更新完再非阻塞写入通道（拿到回执时深度快照、排队位置已包含这笔订单）
+//   3. 调用方在通道上等待，ctx 超时即返回（订单照常撮合，结果通过事件流获得）
+//
+// 【注意】
+//   - 事件由 handler 异步处理：拿到回执时 handler 不一定已经看到对应事件
+//   - 容量为 1 + 非阻塞写入，调用方放弃等待不会卡住撮合线程
+//   - 回执里的成交是拷贝，和事件中的池化 Trade 没有关系，可以随意保存
+
+var (
+	// ErrQueueFull 订单队列已满
+	ErrQueueFull = errors.New("order queue full")
+	// ErrEngineStopped 引擎已停止，订单可能未被处理
+	ErrEngineStopped = errors.New("engine stopped")
+)
+
+// OrderAck 同步下单回执（matchLoop 处理完订单那一刻的状态）
+type OrderAck struct {
+	OrderID       int64
+	ClientOrderID string
+	Status        OrderStatus
+	FilledQty     int64        // 本次立即成交量
+	RemainingQty  int64        // 剩余未成交量（已挂单或 IOC 剩余被撤）
+	RejectReason  RejectReason // 拒单原因（仅 OrderStatusRejected 时有意义）
+	Trades        []Trade      // 立即成交明细（拷贝）
+	Seq           uint64       // 处理后的订单簿序列号
+}
+
+// Accepted 订单是否被接受（未被拒单）
+func (a *OrderAck) Accepted() bool {
+	return a.Status != OrderStatusRejected
+}
+
+// SubmitOrderSync 提交订单并等待撮合结果
+//
+// 返回 error 时：ErrQueueFull / ErrEngineFrozen 表示未入队；ctx 错误或 ErrEngineStopped 表示已入队但没等到回执，
+// 订单状态需通过事件或查询确认
+// 池化订单（AcquireOrder）同样适用：回执是值拷贝，返回后不得再读写 order
+func (e *Engine) SubmitOrderSync(ctx context.Context, order *Order) (OrderAck, error) {
+	ack := make(chan OrderAck, 1)
+	order.ack = ack
+	if !e.SubmitOrder(order) {
+		order.ack = nil
+		if e.frozen.Load() {
+			return OrderAck{}, ErrEngineFrozen
+		}
+		return OrderAck{}, ErrQueueFull
+	}
+
+	select {
+	case a := <-ack:
+		return a, nil
+	case <-ctx.Done():
+		return OrderAck{}, ctx.Err()
+	case <-e.stopCh:
+		// 停止前可能刚好处理完，优先返回已有回执
+		select {
+		case a := <-ack:
+			return a, nil
+		default:
+			return OrderAck{}, ErrEngineStopped
+		}
+	}
+}
+
+// buildAck 拷贝撮合结果（仅 matchLoop 调用）
+func (e *Engine) buildAck(order *Order, result *MatchResult) OrderAck {
+	a := OrderAck{
+		OrderID:       order.ID,
+		ClientOrderID: order.ClientOrderID,
+		Status:        order.Status,
+		FilledQty:     result.FilledQty,
+		RemainingQty:  result.RemainingQty,
+		RejectReason:  result.RejectReason,
+		Seq:           e.orderBook.Seq(),
+	}
+	if len(result.Trades) > 0 {
+		a.Trades = make([]Trade, len(result.Trades))
+		copy(a.Trades, result.Trades)
+		epoch := e.epoch.Load()
+		for i := range a.Trades {
+			a.Trades[i].Epoch = epoch
+		}
+	}
+
+	// 挂单会留在订单簿里，断开引用让通道尽早回收
+	order.ack = nil
+	return a
+}
+
+// sendAck 非阻塞写入回执（调用方已放弃等待也不会卡住撮合线程）
+func sendAck(ch chan OrderAck, a OrderAck) {
+	select {
+	case ch <- a:
+	default:
+	}
+}
@@ -0,0 +1,331 @@
+package mtrade
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// =============================================================================
+// 跳价数组索引 (Tick Array Index) - 实现 PriceIndex 接口
+// =============================================================================
+//
+// 【面试进阶】价格范围有界时，跳表不是最优解
+//
+// 合约规格给出 TickSize 与价格带 [MinPrice, MaxPrice]，
+// 价格一定落在 (MaxPrice-MinPrice)/TickSize+1 个离散点上：
+//   slot = (price - MinPrice) / TickSize
+//
+// 结构：
+//   slots   []*tickNode  数组直接按 slot 下标寻址，Find/Insert O(1)
+//   words   []uint64     占用位图，1 bit 对应 1 个 slot
+//   summary []uint64     二级位图，1 bit 对应 1 个非空 word（64 个 slot）
+//   best    int          最优价 slot，First() O(1)
+//
+// 删除最优价时需要找 "下一个非空 slot"：
+//   先在当前 word 内用 TrailingZeros/LeadingZeros 找，
+//   再通过 summary 跳过整段空 word —— 100 万个 slot 只需扫描 ~250 个 summary word
+//
+// 对比跳表：
+//   | 操作       | SkipList  | TickIndex            |
+//   |------------|-----------|----------------------|
+//   | Find       | O(log n)  | O(1)                 |
+//   | Insert     | O(log n)  | O(1)                 |
+//   | First      | O(1)      | O(1)                 |
+//   | Delete     | O(log n)  | O(1)，删最优价时 O(range/4096) |
+//   | 内存       | 与档位数成正比 | 与价格带宽度成正比 |
+
+const (
+	// MaxTickSlots 单侧最多 slot 数
+	// 【注意】每个 slot 一个指针（8 字节），上限 100 万 ≈ 8MB/侧
+	MaxTickSlots = 1 << 20
+)
+
+var (
+	ErrInvalidTickConfig = errors.New("invalid tick config")
+	ErrTickRangeTooWide  = errors.New("tick range too wide")
+)
+
+// TickConfig 价格带配置（通常来自合约规格）
+type TickConfig struct {
+	MinPrice int64 // 最低可挂单价格（含）
+	MaxPrice int64 // 最高可挂单价格（含）
+	TickSize int64 // 最小价格变动单位
+}
+
+// Slots 价格带内的 slot 数量
+func (c TickConfig) Slots() int64 {
+	return (c.MaxPrice-c.MinPrice)/c.TickSize + 1
+}
+
+// Validate 校验配置
+func (c TickConfig) Validate() error {
+	if c.TickSize <= 0 || c.MinPrice < 0 || c.MaxPrice < c.MinPrice {
+		return ErrInvalidTickConfig
+	}
+	if c.MinPrice%c.TickSize != 0 || c.MaxPrice%c.TickSize != 0 {
+		return ErrInvalidTickConfig
+	}
+	if c.Slots() > MaxTickSlots {
+		return ErrTickRangeTooWide
+	}
+	return nil
+}
+
+// Contains 价格是否在价格带内且对齐 TickSize
+func (c TickConfig) Contains(price int64) bool {
+	return price >= c.MinPrice && price <= c.MaxPrice && price%c.TickSize == 0
+}
+
+// =============================================================================
+// 节点定义 - 实现 PriceLevelNode 接口
+// =============================================================================
+
+// tickNode 跳价数组节点
+// 【优化】档位删除后节点留在 slots 中复用，撤单-重挂不产生分配
+type tickNode struct {
+	price int64
+	level PriceLevel
+}
+
+// GetPrice 实现 PriceLevelNode 接口
+func (n *tickNode) GetPrice() int64 {
+	return n.price
+}
+
+// GetLevel 实现 PriceLevelNode 接口
+func (n *tickNode) GetLevel() *PriceLevel {
+	return &n.level
+}
+
+// =============================================================================
+// TickIndex - 实现 PriceIndex 接口
+// =============================================================================
+
+// TickIndex 跳价数组价格索引
+type TickIndex struct {
+	cfg       TickConfig
+	ascending bool // true: 卖盘（最低价最优），false: 买盘（最高价最优）
+
+	slots   []*tickNode
+	words   []uint64 // 一级位图
+	summary []uint64 // 二级位图
+
+	best   int // 最优价 slot，-1 表示空
+	length int // 非空档位数
+}
+
+// 编译时检查：确保 TickIndex 实现了 PriceIndex 接口
+var _ PriceIndex = (*TickIndex)(nil)
+
+// NewTickIndex 创建跳价数组索引
+// ascending: true 升序（卖盘），false 降序（买盘）
+func NewTickIndex(cfg TickConfig, ascending bool) (*TickIndex, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := int(cfg.Slots())
+	words := (n + 63) / 64
+	return &TickIndex{
+		cfg:       cfg,
+		ascending: ascending,
+		slots:     make([]*tickNode, n),
+		words:     make([]uint64, words),
+		summary:   make([]uint64, (words+63)/64),
+		best:      -1,
+	}, nil
+}
+
+// =============================================================================
+// 位图操作
+// =============================================================================
+
+// slotOf 价格 → slot，价格不合法时返回 false
+func (t *TickIndex) slotOf(price int64) (int, bool) {
+	if !t.cfg.Contains(price) {
+		return 0, false
+	}
+	return int((price - t.cfg.MinPrice) / t.cfg.TickSize), true
+}
+
+// occupied slot 是否有档位
+func (t *TickIndex) occupied(i int) bool {
+	return t.words[i>>6]&(1<<(i&63)) != 0
+}
+
+// setBit 标记 slot 非空
+func (t *TickIndex) setBit(i int) {
+	w := i >> 6
+	t.words[w] |= 1 << (i & 63)
+	t.summary[w>>6] |= 1 << (w & 63)
+}
+
+// clearBit 标记 slot 为空
+func (t *TickIndex) clearBit(i int) {
+	w := i >> 6
+	t.words[w] &^= 1 << (i & 63)
+	if t.words[w] == 0 {
+		t.summary[w>>6] &^= 1 << (w & 63)
+	}
+}
+
+// nextSet 查找 >= i 的第一个非空 slot，不存在返回 -1
+func (t *TickIndex) nextSet(i int) int {
+	if i >= len(t.slots) {
+		return -1
+	}
+	w := i >> 6
+	if m := t.words[w] & (^uint64(0) << (i & 63)); m != 0 {
+		return w<<6 | bits.TrailingZeros64(m)
+	}
+
+	// 通过 summary 跳到下一个非空 word
+	w++
+	for s := w >> 6; s < len(t.summary); s++ {
+		m := t.summary[s]
+		if s == w>>6 {
+			m &= ^uint64(0) << (w & 63)
+		}
+		if m != 0 {
+			nw := s<<6 | bits.TrailingZeros64(m)
+			return nw<<6 | bits.TrailingZeros64(t.words[nw])
+		}
+	}
+	return -1
+}
+
+// prevSet 查找 <= i 的最后一个非空 slot，不存在返回 -1
+func (t *TickIndex) prevSet(i int) int {
+	if i < 0 {
+		return -1
+	}
+	w := i >> 6
+	if m := t.words[w] & (^uint64(0) >> (63 - i&63)); m != 0 {
+		return w<<6 | (63 - bits.LeadingZeros64(m))
+	}
+
+	w--
+	for s := w >> 6; s >= 0 && w >= 0; s-- {
+		m := t.summary[s]
+		if s == w>>6 {
+			m &= ^uint64(0) >> (63 - w&63)
+		}
+		if m != 0 {
+			pw := s<<6 | (63 - bits.LeadingZeros64(m))
+			return pw<<6 | (63 - bits.LeadingZeros64(t.words[pw]))
+		}
+	}
+	return -1
+}
+
+// following 按最优 → 次优方向，返回 i 之后的下一个非空 slot
+func (t *TickIndex) following(i int) int {
+	if t.ascending {
+		return t.nextSet(i + 1)
+	}
+	return t.prevSet(i - 1)
+}
+
+// better slot a 是否比 b 更优
+func (t *TickIndex) better(a, b int) bool {
+	if t.ascending {
+		return a < b
+	}
+	return a > b
+}
+
+// =============================================================================
+// PriceIndex 接口实现
+// =============================================================================
+
+// Find 查找指定价格的节点
+func (t *TickIndex) Find(price int64) PriceLevelNode {
+	i, ok := t.slotOf(price)
+	if !ok || !t.occupied(i) {
+		return nil
+	}
+	return t.slots[i]
+}
+
+// Insert 插入价格档位（如果不存在则创建）
+// 【注意】价格超出价格带或未对齐 TickSize 时返回 nil
+func (t *TickIndex) Insert(price int64) PriceLevelNode {
+	i, ok := t.slotOf(price)
+	if !ok {
+		return nil
+	}
+	if t.occupied(i) {
+		return t.slots[i]
+	}
+
+	node := t.slots[i]
+	if node == nil {
+		node = &tickNode{price: price}
+		t.slots[i] = node
+	}
+	node.level.reset(price)
+
+	t.setBit(i)
+	t.length++
+	if t.best < 0 || t.better(i, t.best) {
+		t.best = i
+	}
+	return node
+}
+
+// Delete 删除价格档位
+func (t *TickIndex) Delete(price int64) PriceLevelNode {
+	i, ok := t.slotOf(price)
+	if !ok || !t.occupied(i) {
+		return nil
+	}
+
+	t.clearBit(i)
+	t.length--
+	if i == t.best {
+		t.best = t.following(i)
+	}
+	return t.slots[i]
+}
+
+// First 获取第一个节点（最优价格）
+// 时间复杂度：O(1)
+func (t *TickIndex) First() PriceLevelNode {
+	if t.best < 0 {
+		return nil
+	}
+	return t.slots[t.best]
+}
+
+// Len 返回价格档位数量
+func (t *TickIndex) Len() int {
+	return t.length
+}
+
+// IsEmpty 是否为空
+func (t *TickIndex) IsEmpty() bool {
+	return t.length == 0
+}
+
+// ForEach 从最优价开始遍历所有节点
+func (t *TickIndex) ForEach(fn func(PriceLevelNode) bool) {
+	for i := t.best; i >= 0; i = t.following(i) {
+		if !fn(t.slots[i]) {
+			break
+		}
+	}
+}
+
+// GetTopN 获取前 N 个价格档位
+func (t *TickIndex) GetTopN(n int) []PriceLevelNode {
+	result := make([]PriceLevelNode, 0, n)
+	for i := t.best; i >= 0 && len(result) < n; i = t.following(i) {
+		result = append(result, t.slots[i])
+	}
+	return result
+}
+
+// Config 返回价格带配置
+func (t *TickIndex) Config() TickConfig {
+	return t.cfg
+}
@@ -0,0 +1,299 @@
+package mtrade
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// =============================================================================
+// 跳价数组索引测试
+// =============================================================================
+
+var testTick = TickConfig{MinPrice: 40000, MaxPrice: 60000, TickSize: 1}
+
+func mustNewTickOrderBook(tb testing.TB) *OrderBook {
+	tb.Helper()
+	ob, err := NewTickOrderBook("BTC_USDT", testTick)
+	if err != nil {
+		tb.Fatalf("failed to create tick order book: %v", err)
+	}
+	return ob
+}
+
+func TestTickConfig_Validate(t *testing.T) {
+	cases := []struct {
+		cfg  TickConfig
+		want error
+	}{
+		{TickConfig{MinPrice: 100, MaxPrice: 200, TickSize: 5}, nil},
+		{TickConfig{MinPrice: 100, MaxPrice: 200, TickSize: 0}, ErrInvalidTickConfig},
+		{TickConfig{MinPrice: 200, MaxPrice: 100, TickSize: 1}, ErrInvalidTickConfig},
+		{TickConfig{MinPrice: 101, MaxPrice: 200, TickSize: 5}, ErrInvalidTickConfig},
+		{TickConfig{MinPrice: 0, MaxPrice: MaxTickSlots, TickSize: 1}, ErrTickRangeTooWide},
+	}
+	for _, c := range cases {
+		if err := c.cfg.Validate(); err != c.want {
+			t.Errorf("%+v: expected %v, got %v", c.cfg, c.want, err)
+		}
+	}
+}
+
+// TestTickIndex_MatchesSkipList 随机插入/删除，TickIndex 与跳表的遍历顺序必须一致
+func TestTickIndex_MatchesSkipList(t *testing.T) {
+	cfg := TickConfig{MinPrice: 0, MaxPrice: 20000, TickSize: 1} // 跨多个 summary word
+	for _, ascending := range []bool{true, false} {
+		ti, err := NewTickIndex(cfg, ascending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sl := NewSkipList(ascending)
+		r := rand.New(rand.NewSource(42))
+
+		for step := 0; step < 20000; step++ {
+			price := r.Int63n(cfg.MaxPrice + 1)
+			if r.Intn(3) == 0 {
+				price = r.Int63n(64) * 300 // 稀疏价格，触发跨 word 查找
+			}
+			if r.Intn(2) == 0 {
+				ti.Insert(price)
+				sl.Insert(price)
+			} else if first := sl.First(); first != nil && r.Intn(2) == 0 {
+				// 删除最优价，验证 best 的重新定位
+				ti.Delete(first.GetPrice())
+				sl.Delete(first.GetPrice())
+			} else {
+				ti.Delete(price)
+				sl.Delete(price)
+			}
+
+			if ti.Len() != sl.Len() {
+				t.Fatalf("asc=%v step %d: len %d != %d", ascending, step, ti.Len(), sl.Len())
+			}
+			tf, sf := ti.First(), sl.First()
+			if (tf == nil) != (sf == nil) || (tf != nil && tf.GetPrice() != sf.GetPrice()) {
+				t.Fatalf("asc=%v step %d: first mismatch %v vs %v", ascending, step, tf, sf)
+			}
+		}
+
+		var got, want []int64
+		ti.ForEach(func(n PriceLevelNode) bool { got = append(got, n.GetPrice()); return true })
+		sl.ForEach(func(n PriceLevelNode) bool { want = append(want, n.GetPrice()); return true })
+		if len(got) != len(want) {
+			t.Fatalf("asc=%v: walked %d levels, want %d", ascending, len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("asc=%v: level %d = %d, want %d", ascending, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestTickIndex_RejectsOutOfRange(t *testing.T) {
+	ti, _ := NewTickIndex(TickConfig{MinPrice: 100, MaxPrice: 200, TickSize: 5}, true)
+
+	for _, price := range []int64{95, 205, 102} {
+		if ti.Insert(price) != nil {
+			t.Errorf("price %d should be rejected", price)
+		}
+	}
+	if ti.Insert(200) == nil || ti.First().GetPrice() != 200 {
+		t.Errorf("price 200 should be accepted")
+	}
+}
+
+// =============================================================================
+// 侵入式链表档位 + 订单簿聚合测试
+// =============================================================================
+
+func TestPriceLevel_RemoveMiddle(t *testing.T) {
+	pl := NewPriceLevel(100)
+	orders := []*Order{{ID: 1, Qty: 10}, {ID: 2, Qty: 20}, {ID: 3, Qty: 30}}
+	for _, o := range orders {
+		pl.AddOrder(o)
+	}
+
+	pl.Remove(orders[1])
+	if pl.Len() != 2 || pl.TotalQty != 40 {
+		t.Fatalf("expected 2 orders / qty 40, got %d / %d", pl.Len(), pl.TotalQty)
+	}
+
+	var ids []int64
+	pl.ForEach(func(o *Order) { ids = append(ids, o.ID) })
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("expected FIFO [1 3], got %v", ids)
+	}
+
+	// 重复摘除是空操作
+	pl.Remove(orders[1])
+	if pl.Len() != 2 {
+		t.Errorf("double remove changed level")
+	}
+}
+
+func TestOrderBook_DepthAggregateAfterPartialFill(t *testing.T) {
+	for name, ob := range map[string]*OrderBook{
+		"skiplist": NewOrderBook("BTC_USDT"),
+		"tick":     mustNewTickOrderBook(t),
+	} {
+		matcher := NewMatcher(ob)
+		ob.AddOrder(&Order{ID: 1, Side: SideSell, Price: 50000, Qty: 10})
+		ob.AddOrder(&Order{ID: 2, Side: SideSell, Price: 50000, Qty: 10})
+		ob.AddOrder(&Order{ID: 3, Side: SideSell, Price: 50001, Qty: 5})
+		ob.AddOrder(&Order{ID: 4, Side: SideBuy, Price: 49999, Qty: 7})
+
+		// 吃掉 1 号全部 + 2 号 3 个
+		PutMatchResult(matcher.ProcessOrder(&Order{ID: 5, Side: SideBuy, Price: 50000, Qty: 13, Type: OrderTypeIOC}))
+		ob.CancelOrder(3)
+		ob.UpdateSnapshot()
+
+		bids, asks := ob.Depth(5)
+		if len(asks) != 1 || asks[0] != (DepthLevel{Price: 50000, Quantity: 7, Orders: 1}) {
+			t.Errorf("%s: unexpected asks %+v", name, asks)
+		}
+		if len(bids) != 1 || bids[0] != (DepthLevel{Price: 49999, Quantity: 7, Orders: 1}) {
+			t.Errorf("%s: unexpected bids %+v", name, bids)
+		}
+		if st := ob.GetStats(); st.TotalOrders != 2 {
+			t.Errorf("%s: expected 2 resting orders, got %d", name, st.TotalOrders)
+		}
+	}
+}
+
+func TestMatcher_TickBookRejectsOffTickPrice(t *testing.T) {
+	ob, _ := NewTickOrderBook("BTC_USDT", TickConfig{MinPrice: 40000, MaxPrice: 60000, TickSize: 10})
+	matcher := NewMatcher(ob)
+	ob.AddOrder(&Order{ID: 1, Side: SideSell, Price: 50000, Qty: 10})
+
+	// 未对齐 TickSize：撮合前拒绝，不能先成交再挂不上
+	order := &Order{ID: 2, Side: SideBuy, Price: 50005, Qty: 20, Type: OrderTypeLimit}
+	result := matcher.ProcessOrder(order)
+	if order.Status != OrderStatusRejected || len(result.Trades) != 0 {
+		t.Errorf("expected rejection without trades, got %s with %d trades", order.Status, len(result.Trades))
+	}
+
+	// 市价单不受价格带限制
+	market := &Order{ID: 3, Side: SideBuy, Qty: 5, Type: OrderTypeMarket}
+	if result := matcher.ProcessOrder(market); len(result.Trades) != 1 {
+		t.Errorf("expected market order to trade, got %d trades", len(result.Trades))
+	}
+}
+
+func TestEngine_TickArrayBook(t *testing.T) {
+	config := DefaultEngineConfig("BTC_USDT")
+	config.BookIndex = BookIndexTickArray
+	if _, err := NewEngine(config); err == nil {
+		t.Fatalf("expected error for missing tick config")
+	}
+
+	config.Tick = testTick
+	engine := mustNewEngine(t, config)
+	if engine.GetOrderBook().ValidPrice(testTick.MaxPrice + 1) {
+		t.Errorf("expected engine to use the tick order book")
+	}
+}
+
+// =============================================================================
+// 基准测试：跳表 vs 跳价数组索引，环形队列 vs 侵入式链表档位
+// =============================================================================
+//
+//   go test ./pkg/mtrade -run=^$ -bench='CancelReplace|LevelRemove|BestPrice'
+
+// BenchmarkOrderBook_CancelReplace 深度订单簿上的撤单-重挂（做市商刷新报价）
+func BenchmarkOrderBook_CancelReplace(b *testing.B) {
+	const levels, perLevel = 2000, 50 // 10 万笔挂单
+
+	books := map[string]func() *OrderBook{
+		"skiplist": func() *OrderBook { return NewOrderBook("BTC_USDT") },
+		"tick":     func() *OrderBook { return mustNewTickOrderBook(b) },
+	}
+	for _, name := range []string{"skiplist", "tick"} {
+		b.Run(name, func(b *testing.B) {
+			ob := books[name]()
+			r := rand.New(rand.NewSource(1))
+
+			live := make([]*Order, 0, levels*perLevel)
+			for l := 0; l < levels; l++ {
+				for k := 0; k < perLevel; k++ {
+					o := &Order{ID: int64(len(live) + 1), Side: SideBuy, Price: int64(50000 - l), Qty: 10}
+					ob.AddOrder(o)
+					live = append(live, o)
+				}
+			}
+			nextID := int64(len(live) + 1)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// 随机撤掉一笔（多数在档位中间），在新价格重挂
+				idx := r.Intn(len(live))
+				old := live[idx]
+				ob.CancelOrder(old.ID)
+
+				o := &Order{ID: nextID, Side: SideBuy, Price: int64(50000 - r.Intn(levels)), Qty: 10}
+				nextID++
+				ob.AddOrder(o)
+				live[idx] = o
+			}
+		})
+	}
+}
+
+// BenchmarkOrderBook_BestPriceChurn 反复清空最优档再重建（盘口跳动）
+func BenchmarkOrderBook_BestPriceChurn(b *testing.B) {
+	books := map[string]func() *OrderBook{
+		"skiplist": func() *OrderBook { return NewOrderBook("BTC_USDT") },
+		"tick":     func() *OrderBook { return mustNewTickOrderBook(b) },
+	}
+	for _, name := range []string{"skiplist", "tick"} {
+		b.Run(name, func(b *testing.B) {
+			ob := books[name]()
+			for l := 1; l <= 1000; l++ {
+				ob.AddOrder(&Order{ID: int64(l), Side: SideSell, Price: int64(50000 + l), Qty: 10})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := int64(1_000_000 + i)
+				ob.AddOrder(&Order{ID: id, Side: SideSell, Price: 50000, Qty: 10})
+				if ob.asks.First().GetPrice() != 50000 {
+					b.Fatal("best ask mismatch")
+				}
+				ob.CancelOrder(id)
+			}
+		})
+	}
+}
+
+// BenchmarkLevelRemove_Middle 单个档位内撤掉中间的订单：O(n) 环形队列 vs O(1) 链表
+func BenchmarkLevelRemove_Middle(b *testing.B) {
+	const depth = 500
+
+	b.Run("ring", func(b *testing.B) {
+		pl := NewRingPriceLevel(50000)
+		for i := 0; i < depth; i++ {
+			pl.AddOrder(&Order{ID: int64(i), Qty: 10})
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			o := pl.RemoveOrder(int64((i + depth/2) % depth))
+			pl.AddOrder(o) // 放回队尾，保持深度
+		}
+	})
+
+	b.Run("list", func(b *testing.B) {
+		pl := NewPriceLevel(50000)
+		orders := make([]*Order, depth)
+		for i := range orders {
+			orders[i] = &Order{ID: int64(i), Qty: 10}
+			pl.AddOrder(orders[i])
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			o := orders[(i+depth/2)%depth]
+			pl.Remove(o)
+			pl.AddOrder(o)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package mtrade
+
+import (
+	"sync"
+
+	"max.com/pkg/fixed"
+)
+
+// =============================================================================
+// 用户挂单敞口 (User Open Exposure)
+// =============================================================================
+//
+// 【问题】风控下单前要知道 "用户 X 在这个交易对上还挂着多少单"：
+// 扫订单簿是 O(挂单数)，而且订单簿只能由 matchLoop 访问
+//
+// 【做法】订单簿按用户维护买卖两侧的剩余挂单量、名义价值和笔数，
+// 挂单 (AddOrder)、成交 (matchAtLevel)、离开订单簿 (forget：撤单 / 完全成交) 时增量更新，
+// 查询 O(1)，可在任意 goroutine 调用
+//
+//	名义价值 = Σ 价格 × 剩余量 / 1e8 (计价币)
+//
+// 【注意】
+//   - 每个订单的名义价值按 "当前剩余量" 整体截断，成交时用前后差值更新，
+//     聚合值始终等于逐笔重算的结果，不会因多次部分成交累积舍入误差
+//   - 写只发生在 matchLoop，读写锁基本无竞争；所有挂单撤完、成交完的用户从表里删除
+
+// UserExposure 用户在一个交易对上的挂单敞口
+type UserExposure struct {
+	UserID       int64
+	Symbol       string
+	BuyQty       int64 // 买单剩余挂单量
+	BuyNotional  int64 // 买单名义价值
+	BuyOrders    int
+	SellQty      int64 // 卖单剩余挂单量
+	SellNotional int64 // 卖单名义价值
+	SellOrders   int
+}
+
+// UserExposureReader 可查询用户挂单敞口的引擎 (进程内 *Engine 实现)
+type UserExposureReader interface {
+	GetUserOpenExposure(userID int64) (UserExposure, bool)
+}
+
+var _ UserExposureReader = (*Engine)(nil)
+
+// exposureBook 订单簿内按用户的挂单聚合
+// 【无锁】写只由 matchLoop 调用，读可在任意 goroutine
+type exposureBook struct {
+	mu    sync.RWMutex
+	users map[int64]*UserExposure
+}
+
+// orderNotional 订单按 remaining 计的名义价值
+func orderNotional(price, remaining int64) int64 {
+	return fixed.MulDiv(price, remaining, PriceMultiplier)
+}
+
+// apply 订单剩余量从 before 变为 after，挂单笔数变化 orders
+func (x *exposureBook) apply(symbol string, order *Order, before, after int64, orders int) {
+	dq := after - before
+	dn := orderNotional(order.Price, after) - orderNotional(order.Price, before)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.users == nil {
+		x.users = make(map[int64]*UserExposure)
+	}
+	e := x.users[order.UserID]
+	if e == nil {
+		e = &UserExposure{UserID: order.UserID, Symbol: symbol}
+		x.users[order.UserID] = e
+	}
+	if order.Side == SideBuy {
+		e.BuyQty += dq
+		e.BuyNotional += dn
+		e.BuyOrders += orders
+	} else {
+		e.SellQty += dq
+		e.SellNotional += dn
+		e.SellOrders += orders
+	}
+	if e.BuyOrders == 0 && e.SellOrders == 0 {
+		delete(x.users, order.UserID)
+	}
+}
+
+// trackExposureAdd / trackExposureFill / trackExposureRemove 订单进入、部分成交、离开订单簿
+// 【无锁】仅由 matchLoop 调用
+func (ob *OrderBook) trackExposureAdd(order *Order) {
+	ob.exposure.apply(ob.Symbol, order, 0, order.RemainingQty(), 1)
+}
+
+// trackExposureFill 在 FilledQty 增加 qty 之后调用
+func (ob *OrderBook) trackExposureFill(order *Order, qty int64) {
+	remaining := order.RemainingQty()
+	ob.exposure.apply(ob.Symbol, order, remaining+qty, remaining, 0)
+}
+
+func (ob *OrderBook) trackExposureRemove(order *Order) {
+	ob.exposure.apply(ob.Symbol, order, order.RemainingQty(), 0, -1)
+}
+
+// GetUserOpenExposure 用户在本交易对的挂单敞口，没有挂单返回 false（可在任意 goroutine 调用）
+func (ob *OrderBook) GetUserOpenExposure(userID int64) (UserExposure, bool) {
+	ob.exposure.mu.RLock()
+	defer ob.exposure.mu.RUnlock()
+	e, ok := ob.exposure.users[userID]
+	if !ok {
+		return UserExposure{}, false
+	}
+	return *e, true
+}
+
+// GetUserOpenExposure 用户在本引擎交易对上的挂单敞口（线程安全）
+func (e *Engine) GetUserOpenExposure(userID int64) (UserExposure, bool) {
+	return e.orderBook.GetUserOpenExposure(userID)
+}
+
+// RouteUserOpenExposure 用户在各交易对上的挂单敞口，跳过不支持查询的引擎（远程客户端）
+func RouteUserOpenExposure(r EngineRouter, userID int64) []UserExposure {
+	var out []UserExposure
+	for _, c := range r.Engines() {
+		reader, ok := c.(UserExposureReader)
+		if !ok {
+			continue
+		}
+		if e, ok := reader.GetUserOpenExposure(userID); ok {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package mtrade
+
+import "testing"
+
+func TestUserOpenExposure(t *testing.T) {
+	ob := NewOrderBook("BTC_USDT")
+	m := NewMatcher(ob)
+	price := ToFixedPrice(50000)
+
+	m.ProcessOrder(limitOrder(1, 1, SideBuy, price, 3))
+	m.ProcessOrder(limitOrder(2, 1, SideBuy, price-PriceMultiplier, 2))
+	m.ProcessOrder(limitOrder(3, 1, SideSell, price+PriceMultiplier, 1))
+	m.ProcessOrder(limitOrder(4, 2, SideBuy, price, 5))
+
+	e, ok := ob.GetUserOpenExposure(1)
+	if !ok || e.BuyQty != 5 || e.BuyOrders != 2 || e.SellQty != 1 || e.SellOrders != 1 {
+		t.Fatalf("after add: %+v", e)
+	}
+	if want := orderNotional(price, 3) + orderNotional(price-PriceMultiplier, 2); e.BuyNotional != want {
+		t.Fatalf("buy notional %d, want %d", e.BuyNotional, want)
+	}
+
+	// 部分成交：订单 1 被吃掉 2，名义价值按剩余量重算
+	m.ProcessOrder(&Order{ID: 5, UserID: 3, Symbol: "BTC_USDT", Side: SideSell, Type: OrderTypeMarket, Qty: 2})
+	e, _ = ob.GetUserOpenExposure(1)
+	if e.BuyQty != 3 || e.BuyOrders != 2 || e.BuyNotional != orderNotional(price, 1)+orderNotional(price-PriceMultiplier, 2) {
+		t.Fatalf("after partial fill: %+v", e)
+	}
+
+	// 完全成交 + 撤单
+	m.ProcessOrder(&Order{ID: 6, UserID: 3, Symbol: "BTC_USDT", Side: SideSell, Type: OrderTypeMarket, Qty: 1})
+	ob.CancelOrder(2)
+	e, _ = ob.GetUserOpenExposure(1)
+	if e.BuyQty != 0 || e.BuyOrders != 0 || e.BuyNotional != 0 || e.SellOrders != 1 {
+		t.Fatalf("after fill and cancel: %+v", e)
+	}
+
+	// 吃单方 (taker) 不挂单不计入；所有挂单离开后用户从表里删除
+	if _, ok := ob.GetUserOpenExposure(3); ok {
+		t.Fatal("taker-only user should have no exposure")
+	}
+	ob.CancelOrder(3)
+	if _, ok := ob.GetUserOpenExposure(1); ok {
+		t.Fatal("user 1 should have no exposure left")
+	}
+	if e, ok := ob.GetUserOpenExposure(2); !ok || e.BuyQty != 5 || e.Symbol != "BTC_USDT" {
+		t.Fatalf("user 2: %+v", e)
+	}
+}
@@ -8,9 +8,14 @@ import (
 	"hash"
 	"hash/crc32"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"max.com/pkg/walstore"
 )
 
 // =============================================================================
@@ -26,6 +31,10 @@ import (
 //
 // 恢复流程：
 //   启动 → 加载 Checkpoint → 重放 WAL → 继续服务
+//
+// 存储：段和检查点经 walstore.Store 读写 (默认本地目录)；配置归档后，
+// 截断时旧段改名进归档、检查点复制一份进归档，由 walstore.Archiver 上传到对象存储，
+// 新机器恢复前用 walstore.Restore 把缺失的归档拉回来 (见 restorePick)
 
 // =============================================================================
 // WAL Entry 定义
@@ -38,6 +47,7 @@ const (
 	EntryPlaceOrder  EntryType = 1 // 下单
 	EntryCancelOrder EntryType = 2 // 取消订单
 	EntryCheckpoint  EntryType = 3 // 检查点
+	EntryEpoch       EntryType = 4 // 纪元变更（主备切换），之后的条目都属于该纪元
 )
 
 // WALEntry WAL 条目
@@ -57,11 +67,11 @@ type WALEntry struct {
 // WAL Write-Ahead Log
 // 【无锁设计】只由 matchLoop 单线程调用，无需加锁
 type WAL struct {
-	file     *os.File
+	store    walstore.Store   // 活动段 wal.log 和检查点
+	archive  walstore.Store   // 封存的旧段和检查点副本，nil 表示截断时直接丢弃
+	seg      walstore.Segment // 当前活动段
 	writer   *bufio.Writer
 	sequence int64
-	dir      string
-	filename string
 
 	// 【优化】可复用 buffer，避免每次分配
 	buf []byte
@@ -71,6 +81,12 @@ type WAL struct {
 
 	// 配置
 	syncMode SyncMode
+
+	// 监控用的原子副本（matchLoop 写，诊断接口读，见 Stats）
+	written       atomic.Int64 // 最后写入的序列号
+	synced        atomic.Int64 // 最后刷盘的序列号
+	lastSync      atomic.Int64 // 最后一次刷盘时间（纳秒）
+	firstUnsynced atomic.Int64 // 最早一条未刷盘条目的写入时间（纳秒），0 表示全部已刷盘
 }
 
 // SyncMode 同步模式
@@ -86,8 +102,19 @@ const (
 type WALConfig struct {
 	Dir      string   // WAL 文件目录
 	SyncMode SyncMode // 同步模式
+
+	// ArchiveDir 截断时把旧 WAL 段移到这里 (wal_<最后序列号>.log) 而不是丢弃，检查点也复制一份，
+	// 供离线重建历史订单簿 (见 replay.go) 和上传对象存储；为空则直接丢弃
+	ArchiveDir string
+
+	// Store / Archive 自定义存储 (见 pkg/walstore)，设置后分别忽略 Dir / ArchiveDir
+	Store   walstore.Store
+	Archive walstore.Store
 }
 
+// walFileName 活动段文件名
+const walFileName = "wal.log"
+
 // DefaultWALConfig 默认配置
 func DefaultWALConfig(dir string) WALConfig {
 	return WALConfig{
@@ -98,23 +125,22 @@ func DefaultWALConfig(dir string) WALConfig {
 
 // NewWAL 创建 WAL
 func NewWAL(config WALConfig) (*WAL, error) {
-	// 创建目录
-	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+	store, archive, err := config.stores()
+	if err != nil {
 		return nil, err
 	}
 
 	// 打开 WAL 文件（追加模式）
-	filename := filepath.Join(config.Dir, "wal.log")
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	seg, err := store.Append(walFileName)
 	if err != nil {
 		return nil, err
 	}
 
 	wal := &WAL{
-		file:      file,
-		writer:    bufio.NewWriter(file),
-		dir:       config.Dir,
-		filename:  filename,
+		store:     store,
+		archive:   archive,
+		seg:       seg,
+		writer:    bufio.NewWriter(seg),
 		buf:       make([]byte, 256), // 初始化可复用 buffer
 		crc32Hash: crc32.NewIEEE(),   // 初始化 CRC32 对象
 		syncMode:  config.SyncMode,
@@ -122,10 +148,33 @@ func NewWAL(config WALConfig) (*WAL, error) {
 
 	// 读取最后的序列号
 	wal.sequence, _ = wal.getLastSequence()
+	wal.written.Store(wal.sequence)
+	wal.synced.Store(wal.sequence)
 
 	return wal, nil
 }
 
+// stores 活动段 / 归档存储，未自定义时用本地目录 (目录不存在时创建)
+func (c WALConfig) stores() (store, archive walstore.Store, err error) {
+	store, archive = c.Store, c.Archive
+	if store == nil {
+		if store, err = walstore.NewLocal(c.Dir); err != nil {
+			return nil, nil, err
+		}
+	}
+	if archive == nil && c.ArchiveDir != "" {
+		if archive, err = walstore.NewLocal(c.ArchiveDir); err != nil {
+			return nil, nil, err
+		}
+	}
+	return store, archive, nil
+}
+
+// Archive 归档存储，未配置归档时为 nil
+func (w *WAL) Archive() walstore.Store {
+	return w.archive
+}
+
 // =============================================================================
 // 写入操作
 // =============================================================================
@@ -133,41 +182,13 @@ func NewWAL(config WALConfig) (*WAL, error) {
 // WriteOrder 写入下单日志
 // 【优化】使用二进制序列化 + 可复用 buffer
 func (w *WAL) WriteOrder(order *Order) (int64, error) {
-	// 二进制格式：ID(8) + UserID(8) + Price(8) + Qty(8) + FilledQty(8) + CreatedAt(8)
-	//            + Side(1) + Type(1) + Status(1) + SymbolLen(2) + Symbol(n)
-	symbolBytes := []byte(order.Symbol)
-	dataLen := 8*6 + 3 + 2 + len(symbolBytes)
-
-	// 使用可复用 buffer，按需扩容
-	if cap(w.buf) < dataLen {
-		w.buf = make([]byte, dataLen*2) // 2x 扩容
+	// 格式见 appendOrder，使用可复用 buffer
+	// 按金额下单的订单末尾再带 QuoteQty(8)（只出现在下单条目，检查点里都是挂单，不会有）
+	w.buf = appendOrder(w.buf[:0], order)
+	if order.QuoteQty > 0 {
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(order.QuoteQty))
 	}
-	data := w.buf[:dataLen]
-
-	offset := 0
-	binary.LittleEndian.PutUint64(data[offset:], uint64(order.ID))
-	offset += 8
-	binary.LittleEndian.PutUint64(data[offset:], uint64(order.UserID))
-	offset += 8
-	binary.LittleEndian.PutUint64(data[offset:], uint64(order.Price))
-	offset += 8
-	binary.LittleEndian.PutUint64(data[offset:], uint64(order.Qty))
-	offset += 8
-	binary.LittleEndian.PutUint64(data[offset:], uint64(order.FilledQty))
-	offset += 8
-	binary.LittleEndian.PutUint64(data[offset:], uint64(order.CreatedAt))
-	offset += 8
-	data[offset] = byte(order.Side)
-	offset++
-	data[offset] = byte(order.Type)
-	offset++
-	data[offset] = byte(order.Status)
-	offset++
-	binary.LittleEndian.PutUint16(data[offset:], uint16(len(symbolBytes)))
-	offset += 2
-	copy(data[offset:], symbolBytes)
-
-	return w.write(EntryPlaceOrder, data)
+	return w.write(EntryPlaceOrder, w.buf)
 }
 
 // WriteCancelOrder 写入取消订单日志
@@ -178,6 +199,14 @@ func (w *WAL) WriteCancelOrder(orderID int64) (int64, error) {
 	return w.write(EntryCancelOrder, data)
 }
 
+// WriteEpoch 写入纪元变更
+func (w *WAL) WriteEpoch(epoch uint64) (int64, error) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, epoch)
+
+	return w.write(EntryEpoch, data)
+}
+
 // WriteCheckpoint 写入检查点
 func (w *WAL) WriteCheckpoint(data []byte) (int64, error) {
 	return w.write(EntryCheckpoint, data)
@@ -201,6 +230,8 @@ func (w *WAL) write(entryType EntryType, data []byte) (int64, error) {
 	if err := w.writeEntry(&entry); err != nil {
 		return 0, err
 	}
+	w.written.Store(entry.Sequence)
+	w.firstUnsynced.CompareAndSwap(0, entry.Timestamp)
 
 	// 根据同步模式决定是否刷盘
 	if w.syncMode == SyncModeAlways {
@@ -256,7 +287,38 @@ func (w *WAL) sync() error {
 	if err := w.writer.Flush(); err != nil {
 		return err
 	}
-	return w.file.Sync()
+	if err := w.seg.Sync(); err != nil {
+		return err
+	}
+	w.synced.Store(w.sequence)
+	w.lastSync.Store(time.Now().UnixNano())
+	w.firstUnsynced.Store(0)
+	return nil
+}
+
+// WALStats WAL 写入 / 刷盘进度
+type WALStats struct {
+	Sequence       int64         // 最后写入的序列号
+	SyncedSequence int64         // 最后刷盘的序列号
+	Unsynced       int64         // 已写入未刷盘的条目数（宕机会丢）
+	LastSync       time.Time     // 最后一次刷盘时间，零值表示启动后还没刷过
+	SyncLag        time.Duration // 最早一条未刷盘条目已等待的时间，全部已刷盘为 0
+}
+
+// Stats WAL 写入 / 刷盘进度，可在任意 goroutine 调用
+func (w *WAL) Stats() WALStats {
+	st := WALStats{
+		Sequence:       w.written.Load(),
+		SyncedSequence: w.synced.Load(),
+	}
+	st.Unsynced = st.Sequence - st.SyncedSequence
+	if ts := w.lastSync.Load(); ts != 0 {
+		st.LastSync = time.Unix(0, ts)
+	}
+	if ts := w.firstUnsynced.Load(); ts != 0 {
+		st.SyncLag = time.Since(time.Unix(0, ts))
+	}
+	return st
 }
 
 // Close 关闭 WAL
@@ -264,7 +326,7 @@ func (w *WAL) Close() error {
 	if err := w.writer.Flush(); err != nil {
 		return err
 	}
-	return w.file.Close()
+	return w.seg.Close()
 }
 
 // =============================================================================
@@ -273,8 +335,12 @@ func (w *WAL) Close() error {
 
 // ReadAll 读取所有 WAL 条目
 func (w *WAL) ReadAll() ([]WALEntry, error) {
-	// 重新打开文件读取
-	file, err := os.Open(w.filename)
+	return readWALSegment(w.store, walFileName, w.crc32Hash)
+}
+
+// ReadWALFile 读取一个 WAL 段文件 (当前的 wal.log 或归档段)，文件不存在返回空
+func ReadWALFile(filename string) ([]WALEntry, error) {
+	file, err := os.Open(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -282,12 +348,28 @@ func (w *WAL) ReadAll() ([]WALEntry, error) {
 		return nil, err
 	}
 	defer file.Close()
+	return readWALEntries(file, crc32.NewIEEE())
+}
 
-	reader := bufio.NewReader(file)
+// readWALSegment 从 store 读取一个段，不存在返回空
+func readWALSegment(store walstore.Store, name string, h hash.Hash32) ([]WALEntry, error) {
+	r, err := store.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return readWALEntries(r, h)
+}
+
+func readWALEntries(r io.Reader, h hash.Hash32) ([]WALEntry, error) {
+	reader := bufio.NewReader(r)
 	var entries []WALEntry
 
 	for {
-		entry, err := w.readEntry(reader)
+		entry, err := readEntry(reader)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
@@ -296,7 +378,7 @@ func (w *WAL) ReadAll() ([]WALEntry, error) {
 		}
 
 		// 验证校验和
-		expectedChecksum := w.calculateChecksum(entry)
+		expectedChecksum := entryChecksum(h, entry)
 		if entry.Checksum != expectedChecksum {
 			return entries, errors.New("WAL entry checksum mismatch")
 		}
@@ -308,7 +390,7 @@ func (w *WAL) ReadAll() ([]WALEntry, error) {
 }
 
 // readEntry 读取单条 Entry
-func (w *WAL) readEntry(reader *bufio.Reader) (*WALEntry, error) {
+func readEntry(reader *bufio.Reader) (*WALEntry, error) {
 	entry := &WALEntry{}
 
 	// 读取 Sequence
@@ -367,25 +449,25 @@ func (w *WAL) getLastSequence() (int64, error) {
 // =============================================================================
 
 // calculateChecksum 计算校验和
-// calculateChecksum 计算校验和
-// 【优化】复用 Hash 对象 + 零分配
+// 【优化】复用 Hash 对象
 func (w *WAL) calculateChecksum(entry *WALEntry) uint32 {
-	w.crc32Hash.Reset()
+	return entryChecksum(w.crc32Hash, entry)
+}
 
-	// 使用 buf 作为临时 buffer (复用前 17 字节)
-	// Seq(8) + Time(8) + Type(1)
-	if cap(w.buf) < 17 {
-		w.buf = make([]byte, 256)
-	}
-	tmp := w.buf[:17]
+// entryChecksum CRC32(Seq + Time + Type + Data)
+// 【注意】头部用独立的栈上数组：写入时 entry.Data 就是 w.buf，借用 w.buf 会覆盖订单数据
+func entryChecksum(h hash.Hash32, entry *WALEntry) uint32 {
+	h.Reset()
 
+	// Seq(8) + Time(8) + Type(1)
+	var tmp [17]byte
 	binary.LittleEndian.PutUint64(tmp[0:], uint64(entry.Sequence))
 	binary.LittleEndian.PutUint64(tmp[8:], uint64(entry.Timestamp))
 	tmp[16] = byte(entry.Type)
 
-	w.crc32Hash.Write(tmp)
-	w.crc32Hash.Write(entry.Data)
-	return w.crc32Hash.Sum32()
+	h.Write(tmp[:])
+	h.Write(entry.Data)
+	return h.Sum32()
 }
 
 // GetSequence 获取当前序列号
@@ -399,18 +481,25 @@ func (w *WAL) Truncate() error {
 	if err := w.writer.Flush(); err != nil {
 		return err
 	}
-	if err := w.file.Close(); err != nil {
+	if err := w.seg.Close(); err != nil {
 		return err
 	}
 
+	// 归档旧段：文件名带最后一条的序列号，按文件名排序即按时间排序
+	if w.archive != nil {
+		if err := walstore.Move(w.store, walFileName, w.archive, archivedSegmentName(w.sequence)); err != nil {
+			return err
+		}
+	}
+
 	// 创建新文件
-	file, err := os.Create(w.filename)
+	seg, err := w.store.Create(walFileName)
 	if err != nil {
 		return err
 	}
 
-	w.file = file
-	w.writer = bufio.NewWriter(file)
+	w.seg = seg
+	w.writer = bufio.NewWriter(seg)
 
 	// 重置序列号（可选，取决于是否希望序列号连续）
 	// w.sequence = 0
@@ -418,12 +507,19 @@ func (w *WAL) Truncate() error {
 	return nil
 }
 
+// Checkpoint 检查点内容
+type Checkpoint struct {
+	Seq    int64       // 对应的 WAL 序列号
+	Orders []*Order    // 挂单
+	IDs    IDWatermark // 截至检查点的 ID 高水位（版本 3 起，旧版本为零值）
+}
+
 // CreateCheckpoint 创建检查点
-// 【优化】二进制格式存储：Header + Orders + Checksum
-func (w *WAL) CreateCheckpoint(seq int64, orders []*Order) error {
+// 【优化】二进制格式存储：Header + Orders + IDs
+func (w *WAL) CreateCheckpoint(seq int64, orders []*Order, ids IDWatermark) error {
 	// 1. 创建临时文件
-	tmpFile := filepath.Join(w.dir, fmt.Sprintf("checkpoint_%d.tmp", seq))
-	f, err := os.Create(tmpFile)
+	tmpFile := fmt.Sprintf("checkpoint_%d.tmp", seq)
+	f, err := w.store.Create(tmpFile)
 	if err != nil {
 		return err
 	}
@@ -435,7 +531,7 @@ func (w *WAL) CreateCheckpoint(seq int64, orders []*Order) error {
 	// Magic(4) + Version(1) + Seq(8) + OrderCount(8) = 21 bytes
 	header := make([]byte, 21)
 	binary.LittleEndian.PutUint32(header[0:], 0x43505431) // "CPT1"
-	header[4] = 1                                         // Version 1
+	header[4] = checkpointVersion
 	binary.LittleEndian.PutUint64(header[5:], uint64(seq))
 	binary.LittleEndian.PutUint64(header[13:], uint64(len(orders)))
 
@@ -443,144 +539,205 @@ func (w *WAL) CreateCheckpoint(seq int64, orders []*Order) error {
 		return err
 	}
 
-	// 3. 写入 Orders
-	// 复用 buffer 进行序列化
-	buf := make([]byte, 256)
+	// 3. 写入 Orders（格式见 appendOrder，复用 buffer 进行序列化）
+	buf := make([]byte, 0, 256)
 	for _, order := range orders {
-		// 序列化 Order
-		// ID(8) + UserID(8) + Price(8) + Qty(8) + FilledQty(8) + CreatedAt(8) +
-		// Side(1) + Type(1) + Status(1) + SymLen(2) + Symbol(n)
-		// 固定长度 = 8*6 + 3 + 2 = 53 bytes
-
-		symbolLen := len(order.Symbol)
-		totalLen := 53 + symbolLen
-
-		if cap(buf) < totalLen {
-			buf = make([]byte, totalLen*2)
-		}
-
-		offset := 0
-		binary.LittleEndian.PutUint64(buf[offset:], uint64(order.ID))
-		offset += 8
-		binary.LittleEndian.PutUint64(buf[offset:], uint64(order.UserID))
-		offset += 8
-		binary.LittleEndian.PutUint64(buf[offset:], uint64(order.Price))
-		offset += 8
-		binary.LittleEndian.PutUint64(buf[offset:], uint64(order.Qty))
-		offset += 8
-		binary.LittleEndian.PutUint64(buf[offset:], uint64(order.FilledQty))
-		offset += 8
-		binary.LittleEndian.PutUint64(buf[offset:], uint64(order.CreatedAt))
-		offset += 8
-		buf[offset] = byte(order.Side)
-		offset++
-		buf[offset] = byte(order.Type)
-		offset++
-		buf[offset] = byte(order.Status)
-		offset++
-		binary.LittleEndian.PutUint16(buf[offset:], uint16(symbolLen))
-		offset += 2
-		copy(buf[offset:], order.Symbol)
-
-		if _, err := writer.Write(buf[:totalLen]); err != nil {
+		buf = appendOrder(buf[:0], order)
+		if _, err := writer.Write(buf); err != nil {
 			return err
 		}
 	}
 
-	// 4. 刷盘
+	// 4. 写入 ID 高水位：MaxOrderID(8) + MaxTradeID(8)
+	trailer := binary.LittleEndian.AppendUint64(buf[:0], uint64(ids.OrderID))
+	trailer = binary.LittleEndian.AppendUint64(trailer, uint64(ids.TradeID))
+	if _, err := writer.Write(trailer); err != nil {
+		return err
+	}
+
+	// 5. 刷盘
 	if err := writer.Flush(); err != nil {
 		return err
 	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
 
-	// 5. 重命名为正式文件
-	finalFile := filepath.Join(w.dir, fmt.Sprintf("checkpoint_%d.dat", seq))
-	if err := os.Rename(tmpFile, finalFile); err != nil {
+	// 6. 重命名为正式文件，归档里再放一份 (丢盘后从归档恢复的起点)
+	finalFile := checkpointName(seq)
+	if err := w.store.Rename(tmpFile, finalFile); err != nil {
 		return err
 	}
+	if w.archive != nil {
+		return walstore.Copy(w.store, finalFile, w.archive, finalFile)
+	}
 	return nil
 }
 
-// LoadCheckpoint 加载最新的检查点
-func (w *WAL) LoadCheckpoint() (int64, []*Order, error) {
-	// 1. 查找最新的 checkpoint 文件
-	files, err := filepath.Glob(filepath.Join(w.dir, "checkpoint_*.dat"))
-	if err != nil {
-		return 0, nil, err
+// checkpointName 检查点文件名
+func checkpointName(seq int64) string {
+	return fmt.Sprintf("checkpoint_%d.dat", seq)
+}
+
+// archivedSegmentName 封存段文件名：带段内最后一条的序列号，补零保证按名字排序即按序列号排序
+func archivedSegmentName(lastSeq int64) string {
+	return fmt.Sprintf("wal_%020d.log", lastSeq)
+}
+
+// parseSeq 从 checkpoint_<seq>.dat / wal_<seq>.log 里取序列号
+func parseSeq(name, prefix, suffix string) (int64, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return 0, false
 	}
-	if len(files) == 0 {
-		return 0, nil, nil // 没有检查点
+	var seq int64
+	if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), "%d", &seq); err != nil {
+		return 0, false
 	}
+	return seq, true
+}
 
-	// 找到序列号最大的文件
+// LoadCheckpoint 加载最新的检查点，没有检查点时返回空的 Checkpoint
+//
+// 配置了归档时一起找：本地检查点丢失 (换机器) 时用归档里 (或刚从对象存储拉回) 的副本
+func (w *WAL) LoadCheckpoint() (*Checkpoint, error) {
+	// 1. 查找序列号最大的 checkpoint 文件
 	var maxSeq int64 = -1
-	var latestFile string
-	for _, file := range files {
-		var seq int64
-		_, err := fmt.Sscanf(filepath.Base(file), "checkpoint_%d.dat", &seq)
-		if err == nil && seq > maxSeq {
-			maxSeq = seq
-			latestFile = file
+	var latest walstore.Store
+	for _, store := range []walstore.Store{w.store, w.archive} {
+		if store == nil {
+			continue
+		}
+		names, err := store.List("checkpoint_")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if seq, ok := parseSeq(name, "checkpoint_", ".dat"); ok && seq > maxSeq {
+				maxSeq, latest = seq, store
+			}
 		}
 	}
+	if latest == nil {
+		return &Checkpoint{}, nil // 没有检查点
+	}
 
-	if latestFile == "" {
-		return 0, nil, nil
+	r, err := latest.Open(checkpointName(maxSeq))
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
+	return readCheckpoint(r)
+}
 
+// LoadCheckpointFile 读取一个检查点文件
+func LoadCheckpointFile(filename string) (*Checkpoint, error) {
 	// 2. 打开文件
-	f, err := os.Open(latestFile)
+	f, err := os.Open(filename)
 	if err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 	defer f.Close()
+	return readCheckpoint(f)
+}
 
-	reader := bufio.NewReader(f)
+func readCheckpoint(r io.Reader) (*Checkpoint, error) {
+	reader := bufio.NewReader(r)
 
 	// 3. 读取 Header
 	header := make([]byte, 21)
 	if _, err := io.ReadFull(reader, header); err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 
 	// 验证 Magic
 	if binary.LittleEndian.Uint32(header[0:]) != 0x43505431 {
-		return 0, nil, errors.New("invalid checkpoint magic")
+		return nil, errors.New("invalid checkpoint magic")
 	}
 
-	seq := int64(binary.LittleEndian.Uint64(header[5:]))
+	cp := &Checkpoint{Seq: int64(binary.LittleEndian.Uint64(header[5:]))}
 	count := int64(binary.LittleEndian.Uint64(header[13:]))
 
-	// 4. 读取 Orders
-	orders := make([]*Order, 0, count)
+	// 4. 读取 Orders（版本 1 没有 ClientOrderID）
+	withClientID := header[4] >= 2
+	cp.Orders = make([]*Order, 0, count)
 	for i := int64(0); i < count; i++ {
-		// 读取固定长度部分 (53 bytes)
-		buf := make([]byte, 53)
-		if _, err := io.ReadFull(reader, buf); err != nil {
-			return 0, nil, err
+		order, err := readOrder(reader, withClientID)
+		if err != nil {
+			return nil, err
 		}
+		cp.Orders = append(cp.Orders, order)
+	}
 
-		// 解析 Symbol 长度
-		symbolLen := binary.LittleEndian.Uint16(buf[51:])
-
-		// 读取 Symbol
-		symbolBuf := make([]byte, symbolLen)
-		if _, err := io.ReadFull(reader, symbolBuf); err != nil {
-			return 0, nil, err
+	// 5. 读取 ID 高水位（版本 3 起）
+	if header[4] >= 3 {
+		var trailer [16]byte
+		if _, err := io.ReadFull(reader, trailer[:]); err != nil {
+			return nil, err
 		}
-
-		// 拼接完整数据进行解码
-		fullData := append(buf, symbolBuf...)
-		order := decodeOrder(fullData)
-		orders = append(orders, order)
+		cp.IDs.OrderID = int64(binary.LittleEndian.Uint64(trailer[0:]))
+		cp.IDs.TradeID = int64(binary.LittleEndian.Uint64(trailer[8:]))
 	}
-
-	return seq, orders, nil
+	return cp, nil
 }
 
 // =============================================================================
 // 二进制序列化辅助
 // =============================================================================
 
+// checkpointVersion 检查点格式版本：2 起订单带 ClientOrderID，3 起末尾带 ID 高水位
+const checkpointVersion = 3
+
+// orderFixedLen 订单编码的定长部分
+const orderFixedLen = 8*6 + 3 + 2
+
+// appendOrder 把订单编码追加到 buf（WAL 下单条目、检查点、迁移包共用）
+//
+//	ID(8) + UserID(8) + Price(8) + Qty(8) + FilledQty(8) + CreatedAt(8)
+//	+ Side(1) + Type(1) + Status(1) + SymbolLen(2) + Symbol(n)
+//	+ ClientIDLen(1) + ClientOrderID(m)
+//
+// 末尾的 ClientOrderID 是后加的，旧 WAL 条目没有这一段，decodeOrder 按长度兼容
+func appendOrder(buf []byte, o *Order) []byte {
+	le := binary.LittleEndian
+	buf = le.AppendUint64(buf, uint64(o.ID))
+	buf = le.AppendUint64(buf, uint64(o.UserID))
+	buf = le.AppendUint64(buf, uint64(o.Price))
+	buf = le.AppendUint64(buf, uint64(o.Qty))
+	buf = le.AppendUint64(buf, uint64(o.FilledQty))
+	buf = le.AppendUint64(buf, uint64(o.CreatedAt))
+	buf = append(buf, byte(o.Side), byte(o.Type), byte(o.Status))
+	buf = le.AppendUint16(buf, uint16(len(o.Symbol)))
+	buf = append(buf, o.Symbol...)
+	buf = append(buf, byte(len(o.ClientOrderID))) // 超长的 clientOid 在撮合前已被拒，不会写入
+	return append(buf, o.ClientOrderID...)
+}
+
+// readOrder 从流中读取一笔 appendOrder 编码的订单
+// withClientID 为 false 时按不带 ClientOrderID 的旧格式读取
+func readOrder(r io.Reader, withClientID bool) (*Order, error) {
+	buf := make([]byte, orderFixedLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	symbolLen := int(binary.LittleEndian.Uint16(buf[orderFixedLen-2:]))
+	buf = append(buf, make([]byte, symbolLen)...)
+	if _, err := io.ReadFull(r, buf[orderFixedLen:]); err != nil {
+		return nil, err
+	}
+	if withClientID {
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return nil, err
+		}
+		buf = append(buf, n[0])
+		buf = append(buf, make([]byte, n[0])...)
+		if _, err := io.ReadFull(r, buf[len(buf)-int(n[0]):]); err != nil {
+			return nil, err
+		}
+	}
+	return decodeOrder(buf), nil
+}
+
 // decodeOrder 从二进制数据解码 Order
 func decodeOrder(data []byte) *Order {
 	order := &Order{}
@@ -607,6 +764,20 @@ func decodeOrder(data []byte) *Order {
 	symbolLen := binary.LittleEndian.Uint16(data[offset:])
 	offset += 2
 	order.Symbol = string(data[offset : offset+int(symbolLen)])
+	offset += int(symbolLen)
+
+	// 旧格式没有 ClientOrderID
+	if offset < len(data) {
+		n := int(data[offset])
+		offset++
+		order.ClientOrderID = string(data[offset : offset+n])
+		offset += n
+	}
+
+	// 下单条目：按金额下单的预算（见 WAL.WriteOrder）
+	if offset+8 <= len(data) {
+		order.QuoteQty = int64(binary.LittleEndian.Uint64(data[offset:]))
+	}
 
 	return order
 }
@@ -631,27 +802,41 @@ func NewWALRecovery(wal *WAL) *WALRecovery {
 // 【面试】重放 WAL 条目到订单簿
 func (r *WALRecovery) Recover(engine *Engine) error {
 	// 1. 加载 Checkpoint
-	lastSeq, orders, err := r.wal.LoadCheckpoint()
+	cp, err := r.wal.LoadCheckpoint()
 	if err != nil {
 		return fmt.Errorf("load checkpoint failed: %v", err)
 	}
+	lastSeq := cp.Seq
+	ids := cp.IDs
 
 	// 恢复 Checkpoint 数据
-	if len(orders) > 0 {
-		for _, order := range orders {
+	if len(cp.Orders) > 0 {
+		for _, order := range cp.Orders {
+			// 版本 3 起高水位覆盖检查点里的全部挂单，超出说明检查点不一致
+			if cp.IDs != (IDWatermark{}) && order.ID > cp.IDs.OrderID {
+				return fmt.Errorf("%w: order %d > %d", ErrIDWatermark, order.ID, cp.IDs.OrderID)
+			}
+			ids.OrderID = max(ids.OrderID, order.ID)
 			// 直接恢复到 OrderBook，不经过 Matcher 处理（因为已经是最终状态）
 			// 但为了简单，这里还是通过 AddOrder 恢复，假设 Checkpoint 存的是 Active Orders
 			engine.orderBook.AddOrder(order)
+			engine.clientOrders.claim(order, engine.isResting)
 		}
-		// 更新 WAL 序列号
-		r.wal.sequence = lastSeq
 	}
+	// 更新 WAL 序列号 (检查点之后截断过，活动段可能是空的)
+	r.wal.sequence = max(r.wal.sequence, lastSeq)
+	engine.matcher.advanceIDs(ids)
 
-	// 2. 读取 WAL
-	entries, err := r.wal.ReadAll()
+	// 2. 读取 WAL：检查点之后封存的归档段 (检查点来自更早的归档时才有) + 活动段
+	entries, err := r.wal.readArchivedAfter(lastSeq)
 	if err != nil {
 		return err
 	}
+	active, err := r.wal.ReadAll()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, active...)
 
 	// 3. 重放 WAL (仅重放 Sequence > lastSeq 的条目)
 	for _, entry := range entries {
@@ -662,12 +847,21 @@ func (r *WALRecovery) Recover(engine *Engine) error {
 		switch entry.Type {
 		case EntryPlaceOrder:
 			order := decodeOrder(entry.Data)
+			// 入口会拒绝与挂单同 ID 的订单，WAL 里出现说明日志已损坏
+			if engine.isResting(order.ID) {
+				return fmt.Errorf("%w: order %d at WAL seq %d", ErrDuplicateOrderID, order.ID, entry.Sequence)
+			}
+			// 只有登记成功的订单才写过 WAL，按相同规则重建 clientOid 索引
+			engine.clientOrders.claim(order, engine.isResting)
 			// 直接添加到订单簿（绕过 WAL 避免重复写入）
 			engine.matcher.ProcessOrder(order)
 
 		case EntryCancelOrder:
 			orderID := int64(binary.LittleEndian.Uint64(entry.Data))
 			engine.orderBook.CancelOrder(orderID)
+
+		case EntryEpoch:
+			engine.epoch.Store(binary.LittleEndian.Uint64(entry.Data))
 		}
 
 		// 更新序列号
@@ -676,8 +870,56 @@ func (r *WALRecovery) Recover(engine *Engine) error {
 		}
 	}
 
+	// 4. ID 生成器从恢复出的最大值之上开始（见 id_watermark.go）
+	engine.recoveredIDs = engine.matcher.ids
+	AdvanceOrderID(engine.recoveredIDs.OrderID)
+
 	// 恢复完成后更新快照
 	engine.orderBook.UpdateSnapshot()
 
 	return nil
 }
+
+// readArchivedAfter 读取归档里包含序列号 > seq 的条目的段 (按序列号升序)
+func (w *WAL) readArchivedAfter(seq int64) ([]WALEntry, error) {
+	if w.archive == nil {
+		return nil, nil
+	}
+	names, err := w.archive.List("wal_")
+	if err != nil {
+		return nil, err
+	}
+	var entries []WALEntry
+	for _, name := range names {
+		if last, ok := parseSeq(name, "wal_", ".log"); !ok || last <= seq {
+			continue
+		}
+		segment, err := readWALSegment(w.archive, name, w.crc32Hash)
+		if err != nil {
+			return nil, fmt.Errorf("read archived %s: %w", name, err)
+		}
+		entries = append(entries, segment...)
+	}
+	return entries, nil
+}
+
+// restorePick 从对象存储恢复时要拉回的文件：最新的检查点 + 之后封存的段 (见 walstore.Restore)
+func restorePick(names []string) []string {
+	var cpSeq int64 = -1
+	for _, name := range names {
+		if seq, ok := parseSeq(name, "checkpoint_", ".dat"); ok && seq > cpSeq {
+			cpSeq = seq
+		}
+	}
+	var picked []string
+	if cpSeq >= 0 {
+		picked = append(picked, checkpointName(cpSeq))
+	}
+	for _, name := range names {
+		if seq, ok := parseSeq(name, "wal_", ".log"); ok && seq > cpSeq {
+			picked = append(picked, name)
+		}
+	}
+	sort.Strings(picked)
+	return picked
+}
@@ -1,10 +1,16 @@
 package mtrade
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+
+	"max.com/pkg/walstore"
 )
 
 func TestWAL_WriteAndRead(t *testing.T) {
@@ -152,6 +158,33 @@ func TestWAL_Truncate(t *testing.T) {
 	}
 }
 
+func TestWAL_Stats(t *testing.T) {
+	wal, err := NewWAL(DefaultWALConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to create WAL: %v", err)
+	}
+	defer wal.Close()
+
+	for i := 0; i < 3; i++ {
+		wal.WriteOrder(&Order{ID: int64(i)})
+	}
+	st := wal.Stats()
+	if st.Sequence != 3 || st.SyncedSequence != 0 || st.Unsynced != 3 {
+		t.Errorf("before sync: %+v", st)
+	}
+	if st.SyncLag <= 0 || !st.LastSync.IsZero() {
+		t.Errorf("before sync: SyncLag = %v, LastSync = %v", st.SyncLag, st.LastSync)
+	}
+
+	if err := wal.Sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	st = wal.Stats()
+	if st.SyncedSequence != 3 || st.Unsynced != 0 || st.SyncLag != 0 || st.LastSync.IsZero() {
+		t.Errorf("after sync: %+v", st)
+	}
+}
+
 func TestWAL_Checkpoint(t *testing.T) {
 	dir, err := os.MkdirTemp("", "wal_test_checkpoint")
 	if err != nil {
@@ -174,7 +207,7 @@ func TestWAL_Checkpoint(t *testing.T) {
 
 	// 创建 Checkpoint
 	seq := int64(100)
-	if err := wal.CreateCheckpoint(seq, orders); err != nil {
+	if err := wal.CreateCheckpoint(seq, orders, IDWatermark{OrderID: 2}); err != nil {
 		t.Fatalf("failed to create checkpoint: %v", err)
 	}
 
@@ -186,9 +219,9 @@ func TestWAL_Checkpoint(t *testing.T) {
 
 	// 验证文件内容（简单验证大小）
 	info, _ := os.Stat(checkpointFile)
-	// Header(21) + 2 * (53 + len("BTC_USDT")) = 21 + 2 * 61 = 143 bytes
-	// ETH_USDT 也是 8 字节，所以长度一样
-	expectedSize := int64(21 + 2*(53+8))
+	// Header(21) + 2 * (53 + len("BTC_USDT") + 1) + IDs(16) = 21 + 2 * 62 + 16 = 161 bytes
+	// ETH_USDT 也是 8 字节，所以长度一样；订单末尾 1 字节是空 ClientOrderID 的长度
+	expectedSize := int64(21 + 2*(53+8+1) + 16)
 	if info.Size() != expectedSize {
 		t.Errorf("expected file size %d, got %d", expectedSize, info.Size())
 	}
@@ -217,7 +250,7 @@ func TestWAL_Recovery(t *testing.T) {
 	}
 
 	// 创建 Checkpoint (包含前 10 个)
-	if err := wal.CreateCheckpoint(10, orders); err != nil {
+	if err := wal.CreateCheckpoint(10, orders, IDWatermark{OrderID: 10}); err != nil {
 		t.Fatal(err)
 	}
 	// 截断 WAL
@@ -268,6 +301,98 @@ func TestWAL_Recovery(t *testing.T) {
 	}
 }
 
+// memObjects 内存对象存储 (代替 S3)
+type memObjects map[string][]byte
+
+func (m memObjects) Put(ctx context.Context, key string, data []byte) error {
+	m[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m memObjects) Get(ctx context.Context, key string) ([]byte, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (m memObjects) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// TestWAL_RecoverFromRemoteArchive 本地 WAL 目录全丢，只靠对象存储里的检查点 + 封存段恢复
+func TestWAL_RecoverFromRemoteArchive(t *testing.T) {
+	remote := &walstore.Remote{Objects: memObjects{}, Prefix: "mtrade/BTC_USDT/"}
+	wal, err := NewWAL(WALConfig{Dir: t.TempDir(), ArchiveDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(from, to int64) []*Order {
+		var orders []*Order
+		for i := from; i <= to; i++ {
+			o := &Order{ID: i, Symbol: "BTC_USDT", Price: 50000, Qty: 10, Side: SideBuy, Type: OrderTypeLimit}
+			wal.WriteOrder(o)
+			orders = append(orders, o)
+		}
+		wal.Sync()
+		return orders
+	}
+	// 检查点覆盖 1-10，封存段 wal_10 (检查点之前) 和 wal_15，活动段里的 16-17 随本地磁盘丢失
+	orders := write(1, 10)
+	if err := wal.CreateCheckpoint(10, orders, IDWatermark{OrderID: 10}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Truncate()
+	write(11, 15)
+	wal.Truncate()
+	write(16, 17)
+	wal.Close()
+
+	done, err := walstore.NewArchiver(walstore.ArchiverConfig{Source: wal.Archive(), Remote: *remote}).Sync(context.Background())
+	if err != nil || len(done) != 3 {
+		t.Fatalf("archive sync: %v %v", done, err)
+	}
+
+	config := DefaultEngineConfig("BTC_USDT")
+	config.WALDir = t.TempDir()
+	config.WALArchiveDir = t.TempDir()
+	config.WALRemote = remote
+	engine, err := NewEngine(config)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Stop()
+
+	// 只拉回最新检查点和它之后的段
+	restored, _ := engine.wal.Archive().List("")
+	if want := []string{"checkpoint_10.dat", archivedSegmentName(15)}; fmt.Sprint(restored) != fmt.Sprint(want) {
+		t.Errorf("restored %v, want %v", restored, want)
+	}
+	bids, _ := engine.orderBook.Depth(100)
+	total := 0
+	for _, level := range bids {
+		total += level.Orders
+	}
+	if total != 15 {
+		t.Errorf("expected 15 orders in book, got %d", total)
+	}
+	if engine.wal.GetSequence() != 15 {
+		t.Errorf("expected wal sequence 15, got %d", engine.wal.GetSequence())
+	}
+	if engine.WALArchiver() == nil {
+		t.Error("archiver not created")
+	}
+}
+
 // =============================================================================
 // WAL 基准测试
 // =============================================================================
@@ -0,0 +1,204 @@
+// 文件: pkg/nats/dedup.go
+// 消费端去重
+//
+// NATS 是至少一次投递：JetStream 超过 AckWait 未确认会重投，消费者重启也会重放未确认的消息。
+// 成交这类事件重复处理会把订单成交量、冷库余额加两次，所以消费端按 (subject, event_id) 去重：
+//
+//	Claim → 处理 → Done        成功：记录保留 TTL，窗口内的重投直接跳过
+//	Claim → 处理失败 → Release 失败：删除记录，等重投再处理
+//
+// Claim 只占一个较短的处理中 TTL：进程在处理途中崩溃时记录自动过期，重投的消息还能处理。
+// 去重只是第一道防线，TTL 过后的重投、Done 之前崩溃的消息仍可能再次到达，
+// 落库操作本身也要做成条件更新 (见 order.OrderService.OnTradeFill)。
+
+package nats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultDedupTTL 已处理事件的保留时间，应大于消息可能被重投的最长间隔
+	DefaultDedupTTL = 24 * time.Hour
+	// DefaultClaimTTL 处理中记录的保留时间，应大于单条消息的最长处理时间
+	DefaultClaimTTL = 30 * time.Second
+)
+
+// Deduper 消费端去重存储
+type Deduper interface {
+	// Claim 占用 (subject, eventID)，返回 false 表示已处理或其他实例正在处理
+	Claim(ctx context.Context, subject, eventID string) (bool, error)
+	// Done 标记处理完成，记录保留 TTL
+	Done(ctx context.Context, subject, eventID string) error
+	// Release 处理失败时释放占用，重投的消息可以再次处理
+	Release(ctx context.Context, subject, eventID string) error
+}
+
+// DedupConfig 去重配置
+type DedupConfig struct {
+	Consumer string        // 消费者名 (同一消息被多个消费组各自处理，按消费者隔离)
+	TTL      time.Duration // 已处理记录保留时间，<=0 为 DefaultDedupTTL
+	ClaimTTL time.Duration // 处理中记录保留时间，<=0 为 DefaultClaimTTL
+}
+
+func (c DedupConfig) withDefaults() DedupConfig {
+	if c.TTL <= 0 {
+		c.TTL = DefaultDedupTTL
+	}
+	if c.ClaimTTL <= 0 {
+		c.ClaimTTL = DefaultClaimTTL
+	}
+	return c
+}
+
+func (c DedupConfig) key(subject, eventID string) string {
+	return "dedup:" + c.Consumer + ":" + subject + ":" + eventID
+}
+
+// HandleOnce 按 Claim/Done/Release 协议执行 fn，重复事件返回 (false, nil)
+//
+// eventID 为空时不去重，直接执行
+func HandleOnce(ctx context.Context, d Deduper, subject, eventID string, fn func() error) (bool, error) {
+	if d == nil || eventID == "" {
+		return true, fn()
+	}
+	ok, err := d.Claim(ctx, subject, eventID)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	if err := fn(); err != nil {
+		d.Release(ctx, subject, eventID)
+		return true, err
+	}
+	return true, d.Done(ctx, subject, eventID)
+}
+
+// =============================================================================
+// Redis 实现 (多实例共享)
+// =============================================================================
+
+// RedisDeduper 基于 Redis SET NX EX 的去重
+type RedisDeduper struct {
+	client *redis.Client
+	cfg    DedupConfig
+}
+
+// NewRedisDeduper 创建 Redis 去重存储
+func NewRedisDeduper(client *redis.Client, cfg DedupConfig) *RedisDeduper {
+	return &RedisDeduper{client: client, cfg: cfg.withDefaults()}
+}
+
+const (
+	dedupClaimed = "1"
+	dedupDone    = "2"
+)
+
+func (d *RedisDeduper) Claim(ctx context.Context, subject, eventID string) (bool, error) {
+	return d.client.SetNX(ctx, d.cfg.key(subject, eventID), dedupClaimed, d.cfg.ClaimTTL).Result()
+}
+
+func (d *RedisDeduper) Done(ctx context.Context, subject, eventID string) error {
+	return d.client.Set(ctx, d.cfg.key(subject, eventID), dedupDone, d.cfg.TTL).Err()
+}
+
+// luaRelease 只删除处理中的记录，不误删已完成的
+const luaRelease = `
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`
+
+func (d *RedisDeduper) Release(ctx context.Context, subject, eventID string) error {
+	return d.client.Eval(ctx, luaRelease, []string{d.cfg.key(subject, eventID)}, dedupClaimed).Err()
+}
+
+// =============================================================================
+// 内存实现 (单实例 / 测试)
+// =============================================================================
+
+// MemoryDeduper 进程内去重，过期记录在 Claim 时顺带清理
+type MemoryDeduper struct {
+	cfg DedupConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]memDedupEntry
+	sweepAt time.Time
+}
+
+type memDedupEntry struct {
+	done     bool
+	expireAt time.Time
+}
+
+// NewMemoryDeduper 创建内存去重存储
+func NewMemoryDeduper(cfg DedupConfig) *MemoryDeduper {
+	return &MemoryDeduper{
+		cfg:     cfg.withDefaults(),
+		now:     time.Now,
+		entries: make(map[string]memDedupEntry),
+	}
+}
+
+// SetClock 替换去重记录过期 (TTL) 的时钟
+func (d *MemoryDeduper) SetClock(now func() time.Time) {
+	d.now = now
+}
+
+func (d *MemoryDeduper) Claim(ctx context.Context, subject, eventID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	d.sweep(now)
+	key := d.cfg.key(subject, eventID)
+	if e, ok := d.entries[key]; ok && now.Before(e.expireAt) {
+		return false, nil
+	}
+	d.entries[key] = memDedupEntry{expireAt: now.Add(d.cfg.ClaimTTL)}
+	return true, nil
+}
+
+func (d *MemoryDeduper) Done(ctx context.Context, subject, eventID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[d.cfg.key(subject, eventID)] = memDedupEntry{done: true, expireAt: d.now().Add(d.cfg.TTL)}
+	return nil
+}
+
+func (d *MemoryDeduper) Release(ctx context.Context, subject, eventID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := d.cfg.key(subject, eventID)
+	if e, ok := d.entries[key]; ok && !e.done {
+		delete(d.entries, key)
+	}
+	return nil
+}
+
+// sweep 每个 ClaimTTL 周期最多全表清理一次过期记录
+func (d *MemoryDeduper) sweep(now time.Time) {
+	if now.Before(d.sweepAt) {
+		return
+	}
+	for k, e := range d.entries {
+		if !now.Before(e.expireAt) {
+			delete(d.entries, k)
+		}
+	}
+	d.sweepAt = now.Add(d.cfg.ClaimTTL)
+}
+
+// Len 当前记录数 (含未清理的过期记录)
+func (d *MemoryDeduper) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.entries)
+}
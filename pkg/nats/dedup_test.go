@@ -0,0 +1,60 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryDeduper_HandleOnce(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := NewMemoryDeduper(DedupConfig{Consumer: "test", TTL: time.Hour, ClaimTTL: time.Minute})
+	d.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	calls := 0
+	apply := func() error { calls++; return nil }
+
+	if ok, err := HandleOnce(ctx, d, "trades", "1", apply); !ok || err != nil {
+		t.Fatalf("first delivery: %v %v", ok, err)
+	}
+	if ok, err := HandleOnce(ctx, d, "trades", "1", apply); ok || err != nil {
+		t.Fatalf("redelivery: %v %v", ok, err)
+	}
+	// 不同主题的同号事件互不影响
+	if ok, _ := HandleOnce(ctx, d, "order.canceled", "1", apply); !ok {
+		t.Fatal("other subject deduplicated")
+	}
+	if calls != 2 {
+		t.Fatalf("applied %d times, want 2", calls)
+	}
+
+	// 失败释放占用，重投可以再处理
+	boom := errors.New("db down")
+	if _, err := HandleOnce(ctx, d, "trades", "2", func() error { return boom }); !errors.Is(err, boom) {
+		t.Fatalf("failed handler: %v", err)
+	}
+	if ok, _ := HandleOnce(ctx, d, "trades", "2", apply); !ok {
+		t.Fatal("failed event not retried")
+	}
+
+	// 处理途中崩溃：占用过期后重投可以再处理
+	if ok, _ := d.Claim(ctx, "trades", "3"); !ok {
+		t.Fatal("claim failed")
+	}
+	if ok, _ := d.Claim(ctx, "trades", "3"); ok {
+		t.Fatal("concurrent claim succeeded")
+	}
+	now = now.Add(2 * time.Minute)
+	if ok, _ := d.Claim(ctx, "trades", "3"); !ok {
+		t.Fatal("expired claim not reclaimable")
+	}
+
+	// 已处理记录 TTL 过后清理
+	now = now.Add(2 * time.Hour)
+	d.Claim(ctx, "trades", "4")
+	if n := d.Len(); n != 1 {
+		t.Fatalf("entries after TTL = %d, want 1", n)
+	}
+}
@@ -0,0 +1,311 @@
+// 文件: pkg/nats/jetstream.go
+// JetStream 持久化投递 (可选)
+//
+// 【问题】Core NATS 是"在线才收得到"：订单服务、冷钱包写入器发布期间重启或宕机，
+// 这段时间的成交 / 撤单直接丢失，只能靠对账发现
+//
+// 【做法】Publisher / Subscriber 调 EnableJetStream 之后：
+//   - 流覆盖的主题发布走 JetStream，等服务端确认写入流再返回 (失败返回错误，调用方按原有逻辑重试或记录)；
+//     其余主题照旧走 Core NATS
+//   - SubscribeQueue 变成持久化消费者 (durable = 队列名 + 主题)：服务端记住消费位点，
+//     重启后从上次确认的位置继续；同一队列的多个实例共享一个消费者做负载均衡
+//   - 显式确认：handler 成功 Ack，失败 NakWithDelay 等待重投；
+//     投递次数达到 MaxDeliver 仍失败时 Term，不再重投 (计入 Terminated，需人工处理)
+//   - ConsumerConfig.StartSeq：重新部署后从指定流序号重放 (删掉旧消费者按新起点重建)
+//   - 消费者显式创建后绑定订阅，进程退出 (Unsubscribe / 断连) 不会删掉消费者
+//
+// 流的保留策略 (时长 / 条数 / 字节 / 副本数) 见 StreamConfig，EnableJetStream 时创建或更新
+//
+// 【注意】
+//   - 重投和重放都会让 handler 看到重复消息，消费端仍需去重 (见 dedup.go)；
+//     去重记录 TTL 内的重放会被跳过，只有没处理过的消息真正生效
+//   - 同步发布多一次服务端往返 (同机房约 1ms)，撮合回调里发布的吞吐以此为上限
+//   - Subscribe (非队列订阅) 仍是 Core NATS 订阅：行情这类丢了无所谓的推送不占用服务端消费者
+
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// =============================================================================
+// 流配置
+// =============================================================================
+
+// Retention 流的消息保留策略
+type Retention string
+
+const (
+	RetentionLimits    Retention = "limits"    // 按时长 / 条数 / 字节淘汰，消费与否不影响 (默认，可重放)
+	RetentionInterest  Retention = "interest"  // 所有消费者都确认后删除
+	RetentionWorkQueue Retention = "workqueue" // 任一消费者确认后删除，同一主题只允许一个消费者
+)
+
+// StreamConfig 流配置
+type StreamConfig struct {
+	Name            string
+	Subjects        []string
+	Retention       Retention     // 默认 limits
+	MaxAge          time.Duration // 消息最长保留时间，默认 7 天 (重放窗口)
+	MaxMsgs         int64         // 0 = 不限
+	MaxBytes        int64         // 0 = 不限
+	Replicas        int           // 副本数，默认 1 (集群部署建议 3)
+	MemoryStorage   bool          // true = 内存存储 (服务端重启丢数据，只用于测试)
+	DuplicateWindow time.Duration // 发布端按消息 ID 去重的窗口，默认 2 分钟
+}
+
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.Retention == "" {
+		c.Retention = RetentionLimits
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 7 * 24 * time.Hour
+	}
+	if c.Replicas <= 0 {
+		c.Replicas = 1
+	}
+	if c.DuplicateWindow <= 0 {
+		c.DuplicateWindow = 2 * time.Minute
+	}
+	return c
+}
+
+func (c StreamConfig) toNATS() (*nats.StreamConfig, error) {
+	c = c.withDefaults()
+	if c.Name == "" || len(c.Subjects) == 0 {
+		return nil, fmt.Errorf("nats: stream name and subjects are required")
+	}
+	sc := &nats.StreamConfig{
+		Name:       c.Name,
+		Subjects:   c.Subjects,
+		MaxAge:     c.MaxAge,
+		MaxMsgs:    c.MaxMsgs,
+		MaxBytes:   c.MaxBytes,
+		Replicas:   c.Replicas,
+		Storage:    nats.FileStorage,
+		Duplicates: c.DuplicateWindow,
+	}
+	if c.MaxMsgs <= 0 {
+		sc.MaxMsgs = -1
+	}
+	if c.MaxBytes <= 0 {
+		sc.MaxBytes = -1
+	}
+	if c.MemoryStorage {
+		sc.Storage = nats.MemoryStorage
+	}
+	switch c.Retention {
+	case RetentionLimits:
+		sc.Retention = nats.LimitsPolicy
+	case RetentionInterest:
+		sc.Retention = nats.InterestPolicy
+	case RetentionWorkQueue:
+		sc.Retention = nats.WorkQueuePolicy
+	default:
+		return nil, fmt.Errorf("nats: unknown retention %q", c.Retention)
+	}
+	return sc, nil
+}
+
+// MatchEventStream 撮合事件流：成交和撤单 (订单状态、冷钱包写入器的持久化消费者都在这个流上)
+func MatchEventStream() StreamConfig {
+	return StreamConfig{Name: "MATCH_EVENTS", Subjects: []string{"trades", "order.canceled"}}
+}
+
+// subjectMatches 主题是否匹配流的主题模式 (* 匹配一段，> 匹配剩余所有段)
+func subjectMatches(pattern, subject string) bool {
+	pt, st := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, tok := range pt {
+		if tok == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) || (tok != "*" && tok != st[i]) {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
+
+// ensureStreams 创建不存在的流，已存在的按配置更新 (保留策略调整不需要重建流)
+func ensureStreams(js nats.JetStreamContext, streams []StreamConfig) error {
+	for _, c := range streams {
+		sc, err := c.toNATS()
+		if err != nil {
+			return err
+		}
+		if _, err := js.StreamInfo(sc.Name); errors.Is(err, nats.ErrStreamNotFound) {
+			_, err = js.AddStream(sc)
+			if err != nil {
+				return fmt.Errorf("add stream %s: %w", sc.Name, err)
+			}
+			continue
+		} else if err != nil {
+			return fmt.Errorf("stream info %s: %w", sc.Name, err)
+		}
+		if _, err := js.UpdateStream(sc); err != nil {
+			return fmt.Errorf("update stream %s: %w", sc.Name, err)
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// 消费者配置
+// =============================================================================
+
+// ConsumerConfig 持久化消费者配置
+type ConsumerConfig struct {
+	AckWait       time.Duration // 超过未确认即重投，默认 30s (应大于单条消息最长处理时间)
+	MaxDeliver    int           // 最多投递次数，默认 10；达到后失败的消息 Term
+	MaxAckPending int           // 已投递未确认上限 (流控)，默认 1024
+	NakDelay      time.Duration // handler 失败后的重投延迟，默认 1s
+	StartSeq      uint64        // >0：从该流序号开始重放；0：新消费者从头消费，已有消费者从位点继续
+}
+
+func (c ConsumerConfig) withDefaults() ConsumerConfig {
+	if c.AckWait <= 0 {
+		c.AckWait = 30 * time.Second
+	}
+	if c.MaxDeliver <= 0 {
+		c.MaxDeliver = 10
+	}
+	if c.MaxAckPending <= 0 {
+		c.MaxAckPending = 1024
+	}
+	if c.NakDelay <= 0 {
+		c.NakDelay = time.Second
+	}
+	return c
+}
+
+// DurableName 队列 + 主题对应的持久化消费者名 (消费者名不能含 . * >)
+func DurableName(queue, subject string) string {
+	r := strings.NewReplacer(".", "_", "*", "_", ">", "_")
+	return r.Replace(queue + "_" + subject)
+}
+
+// JetStreamConfig Subscriber.EnableJetStream 的配置
+type JetStreamConfig struct {
+	Streams  []StreamConfig // 需要保证存在的流
+	Consumer ConsumerConfig // SubscribeQueue 创建的持久化消费者
+}
+
+// =============================================================================
+// 确认
+// =============================================================================
+
+// acker JetStream 消息的确认操作 (*nats.Msg 实现)
+type acker interface {
+	Ack(opts ...nats.AckOpt) error
+	NakWithDelay(delay time.Duration, opts ...nats.AckOpt) error
+	Term(opts ...nats.AckOpt) error
+}
+
+// settle 处理一条 JetStream 消息并确认：成功 Ack，失败 Nak 等重投，投递次数用完 Term
+func (s *Subscriber) settle(ss *subscription, subject string, data []byte, m acker, delivered uint64) {
+	cfg := s.jsCfg.Consumer
+	err := s.handle(subject, data)
+	switch {
+	case err == nil:
+		err = m.Ack()
+		if err != nil {
+			ss.ackErrors.Add(1)
+			log.Printf("[NATS] ack error: subject=%s durable=%s, err=%v", subject, ss.durable, err)
+		}
+		return
+	case delivered >= uint64(cfg.MaxDeliver):
+		ss.errors.Add(1)
+		ss.terminated.Add(1)
+		log.Printf("[NATS] give up after %d deliveries: subject=%s durable=%s, err=%v", delivered, subject, ss.durable, err)
+		err = m.Term()
+	default:
+		ss.errors.Add(1)
+		log.Printf("[NATS] handle error (delivery %d/%d): subject=%s durable=%s, err=%v", delivered, cfg.MaxDeliver, subject, ss.durable, err)
+		err = m.NakWithDelay(cfg.NakDelay)
+	}
+	if err != nil {
+		ss.ackErrors.Add(1)
+	}
+}
+
+// jsCallback JetStream 订阅的回调
+func (s *Subscriber) jsCallback(ss *subscription) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		var delivered uint64 = 1
+		if md, err := msg.Metadata(); err == nil {
+			delivered = md.NumDelivered
+		}
+		s.settle(ss, msg.Subject, msg.Data, msg, delivered)
+	}
+}
+
+// subscribeDurable EnableJetStream 之后的 SubscribeQueue：持久化队列消费者
+//
+// 消费者由这里显式创建再 Bind 订阅：库自己创建的消费者在 Unsubscribe / Drain 时会被删掉，
+// 进程退出一次就丢了消费位点
+func (s *Subscriber) subscribeDurable(subject, queue string) error {
+	durable := DurableName(queue, subject)
+	stream, err := s.js.StreamNameBySubject(subject)
+	if err != nil {
+		return fmt.Errorf("no stream for %s: %w", subject, err)
+	}
+	if err := s.ensureConsumer(stream, durable, subject, queue); err != nil {
+		return err
+	}
+
+	ss := &subscription{subject: subject, queue: queue, durable: durable, stream: stream}
+	if err := s.subscribe(ss); err != nil {
+		return err
+	}
+	s.addSub(ss)
+	return nil
+}
+
+// ensureConsumer 消费者不存在时创建；配置了 StartSeq 且已有消费者的起点不同时删掉按新起点重建
+//
+// 起点相同说明本次重放已经建过 (重启时配置没改)，保留位点不再从头重放。
+// 多个实例同时创建时只有一个成功，其余发现已存在直接绑定
+func (s *Subscriber) ensureConsumer(stream, durable, subject, queue string) error {
+	cfg := s.jsCfg.Consumer
+	info, err := s.js.ConsumerInfo(stream, durable)
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound):
+	case err != nil:
+		return fmt.Errorf("consumer info %s/%s: %w", stream, durable, err)
+	case cfg.StartSeq == 0 || (info.Config.DeliverPolicy == nats.DeliverByStartSequencePolicy && info.Config.OptStartSeq == cfg.StartSeq):
+		return nil
+	default:
+		if err := s.js.DeleteConsumer(stream, durable); err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+			return fmt.Errorf("delete consumer %s/%s: %w", stream, durable, err)
+		}
+		log.Printf("[NATS] replay %s/%s from stream seq %d (consumer reset)", stream, durable, cfg.StartSeq)
+	}
+
+	cc := &nats.ConsumerConfig{
+		Durable:        durable,
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   queue,
+		FilterSubject:  subject,
+		DeliverPolicy:  nats.DeliverAllPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        cfg.AckWait,
+		MaxDeliver:     cfg.MaxDeliver,
+		MaxAckPending:  cfg.MaxAckPending,
+	}
+	if cfg.StartSeq > 0 {
+		cc.DeliverPolicy, cc.OptStartSeq = nats.DeliverByStartSequencePolicy, cfg.StartSeq
+	}
+	if _, err := s.js.AddConsumer(stream, cc); err != nil {
+		if _, infoErr := s.js.ConsumerInfo(stream, durable); infoErr == nil {
+			return nil // 其他实例抢先建好了
+		}
+		return fmt.Errorf("add consumer %s/%s: %w", stream, durable, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package nats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestStreamConfig_ToNATS(t *testing.T) {
+	sc, err := MatchEventStream().toNATS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Retention != nats.LimitsPolicy || sc.Storage != nats.FileStorage || sc.MaxAge != 7*24*time.Hour ||
+		sc.MaxMsgs != -1 || sc.MaxBytes != -1 || sc.Replicas != 1 {
+		t.Errorf("defaults: %+v", sc)
+	}
+
+	sc, err = StreamConfig{Name: "Q", Subjects: []string{"jobs.>"}, Retention: RetentionWorkQueue, MaxMsgs: 10, MemoryStorage: true}.toNATS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Retention != nats.WorkQueuePolicy || sc.Storage != nats.MemoryStorage || sc.MaxMsgs != 10 {
+		t.Errorf("overrides: %+v", sc)
+	}
+
+	if _, err := (StreamConfig{Name: "X", Subjects: []string{"a"}, Retention: "forever"}).toNATS(); err == nil {
+		t.Error("unknown retention accepted")
+	}
+	if _, err := (StreamConfig{Name: "X"}).toNATS(); err == nil {
+		t.Error("stream without subjects accepted")
+	}
+}
+
+func TestSubjectMatches(t *testing.T) {
+	cases := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"trades", "trades", true},
+		{"trades", "trades.btc", false},
+		{"order.canceled", "order.canceled", true},
+		{"order.*", "order.canceled", true},
+		{"order.*", "order.canceled.x", false},
+		{"order.>", "order.canceled.x", true},
+		{"order.>", "order", false},
+		{"asset_journal_events", "trades", false},
+	}
+	for _, c := range cases {
+		if got := subjectMatches(c.pattern, c.subject); got != c.want {
+			t.Errorf("subjectMatches(%q, %q) = %v", c.pattern, c.subject, got)
+		}
+	}
+	if got := DurableName("db-writer", "order.canceled"); got != "db-writer_order_canceled" {
+		t.Errorf("DurableName = %q", got)
+	}
+}
+
+// fakeAck 记录确认动作
+type fakeAck struct{ acked, naked, termed int }
+
+func (a *fakeAck) Ack(...nats.AckOpt) error                         { a.acked++; return nil }
+func (a *fakeAck) NakWithDelay(time.Duration, ...nats.AckOpt) error { a.naked++; return nil }
+func (a *fakeAck) Term(...nats.AckOpt) error                        { a.termed++; return nil }
+
+func TestSubscriber_Settle(t *testing.T) {
+	fail := true
+	s := &Subscriber{
+		handler: func(string, []byte) error {
+			if fail {
+				return errors.New("db down")
+			}
+			return nil
+		},
+		jsCfg: JetStreamConfig{Consumer: ConsumerConfig{MaxDeliver: 3}.withDefaults()},
+	}
+	ss := &subscription{durable: "db-writer_trades"}
+
+	// 失败：未到上限 Nak 等重投，到上限 Term
+	var a fakeAck
+	s.settle(ss, "trades", nil, &a, 1)
+	s.settle(ss, "trades", nil, &a, 2)
+	s.settle(ss, "trades", nil, &a, 3)
+	if a.naked != 2 || a.termed != 1 || a.acked != 0 {
+		t.Fatalf("failures: %+v", a)
+	}
+	if ss.errors.Load() != 3 || ss.terminated.Load() != 1 {
+		t.Fatalf("counters: errors=%d terminated=%d", ss.errors.Load(), ss.terminated.Load())
+	}
+
+	fail = false
+	s.settle(ss, "trades", nil, &a, 2)
+	if a.acked != 1 {
+		t.Fatalf("success not acked: %+v", a)
+	}
+}
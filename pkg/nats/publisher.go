@@ -14,6 +14,9 @@ import (
 // Publisher NATS 发布者
 type Publisher struct {
 	conn *nats.Conn
+	js   nats.JetStreamContext // 启用 JetStream 后非空 (见 jetstream.go)
+
+	jsSubjects []string // 流覆盖的主题，其余主题仍走 Core NATS (发到没有流的主题 JetStream 会报错)
 }
 
 // NewPublisher 创建发布者
@@ -25,20 +28,63 @@ func NewPublisher(url string) (*Publisher, error) {
 	return &Publisher{conn: conn}, nil
 }
 
+// EnableJetStream 启用 JetStream：创建或更新 streams，之后发布等服务端确认写入流
+func (p *Publisher) EnableJetStream(streams ...StreamConfig) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	if err := ensureStreams(js, streams); err != nil {
+		return err
+	}
+	for _, sc := range streams {
+		p.jsSubjects = append(p.jsSubjects, sc.Subjects...)
+	}
+	p.js = js
+	return nil
+}
+
+// durable 该主题是否写入 JetStream 流
+func (p *Publisher) durable(subject string) bool {
+	if p.js == nil {
+		return false
+	}
+	for _, pattern := range p.jsSubjects {
+		if subjectMatches(pattern, subject) {
+			return true
+		}
+	}
+	return false
+}
+
 // Publish 发布消息
 func (p *Publisher) Publish(subject string, data any) error {
 	bytes, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	return p.conn.Publish(subject, bytes)
+	return p.PublishRaw(subject, bytes)
 }
 
 // PublishRaw 发布原始消息
 func (p *Publisher) PublishRaw(subject string, data []byte) error {
+	if p.durable(subject) {
+		_, err := p.js.Publish(subject, data)
+		return err
+	}
 	return p.conn.Publish(subject, data)
 }
 
+// PublishWithID 带消息 ID 发布：JetStream 在流的 DuplicateWindow 内丢弃同 ID 的重复发布，
+// 发布超时后重试不会写两份。主题不在 JetStream 流里时 ID 不起作用
+func (p *Publisher) PublishWithID(subject, msgID string, data []byte) error {
+	if !p.durable(subject) {
+		return p.conn.Publish(subject, data)
+	}
+	_, err := p.js.Publish(subject, data, nats.MsgId(msgID))
+	return err
+}
+
 // Close 关闭连接
 func (p *Publisher) Close() {
 	p.conn.Close()
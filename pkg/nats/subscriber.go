@@ -4,9 +4,13 @@
 package nats
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nats-io/nats.go"
 )
@@ -17,8 +21,47 @@ type MessageHandler func(subject string, data []byte) error
 // Subscriber NATS 订阅者
 type Subscriber struct {
 	conn    *nats.Conn
-	subs    []*nats.Subscription
+	mu      sync.Mutex
+	subs    []*subscription
 	handler MessageHandler
+
+	// JetStream (可选，见 jetstream.go)：启用后 SubscribeQueue 为持久化消费者
+	js    nats.JetStreamContext
+	jsCfg JetStreamConfig
+
+	// 维护模式 (见 Pause)
+	paused   bool
+	inflight atomic.Int64 // 正在执行的 handler 数
+}
+
+// subscription 一个订阅及其处理失败计数
+type subscription struct {
+	sub     *nats.Subscription // Resume 时替换，读写持 Subscriber.mu
+	subject string
+	queue   string
+	durable string // JetStream 持久化消费者名，Core NATS 订阅为空
+	stream  string // 持久化消费者所在的流
+	errors  atomic.Int64
+
+	terminated atomic.Int64 // 投递次数用完仍失败、已 Term 的消息数
+	ackErrors  atomic.Int64 // Ack / Nak / Term 发送失败次数 (消息会在 AckWait 后重投)
+}
+
+// handle 调用 handler 并计入在途数，Pause 据此等在途消息处理完
+func (s *Subscriber) handle(subject string, data []byte) error {
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+	return s.handler(subject, data)
+}
+
+// callback 处理消息：Core NATS 没有重投，失败只记日志和计数
+func (s *Subscriber) callback(ss *subscription) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		if err := s.handle(msg.Subject, msg.Data); err != nil {
+			ss.errors.Add(1)
+			log.Printf("[NATS] handle error: subject=%s, err=%v", msg.Subject, err)
+		}
+	}
 }
 
 // NewSubscriber 创建订阅者
@@ -33,45 +76,255 @@ func NewSubscriber(url string, handler MessageHandler) (*Subscriber, error) {
 	}, nil
 }
 
+// EnableJetStream 启用 JetStream：创建或更新 cfg.Streams，之后的 SubscribeQueue 为持久化消费者
+//
+// 需在 SubscribeQueue 之前调用
+func (s *Subscriber) EnableJetStream(cfg JetStreamConfig) error {
+	js, err := s.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	if err := ensureStreams(js, cfg.Streams); err != nil {
+		return err
+	}
+	cfg.Consumer = cfg.Consumer.withDefaults()
+	s.js, s.jsCfg = js, cfg
+	return nil
+}
+
 // Subscribe 订阅主题
 func (s *Subscriber) Subscribe(subjects ...string) error {
 	for _, subject := range subjects {
-		sub, err := s.conn.Subscribe(subject, func(msg *nats.Msg) {
-			if err := s.handler(msg.Subject, msg.Data); err != nil {
-				log.Printf("[NATS] handle error: subject=%s, err=%v", msg.Subject, err)
-			}
-		})
-		if err != nil {
-			return fmt.Errorf("subscribe %s: %w", subject, err)
+		ss := &subscription{subject: subject}
+		if err := s.subscribe(ss); err != nil {
+			return err
 		}
-		s.subs = append(s.subs, sub)
+		s.addSub(ss)
 	}
 	return nil
 }
 
 // SubscribeQueue 队列订阅 (负载均衡)
+//
+// 启用 JetStream 后为持久化消费者 (消费者名见 DurableName)，消费位点由服务端保存
 func (s *Subscriber) SubscribeQueue(subject, queue string) error {
-	sub, err := s.conn.QueueSubscribe(subject, queue, func(msg *nats.Msg) {
-		if err := s.handler(msg.Subject, msg.Data); err != nil {
-			log.Printf("[NATS] handle error: subject=%s, err=%v", msg.Subject, err)
+	if s.js != nil {
+		return s.subscribeDurable(subject, queue)
+	}
+	ss := &subscription{subject: subject, queue: queue}
+	if err := s.subscribe(ss); err != nil {
+		return err
+	}
+	s.addSub(ss)
+	return nil
+}
+
+// subscribe 按 ss 的主题 / 队列 / 持久化消费者 (重新) 订阅，Resume 复用同一个 ss 保留计数
+func (s *Subscriber) subscribe(ss *subscription) error {
+	var (
+		sub *nats.Subscription
+		err error
+	)
+	switch {
+	case ss.durable != "":
+		sub, err = s.js.QueueSubscribe(ss.subject, ss.queue, s.jsCallback(ss), nats.Bind(ss.stream, ss.durable), nats.ManualAck())
+		if err != nil {
+			return fmt.Errorf("subscribe %s (durable %s): %w", ss.subject, ss.durable, err)
 		}
-	})
+	case ss.queue != "":
+		sub, err = s.conn.QueueSubscribe(ss.subject, ss.queue, s.callback(ss))
+	default:
+		sub, err = s.conn.Subscribe(ss.subject, s.callback(ss))
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("subscribe %s: %w", ss.subject, err)
 	}
-	s.subs = append(s.subs, sub)
+	s.mu.Lock()
+	ss.sub = sub
+	s.mu.Unlock()
 	return nil
 }
 
-// Close 关闭
+func (s *Subscriber) addSub(ss *subscription) {
+	s.mu.Lock()
+	s.subs = append(s.subs, ss)
+	s.mu.Unlock()
+}
+
+// SubscriptionStats 订阅的消费进度
+//
+// Core NATS 没有服务端的消费位点，"消费延迟" 看客户端积压：Pending 持续增长说明处理跟不上，
+// 积压超过客户端上限后新消息被丢弃 (Dropped)。
+// JetStream 持久化消费者另外带服务端视角：StreamPending 是流里还没投递给该消费者的消息数
+type SubscriptionStats struct {
+	Subject       string
+	Queue         string // 队列组，普通订阅为空
+	Pending       int    // 已收到未处理的消息数
+	PendingBytes  int
+	Delivered     int64 // 已交给 handler 的消息数
+	Dropped       int   // 积压超限被丢弃的消息数
+	HandlerErrors int64 // handler 返回错误的次数
+
+	Durable       string // JetStream 持久化消费者名，以下字段只对 JetStream 订阅有值
+	AckFloor      uint64 // 已确认的最大连续流序号 (重放起点参考)
+	StreamPending uint64 // 流里还没投递给该消费者的消息数
+	AckPending    int    // 已投递未确认
+	Redelivered   int    // 正在重投的消息数
+	Terminated    int64  // 投递次数用完已放弃的消息数
+	AckErrors     int64
+}
+
+// Stats 各订阅的消费进度
+func (s *Subscriber) Stats() []SubscriptionStats {
+	s.mu.Lock()
+	subs := s.subs
+	current := make([]*nats.Subscription, len(subs))
+	for i, ss := range subs {
+		current[i] = ss.sub
+	}
+	s.mu.Unlock()
+
+	stats := make([]SubscriptionStats, 0, len(subs))
+	for i, ss := range subs {
+		sub := current[i]
+		st := SubscriptionStats{
+			Subject:       ss.subject,
+			Queue:         ss.queue,
+			HandlerErrors: ss.errors.Load(),
+		}
+		// 订阅已关闭 (含暂停中) 时以下调用返回错误，保留零值
+		st.Pending, st.PendingBytes, _ = sub.Pending()
+		st.Delivered, _ = sub.Delivered()
+		st.Dropped, _ = sub.Dropped()
+		if ss.durable != "" {
+			st.Durable = ss.durable
+			st.Terminated = ss.terminated.Load()
+			st.AckErrors = ss.ackErrors.Load()
+			if info, err := sub.ConsumerInfo(); err == nil {
+				st.AckFloor = info.AckFloor.Stream
+				st.StreamPending = info.NumPending
+				st.AckPending = info.NumAckPending
+				st.Redelivered = info.NumRedelivered
+			}
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// Close 关闭 (持久化消费者保留在服务端，下次启动从位点继续)
 func (s *Subscriber) Close() error {
-	for _, sub := range s.subs {
-		sub.Unsubscribe()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ss := range s.subs {
+		ss.sub.Unsubscribe()
 	}
 	s.conn.Close()
 	return nil
 }
 
+// =============================================================================
+// 维护模式：暂停 / 恢复
+// =============================================================================
+//
+// 【问题】滚动发布直接 Close：客户端已收到未处理的消息被丢掉 (Core NATS 没有重投)，
+// 正在执行的 handler 被进程退出打断，结算尾巴要靠对账补
+//
+// 【做法】Pause 对每个订阅 Drain：先撤销订阅兴趣不再拉新消息 (队列组里的消息改投其他实例)，
+// 已收到的消息照常处理完再关闭订阅；等所有订阅关闭、在途 handler 归零后返回，即 "已排空"。
+// Resume 按原主题 / 队列 / 持久化消费者重新订阅，计数沿用
+//
+// 【注意】持久化消费者是 Bind 的，Drain 不会删掉它，暂停期间的消息留在流里，恢复后继续消费
+
+// drainPollInterval Pause 检查排空进度的间隔
+const drainPollInterval = 10 * time.Millisecond
+
+// Pause 停止拉取新消息，等已收到和正在处理的消息处理完
+//
+// ctx 到期仍未排空返回错误，订阅保持暂停 (Drain 在后台继续)；可重复调用，
+// 上次 Resume 中途失败时已恢复的订阅也会被重新 Drain
+func (s *Subscriber) Pause(ctx context.Context) error {
+	s.mu.Lock()
+	for _, ss := range s.subs {
+		if !ss.sub.IsValid() {
+			continue
+		}
+		if err := ss.sub.Drain(); err != nil {
+			log.Printf("[NATS] drain error: subject=%s, err=%v", ss.subject, err)
+		}
+	}
+	s.paused = true
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for !s.Drained() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("drain subscriber: %d in flight: %w", s.inflight.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Resume 重新订阅，未暂停时什么都不做；Pause 超时后 Drain 还没结束时返回错误，稍后重试
+func (s *Subscriber) Resume() error {
+	s.mu.Lock()
+	if !s.paused {
+		s.mu.Unlock()
+		return nil
+	}
+	subs := s.subs
+	s.mu.Unlock()
+
+	for _, ss := range subs {
+		s.mu.Lock()
+		sub := ss.sub
+		s.mu.Unlock()
+		if sub.IsDraining() {
+			return fmt.Errorf("resume %s: still draining", ss.subject)
+		}
+		if sub.IsValid() {
+			continue // 上次 Resume 中途失败前已恢复
+		}
+		if err := s.subscribe(ss); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+	return nil
+}
+
+// Paused 是否处于暂停中
+func (s *Subscriber) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// Drained 已暂停、所有订阅已关闭且没有在途消息
+func (s *Subscriber) Drained() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return false
+	}
+	for _, ss := range s.subs {
+		if ss.sub.IsValid() {
+			return false
+		}
+	}
+	return s.inflight.Load() == 0
+}
+
+// InFlight 正在执行的 handler 数
+func (s *Subscriber) InFlight() int64 {
+	return s.inflight.Load()
+}
+
 // =============================================================================
 // 便捷方法
 // =============================================================================
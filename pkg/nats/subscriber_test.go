@@ -0,0 +1,38 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscriber_PauseWaitsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s := &Subscriber{handler: func(string, []byte) error {
+		close(started)
+		<-release
+		return nil
+	}}
+	go s.handle("trades", nil)
+	<-started
+	if s.InFlight() != 1 {
+		t.Fatalf("in flight: %d", s.InFlight())
+	}
+
+	// 处理中的消息没结束，Pause 不算排空
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := s.Pause(ctx); !errors.Is(err, context.DeadlineExceeded) || s.Drained() {
+		t.Fatalf("pause while in flight: %v", err)
+	}
+
+	close(release)
+	if err := s.Pause(context.Background()); err != nil || !s.Drained() || !s.Paused() {
+		t.Fatalf("pause after in flight done: %v", err)
+	}
+	if err := s.Resume(); err != nil || s.Paused() {
+		t.Fatalf("resume: %v", err)
+	}
+}
@@ -0,0 +1,390 @@
+// Package notify 用户通知：把各模块的业务事件按用户偏好扇出到邮件 / Webhook / 推送
+//
+//	强平预警 (liquidation.LevelChange) ──LiquidationWarningHandler──┐
+//	大额成交 (spot 流水) ────────────────PublishJournal─────────────┤
+//	资金费扣款 (futures.FundingReport) ──FundingHandler─────────────┼──→ Service.Notify (按用户分片入队)
+//	提现状态 (withdrawrisk.DecisionEvent) ─WithdrawalHandler────────┤
+//	自动追加保证金 (futures.AutoMarginEvent) ─AutoMarginHandler─────┘          │
+//	                                                                          ↓
+//	                                          worker: 读偏好 → 选渠道 → 限流 → Sink.Send
+//	                                                                          ├─→ EmailSink
+//	                                                                          ├─→ WebhookSink
+//	                                                                          └─→ PushSink (WS 网关)
+//
+// 【做法】
+//   - 来源回调只入队不做 IO：队列满时丢弃并计数 (Dropped)，通知是尽力而为，不能拖慢撮合、强平和扫描
+//   - 同一用户固定落在同一个 worker，用户看到的通知顺序和事件顺序一致
+//   - 每个用户在每个渠道一个令牌桶 (RateLimits)：风险率在阈值附近来回抖动时，邮件不会刷屏
+//   - 偏好没配置的用户按默认渠道发 (DefaultChannels)，Webhook 只对显式开启的用户发
+//
+// 【面试】为什么限流按 (用户, 渠道) 而不是按用户？
+// 各渠道的成本和打扰程度不同：推送一秒几条没问题，邮件一分钟几封就是骚扰，
+// 一个渠道被限流也不该影响另一个渠道把消息送到。
+//
+// 【注意】通知不是权威数据：发送失败只记日志和计数，不重试不落库；需要可靠投递的下游请消费 eventlog
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrPreferencesNotFound = cexerr.New("NOTIFY_PREFERENCES_NOT_FOUND", cexerr.CategoryNotFound, "notify: preferences not found")
+	ErrUnknownChannel      = cexerr.New("NOTIFY_UNKNOWN_CHANNEL", cexerr.CategoryInvalidArgument, "notify: unknown channel")
+	ErrInvalidWebhookURL   = cexerr.New("NOTIFY_INVALID_WEBHOOK_URL", cexerr.CategoryInvalidArgument, "notify: invalid webhook url")
+)
+
+// =============================================================================
+// 通知
+// =============================================================================
+
+// Kind 通知类型
+type Kind string
+
+const (
+	KindLiquidationWarning Kind = "LIQUIDATION_WARNING" // 风险等级升高 (预警 / 危险 / 临界 / 强平)
+	KindLargeFill          Kind = "LARGE_FILL"          // 大额成交
+	KindFunding            Kind = "FUNDING"             // 资金费扣款
+	KindWithdrawal         Kind = "WITHDRAWAL"          // 提现状态变化
+	KindAutoMargin         Kind = "AUTO_MARGIN"         // 自动追加保证金 (成功或失败)
+)
+
+// Kinds 全部通知类型
+var Kinds = []Kind{KindLiquidationWarning, KindLargeFill, KindFunding, KindWithdrawal, KindAutoMargin}
+
+// Channel 通知渠道
+type Channel string
+
+const (
+	ChannelEmail   Channel = "EMAIL"
+	ChannelWebhook Channel = "WEBHOOK"
+	ChannelPush    Channel = "PUSH" // WS 推送
+)
+
+// Notification 一条用户通知
+type Notification struct {
+	UserID int64             `json:"user_id"`
+	Kind   Kind              `json:"kind"`
+	Title  string            `json:"title"`
+	Body   string            `json:"body"`
+	Data   map[string]string `json:"data,omitempty"` // 结构化字段 (交易对、金额等)，Webhook / 推送原样下发
+	Time   int64             `json:"time"`           // 事件时间 (Unix 毫秒)
+	Key    string            `json:"key"`            // 幂等键，下游据此去重
+}
+
+// Sink 通知渠道实现
+//
+// Send 在 worker goroutine 里调用，ctx 带 SendTimeout；prefs 为该用户的偏好 (地址、密钥)
+type Sink interface {
+	Channel() Channel
+	Send(ctx context.Context, prefs *Preferences, n *Notification) error
+}
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// RateLimit 令牌桶：最多连发 Burst 条，之后每 Per 恢复一条
+type RateLimit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Config 通知服务配置
+type Config struct {
+	Sinks       []Sink
+	Preferences PreferenceStore // 为空时所有用户按默认偏好
+
+	// RateLimits 每个 (用户, 渠道) 的限流，未配置的渠道用 DefaultRateLimits
+	RateLimits map[Channel]RateLimit
+
+	QueueSize   int           // 入队缓冲 (所有 worker 合计)，默认 4096
+	Workers     int           // 发送 worker 数，默认 4
+	SendTimeout time.Duration // 单次发送超时，默认 5s
+
+	// LargeFillThresholds 大额成交阈值 (按资产，成交金额 >= 阈值才通知)，未配置的资产不通知
+	LargeFillThresholds map[string]int64
+}
+
+// DefaultRateLimits 默认渠道限流
+func DefaultRateLimits() map[Channel]RateLimit {
+	return map[Channel]RateLimit{
+		ChannelEmail:   {Burst: 5, Per: time.Minute},
+		ChannelWebhook: {Burst: 20, Per: time.Second},
+		ChannelPush:    {Burst: 10, Per: time.Second},
+	}
+}
+
+func (c Config) withDefaults() Config {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 4096
+	}
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.SendTimeout <= 0 {
+		c.SendTimeout = 5 * time.Second
+	}
+	limits := DefaultRateLimits()
+	for ch, l := range c.RateLimits {
+		limits[ch] = l
+	}
+	c.RateLimits = limits
+	return c
+}
+
+// Stats 通知统计
+type Stats struct {
+	Queued      int64 // 已入队
+	Sent        int64 // 渠道发送成功 (一条通知发到两个渠道计 2)
+	Dropped     int64 // 队列满 / 已关闭被丢弃
+	RateLimited int64 // 被渠道限流跳过
+	Muted       int64 // 用户关闭了该类型通知
+	Failed      int64 // 读偏好或发送失败
+}
+
+// =============================================================================
+// Service
+// =============================================================================
+
+// Service 通知扇出服务
+type Service struct {
+	cfg     Config
+	sinks   map[Channel]Sink
+	prefs   PreferenceStore
+	limiter *limiter
+	queues  []chan *Notification
+
+	mu     sync.RWMutex // 保护 closed 与队列的关闭
+	closed bool
+	wg     sync.WaitGroup
+
+	queued      atomic.Int64
+	sent        atomic.Int64
+	dropped     atomic.Int64
+	rateLimited atomic.Int64
+	muted       atomic.Int64
+	failed      atomic.Int64
+}
+
+// New 创建并启动通知服务
+func New(cfg Config) *Service {
+	cfg = cfg.withDefaults()
+	s := &Service{
+		cfg:     cfg,
+		sinks:   make(map[Channel]Sink, len(cfg.Sinks)),
+		prefs:   cfg.Preferences,
+		limiter: newLimiter(cfg.RateLimits, time.Now),
+		queues:  make([]chan *Notification, cfg.Workers),
+	}
+	for _, sink := range cfg.Sinks {
+		s.sinks[sink.Channel()] = sink
+	}
+	perWorker := max(cfg.QueueSize/cfg.Workers, 1)
+	for i := range s.queues {
+		s.queues[i] = make(chan *Notification, perWorker)
+		s.wg.Add(1)
+		go s.worker(s.queues[i])
+	}
+	return s
+}
+
+// SetClock 替换限流窗口的时钟，须在 Notify 之前调用
+func (s *Service) SetClock(now func() time.Time) {
+	s.limiter.now = now
+}
+
+// Notify 投递一条通知 (并发安全，不阻塞)
+//
+// 队列满或服务已关闭时丢弃并返回 false
+func (s *Service) Notify(n *Notification) bool {
+	if n.Time == 0 {
+		n.Time = time.Now().UnixMilli()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return false
+	}
+	q := s.queues[uint64(n.UserID)%uint64(len(s.queues))]
+	select {
+	case q <- n:
+		s.queued.Add(1)
+		return true
+	default:
+		s.dropped.Add(1)
+		log.Printf("[Notify] queue full, drop %s for user %d", n.Kind, n.UserID)
+		return false
+	}
+}
+
+// Close 停止接收并发完已入队的通知
+func (s *Service) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		for _, q := range s.queues {
+			close(q)
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Stats 统计
+func (s *Service) Stats() Stats {
+	return Stats{
+		Queued:      s.queued.Load(),
+		Sent:        s.sent.Load(),
+		Dropped:     s.dropped.Load(),
+		RateLimited: s.rateLimited.Load(),
+		Muted:       s.muted.Load(),
+		Failed:      s.failed.Load(),
+	}
+}
+
+func (s *Service) worker(q chan *Notification) {
+	defer s.wg.Done()
+	for n := range q {
+		s.dispatch(n)
+	}
+}
+
+// dispatch 读偏好 → 选渠道 → 限流 → 发送
+func (s *Service) dispatch(n *Notification) {
+	prefs, err := s.preferences(n.UserID)
+	if err != nil {
+		s.failed.Add(1)
+		log.Printf("[Notify] load preferences user=%d: %v", n.UserID, err)
+		return
+	}
+
+	channels := prefs.ChannelsFor(n.Kind)
+	if len(channels) == 0 {
+		s.muted.Add(1)
+		return
+	}
+	for _, ch := range channels {
+		sink, ok := s.sinks[ch]
+		if !ok {
+			continue // 该渠道没有部署
+		}
+		if !s.limiter.allow(n.UserID, ch) {
+			s.rateLimited.Add(1)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.SendTimeout)
+		err := sink.Send(ctx, prefs, n)
+		cancel()
+		if err != nil {
+			s.failed.Add(1)
+			log.Printf("[Notify] send %s via %s user=%d: %v", n.Kind, ch, n.UserID, err)
+			continue
+		}
+		s.sent.Add(1)
+	}
+}
+
+// preferences 读用户偏好，没配置过的用户用默认偏好
+func (s *Service) preferences(userID int64) (*Preferences, error) {
+	if s.prefs == nil {
+		return &Preferences{UserID: userID}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.SendTimeout)
+	defer cancel()
+	prefs, err := s.prefs.Get(ctx, userID)
+	if errors.Is(err, ErrPreferencesNotFound) {
+		return &Preferences{UserID: userID}, nil
+	}
+	return prefs, err
+}
+
+// =============================================================================
+// 限流
+// =============================================================================
+
+type bucketKey struct {
+	userID  int64
+	channel Channel
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter 每个 (用户, 渠道) 一个令牌桶
+//
+// 桶按需创建；回满的桶和新建的桶等价，定期清掉，不活跃用户不占内存
+type limiter struct {
+	limits map[Channel]RateLimit
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// sweepInterval 清理回满令牌桶的间隔
+const sweepInterval = 10 * time.Minute
+
+func newLimiter(limits map[Channel]RateLimit, now func() time.Time) *limiter {
+	return &limiter{limits: limits, now: now, buckets: make(map[bucketKey]*bucket)}
+}
+
+// allow 取一个令牌，没有配置限流的渠道不限
+func (l *limiter) allow(userID int64, ch Channel) bool {
+	limit, ok := l.limits[ch]
+	if !ok || limit.Burst <= 0 || limit.Per <= 0 {
+		return true
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	k := bucketKey{userID: userID, channel: ch}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[k] = b
+	}
+	refill(b, limit, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (l *limiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		limit := l.limits[k.channel]
+		refill(b, limit, now)
+		if b.tokens >= float64(limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+func refill(b *bucket, limit RateLimit, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.tokens+float64(elapsed)/float64(limit.Per), float64(limit.Burst))
+		b.last = now
+	}
+}
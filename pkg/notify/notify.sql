@@ -0,0 +1,11 @@
+-- 用户通知偏好表
+-- 每个用户一条，没有记录的用户按默认渠道通知 (见 notify.DefaultChannels)
+
+CREATE TABLE IF NOT EXISTS `notify_preference` (
+    `user_id` BIGINT NOT NULL PRIMARY KEY,
+    `email` VARCHAR(128) NOT NULL DEFAULT '',
+    `webhook_url` VARCHAR(512) NOT NULL DEFAULT '',
+    `webhook_secret` VARCHAR(128) NOT NULL DEFAULT '' COMMENT 'HMAC-SHA256 签名密钥，空=不签名',
+    `channels` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'JSON: 通知类型 -> 渠道列表，空=全部按默认',
+    `updated_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒'
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户通知偏好';
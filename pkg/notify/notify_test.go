@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+	"max.com/pkg/liquidation"
+	"max.com/pkg/withdrawrisk"
+)
+
+// recordSink 记录收到的通知
+type recordSink struct {
+	ch  Channel
+	mu  sync.Mutex
+	got []Notification
+}
+
+func (s *recordSink) Channel() Channel { return s.ch }
+func (s *recordSink) Send(ctx context.Context, prefs *Preferences, n *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, *n)
+	return nil
+}
+
+func (s *recordSink) kinds(userID int64) []Kind {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Kind
+	for _, n := range s.got {
+		if n.UserID == userID {
+			out = append(out, n.Kind)
+		}
+	}
+	return out
+}
+
+func TestService_DispatchByPreferences(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryPreferenceStore()
+	// 用户 1 填了邮箱，其余按默认；用户 3 关闭资金费通知
+	if err := store.Save(ctx, &Preferences{UserID: 1, Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, &Preferences{UserID: 3, Channels: map[Kind][]Channel{KindFunding: {}}}); err != nil {
+		t.Fatal(err)
+	}
+	push, email := &recordSink{ch: ChannelPush}, &recordSink{ch: ChannelEmail}
+	svc := New(Config{Sinks: []Sink{push, email}, Preferences: store, Workers: 2})
+
+	for _, uid := range []int64{1, 2} {
+		svc.Notify(&Notification{UserID: uid, Kind: KindLiquidationWarning})
+	}
+	svc.Notify(&Notification{UserID: 3, Kind: KindFunding})
+	svc.Close()
+
+	if got := push.kinds(1); len(got) != 1 {
+		t.Fatalf("user 1 push = %v", got)
+	}
+	if got := email.kinds(1); len(got) != 1 {
+		t.Fatalf("user 1 email = %v", got)
+	}
+	// 没填邮箱不发邮件
+	if len(push.kinds(2)) != 1 || len(email.kinds(2)) != 0 {
+		t.Fatalf("user 2 push=%v email=%v", push.kinds(2), email.kinds(2))
+	}
+	st := svc.Stats()
+	if st.Queued != 3 || st.Sent != 3 || st.Muted != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+	if svc.Notify(&Notification{UserID: 1, Kind: KindFunding}) || svc.Stats().Dropped != 1 {
+		t.Fatal("notify after close should be dropped")
+	}
+}
+
+func TestService_RateLimitPerChannel(t *testing.T) {
+	store := NewMemoryPreferenceStore()
+	store.Save(context.Background(), &Preferences{UserID: 1, Email: "a@example.com"})
+	push, email := &recordSink{ch: ChannelPush}, &recordSink{ch: ChannelEmail}
+	svc := New(Config{
+		Sinks:       []Sink{push, email},
+		Preferences: store,
+		Workers:     1,
+		RateLimits:  map[Channel]RateLimit{ChannelEmail: {Burst: 2, Per: time.Minute}},
+	})
+	now := time.Unix(1_700_000_000, 0)
+	var mu sync.Mutex
+	svc.SetClock(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+
+	for i := 0; i < 3; i++ {
+		svc.Notify(&Notification{UserID: 1, Kind: KindWithdrawal})
+	}
+	waitStats(t, svc, func(st Stats) bool { return st.Sent+st.RateLimited == 6 })
+	if len(email.kinds(1)) != 2 || len(push.kinds(1)) != 3 {
+		t.Fatalf("email=%d push=%d, want 2/3", len(email.kinds(1)), len(push.kinds(1)))
+	}
+
+	// 一个周期后恢复一个令牌
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	svc.Notify(&Notification{UserID: 1, Kind: KindWithdrawal})
+	svc.Notify(&Notification{UserID: 1, Kind: KindWithdrawal})
+	svc.Close()
+	if len(email.kinds(1)) != 3 {
+		t.Fatalf("email after refill = %d, want 3", len(email.kinds(1)))
+	}
+	if st := svc.Stats(); st.RateLimited != 2 {
+		t.Fatalf("rate limited = %d, want 2", st.RateLimited)
+	}
+}
+
+func waitStats(t *testing.T, svc *Service, ok func(Stats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !ok(svc.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout, stats %+v", svc.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSources(t *testing.T) {
+	push := &recordSink{ch: ChannelPush}
+	svc := New(Config{
+		Sinks:               []Sink{push},
+		Workers:             1,
+		LargeFillThresholds: map[string]int64{"USDT": 10_000 * futures.Precision},
+	})
+
+	warn := svc.LiquidationWarningHandler()
+	warn(liquidation.LevelChange{UserID: 1, From: liquidation.RiskLevelSafe, To: liquidation.RiskLevelWarning, RiskRatio: 0.72})
+	warn(liquidation.LevelChange{UserID: 1, From: liquidation.RiskLevelDanger, To: liquidation.RiskLevelWarning}) // 降级不通知
+
+	svc.PublishJournal(&fund.JournalEvent{EventID: "trade_1_buyer", UserID: 2, Symbol: "USDT", ChangeType: fund.ChangeTypeTransfer, Amount: 20_000 * futures.Precision, BizType: fund.BizTypeTrade})
+	svc.PublishJournal(&fund.JournalEvent{EventID: "trade_2_buyer", UserID: 2, Symbol: "USDT", ChangeType: fund.ChangeTypeTransfer, Amount: 5_000 * futures.Precision, BizType: fund.BizTypeTrade})
+	svc.PublishJournal(&fund.JournalEvent{EventID: "trade_3_seller", UserID: 2, Symbol: "ETH", ChangeType: fund.ChangeTypeTransfer, Amount: 1_000 * futures.Precision, BizType: fund.BizTypeTrade})
+
+	funding := svc.FundingHandler()
+	funding(&futures.FundingReport{Symbol: "BTC_USDT", FundingRate: 1, Entries: []futures.FundingEntry{
+		{UserID: 3, PositionSize: 2, Applied: -150_000_000},
+		{UserID: 4, Applied: 150_000_000},                     // 收入
+		{UserID: 5, Applied: -1, Err: errors.New("no funds")}, // 落账失败
+		{UserID: 6, Applied: -1, Skipped: true},               // 已落过账
+	}})
+	funding(&futures.FundingReport{DryRun: true, Entries: []futures.FundingEntry{{UserID: 7, Applied: -1}}})
+
+	svc.WithdrawalHandler()(withdrawrisk.DecisionEvent{
+		Request:  withdrawrisk.Request{EventID: "w1", UserID: 8, Asset: "BTC", Amount: 50_000_000},
+		Decision: withdrawrisk.DecisionHold,
+	})
+
+	autoMargin := svc.AutoMarginHandler()
+	autoMargin(futures.AutoMarginEvent{UserID: 9, Symbol: "BTC_USDT", Currency: "USDT", Amount: 25 * futures.Precision, MarginAfter: 125 * futures.Precision, Level: liquidation.RiskLevelDanger, At: 1})
+	autoMargin(futures.AutoMarginEvent{UserID: 10, Symbol: "BTC_USDT", Level: liquidation.RiskLevelDanger, At: 1, Err: futures.ErrAutoMarginCapReached})
+	svc.Close()
+
+	want := map[int64]Kind{1: KindLiquidationWarning, 2: KindLargeFill, 3: KindFunding, 8: KindWithdrawal, 9: KindAutoMargin, 10: KindAutoMargin}
+	if len(push.got) != len(want) {
+		t.Fatalf("got %d notifications: %+v", len(push.got), push.got)
+	}
+	for _, n := range push.got {
+		if want[n.UserID] != n.Kind {
+			t.Errorf("user %d kind %s", n.UserID, n.Kind)
+		}
+		switch n.UserID {
+		case 3:
+			if n.Data["amount"] != "1.5" || n.Data["funding_rate"] != "0.01%" {
+				t.Errorf("funding data %v", n.Data)
+			}
+		case 8:
+			if n.Data["amount"] != "0.5" || n.Data["status"] != "HOLD" {
+				t.Errorf("withdrawal data %v", n.Data)
+			}
+		case 9:
+			if n.Data["amount"] != "25" || n.Data["margin"] != "125" || n.Data["error"] != "" {
+				t.Errorf("auto margin data %v", n.Data)
+			}
+		case 10:
+			if n.Data["error"] == "" || n.Data["amount"] != "" {
+				t.Errorf("auto margin failure data %v", n.Data)
+			}
+		}
+	}
+}
+
+func TestWebhookSink_Signature(t *testing.T) {
+	var gotSig, gotTS, gotKey string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig, gotTS, gotKey = r.Header.Get("X-Notify-Signature"), r.Header.Get("X-Notify-Timestamp"), r.Header.Get("X-Notify-Key")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	sink := &WebhookSink{Client: srv.Client()}
+	prefs := &Preferences{UserID: 1, WebhookURL: srv.URL, WebhookSecret: "s3cret"}
+	if err := sink.Send(context.Background(), prefs, &Notification{UserID: 1, Kind: KindFunding, Key: "k1"}); err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := strconv.ParseInt(gotTS, 10, 64)
+	if gotKey != "k1" || gotSig == "" || gotSig != SignWebhook("s3cret", ts, body) {
+		t.Fatalf("key=%q sig=%q", gotKey, gotSig)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	prefs.WebhookURL = failing.URL
+	if err := sink.Send(context.Background(), prefs, &Notification{UserID: 1}); err == nil {
+		t.Fatal("non-2xx should fail")
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	p := &Preferences{
+		UserID:     1,
+		WebhookURL: "https://hooks.example.com/cex",
+		Channels:   map[Kind][]Channel{KindLargeFill: {ChannelWebhook, ChannelEmail, ChannelWebhook}},
+	}
+	// 没填邮箱的渠道去掉，重复渠道去重；没配置的类型按默认
+	if got := p.ChannelsFor(KindLargeFill); len(got) != 1 || got[0] != ChannelWebhook {
+		t.Fatalf("large fill channels %v", got)
+	}
+	if got := p.ChannelsFor(KindFunding); len(got) != 1 || got[0] != ChannelPush {
+		t.Fatalf("funding channels %v", got)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Channels[KindFunding] = []Channel{"SMS"}
+	if err := p.Validate(); !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("unknown channel: %v", err)
+	}
+	delete(p.Channels, KindFunding)
+	p.WebhookURL = "ftp://x"
+	if err := NewMemoryPreferenceStore().Save(context.Background(), p); !errors.Is(err, ErrInvalidWebhookURL) {
+		t.Fatalf("bad webhook url: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// =============================================================================
+// 用户偏好
+// =============================================================================
+
+// Preferences 用户通知偏好
+type Preferences struct {
+	UserID        int64
+	Email         string
+	WebhookURL    string
+	WebhookSecret string // Webhook 签名密钥，空表示不签名
+
+	// Channels 每种通知发到哪些渠道：没配置的类型按 DefaultChannels，配置为空表示关闭该类型
+	Channels  map[Kind][]Channel
+	UpdatedAt int64 // 毫秒
+}
+
+// DefaultChannels 没配置偏好时的渠道
+//
+// 强平预警、提现结果、自动追加保证金关系到资金安全，推送 + 邮件；成交、资金费量大，只推送。
+// Webhook 要用户自己填地址，只在显式配置后发送
+func DefaultChannels(kind Kind) []Channel {
+	switch kind {
+	case KindLiquidationWarning, KindWithdrawal, KindAutoMargin:
+		return []Channel{ChannelPush, ChannelEmail}
+	}
+	return []Channel{ChannelPush}
+}
+
+// ChannelsFor 该类型通知实际要发的渠道 (去掉没填地址的邮件 / Webhook)
+func (p *Preferences) ChannelsFor(kind Kind) []Channel {
+	channels, ok := p.Channels[kind]
+	if !ok {
+		channels = DefaultChannels(kind)
+	}
+	out := make([]Channel, 0, len(channels))
+	for _, ch := range channels {
+		switch {
+		case ch == ChannelEmail && p.Email == "",
+			ch == ChannelWebhook && p.WebhookURL == "",
+			slices.Contains(out, ch):
+			continue
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// Validate 保存前校验
+func (p *Preferences) Validate() error {
+	for _, channels := range p.Channels {
+		for _, ch := range channels {
+			switch ch {
+			case ChannelEmail, ChannelWebhook, ChannelPush:
+			default:
+				return ErrUnknownChannel.Wrapf("channel %q", ch)
+			}
+		}
+	}
+	if p.WebhookURL != "" {
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return ErrInvalidWebhookURL.Wrapf("%q", p.WebhookURL)
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// 存储接口
+// =============================================================================
+
+// PreferenceStore 偏好存储
+type PreferenceStore interface {
+	// Get 按用户查询，没配置过返回 ErrPreferencesNotFound
+	Get(ctx context.Context, userID int64) (*Preferences, error)
+	// Save 整条覆盖写入
+	Save(ctx context.Context, prefs *Preferences) error
+}
+
+// =============================================================================
+// MemoryPreferenceStore
+// =============================================================================
+
+// MemoryPreferenceStore 内存存储 (测试、单机部署)
+type MemoryPreferenceStore struct {
+	mu    sync.RWMutex
+	prefs map[int64]*Preferences
+}
+
+// NewMemoryPreferenceStore 创建内存存储
+func NewMemoryPreferenceStore() *MemoryPreferenceStore {
+	return &MemoryPreferenceStore{prefs: make(map[int64]*Preferences)}
+}
+
+// Get 实现 PreferenceStore
+func (s *MemoryPreferenceStore) Get(ctx context.Context, userID int64) (*Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.prefs[userID]
+	if !ok {
+		return nil, ErrPreferencesNotFound
+	}
+	return p.clone(), nil
+}
+
+// Save 实现 PreferenceStore
+func (s *MemoryPreferenceStore) Save(ctx context.Context, prefs *Preferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs[prefs.UserID] = prefs.clone()
+	return nil
+}
+
+func (p *Preferences) clone() *Preferences {
+	out := *p
+	if p.Channels != nil {
+		out.Channels = make(map[Kind][]Channel, len(p.Channels))
+		for k, v := range p.Channels {
+			out.Channels[k] = slices.Clone(v)
+		}
+	}
+	return &out
+}
+
+// =============================================================================
+// GormPreferenceStore
+// =============================================================================
+
+// preferenceRecord 偏好表 (见 notify.sql)，Channels 存 JSON
+type preferenceRecord struct {
+	UserID        int64  `gorm:"column:user_id;primaryKey"`
+	Email         string `gorm:"column:email"`
+	WebhookURL    string `gorm:"column:webhook_url"`
+	WebhookSecret string `gorm:"column:webhook_secret"`
+	Channels      string `gorm:"column:channels"`
+	UpdatedAt     int64  `gorm:"column:updated_at"`
+}
+
+func (preferenceRecord) TableName() string { return "notify_preference" }
+
+// GormPreferenceStore MySQL 存储
+type GormPreferenceStore struct {
+	db *gorm.DB
+}
+
+// NewGormPreferenceStore 创建 MySQL 存储
+func NewGormPreferenceStore(db *gorm.DB) *GormPreferenceStore {
+	return &GormPreferenceStore{db: db}
+}
+
+// Get 实现 PreferenceStore
+func (s *GormPreferenceStore) Get(ctx context.Context, userID int64) (*Preferences, error) {
+	var r preferenceRecord
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Take(&r).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrPreferencesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &Preferences{
+		UserID:        r.UserID,
+		Email:         r.Email,
+		WebhookURL:    r.WebhookURL,
+		WebhookSecret: r.WebhookSecret,
+		UpdatedAt:     r.UpdatedAt,
+	}
+	if r.Channels != "" {
+		if err := json.Unmarshal([]byte(r.Channels), &p.Channels); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Save 实现 PreferenceStore
+func (s *GormPreferenceStore) Save(ctx context.Context, prefs *Preferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+	channels := ""
+	if prefs.Channels != nil {
+		raw, err := json.Marshal(prefs.Channels)
+		if err != nil {
+			return err
+		}
+		channels = string(raw)
+	}
+	r := preferenceRecord{
+		UserID:        prefs.UserID,
+		Email:         prefs.Email,
+		WebhookURL:    prefs.WebhookURL,
+		WebhookSecret: prefs.WebhookSecret,
+		Channels:      channels,
+		UpdatedAt:     prefs.UpdatedAt,
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&r).Error
+}
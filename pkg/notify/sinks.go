@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Webhook
+// =============================================================================
+//
+// POST 通知 JSON 到用户配置的地址，请求头：
+//
+//	X-Notify-Key        幂等键 (Notification.Key)，重复投递时接收方据此去重
+//	X-Notify-Timestamp  发送时间 (Unix 毫秒)
+//	X-Notify-Signature  hex(HMAC-SHA256(secret, timestamp + "\n" + body))，用户配置了密钥时才有
+//
+// 接收方用 SignWebhook 重算签名并检查时间戳，防伪造和重放
+
+// WebhookSink Webhook 渠道
+type WebhookSink struct {
+	Client *http.Client // 为空使用 http.DefaultClient
+}
+
+// Channel 实现 Sink
+func (s *WebhookSink) Channel() Channel { return ChannelWebhook }
+
+// Send 实现 Sink，非 2xx 视为失败
+func (s *WebhookSink) Send(ctx context.Context, prefs *Preferences, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prefs.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().UnixMilli()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notify-Key", n.Key)
+	req.Header.Set("X-Notify-Timestamp", strconv.FormatInt(ts, 10))
+	if prefs.WebhookSecret != "" {
+		req.Header.Set("X-Notify-Signature", SignWebhook(prefs.WebhookSecret, ts, body))
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // 读完才能复用连接
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify: webhook %s: HTTP %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhook 计算 Webhook 签名 (接收方验签用同一个函数)
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// =============================================================================
+// 邮件
+// =============================================================================
+
+// Mailer 发信 (SMTP / 第三方邮件服务)
+type Mailer interface {
+	SendMail(ctx context.Context, to, subject, body string) error
+}
+
+// EmailSink 邮件渠道
+type EmailSink struct {
+	Mailer Mailer
+}
+
+// Channel 实现 Sink
+func (s *EmailSink) Channel() Channel { return ChannelEmail }
+
+// Send 实现 Sink
+func (s *EmailSink) Send(ctx context.Context, prefs *Preferences, n *Notification) error {
+	return s.Mailer.SendMail(ctx, prefs.Email, n.Title, n.Body)
+}
+
+// SMTPMailer 直连 SMTP 发信
+//
+// 【注意】net/smtp 不支持 ctx，超时由 SMTP 服务器连接本身决定；量大时请换第三方邮件服务
+type SMTPMailer struct {
+	Addr string    // host:port
+	From string    // 发件人地址
+	Auth smtp.Auth // 为空表示不认证
+}
+
+// SendMail 实现 Mailer
+func (m *SMTPMailer) SendMail(ctx context.Context, to, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body)
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg.String()))
+}
+
+// =============================================================================
+// 推送
+// =============================================================================
+
+// SubjectPublisher 消息总线发布 (nats.Publisher)
+type SubjectPublisher interface {
+	Publish(subject string, data any) error
+}
+
+// PushSink WS 推送渠道
+//
+// 发布到 {SubjectPrefix}.{userID}，WS 网关订阅在线用户的主题转发给客户端；
+// 用户不在线时消息直接丢弃，推送只管实时提醒
+type PushSink struct {
+	Publisher     SubjectPublisher
+	SubjectPrefix string // 默认 "notify.user"
+}
+
+// Channel 实现 Sink
+func (s *PushSink) Channel() Channel { return ChannelPush }
+
+// Send 实现 Sink
+func (s *PushSink) Send(ctx context.Context, prefs *Preferences, n *Notification) error {
+	prefix := s.SubjectPrefix
+	if prefix == "" {
+		prefix = "notify.user"
+	}
+	return s.Publisher.Publish(prefix+"."+strconv.FormatInt(n.UserID, 10), n)
+}
@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+	"max.com/pkg/liquidation"
+	"max.com/pkg/withdrawrisk"
+)
+
+// =============================================================================
+// 事件来源适配
+// =============================================================================
+//
+// 接线示例：
+//
+//	svc := notify.New(notify.Config{
+//		Sinks:               []notify.Sink{&notify.PushSink{Publisher: natsPublisher}, &notify.EmailSink{Mailer: mailer}, &notify.WebhookSink{}},
+//		Preferences:         notify.NewGormPreferenceStore(db),
+//		LargeFillThresholds: map[string]int64{"USDT": 100_000 * asset.Precision, "BTC": 2 * asset.Precision},
+//	})
+//	liquidationEngine.OnLevelChange(svc.LiquidationWarningHandler())
+//	spot.NewSpotProcessor(spot.ProcessorConfig{Publisher: eventlog.TeeJournals(kafkaPublisher, svc), ...})
+//	fundingService.OnSettled(svc.FundingHandler())
+//	withdrawRisk.OnDecision(svc.WithdrawalHandler())
+//	autoMargin.OnTopUp(svc.AutoMarginHandler())
+
+// LiquidationWarningHandler 风险等级升高到预警及以上 → LIQUIDATION_WARNING
+//
+// 等级降低 (风险解除) 不通知
+func (s *Service) LiquidationWarningHandler() func(liquidation.LevelChange) {
+	return func(c liquidation.LevelChange) {
+		if !c.Escalated() || c.To < liquidation.RiskLevelWarning {
+			return
+		}
+		s.Notify(&Notification{
+			UserID: c.UserID,
+			Kind:   KindLiquidationWarning,
+			Title:  "Risk level " + c.To.String(),
+			Body: fmt.Sprintf("Your account risk ratio reached %.2f%% (%s -> %s). Add margin or reduce positions to avoid liquidation.",
+				c.RiskRatio*100, c.From, c.To),
+			Data: map[string]string{
+				"from":       c.From.String(),
+				"to":         c.To.String(),
+				"risk_ratio": strconv.FormatFloat(c.RiskRatio, 'f', 4, 64),
+			},
+			Time: c.At,
+			Key:  fmt.Sprintf("liq_warn_%d_%d_%d", c.UserID, c.To, c.At),
+		})
+	}
+}
+
+// PublishJournal 成交流水 → LARGE_FILL (实现 spot.JournalPublisher，可与 Kafka 发布器 Tee)
+//
+// 只看成交的交割流水 (TRADE + TRANSFER)，金额达到该资产的 LargeFillThresholds 才通知；
+// 永远返回 nil，通知失败不影响流水发布
+func (s *Service) PublishJournal(e *fund.JournalEvent) error {
+	if e.BizType != fund.BizTypeTrade || e.ChangeType != fund.ChangeTypeTransfer {
+		return nil
+	}
+	threshold, ok := s.cfg.LargeFillThresholds[e.Symbol]
+	if !ok || e.Amount < threshold {
+		return nil
+	}
+	amount := formatAmount(e.Amount)
+	s.Notify(&Notification{
+		UserID: e.UserID,
+		Kind:   KindLargeFill,
+		Title:  "Large trade filled",
+		Body:   fmt.Sprintf("Trade %s settled %s %s.", e.BizID, amount, e.Symbol),
+		Data: map[string]string{
+			"trade_id": e.BizID,
+			"asset":    e.Symbol,
+			"amount":   amount,
+		},
+		Time: e.CreatedAt.UnixMilli(),
+		Key:  e.EventID,
+	})
+	return nil
+}
+
+// FundingHandler 资金费结算 → 每个实际扣款的持仓一条 FUNDING
+//
+// dry-run、收入、落账失败、续跑时已落过账的明细都不通知
+func (s *Service) FundingHandler() func(*futures.FundingReport) {
+	return func(r *futures.FundingReport) {
+		if r.DryRun {
+			return
+		}
+		for _, e := range r.Entries {
+			if e.Applied >= 0 || e.Err != nil || e.Skipped {
+				continue
+			}
+			charged := formatAmount(-e.Applied)
+			s.Notify(&Notification{
+				UserID: e.UserID,
+				Kind:   KindFunding,
+				Title:  "Funding fee charged",
+				Body:   fmt.Sprintf("%s funding fee charged on %s (rate %s).", charged, r.Symbol, formatRate(r.FundingRate)),
+				Data: map[string]string{
+					"symbol":        r.Symbol,
+					"amount":        charged,
+					"funding_rate":  formatRate(r.FundingRate),
+					"position_size": strconv.FormatInt(e.PositionSize, 10),
+				},
+				Time: r.FundingTime,
+				Key:  fmt.Sprintf("funding_%s_%d_%d", r.Symbol, r.FundingTime, e.UserID),
+			})
+		}
+	}
+}
+
+// WithdrawalHandler 提现风控决策 → WITHDRAWAL
+func (s *Service) WithdrawalHandler() func(withdrawrisk.DecisionEvent) {
+	return func(ev withdrawrisk.DecisionEvent) {
+		var status string
+		switch ev.Decision {
+		case withdrawrisk.DecisionApprove:
+			status = "approved"
+		case withdrawrisk.DecisionHold:
+			status = "under review"
+		case withdrawrisk.DecisionDeny:
+			status = "rejected"
+		default:
+			return
+		}
+		req := ev.Request
+		amount := formatAmount(req.Amount)
+		s.Notify(&Notification{
+			UserID: req.UserID,
+			Kind:   KindWithdrawal,
+			Title:  "Withdrawal " + status,
+			Body:   fmt.Sprintf("Your withdrawal of %s %s is %s.", amount, req.Asset, status),
+			Data: map[string]string{
+				"withdrawal_id": req.EventID,
+				"asset":         req.Asset,
+				"amount":        amount,
+				"status":        ev.Decision.String(),
+			},
+			Time: ev.At,
+			Key:  fmt.Sprintf("withdraw_%s_%s", req.EventID, ev.Decision),
+		})
+	}
+}
+
+// AutoMarginHandler 自动追加保证金 → AUTO_MARGIN
+//
+// 失败 (额度用完、余额不足) 也通知：用户以为开了自动追加就安全了，追加不上必须让他知道
+func (s *Service) AutoMarginHandler() func(futures.AutoMarginEvent) {
+	return func(e futures.AutoMarginEvent) {
+		data := map[string]string{
+			"symbol":     e.Symbol,
+			"level":      e.Level.String(),
+			"risk_ratio": strconv.FormatFloat(e.RiskRatio, 'f', 4, 64),
+		}
+		n := &Notification{
+			UserID: e.UserID,
+			Kind:   KindAutoMargin,
+			Data:   data,
+			Time:   e.At,
+			Key:    fmt.Sprintf("auto_margin_%d_%s_%d", e.UserID, e.Symbol, e.At),
+		}
+		if e.Err != nil {
+			data["error"] = e.Err.Error()
+			n.Title = "Auto margin top-up failed"
+			n.Body = fmt.Sprintf("Your %s position reached %s risk but margin could not be added automatically. Please add margin or reduce the position.", e.Symbol, e.Level)
+		} else {
+			amount := formatAmount(e.Amount)
+			data["amount"] = amount
+			data["currency"] = e.Currency
+			data["margin"] = formatAmount(e.MarginAfter)
+			n.Title = "Margin added automatically"
+			n.Body = fmt.Sprintf("%s %s was added to your %s position margin (now %s).", amount, e.Currency, e.Symbol, data["margin"])
+		}
+		s.Notify(n)
+	}
+}
+
+// formatAmount 定点数金额 (1e8) 转十进制字符串，去掉末尾的 0
+func formatAmount(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := fmt.Sprintf("%s%d.%08d", sign, v/futures.Precision, v%futures.Precision)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// formatRate 万分比 → 百分比字符串
+func formatRate(rate int64) string {
+	return strconv.FormatFloat(float64(rate)*100/futures.FundingPrecision, 'f', -1, 64) + "%"
+}
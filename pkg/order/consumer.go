@@ -1,6 +1,6 @@
 // 文件: pkg/order/consumer.go
 // 订单事件消费者 - 监听撮合引擎事件，更新订单状态
-// 使用 NATS (轻量级替代 Kafka)
+// 使用 NATS (轻量级替代 Kafka)，同一订单的事件按撮合序号重排 (见 sequencer.go)
 
 package order
 
@@ -8,6 +8,9 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"max.com/pkg/nats"
 )
@@ -24,6 +27,11 @@ type TradeEvent struct {
 	Price     int64 `json:"price"`
 	Qty       int64 `json:"qty"`
 	Timestamp int64 `json:"timestamp"`
+
+	// 双方订单的事件序号 (mtrade.EventSeq)，0 表示发布方不带序号
+	Session  int64  `json:"seq_session"`
+	TakerSeq uint64 `json:"taker_seq"`
+	MakerSeq uint64 `json:"maker_seq"`
 }
 
 // CancelEvent 撤单事件
@@ -31,6 +39,8 @@ type CancelEvent struct {
 	OrderID   int64  `json:"order_id"`
 	Reason    string `json:"reason"`
 	Timestamp int64  `json:"timestamp"`
+	Session   int64  `json:"seq_session"`
+	Seq       uint64 `json:"seq"`
 }
 
 // =============================================================================
@@ -40,6 +50,13 @@ type CancelEvent struct {
 type OrderConsumer struct {
 	service    *OrderService
 	subscriber *nats.Subscriber
+
+	// 消费端去重 (可选)：NATS 重投的成交不会把订单成交量加两次
+	deduper    nats.Deduper
+	duplicates atomic.Int64
+
+	// 按订单事件序号重排 (见 sequencer.go)
+	seq orderSequencer
 }
 
 // NewOrderConsumer 创建订单消费者
@@ -55,6 +72,42 @@ func NewOrderConsumer(service *OrderService, natsURL string) (*OrderConsumer, er
 	return oc, nil
 }
 
+// EnableJetStream 改用 JetStream 持久化消费者 (nats.MatchEventStream)，需在 Start 之前调用
+//
+// 服务重启 / 重新部署期间的成交和撤单留在流里，启动后从上次确认的位置补上；
+// cfg.Consumer.StartSeq 可指定从某个流序号重放。重投和重放的事件由 Deduper 和事件序号去重
+func (c *OrderConsumer) EnableJetStream(cfg nats.JetStreamConfig) error {
+	if len(cfg.Streams) == 0 {
+		cfg.Streams = []nats.StreamConfig{nats.MatchEventStream()}
+	}
+	return c.subscriber.EnableJetStream(cfg)
+}
+
+// SetDeduper 设置消费端去重，多实例部署时用共享存储 (nats.RedisDeduper)
+func (c *OrderConsumer) SetDeduper(d nats.Deduper) {
+	c.deduper = d
+}
+
+// Duplicates 被去重跳过的事件数
+func (c *OrderConsumer) Duplicates() int64 {
+	return c.duplicates.Load()
+}
+
+// SetReorderWindow 乱序事件最多暂存多久，默认 DefaultReorderWindow，需在 Start 之前调用
+func (c *OrderConsumer) SetReorderWindow(d time.Duration) {
+	c.seq.window = d
+}
+
+// OutOfSequence 序号重复或会话过期而丢弃的事件数
+func (c *OrderConsumer) OutOfSequence() int64 {
+	return c.seq.dropped.Load()
+}
+
+// Reordered 提前到达、暂存后按序应用的事件数
+func (c *OrderConsumer) Reordered() int64 {
+	return c.seq.reordered.Load()
+}
+
 // Start 启动消费 (队列订阅，支持多实例负载均衡)
 func (c *OrderConsumer) Start() error {
 	// 订阅成交事件
@@ -73,6 +126,21 @@ func (c *OrderConsumer) Stop() error {
 	return c.subscriber.Close()
 }
 
+// Pause 维护模式：停止拉取新事件，等已收到和正在处理的事件处理完 (见 nats.Subscriber.Pause)
+func (c *OrderConsumer) Pause(ctx context.Context) error {
+	return c.subscriber.Pause(ctx)
+}
+
+// Resume 退出维护模式，重新订阅
+func (c *OrderConsumer) Resume() error {
+	return c.subscriber.Resume()
+}
+
+// Drained 是否已暂停且排空
+func (c *OrderConsumer) Drained() bool {
+	return c.subscriber.Drained()
+}
+
 // handleMessage 处理消息
 func (c *OrderConsumer) handleMessage(subject string, data []byte) error {
 	ctx := context.Background()
@@ -94,19 +162,35 @@ func (c *OrderConsumer) handleTradeEvent(ctx context.Context, data []byte) error
 		return err
 	}
 
-	// 更新 Taker 订单
-	if err := c.service.OnTradeFill(ctx, event.TakerID, event.Qty, event.Price); err != nil {
+	// Taker / Maker 各自排序、去重：一边失败重投时，已更新的另一边不会再加一次
+	tradeID := strconv.FormatInt(event.TradeID, 10)
+	if err := c.seq.admit(event.TakerID, event.Session, event.TakerSeq, func() error {
+		return c.once(ctx, "trades", tradeID+":taker", func() error {
+			return c.service.OnTradeFill(ctx, event.TakerID, event.Qty, event.Price)
+		})
+	}); err != nil {
 		log.Printf("update taker order error: %v", err)
 	}
-
-	// 更新 Maker 订单
-	if err := c.service.OnTradeFill(ctx, event.MakerID, event.Qty, event.Price); err != nil {
+	if err := c.seq.admit(event.MakerID, event.Session, event.MakerSeq, func() error {
+		return c.once(ctx, "trades", tradeID+":maker", func() error {
+			return c.service.OnTradeFill(ctx, event.MakerID, event.Qty, event.Price)
+		})
+	}); err != nil {
 		log.Printf("update maker order error: %v", err)
 	}
 
 	return nil
 }
 
+// once 按 (subject, eventID) 去重执行
+func (c *OrderConsumer) once(ctx context.Context, subject, eventID string, fn func() error) error {
+	handled, err := nats.HandleOnce(ctx, c.deduper, subject, eventID, fn)
+	if !handled && err == nil {
+		c.duplicates.Add(1)
+	}
+	return err
+}
+
 // handleCancelEvent 处理撤单事件
 func (c *OrderConsumer) handleCancelEvent(ctx context.Context, data []byte) error {
 	var event CancelEvent
@@ -115,5 +199,9 @@ func (c *OrderConsumer) handleCancelEvent(ctx context.Context, data []byte) erro
 		return err
 	}
 
-	return c.service.OnOrderCanceled(ctx, event.OrderID)
+	return c.seq.admit(event.OrderID, event.Session, event.Seq, func() error {
+		return c.once(ctx, "order.canceled", strconv.FormatInt(event.OrderID, 10), func() error {
+			return c.service.OnOrderCanceled(ctx, event.OrderID)
+		})
+	})
 }
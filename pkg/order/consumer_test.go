@@ -0,0 +1,196 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"max.com/pkg/nats"
+)
+
+// memRepo 内存订单仓储
+type memRepo struct {
+	mu     sync.Mutex
+	orders map[int64]*Order
+}
+
+func (r *memRepo) Create(_ context.Context, o *Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *o
+	r.orders[o.OrderID] = &cp
+	return nil
+}
+
+func (r *memRepo) GetByOrderID(_ context.Context, orderID int64) (*Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[orderID]
+	if !ok {
+		return nil, nil
+	}
+	cp := *o
+	return &cp, nil
+}
+
+func (r *memRepo) GetActiveByUser(context.Context, int64) ([]*Order, error) { return nil, nil }
+
+func (r *memRepo) GetByUserAndSymbol(context.Context, int64, string, int) ([]*Order, error) {
+	return nil, nil
+}
+
+func (r *memRepo) UpdateFill(_ context.Context, orderID int64, prevFilledQty, filledQty, avgPrice int64, status OrderStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[orderID]
+	if !ok || o.FilledQty != prevFilledQty || filledQty <= o.FilledQty {
+		return false, nil
+	}
+	o.FilledQty, o.AvgPrice, o.Status = filledQty, avgPrice, status
+	return true, nil
+}
+
+func (r *memRepo) UpdateStatus(_ context.Context, orderID int64, status OrderStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if o, ok := r.orders[orderID]; ok && o.IsActive() {
+		o.Status = status
+	}
+	return nil
+}
+
+func newTestConsumer(t *testing.T) (*OrderConsumer, *memRepo) {
+	t.Helper()
+	repo := &memRepo{orders: make(map[int64]*Order)}
+	svc := NewOrderService(repo)
+	ctx := context.Background()
+	svc.CreateOrder(ctx, NewOrder(1, 10, "BTC_USDT", ProductSpot, SideBuy, OrderTypeLimit, 100, 10))
+	svc.CreateOrder(ctx, NewOrder(2, 20, "BTC_USDT", ProductSpot, SideSell, OrderTypeLimit, 100, 4))
+	c := &OrderConsumer{service: svc}
+	c.SetDeduper(nats.NewMemoryDeduper(nats.DedupConfig{Consumer: "order-service"}))
+	return c, repo
+}
+
+func TestOrderConsumer_ReplayedTradeAppliedOnce(t *testing.T) {
+	c, repo := newTestConsumer(t)
+	trade, _ := json.Marshal(TradeEvent{TradeID: 99, TakerID: 1, MakerID: 2, Price: 100, Qty: 4})
+
+	for range 2 {
+		if err := c.handleMessage("trades", trade); err != nil {
+			t.Fatal(err)
+		}
+	}
+	taker, _ := repo.GetByOrderID(context.Background(), 1)
+	maker, _ := repo.GetByOrderID(context.Background(), 2)
+	if taker.FilledQty != 4 || taker.Status != StatusPartiallyFilled {
+		t.Fatalf("taker %+v", taker)
+	}
+	if maker.FilledQty != 4 || maker.Status != StatusFilled {
+		t.Fatalf("maker %+v", maker)
+	}
+	if c.Duplicates() != 2 {
+		t.Fatalf("duplicates = %d, want 2", c.Duplicates())
+	}
+
+	// 撤单不覆盖终态
+	cancel, _ := json.Marshal(CancelEvent{OrderID: 2})
+	c.handleMessage("order.canceled", cancel)
+	if maker, _ := repo.GetByOrderID(context.Background(), 2); maker.Status != StatusFilled {
+		t.Fatalf("canceled a filled order: %+v", maker)
+	}
+}
+
+func TestOrderService_OnTradeFill_Conditional(t *testing.T) {
+	c, repo := newTestConsumer(t)
+	svc, ctx := c.service, context.Background()
+
+	// 去重记录过期后的重投：超过委托量直接拒绝，不会写入
+	svc.OnTradeFill(ctx, 2, 4, 100)
+	if err := svc.OnTradeFill(ctx, 2, 4, 100); !errors.Is(err, ErrOverfill) {
+		t.Fatalf("overfill: %v", err)
+	}
+	if o, _ := repo.GetByOrderID(ctx, 2); o.FilledQty != 4 {
+		t.Fatalf("filled %d, want 4", o.FilledQty)
+	}
+
+	// 并发成交：条件更新冲突后重读重试，成交量不丢
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if err := svc.OnTradeFill(ctx, 1, 2, 100); !errors.Is(err, ErrFillConflict) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if o, _ := repo.GetByOrderID(ctx, 1); o.FilledQty != 10 || o.Status != StatusFilled {
+		t.Fatalf("concurrent fills: %+v", o)
+	}
+
+	if err := svc.OnTradeFill(ctx, 404, 1, 100); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("missing order: %v", err)
+	}
+}
+
+func TestOrderConsumer_SequencesOrderEvents(t *testing.T) {
+	c, repo := newTestConsumer(t)
+	c.SetReorderWindow(20 * time.Millisecond)
+	ctx := context.Background()
+	send := func(subject string, v any) {
+		t.Helper()
+		data, _ := json.Marshal(v)
+		if err := c.handleMessage(subject, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 撤单 (序号 3) 先到：暂存，等成交 (序号 2) 到了依次应用
+	send("order.canceled", CancelEvent{OrderID: 1, Session: 5, Seq: 3})
+	if o, _ := repo.GetByOrderID(ctx, 1); o.Status != StatusNew {
+		t.Fatalf("cancel applied before earlier fill: %+v", o)
+	}
+	send("trades", TradeEvent{TradeID: 7, TakerID: 1, MakerID: 2, Price: 100, Qty: 4, Session: 5, TakerSeq: 2, MakerSeq: 2})
+	if o, _ := repo.GetByOrderID(ctx, 1); o.FilledQty != 4 || o.Status != StatusCanceled {
+		t.Fatalf("after reorder: %+v", o)
+	}
+	if c.Reordered() != 1 {
+		t.Fatalf("reordered = %d, want 1", c.Reordered())
+	}
+
+	// 重复序号、旧会话直接丢弃
+	send("trades", TradeEvent{TradeID: 8, TakerID: 1, MakerID: 2, Price: 100, Qty: 1, Session: 5, TakerSeq: 2, MakerSeq: 2})
+	send("order.canceled", CancelEvent{OrderID: 1, Session: 4, Seq: 9})
+	if c.OutOfSequence() != 3 {
+		t.Fatalf("out of sequence = %d, want 3", c.OutOfSequence())
+	}
+	if o, _ := repo.GetByOrderID(ctx, 1); o.FilledQty != 4 {
+		t.Fatalf("duplicate seq applied: %+v", o)
+	}
+
+	// 空洞一直补不上：窗口过后跳过空洞照常应用
+	svc := c.service
+	svc.CreateOrder(ctx, NewOrder(3, 30, "BTC_USDT", ProductSpot, SideBuy, OrderTypeLimit, 100, 10))
+	send("trades", TradeEvent{TradeID: 9, TakerID: 3, Price: 100, Qty: 2, Session: 5, TakerSeq: 3})
+	deadline := time.Now().Add(time.Second)
+	for {
+		if o, _ := repo.GetByOrderID(ctx, 3); o.FilledQty == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("held event never released")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 被跳过的序号迟到：照常应用
+	send("trades", TradeEvent{TradeID: 10, TakerID: 3, Price: 100, Qty: 1, Session: 5, TakerSeq: 2})
+	if o, _ := repo.GetByOrderID(ctx, 3); o.FilledQty != 3 {
+		t.Fatalf("late event dropped: %+v", o)
+	}
+}
@@ -6,23 +6,40 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"max.com/pkg/dbroute"
 )
 
 type MySQLOrderRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	router *dbroute.Router // 读写分离，nil 表示全部走 db
 }
 
 func NewMySQLOrderRepository(db *gorm.DB) *MySQLOrderRepository {
 	return &MySQLOrderRepository{db: db}
 }
 
+// SetRouter 启用读写分离：ctx 标记了 dbroute.ReadOnly 的查询读从库，写始终走主库
+//
+// 成交回调里的查询不带标记，仍读主库 (条件更新依赖最新的 filled_qty)
+func (r *MySQLOrderRepository) SetRouter(router *dbroute.Router) {
+	r.router = router
+}
+
+func (r *MySQLOrderRepository) reader(ctx context.Context) *gorm.DB {
+	if r.router == nil {
+		return r.db
+	}
+	return r.router.DB(ctx)
+}
+
 func (r *MySQLOrderRepository) Create(ctx context.Context, order *Order) error {
 	return r.db.WithContext(ctx).Create(order).Error
 }
 
 func (r *MySQLOrderRepository) GetByOrderID(ctx context.Context, orderID int64) (*Order, error) {
 	var order Order
-	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).First(&order).Error
+	err := r.reader(ctx).WithContext(ctx).Where("order_id = ?", orderID).First(&order).Error
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +48,7 @@ func (r *MySQLOrderRepository) GetByOrderID(ctx context.Context, orderID int64)
 
 func (r *MySQLOrderRepository) GetActiveByUser(ctx context.Context, userID int64) ([]*Order, error) {
 	var orders []*Order
-	err := r.db.WithContext(ctx).
+	err := r.reader(ctx).WithContext(ctx).
 		Where("user_id = ? AND status IN ?", userID, []OrderStatus{StatusNew, StatusPartiallyFilled}).
 		Order("created_at DESC").
 		Find(&orders).Error
@@ -40,7 +57,7 @@ func (r *MySQLOrderRepository) GetActiveByUser(ctx context.Context, userID int64
 
 func (r *MySQLOrderRepository) GetByUserAndSymbol(ctx context.Context, userID int64, symbol string, limit int) ([]*Order, error) {
 	var orders []*Order
-	err := r.db.WithContext(ctx).
+	err := r.reader(ctx).WithContext(ctx).
 		Where("user_id = ? AND symbol = ?", userID, symbol).
 		Order("created_at DESC").
 		Limit(limit).
@@ -48,22 +65,23 @@ func (r *MySQLOrderRepository) GetByUserAndSymbol(ctx context.Context, userID in
 	return orders, err
 }
 
-func (r *MySQLOrderRepository) UpdateFill(ctx context.Context, orderID int64, filledQty, avgPrice int64, status OrderStatus) error {
-	return r.db.WithContext(ctx).
+func (r *MySQLOrderRepository) UpdateFill(ctx context.Context, orderID int64, prevFilledQty, filledQty, avgPrice int64, status OrderStatus) (bool, error) {
+	result := r.db.WithContext(ctx).
 		Model(&Order{}).
-		Where("order_id = ?", orderID).
+		Where("order_id = ? AND filled_qty = ? AND filled_qty < ?", orderID, prevFilledQty, filledQty).
 		Updates(map[string]any{
 			"filled_qty": filledQty,
 			"avg_price":  avgPrice,
 			"status":     status,
 			"updated_at": time.Now().UnixMilli(),
-		}).Error
+		})
+	return result.RowsAffected > 0, result.Error
 }
 
 func (r *MySQLOrderRepository) UpdateStatus(ctx context.Context, orderID int64, status OrderStatus) error {
 	return r.db.WithContext(ctx).
 		Model(&Order{}).
-		Where("order_id = ?", orderID).
+		Where("order_id = ? AND status IN ?", orderID, []OrderStatus{StatusNew, StatusPartiallyFilled}).
 		Updates(map[string]any{
 			"status":     status,
 			"updated_at": time.Now().UnixMilli(),
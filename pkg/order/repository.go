@@ -13,6 +13,9 @@ type OrderRepository interface {
 	GetByUserAndSymbol(ctx context.Context, userID int64, symbol string, limit int) ([]*Order, error)
 
 	// 更新
-	UpdateFill(ctx context.Context, orderID int64, filledQty, avgPrice int64, status OrderStatus) error
+	// UpdateFill 条件更新成交：仅当当前 filled_qty == prevFilledQty 且新值更大时生效，返回是否更新
+	// (重复投递、并发消费的成交事件不会把成交量加两次或改回旧值)
+	UpdateFill(ctx context.Context, orderID int64, prevFilledQty, filledQty, avgPrice int64, status OrderStatus) (bool, error)
+	// UpdateStatus 仅在订单仍活跃 (NEW / PARTIALLY_FILLED) 时更新状态，终态不会被覆盖
 	UpdateStatus(ctx context.Context, orderID int64, status OrderStatus) error
 }
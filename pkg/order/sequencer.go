@@ -0,0 +1,195 @@
+// 文件: pkg/order/sequencer.go
+// 订单事件按序号重排
+//
+// 撮合给每个订单事件盖了连续的订单内序号 (mtrade.EventSeq)，这里按订单记录已应用到的序号：
+//   - seq <= 已应用：重复投递，丢弃
+//   - seq == 已应用 + 1：立即应用，再把暂存里接得上的依次应用
+//   - seq 更大：前面的事件还在路上，先暂存；ReorderWindow 内等不到就跳过空洞，按序号依次应用
+//     (空洞也可能是本消费者不订阅的事件)
+//   - 会话更新 (引擎重启)：计数重新开始；比已见会话旧的事件是过期事件，丢弃
+//
+// 【注意】
+//   - 序号为 0 的事件来自不带序号的发布方，直接应用
+//   - 序号 1 固定是受理 / 拒绝事件，订单服务不订阅，新订单 (以及新会话) 从 2 开始等
+//   - 被跳过的序号之后才到 (迟到)，或应用失败等重投的，照常应用，不当作重复：
+//     OnTradeFill / OnOrderCanceled 本身容忍乱序，排序只是为了减少先撤单后成交的中间状态
+//   - 暂存只在内存里：进程退出时未应用的暂存事件丢失，与 NATS 核心订阅 "至多一次" 一致，由对账兜底
+
+package order
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultReorderWindow 乱序事件最多暂存多久
+	DefaultReorderWindow = 2 * time.Second
+
+	// seqIdleTTL 多久没有事件的订单清掉序号状态 (已终态或长期挂单)
+	seqIdleTTL = 10 * time.Minute
+)
+
+// orderSeqState 单个订单的序号状态
+type orderSeqState struct {
+	mu      sync.Mutex
+	session int64
+	applied uint64                  // 已连续应用到的序号
+	pending map[uint64]func() error // 提前到达、等待前序事件的
+	skipped map[uint64]struct{}     // 超时跳过的空洞 (迟到时照常应用)
+	timer   *time.Timer
+	touched time.Time
+}
+
+// reset 进入新会话
+func (st *orderSeqState) reset(session int64) {
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+	st.session = session
+	st.applied = 1
+	st.pending = make(map[uint64]func() error)
+	st.skipped = make(map[uint64]struct{})
+}
+
+// orderSequencer 按订单序号重排 (零值可用)
+type orderSequencer struct {
+	window time.Duration // <=0 为 DefaultReorderWindow
+
+	mu        sync.Mutex
+	orders    map[int64]*orderSeqState
+	lastSweep time.Time
+
+	dropped   atomic.Int64 // 重复 / 过期会话而丢弃的事件
+	reordered atomic.Int64 // 暂存过的事件
+}
+
+func (s *orderSequencer) state(orderID int64) *orderSeqState {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.orders == nil {
+		s.orders = make(map[int64]*orderSeqState)
+	}
+	if now.Sub(s.lastSweep) >= seqIdleTTL {
+		s.sweep(now)
+	}
+	st, ok := s.orders[orderID]
+	if !ok {
+		st = &orderSeqState{}
+		s.orders[orderID] = st
+	}
+	return st
+}
+
+// sweep 清掉空闲订单的状态 (调用方持 s.mu)
+func (s *orderSequencer) sweep(now time.Time) {
+	for id, st := range s.orders {
+		st.mu.Lock()
+		if len(st.pending) == 0 && now.Sub(st.touched) >= seqIdleTTL {
+			delete(s.orders, id)
+		}
+		st.mu.Unlock()
+	}
+	s.lastSweep = now
+}
+
+// admit 按序号应用订单的一个事件，返回立即应用时的错误 (暂存 / 丢弃返回 nil)
+func (s *orderSequencer) admit(orderID, session int64, seq uint64, apply func() error) error {
+	if seq == 0 {
+		return apply()
+	}
+	st := s.state(orderID)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.touched = time.Now()
+
+	switch {
+	case session < st.session:
+		s.dropped.Add(1)
+		return nil
+	case session > st.session:
+		st.reset(session)
+	}
+
+	if _, late := st.skipped[seq]; late {
+		delete(st.skipped, seq)
+		return s.run(st, seq, apply)
+	}
+	if _, held := st.pending[seq]; held || seq <= st.applied {
+		s.dropped.Add(1)
+		return nil
+	}
+	if seq > st.applied+1 {
+		st.pending[seq] = apply
+		s.reordered.Add(1)
+		if st.timer == nil {
+			st.timer = time.AfterFunc(s.reorderWindow(), func() { s.expire(st) })
+		}
+		return nil
+	}
+
+	err := s.run(st, seq, apply)
+	st.applied = seq
+	s.drain(st)
+	return err
+}
+
+// run 应用一个事件；失败时记为空洞，重投的消息还能应用
+func (s *orderSequencer) run(st *orderSeqState, seq uint64, apply func() error) error {
+	err := apply()
+	if err != nil {
+		st.skipped[seq] = struct{}{}
+	}
+	return err
+}
+
+// drain 依次应用暂存里接得上的事件 (调用方持 st.mu)
+func (s *orderSequencer) drain(st *orderSeqState) {
+	for {
+		apply, ok := st.pending[st.applied+1]
+		if !ok {
+			break
+		}
+		delete(st.pending, st.applied+1)
+		st.applied++
+		if err := s.run(st, st.applied, apply); err != nil {
+			log.Printf("apply reordered order event seq=%d error: %v", st.applied, err)
+		}
+	}
+	if len(st.pending) == 0 && st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+}
+
+// expire 暂存超时：跳过空洞，按序号应用全部暂存事件
+func (s *orderSequencer) expire(st *orderSeqState) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.timer = nil
+	if len(st.pending) == 0 {
+		return
+	}
+	first := slices.Min(slices.Collect(maps.Keys(st.pending)))
+	for seq := st.applied + 1; seq < first; seq++ {
+		st.skipped[seq] = struct{}{}
+	}
+	st.applied = first - 1
+	s.drain(st)
+	if len(st.pending) > 0 { // 暂存里还有空洞，再等一个窗口
+		st.timer = time.AfterFunc(s.reorderWindow(), func() { s.expire(st) })
+	}
+}
+
+func (s *orderSequencer) reorderWindow() time.Duration {
+	if s.window <= 0 {
+		return DefaultReorderWindow
+	}
+	return s.window
+}
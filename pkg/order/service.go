@@ -6,9 +6,21 @@ package order
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+var (
+	ErrOrderNotFound = cexerr.New("ORDER_NOT_FOUND", cexerr.CategoryNotFound, "order not found")
+	ErrOverfill      = cexerr.New("ORDER_OVERFILL", cexerr.CategoryConflict, "fill exceeds order quantity")
+	ErrFillConflict  = cexerr.NewRetryable("ORDER_FILL_CONFLICT", cexerr.CategoryConflict, "order fill updated concurrently")
 )
 
+// fillRetries 成交条件更新冲突时的重试次数
+const fillRetries = 3
+
 type OrderService struct {
 	repo OrderRepository
 }
@@ -31,11 +43,19 @@ func (s *OrderService) CreateOrder(ctx context.Context, order *Order) error {
 
 // CreateFuturesOrder 创建合约订单 (便捷方法)
 func (s *OrderService) CreateFuturesOrder(ctx context.Context, orderID, userID int64, symbol string, side OrderSide, price, qty int64, leverage int, margin int64) error {
+	return s.CreateOrder(ctx, NewFuturesOrder(orderID, userID, symbol, side, price, qty, leverage, margin))
+}
+
+// NewFuturesOrder 构造合约限价订单 (状态 NEW，未落库)
+//
+// 需要和其他表同一事务写入时 (见 futures 的订单 outbox)，由调用方自己 Create
+func NewFuturesOrder(orderID, userID int64, symbol string, side OrderSide, price, qty int64, leverage int, margin int64) *Order {
 	extra, _ := json.Marshal(map[string]any{
 		"leverage": leverage,
 		"margin":   margin,
 	})
-	order := &Order{
+	now := time.Now().UnixMilli()
+	return &Order{
 		OrderID:     orderID,
 		UserID:      userID,
 		Symbol:      symbol,
@@ -44,9 +64,11 @@ func (s *OrderService) CreateFuturesOrder(ctx context.Context, orderID, userID i
 		OrderType:   OrderTypeLimit,
 		Price:       price,
 		Qty:         qty,
+		Status:      StatusNew,
 		Extra:       string(extra),
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
-	return s.CreateOrder(ctx, order)
 }
 
 // =============================================================================
@@ -54,25 +76,43 @@ func (s *OrderService) CreateFuturesOrder(ctx context.Context, orderID, userID i
 // =============================================================================
 
 // OnTradeFill 成交事件处理
+//
+// 读 → 算 → 条件写 (filled_qty 没被别人改过才生效)，冲突时重读重试：
+// 同一订单的两笔成交被不同消费实例并发处理时不会互相覆盖。
+// 同一笔成交的重复投递由消费端去重挡掉 (见 nats.Deduper)，这里保证成交量只增不减、不超过委托量
 func (s *OrderService) OnTradeFill(ctx context.Context, orderID int64, fillQty, fillPrice int64) error {
-	order, err := s.repo.GetByOrderID(ctx, orderID)
-	if err != nil {
-		return err
+	for range fillRetries {
+		order, err := s.repo.GetByOrderID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			return ErrOrderNotFound
+		}
+
+		// 计算新的成交均价
+		newFilledQty := order.FilledQty + fillQty
+		if newFilledQty > order.Qty {
+			return fmt.Errorf("%w: order %d filled %d + %d > qty %d", ErrOverfill, orderID, order.FilledQty, fillQty, order.Qty)
+		}
+		newAvgPrice := (order.AvgPrice*order.FilledQty + fillPrice*fillQty) / newFilledQty
+
+		// 判断状态 (撤单事件先到时保留终态，只补成交量)
+		newStatus := order.Status
+		if order.IsActive() {
+			if newFilledQty >= order.Qty {
+				newStatus = StatusFilled
+			} else {
+				newStatus = StatusPartiallyFilled
+			}
+		}
+
+		ok, err := s.repo.UpdateFill(ctx, orderID, order.FilledQty, newFilledQty, newAvgPrice, newStatus)
+		if err != nil || ok {
+			return err
+		}
 	}
-
-	// 计算新的成交均价
-	newFilledQty := order.FilledQty + fillQty
-	newAvgPrice := (order.AvgPrice*order.FilledQty + fillPrice*fillQty) / newFilledQty
-
-	// 判断状态
-	var newStatus OrderStatus
-	if newFilledQty >= order.Qty {
-		newStatus = StatusFilled
-	} else {
-		newStatus = StatusPartiallyFilled
-	}
-
-	return s.repo.UpdateFill(ctx, orderID, newFilledQty, newAvgPrice, newStatus)
+	return fmt.Errorf("%w: order %d", ErrFillConflict, orderID)
 }
 
 // OnOrderCanceled 撤单事件处理
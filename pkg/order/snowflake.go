@@ -6,6 +6,7 @@ package order
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/bwmarrin/snowflake"
 )
@@ -13,6 +14,9 @@ import (
 var (
 	node     *snowflake.Node
 	initOnce sync.Once
+
+	// lastID 已分配的最大 ID：时钟回拨或恢复出更大的 ID 时从 上一个 + 1 继续
+	lastID atomic.Int64
 )
 
 // InitSnowflake 初始化雪花算法
@@ -25,11 +29,29 @@ func InitSnowflake(nodeID int64) error {
 	return err
 }
 
-// GenerateOrderID 生成订单ID
+// GenerateOrderID 生成订单ID，单调递增且大于 AdvanceOrderID 设置的高水位
 func GenerateOrderID() int64 {
 	if node == nil {
 		// 未初始化则使用默认节点0
 		InitSnowflake(0)
 	}
-	return node.Generate().Int64()
+	candidate := node.Generate().Int64()
+	for {
+		last := lastID.Load()
+		id := max(candidate, last+1)
+		if lastID.CompareAndSwap(last, id) {
+			return id
+		}
+	}
+}
+
+// AdvanceOrderID 保证之后生成的 ID 都大于 id
+// 撮合引擎从 WAL 恢复后用恢复出的最大订单 ID 调用 (mtrade.Engine.RecoveredIDs)，避免撞上挂单
+func AdvanceOrderID(id int64) {
+	for {
+		last := lastID.Load()
+		if id <= last || lastID.CompareAndSwap(last, id) {
+			return
+		}
+	}
 }
@@ -0,0 +1,116 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/cexerr"
+)
+
+var (
+	ErrFaucetAsset    = cexerr.New("PAPER_FAUCET_ASSET", cexerr.CategoryInvalidArgument, "faucet does not grant this asset")
+	ErrFaucetCooldown = cexerr.New("PAPER_FAUCET_COOLDOWN", cexerr.CategoryRateLimited, "faucet cooldown not elapsed")
+	ErrFaucetCap      = cexerr.New("PAPER_FAUCET_CAP", cexerr.CategoryFailedPrecondition, "balance already at faucet cap")
+)
+
+// FaucetGrant 一种资产的发放规则
+type FaucetGrant struct {
+	Amount   int64         `yaml:"amount"`   // 每次发放数量 (×1e8)
+	Cooldown time.Duration `yaml:"cooldown"` // 同一用户两次领取的最小间隔
+	Cap      int64         `yaml:"cap"`      // 可用余额达到此值不再发放，0 不限
+}
+
+// FaucetConfig 水龙头配置
+type FaucetConfig struct {
+	Grants map[string]FaucetGrant `yaml:"grants"` // asset → 规则
+}
+
+// HotWallet 热钱包 (asset.AccountEngine 实现)
+type HotWallet interface {
+	ApplyBalanceChange(event *asset.BalanceChangeEvent) error
+	GetAvailable(userID int64, symbol string) int64
+}
+
+// ColdLedger 冷钱包 (fund.BalanceRepo 实现)，合约保证金从这里冻结
+type ColdLedger interface {
+	AddAvailable(ctx context.Context, userID int64, symbol string, amount int64) error
+}
+
+var _ HotWallet = (*asset.AccountEngine)(nil)
+
+// Faucet 测试币水龙头：纸面模式下替代链上充值
+//
+// 发放走和真实充值相同的 DEPOSIT 路径 (冷钱包入账 → 热钱包 ApplyBalanceChange)，
+// 下游看到的余额变动和真实环境一致
+type Faucet struct {
+	cfg  FaucetConfig
+	hot  HotWallet
+	cold ColdLedger
+	now  func() time.Time
+
+	mu   sync.Mutex
+	last map[faucetKey]time.Time // 上次领取时间
+}
+
+type faucetKey struct {
+	userID int64
+	asset  string
+}
+
+// NewFaucet 创建水龙头，cold 为 nil 时只给热钱包入账 (纯现货测试网)
+func NewFaucet(cfg FaucetConfig, hot HotWallet, cold ColdLedger) *Faucet {
+	return &Faucet{cfg: cfg, hot: hot, cold: cold, now: time.Now, last: make(map[faucetKey]time.Time)}
+}
+
+// SetClock 替换领取冷却期的时钟
+func (f *Faucet) SetClock(now func() time.Time) {
+	f.now = now
+}
+
+// Grant 给用户发放一次测试币，返回发放数量
+func (f *Faucet) Grant(ctx context.Context, userID int64, assetName string) (int64, error) {
+	g, ok := f.cfg.Grants[assetName]
+	if !ok || g.Amount <= 0 {
+		return 0, ErrFaucetAsset.Wrapf("%s", assetName)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	key := faucetKey{userID, assetName}
+	if last, ok := f.last[key]; ok && now.Sub(last) < g.Cooldown {
+		return 0, ErrFaucetCooldown.Wrapf("retry after %s", last.Add(g.Cooldown).Sub(now).Round(time.Second))
+	}
+	amount := g.Amount
+	if g.Cap > 0 {
+		avail := f.hot.GetAvailable(userID, assetName)
+		if avail >= g.Cap {
+			return 0, ErrFaucetCap
+		}
+		amount = min(amount, g.Cap-avail)
+	}
+
+	// 先冷后热，与真实充值顺序一致；热钱包入账失败时两边差额由对账发现
+	if f.cold != nil {
+		if err := f.cold.AddAvailable(ctx, userID, assetName, amount); err != nil {
+			return 0, err
+		}
+	}
+	err := f.hot.ApplyBalanceChange(&asset.BalanceChangeEvent{
+		EventType: "DEPOSIT",
+		EventID:   fmt.Sprintf("faucet_%d_%s_%d", userID, assetName, now.UnixNano()),
+		UserID:    userID,
+		Symbol:    assetName,
+		Amount:    amount,
+		Timestamp: now.UnixMilli(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	f.last[key] = now
+	return amount, nil
+}
@@ -0,0 +1,111 @@
+// Package paper 纸面交易 / 公开测试网模式
+//
+// 同一套二进制跑测试网：撮合、风控、强平全是真的，只有钱是假的。
+//
+//	              live                         paper
+//	MySQL 表      balance_000, positions ...    paper_balance_000, paper_positions ...
+//	热钱包 WAL    <wal_dir>                     <wal_dir>/paper
+//	充值          链上确认                      水龙头 (Faucet) 发放
+//	提现          正常                          关闭 (asset.ErrWithdrawDisabled)
+//
+// 【做法】表隔离靠 gorm 回调统一加前缀 (UseTablePrefix)，各仓库代码不用改；
+// 纸面库和真实库即使是同一个 MySQL 实例，也不会读写到对方的表
+//
+// 建表: 各模块的 .sql 把表名加上前缀执行一遍 (纸面表结构与正式表完全相同)
+//
+// 【注意】前缀只作用于 gorm 构造的语句 (Model / Table)，手写的 Raw / Exec SQL 不会改写，
+// 纸面模式下不要在仓库里写死表名的原生 SQL
+package paper
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/asset"
+)
+
+// DefaultTablePrefix 纸面交易表前缀
+const DefaultTablePrefix = "paper_"
+
+// Config 纸面交易配置 (挂在服务总配置上，Enabled=false 即正式环境)
+type Config struct {
+	Enabled     bool         `yaml:"enabled"`
+	TablePrefix string       `yaml:"table_prefix"` // 为空使用 DefaultTablePrefix
+	Faucet      FaucetConfig `yaml:"faucet"`
+}
+
+func (c Config) prefix() string {
+	if c.TablePrefix == "" {
+		return DefaultTablePrefix
+	}
+	return c.TablePrefix
+}
+
+// PrepareDB 纸面模式下给 db 加表前缀，正式模式原样返回
+//
+// db 必须是纸面交易专用的 *gorm.DB 实例：回调注册在实例上，对同一实例派生的所有会话生效
+func (c Config) PrepareDB(db *gorm.DB) error {
+	if !c.Enabled {
+		return nil
+	}
+	return UseTablePrefix(db, c.prefix())
+}
+
+// AssetConfig 纸面模式下调整热钱包配置：关闭提现，WAL 写到单独的子目录
+func (c Config) AssetConfig(cfg asset.EngineConfig) asset.EngineConfig {
+	if !c.Enabled {
+		return cfg
+	}
+	cfg.WithdrawDisabled = true
+	if cfg.WALDir != "" {
+		cfg.WALDir = filepath.Join(cfg.WALDir, strings.TrimSuffix(c.prefix(), "_"))
+	}
+	return cfg
+}
+
+// =============================================================================
+// 表前缀
+// =============================================================================
+
+const prefixCallback = "paper:table_prefix"
+
+// UseTablePrefix 注册 gorm 回调，所有 CRUD 语句的表名加上 prefix
+//
+// 在各处理链的最前面执行：此时模型已解析出表名 (TableName / Table())，还没拼 SQL
+func UseTablePrefix(db *gorm.DB, prefix string) error {
+	fn := func(tx *gorm.DB) { applyPrefix(tx.Statement, prefix) }
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register(prefixCallback, fn),
+		cb.Query().Before("*").Register(prefixCallback, fn),
+		cb.Update().Before("*").Register(prefixCallback, fn),
+		cb.Delete().Before("*").Register(prefixCallback, fn),
+		cb.Row().Before("*").Register(prefixCallback, fn),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPrefix 改写语句的表名
+//
+// Table("x") 会同时设置 Table 和 TableExpr，两个都要改；
+// 带空格/反引号的复杂表达式 (子查询、别名) 不改写
+func applyPrefix(stmt *gorm.Statement, prefix string) {
+	table := stmt.Table
+	if table == "" || strings.HasPrefix(table, prefix) {
+		return
+	}
+	if stmt.TableExpr != nil {
+		if stmt.TableExpr.SQL != stmt.Quote(table) {
+			return
+		}
+		stmt.TableExpr = &clause.Expr{SQL: stmt.Quote(prefix + table)}
+	}
+	stmt.Table = prefix + table
+}
@@ -0,0 +1,117 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"max.com/pkg/asset"
+)
+
+type coldLedger map[string]int64
+
+func (l coldLedger) AddAvailable(ctx context.Context, userID int64, symbol string, amount int64) error {
+	l[symbol] += amount
+	return nil
+}
+
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "u:p@tcp(127.0.0.1:1)/x", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type widget struct {
+	ID   int64
+	Name string
+}
+
+func (widget) TableName() string { return "widgets" }
+
+func TestUseTablePrefix(t *testing.T) {
+	db := dryRunDB(t)
+	if err := (Config{Enabled: true}).PrepareDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]*gorm.DB{
+		"SELECT * FROM `paper_widgets` WHERE id = ? ORDER BY `paper_widgets`.`id` LIMIT ?": db.Where("id = ?", 1).First(&widget{}),
+		"SELECT * FROM `paper_balance_001` WHERE user_id = ?":                              db.Table("balance_001").Where("user_id = ?", 1).Find(&[]widget{}),
+		"UPDATE `paper_widgets` SET `name`=? WHERE id = ?":                                 db.Model(&widget{}).Where("id = ?", 1).Update("name", "x"),
+		"INSERT INTO `paper_widgets` (`name`,`id`) VALUES (?,?)":                           db.Create(&widget{ID: 1, Name: "x"}),
+	}
+	for want, tx := range cases {
+		if got := tx.Statement.SQL.String(); got != want {
+			t.Errorf("got  %s (%v)\nwant %s", got, tx.Error, want)
+		}
+	}
+
+	// 正式模式不改写
+	live := dryRunDB(t)
+	(Config{}).PrepareDB(live)
+	if got := live.Find(&[]widget{}).Statement.SQL.String(); got != "SELECT * FROM `widgets`" {
+		t.Errorf("live mode rewritten: %s", got)
+	}
+}
+
+func TestAssetConfig(t *testing.T) {
+	cfg := Config{Enabled: true}.AssetConfig(asset.EngineConfig{WALDir: "/data/wal"})
+	if !cfg.WithdrawDisabled || cfg.WALDir != "/data/wal/paper" {
+		t.Fatalf("unexpected paper asset config %+v", cfg)
+	}
+	if live := (Config{}).AssetConfig(asset.EngineConfig{WALDir: "/data/wal"}); live.WithdrawDisabled || live.WALDir != "/data/wal" {
+		t.Fatalf("live config changed %+v", live)
+	}
+}
+
+func TestFaucet(t *testing.T) {
+	engine := asset.NewEngine(Config{Enabled: true}.AssetConfig(asset.DefaultEngineConfig()))
+	engine.Start()
+	defer engine.Stop()
+
+	cold := coldLedger{}
+	now := time.Unix(1_700_000_000, 0)
+	f := NewFaucet(FaucetConfig{Grants: map[string]FaucetGrant{
+		"USDT": {Amount: 10000, Cooldown: time.Hour, Cap: 15000},
+	}}, engine, cold)
+	f.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	if n, err := f.Grant(ctx, 1, "USDT"); err != nil || n != 10000 {
+		t.Fatalf("first grant: %d %v", n, err)
+	}
+	if _, err := f.Grant(ctx, 1, "USDT"); !errors.Is(err, ErrFaucetCooldown) {
+		t.Fatalf("expected cooldown, got %v", err)
+	}
+	if _, err := f.Grant(ctx, 1, "BTC"); !errors.Is(err, ErrFaucetAsset) {
+		t.Fatalf("expected unsupported asset, got %v", err)
+	}
+
+	// 第二次只补到上限
+	now = now.Add(time.Hour)
+	if n, err := f.Grant(ctx, 1, "USDT"); err != nil || n != 5000 {
+		t.Fatalf("second grant: %d %v", n, err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := f.Grant(ctx, 1, "USDT"); !errors.Is(err, ErrFaucetCap) {
+		t.Fatalf("expected cap, got %v", err)
+	}
+	if got := engine.GetAvailable(1, "USDT"); got != 15000 || cold["USDT"] != 15000 {
+		t.Fatalf("hot %d cold %d", got, cold["USDT"])
+	}
+
+	// 纸面模式不能提现
+	err := engine.ApplyBalanceChange(&asset.BalanceChangeEvent{
+		EventType: "WITHDRAW", EventID: "w1", UserID: 1, Symbol: "USDT", Amount: 100,
+	})
+	if !errors.Is(err, asset.ErrWithdrawDisabled) {
+		t.Fatalf("expected ErrWithdrawDisabled, got %v", err)
+	}
+}
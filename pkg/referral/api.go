@@ -0,0 +1,69 @@
+package referral
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// EarningsResponse 返佣查询响应
+type EarningsResponse struct {
+	ReferrerID int64            `json:"referrer_id"`
+	From       string           `json:"from"`
+	To         string           `json:"to"`
+	Totals     map[string]int64 `json:"totals"` // 区间内各资产应付总额
+	Daily      []Earning        `json:"daily"`
+}
+
+// NewHandler 创建返佣查询接口
+//
+//	GET /referral/earnings?from=2024-01-01&to=2024-01-31   当前登录用户作为推荐人的返佣
+//
+// from/to 省略时默认最近 30 天 (含今天)。
+// userID 从请求中取出已认证的用户 ID (由网关鉴权后注入)，失败返回 error
+func NewHandler(e *Engine, userID func(r *http.Request) (int64, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/referral/earnings", func(w http.ResponseWriter, r *http.Request) {
+		uid, err := userID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		now := e.config.Now()
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		if to == "" {
+			to = Day(now)
+		}
+		if from == "" {
+			from = Day(now.AddDate(0, 0, -29))
+		}
+
+		daily, err := e.Earnings(uid, from, to)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidDay) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		resp := EarningsResponse{ReferrerID: uid, From: from, To: to, Totals: make(map[string]int64), Daily: daily}
+		if resp.Daily == nil {
+			resp.Daily = []Earning{}
+		}
+		for _, d := range daily {
+			resp.Totals[d.Asset] += d.Total()
+		}
+		writeJSON(w, resp)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
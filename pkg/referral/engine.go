@@ -0,0 +1,377 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/fund"
+)
+
+// =============================================================================
+// 依赖
+// =============================================================================
+
+// Payer 从手续费收入账户付款 (asset.AccountEngine 实现)
+type Payer interface {
+	PayFromFeeAccount(payID string, userID int64, symbol string, amount int64) error
+}
+
+// JournalPublisher 流水发布 (fund.EventPublisher 实现)
+type JournalPublisher interface {
+	PublishJournal(event *fund.JournalEvent) error
+}
+
+var (
+	_ Payer            = (*asset.AccountEngine)(nil)
+	_ JournalPublisher = (*fund.EventPublisher)(nil)
+)
+
+// =============================================================================
+// 数据结构
+// =============================================================================
+
+// Fill 一笔收费成交中某个用户付的手续费
+type Fill struct {
+	TradeID  int64
+	UserID   int64  // 付手续费的用户
+	Fee      int64  // 手续费，<= 0 (maker 返佣) 不分佣
+	FeeAsset string // 手续费资产，返佣同资产发放
+	Time     time.Time
+}
+
+// Commission 一笔成交产生的单个返佣
+type Commission struct {
+	ReferrerID int64
+	Level      int // 1 或 2
+	Asset      string
+	Amount     int64
+}
+
+// Earning 推荐人某天某资产的返佣汇总
+type Earning struct {
+	ReferrerID int64  `json:"referrer_id"`
+	Day        string `json:"day"`
+	Asset      string `json:"asset"`
+	Level1     int64  `json:"level1"` // 一级返佣
+	Level2     int64  `json:"level2"` // 二级返佣
+	Fills      int64  `json:"fills"`  // 贡献返佣的成交笔数
+	Paid       int64  `json:"paid"`   // 已入账
+
+	// retry 上次打款失败的金额：资产引擎可能已按 payID 扣了手续费账户，
+	// 重试必须用同一 payID、同一金额，期间新增的应付另起一笔
+	retry int64
+}
+
+// Total 应付总额
+func (e Earning) Total() int64 { return e.Level1 + e.Level2 }
+
+// PayoutResult 一次日结结果
+type PayoutResult struct {
+	Day     string
+	Payouts int              // 成功入账笔数
+	Amounts map[string]int64 // 各资产入账总额
+	Failed  int              // 失败笔数 (下次 Payout 重试)
+}
+
+type fillKey struct {
+	tradeID int64
+	userID  int64
+	asset   string
+}
+
+type earningKey struct {
+	referrerID int64
+	asset      string
+}
+
+// dayBook 一天的账本
+type dayBook struct {
+	seen     map[fillKey]struct{} // 成交去重 (重放、重复投递)
+	earnings map[earningKey]*Earning
+}
+
+// =============================================================================
+// Engine
+// =============================================================================
+
+// Engine 返佣引擎
+//
+// 【注意】累计账本在内存：重启会丢失当天未结算的返佣，
+// 生产环境重启后需要从成交流水重放当天的 OnFill (成交去重保证重放安全)
+type Engine struct {
+	tree      Tree
+	payer     Payer            // 为 nil 时只累计不打款
+	publisher JournalPublisher // 可选
+	config    Config
+
+	mu   sync.Mutex
+	days map[string]*dayBook
+
+	// 同一时刻只跑一个日结，避免两次日结算出同一个 payID 各记一次已付
+	payoutMu sync.Mutex
+}
+
+// NewEngine 创建返佣引擎
+func NewEngine(tree Tree, payer Payer, publisher JournalPublisher, cfg Config) (*Engine, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Engine{
+		tree:      tree,
+		payer:     payer,
+		publisher: publisher,
+		config:    cfg,
+		days:      make(map[string]*dayBook),
+	}, nil
+}
+
+// OnFill 成交回调：计算返佣并累计到成交当天
+//
+// 同一 (成交, 用户, 资产) 只计一次，返回本次新增的返佣
+func (e *Engine) OnFill(f Fill) []Commission {
+	if f.Fee <= 0 || f.FeeAsset == "" {
+		return nil
+	}
+	commissions := e.compute(f)
+	if len(commissions) == 0 {
+		return nil
+	}
+
+	ts := f.Time
+	if ts.IsZero() {
+		ts = e.config.Now()
+	}
+	day := Day(ts)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	book := e.days[day]
+	if book == nil {
+		book = &dayBook{seen: make(map[fillKey]struct{}), earnings: make(map[earningKey]*Earning)}
+		e.days[day] = book
+	}
+	key := fillKey{tradeID: f.TradeID, userID: f.UserID, asset: f.FeeAsset}
+	if _, dup := book.seen[key]; dup {
+		return nil
+	}
+	book.seen[key] = struct{}{}
+
+	for _, c := range commissions {
+		ek := earningKey{referrerID: c.ReferrerID, asset: c.Asset}
+		earning := book.earnings[ek]
+		if earning == nil {
+			earning = &Earning{ReferrerID: c.ReferrerID, Day: day, Asset: c.Asset}
+			book.earnings[ek] = earning
+		}
+		if c.Level == 1 {
+			earning.Level1 += c.Amount
+		} else {
+			earning.Level2 += c.Amount
+		}
+		earning.Fills++
+	}
+	return commissions
+}
+
+// compute 沿推荐链往上最多 MaxLevels 级
+func (e *Engine) compute(f Fill) []Commission {
+	var out []Commission
+	cur := f.UserID
+	for level := 1; level <= MaxLevels; level++ {
+		ref, ok := e.tree.Referrer(cur)
+		if !ok {
+			break
+		}
+		if amount := mulRate(f.Fee, e.config.rate(level)); amount > 0 {
+			out = append(out, Commission{ReferrerID: ref, Level: level, Asset: f.FeeAsset, Amount: amount})
+		}
+		cur = ref
+	}
+	return out
+}
+
+// mulRate fee × rate / 10000，向下取整 (零头留给平台)，拆开算避免溢出
+func mulRate(fee, rate int64) int64 {
+	return fee/RatePrecision*rate + fee%RatePrecision*rate/RatePrecision
+}
+
+// =============================================================================
+// 日结
+// =============================================================================
+
+// Payout 结算某一天的返佣 (只能结算已经结束的日期)
+//
+// 每个 (推荐人, 资产) 付 应付 - 已付，payID = commission_{day}_{推荐人}_{资产}_{已付}：
+//   - 打款失败时记下这次的金额，重试时已付没变，payID 和金额都相同，资产引擎幂等
+//     (资产引擎按 payID 去重，换金额重试会出现手续费账户扣旧额、用户入新额)
+//   - 日结后才到的迟到成交会让应付变大，用新的 payID 补发差额 (重试成功后同一轮接着补)
+//
+// 单笔失败不影响其他推荐人，错误汇总返回，失败的留到下次重试
+func (e *Engine) Payout(ctx context.Context, day string) (PayoutResult, error) {
+	result := PayoutResult{Day: day, Amounts: make(map[string]int64)}
+	if e.payer == nil {
+		return result, ErrPayoutDisabled
+	}
+	d, err := time.Parse(DayLayout, day)
+	if err != nil {
+		return result, fmt.Errorf("%w: %q", ErrInvalidDay, day)
+	}
+	if d.AddDate(0, 0, 1).After(e.config.Now().UTC()) {
+		return result, fmt.Errorf("%w: %s", ErrDayNotClosed, day)
+	}
+
+	e.payoutMu.Lock()
+	defer e.payoutMu.Unlock()
+
+	type pending struct {
+		Earning
+		amount int64
+	}
+	e.mu.Lock()
+	var todo []pending
+	if book := e.days[day]; book != nil {
+		for _, earning := range book.earnings {
+			if earning.retry > 0 {
+				todo = append(todo, pending{Earning: *earning, amount: earning.retry})
+			} else if owed := earning.Total() - earning.Paid; owed > 0 {
+				todo = append(todo, pending{Earning: *earning, amount: owed})
+			}
+		}
+	}
+	e.mu.Unlock()
+	// 固定顺序，便于排查
+	sort.Slice(todo, func(i, j int) bool {
+		if todo[i].ReferrerID != todo[j].ReferrerID {
+			return todo[i].ReferrerID < todo[j].ReferrerID
+		}
+		return todo[i].Asset < todo[j].Asset
+	})
+
+	var errs []error
+	// 重试成功后可能还有差额要补，会追加到 todo 末尾
+	for i := 0; i < len(todo); i++ {
+		p := todo[i]
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		key := earningKey{referrerID: p.ReferrerID, asset: p.Asset}
+		payID := fmt.Sprintf("commission_%s_%d_%s_%d", day, p.ReferrerID, p.Asset, p.Paid)
+		if err := e.payer.PayFromFeeAccount(payID, p.ReferrerID, p.Asset, p.amount); err != nil {
+			e.mu.Lock()
+			if book := e.days[day]; book != nil {
+				book.earnings[key].retry = p.amount
+			}
+			e.mu.Unlock()
+			result.Failed++
+			errs = append(errs, fmt.Errorf("referrer %d %s: %w", p.ReferrerID, p.Asset, err))
+			continue
+		}
+
+		e.mu.Lock()
+		if book := e.days[day]; book != nil {
+			earning := book.earnings[key]
+			earning.Paid += p.amount
+			earning.retry = 0
+			if owed := earning.Total() - earning.Paid; p.retry > 0 && owed > 0 {
+				todo = append(todo, pending{Earning: *earning, amount: owed})
+			}
+		}
+		e.mu.Unlock()
+		result.Payouts++
+		result.Amounts[p.Asset] += p.amount
+
+		if e.publisher != nil {
+			e.publisher.PublishJournal(&fund.JournalEvent{
+				EventID:    payID,
+				UserID:     p.ReferrerID,
+				Symbol:     p.Asset,
+				ChangeType: fund.ChangeTypeCommission,
+				Amount:     p.amount,
+				BizType:    fund.BizTypeCommission,
+				BizID:      day,
+				CreatedAt:  e.config.Now(),
+			})
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// =============================================================================
+// 查询
+// =============================================================================
+
+// Earnings 推荐人在 [from, to] (含) 的每日返佣，按日期、资产排序
+func (e *Engine) Earnings(referrerID int64, from, to string) ([]Earning, error) {
+	if _, err := time.Parse(DayLayout, from); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDay, from)
+	}
+	if _, err := time.Parse(DayLayout, to); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDay, to)
+	}
+
+	e.mu.Lock()
+	var out []Earning
+	for day, book := range e.days {
+		// YYYY-MM-DD 字典序即时间序
+		if day < from || day > to {
+			continue
+		}
+		for key, earning := range book.earnings {
+			if key.referrerID == referrerID {
+				out = append(out, *earning)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day < out[j].Day
+		}
+		return out[i].Asset < out[j].Asset
+	})
+	return out, nil
+}
+
+// DailyPayouts 某一天所有推荐人的返佣汇总 (对账、运营报表)
+func (e *Engine) DailyPayouts(day string) []Earning {
+	e.mu.Lock()
+	var out []Earning
+	if book := e.days[day]; book != nil {
+		for _, earning := range book.earnings {
+			out = append(out, *earning)
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ReferrerID != out[j].ReferrerID {
+			return out[i].ReferrerID < out[j].ReferrerID
+		}
+		return out[i].Asset < out[j].Asset
+	})
+	return out
+}
+
+// Prune 删除 before 之前 (不含) 的账本，调用方应确保这些日期已经结清
+func (e *Engine) Prune(before string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for day := range e.days {
+		if day < before {
+			delete(e.days, day)
+			n++
+		}
+	}
+	return n
+}
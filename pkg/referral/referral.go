@@ -0,0 +1,148 @@
+// Package referral 推荐返佣 (邀请返佣)
+//
+// 每笔收了手续费的成交，按推荐关系把手续费的一部分返给推荐人，最多两级：
+//
+//	被推荐人 C 成交，手续费 fee
+//	  └─ 一级推荐人 B (直接邀请 C)   得 fee × Level1Rate
+//	       └─ 二级推荐人 A (邀请了 B) 得 fee × Level2Rate
+//
+// 【流程】
+//
+//	成交 ──OnFill──→ 按日累计 (推荐人, 资产) ──Payout(日)──→ 手续费账户 → 推荐人
+//	                                                           + COMMISSION 流水
+//
+// 成交时只记账不打款：一个活跃用户一天几千笔成交，逐笔打款会把推荐人的分片打满，
+// 也会产生海量流水；按日汇总后每个 (推荐人, 资产) 一天只有一次入账。
+//
+// 【面试】返佣从哪里出？
+// 从手续费收入账户 (asset.EngineConfig.FeeAccountID) 出，和 maker 返佣同一个来源。
+// 一级 + 二级费率之和不能超过 100%，否则平台每笔成交都倒贴。
+package referral
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrSelfReferral   = errors.New("referral: user cannot refer themselves")
+	ErrAlreadyBound   = errors.New("referral: user already has a referrer")
+	ErrReferralCycle  = errors.New("referral: binding would create a cycle")
+	ErrInvalidRates   = errors.New("referral: invalid commission rates")
+	ErrDayNotClosed   = errors.New("referral: day not closed yet")
+	ErrInvalidDay     = errors.New("referral: invalid day, want YYYY-MM-DD")
+	ErrPayoutDisabled = errors.New("referral: no payer configured")
+)
+
+// RatePrecision 费率精度 (万分比)
+const RatePrecision = 10000
+
+// MaxLevels 最多返佣层级
+const MaxLevels = 2
+
+// DayLayout 结算日格式 (UTC)
+const DayLayout = "2006-01-02"
+
+// =============================================================================
+// 推荐关系
+// =============================================================================
+
+// Tree 推荐关系查询
+type Tree interface {
+	// Referrer 返回 userID 的直接推荐人，没有则 ok=false
+	Referrer(userID int64) (referrerID int64, ok bool)
+}
+
+// MemoryTree 内存推荐关系
+//
+// 【规则】绑定后不可修改 (防止刷单时临时改推荐人套返佣)；不能形成环
+type MemoryTree struct {
+	mu        sync.RWMutex
+	referrers map[int64]int64
+}
+
+// NewMemoryTree 创建内存推荐关系
+func NewMemoryTree() *MemoryTree {
+	return &MemoryTree{referrers: make(map[int64]int64)}
+}
+
+// Bind 绑定推荐关系 (注册时填写邀请码)
+func (t *MemoryTree) Bind(userID, referrerID int64) error {
+	if userID == referrerID {
+		return ErrSelfReferral
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.referrers[userID]; ok {
+		return ErrAlreadyBound
+	}
+	// 沿 referrer 往上走，遇到 userID 就是环
+	for cur, ok := referrerID, true; ok; cur, ok = t.referrers[cur] {
+		if cur == userID {
+			return fmt.Errorf("%w: %d -> %d", ErrReferralCycle, userID, referrerID)
+		}
+	}
+	t.referrers[userID] = referrerID
+	return nil
+}
+
+// Referrer 实现 Tree
+func (t *MemoryTree) Referrer(userID int64) (int64, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	id, ok := t.referrers[userID]
+	return id, ok
+}
+
+// Referees 直接邀请的用户 (管理后台用，O(n))
+func (t *MemoryTree) Referees(referrerID int64) []int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var out []int64
+	for uid, ref := range t.referrers {
+		if ref == referrerID {
+			out = append(out, uid)
+		}
+	}
+	return out
+}
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 返佣配置
+type Config struct {
+	Level1Rate int64 // 一级返佣比例 (万分比，占手续费)，如 2000 = 20%
+	Level2Rate int64 // 二级返佣比例 (万分比，占手续费)，如 500 = 5%
+
+	// Now 时钟 (测试注入)，默认 time.Now
+	Now func() time.Time
+}
+
+// Validate 校验费率
+func (c Config) Validate() error {
+	if c.Level1Rate < 0 || c.Level2Rate < 0 || c.Level1Rate+c.Level2Rate > RatePrecision {
+		return fmt.Errorf("%w: level1=%d level2=%d", ErrInvalidRates, c.Level1Rate, c.Level2Rate)
+	}
+	return nil
+}
+
+func (c Config) rate(level int) int64 {
+	if level == 1 {
+		return c.Level1Rate
+	}
+	return c.Level2Rate
+}
+
+// Day 时间对应的结算日 (UTC)
+func Day(t time.Time) string {
+	return t.UTC().Format(DayLayout)
+}
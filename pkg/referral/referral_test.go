@@ -0,0 +1,207 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"max.com/pkg/fund"
+)
+
+// fakePayer 与 asset.AccountEngine 一样分两步、按 key 幂等：先扣手续费账户 ({payID}_out) 再入账用户
+type fakePayer struct {
+	paid       map[string]int64 // payID -> amount
+	out        map[string]int64 // {payID}_out -> 手续费账户出账金额
+	balance    map[int64]int64
+	fail       map[int64]bool // 手续费账户出账前失败
+	failCredit map[int64]bool // 出账后、入账用户时失败
+}
+
+func newFakePayer() *fakePayer {
+	return &fakePayer{
+		paid:       make(map[string]int64),
+		out:        make(map[string]int64),
+		balance:    make(map[int64]int64),
+		fail:       make(map[int64]bool),
+		failCredit: make(map[int64]bool),
+	}
+}
+
+func (p *fakePayer) PayFromFeeAccount(payID string, userID int64, symbol string, amount int64) error {
+	if p.fail[userID] {
+		return errors.New("fee account unavailable")
+	}
+	if _, ok := p.out[payID+"_out"]; !ok {
+		p.out[payID+"_out"] = amount
+	}
+	if p.failCredit[userID] {
+		return errors.New("credit timeout")
+	}
+	if _, ok := p.paid[payID]; ok {
+		return nil
+	}
+	p.paid[payID] = amount
+	p.balance[userID] += amount
+	return nil
+}
+
+type fakePublisher struct{ events []*fund.JournalEvent }
+
+func (p *fakePublisher) PublishJournal(e *fund.JournalEvent) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestMemoryTree_Bind(t *testing.T) {
+	tree := NewMemoryTree()
+	if err := tree.Bind(1, 1); !errors.Is(err, ErrSelfReferral) {
+		t.Fatalf("expected ErrSelfReferral, got %v", err)
+	}
+	if err := tree.Bind(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Bind(3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Bind(2, 4); !errors.Is(err, ErrAlreadyBound) {
+		t.Fatalf("expected ErrAlreadyBound, got %v", err)
+	}
+	// 1 <- 2 <- 3，再绑 1 -> 3 成环
+	if err := tree.Bind(1, 3); !errors.Is(err, ErrReferralCycle) {
+		t.Fatalf("expected ErrReferralCycle, got %v", err)
+	}
+}
+
+func TestEngine_AccrueAndPayout(t *testing.T) {
+	// A(1) 邀请 B(2)，B 邀请 C(3)，C 邀请 D(4)
+	tree := NewMemoryTree()
+	tree.Bind(2, 1)
+	tree.Bind(3, 2)
+	tree.Bind(4, 3)
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	payer, pub := newFakePayer(), &fakePublisher{}
+	e, err := NewEngine(tree, payer, pub, Config{Level1Rate: 2000, Level2Rate: 500, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEngine(tree, payer, nil, Config{Level1Rate: 9000, Level2Rate: 2000}); !errors.Is(err, ErrInvalidRates) {
+		t.Fatalf("expected ErrInvalidRates, got %v", err)
+	}
+
+	// D 付 1000 USDT 手续费：C 一级 200，B 二级 50，A 不分 (超过两级)
+	got := e.OnFill(Fill{TradeID: 1, UserID: 4, Fee: 1000, FeeAsset: "USDT", Time: now})
+	if len(got) != 2 || got[0].ReferrerID != 3 || got[0].Amount != 200 || got[1].ReferrerID != 2 || got[1].Amount != 50 {
+		t.Fatalf("unexpected commissions: %+v", got)
+	}
+	// 重复投递不重复计
+	if dup := e.OnFill(Fill{TradeID: 1, UserID: 4, Fee: 1000, FeeAsset: "USDT", Time: now}); dup != nil {
+		t.Fatalf("duplicate fill accrued: %+v", dup)
+	}
+	// maker 返佣不分佣
+	if neg := e.OnFill(Fill{TradeID: 2, UserID: 4, Fee: -10, FeeAsset: "USDT", Time: now}); neg != nil {
+		t.Fatalf("rebate accrued: %+v", neg)
+	}
+	e.OnFill(Fill{TradeID: 3, UserID: 3, Fee: 500, FeeAsset: "USDT", Time: now})
+
+	day := Day(now)
+	if _, err := e.Payout(context.Background(), day); !errors.Is(err, ErrDayNotClosed) {
+		t.Fatalf("expected ErrDayNotClosed, got %v", err)
+	}
+
+	now = now.Add(24 * time.Hour)
+	res, err := e.Payout(context.Background(), day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// C: 200；B: 50 + 100 (C 的一级)；A: 25 (C 的二级)
+	if res.Payouts != 3 || res.Amounts["USDT"] != 375 {
+		t.Fatalf("unexpected payout result: %+v", res)
+	}
+	if payer.balance[3] != 200 || payer.balance[2] != 150 || payer.balance[1] != 25 {
+		t.Fatalf("unexpected balances: %+v", payer.balance)
+	}
+	if len(pub.events) != 3 || pub.events[0].ChangeType != fund.ChangeTypeCommission {
+		t.Fatalf("unexpected journals: %d", len(pub.events))
+	}
+
+	// 再次日结不重复发放；迟到成交只补差额
+	if res, _ := e.Payout(context.Background(), day); res.Payouts != 0 {
+		t.Fatalf("second payout paid again: %+v", res)
+	}
+	e.OnFill(Fill{TradeID: 4, UserID: 4, Fee: 100, FeeAsset: "USDT", Time: now.Add(-24 * time.Hour)})
+	if res, _ := e.Payout(context.Background(), day); res.Amounts["USDT"] != 25 {
+		t.Fatalf("late fill payout: %+v", res)
+	}
+
+	earnings, err := e.Earnings(2, day, day)
+	if err != nil || len(earnings) != 1 {
+		t.Fatalf("earnings: %+v %v", earnings, err)
+	}
+	if earnings[0].Level1 != 100 || earnings[0].Level2 != 55 || earnings[0].Paid != 155 {
+		t.Fatalf("unexpected earning: %+v", earnings[0])
+	}
+}
+
+func TestEngine_PayoutFailureRetried(t *testing.T) {
+	tree := NewMemoryTree()
+	tree.Bind(2, 1)
+	now := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	payer := newFakePayer()
+	e, _ := NewEngine(tree, payer, nil, Config{Level1Rate: 1000, Now: func() time.Time { return now }})
+
+	e.OnFill(Fill{TradeID: 1, UserID: 2, Fee: 1000, FeeAsset: "BTC", Time: now.Add(-time.Hour)})
+	day := Day(now.Add(-time.Hour))
+
+	payer.fail[1] = true
+	if res, err := e.Payout(context.Background(), day); err == nil || res.Failed != 1 {
+		t.Fatalf("expected failure, got %+v %v", res, err)
+	}
+	payer.fail[1] = false
+	if res, err := e.Payout(context.Background(), day); err != nil || res.Amounts["BTC"] != 100 {
+		t.Fatalf("retry: %+v %v", res, err)
+	}
+	if payer.balance[1] != 100 {
+		t.Fatalf("expected 100, got %d", payer.balance[1])
+	}
+}
+
+func TestEngine_PayoutRetryKeepsAmount(t *testing.T) {
+	tree := NewMemoryTree()
+	tree.Bind(2, 1)
+	now := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	payer := newFakePayer()
+	e, _ := NewEngine(tree, payer, nil, Config{Level1Rate: 1000, Now: func() time.Time { return now }})
+	day := Day(now.Add(-time.Hour))
+
+	// 手续费账户已出账 100，入账用户失败
+	e.OnFill(Fill{TradeID: 1, UserID: 2, Fee: 1000, FeeAsset: "BTC", Time: now.Add(-time.Hour)})
+	payer.failCredit[1] = true
+	if res, err := e.Payout(context.Background(), day); err == nil || res.Failed != 1 {
+		t.Fatalf("expected failure, got %+v %v", res, err)
+	}
+
+	// 重试前到了一笔迟到成交：重试仍按原 payID 付 100，差额 50 另起一笔
+	e.OnFill(Fill{TradeID: 2, UserID: 2, Fee: 500, FeeAsset: "BTC", Time: now.Add(-time.Minute)})
+	payer.failCredit[1] = false
+	res, err := e.Payout(context.Background(), day)
+	if err != nil || res.Payouts != 2 || res.Amounts["BTC"] != 150 {
+		t.Fatalf("retry: %+v %v", res, err)
+	}
+
+	var debited int64
+	for key, amount := range payer.out {
+		if credited, ok := payer.paid[strings.TrimSuffix(key, "_out")]; !ok || credited != amount {
+			t.Errorf("%s debited %d, credited %d", key, amount, credited)
+		}
+		debited += amount
+	}
+	if debited != 150 || payer.balance[1] != 150 {
+		t.Fatalf("fee account debited %d, referrer credited %d", debited, payer.balance[1])
+	}
+	if res, _ := e.Payout(context.Background(), day); res.Payouts != 0 {
+		t.Fatalf("nothing left to pay: %+v", res)
+	}
+}
@@ -0,0 +1,571 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+// =============================================================================
+// 日终关账 (daily close)
+// =============================================================================
+//
+// 【问题】日报从流水汇总，只有"发生了什么"，没有"日终时点账户是什么样"：
+// 用户日盈亏、权益曲线、按日终资产定的手续费等级，都需要一个所有账户同一时刻的快照
+//
+// 【做法】每天在关账时刻 (UTC 0 点 + CloseOffset) 跑一次：
+//   1. 冻结窗口：通知各 Freezer 暂停会改余额的后台动作 (划转、参数生效等)，
+//      在 FreezeWindow 内抓取日终标记价格、全部余额、持仓，抓完立即解冻
+//   2. 按日终标记价格算每个 (用户, 资产) 的权益：余额 (可用 + 冻结) + 未实现盈亏
+//   3. 按持仓算日盈亏：已实现 (累计已实现盈亏与前一日的差) + 资金费 + 未实现盈亏变动
+//   4. 快照、盈亏、关账记录同一事务落库；一天只能关一次 (ErrAlreadyClosed)
+//   5. 轮转：删除超过 Retention 天的用户级明细 (权益、盈亏)，标记价格和关账记录长期保留
+//   6. 回调 OnClosed：对账单、手续费等级等依赖日终数据的任务以此为准，不再自己猜"过了 0 点就算关账"
+//
+// 【面试】为什么快照不能补跑？
+// 日报可以随时从流水重算，快照记录的是"那一刻"的余额和标记价格，过去了就拿不回来。
+// 错过关账时刻 (进程挂了) 时照常补关，但关账记录标记 Late，抓到的是补关时的状态
+//
+// 【注意】
+//   - 已实现盈亏不含手续费 (手续费按成交记在流水里，见对账单)
+//   - 没有标记价格的持仓未实现盈亏按 0 计并计入 MissingMarks
+//   - 前一日没有盈亏行的持仓视为前一日关账时空仓：当日已实现 = 当日平掉的各轮 + 当日新开这一轮的已实现
+//
+// 接线示例：
+//
+//	closer := report.NewCloser(gormSource, report.MarkPriceServiceSource{Service: markPrices}, report.NewGormCloseStore(db), report.CloseConfig{})
+//	closer.OnClosed(func(d report.DailyClose) { go reportJob.RunDay(context.Background(), d.Day) })
+//	closer.Start()
+
+var (
+	ErrAlreadyClosed = errors.New("report: day already closed")
+)
+
+// =============================================================================
+// 日终表 (落库)
+// =============================================================================
+
+// EODMark 日终标记价格
+type EODMark struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Day        string `gorm:"column:day;type:char(10);uniqueIndex:uk_day_symbol" json:"day"`
+	Symbol     string `gorm:"column:symbol;type:varchar(32);uniqueIndex:uk_day_symbol" json:"symbol"`
+	MarkPrice  int64  `gorm:"column:mark_price" json:"mark_price"`
+	CapturedAt int64  `gorm:"column:captured_at" json:"captured_at"` // 毫秒
+}
+
+func (EODMark) TableName() string {
+	return "report_eod_mark"
+}
+
+// AccountEquity 日终账户权益 (每个用户每个资产一行)
+type AccountEquity struct {
+	ID            uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Day           string `gorm:"column:day;type:char(10);uniqueIndex:uk_day_user_asset" json:"day"`
+	UserID        int64  `gorm:"column:user_id;uniqueIndex:uk_day_user_asset" json:"user_id"`
+	Asset         string `gorm:"column:asset;type:varchar(16);uniqueIndex:uk_day_user_asset" json:"asset"`
+	Balance       int64  `gorm:"column:balance" json:"balance"`               // 可用 + 冻结 (含持仓保证金)
+	UnrealizedPnL int64  `gorm:"column:unrealized_pnl" json:"unrealized_pnl"` // 以该资产结算的持仓未实现盈亏
+	Equity        int64  `gorm:"column:equity" json:"equity"`                 // Balance + UnrealizedPnL
+}
+
+func (AccountEquity) TableName() string {
+	return "report_account_equity"
+}
+
+// UserDailyPnL 用户持仓日盈亏 (每个用户每个合约一行)
+type UserDailyPnL struct {
+	ID               uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Day              string `gorm:"column:day;type:char(10);uniqueIndex:uk_day_user_symbol" json:"day"`
+	UserID           int64  `gorm:"column:user_id;uniqueIndex:uk_day_user_symbol" json:"user_id"`
+	Symbol           string `gorm:"column:symbol;type:varchar(32);uniqueIndex:uk_day_user_symbol" json:"symbol"`
+	Currency         string `gorm:"column:currency;type:varchar(16)" json:"currency"`
+	PositionSize     int64  `gorm:"column:position_size" json:"position_size"` // 日终持仓 (正多负空)
+	EntryPrice       int64  `gorm:"column:entry_price" json:"entry_price"`
+	MarkPrice        int64  `gorm:"column:mark_price" json:"mark_price"`
+	CumRealizedPnL   int64  `gorm:"column:cum_realized_pnl" json:"cum_realized_pnl"` // 持仓累计已实现盈亏 (次日的基准)
+	RealizedPnL      int64  `gorm:"column:realized_pnl" json:"realized_pnl"`         // 当日已实现
+	Funding          int64  `gorm:"column:funding" json:"funding"`                   // 当日资金费 (正=收入)
+	UnrealizedPnL    int64  `gorm:"column:unrealized_pnl" json:"unrealized_pnl"`     // 日终未实现
+	UnrealizedChange int64  `gorm:"column:unrealized_change" json:"unrealized_change"`
+	TotalPnL         int64  `gorm:"column:total_pnl" json:"total_pnl"` // RealizedPnL + Funding + UnrealizedChange
+}
+
+func (UserDailyPnL) TableName() string {
+	return "report_user_pnl"
+}
+
+// DailyClose 关账记录，也是关账完成事件
+type DailyClose struct {
+	Day          string `gorm:"column:day;type:char(10);primaryKey" json:"day"`
+	CloseAt      int64  `gorm:"column:close_at" json:"close_at"`       // 应关账时刻 (毫秒)
+	CapturedAt   int64  `gorm:"column:captured_at" json:"captured_at"` // 实际抓取快照时刻
+	CompletedAt  int64  `gorm:"column:completed_at" json:"completed_at"`
+	Late         bool   `gorm:"column:late" json:"late"` // 抓取晚于 CloseAt + FreezeWindow
+	Marks        int    `gorm:"column:marks" json:"marks"`
+	Accounts     int    `gorm:"column:accounts" json:"accounts"`
+	PnLRows      int    `gorm:"column:pnl_rows" json:"pnl_rows"`
+	MissingMarks int    `gorm:"column:missing_marks" json:"missing_marks"` // 有持仓但没有标记价格的合约数
+}
+
+func (DailyClose) TableName() string {
+	return "report_daily_close"
+}
+
+// CloseResult 一次关账的全部数据
+type CloseResult struct {
+	Close    DailyClose
+	Marks    []EODMark       // 按 Symbol 排序
+	Equities []AccountEquity // 按 UserID, Asset 排序
+	PnL      []UserDailyPnL  // 按 UserID, Symbol 排序
+}
+
+// =============================================================================
+// 扩展点
+// =============================================================================
+
+// CloseSource 关账数据源
+//
+// Balances / Positions 是"当前"状态，必须读主库：从库的延迟会让快照跨过关账时刻
+type CloseSource interface {
+	Balances(ctx context.Context) ([]fund.BalanceRecord, error)
+	// Positions 非空持仓 + since 之后变动过的持仓
+	Positions(ctx context.Context, since time.Time) ([]futures.Position, error)
+	// FundingPayments / PositionHistory 区间左闭右开
+	FundingPayments(ctx context.Context, from, to time.Time) ([]futures.FundingPayment, error)
+	PositionHistory(ctx context.Context, from, to time.Time) ([]futures.PositionHistory, error)
+}
+
+// MarkSource 日终标记价格
+type MarkSource interface {
+	MarkPrices(ctx context.Context) (map[string]int64, error)
+}
+
+// MarkPriceServiceSource 从标记价格服务取当前标记价格
+type MarkPriceServiceSource struct {
+	Service *futures.MarkPriceService
+}
+
+// MarkPrices 实现 MarkSource
+func (s MarkPriceServiceSource) MarkPrices(ctx context.Context) (map[string]int64, error) {
+	out := make(map[string]int64)
+	for symbol, info := range s.Service.GetAllPrices() {
+		if info.MarkPrice > 0 {
+			out[symbol] = info.MarkPrice
+		}
+	}
+	return out, nil
+}
+
+// Freezer 关账冻结窗口内需要暂停的组件
+//
+// Freeze 返回错误时本次关账放弃 (已冻结的会被解冻)，下个周期重试
+type Freezer interface {
+	Freeze(ctx context.Context, day string) error
+	Unfreeze(day string)
+}
+
+// =============================================================================
+// Closer
+// =============================================================================
+
+// CloseConfig 关账配置
+type CloseConfig struct {
+	// CloseOffset 关账时刻相对 UTC 0 点的偏移，默认 0 (如 8h 表示北京时间 0 点关账)
+	CloseOffset time.Duration
+	// FreezeWindow 冻结窗口上限 (抓取快照的超时)，默认 30 秒
+	FreezeWindow time.Duration
+	// Retention 用户级明细保留天数，默认 90，<0 不轮转
+	Retention int
+	// Interval 后台检查间隔，默认 10 秒
+	Interval time.Duration
+
+	// Contract 合约规格，反向合约按 ContractSpec.PnL 算未实现盈亏；
+	// 默认不查 (全部按正向合约)，返回 nil 同样按正向合约
+	Contract func(symbol string) *futures.ContractSpec
+	// SettleAsset 合约结算币种，默认取 Contract 的 SettleCurrency，查不到为 USDT
+	SettleAsset func(symbol string) string
+	Now         func() time.Time
+}
+
+func (c *CloseConfig) withDefaults() {
+	if c.FreezeWindow <= 0 {
+		c.FreezeWindow = 30 * time.Second
+	}
+	if c.Retention == 0 {
+		c.Retention = 90
+	}
+	if c.Interval <= 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.Contract == nil {
+		c.Contract = func(string) *futures.ContractSpec { return nil }
+	}
+	if c.SettleAsset == nil {
+		contract := c.Contract
+		c.SettleAsset = func(symbol string) string {
+			if spec := contract(symbol); spec != nil && spec.SettleCurrency != "" {
+				return spec.SettleCurrency
+			}
+			return "USDT"
+		}
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+}
+
+// Closer 日终关账
+type Closer struct {
+	src    CloseSource
+	marks  MarkSource
+	store  CloseStore
+	config CloseConfig
+
+	freezers []Freezer
+	onClosed []func(DailyClose)
+
+	mu       sync.Mutex // 串行化关账
+	lastDone string
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewCloser 创建关账任务
+func NewCloser(src CloseSource, marks MarkSource, store CloseStore, cfg CloseConfig) *Closer {
+	cfg.withDefaults()
+	return &Closer{src: src, marks: marks, store: store, config: cfg, stopChan: make(chan struct{})}
+}
+
+// AddFreezer 注册冻结窗口内暂停的组件，须在 Start 之前调用
+func (c *Closer) AddFreezer(f Freezer) {
+	c.freezers = append(c.freezers, f)
+}
+
+// OnClosed 注册关账完成回调 (对账单、手续费等级等)，须在 Start 之前调用
+func (c *Closer) OnClosed(fn func(DailyClose)) {
+	c.onClosed = append(c.onClosed, fn)
+}
+
+// CloseAt day 的关账时刻
+func (c *Closer) CloseAt(day string) (time.Time, error) {
+	start, err := ParseDay(day)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return start.AddDate(0, 0, 1).Add(c.config.CloseOffset), nil
+}
+
+// dueDay 当前应该已经关账的最近一天
+func (c *Closer) dueDay() string {
+	return c.config.Now().UTC().Add(-c.config.CloseOffset).AddDate(0, 0, -1).Format(DayLayout)
+}
+
+// Close 关账 day (未到关账时刻返回 ErrDayNotClosed，已关过返回 ErrAlreadyClosed)
+func (c *Closer) Close(ctx context.Context, day string) (*CloseResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	closeAt, err := c.CloseAt(day)
+	if err != nil {
+		return nil, err
+	}
+	if closeAt.After(c.config.Now()) {
+		return nil, fmt.Errorf("%w: %s", ErrDayNotClosed, day)
+	}
+	if _, found, err := c.store.GetClose(ctx, day); err != nil {
+		return nil, err
+	} else if found {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyClosed, day)
+	}
+
+	// 1. 冻结窗口内抓快照
+	snap, err := c.capture(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 当日资金费、平仓历史，前一日盈亏 (基准)
+	from := closeAt.AddDate(0, 0, -1)
+	funding, err := c.src.FundingPayments(ctx, from, closeAt)
+	if err != nil {
+		return nil, fmt.Errorf("report: load funding payments: %w", err)
+	}
+	history, err := c.src.PositionHistory(ctx, from, closeAt)
+	if err != nil {
+		return nil, fmt.Errorf("report: load position history: %w", err)
+	}
+	prev, err := c.store.UserPnL(ctx, from.AddDate(0, 0, -1).Format(DayLayout))
+	if err != nil {
+		return nil, fmt.Errorf("report: load previous pnl: %w", err)
+	}
+
+	// 3. 汇总
+	res := c.build(day, from, snap, funding, history, prev)
+	res.Close.CloseAt = closeAt.UnixMilli()
+	res.Close.Late = snap.at.Sub(closeAt) > c.config.FreezeWindow
+	res.Close.CompletedAt = c.config.Now().UnixMilli()
+
+	// 4. 落库
+	if err := c.store.SaveClose(ctx, res); err != nil {
+		return nil, err
+	}
+	if day > c.lastDone {
+		c.lastDone = day
+	}
+	log.Printf("[Close] %s closed: %d marks, %d accounts, %d pnl rows, late=%v",
+		day, res.Close.Marks, res.Close.Accounts, res.Close.PnLRows, res.Close.Late)
+
+	// 5. 轮转
+	if c.config.Retention > 0 {
+		before := from.AddDate(0, 0, -c.config.Retention+1).Format(DayLayout)
+		if n, err := c.store.Prune(ctx, before); err != nil {
+			log.Printf("[Close] prune before %s failed: %v", before, err)
+		} else if n > 0 {
+			log.Printf("[Close] pruned %d rows before %s", n, before)
+		}
+	}
+
+	// 6. 关账完成
+	for _, fn := range c.onClosed {
+		fn(res.Close)
+	}
+	return res, nil
+}
+
+// snapshot 冻结窗口内抓到的状态
+type snapshot struct {
+	at        time.Time
+	marks     map[string]int64
+	balances  []fund.BalanceRecord
+	positions []futures.Position
+}
+
+// capture 冻结 → 抓取 → 解冻
+func (c *Closer) capture(ctx context.Context, day string) (*snapshot, error) {
+	frozen := 0
+	defer func() {
+		for i := frozen - 1; i >= 0; i-- {
+			c.freezers[i].Unfreeze(day)
+		}
+	}()
+	for _, f := range c.freezers {
+		if err := f.Freeze(ctx, day); err != nil {
+			return nil, fmt.Errorf("report: freeze for close %s: %w", day, err)
+		}
+		frozen++
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.FreezeWindow)
+	defer cancel()
+	snap := &snapshot{at: c.config.Now()}
+	var err error
+	if snap.marks, err = c.marks.MarkPrices(ctx); err != nil {
+		return nil, fmt.Errorf("report: load mark prices: %w", err)
+	}
+	if snap.balances, err = c.src.Balances(ctx); err != nil {
+		return nil, fmt.Errorf("report: load balances: %w", err)
+	}
+	closeAt, _ := c.CloseAt(day)
+	if snap.positions, err = c.src.Positions(ctx, closeAt.AddDate(0, 0, -1)); err != nil {
+		return nil, fmt.Errorf("report: load positions: %w", err)
+	}
+	return snap, nil
+}
+
+type userAsset struct {
+	userID int64
+	asset  string
+}
+
+type userSymbol struct {
+	userID int64
+	symbol string
+}
+
+// build 由快照算出权益和日盈亏
+func (c *Closer) build(day string, from time.Time, snap *snapshot, funding []futures.FundingPayment,
+	history []futures.PositionHistory, prev []UserDailyPnL) *CloseResult {
+	res := &CloseResult{Close: DailyClose{Day: day, CapturedAt: snap.at.UnixMilli()}}
+
+	for symbol, price := range snap.marks {
+		res.Marks = append(res.Marks, EODMark{Day: day, Symbol: symbol, MarkPrice: price, CapturedAt: snap.at.UnixMilli()})
+	}
+	sort.Slice(res.Marks, func(i, j int) bool { return res.Marks[i].Symbol < res.Marks[j].Symbol })
+
+	// 权益：余额
+	equities := make(map[userAsset]*AccountEquity)
+	equityOf := func(k userAsset) *AccountEquity {
+		e, ok := equities[k]
+		if !ok {
+			e = &AccountEquity{Day: day, UserID: k.userID, Asset: k.asset}
+			equities[k] = e
+		}
+		return e
+	}
+	for _, b := range snap.balances {
+		if b.Available == 0 && b.Locked == 0 {
+			continue
+		}
+		equityOf(userAsset{b.UserID, b.Symbol}).Balance += b.Available + b.Locked
+	}
+
+	// 盈亏基准
+	prevByKey := make(map[userSymbol]UserDailyPnL, len(prev))
+	for _, p := range prev {
+		prevByKey[userSymbol{p.UserID, p.Symbol}] = p
+	}
+	fundingByKey := make(map[userSymbol]int64)
+	for _, f := range funding {
+		fundingByKey[userSymbol{f.UserID, f.Symbol}] += f.Payment
+	}
+	closedByKey := make(map[userSymbol]int64)
+	for _, h := range history {
+		closedByKey[userSymbol{h.UserID, h.Symbol}] += h.RealizedPnL
+	}
+
+	missing := make(map[string]bool)
+	seen := make(map[userSymbol]bool, len(snap.positions))
+	for i := range snap.positions {
+		pos := &snap.positions[i]
+		k := userSymbol{pos.UserID, pos.Symbol}
+		seen[k] = true
+		row := UserDailyPnL{
+			Day:            day,
+			UserID:         pos.UserID,
+			Symbol:         pos.Symbol,
+			Currency:       c.config.SettleAsset(pos.Symbol),
+			PositionSize:   pos.Size,
+			EntryPrice:     pos.EntryPrice,
+			CumRealizedPnL: pos.RealizedPnL,
+			Funding:        fundingByKey[k],
+		}
+		if pos.Size != 0 {
+			if mark, ok := snap.marks[pos.Symbol]; ok {
+				row.MarkPrice = mark
+				row.UnrealizedPnL = c.config.Contract(pos.Symbol).PnL(pos.Size, pos.EntryPrice, mark)
+			} else {
+				missing[pos.Symbol] = true
+			}
+		}
+
+		p, hadPrev := prevByKey[k]
+		if hadPrev {
+			row.RealizedPnL = pos.RealizedPnL - p.CumRealizedPnL
+			row.UnrealizedChange = row.UnrealizedPnL - p.UnrealizedPnL
+		} else {
+			// 前一日关账时空仓：当日平掉的各轮 + 当日新开、尚未平完的这一轮
+			row.RealizedPnL = closedByKey[k]
+			if pos.Size != 0 && pos.OpenedAt >= from.UnixMilli() {
+				row.RealizedPnL += pos.CyclePnL
+			}
+			row.UnrealizedChange = row.UnrealizedPnL
+		}
+		row.TotalPnL = row.RealizedPnL + row.Funding + row.UnrealizedChange
+		if !hadPrev && pos.Size == 0 && row.RealizedPnL == 0 && row.Funding == 0 {
+			continue // 全天空仓没有变化
+		}
+		res.PnL = append(res.PnL, row)
+		if row.UnrealizedPnL != 0 {
+			equityOf(userAsset{row.UserID, row.Currency}).UnrealizedPnL += row.UnrealizedPnL
+		}
+	}
+	// 前一日有持仓、今天数据源却没返回 (Positions 实现有误)，记日志便于排查
+	for k, p := range prevByKey {
+		if !seen[k] && p.PositionSize != 0 {
+			log.Printf("[Close] %s: position user=%d symbol=%s missing from snapshot", day, k.userID, k.symbol)
+		}
+	}
+
+	for _, e := range equities {
+		e.Equity = e.Balance + e.UnrealizedPnL
+		res.Equities = append(res.Equities, *e)
+	}
+	sort.Slice(res.Equities, func(i, j int) bool {
+		a, b := res.Equities[i], res.Equities[j]
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Asset < b.Asset
+	})
+	sort.Slice(res.PnL, func(i, j int) bool {
+		a, b := res.PnL[i], res.PnL[j]
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Symbol < b.Symbol
+	})
+
+	res.Close.Marks = len(res.Marks)
+	res.Close.Accounts = len(res.Equities)
+	res.Close.PnLRows = len(res.PnL)
+	res.Close.MissingMarks = len(missing)
+	return res
+}
+
+// =============================================================================
+// 后台关账
+// =============================================================================
+
+// Start 启动后台关账：每个检查周期看最近一天是否已关账
+func (c *Closer) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-c.stopChan
+			cancel()
+		}()
+
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+		for {
+			c.tick(ctx)
+			select {
+			case <-c.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *Closer) tick(ctx context.Context) {
+	day := c.dueDay()
+	c.mu.Lock()
+	done := c.lastDone >= day
+	c.mu.Unlock()
+	if done {
+		return
+	}
+	_, err := c.Close(ctx, day)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrAlreadyClosed):
+		// 其他实例或重启前已关账
+		c.mu.Lock()
+		if day > c.lastDone {
+			c.lastDone = day
+		}
+		c.mu.Unlock()
+	default:
+		// 失败下个周期重试
+		log.Printf("[Close] %s failed: %v", day, err)
+	}
+}
+
+// Stop 停止后台关账
+func (c *Closer) Stop() {
+	close(c.stopChan)
+	c.wg.Wait()
+}
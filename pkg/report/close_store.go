@@ -0,0 +1,227 @@
+package report
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+// =============================================================================
+// 关账存储
+// =============================================================================
+
+// CloseStore 关账存储
+type CloseStore interface {
+	// SaveClose 同一事务写入快照、盈亏和关账记录，该日已关账返回 ErrAlreadyClosed
+	SaveClose(ctx context.Context, r *CloseResult) error
+	GetClose(ctx context.Context, day string) (*DailyClose, bool, error)
+	// UserPnL 某一天的用户盈亏 (次日关账的基准)
+	UserPnL(ctx context.Context, day string) ([]UserDailyPnL, error)
+	// Prune 删除 before 之前的用户级明细 (权益、盈亏)，返回删除行数
+	Prune(ctx context.Context, before string) (int64, error)
+}
+
+// MemoryCloseStore 内存存储 (测试 / 单机)
+type MemoryCloseStore struct {
+	mu       sync.RWMutex
+	closes   map[string]DailyClose
+	marks    map[string][]EODMark
+	equities map[string][]AccountEquity
+	pnl      map[string][]UserDailyPnL
+}
+
+// NewMemoryCloseStore 创建内存存储
+func NewMemoryCloseStore() *MemoryCloseStore {
+	return &MemoryCloseStore{
+		closes:   make(map[string]DailyClose),
+		marks:    make(map[string][]EODMark),
+		equities: make(map[string][]AccountEquity),
+		pnl:      make(map[string][]UserDailyPnL),
+	}
+}
+
+func (s *MemoryCloseStore) SaveClose(_ context.Context, r *CloseResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := r.Close.Day
+	if _, ok := s.closes[day]; ok {
+		return ErrAlreadyClosed
+	}
+	s.closes[day] = r.Close
+	s.marks[day] = append([]EODMark(nil), r.Marks...)
+	s.equities[day] = append([]AccountEquity(nil), r.Equities...)
+	s.pnl[day] = append([]UserDailyPnL(nil), r.PnL...)
+	return nil
+}
+
+func (s *MemoryCloseStore) GetClose(_ context.Context, day string) (*DailyClose, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.closes[day]
+	if !ok {
+		return nil, false, nil
+	}
+	return &c, true, nil
+}
+
+func (s *MemoryCloseStore) UserPnL(_ context.Context, day string) ([]UserDailyPnL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]UserDailyPnL(nil), s.pnl[day]...), nil
+}
+
+// Equities 某一天的账户权益
+func (s *MemoryCloseStore) Equities(day string) []AccountEquity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]AccountEquity(nil), s.equities[day]...)
+}
+
+func (s *MemoryCloseStore) Prune(_ context.Context, before string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for day := range s.equities {
+		if day < before {
+			n += int64(len(s.equities[day]))
+			delete(s.equities, day)
+		}
+	}
+	for day := range s.pnl {
+		if day < before {
+			n += int64(len(s.pnl[day]))
+			delete(s.pnl, day)
+		}
+	}
+	return n, nil
+}
+
+// GormCloseStore MySQL 存储 (report.sql)
+type GormCloseStore struct {
+	db *gorm.DB
+}
+
+// NewGormCloseStore 创建 MySQL 存储
+func NewGormCloseStore(db *gorm.DB) *GormCloseStore {
+	return &GormCloseStore{db: db}
+}
+
+// pruneBatch 轮转时每次删除的行数，避免一条大 DELETE 长时间锁表、撑大 binlog
+const pruneBatch = 5000
+
+// SaveClose 关账记录主键冲突 (其他实例抢先关账) 时整体回滚
+func (s *GormCloseStore) SaveClose(ctx context.Context, r *CloseResult) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&r.Close).Error; err != nil {
+			return err
+		}
+		if len(r.Marks) > 0 {
+			if err := tx.CreateInBatches(&r.Marks, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(r.Equities) > 0 {
+			if err := tx.CreateInBatches(&r.Equities, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(r.PnL) > 0 {
+			if err := tx.CreateInBatches(&r.PnL, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrAlreadyClosed
+	}
+	return err
+}
+
+func (s *GormCloseStore) GetClose(ctx context.Context, day string) (*DailyClose, bool, error) {
+	var c DailyClose
+	err := s.db.WithContext(ctx).Where("day = ?", day).Take(&c).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &c, true, nil
+}
+
+func (s *GormCloseStore) UserPnL(ctx context.Context, day string) ([]UserDailyPnL, error) {
+	var rows []UserDailyPnL
+	err := s.db.WithContext(ctx).Where("day = ?", day).Order("user_id, symbol").Find(&rows).Error
+	return rows, err
+}
+
+func (s *GormCloseStore) Prune(ctx context.Context, before string) (int64, error) {
+	var total int64
+	for _, table := range []string{AccountEquity{}.TableName(), UserDailyPnL{}.TableName()} {
+		for {
+			res := s.db.WithContext(ctx).Exec("DELETE FROM "+table+" WHERE day < ? LIMIT ?", before, pruneBatch)
+			if res.Error != nil {
+				return total, res.Error
+			}
+			total += res.RowsAffected
+			if res.RowsAffected < pruneBatch {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// =============================================================================
+// MySQL 关账数据源 (GormSource 实现 CloseSource)
+// =============================================================================
+
+// Balances 全部非零余额，读主库
+func (s *GormSource) Balances(ctx context.Context) ([]fund.BalanceRecord, error) {
+	tables := []string{"balances"}
+	if !s.useSingleTable {
+		tables = tables[:0]
+		for shard := 0; shard < fund.NumShards; shard++ {
+			tables = append(tables, fund.GetTableName("balance", int64(shard)))
+		}
+	}
+	var out []fund.BalanceRecord
+	for _, table := range tables {
+		var rows []fund.BalanceRecord
+		err := s.fundDB.WithContext(ctx).Table(table).
+			Where("available <> 0 OR locked <> 0").
+			Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rows...)
+	}
+	return out, nil
+}
+
+// Positions 非空持仓 + since 之后变动过的持仓，读主库
+func (s *GormSource) Positions(ctx context.Context, since time.Time) ([]futures.Position, error) {
+	var rows []futures.Position
+	err := s.futuresDB.WithContext(ctx).
+		Where("size <> 0 OR updated_at >= ?", since.UnixMilli()).
+		Find(&rows).Error
+	return rows, err
+}
+
+// PositionHistory 区间内平仓的持仓历史
+func (s *GormSource) PositionHistory(ctx context.Context, from, to time.Time) ([]futures.PositionHistory, error) {
+	var rows []futures.PositionHistory
+	err := s.futures(ctx).
+		Where("closed_at >= ? AND closed_at < ?", from.UnixMilli(), to.UnixMilli()).
+		Find(&rows).Error
+	return rows, err
+}
+
+var _ CloseSource = (*GormSource)(nil)
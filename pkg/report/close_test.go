@@ -0,0 +1,211 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+type memCloseSource struct {
+	memSource
+	balances  []fund.BalanceRecord
+	positions []futures.Position
+	history   []futures.PositionHistory
+}
+
+func (s *memCloseSource) Balances(context.Context) ([]fund.BalanceRecord, error) {
+	return s.balances, nil
+}
+
+func (s *memCloseSource) Positions(_ context.Context, since time.Time) ([]futures.Position, error) {
+	var out []futures.Position
+	for _, p := range s.positions {
+		if p.Size != 0 || p.UpdatedAt >= since.UnixMilli() {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (s *memCloseSource) PositionHistory(_ context.Context, from, to time.Time) ([]futures.PositionHistory, error) {
+	var out []futures.PositionHistory
+	for _, h := range s.history {
+		if h.ClosedAt >= from.UnixMilli() && h.ClosedAt < to.UnixMilli() {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+type fixedMarks map[string]int64
+
+func (m fixedMarks) MarkPrices(context.Context) (map[string]int64, error) { return m, nil }
+
+type recordFreezer struct {
+	calls []string
+	err   error
+}
+
+func (f *recordFreezer) Freeze(_ context.Context, day string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.calls = append(f.calls, "freeze "+day)
+	return nil
+}
+
+func (f *recordFreezer) Unfreeze(day string) { f.calls = append(f.calls, "unfreeze "+day) }
+
+func TestCloser_DailyClose(t *testing.T) {
+	const p = futures.Precision
+	at := func(s string) time.Time { v, _ := time.Parse(time.RFC3339, s); return v }
+	now := at("2026-03-02T00:00:05Z")
+
+	src := &memCloseSource{
+		memSource: memSource{funding: []futures.FundingPayment{
+			{UserID: 1, Symbol: "BTC_USDT", Payment: -5 * p, FundingTime: at("2026-03-01T08:00:00Z").UnixMilli()},
+		}},
+		balances: []fund.BalanceRecord{
+			{UserID: 1, Symbol: "USDT", Available: 1000 * p, Locked: 200 * p},
+			{UserID: 2, Symbol: "BTC", Available: p},
+			{UserID: 3, Symbol: "USDT"}, // 零余额不进快照
+		},
+		positions: []futures.Position{{
+			UserID: 1, Symbol: "BTC_USDT", Size: p, EntryPrice: 50000 * p,
+			RealizedPnL: 30 * p, CyclePnL: 30 * p, OpenedAt: at("2026-03-01T10:00:00Z").UnixMilli(),
+		}},
+	}
+	marks := fixedMarks{"BTC_USDT": 51000 * p}
+	store := NewMemoryCloseStore()
+	c := NewCloser(src, marks, store, CloseConfig{Retention: 1, Now: func() time.Time { return now }})
+	freezer := &recordFreezer{}
+	c.AddFreezer(freezer)
+	var closed []DailyClose
+	c.OnClosed(func(d DailyClose) { closed = append(closed, d) })
+
+	ctx := context.Background()
+	if _, err := c.Close(ctx, "2026-03-02"); !errors.Is(err, ErrDayNotClosed) {
+		t.Fatalf("expected ErrDayNotClosed, got %v", err)
+	}
+	res, err := c.Close(ctx, "2026-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.PnL) != 1 {
+		t.Fatalf("pnl rows %+v", res.PnL)
+	}
+	// 当日新开仓：已实现取本轮，未实现全部计入变动
+	row := res.PnL[0]
+	if row.RealizedPnL != 30*p || row.Funding != -5*p || row.UnrealizedPnL != 1000*p || row.TotalPnL != 1025*p {
+		t.Fatalf("day 1 pnl %+v", row)
+	}
+	if len(res.Equities) != 2 || res.Equities[0].Equity != 2200*p || res.Equities[1].Asset != "BTC" {
+		t.Fatalf("equities %+v", res.Equities)
+	}
+	if res.Close.Late || res.Close.Marks != 1 || res.Close.CloseAt != at("2026-03-02T00:00:00Z").UnixMilli() {
+		t.Fatalf("close record %+v", res.Close)
+	}
+	if _, err := c.Close(ctx, "2026-03-01"); !errors.Is(err, ErrAlreadyClosed) {
+		t.Fatalf("expected ErrAlreadyClosed, got %v", err)
+	}
+
+	// 第二天：部分平仓赚 20，标记价格回落
+	now = at("2026-03-03T00:00:05Z")
+	src.positions[0].RealizedPnL = 50 * p
+	src.positions[0].CyclePnL = 50 * p
+	marks["BTC_USDT"] = 50500 * p
+	res, err = c.Close(ctx, "2026-03-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row = res.PnL[0]
+	if row.RealizedPnL != 20*p || row.Funding != 0 || row.UnrealizedChange != -500*p || row.TotalPnL != -480*p {
+		t.Fatalf("day 2 pnl %+v", row)
+	}
+
+	// 保留 1 天：前一天的用户明细已轮转
+	if rows, _ := store.UserPnL(ctx, "2026-03-01"); len(rows) != 0 || len(store.Equities("2026-03-01")) != 0 {
+		t.Fatalf("day 1 rows not pruned: %+v", rows)
+	}
+	if len(closed) != 2 || closed[1].Day != "2026-03-02" {
+		t.Fatalf("close events %+v", closed)
+	}
+	if len(freezer.calls) != 4 || freezer.calls[1] != "unfreeze 2026-03-01" {
+		t.Fatalf("freezer calls %v", freezer.calls)
+	}
+}
+
+// TestCloser_InverseContract 反向合约按 ContractSpec.PnL 算未实现盈亏，权益记在结算币种 (BTC) 上
+func TestCloser_InverseContract(t *testing.T) {
+	const p = futures.Precision
+	now, _ := time.Parse(time.RFC3339, "2026-03-02T00:00:05Z")
+
+	inverse := &futures.ContractSpec{Symbol: "BTC_USD", SettleCurrency: "BTC", Inverse: true, ContractSize: 100 * p}
+	src := &memCloseSource{
+		balances: []fund.BalanceRecord{{UserID: 1, Symbol: "BTC", Available: 3 * p}},
+		positions: []futures.Position{
+			// 1000 张 × 100 USD，开仓 50000：价值 2 BTC
+			{UserID: 1, Symbol: "BTC_USD", Size: 1000 * p, EntryPrice: 50000 * p, OpenedAt: now.Add(-time.Hour).UnixMilli()},
+			{UserID: 2, Symbol: "BTC_USDT", Size: p, EntryPrice: 50000 * p, OpenedAt: now.Add(-time.Hour).UnixMilli()},
+		},
+	}
+	marks := fixedMarks{"BTC_USD": 40000 * p, "BTC_USDT": 40000 * p}
+	c := NewCloser(src, marks, NewMemoryCloseStore(), CloseConfig{
+		Now: func() time.Time { return now },
+		Contract: func(symbol string) *futures.ContractSpec {
+			if symbol == inverse.Symbol {
+				return inverse
+			}
+			return nil
+		},
+	})
+
+	res, err := c.Close(context.Background(), "2026-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]UserDailyPnL)
+	for _, row := range res.PnL {
+		rows[row.Symbol] = row
+	}
+	// 标记价格 40000 时价值 2.5 BTC，多头亏 0.5 BTC
+	if row := rows["BTC_USD"]; row.Currency != "BTC" || row.UnrealizedPnL != -p/2 {
+		t.Fatalf("inverse pnl %+v", row)
+	}
+	// 正向合约不受影响
+	if row := rows["BTC_USDT"]; row.Currency != "USDT" || row.UnrealizedPnL != -10000*p {
+		t.Fatalf("linear pnl %+v", row)
+	}
+	for _, e := range res.Equities {
+		if e.UserID == 1 && (e.Asset != "BTC" || e.Equity != 3*p-p/2) {
+			t.Fatalf("inverse equity %+v", e)
+		}
+	}
+}
+
+func TestCloser_FreezeFailure(t *testing.T) {
+	now := time.Date(2026, 3, 2, 0, 0, 1, 0, time.UTC)
+	store := NewMemoryCloseStore()
+	c := NewCloser(&memCloseSource{}, fixedMarks{}, store, CloseConfig{CloseOffset: -time.Hour, Now: func() time.Time { return now }})
+	first, failing := &recordFreezer{}, &recordFreezer{err: errors.New("busy")}
+	c.AddFreezer(first)
+	c.AddFreezer(failing)
+
+	// CloseOffset -1h：3 月 1 日在 2 日 23:00 前一小时关账
+	if got := c.dueDay(); got != "2026-03-01" {
+		t.Fatalf("due day %s", got)
+	}
+	if _, err := c.Close(context.Background(), "2026-03-01"); err == nil {
+		t.Fatal("freeze failure should abort close")
+	}
+	if len(first.calls) != 2 || first.calls[1] != "unfreeze 2026-03-01" {
+		t.Fatalf("already frozen component must be released: %v", first.calls)
+	}
+	if _, found, _ := store.GetClose(context.Background(), "2026-03-01"); found {
+		t.Fatal("failed close must not be recorded")
+	}
+}
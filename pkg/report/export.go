@@ -0,0 +1,156 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"max.com/pkg/asset"
+)
+
+// =============================================================================
+// CSV 导出
+// =============================================================================
+
+// 金额列按 asset.Precision 输出为十进制，方便财务直接用表格打开
+var (
+	symbolHeader = []string{
+		"day", "symbol", "base_asset", "quote_asset", "trades", "base_volume", "quote_volume",
+		"fee_base", "fee_quote", "rebate_base", "rebate_quote",
+		"funding_paid", "funding_received", "liquidations",
+	}
+	exchangeHeader = []string{
+		"day", "asset", "trades", "quote_volume", "fees", "rebates", "commissions", "net_fee_revenue",
+		"funding_paid", "funding_received", "insurance_delta", "liquidations",
+	}
+)
+
+// WriteSymbolsCSV 导出分交易对日报
+func WriteSymbolsCSV(w io.Writer, rows []SymbolDaily) error {
+	cw := csv.NewWriter(w)
+	cw.Write(symbolHeader)
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Day, r.Symbol, r.BaseAsset, r.QuoteAsset, strconv.FormatInt(r.Trades, 10),
+			decimal(r.BaseVolume), decimal(r.QuoteVolume),
+			decimal(r.FeeBase), decimal(r.FeeQuote), decimal(r.RebateBase), decimal(r.RebateQuote),
+			decimal(r.FundingPaid), decimal(r.FundingReceived), strconv.FormatInt(r.Liquidations, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteExchangeCSV 导出全站日报
+func WriteExchangeCSV(w io.Writer, rows []ExchangeDaily) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exchangeHeader)
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Day, r.Asset, strconv.FormatInt(r.Trades, 10), decimal(r.QuoteVolume),
+			decimal(r.Fees), decimal(r.Rebates), decimal(r.Commissions), decimal(r.NetFeeRevenue),
+			decimal(r.FundingPaid), decimal(r.FundingReceived), decimal(r.InsuranceDelta),
+			strconv.FormatInt(r.Liquidations, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// decimal 定点数转十进制字符串，固定 8 位小数
+func decimal(v int64) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign, u = "-", uint64(-v)
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, u/asset.Precision, u%asset.Precision)
+}
+
+// =============================================================================
+// HTTP 接口 (仅内网 / 运营后台)
+// =============================================================================
+
+// NewHandler 创建报表查询接口
+//
+//	GET /reports/symbols?from=2026-01-01&to=2026-01-31&symbol=BTC_USDT&format=csv
+//	GET /reports/exchange?from=&to=&asset=USDT&format=csv
+//	    format 默认 json；csv 以附件形式下载
+func NewHandler(store Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reports/symbols", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := parseQuery(w, r, "symbol")
+		if !ok {
+			return
+		}
+		rows, err := store.Symbols(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("format") == "csv" {
+			setCSVHeaders(w, "symbols", q)
+			WriteSymbolsCSV(w, rows)
+			return
+		}
+		writeJSON(w, rows)
+	})
+	mux.HandleFunc("/reports/exchange", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := parseQuery(w, r, "asset")
+		if !ok {
+			return
+		}
+		rows, err := store.Exchange(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("format") == "csv" {
+			setCSVHeaders(w, "exchange", q)
+			WriteExchangeCSV(w, rows)
+			return
+		}
+		writeJSON(w, rows)
+	})
+	return mux
+}
+
+func parseQuery(w http.ResponseWriter, r *http.Request, keyParam string) (Query, bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return Query{}, false
+	}
+	v := r.URL.Query()
+	q := Query{From: v.Get("from"), To: v.Get("to"), Key: v.Get(keyParam)}
+	for _, day := range []string{q.From, q.To} {
+		if day == "" {
+			continue
+		}
+		if _, err := ParseDay(day); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return Query{}, false
+		}
+	}
+	return q, true
+}
+
+func setCSVHeaders(w http.ResponseWriter, kind string, q Query) {
+	name := "report_" + kind
+	if q.From != "" {
+		name += "_" + q.From
+	}
+	if q.To != "" {
+		name += "_" + q.To
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
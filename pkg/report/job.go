@@ -0,0 +1,126 @@
+package report
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 日终跑批
+// =============================================================================
+
+// JobConfig 跑批配置
+type JobConfig struct {
+	Build BuildConfig
+
+	// Delay 日终后等待多久再出报表，给延迟落库的流水留时间，默认 10 分钟
+	Delay time.Duration
+	// Interval 检查间隔，默认 1 分钟
+	Interval time.Duration
+	// Backfill 启动时补跑最近几天 (已生成的会被覆盖)，默认 0
+	Backfill int
+}
+
+// Job 每天生成前一天的报表
+type Job struct {
+	src    Source
+	store  Store
+	config JobConfig
+
+	mu       sync.Mutex
+	lastDone string // 最近一次成功生成的日期
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewJob 创建跑批任务
+func NewJob(src Source, store Store, cfg JobConfig) *Job {
+	cfg.Build.withDefaults()
+	if cfg.Delay <= 0 {
+		cfg.Delay = 10 * time.Minute
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	return &Job{src: src, store: store, config: cfg, stopChan: make(chan struct{})}
+}
+
+// RunDay 生成并保存某一天的报表 (可手工重跑)
+func (j *Job) RunDay(ctx context.Context, day string) (*Daily, error) {
+	d, err := Build(ctx, j.src, day, j.config.Build)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.store.Save(ctx, d); err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	if day > j.lastDone {
+		j.lastDone = day
+	}
+	j.mu.Unlock()
+	log.Printf("[Report] %s generated: %d symbols, %d assets, %d incomplete trades",
+		day, len(d.Symbols), len(d.Exchange), d.IncompleteTrades)
+	return d, nil
+}
+
+// dueDay 当前应该已经生成的最近一天
+func (j *Job) dueDay() string {
+	return j.config.Build.Now().Add(-j.config.Delay).UTC().AddDate(0, 0, -1).Format(DayLayout)
+}
+
+// Start 启动后台跑批
+func (j *Job) Start() {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-j.stopChan
+			cancel()
+		}()
+
+		due, _ := ParseDay(j.dueDay())
+		for i := j.config.Backfill; i > 0; i-- {
+			day := due.AddDate(0, 0, -i).Format(DayLayout)
+			if _, err := j.RunDay(ctx, day); err != nil {
+				log.Printf("[Report] backfill %s failed: %v", day, err)
+			}
+		}
+
+		ticker := time.NewTicker(j.config.Interval)
+		defer ticker.Stop()
+		for {
+			j.tick(ctx)
+			select {
+			case <-j.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (j *Job) tick(ctx context.Context) {
+	day := j.dueDay()
+	j.mu.Lock()
+	done := j.lastDone >= day
+	j.mu.Unlock()
+	if done {
+		return
+	}
+	if _, err := j.RunDay(ctx, day); err != nil {
+		// 失败下个周期重试
+		log.Printf("[Report] %s failed: %v", day, err)
+	}
+}
+
+// Stop 停止后台跑批
+func (j *Job) Stop() {
+	close(j.stopChan)
+	j.wg.Wait()
+}
@@ -0,0 +1,301 @@
+// Package report 运营报表：按日汇总流水、资金费和保险基金流水，生成
+// 分交易对日报 (成交量、手续费、资金费、强平次数) 和全站日报 (按资产)。
+//
+// 【数据来源】
+//
+//	流水 (fund.JournalRecord)            → 现货成交量、手续费 / maker 返佣 / 推荐返佣
+//	资金费 (futures.FundingPayment)      → 用户支付 / 收取的资金费
+//	保险基金流水 (futures.InsuranceFundLog) → 保险基金变动、强平次数
+//
+// 成交没有单独落库，现货成交从 TRADE 流水还原：每笔成交有买方 (报价币) 和
+// 卖方 (基础币) 两条 TRANSFER 流水，BizID 都是成交 ID，两条合起来就得到交易对和成交额。
+// 合约成交不发流水，合约交易对只有资金费和强平数据。
+//
+// 【面试】为什么从流水出报表，而不是在撮合时实时累加？
+// 流水是资金的唯一事实来源，对账也以它为准；实时累加的计数器重启会丢、重放会重，
+// 报表和账对不上时谁也说不清哪个错。日报在日终后跑批，可以重跑覆盖 (按 日期+维度 upsert)。
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrInvalidDay   = errors.New("report: invalid day, want YYYY-MM-DD")
+	ErrDayNotClosed = errors.New("report: day not closed yet")
+)
+
+// DayLayout 报表日期格式 (UTC)
+const DayLayout = "2006-01-02"
+
+// ParseDay 解析报表日期，返回当天 00:00 UTC
+func ParseDay(day string) (time.Time, error) {
+	t, err := time.ParseInLocation(DayLayout, day, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidDay, day)
+	}
+	return t, nil
+}
+
+// =============================================================================
+// 报表行 (落库)
+// =============================================================================
+
+// SymbolDaily 分交易对日报
+//
+// 现货手续费按币种分两列：买方手续费扣基础币，卖方扣报价币
+type SymbolDaily struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Day             string `gorm:"column:day;type:char(10);uniqueIndex:uk_day_symbol" json:"day"`
+	Symbol          string `gorm:"column:symbol;type:varchar(32);uniqueIndex:uk_day_symbol" json:"symbol"`
+	BaseAsset       string `gorm:"column:base_asset" json:"base_asset"`
+	QuoteAsset      string `gorm:"column:quote_asset" json:"quote_asset"`
+	Trades          int64  `gorm:"column:trades" json:"trades"`
+	BaseVolume      int64  `gorm:"column:base_volume" json:"base_volume"`
+	QuoteVolume     int64  `gorm:"column:quote_volume" json:"quote_volume"`
+	FeeBase         int64  `gorm:"column:fee_base" json:"fee_base"`
+	FeeQuote        int64  `gorm:"column:fee_quote" json:"fee_quote"`
+	RebateBase      int64  `gorm:"column:rebate_base" json:"rebate_base"`
+	RebateQuote     int64  `gorm:"column:rebate_quote" json:"rebate_quote"`
+	FundingPaid     int64  `gorm:"column:funding_paid" json:"funding_paid"`         // 用户支付的资金费 (正数)
+	FundingReceived int64  `gorm:"column:funding_received" json:"funding_received"` // 用户收取的资金费
+	Liquidations    int64  `gorm:"column:liquidations" json:"liquidations"`
+	GeneratedAt     int64  `gorm:"column:generated_at" json:"generated_at"` // 毫秒
+}
+
+func (SymbolDaily) TableName() string {
+	return "report_symbol_daily"
+}
+
+// ExchangeDaily 全站日报 (按资产，不同币种的金额不能相加)
+//
+// NetFeeRevenue = Fees - Rebates - Commissions，即平台当日手续费净收入
+type ExchangeDaily struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	Day             string `gorm:"column:day;type:char(10);uniqueIndex:uk_day_asset" json:"day"`
+	Asset           string `gorm:"column:asset;type:varchar(16);uniqueIndex:uk_day_asset" json:"asset"`
+	Trades          int64  `gorm:"column:trades" json:"trades"`             // 以该资产报价的成交笔数
+	QuoteVolume     int64  `gorm:"column:quote_volume" json:"quote_volume"` // 以该资产报价的成交额
+	Fees            int64  `gorm:"column:fees" json:"fees"`
+	Rebates         int64  `gorm:"column:rebates" json:"rebates"`         // maker 返佣
+	Commissions     int64  `gorm:"column:commissions" json:"commissions"` // 推荐返佣
+	NetFeeRevenue   int64  `gorm:"column:net_fee_revenue" json:"net_fee_revenue"`
+	FundingPaid     int64  `gorm:"column:funding_paid" json:"funding_paid"`
+	FundingReceived int64  `gorm:"column:funding_received" json:"funding_received"`
+	InsuranceDelta  int64  `gorm:"column:insurance_delta" json:"insurance_delta"` // 保险基金当日净变动 (含注资/提取)
+	Liquidations    int64  `gorm:"column:liquidations" json:"liquidations"`
+	GeneratedAt     int64  `gorm:"column:generated_at" json:"generated_at"`
+}
+
+func (ExchangeDaily) TableName() string {
+	return "report_exchange_daily"
+}
+
+// Daily 一天的报表
+type Daily struct {
+	Day      string
+	Symbols  []SymbolDaily   // 按 Symbol 排序
+	Exchange []ExchangeDaily // 按 Asset 排序
+
+	// IncompleteTrades 只找到单边 TRANSFER 流水的成交 (跨日或流水缺失)，
+	// 不计入交易对成交量，手续费仍计入全站
+	IncompleteTrades int
+}
+
+// =============================================================================
+// 数据源
+// =============================================================================
+
+// Source 报表数据源，区间左闭右开
+type Source interface {
+	Journals(ctx context.Context, from, to time.Time) ([]fund.JournalRecord, error)
+	FundingPayments(ctx context.Context, from, to time.Time) ([]futures.FundingPayment, error)
+	InsuranceLogs(ctx context.Context, from, to time.Time) ([]futures.InsuranceFundLog, error)
+}
+
+// =============================================================================
+// 汇总
+// =============================================================================
+
+// BuildConfig 汇总配置
+type BuildConfig struct {
+	// SettleAsset 合约结算币种 (资金费计入哪个资产)，默认 USDT
+	SettleAsset func(symbol string) string
+	Now         func() time.Time
+}
+
+func (c *BuildConfig) withDefaults() {
+	if c.SettleAsset == nil {
+		c.SettleAsset = func(string) string { return "USDT" }
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+}
+
+// spotTrade 从流水还原的一笔现货成交
+type spotTrade struct {
+	base, quote       string
+	qty, quoteAmount  int64
+	hasBuyer, hasSell bool
+	fees              []feeLeg
+}
+
+type feeLeg struct {
+	asset  string
+	amount int64
+	rebate bool
+}
+
+// Build 汇总 day 当天 (UTC) 的报表
+func Build(ctx context.Context, src Source, day string, cfg BuildConfig) (*Daily, error) {
+	cfg.withDefaults()
+	from, err := ParseDay(day)
+	if err != nil {
+		return nil, err
+	}
+	to := from.AddDate(0, 0, 1)
+	if to.After(cfg.Now()) {
+		return nil, fmt.Errorf("%w: %s", ErrDayNotClosed, day)
+	}
+
+	journals, err := src.Journals(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("report: load journals: %w", err)
+	}
+	funding, err := src.FundingPayments(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("report: load funding payments: %w", err)
+	}
+	insurance, err := src.InsuranceLogs(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("report: load insurance logs: %w", err)
+	}
+
+	generatedAt := cfg.Now().UnixMilli()
+	symbols := make(map[string]*SymbolDaily)
+	assets := make(map[string]*ExchangeDaily)
+	symbolRow := func(symbol string) *SymbolDaily {
+		r, ok := symbols[symbol]
+		if !ok {
+			r = &SymbolDaily{Day: day, Symbol: symbol, GeneratedAt: generatedAt}
+			symbols[symbol] = r
+		}
+		return r
+	}
+	assetRow := func(asset string) *ExchangeDaily {
+		r, ok := assets[asset]
+		if !ok {
+			r = &ExchangeDaily{Day: day, Asset: asset, GeneratedAt: generatedAt}
+			assets[asset] = r
+		}
+		return r
+	}
+
+	// 1. 流水：手续费 / 返佣直接按资产累计，成交按 BizID 归并
+	trades := make(map[string]*spotTrade)
+	for i := range journals {
+		j := &journals[i]
+		switch j.ChangeType {
+		case fund.ChangeTypeFee:
+			assetRow(j.Symbol).Fees += j.Amount
+		case fund.ChangeTypeRebate:
+			assetRow(j.Symbol).Rebates += j.Amount
+		case fund.ChangeTypeCommission:
+			assetRow(j.Symbol).Commissions += j.Amount
+		}
+		if j.BizType != fund.BizTypeTrade {
+			continue
+		}
+		t, ok := trades[j.BizID]
+		if !ok {
+			t = &spotTrade{}
+			trades[j.BizID] = t
+		}
+		switch {
+		case j.ChangeType == fund.ChangeTypeTransfer && strings.HasSuffix(j.EventID, "_buyer"):
+			t.quote, t.quoteAmount, t.hasBuyer = j.Symbol, j.Amount, true
+		case j.ChangeType == fund.ChangeTypeTransfer && strings.HasSuffix(j.EventID, "_seller"):
+			t.base, t.qty, t.hasSell = j.Symbol, j.Amount, true
+		case j.ChangeType == fund.ChangeTypeFee, j.ChangeType == fund.ChangeTypeRebate:
+			t.fees = append(t.fees, feeLeg{asset: j.Symbol, amount: j.Amount, rebate: j.ChangeType == fund.ChangeTypeRebate})
+		}
+	}
+
+	daily := &Daily{Day: day}
+	for _, t := range trades {
+		if !t.hasBuyer || !t.hasSell {
+			daily.IncompleteTrades++
+			continue
+		}
+		r := symbolRow(t.base + "_" + t.quote)
+		r.BaseAsset, r.QuoteAsset = t.base, t.quote
+		r.Trades++
+		r.BaseVolume += t.qty
+		r.QuoteVolume += t.quoteAmount
+		for _, f := range t.fees {
+			switch {
+			case f.asset == t.base && f.rebate:
+				r.RebateBase += f.amount
+			case f.asset == t.base:
+				r.FeeBase += f.amount
+			case f.rebate:
+				r.RebateQuote += f.amount
+			default:
+				r.FeeQuote += f.amount
+			}
+		}
+		a := assetRow(t.quote)
+		a.Trades++
+		a.QuoteVolume += t.quoteAmount
+	}
+
+	// 2. 资金费：Payment 正数为用户收入
+	for i := range funding {
+		p := &funding[i]
+		r, a := symbolRow(p.Symbol), assetRow(cfg.SettleAsset(p.Symbol))
+		if p.Payment >= 0 {
+			r.FundingReceived += p.Payment
+			a.FundingReceived += p.Payment
+		} else {
+			r.FundingPaid -= p.Payment
+			a.FundingPaid -= p.Payment
+		}
+	}
+
+	// 3. 保险基金：每次强平结算产生一条盈余注入或穿仓兜底流水，据此计数
+	for i := range insurance {
+		l := &insurance[i]
+		a := assetRow(l.Currency)
+		a.InsuranceDelta += l.Amount
+		if l.ChangeType == futures.InsuranceChangeLiquidationProfit || l.ChangeType == futures.InsuranceChangeBankruptCover {
+			a.Liquidations++
+			if l.RelatedSymbol != "" {
+				symbolRow(l.RelatedSymbol).Liquidations++
+			}
+		}
+	}
+
+	for _, r := range symbols {
+		daily.Symbols = append(daily.Symbols, *r)
+	}
+	sort.Slice(daily.Symbols, func(i, j int) bool { return daily.Symbols[i].Symbol < daily.Symbols[j].Symbol })
+	for _, a := range assets {
+		a.NetFeeRevenue = a.Fees - a.Rebates - a.Commissions
+		daily.Exchange = append(daily.Exchange, *a)
+	}
+	sort.Slice(daily.Exchange, func(i, j int) bool { return daily.Exchange[i].Asset < daily.Exchange[j].Asset })
+	return daily, nil
+}
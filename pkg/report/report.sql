@@ -0,0 +1,102 @@
+-- 运营日报 (UTC 自然日，金额为 1e8 定点数)
+-- 重跑同一天时先删后写，见 GormStore.Save
+
+-- 分交易对日报
+CREATE TABLE IF NOT EXISTS `report_symbol_daily` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `day` CHAR(10) NOT NULL COMMENT 'YYYY-MM-DD',
+    `symbol` VARCHAR(32) NOT NULL COMMENT '现货 BTC_USDT / 合约 BTCUSDT',
+    `base_asset` VARCHAR(16) NOT NULL DEFAULT '',
+    `quote_asset` VARCHAR(16) NOT NULL DEFAULT '',
+    `trades` BIGINT NOT NULL DEFAULT 0,
+    `base_volume` BIGINT NOT NULL DEFAULT 0,
+    `quote_volume` BIGINT NOT NULL DEFAULT 0,
+    `fee_base` BIGINT NOT NULL DEFAULT 0 COMMENT '买方手续费 (基础币)',
+    `fee_quote` BIGINT NOT NULL DEFAULT 0 COMMENT '卖方手续费 (报价币)',
+    `rebate_base` BIGINT NOT NULL DEFAULT 0,
+    `rebate_quote` BIGINT NOT NULL DEFAULT 0,
+    `funding_paid` BIGINT NOT NULL DEFAULT 0 COMMENT '用户支付的资金费',
+    `funding_received` BIGINT NOT NULL DEFAULT 0 COMMENT '用户收取的资金费',
+    `liquidations` BIGINT NOT NULL DEFAULT 0,
+    `generated_at` BIGINT NOT NULL COMMENT '毫秒',
+    UNIQUE KEY `uk_day_symbol` (`day`, `symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '分交易对日报';
+
+-- 全站日报 (按资产)
+CREATE TABLE IF NOT EXISTS `report_exchange_daily` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `day` CHAR(10) NOT NULL COMMENT 'YYYY-MM-DD',
+    `asset` VARCHAR(16) NOT NULL,
+    `trades` BIGINT NOT NULL DEFAULT 0 COMMENT '以该资产报价的成交笔数',
+    `quote_volume` BIGINT NOT NULL DEFAULT 0,
+    `fees` BIGINT NOT NULL DEFAULT 0,
+    `rebates` BIGINT NOT NULL DEFAULT 0 COMMENT 'maker 返佣',
+    `commissions` BIGINT NOT NULL DEFAULT 0 COMMENT '推荐返佣',
+    `net_fee_revenue` BIGINT NOT NULL DEFAULT 0 COMMENT 'fees - rebates - commissions',
+    `funding_paid` BIGINT NOT NULL DEFAULT 0,
+    `funding_received` BIGINT NOT NULL DEFAULT 0,
+    `insurance_delta` BIGINT NOT NULL DEFAULT 0 COMMENT '保险基金净变动',
+    `liquidations` BIGINT NOT NULL DEFAULT 0,
+    `generated_at` BIGINT NOT NULL COMMENT '毫秒',
+    UNIQUE KEY `uk_day_asset` (`day`, `asset`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '全站日报';
+
+-- =============================================================================
+-- 日终关账 (见 close.go)，关账时刻 = UTC 0 点 + CloseOffset
+-- =============================================================================
+
+-- 关账记录 (一天一条，关账完成事件)
+CREATE TABLE IF NOT EXISTS `report_daily_close` (
+    `day` CHAR(10) NOT NULL PRIMARY KEY COMMENT 'YYYY-MM-DD',
+    `close_at` BIGINT NOT NULL COMMENT '应关账时刻 (毫秒)',
+    `captured_at` BIGINT NOT NULL COMMENT '实际抓取快照时刻 (毫秒)',
+    `completed_at` BIGINT NOT NULL COMMENT '毫秒',
+    `late` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '抓取晚于 close_at + 冻结窗口',
+    `marks` INT NOT NULL DEFAULT 0,
+    `accounts` INT NOT NULL DEFAULT 0,
+    `pnl_rows` INT NOT NULL DEFAULT 0,
+    `missing_marks` INT NOT NULL DEFAULT 0 COMMENT '有持仓但没有标记价格的合约数'
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '日终关账记录';
+
+-- 日终标记价格 (长期保留)
+CREATE TABLE IF NOT EXISTS `report_eod_mark` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `day` CHAR(10) NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `mark_price` BIGINT NOT NULL,
+    `captured_at` BIGINT NOT NULL COMMENT '毫秒',
+    UNIQUE KEY `uk_day_symbol` (`day`, `symbol`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '日终标记价格';
+
+-- 日终账户权益 (按保留天数轮转)
+CREATE TABLE IF NOT EXISTS `report_account_equity` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `day` CHAR(10) NOT NULL,
+    `user_id` BIGINT NOT NULL,
+    `asset` VARCHAR(16) NOT NULL,
+    `balance` BIGINT NOT NULL DEFAULT 0 COMMENT '可用 + 冻结',
+    `unrealized_pnl` BIGINT NOT NULL DEFAULT 0,
+    `equity` BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY `uk_day_user_asset` (`day`, `user_id`, `asset`),
+    KEY `idx_user_day` (`user_id`, `day`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '日终账户权益';
+
+-- 用户持仓日盈亏 (按保留天数轮转)
+CREATE TABLE IF NOT EXISTS `report_user_pnl` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `day` CHAR(10) NOT NULL,
+    `user_id` BIGINT NOT NULL,
+    `symbol` VARCHAR(32) NOT NULL,
+    `currency` VARCHAR(16) NOT NULL DEFAULT '',
+    `position_size` BIGINT NOT NULL DEFAULT 0 COMMENT '日终持仓 (正多负空)',
+    `entry_price` BIGINT NOT NULL DEFAULT 0,
+    `mark_price` BIGINT NOT NULL DEFAULT 0,
+    `cum_realized_pnl` BIGINT NOT NULL DEFAULT 0 COMMENT '累计已实现盈亏 (次日基准)',
+    `realized_pnl` BIGINT NOT NULL DEFAULT 0,
+    `funding` BIGINT NOT NULL DEFAULT 0 COMMENT '正=收入',
+    `unrealized_pnl` BIGINT NOT NULL DEFAULT 0,
+    `unrealized_change` BIGINT NOT NULL DEFAULT 0,
+    `total_pnl` BIGINT NOT NULL DEFAULT 0 COMMENT 'realized + funding + unrealized_change',
+    UNIQUE KEY `uk_day_user_symbol` (`day`, `user_id`, `symbol`),
+    KEY `idx_user_day` (`user_id`, `day`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户持仓日盈亏';
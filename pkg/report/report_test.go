@@ -0,0 +1,162 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+type memSource struct {
+	journals  []fund.JournalRecord
+	funding   []futures.FundingPayment
+	insurance []futures.InsuranceFundLog
+}
+
+func (s *memSource) Journals(_ context.Context, from, to time.Time) ([]fund.JournalRecord, error) {
+	var out []fund.JournalRecord
+	for _, j := range s.journals {
+		if !j.CreatedAt.Before(from) && j.CreatedAt.Before(to) {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+func (s *memSource) FundingPayments(_ context.Context, from, to time.Time) ([]futures.FundingPayment, error) {
+	var out []futures.FundingPayment
+	for _, p := range s.funding {
+		if p.FundingTime >= from.UnixMilli() && p.FundingTime < to.UnixMilli() {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (s *memSource) InsuranceLogs(_ context.Context, from, to time.Time) ([]futures.InsuranceFundLog, error) {
+	var out []futures.InsuranceFundLog
+	for _, l := range s.insurance {
+		if l.CreatedAt >= from.UnixMilli() && l.CreatedAt < to.UnixMilli() {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// spotTradeJournals 与 spot.SpotProcessor 发出的成交流水格式一致
+func spotTradeJournals(id string, at time.Time, base, quote string, qty, quoteAmount, buyerFee, sellerFee int64) []fund.JournalRecord {
+	j := func(suffix, symbol string, ct fund.ChangeType, amount int64) fund.JournalRecord {
+		return fund.JournalRecord{
+			EventID: "trade_" + id + "_" + suffix, Symbol: symbol, ChangeType: ct, Amount: amount,
+			BizType: fund.BizTypeTrade, BizID: id, CreatedAt: at,
+		}
+	}
+	out := []fund.JournalRecord{
+		j("buyer", quote, fund.ChangeTypeTransfer, quoteAmount),
+		j("seller", base, fund.ChangeTypeTransfer, qty),
+		j("buyer_fee", base, fund.ChangeTypeFee, buyerFee),
+	}
+	if sellerFee < 0 {
+		return append(out, j("seller_rebate", quote, fund.ChangeTypeRebate, -sellerFee))
+	}
+	return append(out, j("seller_fee", quote, fund.ChangeTypeFee, sellerFee))
+}
+
+func TestBuild(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	noon := day.Add(12 * time.Hour)
+	src := &memSource{}
+	src.journals = append(src.journals, spotTradeJournals("1", noon, "BTC", "USDT", 100, 5000, 2, 10)...)
+	src.journals = append(src.journals, spotTradeJournals("2", noon, "BTC", "USDT", 50, 2500, 1, -3)...)
+	// 前一天的成交不计入
+	src.journals = append(src.journals, spotTradeJournals("0", day.Add(-time.Hour), "BTC", "USDT", 999, 999, 9, 9)...)
+	// 只有单边流水
+	src.journals = append(src.journals, fund.JournalRecord{
+		EventID: "trade_3_buyer", Symbol: "USDT", ChangeType: fund.ChangeTypeTransfer, Amount: 7,
+		BizType: fund.BizTypeTrade, BizID: "3", CreatedAt: noon,
+	})
+	src.journals = append(src.journals, fund.JournalRecord{
+		EventID: "commission_x", Symbol: "USDT", ChangeType: fund.ChangeTypeCommission, Amount: 4,
+		BizType: fund.BizTypeCommission, BizID: "2026-02-28", CreatedAt: noon,
+	})
+	src.funding = []futures.FundingPayment{
+		{Symbol: "BTCUSDT", Payment: -30, FundingTime: noon.UnixMilli()},
+		{Symbol: "BTCUSDT", Payment: 30, FundingTime: noon.UnixMilli()},
+	}
+	src.insurance = []futures.InsuranceFundLog{
+		{Currency: "USDT", ChangeType: futures.InsuranceChangeLiquidationProfit, Amount: 40, RelatedSymbol: "BTCUSDT", CreatedAt: noon.UnixMilli()},
+		{Currency: "USDT", ChangeType: futures.InsuranceChangeBankruptCover, Amount: -15, RelatedSymbol: "BTCUSDT", CreatedAt: noon.UnixMilli()},
+		{Currency: "USDT", ChangeType: futures.InsuranceChangeDeposit, Amount: 100, CreatedAt: noon.UnixMilli()},
+	}
+
+	cfg := BuildConfig{Now: func() time.Time { return day.Add(25 * time.Hour) }}
+	if _, err := Build(context.Background(), src, "2026-03-02", cfg); !errors.Is(err, ErrDayNotClosed) {
+		t.Fatalf("expected ErrDayNotClosed, got %v", err)
+	}
+	d, err := Build(context.Background(), src, "2026-03-01", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IncompleteTrades != 1 || len(d.Symbols) != 2 || len(d.Exchange) != 2 {
+		t.Fatalf("unexpected shape: %+v", d)
+	}
+
+	perp, spot := d.Symbols[0], d.Symbols[1] // "BTCUSDT" < "BTC_USDT"
+	if spot.Symbol != "BTC_USDT" || spot.Trades != 2 || spot.BaseVolume != 150 || spot.QuoteVolume != 7500 ||
+		spot.FeeBase != 3 || spot.FeeQuote != 10 || spot.RebateQuote != 3 {
+		t.Fatalf("spot row: %+v", spot)
+	}
+	if perp.FundingPaid != 30 || perp.FundingReceived != 30 || perp.Liquidations != 2 {
+		t.Fatalf("perp row: %+v", perp)
+	}
+
+	var usdt ExchangeDaily
+	for _, a := range d.Exchange {
+		if a.Asset == "USDT" {
+			usdt = a
+		}
+	}
+	// 费用按资产：USDT 收 10，返 3，推荐返佣 4
+	if usdt.Trades != 2 || usdt.QuoteVolume != 7500 || usdt.Fees != 10 || usdt.Rebates != 3 ||
+		usdt.Commissions != 4 || usdt.NetFeeRevenue != 3 || usdt.InsuranceDelta != 125 || usdt.Liquidations != 2 {
+		t.Fatalf("USDT row: %+v", usdt)
+	}
+}
+
+func TestJob_SaveAndExport(t *testing.T) {
+	now := time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC)
+	src := &memSource{journals: spotTradeJournals("1", now.Add(-12*time.Hour), "ETH", "USDT", 2*100000000, 6000*100000000, 100000, 1200000)}
+	store := NewMemoryStore()
+	job := NewJob(src, store, JobConfig{Build: BuildConfig{Now: func() time.Time { return now }}})
+	if got := job.dueDay(); got != "2026-03-01" {
+		t.Fatalf("due day %s", got)
+	}
+	// 重跑覆盖，不会重复
+	for i := 0; i < 2; i++ {
+		if _, err := job.RunDay(context.Background(), "2026-03-01"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewHandler(store)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/reports/symbols?from=2026-03-01&to=2026-03-01&format=csv", nil))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != 200 || len(lines) != 2 {
+		t.Fatalf("csv export: %d %q", w.Code, w.Body.String())
+	}
+	if want := "2026-03-01,ETH_USDT,ETH,USDT,1,2.00000000,6000.00000000,0.00100000,0.01200000"; !strings.HasPrefix(lines[1], want) {
+		t.Fatalf("csv row %q, want prefix %q", lines[1], want)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/reports/exchange?from=bad", nil))
+	if w.Code != 400 {
+		t.Fatalf("bad day should be 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,350 @@
+package report
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+// =============================================================================
+// 用户对账单 (月结单)
+// =============================================================================
+//
+// 把一个用户在一段时间内的资金变动统一成一张明细表 + 按资产汇总：
+//
+//	流水 (fund.JournalRecord)          → 充值、提现、成交支付、手续费、返佣、推荐返佣、交割
+//	资金费 (futures.FundingPayment)    → 资金费收支 (按合约)
+//	历史持仓 (futures.PositionHistory) → 已实现盈亏、强平
+//
+// 资金费只取资金费表：合约资金费同时会发 FUNDING 流水，两边都算会重复。
+// 成交流水每个用户只有支付的一边 (买方付报价币、卖方付基础币)，明细里记为支出，
+// 收到的一边由对手方的流水体现，对账单不做推算。
+
+var ErrInvalidMonth = errors.New("report: invalid month, want YYYY-MM")
+
+// MonthLayout 对账单月份格式 (UTC)
+const MonthLayout = "2006-01"
+
+// ParseMonth 解析月份，返回 [当月 1 日, 下月 1 日) 的日期 (YYYY-MM-DD)
+func ParseMonth(month string) (from, to string, err error) {
+	t, err := time.ParseInLocation(MonthLayout, month, time.UTC)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidMonth, month)
+	}
+	return t.Format(DayLayout), t.AddDate(0, 1, 0).Format(DayLayout), nil
+}
+
+// EntryKind 明细类型
+type EntryKind string
+
+const (
+	EntryDeposit     EntryKind = "DEPOSIT"
+	EntryWithdraw    EntryKind = "WITHDRAW"
+	EntryTradeBuy    EntryKind = "TRADE_BUY"  // 买入支付报价币
+	EntryTradeSell   EntryKind = "TRADE_SELL" // 卖出支付基础币
+	EntryFee         EntryKind = "FEE"
+	EntryRebate      EntryKind = "REBATE"
+	EntryCommission  EntryKind = "COMMISSION"
+	EntryDust        EntryKind = "DUST"
+	EntrySettlement  EntryKind = "SETTLEMENT"
+	EntryFunding     EntryKind = "FUNDING"
+	EntryRealizedPnL EntryKind = "REALIZED_PNL"
+	EntryLiquidation EntryKind = "LIQUIDATION"     // 强平的已实现盈亏
+	EntrySocialLoss  EntryKind = "SOCIALIZED_LOSS" // 穿仓分摊扣回
+)
+
+// StatementEntry 一条明细，Amount 带符号 (正=收入, 负=支出)
+type StatementEntry struct {
+	Time   int64     `json:"time"` // 毫秒
+	Kind   EntryKind `json:"kind"`
+	Asset  string    `json:"asset"`
+	Symbol string    `json:"symbol,omitempty"` // 合约 / 交易对，资金划转为空
+	Amount int64     `json:"amount"`
+	RefID  string    `json:"ref_id"` // 业务 ID (充提单号、成交 ID、持仓历史 ID ...)
+}
+
+// StatementSummary 按资产汇总，都是带符号的净额
+type StatementSummary struct {
+	Asset        string `json:"asset"`
+	Deposits     int64  `json:"deposits"`
+	Withdrawals  int64  `json:"withdrawals"`
+	TradePaid    int64  `json:"trade_paid"`
+	Fees         int64  `json:"fees"`
+	Rebates      int64  `json:"rebates"` // maker 返佣 + 推荐返佣
+	Funding      int64  `json:"funding"`
+	RealizedPnL  int64  `json:"realized_pnl"` // 含强平
+	Other        int64  `json:"other"`        // 碎币兑换、交割
+	Liquidations int64  `json:"liquidations"`
+}
+
+// Statement 用户对账单，区间 [From, To)
+type Statement struct {
+	UserID      int64              `json:"user_id"`
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Entries     []StatementEntry   `json:"entries"` // 按时间排序
+	Summary     []StatementSummary `json:"summary"` // 按资产排序
+	GeneratedAt int64              `json:"generated_at"`
+}
+
+// StatementSource 对账单数据源，区间左闭右开
+type StatementSource interface {
+	UserJournals(ctx context.Context, userID int64, from, to time.Time) ([]fund.JournalRecord, error)
+	UserFundingPayments(ctx context.Context, userID int64, from, to time.Time) ([]futures.FundingPayment, error)
+	UserPositionHistory(ctx context.Context, userID int64, from, to time.Time) ([]futures.PositionHistory, error)
+}
+
+// journalKinds 流水类型 → 明细类型，符号为资金方向
+var journalKinds = map[fund.ChangeType]struct {
+	kind EntryKind
+	sign int64
+}{
+	fund.ChangeTypeDeposit:    {EntryDeposit, 1},
+	fund.ChangeTypeWithdraw:   {EntryWithdraw, -1},
+	fund.ChangeTypeFee:        {EntryFee, -1},
+	fund.ChangeTypeRebate:     {EntryRebate, 1},
+	fund.ChangeTypeCommission: {EntryCommission, 1},
+	fund.ChangeTypeDust:       {EntryDust, 1},
+	fund.ChangeTypeSettlement: {EntrySettlement, 1},
+	fund.ChangeTypeSocialLoss: {EntrySocialLoss, -1},
+}
+
+// BuildStatement 生成 userID 在 [from, to) (YYYY-MM-DD，UTC) 的对账单
+//
+// 区间未结束也能生成 (用户查看当月至今)，结果只包含已落库的数据
+func BuildStatement(ctx context.Context, src StatementSource, userID int64, from, to string, cfg BuildConfig) (*Statement, error) {
+	cfg.withDefaults()
+	start, err := ParseDay(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := ParseDay(to)
+	if err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: to %s not after from %s", ErrInvalidDay, to, from)
+	}
+
+	journals, err := src.UserJournals(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("report: load journals: %w", err)
+	}
+	funding, err := src.UserFundingPayments(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("report: load funding payments: %w", err)
+	}
+	history, err := src.UserPositionHistory(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("report: load position history: %w", err)
+	}
+
+	st := &Statement{UserID: userID, From: from, To: to, GeneratedAt: cfg.Now().UnixMilli()}
+	for i := range journals {
+		j := &journals[i]
+		e := StatementEntry{Time: j.CreatedAt.UnixMilli(), Asset: j.Symbol, RefID: j.BizID}
+		switch {
+		case j.ChangeType == fund.ChangeTypeTransfer && j.BizType == fund.BizTypeTrade:
+			e.Kind, e.Amount = EntryTradeBuy, -j.Amount
+			if strings.HasSuffix(j.EventID, "_seller") {
+				e.Kind = EntryTradeSell
+			}
+		default:
+			k, ok := journalKinds[j.ChangeType]
+			if !ok {
+				continue // 冻结 / 解冻不改变总额；资金费以资金费表为准
+			}
+			e.Kind, e.Amount = k.kind, k.sign*j.Amount
+		}
+		st.Entries = append(st.Entries, e)
+	}
+	for i := range funding {
+		p := &funding[i]
+		st.Entries = append(st.Entries, StatementEntry{
+			Time: p.FundingTime, Kind: EntryFunding, Asset: cfg.SettleAsset(p.Symbol), Symbol: p.Symbol,
+			Amount: p.Payment, RefID: fmt.Sprintf("%s_%d", p.Symbol, p.FundingTime),
+		})
+	}
+	for i := range history {
+		h := &history[i]
+		kind := EntryRealizedPnL
+		if h.CloseReason == futures.CloseReasonLiquidation {
+			kind = EntryLiquidation
+		}
+		st.Entries = append(st.Entries, StatementEntry{
+			Time: h.ClosedAt, Kind: kind, Asset: cfg.SettleAsset(h.Symbol), Symbol: h.Symbol,
+			Amount: h.RealizedPnL, RefID: strconv.FormatUint(h.ID, 10),
+		})
+	}
+	sort.SliceStable(st.Entries, func(i, j int) bool { return st.Entries[i].Time < st.Entries[j].Time })
+
+	sums := make(map[string]*StatementSummary)
+	for _, e := range st.Entries {
+		s, ok := sums[e.Asset]
+		if !ok {
+			s = &StatementSummary{Asset: e.Asset}
+			sums[e.Asset] = s
+		}
+		switch e.Kind {
+		case EntryDeposit:
+			s.Deposits += e.Amount
+		case EntryWithdraw:
+			s.Withdrawals += e.Amount
+		case EntryTradeBuy, EntryTradeSell:
+			s.TradePaid += e.Amount
+		case EntryFee:
+			s.Fees += e.Amount
+		case EntryRebate, EntryCommission:
+			s.Rebates += e.Amount
+		case EntryFunding:
+			s.Funding += e.Amount
+		case EntryLiquidation:
+			s.Liquidations++
+			s.RealizedPnL += e.Amount
+		case EntryRealizedPnL:
+			s.RealizedPnL += e.Amount
+		default:
+			s.Other += e.Amount
+		}
+	}
+	for _, s := range sums {
+		st.Summary = append(st.Summary, *s)
+	}
+	sort.Slice(st.Summary, func(i, j int) bool { return st.Summary[i].Asset < st.Summary[j].Asset })
+	return st, nil
+}
+
+// =============================================================================
+// CSV 导出
+// =============================================================================
+
+var statementHeader = []string{"time", "kind", "asset", "symbol", "amount", "ref_id"}
+
+// WriteStatementCSV 导出对账单明细 (时间为 RFC3339 UTC，金额 8 位小数)
+func WriteStatementCSV(w io.Writer, st *Statement) error {
+	cw := csv.NewWriter(w)
+	cw.Write(statementHeader)
+	for _, e := range st.Entries {
+		cw.Write([]string{
+			time.UnixMilli(e.Time).UTC().Format(time.RFC3339), string(e.Kind), e.Asset, e.Symbol,
+			decimal(e.Amount), e.RefID,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// NewStatementHandler 用户对账单接口
+//
+//	GET /account/statement?user_id=1&month=2026-01&format=csv
+//	GET /account/statement?user_id=1&from=2026-01-01&to=2026-02-01
+//	    month 与 from/to 二选一；format 默认 json，csv 只导出明细
+//
+// user_id 由网关鉴权后注入，这里不做身份校验
+func NewStatementHandler(src StatementSource, cfg BuildConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		userID, err := strconv.ParseInt(q.Get("user_id"), 10, 64)
+		if err != nil || userID <= 0 {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("user_id"))
+			return
+		}
+		from, to := q.Get("from"), q.Get("to")
+		if month := q.Get("month"); month != "" {
+			if from, to, err = ParseMonth(month); err != nil {
+				cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("month"))
+				return
+			}
+		}
+		st, err := BuildStatement(r.Context(), src, userID, from, to, cfg)
+		if errors.Is(err, ErrInvalidDay) {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("from/to"))
+			return
+		}
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		if q.Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition",
+				fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("statement_%d_%s_%s.csv", userID, from, to)))
+			WriteStatementCSV(w, st)
+			return
+		}
+		writeJSON(w, st)
+	})
+}
+
+// =============================================================================
+// MySQL 数据源
+// =============================================================================
+
+// UserJournals 只查用户所在的分表；设置了归档时合并归档里的记录
+//
+// 批次在归档和删库之间、或恢复回原表后，同一条流水会两边都有，按 event_id 去重
+func (s *GormSource) UserJournals(ctx context.Context, userID int64, from, to time.Time) ([]fund.JournalRecord, error) {
+	table := "journals"
+	if !s.useSingleTable {
+		table = fund.GetTableName("journal", userID)
+	}
+	var rows []fund.JournalRecord
+	err := s.fund(ctx).Table(table).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, from, to).
+		Order("id").Find(&rows).Error
+	if err != nil || s.archive == nil {
+		return rows, err
+	}
+
+	archived, err := s.archive.ArchivedJournals(ctx, userID, from, to)
+	if err != nil || len(archived) == 0 {
+		return rows, err
+	}
+	seen := make(map[string]bool, len(rows))
+	for i := range rows {
+		seen[rows[i].EventID] = true
+	}
+	for _, j := range archived {
+		if !seen[j.EventID] {
+			rows = append(rows, j)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows, nil
+}
+
+func (s *GormSource) UserFundingPayments(ctx context.Context, userID int64, from, to time.Time) ([]futures.FundingPayment, error) {
+	var rows []futures.FundingPayment
+	err := s.futures(ctx).
+		Where("user_id = ? AND funding_time >= ? AND funding_time < ?", userID, from.UnixMilli(), to.UnixMilli()).
+		Find(&rows).Error
+	return rows, err
+}
+
+func (s *GormSource) UserPositionHistory(ctx context.Context, userID int64, from, to time.Time) ([]futures.PositionHistory, error) {
+	var rows []futures.PositionHistory
+	err := s.futures(ctx).
+		Where("user_id = ? AND closed_at >= ? AND closed_at < ?", userID, from.UnixMilli(), to.UnixMilli()).
+		Order("id").Find(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+type memStatementSource struct {
+	journals []fund.JournalRecord
+	funding  []futures.FundingPayment
+	history  []futures.PositionHistory
+}
+
+func (s *memStatementSource) UserJournals(_ context.Context, userID int64, from, to time.Time) ([]fund.JournalRecord, error) {
+	var out []fund.JournalRecord
+	for _, j := range s.journals {
+		if j.UserID == userID && !j.CreatedAt.Before(from) && j.CreatedAt.Before(to) {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStatementSource) UserFundingPayments(_ context.Context, userID int64, from, to time.Time) ([]futures.FundingPayment, error) {
+	var out []futures.FundingPayment
+	for _, p := range s.funding {
+		if p.UserID == userID && p.FundingTime >= from.UnixMilli() && p.FundingTime < to.UnixMilli() {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStatementSource) UserPositionHistory(_ context.Context, userID int64, from, to time.Time) ([]futures.PositionHistory, error) {
+	var out []futures.PositionHistory
+	for _, h := range s.history {
+		if h.UserID == userID && h.ClosedAt >= from.UnixMilli() && h.ClosedAt < to.UnixMilli() {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+func statementFixture() *memStatementSource {
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return mar.Add(time.Duration(h) * time.Hour) }
+	src := &memStatementSource{}
+	for _, j := range spotTradeJournals("1", at(3), "BTC", "USDT", 100, 5000, 2, 10) {
+		j.UserID = 7
+		src.journals = append(src.journals, j)
+	}
+	src.journals = append(src.journals,
+		fund.JournalRecord{UserID: 7, Symbol: "USDT", ChangeType: fund.ChangeTypeDeposit, Amount: 10000, BizType: fund.BizTypeDeposit, BizID: "d1", CreatedAt: at(1)},
+		fund.JournalRecord{UserID: 7, Symbol: "USDT", ChangeType: fund.ChangeTypeWithdraw, Amount: 300, BizType: fund.BizTypeWithdraw, BizID: "w1", CreatedAt: at(5)},
+		fund.JournalRecord{UserID: 7, Symbol: "USDT", ChangeType: fund.ChangeTypeReserve, Amount: 5000, BizType: fund.BizTypeOrder, CreatedAt: at(2)},
+		fund.JournalRecord{UserID: 7, Symbol: "USDT", ChangeType: fund.ChangeTypeFunding, Amount: 12, BizType: fund.BizTypeFunding, CreatedAt: at(8)},
+		// 其他用户、其他月份
+		fund.JournalRecord{UserID: 8, Symbol: "USDT", ChangeType: fund.ChangeTypeDeposit, Amount: 1, CreatedAt: at(1)},
+		fund.JournalRecord{UserID: 7, Symbol: "USDT", ChangeType: fund.ChangeTypeDeposit, Amount: 1, CreatedAt: at(-1)},
+	)
+	src.funding = []futures.FundingPayment{
+		{UserID: 7, Symbol: "BTCUSDT", Payment: -12, FundingTime: at(8).UnixMilli()},
+	}
+	src.history = []futures.PositionHistory{
+		{ID: 11, UserID: 7, Symbol: "BTCUSDT", CloseReason: futures.CloseReasonClose, RealizedPnL: 400, ClosedAt: at(10).UnixMilli()},
+		{ID: 12, UserID: 7, Symbol: "BTCUSDT", CloseReason: futures.CloseReasonLiquidation, RealizedPnL: -900, ClosedAt: at(20).UnixMilli()},
+	}
+	return src
+}
+
+func TestBuildStatement(t *testing.T) {
+	from, to, err := ParseMonth("2026-03")
+	if err != nil || from != "2026-03-01" || to != "2026-04-01" {
+		t.Fatalf("ParseMonth = %s %s %v", from, to, err)
+	}
+	st, err := BuildStatement(context.Background(), statementFixture(), 7, from, to, BuildConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	for _, e := range st.Entries {
+		kinds = append(kinds, string(e.Kind))
+	}
+	want := "DEPOSIT,TRADE_BUY,TRADE_SELL,FEE,FEE,WITHDRAW,FUNDING,REALIZED_PNL,LIQUIDATION"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Fatalf("entries %s\nwant    %s", got, want)
+	}
+
+	sums := make(map[string]StatementSummary)
+	for _, s := range st.Summary {
+		sums[s.Asset] = s
+	}
+	usdt := sums["USDT"]
+	if usdt.Deposits != 10000 || usdt.Withdrawals != -300 || usdt.TradePaid != -5000 || usdt.Fees != -10 ||
+		usdt.Funding != -12 || usdt.RealizedPnL != -500 || usdt.Liquidations != 1 {
+		t.Fatalf("USDT summary %+v", usdt)
+	}
+	if btc := sums["BTC"]; btc.TradePaid != -100 || btc.Fees != -2 {
+		t.Fatalf("BTC summary %+v", btc)
+	}
+}
+
+func TestStatementHandler(t *testing.T) {
+	h := NewStatementHandler(statementFixture(), BuildConfig{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/account/statement?user_id=7&month=2026-03&format=csv", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 10 || lines[1] != "2026-03-01T01:00:00Z,DEPOSIT,USDT,,0.00010000,d1" {
+		t.Fatalf("csv:\n%s", rec.Body)
+	}
+
+	for _, q := range []string{"user_id=7&month=2026-13", "user_id=0&month=2026-03", "user_id=7"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/account/statement?"+q, nil))
+		if rec.Code != 400 {
+			t.Fatalf("%s: status %d", q, rec.Code)
+		}
+	}
+}
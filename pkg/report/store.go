@@ -0,0 +1,258 @@
+package report
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"max.com/pkg/dbroute"
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+)
+
+// =============================================================================
+// 报表存储
+// =============================================================================
+
+// Query 报表查询，日期闭区间 (YYYY-MM-DD，字符串比较即日期比较)，空表示不限
+type Query struct {
+	From, To string
+	Key      string // Symbol 或 Asset，空表示全部
+}
+
+func (q Query) match(day, key string) bool {
+	return (q.From == "" || day >= q.From) && (q.To == "" || day <= q.To) && (q.Key == "" || key == q.Key)
+}
+
+// Store 报表存储
+type Store interface {
+	// Save 保存一天的报表，同一天重跑时覆盖 (该日不再出现的行删除)
+	Save(ctx context.Context, d *Daily) error
+	Symbols(ctx context.Context, q Query) ([]SymbolDaily, error)
+	Exchange(ctx context.Context, q Query) ([]ExchangeDaily, error)
+}
+
+// MemoryStore 内存存储 (测试 / 单机)
+type MemoryStore struct {
+	mu       sync.RWMutex
+	symbols  map[string][]SymbolDaily
+	exchange map[string][]ExchangeDaily
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		symbols:  make(map[string][]SymbolDaily),
+		exchange: make(map[string][]ExchangeDaily),
+	}
+}
+
+func (s *MemoryStore) Save(_ context.Context, d *Daily) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[d.Day] = append([]SymbolDaily(nil), d.Symbols...)
+	s.exchange[d.Day] = append([]ExchangeDaily(nil), d.Exchange...)
+	return nil
+}
+
+func (s *MemoryStore) Symbols(_ context.Context, q Query) ([]SymbolDaily, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []SymbolDaily
+	for day, rows := range s.symbols {
+		for _, r := range rows {
+			if q.match(day, r.Symbol) {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day < out[j].Day
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) Exchange(_ context.Context, q Query) ([]ExchangeDaily, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []ExchangeDaily
+	for day, rows := range s.exchange {
+		for _, r := range rows {
+			if q.match(day, r.Asset) {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day < out[j].Day
+		}
+		return out[i].Asset < out[j].Asset
+	})
+	return out, nil
+}
+
+// GormStore MySQL 存储 (report.sql)
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建 MySQL 存储
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Save 事务内先删该日旧行再写入，重跑结果与首次一致
+func (s *GormStore) Save(ctx context.Context, d *Daily) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", d.Day).Delete(&SymbolDaily{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("day = ?", d.Day).Delete(&ExchangeDaily{}).Error; err != nil {
+			return err
+		}
+		if len(d.Symbols) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&d.Symbols).Error; err != nil {
+				return err
+			}
+		}
+		if len(d.Exchange) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&d.Exchange).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) Symbols(ctx context.Context, q Query) ([]SymbolDaily, error) {
+	var rows []SymbolDaily
+	err := s.where(ctx, q, "symbol").Order("day, symbol").Find(&rows).Error
+	return rows, err
+}
+
+func (s *GormStore) Exchange(ctx context.Context, q Query) ([]ExchangeDaily, error) {
+	var rows []ExchangeDaily
+	err := s.where(ctx, q, "asset").Order("day, asset").Find(&rows).Error
+	return rows, err
+}
+
+func (s *GormStore) where(ctx context.Context, q Query, keyColumn string) *gorm.DB {
+	tx := s.db.WithContext(ctx)
+	if q.From != "" {
+		tx = tx.Where("day >= ?", q.From)
+	}
+	if q.To != "" {
+		tx = tx.Where("day <= ?", q.To)
+	}
+	if q.Key != "" {
+		tx = tx.Where(keyColumn+" = ?", q.Key)
+	}
+	return tx
+}
+
+// =============================================================================
+// MySQL 数据源
+// =============================================================================
+
+// GormSource 从资产库 (流水) 和合约库 (资金费、保险基金) 读取
+//
+// 流水表与 fund.BalanceRepo 一致：单表 journals，或按用户分 128 张表 journal_000 ~ journal_127
+type GormSource struct {
+	fundDB         *gorm.DB
+	futuresDB      *gorm.DB
+	useSingleTable bool
+
+	fundRouter    *dbroute.Router
+	futuresRouter *dbroute.Router
+
+	archive JournalArchive // nil 表示流水没有归档，只查库
+}
+
+// JournalArchive 已归档的流水 (*fund.JournalArchiver 实现)
+type JournalArchive interface {
+	ArchivedJournals(ctx context.Context, userID int64, from, to time.Time) ([]fund.JournalRecord, error)
+}
+
+var _ JournalArchive = (*fund.JournalArchiver)(nil)
+
+// NewGormSource 创建数据源 (流水分表)
+func NewGormSource(fundDB, futuresDB *gorm.DB) *GormSource {
+	return &GormSource{fundDB: fundDB, futuresDB: futuresDB}
+}
+
+// NewSingleTableGormSource 创建数据源 (流水单表，开发环境)
+func NewSingleTableGormSource(fundDB, futuresDB *gorm.DB) *GormSource {
+	return &GormSource{fundDB: fundDB, futuresDB: futuresDB, useSingleTable: true}
+}
+
+// SetRouters 启用读写分离，nil 表示该库不分离
+//
+// 报表和对账单只读已落账的历史数据，查询一律标记为只读，按路由默认容忍度读从库
+func (s *GormSource) SetRouters(fundRouter, futuresRouter *dbroute.Router) {
+	s.fundRouter, s.futuresRouter = fundRouter, futuresRouter
+}
+
+// SetJournalArchive 流水归档后对账单仍能覆盖保留期之前的月份：用户流水查询合并归档里的记录
+func (s *GormSource) SetJournalArchive(archive JournalArchive) {
+	s.archive = archive
+}
+
+func (s *GormSource) fund(ctx context.Context) *gorm.DB {
+	if s.fundRouter == nil {
+		return s.fundDB.WithContext(ctx)
+	}
+	return s.fundRouter.DB(dbroute.ReadOnly(ctx, 0)).WithContext(ctx)
+}
+
+func (s *GormSource) futures(ctx context.Context) *gorm.DB {
+	if s.futuresRouter == nil {
+		return s.futuresDB.WithContext(ctx)
+	}
+	return s.futuresRouter.DB(dbroute.ReadOnly(ctx, 0)).WithContext(ctx)
+}
+
+func (s *GormSource) Journals(ctx context.Context, from, to time.Time) ([]fund.JournalRecord, error) {
+	tables := []string{"journals"}
+	if !s.useSingleTable {
+		tables = tables[:0]
+		for shard := 0; shard < fund.NumShards; shard++ {
+			tables = append(tables, fund.GetTableName("journal", int64(shard)))
+		}
+	}
+	var out []fund.JournalRecord
+	for _, table := range tables {
+		var rows []fund.JournalRecord
+		err := s.fund(ctx).Table(table).
+			Where("created_at >= ? AND created_at < ?", from, to).
+			Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rows...)
+	}
+	return out, nil
+}
+
+func (s *GormSource) FundingPayments(ctx context.Context, from, to time.Time) ([]futures.FundingPayment, error) {
+	var rows []futures.FundingPayment
+	err := s.futures(ctx).
+		Where("funding_time >= ? AND funding_time < ?", from.UnixMilli(), to.UnixMilli()).
+		Find(&rows).Error
+	return rows, err
+}
+
+func (s *GormSource) InsuranceLogs(ctx context.Context, from, to time.Time) ([]futures.InsuranceFundLog, error) {
+	var rows []futures.InsuranceFundLog
+	err := s.futures(ctx).
+		Where("created_at >= ? AND created_at < ?", from.UnixMilli(), to.UnixMilli()).
+		Find(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,210 @@
+package reserve
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// =============================================================================
+// 哈希
+// =============================================================================
+
+// Hash 节点哈希
+type Hash [sha256.Size]byte
+
+const (
+	leafPrefix = 0x00 // 叶子与内部节点用不同前缀，防止把内部节点伪造成叶子 (第二原像攻击)
+	nodePrefix = 0x01
+)
+
+// Leaf 一个用户一个资产的余额
+type Leaf struct {
+	UserID  int64
+	Asset   string
+	Balance int64 // 可用 + 冻结
+}
+
+// LeafHash 叶子哈希 = SHA256(0x00 || nonce || userID || asset || balance)
+//
+// nonce 每个快照每个用户不同 (见 Generator)，只发给用户本人：
+// 别人拿到证明路径上的兄弟哈希，也没法枚举出对应的余额
+func LeafHash(l Leaf, nonce []byte) Hash {
+	h := sha256.New()
+	var buf [8]byte
+	h.Write([]byte{leafPrefix})
+	binary.BigEndian.PutUint64(buf[:], uint64(len(nonce)))
+	h.Write(buf[:])
+	h.Write(nonce)
+	binary.BigEndian.PutUint64(buf[:], uint64(l.UserID))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(len(l.Asset)))
+	h.Write(buf[:])
+	h.Write([]byte(l.Asset))
+	binary.BigEndian.PutUint64(buf[:], uint64(l.Balance))
+	h.Write(buf[:])
+	var out Hash
+	h.Sum(out[:0])
+	return out
+}
+
+func nodeHash(left, right Hash) Hash {
+	var buf [1 + 2*sha256.Size]byte
+	buf[0] = nodePrefix
+	copy(buf[1:], left[:])
+	copy(buf[1+sha256.Size:], right[:])
+	return sha256.Sum256(buf[:])
+}
+
+// =============================================================================
+// Builder - 流式建树
+// =============================================================================
+
+// ErrEmptyTree 没有叶子
+var ErrEmptyTree = errors.New("merkle tree has no leaves")
+
+// Builder 流式构建 Merkle 树
+//
+// 叶子逐个 Add，每层只要凑齐一对就立刻算出父节点 (二进制进位)，
+// 不需要先把所有叶子读进内存再自底向上建树。
+//
+//   - retain=false: 只保留每层最后一个未配对节点，O(log n) 内存，只能得到根 (用于校验/对外发布)
+//   - retain=true:  保留所有层的哈希 (约 2n × 32 字节，100 万叶子 ≈ 64MB)，可以生成包含证明
+//
+// 【面试】奇数个节点怎么办？
+// 最后一个节点直接提升到上一层，不复制自己配对。
+// 复制最后一个节点 (比特币的做法) 会让 [a,b,c] 和 [a,b,c,c] 得到相同的根，
+// 可以凭空多出一个用户余额而根不变。
+type Builder struct {
+	retain  bool
+	levels  [][]Hash // retain 时保存每层全部节点
+	pending []Hash   // 每层最后一个未配对节点
+	counts  []int    // 每层节点数
+}
+
+// NewBuilder 创建 Builder
+func NewBuilder(retain bool) *Builder {
+	return &Builder{retain: retain}
+}
+
+// Add 追加一个叶子哈希，返回叶子下标
+func (b *Builder) Add(leaf Hash) int {
+	index := 0
+	if len(b.counts) > 0 {
+		index = b.counts[0]
+	}
+	b.push(0, leaf)
+	return index
+}
+
+// Len 已添加的叶子数
+func (b *Builder) Len() int {
+	if len(b.counts) == 0 {
+		return 0
+	}
+	return b.counts[0]
+}
+
+func (b *Builder) push(level int, h Hash) {
+	for {
+		if level == len(b.counts) {
+			b.counts = append(b.counts, 0)
+			b.pending = append(b.pending, Hash{})
+			if b.retain {
+				b.levels = append(b.levels, nil)
+			}
+		}
+		b.counts[level]++
+		if b.retain {
+			b.levels[level] = append(b.levels[level], h)
+		}
+		if b.counts[level]%2 == 1 {
+			b.pending[level] = h
+			return
+		}
+		h = nodeHash(b.pending[level], h)
+		level++
+	}
+}
+
+// Finish 处理各层落单节点，得到最终的树 (之后不能再 Add)
+func (b *Builder) Finish() (*Tree, error) {
+	if b.Len() == 0 {
+		return nil, ErrEmptyTree
+	}
+	for i := 0; i < len(b.counts); i++ {
+		top := i == len(b.counts)-1
+		if top && b.counts[i] == 1 {
+			break
+		}
+		if b.counts[i]%2 == 1 {
+			// 落单节点提升到上一层 (上一层可能因此凑成一对，继续进位)
+			b.push(i+1, b.pending[i])
+		}
+	}
+	top := len(b.counts) - 1
+	return &Tree{root: b.pending[top], leafCount: b.counts[0], levels: b.levels}, nil
+}
+
+// =============================================================================
+// Tree - 包含证明
+// =============================================================================
+
+// Tree 建好的 Merkle 树
+type Tree struct {
+	root      Hash
+	leafCount int
+	levels    [][]Hash // nil 表示未保留 (retain=false)
+}
+
+// ErrProofUnavailable 树没有保留中间层，无法生成证明
+var ErrProofUnavailable = errors.New("merkle tree built without retained levels")
+
+// ErrLeafIndex 叶子下标越界
+var ErrLeafIndex = errors.New("merkle leaf index out of range")
+
+// Root 根哈希
+func (t *Tree) Root() Hash { return t.root }
+
+// LeafCount 叶子数
+func (t *Tree) LeafCount() int { return t.leafCount }
+
+// ProofStep 证明路径上的一步：兄弟节点及其在左还是右
+type ProofStep struct {
+	Sibling Hash
+	Left    bool // 兄弟在左边：parent = H(sibling, cur)
+}
+
+// Proof 生成第 index 个叶子的包含证明
+func (t *Tree) Proof(index int) ([]ProofStep, error) {
+	if t.levels == nil {
+		return nil, ErrProofUnavailable
+	}
+	if index < 0 || index >= t.leafCount {
+		return nil, ErrLeafIndex
+	}
+	var steps []ProofStep
+	for level := 0; level < len(t.levels)-1; level++ {
+		nodes := t.levels[level]
+		sibling := index ^ 1
+		// 兄弟不存在说明当前节点是落单提升上去的，这一层没有哈希运算
+		if sibling < len(nodes) {
+			steps = append(steps, ProofStep{Sibling: nodes[sibling], Left: sibling < index})
+		}
+		index /= 2
+	}
+	return steps, nil
+}
+
+// VerifyProof 用叶子哈希和证明路径重算根
+func VerifyProof(root, leaf Hash, steps []ProofStep) bool {
+	cur := leaf
+	for _, s := range steps {
+		if s.Left {
+			cur = nodeHash(s.Sibling, cur)
+		} else {
+			cur = nodeHash(cur, s.Sibling)
+		}
+	}
+	return cur == root
+}
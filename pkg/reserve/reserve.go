@@ -0,0 +1,339 @@
+// Package reserve 储备金证明 (Proof of Reserves)
+//
+// 定期给全部用户余额拍快照，每个 (用户, 资产, 余额) 作为一个叶子建 Merkle 树，
+// 对外发布根哈希和各资产负债总额；用户可以拉取自己的包含证明，
+// 自己重算到根，确认"我的余额被计入了平台公布的负债总额"。
+//
+//	用户余额 (热冷对账) ──→ 叶子哈希 ──→ 流式建树 ──→ 根 + 总额 (公开)
+//	                                       │
+//	                                       └──→ 用户证明: 叶子 + nonce + 兄弟路径
+//
+// 【面试】储备金证明能证明什么，不能证明什么？
+// Merkle 树证明的是负债端：每个用户都能验证自己在树里，总额没有漏算自己。
+// 资产端 (链上地址确实有这么多币) 要另外用签名证明地址归属。
+// 另外树只能防"少算"，防不了"多算"——平台可以塞假用户抬高负债，但这对平台没好处。
+package reserve
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnsortedLeaves Source 输出的叶子没有按 (UserID, Asset) 升序
+	ErrUnsortedLeaves = errors.New("reserve source leaves not sorted by (user, asset)")
+	// ErrNoSnapshot 还没有生成过快照
+	ErrNoSnapshot = errors.New("no proof-of-reserves snapshot yet")
+	// ErrUserNotInSnapshot 用户在快照时没有余额
+	ErrUserNotInSnapshot = errors.New("user not included in snapshot")
+	// ErrInvalidProof 证明校验失败
+	ErrInvalidProof = errors.New("invalid inclusion proof")
+)
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 生成器配置
+type Config struct {
+	// Secret 生成每个用户 nonce 的密钥 (HMAC)，为空则 nonce 为空：
+	// 证明路径上的兄弟叶子可被枚举出余额，生产环境必须配置
+	Secret []byte
+
+	// Publish 快照生成后发布根 (写公告、上链、推 NATS 等)，可选
+	Publish func(ctx context.Context, c Commitment) error
+
+	// DisableProofs 不保留中间层和用户叶子，只算根 (O(log n) 内存)，
+	// 用于独立复核已发布的根；此时 Proof 返回 ErrProofUnavailable
+	DisableProofs bool
+}
+
+// =============================================================================
+// 快照
+// =============================================================================
+
+// Commitment 对外公布的内容
+type Commitment struct {
+	SnapshotID string           `json:"snapshot_id"`
+	Root       string           `json:"root"` // hex
+	LeafCount  int              `json:"leaf_count"`
+	UserCount  int              `json:"user_count"`
+	Totals     map[string]int64 `json:"totals"` // 各资产负债总额
+	CreatedAt  int64            `json:"created_at"`
+}
+
+// Snapshot 一次储备金证明快照
+type Snapshot struct {
+	Commitment
+	Mismatches []Mismatch // 热冷对账差异 (不公开)
+
+	tree  *Tree
+	users map[int64]userLeaves // retain 时保存，用于生成用户证明
+}
+
+// userLeaves 一个用户在树里的叶子 (下标连续)
+type userLeaves struct {
+	first  int
+	assets []string
+	amount []int64
+}
+
+// =============================================================================
+// Generator
+// =============================================================================
+
+// Generator 储备金证明生成器
+type Generator struct {
+	source Source
+	config Config
+
+	// 同一时刻只生成一个快照；latest 读写用 mu 保护
+	genMu  sync.Mutex
+	mu     sync.RWMutex
+	latest *Snapshot
+}
+
+// NewGenerator 创建生成器
+func NewGenerator(source Source, cfg Config) *Generator {
+	return &Generator{source: source, config: cfg}
+}
+
+// Generate 生成快照并发布根
+func (g *Generator) Generate(ctx context.Context, snapshotID string) (*Snapshot, error) {
+	g.genMu.Lock()
+	defer g.genMu.Unlock()
+
+	retain := !g.config.DisableProofs
+	builder := NewBuilder(retain)
+	snap := &Snapshot{
+		Commitment: Commitment{SnapshotID: snapshotID, Totals: make(map[string]int64)},
+	}
+	if retain {
+		snap.users = make(map[int64]userLeaves)
+	}
+
+	var prev *Leaf
+	var cur userLeaves
+	curUser := int64(0)
+	flush := func() {
+		if prev != nil && retain {
+			snap.users[curUser] = cur
+		}
+	}
+
+	mismatches, err := g.source.Leaves(ctx, func(l Leaf) error {
+		if prev != nil && (l.UserID < prev.UserID || (l.UserID == prev.UserID && l.Asset <= prev.Asset)) {
+			return fmt.Errorf("%w: (%d,%s) after (%d,%s)", ErrUnsortedLeaves, l.UserID, l.Asset, prev.UserID, prev.Asset)
+		}
+		if prev == nil || l.UserID != prev.UserID {
+			flush()
+			curUser = l.UserID
+			cur = userLeaves{first: builder.Len()}
+			snap.UserCount++
+		}
+		builder.Add(LeafHash(l, g.nonce(snapshotID, l.UserID)))
+		snap.Totals[l.Asset] += l.Balance
+		if retain {
+			cur.assets = append(cur.assets, l.Asset)
+			cur.amount = append(cur.amount, l.Balance)
+		}
+		leaf := l
+		prev = &leaf
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	flush()
+
+	tree, err := builder.Finish()
+	if err != nil {
+		return nil, err
+	}
+	snap.tree = tree
+	snap.Root = hex.EncodeToString(tree.root[:])
+	snap.LeafCount = tree.leafCount
+	snap.CreatedAt = time.Now().UnixMilli()
+	snap.Mismatches = mismatches
+
+	if g.config.Publish != nil {
+		if err := g.config.Publish(ctx, snap.Commitment); err != nil {
+			return nil, fmt.Errorf("publish root: %w", err)
+		}
+	}
+
+	g.mu.Lock()
+	g.latest = snap
+	g.mu.Unlock()
+	return snap, nil
+}
+
+// Latest 最近一次快照
+func (g *Generator) Latest() *Snapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.latest
+}
+
+// nonce 每个快照每个用户一个，HMAC(secret, snapshotID || userID)
+func (g *Generator) nonce(snapshotID string, userID int64) []byte {
+	if len(g.config.Secret) == 0 {
+		return nil
+	}
+	mac := hmac.New(sha256.New, g.config.Secret)
+	mac.Write([]byte(snapshotID))
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(userID))
+	mac.Write(buf[:])
+	return mac.Sum(nil)[:16]
+}
+
+// =============================================================================
+// 用户证明
+// =============================================================================
+
+// UserProof 发给用户的包含证明
+type UserProof struct {
+	SnapshotID string      `json:"snapshot_id"`
+	Root       string      `json:"root"`
+	UserID     int64       `json:"user_id"`
+	Nonce      string      `json:"nonce"` // hex，只发给用户本人
+	Leaves     []LeafProof `json:"leaves"`
+}
+
+// LeafProof 单个资产的证明
+type LeafProof struct {
+	Asset   string     `json:"asset"`
+	Balance int64      `json:"balance"`
+	Index   int        `json:"index"`
+	Path    []PathStep `json:"path"`
+}
+
+// PathStep 证明路径 (JSON 友好)
+type PathStep struct {
+	Sibling string `json:"sibling"` // hex
+	Left    bool   `json:"left"`
+}
+
+// Proof 生成用户的包含证明
+func (g *Generator) Proof(userID int64) (*UserProof, error) {
+	snap := g.Latest()
+	if snap == nil {
+		return nil, ErrNoSnapshot
+	}
+	if snap.users == nil {
+		return nil, ErrProofUnavailable
+	}
+	ul, ok := snap.users[userID]
+	if !ok {
+		return nil, ErrUserNotInSnapshot
+	}
+
+	proof := &UserProof{
+		SnapshotID: snap.SnapshotID,
+		Root:       snap.Root,
+		UserID:     userID,
+		Nonce:      hex.EncodeToString(g.nonce(snap.SnapshotID, userID)),
+	}
+	for i, a := range ul.assets {
+		steps, err := snap.tree.Proof(ul.first + i)
+		if err != nil {
+			return nil, err
+		}
+		path := make([]PathStep, len(steps))
+		for j, s := range steps {
+			path[j] = PathStep{Sibling: hex.EncodeToString(s.Sibling[:]), Left: s.Left}
+		}
+		proof.Leaves = append(proof.Leaves, LeafProof{Asset: a, Balance: ul.amount[i], Index: ul.first + i, Path: path})
+	}
+	return proof, nil
+}
+
+// VerifyUserProof 用户侧校验 (与服务端无关，只用证明本身和公布的根)
+func VerifyUserProof(p *UserProof) error {
+	root, err := decodeHash(p.Root)
+	if err != nil {
+		return err
+	}
+	nonce, err := hex.DecodeString(p.Nonce)
+	if err != nil {
+		return fmt.Errorf("%w: bad nonce", ErrInvalidProof)
+	}
+	for _, lp := range p.Leaves {
+		steps := make([]ProofStep, len(lp.Path))
+		for i, s := range lp.Path {
+			if steps[i].Sibling, err = decodeHash(s.Sibling); err != nil {
+				return err
+			}
+			steps[i].Left = s.Left
+		}
+		leaf := LeafHash(Leaf{UserID: p.UserID, Asset: lp.Asset, Balance: lp.Balance}, nonce)
+		if !VerifyProof(root, leaf, steps) {
+			return fmt.Errorf("%w: asset %s", ErrInvalidProof, lp.Asset)
+		}
+	}
+	return nil
+}
+
+func decodeHash(s string) (Hash, error) {
+	var h Hash
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(h) {
+		return h, fmt.Errorf("%w: bad hash %q", ErrInvalidProof, s)
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// NewHandler 创建储备金证明接口
+//
+//	GET /reserve/root   最新快照的根与负债总额 (公开)
+//	GET /reserve/proof  当前登录用户的包含证明
+//
+// userID 从请求中取出已认证的用户 ID (由网关鉴权后注入)，失败返回 error
+func NewHandler(g *Generator, userID func(r *http.Request) (int64, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reserve/root", func(w http.ResponseWriter, r *http.Request) {
+		snap := g.Latest()
+		if snap == nil {
+			http.Error(w, ErrNoSnapshot.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, snap.Commitment)
+	})
+	mux.HandleFunc("/reserve/proof", func(w http.ResponseWriter, r *http.Request) {
+		uid, err := userID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		proof, err := g.Proof(uid)
+		switch {
+		case errors.Is(err, ErrNoSnapshot), errors.Is(err, ErrUserNotInSnapshot):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			writeJSON(w, proof)
+		}
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,120 @@
+package reserve
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/fund"
+)
+
+func leafAt(i int) Hash {
+	return LeafHash(Leaf{UserID: int64(i), Asset: "USDT", Balance: int64(i) * 100}, nil)
+}
+
+func TestBuilder_ProofsForAllSizes(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		full, light := NewBuilder(true), NewBuilder(false)
+		for i := 0; i < n; i++ {
+			full.Add(leafAt(i))
+			light.Add(leafAt(i))
+		}
+		tree, _ := full.Finish()
+		lightTree, _ := light.Finish()
+		if tree.Root() != lightTree.Root() {
+			t.Fatalf("n=%d: streaming root differs from retained root", n)
+		}
+		for i := 0; i < n; i++ {
+			steps, err := tree.Proof(i)
+			if err != nil || !VerifyProof(tree.Root(), leafAt(i), steps) {
+				t.Fatalf("n=%d leaf %d: proof failed (%v)", n, i, err)
+			}
+			if n > 1 && VerifyProof(tree.Root(), leafAt(n+i), steps) {
+				t.Fatalf("n=%d leaf %d: foreign leaf verified", n, i)
+			}
+		}
+		if _, err := lightTree.Proof(0); !errors.Is(err, ErrProofUnavailable) {
+			t.Fatalf("expected ErrProofUnavailable, got %v", err)
+		}
+	}
+
+	// 复制最后一个叶子不能得到相同的根
+	a, b := NewBuilder(false), NewBuilder(false)
+	for i := 0; i < 3; i++ {
+		a.Add(leafAt(i))
+		b.Add(leafAt(i))
+	}
+	b.Add(leafAt(2))
+	ra, _ := a.Finish()
+	rb, _ := b.Finish()
+	if ra.Root() == rb.Root() {
+		t.Error("duplicated last leaf produced the same root")
+	}
+}
+
+type fakeHot map[int64]*asset.Snapshot
+
+func (h fakeHot) GetAllSnapshots() map[int64]*asset.Snapshot { return h }
+
+type fakeCold []*fund.BalanceRecord
+
+func (c fakeCold) ForEachBalance(ctx context.Context, batchSize int, fn func([]*fund.BalanceRecord) error) error {
+	return fn(c)
+}
+
+func TestGenerator_ReconciledProofs(t *testing.T) {
+	hot := fakeHot{
+		2: {UserID: 2, Assets: map[string]asset.Asset{"USDT": {Available: 70, Locked: 30}, "BTC": {Available: 5}, "ETH": {}}},
+		1: {UserID: 1, Assets: map[string]asset.Asset{"USDT": {Available: 10}}},
+	}
+	cold := fakeCold{
+		{UserID: 1, Symbol: "USDT", Available: 9}, // 写回延迟：以热账户为准
+		{UserID: 3, Symbol: "BTC", Available: 7},  // 不活跃用户只在冷库
+		{UserID: 4, Symbol: "BTC"},                // 0 余额不进树
+	}
+
+	var published Commitment
+	g := NewGenerator(&ReconciledSource{Hot: hot, Cold: cold}, Config{
+		Secret:  []byte("secret"),
+		Publish: func(ctx context.Context, c Commitment) error { published = c; return nil },
+	})
+	snap, err := g.Generate(context.Background(), "2026-10-16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.LeafCount != 4 || snap.UserCount != 3 || snap.Totals["USDT"] != 110 || snap.Totals["BTC"] != 12 {
+		t.Fatalf("unexpected commitment %+v", snap.Commitment)
+	}
+	if published.Root != snap.Root {
+		t.Errorf("root not published")
+	}
+	if len(snap.Mismatches) != 1 || snap.Mismatches[0] != (Mismatch{UserID: 1, Asset: "USDT", Hot: 10, Cold: 9}) {
+		t.Errorf("unexpected mismatches %+v", snap.Mismatches)
+	}
+
+	proof, err := g.Proof(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof.Leaves) != 2 || proof.Leaves[0].Asset != "BTC" || proof.Leaves[1].Balance != 100 {
+		t.Fatalf("unexpected proof %+v", proof)
+	}
+	if err := VerifyUserProof(proof); err != nil {
+		t.Fatal(err)
+	}
+
+	// 篡改余额或换掉 nonce 都验不过
+	proof.Leaves[1].Balance = 99
+	if err := VerifyUserProof(proof); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected tampered balance to fail, got %v", err)
+	}
+	proof, _ = g.Proof(3)
+	proof.Nonce = ""
+	if err := VerifyUserProof(proof); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected missing nonce to fail, got %v", err)
+	}
+	if _, err := g.Proof(4); !errors.Is(err, ErrUserNotInSnapshot) {
+		t.Errorf("expected ErrUserNotInSnapshot, got %v", err)
+	}
+}
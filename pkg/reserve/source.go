@@ -0,0 +1,128 @@
+package reserve
+
+import (
+	"context"
+	"sort"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/fund"
+)
+
+// =============================================================================
+// 余额来源
+// =============================================================================
+
+// Source 快照余额来源
+//
+// emit 必须按 (UserID, Asset) 升序调用：同一份余额每次建出的树都一样，
+// 用户才能拿旧快照的证明去对已发布的根
+type Source interface {
+	Leaves(ctx context.Context, emit func(Leaf) error) ([]Mismatch, error)
+}
+
+// Mismatch 热冷账户对不上的余额
+type Mismatch struct {
+	UserID int64
+	Asset  string
+	Hot    int64
+	Cold   int64
+}
+
+// HotSnapshots 热账户快照 (asset.AccountEngine 实现)
+type HotSnapshots interface {
+	GetAllSnapshots() map[int64]*asset.Snapshot
+}
+
+// ColdBalances 冷账户余额 (fund.BalanceRepo 实现)
+type ColdBalances interface {
+	ForEachBalance(ctx context.Context, batchSize int, fn func([]*fund.BalanceRecord) error) error
+}
+
+var (
+	_ HotSnapshots = (*asset.AccountEngine)(nil)
+	_ ColdBalances = (*fund.BalanceRepo)(nil)
+)
+
+// ReconciledSource 热冷对账后的余额
+//
+// 【规则】
+//   - 热账户是实时账本，热冷都有时以热账户为准，不一致的记入 Mismatch
+//   - 只有冷账户有的 (长期不活跃、未加载进内存的用户) 用冷账户余额
+//   - 余额为 0 的不进树
+//
+// 【注意】GetAllSnapshots 与冷库扫描不是同一时刻，写回冷库有延迟，
+// 少量 Mismatch 是正常的；数量或金额异常时应暂停发布 (由调用方根据 Mismatch 判断)
+type ReconciledSource struct {
+	Hot       HotSnapshots // 可为 nil (只用冷账户)
+	Cold      ColdBalances // 可为 nil (只用热账户)
+	BatchSize int          // 冷库分批大小，默认 1000
+}
+
+// Leaves 实现 Source
+func (s *ReconciledSource) Leaves(ctx context.Context, emit func(Leaf) error) ([]Mismatch, error) {
+	var hot map[int64]*asset.Snapshot
+	if s.Hot != nil {
+		hot = s.Hot.GetAllSnapshots()
+	}
+
+	// 冷库只保留热账户没有的余额和不一致的余额，不把全量冷库读进内存
+	coldOnly := make(map[int64]map[string]int64)
+	var mismatches []Mismatch
+	if s.Cold != nil {
+		err := s.Cold.ForEachBalance(ctx, s.BatchSize, func(records []*fund.BalanceRecord) error {
+			for _, r := range records {
+				cold := r.Available + r.Locked
+				if snap, ok := hot[r.UserID]; ok {
+					a := snap.Assets[r.Symbol]
+					if h := a.Available + a.Locked; h != cold {
+						mismatches = append(mismatches, Mismatch{UserID: r.UserID, Asset: r.Symbol, Hot: h, Cold: cold})
+					}
+					continue
+				}
+				if cold == 0 {
+					continue
+				}
+				if coldOnly[r.UserID] == nil {
+					coldOnly[r.UserID] = make(map[string]int64)
+				}
+				coldOnly[r.UserID][r.Symbol] = cold
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	userIDs := make([]int64, 0, len(hot)+len(coldOnly))
+	for id := range hot {
+		userIDs = append(userIDs, id)
+	}
+	for id := range coldOnly {
+		userIDs = append(userIDs, id)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	for _, id := range userIDs {
+		balances := coldOnly[id]
+		if snap, ok := hot[id]; ok {
+			balances = make(map[string]int64, len(snap.Assets))
+			for symbol, a := range snap.Assets {
+				balances[symbol] = a.Available + a.Locked
+			}
+		}
+		assets := make([]string, 0, len(balances))
+		for symbol, amount := range balances {
+			if amount != 0 {
+				assets = append(assets, symbol)
+			}
+		}
+		sort.Strings(assets)
+		for _, symbol := range assets {
+			if err := emit(Leaf{UserID: id, Asset: symbol, Balance: balances[symbol]}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mismatches, nil
+}
@@ -92,8 +92,8 @@ func (e *Engine) ComputeRisk(in RiskInput) (RiskOutput, error) {
 
 	// 3. 账户级风控计算 (Cross Margin / 全仓模式)
 
-	// 动态权益 = 静态余额 + 总未实现盈亏
-	equity := in.Account.Balance + totalUPnL
+	// 动态权益 = 静态余额 + 抵押品价值 + 总未实现盈亏
+	equity := in.Account.Balance + in.Account.Collateral + totalUPnL
 
 	// 风险率 = 维持保证金 / 动态权益
 	// Risk Ratio >= 1.0 意味着 权益 < 维持保证金 -> 爆仓
@@ -102,6 +102,28 @@ func TestComputeRisk_Liquidation(t *testing.T) {
 	}
 }
 
+func TestComputeRisk_CollateralAbsorbsLoss(t *testing.T) {
+	e := NewEngine()
+
+	// 同上的仓位，另有折算后 100 U 的 BTC 抵押品
+	// Equity = 100 + 100 - 50 = 150 > MaintMargin 99.75，不再强平
+	in := RiskInput{
+		Account: Account{Balance: 100, Collateral: 100, InitMarginRate: 0.01},
+		Positions: []Position{
+			{Instrument: InstrumentPerp, Symbol: "ETH_USDT", Qty: 10, EntryPrice: 2000, MaintenanceMarginRate: 0.005},
+		},
+		Prices: map[string]PriceSnapshot{"ETH_USDT": {MarkPrice: 1995}},
+	}
+
+	out, err := e.ComputeRisk(in)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !almostEqual(out.Equity, 150) || out.RiskRatio >= 1.0 {
+		t.Errorf("expected equity 150 and RiskRatio < 1, got %v / %v", out.Equity, out.RiskRatio)
+	}
+}
+
 // almostEqual 用于比较两个 float64 是否相等 (容忍微小误差)
 func almostEqual(a, b float64) bool {
 	return math.Abs(a-b) <= 1e-9
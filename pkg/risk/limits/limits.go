@@ -0,0 +1,312 @@
+// Package limits 下单前风控 (pre-trade risk check)
+//
+// 现货 SpotProcessor.PlaceOrder 和合约 FuturesProcessor.OpenPosition
+// 在冻结资金之前都会调用 Service.Check，任何一项不通过直接拒单。
+//
+// 【检查项】（按顺序，命中即返回）
+//  1. 禁止交易的交易对（全局 / 按用户）
+//  2. 国家限制（全局 / 按交易对）
+//  3. 单笔订单价值上限 MaxOrderValue
+//  4. 持仓（挂单）名义价值上限 MaxOpenNotional = 当前敞口 + 本单
+//
+// 【限额优先级】用户 > 交易对 > 默认，按字段覆盖，0 表示继承上一级
+//
+// 【fail-closed】
+// 国家、敞口需要查外部数据；查询失败或未配置数据源时一律拒单，
+// 宁可误拒也不能在风控不可用时放行
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrSymbolBlocked        = errors.New("risk: symbol is blocked")
+	ErrCountryRestricted    = errors.New("risk: country is restricted")
+	ErrOrderValueExceeded   = errors.New("risk: order value exceeds limit")
+	ErrOpenNotionalExceeded = errors.New("risk: open notional exceeds limit")
+	ErrLookupFailed         = errors.New("risk: lookup failed")
+)
+
+// =============================================================================
+// 外部数据源
+// =============================================================================
+
+// ExposureProvider 查询用户在某交易对上的当前敞口（名义价值）
+// 现货: 未成交挂单价值；合约: 持仓价值
+type ExposureProvider interface {
+	OpenNotional(ctx context.Context, userID int64, symbol string) (int64, error)
+}
+
+// ProfileProvider 查询用户 KYC 国家（ISO 3166 代码）
+type ProfileProvider interface {
+	Country(ctx context.Context, userID int64) (string, error)
+}
+
+// =============================================================================
+// 规则
+// =============================================================================
+
+// Limits 数值限额，0 表示不限（或继承上一级）
+type Limits struct {
+	MaxOpenNotional int64 // 当前敞口 + 本单的上限
+	MaxOrderValue   int64 // 单笔订单价值上限
+}
+
+// merge 用 o 中的非零字段覆盖 l
+func (l Limits) merge(o Limits) Limits {
+	if o.MaxOpenNotional != 0 {
+		l.MaxOpenNotional = o.MaxOpenNotional
+	}
+	if o.MaxOrderValue != 0 {
+		l.MaxOrderValue = o.MaxOrderValue
+	}
+	return l
+}
+
+// Rules 全部风控规则
+type Rules struct {
+	Default Limits
+	Symbols map[string]Limits // 按交易对覆盖
+	Users   map[int64]Limits  // 按用户覆盖
+
+	BlockedSymbols     map[string]bool           // 全局禁止交易
+	UserBlockedSymbols map[int64]map[string]bool // 按用户禁止交易
+
+	RestrictedCountries       map[string]bool            // 全局禁止的国家
+	SymbolRestrictedCountries map[string]map[string]bool // 按交易对禁止的国家
+}
+
+// clone 深拷贝（运行时修改走写时复制）
+func (r *Rules) clone() *Rules {
+	c := &Rules{
+		Default:                   r.Default,
+		Symbols:                   make(map[string]Limits, len(r.Symbols)),
+		Users:                     make(map[int64]Limits, len(r.Users)),
+		BlockedSymbols:            make(map[string]bool, len(r.BlockedSymbols)),
+		UserBlockedSymbols:        make(map[int64]map[string]bool, len(r.UserBlockedSymbols)),
+		RestrictedCountries:       make(map[string]bool, len(r.RestrictedCountries)),
+		SymbolRestrictedCountries: make(map[string]map[string]bool, len(r.SymbolRestrictedCountries)),
+	}
+	for k, v := range r.Symbols {
+		c.Symbols[k] = v
+	}
+	for k, v := range r.Users {
+		c.Users[k] = v
+	}
+	for k, v := range r.BlockedSymbols {
+		c.BlockedSymbols[k] = v
+	}
+	for k, v := range r.UserBlockedSymbols {
+		c.UserBlockedSymbols[k] = cloneSet(v)
+	}
+	for k, v := range r.RestrictedCountries {
+		c.RestrictedCountries[k] = v
+	}
+	for k, v := range r.SymbolRestrictedCountries {
+		c.SymbolRestrictedCountries[k] = cloneSet(v)
+	}
+	return c
+}
+
+func cloneSet(s map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// limitsFor 解析某用户在某交易对上的生效限额
+func (r *Rules) limitsFor(userID int64, symbol string) Limits {
+	return r.Default.merge(r.Symbols[symbol]).merge(r.Users[userID])
+}
+
+// hasCountryRules 是否配置了任何国家限制（没有则不查 KYC）
+func (r *Rules) hasCountryRules(symbol string) bool {
+	return len(r.RestrictedCountries) > 0 || len(r.SymbolRestrictedCountries[symbol]) > 0
+}
+
+// =============================================================================
+// Service
+// =============================================================================
+
+// Request 一次下单的风控请求
+type Request struct {
+	UserID   int64
+	Symbol   string
+	Notional int64 // 本单名义价值（与敞口同单位）
+}
+
+// Service 下单前风控服务
+//
+// 【并发】Check 在下单热路径上，只做一次原子读；
+// 规则修改是低频运维操作，加锁后写时复制整份 Rules
+type Service struct {
+	profiles ProfileProvider
+
+	rules atomic.Pointer[Rules]
+	mu    sync.Mutex // 串行化规则修改
+}
+
+// NewService 创建风控服务
+// profiles 可以为 nil，此时一旦配置国家限制，所有下单都会被拒 (fail-closed)
+func NewService(rules Rules, profiles ProfileProvider) *Service {
+	s := &Service{profiles: profiles}
+	s.rules.Store(rules.clone())
+	return s
+}
+
+// Check 下单前检查
+// exposure 由调用方提供（现货/合约的敞口口径不同），为 nil 时视为查询失败
+func (s *Service) Check(ctx context.Context, exposure ExposureProvider, req Request) error {
+	rules := s.rules.Load()
+
+	// 1. 禁止交易的交易对
+	if rules.BlockedSymbols[req.Symbol] || rules.UserBlockedSymbols[req.UserID][req.Symbol] {
+		return fmt.Errorf("%w: %s", ErrSymbolBlocked, req.Symbol)
+	}
+
+	// 2. 国家限制
+	if rules.hasCountryRules(req.Symbol) {
+		if s.profiles == nil {
+			return fmt.Errorf("%w: no profile provider", ErrLookupFailed)
+		}
+		country, err := s.profiles.Country(ctx, req.UserID)
+		if err != nil {
+			return fmt.Errorf("%w: country of user %d: %v", ErrLookupFailed, req.UserID, err)
+		}
+		if rules.RestrictedCountries[country] || rules.SymbolRestrictedCountries[req.Symbol][country] {
+			return fmt.Errorf("%w: %s on %s", ErrCountryRestricted, country, req.Symbol)
+		}
+	}
+
+	limits := rules.limitsFor(req.UserID, req.Symbol)
+
+	// 3. 单笔订单价值
+	if limits.MaxOrderValue > 0 && req.Notional > limits.MaxOrderValue {
+		return fmt.Errorf("%w: %d > %d", ErrOrderValueExceeded, req.Notional, limits.MaxOrderValue)
+	}
+
+	// 4. 敞口上限
+	if limits.MaxOpenNotional > 0 {
+		if exposure == nil {
+			return fmt.Errorf("%w: no exposure provider", ErrLookupFailed)
+		}
+		current, err := exposure.OpenNotional(ctx, req.UserID, req.Symbol)
+		if err != nil {
+			return fmt.Errorf("%w: exposure of user %d: %v", ErrLookupFailed, req.UserID, err)
+		}
+		if current+req.Notional > limits.MaxOpenNotional {
+			return fmt.Errorf("%w: %d + %d > %d",
+				ErrOpenNotionalExceeded, current, req.Notional, limits.MaxOpenNotional)
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+// 运行时配置
+// =============================================================================
+
+// Rules 当前规则的副本
+func (s *Service) Rules() Rules {
+	return *s.rules.Load().clone()
+}
+
+// SetRules 整体替换规则
+func (s *Service) SetRules(rules Rules) {
+	s.update(func(r *Rules) { *r = *rules.clone() })
+}
+
+// SetDefaultLimits 设置默认限额
+func (s *Service) SetDefaultLimits(l Limits) {
+	s.update(func(r *Rules) { r.Default = l })
+}
+
+// SetSymbolLimits 设置交易对限额，传零值表示删除
+func (s *Service) SetSymbolLimits(symbol string, l Limits) {
+	s.update(func(r *Rules) {
+		if l == (Limits{}) {
+			delete(r.Symbols, symbol)
+			return
+		}
+		r.Symbols[symbol] = l
+	})
+}
+
+// SetUserLimits 设置用户限额，传零值表示删除
+func (s *Service) SetUserLimits(userID int64, l Limits) {
+	s.update(func(r *Rules) {
+		if l == (Limits{}) {
+			delete(r.Users, userID)
+			return
+		}
+		r.Users[userID] = l
+	})
+}
+
+// BlockSymbol 全局禁止 / 恢复交易对
+func (s *Service) BlockSymbol(symbol string, blocked bool) {
+	s.update(func(r *Rules) { setFlag(r.BlockedSymbols, symbol, blocked) })
+}
+
+// BlockUserSymbol 禁止 / 恢复某用户交易某交易对
+func (s *Service) BlockUserSymbol(userID int64, symbol string, blocked bool) {
+	s.update(func(r *Rules) {
+		set := r.UserBlockedSymbols[userID]
+		if set == nil {
+			set = make(map[string]bool)
+			r.UserBlockedSymbols[userID] = set
+		}
+		setFlag(set, symbol, blocked)
+		if len(set) == 0 {
+			delete(r.UserBlockedSymbols, userID)
+		}
+	})
+}
+
+// RestrictCountry 限制 / 解除某国家；symbol 为空表示全局
+func (s *Service) RestrictCountry(country, symbol string, restricted bool) {
+	s.update(func(r *Rules) {
+		if symbol == "" {
+			setFlag(r.RestrictedCountries, country, restricted)
+			return
+		}
+		set := r.SymbolRestrictedCountries[symbol]
+		if set == nil {
+			set = make(map[string]bool)
+			r.SymbolRestrictedCountries[symbol] = set
+		}
+		setFlag(set, country, restricted)
+		if len(set) == 0 {
+			delete(r.SymbolRestrictedCountries, symbol)
+		}
+	})
+}
+
+// update 写时复制修改规则
+func (s *Service) update(fn func(r *Rules)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.rules.Load().clone()
+	fn(next)
+	s.rules.Store(next)
+}
+
+func setFlag(set map[string]bool, key string, on bool) {
+	if on {
+		set[key] = true
+	} else {
+		delete(set, key)
+	}
+}
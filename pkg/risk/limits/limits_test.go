@@ -0,0 +1,130 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeExposure struct {
+	notional int64
+	err      error
+}
+
+func (f *fakeExposure) OpenNotional(ctx context.Context, userID int64, symbol string) (int64, error) {
+	return f.notional, f.err
+}
+
+type fakeProfiles map[int64]string
+
+func (f fakeProfiles) Country(ctx context.Context, userID int64) (string, error) {
+	c, ok := f[userID]
+	if !ok {
+		return "", errors.New("kyc not found")
+	}
+	return c, nil
+}
+
+func TestCheck_Limits(t *testing.T) {
+	s := NewService(Rules{
+		Default: Limits{MaxOpenNotional: 1000, MaxOrderValue: 500},
+		Symbols: map[string]Limits{"ETH_USDT": {MaxOrderValue: 100}},
+		Users:   map[int64]Limits{7: {MaxOpenNotional: 5000}},
+	}, nil)
+	ctx := context.Background()
+	exp := &fakeExposure{notional: 600}
+
+	cases := []struct {
+		name string
+		req  Request
+		want error
+	}{
+		{"within limits", Request{UserID: 1, Symbol: "BTC_USDT", Notional: 400}, nil},
+		{"order too large", Request{UserID: 1, Symbol: "BTC_USDT", Notional: 501}, ErrOrderValueExceeded},
+		{"symbol override", Request{UserID: 1, Symbol: "ETH_USDT", Notional: 101}, ErrOrderValueExceeded},
+		{"open notional", Request{UserID: 1, Symbol: "BTC_USDT", Notional: 401}, ErrOpenNotionalExceeded},
+		{"user override keeps default order cap", Request{UserID: 7, Symbol: "BTC_USDT", Notional: 500}, nil},
+	}
+	for _, c := range cases {
+		if err := s.Check(ctx, exp, c.req); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestCheck_FailClosed(t *testing.T) {
+	s := NewService(Rules{Default: Limits{MaxOpenNotional: 1000}}, nil)
+	ctx := context.Background()
+	req := Request{UserID: 1, Symbol: "BTC_USDT", Notional: 1}
+
+	if err := s.Check(ctx, &fakeExposure{err: errors.New("db down")}, req); !errors.Is(err, ErrLookupFailed) {
+		t.Errorf("expected lookup failure on exposure error, got %v", err)
+	}
+	if err := s.Check(ctx, nil, req); !errors.Is(err, ErrLookupFailed) {
+		t.Errorf("expected lookup failure without exposure provider, got %v", err)
+	}
+
+	// 配置了国家限制却没有 KYC 数据源
+	s.RestrictCountry("KP", "", true)
+	if err := s.Check(ctx, &fakeExposure{}, req); !errors.Is(err, ErrLookupFailed) {
+		t.Errorf("expected lookup failure without profile provider, got %v", err)
+	}
+}
+
+func TestCheck_BlockedAndCountries(t *testing.T) {
+	s := NewService(Rules{}, fakeProfiles{1: "US", 2: "SG"})
+	ctx := context.Background()
+	exp := &fakeExposure{}
+
+	s.BlockSymbol("LUNA_USDT", true)
+	s.BlockUserSymbol(2, "BTC_USDT", true)
+	s.RestrictCountry("US", "ETH_USDT", true)
+
+	cases := []struct {
+		req  Request
+		want error
+	}{
+		{Request{UserID: 1, Symbol: "LUNA_USDT"}, ErrSymbolBlocked},
+		{Request{UserID: 2, Symbol: "BTC_USDT"}, ErrSymbolBlocked},
+		{Request{UserID: 1, Symbol: "BTC_USDT"}, nil},
+		{Request{UserID: 1, Symbol: "ETH_USDT"}, ErrCountryRestricted},
+		{Request{UserID: 2, Symbol: "ETH_USDT"}, nil},
+		{Request{UserID: 3, Symbol: "ETH_USDT"}, ErrLookupFailed}, // 无 KYC 记录
+		{Request{UserID: 3, Symbol: "BTC_USDT"}, nil},             // 该交易对没有国家限制，不查 KYC
+	}
+	for _, c := range cases {
+		if err := s.Check(ctx, exp, c.req); !errors.Is(err, c.want) {
+			t.Errorf("%+v: expected %v, got %v", c.req, c.want, err)
+		}
+	}
+
+	// 运行时解除
+	s.BlockSymbol("LUNA_USDT", false)
+	s.BlockUserSymbol(2, "BTC_USDT", false)
+	s.RestrictCountry("US", "ETH_USDT", false)
+	for _, req := range []Request{{UserID: 1, Symbol: "LUNA_USDT"}, {UserID: 2, Symbol: "BTC_USDT"}, {UserID: 1, Symbol: "ETH_USDT"}} {
+		if err := s.Check(ctx, exp, req); err != nil {
+			t.Errorf("%+v: expected pass after unblock, got %v", req, err)
+		}
+	}
+	if r := s.Rules(); len(r.UserBlockedSymbols) != 0 || len(r.SymbolRestrictedCountries) != 0 {
+		t.Errorf("empty rule sets should be pruned: %+v", r)
+	}
+}
+
+func TestRules_CopyOnWrite(t *testing.T) {
+	rules := Rules{Symbols: map[string]Limits{"BTC_USDT": {MaxOrderValue: 10}}}
+	s := NewService(rules, nil)
+
+	// 调用方修改自己的 map 不影响服务
+	rules.Symbols["BTC_USDT"] = Limits{MaxOrderValue: 1}
+	snapshot := s.Rules()
+	s.SetSymbolLimits("BTC_USDT", Limits{MaxOrderValue: 20})
+
+	if snapshot.Symbols["BTC_USDT"].MaxOrderValue != 10 {
+		t.Errorf("snapshot changed after update: %+v", snapshot.Symbols)
+	}
+	if got := s.Rules().Symbols["BTC_USDT"].MaxOrderValue; got != 20 {
+		t.Errorf("expected updated limit 20, got %d", got)
+	}
+}
@@ -80,6 +80,10 @@ type Account struct {
 	// Equity(动态) = Balance(静态) + uPnL(浮动)
 	Balance float64 `json:"balance"`
 
+	// collateral：其他币种抵押品折算成余额币种后的价值 (已按折价率打折，见 futures.CollateralService)
+	// 和 Balance 一起计入权益，吸收浮亏
+	Collateral float64 `json:"collateral"`
+
 	// init_margin_rate：初始保证金率（Day1 占位参数）
 	// 举例：0.1 表示名义价值的 10% 作为初始保证金需求。
 	//
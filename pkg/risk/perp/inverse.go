@@ -0,0 +1,77 @@
+package perp
+
+// =============================================================================
+// 反向合约 (币本位，Coin-Margined)
+// =============================================================================
+//
+// 【和线性合约的区别】
+// - 数量单位是"张"，每张面值 ContractSize 个报价币 (如 1 张 = 100 USD)
+// - 保证金、盈亏、资金费都以基础币结算 (BTCUSD 永续用 BTC 结算)
+// - 名义价值 (基础币) = |Qty| × ContractSize / MarkPrice
+// - 盈亏 (基础币)     = Qty × ContractSize × (1/EntryPrice − 1/MarkPrice)
+//
+// 【面试】为什么币本位 1 倍空单等于"锁定美元价值"？
+// 空单亏损随价格上涨趋近于 Qty × ContractSize / EntryPrice，恰好等于开仓时的保证金，
+// 价格涨到多高都亏不完，所以 1 倍空单没有强平价格
+
+// CalculateInverseRisk 反向合约风险计算
+//
+// pos.Qty 为张数 (+多, -空)，balance 与返回值均以基础币计价
+func CalculateInverseRisk(pos Position, contractSize, balance float64) RiskMetrics {
+	if pos.Qty == 0 || pos.EntryPrice <= 0 || pos.MarkPrice <= 0 {
+		return RiskMetrics{}
+	}
+
+	absQty := pos.Qty
+	if absQty < 0 {
+		absQty = -absQty
+	}
+	notional := absQty * contractSize / pos.MarkPrice
+	uPnL := pos.Qty * contractSize * (1/pos.EntryPrice - 1/pos.MarkPrice)
+
+	mmr := notional * pos.MaintenanceRate
+	imr := notional * pos.InitialRate
+	equity := balance + uPnL
+
+	return RiskMetrics{
+		Notional:       notional,
+		UnrealizedPnL:  uPnL,
+		MaintMarginReq: mmr,
+		InitMarginReq:  imr,
+		IsLiquidatable: equity <= mmr,
+	}
+}
+
+// CalculateInverseLiquidationPrice 反向合约强平价格
+//
+// 【推导】设 k = Qty × ContractSize，强平条件 Balance + uPnL = 名义价值 × mmr：
+//
+//	Balance + k/Entry − k/P = |k|/P × mmr
+//
+// 多仓 (k > 0): P = k × (1 + mmr) / (Balance + k/Entry)
+// 空仓 (k < 0): P = k × (1 − mmr) / (Balance + k/Entry)
+//
+// 空仓分母 >= 0 (Balance >= |k|/Entry，即不超过 1 倍杠杆) 说明保证金足以覆盖
+// 价格涨到无穷的亏损，不会强平，返回 0
+func CalculateInverseLiquidationPrice(qty, contractSize, entryPrice, balance, mmr float64) float64 {
+	if qty == 0 || entryPrice <= 0 {
+		return 0
+	}
+
+	k := qty * contractSize
+	denom := balance + k/entryPrice
+	if denom == 0 {
+		return 0
+	}
+
+	var liqPrice float64
+	if k > 0 {
+		liqPrice = k * (1 + mmr) / denom
+	} else {
+		liqPrice = k * (1 - mmr) / denom
+	}
+	if liqPrice <= 0 {
+		return 0
+	}
+	return liqPrice
+}
@@ -70,3 +70,36 @@ func TestCalculateLiquidationPrice(t *testing.T) {
 		}
 	})
 }
+
+// 测试反向合约：100 张 × 100 USD，开仓 50000，保证金 0.02 BTC (10 倍)
+func TestCalculateInverseLiquidationPrice(t *testing.T) {
+	cases := []struct {
+		name     string
+		qty      float64
+		balance  float64
+		expected float64
+	}{
+		{"Long Position", 100, 0.02, 45681.82},
+		{"Short Position", -100, 0.02, 55277.78},
+		{"Short 1x", -100, 0.2, 0},    // 1 倍空单不会强平
+		{"Short Over", -100, 0.25, 0}, // 超额保证金同样不会强平
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			liqPrice := CalculateInverseLiquidationPrice(c.qty, 100, 50000, c.balance, 0.005)
+			if math.Abs(liqPrice-c.expected) > 1 {
+				t.Errorf("Expected ~%.2f, got %.2f", c.expected, liqPrice)
+			}
+			if liqPrice == 0 {
+				return
+			}
+			// 强平价处权益恰好等于维持保证金
+			m := CalculateInverseRisk(Position{
+				Qty: c.qty, EntryPrice: 50000, MarkPrice: liqPrice, MaintenanceRate: 0.005,
+			}, 100, c.balance)
+			if equity := c.balance + m.UnrealizedPnL; math.Abs(equity-m.MaintMarginReq) > 1e-9 {
+				t.Errorf("equity %.8f != maint margin %.8f at liq price", equity, m.MaintMarginReq)
+			}
+		})
+	}
+}
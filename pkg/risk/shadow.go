@@ -0,0 +1,287 @@
+package risk
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 影子模式 (Shadow Mode)：新旧风控模型并行跑，只比对不生效
+// =============================================================================
+//
+// 【为什么需要】改保证金公式 (换分档、换维持保证金率算法) 是高危操作：
+// 公式一错，要么一批用户被误强平，要么该强平的没强平。上线前要用真实持仓验证：
+//
+//	同一个 RiskInput → 主模型 (线上) 的结果照常返回
+//	                 → 影子模型 (新公式) 的结果只用来比对，超出容差记一条分歧
+//
+// 跑一段时间看统计：分歧率多少、风险率最大偏差多少、有没有 "一边说要强平、另一边说安全" 的翻转。
+//
+// 【注意】
+//   - 影子模型的错误 / panic 只计数，绝不影响主模型的结果
+//   - 影子模型同步执行，CPU 成本翻倍：扫描器按功能开关的用户比例采样 (liquidation.FlagShadowRisk)
+//   - 只保留最近 MaxSamples 条分歧样本 (含完整输入)，足够复现问题，又不会无限增长
+
+// RiskModel 风控模型：RiskInput → RiskOutput（*Engine 实现）
+type RiskModel interface {
+	ComputeRisk(in RiskInput) (RiskOutput, error)
+}
+
+var _ RiskModel = (*Engine)(nil)
+
+// ShadowConfig 影子比对配置
+type ShadowConfig struct {
+	// RatioTolerance 风险率允许的绝对偏差，默认 0.0001 (0.01 个百分点)
+	RatioTolerance float64
+
+	// ValueTolerance 金额类指标 (权益、保证金、名义价值、浮盈) 允许的相对偏差，默认 1e-6
+	// 按 |a-b| / max(|a|, |b|, 1) 计算，避免金额接近 0 时相对偏差被放大
+	ValueTolerance float64
+
+	// MaxSamples 保留最近多少条分歧样本，默认 100
+	MaxSamples int
+
+	// OnDivergence 发现分歧时回调 (可选，比如打日志、推告警)，在计算线程里同步调用，不要阻塞
+	OnDivergence func(Divergence)
+}
+
+func (c ShadowConfig) withDefaults() ShadowConfig {
+	if c.RatioTolerance <= 0 {
+		c.RatioTolerance = 0.0001
+	}
+	if c.ValueTolerance <= 0 {
+		c.ValueTolerance = 1e-6
+	}
+	if c.MaxSamples <= 0 {
+		c.MaxSamples = 100
+	}
+	return c
+}
+
+// 比对的字段名 (Divergence.Fields、ShadowStats.FieldDivergences 的 key)
+const (
+	FieldNotional       = "notional"
+	FieldTotalUPnL      = "total_upnl"
+	FieldEquity         = "equity"
+	FieldMaintMarginReq = "maint_margin_req"
+	FieldInitMarginReq  = "init_margin_req"
+	FieldRiskRatio      = "risk_ratio"
+	FieldError          = "error" // 一边成功、一边失败
+)
+
+// Divergence 一次超出容差的分歧
+type Divergence struct {
+	UserID     int64      `json:"user_id"`
+	At         time.Time  `json:"at"`
+	Fields     []string   `json:"fields"` // 超出容差的字段
+	Input      RiskInput  `json:"input"`
+	Primary    RiskOutput `json:"primary"`
+	Shadow     RiskOutput `json:"shadow"`
+	PrimaryErr string     `json:"primary_err,omitempty"`
+	ShadowErr  string     `json:"shadow_err,omitempty"`
+
+	// LiquidationFlip 一边风险率 >= 1 (要强平)、另一边 < 1：最严重的一类分歧
+	LiquidationFlip bool `json:"liquidation_flip"`
+}
+
+// ShadowStats 比对统计
+type ShadowStats struct {
+	Evaluations      int64            `json:"evaluations"`       // 比对次数
+	Divergences      int64            `json:"divergences"`       // 超出容差的次数
+	LiquidationFlips int64            `json:"liquidation_flips"` // 强平判定翻转次数
+	ShadowErrors     int64            `json:"shadow_errors"`     // 影子模型报错 / panic 次数 (主模型成功时)
+	FieldDivergences map[string]int64 `json:"field_divergences"` // 按字段统计
+
+	MaxRatioDiff  float64 `json:"max_ratio_diff"`  // 风险率最大绝对偏差 (两边都有限时)
+	MeanRatioDiff float64 `json:"mean_ratio_diff"` // 风险率平均绝对偏差
+
+	Samples []Divergence `json:"samples"` // 最近的分歧样本，从旧到新
+}
+
+// DivergenceRate 分歧率
+func (s ShadowStats) DivergenceRate() float64 {
+	if s.Evaluations == 0 {
+		return 0
+	}
+	return float64(s.Divergences) / float64(s.Evaluations)
+}
+
+// Shadow 影子评估：返回主模型的结果，同时用影子模型比对
+type Shadow struct {
+	primary RiskModel
+	shadow  RiskModel
+	cfg     ShadowConfig
+	now     func() time.Time
+
+	mu           sync.Mutex
+	stats        ShadowStats
+	ratioDiffSum float64
+	ratioSamples int64
+	samples      []Divergence // 环形缓冲
+	next         int
+}
+
+// NewShadow 创建影子评估，primary 为线上模型，shadow 为待验证的模型
+func NewShadow(primary, shadow RiskModel, cfg ShadowConfig) *Shadow {
+	return &Shadow{
+		primary: primary,
+		shadow:  shadow,
+		cfg:     cfg.withDefaults(),
+		now:     time.Now,
+		stats:   ShadowStats{FieldDivergences: make(map[string]int64)},
+	}
+}
+
+// ComputeRisk 实现 RiskModel (不区分用户)，见 Evaluate
+func (s *Shadow) ComputeRisk(in RiskInput) (RiskOutput, error) {
+	return s.Evaluate(0, in)
+}
+
+// Evaluate 计算主模型的结果并返回；影子模型跑同一份输入，超出容差时记录分歧
+func (s *Shadow) Evaluate(userID int64, in RiskInput) (RiskOutput, error) {
+	out, err := s.primary.ComputeRisk(in)
+	shadowOut, shadowErr := s.runShadow(in)
+
+	d := Divergence{UserID: userID, Input: in, Primary: out, Shadow: shadowOut}
+	if err != nil {
+		d.PrimaryErr = err.Error()
+	}
+	if shadowErr != nil {
+		d.ShadowErr = shadowErr.Error()
+	}
+
+	switch {
+	case err != nil && shadowErr != nil:
+		// 两边都拒绝 (输入非法)，不算分歧
+	case err != nil || shadowErr != nil:
+		d.Fields = []string{FieldError}
+	default:
+		d.Fields = s.compare(out, shadowOut)
+		d.LiquidationFlip = (out.RiskRatio >= 1) != (shadowOut.RiskRatio >= 1)
+	}
+	s.record(d, err == nil && shadowErr == nil, err == nil && shadowErr != nil)
+	return out, err
+}
+
+// runShadow 运行影子模型，panic 转成错误
+func (s *Shadow) runShadow(in RiskInput) (out RiskOutput, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("shadow model panic: %v", r)
+		}
+	}()
+	return s.shadow.ComputeRisk(in)
+}
+
+// compare 逐字段比对，返回超出容差的字段
+func (s *Shadow) compare(a, b RiskOutput) []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		a, b float64
+	}{
+		{FieldNotional, a.Notional, b.Notional},
+		{FieldTotalUPnL, a.TotalUPnL, b.TotalUPnL},
+		{FieldEquity, a.Equity, b.Equity},
+		{FieldMaintMarginReq, a.MaintMarginReq, b.MaintMarginReq},
+		{FieldInitMarginReq, a.InitMarginReq, b.InitMarginReq},
+	} {
+		if relDiff(f.a, f.b) > s.cfg.ValueTolerance {
+			fields = append(fields, f.name)
+		}
+	}
+	if absDiff(a.RiskRatio, b.RiskRatio) > s.cfg.RatioTolerance {
+		fields = append(fields, FieldRiskRatio)
+	}
+	return fields
+}
+
+// record 更新统计，有分歧时保存样本并回调
+func (s *Shadow) record(d Divergence, compared, shadowFailed bool) {
+	s.mu.Lock()
+	s.stats.Evaluations++
+	if shadowFailed {
+		s.stats.ShadowErrors++
+	}
+	if compared && !math.IsInf(d.Primary.RiskRatio, 0) && !math.IsInf(d.Shadow.RiskRatio, 0) {
+		diff := math.Abs(d.Primary.RiskRatio - d.Shadow.RiskRatio)
+		s.stats.MaxRatioDiff = max(s.stats.MaxRatioDiff, diff)
+		s.ratioDiffSum += diff
+		s.ratioSamples++
+	}
+	if len(d.Fields) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	d.At = s.now()
+	// 样本要保留到被覆盖，输入拷贝一份，调用方复用切片 / map 不影响
+	d.Input.Positions = slices.Clone(d.Input.Positions)
+	d.Input.Prices = maps.Clone(d.Input.Prices)
+	s.stats.Divergences++
+	if d.LiquidationFlip {
+		s.stats.LiquidationFlips++
+	}
+	for _, f := range d.Fields {
+		s.stats.FieldDivergences[f]++
+	}
+	if len(s.samples) < s.cfg.MaxSamples {
+		s.samples = append(s.samples, d)
+	} else {
+		s.samples[s.next] = d
+	}
+	s.next = (s.next + 1) % s.cfg.MaxSamples
+	s.mu.Unlock()
+
+	if s.cfg.OnDivergence != nil {
+		s.cfg.OnDivergence(d)
+	}
+}
+
+// Stats 当前统计 (拷贝)
+func (s *Shadow) Stats() ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.FieldDivergences = maps.Clone(s.stats.FieldDivergences)
+	if s.ratioSamples > 0 {
+		st.MeanRatioDiff = s.ratioDiffSum / float64(s.ratioSamples)
+	}
+	// 环形缓冲按时间顺序展开：未写满时从 0 开始，写满后从 next (最旧) 开始
+	st.Samples = make([]Divergence, 0, len(s.samples))
+	if len(s.samples) == s.cfg.MaxSamples {
+		st.Samples = append(st.Samples, s.samples[s.next:]...)
+		st.Samples = append(st.Samples, s.samples[:s.next]...)
+	} else {
+		st.Samples = append(st.Samples, s.samples...)
+	}
+	return st
+}
+
+// Reset 清空统计 (换一版影子模型、开始新一轮验证时调用)
+func (s *Shadow) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = ShadowStats{FieldDivergences: make(map[string]int64)}
+	s.ratioDiffSum, s.ratioSamples = 0, 0
+	s.samples, s.next = nil, 0
+}
+
+// absDiff 绝对偏差，两边都是同号无穷大时视为相等
+func absDiff(a, b float64) float64 {
+	if a == b {
+		return 0
+	}
+	return math.Abs(a - b)
+}
+
+// relDiff 相对偏差 |a-b| / max(|a|, |b|, 1)
+func relDiff(a, b float64) float64 {
+	if a == b {
+		return 0
+	}
+	return math.Abs(a-b) / max(math.Abs(a), math.Abs(b), 1)
+}
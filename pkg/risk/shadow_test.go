@@ -0,0 +1,173 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+)
+
+// scaledMMR 影子模型：维持保证金率整体乘以 factor (模拟 "新分档公式")
+type scaledMMR struct {
+	factor float64
+}
+
+func (m scaledMMR) ComputeRisk(in RiskInput) (RiskOutput, error) {
+	scaled := in
+	scaled.Positions = make([]Position, len(in.Positions))
+	for i, p := range in.Positions {
+		p.MaintenanceMarginRate *= m.factor
+		scaled.Positions[i] = p
+	}
+	return NewEngine().ComputeRisk(scaled)
+}
+
+type modelFunc func(RiskInput) (RiskOutput, error)
+
+func (f modelFunc) ComputeRisk(in RiskInput) (RiskOutput, error) { return f(in) }
+
+// shadowInput 1 BTC @ 50000，MMR 0.5% → 维持保证金 250，风险率 = 250 / balance
+func shadowInput(balance float64) RiskInput {
+	return RiskInput{
+		Account: Account{Balance: balance, InitMarginRate: 0.01},
+		Positions: []Position{{
+			Instrument:            InstrumentPerp,
+			Symbol:                "BTC_USDT",
+			Qty:                   1,
+			EntryPrice:            50000,
+			MaintenanceMarginRate: 0.005,
+		}},
+		Prices: map[string]PriceSnapshot{
+			"BTC_USDT": {Price: 50000, MarkPrice: 50000},
+		},
+	}
+}
+
+func TestShadow_SameModelNoDivergence(t *testing.T) {
+	s := NewShadow(NewEngine(), NewEngine(), ShadowConfig{})
+
+	for _, balance := range []float64{300, 500, 1000} {
+		if _, err := s.Evaluate(1, shadowInput(balance)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	st := s.Stats()
+	if st.Evaluations != 3 || st.Divergences != 0 || len(st.Samples) != 0 {
+		t.Errorf("stats = %+v, want 3 evaluations and no divergence", st)
+	}
+	if st.MaxRatioDiff != 0 || st.DivergenceRate() != 0 {
+		t.Errorf("MaxRatioDiff = %v, rate = %v, want 0", st.MaxRatioDiff, st.DivergenceRate())
+	}
+}
+
+func TestShadow_DivergenceAndLiquidationFlip(t *testing.T) {
+	var seen []Divergence
+	s := NewShadow(NewEngine(), scaledMMR{factor: 1.2}, ShadowConfig{
+		OnDivergence: func(d Divergence) { seen = append(seen, d) },
+	})
+
+	// 主模型: 250 / 1000 = 0.25，影子: 300 / 1000 = 0.30
+	out, err := s.Evaluate(1, shadowInput(1000))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 返回的始终是主模型的结果
+	if out.MaintMarginReq != 250 {
+		t.Errorf("MaintMarginReq = %v, want primary 250", out.MaintMarginReq)
+	}
+
+	// 主模型: 250 / 270 ≈ 0.93 (安全)，影子: 300 / 270 ≈ 1.11 (强平)
+	in := shadowInput(270)
+	if _, err := s.Evaluate(2, in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 调用方复用输入不影响已保存的样本
+	in.Positions[0].Qty = 99
+
+	st := s.Stats()
+	if st.Evaluations != 2 || st.Divergences != 2 || st.LiquidationFlips != 1 {
+		t.Fatalf("stats = %+v, want 2 divergences, 1 flip", st)
+	}
+	if st.FieldDivergences[FieldMaintMarginReq] != 2 || st.FieldDivergences[FieldRiskRatio] != 2 {
+		t.Errorf("FieldDivergences = %v", st.FieldDivergences)
+	}
+	if st.FieldDivergences[FieldEquity] != 0 {
+		t.Errorf("equity should not diverge: %v", st.FieldDivergences)
+	}
+	if len(seen) != 2 || len(st.Samples) != 2 {
+		t.Fatalf("callbacks = %d, samples = %d, want 2", len(seen), len(st.Samples))
+	}
+	flip := st.Samples[1]
+	if flip.UserID != 2 || !flip.LiquidationFlip {
+		t.Errorf("sample = %+v, want user 2 with liquidation flip", flip)
+	}
+	if flip.Input.Positions[0].Qty != 1 {
+		t.Errorf("sample input mutated: qty = %v", flip.Input.Positions[0].Qty)
+	}
+	if st.MaxRatioDiff < 0.18 || st.MeanRatioDiff <= 0 {
+		t.Errorf("MaxRatioDiff = %v, MeanRatioDiff = %v", st.MaxRatioDiff, st.MeanRatioDiff)
+	}
+}
+
+func TestShadow_ToleranceAbsorbsNoise(t *testing.T) {
+	// MMR 只差百万分之一：保证金差 0.00025，在默认容差内
+	s := NewShadow(NewEngine(), scaledMMR{factor: 1.000001}, ShadowConfig{})
+	if _, err := s.Evaluate(1, shadowInput(1000)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if st := s.Stats(); st.Divergences != 0 {
+		t.Errorf("Divergences = %d, want 0 (fields %v)", st.Divergences, st.FieldDivergences)
+	}
+}
+
+func TestShadow_ShadowFailureDoesNotAffectPrimary(t *testing.T) {
+	panicking := modelFunc(func(RiskInput) (RiskOutput, error) { panic("boom") })
+	s := NewShadow(NewEngine(), panicking, ShadowConfig{})
+
+	out, err := s.Evaluate(1, shadowInput(1000))
+	if err != nil {
+		t.Fatalf("primary error leaked: %v", err)
+	}
+	if out.RiskRatio != 0.25 {
+		t.Errorf("RiskRatio = %v, want 0.25", out.RiskRatio)
+	}
+
+	st := s.Stats()
+	if st.ShadowErrors != 1 || st.FieldDivergences[FieldError] != 1 {
+		t.Errorf("stats = %+v, want 1 shadow error", st)
+	}
+	if len(st.Samples) != 1 || st.Samples[0].ShadowErr == "" {
+		t.Errorf("samples = %+v, want shadow error recorded", st.Samples)
+	}
+
+	// 两边都拒绝 (非法输入) 不算分歧
+	failing := modelFunc(func(RiskInput) (RiskOutput, error) { return RiskOutput{}, errors.New("bad input") })
+	s = NewShadow(failing, failing, ShadowConfig{})
+	if _, err := s.Evaluate(1, RiskInput{}); err == nil {
+		t.Fatal("expected primary error")
+	}
+	if st := s.Stats(); st.Divergences != 0 || st.ShadowErrors != 0 {
+		t.Errorf("stats = %+v, want no divergence", st)
+	}
+}
+
+func TestShadow_SampleRingAndReset(t *testing.T) {
+	s := NewShadow(NewEngine(), scaledMMR{factor: 2}, ShadowConfig{MaxSamples: 3})
+	for user := int64(1); user <= 5; user++ {
+		s.Evaluate(user, shadowInput(1000))
+	}
+
+	st := s.Stats()
+	if st.Divergences != 5 || len(st.Samples) != 3 {
+		t.Fatalf("divergences = %d, samples = %d, want 5 / 3", st.Divergences, len(st.Samples))
+	}
+	for i, want := range []int64{3, 4, 5} {
+		if st.Samples[i].UserID != want {
+			t.Errorf("Samples[%d].UserID = %d, want %d", i, st.Samples[i].UserID, want)
+		}
+	}
+
+	s.Reset()
+	if st := s.Stats(); st.Evaluations != 0 || len(st.Samples) != 0 || st.MaxRatioDiff != 0 {
+		t.Errorf("after Reset stats = %+v", st)
+	}
+}
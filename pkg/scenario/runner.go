@@ -0,0 +1,450 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/liquidation"
+	"max.com/pkg/mtrade"
+	"max.com/pkg/risk"
+	"max.com/pkg/spot"
+)
+
+// ErrSettleTimeout 撮合事件在超时内没有处理完
+var ErrSettleTimeout = errors.New("scenario: engines did not settle in time")
+
+// =============================================================================
+// 结果
+// =============================================================================
+
+// Options 运行选项
+type Options struct {
+	// Logf 步骤日志 (演示模式用 log.Printf)，nil 不打印
+	Logf func(format string, args ...any)
+	// SettleTimeout 每步等待撮合和结算完成的超时，默认 2s
+	SettleTimeout time.Duration
+}
+
+// Liquidation 价格路径上触发的强平
+type Liquidation struct {
+	UserID    int64
+	Symbol    string
+	Price     float64
+	RiskRatio float64
+	Step      int // 第几步 (从 1 开始)
+}
+
+// Report 运行报告
+type Report struct {
+	Name         string
+	Steps        int // 实际执行的步骤数
+	Trades       int
+	Liquidations []Liquidation
+	Failures     []string // 步骤失败 + 断言失败
+	Duration     time.Duration
+}
+
+// Passed 是否全部通过
+func (r *Report) Passed() bool { return len(r.Failures) == 0 }
+
+func (r *Report) failf(format string, args ...any) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+// =============================================================================
+// Runner
+// =============================================================================
+
+type market struct {
+	engine    *mtrade.Engine
+	processor *spot.SpotProcessor
+}
+
+type runner struct {
+	sc   *Scenario
+	opts Options
+
+	assets  *asset.AccountEngine
+	markets map[string]*market
+	trades  atomic.Int64
+
+	orderIDs    map[string]int64 // ref -> 订单 ID
+	orderMarket map[int64]string // 订单 ID -> 市场
+	nextOrderID int64
+	eventSeq    int
+
+	risk       *risk.Engine
+	prices     map[string]float64
+	liquidated map[int64]Liquidation
+
+	report *Report
+}
+
+// Run 在一套全新的引擎上执行场景
+//
+// 步骤失败 (预期外的拒单、充值失败等) 会停止后续步骤；断言失败都记在 Report.Failures。
+// 返回的 error 只表示基础设施问题 (引擎启动失败、结算超时)
+func Run(ctx context.Context, sc *Scenario, opts Options) (*Report, error) {
+	if opts.SettleTimeout <= 0 {
+		opts.SettleTimeout = 2 * time.Second
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	start := time.Now()
+
+	r := &runner{
+		sc:          sc,
+		opts:        opts,
+		markets:     make(map[string]*market, len(sc.Markets)),
+		orderIDs:    make(map[string]int64),
+		orderMarket: make(map[int64]string),
+		risk:        risk.NewEngine(),
+		prices:      make(map[string]float64),
+		liquidated:  make(map[int64]Liquidation),
+		report:      &Report{Name: sc.Name},
+	}
+	stop, err := r.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	if err := r.setup(); err != nil {
+		r.report.failf("setup: %v", err)
+		return r.report, nil
+	}
+
+	for i, st := range sc.Steps {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := r.step(i+1, st); err != nil {
+			r.report.failf("step %d: %v", i+1, err)
+			break
+		}
+		if err := r.settle(); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		r.report.Steps++
+	}
+
+	r.report.Trades = int(r.trades.Load())
+	for _, l := range r.liquidated {
+		r.report.Liquidations = append(r.report.Liquidations, l)
+	}
+	sort.Slice(r.report.Liquidations, func(i, j int) bool {
+		return r.report.Liquidations[i].UserID < r.report.Liquidations[j].UserID
+	})
+	r.assert()
+	r.report.Duration = time.Since(start)
+	return r.report, nil
+}
+
+// start 启动资产引擎和每个市场的撮合引擎 + 现货处理器
+func (r *runner) start(ctx context.Context) (func(), error) {
+	cfg := asset.DefaultEngineConfig()
+	cfg.FeeAccountID = r.sc.FeeAccount
+	r.assets = asset.NewEngine(cfg)
+	if err := r.assets.Start(); err != nil {
+		return nil, err
+	}
+
+	stop := func() {
+		for _, m := range r.markets {
+			m.engine.Stop()
+		}
+		r.assets.Stop()
+	}
+	for _, symbol := range r.sc.Markets {
+		engine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig(symbol))
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		processor := spot.NewSpotProcessor(spot.ProcessorConfig{
+			AssetEngine:  r.assets,
+			MatchEngine:  engine,
+			MakerFeeRate: r.sc.Fees.Maker,
+			TakerFeeRate: r.sc.Fees.Taker,
+		})
+		// 在处理器之后注册：只用来计数
+		engine.OnEvent(func(e mtrade.Event) {
+			if e.Type == mtrade.EventTrade {
+				r.trades.Add(1)
+			}
+		})
+		engine.Start(ctx)
+		r.markets[symbol] = &market{engine: engine, processor: processor}
+	}
+	return stop, nil
+}
+
+// setup 初始充值，持仓用户的初始价格取开仓价
+func (r *runner) setup() error {
+	for _, u := range r.sc.Users {
+		assets := make([]string, 0, len(u.Deposits))
+		for a := range u.Deposits {
+			assets = append(assets, a)
+		}
+		sort.Strings(assets)
+		for _, a := range assets {
+			if err := r.balanceChange("DEPOSIT", u.ID, a, u.Deposits[a]); err != nil {
+				return fmt.Errorf("deposit user %d %s: %w", u.ID, a, err)
+			}
+		}
+	}
+	for _, p := range r.sc.Positions {
+		if _, ok := r.prices[p.Symbol]; !ok {
+			r.prices[p.Symbol] = p.Entry
+		}
+	}
+	return nil
+}
+
+func (r *runner) balanceChange(eventType string, userID int64, assetSym string, amount Amount) error {
+	r.eventSeq++
+	return r.assets.ApplyBalanceChange(&asset.BalanceChangeEvent{
+		EventType: eventType,
+		EventID:   fmt.Sprintf("scenario_%s_%d", r.sc.Name, r.eventSeq),
+		UserID:    userID,
+		Symbol:    assetSym,
+		Amount:    int64(amount),
+	})
+}
+
+// step 执行一个步骤
+func (r *runner) step(n int, st Step) error {
+	switch {
+	case st.Deposit != nil:
+		d := st.Deposit
+		r.opts.Logf("[%d] deposit user=%d %s %s", n, d.User, d.Amount, d.Asset)
+		return r.balanceChange("DEPOSIT", d.User, d.Asset, d.Amount)
+
+	case st.Withdraw != nil:
+		w := st.Withdraw
+		r.opts.Logf("[%d] withdraw user=%d %s %s", n, w.User, w.Amount, w.Asset)
+		return r.balanceChange("WITHDRAW", w.User, w.Asset, w.Amount)
+
+	case st.Order != nil:
+		return r.placeOrder(n, st.Order)
+
+	case st.Cancel != nil:
+		id, ok := r.orderIDs[st.Cancel.Ref]
+		if !ok {
+			return fmt.Errorf("cancel: order %q was never placed", st.Cancel.Ref)
+		}
+		r.opts.Logf("[%d] cancel %s (order %d)", n, st.Cancel.Ref, id)
+		if !r.markets[r.orderMarket[id]].processor.CancelOrder(id) {
+			return fmt.Errorf("cancel %q: engine queue full", st.Cancel.Ref)
+		}
+		return nil
+
+	case st.Price != nil:
+		for _, price := range st.Price.Path {
+			r.prices[st.Price.Symbol] = price
+			r.evaluateRisk(n, st.Price.Symbol)
+		}
+		r.opts.Logf("[%d] price %s -> %v", n, st.Price.Symbol, st.Price.Path)
+		return nil
+	}
+	return nil
+}
+
+func (r *runner) placeOrder(n int, o *OrderStep) error {
+	side, _ := parseSide(o.Side)
+	typ, _ := parseOrderType(o.Type)
+	r.nextOrderID++
+	order := &mtrade.Order{
+		ID:        r.nextOrderID,
+		UserID:    o.User,
+		Symbol:    o.Market,
+		Side:      side,
+		Type:      typ,
+		Price:     int64(o.Price),
+		Qty:       int64(o.Qty),
+		CreatedAt: time.Now().UnixNano(),
+	}
+	err := r.markets[o.Market].processor.PlaceOrder(order)
+	r.opts.Logf("[%d] order user=%d %s %s %s@%s err=%v", n, o.User, o.Market, o.Side, o.Qty, o.Price, err)
+
+	switch {
+	case o.ExpectReject && err == nil:
+		return fmt.Errorf("order %q: expected rejection, was accepted", o.Ref)
+	case !o.ExpectReject && err != nil:
+		return fmt.Errorf("order %q: %w", o.Ref, err)
+	case err != nil:
+		return nil
+	}
+	if o.Ref != "" {
+		r.orderIDs[o.Ref] = order.ID
+	}
+	r.orderMarket[order.ID] = o.Market
+	return nil
+}
+
+// evaluateRisk 在当前价格下重算持有 symbol 仓位的用户，记录第一次触发强平
+func (r *runner) evaluateRisk(step int, symbol string) {
+	byUser := make(map[int64][]PerpPosition)
+	for _, p := range r.sc.Positions {
+		byUser[p.User] = append(byUser[p.User], p)
+	}
+	for userID, positions := range byUser {
+		if _, done := r.liquidated[userID]; done {
+			continue
+		}
+		hit := false
+		input := risk.RiskInput{
+			Account: risk.Account{
+				Balance:        positions[0].Balance,
+				InitMarginRate: 0.1,
+			},
+			Prices: make(map[string]risk.PriceSnapshot),
+		}
+		for _, p := range positions {
+			hit = hit || p.Symbol == symbol
+			input.Positions = append(input.Positions, risk.Position{
+				Instrument:            risk.InstrumentPerp,
+				Symbol:                p.Symbol,
+				Qty:                   p.Qty,
+				EntryPrice:            p.Entry,
+				MaintenanceMarginRate: p.MMR,
+			})
+			price := r.prices[p.Symbol]
+			input.Prices[p.Symbol] = risk.PriceSnapshot{Price: price, MarkPrice: price}
+		}
+		if !hit {
+			continue
+		}
+		out, err := r.risk.ComputeRisk(input)
+		if err != nil {
+			r.report.failf("step %d: risk for user %d: %v", step, userID, err)
+			continue
+		}
+		if liquidation.CalculateRiskLevel(out.RiskRatio) == liquidation.RiskLevelLiquidate {
+			r.liquidated[userID] = Liquidation{
+				UserID: userID, Symbol: symbol, Price: r.prices[symbol], RiskRatio: out.RiskRatio, Step: step,
+			}
+			r.opts.Logf("[%d] LIQUIDATE user=%d %s price=%.2f risk=%.2f", step, userID, symbol, r.prices[symbol], out.RiskRatio)
+		}
+	}
+}
+
+// settle 等待所有撮合引擎的事件处理完
+//
+// 撮合和结算都是异步的：所有 handler 队列为空、处理数一致，
+// 且连续几次采样都不变，才认为这一步的成交、撤单已经结算完
+func (r *runner) settle() error {
+	const stableRounds = 3
+	deadline := time.Now().Add(r.opts.SettleTimeout)
+	var last []int64
+	stable := 0
+	for time.Now().Before(deadline) {
+		var sig []int64
+		idle := true
+		for _, symbol := range r.sc.Markets {
+			stats := r.markets[symbol].engine.HandlerStats()
+			for _, s := range stats {
+				if s.QueueLen != 0 || s.Processed != stats[0].Processed {
+					idle = false
+				}
+				sig = append(sig, s.Processed)
+			}
+		}
+		if idle && equalInts(sig, last) {
+			stable++
+			if stable >= stableRounds {
+				return nil
+			}
+		} else {
+			stable = 0
+		}
+		last = sig
+		time.Sleep(2 * time.Millisecond)
+	}
+	return ErrSettleTimeout
+}
+
+func equalInts(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// =============================================================================
+// 断言
+// =============================================================================
+
+func (r *runner) assert() {
+	exp := r.sc.Expect
+	rep := r.report
+
+	if exp.Trades != nil && rep.Trades != *exp.Trades {
+		rep.failf("trades: expected %d, got %d", *exp.Trades, rep.Trades)
+	}
+	for _, b := range exp.Balances {
+		var avail, locked int64
+		if snap := r.assets.GetSnapshot(b.User); snap != nil {
+			a := snap.Assets[b.Asset]
+			avail, locked = a.Available, a.Locked
+		}
+		if b.Available != nil && int64(*b.Available) != avail {
+			rep.failf("user %d %s available: expected %s, got %s", b.User, b.Asset, *b.Available, Amount(avail))
+		}
+		if b.Locked != nil && int64(*b.Locked) != locked {
+			rep.failf("user %d %s locked: expected %s, got %s", b.User, b.Asset, *b.Locked, Amount(locked))
+		}
+	}
+	for _, id := range exp.Liquidated {
+		if _, ok := r.liquidated[id]; !ok {
+			rep.failf("user %d: expected liquidation, stayed safe", id)
+		}
+	}
+	for _, id := range exp.Safe {
+		if l, ok := r.liquidated[id]; ok {
+			rep.failf("user %d: expected safe, liquidated at %s %.2f (step %d)", id, l.Symbol, l.Price, l.Step)
+		}
+	}
+}
+
+// =============================================================================
+// 解析辅助
+// =============================================================================
+
+func parseSide(s string) (mtrade.Side, error) {
+	switch strings.ToLower(s) {
+	case "buy":
+		return mtrade.SideBuy, nil
+	case "sell":
+		return mtrade.SideSell, nil
+	}
+	return 0, fmt.Errorf("invalid side %q", s)
+}
+
+func parseOrderType(s string) (mtrade.OrderType, error) {
+	switch strings.ToLower(s) {
+	case "", "limit":
+		return mtrade.OrderTypeLimit, nil
+	case "market":
+		return mtrade.OrderTypeMarket, nil
+	case "ioc":
+		return mtrade.OrderTypeIOC, nil
+	case "fok":
+		return mtrade.OrderTypeFOK, nil
+	case "post_only":
+		return mtrade.OrderTypePostOnly, nil
+	}
+	return 0, fmt.Errorf("invalid order type %q", s)
+}
@@ -0,0 +1,289 @@
+// Package scenario 脚本化场景：用 YAML 描述用户、充值、下单、价格路径和预期结果，
+// 在真实的撮合 / 资产 / 现货结算 / 风控引擎上跑一遍并断言最终状态。
+//
+// 同一份脚本既是演示 (cmd/simulation -scenario xxx.yaml)，也是回归测试
+// (scenario_test.go 会跑 cmd/simulation/scenarios 下的全部脚本)。
+//
+//	name: taker-buys-resting-ask
+//	fees: {maker: 10, taker: 20}        # 万分比
+//	markets: [BTC_USDT]
+//	users:
+//	  - {id: 1, deposits: {USDT: "60000"}}
+//	  - {id: 2, deposits: {BTC: "1"}}
+//	positions:                           # 永续仓位，只参与价格路径上的风险计算
+//	  - {user: 888, symbol: BTC_USDT, balance: 5000, qty: 10, entry: 50000, mmr: 0.005}
+//	steps:
+//	  - order: {ref: ask, user: 2, market: BTC_USDT, side: sell, price: "50000", qty: "1"}
+//	  - order: {user: 1, market: BTC_USDT, side: buy, price: "50000", qty: "1"}
+//	  - price: {symbol: BTC_USDT, path: [49000, 45000, 40000]}
+//	expect:
+//	  trades: 1
+//	  balances:
+//	    - {user: 1, asset: BTC, available: "0.998"}
+//	  liquidated: [888]
+//
+// 金额、价格写成十进制字符串，按 asset.Precision 换成定点数，避免浮点误差
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"max.com/pkg/asset"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrInvalidScenario = errors.New("scenario: invalid scenario")
+	ErrInvalidAmount   = errors.New("scenario: invalid decimal amount")
+)
+
+// =============================================================================
+// DSL
+// =============================================================================
+
+// Scenario 一个场景脚本
+type Scenario struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Fees        Fees           `yaml:"fees"`
+	FeeAccount  int64          `yaml:"fee_account"` // 手续费收入账户，0 = 不归集
+	Markets     []string       `yaml:"markets"`     // 如 BTC_USDT，每个市场一个撮合引擎
+	Users       []User         `yaml:"users"`
+	Positions   []PerpPosition `yaml:"positions"`
+	Steps       []Step         `yaml:"steps"`
+	Expect      Expect         `yaml:"expect"`
+}
+
+// Fees 费率 (万分比)
+type Fees struct {
+	Maker int64 `yaml:"maker"`
+	Taker int64 `yaml:"taker"`
+}
+
+// User 初始用户
+type User struct {
+	ID       int64             `yaml:"id"`
+	Deposits map[string]Amount `yaml:"deposits"` // 资产 -> 金额
+}
+
+// PerpPosition 永续仓位 (浮点，与 risk.Position 一致)
+type PerpPosition struct {
+	User    int64   `yaml:"user"`
+	Symbol  string  `yaml:"symbol"`
+	Balance float64 `yaml:"balance"` // 账户静态余额
+	Qty     float64 `yaml:"qty"`     // 正多负空
+	Entry   float64 `yaml:"entry"`
+	MMR     float64 `yaml:"mmr"`
+}
+
+// Step 一个步骤，只能填一种动作
+type Step struct {
+	Deposit  *BalanceStep `yaml:"deposit"`
+	Withdraw *BalanceStep `yaml:"withdraw"`
+	Order    *OrderStep   `yaml:"order"`
+	Cancel   *CancelStep  `yaml:"cancel"`
+	Price    *PriceStep   `yaml:"price"`
+}
+
+// BalanceStep 充值 / 提现
+type BalanceStep struct {
+	User   int64  `yaml:"user"`
+	Asset  string `yaml:"asset"`
+	Amount Amount `yaml:"amount"`
+}
+
+// OrderStep 现货下单
+type OrderStep struct {
+	Ref          string `yaml:"ref"` // 供 cancel 引用，可选
+	User         int64  `yaml:"user"`
+	Market       string `yaml:"market"` // 只有一个市场时可省略
+	Side         string `yaml:"side"`   // buy / sell
+	Type         string `yaml:"type"`   // limit (默认) / market / ioc / fok / post_only
+	Price        Amount `yaml:"price"`
+	Qty          Amount `yaml:"qty"`
+	ExpectReject bool   `yaml:"expect_reject"` // 预期下单被拒 (余额不足等)
+}
+
+// CancelStep 撤单
+type CancelStep struct {
+	Ref string `yaml:"ref"`
+}
+
+// PriceStep 价格路径：逐个价格点重算所有持仓用户的风险
+type PriceStep struct {
+	Symbol string    `yaml:"symbol"`
+	Path   []float64 `yaml:"path"`
+}
+
+// Expect 预期结果
+type Expect struct {
+	Trades     *int            `yaml:"trades"` // 全部市场成交笔数
+	Balances   []BalanceExpect `yaml:"balances"`
+	Liquidated []int64         `yaml:"liquidated"` // 价格路径上触发强平的用户
+	Safe       []int64         `yaml:"safe"`       // 全程未触发强平的用户
+}
+
+// BalanceExpect 余额断言 (未填的字段不检查)
+type BalanceExpect struct {
+	User      int64   `yaml:"user"`
+	Asset     string  `yaml:"asset"`
+	Available *Amount `yaml:"available"`
+	Locked    *Amount `yaml:"locked"`
+}
+
+// =============================================================================
+// 定点数
+// =============================================================================
+
+// Amount 定点金额 (asset.Precision)，YAML 中写十进制
+type Amount int64
+
+// UnmarshalYAML 解析 "1.5" / 1.5 / 100
+func (a *Amount) UnmarshalYAML(node *yaml.Node) error {
+	v, err := ParseAmount(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*a = v
+	return nil
+}
+
+// String 十进制表示
+func (a Amount) String() string {
+	v := int64(a)
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%08d", v%asset.Precision), "0")
+	if frac == "" {
+		return fmt.Sprintf("%s%d", sign, v/asset.Precision)
+	}
+	return fmt.Sprintf("%s%d.%s", sign, v/asset.Precision, frac)
+}
+
+// ParseAmount 十进制字符串转定点数，最多 8 位小数
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || len(fracPart) > 8 || len(intPart) > 10 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	var v int64
+	for _, c := range intPart + fracPart + strings.Repeat("0", 8-len(fracPart)) {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+		v = v*10 + int64(c-'0')
+	}
+	if neg {
+		v = -v
+	}
+	return Amount(v), nil
+}
+
+// =============================================================================
+// 加载与校验
+// =============================================================================
+
+// Load 从文件加载
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// Parse 解析 YAML (未知字段报错，防止拼错字段名导致断言被静默跳过)
+func Parse(data []byte) (*Scenario, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var sc Scenario
+	if err := dec.Decode(&sc); err != nil {
+		return nil, err
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Validate 静态校验
+func (sc *Scenario) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidScenario, fmt.Sprintf(format, args...))
+	}
+	if sc.Name == "" {
+		return invalid("missing name")
+	}
+	if len(sc.Markets) == 0 {
+		return invalid("no markets")
+	}
+	markets := make(map[string]bool, len(sc.Markets))
+	for _, m := range sc.Markets {
+		if _, _, ok := strings.Cut(m, "_"); !ok {
+			return invalid("market %q, want BASE_QUOTE", m)
+		}
+		markets[m] = true
+	}
+
+	refs := make(map[string]bool)
+	for i, st := range sc.Steps {
+		n := 0
+		for _, set := range []bool{st.Deposit != nil, st.Withdraw != nil, st.Order != nil, st.Cancel != nil, st.Price != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return invalid("step %d: exactly one action required, got %d", i+1, n)
+		}
+		switch {
+		case st.Order != nil:
+			o := st.Order
+			if o.Market == "" && len(sc.Markets) == 1 {
+				o.Market = sc.Markets[0]
+			}
+			if !markets[o.Market] {
+				return invalid("step %d: unknown market %q", i+1, o.Market)
+			}
+			if _, err := parseSide(o.Side); err != nil {
+				return invalid("step %d: %v", i+1, err)
+			}
+			if _, err := parseOrderType(o.Type); err != nil {
+				return invalid("step %d: %v", i+1, err)
+			}
+			if o.Ref != "" {
+				if refs[o.Ref] {
+					return invalid("step %d: duplicate ref %q", i+1, o.Ref)
+				}
+				refs[o.Ref] = true
+			}
+		case st.Cancel != nil:
+			if !refs[st.Cancel.Ref] {
+				return invalid("step %d: cancel unknown ref %q", i+1, st.Cancel.Ref)
+			}
+		case st.Price != nil:
+			if len(st.Price.Path) == 0 {
+				return invalid("step %d: empty price path", i+1)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// TestBundledScenarios cmd/simulation/scenarios 下的脚本同时是回归测试
+func TestBundledScenarios(t *testing.T) {
+	files, err := filepath.Glob("../../cmd/simulation/scenarios/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no scenarios found: %v", err)
+	}
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			sc, err := Load(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rep, err := Run(context.Background(), sc, Options{Logf: t.Logf})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range rep.Failures {
+				t.Error(f)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown field": "name: x\nmarkets: [BTC_USDT]\nexpect:\n  balance: []\n",
+		"two actions":   "name: x\nmarkets: [BTC_USDT]\nsteps:\n  - {deposit: {user: 1, asset: USDT, amount: 1}, cancel: {ref: a}}\n",
+		"unknown ref":   "name: x\nmarkets: [BTC_USDT]\nsteps:\n  - cancel: {ref: a}\n",
+		"bad amount":    "name: x\nmarkets: [BTC_USDT]\nusers:\n  - {id: 1, deposits: {USDT: \"1.000000001\"}}\n",
+	}
+	for name, doc := range cases {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Parse([]byte("name: x\nmarkets: [BTCUSDT]\n")); !errors.Is(err, ErrInvalidScenario) {
+		t.Errorf("expected ErrInvalidScenario, got %v", err)
+	}
+}
+
+func TestAmount(t *testing.T) {
+	for s, want := range map[string]Amount{"1": 100_000_000, "0.998": 99_800_000, "-2.5": -250_000_000, ".5": 50_000_000} {
+		got, err := ParseAmount(s)
+		if err != nil || got != want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	if s := Amount(99_800_000).String(); s != "0.998" {
+		t.Errorf("String: got %s", s)
+	}
+}
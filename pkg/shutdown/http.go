@@ -0,0 +1,57 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// =============================================================================
+// 管理接口 (仅内网，鉴权由网关负责)
+// =============================================================================
+
+// NewAdminHandler 创建维护模式管理接口
+//
+//	GET  /maintenance                     各组件维护状态 (Report)
+//	POST /maintenance/drain?timeout=30s   排空，未全部排空返回 503 (body 仍是 Report)
+//	POST /maintenance/resume              恢复消费
+func NewAdminHandler(o *Orchestrator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /maintenance", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, o.Status())
+	})
+	mux.HandleFunc("POST /maintenance/drain", func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if s := r.URL.Query().Get("timeout"); s != "" {
+			timeout, err := time.ParseDuration(s)
+			if err != nil || timeout <= 0 {
+				http.Error(w, "invalid timeout", http.StatusBadRequest)
+				return
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		report := o.Drain(ctx)
+		status := http.StatusOK
+		if !report.Drained {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+	mux.HandleFunc("POST /maintenance/resume", func(w http.ResponseWriter, r *http.Request) {
+		if err := o.Resume(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, o.Status())
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,233 @@
+// Package shutdown 停机编排：滚动发布时让消费者进入维护模式，排空结算尾巴
+//
+// 【问题】发布时直接停进程：撮合事件消费者 (order.OrderConsumer、fund.NatsDBWriter) 正在处理的
+// 事件被打断，客户端已收到未处理的 Core NATS 消息直接丢失；资产流水写入器 (fund.DBWriter)
+// 缓冲里没刷库的流水要等对账补
+//
+// 【做法】各消费者实现 Drainer (Pause / Resume)，按依赖顺序注册到 Orchestrator：
+//   - Drain: 按注册顺序逐个 Pause (上游先停，下游把上游最后产出的事件也消化掉)，
+//     每个组件处理完在途消息、停止拉取后才算 "已排空"，返回各组件的排空结果
+//   - Resume: 按注册的逆序恢复 (下游先就绪)，发布取消或发布完成后调用
+//   - Shutdown: Drain 之后逐个 Stop，进程收到 SIGTERM 时调用
+//
+// 管理接口见 http.go，发布脚本先 POST /maintenance/drain，确认 drained 后再停进程
+//
+// 【注意】某个组件超时未排空时继续暂停后面的组件 (停止拉取总是要做的)，结果里 Drained=false，
+// 发布脚本据此决定等待重试还是强行发布
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Drainer 可进入维护模式的组件
+//
+// Pause 停止拉取新消息并等在途消息处理完，ctx 到期仍未排空返回错误；
+// Resume 恢复消费。两者都应可重复调用
+type Drainer interface {
+	Pause(ctx context.Context) error
+	Resume() error
+}
+
+// Stopper 可停止的组件，Shutdown 时在排空之后调用
+type Stopper interface {
+	Stop() error
+}
+
+// 组件状态
+const (
+	StateRunning  = "RUNNING"
+	StateDraining = "DRAINING"
+	StateDrained  = "DRAINED"
+	StateFailed   = "FAILED" // 排空超时或出错，已停止拉取但可能仍有在途消息
+	StateStopped  = "STOPPED"
+)
+
+// Config 编排配置
+type Config struct {
+	DrainTimeout time.Duration // 整个 Drain 的超时，默认 30s (调用方 ctx 更早到期以 ctx 为准)
+}
+
+func (c Config) withDefaults() Config {
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// ComponentStatus 单个组件的维护状态
+type ComponentStatus struct {
+	Name      string        `json:"name"`
+	State     string        `json:"state"`
+	Error     string        `json:"error,omitempty"`
+	DrainTime time.Duration `json:"drain_time_ns,omitempty"` // 最近一次排空耗时
+	Since     time.Time     `json:"since"`                   // 进入当前状态的时间
+}
+
+// Report 维护状态汇总
+type Report struct {
+	Drained    bool              `json:"drained"` // 所有组件都已排空
+	Components []ComponentStatus `json:"components"`
+}
+
+type component struct {
+	name string
+	d    Drainer
+
+	status ComponentStatus
+}
+
+// Orchestrator 停机编排器
+type Orchestrator struct {
+	cfg Config
+
+	op         sync.Mutex // 串行化 Drain / Resume / Shutdown
+	mu         sync.Mutex // 保护 components 的状态
+	components []*component
+	now        func() time.Time
+}
+
+// New 创建编排器
+func New(cfg Config) *Orchestrator {
+	return &Orchestrator{cfg: cfg.withDefaults(), now: time.Now}
+}
+
+// SetClock 替换时钟，组件状态时间 (Since) 和排空耗时 (DrainTime) 按它记录；
+// 排空超时仍以 ctx 为准
+func (o *Orchestrator) SetClock(now func() time.Time) {
+	o.now = now
+}
+
+// Register 注册组件，注册顺序即排空顺序 (上游在前)
+func (o *Orchestrator) Register(name string, d Drainer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.components = append(o.components, &component{
+		name:   name,
+		d:      d,
+		status: ComponentStatus{Name: name, State: StateRunning, Since: o.now()},
+	})
+}
+
+// Drain 按注册顺序逐个暂停并等排空
+func (o *Orchestrator) Drain(ctx context.Context) Report {
+	o.op.Lock()
+	defer o.op.Unlock()
+	return o.drain(ctx)
+}
+
+func (o *Orchestrator) drain(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, o.cfg.DrainTimeout)
+	defer cancel()
+
+	for _, c := range o.snapshot() {
+		if o.state(c) == StateDrained || o.state(c) == StateStopped {
+			continue
+		}
+		o.setState(c, StateDraining, nil)
+		start := o.now()
+		err := c.d.Pause(ctx)
+		elapsed := o.now().Sub(start)
+
+		o.mu.Lock()
+		c.status.DrainTime = elapsed
+		o.mu.Unlock()
+		if err != nil {
+			o.setState(c, StateFailed, err)
+			log.Printf("[Shutdown] drain %s failed after %v: %v", c.name, elapsed, err)
+			continue
+		}
+		o.setState(c, StateDrained, nil)
+		log.Printf("[Shutdown] %s drained in %v", c.name, elapsed)
+	}
+	return o.Status()
+}
+
+// Resume 按注册的逆序恢复，返回恢复失败的组件 (保持原状态，可重试)
+func (o *Orchestrator) Resume() error {
+	o.op.Lock()
+	defer o.op.Unlock()
+
+	components := o.snapshot()
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		switch o.state(c) {
+		case StateRunning, StateStopped:
+			continue
+		}
+		if err := c.d.Resume(); err != nil {
+			errs = append(errs, fmt.Errorf("resume %s: %w", c.name, err))
+			continue
+		}
+		o.setState(c, StateRunning, nil)
+		log.Printf("[Shutdown] %s resumed", c.name)
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown 排空后按注册顺序停止实现了 Stopper 的组件 (未排空的也停，进程要退出了)
+func (o *Orchestrator) Shutdown(ctx context.Context) (Report, error) {
+	o.op.Lock()
+	defer o.op.Unlock()
+
+	report := o.drain(ctx)
+	var errs []error
+	for _, c := range o.snapshot() {
+		s, ok := c.d.(Stopper)
+		if !ok || o.state(c) == StateStopped {
+			continue
+		}
+		if err := s.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			continue
+		}
+		o.setState(c, StateStopped, nil)
+	}
+	if !report.Drained {
+		errs = append(errs, errors.New("not all components drained"))
+	}
+	return report, errors.Join(errs...)
+}
+
+// Status 当前各组件的维护状态
+func (o *Orchestrator) Status() Report {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	r := Report{Drained: len(o.components) > 0, Components: make([]ComponentStatus, 0, len(o.components))}
+	for _, c := range o.components {
+		r.Components = append(r.Components, c.status)
+		if c.status.State != StateDrained && c.status.State != StateStopped {
+			r.Drained = false
+		}
+	}
+	return r
+}
+
+func (o *Orchestrator) snapshot() []*component {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*component(nil), o.components...)
+}
+
+func (o *Orchestrator) state(c *component) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return c.status.State
+}
+
+func (o *Orchestrator) setState(c *component, state string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	c.status.State = state
+	c.status.Since = o.now()
+	c.status.Error = ""
+	if err != nil {
+		c.status.Error = err.Error()
+	}
+}
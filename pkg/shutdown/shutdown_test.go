@@ -0,0 +1,172 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// manualClock 由 fakeDrainer 在 Pause 里推进，排空耗时不依赖真实调度
+type manualClock struct{ t time.Time }
+
+func (c *manualClock) now() time.Time { return c.t }
+
+// fakeDrainer 记录调用顺序，block 非空时 Pause 等它关闭或 ctx 到期；
+// clock 非空时 Pause 把它推进 took，模拟排空花掉的时间
+type fakeDrainer struct {
+	name      string
+	calls     *[]string
+	block     chan struct{}
+	resumeErr error
+	stopped   bool
+	clock     *manualClock
+	took      time.Duration
+}
+
+func (f *fakeDrainer) Pause(ctx context.Context) error {
+	*f.calls = append(*f.calls, "pause "+f.name)
+	if f.clock != nil {
+		f.clock.t = f.clock.t.Add(f.took)
+	}
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (f *fakeDrainer) Resume() error {
+	*f.calls = append(*f.calls, "resume "+f.name)
+	return f.resumeErr
+}
+
+func (f *fakeDrainer) Stop() error {
+	f.stopped = true
+	return nil
+}
+
+func TestOrchestrator_DrainResume(t *testing.T) {
+	var calls []string
+	consumer := &fakeDrainer{name: "order-consumer", calls: &calls}
+	writer := &fakeDrainer{name: "db-writer", calls: &calls}
+	o := New(Config{})
+	o.Register("order-consumer", consumer)
+	o.Register("db-writer", writer)
+
+	report := o.Drain(context.Background())
+	if !report.Drained || len(report.Components) != 2 || report.Components[1].State != StateDrained {
+		t.Fatalf("drain: %+v", report)
+	}
+	// 已排空的不再重复 Pause
+	o.Drain(context.Background())
+
+	writer.resumeErr = errors.New("nats down")
+	if err := o.Resume(); err == nil {
+		t.Fatal("expected resume error")
+	}
+	if st := o.Status(); st.Components[0].State != StateRunning || st.Components[1].State != StateDrained {
+		t.Fatalf("partial resume: %+v", st)
+	}
+	writer.resumeErr = nil
+	if err := o.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 上游先停，下游先恢复
+	want := []string{"pause order-consumer", "pause db-writer", "resume db-writer", "resume order-consumer", "resume db-writer"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls:\n got %v\nwant %v", calls, want)
+	}
+}
+
+func TestOrchestrator_DrainTimeout(t *testing.T) {
+	var calls []string
+	clock := &manualClock{t: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	start := clock.t
+	stuck := &fakeDrainer{name: "stuck", calls: &calls, block: make(chan struct{}), clock: clock, took: 30 * time.Second}
+	tail := &fakeDrainer{name: "tail", calls: &calls, clock: clock, took: 2 * time.Second}
+	o := New(Config{DrainTimeout: 20 * time.Millisecond})
+	o.SetClock(clock.now)
+	o.Register("stuck", stuck)
+	o.Register("tail", tail)
+
+	report := o.Drain(context.Background())
+	if report.Drained || report.Components[0].State != StateFailed || report.Components[0].Error == "" {
+		t.Fatalf("stuck component: %+v", report)
+	}
+	if c := report.Components[0]; c.DrainTime != 30*time.Second || !c.Since.Equal(start.Add(30*time.Second)) {
+		t.Fatalf("stuck drain time / since: %+v", c)
+	}
+	// 后面的组件照样停止拉取
+	if c := report.Components[1]; c.State != StateDrained || c.DrainTime != 2*time.Second || !c.Since.Equal(start.Add(32*time.Second)) {
+		t.Fatalf("tail: %+v", c)
+	}
+
+	// 在途消息处理完后再 Drain 只重试失败的那个
+	close(stuck.block)
+	calls = nil
+	if report := o.Drain(context.Background()); !report.Drained {
+		t.Fatalf("retry: %+v", report)
+	}
+	if !slices.Equal(calls, []string{"pause stuck"}) {
+		t.Fatalf("retry calls: %v", calls)
+	}
+	// 重试记录的是这一次的耗时，已排空的组件状态时间不变
+	if st := o.Status(); st.Components[0].DrainTime != 30*time.Second || !st.Components[0].Since.Equal(start.Add(62*time.Second)) ||
+		!st.Components[1].Since.Equal(start.Add(32*time.Second)) {
+		t.Fatalf("after retry: %+v", st)
+	}
+
+	report, err := o.Shutdown(context.Background())
+	if err != nil || !stuck.stopped || !tail.stopped || report.Components[0].State != StateDrained {
+		t.Fatalf("shutdown: %+v %v", report, err)
+	}
+	if st := o.Status(); st.Components[1].State != StateStopped {
+		t.Fatalf("after shutdown: %+v", st)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	var calls []string
+	block := make(chan struct{})
+	o := New(Config{})
+	o.Register("writer", &fakeDrainer{name: "writer", calls: &calls, block: block})
+	h := NewAdminHandler(o)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/maintenance/drain?timeout=10ms", nil))
+	var report Report
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusServiceUnavailable || report.Drained {
+		t.Fatalf("drain timeout: %d %+v", rec.Code, report)
+	}
+
+	close(block)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/maintenance/drain", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("drain: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/maintenance/resume", nil))
+	report = Report{}
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || report.Components[0].State != StateRunning {
+		t.Fatalf("resume: %d %+v", rec.Code, report)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/maintenance/drain?timeout=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad timeout: %d", rec.Code)
+	}
+}
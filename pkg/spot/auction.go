@@ -0,0 +1,240 @@
+// 文件: pkg/spot/auction.go
+// 开盘集合竞价 - 新交易对上线时用一个统一价格撮合竞价期间的挂单
+//
+// 【问题】新币上线第一笔成交由谁先到决定：一笔挂得离谱的卖单被第一个买单吃掉，
+// 开盘价就是这笔离谱的价格，K 线第一根就是一根长针
+//
+// 【做法】AUCTION 状态下订单照常冻结资金，但不进撮合，停在处理器的竞价簿里 (可撤单)。
+// ListSymbol 时按下面的规则定开盘价，再把竞价簿一次性提交撮合：
+//  1. 成交量最大
+//  2. 同量取买卖不平衡 |买量 - 卖量| 最小
+//  3. 仍同量取离参考价 (SymbolSpec.RefPrice) 最近
+//  4. 仍相同取较低价
+//
+// 提交顺序保证全部按开盘价成交：能成交的卖单 (价格 <= 开盘价) 按价格优先、时间优先
+// 以开盘价挂单，再以开盘价提交能成交的买单，最后按原价提交不能成交的订单
+//
+// 【注意】
+//   - 能成交一侧没成交完的部分以开盘价挂在簿上 (比原限价更保守)
+//   - 竞价成交中买单是 taker、卖单是 maker，手续费按各自角色收
+//   - 冻结 / 解冻仍按原委托价计算 (OrderMeta.Price)，与普通限价单以更优价格成交时一样
+//   - 竞价簿和订单索引一样只在内存：竞价期间重启，冻结资金需要运营对账解冻
+//   - 竞价期间只收限价单 (LIMIT / GTC)，IOC / FOK / 市价没有意义，PostOnly 开盘时会吃单
+
+package spot
+
+import (
+	"cmp"
+	"slices"
+
+	"max.com/pkg/audit"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/mtrade"
+)
+
+var ErrAuctionOrderType = cexerr.New("SPOT_AUCTION_ORDER_TYPE", cexerr.CategoryInvalidArgument, "only limit orders are accepted during opening auction")
+
+// AuctionResult 集合竞价结果
+type AuctionResult struct {
+	Symbol    string
+	Price     int64 // 开盘价，0 表示没有可成交的价格
+	Volume    int64 // 按开盘价可成交的数量
+	Imbalance int64 // 开盘价上 买量 - 卖量
+	Orders    int   // 参与竞价的订单数
+}
+
+// auctionBook 一个交易对的竞价簿，按到达顺序
+type auctionBook struct {
+	orders []*mtrade.Order
+}
+
+// auctionOrderType 竞价期间可接受的订单类型
+func auctionOrderType(t mtrade.OrderType) bool {
+	return t == mtrade.OrderTypeLimit || t == mtrade.OrderTypeGTC
+}
+
+// openingPrice 按集合竞价规则计算开盘价
+func openingPrice(orders []*mtrade.Order, refPrice int64) (price, volume, imbalance int64) {
+	buys := make(map[int64]int64)
+	sells := make(map[int64]int64)
+	var prices []int64
+	for _, o := range orders {
+		if o.Side == mtrade.SideBuy {
+			buys[o.Price] += o.Qty
+		} else {
+			sells[o.Price] += o.Qty
+		}
+		prices = append(prices, o.Price)
+	}
+	slices.Sort(prices)
+	prices = slices.Compact(prices)
+
+	// demand[i]: 价格 >= prices[i] 的买量；supply[i]: 价格 <= prices[i] 的卖量
+	n := len(prices)
+	demand := make([]int64, n)
+	supply := make([]int64, n)
+	for i := n - 1; i >= 0; i-- {
+		demand[i] = buys[prices[i]]
+		if i+1 < n {
+			demand[i] += demand[i+1]
+		}
+	}
+	for i := range n {
+		supply[i] = sells[prices[i]]
+		if i > 0 {
+			supply[i] += supply[i-1]
+		}
+	}
+
+	better := func(i, best int) bool {
+		vi, vb := min(demand[i], supply[i]), min(demand[best], supply[best])
+		if vi != vb {
+			return vi > vb
+		}
+		ii, ib := absInt64(demand[i]-supply[i]), absInt64(demand[best]-supply[best])
+		if ii != ib {
+			return ii < ib
+		}
+		if refPrice > 0 {
+			return absInt64(prices[i]-refPrice) < absInt64(prices[best]-refPrice)
+		}
+		return false // 价格升序遍历，同等条件保留较低价
+	}
+	best := -1
+	for i := range n {
+		if best < 0 || better(i, best) {
+			best = i
+		}
+	}
+	if best < 0 || min(demand[best], supply[best]) == 0 {
+		return 0, 0, 0
+	}
+	return prices[best], min(demand[best], supply[best]), demand[best] - supply[best]
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// uncrossOrder 开盘提交顺序：能成交的卖单 → 能成交的买单 → 其余按到达顺序
+//
+// 能成交的订单改为以开盘价提交，返回的切片与 orders 共享订单指针
+func uncrossOrder(orders []*mtrade.Order, price int64) []*mtrade.Order {
+	if price == 0 {
+		return orders
+	}
+	var sells, buys, rest []*mtrade.Order
+	for _, o := range orders {
+		switch {
+		case o.Side == mtrade.SideSell && o.Price <= price:
+			sells = append(sells, o)
+		case o.Side == mtrade.SideBuy && o.Price >= price:
+			buys = append(buys, o)
+		default:
+			rest = append(rest, o)
+		}
+	}
+	// 稳定排序：同价保持到达顺序 (时间优先)
+	slices.SortStableFunc(sells, func(a, b *mtrade.Order) int { return cmp.Compare(a.Price, b.Price) })
+	slices.SortStableFunc(buys, func(a, b *mtrade.Order) int { return cmp.Compare(b.Price, a.Price) })
+	for _, o := range sells {
+		o.Price = price
+	}
+	for _, o := range buys {
+		o.Price = price
+	}
+	return slices.Concat(sells, buys, rest)
+}
+
+// =============================================================================
+// 处理器侧的竞价簿
+// =============================================================================
+
+// park 竞价中的订单停进竞价簿，返回 false 表示应直接提交撮合
+//
+// 已开盘的交易对不再停单 (规格缓存可能还是旧的 AUCTION)；上线后、开盘前到达的订单
+// 也停进竞价簿，保证竞价单先于它们进入撮合
+func (p *SpotProcessor) park(order *mtrade.Order, auction bool) bool {
+	p.auctionMu.Lock()
+	defer p.auctionMu.Unlock()
+	book := p.auctions[order.Symbol]
+	if book == nil {
+		if !auction || p.opened[order.Symbol] {
+			return false
+		}
+		book = &auctionBook{}
+		p.auctions[order.Symbol] = book
+	}
+	book.orders = append(book.orders, order)
+	return true
+}
+
+// cancelParked 撤销竞价簿里的订单并全额解冻，订单不在竞价簿里返回 false
+func (p *SpotProcessor) cancelParked(meta *OrderMeta) bool {
+	p.auctionMu.Lock()
+	book := p.auctions[meta.Symbol]
+	var found bool
+	if book != nil {
+		book.orders = slices.DeleteFunc(book.orders, func(o *mtrade.Order) bool {
+			if o.ID == meta.OrderID {
+				found = true
+				return true
+			}
+			return false
+		})
+	}
+	p.auctionMu.Unlock()
+	if !found {
+		return false
+	}
+	p.releaseOrder(meta, meta.Qty)
+	p.auditOrder(audit.ActionOrderCancel, meta)
+	return true
+}
+
+// IndicativeOpen 当前竞价簿的参考开盘结果 (行情展示用，不改变竞价簿)
+func (p *SpotProcessor) IndicativeOpen(symbol string, refPrice int64) AuctionResult {
+	p.auctionMu.Lock()
+	defer p.auctionMu.Unlock()
+	res := AuctionResult{Symbol: symbol}
+	if book := p.auctions[symbol]; book != nil {
+		res.Orders = len(book.orders)
+		res.Price, res.Volume, res.Imbalance = openingPrice(book.orders, refPrice)
+	}
+	return res
+}
+
+// OpenAuction 按集合竞价开盘：计算开盘价，竞价簿一次性提交撮合，之后的订单直接撮合
+//
+// 由 SymbolManager.ListSymbol 在交易对切到 TRADING 之后调用；提交失败 (撮合队列满)
+// 的订单全额解冻，不影响其他订单
+func (p *SpotProcessor) OpenAuction(symbol string, refPrice int64) AuctionResult {
+	p.auctionMu.Lock()
+	defer p.auctionMu.Unlock()
+	p.opened[symbol] = true
+	book := p.auctions[symbol]
+	delete(p.auctions, symbol)
+
+	res := AuctionResult{Symbol: symbol}
+	if book == nil {
+		return res
+	}
+	res.Orders = len(book.orders)
+	res.Price, res.Volume, res.Imbalance = openingPrice(book.orders, refPrice)
+
+	for _, o := range uncrossOrder(book.orders, res.Price) {
+		if mtrade.RouteSubmit(p.engines, o) {
+			continue
+		}
+		p.mu.RLock()
+		meta := p.orderIndex[o.ID]
+		p.mu.RUnlock()
+		if meta != nil {
+			p.releaseOrder(meta, meta.Qty)
+		}
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package spot
+
+import (
+	"testing"
+
+	"max.com/pkg/mtrade"
+)
+
+func auctionOrders(specs ...[3]int64) []*mtrade.Order {
+	var orders []*mtrade.Order
+	for i, s := range specs {
+		side := mtrade.SideBuy
+		if s[0] < 0 {
+			side = mtrade.SideSell
+		}
+		orders = append(orders, &mtrade.Order{ID: int64(i + 1), Side: side, Price: s[1], Qty: s[2]})
+	}
+	return orders
+}
+
+func TestOpeningPrice(t *testing.T) {
+	const buy, sell = 1, -1
+	tests := []struct {
+		name      string
+		orders    []*mtrade.Order
+		ref       int64
+		price     int64
+		volume    int64
+		imbalance int64
+	}{
+		{
+			name:   "max volume",
+			orders: auctionOrders([3]int64{buy, 10, 100}, [3]int64{buy, 9, 100}, [3]int64{sell, 8, 50}, [3]int64{sell, 9, 100}, [3]int64{sell, 11, 100}),
+			price:  9, volume: 150, imbalance: 50,
+		},
+		{
+			name:   "tie keeps lower price",
+			orders: auctionOrders([3]int64{buy, 10, 100}, [3]int64{sell, 9, 100}),
+			price:  9, volume: 100,
+		},
+		{
+			name:   "tie closest to reference",
+			orders: auctionOrders([3]int64{buy, 10, 100}, [3]int64{sell, 9, 100}),
+			ref:    12,
+			price:  10, volume: 100,
+		},
+		{
+			name:   "min imbalance before reference",
+			orders: auctionOrders([3]int64{buy, 10, 100}, [3]int64{buy, 9, 50}, [3]int64{sell, 9, 100}),
+			ref:    9,
+			price:  10, volume: 100,
+		},
+		{
+			name:   "no cross",
+			orders: auctionOrders([3]int64{buy, 8, 100}, [3]int64{sell, 9, 100}),
+		},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		price, volume, imbalance := openingPrice(tt.orders, tt.ref)
+		if price != tt.price || volume != tt.volume || imbalance != tt.imbalance {
+			t.Errorf("%s: got (%d, %d, %d), want (%d, %d, %d)", tt.name, price, volume, imbalance, tt.price, tt.volume, tt.imbalance)
+		}
+	}
+}
+
+func TestUncrossOrder(t *testing.T) {
+	const buy, sell = 1, -1
+	orders := auctionOrders(
+		[3]int64{buy, 8, 10},   // 1 不能成交
+		[3]int64{sell, 9, 10},  // 2
+		[3]int64{buy, 11, 10},  // 3
+		[3]int64{sell, 7, 10},  // 4
+		[3]int64{buy, 12, 10},  // 5
+		[3]int64{sell, 13, 10}, // 6 不能成交
+	)
+	got := uncrossOrder(orders, 10)
+
+	wantIDs := []int64{4, 2, 5, 3, 1, 6}
+	wantPrices := []int64{10, 10, 10, 10, 8, 13}
+	for i, o := range got {
+		if o.ID != wantIDs[i] || o.Price != wantPrices[i] {
+			t.Errorf("[%d] = order %d @ %d, want order %d @ %d", i, o.ID, o.Price, wantIDs[i], wantPrices[i])
+		}
+	}
+}
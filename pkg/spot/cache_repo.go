@@ -0,0 +1,183 @@
+// 文件: pkg/spot/cache_repo.go
+// 交易对规格 Redis 缓存层 (装饰器，与 futures.CachedContractRepository 同一套策略)
+//
+// 【缓存策略】
+// - 读: 先查 Redis，miss 则查 DB 并回填；并发回源 singleflight 合并
+// - 写: 先写 DB，成功后删除缓存 (Cache Aside)
+// - 不存在的 symbol 写短 TTL 负缓存，拼错的交易对不会每单都打到 DB
+//
+// 【注意】下单路径每单读一次规格；状态变更 (上线 / 下架) 删缓存后其他实例下一单即可见
+
+package spot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+var _ SymbolRepository = (*CachedSymbolRepository)(nil)
+
+const (
+	symbolCacheKey         = "spot:symbol:%s"
+	symbolCacheTradingList = "spot:symbol:trading"
+
+	symbolCacheTTL     = 24 * time.Hour
+	symbolListCacheTTL = 5 * time.Minute
+
+	symbolNegativeValue = "-"
+	symbolNegativeTTL   = 30 * time.Second
+)
+
+// CachedSymbolRepository Redis 缓存装饰器
+type CachedSymbolRepository struct {
+	repo  SymbolRepository
+	redis *redis.Client
+
+	loads singleflight.Group
+
+	// 本进程内每个 symbol 的失效代数：回源期间发生了写操作，回源结果不再回填
+	mu          sync.Mutex
+	generations map[string]uint64
+}
+
+// NewCachedSymbolRepository 创建带缓存的 Repository
+func NewCachedSymbolRepository(repo SymbolRepository, rds *redis.Client) *CachedSymbolRepository {
+	return &CachedSymbolRepository{repo: repo, redis: rds, generations: make(map[string]uint64)}
+}
+
+// GetBySymbol 根据 symbol 查询 (带缓存)
+func (r *CachedSymbolRepository) GetBySymbol(ctx context.Context, symbol string) (*SymbolSpec, error) {
+	if data, err := r.redis.Get(ctx, fmt.Sprintf(symbolCacheKey, symbol)).Bytes(); err == nil {
+		if string(data) == symbolNegativeValue {
+			return nil, ErrSymbolNotFound
+		}
+		var spec SymbolSpec
+		if json.Unmarshal(data, &spec) == nil {
+			return &spec, nil
+		}
+	}
+
+	// 回源用不带取消的 context：领头请求被取消不应该让排队的请求全部失败
+	ch := r.loads.DoChan("symbol:"+symbol, func() (any, error) {
+		return r.fetch(context.WithoutCancel(ctx), symbol)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		spec := *res.Val.(*SymbolSpec)
+		return &spec, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch 查底层并回填：存在写正常缓存，不存在写负缓存
+func (r *CachedSymbolRepository) fetch(ctx context.Context, symbol string) (*SymbolSpec, error) {
+	key := fmt.Sprintf(symbolCacheKey, symbol)
+	gen := r.generation(symbol)
+
+	spec, err := r.repo.GetBySymbol(ctx, symbol)
+	if errors.Is(err, ErrSymbolNotFound) {
+		if r.generation(symbol) == gen {
+			r.redis.Set(ctx, key, symbolNegativeValue, symbolNegativeTTL)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.generation(symbol) == gen {
+		r.setCache(ctx, key, spec, symbolCacheTTL)
+	}
+	return spec, nil
+}
+
+func (r *CachedSymbolRepository) generation(symbol string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generations[symbol]
+}
+
+// ListByStatus 按状态查询，只缓存交易中的列表
+func (r *CachedSymbolRepository) ListByStatus(ctx context.Context, status SymbolStatus) ([]*SymbolSpec, error) {
+	if status != StatusTrading {
+		return r.repo.ListByStatus(ctx, status)
+	}
+	if data, err := r.redis.Get(ctx, symbolCacheTradingList).Bytes(); err == nil {
+		var specs []*SymbolSpec
+		if json.Unmarshal(data, &specs) == nil {
+			return specs, nil
+		}
+	}
+	v, err, _ := r.loads.Do("list:trading", func() (any, error) {
+		specs, err := r.repo.ListByStatus(context.WithoutCancel(ctx), StatusTrading)
+		if err != nil {
+			return nil, err
+		}
+		r.setCache(context.Background(), symbolCacheTradingList, specs, symbolListCacheTTL)
+		return specs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*SymbolSpec), nil
+}
+
+// List 列出所有交易对 (不缓存，管理后台用)
+func (r *CachedSymbolRepository) List(ctx context.Context) ([]*SymbolSpec, error) {
+	return r.repo.List(ctx)
+}
+
+// Create 创建交易对，删除可能存在的负缓存
+func (r *CachedSymbolRepository) Create(ctx context.Context, spec *SymbolSpec) error {
+	if err := r.repo.Create(ctx, spec); err != nil {
+		return err
+	}
+	r.invalidate(ctx, spec.Symbol)
+	return nil
+}
+
+// Update 更新规格
+func (r *CachedSymbolRepository) Update(ctx context.Context, spec *SymbolSpec) error {
+	if err := r.repo.Update(ctx, spec); err != nil {
+		return err
+	}
+	r.invalidate(ctx, spec.Symbol)
+	return nil
+}
+
+// UpdateStatus 更新状态
+func (r *CachedSymbolRepository) UpdateStatus(ctx context.Context, symbol string, from, to SymbolStatus) error {
+	if err := r.repo.UpdateStatus(ctx, symbol, from, to); err != nil {
+		return err
+	}
+	r.invalidate(ctx, symbol)
+	return nil
+}
+
+// setCache 写缓存 (单个规格或列表)
+func (r *CachedSymbolRepository) setCache(ctx context.Context, key string, v any, ttl time.Duration) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	r.redis.Set(ctx, key, data, ttl)
+}
+
+// invalidate 删除单个缓存和列表缓存，进行中的回源结果作废
+func (r *CachedSymbolRepository) invalidate(ctx context.Context, symbol string) {
+	r.mu.Lock()
+	r.generations[symbol]++
+	r.mu.Unlock()
+
+	r.redis.Del(ctx, fmt.Sprintf(symbolCacheKey, symbol), symbolCacheTradingList)
+}
@@ -0,0 +1,43 @@
+// 文件: pkg/spot/feature_flags.go
+// 功能开关 - 撮合新订单类型按交易对 / 用户比例灰度，出问题一键关闭 (见 pkg/featureflag)
+//
+// 限价单、市价单是基础能力，不设开关
+
+package spot
+
+import (
+	"max.com/pkg/featureflag"
+	"max.com/pkg/mtrade"
+)
+
+var (
+	FlagOrderIOC      = featureflag.Define("spot.order_type.ioc", true)
+	FlagOrderFOK      = featureflag.Define("spot.order_type.fok", true)
+	FlagOrderPostOnly = featureflag.Define("spot.order_type.post_only", true)
+	FlagOrderGTC      = featureflag.Define("spot.order_type.gtc", true)
+
+	// FlagOrderMarketQuote 按金额下市价单 (不是独立的订单类型，见 quote_order.go)
+	FlagOrderMarketQuote = featureflag.Define("spot.order_type.market_quote", true)
+)
+
+// orderTypeFlags 订单类型 → 开关
+var orderTypeFlags = map[mtrade.OrderType]featureflag.Flag{
+	mtrade.OrderTypeIOC:      FlagOrderIOC,
+	mtrade.OrderTypeFOK:      FlagOrderFOK,
+	mtrade.OrderTypePostOnly: FlagOrderPostOnly,
+	mtrade.OrderTypeGTC:      FlagOrderGTC,
+}
+
+// SetFeatureFlags 设置功能开关，不设置时全部取默认值
+func (p *SpotProcessor) SetFeatureFlags(flags *featureflag.Flags) {
+	p.flags = flags
+}
+
+// checkOrderType 订单类型是否对该用户开放
+func (p *SpotProcessor) checkOrderType(order *mtrade.Order) error {
+	flag, ok := orderTypeFlags[order.Type]
+	if !ok {
+		return nil
+	}
+	return p.flags.Check(flag, order.Symbol, order.UserID)
+}
@@ -0,0 +1,125 @@
+// 文件: pkg/spot/fee.go
+// 现货手续费计算 (含 maker 返佣)
+//
+// 【规则】
+// - 手续费从收到的资产里扣：买方扣 base，卖方扣 quote
+// - MakerRate 可以为负 (返佣)，返佣按 taker 手续费的资产计价，
+//   并且逐笔不超过该笔 taker 手续费 (资产引擎会再校验一次，见 asset/fee.go)
+// - 配置了费率档位 (FeeTierResolver) 时 taker 按自己档位的 TakerRate、maker 按自己档位的 MakerRate，
+//   双方的费率和档位随成交事件、手续费流水一起发出，下游不用再自己推算
+
+package spot
+
+import (
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+	"max.com/pkg/mtrade"
+)
+
+// ErrInvalidFeeRate 费率配置不合法
+var ErrInvalidFeeRate = cexerr.New("SPOT_INVALID_FEE_RATE", cexerr.CategoryInvalidArgument, "invalid fee rate: taker fee must be >= 0 and maker rebate must not exceed taker fee")
+
+// FeeRatePrecision 费率精度 (万分比)
+const FeeRatePrecision = fixed.BpsScale
+
+// DefaultFeeTier 未指定档位的费率表记为的档位标识
+const DefaultFeeTier = "default"
+
+// FeeSchedule 费率表 (万分比)
+type FeeSchedule struct {
+	Tier      string // 档位标识，如 "VIP1"，为空记为 DefaultFeeTier
+	MakerRate int64  // 负数表示返佣，如 -2 = 返 0.02%
+	TakerRate int64
+}
+
+// TierName 档位标识
+func (f FeeSchedule) TierName() string {
+	if f.Tier == "" {
+		return DefaultFeeTier
+	}
+	return f.Tier
+}
+
+// FeeTierResolver 按用户查费率档位 (VIP 等级、做市商协议等)
+//
+// ok=false 表示该用户没有单独的档位，用处理器的默认费率
+type FeeTierResolver interface {
+	FeeSchedule(userID int64) (fees FeeSchedule, ok bool)
+}
+
+// Validate 检查费率：返佣率不能超过 taker 费率，否则平台每笔成交都在倒贴
+func (f FeeSchedule) Validate() error {
+	if f.TakerRate < 0 || f.TakerRate >= FeeRatePrecision || f.MakerRate >= FeeRatePrecision {
+		return ErrInvalidFeeRate
+	}
+	if f.MakerRate < -f.TakerRate {
+		return ErrInvalidFeeRate
+	}
+	return nil
+}
+
+// TradeFees 一笔成交双方的手续费，负数表示返佣 (对应 asset.FillEvent 的手续费字段)
+type TradeFees struct {
+	BuyerFee       int64
+	BuyerFeeAsset  string
+	SellerFee      int64
+	SellerFeeAsset string
+}
+
+// Compute 计算一笔成交的手续费
+//
+// 返佣与 taker 手续费同资产，并封顶为该笔 taker 手续费：
+// 费率合法时取整误差也可能让返佣略大于手续费，封顶保证逐笔不倒贴
+func (f FeeSchedule) Compute(takerSide mtrade.Side, price, qty int64, base, quote string) TradeFees {
+	quoteAmount := fixed.Mul(price, qty)
+	baseFee := func(rate int64) int64 { return fixed.Bps(qty, rate) }
+	quoteFee := func(rate int64) int64 { return fixed.Bps(quoteAmount, rate) }
+
+	var fees TradeFees
+	if takerSide == mtrade.SideBuy {
+		// taker 买方扣 base
+		takerFee := baseFee(f.TakerRate)
+		fees.BuyerFee, fees.BuyerFeeAsset = takerFee, base
+		if f.MakerRate >= 0 {
+			fees.SellerFee, fees.SellerFeeAsset = quoteFee(f.MakerRate), quote
+		} else {
+			fees.SellerFee, fees.SellerFeeAsset = -min(baseFee(-f.MakerRate), takerFee), base
+		}
+	} else {
+		// taker 卖方扣 quote
+		takerFee := quoteFee(f.TakerRate)
+		fees.SellerFee, fees.SellerFeeAsset = takerFee, quote
+		if f.MakerRate >= 0 {
+			fees.BuyerFee, fees.BuyerFeeAsset = baseFee(f.MakerRate), base
+		} else {
+			fees.BuyerFee, fees.BuyerFeeAsset = -min(quoteFee(-f.MakerRate), takerFee), quote
+		}
+	}
+	return fees
+}
+
+// FeeCharge 一笔成交里一方实际收取的手续费
+type FeeCharge struct {
+	Fee   int64  // 负数表示返佣
+	Asset string // 手续费资产
+	Rate  int64  // 适用的费率 (万分比，返佣封顶时实际金额可能小于按费率算的)
+	Tier  string // 费率档位
+}
+
+// ComputeFees 按双方各自的费率档位计算一笔成交的手续费
+//
+// 返回按买卖方划分的 TradeFees (交给资产引擎结算) 和按 taker / maker 划分的明细 (发给下游)
+func ComputeFees(taker, maker FeeSchedule, takerSide mtrade.Side, price, qty int64, base, quote string) (fees TradeFees, takerCharge, makerCharge FeeCharge) {
+	fees = FeeSchedule{MakerRate: maker.MakerRate, TakerRate: taker.TakerRate}.Compute(takerSide, price, qty, base, quote)
+
+	takerCharge = FeeCharge{Rate: taker.TakerRate, Tier: taker.TierName()}
+	makerCharge = FeeCharge{Rate: maker.MakerRate, Tier: maker.TierName()}
+	if takerSide == mtrade.SideBuy {
+		takerCharge.Fee, takerCharge.Asset = fees.BuyerFee, fees.BuyerFeeAsset
+		makerCharge.Fee, makerCharge.Asset = fees.SellerFee, fees.SellerFeeAsset
+	} else {
+		takerCharge.Fee, takerCharge.Asset = fees.SellerFee, fees.SellerFeeAsset
+		makerCharge.Fee, makerCharge.Asset = fees.BuyerFee, fees.BuyerFeeAsset
+	}
+	return fees, takerCharge, makerCharge
+}
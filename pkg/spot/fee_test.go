@@ -0,0 +1,96 @@
+package spot
+
+import (
+	"testing"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/mtrade"
+)
+
+func TestFeeSchedule_Validate(t *testing.T) {
+	cases := []struct {
+		fees FeeSchedule
+		ok   bool
+	}{
+		{FeeSchedule{MakerRate: 10, TakerRate: 20}, true},
+		{FeeSchedule{MakerRate: -20, TakerRate: 20}, true},
+		{FeeSchedule{MakerRate: -21, TakerRate: 20}, false}, // 返佣率 > taker 费率
+		{FeeSchedule{MakerRate: 0, TakerRate: -1}, false},
+	}
+	for _, c := range cases {
+		if err := c.fees.Validate(); (err == nil) != c.ok {
+			t.Errorf("%+v: expected ok=%v, got %v", c.fees, c.ok, err)
+		}
+	}
+}
+
+func TestFeeSchedule_Compute(t *testing.T) {
+	price := int64(50000 * asset.Precision)
+	qty := int64(1 * asset.Precision)
+
+	// 正费率：买方扣 BTC，卖方扣 USDT
+	fees := FeeSchedule{MakerRate: 10, TakerRate: 20}.Compute(mtrade.SideBuy, price, qty, "BTC", "USDT")
+	want := TradeFees{BuyerFee: 200000, BuyerFeeAsset: "BTC", SellerFee: 50 * asset.Precision, SellerFeeAsset: "USDT"}
+	if fees != want {
+		t.Errorf("expected %+v, got %+v", want, fees)
+	}
+
+	// taker 卖出、maker 买方返佣：返佣与 taker 手续费同为 USDT
+	fees = FeeSchedule{MakerRate: -5, TakerRate: 20}.Compute(mtrade.SideSell, price, qty, "BTC", "USDT")
+	want = TradeFees{BuyerFee: -25 * asset.Precision, BuyerFeeAsset: "USDT", SellerFee: 100 * asset.Precision, SellerFeeAsset: "USDT"}
+	if fees != want {
+		t.Errorf("expected %+v, got %+v", want, fees)
+	}
+
+	// 不合法的费率表也不会逐笔倒贴：返佣封顶为 taker 手续费
+	fees = FeeSchedule{MakerRate: -30, TakerRate: 20}.Compute(mtrade.SideBuy, price, qty, "BTC", "USDT")
+	if fees.SellerFee != -fees.BuyerFee || fees.SellerFeeAsset != "BTC" {
+		t.Errorf("expected rebate capped at taker fee, got %+v", fees)
+	}
+}
+
+// TestFeeSchedule_FractionalPrice 价格带小数时成交额不丢精度 (先除后乘会把 0.5 USDT 的价格算成 0)
+func TestFeeSchedule_FractionalPrice(t *testing.T) {
+	price := int64(asset.Precision / 2) // 0.5 USDT
+	qty := int64(10_000 * asset.Precision)
+
+	fees := FeeSchedule{MakerRate: 10, TakerRate: 20}.Compute(mtrade.SideSell, price, qty, "DOGE", "USDT")
+	if want := int64(10 * asset.Precision); fees.SellerFee != want { // 5000 USDT × 0.2%
+		t.Errorf("seller fee = %d, want %d", fees.SellerFee, want)
+	}
+	if want := int64(10 * asset.Precision); fees.BuyerFee != want { // 10000 DOGE × 0.1%
+		t.Errorf("buyer fee = %d, want %d", fees.BuyerFee, want)
+	}
+	if got := orderNotional(price, qty); got != 5_000*asset.Precision {
+		t.Errorf("notional = %d", got)
+	}
+}
+
+func TestComputeFees_Tiers(t *testing.T) {
+	price := int64(50000 * asset.Precision)
+	qty := int64(1 * asset.Precision)
+	taker := FeeSchedule{MakerRate: 10, TakerRate: 20}
+	maker := FeeSchedule{Tier: "VIP3", MakerRate: -2, TakerRate: 8}
+
+	// taker 卖出：taker 按默认档位 0.2% 扣 USDT，maker 买方按 VIP3 返 0.02% USDT
+	fees, takerCharge, makerCharge := ComputeFees(taker, maker, mtrade.SideSell, price, qty, "BTC", "USDT")
+	want := TradeFees{BuyerFee: -10 * asset.Precision, BuyerFeeAsset: "USDT", SellerFee: 100 * asset.Precision, SellerFeeAsset: "USDT"}
+	if fees != want {
+		t.Errorf("expected %+v, got %+v", want, fees)
+	}
+	if want := (FeeCharge{Fee: 100 * asset.Precision, Asset: "USDT", Rate: 20, Tier: DefaultFeeTier}); takerCharge != want {
+		t.Errorf("taker: expected %+v, got %+v", want, takerCharge)
+	}
+	if want := (FeeCharge{Fee: -10 * asset.Precision, Asset: "USDT", Rate: -2, Tier: "VIP3"}); makerCharge != want {
+		t.Errorf("maker: expected %+v, got %+v", want, makerCharge)
+	}
+
+	// taker 买入：taker 扣 BTC，maker 卖方收 USDT
+	_, takerCharge, makerCharge = ComputeFees(maker, taker, mtrade.SideBuy, price, qty, "BTC", "USDT")
+	if takerCharge.Asset != "BTC" || takerCharge.Rate != 8 || takerCharge.Tier != "VIP3" {
+		t.Errorf("taker: %+v", takerCharge)
+	}
+	if makerCharge.Asset != "USDT" || makerCharge.Fee != 50*asset.Precision || makerCharge.Tier != DefaultFeeTier {
+		t.Errorf("maker: %+v", makerCharge)
+	}
+}
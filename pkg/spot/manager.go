@@ -0,0 +1,150 @@
+// 文件: pkg/spot/manager.go
+// 交易对管理器 - 创建 / 竞价 / 上线 / 下架 (生命周期见 symbol.go)
+
+package spot
+
+import (
+	"context"
+	"time"
+)
+
+// AuctionOpener 开盘时撮合竞价簿 (*SpotProcessor 实现)
+type AuctionOpener interface {
+	OpenAuction(symbol string, refPrice int64) AuctionResult
+}
+
+var _ AuctionOpener = (*SpotProcessor)(nil)
+
+// SymbolManager 交易对管理器
+//
+// 【设计】只依赖 SymbolRepository 接口，可传入 MySQLSymbolRepository 或 CachedSymbolRepository
+type SymbolManager struct {
+	repo    SymbolRepository
+	auction AuctionOpener // 可选：处理器创建时注入 (见 ProcessorConfig.Symbols)
+}
+
+// NewSymbolManager 创建交易对管理器
+func NewSymbolManager(repo SymbolRepository) *SymbolManager {
+	return &SymbolManager{repo: repo}
+}
+
+// SetAuction 设置开盘撮合竞价簿的处理器，未设置时竞价上线不撮合 (竞价簿为空)
+func (m *SymbolManager) SetAuction(opener AuctionOpener) {
+	m.auction = opener
+}
+
+// CreateSymbolRequest 创建交易对请求
+type CreateSymbolRequest struct {
+	BaseAsset  string
+	QuoteAsset string
+
+	TickSize int64 // 0 不限制
+	LotSize  int64 // 0 不限制
+	MinQty   int64 // 0 不限制
+	MaxQty   int64 // 0 不限制
+
+	RefPrice int64 // 集合竞价参考价 (可选)
+}
+
+// CreateSymbol 创建交易对 (PENDING)，symbol 为 BASE_QUOTE
+func (m *SymbolManager) CreateSymbol(ctx context.Context, req *CreateSymbolRequest) (*SymbolSpec, error) {
+	now := time.Now().UnixMilli()
+	spec := &SymbolSpec{
+		Symbol:     req.BaseAsset + "_" + req.QuoteAsset,
+		BaseAsset:  req.BaseAsset,
+		QuoteAsset: req.QuoteAsset,
+		TickSize:   req.TickSize,
+		LotSize:    req.LotSize,
+		MinQty:     req.MinQty,
+		MaxQty:     req.MaxQty,
+		RefPrice:   req.RefPrice,
+		Status:     StatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	if err := m.repo.Create(ctx, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// =============================================================================
+// 查询
+// =============================================================================
+
+// GetSymbol 获取交易对规格
+func (m *SymbolManager) GetSymbol(ctx context.Context, symbol string) (*SymbolSpec, error) {
+	return m.repo.GetBySymbol(ctx, symbol)
+}
+
+// GetTradingSymbols 获取所有交易中的交易对
+func (m *SymbolManager) GetTradingSymbols(ctx context.Context) ([]*SymbolSpec, error) {
+	return m.repo.ListByStatus(ctx, StatusTrading)
+}
+
+// GetAllSymbols 获取所有未下架的交易对
+func (m *SymbolManager) GetAllSymbols(ctx context.Context) ([]*SymbolSpec, error) {
+	return m.repo.List(ctx)
+}
+
+// =============================================================================
+// 生命周期
+// =============================================================================
+
+// StartAuction 开始开盘集合竞价 (PENDING -> AUCTION)：之后的限价单只冻结不撮合
+func (m *SymbolManager) StartAuction(ctx context.Context, symbol string) error {
+	return m.transition(ctx, symbol, StatusPending, StatusAuction)
+}
+
+// ListSymbol 上线交易对 (PENDING / AUCTION -> TRADING)
+//
+// 竞价中的交易对先切到 TRADING 再撮合竞价簿：切换之后、撮合之前到达的订单同样停进竞价簿，
+// 排在竞价单之后进入撮合。开盘价写回规格失败时返回错误，但竞价单已经提交，不会回滚
+func (m *SymbolManager) ListSymbol(ctx context.Context, symbol string) (AuctionResult, error) {
+	res := AuctionResult{Symbol: symbol}
+	spec, err := m.repo.GetBySymbol(ctx, symbol)
+	if err != nil {
+		return res, err
+	}
+	switch spec.Status {
+	case StatusPending:
+		return res, m.repo.UpdateStatus(ctx, symbol, StatusPending, StatusTrading)
+	case StatusAuction:
+	default:
+		return res, ErrSymbolStatus.Wrapf("cannot list %s symbol %s", spec.Status, symbol)
+	}
+
+	if err := m.repo.UpdateStatus(ctx, symbol, StatusAuction, StatusTrading); err != nil {
+		return res, err
+	}
+	if m.auction == nil {
+		return res, nil
+	}
+	res = m.auction.OpenAuction(symbol, spec.RefPrice)
+	if res.Price == 0 {
+		return res, nil
+	}
+	spec.Status = StatusTrading // Update 按非零字段更新，不能把状态写回 AUCTION
+	spec.OpeningPrice = res.Price
+	return res, m.repo.Update(ctx, spec)
+}
+
+// DelistSymbol 下架交易对 (TRADING -> DELISTED)：之后新单拒绝，存量挂单由运营撤单
+func (m *SymbolManager) DelistSymbol(ctx context.Context, symbol string) error {
+	return m.transition(ctx, symbol, StatusTrading, StatusDelisted)
+}
+
+// transition 状态切换，当前状态不是 from 返回 ErrSymbolStatus
+func (m *SymbolManager) transition(ctx context.Context, symbol string, from, to SymbolStatus) error {
+	spec, err := m.repo.GetBySymbol(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	if spec.Status != from {
+		return ErrSymbolStatus.Wrapf("%s: %s -> %s", symbol, spec.Status, to)
+	}
+	return m.repo.UpdateStatus(ctx, symbol, from, to)
+}
@@ -0,0 +1,218 @@
+package spot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/mtrade"
+)
+
+// memSymbolRepo 内存 SymbolRepository
+type memSymbolRepo struct {
+	mu    sync.Mutex
+	specs map[string]*SymbolSpec
+}
+
+func newMemSymbolRepo() *memSymbolRepo {
+	return &memSymbolRepo{specs: make(map[string]*SymbolSpec)}
+}
+
+func (r *memSymbolRepo) Create(ctx context.Context, spec *SymbolSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.specs[spec.Symbol]; ok {
+		return ErrSymbolExists
+	}
+	cp := *spec
+	r.specs[spec.Symbol] = &cp
+	return nil
+}
+
+func (r *memSymbolRepo) GetBySymbol(ctx context.Context, symbol string) (*SymbolSpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[symbol]
+	if !ok {
+		return nil, ErrSymbolNotFound
+	}
+	cp := *spec
+	return &cp, nil
+}
+
+func (r *memSymbolRepo) Update(ctx context.Context, spec *SymbolSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.specs[spec.Symbol]; !ok {
+		return ErrSymbolNotFound
+	}
+	cp := *spec
+	r.specs[spec.Symbol] = &cp
+	return nil
+}
+
+func (r *memSymbolRepo) UpdateStatus(ctx context.Context, symbol string, from, to SymbolStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[symbol]
+	if !ok || spec.Status != from {
+		return ErrSymbolNotFound
+	}
+	spec.Status = to
+	return nil
+}
+
+func (r *memSymbolRepo) List(ctx context.Context) ([]*SymbolSpec, error) {
+	return r.ListByStatus(ctx, StatusTrading)
+}
+
+func (r *memSymbolRepo) ListByStatus(ctx context.Context, status SymbolStatus) ([]*SymbolSpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*SymbolSpec
+	for _, spec := range r.specs {
+		if spec.Status == status {
+			cp := *spec
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func TestSymbolManager_CreateValidation(t *testing.T) {
+	m := NewSymbolManager(newMemSymbolRepo())
+	ctx := context.Background()
+
+	spec, err := m.CreateSymbol(ctx, &CreateSymbolRequest{BaseAsset: "BTC", QuoteAsset: "USDT", TickSize: asset.Precision})
+	if err != nil {
+		t.Fatalf("CreateSymbol: %v", err)
+	}
+	if spec.Symbol != "BTC_USDT" || spec.Status != StatusPending {
+		t.Errorf("spec = %s %s", spec.Symbol, spec.Status)
+	}
+	if _, err := m.CreateSymbol(ctx, &CreateSymbolRequest{BaseAsset: "BTC", QuoteAsset: "USDT"}); !errors.Is(err, ErrSymbolExists) {
+		t.Errorf("duplicate: err = %v", err)
+	}
+	for _, req := range []*CreateSymbolRequest{
+		{BaseAsset: "BTC"},
+		{BaseAsset: "A_B", QuoteAsset: "USDT"},
+		{BaseAsset: "ETH", QuoteAsset: "USDT", MinQty: 10, MaxQty: 5},
+	} {
+		if _, err := m.CreateSymbol(ctx, req); !errors.Is(err, ErrInvalidSymbolSpec) {
+			t.Errorf("%+v: err = %v, want ErrInvalidSymbolSpec", req, err)
+		}
+	}
+	if err := m.DelistSymbol(ctx, "BTC_USDT"); !errors.Is(err, ErrSymbolStatus) {
+		t.Errorf("delist pending: err = %v, want ErrSymbolStatus", err)
+	}
+}
+
+func TestSymbolManager_AuctionListing(t *testing.T) {
+	assetEngine := asset.NewEngine(asset.DefaultEngineConfig())
+	assetEngine.Start()
+	defer assetEngine.Stop()
+	matchEngine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig("BTC_USDT"))
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	matchEngine.Start(context.Background())
+	defer matchEngine.Stop()
+
+	ctx := context.Background()
+	symbols := NewSymbolManager(newMemSymbolRepo())
+	processor := NewSpotProcessor(ProcessorConfig{
+		AssetEngine:  assetEngine,
+		MatchEngine:  matchEngine,
+		MakerFeeRate: 10,
+		TakerFeeRate: 20,
+		Symbols:      symbols,
+	})
+	if _, err := symbols.CreateSymbol(ctx, &CreateSymbolRequest{
+		BaseAsset: "BTC", QuoteAsset: "USDT", TickSize: 1000 * asset.Precision, RefPrice: 50000 * asset.Precision,
+	}); err != nil {
+		t.Fatalf("CreateSymbol: %v", err)
+	}
+
+	depositFunds(t, assetEngine, 1, "BTC", 2*asset.Precision)
+	depositFunds(t, assetEngine, 2, "BTC", 2*asset.Precision)
+	depositFunds(t, assetEngine, 3, "USDT", 200000*asset.Precision)
+	depositFunds(t, assetEngine, 4, "USDT", 100000*asset.Precision)
+
+	limit := func(id, userID int64, side mtrade.Side, price int64, qty int64) *mtrade.Order {
+		return &mtrade.Order{ID: id, UserID: userID, Symbol: "BTC_USDT", Side: side, Type: mtrade.OrderTypeLimit, Price: price * asset.Precision, Qty: qty}
+	}
+
+	// 未上线、未知交易对都拒单
+	if err := processor.PlaceOrder(limit(1, 1, mtrade.SideSell, 49000, asset.Precision)); !errors.Is(err, ErrSymbolNotTrading) {
+		t.Fatalf("pending: err = %v, want ErrSymbolNotTrading", err)
+	}
+	unknown := limit(1, 1, mtrade.SideSell, 49000, asset.Precision)
+	unknown.Symbol = "BTC_USDC"
+	if err := processor.PlaceOrder(unknown); !errors.Is(err, ErrSymbolNotFound) {
+		t.Fatalf("unknown: err = %v, want ErrSymbolNotFound", err)
+	}
+
+	if err := symbols.StartAuction(ctx, "BTC_USDT"); err != nil {
+		t.Fatalf("StartAuction: %v", err)
+	}
+	ioc := limit(9, 3, mtrade.SideBuy, 52000, asset.Precision)
+	ioc.Type = mtrade.OrderTypeIOC
+	if err := processor.PlaceOrder(ioc); !errors.Is(err, ErrAuctionOrderType) {
+		t.Fatalf("ioc: err = %v, want ErrAuctionOrderType", err)
+	}
+	if err := processor.PlaceOrder(limit(9, 3, mtrade.SideBuy, 51500, asset.Precision)); !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("off-tick: err = %v, want ErrInvalidOrder", err)
+	}
+
+	for _, o := range []*mtrade.Order{
+		limit(1, 1, mtrade.SideSell, 49000, asset.Precision),
+		limit(2, 2, mtrade.SideSell, 51000, asset.Precision),
+		limit(3, 3, mtrade.SideBuy, 52000, 2*asset.Precision),
+		limit(4, 4, mtrade.SideBuy, 50000, asset.Precision),
+	} {
+		if err := processor.PlaceOrder(o); err != nil {
+			t.Fatalf("PlaceOrder %d: %v", o.ID, err)
+		}
+	}
+	// 竞价期间不撮合，可以撤单 (全额解冻)
+	if !processor.CancelOrder(4) {
+		t.Fatal("CancelOrder parked order failed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := assetEngine.GetSnapshot(4).Assets["USDT"]; got.Locked != 0 || got.Available != 100000*asset.Precision {
+		t.Errorf("canceled parked order: USDT = %+v", got)
+	}
+	if got := processor.IndicativeOpen("BTC_USDT", 50000*asset.Precision); got.Price != 51000*asset.Precision || got.Orders != 3 {
+		t.Errorf("IndicativeOpen = %+v", got)
+	}
+
+	// 49000 和 51000 的卖单都按开盘价 51000 成交 (同量同平衡，离参考价 50000 更近)
+	res, err := symbols.ListSymbol(ctx, "BTC_USDT")
+	if err != nil {
+		t.Fatalf("ListSymbol: %v", err)
+	}
+	if res.Price != 51000*asset.Precision || res.Volume != 2*asset.Precision || res.Orders != 3 {
+		t.Errorf("ListSymbol = %+v", res)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// 卖方 maker 手续费 0.1%：51000 - 51
+	if got := assetEngine.GetSnapshot(1).Assets["USDT"].Available; got != 50949*asset.Precision {
+		t.Errorf("seller 1 USDT = %d, want %d", got, 50949*asset.Precision)
+	}
+	spec, _ := symbols.GetSymbol(ctx, "BTC_USDT")
+	if spec.Status != StatusTrading || spec.OpeningPrice != 51000*asset.Precision {
+		t.Errorf("spec after listing: %s opening %d", spec.Status, spec.OpeningPrice)
+	}
+
+	// 下架后拒单
+	if err := symbols.DelistSymbol(ctx, "BTC_USDT"); err != nil {
+		t.Fatalf("DelistSymbol: %v", err)
+	}
+	if err := processor.PlaceOrder(limit(5, 3, mtrade.SideBuy, 50000, asset.Precision)); !errors.Is(err, ErrSymbolNotTrading) {
+		t.Errorf("delisted: err = %v, want ErrSymbolNotTrading", err)
+	}
+}
@@ -19,15 +19,28 @@
 package spot
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"max.com/pkg/account"
 	"max.com/pkg/asset"
+	"max.com/pkg/audit"
+	"max.com/pkg/calendar"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/emergency"
+	"max.com/pkg/epoch"
+	"max.com/pkg/featureflag"
+	"max.com/pkg/fixed"
 	"max.com/pkg/fund"
 	"max.com/pkg/mtrade"
+	"max.com/pkg/referral"
+	"max.com/pkg/risk/limits"
 )
 
 // =============================================================================
@@ -35,10 +48,13 @@ import (
 // =============================================================================
 
 var (
-	ErrInvalidSymbol    = errors.New("invalid symbol format, expected BASE_QUOTE")
-	ErrOrderNotFound    = errors.New("order not found")
-	ErrAssetReserveFail = errors.New("asset reserve failed")
-	ErrSubmitOrderFail  = errors.New("submit order to matching engine failed")
+	ErrInvalidSymbol    = cexerr.New("SPOT_INVALID_SYMBOL", cexerr.CategoryInvalidArgument, "invalid symbol format, expected BASE_QUOTE")
+	ErrOrderNotFound    = cexerr.New("SPOT_ORDER_NOT_FOUND", cexerr.CategoryNotFound, "order not found")
+	ErrAssetReserveFail = cexerr.New("SPOT_ASSET_RESERVE_FAILED", cexerr.CategoryInsufficientFunds, "asset reserve failed")
+	ErrSubmitOrderFail  = cexerr.NewRetryable("SPOT_SUBMIT_ORDER_FAILED", cexerr.CategoryUnavailable, "submit order to matching engine failed")
+
+	ErrDuplicateClientOrderID = cexerr.New("SPOT_DUPLICATE_CLIENT_ORDER_ID", cexerr.CategoryConflict, "duplicate client order id")
+	ErrInvalidClientOrderID   = cexerr.New("SPOT_INVALID_CLIENT_ORDER_ID", cexerr.CategoryInvalidArgument, "client order id too long")
 )
 
 // =============================================================================
@@ -59,6 +75,12 @@ type OrderMeta struct {
 	FeeReserve   int64  // 预估手续费冻结
 	Price        int64  // 订单价格
 	Qty          int64  // 订单数量
+	QuoteQty     int64  // 按金额下单的预算 (报价资产)，见 quote_order.go
+
+	// pendingTrades 按金额下单：受理时尚未结算的成交笔数，结算完删除元数据 (受 mu 保护)
+	pendingTrades int
+
+	ClientOrderID string // 客户端订单号 (可选)
 }
 
 // =============================================================================
@@ -73,43 +95,130 @@ type OrderMeta struct {
 // - 通过事件监听处理成交和撤单
 type SpotProcessor struct {
 	assetEngine *asset.AccountEngine
-	matchEngine *mtrade.Engine
+	engines     mtrade.EngineRouter // 交易对 → 撮合引擎 (见 mtrade/router.go)
 
 	// 订单索引: OrderID -> OrderMeta
 	// 用于在成交事件中查找用户信息
 	orderIndex map[int64]*OrderMeta
 	mu         sync.RWMutex
 
-	// 手续费率 (万分比)
-	makerFeeRate int64 // Maker 手续费率，如 10 表示 0.1%
-	takerFeeRate int64 // Taker 手续费率，如 20 表示 0.2%
+	// 手续费率 (万分比)，maker 可为负 (返佣)
+	fees     FeeSchedule
+	feeTiers FeeTierResolver // 按用户的费率档位 (可选)，没有档位的用户用 fees
+
+	// 成交事件发布 (可选)：带双方用户和手续费明细
+	trades TradePublisher
 
 	// Kafka 事件发布器 (可选)
-	publisher *fund.EventPublisher
+	publisher JournalPublisher
+
+	// 下单前风控 (可选)
+	// openNotional 记录每个用户每个交易对未成交挂单的价值，受 mu 保护
+	riskLimits   *limits.Service
+	openNotional map[exposureKey]int64
+
+	// 审计 (可选)
+	auditor audit.Recorder
+
+	// 账户状态 (可选)：未认证/封禁不能下单，受限账户只能卖出
+	accounts account.Provider
+
+	// 推荐返佣 (可选)
+	referral *referral.Engine
+
+	// 功能开关 (可选，见 feature_flags.go)
+	flags *featureflag.Flags
+
+	// 交易日历 (可选)：休市 / 维护中拒单
+	calendar *calendar.Service
+
+	// 紧急控制 (可选)：全站停机拒单，被暂停的用户只能卖出
+	emergency *emergency.Control
+
+	// 交易对规格 (可选，见 symbol.go)：未上线 / 已下架拒单，竞价中的订单停进竞价簿
+	symbols   *SymbolManager
+	auctionMu sync.Mutex
+	auctions  map[string]*auctionBook // symbol → 开盘前的竞价簿 (见 auction.go)
+	opened    map[string]bool         // 已按竞价开盘的交易对
+
+	// 纪元栅栏：丢弃已被切换掉的撮合实例发来的事件
+	fence       epoch.Fence
+	staleEvents atomic.Uint64
+}
+
+// exposureKey 敞口统计维度
+type exposureKey struct {
+	userID int64
+	symbol string
 }
 
 // ProcessorConfig 处理器配置
 type ProcessorConfig struct {
-	AssetEngine  *asset.AccountEngine
-	MatchEngine  *mtrade.Engine
-	MakerFeeRate int64                // 万分比，如 10 = 0.1%
-	TakerFeeRate int64                // 万分比，如 20 = 0.2%
-	Publisher    *fund.EventPublisher // 可选，不为 nil 则发送 Kafka 事件
+	AssetEngine   *asset.AccountEngine
+	MatchEngine   *mtrade.Engine
+	MakerFeeRate  int64              // 万分比，如 10 = 0.1%，负数为返佣 (需配置资产引擎 FeeAccountID)
+	TakerFeeRate  int64              // 万分比，如 20 = 0.2%
+	FeeTier       string             // 默认费率的档位标识，为空记为 DefaultFeeTier
+	FeeTiers      FeeTierResolver    // 可选，不为 nil 则按用户档位收费，查不到的用户用默认费率
+	Publisher     JournalPublisher   // 可选，不为 nil 则发送流水事件 (fund.EventPublisher / eventlog.Log)
+	Trades        TradePublisher     // 可选，不为 nil 则发送带手续费明细的成交事件 (fund.NatsEventPublisher)
+	RiskLimits    *limits.Service    // 可选，不为 nil 则下单前做风控检查
+	Auditor       audit.Recorder     // 可选，不为 nil 则记录下单/撤单审计
+	AccountStatus account.Provider   // 可选，不为 nil 则下单前检查账户状态 (KYC/封禁)
+	Referral      *referral.Engine   // 可选，不为 nil 则成交手续费计推荐返佣
+	Calendar      *calendar.Service  // 可选，不为 nil 则休市 / 维护中拒单 (引擎按同一日历暂停)
+	Symbols       *SymbolManager     // 可选，不为 nil 则只接受已上线 / 竞价中的交易对，并注入为开盘竞价处理器
+	Emergency     *emergency.Control // 可选，不为 nil 则全站停机时拒单，被暂停的用户只能卖出
+
+	// Engines 可选，多引擎部署时按交易对路由 (见 mtrade/router.go)，设置后忽略 MatchEngine
+	Engines mtrade.EngineRouter
 }
 
+// JournalPublisher 流水发布
+type JournalPublisher interface {
+	PublishJournal(event *fund.JournalEvent) error
+}
+
+var _ JournalPublisher = (*fund.EventPublisher)(nil)
+
+// TradePublisher 成交事件发布
+type TradePublisher interface {
+	PublishTradeEvent(event *fund.TradeEvent) error
+}
+
+var _ TradePublisher = (*fund.NatsEventPublisher)(nil)
+
 // NewSpotProcessor 创建现货交易处理器
 func NewSpotProcessor(cfg ProcessorConfig) *SpotProcessor {
+	engines := cfg.Engines
+	if engines == nil {
+		engines = mtrade.SingleEngine(cfg.MatchEngine)
+	}
 	p := &SpotProcessor{
 		assetEngine:  cfg.AssetEngine,
-		matchEngine:  cfg.MatchEngine,
+		engines:      engines,
 		orderIndex:   make(map[int64]*OrderMeta),
-		makerFeeRate: cfg.MakerFeeRate,
-		takerFeeRate: cfg.TakerFeeRate,
+		fees:         FeeSchedule{Tier: cfg.FeeTier, MakerRate: cfg.MakerFeeRate, TakerRate: cfg.TakerFeeRate},
+		feeTiers:     cfg.FeeTiers,
+		trades:       cfg.Trades,
 		publisher:    cfg.Publisher,
+		riskLimits:   cfg.RiskLimits,
+		openNotional: make(map[exposureKey]int64),
+		auditor:      cfg.Auditor,
+		accounts:     cfg.AccountStatus,
+		referral:     cfg.Referral,
+		calendar:     cfg.Calendar,
+		symbols:      cfg.Symbols,
+		emergency:    cfg.Emergency,
+		auctions:     make(map[string]*auctionBook),
+		opened:       make(map[string]bool),
+	}
+	if cfg.Symbols != nil {
+		cfg.Symbols.SetAuction(p)
 	}
 
 	// 注册事件处理器
-	p.matchEngine.OnEvent(p.handleEvent)
+	p.engines.Subscribe(p.handleEvent, mtrade.HandlerOptions{Name: "spot-processor"})
 
 	return p
 }
@@ -129,14 +238,63 @@ func NewSpotProcessor(cfg ProcessorConfig) *SpotProcessor {
 // 参数:
 // - order: 订单 (需要已填充 UserID, Symbol, Side, Price, Qty)
 func (p *SpotProcessor) PlaceOrder(order *mtrade.Order) error {
-	// 1. 解析交易对
-	base, quote, err := parseSymbol(order.Symbol)
+	// 1. 解析交易对 (配置了交易对管理器时按规格检查状态与精度)
+	base, quote, auction, err := p.resolveSymbol(order)
 	if err != nil {
 		return err
 	}
+	if err := p.checkOrderType(order); err != nil {
+		return err
+	}
+	if err := p.checkQuoteOrder(order); err != nil {
+		return err
+	}
+	if p.calendar != nil {
+		if err := p.calendar.CheckOpen(order.Symbol); err != nil {
+			return err
+		}
+	}
+	if err := p.checkClientOrderID(order); err != nil {
+		return err
+	}
+
+	// 紧急控制与账户状态检查：现货没有仓位，卖出视为减仓
+	emergencyCheck := p.emergency.CheckOpen
+	if order.Side == mtrade.SideSell {
+		emergencyCheck = p.emergency.CheckClose
+	}
+	if err := emergencyCheck(order.UserID); err != nil {
+		return err
+	}
+	if p.accounts != nil {
+		check := account.CheckOpen
+		if order.Side == mtrade.SideSell {
+			check = account.CheckClose
+		}
+		if err := check(context.Background(), p.accounts, order.UserID); err != nil {
+			return err
+		}
+	}
+
+	// 风控检查 (冻结之前，拒单无需回滚)
+	notional := orderNotional(order.Price, order.Qty)
+	if order.QuoteQty > 0 {
+		notional = order.QuoteQty
+	}
+	if p.riskLimits != nil {
+		err := p.riskLimits.Check(context.Background(), p, limits.Request{
+			UserID:   order.UserID,
+			Symbol:   order.Symbol,
+			Notional: notional,
+		})
+		if err != nil {
+			return err
+		}
+	}
 
 	// 2. 计算冻结金额 (本金 + 预估手续费)
-	// 手续费按 Taker 费率预估 (最高费率)，实际可能更低
+	// 手续费按该用户档位的 Taker 费率预估 (最高费率)，实际可能更低
+	takerRate := p.feeSchedule(order.UserID).TakerRate
 	var reserveAsset string
 	var reserveAmt int64
 	var feeReserve int64
@@ -144,17 +302,20 @@ func (p *SpotProcessor) PlaceOrder(order *mtrade.Order) error {
 	if order.Side == mtrade.SideBuy {
 		// 买单: 冻结报价资产 (USDT)
 		reserveAsset = quote
-		// 本金 = 价格 * 数量 / 精度
-		principal := (order.Price / asset.Precision) * order.Qty
+		// 本金 = 价格 * 数量 / 精度，按金额下单直接冻结预算
+		principal := fixed.Mul(order.Price, order.Qty)
+		if order.QuoteQty > 0 {
+			principal = order.QuoteQty
+		}
 		// 预估手续费 (买方扣 BTC，但下单时锁 USDT，需要额外预留)
 		// 这里简化处理: 直接在 USDT 中多锁一点
-		feeReserve = principal * p.takerFeeRate / 10000
+		feeReserve = fixed.Bps(principal, takerRate)
 		reserveAmt = principal + feeReserve
 	} else {
 		// 卖单: 冻结基础资产 (BTC)
 		reserveAsset = base
 		// 预估手续费 (卖方扣 BTC)
-		feeReserve = order.Qty * p.takerFeeRate / 10000
+		feeReserve = fixed.Bps(order.Qty, takerRate)
 		reserveAmt = order.Qty + feeReserve
 	}
 
@@ -176,43 +337,132 @@ func (p *SpotProcessor) PlaceOrder(order *mtrade.Order) error {
 		FeeReserve:   feeReserve,              // 手续费部分
 		Price:        order.Price,
 		Qty:          order.Qty,
+		QuoteQty:     order.QuoteQty,
+
+		ClientOrderID: order.ClientOrderID,
 	}
 
 	p.mu.Lock()
 	p.orderIndex[order.ID] = meta
+	p.addOpenNotional(meta, notional)
 	p.mu.Unlock()
 
+	// 开盘集合竞价中：停进竞价簿，开盘时统一撮合 (见 auction.go)
+	if p.symbols != nil && p.park(order, auction) {
+		p.auditOrder(audit.ActionOrderPlace, meta)
+		return nil
+	}
+
 	// 5. 提交到撮合引擎
-	if !p.matchEngine.SubmitOrder(order) {
+	if !mtrade.RouteSubmit(p.engines, order) {
 		// 撮合队列满，解冻资产
 		p.assetEngine.Release(order.UserID, reserveAsset, reserveAmt, order.ID)
 		p.mu.Lock()
 		delete(p.orderIndex, order.ID)
+		p.addOpenNotional(meta, -notional)
 		p.mu.Unlock()
 		return ErrSubmitOrderFail
 	}
 
+	p.auditOrder(audit.ActionOrderPlace, meta)
 	return nil
 }
 
 // CancelOrder 取消订单
+// 订单不在索引里时发给每个撮合引擎
 func (p *SpotProcessor) CancelOrder(orderID int64) bool {
-	return p.matchEngine.CancelOrder(orderID)
+	p.mu.RLock()
+	meta := p.orderIndex[orderID]
+	p.mu.RUnlock()
+	symbol := ""
+	if meta != nil {
+		symbol = meta.Symbol
+		if p.symbols != nil && p.cancelParked(meta) {
+			return true
+		}
+	}
+	if !mtrade.RouteCancel(p.engines, symbol, orderID) {
+		return false
+	}
+	if meta != nil {
+		p.auditOrder(audit.ActionOrderCancel, meta)
+	}
+	return true
+}
+
+// CancelByClientOrderID 按客户端订单号撤单
+// 返回 false 表示订单不存在 (或已过去重窗口且不在簿上) 或撮合撤单队列已满
+func (p *SpotProcessor) CancelByClientOrderID(userID int64, clientOrderID string) bool {
+	orderID, ok := mtrade.RouteLookupClientOrder(p.engines, userID, clientOrderID)
+	if !ok {
+		return false
+	}
+	return p.CancelOrder(orderID)
+}
+
+// checkClientOrderID 冻结之前挡掉重复的 clientOid
+//
+// 只是快速路径：还在撮合队列里的同号订单查不到，最终由撮合引擎拒单 (拒单事件全额解冻)
+func (p *SpotProcessor) checkClientOrderID(order *mtrade.Order) error {
+	if order.ClientOrderID == "" {
+		return nil
+	}
+	if len(order.ClientOrderID) > mtrade.MaxClientOrderIDLen {
+		return ErrInvalidClientOrderID
+	}
+	if _, ok := mtrade.RouteLookupClientOrder(p.engines, order.UserID, order.ClientOrderID); ok {
+		return ErrDuplicateClientOrderID
+	}
+	return nil
+}
+
+// auditOrder 记录下单 / 撤单请求 (冻结金额的变动由资产引擎另行审计)
+func (p *SpotProcessor) auditOrder(action audit.Action, meta *OrderMeta) {
+	if p.auditor == nil {
+		return
+	}
+	ev := audit.Event{
+		ActorType: audit.ActorUser,
+		ActorID:   meta.UserID,
+		Action:    action,
+		Resource:  "order:" + strconv.FormatInt(meta.OrderID, 10),
+	}
+	if action == audit.ActionOrderPlace {
+		ev.After = meta
+	}
+	p.auditor.Record(ev)
 }
 
 // =============================================================================
 // 事件处理
 // =============================================================================
 
+// AdvanceEpoch 主备切换时由控制面调用，之后旧纪元的事件一律丢弃
+func (p *SpotProcessor) AdvanceEpoch(epoch uint64) {
+	p.fence.Advance(epoch)
+}
+
+// StaleEvents 因纪元过旧被丢弃的事件数
+func (p *SpotProcessor) StaleEvents() uint64 {
+	return p.staleEvents.Load()
+}
+
 // handleEvent 处理撮合引擎事件
 func (p *SpotProcessor) handleEvent(event mtrade.Event) {
+	// 旧纪元的成交/撤单/拒单都不能处理，否则会重复结算或重复解冻
+	if err := p.fence.Admit(event.Epoch); err != nil {
+		p.staleEvents.Add(1)
+		return
+	}
+
 	switch event.Type {
 	case mtrade.EventTrade:
 		p.handleTrade(event)
 	case mtrade.EventOrderCanceled:
 		p.handleCancel(event)
 	case mtrade.EventOrderAccepted:
-		// 订单接受，无需处理
+		// 订单接受，只有按金额下单的订单需要解冻未用完的预算
+		p.handleQuoteAccepted(event)
 	case mtrade.EventOrderRejected:
 		p.handleReject(event)
 	}
@@ -239,44 +489,33 @@ func (p *SpotProcessor) handleTrade(event mtrade.Event) {
 		// 订单不存在，可能是恢复场景
 		return
 	}
+	if takerMeta.QuoteQty > 0 {
+		defer p.settledQuoteTrade(takerMeta)
+	}
+
+	// 成交部分不再计入挂单敞口 (按各自的委托价)
+	p.mu.Lock()
+	p.addOpenNotional(takerMeta, -orderNotional(takerMeta.Price, trade.Qty))
+	p.addOpenNotional(makerMeta, -orderNotional(makerMeta.Price, trade.Qty))
+	p.mu.Unlock()
 
 	// 确定买卖方
 	var buyerID, sellerID int64
-	var buyerMeta, sellerMeta *OrderMeta
 
 	if trade.TakerSide == mtrade.SideBuy {
 		buyerID = takerMeta.UserID
 		sellerID = makerMeta.UserID
-		buyerMeta = takerMeta
-		sellerMeta = makerMeta
 	} else {
 		buyerID = makerMeta.UserID
 		sellerID = takerMeta.UserID
-		buyerMeta = makerMeta
-		sellerMeta = takerMeta
 	}
 
-	// 计算手续费
-	// Taker 手续费率高于 Maker
-	var buyerFee, sellerFee int64
-	var buyerFeeAsset, sellerFeeAsset string
-
-	if trade.TakerSide == mtrade.SideBuy {
-		// Taker 是买方
-		buyerFee = trade.Qty * p.takerFeeRate / 10000
-		buyerFeeAsset = buyerMeta.BaseAsset // 买方手续费用 BTC 扣
-		sellerFee = (trade.Price / asset.Precision) * trade.Qty * p.makerFeeRate / 10000
-		sellerFeeAsset = sellerMeta.QuoteAsset // 卖方手续费用 USDT 扣
-	} else {
-		// Taker 是卖方
-		buyerFee = trade.Qty * p.makerFeeRate / 10000
-		buyerFeeAsset = buyerMeta.BaseAsset
-		sellerFee = (trade.Price / asset.Precision) * trade.Qty * p.takerFeeRate / 10000
-		sellerFeeAsset = sellerMeta.QuoteAsset
-	}
+	// 计算手续费 (买方扣 BTC，卖方扣 USDT；maker 费率为负时返佣)，双方各按自己的档位
+	fees, takerCharge, makerCharge := ComputeFees(p.feeSchedule(takerMeta.UserID), p.feeSchedule(makerMeta.UserID),
+		trade.TakerSide, trade.Price, trade.Qty, takerMeta.BaseAsset, takerMeta.QuoteAsset)
 
 	// 调用资产引擎结算
-	p.assetEngine.ApplyFill(&asset.FillEvent{
+	err := p.assetEngine.ApplyFill(&asset.FillEvent{
 		TradeID:        trade.ID,
 		BuyerID:        buyerID,
 		SellerID:       sellerID,
@@ -284,15 +523,53 @@ func (p *SpotProcessor) handleTrade(event mtrade.Event) {
 		QuoteAsset:     takerMeta.QuoteAsset,
 		Price:          trade.Price,
 		Quantity:       trade.Qty,
-		BuyerFee:       buyerFee,
-		BuyerFeeAsset:  buyerFeeAsset,
-		SellerFee:      sellerFee,
-		SellerFeeAsset: sellerFeeAsset,
+		BuyerFee:       fees.BuyerFee,
+		BuyerFeeAsset:  fees.BuyerFeeAsset,
+		SellerFee:      fees.SellerFee,
+		SellerFeeAsset: fees.SellerFeeAsset,
+		Epoch:          trade.Epoch,
 	})
+	if err != nil {
+		// 结算失败不发流水，由对账发现并补偿
+		return
+	}
+
+	// 推荐返佣只累计，日结时从手续费账户统一发放
+	if p.referral != nil {
+		var tradeTime time.Time // 零值时按当前时间记账
+		if trade.Timestamp > 0 {
+			tradeTime = time.Unix(0, trade.Timestamp)
+		}
+		p.referral.OnFill(referral.Fill{TradeID: trade.ID, UserID: buyerID, Fee: fees.BuyerFee, FeeAsset: fees.BuyerFeeAsset, Time: tradeTime})
+		p.referral.OnFill(referral.Fill{TradeID: trade.ID, UserID: sellerID, Fee: fees.SellerFee, FeeAsset: fees.SellerFeeAsset, Time: tradeTime})
+	}
+
+	if p.trades != nil {
+		p.trades.PublishTradeEvent(&fund.TradeEvent{
+			TradeID:        trade.ID,
+			TakerOrderID:   trade.TakerID,
+			MakerOrderID:   trade.MakerID,
+			TakerUserID:    takerMeta.UserID,
+			MakerUserID:    makerMeta.UserID,
+			Symbol:         takerMeta.Symbol,
+			SettleCurrency: takerMeta.QuoteAsset,
+			Price:          trade.Price,
+			Qty:            trade.Qty,
+			Timestamp:      trade.Timestamp,
+			TakerFee:       takerCharge.Fee,
+			TakerFeeAsset:  takerCharge.Asset,
+			TakerFeeRate:   takerCharge.Rate,
+			TakerFeeTier:   takerCharge.Tier,
+			MakerFee:       makerCharge.Fee,
+			MakerFeeAsset:  makerCharge.Asset,
+			MakerFeeRate:   makerCharge.Rate,
+			MakerFeeTier:   makerCharge.Tier,
+		})
+	}
 
 	// 发送 Kafka 事件 (买方和卖方各一条流水)
 	if p.publisher != nil {
-		quoteAmount := (trade.Price / asset.Precision) * trade.Qty
+		quoteAmount := fixed.Mul(trade.Price, trade.Qty)
 
 		// 买方流水: 支付 USDT，获得 BTC
 		p.publisher.PublishJournal(&fund.JournalEvent{
@@ -317,7 +594,53 @@ func (p *SpotProcessor) handleTrade(event mtrade.Event) {
 			BizID:      fmt.Sprintf("%d", trade.ID),
 			CreatedAt:  time.Now(),
 		})
+
+		// 手续费 / 返佣流水
+		buyerCharge, buyerLiquidity := takerCharge, "taker"
+		sellerCharge, sellerLiquidity := makerCharge, "maker"
+		if trade.TakerSide == mtrade.SideSell {
+			buyerCharge, buyerLiquidity = makerCharge, "maker"
+			sellerCharge, sellerLiquidity = takerCharge, "taker"
+		}
+		p.publishFeeJournal(trade.ID, buyerID, "buyer", buyerLiquidity, buyerCharge)
+		p.publishFeeJournal(trade.ID, sellerID, "seller", sellerLiquidity, sellerCharge)
+	}
+}
+
+// publishFeeJournal 手续费记 FEE，返佣 (fee < 0) 记 REBATE，金额均为正数
+func (p *SpotProcessor) publishFeeJournal(tradeID, userID int64, role, liquidity string, charge FeeCharge) {
+	if charge.Fee == 0 {
+		return
 	}
+	changeType, amount, kind := fund.ChangeTypeFee, charge.Fee, "fee"
+	if charge.Fee < 0 {
+		changeType, amount, kind = fund.ChangeTypeRebate, -charge.Fee, "rebate"
+	}
+	p.publisher.PublishJournal(&fund.JournalEvent{
+		EventID:    fmt.Sprintf("trade_%d_%s_%s", tradeID, role, kind),
+		UserID:     userID,
+		Symbol:     charge.Asset,
+		ChangeType: changeType,
+		Amount:     amount,
+		BizType:    fund.BizTypeTrade,
+		BizID:      fmt.Sprintf("%d", tradeID),
+		Liquidity:  liquidity,
+		FeeRate:    charge.Rate,
+		FeeTier:    charge.Tier,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// feeSchedule 用户适用的费率：有档位且档位合法时用档位，否则用默认费率
+func (p *SpotProcessor) feeSchedule(userID int64) FeeSchedule {
+	if p.feeTiers == nil {
+		return p.fees
+	}
+	// 档位配错 (返佣超过 taker 费率等) 时不能按它收费，退回默认费率
+	if fees, ok := p.feeTiers.FeeSchedule(userID); ok && fees.Validate() == nil {
+		return fees
+	}
+	return p.fees
 }
 
 // handleCancel 处理撤单事件
@@ -338,18 +661,15 @@ func (p *SpotProcessor) handleCancel(event mtrade.Event) {
 	// 计算剩余冻结金额 (本金 + 手续费预留)
 	// 已成交部分不解冻，只解冻剩余部分
 	remainingQty := order.Qty - order.FilledQty
-	remainingRatio := remainingQty * 10000 / order.Qty // 万分比
+	feeRelease := fixed.MulDiv(meta.FeeReserve, remainingQty, order.Qty) // 按剩余比例
 
 	var releaseAmt int64
 
 	if order.Side == mtrade.SideBuy {
 		// 买单剩余: (价格 * 剩余数量) + 比例手续费
-		principal := (meta.Price / asset.Precision) * remainingQty
-		feeRelease := meta.FeeReserve * remainingRatio / 10000
-		releaseAmt = principal + feeRelease
+		releaseAmt = fixed.Mul(meta.Price, remainingQty) + feeRelease
 	} else {
 		// 卖单剩余: 剩余数量 + 比例手续费
-		feeRelease := meta.FeeReserve * remainingRatio / 10000
 		releaseAmt = remainingQty + feeRelease
 	}
 
@@ -361,6 +681,7 @@ func (p *SpotProcessor) handleCancel(event mtrade.Event) {
 	// 清理元数据
 	p.mu.Lock()
 	delete(p.orderIndex, order.ID)
+	p.addOpenNotional(meta, -orderNotional(meta.Price, remainingQty))
 	p.mu.Unlock()
 }
 
@@ -379,19 +700,74 @@ func (p *SpotProcessor) handleReject(event mtrade.Event) {
 		return
 	}
 
-	// 全额解冻 (本金 + 手续费预留)
-	totalReserve := meta.ReserveAmt + meta.FeeReserve
-	p.assetEngine.Release(meta.UserID, meta.ReserveAsset, totalReserve, order.ID)
+	p.releaseOrder(meta, order.Qty-order.FilledQty)
+}
+
+// releaseOrder 全额解冻 (本金 + 手续费预留) 并清理元数据，openQty 为仍计入挂单敞口的数量
+func (p *SpotProcessor) releaseOrder(meta *OrderMeta, openQty int64) {
+	p.assetEngine.Release(meta.UserID, meta.ReserveAsset, meta.ReserveAmt+meta.FeeReserve, meta.OrderID)
 
 	p.mu.Lock()
-	delete(p.orderIndex, order.ID)
+	delete(p.orderIndex, meta.OrderID)
+	p.addOpenNotional(meta, -orderNotional(meta.Price, openQty))
 	p.mu.Unlock()
 }
 
+// =============================================================================
+// 风控敞口
+// =============================================================================
+
+// OpenNotional 用户在某交易对上未成交挂单的价值 (实现 limits.ExposureProvider)
+func (p *SpotProcessor) OpenNotional(ctx context.Context, userID int64, symbol string) (int64, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.openNotional[exposureKey{userID, symbol}], nil
+}
+
+// addOpenNotional 调整挂单敞口 (调用方持有 p.mu 写锁)
+func (p *SpotProcessor) addOpenNotional(meta *OrderMeta, delta int64) {
+	key := exposureKey{meta.UserID, meta.Symbol}
+	if v := p.openNotional[key] + delta; v > 0 {
+		p.openNotional[key] = v
+	} else {
+		delete(p.openNotional, key)
+	}
+}
+
 // =============================================================================
 // 辅助函数
 // =============================================================================
 
+// orderNotional 订单价值 (报价货币)，与冻结本金同口径
+func orderNotional(price, qty int64) int64 {
+	return fixed.Mul(price, qty)
+}
+
+// resolveSymbol 下单前的交易对检查，返回基础 / 报价资产和是否处于开盘竞价
+//
+// 未配置交易对管理器时只按 BASE_QUOTE 解析 (兼容旧部署)
+func (p *SpotProcessor) resolveSymbol(order *mtrade.Order) (base, quote string, auction bool, err error) {
+	if p.symbols == nil {
+		base, quote, err = parseSymbol(order.Symbol)
+		return base, quote, false, err
+	}
+	spec, err := p.symbols.GetSymbol(context.Background(), order.Symbol)
+	if err != nil {
+		return "", "", false, err
+	}
+	if !spec.AcceptsOrders() {
+		return "", "", false, ErrSymbolNotTrading.Wrapf("%s is %s", spec.Symbol, spec.Status)
+	}
+	if err := spec.ValidateOrder(order); err != nil {
+		return "", "", false, err
+	}
+	auction = spec.Status == StatusAuction
+	if auction && !auctionOrderType(order.Type) {
+		return "", "", false, ErrAuctionOrderType.Wrapf("%s during opening auction", order.Type)
+	}
+	return spec.BaseAsset, spec.QuoteAsset, auction, nil
+}
+
 // parseSymbol 解析交易对
 // "BTC_USDT" -> "BTC", "USDT"
 func parseSymbol(symbol string) (base, quote string, err error) {
@@ -11,12 +11,17 @@ package spot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"max.com/pkg/asset"
+	"max.com/pkg/emergency"
+	"max.com/pkg/fund"
 	"max.com/pkg/mtrade"
+	"max.com/pkg/risk/limits"
 )
 
 // =============================================================================
@@ -248,6 +253,49 @@ func TestSpotProcessor_InsufficientBalance(t *testing.T) {
 	}
 }
 
+// TestSpotProcessor_ClientOrderID 重试带同一 clientOid 不会重复冻结，按 clientOid 撤单
+func TestSpotProcessor_ClientOrderID(t *testing.T) {
+	processor, assetEngine, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	userID := int64(100)
+	depositFunds(t, assetEngine, userID, "USDT", 60000*asset.Precision)
+	initialBalance := assetEngine.GetAvailable(userID, "USDT")
+
+	newOrder := func(id int64) *mtrade.Order {
+		return &mtrade.Order{
+			ID:            id,
+			UserID:        userID,
+			Symbol:        "BTC_USDT",
+			Side:          mtrade.SideBuy,
+			Type:          mtrade.OrderTypeLimit,
+			Price:         20000 * asset.Precision,
+			Qty:           1 * asset.Precision,
+			ClientOrderID: "my-order-1",
+		}
+	}
+	if err := processor.PlaceOrder(newOrder(1001)); err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	locked := initialBalance - assetEngine.GetAvailable(userID, "USDT")
+
+	if err := processor.PlaceOrder(newOrder(1002)); !errors.Is(err, ErrDuplicateClientOrderID) {
+		t.Fatalf("expected duplicate clientOid, got %v", err)
+	}
+	if got := initialBalance - assetEngine.GetAvailable(userID, "USDT"); got != locked {
+		t.Fatalf("retry locked more funds: %d, want %d", got, locked)
+	}
+
+	if !processor.CancelByClientOrderID(userID, "my-order-1") {
+		t.Fatal("CancelByClientOrderID failed")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := assetEngine.GetAvailable(userID, "USDT"); got != initialBalance {
+		t.Fatalf("balance after cancel %d, want %d", got, initialBalance)
+	}
+}
+
 // =============================================================================
 // 压测
 // =============================================================================
@@ -294,3 +342,274 @@ func BenchmarkSpotProcessor_PlaceOrder(b *testing.B) {
 		processor.PlaceOrder(order)
 	}
 }
+
+// TestSpotProcessor_RiskLimits 测试下单前风控: 挂单敞口随成交/撤单释放
+func TestSpotProcessor_RiskLimits(t *testing.T) {
+	processor, assetEngine, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	processor.riskLimits = limits.NewService(limits.Rules{
+		Default: limits.Limits{MaxOpenNotional: 80000 * asset.Precision},
+	}, nil)
+
+	userID := int64(300)
+	depositFunds(t, assetEngine, userID, "USDT", 200000*asset.Precision)
+
+	newOrder := func(id int64) *mtrade.Order {
+		return &mtrade.Order{
+			ID:     id,
+			UserID: userID,
+			Symbol: "BTC_USDT",
+			Side:   mtrade.SideBuy,
+			Type:   mtrade.OrderTypeLimit,
+			Price:  50000 * asset.Precision,
+			Qty:    1 * asset.Precision,
+		}
+	}
+
+	if err := processor.PlaceOrder(newOrder(3001)); err != nil {
+		t.Fatalf("first order should pass: %v", err)
+	}
+	if err := processor.PlaceOrder(newOrder(3002)); !errors.Is(err, limits.ErrOpenNotionalExceeded) {
+		t.Fatalf("expected open notional rejection, got %v", err)
+	}
+
+	// 撤单后敞口释放
+	processor.CancelOrder(3001)
+	time.Sleep(50 * time.Millisecond)
+	if n, _ := processor.OpenNotional(context.Background(), userID, "BTC_USDT"); n != 0 {
+		t.Fatalf("expected exposure released after cancel, got %d", n)
+	}
+	if err := processor.PlaceOrder(newOrder(3003)); err != nil {
+		t.Fatalf("order after cancel should pass: %v", err)
+	}
+
+	processor.riskLimits.BlockSymbol("BTC_USDT", true)
+	if err := processor.PlaceOrder(newOrder(3004)); !errors.Is(err, limits.ErrSymbolBlocked) {
+		t.Fatalf("expected blocked symbol, got %v", err)
+	}
+}
+
+// TestSpotProcessor_RoutedEngines 测试多引擎按交易对路由
+func TestSpotProcessor_RoutedEngines(t *testing.T) {
+	assetEngine := asset.NewEngine(asset.DefaultEngineConfig())
+	assetEngine.Start()
+	defer assetEngine.Stop()
+
+	router := mtrade.NewSymbolRouter(mtrade.SymbolRouterConfig{})
+	engines := make(map[string]*mtrade.Engine)
+	for _, symbol := range []string{"BTC_USDT", "ETH_USDT"} {
+		engine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig(symbol))
+		if err != nil {
+			t.Fatalf("Failed to create match engine: %v", err)
+		}
+		engine.Start(context.Background())
+		defer engine.Stop()
+		engines[symbol] = engine
+		if err := router.Register(symbol, engine); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	processor := NewSpotProcessor(ProcessorConfig{AssetEngine: assetEngine, Engines: router})
+
+	userID := int64(100)
+	depositFunds(t, assetEngine, userID, "USDT", 10000*asset.Precision)
+	initialBalance := assetEngine.GetAvailable(userID, "USDT")
+
+	order := &mtrade.Order{
+		ID:     2001,
+		UserID: userID,
+		Symbol: "ETH_USDT",
+		Side:   mtrade.SideBuy,
+		Type:   mtrade.OrderTypeLimit,
+		Price:  3000 * asset.Precision,
+		Qty:    asset.Precision,
+	}
+	if err := processor.PlaceOrder(order); err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if n := engines["ETH_USDT"].GetOrderBook().GetSnapshot().Orders; n != 1 {
+		t.Errorf("ETH_USDT book should have 1 order, got %d", n)
+	}
+	if n := engines["BTC_USDT"].GetOrderBook().GetSnapshot().Orders; n != 0 {
+		t.Errorf("BTC_USDT book should be empty, got %d", n)
+	}
+
+	// 撤单事件来自 ETH 引擎，同样能解冻
+	if !processor.CancelOrder(2001) {
+		t.Fatal("CancelOrder failed")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := assetEngine.GetAvailable(userID, "USDT"); got != initialBalance {
+		t.Errorf("Balance should be restored, expected %d, got %d", initialBalance, got)
+	}
+
+	// 未登记的交易对下单失败，冻结回滚
+	order = &mtrade.Order{ID: 2002, UserID: userID, Symbol: "SOL_USDT", Side: mtrade.SideBuy, Type: mtrade.OrderTypeLimit, Price: 100 * asset.Precision, Qty: asset.Precision}
+	if err := processor.PlaceOrder(order); !errors.Is(err, ErrSubmitOrderFail) {
+		t.Errorf("Expected ErrSubmitOrderFail, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := assetEngine.GetAvailable(userID, "USDT"); got != initialBalance {
+		t.Errorf("Balance should be restored after failed submit, expected %d, got %d", initialBalance, got)
+	}
+}
+
+// TestSpotProcessor_Emergency 测试全站停机与用户暂停：暂停只拦买入，停机全拦，撤单不受影响
+func TestSpotProcessor_Emergency(t *testing.T) {
+	processor, assetEngine, matchEngine, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	control := emergency.New(emergency.Config{Engines: []mtrade.EngineRouter{mtrade.SingleEngine(matchEngine)}})
+	processor.emergency = control
+
+	userID := int64(400)
+	depositFunds(t, assetEngine, userID, "USDT", 100000*asset.Precision)
+	depositFunds(t, assetEngine, userID, "BTC", 2*asset.Precision)
+	newOrder := func(id int64, side mtrade.Side, price int64) *mtrade.Order {
+		return &mtrade.Order{ID: id, UserID: userID, Symbol: "BTC_USDT", Side: side, Type: mtrade.OrderTypeLimit, Price: price * asset.Precision, Qty: asset.Precision}
+	}
+
+	if err := processor.PlaceOrder(newOrder(4001, mtrade.SideBuy, 40000)); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if _, err := control.Suspend(context.Background(), userID, 1, "suspicious login", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.PlaceOrder(newOrder(4002, mtrade.SideBuy, 40000)); !errors.Is(err, emergency.ErrUserSuspended) {
+		t.Fatalf("suspended buy: err = %v", err)
+	}
+	if err := processor.PlaceOrder(newOrder(4003, mtrade.SideSell, 60000)); err != nil {
+		t.Fatalf("suspended sell should pass: %v", err)
+	}
+	if !processor.CancelOrder(4001) {
+		t.Fatal("suspended user should cancel")
+	}
+	// 下单 / 撤单异步进簿：等簿上只剩这笔卖单，停机时可撤的挂单数才确定
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		book := matchEngine.GetOrderBook().GetSnapshot()
+		if book.Orders == 1 && book.BestAsk == 60000*asset.Precision {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("book never settled to the resting sell: %+v", book)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 停机撤掉剩下的卖单，资金全部解冻
+	if res, err := control.Kill(context.Background(), 1, "incident", true); err != nil || res.Canceled != 1 {
+		t.Fatalf("Kill = %+v, %v", res, err)
+	}
+	if err := processor.PlaceOrder(newOrder(4004, mtrade.SideSell, 60000)); !errors.Is(err, emergency.ErrKillSwitch) {
+		t.Fatalf("sell during kill switch: err = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	snap := assetEngine.GetSnapshot(userID)
+	if snap.Assets["USDT"].Locked != 0 || snap.Assets["BTC"].Locked != 0 {
+		t.Fatalf("funds still locked: %+v", snap.Assets)
+	}
+}
+
+// feeTiers 按用户的费率档位
+type feeTiers map[int64]FeeSchedule
+
+func (f feeTiers) FeeSchedule(userID int64) (FeeSchedule, bool) {
+	fees, ok := f[userID]
+	return fees, ok
+}
+
+// capturePublisher 记录发出的成交事件和流水
+type capturePublisher struct {
+	mu       sync.Mutex
+	trades   []*fund.TradeEvent
+	journals []*fund.JournalEvent
+}
+
+func (c *capturePublisher) PublishTradeEvent(event *fund.TradeEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trades = append(c.trades, event)
+	return nil
+}
+
+func (c *capturePublisher) PublishJournal(event *fund.JournalEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.journals = append(c.journals, event)
+	return nil
+}
+
+// TestSpotProcessor_FeeBreakdown 成交事件和手续费流水带双方的手续费、费率和档位
+func TestSpotProcessor_FeeBreakdown(t *testing.T) {
+	assetEngine := asset.NewEngine(asset.DefaultEngineConfig())
+	assetEngine.Start()
+	defer assetEngine.Stop()
+	matchEngine, err := mtrade.NewEngine(mtrade.DefaultEngineConfig("BTC_USDT"))
+	if err != nil {
+		t.Fatalf("Failed to create match engine: %v", err)
+	}
+	matchEngine.Start(context.Background())
+	defer matchEngine.Stop()
+
+	buyerID, sellerID := int64(100), int64(200)
+	pub := &capturePublisher{}
+	processor := NewSpotProcessor(ProcessorConfig{
+		AssetEngine:  assetEngine,
+		MatchEngine:  matchEngine,
+		MakerFeeRate: 10,
+		TakerFeeRate: 20,
+		FeeTiers:     feeTiers{sellerID: {Tier: "VIP1", MakerRate: 5, TakerRate: 15}},
+		Publisher:    pub,
+		Trades:       pub,
+	})
+
+	price := int64(50000 * asset.Precision)
+	qty := int64(1 * asset.Precision)
+	depositFunds(t, assetEngine, buyerID, "USDT", 60000*asset.Precision)
+	depositFunds(t, assetEngine, sellerID, "BTC", 2*asset.Precision)
+
+	// 卖方 (VIP1) 挂单，买方 (默认档位) 吃单
+	for _, o := range []*mtrade.Order{
+		{ID: 2001, UserID: sellerID, Symbol: "BTC_USDT", Side: mtrade.SideSell, Type: mtrade.OrderTypeLimit, Price: price, Qty: qty},
+		{ID: 1001, UserID: buyerID, Symbol: "BTC_USDT", Side: mtrade.SideBuy, Type: mtrade.OrderTypeLimit, Price: price, Qty: qty},
+	} {
+		if err := processor.PlaceOrder(o); err != nil {
+			t.Fatalf("PlaceOrder failed: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if len(pub.trades) != 1 {
+		t.Fatalf("expected 1 trade event, got %d", len(pub.trades))
+	}
+	ev := pub.trades[0]
+	if ev.TakerUserID != buyerID || ev.MakerUserID != sellerID || ev.Symbol != "BTC_USDT" || ev.SettleCurrency != "USDT" {
+		t.Errorf("trade event parties: %+v", ev)
+	}
+	// taker 买方 0.2% 扣 BTC，maker 卖方按 VIP1 0.05% 扣 USDT
+	if ev.TakerFee != qty*20/10000 || ev.TakerFeeAsset != "BTC" || ev.TakerFeeRate != 20 || ev.TakerFeeTier != DefaultFeeTier {
+		t.Errorf("taker fee: %+v", ev)
+	}
+	if ev.MakerFee != 25*asset.Precision || ev.MakerFeeAsset != "USDT" || ev.MakerFeeRate != 5 || ev.MakerFeeTier != "VIP1" {
+		t.Errorf("maker fee: %+v", ev)
+	}
+
+	feeJournals := make(map[int64]*fund.JournalEvent)
+	for _, j := range pub.journals {
+		if j.ChangeType == fund.ChangeTypeFee {
+			feeJournals[j.UserID] = j
+		}
+	}
+	if j := feeJournals[buyerID]; j == nil || j.Liquidity != "taker" || j.FeeTier != DefaultFeeTier || j.Amount != ev.TakerFee {
+		t.Errorf("buyer fee journal: %+v", j)
+	}
+	if j := feeJournals[sellerID]; j == nil || j.Liquidity != "maker" || j.FeeTier != "VIP1" || j.FeeRate != 5 || j.Symbol != "USDT" {
+		t.Errorf("seller fee journal: %+v", j)
+	}
+}
@@ -0,0 +1,75 @@
+// 文件: pkg/spot/quote_order.go
+// 按金额下市价单 - "花 100 USDT 买 BTC"
+//
+// 【流程】
+//
+//	PlaceOrder (QuoteQty=100 USDT, Qty=0)
+//	    ↓ 冻结 100 USDT + 预估手续费
+//	撮合逐档用预算换算成交量 (mtrade/quote_order.go)
+//	    ↓
+//	EventOrderAccepted (订单已是终态)：解冻未用完的预算 + 手续费预留
+//	    ↓
+//	EventTrade × N：按成交价 × 成交量结算，最后一笔结算完清理元数据
+//
+// 【注意】
+//   - 只支持市价买单：卖出按数量下单即可，按金额卖需要冻结一个事先算不出的基础资产数量
+//   - 撮合保证 Σ 成交金额 <= 预算，结算从冻结里扣的正是成交金额，剩下的在受理事件里一次解冻
+//   - 买方手续费按基础资产收 (见 fee.go)，报价资产的手续费预留全部退回
+
+package spot
+
+import (
+	"max.com/pkg/mtrade"
+)
+
+// checkQuoteOrder 按金额下单的参数检查 (撮合也会拒，这里提前挡掉免得白冻结)
+func (p *SpotProcessor) checkQuoteOrder(order *mtrade.Order) error {
+	if order.QuoteQty == 0 {
+		return nil
+	}
+	if order.QuoteQty < 0 || order.Qty != 0 {
+		return ErrInvalidOrder.Wrapf("quote qty %d with qty %d", order.QuoteQty, order.Qty)
+	}
+	if order.Type != mtrade.OrderTypeMarket || order.Side != mtrade.SideBuy {
+		return ErrInvalidOrder.Wrapf("quote qty is only supported for market buy orders")
+	}
+	return p.flags.Check(FlagOrderMarketQuote, order.Symbol, order.UserID)
+}
+
+// handleQuoteAccepted 按金额下单的订单撮合完毕：解冻未用完的预算，移出挂单敞口
+//
+// 受理事件先于本单的成交事件发布，成交还没结算，元数据留到最后一笔成交结算完再删
+func (p *SpotProcessor) handleQuoteAccepted(event mtrade.Event) {
+	order := event.Order
+	if order == nil || order.QuoteQty <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	meta := p.orderIndex[order.ID]
+	if meta == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.addOpenNotional(meta, -meta.QuoteQty)
+	if event.Result != nil {
+		meta.pendingTrades = len(event.Result.Trades)
+	}
+	if meta.pendingTrades == 0 {
+		delete(p.orderIndex, order.ID)
+	}
+	p.mu.Unlock()
+
+	if unspent := meta.QuoteQty - order.FilledQuote + meta.FeeReserve; unspent > 0 {
+		p.assetEngine.Release(meta.UserID, meta.ReserveAsset, unspent, order.ID)
+	}
+}
+
+// settledQuoteTrade 按金额下单的订单结算完一笔成交 (无论成功与否)，全部结算完清理元数据
+func (p *SpotProcessor) settledQuoteTrade(meta *OrderMeta) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if meta.pendingTrades--; meta.pendingTrades <= 0 {
+		delete(p.orderIndex, meta.OrderID)
+	}
+}
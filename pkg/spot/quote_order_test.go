@@ -0,0 +1,105 @@
+package spot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/mtrade"
+)
+
+// TestSpotProcessor_QuoteMarketBuy 花固定金额买入：跨两档成交，未用完的冻结全部解冻
+func TestSpotProcessor_QuoteMarketBuy(t *testing.T) {
+	processor, assetEngine, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	buyerID, sellerID := int64(100), int64(200)
+	depositFunds(t, assetEngine, buyerID, "USDT", 100000*asset.Precision)
+	depositFunds(t, assetEngine, sellerID, "BTC", 3*asset.Precision)
+
+	for i, price := range []int64{50000, 51000} {
+		sell := &mtrade.Order{ID: int64(2001 + i), UserID: sellerID, Symbol: "BTC_USDT", Side: mtrade.SideSell,
+			Type: mtrade.OrderTypeLimit, Price: price * asset.Precision, Qty: asset.Precision}
+		if err := processor.PlaceOrder(sell); err != nil {
+			t.Fatalf("sell %d: %v", i, err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// 75500 USDT = 1 BTC @ 50000 + 0.5 BTC @ 51000
+	buy := &mtrade.Order{ID: 1001, UserID: buyerID, Symbol: "BTC_USDT", Side: mtrade.SideBuy,
+		Type: mtrade.OrderTypeMarket, QuoteQty: 75500 * asset.Precision}
+	if err := processor.PlaceOrder(buy); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	usdt := assetEngine.GetSnapshot(buyerID).Assets["USDT"]
+	if usdt.Available != 24500*asset.Precision || usdt.Locked != 0 {
+		t.Errorf("buyer USDT available = %d locked = %d, want 24500 / 0", usdt.Available, usdt.Locked)
+	}
+	// 1.5 BTC 扣 0.2% taker 手续费
+	if got, want := assetEngine.GetAvailable(buyerID, "BTC"), int64(1.5*asset.Precision)*998/1000; got != want {
+		t.Errorf("buyer BTC = %d, want %d", got, want)
+	}
+
+	processor.mu.RLock()
+	_, leaked := processor.orderIndex[buy.ID]
+	open := processor.openNotional[exposureKey{buyerID, "BTC_USDT"}]
+	processor.mu.RUnlock()
+	if leaked || open != 0 {
+		t.Errorf("order meta kept = %v, open notional = %d", leaked, open)
+	}
+}
+
+// TestSpotProcessor_QuoteMarketBuyBookExhausted 对手盘不够：剩余预算解冻
+func TestSpotProcessor_QuoteMarketBuyBookExhausted(t *testing.T) {
+	processor, assetEngine, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	buyerID, sellerID := int64(100), int64(200)
+	depositFunds(t, assetEngine, buyerID, "USDT", 100000*asset.Precision)
+	depositFunds(t, assetEngine, sellerID, "BTC", 2*asset.Precision)
+
+	sell := &mtrade.Order{ID: 2001, UserID: sellerID, Symbol: "BTC_USDT", Side: mtrade.SideSell,
+		Type: mtrade.OrderTypeLimit, Price: 50000 * asset.Precision, Qty: asset.Precision}
+	if err := processor.PlaceOrder(sell); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	buy := &mtrade.Order{ID: 1001, UserID: buyerID, Symbol: "BTC_USDT", Side: mtrade.SideBuy,
+		Type: mtrade.OrderTypeMarket, QuoteQty: 60000 * asset.Precision}
+	if err := processor.PlaceOrder(buy); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	usdt := assetEngine.GetSnapshot(buyerID).Assets["USDT"]
+	if usdt.Available != 50000*asset.Precision || usdt.Locked != 0 {
+		t.Errorf("buyer USDT available = %d locked = %d, want 50000 / 0", usdt.Available, usdt.Locked)
+	}
+}
+
+func TestSpotProcessor_QuoteOrderValidation(t *testing.T) {
+	processor, assetEngine, _, cleanup := setupTestEnv(t)
+	defer cleanup()
+	depositFunds(t, assetEngine, 100, "USDT", 1000*asset.Precision)
+	depositFunds(t, assetEngine, 100, "BTC", asset.Precision)
+
+	cases := []*mtrade.Order{
+		{ID: 1, Side: mtrade.SideSell, Type: mtrade.OrderTypeMarket, QuoteQty: 100 * asset.Precision},
+		{ID: 2, Side: mtrade.SideBuy, Type: mtrade.OrderTypeLimit, Price: asset.Precision, QuoteQty: 100 * asset.Precision},
+		{ID: 3, Side: mtrade.SideBuy, Type: mtrade.OrderTypeMarket, Qty: asset.Precision, QuoteQty: 100 * asset.Precision},
+	}
+	for _, o := range cases {
+		o.UserID, o.Symbol = 100, "BTC_USDT"
+		if err := processor.PlaceOrder(o); !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("order %d: err = %v, want ErrInvalidOrder", o.ID, err)
+		}
+	}
+	if locked := assetEngine.GetSnapshot(100).Assets["USDT"].Locked; locked != 0 {
+		t.Errorf("rejected orders locked %d USDT", locked)
+	}
+}
@@ -0,0 +1,141 @@
+// 文件: pkg/spot/repository.go
+// 交易对规格存储 - 接口与 MySQL 实现 (缓存层见 cache_repo.go)
+
+package spot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SymbolRepository 交易对规格存储接口
+type SymbolRepository interface {
+	// Create 创建交易对，symbol 已存在返回 ErrSymbolExists
+	Create(ctx context.Context, spec *SymbolSpec) error
+
+	// GetBySymbol 不存在返回 ErrSymbolNotFound
+	GetBySymbol(ctx context.Context, symbol string) (*SymbolSpec, error)
+
+	// Update 更新规格 (根据 Symbol)
+	Update(ctx context.Context, spec *SymbolSpec) error
+
+	// UpdateStatus 状态从 from 切换到 to，当前状态不是 from 返回 ErrSymbolNotFound
+	UpdateStatus(ctx context.Context, symbol string, from, to SymbolStatus) error
+
+	// List 列出所有未下架的交易对
+	List(ctx context.Context) ([]*SymbolSpec, error)
+
+	// ListByStatus 按状态查询
+	ListByStatus(ctx context.Context, status SymbolStatus) ([]*SymbolSpec, error)
+}
+
+var _ SymbolRepository = (*MySQLSymbolRepository)(nil)
+
+// MySQLSymbolRepository MySQL 实现
+type MySQLSymbolRepository struct {
+	db *gorm.DB
+}
+
+// NewMySQLSymbolRepository 创建 MySQL 存储
+func NewMySQLSymbolRepository(db *gorm.DB) *MySQLSymbolRepository {
+	return &MySQLSymbolRepository{db: db}
+}
+
+// TableName GORM 表名
+func (SymbolSpec) TableName() string {
+	return "spot_symbols"
+}
+
+// Create 创建交易对
+func (r *MySQLSymbolRepository) Create(ctx context.Context, spec *SymbolSpec) error {
+	now := time.Now().UnixMilli()
+	spec.CreatedAt = now
+	spec.UpdatedAt = now
+
+	if err := r.db.WithContext(ctx).Create(spec).Error; err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrSymbolExists
+		}
+		return err
+	}
+	return nil
+}
+
+// GetBySymbol 根据 symbol 查询
+func (r *MySQLSymbolRepository) GetBySymbol(ctx context.Context, symbol string) (*SymbolSpec, error) {
+	var spec SymbolSpec
+	err := r.db.WithContext(ctx).Where("symbol = ?", symbol).First(&spec).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSymbolNotFound
+		}
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Update 更新规格
+func (r *MySQLSymbolRepository) Update(ctx context.Context, spec *SymbolSpec) error {
+	spec.UpdatedAt = time.Now().UnixMilli()
+
+	result := r.db.WithContext(ctx).
+		Model(&SymbolSpec{}).
+		Where("symbol = ?", spec.Symbol).
+		Updates(spec)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSymbolNotFound
+	}
+	return nil
+}
+
+// UpdateStatus 按状态条件更新 (乐观并发：并发的两次上线只有一次生效)
+func (r *MySQLSymbolRepository) UpdateStatus(ctx context.Context, symbol string, from, to SymbolStatus) error {
+	now := time.Now().UnixMilli()
+
+	updates := map[string]interface{}{
+		"status":     to,
+		"updated_at": now,
+	}
+	if to == StatusTrading {
+		updates["listed_at"] = gorm.Expr("CASE WHEN listed_at = 0 THEN ? ELSE listed_at END", now)
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&SymbolSpec{}).
+		Where("symbol = ? AND status = ?", symbol, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSymbolNotFound
+	}
+	return nil
+}
+
+// List 列出所有未下架的交易对
+func (r *MySQLSymbolRepository) List(ctx context.Context) ([]*SymbolSpec, error) {
+	var specs []*SymbolSpec
+	err := r.db.WithContext(ctx).Where("status != ?", StatusDelisted).Find(&specs).Error
+	return specs, err
+}
+
+// ListByStatus 按状态查询
+func (r *MySQLSymbolRepository) ListByStatus(ctx context.Context, status SymbolStatus) ([]*SymbolSpec, error) {
+	var specs []*SymbolSpec
+	err := r.db.WithContext(ctx).Where("status = ?", status).Find(&specs).Error
+	return specs, err
+}
+
+// isDuplicateKeyError MySQL error 1062 = Duplicate entry
+func isDuplicateKeyError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") || strings.Contains(msg, "1062")
+}
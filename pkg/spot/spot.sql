@@ -0,0 +1,19 @@
+-- 现货交易对规格表
+CREATE TABLE IF NOT EXISTS `spot_symbols` (
+    `id` INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '交易对ID',
+    `symbol` VARCHAR(32) NOT NULL COMMENT '交易对: BTC_USDT',
+    `base_asset` VARCHAR(16) NOT NULL COMMENT '基础资产: BTC',
+    `quote_asset` VARCHAR(16) NOT NULL COMMENT '报价资产: USDT',
+    `tick_size` BIGINT NOT NULL DEFAULT 0 COMMENT '最小价格变动, 0=不限',
+    `lot_size` BIGINT NOT NULL DEFAULT 0 COMMENT '最小数量变动, 0=不限',
+    `min_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最小下单量, 0=不限',
+    `max_qty` BIGINT NOT NULL DEFAULT 0 COMMENT '最大下单量, 0=不限',
+    `ref_price` BIGINT NOT NULL DEFAULT 0 COMMENT '集合竞价参考价',
+    `opening_price` BIGINT NOT NULL DEFAULT 0 COMMENT '集合竞价开盘价, 0=未竞价或无成交',
+    `status` TINYINT NOT NULL DEFAULT 0 COMMENT '0=待上线,1=集合竞价,2=交易中,3=已下架',
+    `listed_at` BIGINT NOT NULL DEFAULT 0 COMMENT '上线时间 (unix ms)',
+    `created_at` BIGINT NOT NULL COMMENT '创建时间',
+    `updated_at` BIGINT NOT NULL COMMENT '更新时间',
+    UNIQUE KEY `uk_symbol` (`symbol`),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '现货交易对规格表';
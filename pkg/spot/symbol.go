@@ -0,0 +1,136 @@
+// 文件: pkg/spot/symbol.go
+// 现货交易对规格 - 上线 / 下架生命周期，对应合约侧的 futures.ContractSpec
+//
+// 【问题】之前交易对就是任意 "BASE_QUOTE" 字符串：拼错的交易对也能冻结资金、提交撮合，
+// 没有下架的概念，也没有价格 / 数量精度约束
+//
+// 【生命周期】
+//
+//	PENDING ──StartAuction──→ AUCTION ──ListSymbol──→ TRADING ──DelistSymbol──→ DELISTED
+//	   └──────────────────ListSymbol (不竞价直接上线)──────↗
+//
+// AUCTION 期间只收限价单，挂单不撮合；上线时按集合竞价定开盘价一次性撮合 (见 auction.go)
+
+package spot
+
+import (
+	"strings"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/mtrade"
+)
+
+var (
+	ErrSymbolExists      = cexerr.New("SPOT_SYMBOL_EXISTS", cexerr.CategoryConflict, "spot symbol already exists")
+	ErrSymbolNotFound    = cexerr.New("SPOT_SYMBOL_NOT_FOUND", cexerr.CategoryNotFound, "spot symbol not found")
+	ErrSymbolNotTrading  = cexerr.New("SPOT_SYMBOL_NOT_TRADING", cexerr.CategoryFailedPrecondition, "spot symbol not trading")
+	ErrSymbolStatus      = cexerr.New("SPOT_SYMBOL_STATUS", cexerr.CategoryFailedPrecondition, "invalid spot symbol status transition")
+	ErrInvalidSymbolSpec = cexerr.New("SPOT_INVALID_SYMBOL_SPEC", cexerr.CategoryInvalidArgument, "invalid spot symbol spec")
+	ErrInvalidOrder      = cexerr.New("SPOT_INVALID_ORDER", cexerr.CategoryInvalidArgument, "order violates symbol spec")
+)
+
+// =============================================================================
+// 交易对状态
+// =============================================================================
+
+// SymbolStatus 交易对状态
+type SymbolStatus int8
+
+const (
+	StatusPending  SymbolStatus = iota // 待上线
+	StatusAuction                      // 开盘集合竞价：收单不撮合
+	StatusTrading                      // 交易中
+	StatusDelisted                     // 已下架
+)
+
+func (s SymbolStatus) String() string {
+	switch s {
+	case StatusPending:
+		return "PENDING"
+	case StatusAuction:
+		return "AUCTION"
+	case StatusTrading:
+		return "TRADING"
+	case StatusDelisted:
+		return "DELISTED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// =============================================================================
+// SymbolSpec - 交易对规格
+// =============================================================================
+
+// SymbolSpec 交易对规格
+type SymbolSpec struct {
+	ID uint `gorm:"primaryKey;autoIncrement"`
+
+	// ===== 标识 =====
+	Symbol     string `gorm:"column:symbol;type:varchar(32);uniqueIndex"` // BASE_QUOTE
+	BaseAsset  string `gorm:"column:base_asset;type:varchar(16)"`
+	QuoteAsset string `gorm:"column:quote_asset;type:varchar(16)"`
+
+	// ===== 精度 (0 表示不限制) =====
+	TickSize int64 `gorm:"column:tick_size"` // 最小价格变动
+	LotSize  int64 `gorm:"column:lot_size"`  // 最小数量变动
+	MinQty   int64 `gorm:"column:min_qty"`   // 最小下单量
+	MaxQty   int64 `gorm:"column:max_qty"`   // 最大下单量
+
+	// ===== 开盘 =====
+	RefPrice     int64 `gorm:"column:ref_price"`     // 参考价 (如其他交易所价格)，集合竞价多个价格同量时取最接近的
+	OpeningPrice int64 `gorm:"column:opening_price"` // 集合竞价开盘价，0 表示未竞价或没有成交
+
+	// ===== 生命周期 =====
+	Status    SymbolStatus `gorm:"column:status;index"`
+	ListedAt  int64        `gorm:"column:listed_at"`
+	CreatedAt int64        `gorm:"column:created_at"`
+	UpdatedAt int64        `gorm:"column:updated_at"`
+}
+
+// IsTrading 是否可交易
+func (s *SymbolSpec) IsTrading() bool {
+	return s.Status == StatusTrading
+}
+
+// AcceptsOrders 是否接受新订单 (交易中或集合竞价中)
+func (s *SymbolSpec) AcceptsOrders() bool {
+	return s.Status == StatusTrading || s.Status == StatusAuction
+}
+
+// ValidateOrder 检查订单价格 / 数量精度与上下限
+func (s *SymbolSpec) ValidateOrder(order *mtrade.Order) error {
+	if order.QuoteQty != 0 {
+		return nil // 按金额下单的数量由撮合换算，见 quote_order.go
+	}
+	if order.Qty <= 0 {
+		return ErrInvalidOrder.Wrapf("qty %d must be positive", order.Qty)
+	}
+	if s.LotSize > 0 && order.Qty%s.LotSize != 0 {
+		return ErrInvalidOrder.Wrapf("qty %d not a multiple of lot size %d", order.Qty, s.LotSize)
+	}
+	if s.MinQty > 0 && order.Qty < s.MinQty {
+		return ErrInvalidOrder.Wrapf("qty %d below min %d", order.Qty, s.MinQty)
+	}
+	if s.MaxQty > 0 && order.Qty > s.MaxQty {
+		return ErrInvalidOrder.Wrapf("qty %d above max %d", order.Qty, s.MaxQty)
+	}
+	if order.Type != mtrade.OrderTypeMarket && s.TickSize > 0 && order.Price%s.TickSize != 0 {
+		return ErrInvalidOrder.Wrapf("price %d not a multiple of tick size %d", order.Price, s.TickSize)
+	}
+	return nil
+}
+
+// validate 创建时的规格校验
+func (s *SymbolSpec) validate() error {
+	if s.BaseAsset == "" || s.QuoteAsset == "" || strings.Contains(s.BaseAsset+s.QuoteAsset, "_") {
+		return ErrInvalidSymbolSpec.Wrapf("invalid assets %q/%q", s.BaseAsset, s.QuoteAsset)
+	}
+	if s.TickSize < 0 || s.LotSize < 0 || s.MinQty < 0 || s.MaxQty < 0 || s.RefPrice < 0 {
+		return ErrInvalidSymbolSpec.Wrapf("negative precision or limit")
+	}
+	if s.MaxQty > 0 && s.MinQty > s.MaxQty {
+		return ErrInvalidSymbolSpec.Wrapf("min qty %d > max qty %d", s.MinQty, s.MaxQty)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package valuation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"max.com/pkg/cexerr"
+)
+
+// maxDashboardUsers 看板接口一次最多估值的用户数
+const maxDashboardUsers = 200
+
+// ValuateUsers 批量估值，按总资产从大到小排序 (风控看板)
+func (s *Service) ValuateUsers(ctx context.Context, userIDs []int64, quote string) ([]*Valuation, error) {
+	out := make([]*Valuation, 0, len(userIDs))
+	for _, uid := range userIDs {
+		v, err := s.Valuate(ctx, uid, quote)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Total > out[j].Total })
+	return out, nil
+}
+
+// =============================================================================
+// HTTP 接口
+// =============================================================================
+
+// NewHandler 账户资产估值接口
+//
+//	GET /account/valuation?user_id=1&quote=USD   quote 省略用默认计价币种
+//
+// user_id 由网关鉴权后注入，这里不做身份校验
+func NewHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		userID, err := strconv.ParseInt(q.Get("user_id"), 10, 64)
+		if err != nil || userID <= 0 {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("user_id"))
+			return
+		}
+		v, err := s.Valuate(r.Context(), userID, q.Get("quote"))
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		writeJSON(w, v)
+	})
+}
+
+// NewAdminHandler 风控看板用的批量估值接口 (仅内网)
+//
+//	GET /admin/valuation?user_ids=1,2,3&quote=USD   按总资产从大到小返回，最多 200 个用户
+func NewAdminHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		var userIDs []int64
+		for _, field := range strings.Split(q.Get("user_ids"), ",") {
+			uid, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil || uid <= 0 {
+				cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("user_ids"))
+				return
+			}
+			userIDs = append(userIDs, uid)
+		}
+		if len(userIDs) > maxDashboardUsers {
+			cexerr.WriteHTTP(w, cexerr.ErrInvalidParam.Wrapf("user_ids: at most %d", maxDashboardUsers))
+			return
+		}
+		out, err := s.ValuateUsers(r.Context(), userIDs, q.Get("quote"))
+		if err != nil {
+			cexerr.WriteHTTP(w, err)
+			return
+		}
+		writeJSON(w, out)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,398 @@
+// Package valuation 用户资产估值
+//
+// 【问题】用户的资产散在几处：现货余额 (各币种可用 + 冻结)、合约持仓保证金、持仓浮动盈亏，
+// 各自按不同币种计价。账户页要显示"总资产折合多少 USDT"，风控看板要按估值排大户，
+// 过去只能各处自己拼，口径不一致
+//
+// 【做法】按指数价格把整张资产负债表折算成一个计价币种 (USDT / USD)：
+//
+//	每个币种的数量 = 余额 (可用 + 冻结) + 持仓保证金 + 未实现盈亏 (按结算币种归集)
+//	折算价值       = 数量 × 指数价 (币种/计价币)
+//
+// 指数价先找直接报价 (BTC/USDT)，再找反向报价 (USDT/BTC 取倒数)，
+// 最后经桥接币种换算 (BTC/USDT × USDT/USD)。拿不到价格的币种不计入合计，列在 Unpriced
+//
+// 【缓存】同一 (用户, 计价币) 的结果缓存 CacheTTL：账户页轮询、看板批量刷新不会每次都查库。
+// 余额变动后需要立即反映的场景 (充提完成) 调用 Invalidate
+//
+// 【注意】
+//   - 估值只用于展示和风控排序，不参与保证金计算 (保证金见 futures.AccountMargin，抵押品另有折价)
+//   - 未实现盈亏可能为负，亏损超过余额的币种价值为负，合计照常相加
+package valuation
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"max.com/pkg/asset"
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fixed"
+	"max.com/pkg/fund"
+	"max.com/pkg/futures"
+	"max.com/pkg/indexprice"
+)
+
+// Precision 价格与数量精度 (与 asset.Precision 一致)
+const Precision = fixed.Scale
+
+var (
+	// ErrUnsupportedQuote 计价币种不在配置的 Quotes 里
+	ErrUnsupportedQuote = cexerr.New("VALUATION_UNSUPPORTED_QUOTE", cexerr.CategoryInvalidArgument, "unsupported valuation quote currency")
+	// ErrPositionsUnavailable 合约持仓估不出来 (如合约规格查不到)，整单失败而不是少算一块资产
+	ErrPositionsUnavailable = cexerr.New("VALUATION_POSITIONS_UNAVAILABLE", cexerr.CategoryInternal, "futures positions cannot be valued")
+)
+
+// =============================================================================
+// 依赖接口
+// =============================================================================
+
+// Balance 一个币种的现货余额
+type Balance struct {
+	Asset     string
+	Available int64
+	Locked    int64
+}
+
+// BalanceSource 现货余额来源
+type BalanceSource interface {
+	Balances(ctx context.Context, userID int64) ([]Balance, error)
+}
+
+// PositionSource 合约持仓 (*futures.FuturesProcessor 实现)
+type PositionSource interface {
+	PositionValues(ctx context.Context, userID int64) ([]futures.PositionValue, error)
+}
+
+var _ PositionSource = (*futures.FuturesProcessor)(nil)
+
+// PriceSource 指数价格来源
+type PriceSource interface {
+	// IndexPrice 返回 1 单位 asset 值多少 quote (精度 Precision)，没有价格返回 0
+	IndexPrice(asset, quote string) int64
+}
+
+// PriceFunc 函数适配器
+type PriceFunc func(asset, quote string) int64
+
+// IndexPrice 实现 PriceSource
+func (f PriceFunc) IndexPrice(asset, quote string) int64 { return f(asset, quote) }
+
+// IndexSource 按 symbol 查指数 (*indexprice.Service 实现)
+type IndexSource interface {
+	Index(symbol string) (indexprice.Index, bool)
+}
+
+var _ IndexSource = (*indexprice.Service)(nil)
+
+// IndexPrices 把指数服务适配成 PriceSource：asset/quote 对应 "ASSET/QUOTE" 指数，
+// 超过 maxAge 未更新的指数视为没有价格 (maxAge <= 0 不检查)
+func IndexPrices(idx IndexSource, maxAge time.Duration) PriceFunc {
+	return func(asset, quote string) int64 {
+		index, ok := idx.Index(indexprice.Pair{Base: asset, Quote: quote}.String())
+		if !ok {
+			return 0
+		}
+		if maxAge > 0 && time.Since(time.UnixMilli(index.UpdatedAt)) > maxAge {
+			return 0
+		}
+		return index.Price
+	}
+}
+
+// FundBalances 冷钱包余额 (*fund.BalanceRepo)
+func FundBalances(repo *fund.BalanceRepo) BalanceSource {
+	return fundBalances{repo}
+}
+
+type fundBalances struct{ repo *fund.BalanceRepo }
+
+func (f fundBalances) Balances(ctx context.Context, userID int64) ([]Balance, error) {
+	records, err := f.repo.GetBalances(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Balance, 0, len(records))
+	for _, r := range records {
+		out = append(out, Balance{Asset: r.Symbol, Available: r.Available, Locked: r.Locked})
+	}
+	return out, nil
+}
+
+// HotBalances 热钱包余额 (*asset.AccountEngine 的快照)
+func HotBalances(engine *asset.AccountEngine) BalanceSource {
+	return hotBalances{engine}
+}
+
+type hotBalances struct{ engine *asset.AccountEngine }
+
+func (h hotBalances) Balances(ctx context.Context, userID int64) ([]Balance, error) {
+	snap := h.engine.GetSnapshot(userID)
+	if snap == nil {
+		return nil, nil
+	}
+	out := make([]Balance, 0, len(snap.Assets))
+	for symbol, a := range snap.Assets {
+		out = append(out, Balance{Asset: symbol, Available: a.Available, Locked: a.Locked})
+	}
+	return out, nil
+}
+
+// =============================================================================
+// 配置
+// =============================================================================
+
+// Config 估值配置
+type Config struct {
+	Quotes   []string      // 支持的计价币种，第一个为默认，默认 USDT、USD
+	Bridge   string        // 没有直接报价时经这个币种换算，默认 USDT
+	CacheTTL time.Duration // 结果缓存时间，默认 2s，<0 不缓存
+}
+
+func (c Config) withDefaults() Config {
+	if len(c.Quotes) == 0 {
+		c.Quotes = []string{"USDT", "USD"}
+	}
+	if c.Bridge == "" {
+		c.Bridge = "USDT"
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = 2 * time.Second
+	}
+	return c
+}
+
+// =============================================================================
+// 估值结果
+// =============================================================================
+
+// Line 一个币种的估值明细
+type Line struct {
+	Asset          string `json:"asset"`
+	Balance        int64  `json:"balance"`         // 现货余额 (可用 + 冻结)
+	PositionMargin int64  `json:"position_margin"` // 以该币种结算的持仓保证金
+	UnrealizedPnL  int64  `json:"unrealized_pnl"`  // 以该币种结算的持仓浮动盈亏
+	Amount         int64  `json:"amount"`          // 合计数量
+	Price          int64  `json:"price"`           // 1 单位折合多少计价币，未定价为 0
+	Value          int64  `json:"value"`           // 折算价值
+}
+
+// Valuation 用户资产估值
+type Valuation struct {
+	UserID int64  `json:"user_id"`
+	Quote  string `json:"quote"`
+
+	Total          int64 `json:"total"`           // 总资产 = 下面三项之和
+	BalanceValue   int64 `json:"balance_value"`   // 现货余额折算
+	MarginValue    int64 `json:"margin_value"`    // 持仓保证金折算
+	UnrealizedPnL  int64 `json:"unrealized_pnl"`  // 浮动盈亏折算
+	PositionsCount int   `json:"positions_count"` // 持仓数
+
+	Lines    []Line   `json:"lines"`    // 按币种升序
+	Unpriced []string `json:"unpriced"` // 有数量但拿不到价格、未计入合计的币种
+	At       int64    `json:"at"`       // 估值时间 (毫秒)
+}
+
+// =============================================================================
+// Service
+// =============================================================================
+
+// Service 资产估值服务
+type Service struct {
+	cfg       Config
+	balances  BalanceSource
+	positions PositionSource // 可选
+	prices    PriceSource
+	now       func() time.Time
+
+	mu    sync.Mutex
+	cache map[cacheKey]*Valuation
+}
+
+type cacheKey struct {
+	userID int64
+	quote  string
+}
+
+// NewService 创建估值服务，positions 为 nil 时只估值现货余额
+func NewService(cfg Config, balances BalanceSource, positions PositionSource, prices PriceSource) *Service {
+	return &Service{
+		cfg:       cfg.withDefaults(),
+		balances:  balances,
+		positions: positions,
+		prices:    prices,
+		now:       time.Now,
+		cache:     make(map[cacheKey]*Valuation),
+	}
+}
+
+// SetClock 替换判断估值缓存是否过期所用的时钟
+func (s *Service) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// DefaultQuote 默认计价币种
+func (s *Service) DefaultQuote() string {
+	return s.cfg.Quotes[0]
+}
+
+// Valuate 估值用户资产 (quote 为空用默认计价币种)，CacheTTL 内返回缓存结果
+//
+// 返回值与缓存共享，调用方不要修改
+func (s *Service) Valuate(ctx context.Context, userID int64, quote string) (*Valuation, error) {
+	if quote == "" {
+		quote = s.DefaultQuote()
+	}
+	if !s.supported(quote) {
+		return nil, ErrUnsupportedQuote.Wrapf("%q", quote)
+	}
+	key := cacheKey{userID, quote}
+	now := s.now()
+	if s.cfg.CacheTTL > 0 {
+		s.mu.Lock()
+		v, ok := s.cache[key]
+		s.mu.Unlock()
+		if ok && now.Sub(time.UnixMilli(v.At)) < s.cfg.CacheTTL {
+			return v, nil
+		}
+	}
+
+	v, err := s.valuate(ctx, userID, quote, now)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.CacheTTL > 0 {
+		s.mu.Lock()
+		s.cache[key] = v
+		s.mu.Unlock()
+	}
+	return v, nil
+}
+
+// Invalidate 丢弃用户的缓存结果 (充提完成等需要立即反映的场景)
+func (s *Service) Invalidate(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.cache {
+		if key.userID == userID {
+			delete(s.cache, key)
+		}
+	}
+}
+
+// Prune 清掉已过期的缓存，由调用方定期执行 (用户多时避免缓存只增不减)
+func (s *Service) Prune() int {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key, v := range s.cache {
+		if now.Sub(time.UnixMilli(v.At)) >= s.cfg.CacheTTL {
+			delete(s.cache, key)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Service) supported(quote string) bool {
+	for _, q := range s.cfg.Quotes {
+		if q == quote {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) valuate(ctx context.Context, userID int64, quote string, now time.Time) (*Valuation, error) {
+	lines := make(map[string]*Line)
+	line := func(asset string) *Line {
+		l, ok := lines[asset]
+		if !ok {
+			l = &Line{Asset: asset}
+			lines[asset] = l
+		}
+		return l
+	}
+
+	balances, err := s.balances.Balances(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range balances {
+		if b.Available == 0 && b.Locked == 0 {
+			continue
+		}
+		line(b.Asset).Balance += b.Available + b.Locked
+	}
+
+	v := &Valuation{UserID: userID, Quote: quote, Lines: []Line{}, Unpriced: []string{}, At: now.UnixMilli()}
+	if s.positions != nil {
+		positions, err := s.positions.PositionValues(ctx, userID)
+		if err != nil {
+			return nil, ErrPositionsUnavailable.Wrap(err)
+		}
+		for _, p := range positions {
+			l := line(p.Settle)
+			l.PositionMargin += p.Margin
+			l.UnrealizedPnL += p.UnrealizedPnL
+		}
+		v.PositionsCount = len(positions)
+	}
+
+	assets := make([]string, 0, len(lines))
+	for a := range lines {
+		assets = append(assets, a)
+	}
+	sort.Strings(assets)
+	for _, a := range assets {
+		l := lines[a]
+		l.Amount = l.Balance + l.PositionMargin + l.UnrealizedPnL
+		l.Price = s.price(a, quote)
+		if l.Price == 0 {
+			if l.Amount != 0 {
+				v.Unpriced = append(v.Unpriced, a)
+			}
+			v.Lines = append(v.Lines, *l)
+			continue
+		}
+		l.Value = fixed.Mul(l.Amount, l.Price)
+		v.BalanceValue += fixed.Mul(l.Balance, l.Price)
+		v.MarginValue += fixed.Mul(l.PositionMargin, l.Price)
+		v.UnrealizedPnL += fixed.Mul(l.UnrealizedPnL, l.Price)
+		v.Lines = append(v.Lines, *l)
+	}
+	v.Total = v.BalanceValue + v.MarginValue + v.UnrealizedPnL
+	return v, nil
+}
+
+// price 1 单位 asset 折合多少 quote：同币种、直接报价、反向报价、经桥接币种，依次尝试
+func (s *Service) price(asset, quote string) int64 {
+	if p := s.pairPrice(asset, quote); p > 0 {
+		return p
+	}
+	bridge := s.cfg.Bridge
+	if asset == bridge || quote == bridge {
+		return 0
+	}
+	toBridge := s.pairPrice(asset, bridge)
+	fromBridge := s.pairPrice(bridge, quote)
+	if toBridge == 0 || fromBridge == 0 {
+		return 0
+	}
+	return fixed.Mul(toBridge, fromBridge)
+}
+
+func (s *Service) pairPrice(asset, quote string) int64 {
+	if asset == quote {
+		return Precision
+	}
+	if p := s.prices.IndexPrice(asset, quote); p > 0 {
+		return p
+	}
+	if p := s.prices.IndexPrice(quote, asset); p > 0 {
+		return fixed.Quo(Precision, p)
+	}
+	return 0
+}
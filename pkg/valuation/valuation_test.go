@@ -0,0 +1,185 @@
+package valuation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"max.com/pkg/futures"
+)
+
+type memBalances map[int64][]Balance
+
+func (m memBalances) Balances(ctx context.Context, userID int64) ([]Balance, error) {
+	return m[userID], nil
+}
+
+type memPositions map[int64][]futures.PositionValue
+
+func (m memPositions) PositionValues(ctx context.Context, userID int64) ([]futures.PositionValue, error) {
+	return m[userID], nil
+}
+
+// failingPositions 合约持仓查不出来 (如合约规格已下架)
+type failingPositions struct{ err error }
+
+func (f failingPositions) PositionValues(ctx context.Context, userID int64) ([]futures.PositionValue, error) {
+	return nil, f.err
+}
+
+// prices BTC/USDT 50000、USDT/USD 0.999、ETH 只有 USDT/ETH 反向报价 (1/2500)
+var prices = PriceFunc(func(asset, quote string) int64 {
+	switch asset + "/" + quote {
+	case "BTC/USDT":
+		return 50000 * Precision
+	case "USDT/USD":
+		return 99_900_000
+	case "USDT/ETH":
+		return Precision / 2500
+	}
+	return 0
+})
+
+func TestService_Valuate(t *testing.T) {
+	balances := memBalances{1: {
+		{Asset: "BTC", Available: Precision, Locked: Precision / 2},
+		{Asset: "USDT", Available: 1000 * Precision},
+		{Asset: "ETH", Available: 2 * Precision},
+		{Asset: "DOGE", Available: 100 * Precision}, // 没有指数
+		{Asset: "SOL"}, // 空余额不列出
+	}}
+	positions := memPositions{1: {
+		{Symbol: "BTCUSDT", Settle: "USDT", Margin: 500 * Precision, UnrealizedPnL: -200 * Precision},
+		{Symbol: "BTCUSD", Settle: "BTC", Margin: Precision / 10, UnrealizedPnL: Precision / 100},
+	}}
+	svc := NewService(Config{}, balances, positions, prices)
+
+	v, err := svc.Valuate(context.Background(), 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Quote != "USDT" || len(v.Lines) != 4 || v.PositionsCount != 2 {
+		t.Fatalf("valuation: %+v", v)
+	}
+	byAsset := make(map[string]Line)
+	for _, l := range v.Lines {
+		byAsset[l.Asset] = l
+	}
+	// BTC: 1.5 余额 + 0.1 保证金 + 0.01 浮盈 = 1.61 BTC
+	if l := byAsset["BTC"]; l.Amount != 161*Precision/100 || l.Value != 80500*Precision {
+		t.Errorf("BTC line: %+v", l)
+	}
+	// ETH 经反向报价定价
+	if l := byAsset["ETH"]; l.Price != 2500*Precision || l.Value != 5000*Precision {
+		t.Errorf("ETH line: %+v", l)
+	}
+	if len(v.Unpriced) != 1 || v.Unpriced[0] != "DOGE" {
+		t.Errorf("unpriced: %v", v.Unpriced)
+	}
+	// 余额 75000 + 1000 + 5000，保证金 500 + 5000，浮盈亏 −200 + 500
+	if v.BalanceValue != 81000*Precision || v.MarginValue != 5500*Precision || v.UnrealizedPnL != 300*Precision {
+		t.Errorf("breakdown: %d %d %d", v.BalanceValue, v.MarginValue, v.UnrealizedPnL)
+	}
+	if v.Total != 86800*Precision {
+		t.Errorf("total: %d", v.Total)
+	}
+
+	// USD 经 USDT 桥接
+	usd, err := svc.Valuate(context.Background(), 1, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usd.Total != 86800*99_900_000 {
+		t.Errorf("usd total: %d", usd.Total)
+	}
+
+	if _, err := svc.Valuate(context.Background(), 1, "EUR"); !errors.Is(err, ErrUnsupportedQuote) {
+		t.Errorf("expected ErrUnsupportedQuote, got %v", err)
+	}
+}
+
+func TestService_Cache(t *testing.T) {
+	balances := memBalances{1: {{Asset: "USDT", Available: 100 * Precision}}}
+	svc := NewService(Config{CacheTTL: time.Second}, balances, nil, prices)
+	now := time.UnixMilli(1_700_000_000_000)
+	svc.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	first, _ := svc.Valuate(ctx, 1, "USDT")
+	balances[1][0].Available = 200 * Precision
+	if v, _ := svc.Valuate(ctx, 1, "USDT"); v != first {
+		t.Error("expected cached result within TTL")
+	}
+	svc.Invalidate(1)
+	if v, _ := svc.Valuate(ctx, 1, "USDT"); v.Total != 200*Precision {
+		t.Errorf("after invalidate: %d", v.Total)
+	}
+
+	balances[1][0].Available = 300 * Precision
+	now = now.Add(time.Second)
+	if n := svc.Prune(); n != 1 {
+		t.Errorf("pruned %d", n)
+	}
+	if v, _ := svc.Valuate(ctx, 1, "USDT"); v.Total != 300*Precision {
+		t.Errorf("after expiry: %d", v.Total)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	balances := memBalances{
+		1: {{Asset: "USDT", Available: 100 * Precision}},
+		2: {{Asset: "BTC", Available: Precision}},
+	}
+	svc := NewService(Config{}, balances, nil, prices)
+
+	rec := httptest.NewRecorder()
+	NewHandler(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/account/valuation?user_id=2&quote=USD", nil))
+	var v Valuation
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("account: %d %v", rec.Code, err)
+	}
+	if v.UserID != 2 || v.Quote != "USD" || v.Total != 50000*99_900_000 {
+		t.Errorf("account valuation: %+v", v)
+	}
+
+	rec = httptest.NewRecorder()
+	NewHandler(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/account/valuation?user_id=1&quote=EUR", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported quote: %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewAdminHandler(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/valuation?user_ids=1,2", nil))
+	var ranked []Valuation
+	if err := json.NewDecoder(rec.Body).Decode(&ranked); err != nil {
+		t.Fatal(err)
+	}
+	if len(ranked) != 2 || ranked[0].UserID != 2 || ranked[1].UserID != 1 {
+		t.Errorf("ranked: %+v", ranked)
+	}
+
+	rec = httptest.NewRecorder()
+	NewAdminHandler(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/valuation?user_ids=1,x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad user_ids: %d", rec.Code)
+	}
+}
+
+func TestHandlers_PositionsUnavailable(t *testing.T) {
+	balances := memBalances{1: {{Asset: "USDT", Available: 100 * Precision}}}
+	svc := NewService(Config{}, balances, failingPositions{futures.ErrSymbolNotFound}, prices)
+
+	// 只算余额会少报资产：整单失败
+	if _, err := svc.Valuate(context.Background(), 1, ""); !errors.Is(err, ErrPositionsUnavailable) {
+		t.Fatalf("valuate: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	NewHandler(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/account/valuation?user_id=1", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("account: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,223 @@
+package walstore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Archiver - 归档 Store → 对象存储
+// =============================================================================
+
+// ArchiverConfig 归档配置
+type ArchiverConfig struct {
+	Source Store  // 已封存文件所在的归档 Store (写完不再修改的文件)
+	Remote Remote // 上传目标
+
+	Interval time.Duration // 扫描间隔，默认 30s
+
+	// KeepLocal 上传成功后本地保留最新的几个文件，0 表示全部保留；
+	// 清掉的文件恢复时由 Restore 按需拉回
+	KeepLocal int
+}
+
+func (c ArchiverConfig) withDefaults() ArchiverConfig {
+	if c.Interval <= 0 {
+		c.Interval = 30 * time.Second
+	}
+	return c
+}
+
+// ArchiverStats 归档进度
+type ArchiverStats struct {
+	Uploaded  uint64    // 累计上传的文件数
+	Failed    uint64    // 累计失败的上传次数
+	Pending   int       // 上一轮结束时还没上传的文件数
+	LastSync  time.Time // 上一轮成功扫完的时间
+	LastError string    // 上一轮的错误，成功时清空
+}
+
+// Archiver 后台把 Source 里的新文件上传到对象存储
+//
+// 按文件名升序上传，某个文件失败时本轮停止 (后面的文件下一轮再试)，
+// 保证对象存储里的段不会跳号
+type Archiver struct {
+	cfg ArchiverConfig
+
+	mu       sync.Mutex // 串行化 Sync
+	uploaded map[string]bool
+	listed   bool // 已列过远端 (启动后第一次 Sync 时列一次)
+
+	statsMu sync.Mutex
+	stats   ArchiverStats
+}
+
+// NewArchiver 创建归档器
+func NewArchiver(cfg ArchiverConfig) *Archiver {
+	return &Archiver{cfg: cfg.withDefaults(), uploaded: make(map[string]bool)}
+}
+
+// Run 每 Interval 同步一次，ctx 取消时做最后一轮后退出
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), a.cfg.Interval)
+			a.Sync(final)
+			cancel()
+			return
+		case <-ticker.C:
+			a.Sync(ctx)
+		}
+	}
+}
+
+// Sync 上传一轮，返回本轮上传的文件名
+func (a *Archiver) Sync(ctx context.Context) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	done, err := a.sync(ctx)
+	a.statsMu.Lock()
+	a.stats.Uploaded += uint64(len(done))
+	if err != nil {
+		a.stats.Failed++
+		a.stats.LastError = err.Error()
+		log.Printf("[WALArchive] %s: uploaded %d, error: %v", a.cfg.Remote.Prefix, len(done), err)
+	} else {
+		a.stats.LastSync = time.Now()
+		a.stats.LastError = ""
+	}
+	a.statsMu.Unlock()
+	return done, err
+}
+
+func (a *Archiver) sync(ctx context.Context) ([]string, error) {
+	if !a.listed {
+		keys, err := a.cfg.Remote.Objects.List(ctx, a.cfg.Remote.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			a.uploaded[strings.TrimPrefix(k, a.cfg.Remote.Prefix)] = true
+		}
+		a.listed = true
+	}
+
+	names, err := a.cfg.Source.List("")
+	if err != nil {
+		return nil, err
+	}
+	var done []string
+	pending := 0
+	var syncErr error
+	for _, name := range names {
+		if a.uploaded[name] || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if syncErr != nil {
+			pending++
+			continue
+		}
+		data, err := ReadFile(a.cfg.Source, name)
+		if err == nil {
+			err = a.cfg.Remote.Objects.Put(ctx, a.cfg.Remote.key(name), data)
+		}
+		if err != nil {
+			syncErr = err
+			pending++
+			continue
+		}
+		a.uploaded[name] = true
+		done = append(done, name)
+	}
+	a.statsMu.Lock()
+	a.stats.Pending = pending
+	a.statsMu.Unlock()
+	if syncErr != nil {
+		return done, syncErr
+	}
+	return done, a.prune(names)
+}
+
+// prune 只删已上传的文件，保留最新的 KeepLocal 个
+func (a *Archiver) prune(names []string) error {
+	if a.cfg.KeepLocal <= 0 || len(names) <= a.cfg.KeepLocal {
+		return nil
+	}
+	for _, name := range names[:len(names)-a.cfg.KeepLocal] {
+		if !a.uploaded[name] {
+			continue
+		}
+		if err := a.cfg.Source.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats 归档进度，可在任意 goroutine 调用
+func (a *Archiver) Stats() ArchiverStats {
+	a.statsMu.Lock()
+	defer a.statsMu.Unlock()
+	return a.stats
+}
+
+// =============================================================================
+// Restore - 对象存储 → 本地
+// =============================================================================
+
+// Restore 把对象存储里有、dst 里没有的归档文件拉回 dst，返回拉回的文件名
+//
+// pick 从远端全部文件名 (去掉前缀，升序) 里挑出恢复需要的那些，为 nil 时全部拉回
+func Restore(ctx context.Context, remote Remote, dst Store, pick func(names []string) []string) ([]string, error) {
+	keys, err := remote.Objects.List(ctx, remote.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, strings.TrimPrefix(k, remote.Prefix))
+	}
+	if pick != nil {
+		names = pick(names)
+	}
+
+	local, err := dst.List("")
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(local))
+	for _, name := range local {
+		have[name] = true
+	}
+
+	var restored []string
+	for _, name := range names {
+		if have[name] {
+			continue
+		}
+		data, err := remote.Objects.Get(ctx, remote.key(name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // 列举之后被删了 (生命周期策略)
+		}
+		if err != nil {
+			return restored, err
+		}
+		if err := WriteFile(dst, name, data); err != nil {
+			return restored, err
+		}
+		restored = append(restored, name)
+	}
+	if len(restored) > 0 {
+		log.Printf("[WALArchive] %s: restored %d file(s) from archive", remote.Prefix, len(restored))
+	}
+	return restored, nil
+}
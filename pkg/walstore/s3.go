@@ -0,0 +1,295 @@
+package walstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// 对象存储
+// =============================================================================
+
+// ObjectStore 归档用的对象存储，*S3 实现
+//
+// Get 对象不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	List(ctx context.Context, prefix string) ([]string, error) // 以 prefix 开头的 key，升序
+}
+
+// Remote 对象存储上的一个归档位置
+type Remote struct {
+	Objects ObjectStore
+	Prefix  string // key 前缀，如 "mtrade/BTC_USDT/"，同一存储桶里区分不同 WAL
+}
+
+func (r Remote) key(name string) string { return r.Prefix + name }
+
+// =============================================================================
+// S3 - S3 兼容对象存储 (AWS S3 / MinIO / 各云厂商兼容接口)
+// =============================================================================
+//
+// 只用到 PutObject / GetObject / ListObjectsV2，签名按 AWS Signature V4 自己实现，不引入 SDK。
+// 统一用 path-style 地址 (endpoint/bucket/key)：MinIO 和自建存储只支持这种，AWS 也仍兼容
+
+// S3Config S3 连接配置
+type S3Config struct {
+	Endpoint  string // 如 https://s3.ap-northeast-1.amazonaws.com、http://minio:9000
+	Region    string // 签名用的区域，默认 us-east-1 (MinIO 默认值)
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	Client *http.Client // 默认超时 30s
+}
+
+func (c S3Config) withDefaults() S3Config {
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	c.Endpoint = strings.TrimRight(c.Endpoint, "/")
+	return c
+}
+
+// S3 S3 兼容对象存储客户端
+type S3 struct {
+	cfg S3Config
+	now func() time.Time
+}
+
+var _ ObjectStore = (*S3)(nil)
+
+// NewS3 创建 S3 客户端 (不发请求，连不上在第一次读写时报错)
+func NewS3(cfg S3Config) (*S3, error) {
+	cfg = cfg.withDefaults()
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("walstore: s3 endpoint and bucket are required")
+	}
+	if _, err := url.Parse(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("walstore: s3 endpoint: %w", err)
+	}
+	return &S3{cfg: cfg, now: time.Now}, nil
+}
+
+// Put 上传对象 (覆盖同名对象)
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get 下载对象
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// listResult ListObjectsV2 响应里用到的字段
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 列举以 prefix 开头的 key (自动翻页)
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("walstore: s3 list: %w", err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// do 发送签名请求，非 2xx 转成错误 (404 包装 fs.ErrNotExist)
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := s.cfg.Endpoint + "/" + s.cfg.Bucket
+	if key != "" {
+		u += "/" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.ContentLength = int64(len(body))
+	signV4(req, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, sha256Hex(body), s.now().UTC())
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("walstore: s3 %s %s: %w", method, key, fs.ErrNotExist)
+	}
+	return nil, fmt.Errorf("walstore: s3 %s %s: %s %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// =============================================================================
+// AWS Signature V4
+// =============================================================================
+
+const (
+	amzDateFormat = "20060102T150405Z"
+	signAlgorithm = "AWS4-HMAC-SHA256"
+)
+
+// signV4 给请求加 x-amz-date / x-amz-content-sha256 / Authorization
+//
+// 参与签名的头：host、x-amz-*，以及请求里已有的 content-type / range
+func signV4(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{signAlgorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalURI 路径按段做 URI 编码 (S3 不做二次编码)
+func canonicalURI(u *url.URL) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery 参数按名字排序，名字和值都做 URI 编码
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 除 A-Z a-z 0-9 - _ . ~ 以外都编码成 %XX
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
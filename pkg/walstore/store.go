@@ -0,0 +1,165 @@
+// Package walstore WAL 段与检查点的存储抽象
+//
+// 【问题】撮合 (mtrade) 和资产 (asset) 两套 WAL 都直接读写本地文件：
+// 磁盘坏了，检查点和归档段跟着一起没；换机器恢复只能人工拷文件
+//
+// 【做法】
+//   - Store: 按文件名读写 (追加写活动段、新建、读取、改名、删除、列举)，默认 Local (本地目录)
+//   - 封存：活动段截断时改名进归档 Store，检查点写完后复制一份进去；归档里的文件写完不再改
+//   - Archiver: 后台把归档 Store 里的文件上传到对象存储 (S3 兼容，见 s3.go)，可选上传后只保留最近几个
+//   - Restore: 恢复前把本地缺失的归档文件从对象存储拉回来 (新机器 / 磁盘损坏)
+//
+// 【注意】活动段 (正在追加写的文件) 永远不上传：宕机时它只在本地，归档只兜底到最近一次截断
+package walstore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Segment 可写的段文件
+type Segment interface {
+	io.Writer
+	Sync() error
+	Close() error
+}
+
+// Store 段存储，name 为不含目录的文件名
+//
+// 文件不存在时 Open 返回的错误满足 errors.Is(err, fs.ErrNotExist)
+type Store interface {
+	Append(name string) (Segment, error)     // 追加写，不存在则创建
+	Create(name string) (Segment, error)     // 新建，已存在则清空
+	Open(name string) (io.ReadCloser, error) // 读取
+	Rename(oldName, newName string) error    // 改名 (同一 Store 内原子替换)
+	Remove(name string) error                // 删除，不存在不报错
+	List(prefix string) ([]string, error)    // 以 prefix 开头的文件名，升序
+}
+
+// =============================================================================
+// Local - 本地目录
+// =============================================================================
+
+// Local 本地目录存储 (默认实现)
+type Local struct {
+	dir string
+}
+
+var _ Store = (*Local)(nil)
+
+// NewLocal 以 dir 为根目录，目录不存在时创建
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+// Dir 根目录
+func (l *Local) Dir() string { return l.dir }
+
+// Path 文件的本地路径
+func (l *Local) Path(name string) string { return filepath.Join(l.dir, name) }
+
+func (l *Local) Append(name string) (Segment, error) {
+	return os.OpenFile(l.Path(name), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+}
+
+func (l *Local) Create(name string) (Segment, error) {
+	return os.Create(l.Path(name))
+}
+
+func (l *Local) Open(name string) (io.ReadCloser, error) {
+	return os.Open(l.Path(name))
+}
+
+func (l *Local) Rename(oldName, newName string) error {
+	return os.Rename(l.Path(oldName), l.Path(newName))
+}
+
+func (l *Local) Remove(name string) error {
+	if err := os.Remove(l.Path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) List(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names) // ReadDir 已按名字排序，这里只是把约定写死
+	return names, nil
+}
+
+// =============================================================================
+// 辅助
+// =============================================================================
+
+// ReadFile 读取整个文件
+func ReadFile(s Store, name string) ([]byte, error) {
+	r, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// WriteFile 原子写整个文件：先写临时文件、刷盘，再改名覆盖
+func WriteFile(s Store, name string, data []byte) error {
+	tmp := name + ".tmp"
+	seg, err := s.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := seg.Write(data); err != nil {
+		seg.Close()
+		return err
+	}
+	if err := seg.Sync(); err != nil {
+		seg.Close()
+		return err
+	}
+	if err := seg.Close(); err != nil {
+		return err
+	}
+	return s.Rename(tmp, name)
+}
+
+// Copy 把 src 的 name 复制到 dst 的 newName (原子写)
+func Copy(src Store, name string, dst Store, newName string) error {
+	data, err := ReadFile(src, name)
+	if err != nil {
+		return err
+	}
+	return WriteFile(dst, newName, data)
+}
+
+// Move 把 src 的 name 移到 dst 的 newName：都是本地目录时直接改名，否则复制后删除
+func Move(src Store, name string, dst Store, newName string) error {
+	if from, ok := src.(*Local); ok {
+		if to, ok := dst.(*Local); ok {
+			return os.Rename(from.Path(name), to.Path(newName))
+		}
+	}
+	if err := Copy(src, name, dst, newName); err != nil {
+		return err
+	}
+	return src.Remove(name)
+}
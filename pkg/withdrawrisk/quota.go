@@ -0,0 +1,289 @@
+package withdrawrisk
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fund"
+)
+
+// =============================================================================
+// Quota - 按 KYC 等级的提现日限额
+// =============================================================================
+//
+// 【和 VelocityRule 的区别】VelocityRule 是风控打分：超了挂起等人工复核，历史在进程内存里。
+// Quota 是硬限额：超了直接拒绝 (带明确错误码，前端能提示 "今日额度已用完")，
+// 用量来自 fund.FlowCounter (Redis，多实例共享，丢了从 journal 回填)
+//
+//	提现 ──→ 账户状态 ──→ Quota.Check ──→ 风控 Engine.Check ──→ 扣款 ──→ FlowCounter.Record
+//
+// 限额按 KYC 等级分档，取等级 <= 用户等级的最高一档；运营可给单个用户临时放宽 (SetOverride，
+// 走双人复核 approval.WithdrawQuotaOverrideOperation)，到期自动失效
+//
+// 【fail-closed】等级查询、用量查询失败都拒绝，返回可重试的 ErrQuotaUnavailable
+//
+// 【注意】
+//   - 用量在扣款成功后才记，检查和记录之间有窗口：同一用户并发提现可能一起通过，
+//     最多超出并发笔数的金额；热钱包按用户分片串行扣款，实际并发很低
+//   - 临时放宽只在内存里，进程重启后需要重新审批 (宁可丢，不可误放)
+
+var (
+	ErrQuotaAmountExceeded = cexerr.New("WITHDRAW_QUOTA_AMOUNT_EXCEEDED", cexerr.CategoryFailedPrecondition, "withdrawrisk: daily withdrawal amount quota exceeded")
+	ErrQuotaCountExceeded  = cexerr.New("WITHDRAW_QUOTA_COUNT_EXCEEDED", cexerr.CategoryFailedPrecondition, "withdrawrisk: daily withdrawal count quota exceeded")
+	ErrQuotaNoTier         = cexerr.New("WITHDRAW_QUOTA_NO_TIER", cexerr.CategoryFailedPrecondition, "withdrawrisk: no withdrawal quota for KYC level")
+	ErrQuotaUnavailable    = cexerr.NewRetryable("WITHDRAW_QUOTA_UNAVAILABLE", cexerr.CategoryUnavailable, "withdrawrisk: withdrawal quota unavailable")
+	ErrInvalidQuota        = cexerr.New("WITHDRAW_QUOTA_INVALID", cexerr.CategoryInvalidArgument, "withdrawrisk: invalid quota config")
+)
+
+// LevelProvider 查询用户 KYC 等级 (KYC 服务实现)，数字越大认证越完整
+type LevelProvider interface {
+	KYCLevel(ctx context.Context, userID int64) (int, error)
+}
+
+// QuotaLimits 滚动窗口内的提现上限
+type QuotaLimits struct {
+	MaxAmount map[string]int64 `json:"max_amount"` // asset → 累计金额上限 (含本笔)，未配置的资产不限
+	MaxCount  int              `json:"max_count"`  // 每个币种的笔数上限 (含本笔)，0 不限
+}
+
+// QuotaTier 一个 KYC 等级的限额
+type QuotaTier struct {
+	Level int
+	QuotaLimits
+}
+
+// Override 运营给单个用户的临时限额，配置了的字段替换档位的值
+type Override struct {
+	UserID     int64       `json:"user_id"`
+	Limits     QuotaLimits `json:"limits"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	OperatorID int64       `json:"operator_id"`
+	Reason     string      `json:"reason"`
+}
+
+// QuotaConfig 限额配置
+type QuotaConfig struct {
+	Tiers  []QuotaTier
+	Levels LevelProvider
+	Flows  fund.FlowCounter
+	Window time.Duration    // 仅用于错误信息，须与 Flows 的窗口一致，默认 24h
+	Now    func() time.Time // 默认 time.Now
+}
+
+// Quota 提现日限额，实现 Checker
+type Quota struct {
+	tiers  []QuotaTier // 按 Level 升序
+	levels LevelProvider
+	flows  fund.FlowCounter
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	overrides map[int64]Override
+}
+
+var _ Checker = (*Quota)(nil)
+
+// NewQuota 创建提现限额，档位等级重复或限额为负返回 ErrInvalidQuota
+func NewQuota(cfg QuotaConfig) (*Quota, error) {
+	if cfg.Levels == nil || cfg.Flows == nil {
+		return nil, ErrInvalidQuota.Wrapf("levels and flows are required")
+	}
+	tiers := slices.Clone(cfg.Tiers)
+	slices.SortFunc(tiers, func(a, b QuotaTier) int { return cmp.Compare(a.Level, b.Level) })
+	for i, t := range tiers {
+		if i > 0 && tiers[i-1].Level == t.Level {
+			return nil, ErrInvalidQuota.Wrapf("duplicate tier level %d", t.Level)
+		}
+		if err := t.validate(); err != nil {
+			return nil, ErrInvalidQuota.Wrapf("tier %d: %v", t.Level, err)
+		}
+	}
+	q := &Quota{
+		tiers:     tiers,
+		levels:    cfg.Levels,
+		flows:     cfg.Flows,
+		window:    cmp.Or(cfg.Window, 24*time.Hour),
+		now:       cfg.Now,
+		overrides: make(map[int64]Override),
+	}
+	if q.now == nil {
+		q.now = time.Now
+	}
+	return q, nil
+}
+
+func (l QuotaLimits) validate() error {
+	if l.MaxCount < 0 {
+		return fmt.Errorf("max_count %d < 0", l.MaxCount)
+	}
+	for asset, v := range l.MaxAmount {
+		if v < 0 {
+			return fmt.Errorf("%s max_amount %d < 0", asset, v)
+		}
+	}
+	return nil
+}
+
+// merge 用 o 里配置了的字段替换
+func (l QuotaLimits) merge(o QuotaLimits) QuotaLimits {
+	out := QuotaLimits{MaxAmount: maps.Clone(l.MaxAmount), MaxCount: l.MaxCount}
+	if out.MaxAmount == nil {
+		out.MaxAmount = make(map[string]int64, len(o.MaxAmount))
+	}
+	maps.Copy(out.MaxAmount, o.MaxAmount)
+	if o.MaxCount > 0 {
+		out.MaxCount = o.MaxCount
+	}
+	return out
+}
+
+// Check 实现 Checker
+func (q *Quota) Check(ctx context.Context, req Request) error {
+	st, err := q.Status(ctx, req.UserID, req.Asset)
+	if err != nil {
+		return err
+	}
+	if st.MaxCount > 0 && st.Used.Count+1 > int64(st.MaxCount) {
+		return ErrQuotaCountExceeded.Wrapf("%s: %d withdrawals within %s, limit %d", req.Asset, st.Used.Count+1, q.window, st.MaxCount)
+	}
+	if st.MaxAmount != nil && st.Used.Amount+req.Amount > *st.MaxAmount {
+		return ErrQuotaAmountExceeded.Wrapf("%s: used %d + %d within %s exceeds %d", req.Asset, st.Used.Amount, req.Amount, q.window, *st.MaxAmount)
+	}
+	return nil
+}
+
+// QuotaStatus 用户某币种的限额与用量 (客服 / 前端展示用)
+type QuotaStatus struct {
+	UserID    int64          `json:"user_id"`
+	Asset     string         `json:"asset"`
+	Level     int            `json:"level"`
+	MaxAmount *int64         `json:"max_amount,omitempty"` // nil 表示不限
+	MaxCount  int            `json:"max_count"`            // 0 表示不限
+	Used      fund.FlowUsage `json:"used"`
+	Override  *Override      `json:"override,omitempty"`
+}
+
+// Remaining 剩余可提金额，不限时 ok=false
+func (s QuotaStatus) Remaining() (amount int64, ok bool) {
+	if s.MaxAmount == nil {
+		return 0, false
+	}
+	return max(*s.MaxAmount-s.Used.Amount, 0), true
+}
+
+// Status 查询用户某币种当前适用的限额与窗口内用量
+func (q *Quota) Status(ctx context.Context, userID int64, asset string) (QuotaStatus, error) {
+	level, err := q.levels.KYCLevel(ctx, userID)
+	if err != nil {
+		return QuotaStatus{}, ErrQuotaUnavailable.Wrap(err)
+	}
+	tier, ok := q.tierFor(level)
+	if !ok {
+		return QuotaStatus{}, ErrQuotaNoTier.Wrapf("level %d", level)
+	}
+
+	st := QuotaStatus{UserID: userID, Asset: asset, Level: level}
+	limits := tier.QuotaLimits
+	if o, ok := q.override(userID); ok {
+		limits = limits.merge(o.Limits)
+		st.Override = &o
+	}
+	st.MaxCount = limits.MaxCount
+	if v, ok := limits.MaxAmount[asset]; ok {
+		st.MaxAmount = &v
+	}
+	if st.MaxAmount == nil && st.MaxCount == 0 {
+		return st, nil
+	}
+	if st.Used, err = q.flows.Usage(ctx, userID, asset, fund.BizTypeWithdraw, q.now()); err != nil {
+		return QuotaStatus{}, ErrQuotaUnavailable.Wrap(err)
+	}
+	return st, nil
+}
+
+// tierFor 等级 <= level 的最高一档
+func (q *Quota) tierFor(level int) (QuotaTier, bool) {
+	i, found := slices.BinarySearchFunc(q.tiers, level, func(t QuotaTier, level int) int { return cmp.Compare(t.Level, level) })
+	if found {
+		return q.tiers[i], true
+	}
+	if i == 0 {
+		return QuotaTier{}, false
+	}
+	return q.tiers[i-1], true
+}
+
+// =============================================================================
+// 临时放宽
+// =============================================================================
+
+// ValidateOverride 校验临时限额 (复核提议时先校验，执行时 SetOverride 再校验一次)
+func (q *Quota) ValidateOverride(o Override) error {
+	if o.UserID <= 0 {
+		return ErrInvalidQuota.Wrapf("user_id is required")
+	}
+	if !o.ExpiresAt.After(q.now()) {
+		return ErrInvalidQuota.Wrapf("override already expired at %s", o.ExpiresAt)
+	}
+	if len(o.Limits.MaxAmount) == 0 && o.Limits.MaxCount == 0 {
+		return ErrInvalidQuota.Wrapf("override sets no limit")
+	}
+	if err := o.Limits.validate(); err != nil {
+		return ErrInvalidQuota.Wrap(err)
+	}
+	return nil
+}
+
+// SetOverride 给用户设置临时限额 (覆盖之前的)，ExpiresAt 须在未来
+func (q *Quota) SetOverride(o Override) error {
+	if err := q.ValidateOverride(o); err != nil {
+		return err
+	}
+	o.Limits.MaxAmount = maps.Clone(o.Limits.MaxAmount)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.overrides[o.UserID] = o
+	return nil
+}
+
+// ClearOverride 撤销用户的临时限额 (收紧，不需要复核)
+func (q *Quota) ClearOverride(userID int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.overrides, userID)
+}
+
+// Overrides 生效中的临时限额，按用户排序
+func (q *Quota) Overrides() []Override {
+	now := q.now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Override, 0, len(q.overrides))
+	for id, o := range q.overrides {
+		if !o.ExpiresAt.After(now) {
+			delete(q.overrides, id)
+			continue
+		}
+		out = append(out, o)
+	}
+	slices.SortFunc(out, func(a, b Override) int { return cmp.Compare(a.UserID, b.UserID) })
+	return out
+}
+
+// override 用户生效中的临时限额
+func (q *Quota) override(userID int64) (Override, bool) {
+	q.mu.RLock()
+	o, ok := q.overrides[userID]
+	q.mu.RUnlock()
+	if !ok || !o.ExpiresAt.After(q.now()) {
+		return Override{}, false
+	}
+	return o, true
+}
@@ -0,0 +1,153 @@
+package withdrawrisk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"max.com/pkg/cexerr"
+	"max.com/pkg/fund"
+)
+
+type memLevels map[int64]int
+
+func (l memLevels) KYCLevel(ctx context.Context, userID int64) (int, error) {
+	level, ok := l[userID]
+	if !ok {
+		return 0, errors.New("kyc service down")
+	}
+	return level, nil
+}
+
+func newTestQuota(t *testing.T, now *time.Time) (*Quota, *fund.MemoryFlowCounter) {
+	t.Helper()
+	flows := fund.NewMemoryFlowCounter(fund.FlowConfig{})
+	q, err := NewQuota(QuotaConfig{
+		Tiers: []QuotaTier{
+			{Level: 2, QuotaLimits: QuotaLimits{MaxAmount: map[string]int64{"BTC": 100}, MaxCount: 10}},
+			{Level: 1, QuotaLimits: QuotaLimits{MaxAmount: map[string]int64{"BTC": 10}, MaxCount: 2}},
+		},
+		Levels: memLevels{1: 1, 2: 3, 3: 0},
+		Flows:  flows,
+		Now:    func() time.Time { return *now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q, flows
+}
+
+func TestQuota_TiersAndLimits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	q, flows := newTestQuota(t, &now)
+	withdraw := func(userID, amount int64) error {
+		err := q.Check(ctx, Request{UserID: userID, Asset: "BTC", Amount: amount})
+		if err == nil {
+			flows.Record(ctx, userID, "BTC", fund.BizTypeWithdraw, amount, now)
+		}
+		return err
+	}
+
+	// 等级 1：10 BTC / 2 笔
+	if err := withdraw(1, 6); err != nil {
+		t.Fatalf("first withdrawal: %v", err)
+	}
+	if err := withdraw(1, 5); !errors.Is(err, ErrQuotaAmountExceeded) {
+		t.Fatalf("expected amount exceeded, got %v", err)
+	}
+	if err := withdraw(1, 4); err != nil {
+		t.Fatalf("withdrawal within quota: %v", err)
+	}
+	if err := withdraw(1, 0); !errors.Is(err, ErrQuotaCountExceeded) {
+		t.Fatalf("expected count exceeded, got %v", err)
+	}
+
+	// 等级 3 没有单独的档位，取等级 2 的档位；未配置的资产只限笔数
+	if err := withdraw(2, 50); err != nil {
+		t.Fatalf("level 3 user: %v", err)
+	}
+	st, err := q.Status(ctx, 2, "ETH")
+	if err != nil || st.MaxAmount != nil || st.MaxCount != 10 {
+		t.Errorf("ETH status = %+v, err %v", st, err)
+	}
+
+	// 滚动窗口：24h 后额度恢复
+	now = now.Add(25 * time.Hour)
+	if err := withdraw(1, 10); err != nil {
+		t.Fatalf("after window: %v", err)
+	}
+	st, _ = q.Status(ctx, 1, "BTC")
+	if remaining, ok := st.Remaining(); !ok || remaining != 0 || st.Level != 1 {
+		t.Errorf("status = %+v, remaining %d", st, remaining)
+	}
+}
+
+func TestQuota_FailClosed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	q, _ := newTestQuota(t, &now)
+
+	err := q.Check(ctx, Request{UserID: 99, Asset: "BTC", Amount: 1})
+	if !errors.Is(err, ErrQuotaUnavailable) || !cexerr.IsRetryable(err) {
+		t.Errorf("level lookup failure: %v", err)
+	}
+	if err := q.Check(ctx, Request{UserID: 3, Asset: "BTC", Amount: 1}); !errors.Is(err, ErrQuotaNoTier) {
+		t.Errorf("level 0 has no tier: %v", err)
+	}
+}
+
+func TestQuota_Override(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	q, _ := newTestQuota(t, &now)
+
+	if err := q.SetOverride(Override{UserID: 1, Limits: QuotaLimits{MaxAmount: map[string]int64{"BTC": 50}}, ExpiresAt: now}); !errors.Is(err, ErrInvalidQuota) {
+		t.Fatalf("expired override accepted: %v", err)
+	}
+	if err := q.SetOverride(Override{UserID: 1, ExpiresAt: now.Add(time.Hour)}); !errors.Is(err, ErrInvalidQuota) {
+		t.Fatalf("empty override accepted: %v", err)
+	}
+
+	err := q.SetOverride(Override{
+		UserID:     1,
+		Limits:     QuotaLimits{MaxAmount: map[string]int64{"BTC": 50}},
+		ExpiresAt:  now.Add(time.Hour),
+		OperatorID: 7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Check(ctx, Request{UserID: 1, Asset: "BTC", Amount: 40}); err != nil {
+		t.Fatalf("override should allow 40 BTC: %v", err)
+	}
+	st, _ := q.Status(ctx, 1, "BTC")
+	if st.Override == nil || st.Override.OperatorID != 7 || st.MaxCount != 2 {
+		t.Errorf("status = %+v, want override with tier count kept", st)
+	}
+	if got := q.Overrides(); len(got) != 1 {
+		t.Errorf("Overrides = %+v", got)
+	}
+
+	// 到期自动失效
+	now = now.Add(2 * time.Hour)
+	if err := q.Check(ctx, Request{UserID: 1, Asset: "BTC", Amount: 40}); !errors.Is(err, ErrQuotaAmountExceeded) {
+		t.Fatalf("expired override still applied: %v", err)
+	}
+	if got := q.Overrides(); len(got) != 0 {
+		t.Errorf("expired override listed: %+v", got)
+	}
+}
+
+func TestNewQuota_Invalid(t *testing.T) {
+	flows := fund.NewMemoryFlowCounter(fund.FlowConfig{})
+	for name, tiers := range map[string][]QuotaTier{
+		"duplicate level": {{Level: 1}, {Level: 1}},
+		"negative amount": {{Level: 1, QuotaLimits: QuotaLimits{MaxAmount: map[string]int64{"BTC": -1}}}},
+	} {
+		if _, err := NewQuota(QuotaConfig{Tiers: tiers, Levels: memLevels{}, Flows: flows}); !errors.Is(err, ErrInvalidQuota) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
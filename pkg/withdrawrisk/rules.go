@@ -0,0 +1,173 @@
+package withdrawrisk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// LargeAmountRule - 大额人工复核
+// =============================================================================
+
+// LargeAmountRule 单笔金额达到阈值挂起人工复核
+type LargeAmountRule struct {
+	Thresholds map[string]int64 // asset → 阈值，未配置的资产不检查
+	Score      int
+}
+
+func (r *LargeAmountRule) Name() string { return "large_amount" }
+
+// Evaluate 实现 Rule
+func (r *LargeAmountRule) Evaluate(ctx context.Context, req Request) (Assessment, error) {
+	limit, ok := r.Thresholds[req.Asset]
+	if !ok || limit <= 0 || req.Amount < limit {
+		return Assessment{}, nil
+	}
+	return Assessment{
+		Decision: DecisionHold,
+		Score:    r.Score,
+		Reasons:  []string{fmt.Sprintf("amount %d >= %d", req.Amount, limit)},
+	}, nil
+}
+
+// =============================================================================
+// VelocityRule - 提现频率
+// =============================================================================
+
+// VelocityRule 滑动窗口内的提现笔数 / 金额
+//
+// 只统计最终放行的提现 (Observer)，被拦下的不计入，避免用户重试把自己越锁越死
+//
+// 【注意】历史在内存里，多实例部署时每个实例各算各的；
+// 重启后窗口清空，窗口通常是小时级，可以接受
+type VelocityRule struct {
+	Window    time.Duration
+	MaxCount  int              // 窗口内最多笔数，0 不限
+	MaxAmount map[string]int64 // asset → 窗口内累计金额上限 (含本笔)，未配置不限
+	Score     int
+	Now       func() time.Time // 为空使用 time.Now
+
+	mu      sync.Mutex
+	history map[int64][]Request // userID → 窗口内已放行的提现
+}
+
+func (r *VelocityRule) Name() string { return "velocity" }
+
+// Evaluate 实现 Rule
+func (r *VelocityRule) Evaluate(ctx context.Context, req Request) (Assessment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recent := r.prune(req.UserID)
+
+	var reasons []string
+	if r.MaxCount > 0 && len(recent)+1 > r.MaxCount {
+		reasons = append(reasons, fmt.Sprintf("%d withdrawals within %s", len(recent)+1, r.Window))
+	}
+	if limit, ok := r.MaxAmount[req.Asset]; ok && limit > 0 {
+		total := req.Amount
+		for _, h := range recent {
+			if h.Asset == req.Asset {
+				total += h.Amount
+			}
+		}
+		if total > limit {
+			reasons = append(reasons, fmt.Sprintf("%s total %d within %s exceeds %d", req.Asset, total, r.Window, limit))
+		}
+	}
+	if len(reasons) == 0 {
+		return Assessment{}, nil
+	}
+	return Assessment{Decision: DecisionHold, Score: r.Score, Reasons: reasons}, nil
+}
+
+// Approved 实现 Observer
+func (r *VelocityRule) Approved(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.history == nil {
+		r.history = make(map[int64][]Request)
+	}
+	// 按放行时间计窗口：人工复核通过的提现从通过时刻算起
+	req.Timestamp = r.now().UnixMilli()
+	r.history[req.UserID] = append(r.prune(req.UserID), req)
+}
+
+// prune 丢掉窗口外的记录，返回窗口内的
+func (r *VelocityRule) prune(userID int64) []Request {
+	list := r.history[userID]
+	cutoff := r.now().Add(-r.Window).UnixMilli()
+	i := 0
+	for i < len(list) && list[i].Timestamp <= cutoff {
+		i++
+	}
+	list = list[i:]
+	if len(list) == 0 {
+		delete(r.history, userID)
+		return nil
+	}
+	r.history[userID] = list
+	return list
+}
+
+func (r *VelocityRule) setClock(now func() time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Now = now
+}
+
+func (r *VelocityRule) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// =============================================================================
+// NewAddressRule - 新地址
+// =============================================================================
+
+// AddressBook 用户提现地址簿 (地址白名单 / 历史提现地址)
+type AddressBook interface {
+	// FirstSeen 地址首次添加 / 使用的时间 (毫秒)，从未出现过返回 ok=false
+	FirstSeen(ctx context.Context, userID int64, address string) (at int64, ok bool, err error)
+}
+
+// NewAddressRule 首次使用或刚添加不久的地址挂起人工复核
+//
+// 【面试】为什么新地址要冷却？
+// 账号被盗后最常见的操作就是加一个新地址把钱提走，
+// 冷却期给了真实用户收到通知、冻结账号的时间
+type NewAddressRule struct {
+	Book     AddressBook
+	Cooldown time.Duration // 地址添加后多久才算"老地址"，0 表示只拦从未出现过的地址
+	Score    int
+	Now      func() time.Time // 为空使用 time.Now
+}
+
+func (r *NewAddressRule) Name() string { return "new_address" }
+
+// Evaluate 实现 Rule
+func (r *NewAddressRule) Evaluate(ctx context.Context, req Request) (Assessment, error) {
+	if req.Address == "" || r.Book == nil {
+		return Assessment{}, nil
+	}
+	at, ok, err := r.Book.FirstSeen(ctx, req.UserID, req.Address)
+	if err != nil {
+		return Assessment{}, err
+	}
+	if !ok {
+		return Assessment{Decision: DecisionHold, Score: r.Score, Reasons: []string{"first withdrawal to " + req.Address}}, nil
+	}
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	if r.Cooldown > 0 && now().UnixMilli()-at < r.Cooldown.Milliseconds() {
+		return Assessment{Decision: DecisionHold, Score: r.Score, Reasons: []string{"address added within " + r.Cooldown.String()}}, nil
+	}
+	return Assessment{}, nil
+}
+
+func (r *NewAddressRule) setClock(now func() time.Time) { r.Now = now }
@@ -0,0 +1,224 @@
+package withdrawrisk
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Record 持久化的风控决策 (一笔提现一条)
+type Record struct {
+	EventID    string   `gorm:"column:event_id;primaryKey"`
+	UserID     int64    `gorm:"column:user_id"`
+	Asset      string   `gorm:"column:asset"`
+	Amount     int64    `gorm:"column:amount"`
+	Address    string   `gorm:"column:address"`
+	Decision   Decision `gorm:"column:decision"`
+	Score      int      `gorm:"column:score"`
+	Reasons    string   `gorm:"column:reasons"`
+	Reviewer   string   `gorm:"column:reviewer"` // 人工复核人，自动决策为空
+	CreatedAt  int64    `gorm:"column:created_at"`
+	UpdatedAt  int64    `gorm:"column:updated_at"`
+	ReviewedAt int64    `gorm:"column:reviewed_at"`
+}
+
+func (Record) TableName() string { return "withdraw_risk_decision" }
+
+// request 还原提现请求 (复核通过后通知规则)
+func (r *Record) request() Request {
+	return Request{
+		EventID:   r.EventID,
+		UserID:    r.UserID,
+		Asset:     r.Asset,
+		Amount:    r.Amount,
+		Address:   r.Address,
+		Timestamp: r.CreatedAt,
+	}
+}
+
+// verdict 已有决策对重试请求的结论：请求与落库的不一致时返回 ErrRequestMismatch
+//
+// 错误详情会返回给调用方，只列出不一致的字段，不带落库的值
+func (r *Record) verdict(req Request) error {
+	var diff []string
+	if r.UserID != req.UserID {
+		diff = append(diff, "user_id")
+	}
+	if r.Asset != req.Asset {
+		diff = append(diff, "asset")
+	}
+	if r.Amount != req.Amount {
+		diff = append(diff, "amount")
+	}
+	if r.Address != req.Address {
+		diff = append(diff, "address")
+	}
+	if len(diff) > 0 {
+		return ErrRequestMismatch.Wrapf("event %s: %s", r.EventID, strings.Join(diff, ", "))
+	}
+	return r.Decision.err()
+}
+
+// =============================================================================
+// 存储接口
+// =============================================================================
+
+// Store 决策存储
+type Store interface {
+	// Create 写入决策，EventID 已存在返回 ErrDuplicateEvent
+	Create(ctx context.Context, rec *Record) error
+	// Get 按 EventID 查询，不存在返回 ErrDecisionNotFound
+	Get(ctx context.Context, eventID string) (*Record, error)
+	// Resolve 人工复核：HOLD → decision，非 HOLD 返回 ErrNotHeld
+	Resolve(ctx context.Context, eventID string, decision Decision, reviewer string, at int64) error
+	// ListHeld 待复核列表，按创建时间升序
+	ListHeld(ctx context.Context, limit int) ([]Record, error)
+}
+
+// =============================================================================
+// MemoryStore
+// =============================================================================
+
+// MemoryStore 内存存储 (测试、单机部署)
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]*Record
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+// Create 实现 Store
+func (s *MemoryStore) Create(ctx context.Context, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[rec.EventID]; ok {
+		return ErrDuplicateEvent
+	}
+	r := *rec
+	s.records[rec.EventID] = &r
+	return nil
+}
+
+// Get 实现 Store
+func (s *MemoryStore) Get(ctx context.Context, eventID string) (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[eventID]
+	if !ok {
+		return nil, ErrDecisionNotFound
+	}
+	out := *r
+	return &out, nil
+}
+
+// Resolve 实现 Store
+func (s *MemoryStore) Resolve(ctx context.Context, eventID string, decision Decision, reviewer string, at int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[eventID]
+	if !ok {
+		return ErrDecisionNotFound
+	}
+	if r.Decision != DecisionHold {
+		return ErrNotHeld
+	}
+	r.Decision = decision
+	r.Reviewer = reviewer
+	r.ReviewedAt = at
+	r.UpdatedAt = at
+	return nil
+}
+
+// ListHeld 实现 Store
+func (s *MemoryStore) ListHeld(ctx context.Context, limit int) ([]Record, error) {
+	s.mu.RLock()
+	var out []Record
+	for _, r := range s.records {
+		if r.Decision == DecisionHold {
+			out = append(out, *r)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// =============================================================================
+// GormStore - MySQL 实现
+// =============================================================================
+
+// GormStore MySQL 存储 (表结构见 withdrawrisk.sql)
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建 MySQL 存储
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Create 实现 Store
+func (s *GormStore) Create(ctx context.Context, rec *Record) error {
+	err := s.db.WithContext(ctx).Create(rec).Error
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrDuplicateEvent
+	}
+	return err
+}
+
+// Get 实现 Store
+func (s *GormStore) Get(ctx context.Context, eventID string) (*Record, error) {
+	var r Record
+	err := s.db.WithContext(ctx).Where("event_id = ?", eventID).Take(&r).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Resolve 实现 Store
+//
+// 条件更新 decision = HOLD，两个复核人同时操作只有一个生效
+func (s *GormStore) Resolve(ctx context.Context, eventID string, decision Decision, reviewer string, at int64) error {
+	res := s.db.WithContext(ctx).Model(&Record{}).
+		Where("event_id = ? AND decision = ?", eventID, DecisionHold).
+		Updates(map[string]any{
+			"decision":    decision,
+			"reviewer":    reviewer,
+			"reviewed_at": at,
+			"updated_at":  at,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := s.Get(ctx, eventID); err != nil {
+			return err
+		}
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// ListHeld 实现 Store
+func (s *GormStore) ListHeld(ctx context.Context, limit int) ([]Record, error) {
+	var out []Record
+	q := s.db.WithContext(ctx).Where("decision = ?", DecisionHold).Order("created_at ASC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Find(&out).Error
+	return out, err
+}
@@ -0,0 +1,300 @@
+// Package withdrawrisk 提现风控评分：热钱包扣款前的合规卡口
+//
+// 资金服务确认提现后，热钱包 ApplyBalanceChange(WITHDRAW) 扣款前先过这里：
+//
+//	提现请求 ──→ Engine.Check ──→ 已有决策？── 是 ──→ 按原决策返回 (幂等，人工复核结果生效)
+//	                 │ 否
+//	                 ↓
+//	           逐条规则打分 (频率、新地址、大额 ...)
+//	                 ↓
+//	           汇总决策 → 落库 ──→ APPROVE: 放行扣款
+//	                              HOLD:    返回 ErrHeld，等人工复核 (Review) 后重试
+//	                              DENY:    返回 ErrDenied
+//
+// 【fail-closed】规则报错、决策落库失败都不放行：合规卡口宁可误拦，不能漏放
+//
+// 【面试】为什么要先落库再放行？
+// 事后审计要能回答"这笔钱当时为什么放出去"；先扣款后记录，进程在中间挂掉就丢了依据。
+// 同一提现 (EventID) 重试时直接读已有决策，不会因为规则状态变化 (如频率窗口滑动) 前后结论不一致
+package withdrawrisk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"max.com/pkg/cexerr"
+)
+
+// =============================================================================
+// 错误定义
+// =============================================================================
+
+var (
+	ErrDenied           = cexerr.New("WITHDRAW_RISK_DENIED", cexerr.CategoryFailedPrecondition, "withdrawrisk: withdrawal denied")
+	ErrHeld             = cexerr.New("WITHDRAW_RISK_HELD", cexerr.CategoryFailedPrecondition, "withdrawrisk: withdrawal held for manual review")
+	ErrDecisionNotFound = cexerr.New("WITHDRAW_RISK_DECISION_NOT_FOUND", cexerr.CategoryNotFound, "withdrawrisk: decision not found")
+	ErrNotHeld          = cexerr.New("WITHDRAW_RISK_NOT_HELD", cexerr.CategoryConflict, "withdrawrisk: decision is not on hold")
+	ErrDuplicateEvent   = cexerr.New("WITHDRAW_RISK_DUPLICATE_EVENT", cexerr.CategoryConflict, "withdrawrisk: decision already exists")
+	ErrRequestMismatch  = cexerr.New("WITHDRAW_RISK_REQUEST_MISMATCH", cexerr.CategoryConflict, "withdrawrisk: event id reused with different withdrawal")
+)
+
+// =============================================================================
+// 决策
+// =============================================================================
+
+// Decision 风控结论，数值越大越严格
+type Decision int8
+
+const (
+	DecisionApprove Decision = iota + 1 // 放行
+	DecisionHold                        // 挂起，人工复核
+	DecisionDeny                        // 拒绝
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionApprove:
+		return "APPROVE"
+	case DecisionHold:
+		return "HOLD"
+	case DecisionDeny:
+		return "DENY"
+	}
+	return "UNKNOWN"
+}
+
+// err 决策对应的返回值
+func (d Decision) err() error {
+	switch d {
+	case DecisionApprove:
+		return nil
+	case DecisionHold:
+		return ErrHeld
+	}
+	return ErrDenied
+}
+
+// Request 一笔待扣款的提现
+type Request struct {
+	EventID   string // 提现单号 (与热钱包命令幂等键相同)
+	UserID    int64
+	Asset     string
+	Amount    int64
+	Address   string // 提现地址，内部转账等场景可为空
+	Timestamp int64  // 毫秒
+}
+
+// Assessment 评分结果
+type Assessment struct {
+	Decision Decision
+	Score    int
+	Reasons  []string
+}
+
+// =============================================================================
+// 扩展点
+// =============================================================================
+
+// Rule 一条风控规则
+//
+// 只需要给出分数和原因；Decision 为 0 表示本规则不单独下结论，交给总分阈值
+type Rule interface {
+	Name() string
+	Evaluate(ctx context.Context, req Request) (Assessment, error)
+}
+
+// Observer 需要知道哪些提现最终放行的规则 (如频率统计) 实现此接口
+type Observer interface {
+	Approved(req Request)
+}
+
+// clockedRule 按时间窗口判断的规则，时钟跟随 Engine.SetClock
+type clockedRule interface {
+	setClock(now func() time.Time)
+}
+
+// Checker 扣款前的检查入口，nil 表示放行 (热钱包引擎依赖这个接口)
+type Checker interface {
+	Check(ctx context.Context, req Request) error
+}
+
+var _ Checker = (*Engine)(nil)
+
+// DecisionEvent 决策落库 / 人工复核完成 (用户通知用)
+type DecisionEvent struct {
+	Request  Request
+	Decision Decision
+	Reviewed bool   // true 表示人工复核的结论
+	Reviewer string // 复核人，Reviewed 时有效
+	At       int64  // 毫秒
+}
+
+// =============================================================================
+// Engine
+// =============================================================================
+
+// Engine 规则编排 + 决策持久化
+type Engine struct {
+	store Store
+	rules []Rule
+	now   func() time.Time
+
+	// 总分阈值，0 表示不按总分判定
+	holdScore int
+	denyScore int
+
+	mu sync.Mutex // 串行化同一进程内的评估，避免频率类规则并发漏计
+
+	onDecision []func(DecisionEvent)
+}
+
+// NewEngine 创建风控引擎
+func NewEngine(store Store, rules ...Rule) *Engine {
+	return &Engine{store: store, rules: rules, now: time.Now}
+}
+
+// SetScoreThresholds 总分达到 hold / deny 时挂起 / 拒绝，0 表示不启用
+func (e *Engine) SetScoreThresholds(hold, deny int) {
+	e.holdScore = hold
+	e.denyScore = deny
+}
+
+// SetClock 替换时钟：决策落库 / 复核时间取自它，并同步给带时间窗口的规则
+// (VelocityRule 的频率窗口、NewAddressRule 的冷却期)，覆盖规则自己的 Now
+func (e *Engine) SetClock(now func() time.Time) {
+	e.now = now
+	for _, r := range e.rules {
+		if c, ok := r.(clockedRule); ok {
+			c.setClock(now)
+		}
+	}
+}
+
+// OnDecision 注册决策回调，须在使用前调用
+//
+// 只在新决策落库、人工复核完成时回调，重试命中已有决策不回调；
+// 回调在 Check / Review 的锁内同步执行，必须很快返回
+func (e *Engine) OnDecision(fn func(DecisionEvent)) {
+	e.onDecision = append(e.onDecision, fn)
+}
+
+// Check 实现 Checker
+func (e *Engine) Check(ctx context.Context, req Request) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// 1. 已有决策：重试 / 人工复核后的再次提交
+	//    决策只对落库时那笔提现有效，同一单号换了用户 / 金额 / 地址不能沿用
+	rec, err := e.store.Get(ctx, req.EventID)
+	if err == nil {
+		return rec.verdict(req)
+	}
+	if !errors.Is(err, ErrDecisionNotFound) {
+		return err
+	}
+
+	// 2. 评分
+	a, err := e.Assess(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// 3. 先落库再放行
+	now := e.now().UnixMilli()
+	rec = &Record{
+		EventID:   req.EventID,
+		UserID:    req.UserID,
+		Asset:     req.Asset,
+		Amount:    req.Amount,
+		Address:   req.Address,
+		Decision:  a.Decision,
+		Score:     a.Score,
+		Reasons:   strings.Join(a.Reasons, "; "),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.store.Create(ctx, rec); err != nil {
+		if errors.Is(err, ErrDuplicateEvent) {
+			// 其他实例抢先落库，以它的结论为准
+			if rec, err = e.store.Get(ctx, req.EventID); err == nil {
+				return rec.verdict(req)
+			}
+		}
+		return err
+	}
+
+	if a.Decision == DecisionApprove {
+		e.notifyApproved(req)
+	}
+	e.emitDecision(DecisionEvent{Request: req, Decision: a.Decision, At: now})
+	return a.Decision.err()
+}
+
+// Assess 逐条规则评分并汇总 (只读，不落库)
+//
+// 结论取各规则结论里最严格的，再和总分阈值比较取更严格的
+func (e *Engine) Assess(ctx context.Context, req Request) (Assessment, error) {
+	out := Assessment{Decision: DecisionApprove}
+	for _, r := range e.rules {
+		a, err := r.Evaluate(ctx, req)
+		if err != nil {
+			return Assessment{}, fmt.Errorf("withdrawrisk: rule %s: %w", r.Name(), err)
+		}
+		out.Score += a.Score
+		out.Decision = max(out.Decision, a.Decision)
+		for _, reason := range a.Reasons {
+			out.Reasons = append(out.Reasons, r.Name()+": "+reason)
+		}
+	}
+	switch {
+	case e.denyScore > 0 && out.Score >= e.denyScore:
+		out.Decision = DecisionDeny
+	case e.holdScore > 0 && out.Score >= e.holdScore:
+		out.Decision = max(out.Decision, DecisionHold)
+	}
+	return out, nil
+}
+
+// Review 人工复核挂起的提现
+//
+// 只能处理 HOLD；复核通过后资金服务重新提交同一 EventID 即可扣款
+func (e *Engine) Review(ctx context.Context, eventID string, approve bool, reviewer string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rec, err := e.store.Get(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	decision := DecisionDeny
+	if approve {
+		decision = DecisionApprove
+	}
+	now := e.now().UnixMilli()
+	if err := e.store.Resolve(ctx, eventID, decision, reviewer, now); err != nil {
+		return err
+	}
+	if approve {
+		e.notifyApproved(rec.request())
+	}
+	e.emitDecision(DecisionEvent{Request: rec.request(), Decision: decision, Reviewed: true, Reviewer: reviewer, At: now})
+	return nil
+}
+
+func (e *Engine) emitDecision(ev DecisionEvent) {
+	for _, fn := range e.onDecision {
+		fn(ev)
+	}
+}
+
+func (e *Engine) notifyApproved(req Request) {
+	for _, r := range e.rules {
+		if o, ok := r.(Observer); ok {
+			o.Approved(req)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+-- 提现风控决策表
+-- 每笔提现 (event_id) 一条，扣款前写入；HOLD 由人工复核改为 APPROVE / DENY
+
+CREATE TABLE IF NOT EXISTS `withdraw_risk_decision` (
+    `event_id` VARCHAR(64) NOT NULL PRIMARY KEY COMMENT '提现单号，与热钱包命令幂等键相同',
+    `user_id` BIGINT NOT NULL,
+    `asset` VARCHAR(16) NOT NULL,
+    `amount` BIGINT NOT NULL,
+    `address` VARCHAR(128) NOT NULL DEFAULT '',
+    `decision` TINYINT NOT NULL COMMENT '1=APPROVE,2=HOLD,3=DENY',
+    `score` INT NOT NULL DEFAULT 0,
+    `reasons` VARCHAR(1024) NOT NULL DEFAULT '',
+    `reviewer` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '人工复核人，自动决策为空',
+    `created_at` BIGINT NOT NULL COMMENT '毫秒',
+    `updated_at` BIGINT NOT NULL COMMENT '毫秒',
+    `reviewed_at` BIGINT NOT NULL DEFAULT 0 COMMENT '毫秒，0=未复核',
+    KEY `idx_user` (`user_id`, `created_at`),
+    KEY `idx_decision` (`decision`, `created_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '提现风控决策';
@@ -0,0 +1,250 @@
+package withdrawrisk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memBook map[string]int64
+
+func (b memBook) FirstSeen(ctx context.Context, userID int64, address string) (int64, bool, error) {
+	at, ok := b[address]
+	return at, ok, nil
+}
+
+type failRule struct{}
+
+func (failRule) Name() string { return "fail" }
+func (failRule) Evaluate(ctx context.Context, req Request) (Assessment, error) {
+	return Assessment{}, errors.New("backend down")
+}
+
+func TestEngine_LargeAmountHoldAndReview(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	e := NewEngine(store, &LargeAmountRule{Thresholds: map[string]int64{"BTC": 10}, Score: 50})
+	var events []DecisionEvent
+	e.OnDecision(func(ev DecisionEvent) { events = append(events, ev) })
+
+	if err := e.Check(ctx, Request{EventID: "w1", UserID: 1, Asset: "BTC", Amount: 5}); err != nil {
+		t.Fatalf("small withdrawal: %v", err)
+	}
+	if err := e.Check(ctx, Request{EventID: "w2", UserID: 1, Asset: "BTC", Amount: 10}); !errors.Is(err, ErrHeld) {
+		t.Fatalf("expected ErrHeld, got %v", err)
+	}
+
+	held, _ := store.ListHeld(ctx, 0)
+	if len(held) != 1 || held[0].EventID != "w2" || held[0].Score != 50 || !strings.Contains(held[0].Reasons, "large_amount") {
+		t.Fatalf("unexpected held list %+v", held)
+	}
+
+	// 重试仍然挂起，复核通过后放行
+	if err := e.Check(ctx, Request{EventID: "w2", UserID: 1, Asset: "BTC", Amount: 10}); !errors.Is(err, ErrHeld) {
+		t.Fatalf("retry before review: %v", err)
+	}
+	if err := e.Review(ctx, "w2", true, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Check(ctx, Request{EventID: "w2", UserID: 1, Asset: "BTC", Amount: 10}); err != nil {
+		t.Fatalf("retry after approve: %v", err)
+	}
+	if err := e.Review(ctx, "w2", false, "bob"); !errors.Is(err, ErrNotHeld) {
+		t.Fatalf("second review must fail, got %v", err)
+	}
+	rec, _ := store.Get(ctx, "w2")
+	if rec.Decision != DecisionApprove || rec.Reviewer != "alice" {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+	if err := e.Review(ctx, "missing", true, "alice"); !errors.Is(err, ErrDecisionNotFound) {
+		t.Fatalf("expected ErrDecisionNotFound, got %v", err)
+	}
+
+	// 新决策和复核结论各回调一次，重试不回调
+	if len(events) != 3 || events[1].Decision != DecisionHold || !events[2].Reviewed ||
+		events[2].Decision != DecisionApprove || events[2].Request.UserID != 1 {
+		t.Fatalf("unexpected decision events %+v", events)
+	}
+}
+
+func TestEngine_RetryMustMatchDecision(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	e := NewEngine(store, &LargeAmountRule{Thresholds: map[string]int64{"BTC": 10}, Score: 100})
+	var events []DecisionEvent
+	e.OnDecision(func(ev DecisionEvent) { events = append(events, ev) })
+
+	req := Request{EventID: "w1", UserID: 1, Asset: "BTC", Amount: 5, Address: "bc1-a"}
+	if err := e.Check(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一单号换成大额 / 别的地址 / 别的用户 / 别的币种：不能沿用小额的放行结论
+	for _, tc := range []struct {
+		name   string
+		mutate func(*Request)
+	}{
+		{"amount", func(r *Request) { r.Amount = 1000 }},
+		{"address", func(r *Request) { r.Address = "bc1-b" }},
+		{"user", func(r *Request) { r.UserID = 2 }},
+		{"asset", func(r *Request) { r.Asset = "ETH" }},
+	} {
+		r := req
+		tc.mutate(&r)
+		err := e.Check(ctx, r)
+		if !errors.Is(err, ErrRequestMismatch) || !strings.Contains(err.Error(), tc.name) {
+			t.Errorf("%s changed: %v", tc.name, err)
+		}
+	}
+
+	// 原样重试仍然放行，落库的决策没被改写、也没有新的回调
+	if err := e.Check(ctx, req); err != nil {
+		t.Fatalf("identical retry: %v", err)
+	}
+	if rec, _ := store.Get(ctx, "w1"); rec.Amount != 5 || rec.Decision != DecisionApprove {
+		t.Fatalf("record changed: %+v", rec)
+	}
+	if len(events) != 1 {
+		t.Fatalf("decision events %+v", events)
+	}
+}
+
+func TestVelocityRule(t *testing.T) {
+	ctx := context.Background()
+	now := time.UnixMilli(1_000_000)
+	store := NewMemoryStore()
+	e := NewEngine(store, &VelocityRule{
+		Window:    time.Hour,
+		MaxCount:  2,
+		MaxAmount: map[string]int64{"USDT": 1000},
+	})
+	e.SetClock(func() time.Time { return now }) // 规则的频率窗口跟随引擎时钟
+
+	for i, amount := range []int64{400, 500} {
+		req := Request{EventID: string(rune('a' + i)), UserID: 1, Asset: "USDT", Amount: amount}
+		if err := e.Check(ctx, req); err != nil {
+			t.Fatalf("withdrawal %d: %v", i, err)
+		}
+	}
+	// 第 3 笔超过笔数
+	if err := e.Check(ctx, Request{EventID: "c", UserID: 1, Asset: "USDT", Amount: 1}); !errors.Is(err, ErrHeld) {
+		t.Fatalf("expected count hold, got %v", err)
+	}
+	// 其他用户不受影响
+	if err := e.Check(ctx, Request{EventID: "d", UserID: 2, Asset: "USDT", Amount: 1}); err != nil {
+		t.Fatalf("other user: %v", err)
+	}
+
+	// 窗口边界前 1ms 仍在窗口内
+	now = now.Add(time.Hour - time.Millisecond)
+	if err := e.Check(ctx, Request{EventID: "c2", UserID: 1, Asset: "USDT", Amount: 1}); !errors.Is(err, ErrHeld) {
+		t.Fatalf("expected count hold just inside window, got %v", err)
+	}
+
+	// 窗口滑过后重新计数；被挂起的 c 不计入
+	now = now.Add(time.Millisecond)
+	if err := e.Check(ctx, Request{EventID: "e", UserID: 1, Asset: "USDT", Amount: 1001}); !errors.Is(err, ErrHeld) {
+		t.Fatalf("expected amount hold, got %v", err)
+	}
+	if err := e.Check(ctx, Request{EventID: "f", UserID: 1, Asset: "USDT", Amount: 1000}); err != nil {
+		t.Fatalf("after window: %v", err)
+	}
+	if rec, _ := store.Get(ctx, "f"); rec.CreatedAt != now.UnixMilli() {
+		t.Fatalf("decision time %d, want %d", rec.CreatedAt, now.UnixMilli())
+	}
+
+	// 人工复核通过的提现从通过时刻起计入窗口
+	now = now.Add(30 * time.Minute)
+	if err := e.Review(ctx, "e", true, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := store.Get(ctx, "e"); rec.ReviewedAt != now.UnixMilli() {
+		t.Fatalf("review time %d, want %d", rec.ReviewedAt, now.UnixMilli())
+	}
+	now = now.Add(31 * time.Minute) // f 已滑出窗口，e 还在
+	if err := e.Check(ctx, Request{EventID: "g", UserID: 1, Asset: "USDT", Amount: 1}); !errors.Is(err, ErrHeld) {
+		t.Fatalf("reviewed withdrawal should count from approval, got %v", err)
+	}
+	now = now.Add(30 * time.Minute)
+	if err := e.Check(ctx, Request{EventID: "h", UserID: 1, Asset: "USDT", Amount: 1}); err != nil {
+		t.Fatalf("after reviewed withdrawal left window: %v", err)
+	}
+}
+
+func TestEngine_NewAddressCooldown(t *testing.T) {
+	ctx := context.Background()
+	added := time.UnixMilli(100 * time.Hour.Milliseconds())
+	now := added
+	e := NewEngine(NewMemoryStore(), &NewAddressRule{Book: memBook{"addr": added.UnixMilli()}, Cooldown: 24 * time.Hour})
+	e.SetClock(func() time.Time { return now })
+
+	for _, tc := range []struct {
+		eventID string
+		after   time.Duration
+		want    error
+	}{
+		{"w1", 0, ErrHeld},
+		{"w2", 24*time.Hour - time.Millisecond, ErrHeld},
+		{"w3", 24 * time.Hour, nil},
+	} {
+		now = added.Add(tc.after)
+		if err := e.Check(ctx, Request{EventID: tc.eventID, UserID: 1, Asset: "BTC", Amount: 1, Address: "addr"}); !errors.Is(err, tc.want) {
+			t.Errorf("%s after %v: got %v, want %v", tc.eventID, tc.after, err, tc.want)
+		}
+	}
+}
+
+func TestNewAddressRule(t *testing.T) {
+	ctx := context.Background()
+	now := time.UnixMilli(100 * time.Hour.Milliseconds())
+	rule := &NewAddressRule{
+		Book:     memBook{"old": 0, "fresh": now.Add(-time.Hour).UnixMilli()},
+		Cooldown: 24 * time.Hour,
+		Score:    30,
+		Now:      func() time.Time { return now },
+	}
+
+	cases := map[string]Decision{"old": 0, "fresh": DecisionHold, "unknown": DecisionHold, "": 0}
+	for addr, want := range cases {
+		a, err := rule.Evaluate(ctx, Request{UserID: 1, Address: addr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Decision != want {
+			t.Errorf("address %q: expected %v, got %v", addr, want, a.Decision)
+		}
+	}
+}
+
+func TestEngine_ScoreThresholds(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine(NewMemoryStore(),
+		&LargeAmountRule{Thresholds: map[string]int64{"BTC": 10}, Score: 50},
+		&NewAddressRule{Book: memBook{}, Score: 60},
+	)
+	e.SetScoreThresholds(40, 100)
+
+	// 新地址 + 大额 = 110 分，直接拒绝
+	err := e.Check(ctx, Request{EventID: "w1", UserID: 1, Asset: "BTC", Amount: 10, Address: "x"})
+	if !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected ErrDenied, got %v", err)
+	}
+	if err := e.Review(ctx, "w1", true, "alice"); !errors.Is(err, ErrNotHeld) {
+		t.Fatalf("denied decision must not be reviewable, got %v", err)
+	}
+}
+
+func TestEngine_FailClosed(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	e := NewEngine(store, failRule{})
+
+	if err := e.Check(ctx, Request{EventID: "w1", UserID: 1, Asset: "BTC", Amount: 1}); err == nil {
+		t.Fatal("rule error must block the withdrawal")
+	}
+	if _, err := store.Get(ctx, "w1"); !errors.Is(err, ErrDecisionNotFound) {
+		t.Fatalf("no decision should be persisted on error, got %v", err)
+	}
+}